@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tokenizationv2 "github.com/paymentgateway/api/tokenization/v2"
+)
+
+// maxAuditPage is the largest page the tokenization service returns
+const maxAuditPage = 1000
+
+func auditTailCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("audit tail", commands["audit"]["tail"].usage)
+	n := fs.Int("n", 20, "number of recent records to show first")
+	follow := fs.Bool("follow", false, "keep printing new records until interrupted")
+	interval := fs.Duration("interval", 2*time.Second, "how often -follow polls")
+	operation := fs.String("operation", "", "only this operation, e.g. detokenize")
+	token := fs.String("token", "", "only records for this token")
+	outcome := fs.String("outcome", "", "only success or failure")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	req := &tokenizationv2.ListAuditRecordsRequest{
+		Operation:  *operation,
+		MerchantId: c.merchantID,
+		Token:      *token,
+		Outcome:    *outcome,
+		PageSize:   int32(min(max(*n, 1), maxAuditPage)),
+	}
+
+	resp, err := tc.ListAuditRecords(ctx, req)
+	if err != nil {
+		return err
+	}
+	var last *tokenizationv2.AuditRecord
+	// Newest first; print oldest first like tail
+	for i := len(resp.Records) - 1; i >= 0 && *n > 0; i-- {
+		c.printAudit(resp.Records[i])
+	}
+	if len(resp.Records) > 0 {
+		last = resp.Records[0]
+	}
+	if !*follow {
+		return nil
+	}
+
+	req.PageSize = maxAuditPage
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if last != nil {
+			// since is inclusive and in seconds; records already shown
+			// are skipped by sequence number
+			req.Since = last.Time / 1000
+		}
+		resp, err := tc.ListAuditRecords(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for i := len(resp.Records) - 1; i >= 0; i-- {
+			r := resp.Records[i]
+			if last != nil && r.Seq <= last.Seq {
+				continue
+			}
+			c.printAudit(r)
+			last = r
+		}
+	}
+}
+
+func (c *ctl) printAudit(r *tokenizationv2.AuditRecord) {
+	detail := r.Error
+	if r.Detail != "" {
+		detail = r.Detail
+	}
+	c.print(r, "%s\t%s\t%s\t%s\tmerchant=%s\ttoken=%s\trequest=%s\t%.1fms\t%s",
+		time.UnixMilli(r.Time).UTC().Format(time.RFC3339Nano), r.Operation, r.Outcome, r.Principal,
+		r.MerchantId, r.Token, r.RequestId, r.LatencyMs, detail)
+}
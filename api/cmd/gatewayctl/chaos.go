@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	hsmv1 "github.com/paymentgateway/api/hsm/v1"
+)
+
+// chaosLatencyCmd gives the HSM a performance profile through its config
+// file, which it reloads on change or SIGHUP. Other settings in the file
+// are kept.
+func chaosLatencyCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("chaos hsm-latency", commands["chaos"]["hsm-latency"].usage)
+	path := fs.String("config-file", os.Getenv("HSM_CONFIG_FILE"), "the HSM's HSM_CONFIG_FILE")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg := map[string]json.RawMessage{}
+	data, err := os.ReadFile(*path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("reading %s: %w", *path, err)
+		}
+	}
+	profile, _ := json.Marshal(pos[0])
+	cfg["profile"] = profile
+	if data, err = json.MarshalIndent(cfg, "", "  "); err != nil {
+		return err
+	}
+
+	// Replace the file in one step so the HSM never reads half of it
+	tmp, err := os.CreateTemp(filepath.Dir(*path), ".hsm-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *path); err != nil {
+		return err
+	}
+	fmt.Printf("HSM profile set to %s in %s; it applies at the next reload (an invalid profile is rejected and logged)\n", pos[0], *path)
+	return nil
+}
+
+// chaosRotateCmd rotates a key repeatedly, leaving tokens encrypted under
+// several older versions that must all still detokenize
+func chaosRotateCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("chaos rotate-storm", commands["chaos"]["rotate-storm"].usage)
+	count := fs.Int("count", 10, "number of rotations")
+	interval := fs.Duration("interval", 0, "pause between rotations")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < *count; i++ {
+		if i > 0 && *interval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(*interval):
+			}
+		}
+		resp, err := hc.RotateKey(ctx, &hsmv1.RotateKeyRequest{KeyId: pos[0]})
+		if err != nil {
+			return fmt.Errorf("rotation %d: %w", i+1, err)
+		}
+		c.print(resp, "%s\tversion %d -> %d", resp.KeyId, resp.OldVersion, resp.NewVersion)
+	}
+	return nil
+}
+
+// chaosFailoverCmd promotes the standby of an HSM pair, as an operator
+// would after losing the primary. Stop the primary as well to see the
+// tokenization service fail over to it.
+func chaosFailoverCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("chaos failover", commands["chaos"]["failover"].usage)
+	standby := fs.String("standby", "", "gRPC address of the standby HSM")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *standby == "" {
+		fs.Usage()
+		return errUsage
+	}
+	hc, conn, err := hsmv1.Dial(ctx, *standby, c.options()...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := hc.PromoteStandby(ctx, &hsmv1.PromoteStandbyRequest{})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "promoted %s\tapplied change %d", *standby, resp.AppliedSeq)
+	return nil
+}
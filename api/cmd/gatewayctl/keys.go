@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	hsmv1 "github.com/paymentgateway/api/hsm/v1"
+	tokenizationv2 "github.com/paymentgateway/api/tokenization/v2"
+)
+
+func generateKeyCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key generate", commands["key"]["generate"].usage)
+	algorithm := fs.String("algorithm", "AES-256-GCM", "key algorithm")
+	keyType := fs.String("type", "", "key type in the hierarchy (default DEK)")
+	mode := fs.String("mode", "", "TR-31 mode of use, e.g. E to encrypt only (default the type's widest)")
+	exportability := fs.String("exportability", "", "TR-31 exportability (default E for working keys)")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.GenerateKey(ctx, &hsmv1.GenerateKeyRequest{
+		KeyId: pos[0], Algorithm: *algorithm, KeyType: *keyType, ModeOfUse: *mode, Exportability: *exportability,
+	})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s\t%s\tmode %s\texportability %s\tversion %d\t%s\tKCV %s", resp.KeyId, resp.KeyType,
+		resp.ModeOfUse, resp.Exportability, resp.Version, resp.Algorithm, resp.Kcv)
+	return nil
+}
+
+func rotateKeyCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key rotate", commands["key"]["rotate"].usage)
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.RotateKey(ctx, &hsmv1.RotateKeyRequest{KeyId: pos[0]})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s\tversion %d -> %d", resp.KeyId, resp.OldVersion, resp.NewVersion)
+	return nil
+}
+
+func keyInfoCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key info", commands["key"]["info"].usage)
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.GetKeyInfo(ctx, &hsmv1.GetKeyInfoRequest{KeyId: pos[0]})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s\t%s\tmode %s\texportability %s\t%s\tversion %d of %v\tKCV %s\tcreated %s", resp.KeyId, resp.KeyType,
+		resp.ModeOfUse, resp.Exportability, resp.Algorithm, resp.CurrentVersion, resp.AvailableVersions, resp.Kcv, unixTime(resp.CreatedAt))
+	return nil
+}
+
+func exportKeyCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key export", commands["key"]["export"].usage)
+	zmk := fs.String("zmk", "", "zone master key to export under")
+	mode := fs.String("mode", "", "narrower TR-31 mode of use to bind into the block")
+	exportability := fs.String("exportability", "", "narrower TR-31 exportability to bind into the block")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *zmk == "" {
+		fs.Usage()
+		return errUsage
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.ExportKey(ctx, &hsmv1.ExportKeyRequest{
+		KeyId: pos[0], ZmkId: *zmk, ModeOfUse: *mode, Exportability: *exportability,
+	})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s\tKCV %s", resp.KeyBlock, resp.Kcv)
+	return nil
+}
+
+func deriveIPEKCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key derive-ipek", commands["key"]["derive-ipek"].usage)
+	ksnHex := fs.String("ksn", "", "key serial number to inject, 20 hex digits")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	ksn, err := hex.DecodeString(*ksnHex)
+	if err != nil || len(ksn) != 10 {
+		fs.Usage()
+		return errUsage
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.DeriveIPEK(ctx, &hsmv1.DeriveIPEKRequest{BdkId: pos[0], Ksn: ksn})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%X\tKCV %s", resp.Ipek, resp.Kcv)
+	return nil
+}
+
+func importKeyBlockCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key import-block", commands["key"]["import-block"].usage)
+	zmk := fs.String("zmk", "", "zone master key the block is under")
+	kcv := fs.String("kcv", "", "expected key check value")
+	rotate := fs.Bool("rotate", false, "import a key change as a new version of KEY_ID")
+	pos, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if *zmk == "" {
+		fs.Usage()
+		return errUsage
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.ImportKeyBlock(ctx, &hsmv1.ImportKeyBlockRequest{KeyId: pos[0], ZmkId: *zmk, KeyBlock: pos[1], Kcv: *kcv, Rotate: *rotate})
+	if err != nil {
+		return err
+	}
+	if op := resp.Operation; op != nil && op.State != "executed" {
+		// Pending approval by a second operator, or failed
+		c.print(resp, "%s\t%s\t%s\t%s\t%s", op.Id, op.Kind, op.KeyId, op.State, op.Error)
+		return nil
+	}
+	c.print(resp, "%s\t%s\tmode %s\texportability %s\tversion %d\tKCV %s", resp.KeyId, resp.KeyType,
+		resp.ModeOfUse, resp.Exportability, resp.Version, resp.Kcv)
+	return nil
+}
+
+// hsmState is the part of the HSM's state dump that lists keys
+type hsmState struct {
+	Keys []struct {
+		KeyID          string `json:"key_id"`
+		Algorithm      string `json:"algorithm"`
+		CurrentVersion int    `json:"current_version"`
+		Versions       []struct {
+			Version int `json:"version"`
+		} `json:"versions"`
+		LastRotatedAt time.Time `json:"last_rotated_at"`
+	} `json:"keys"`
+}
+
+// listKeysCmd lists keys from the state dump, since the HSM API has no
+// key listing of its own
+func listKeysCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key list", commands["key"]["list"].usage)
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.DumpState(ctx, &hsmv1.DumpStateRequest{})
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput {
+		fmt.Println(resp.StateJson)
+		return nil
+	}
+	var state hsmState
+	if err := json.Unmarshal([]byte(resp.StateJson), &state); err != nil {
+		return fmt.Errorf("reading HSM state: %w", err)
+	}
+	for _, k := range state.Keys {
+		rotated := "never rotated"
+		if !k.LastRotatedAt.IsZero() {
+			rotated = "rotated " + k.LastRotatedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("%s\t%s\tversion %d (%d available)\t%s\n", k.KeyID, k.Algorithm, k.CurrentVersion, len(k.Versions), rotated)
+	}
+	return nil
+}
+
+// rotateVaultCmd starts a key rotation in the tokenization service, which
+// rotates the HSM key, re-encrypts the vault and retires the old version,
+// and with -wait follows it to the end
+func rotateVaultCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key rotate-vault", commands["key"]["rotate-vault"].usage)
+	resume := fs.Bool("resume", false, "finish a failed or interrupted rotation without rotating again")
+	wait := fs.Bool("wait", false, "print progress until the rotation finishes")
+	interval := fs.Duration("interval", 2*time.Second, "how often -wait polls")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.StartKeyRotation(ctx, &tokenizationv2.StartKeyRotationRequest{Resume: *resume})
+	if err != nil {
+		return err
+	}
+	c.printRotation(resp.Rotation)
+	if !*wait {
+		return nil
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		resp, err := tc.GetKeyRotation(ctx, &tokenizationv2.GetKeyRotationRequest{})
+		if err != nil {
+			return err
+		}
+		c.printRotation(resp.Rotation)
+		switch resp.Rotation.State {
+		case "completed":
+			return nil
+		case "failed":
+			return fmt.Errorf("rotation %s failed: %s", resp.Rotation.Id, resp.Rotation.Error)
+		}
+	}
+}
+
+func rotationStatusCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("key rotation", commands["key"]["rotation"].usage)
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.GetKeyRotation(ctx, &tokenizationv2.GetKeyRotationRequest{})
+	if err != nil {
+		return err
+	}
+	c.printRotation(resp.Rotation)
+	return nil
+}
+
+func (c *ctl) printRotation(r *tokenizationv2.KeyRotation) {
+	retiring := make([]string, 0, len(r.Retiring))
+	for _, v := range r.Retiring {
+		s := fmt.Sprintf("%d", v.Version)
+		if v.State != "" {
+			s += " " + v.State
+		}
+		retiring = append(retiring, s)
+	}
+	c.print(r, "%s\t%s\t%s -> version %d\t%d/%d migrated, %d remaining\tretiring %v%s",
+		r.Id, r.State, r.KeyId, r.NewVersion, r.Migrated, r.Total, r.Remaining, retiring, errorSuffix(r.Error))
+}
+
+func errorSuffix(msg string) string {
+	if msg == "" {
+		return ""
+	}
+	return "\t" + msg
+}
+
+// permissionsCmd shows the HSM's permission matrix and what the caller, or
+// the given principal and roles, may do under it
+func permissionsCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("hsm permissions", commands["hsm"]["permissions"].usage)
+	principal := fs.String("principal", "", "principal to evaluate instead of the caller")
+	roles := fs.String("roles", "", "roles to evaluate, separated by |")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	req := &hsmv1.GetPermissionsRequest{PrincipalId: *principal}
+	if *roles != "" {
+		req.Roles = strings.Split(*roles, "|")
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.GetPermissions(ctx, req)
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput {
+		c.print(resp, "")
+		return nil
+	}
+	fmt.Printf("%s\troles %s\n", resp.PrincipalId, strings.Join(resp.Roles, "|"))
+	fmt.Printf("  may call %s\n", strings.Join(resp.Commands, ", "))
+	fmt.Println("Matrix:")
+	for _, g := range resp.Matrix {
+		fmt.Printf("  %s\t%s\n", g.Subject, strings.Join(g.Commands, ", "))
+	}
+	return nil
+}
+
+// generateCVVCmd computes a test card's CVV2, or another of its CVVs with
+// -service-code, as the issuer does when it personalizes the card
+func generateCVVCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("hsm generate-cvv", commands["hsm"]["generate-cvv"].usage)
+	cvk := fs.String("cvk", "", "card verification key")
+	expiry := fs.String("expiry", "", "card expiry date as YYMM")
+	serviceCode := fs.String("service-code", "", "service code (default 000, the CVV2)")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *cvk == "" || *expiry == "" {
+		fs.Usage()
+		return errUsage
+	}
+	// The PAN is read from stdin so it stays out of shell history and ps
+	pan, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if pan = strings.TrimSpace(pan); pan == "" {
+		return fmt.Errorf("no PAN on stdin: %v", err)
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.GenerateCVV(ctx, &hsmv1.GenerateCVVRequest{CvkId: *cvk, Pan: pan, Expiry: *expiry, ServiceCode: *serviceCode})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s", resp.Cvv)
+	return nil
+}
@@ -0,0 +1,232 @@
+// Command gatewayctl works with the tokenization service and the HSM
+// simulator from the command line: tokens, HSM keys, ZMK establishment, the
+// audit trail and chaos scenarios for trying out failure handling.
+//
+//	gatewayctl [global flags] <group> <command> [flags] [args]
+//
+// Run gatewayctl without arguments for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	hsmv1 "github.com/paymentgateway/api/hsm/v1"
+	"github.com/paymentgateway/api/pkg/client"
+	tokenizationv2 "github.com/paymentgateway/api/tokenization/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// errUsage reports bad arguments; the command's usage has been printed
+var errUsage = errors.New("usage")
+
+// ctl holds the global flags and lazily opened connections
+type ctl struct {
+	tokenizationAddr string
+	hsmAddr          string
+	apiKey           string
+	merchantID       string
+	timeout          time.Duration
+	jsonOutput       bool
+
+	conns        []*grpc.ClientConn
+	tokenization tokenizationv2.TokenizationServiceClient
+	hsm          hsmv1.HSMServiceClient
+}
+
+// command is one subcommand; run gets the arguments after its name
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *ctl, args []string) error
+}
+
+// commands by group and name, set in init because the commands look up
+// their own usage
+var commands map[string]map[string]command
+
+func init() {
+	commands = map[string]map[string]command{
+		"token": {
+			"tokenize":   {"[-expiry MM/YYYY] [-cvv CVV] [-metadata k=v,...] [-ttl DURATION] [-channels C,...] [-merchants M,...] [-requestors R,...]  (PAN on stdin)", tokenizeCmd},
+			"detokenize": {"[-reveal] [-channel C] [-requestor R] TOKEN", detokenizeCmd},
+			"validate":   {"TOKEN", validateCmd},
+			"cryptogram": {"-amount MINOR -currency CUR NETWORK_TOKEN", cryptogramCmd},
+			"revoke":     {"[-if-revision N] TOKEN", revokeCmd},
+			"list":       {"[-active] [-par PAR] [-limit N]", listTokensCmd},
+		},
+		"key": {
+			"generate":     {"[-algorithm ALG] [-type ZMK|ZPK|CVK|DEK|BDK|PVK] [-mode M] [-exportability E|N|S] KEY_ID", generateKeyCmd},
+			"rotate":       {"KEY_ID", rotateKeyCmd},
+			"info":         {"KEY_ID", keyInfoCmd},
+			"export":       {"-zmk ZMK_ID [-mode M] [-exportability E|N|S] KEY_ID", exportKeyCmd},
+			"derive-ipek":  {"-ksn KSN BDK_ID", deriveIPEKCmd},
+			"import-block": {"-zmk ZMK_ID [-kcv KCV] [-rotate] KEY_ID KEY_BLOCK", importKeyBlockCmd},
+			"list":         {"", listKeysCmd},
+			"rotate-vault": {"[-resume] [-wait] [-interval DURATION]", rotateVaultCmd},
+			"rotation":     {"", rotationStatusCmd},
+		},
+		"zmk": {
+			"begin":          {"-kcv KCV [-method components|rsa-oaep] [-components N] KEY_ID", beginZMKCmd},
+			"component":      {"[-kcv KCV] EXCHANGE_ID  (hex component on stdin)", enterComponentCmd},
+			"import-wrapped": {"EXCHANGE_ID  (hex wrapped key on stdin)", importWrappedZMKCmd},
+			"cancel":         {"EXCHANGE_ID", cancelZMKCmd},
+			"list":           {"", listZMKCmd},
+			"acquirer":       {"[-method components|rsa-oaep] [-custodian-keys KEY,KEY,...] [-importer-key KEY] KEY_ID", acquirerCmd},
+		},
+		"hsm": {
+			"permissions":  {"[-principal ID] [-roles ROLE|ROLE...]", permissionsCmd},
+			"generate-cvv": {"-cvk CVK_ID -expiry YYMM [-service-code CODE]  (PAN on stdin)", generateCVVCmd},
+		},
+		"audit": {
+			"tail": {"[-n N] [-follow] [-interval DURATION] [-operation OP] [-token TOKEN] [-outcome success|failure]", auditTailCmd},
+		},
+		"chaos": {
+			"hsm-latency":  {"-config-file PATH PROFILE|none", chaosLatencyCmd},
+			"rotate-storm": {"[-count N] [-interval DURATION] KEY_ID", chaosRotateCmd},
+			"failover":     {"-standby ADDR", chaosFailoverCmd},
+		},
+	}
+}
+
+func main() {
+	c := &ctl{}
+	flag.StringVar(&c.tokenizationAddr, "tokenization-addr", envOr("GATEWAYCTL_TOKENIZATION_ADDR", "localhost:8445"), "tokenization service gRPC address")
+	flag.StringVar(&c.hsmAddr, "hsm-addr", envOr("GATEWAYCTL_HSM_ADDR", "localhost:8444"), "HSM gRPC address")
+	flag.StringVar(&c.apiKey, "api-key", os.Getenv("GATEWAYCTL_API_KEY"), "API key sent to both services")
+	flag.StringVar(&c.merchantID, "merchant", os.Getenv("GATEWAYCTL_MERCHANT"), "merchant ID that tokens are scoped to")
+	flag.DurationVar(&c.timeout, "timeout", client.DefaultTimeout, "deadline for each call")
+	flag.BoolVar(&c.jsonOutput, "json", false, "print responses as JSON")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]][args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gatewayctl: unknown command %q\n\n", strings.Join(args[:2], " "))
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := cmd.run(ctx, c, args[2:])
+	stop()
+	c.close()
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "gatewayctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: gatewayctl [global flags] <group> <command> [flags] [args]\n\nCommands:\n")
+	groups := make([]string, 0, len(commands))
+	for g := range commands {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	for _, g := range groups {
+		names := make([]string, 0, len(commands[g]))
+		for n := range commands[g] {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(os.Stderr, "  %s %s %s\n", g, n, commands[g][n].usage)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\nGlobal flags:\n")
+	flag.PrintDefaults()
+}
+
+// flags creates the flag set of a subcommand
+func flags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gatewayctl %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses a subcommand's flags and checks its positional arguments
+func parse(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if fs.NArg() != positional {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func (c *ctl) options() []client.Option {
+	opts := []client.Option{client.WithTimeout(c.timeout)}
+	if c.apiKey != "" {
+		opts = append(opts, client.WithAPIKey(c.apiKey))
+	}
+	return opts
+}
+
+func (c *ctl) tokenizationClient(ctx context.Context) (tokenizationv2.TokenizationServiceClient, error) {
+	if c.tokenization == nil {
+		tc, conn, err := tokenizationv2.Dial(ctx, c.tokenizationAddr, c.options()...)
+		if err != nil {
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+		c.tokenization = tc
+	}
+	return c.tokenization, nil
+}
+
+func (c *ctl) hsmClient(ctx context.Context) (hsmv1.HSMServiceClient, error) {
+	if c.hsm == nil {
+		hc, conn, err := hsmv1.Dial(ctx, c.hsmAddr, c.options()...)
+		if err != nil {
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+		c.hsm = hc
+	}
+	return c.hsm, nil
+}
+
+func (c *ctl) close() {
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// print writes a response as JSON with -json, or as text otherwise
+func (c *ctl) print(m proto.Message, text string, args ...interface{}) {
+	if c.jsonOutput {
+		fmt.Println(protojson.MarshalOptions{UseProtoNames: true}.Format(m))
+		return
+	}
+	fmt.Printf(text+"\n", args...)
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
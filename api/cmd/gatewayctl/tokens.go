@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tokenizationv2 "github.com/paymentgateway/api/tokenization/v2"
+)
+
+func tokenizeCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("token tokenize", commands["token"]["tokenize"].usage)
+	expiry := fs.String("expiry", "", "card expiry as MM/YYYY (default: December, three years ahead)")
+	cvv := fs.String("cvv", "", "card verification value; never stored")
+	metadata := fs.String("metadata", "", "comma-separated key=value pairs stored with the token")
+	ttl := fs.Duration("ttl", 0, "token lifetime (0 uses the service default)")
+	channels := fs.String("channels", "", "comma-separated channels the token is restricted to: ecommerce, in-store, moto")
+	merchants := fs.String("merchants", "", "comma-separated merchant IDs the token is restricted to")
+	requestors := fs.String("requestors", "", "comma-separated token requestor IDs the token is restricted to")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	// The PAN is read from stdin so it stays out of shell history and ps
+	pan, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if pan = strings.TrimSpace(pan); pan == "" {
+		return fmt.Errorf("no PAN on stdin: %v", err)
+	}
+	month, year := 12, time.Now().Year()+3
+	if *expiry != "" {
+		if month, year, err = parseExpiry(*expiry); err != nil {
+			return err
+		}
+	}
+	md, err := parseMetadata(*metadata)
+	if err != nil {
+		return err
+	}
+
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.TokenizeCard(ctx, &tokenizationv2.TokenizeRequest{
+		Pan:         pan,
+		ExpiryMonth: int32(month),
+		ExpiryYear:  int32(year),
+		Cvv:         *cvv,
+		MerchantId:  c.merchantID,
+		Metadata:    md,
+		TtlSeconds:  int64(ttl.Seconds()),
+		Domain:      parseDomain(*channels, *merchants, *requestors),
+	})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s\t%s ****%s\texpires %s\tpar %s", resp.Token, resp.CardBrand, resp.LastFour, unixTime(resp.ExpiresAt), resp.Par)
+	return nil
+}
+
+func detokenizeCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("token detokenize", commands["token"]["detokenize"].usage)
+	reveal := fs.Bool("reveal", false, "print the full PAN instead of a masked one")
+	channel := fs.String("channel", "", "channel the token is used in, for tokens with domain controls")
+	requestor := fs.String("requestor", "", "token requestor ID, for tokens with domain controls")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.DetokenizeCard(ctx, &tokenizationv2.DetokenizeRequest{
+		Token:      pos[0],
+		MerchantId: c.merchantID,
+		Use:        &tokenizationv2.TokenUse{Channel: *channel, RequestorId: *requestor},
+	})
+	if err != nil {
+		return err
+	}
+	if !*reveal {
+		resp.Pan = maskPAN(resp.Pan)
+	}
+	c.print(resp, "%s\t%02d/%d", resp.Pan, resp.ExpiryMonth, resp.ExpiryYear)
+	return nil
+}
+
+func validateCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("token validate", commands["token"]["validate"].usage)
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.ValidateToken(ctx, &tokenizationv2.ValidateRequest{Token: pos[0], MerchantId: c.merchantID})
+	if err != nil {
+		return err
+	}
+	if resp.Valid {
+		c.print(resp, "valid\texpires %s", unixTime(resp.ExpiresAt))
+		return nil
+	}
+	c.print(resp, "invalid\t%s", resp.ErrorMessage)
+	// Scripts can test the exit status
+	return fmt.Errorf("token %s is not valid", pos[0])
+}
+
+func cryptogramCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("token cryptogram", commands["token"]["cryptogram"].usage)
+	amount := fs.Int64("amount", 0, "transaction amount in minor units")
+	currency := fs.String("currency", "", "ISO 4217 currency code")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.GenerateTokenCryptogram(ctx, &tokenizationv2.GenerateTokenCryptogramRequest{
+		NetworkToken: pos[0],
+		MerchantId:   c.merchantID,
+		Amount:       *amount,
+		Currency:     *currency,
+	})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "%s\tatc %d", base64.StdEncoding.EncodeToString(resp.Cryptogram), resp.Atc)
+	return nil
+}
+
+func revokeCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("token revoke", commands["token"]["revoke"].usage)
+	ifRevision := fs.Int64("if-revision", 0, "only revoke if the token is still at this revision")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := tc.RevokeToken(ctx, &tokenizationv2.RevokeTokenRequest{
+		Token: pos[0], MerchantId: c.merchantID, IfRevision: *ifRevision,
+	})
+	if err != nil {
+		return err
+	}
+	c.print(resp, "revoked\trevision %d", resp.Revision)
+	return nil
+}
+
+func listTokensCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("token list", commands["token"]["list"].usage)
+	active := fs.Bool("active", false, "skip revoked and expired tokens")
+	par := fs.String("par", "", "only the tokens of the card with this payment account reference")
+	limit := fs.Int("limit", 50, "maximum number of tokens")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	tc, err := c.tokenizationClient(ctx)
+	if err != nil {
+		return err
+	}
+	req := &tokenizationv2.ListTokensRequest{MerchantId: c.merchantID, ActiveOnly: *active, Par: *par}
+	for remaining := *limit; remaining > 0; {
+		req.PageSize = int32(min(remaining, 100))
+		resp, err := tc.ListTokens(ctx, req)
+		if err != nil {
+			return err
+		}
+		for _, t := range resp.Tokens {
+			c.print(t, "%s\t%s ****%s\t%02d/%d\t%s", t.Token, t.CardBrand, t.LastFour, t.ExpiryMonth, t.ExpiryYear, t.Par)
+		}
+		remaining -= len(resp.Tokens)
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	return nil
+}
+
+// parseExpiry reads MM/YYYY
+func parseExpiry(s string) (month, year int, err error) {
+	m, y, ok := strings.Cut(s, "/")
+	if ok {
+		month, err = strconv.Atoi(m)
+	}
+	if ok && err == nil {
+		year, err = strconv.Atoi(y)
+	}
+	if !ok || err != nil || month < 1 || month > 12 {
+		return 0, 0, fmt.Errorf("invalid expiry %q, want MM/YYYY", s)
+	}
+	return month, year, nil
+}
+
+// parseMetadata reads comma-separated key=value pairs
+func parseMetadata(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	md := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid metadata %q, want key=value", pair)
+		}
+		md[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return md, nil
+}
+
+// maskPAN keeps the first six and last four digits, as PCI DSS allows
+// parseDomain reads comma-separated domain control lists; nil if all are
+// empty
+func parseDomain(channels, merchants, requestors string) *tokenizationv2.TokenDomain {
+	if channels == "" && merchants == "" && requestors == "" {
+		return nil
+	}
+	return &tokenizationv2.TokenDomain{
+		Channels:     splitList(channels),
+		MerchantIds:  splitList(merchants),
+		RequestorIds: splitList(requestors),
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func maskPAN(pan string) string {
+	if len(pan) < 13 {
+		return strings.Repeat("*", len(pan))
+	}
+	return pan[:6] + strings.Repeat("*", len(pan)-10) + pan[len(pan)-4:]
+}
+
+func unixTime(sec int64) string {
+	if sec == 0 {
+		return "never"
+	}
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+}
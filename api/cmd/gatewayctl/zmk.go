@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	hsmv1 "github.com/paymentgateway/api/hsm/v1"
+	"github.com/paymentgateway/api/pkg/client"
+	"google.golang.org/protobuf/proto"
+)
+
+func beginZMKCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("zmk begin", commands["zmk"]["begin"].usage)
+	method := fs.String("method", "components", "how the ZMK arrives: components or rsa-oaep")
+	components := fs.Int("components", 3, "how many components form the ZMK")
+	kcv := fs.String("kcv", "", "key check value the other party announced for the ZMK")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *kcv == "" {
+		fs.Usage()
+		return errUsage
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.BeginZMKExchange(ctx, &hsmv1.BeginZMKExchangeRequest{
+		KeyId: pos[0], Method: *method, Components: int32(*components), Kcv: *kcv,
+	})
+	if err != nil {
+		return err
+	}
+	c.printExchange(resp, resp.Exchange)
+	return nil
+}
+
+func enterComponentCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("zmk component", commands["zmk"]["component"].usage)
+	kcv := fs.String("kcv", "", "key check value of the component")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	// The component is read from stdin so it stays out of shell history and ps
+	component, err := readHex("component")
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.EnterZMKComponent(ctx, &hsmv1.EnterZMKComponentRequest{
+		ExchangeId: pos[0], Component: component, ComponentKcv: *kcv,
+	})
+	if err != nil {
+		return err
+	}
+	c.printExchange(resp, resp.Exchange)
+	return nil
+}
+
+func importWrappedZMKCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("zmk import-wrapped", commands["zmk"]["import-wrapped"].usage)
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	wrapped, err := readHex("wrapped key")
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.ImportWrappedZMK(ctx, &hsmv1.ImportWrappedZMKRequest{ExchangeId: pos[0], WrappedKey: wrapped})
+	if err != nil {
+		return err
+	}
+	c.printExchange(resp, resp.Exchange)
+	return nil
+}
+
+func cancelZMKCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("zmk cancel", commands["zmk"]["cancel"].usage)
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.CancelZMKExchange(ctx, &hsmv1.CancelZMKExchangeRequest{ExchangeId: pos[0]})
+	if err != nil {
+		return err
+	}
+	c.printExchange(resp, resp.Exchange)
+	return nil
+}
+
+func listZMKCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("zmk list", commands["zmk"]["list"].usage)
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.ListZMKExchanges(ctx, &hsmv1.ListZMKExchangesRequest{})
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput {
+		c.print(resp, "")
+		return nil
+	}
+	for _, ex := range resp.Exchanges {
+		c.printExchange(ex, ex)
+	}
+	return nil
+}
+
+// acquirerCmd plays an acquirer establishing a ZMK with the HSM: it
+// generates the ZMK, begins the exchange with the global API key, and then
+// either has one custodian per API key enter a component, or wraps the ZMK
+// under the exchange's RSA key and imports it. The ZMK is never printed,
+// only its KCV.
+func acquirerCmd(ctx context.Context, c *ctl, args []string) error {
+	fs := flags("zmk acquirer", commands["zmk"]["acquirer"].usage)
+	method := fs.String("method", "components", "how the ZMK is sent: components or rsa-oaep")
+	custodianKeys := fs.String("custodian-keys", "", "comma-separated API keys, one per custodian and component")
+	importerKey := fs.String("importer-key", "", "API key that imports a wrapped ZMK (default the global one)")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if *custodianKeys != "" {
+		keys = strings.Split(*custodianKeys, ",")
+	}
+	if *method == "components" && len(keys) < 2 {
+		fmt.Fprintln(fs.Output(), "the components method needs -custodian-keys for at least 2 custodians")
+		fs.Usage()
+		return errUsage
+	}
+
+	zmk := make([]byte, 32)
+	if _, err := rand.Read(zmk); err != nil {
+		return err
+	}
+	kcv := keyCheckValue(zmk)
+	hc, err := c.hsmClient(ctx)
+	if err != nil {
+		return err
+	}
+	begun, err := hc.BeginZMKExchange(ctx, &hsmv1.BeginZMKExchangeRequest{
+		KeyId: pos[0], Method: *method, Components: int32(len(keys)), Kcv: kcv,
+	})
+	if err != nil {
+		return err
+	}
+	ex := begun.Exchange
+	fmt.Fprintf(os.Stderr, "acquirer: exchange %s for %s, ZMK KCV %s\n", ex.Id, ex.KeyId, kcv)
+
+	var resp *hsmv1.ZMKExchangeResponse
+	switch *method {
+	case "components":
+		for i, component := range splitComponents(zmk, len(keys)) {
+			custodian, err := c.hsmClientAs(ctx, keys[i])
+			if err != nil {
+				return err
+			}
+			resp, err = custodian.EnterZMKComponent(ctx, &hsmv1.EnterZMKComponentRequest{
+				ExchangeId: ex.Id, Component: component, ComponentKcv: keyCheckValue(component),
+			})
+			if err != nil {
+				return fmt.Errorf("component %d: %w", i+1, err)
+			}
+		}
+	default:
+		pub, err := x509.ParsePKIXPublicKey(ex.PublicKey)
+		if err != nil {
+			return fmt.Errorf("exchange public key: %w", err)
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("exchange public key is %T, want RSA", pub)
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaKey, zmk, nil)
+		if err != nil {
+			return err
+		}
+		importer := hc
+		if *importerKey != "" {
+			if importer, err = c.hsmClientAs(ctx, *importerKey); err != nil {
+				return err
+			}
+		}
+		if resp, err = importer.ImportWrappedZMK(ctx, &hsmv1.ImportWrappedZMKRequest{ExchangeId: ex.Id, WrappedKey: wrapped}); err != nil {
+			return err
+		}
+	}
+	c.printExchange(resp, resp.Exchange)
+	return nil
+}
+
+// hsmClientAs opens another HSM connection with a different API key, for
+// operators other than the global one
+func (c *ctl) hsmClientAs(ctx context.Context, apiKey string) (hsmv1.HSMServiceClient, error) {
+	hc, conn, err := hsmv1.Dial(ctx, c.hsmAddr, client.WithTimeout(c.timeout), client.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	c.conns = append(c.conns, conn)
+	return hc, nil
+}
+
+// printExchange prints an exchange as a line of text, or m as JSON; m is the
+// response holding the exchange, or the exchange itself when listing
+func (c *ctl) printExchange(m proto.Message, ex *hsmv1.ZMKExchange) {
+	text := "%s\t%s\t%s\t%s\tKCV %s\tcustodians %v of %d\texpires %s"
+	args := []interface{}{ex.Id, ex.KeyId, ex.Method, ex.State, ex.Kcv, ex.Custodians, ex.Components, unixTime(ex.ExpiresAt)}
+	if ex.Error != "" {
+		text += "\t%s"
+		args = append(args, ex.Error)
+	}
+	if len(ex.PublicKey) > 0 && ex.State == "pending" {
+		text += "\n%X"
+		args = append(args, ex.PublicKey)
+	}
+	c.print(m, text, args...)
+}
+
+func readHex(what string) ([]byte, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if line = strings.TrimSpace(line); line == "" {
+		return nil, fmt.Errorf("no %s on stdin: %v", what, err)
+	}
+	b, err := hex.DecodeString(line)
+	if err != nil {
+		return nil, fmt.Errorf("%s on stdin is not hex: %w", what, err)
+	}
+	return b, nil
+}
+
+// splitComponents splits a key into n random components whose XOR is the
+// key
+func splitComponents(key []byte, n int) [][]byte {
+	components := make([][]byte, n)
+	last := append([]byte(nil), key...)
+	for i := 0; i < n-1; i++ {
+		components[i] = make([]byte, len(key))
+		rand.Read(components[i])
+		for j := range last {
+			last[j] ^= components[i][j]
+		}
+	}
+	components[n-1] = last
+	return components
+}
+
+// keyCheckValue is the HSM's KCV: the leftmost three bytes of the AES-CMAC
+// of a zero block, as hex
+func keyCheckValue(key []byte) string {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return ""
+	}
+	// CMAC of one complete block: encrypt it XORed with subkey K1
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	k1 := make([]byte, aes.BlockSize)
+	for i := 0; i < aes.BlockSize; i++ {
+		k1[i] = l[i] << 1
+		if i+1 < aes.BlockSize {
+			k1[i] |= l[i+1] >> 7
+		}
+	}
+	if l[0]&0x80 != 0 {
+		k1[aes.BlockSize-1] ^= 0x87
+	}
+	block.Encrypt(k1, k1)
+	return strings.ToUpper(hex.EncodeToString(k1[:3]))
+}
@@ -0,0 +1,280 @@
+// Command gatewaytop is a terminal dashboard for watching a simulation run,
+// typically during a load test. It polls the services' metrics endpoints
+// and the gateway's REST API and shows authorization approval rates, HSM
+// and tokenization latency, and webhook delivery status.
+//
+//	gatewaytop [-interval 2s] [-gateway-url URL -api-key KEY]
+//
+// Sources that are not running are shown as unavailable, so the dashboard
+// also works against the Go services alone.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/paymentgateway/api/pkg/client"
+)
+
+// sources are the endpoints polled on every refresh
+type sources struct {
+	hsmMetrics          string
+	tokenizationMetrics string
+	gatewayURL          string
+	apiKey              string
+	transactions        int
+}
+
+// poll is everything fetched in one refresh; a nil field with its error
+// set means the source was unavailable
+type poll struct {
+	at           time.Time
+	hsm          snapshot
+	tokenization snapshot
+	gateway      snapshot
+	transactions []client.Payment
+	deliveries   []client.WebhookDelivery
+	errs         map[string]error
+}
+
+func main() {
+	var src sources
+	flag.StringVar(&src.hsmMetrics, "hsm-metrics", envOr("GATEWAYTOP_HSM_METRICS", "http://localhost:9444/metrics"), "HSM Prometheus endpoint")
+	flag.StringVar(&src.tokenizationMetrics, "tokenization-metrics", envOr("GATEWAYTOP_TOKENIZATION_METRICS", "http://localhost:9445/metrics"), "tokenization service Prometheus endpoint")
+	flag.StringVar(&src.gatewayURL, "gateway-url", os.Getenv("GATEWAYTOP_GATEWAY_URL"), "authorization service root, e.g. https://localhost:8446 (empty skips the gateway)")
+	flag.StringVar(&src.apiKey, "api-key", os.Getenv("GATEWAYTOP_API_KEY"), "merchant API key for the gateway's transactions and webhooks")
+	flag.IntVar(&src.transactions, "transactions", 100, "recent transactions the approval rate is computed over")
+	interval := flag.Duration("interval", 2*time.Second, "refresh interval")
+	insecure := flag.Bool("insecure", false, "skip TLS verification, for the gateway's self-signed development certificate")
+	once := flag.Bool("once", false, "print a single frame without redrawing, e.g. for CI logs")
+	flag.Parse()
+
+	hc := &http.Client{Timeout: client.DefaultTimeout}
+	if *insecure {
+		hc.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	var gw *client.Gateway
+	if src.gatewayURL != "" {
+		gw = client.NewGateway(src.gatewayURL, src.apiKey, hc)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Rates need two scrapes, so -once takes a second one after the interval
+	prev := fetch(ctx, hc, gw, src)
+	if *once {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+		render(os.Stdout, build(prev, fetch(ctx, hc, gw, src)), false)
+		return
+	}
+
+	// Alternate screen with the cursor hidden; both restored on exit
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+	render(os.Stdout, build(prev, prev), true)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cur := fetch(ctx, hc, gw, src)
+		render(os.Stdout, build(prev, cur), true)
+		prev = cur
+	}
+}
+
+// fetch polls every source concurrently
+func fetch(ctx context.Context, hc *http.Client, gw *client.Gateway, src sources) *poll {
+	p := &poll{at: time.Now(), errs: map[string]error{}}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	run := func(name string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				mu.Lock()
+				p.errs[name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+
+	run("hsm", func() (err error) {
+		p.hsm, err = scrape(ctx, hc, src.hsmMetrics)
+		return err
+	})
+	run("tokenization", func() (err error) {
+		p.tokenization, err = scrape(ctx, hc, src.tokenizationMetrics)
+		return err
+	})
+	if gw != nil {
+		run("gateway", func() (err error) {
+			p.gateway, err = scrape(ctx, hc, strings.TrimRight(src.gatewayURL, "/")+"/actuator/prometheus")
+			return err
+		})
+		run("transactions", func() (err error) {
+			p.transactions, err = gw.RecentTransactions(ctx, src.transactions)
+			return err
+		})
+		run("webhooks", func() (err error) {
+			p.deliveries, err = gw.WebhookDeliveries(ctx)
+			return err
+		})
+	}
+	wg.Wait()
+	return p
+}
+
+// rpcStats summarises one gRPC method between two scrapes
+type rpcStats struct {
+	method    string
+	perSecond float64
+	errors    float64
+	p50, p95  float64
+}
+
+// rpcTable computes per-method rates and latency quantiles from the
+// grpc_server_* families the Go services export
+func rpcTable(prev, cur snapshot, seconds float64) []rpcStats {
+	var rows []rpcStats
+	for _, method := range cur.values("grpc_server_handled_total", "method") {
+		m := map[string]string{"method": method}
+		total := delta(prev.sum("grpc_server_handled_total", m), cur.sum("grpc_server_handled_total", m))
+		ok := delta(
+			prev.sum("grpc_server_handled_total", map[string]string{"method": method, "code": "OK"}),
+			cur.sum("grpc_server_handled_total", map[string]string{"method": method, "code": "OK"}))
+		pb, cb := prev.buckets("grpc_server_handling_seconds", m), cur.buckets("grpc_server_handling_seconds", m)
+		rows = append(rows, rpcStats{
+			method:    method[strings.LastIndexByte(method, '/')+1:],
+			perSecond: total / seconds,
+			errors:    (total - ok) / seconds,
+			p50:       quantile(0.5, pb, cb),
+			p95:       quantile(0.95, pb, cb),
+		})
+	}
+	return rows
+}
+
+// frame is what one redraw shows
+type frame struct {
+	at   time.Time
+	errs map[string]error
+
+	hsm          []rpcStats
+	tokenization []rpcStats
+	breakers     map[string]float64
+	cacheHitRate float64
+
+	gatewayEnabled bool
+	paymentsPerSec float64
+	avgPaymentMs   float64
+	approved       int
+	declined       int
+	pending        int
+	transactions   []client.Payment
+
+	outboxPending    float64
+	outboxPublished  float64
+	outboxFailed     float64
+	deliveryStatuses map[string]int
+	failedDeliveries []client.WebhookDelivery
+}
+
+// approvedStatuses are the payment states reached only after an approval
+var approvedStatuses = map[string]bool{
+	client.StatusAuthorized: true,
+	client.StatusCaptured:   true,
+	client.StatusSettled:    true,
+	client.StatusRefunded:   true,
+}
+
+func build(prev, cur *poll) *frame {
+	seconds := cur.at.Sub(prev.at).Seconds()
+	if seconds <= 0 {
+		seconds = math.Inf(1)
+	}
+	f := &frame{
+		at:               cur.at,
+		errs:             cur.errs,
+		hsm:              rpcTable(prev.hsm, cur.hsm, seconds),
+		tokenization:     rpcTable(prev.tokenization, cur.tokenization, seconds),
+		breakers:         map[string]float64{},
+		cacheHitRate:     math.NaN(),
+		gatewayEnabled:   cur.gateway != nil || cur.errs["gateway"] != nil,
+		avgPaymentMs:     math.NaN(),
+		deliveryStatuses: map[string]int{},
+	}
+
+	for _, dep := range cur.tokenization.values("circuit_breaker_state", "dependency") {
+		f.breakers[dep] = cur.tokenization.sum("circuit_breaker_state", map[string]string{"dependency": dep})
+	}
+	hits := delta(
+		prev.tokenization.sum("tokenization_datakey_cache_lookups_total", map[string]string{"result": "hit"}),
+		cur.tokenization.sum("tokenization_datakey_cache_lookups_total", map[string]string{"result": "hit"}))
+	lookups := delta(
+		prev.tokenization.sum("tokenization_datakey_cache_lookups_total", nil),
+		cur.tokenization.sum("tokenization_datakey_cache_lookups_total", nil))
+	if lookups > 0 {
+		f.cacheHitRate = hits / lookups
+	}
+
+	// Micrometer's names for payments.processed and payments.processing.time
+	f.paymentsPerSec = delta(prev.gateway.sum("payments_processed_total", nil), cur.gateway.sum("payments_processed_total", nil)) / seconds
+	count := delta(prev.gateway.sum("payments_processing_time_seconds_count", nil), cur.gateway.sum("payments_processing_time_seconds_count", nil))
+	if count > 0 {
+		sum := delta(prev.gateway.sum("payments_processing_time_seconds_sum", nil), cur.gateway.sum("payments_processing_time_seconds_sum", nil))
+		f.avgPaymentMs = sum / count * 1000
+	}
+	f.outboxPending = cur.gateway.sum("outbox_events_pending", nil)
+	f.outboxPublished = delta(prev.gateway.sum("outbox_events_published_total", nil), cur.gateway.sum("outbox_events_published_total", nil)) / seconds
+	f.outboxFailed = delta(prev.gateway.sum("outbox_events_failed_total", nil), cur.gateway.sum("outbox_events_failed_total", nil)) / seconds
+
+	for _, p := range cur.transactions {
+		switch {
+		case approvedStatuses[p.Status]:
+			f.approved++
+		case p.Status == client.StatusDeclined || p.Status == client.StatusFailed:
+			f.declined++
+		case p.Status == client.StatusPending:
+			f.pending++
+		}
+	}
+	f.transactions = cur.transactions
+
+	for _, d := range cur.deliveries {
+		f.deliveryStatuses[d.Status]++
+		if d.Status == "FAILED" || (d.Status == "PENDING" && d.AttemptCount > 0) {
+			f.failedDeliveries = append(f.failedDeliveries, d)
+		}
+	}
+	return f
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
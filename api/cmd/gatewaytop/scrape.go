@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sample is one line of the Prometheus text format
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// snapshot is one scrape of a metrics endpoint
+type snapshot []sample
+
+// scrape fetches and parses a Prometheus text exposition
+func scrape(ctx context.Context, hc *http.Client, url string) (snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP %d", url, resp.StatusCode)
+	}
+	return parseSnapshot(resp.Body)
+}
+
+// parseSnapshot reads the sample lines of the text format, skipping
+// comments, timestamps and anything it cannot read
+func parseSnapshot(r io.Reader) (snapshot, error) {
+	var snap snapshot
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		s := sample{labels: map[string]string{}}
+		rest := line
+		if i := strings.IndexAny(line, "{ "); i < 0 {
+			continue
+		} else if line[i] == '{' {
+			end := strings.LastIndexByte(line, '}')
+			if end < i {
+				continue
+			}
+			s.name = line[:i]
+			parseLabels(line[i+1:end], s.labels)
+			rest = line[end+1:]
+		} else {
+			s.name = line[:i]
+			rest = line[i:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		s.value = v
+		snap = append(snap, s)
+	}
+	return snap, sc.Err()
+}
+
+// parseLabels reads name="value" pairs, unescaping the values
+func parseLabels(s string, into map[string]string) {
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return
+		}
+		name := strings.TrimSpace(s[:eq])
+		var b strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					b.WriteByte('\n')
+					continue
+				}
+			}
+			b.WriteByte(s[i])
+		}
+		into[name] = b.String()
+		s = strings.TrimLeft(s[min(i+1, len(s)):], ", ")
+	}
+}
+
+// matches reports whether the sample carries every label in want
+func (s sample) matches(want map[string]string) bool {
+	for k, v := range want {
+		if s.labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// sum adds up every sample of a metric matching the labels
+func (snap snapshot) sum(name string, want map[string]string) float64 {
+	var total float64
+	for _, s := range snap {
+		if s.name == name && s.matches(want) {
+			total += s.value
+		}
+	}
+	return total
+}
+
+// values lists the distinct values of a label on a metric
+func (snap snapshot) values(name, label string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range snap {
+		if v, ok := s.labels[label]; ok && s.name == name && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// buckets returns a histogram's cumulative bucket counts by upper bound
+func (snap snapshot) buckets(name string, want map[string]string) map[float64]float64 {
+	out := map[float64]float64{}
+	for _, s := range snap {
+		if s.name != name+"_bucket" || !s.matches(want) {
+			continue
+		}
+		le, err := strconv.ParseFloat(s.labels["le"], 64)
+		if err != nil {
+			continue
+		}
+		out[le] += s.value
+	}
+	return out
+}
+
+// delta is the increase of a counter between two scrapes; a counter that
+// went backwards was reset by a restart, so the new value is the increase
+func delta(prev, cur float64) float64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// quantile estimates a quantile from the bucket increases between two
+// scrapes, interpolating linearly within the bucket like Prometheus'
+// histogram_quantile. It returns NaN when nothing was observed.
+func quantile(q float64, prev, cur map[float64]float64) float64 {
+	bounds := make([]float64, 0, len(cur))
+	for le := range cur {
+		bounds = append(bounds, le)
+	}
+	sort.Float64s(bounds)
+	if len(bounds) == 0 {
+		return math.NaN()
+	}
+	total := delta(prev[math.Inf(1)], cur[math.Inf(1)])
+	if total == 0 {
+		return math.NaN()
+	}
+	rank := q * total
+	lower, below := 0.0, 0.0
+	for _, le := range bounds {
+		count := delta(prev[le], cur[le])
+		if count >= rank {
+			if math.IsInf(le, 1) {
+				return lower
+			}
+			if count == below {
+				return le
+			}
+			return lower + (le-lower)*(rank-below)/(count-below)
+		}
+		lower, below = le, count
+	}
+	return lower
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	bold   = "\x1b[1m"
+	dim    = "\x1b[2m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	reset  = "\x1b[0m"
+)
+
+// maxRows caps the transaction and delivery lists
+const maxRows = 8
+
+// view writes one frame, with colours when drawing on a terminal
+type view struct {
+	buf   bytes.Buffer
+	color bool
+}
+
+func (v *view) paint(code, s string) string {
+	if !v.color {
+		return s
+	}
+	return code + s + reset
+}
+
+func (v *view) heading(title string) {
+	fmt.Fprintf(&v.buf, "\n%s\n", v.paint(bold, title))
+}
+
+func (v *view) unavailable(err error) {
+	fmt.Fprintf(&v.buf, "  %s\n", v.paint(red, "unavailable: "+err.Error()))
+}
+
+// render draws a frame; redraw clears the screen first
+func render(w io.Writer, f *frame, redraw bool) {
+	v := &view{color: redraw}
+	if redraw {
+		v.buf.WriteString("\x1b[H\x1b[2J")
+	}
+	fmt.Fprintf(&v.buf, "%s  %s\n", v.paint(bold, "gatewaytop"), v.paint(dim, f.at.Format("15:04:05")+"  ctrl-c to quit"))
+
+	if f.gatewayEnabled {
+		v.authorizations(f)
+		v.webhooks(f)
+	}
+	v.rpcs("HSM", f.hsm, f.errs["hsm"])
+	v.rpcs("Tokenization", f.tokenization, f.errs["tokenization"])
+	if f.errs["tokenization"] == nil {
+		v.tokenizationHealth(f)
+	}
+	if f.gatewayEnabled {
+		v.recentTransactions(f)
+	}
+	w.Write(v.buf.Bytes())
+}
+
+func (v *view) authorizations(f *frame) {
+	v.heading("Authorizations")
+	if err := f.errs["transactions"]; err != nil {
+		v.unavailable(err)
+	} else {
+		decided := f.approved + f.declined
+		rate := "-"
+		if decided > 0 {
+			pct := 100 * float64(f.approved) / float64(decided)
+			rate = v.paint(rateColor(pct, 90, 70), fmt.Sprintf("%.1f%%", pct))
+		}
+		fmt.Fprintf(&v.buf, "  approval %s  approved %d  declined %d  pending %d  %s\n",
+			rate, f.approved, f.declined, f.pending, v.paint(dim, fmt.Sprintf("(last %d payments)", len(f.transactions))))
+	}
+	if err := f.errs["gateway"]; err != nil {
+		v.unavailable(err)
+		return
+	}
+	fmt.Fprintf(&v.buf, "  %.1f payments/s  avg %s\n", f.paymentsPerSec, ms(f.avgPaymentMs))
+}
+
+func (v *view) webhooks(f *frame) {
+	v.heading("Events & webhooks")
+	if f.errs["gateway"] == nil {
+		failed := fmt.Sprintf("%.1f/s", f.outboxFailed)
+		if f.outboxFailed > 0 {
+			failed = v.paint(red, failed)
+		}
+		fmt.Fprintf(&v.buf, "  outbox pending %.0f  published %.1f/s  failed %s\n", f.outboxPending, f.outboxPublished, failed)
+	}
+	if err := f.errs["webhooks"]; err != nil {
+		v.unavailable(err)
+		return
+	}
+	fmt.Fprintf(&v.buf, "  deliveries  %s %d  %s %d  %s %d\n",
+		v.paint(green, "delivered"), f.deliveryStatuses["DELIVERED"],
+		v.paint(yellow, "pending"), f.deliveryStatuses["PENDING"],
+		v.paint(red, "failed"), f.deliveryStatuses["FAILED"])
+	for i, d := range f.failedDeliveries {
+		if i == maxRows {
+			fmt.Fprintf(&v.buf, "    %s\n", v.paint(dim, fmt.Sprintf("... %d more", len(f.failedDeliveries)-maxRows)))
+			break
+		}
+		reason := d.ErrorMessage
+		if d.HTTPStatusCode != 0 {
+			reason = fmt.Sprintf("HTTP %d %s", d.HTTPStatusCode, reason)
+		}
+		fmt.Fprintf(&v.buf, "    %-8s %-24s attempt %-2d %s\n", d.Status, d.EventType, d.AttemptCount, truncate(reason, 60))
+	}
+}
+
+func (v *view) rpcs(title string, rows []rpcStats, err error) {
+	v.heading(title)
+	if err != nil {
+		v.unavailable(err)
+		return
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(&v.buf, "  %s\n", v.paint(dim, "no calls yet"))
+		return
+	}
+	fmt.Fprintf(&v.buf, "  %s\n", v.paint(dim, fmt.Sprintf("%-24s %9s %9s %9s %9s", "method", "calls/s", "errors/s", "p50", "p95")))
+	for _, r := range rows {
+		errors := fmt.Sprintf("%9.1f", r.errors)
+		if r.errors > 0 {
+			errors = v.paint(red, errors)
+		}
+		fmt.Fprintf(&v.buf, "  %-24s %9.1f %s %9s %9s\n", truncate(r.method, 24), r.perSecond, errors, ms(r.p50*1000), ms(r.p95*1000))
+	}
+}
+
+func (v *view) tokenizationHealth(f *frame) {
+	deps := make([]string, 0, len(f.breakers))
+	for d := range f.breakers {
+		deps = append(deps, d)
+	}
+	sort.Strings(deps)
+	var parts []string
+	for _, d := range deps {
+		// 0 closed, 1 half-open, 2 open
+		state := [...]string{v.paint(green, "closed"), v.paint(yellow, "half-open"), v.paint(red, "open")}[min(int(f.breakers[d]), 2)]
+		parts = append(parts, fmt.Sprintf("%s breaker %s", d, state))
+	}
+	if !math.IsNaN(f.cacheHitRate) {
+		parts = append(parts, fmt.Sprintf("data key cache hits %.0f%%", 100*f.cacheHitRate))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(&v.buf, "  %s\n", strings.Join(parts, "  "))
+	}
+}
+
+func (v *view) recentTransactions(f *frame) {
+	if f.errs["transactions"] != nil {
+		return
+	}
+	v.heading("Recent transactions")
+	if len(f.transactions) == 0 {
+		fmt.Fprintf(&v.buf, "  %s\n", v.paint(dim, "none"))
+		return
+	}
+	for _, p := range f.transactions[:min(len(f.transactions), maxRows)] {
+		at := ""
+		if p.CreatedAt != nil {
+			at = p.CreatedAt.Local().Format(time.TimeOnly)
+		}
+		status := fmt.Sprintf("%-10s", p.Status)
+		switch {
+		case approvedStatuses[p.Status]:
+			status = v.paint(green, status)
+		case p.Status == "DECLINED" || p.Status == "FAILED":
+			status = v.paint(red, status)
+		}
+		fmt.Fprintf(&v.buf, "  %s  %-38s %s %10.2f %s  %-10s ****%s  %s\n",
+			at, p.PaymentID, status, p.Amount, p.Currency, p.CardBrand, p.CardLastFour, p.ErrorCode)
+	}
+}
+
+// rateColor is green at or above good, yellow at or above fair, else red
+func rateColor(pct, good, fair float64) string {
+	switch {
+	case pct >= good:
+		return green
+	case pct >= fair:
+		return yellow
+	}
+	return red
+}
+
+func ms(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms", v)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "…"
+}
@@ -0,0 +1,18 @@
+package hsmv1
+
+import (
+	"context"
+
+	"github.com/paymentgateway/api/pkg/client"
+	"google.golang.org/grpc"
+)
+
+// Dial connects to the HSM simulator and returns a typed client together with
+// the connection, which the caller must close
+func Dial(ctx context.Context, target string, opts ...client.Option) (HSMServiceClient, *grpc.ClientConn, error) {
+	conn, err := client.Dial(ctx, target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewHSMServiceClient(conn), conn, nil
+}
@@ -0,0 +1,161 @@
+// Package client is the Go SDK for the payment gateway's APIs. Dial opens a
+// gRPC connection with API-key authentication, request-ID propagation and a
+// default deadline; the typed service clients are created from it by the
+// versioned API packages, e.g. hsmv1.Dial and tokenizationv1.Dial. Gateway
+// talks to the REST payments API.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the metadata key the servers read request IDs from
+const RequestIDHeader = "x-request-id"
+
+// DefaultTimeout bounds calls made without a deadline
+const DefaultTimeout = 5 * time.Second
+
+type options struct {
+	apiKey      string
+	tls         *tls.Config
+	timeout     time.Duration
+	block       bool
+	dialOptions []grpc.DialOption
+}
+
+// Option configures Dial
+type Option func(*options)
+
+// WithAPIKey sends "authorization: Bearer <key>" on every call
+func WithAPIKey(key string) Option {
+	return func(o *options) { o.apiKey = key }
+}
+
+// WithTLS enables transport security. Without it connections are plaintext,
+// which is what the simulators use locally.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) { o.tls = cfg }
+}
+
+// WithTimeout sets the deadline applied to calls whose context has none.
+// Zero disables the default deadline.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithBlock makes Dial wait until the connection is ready
+func WithBlock() Option {
+	return func(o *options) { o.block = true }
+}
+
+// WithDialOptions appends raw gRPC dial options
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOptions = append(o.dialOptions, opts...) }
+}
+
+// Dial connects to a gateway gRPC service
+func Dial(ctx context.Context, target string, opts ...Option) (*grpc.ClientConn, error) {
+	o := options{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(timeoutUnary(o.timeout), requestIDUnary()),
+		grpc.WithChainStreamInterceptor(requestIDStream()),
+	}
+	if o.tls != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(o.tls)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if o.apiKey != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearer{key: o.apiKey, secure: o.tls != nil}))
+	}
+	if o.block {
+		dialOpts = append(dialOpts, grpc.WithBlock())
+	}
+	dialOpts = append(dialOpts, o.dialOptions...)
+
+	conn, err := grpc.DialContext(ctx, target, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", target, err)
+	}
+	return conn, nil
+}
+
+// bearer attaches an API key to each call
+type bearer struct {
+	key    string
+	secure bool
+}
+
+func (b bearer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + b.key}, nil
+}
+
+// RequireTransportSecurity only insists on TLS when TLS was configured, so the
+// key can still be used against local plaintext simulators
+func (b bearer) RequireTransportSecurity() bool {
+	return b.secure
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose calls carry the given request ID.
+// Calls without one get a fresh ID each.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(RequestIDHeader)) > 0 {
+		return ctx
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	if id == "" {
+		id = newRequestID()
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDHeader, id)
+}
+
+func requestIDUnary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func requestIDStream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+func timeoutUnary(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// startServer runs a gRPC server that records the metadata and deadline of
+// every call it receives
+func startServer(t *testing.T) (string, chan metadata.MD, chan bool) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	mds := make(chan metadata.MD, 4)
+	deadlines := make(chan bool, 4)
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		_, hasDeadline := stream.Context().Deadline()
+		mds <- md
+		deadlines <- hasDeadline
+		var in emptypb.Empty
+		if err := stream.RecvMsg(&in); err != nil {
+			return err
+		}
+		return stream.SendMsg(&emptypb.Empty{})
+	}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), mds, deadlines
+}
+
+func TestDialSendsCredentialsAndRequestID(t *testing.T) {
+	addr, mds, deadlines := startServer(t)
+
+	conn, err := Dial(context.Background(), addr, WithAPIKey("k1"), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	ctx := WithRequestID(context.Background(), "req-42")
+	if err := conn.Invoke(ctx, "/hsm.v1.HSMService/GetKeyInfo", &emptypb.Empty{}, &emptypb.Empty{}); err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+	md := <-mds
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer k1" {
+		t.Errorf("authorization = %v, want Bearer k1", got)
+	}
+	if got := md.Get(RequestIDHeader); len(got) != 1 || got[0] != "req-42" {
+		t.Errorf("request ID = %v, want req-42", got)
+	}
+	if !<-deadlines {
+		t.Error("call without a deadline should get the default timeout")
+	}
+
+	if err := conn.Invoke(context.Background(), "/hsm.v1.HSMService/GetKeyInfo", &emptypb.Empty{}, &emptypb.Empty{}); err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+	md = <-mds
+	if got := md.Get(RequestIDHeader); len(got) != 1 || len(got[0]) != 32 {
+		t.Errorf("generated request ID = %v, want 32 hex chars", got)
+	}
+}
+
+func TestGatewayAuthorize(t *testing.T) {
+	var gotKey, gotAuth string
+	var gotBody PaymentRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/payments" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		gotKey = r.Header.Get("Idempotency-Key")
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"paymentId":"pay_1","status":"AUTHORIZED","amount":10.5,"currency":"USD","cardLastFour":"1111","createdAt":"2024-01-02T03:04:05Z"}`))
+	}))
+	defer srv.Close()
+
+	gw := NewGateway(srv.URL+"/", "sk_test", nil)
+	p, err := gw.Authorize(context.Background(), &PaymentRequest{
+		CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030, Amount: 10.5, Currency: "USD",
+	}, "idem-1")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if p.PaymentID != "pay_1" || p.Status != StatusAuthorized || p.CreatedAt == nil {
+		t.Errorf("unexpected payment %+v", p)
+	}
+	if gotKey != "idem-1" || gotAuth != "Bearer sk_test" || gotBody.CardNumber != "4111111111111111" {
+		t.Errorf("request headers/body not sent: key=%q auth=%q body=%+v", gotKey, gotAuth, gotBody)
+	}
+}
+
+func TestGatewayAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":"VALIDATION_ERROR","message":"Request validation failed","fields":{"amount":"must be positive"}}}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewGateway(srv.URL, "", nil).Refund(context.Background(), &RefundRequest{PaymentID: "pay_1", Amount: -1})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != 400 || apiErr.Code != "VALIDATION_ERROR" || apiErr.Fields["amount"] == "" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+}
+
+func TestGatewayRecentTransactions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/transactions" || r.URL.Query().Get("size") != "2" || r.URL.Query().Get("sortDirection") != "DESC" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"transactions":[{"paymentId":"pay_2","status":"DECLINED"},{"paymentId":"pay_1","status":"CAPTURED"}],"page":0,"size":2}`))
+	}))
+	defer srv.Close()
+
+	txs, err := NewGateway(srv.URL, "sk_test", nil).RecentTransactions(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("RecentTransactions() error = %v", err)
+	}
+	if len(txs) != 2 || txs[0].PaymentID != "pay_2" || txs[1].Status != StatusCaptured {
+		t.Errorf("unexpected transactions %+v", txs)
+	}
+}
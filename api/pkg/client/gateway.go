@@ -0,0 +1,374 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Payment statuses reported by the gateway
+const (
+	StatusPending    = "PENDING"
+	StatusAuthorized = "AUTHORIZED"
+	StatusDeclined   = "DECLINED"
+	StatusCaptured   = "CAPTURED"
+	StatusSettled    = "SETTLED"
+	StatusFailed     = "FAILED"
+	StatusCancelled  = "CANCELLED"
+	StatusRefunded   = "REFUNDED"
+)
+
+// PaymentRequest is the body of POST /api/v1/payments
+type PaymentRequest struct {
+	CardNumber     string  `json:"cardNumber"`
+	ExpiryMonth    int     `json:"expiryMonth"`
+	ExpiryYear     int     `json:"expiryYear"`
+	CVV            string  `json:"cvv,omitempty"`
+	CardholderName string  `json:"cardholderName,omitempty"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+	Description    string  `json:"description,omitempty"`
+	ReferenceID    string  `json:"referenceId,omitempty"`
+	BillingStreet  string  `json:"billingStreet,omitempty"`
+	BillingCity    string  `json:"billingCity,omitempty"`
+	BillingState   string  `json:"billingState,omitempty"`
+	BillingZip     string  `json:"billingZip,omitempty"`
+	BillingCountry string  `json:"billingCountry,omitempty"`
+	// BillingAddress takes precedence over the flat billing fields
+	BillingAddress *BillingAddress `json:"billingAddress,omitempty"`
+	// DynamicDescriptor follows the merchant's descriptor and an asterisk
+	// on the cardholder's statement
+	DynamicDescriptor string `json:"dynamicDescriptor,omitempty"`
+	// TaxAmount and CustomerCode are Level 2 purchasing data; the tax is
+	// part of Amount
+	TaxAmount    *float64 `json:"taxAmount,omitempty"`
+	CustomerCode string   `json:"customerCode,omitempty"`
+	// LineItems are Level 3 purchasing data, which with the tax must add
+	// up to Amount
+	LineItems []LineItem `json:"lineItems,omitempty"`
+	// Splits allocate portions of Amount to the platform's sub-merchants
+	Splits []Split `json:"splits,omitempty"`
+	// Installments is the plan the cardholder repays the payment in
+	Installments *Installments `json:"installments,omitempty"`
+}
+
+// Installments is an installment plan: the number of installments and
+// who finances them, MERCHANT or ISSUER
+type Installments struct {
+	Count    int    `json:"count"`
+	PlanType string `json:"planType"`
+}
+
+// Split is a marketplace portion of a payment for a sub-merchant, and the
+// platform's fee on it
+type Split struct {
+	MerchantID string  `json:"merchantId"`
+	Amount     float64 `json:"amount"`
+	FeeAmount  float64 `json:"feeAmount,omitempty"`
+	// NetAmount is set in responses: the amount less the fee
+	NetAmount float64 `json:"netAmount,omitempty"`
+}
+
+// LineItem is a Level 3 line item of a payment
+type LineItem struct {
+	ProductCode    string  `json:"productCode,omitempty"`
+	Description    string  `json:"description"`
+	CommodityCode  string  `json:"commodityCode,omitempty"`
+	Quantity       float64 `json:"quantity"`
+	UnitOfMeasure  string  `json:"unitOfMeasure,omitempty"`
+	UnitPrice      float64 `json:"unitPrice"`
+	DiscountAmount float64 `json:"discountAmount,omitempty"`
+}
+
+// BillingAddress is the structured billing address of a payment, whose
+// street and postal code the issuer checks for AVS
+type BillingAddress struct {
+	Line1      string `json:"line1,omitempty"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+// Payment is the gateway's view of a payment
+type Payment struct {
+	PaymentID    string     `json:"paymentId"`
+	Status       string     `json:"status"`
+	Amount       float64    `json:"amount"`
+	Currency     string     `json:"currency"`
+	CardLastFour string     `json:"cardLastFour"`
+	CardBrand    string     `json:"cardBrand"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	AuthorizedAt *time.Time `json:"authorizedAt,omitempty"`
+	ErrorCode    string     `json:"errorCode,omitempty"`
+	ErrorMessage string     `json:"errorMessage,omitempty"`
+	// AVSResult is the issuer's AVS result (Y, A, Z, N or U), absent
+	// without a billing address
+	AVSResult string `json:"avsResult,omitempty"`
+	// StatementDescriptor is the name on the cardholder's statement
+	StatementDescriptor string `json:"statementDescriptor,omitempty"`
+	// PurchaseDataLevel is the level of purchasing data sent: 1, 2 or 3
+	PurchaseDataLevel int `json:"purchaseDataLevel,omitempty"`
+	// AmountBreakdown splits Amount into the goods and any surcharge or fee
+	AmountBreakdown *AmountBreakdown `json:"amountBreakdown,omitempty"`
+	// Splits are the sub-merchants' portions of a marketplace payment
+	Splits []Split `json:"splits,omitempty"`
+	// Installments is the payment's installment plan, if any
+	Installments *Installments `json:"installments,omitempty"`
+	// ScreeningHold is set on an authorization held by sanctions
+	// screening, which cannot be captured until the hit is released
+	ScreeningHold bool `json:"screeningHold,omitempty"`
+	// IssuerCountry is the country that issued the card, absent when the
+	// gateway's BIN table does not know it
+	IssuerCountry string `json:"issuerCountry,omitempty"`
+	// CrossBorder is set when the card was issued outside the merchant's
+	// country
+	CrossBorder bool `json:"crossBorder,omitempty"`
+}
+
+// AmountBreakdown is the base amount of a payment and what was added to it
+type AmountBreakdown struct {
+	BaseAmount      float64 `json:"baseAmount"`
+	SurchargeAmount float64 `json:"surchargeAmount,omitempty"`
+	ConvenienceFee  float64 `json:"convenienceFee,omitempty"`
+	TipAmount       float64 `json:"tipAmount,omitempty"`
+	TotalAmount     float64 `json:"totalAmount"`
+}
+
+// CaptureRequest is the optional body of POST /api/v1/payments/{id}/capture
+type CaptureRequest struct {
+	TipAmount float64 `json:"tipAmount,omitempty"`
+}
+
+// RefundRequest is the body of POST /api/v1/refunds
+type RefundRequest struct {
+	PaymentID string  `json:"paymentId"`
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason,omitempty"`
+	// OverridePolicy refunds despite the merchant's refund policy; ADMIN role only
+	OverridePolicy bool `json:"overridePolicy,omitempty"`
+}
+
+// Refund is the gateway's view of a refund
+type Refund struct {
+	RefundID     string     `json:"refundId"`
+	PaymentID    string     `json:"paymentId"`
+	Status       string     `json:"status"`
+	Amount       float64    `json:"amount"`
+	Currency     string     `json:"currency"`
+	Reason       string     `json:"reason,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	ProcessedAt  *time.Time `json:"processedAt,omitempty"`
+	ErrorCode    string     `json:"errorCode,omitempty"`
+	ErrorMessage string     `json:"errorMessage,omitempty"`
+	// PolicyOverride lists the refund policy rules an administrator overrode
+	PolicyOverride string `json:"policyOverride,omitempty"`
+}
+
+// WebhookDelivery is one attempt record of a merchant webhook
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	PaymentID      string     `json:"paymentId"`
+	EventType      string     `json:"eventType"`
+	WebhookURL     string     `json:"webhookUrl"`
+	Status         string     `json:"status"`
+	AttemptCount   int        `json:"attemptCount"`
+	HTTPStatusCode int        `json:"httpStatusCode,omitempty"`
+	ErrorMessage   string     `json:"errorMessage,omitempty"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	NextRetryAt    *time.Time `json:"nextRetryAt,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// APIError is a non-2xx response from the gateway
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     map[string]string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("gateway returned HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("gateway returned HTTP %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Gateway is a client for the REST payments API
+type Gateway struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewGateway creates a gateway client. baseURL is the service root, e.g.
+// "https://localhost:8446"; a nil httpClient selects one with DefaultTimeout.
+func NewGateway(baseURL, apiKey string, httpClient *http.Client) *Gateway {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Gateway{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}
+}
+
+// Authorize creates a payment. A non-empty idempotency key makes retries of
+// the same request return the original payment.
+func (g *Gateway) Authorize(ctx context.Context, req *PaymentRequest, idempotencyKey string) (*Payment, error) {
+	var p Payment
+	if err := g.do(ctx, http.MethodPost, "/api/v1/payments", req, idempotencyKey, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPayment fetches a payment by ID
+func (g *Gateway) GetPayment(ctx context.Context, paymentID string) (*Payment, error) {
+	var p Payment
+	if err := g.do(ctx, http.MethodGet, "/api/v1/payments/"+url.PathEscape(paymentID), nil, "", &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Capture captures an authorized payment
+func (g *Gateway) Capture(ctx context.Context, paymentID string) (*Payment, error) {
+	var p Payment
+	if err := g.do(ctx, http.MethodPost, "/api/v1/payments/"+url.PathEscape(paymentID)+"/capture", nil, "", &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CaptureWithTip captures an authorized payment for its amount plus a tip
+func (g *Gateway) CaptureWithTip(ctx context.Context, paymentID string, tip float64) (*Payment, error) {
+	var p Payment
+	if err := g.do(ctx, http.MethodPost, "/api/v1/payments/"+url.PathEscape(paymentID)+"/capture", &CaptureRequest{TipAmount: tip}, "", &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Void cancels an authorized payment
+func (g *Gateway) Void(ctx context.Context, paymentID string) (*Payment, error) {
+	var p Payment
+	if err := g.do(ctx, http.MethodPost, "/api/v1/payments/"+url.PathEscape(paymentID)+"/void", nil, "", &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Refund refunds all or part of a captured payment
+func (g *Gateway) Refund(ctx context.Context, req *RefundRequest) (*Refund, error) {
+	var r Refund
+	if err := g.do(ctx, http.MethodPost, "/api/v1/refunds", req, "", &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetRefund fetches a refund by ID
+func (g *Gateway) GetRefund(ctx context.Context, refundID string) (*Refund, error) {
+	var r Refund
+	if err := g.do(ctx, http.MethodGet, "/api/v1/refunds/"+url.PathEscape(refundID), nil, "", &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// RecentTransactions lists the merchant's newest payments, at most size
+func (g *Gateway) RecentTransactions(ctx context.Context, size int) ([]Payment, error) {
+	var page struct {
+		Transactions []Payment `json:"transactions"`
+	}
+	q := url.Values{"size": {fmt.Sprint(size)}, "sortBy": {"createdAt"}, "sortDirection": {"DESC"}}
+	if err := g.do(ctx, http.MethodGet, "/api/v1/transactions?"+q.Encode(), nil, "", &page); err != nil {
+		return nil, err
+	}
+	return page.Transactions, nil
+}
+
+// WebhookDeliveries lists the merchant's webhook deliveries
+func (g *Gateway) WebhookDeliveries(ctx context.Context) ([]WebhookDelivery, error) {
+	var d []WebhookDelivery
+	if err := g.do(ctx, http.MethodGet, "/api/v1/webhooks/deliveries", nil, "", &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (g *Gateway) do(ctx context.Context, method, path string, body interface{}, idempotencyKey string, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	if id == "" {
+		id = newRequestID()
+	}
+	req.Header.Set("X-Request-Id", id)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp.StatusCode, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// parseAPIError reads the gateway's {"error":{"code","message","fields"}} body
+func parseAPIError(statusCode int, data []byte) error {
+	var envelope struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Message string            `json:"message"`
+			Fields  map[string]string `json:"fields"`
+		} `json:"error"`
+	}
+	apiErr := &APIError{StatusCode: statusCode}
+	if json.Unmarshal(data, &envelope) == nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Fields = envelope.Error.Fields
+	}
+	return apiErr
+}
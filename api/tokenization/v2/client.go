@@ -0,0 +1,18 @@
+package tokenizationv2
+
+import (
+	"context"
+
+	"github.com/paymentgateway/api/pkg/client"
+	"google.golang.org/grpc"
+)
+
+// Dial connects to the tokenization service v2 API and returns a typed client
+// together with the connection, which the caller must close
+func Dial(ctx context.Context, target string, opts ...client.Option) (TokenizationServiceClient, *grpc.ClientConn, error) {
+	conn, err := client.Dial(ctx, target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewTokenizationServiceClient(conn), conn, nil
+}
@@ -1,7 +1,12 @@
 package com.paymentgateway.authorization.audit;
 
 import com.paymentgateway.authorization.domain.PaymentEvent;
+import com.paymentgateway.authorization.pagination.CursorPage;
+import com.paymentgateway.authorization.pagination.InvalidPageTokenException;
+import com.paymentgateway.authorization.pagination.PageLimits;
+import com.paymentgateway.authorization.pagination.PageToken;
 import com.paymentgateway.authorization.repository.PaymentEventRepository;
+import org.springframework.data.domain.PageRequest;
 import org.springframework.stereotype.Service;
 import org.springframework.transaction.annotation.Transactional;
 
@@ -9,6 +14,7 @@ import javax.crypto.Mac;
 import javax.crypto.spec.SecretKeySpec;
 import java.nio.charset.StandardCharsets;
 import java.security.InvalidKeyException;
+import java.security.MessageDigest;
 import java.security.NoSuchAlgorithmException;
 import java.time.Instant;
 import java.util.Base64;
@@ -23,6 +29,8 @@ import java.util.regex.Pattern;
 @Service
 public class AuditLogService {
     
+    static final PageLimits PAGE_LIMITS = new PageLimits(50, 500);
+    
     private final PaymentEventRepository paymentEventRepository;
     private final String hmacSecretKey;
     
@@ -125,6 +133,39 @@ public class AuditLogService {
         return paymentEventRepository.findByPaymentIdOrderByCreatedAtDesc(paymentId);
     }
     
+    /**
+     * Retrieve one page of a payment's audit logs, newest first.
+     * 
+     * @param paymentId The payment ID to retrieve logs for
+     * @param pageSize Requested page size; null for the default
+     * @param pageToken Token from the previous page; null for the first page
+     * @return The page and the token for the next one
+     */
+    @Transactional(readOnly = true)
+    public CursorPage<PaymentEvent> getAuditLogPage(UUID paymentId, Integer pageSize, String pageToken) {
+        String fingerprint = PageToken.fingerprint(paymentId);
+        List<String> after = PageToken.decode(pageToken, fingerprint);
+        int size = PAGE_LIMITS.size(pageSize);
+        PageRequest limit = PageRequest.of(0, size + 1);
+        
+        List<PaymentEvent> events;
+        if (after == null) {
+            events = paymentEventRepository.findByPaymentIdOrderByCreatedAtDescIdDesc(paymentId, limit);
+        } else {
+            Instant createdAt;
+            UUID id;
+            try {
+                createdAt = Instant.parse(after.get(0));
+                id = UUID.fromString(after.get(1));
+            } catch (RuntimeException e) {
+                throw new InvalidPageTokenException("Invalid page token");
+            }
+            events = paymentEventRepository.findByPaymentIdBefore(paymentId, createdAt, id, limit);
+        }
+        return PageToken.page(events, size, fingerprint,
+                              e -> List.of(e.getCreatedAt().toString(), e.getId().toString()));
+    }
+    
     /**
      * Verify the cryptographic integrity of an audit log entry.
      * 
@@ -147,7 +188,10 @@ public class AuditLogService {
         // Restore the original signature
         event.setErrorCode(originalErrorCode);
         
-        return storedSignature.equals(calculatedSignature);
+        // isEqual does not stop at the first mismatching byte
+        return MessageDigest.isEqual(
+                storedSignature.getBytes(StandardCharsets.UTF_8),
+                calculatedSignature.getBytes(StandardCharsets.UTF_8));
     }
     
     /**
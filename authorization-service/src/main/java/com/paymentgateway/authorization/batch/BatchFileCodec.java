@@ -0,0 +1,270 @@
+package com.paymentgateway.authorization.batch;
+
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileRecord;
+import com.paymentgateway.authorization.domain.BatchRecordType;
+
+import java.math.BigDecimal;
+import java.util.ArrayList;
+import java.util.Currency;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.regex.Pattern;
+
+/**
+ * Reads batch transaction files and writes their response files.
+ *
+ * CSV files have a header row naming the columns {@code token},
+ * {@code amount} and {@code currency}, and optionally {@code reference} and
+ * {@code type} ({@code SALE}, the default, or {@code AUTHORIZATION}).
+ *
+ * Fixed-width files have a {@code D} record per transaction and a
+ * {@code T} trailer with the record count and the sum of the amounts in
+ * minor units, which must match:
+ *
+ * <pre>
+ * col  1      'D'
+ * col  2-7    sequence number, from 000001
+ * col  8-26   token, left-justified
+ * col 27-38   amount in minor units, zero-padded
+ * col 39-41   currency
+ * col 42      'S' sale or 'A' authorization
+ * col 43-62   merchant reference, left-justified (optional)
+ *
+ * col  1      'T'
+ * col  2-7    record count
+ * col  8-22   amount total in minor units
+ * </pre>
+ *
+ * A file that breaks these rules is rejected whole; records are only
+ * accepted or declined one by one once the file has been read.
+ */
+public final class BatchFileCodec {
+    
+    public static final int MAX_RECORDS = 10_000;
+    
+    // Tokens of the tokenization service: a 9, then digits keeping the
+    // card number's length
+    private static final Pattern TOKEN = Pattern.compile("^9\\d{12,18}$");
+    private static final Pattern REFERENCE = Pattern.compile("^[A-Za-z0-9_.-]{0,20}$");
+    
+    private BatchFileCodec() {}
+    
+    public static List<BatchFileRecord> parse(BatchFileFormat format, String content) {
+        List<BatchFileRecord> records = switch (format) {
+            case CSV -> parseCsv(content);
+            case FIXED_WIDTH -> parseFixedWidth(content);
+        };
+        if (records.isEmpty()) {
+            throw new InvalidBatchFileException("The file has no records");
+        }
+        if (records.size() > MAX_RECORDS) {
+            throw new InvalidBatchFileException("At most " + MAX_RECORDS + " records per file");
+        }
+        return records;
+    }
+    
+    /**
+     * The response file of a processed batch file, in its format, with each
+     * record's outcome
+     */
+    public static String write(BatchFile file, List<BatchFileRecord> records) {
+        return switch (file.getFormat()) {
+            case CSV -> writeCsv(records);
+            case FIXED_WIDTH -> writeFixedWidth(file, records);
+        };
+    }
+    
+    private static List<BatchFileRecord> parseCsv(String content) {
+        String[] lines = content.split("\\r?\\n");
+        if (lines.length == 0 || lines[0].isBlank()) {
+            throw new InvalidBatchFileException("line 1: header row required");
+        }
+        Map<String, Integer> columns = new HashMap<>();
+        String[] header = lines[0].split(",", -1);
+        for (int i = 0; i < header.length; i++) {
+            columns.put(header[i].trim().toLowerCase(Locale.ROOT), i);
+        }
+        for (String required : List.of("token", "amount", "currency")) {
+            if (!columns.containsKey(required)) {
+                throw new InvalidBatchFileException("line 1: column '" + required + "' required");
+            }
+        }
+        
+        List<BatchFileRecord> records = new ArrayList<>();
+        for (int i = 1; i < lines.length; i++) {
+            if (lines[i].isBlank()) {
+                continue;
+            }
+            String prefix = "line " + (i + 1) + ": ";
+            String[] fields = lines[i].split(",", -1);
+            if (fields.length != header.length) {
+                throw new InvalidBatchFileException(prefix + "expected " + header.length + " fields");
+            }
+            BigDecimal amount;
+            try {
+                amount = new BigDecimal(field(fields, columns, "amount"));
+            } catch (NumberFormatException e) {
+                throw new InvalidBatchFileException(prefix + "amount must be a number");
+            }
+            String type = field(fields, columns, "type");
+            BatchRecordType recordType;
+            try {
+                recordType = type.isEmpty() ? BatchRecordType.SALE : BatchRecordType.valueOf(type.toUpperCase(Locale.ROOT));
+            } catch (IllegalArgumentException e) {
+                throw new InvalidBatchFileException(prefix + "type must be SALE or AUTHORIZATION");
+            }
+            records.add(record(prefix, records.size() + 1, field(fields, columns, "reference"),
+                field(fields, columns, "token"), amount, field(fields, columns, "currency"), recordType));
+        }
+        return records;
+    }
+    
+    private static String field(String[] fields, Map<String, Integer> columns, String name) {
+        Integer index = columns.get(name);
+        return index != null ? fields[index].trim() : "";
+    }
+    
+    private static List<BatchFileRecord> parseFixedWidth(String content) {
+        String[] lines = content.split("\\r?\\n");
+        List<BatchFileRecord> records = new ArrayList<>();
+        long minorTotal = 0;
+        boolean trailer = false;
+        for (int i = 0; i < lines.length; i++) {
+            String line = lines[i];
+            if (line.isBlank()) {
+                continue;
+            }
+            String prefix = "line " + (i + 1) + ": ";
+            if (trailer) {
+                throw new InvalidBatchFileException(prefix + "nothing may follow the trailer");
+            }
+            if (line.startsWith("T")) {
+                if (line.length() < 22 || !line.substring(1, 22).matches("\\d{21}")) {
+                    throw new InvalidBatchFileException(prefix + "malformed trailer");
+                }
+                if (Integer.parseInt(line.substring(1, 7)) != records.size()
+                        || Long.parseLong(line.substring(7, 22)) != minorTotal) {
+                    throw new InvalidBatchFileException(prefix + "trailer count or amount total does not match the records");
+                }
+                trailer = true;
+                continue;
+            }
+            if (!line.startsWith("D") || line.length() < 42) {
+                throw new InvalidBatchFileException(prefix + "expected a D record of at least 42 characters or the trailer");
+            }
+            if (!line.substring(1, 7).matches("\\d{6}") || Integer.parseInt(line.substring(1, 7)) != records.size() + 1) {
+                throw new InvalidBatchFileException(prefix + "sequence number must be " + String.format("%06d", records.size() + 1));
+            }
+            String minorUnits = line.substring(26, 38);
+            if (!minorUnits.matches("\\d{12}")) {
+                throw new InvalidBatchFileException(prefix + "amount must be 12 digits");
+            }
+            String currency = line.substring(38, 41);
+            BatchRecordType recordType = switch (line.charAt(41)) {
+                case 'S' -> BatchRecordType.SALE;
+                case 'A' -> BatchRecordType.AUTHORIZATION;
+                default -> throw new InvalidBatchFileException(prefix + "transaction code must be S or A");
+            };
+            String reference = line.length() > 42 ? line.substring(42, Math.min(line.length(), 62)).trim() : "";
+            BigDecimal amount = new BigDecimal(minorUnits).movePointLeft(fractionDigits(prefix, currency));
+            records.add(record(prefix, records.size() + 1, reference, line.substring(7, 26).trim(), amount, currency,
+                recordType));
+            minorTotal += Long.parseLong(minorUnits);
+        }
+        if (!trailer) {
+            throw new InvalidBatchFileException("trailer record required");
+        }
+        return records;
+    }
+    
+    private static BatchFileRecord record(String prefix, int number, String reference, String token, BigDecimal amount,
+                                          String currency, BatchRecordType recordType) {
+        if (!TOKEN.matcher(token).matches()) {
+            throw new InvalidBatchFileException(prefix + "token must be 13 to 19 digits starting with 9");
+        }
+        if (amount.signum() <= 0 || amount.scale() > fractionDigits(prefix, currency)) {
+            throw new InvalidBatchFileException(prefix + "amount must be positive, in " + currency + " units");
+        }
+        if (!REFERENCE.matcher(reference).matches()) {
+            throw new InvalidBatchFileException(prefix + "reference must be up to 20 letters, digits, _, . or -");
+        }
+        return new BatchFileRecord(number, reference.isEmpty() ? null : reference, token, amount, currency, recordType);
+    }
+    
+    private static int fractionDigits(String prefix, String currency) {
+        try {
+            return Math.max(Currency.getInstance(currency).getDefaultFractionDigits(), 0);
+        } catch (IllegalArgumentException | NullPointerException e) {
+            throw new InvalidBatchFileException(prefix + "unknown currency " + currency);
+        }
+    }
+    
+    private static String writeCsv(List<BatchFileRecord> records) {
+        StringBuilder file = new StringBuilder("record,reference,outcome,payment_id,payment_status,response_code,message\n");
+        for (BatchFileRecord record : records) {
+            file.append(record.getRecordNumber()).append(',')
+                .append(valueOr(record.getReference())).append(',')
+                .append(record.getOutcome()).append(',')
+                .append(valueOr(record.getPaymentId())).append(',')
+                .append(valueOr(record.getPaymentStatus())).append(',')
+                .append(valueOr(record.getResponseCode())).append(',')
+                .append(csvField(record.getMessage())).append('\n');
+        }
+        return file.toString();
+    }
+    
+    /**
+     * An R record per transaction and a T trailer with the outcome counts:
+     *
+     * <pre>
+     * col  1      'R'
+     * col  2-7    sequence number
+     * col  8-27   merchant reference
+     * col 28-35   outcome: APPROVED, DECLINED or ERROR
+     * col 36-63   payment ID
+     * col 64-83   response code
+     *
+     * col  1      'T'
+     * col  2-7    record count
+     * col  8-13   approved
+     * col 14-19   declined
+     * col 20-25   errors
+     * </pre>
+     */
+    private static String writeFixedWidth(BatchFile file, List<BatchFileRecord> records) {
+        StringBuilder out = new StringBuilder();
+        for (BatchFileRecord record : records) {
+            out.append(String.format("R%06d%-20s%-8s%-28s%-20s\n",
+                record.getRecordNumber(),
+                valueOr(record.getReference()),
+                record.getOutcome(),
+                valueOr(record.getPaymentId()),
+                truncate(valueOr(record.getResponseCode()), 20)));
+        }
+        out.append(String.format("T%06d%06d%06d%06d\n",
+            records.size(), file.getApprovedCount(), file.getDeclinedCount(), file.getErrorCount()));
+        return out.toString();
+    }
+    
+    private static String valueOr(String value) {
+        return value != null ? value : "";
+    }
+    
+    private static String truncate(String value, int length) {
+        return value.length() > length ? value.substring(0, length) : value;
+    }
+    
+    private static String csvField(String value) {
+        if (value == null) {
+            return "";
+        }
+        if (value.contains(",") || value.contains("\"") || value.contains("\n")) {
+            return "\"" + value.replace("\"", "\"\"") + "\"";
+        }
+        return value;
+    }
+}
@@ -0,0 +1,160 @@
+package com.paymentgateway.authorization.batch;
+
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileRecord;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import com.paymentgateway.authorization.domain.BatchRecordOutcome;
+import com.paymentgateway.authorization.domain.BatchRecordType;
+import com.paymentgateway.authorization.domain.PaymentStatus;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.repository.BatchFileRecordRepository;
+import com.paymentgateway.authorization.repository.BatchFileRepository;
+import com.paymentgateway.authorization.service.PaymentService;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.ObjectProvider;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.stereotype.Component;
+
+import java.time.Instant;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * Processes received batch files one at a time, in the order received. The
+ * file's tokens are detokenized, then each record is authorized and, for a
+ * sale, captured, and its outcome saved before the next, so a restart picks
+ * up where processing stopped. Records are submitted with the file ID and
+ * record number as their idempotency key, so one interrupted mid-payment
+ * is not charged twice.
+ */
+@Component
+public class BatchFileProcessor {
+    
+    private static final Logger logger = LoggerFactory.getLogger(BatchFileProcessor.class);
+    
+    private static final List<BatchFileStatus> UNFINISHED = List.of(BatchFileStatus.PROCESSING, BatchFileStatus.RECEIVED);
+    
+    private final BatchFileRepository fileRepository;
+    private final BatchFileRecordRepository recordRepository;
+    private final PaymentService paymentService;
+    private final BatchTokenResolver tokenResolver;
+    
+    @Autowired
+    public BatchFileProcessor(BatchFileRepository fileRepository,
+                              BatchFileRecordRepository recordRepository,
+                              PaymentService paymentService,
+                              ObjectProvider<BatchTokenResolver> tokenResolver) {
+        this(fileRepository, recordRepository, paymentService, tokenResolver.getIfAvailable());
+    }
+    
+    public BatchFileProcessor(BatchFileRepository fileRepository,
+                              BatchFileRecordRepository recordRepository,
+                              PaymentService paymentService,
+                              BatchTokenResolver tokenResolver) {
+        this.fileRepository = fileRepository;
+        this.recordRepository = recordRepository;
+        this.paymentService = paymentService;
+        this.tokenResolver = tokenResolver;
+        if (tokenResolver == null) {
+            logger.warn("No tokenization service configured (batch.tokenization.address); "
+                + "batch file records will fail with TOKENIZATION_UNAVAILABLE");
+        }
+    }
+    
+    /**
+     * Process the oldest unfinished file, if any
+     *
+     * @return Whether there was one
+     */
+    public boolean processNext() {
+        BatchFile file = fileRepository.findFirstByStatusInOrderByCreatedAt(UNFINISHED).orElse(null);
+        if (file == null) {
+            return false;
+        }
+        process(file);
+        return true;
+    }
+    
+    void process(BatchFile file) {
+        file.setStatus(BatchFileStatus.PROCESSING);
+        fileRepository.save(file);
+        
+        List<BatchFileRecord> pending = recordRepository.findByBatchFileIdOrderByRecordNumber(file.getId()).stream()
+            .filter(record -> record.getOutcome() == BatchRecordOutcome.PENDING)
+            .toList();
+        Map<String, ResolvedCard> cards = resolve(file, pending);
+        
+        for (BatchFileRecord record : pending) {
+            ResolvedCard card = cards.get(record.getToken());
+            if (card == null) {
+                fail(record, "TOKENIZATION_UNAVAILABLE", "The token could not be detokenized");
+            } else if (!card.isResolved()) {
+                fail(record, card.getErrorCode(), card.getErrorMessage());
+            } else {
+                pay(file, record, card);
+            }
+            recordRepository.save(record);
+            file.count(record.getOutcome());
+            fileRepository.save(file);
+        }
+        
+        file.setStatus(BatchFileStatus.COMPLETED);
+        file.setCompletedAt(Instant.now());
+        fileRepository.save(file);
+        logger.info("Batch file {} completed: {} approved, {} declined, {} errors", file.getFileId(),
+            file.getApprovedCount(), file.getDeclinedCount(), file.getErrorCount());
+    }
+    
+    /**
+     * The cards of the records' tokens. None if the tokenization service is
+     * not configured or cannot be reached, which fails the records.
+     */
+    private Map<String, ResolvedCard> resolve(BatchFile file, List<BatchFileRecord> records) {
+        Map<String, ResolvedCard> cards = new HashMap<>();
+        if (tokenResolver == null || records.isEmpty()) {
+            return cards;
+        }
+        List<String> tokens = records.stream().map(BatchFileRecord::getToken).distinct().toList();
+        try {
+            for (ResolvedCard card : tokenResolver.resolve(
+                    file.getMerchantId().toString(), tokens, file.getFileId())) {
+                cards.put(card.getToken(), card);
+            }
+        } catch (RuntimeException e) {
+            logger.error("Detokenizing batch file {} failed: {}", file.getFileId(), e.getMessage());
+        }
+        return cards;
+    }
+    
+    private void pay(BatchFile file, BatchFileRecord record, ResolvedCard card) {
+        PaymentRequest request = new PaymentRequest(card.getPan(), card.getExpiryMonth(), card.getExpiryYear(), null,
+            record.getAmount(), record.getCurrency());
+        request.setReferenceId(record.getReference());
+        request.setDescription("Batch file " + file.getFileId() + " record " + record.getRecordNumber());
+        try {
+            PaymentResponse response = paymentService.processPayment(request, file.getMerchantId(),
+                file.getFileId() + "-" + record.getRecordNumber());
+            if (response.getStatus() == PaymentStatus.AUTHORIZED && record.getRecordType() == BatchRecordType.SALE) {
+                response = paymentService.capturePayment(response.getPaymentId());
+            }
+            record.setPaymentId(response.getPaymentId());
+            record.setPaymentStatus(response.getStatus().name());
+            record.setResponseCode(response.getErrorCode());
+            record.setMessage(response.getErrorMessage());
+            record.setOutcome(response.getStatus() == PaymentStatus.AUTHORIZED || response.getStatus() == PaymentStatus.CAPTURED
+                ? BatchRecordOutcome.APPROVED : BatchRecordOutcome.DECLINED);
+        } catch (RuntimeException e) {
+            logger.warn("Batch file {} record {} failed: {}", file.getFileId(), record.getRecordNumber(), e.getMessage());
+            fail(record, "PROCESSING_ERROR", e.getMessage());
+        }
+    }
+    
+    private static void fail(BatchFileRecord record, String code, String message) {
+        record.setOutcome(BatchRecordOutcome.ERROR);
+        record.setResponseCode(code);
+        record.setMessage(message);
+    }
+}
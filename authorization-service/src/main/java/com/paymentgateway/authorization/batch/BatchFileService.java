@@ -0,0 +1,87 @@
+package com.paymentgateway.authorization.batch;
+
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileRecord;
+import com.paymentgateway.authorization.domain.BatchFileSource;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import com.paymentgateway.authorization.repository.BatchFileRecordRepository;
+import com.paymentgateway.authorization.repository.BatchFileRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Accepts merchants' batch transaction files and serves their status and
+ * response files. Files are processed in the background by
+ * {@link BatchFileProcessor}.
+ */
+@Service
+public class BatchFileService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(BatchFileService.class);
+    
+    private final BatchFileRepository fileRepository;
+    private final BatchFileRecordRepository recordRepository;
+    
+    public BatchFileService(BatchFileRepository fileRepository, BatchFileRecordRepository recordRepository) {
+        this.fileRepository = fileRepository;
+        this.recordRepository = recordRepository;
+    }
+    
+    /**
+     * Reads and stores a file for processing. A file that cannot be read is
+     * rejected whole with {@link InvalidBatchFileException} and nothing is
+     * stored.
+     */
+    @Transactional
+    public BatchFile submit(UUID merchantId, BatchFileFormat format, String content) {
+        return submit(merchantId, format, content, BatchFileSource.API, null);
+    }
+    
+    /**
+     * Reads and stores a file that came from somewhere other than the API
+     *
+     * @param sourceName The name the file came under
+     */
+    @Transactional
+    public BatchFile submit(UUID merchantId, BatchFileFormat format, String content,
+                            BatchFileSource source, String sourceName) {
+        List<BatchFileRecord> records = BatchFileCodec.parse(format, content);
+        String fileId = "bf_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24);
+        BatchFile file = new BatchFile(fileId, merchantId, format, records.size());
+        file.setSource(source);
+        file.setSourceName(sourceName);
+        file = fileRepository.save(file);
+        for (BatchFileRecord record : records) {
+            record.setBatchFileId(file.getId());
+        }
+        recordRepository.saveAll(records);
+        logger.info("Batch file {} of merchant {} received with {} records", fileId, merchantId, records.size());
+        return file;
+    }
+    
+    /**
+     * A merchant's file; other merchants' files are not found
+     */
+    public Optional<BatchFile> find(UUID merchantId, String fileId) {
+        return fileRepository.findByFileId(fileId).filter(file -> file.getMerchantId().equals(merchantId));
+    }
+    
+    /**
+     * The response file of a completed file, in the file's format
+     *
+     * @throws IllegalStateException if the file is still being processed
+     */
+    public String responseFile(BatchFile file) {
+        if (file.getStatus() != BatchFileStatus.COMPLETED) {
+            throw new IllegalStateException("Batch file " + file.getFileId() + " is " + file.getStatus());
+        }
+        return BatchFileCodec.write(file, recordRepository.findByBatchFileIdOrderByRecordNumber(file.getId()));
+    }
+}
@@ -0,0 +1,20 @@
+package com.paymentgateway.authorization.batch;
+
+import java.util.List;
+
+/**
+ * Exchanges the tokens of a batch file for the cards they stand for
+ */
+public interface BatchTokenResolver {
+    
+    /**
+     * Resolves tokens issued to a merchant, in the order given. A token that
+     * cannot be resolved gets an error rather than failing the call; failing
+     * to reach the token vault throws.
+     *
+     * @param merchantId The merchant's ID; a sandbox merchant's tokens are
+     *                   resolved in its sandbox
+     * @param reference The batch file the tokens come from, for the audit trail
+     */
+    List<ResolvedCard> resolve(String merchantId, List<String> tokens, String reference);
+}
@@ -0,0 +1,11 @@
+package com.paymentgateway.authorization.batch;
+
+/**
+ * A batch file that cannot be read, with the line at fault
+ */
+public class InvalidBatchFileException extends RuntimeException {
+    
+    public InvalidBatchFileException(String message) {
+        super(message);
+    }
+}
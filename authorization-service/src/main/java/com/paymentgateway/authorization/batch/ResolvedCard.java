@@ -0,0 +1,36 @@
+package com.paymentgateway.authorization.batch;
+
+/**
+ * The card a batch file token stands for, or the vault's error code and
+ * message if there is none
+ */
+public final class ResolvedCard {
+    
+    private final String token;
+    private final String pan;
+    private final int expiryMonth;
+    private final int expiryYear;
+    private final String errorCode;
+    private final String errorMessage;
+    
+    public ResolvedCard(String token, String pan, int expiryMonth, int expiryYear,
+                        String errorCode, String errorMessage) {
+        this.token = token;
+        this.pan = pan;
+        this.expiryMonth = expiryMonth;
+        this.expiryYear = expiryYear;
+        this.errorCode = errorCode;
+        this.errorMessage = errorMessage;
+    }
+    
+    public boolean isResolved() {
+        return errorCode == null || errorCode.isEmpty();
+    }
+    
+    public String getToken() { return token; }
+    public String getPan() { return pan; }
+    public int getExpiryMonth() { return expiryMonth; }
+    public int getExpiryYear() { return expiryYear; }
+    public String getErrorCode() { return errorCode; }
+    public String getErrorMessage() { return errorMessage; }
+}
@@ -0,0 +1,124 @@
+package com.paymentgateway.authorization.batch;
+
+import com.paymentgateway.api.tokenization.v2.DetokenizeBatchItem;
+import com.paymentgateway.api.tokenization.v2.DetokenizeBatchRequest;
+import com.paymentgateway.api.tokenization.v2.DetokenizeBatchResponse;
+import com.paymentgateway.api.tokenization.v2.TokenUse;
+import com.paymentgateway.api.tokenization.v2.TokenizationServiceGrpc;
+import com.paymentgateway.authorization.tokens.TokenizationEnvironments;
+import io.grpc.Channel;
+import io.grpc.ClientInterceptors;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Metadata;
+import io.grpc.stub.MetadataUtils;
+import jakarta.annotation.PreDestroy;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+import java.util.ArrayList;
+import java.util.List;
+import java.util.UUID;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Resolves batch file tokens with the tokenization service's v2
+ * DetokenizeBatch. The API key needs the bulk-detokenize role, and each call carries the
+ * justification and the batch file ID for the vault's audit trail. Batch
+ * payments are authorized as e-commerce payments, which is the use checked
+ * against the tokens' domain controls; a token restricted elsewhere fails
+ * its record with PermissionDenied.
+ */
+@Component
+@ConditionalOnProperty(name = "batch.tokenization.address")
+public class TokenizationTokenResolver implements BatchTokenResolver {
+    
+    private static final Logger logger = LoggerFactory.getLogger(TokenizationTokenResolver.class);
+    
+    // The service's limit on tokens per DetokenizeBatch call
+    static final int MAX_TOKENS_PER_CALL = 100;
+    private static final long DEADLINE_MS = 10_000;
+    // The channel batch payments are authorized in, as the vault names it
+    static final String CHANNEL = "ecommerce";
+    
+    private final ManagedChannel managedChannel;
+    private final Channel channel;
+    private final String justification;
+    private final String requestorId;
+    private final TokenizationEnvironments environments;
+    
+    public TokenizationTokenResolver(@Value("${batch.tokenization.address}") String address,
+                                     @Value("${batch.tokenization.api-key:}") String apiKey,
+                                     @Value("${batch.tokenization.justification:Batch file authorization}") String justification,
+                                     @Value("${batch.tokenization.requestor-id:}") String requestorId,
+                                     TokenizationEnvironments environments) {
+        this.managedChannel = ManagedChannelBuilder.forTarget(address).usePlaintext().build();
+        if (apiKey.isBlank()) {
+            this.channel = managedChannel;
+        } else {
+            Metadata headers = new Metadata();
+            headers.put(Metadata.Key.of("authorization", Metadata.ASCII_STRING_MARSHALLER), "Bearer " + apiKey);
+            this.channel = ClientInterceptors.intercept(managedChannel, MetadataUtils.newAttachHeadersInterceptor(headers));
+        }
+        this.justification = justification;
+        this.requestorId = requestorId;
+        this.environments = environments;
+        logger.info("Batch files are detokenized by the tokenization service at {}", address);
+    }
+    
+    @Override
+    public List<ResolvedCard> resolve(String merchantId, List<String> tokens, String reference) {
+        List<ResolvedCard> cards = new ArrayList<>(tokens.size());
+        // A sandbox merchant's tokens are resolved in its sandbox only
+        Channel scoped = TokenizationEnvironments.in(channel, environments.of(UUID.fromString(merchantId)));
+        for (int from = 0; from < tokens.size(); from += MAX_TOKENS_PER_CALL) {
+            List<String> chunk = tokens.subList(from, Math.min(from + MAX_TOKENS_PER_CALL, tokens.size()));
+            DetokenizeBatchRequest request = DetokenizeBatchRequest.newBuilder()
+                .setMerchantId(merchantId)
+                .addAllTokens(chunk)
+                .setJustification(justification)
+                .setReference(reference)
+                .setUse(use(merchantId))
+                .build();
+            DetokenizeBatchResponse response = TokenizationServiceGrpc.newBlockingStub(scoped)
+                .withDeadlineAfter(DEADLINE_MS, TimeUnit.MILLISECONDS)
+                .detokenizeBatch(request);
+            List<ResolvedCard> items = response.getItemsList().stream()
+                .map(TokenizationTokenResolver::toResolvedCard)
+                .toList();
+            if (items.size() != chunk.size()) {
+                throw new IllegalStateException("DetokenizeBatch returned " + items.size() + " items for "
+                    + chunk.size() + " tokens");
+            }
+            cards.addAll(items);
+        }
+        return cards;
+    }
+    
+    /**
+     * The TokenUse of a batch file's payments: the channel, the merchant
+     * accepting them and the gateway's token requestor ID, if it has one
+     */
+    private TokenUse use(String merchantId) {
+        TokenUse.Builder use = TokenUse.newBuilder()
+            .setChannel(CHANNEL)
+            .setMerchantId(merchantId);
+        if (!requestorId.isBlank()) {
+            use.setRequestorId(requestorId);
+        }
+        return use.build();
+    }
+    
+    @PreDestroy
+    public void close() {
+        managedChannel.shutdown();
+    }
+    
+    private static ResolvedCard toResolvedCard(DetokenizeBatchItem item) {
+        return new ResolvedCard(item.getToken(), item.getPan(), item.getExpiryMonth(), item.getExpiryYear(),
+            item.getErrorCode(), item.getErrorMessage());
+    }
+}
@@ -0,0 +1,144 @@
+package com.paymentgateway.authorization.config;
+
+import io.swagger.v3.oas.models.Components;
+import io.swagger.v3.oas.models.OpenAPI;
+import io.swagger.v3.oas.models.Operation;
+import io.swagger.v3.oas.models.headers.Header;
+import io.swagger.v3.oas.models.info.Info;
+import io.swagger.v3.oas.models.media.Content;
+import io.swagger.v3.oas.models.media.IntegerSchema;
+import io.swagger.v3.oas.models.media.MediaType;
+import io.swagger.v3.oas.models.media.ObjectSchema;
+import io.swagger.v3.oas.models.media.Schema;
+import io.swagger.v3.oas.models.media.StringSchema;
+import io.swagger.v3.oas.models.responses.ApiResponse;
+import io.swagger.v3.oas.models.responses.ApiResponses;
+import io.swagger.v3.oas.models.security.SecurityRequirement;
+import io.swagger.v3.oas.models.security.SecurityScheme;
+import io.swagger.v3.oas.models.servers.Server;
+import org.springdoc.core.customizers.OpenApiCustomizer;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.context.annotation.Bean;
+import org.springframework.context.annotation.Configuration;
+
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Set;
+
+/**
+ * OpenAPI 3 description of the gateway's REST API, generated from the
+ * controllers and served at /v3/api-docs (YAML at /v3/api-docs.yaml) with
+ * Swagger UI at /swagger-ui.html.
+ *
+ * Every operation documents the gateway's error envelope
+ * ({@code {"error": {"code", "message", ...}}}) for the error statuses the
+ * exception handler and the security filters produce, so generated clients
+ * get a typed error instead of an untyped body.
+ */
+@Configuration
+public class OpenApiConfig {
+
+    static final String ERROR_SCHEMA = "ErrorResponse";
+    static final String API_KEY_AUTH = "ApiKeyAuth";
+    static final String BEARER_AUTH = "BearerAuth";
+
+    /**
+     * Endpoints reachable without credentials
+     */
+    static final Set<String> PUBLIC_PATHS = Set.of("/api/v1/auth/login");
+
+    private static final Map<String, String> ERROR_RESPONSES = new LinkedHashMap<>();
+
+    static {
+        ERROR_RESPONSES.put("400", "Invalid request (VALIDATION_ERROR)");
+        ERROR_RESPONSES.put("401", "Missing or invalid credentials");
+        ERROR_RESPONSES.put("403", "Authenticated but not permitted");
+        ERROR_RESPONSES.put("429", "Rate limit exceeded (RATE_LIMIT_EXCEEDED)");
+        ERROR_RESPONSES.put("500", "Unexpected error");
+        ERROR_RESPONSES.put("503", "A dependency is unavailable (DEPENDENCY_UNAVAILABLE); retry after Retry-After seconds");
+    }
+
+    @Bean
+    public OpenAPI gatewayOpenApi(@Value("${openapi.server-url:https://localhost:8446}") String serverUrl) {
+        return new OpenAPI()
+            .info(new Info()
+                .title("Payment Acquiring Gateway API")
+                .version("v1")
+                .description("Payments, refunds, credits, transactions, merchant authentication and "
+                    + "webhook deliveries. Authenticate with a merchant API key in X-API-Key or a JWT "
+                    + "from /api/v1/auth/login as a bearer token."))
+            .servers(List.of(new Server().url(serverUrl)))
+            .components(new Components()
+                .addSecuritySchemes(API_KEY_AUTH, new SecurityScheme()
+                    .type(SecurityScheme.Type.APIKEY)
+                    .in(SecurityScheme.In.HEADER)
+                    .name("X-API-Key"))
+                .addSecuritySchemes(BEARER_AUTH, new SecurityScheme()
+                    .type(SecurityScheme.Type.HTTP)
+                    .scheme("bearer")
+                    .bearerFormat("JWT"))
+                .addSchemas(ERROR_SCHEMA, errorSchema()))
+            .addSecurityItem(new SecurityRequirement().addList(API_KEY_AUTH))
+            .addSecurityItem(new SecurityRequirement().addList(BEARER_AUTH));
+    }
+
+    @Bean
+    public OpenApiCustomizer errorResponseCustomizer() {
+        return openApi -> {
+            if (openApi.getPaths() == null) {
+                return;
+            }
+            openApi.getPaths().forEach((path, item) -> item.readOperations().forEach(operation -> {
+                addErrorResponses(operation);
+                if (PUBLIC_PATHS.contains(path)) {
+                    operation.setSecurity(List.of());
+                }
+            }));
+        };
+    }
+
+    private void addErrorResponses(Operation operation) {
+        if (operation.getResponses() == null) {
+            operation.setResponses(new ApiResponses());
+        }
+        ApiResponses responses = operation.getResponses();
+        ERROR_RESPONSES.forEach((status, description) -> {
+            // Responses a controller documents itself are kept as they are
+            if (responses.containsKey(status)) {
+                return;
+            }
+            ApiResponse response = new ApiResponse()
+                .description(description)
+                .content(new Content().addMediaType("application/json",
+                    new MediaType().schema(new Schema<>().$ref("#/components/schemas/" + ERROR_SCHEMA))));
+            if ("429".equals(status) || "503".equals(status)) {
+                response.addHeaderObject("Retry-After", new Header()
+                    .description("Seconds to wait before retrying")
+                    .schema(new IntegerSchema()));
+            }
+            responses.addApiResponse(status, response);
+        });
+    }
+
+    private static Schema<?> errorSchema() {
+        Schema<?> error = new ObjectSchema()
+            .addProperty("code", new StringSchema()
+                .description("Machine-readable error code")
+                .example("VALIDATION_ERROR"))
+            .addProperty("message", new StringSchema().description("Human-readable message"))
+            .addProperty("fields", new ObjectSchema()
+                .description("Per-field messages for validation errors")
+                .additionalProperties(new StringSchema()))
+            .addProperty("retry_after", new IntegerSchema()
+                .format("int64")
+                .description("Seconds until the rate limit window resets, for RATE_LIMIT_EXCEEDED"));
+        error.setRequired(List.of("code", "message"));
+
+        Schema<?> envelope = new ObjectSchema()
+            .description("Error body returned by every endpoint")
+            .addProperty("error", error);
+        envelope.setRequired(List.of("error"));
+        return envelope;
+    }
+}
@@ -5,6 +5,7 @@ import com.paymentgateway.authorization.security.RateLimitFilter;
 import com.paymentgateway.authorization.security.SecurityHeadersFilter;
 import org.springframework.context.annotation.Bean;
 import org.springframework.context.annotation.Configuration;
+import org.springframework.http.HttpMethod;
 import org.springframework.security.config.annotation.method.configuration.EnableMethodSecurity;
 import org.springframework.security.config.annotation.web.builders.HttpSecurity;
 import org.springframework.security.config.annotation.web.configuration.EnableWebSecurity;
@@ -89,8 +90,14 @@ public class SecurityConfig {
                 // Public endpoints
                 .requestMatchers("/actuator/health", "/actuator/info").permitAll()
                 .requestMatchers("/actuator/prometheus").permitAll()
+                // API description, so clients can be generated without credentials
+                .requestMatchers("/v3/api-docs/**", "/swagger-ui.html", "/swagger-ui/**").permitAll()
                 // Auth endpoints
                 .requestMatchers("/api/v1/auth/**").permitAll()
+                // The simulated wallet acts for customers, who hold no credentials
+                .requestMatchers("/api/v1/wallet/**").permitAll()
+                // Applicants have no credentials until they have applied
+                .requestMatchers(HttpMethod.POST, "/api/v1/onboarding/applications").permitAll()
                 // All other endpoints require authentication
                 .anyRequest().authenticated()
             )
@@ -2,11 +2,12 @@ package com.paymentgateway.authorization.controller;
 
 import com.paymentgateway.authorization.audit.AuditLogService;
 import com.paymentgateway.authorization.domain.PaymentEvent;
+import com.paymentgateway.authorization.pagination.CursorPage;
+import io.swagger.v3.oas.annotations.tags.Tag;
 import org.springframework.http.ResponseEntity;
 import org.springframework.security.access.prepost.PreAuthorize;
 import org.springframework.web.bind.annotation.*;
 
-import java.util.List;
 import java.util.UUID;
 
 /**
@@ -14,6 +15,7 @@ import java.util.UUID;
  * Only administrators and authorized merchants can access audit logs.
  */
 @RestController
+@Tag(name = "Audit", description = "Tamper-evident audit trail")
 @RequestMapping("/api/v1/audit")
 public class AuditLogController {
     
@@ -24,17 +26,21 @@ public class AuditLogController {
     }
     
     /**
-     * Get audit logs for a specific payment.
+     * Get audit logs for a specific payment, newest first, a page at a time.
      * Requires ADMIN role or merchant must own the payment.
      * 
      * @param paymentId The payment ID
-     * @return List of audit log entries
+     * @param pageSize Entries per page (default 50, at most 500)
+     * @param pageToken The previous page's nextPageToken
+     * @return One page of audit log entries
      */
     @GetMapping("/payments/{paymentId}")
     @PreAuthorize("hasRole('ADMIN') or @auditLogController.canAccessPayment(#paymentId, authentication)")
-    public ResponseEntity<List<PaymentEvent>> getPaymentAuditLogs(@PathVariable UUID paymentId) {
-        List<PaymentEvent> logs = auditLogService.getAuditLogs(paymentId);
-        return ResponseEntity.ok(logs);
+    public ResponseEntity<CursorPage<PaymentEvent>> getPaymentAuditLogs(
+            @PathVariable UUID paymentId,
+            @RequestParam(required = false) Integer pageSize,
+            @RequestParam(required = false) String pageToken) {
+        return ResponseEntity.ok(auditLogService.getAuditLogPage(paymentId, pageSize, pageToken));
     }
     
     /**
@@ -6,6 +6,7 @@ import com.paymentgateway.authorization.dto.LoginRequest;
 import com.paymentgateway.authorization.dto.TokenResponse;
 import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import io.swagger.v3.oas.annotations.tags.Tag;
 import jakarta.validation.Valid;
 import org.springframework.http.HttpStatus;
 import org.springframework.http.ResponseEntity;
@@ -17,6 +18,7 @@ import java.util.HashMap;
 import java.util.Map;
 
 @RestController
+@Tag(name = "Merchants", description = "Merchant login, API keys and profile")
 @RequestMapping("/api/v1/auth")
 public class AuthController {
     
@@ -0,0 +1,104 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.batch.BatchFileService;
+import com.paymentgateway.authorization.batch.InvalidBatchFileException;
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import com.paymentgateway.authorization.domain.Merchant;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ContentDisposition;
+import org.springframework.http.HttpHeaders;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.MediaType;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Batch transaction files for merchants whose systems submit payments as
+ * files rather than API calls. A file is accepted whole or rejected, then
+ * processed in the background; its response file has every record's
+ * outcome once it is completed.
+ */
+@RestController
+@Tag(name = "Batch files", description = "Batch transaction file upload for legacy merchants")
+@RequestMapping("/api/v1/batch-files")
+public class BatchFileController {
+    
+    private final BatchFileService batchFileService;
+    
+    public BatchFileController(BatchFileService batchFileService) {
+        this.batchFileService = batchFileService;
+    }
+    
+    /**
+     * Upload a file, as csv (the default) or fixed_width
+     */
+    @PostMapping(consumes = {"text/csv", "text/plain", "application/octet-stream"})
+    public ResponseEntity<Map<String, Object>> upload(
+            @RequestAttribute("merchant") Merchant merchant,
+            @RequestParam(defaultValue = "csv") String format,
+            @RequestBody String content) {
+        BatchFileFormat fileFormat = BatchFileFormat.fromName(format).orElse(null);
+        if (fileFormat == null) {
+            return error(400, "INVALID_REQUEST", "format must be csv or fixed_width");
+        }
+        try {
+            BatchFile file = batchFileService.submit(merchant.getId(), fileFormat, content);
+            return ResponseEntity.status(HttpStatus.ACCEPTED).body(toBody(file));
+        } catch (InvalidBatchFileException e) {
+            return error(400, "INVALID_BATCH_FILE", e.getMessage());
+        }
+    }
+    
+    @GetMapping("/{fileId}")
+    public ResponseEntity<Map<String, Object>> getStatus(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable("fileId") String fileId) {
+        return batchFileService.find(merchant.getId(), fileId)
+            .map(file -> ResponseEntity.ok(toBody(file)))
+            .orElseGet(() -> error(404, "BATCH_FILE_NOT_FOUND", "Batch file not found: " + fileId));
+    }
+    
+    /**
+     * The response file of a completed file, in the format it was uploaded in
+     */
+    @GetMapping("/{fileId}/response")
+    public ResponseEntity<?> getResponseFile(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable("fileId") String fileId) {
+        BatchFile file = batchFileService.find(merchant.getId(), fileId).orElse(null);
+        if (file == null) {
+            return error(404, "BATCH_FILE_NOT_FOUND", "Batch file not found: " + fileId);
+        }
+        if (file.getStatus() != BatchFileStatus.COMPLETED) {
+            return error(409, "BATCH_FILE_NOT_COMPLETED", "Batch file " + fileId + " is " + file.getStatus());
+        }
+        String fileName = fileId + "_response." + file.getFormat().getExtension();
+        return ResponseEntity.ok()
+            .contentType(MediaType.parseMediaType(file.getFormat().getContentType()))
+            .header(HttpHeaders.CONTENT_DISPOSITION, ContentDisposition.attachment().filename(fileName).build().toString())
+            .body(batchFileService.responseFile(file));
+    }
+    
+    private static Map<String, Object> toBody(BatchFile file) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("fileId", file.getFileId());
+        body.put("format", file.getFormat().name());
+        body.put("status", file.getStatus().name());
+        body.put("recordCount", file.getRecordCount());
+        body.put("approvedCount", file.getApprovedCount());
+        body.put("declinedCount", file.getDeclinedCount());
+        body.put("errorCount", file.getErrorCount());
+        body.put("createdAt", file.getCreatedAt());
+        body.put("completedAt", file.getCompletedAt());
+        return body;
+    }
+    
+    private static ResponseEntity<Map<String, Object>> error(int status, String code, String message) {
+        return ResponseEntity.status(status).body(Map.of("error", Map.of("code", code, "message", message)));
+    }
+}
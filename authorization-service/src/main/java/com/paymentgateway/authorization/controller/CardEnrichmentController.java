@@ -0,0 +1,56 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.dto.CardEnrichmentRequest;
+import com.paymentgateway.authorization.enrichment.CardEnrichmentException;
+import com.paymentgateway.authorization.enrichment.CardEnrichmentService;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.Map;
+
+/**
+ * Issuer metadata and card art for a BIN or a vault token, for wallet UIs
+ */
+@RestController
+@Tag(name = "Card enrichment", description = "Issuer, product and card art by BIN or token")
+@RequestMapping("/api/v1/cards/enrichment")
+public class CardEnrichmentController {
+    
+    private final CardEnrichmentService enrichmentService;
+    
+    public CardEnrichmentController(CardEnrichmentService enrichmentService) {
+        this.enrichmentService = enrichmentService;
+    }
+    
+    /**
+     * Enrich a BIN, or a token issued to the authenticated merchant
+     */
+    @PostMapping
+    public ResponseEntity<?> enrich(
+            @RequestAttribute("merchant") Merchant merchant,
+            @Valid @RequestBody CardEnrichmentRequest request) {
+        if ((request.getBin() == null) == (request.getToken() == null)) {
+            return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+                "code", "BIN_OR_TOKEN_REQUIRED",
+                "message", "Exactly one of bin and token is required")));
+        }
+        if (request.getBin() != null) {
+            return ResponseEntity.ok(enrichmentService.forBin(request.getBin()));
+        }
+        try {
+            return ResponseEntity.ok(enrichmentService.forToken(merchant.getId().toString(), request.getToken()));
+        } catch (CardEnrichmentException e) {
+            int status = switch (e.getCode()) {
+                case "TOKEN_NOT_FOUND" -> 404;
+                case "TOKEN_LOOKUP_UNAVAILABLE" -> 503;
+                default -> 409;
+            };
+            return ResponseEntity.status(status).body(Map.of("error", Map.of(
+                "code", e.getCode(),
+                "message", e.getMessage())));
+        }
+    }
+}
@@ -0,0 +1,45 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.resilience.CircuitBreaker;
+import com.paymentgateway.authorization.resilience.CircuitBreakerRegistry;
+import com.paymentgateway.authorization.resilience.CircuitBreakerStatus;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.List;
+
+/**
+ * State of the circuit breakers around the gateway's downstream calls, and
+ * a manual reset for when a dependency is known to be back. Requires ADMIN
+ * role.
+ */
+@RestController
+@Tag(name = "Admin", description = "Operational controls for administrators")
+@RequestMapping("/api/v1/admin/circuit-breakers")
+public class CircuitBreakerController {
+    
+    private final CircuitBreakerRegistry circuitBreakers;
+    
+    public CircuitBreakerController(CircuitBreakerRegistry circuitBreakers) {
+        this.circuitBreakers = circuitBreakers;
+    }
+    
+    @GetMapping
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<List<CircuitBreakerStatus>> getCircuitBreakers() {
+        return ResponseEntity.ok(circuitBreakers.getAll().stream()
+            .map(CircuitBreaker::getStatus)
+            .toList());
+    }
+    
+    @PostMapping("/{dependency}/reset")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<CircuitBreakerStatus> reset(@PathVariable String dependency) {
+        if (!circuitBreakers.reset(dependency)) {
+            return ResponseEntity.notFound().build();
+        }
+        return ResponseEntity.ok(circuitBreakers.get(dependency).getStatus());
+    }
+}
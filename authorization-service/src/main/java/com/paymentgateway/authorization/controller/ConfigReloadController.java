@@ -0,0 +1,31 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.reload.ConfigReloadLog;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.List;
+
+/**
+ * Configuration reloads from files, applied or rejected, and what they
+ * changed. Requires ADMIN role.
+ */
+@RestController
+@Tag(name = "Admin", description = "Operational controls for administrators")
+@RequestMapping("/api/v1/config")
+public class ConfigReloadController {
+    
+    private final ConfigReloadLog configReloadLog;
+    
+    public ConfigReloadController(ConfigReloadLog configReloadLog) {
+        this.configReloadLog = configReloadLog;
+    }
+    
+    @GetMapping("/reloads")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<List<ConfigReloadLog.Entry>> getReloads() {
+        return ResponseEntity.ok(configReloadLog.getEntries());
+    }
+}
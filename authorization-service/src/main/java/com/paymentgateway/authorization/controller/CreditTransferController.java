@@ -0,0 +1,102 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+import com.paymentgateway.authorization.domain.CreditTransferStatus;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.iso20022.CreditTransferService;
+import com.paymentgateway.authorization.iso20022.InvalidIso20022MessageException;
+import com.paymentgateway.authorization.iso20022.Iso20022Message;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Account-to-account credit transfers, submitted as ISO 20022 pain.001 or
+ * pacs.008 messages
+ */
+@RestController
+@Tag(name = "Credit transfers", description = "ISO 20022 account-to-account payments")
+@RequestMapping("/api/v1/credit-transfers")
+public class CreditTransferController {
+    
+    private final CreditTransferService creditTransferService;
+    
+    public CreditTransferController(CreditTransferService creditTransferService) {
+        this.creditTransferService = creditTransferService;
+    }
+    
+    /**
+     * Submit a pain.001 or pacs.008 message. Each transaction is accepted or
+     * rejected on its own; a message rejected whole returns its ISO reason
+     * code.
+     */
+    @PostMapping(consumes = {"application/xml", "text/xml"})
+    public ResponseEntity<Map<String, Object>> submit(
+            @RequestAttribute("merchant") Merchant merchant,
+            @RequestBody String xml) {
+        Iso20022Message message;
+        try {
+            message = creditTransferService.ingest(merchant.getId(), xml);
+        } catch (InvalidIso20022MessageException e) {
+            // AM05: a message ID already received
+            boolean duplicate = "AM05".equals(e.getReasonCode());
+            return ResponseEntity.status(duplicate ? 409 : 400).body(Map.of("error", Map.of(
+                "code", duplicate ? "DUPLICATE_MESSAGE" : "INVALID_ISO20022_MESSAGE",
+                "reasonCode", e.getReasonCode(),
+                "message", e.getMessage())));
+        }
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("messageId", message.getMessageId());
+        body.put("messageType", message.getType().getMessageName());
+        body.put("transactionCount", message.getTransfers().size());
+        body.put("rejectedCount", message.getTransfers().stream()
+            .filter(transfer -> transfer.getStatus() == CreditTransferStatus.REJECTED).count());
+        body.put("transfers", message.getTransfers().stream().map(CreditTransferController::toBody).toList());
+        return ResponseEntity.ok(body);
+    }
+    
+    @GetMapping("/{transferId}")
+    public ResponseEntity<Map<String, Object>> getTransfer(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable("transferId") String transferId) {
+        return creditTransferService.find(merchant.getId(), transferId)
+            .map(transfer -> ResponseEntity.ok(toBody(transfer)))
+            .orElseGet(() -> ResponseEntity.status(404).body(Map.of("error", Map.of(
+                "code", "CREDIT_TRANSFER_NOT_FOUND",
+                "message", "Credit transfer not found: " + transferId))));
+    }
+    
+    private static Map<String, Object> toBody(CreditTransfer transfer) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("transferId", transfer.getTransferId());
+        body.put("messageType", transfer.getMessageType().getMessageName());
+        body.put("messageId", transfer.getMessageId());
+        body.put("paymentInfoId", transfer.getPaymentInfoId());
+        body.put("instructionId", transfer.getInstructionId());
+        body.put("endToEndId", transfer.getEndToEndId());
+        body.put("transactionId", transfer.getTransactionId());
+        body.put("amount", transfer.getAmount());
+        body.put("currency", transfer.getCurrency());
+        body.put("executionDate", transfer.getExecutionDate());
+        body.put("debtor", party(transfer.getDebtorName(), transfer.getDebtorIban(), transfer.getDebtorAgentBic()));
+        body.put("creditor", party(transfer.getCreditorName(), transfer.getCreditorIban(), transfer.getCreditorAgentBic()));
+        body.put("remittanceInformation", transfer.getRemittanceInfo());
+        body.put("status", transfer.getStatus().name());
+        body.put("isoStatus", transfer.getStatus().getIsoCode());
+        body.put("reasonCode", transfer.getReasonCode());
+        body.put("createdAt", transfer.getCreatedAt());
+        body.put("settledAt", transfer.getSettledAt());
+        return body;
+    }
+    
+    private static Map<String, Object> party(String name, String iban, String agentBic) {
+        Map<String, Object> party = new LinkedHashMap<>();
+        party.put("name", name);
+        party.put("iban", iban);
+        party.put("agentBic", agentBic);
+        return party;
+    }
+}
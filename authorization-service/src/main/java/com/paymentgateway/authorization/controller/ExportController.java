@@ -0,0 +1,129 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.export.ExportDataset;
+import com.paymentgateway.authorization.export.ExportFormat;
+import com.paymentgateway.authorization.export.ExportService;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.format.annotation.DateTimeFormat;
+import org.springframework.http.ContentDisposition;
+import org.springframework.http.HttpHeaders;
+import org.springframework.http.MediaType;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+import org.springframework.web.servlet.mvc.method.annotation.StreamingResponseBody;
+
+import java.time.Duration;
+import java.time.Instant;
+import java.time.ZoneOffset;
+import java.time.format.DateTimeFormatter;
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.UUID;
+
+/**
+ * Transaction and settlement datasets as CSV or Parquet files, streamed.
+ * Merchants export their own data; admins export everyone's or pick a
+ * merchant with merchantId.
+ */
+@RestController
+@Tag(name = "Exports", description = "Transaction and settlement datasets for analytics")
+@RequestMapping("/api/v1/exports")
+public class ExportController {
+    
+    private static final DateTimeFormatter FILE_TIME = DateTimeFormatter.ofPattern("yyyyMMdd'T'HHmmss'Z'")
+        .withZone(ZoneOffset.UTC);
+    
+    private final ExportService exportService;
+    private final MerchantRepository merchantRepository;
+    
+    public ExportController(ExportService exportService, MerchantRepository merchantRepository) {
+        this.exportService = exportService;
+        this.merchantRepository = merchantRepository;
+    }
+    
+    /**
+     * Columns and types of every dataset's current schema version
+     */
+    @GetMapping("/schemas")
+    public ResponseEntity<Map<String, Object>> getSchemas() {
+        List<Map<String, Object>> datasets = new ArrayList<>();
+        for (ExportDataset dataset : ExportDataset.values()) {
+            Map<String, Object> body = new LinkedHashMap<>();
+            body.put("dataset", dataset.getDatasetName());
+            body.put("schemaVersion", dataset.getSchemaVersion());
+            body.put("columns", dataset.getColumns().stream()
+                .map(column -> Map.of("name", column.getName(), "type", column.getType().name()))
+                .toList());
+            datasets.add(body);
+        }
+        return ResponseEntity.ok(Map.of("datasets", datasets));
+    }
+    
+    /**
+     * Export rows created in [from, to), by default the last 24 hours. The
+     * schema version is in the X-Export-Schema-Version header, the file
+     * name and, for Parquet, the file's metadata.
+     */
+    @GetMapping("/{dataset}")
+    public ResponseEntity<?> export(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable("dataset") String datasetName,
+            @RequestParam(defaultValue = "csv") String format,
+            @RequestParam(required = false) @DateTimeFormat(iso = DateTimeFormat.ISO.DATE_TIME) Instant from,
+            @RequestParam(required = false) @DateTimeFormat(iso = DateTimeFormat.ISO.DATE_TIME) Instant to,
+            @RequestParam(required = false) String merchantId,
+            @RequestParam(required = false) Integer schemaVersion) {
+        ExportDataset dataset = ExportDataset.fromName(datasetName).orElse(null);
+        if (dataset == null) {
+            return error(404, "DATASET_NOT_FOUND", "Unknown dataset " + datasetName
+                    + "; available: transactions, settlements");
+        }
+        ExportFormat exportFormat = ExportFormat.fromName(format).orElse(null);
+        if (exportFormat == null) {
+            return error(400, "INVALID_REQUEST", "format must be csv or parquet");
+        }
+        if (schemaVersion != null && schemaVersion != dataset.getSchemaVersion()) {
+            return error(400, "UNSUPPORTED_SCHEMA_VERSION", "Schema version " + schemaVersion + " of "
+                    + dataset.getDatasetName() + " is not available; current is " + dataset.getSchemaVersion());
+        }
+        
+        Instant end = to != null ? to : Instant.now();
+        Instant start = from != null ? from : end.minus(Duration.ofDays(1));
+        try {
+            exportService.checkRange(start, end);
+        } catch (IllegalArgumentException e) {
+            return error(400, "INVALID_REQUEST", e.getMessage());
+        }
+        
+        // Merchants only ever export their own data
+        UUID scope = merchant.getId();
+        if (merchant.getRoles().contains("ADMIN")) {
+            scope = null;
+            if (merchantId != null && !merchantId.isBlank()) {
+                Merchant target = merchantRepository.findByMerchantId(merchantId).orElse(null);
+                if (target == null) {
+                    return error(404, "MERCHANT_NOT_FOUND", "Merchant not found: " + merchantId);
+                }
+                scope = target.getId();
+            }
+        }
+        
+        String fileName = String.format("%s_v%d_%s_%s.%s", dataset.getDatasetName(), dataset.getSchemaVersion(),
+                FILE_TIME.format(start), FILE_TIME.format(end), exportFormat.getExtension());
+        UUID merchantScope = scope;
+        StreamingResponseBody body = out -> exportService.export(dataset, exportFormat, merchantScope, start, end, out);
+        return ResponseEntity.ok()
+            .contentType(MediaType.parseMediaType(exportFormat.getContentType()))
+            .header(HttpHeaders.CONTENT_DISPOSITION, ContentDisposition.attachment().filename(fileName).build().toString())
+            .header("X-Export-Schema-Version", String.valueOf(dataset.getSchemaVersion()))
+            .body(body);
+    }
+    
+    private static ResponseEntity<Map<String, Object>> error(int status, String code, String message) {
+        return ResponseEntity.status(status).body(Map.of("error", Map.of("code", code, "message", message)));
+    }
+}
@@ -0,0 +1,51 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.fixtures.FixtureService;
+import com.paymentgateway.authorization.fixtures.FixtureSet;
+import com.paymentgateway.authorization.fixtures.InvalidFixtureException;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.Map;
+
+/**
+ * Loads a fixtures document on demand, as the fixtures file is at startup.
+ * Requires ADMIN role outside any sandbox.
+ */
+@RestController
+@Tag(name = "Fixtures", description = "Declarative environment setup")
+@RequestMapping("/api/v1/admin/fixtures")
+@PreAuthorize("hasRole('ADMIN')")
+public class FixtureController {
+    
+    private final FixtureService fixtureService;
+    
+    public FixtureController(FixtureService fixtureService) {
+        this.fixtureService = fixtureService;
+    }
+    
+    /**
+     * Apply a fixtures document, sent as YAML or JSON
+     */
+    @PostMapping(consumes = {"application/yaml", "application/x-yaml", "text/yaml", "text/plain", "application/json"})
+    public ResponseEntity<Map<String, Object>> applyFixtures(
+            @RequestAttribute("merchant") Merchant admin,
+            @RequestBody String document) {
+        if (admin.getSandboxId() != null) {
+            return ResponseEntity.status(HttpStatus.FORBIDDEN).body(Map.of("error", Map.of(
+                "code", "FIXTURES_ADMIN_FORBIDDEN",
+                "message", "Fixtures are loaded by administrators outside any sandbox")));
+        }
+        try {
+            return ResponseEntity.ok(Map.of("applied", fixtureService.apply(FixtureSet.fromYaml(document))));
+        } catch (InvalidFixtureException e) {
+            return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+                "code", "INVALID_FIXTURES",
+                "message", e.getMessage())));
+        }
+    }
+}
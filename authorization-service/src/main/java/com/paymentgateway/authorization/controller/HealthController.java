@@ -9,6 +9,7 @@ import com.paymentgateway.authorization.health.KafkaHealthIndicator;
 import com.paymentgateway.authorization.health.PSPHealthIndicator;
 import com.paymentgateway.authorization.health.RedisHealthIndicator;
 import com.paymentgateway.authorization.monitoring.AlertService;
+import io.swagger.v3.oas.annotations.tags.Tag;
 import org.springframework.boot.actuate.health.Health;
 import org.springframework.http.ResponseEntity;
 import org.springframework.web.bind.annotation.GetMapping;
@@ -27,6 +28,7 @@ import java.util.Map;
  * Requirements: 10.3, 10.4, 10.5, 30.5 - Monitoring, observability, and degradation status
  */
 @RestController
+@Tag(name = "Health", description = "Health, readiness and alerts")
 @RequestMapping("/api/v1/health")
 public class HealthController {
 
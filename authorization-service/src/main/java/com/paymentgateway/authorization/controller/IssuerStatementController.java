@@ -0,0 +1,46 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.dto.CardStatementRequest;
+import com.paymentgateway.authorization.psp.CardFingerprint;
+import com.paymentgateway.authorization.psp.SimulatedIssuer;
+import com.paymentgateway.authorization.psp.SimulatedIssuer.StatementLine;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * Cardholder statements of test cards from the simulated issuer, showing
+ * what cardholders see of each merchant's descriptor. Requires ADMIN role.
+ */
+@RestController
+@Tag(name = "Admin", description = "Operational controls for administrators")
+@RequestMapping("/api/v1/psp/issuer")
+public class IssuerStatementController {
+    
+    private final SimulatedIssuer issuer;
+    
+    public IssuerStatementController(SimulatedIssuer issuer) {
+        this.issuer = issuer;
+    }
+    
+    @PostMapping("/statement")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> getStatement(@Valid @RequestBody CardStatementRequest request) {
+        String card = CardFingerprint.of(request.getCardNumber());
+        List<StatementLine> lines = issuer.getStatement(card);
+        if (lines == null) {
+            return ResponseEntity.notFound().build();
+        }
+        Map<String, Object> body = new HashMap<>();
+        body.put("cardLastFour", request.getCardNumber().substring(request.getCardNumber().length() - 4));
+        body.put("openToBuy", issuer.getOpenToBuy(card));
+        body.put("lines", lines);
+        return ResponseEntity.ok(body);
+    }
+}
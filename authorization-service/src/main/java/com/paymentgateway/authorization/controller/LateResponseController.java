@@ -0,0 +1,31 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.psp.LateResponseLog;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.List;
+
+/**
+ * Authorization responses that arrived after the gateway's timeout, and
+ * whether they were reversed. Requires ADMIN role.
+ */
+@RestController
+@Tag(name = "Admin", description = "Operational controls for administrators")
+@RequestMapping("/api/v1/psp")
+public class LateResponseController {
+    
+    private final LateResponseLog lateResponseLog;
+    
+    public LateResponseController(LateResponseLog lateResponseLog) {
+        this.lateResponseLog = lateResponseLog;
+    }
+    
+    @GetMapping("/late-responses")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<List<LateResponseLog.Entry>> getLateResponses() {
+        return ResponseEntity.ok(lateResponseLog.getEntries());
+    }
+}
@@ -0,0 +1,215 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.dto.DescriptorRequest;
+import com.paymentgateway.authorization.dto.GeoRulesRequest;
+import com.paymentgateway.authorization.dto.MerchantSummaryResponse;
+import com.paymentgateway.authorization.dto.RefundPolicyRequest;
+import com.paymentgateway.authorization.geo.GeoRules;
+import com.paymentgateway.authorization.pagination.CursorPage;
+import com.paymentgateway.authorization.pagination.PageLimits;
+import com.paymentgateway.authorization.pagination.PageToken;
+import com.paymentgateway.authorization.psp.DescriptorRules;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.service.MerchantLifecycleService;
+import com.paymentgateway.authorization.service.MerchantLifecycleService.RestoreResult;
+import com.paymentgateway.authorization.service.RefundPolicy;
+import com.paymentgateway.authorization.service.RefundService;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.data.domain.PageRequest;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.HashMap;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.TreeSet;
+
+/**
+ * Administrative listing, deletion and restoration of onboarded merchants
+ * and their refund policies and geo rules, and the merchant's own
+ * statement descriptor
+ */
+@RestController
+@Tag(name = "Merchants", description = "Merchant login, API keys and profile")
+@RequestMapping("/api/v1/merchants")
+public class MerchantController {
+    
+    static final PageLimits PAGE_LIMITS = new PageLimits(50, 200);
+    
+    private final MerchantRepository merchantRepository;
+    private final MerchantLifecycleService lifecycleService;
+    private final RefundService refundService;
+    
+    public MerchantController(MerchantRepository merchantRepository,
+                              MerchantLifecycleService lifecycleService,
+                              RefundService refundService) {
+        this.merchantRepository = merchantRepository;
+        this.lifecycleService = lifecycleService;
+        this.refundService = refundService;
+    }
+    
+    /**
+     * List merchants ordered by merchant ID, a page at a time.
+     * Requires ADMIN role.
+     * 
+     * @param active Only active (true) or inactive (false) merchants; all if omitted
+     * @param pageSize Merchants per page (default 50, at most 200)
+     * @param pageToken The previous page's nextPageToken
+     * @return One page of merchants
+     */
+    @GetMapping
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<CursorPage<MerchantSummaryResponse>> listMerchants(
+            @RequestParam(required = false) Boolean active,
+            @RequestParam(required = false) Integer pageSize,
+            @RequestParam(required = false) String pageToken) {
+        String fingerprint = PageToken.fingerprint(active);
+        List<String> after = PageToken.decode(pageToken, fingerprint);
+        int size = PAGE_LIMITS.size(pageSize);
+        
+        List<Merchant> merchants = merchantRepository.findPage(
+            active, after == null ? null : after.get(0), PageRequest.of(0, size + 1));
+        CursorPage<Merchant> page = PageToken.page(merchants, size, fingerprint, m -> List.of(m.getMerchantId()));
+        return ResponseEntity.ok(page.map(MerchantSummaryResponse::from));
+    }
+    
+    /**
+     * Set the authenticated merchant's static statement descriptor, shown on
+     * cardholder statements and used as the prefix of dynamic descriptors.
+     * It must be valid for every card brand; 400 INVALID_REQUEST says why
+     * it is not.
+     */
+    @PutMapping("/descriptor")
+    public ResponseEntity<Map<String, Object>> setDescriptor(
+            @RequestAttribute("merchant") Merchant merchant,
+            @Valid @RequestBody DescriptorRequest request) {
+        String descriptor;
+        try {
+            descriptor = DescriptorRules.checkStatic(request.getDescriptor());
+        } catch (IllegalArgumentException e) {
+            return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+                "code", "INVALID_REQUEST",
+                "message", e.getMessage())));
+        }
+        merchant.setStatementDescriptor(descriptor);
+        merchantRepository.save(merchant);
+        return ResponseEntity.ok(Map.of("merchantId", merchant.getMerchantId(), "descriptor", descriptor));
+    }
+    
+    /**
+     * The authenticated merchant's refund policy, defaults included
+     */
+    @GetMapping("/refund-policy")
+    public ResponseEntity<Map<String, Object>> getRefundPolicy(@RequestAttribute("merchant") Merchant merchant) {
+        return ResponseEntity.ok(refundPolicyBody(merchant));
+    }
+    
+    /**
+     * Set a merchant's refund policy; a limit left out reverts to the
+     * default. Requires ADMIN role.
+     */
+    @PutMapping("/{merchantId}/refund-policy")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> setRefundPolicy(
+            @PathVariable String merchantId,
+            @Valid @RequestBody RefundPolicyRequest request) {
+        return merchantRepository.findByMerchantId(merchantId)
+            .map(merchant -> {
+                merchant.setRefundWindowDays(request.getWindowDays());
+                merchant.setMaxRefundsPerPayment(request.getMaxRefunds());
+                merchantRepository.save(merchant);
+                return ResponseEntity.ok(refundPolicyBody(merchant));
+            })
+            .orElseGet(() -> ResponseEntity.notFound().build());
+    }
+    
+    private Map<String, Object> refundPolicyBody(Merchant merchant) {
+        RefundPolicy policy = refundService.policyFor(merchant);
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("merchantId", merchant.getMerchantId());
+        body.put("windowDays", policy.getWindowDays());
+        body.put("maxRefunds", policy.getMaxRefunds());
+        return body;
+    }
+    
+    /**
+     * The authenticated merchant's geo rules
+     */
+    @GetMapping("/geo-rules")
+    public ResponseEntity<Map<String, Object>> getGeoRules(@RequestAttribute("merchant") Merchant merchant) {
+        return ResponseEntity.ok(geoRulesBody(merchant));
+    }
+    
+    /**
+     * Set a merchant's geo rules, replacing its blocked issuing countries.
+     * Requires ADMIN role.
+     */
+    @PutMapping("/{merchantId}/geo-rules")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> setGeoRules(
+            @PathVariable String merchantId,
+            @Valid @RequestBody GeoRulesRequest request) {
+        return merchantRepository.findByMerchantId(merchantId)
+            .map(merchant -> {
+                List<String> blocked = request.getBlockedIssuerCountries();
+                merchant.setBlockedIssuerCountries(blocked == null || blocked.isEmpty()
+                    ? null : String.join(",", new TreeSet<>(blocked)));
+                merchant.setCrossBorderPolicy(request.getCrossBorderPolicy());
+                merchantRepository.save(merchant);
+                return ResponseEntity.ok(geoRulesBody(merchant));
+            })
+            .orElseGet(() -> ResponseEntity.notFound().build());
+    }
+    
+    private Map<String, Object> geoRulesBody(Merchant merchant) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("merchantId", merchant.getMerchantId());
+        body.put("country", merchant.getCountryCode());
+        body.put("blockedIssuerCountries", GeoRules.blockedCountries(merchant.getBlockedIssuerCountries()));
+        body.put("crossBorderPolicy", merchant.getCrossBorderPolicy());
+        return body;
+    }
+    
+    /**
+     * Soft-delete a merchant. It stops authenticating and is no longer listed,
+     * but can be restored until restorableUntil.
+     * Requires ADMIN role.
+     */
+    @DeleteMapping("/{merchantId}")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> deleteMerchant(@PathVariable String merchantId) {
+        return lifecycleService.delete(merchantId)
+            .map(merchant -> {
+                Map<String, Object> response = new HashMap<>();
+                response.put("merchantId", merchant.getMerchantId());
+                response.put("deletedAt", merchant.getDeletedAt());
+                response.put("restorableUntil", lifecycleService.restorableUntil(merchant));
+                return ResponseEntity.ok(response);
+            })
+            .orElseGet(() -> ResponseEntity.notFound().build());
+    }
+    
+    /**
+     * Restore a soft-deleted merchant within its restore window.
+     * Requires ADMIN role.
+     */
+    @PostMapping("/{merchantId}/restore")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> restoreMerchant(@PathVariable String merchantId) {
+        RestoreResult result = lifecycleService.restore(merchantId);
+        if (result == RestoreResult.NOT_FOUND) {
+            return ResponseEntity.notFound().build();
+        }
+        if (result == RestoreResult.WINDOW_EXPIRED) {
+            return ResponseEntity.status(HttpStatus.CONFLICT).body(Map.of("error", Map.of(
+                "code", "RESTORE_WINDOW_EXPIRED",
+                "message", "Merchant " + merchantId + " was deleted too long ago to be restored")));
+        }
+        return ResponseEntity.ok(Map.of("merchantId", merchantId, "restored", true));
+    }
+}
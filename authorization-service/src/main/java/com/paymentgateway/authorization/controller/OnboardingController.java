@@ -0,0 +1,211 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.MerchantOnboarding;
+import com.paymentgateway.authorization.domain.OnboardingDocument;
+import com.paymentgateway.authorization.domain.OnboardingStatus;
+import com.paymentgateway.authorization.dto.ApiKeyResponse;
+import com.paymentgateway.authorization.dto.OnboardingApplicationRequest;
+import com.paymentgateway.authorization.dto.OnboardingDecisionRequest;
+import com.paymentgateway.authorization.dto.OnboardingDocumentRequest;
+import com.paymentgateway.authorization.onboarding.OnboardingRequirements;
+import com.paymentgateway.authorization.onboarding.OnboardingService;
+import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.function.Function;
+import java.util.stream.Collectors;
+
+/**
+ * KYC onboarding: applicants apply without credentials and complete their
+ * application with the test-mode key they get; administrators review it.
+ * Live-mode keys are issued here once the application is approved.
+ */
+@RestController
+@Tag(name = "Onboarding", description = "Merchant KYC onboarding and live-mode API keys")
+@RequestMapping("/api/v1/onboarding")
+public class OnboardingController {
+    
+    private final OnboardingService onboardingService;
+    private final MerchantAuthenticationService authenticationService;
+    
+    public OnboardingController(OnboardingService onboardingService,
+                                MerchantAuthenticationService authenticationService) {
+        this.onboardingService = onboardingService;
+        this.authenticationService = authenticationService;
+    }
+    
+    /**
+     * Apply as a new merchant. The test-mode API key in the response is
+     * shown only once.
+     */
+    @PostMapping("/applications")
+    public ResponseEntity<Map<String, Object>> apply(@Valid @RequestBody OnboardingApplicationRequest request) {
+        OnboardingService.Application application = onboardingService.apply(request.getMerchantName(),
+                request.getMcc(), request.getCountryCode(), request.getCurrency());
+        Map<String, Object> body = toBody(application.getMerchant(), application.getOnboarding());
+        body.put("apiKey", application.getApiKey());
+        return ResponseEntity.status(HttpStatus.CREATED).body(body);
+    }
+    
+    /**
+     * The authenticated merchant's application and what it still lacks
+     */
+    @GetMapping
+    public ResponseEntity<Map<String, Object>> get(@RequestAttribute("merchant") Merchant merchant) {
+        return ResponseEntity.ok(toBody(merchant, onboardingService.find(merchant).orElse(null)));
+    }
+    
+    /**
+     * Set business details by field name; a blank value removes one
+     */
+    @PutMapping("/details")
+    public ResponseEntity<Map<String, Object>> updateDetails(
+            @RequestAttribute("merchant") Merchant merchant,
+            @RequestBody Map<String, String> fields) {
+        try {
+            return ResponseEntity.ok(toBody(merchant, onboardingService.updateDetails(merchant, fields)));
+        } catch (IllegalArgumentException e) {
+            return invalid(e);
+        }
+    }
+    
+    @PostMapping("/documents")
+    public ResponseEntity<Map<String, Object>> addDocument(
+            @RequestAttribute("merchant") Merchant merchant,
+            @Valid @RequestBody OnboardingDocumentRequest request) {
+        try {
+            return ResponseEntity.ok(toBody(merchant,
+                    onboardingService.addDocument(merchant, request.getDocumentType(), request.getReference())));
+        } catch (IllegalArgumentException e) {
+            return invalid(e);
+        }
+    }
+    
+    /**
+     * Submit the application for review
+     */
+    @PostMapping("/submit")
+    public ResponseEntity<Map<String, Object>> submit(@RequestAttribute("merchant") Merchant merchant) {
+        return ResponseEntity.ok(toBody(merchant, onboardingService.submit(merchant)));
+    }
+    
+    /**
+     * Issue a live-mode API key; only approved or live merchants get one
+     */
+    @PostMapping("/live-keys")
+    public ResponseEntity<ApiKeyResponse> createLiveKey(@RequestAttribute("merchant") Merchant merchant) {
+        String apiKey = onboardingService.issueLiveKey(merchant);
+        return ResponseEntity.status(HttpStatus.CREATED).body(new ApiKeyResponse(apiKey,
+                "Live API key created successfully. Store this securely - it won't be shown again."));
+    }
+    
+    @DeleteMapping("/live-keys")
+    public ResponseEntity<Map<String, String>> revokeLiveKey(@RequestAttribute("merchant") Merchant merchant) {
+        authenticationService.revokeLiveApiKey(merchant);
+        return ResponseEntity.ok(Map.of("message", "Live API key revoked successfully"));
+    }
+    
+    /**
+     * Merchants at an onboarding status, by default those awaiting review
+     */
+    @GetMapping("/applications")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> list(
+            @RequestParam(value = "status", defaultValue = "DOCUMENTS_SUBMITTED") OnboardingStatus status) {
+        List<Map<String, Object>> merchants = onboardingService.listByStatus(status).stream()
+                .map(merchant -> toBody(merchant, null))
+                .collect(Collectors.toList());
+        return ResponseEntity.ok(Map.of("status", status.name(), "merchants", merchants));
+    }
+    
+    @GetMapping("/applications/{merchantId}")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> getApplication(@PathVariable("merchantId") String merchantId) {
+        return decide(merchantId, merchant -> onboardingService.find(merchant).orElse(null));
+    }
+    
+    @PostMapping("/applications/{merchantId}/review")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> startReview(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable("merchantId") String merchantId) {
+        return decide(merchantId, merchant -> onboardingService.startReview(merchant, admin.getMerchantId()));
+    }
+    
+    @PostMapping("/applications/{merchantId}/approve")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> approve(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable("merchantId") String merchantId) {
+        return decide(merchantId, merchant -> onboardingService.approve(merchant, admin.getMerchantId()));
+    }
+    
+    @PostMapping("/applications/{merchantId}/reject")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<String, Object>> reject(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable("merchantId") String merchantId,
+            @Valid @RequestBody OnboardingDecisionRequest request) {
+        if (request.getReason() == null || request.getReason().isBlank()) {
+            return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+                "code", "INVALID_ONBOARDING_REQUEST",
+                "message", "A rejection needs a reason")));
+        }
+        return decide(merchantId,
+                merchant -> onboardingService.reject(merchant, admin.getMerchantId(), request.getReason().trim()));
+    }
+    
+    private ResponseEntity<Map<String, Object>> decide(String merchantId,
+                                                       Function<Merchant, MerchantOnboarding> action) {
+        return onboardingService.findMerchant(merchantId)
+            .map(merchant -> ResponseEntity.ok(toBody(merchant, action.apply(merchant))))
+            .orElseGet(() -> ResponseEntity.status(404).body(Map.of("error", Map.of(
+                "code", "MERCHANT_NOT_FOUND",
+                "message", "Merchant not found: " + merchantId))));
+    }
+    
+    private static ResponseEntity<Map<String, Object>> invalid(IllegalArgumentException e) {
+        return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+            "code", "INVALID_ONBOARDING_REQUEST",
+            "message", e.getMessage())));
+    }
+    
+    private Map<String, Object> toBody(Merchant merchant, MerchantOnboarding onboarding) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("merchantId", merchant.getMerchantId());
+        body.put("merchantName", merchant.getMerchantName());
+        body.put("mcc", merchant.getMcc());
+        body.put("status", merchant.getOnboardingStatus().name());
+        body.put("liveKeyIssued", merchant.getLiveApiKeyHash() != null);
+        if (onboarding == null) {
+            return body;
+        }
+        OnboardingRequirements requirements = onboardingService.requirements(merchant);
+        body.put("fields", onboarding.getFields());
+        Map<String, Object> documents = new LinkedHashMap<>();
+        for (Map.Entry<String, OnboardingDocument> document : onboarding.getDocuments().entrySet()) {
+            documents.put(document.getKey(), Map.of(
+                "reference", document.getValue().getReference(),
+                "uploadedAt", document.getValue().getUploadedAt()));
+        }
+        body.put("documents", documents);
+        body.put("requiredFields", requirements.getFields());
+        body.put("requiredDocuments", requirements.getDocuments());
+        body.put("missing", requirements.missing(onboarding));
+        body.put("rejectionReason", onboarding.getRejectionReason());
+        body.put("reviewedBy", onboarding.getReviewedBy());
+        body.put("submittedAt", onboarding.getSubmittedAt());
+        body.put("decidedAt", onboarding.getDecidedAt());
+        body.put("liveAt", onboarding.getLiveAt());
+        return body;
+    }
+}
@@ -1,14 +1,20 @@
 package com.paymentgateway.authorization.controller;
 
+import com.paymentgateway.authorization.dto.CaptureRequest;
+import com.paymentgateway.authorization.dto.CreditRequest;
 import com.paymentgateway.authorization.dto.PaymentRequest;
 import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.dto.PaymentTimelineResponse;
 import com.paymentgateway.authorization.dto.RefundRequest;
 import com.paymentgateway.authorization.dto.RefundResponse;
+import com.paymentgateway.authorization.service.CreditService;
 import com.paymentgateway.authorization.service.PaymentService;
+import com.paymentgateway.authorization.service.PaymentTimelineService;
 import com.paymentgateway.authorization.service.RefundService;
 import io.micrometer.core.instrument.Counter;
 import io.micrometer.core.instrument.MeterRegistry;
 import io.micrometer.core.instrument.Timer;
+import io.swagger.v3.oas.annotations.tags.Tag;
 import jakarta.validation.Valid;
 import org.springframework.http.HttpStatus;
 import org.springframework.http.ResponseEntity;
@@ -17,19 +23,26 @@ import org.springframework.web.bind.annotation.*;
 import java.util.UUID;
 
 @RestController
+@Tag(name = "Payments", description = "Payments, refunds and credits")
 @RequestMapping("/api/v1")
 public class PaymentController {
     
     private final PaymentService paymentService;
     private final RefundService refundService;
+    private final CreditService creditService;
+    private final PaymentTimelineService timelineService;
     private final Counter paymentCounter;
     private final Timer paymentTimer;
     
     public PaymentController(PaymentService paymentService, 
                            RefundService refundService,
+                           CreditService creditService,
+                           PaymentTimelineService timelineService,
                            MeterRegistry meterRegistry) {
         this.paymentService = paymentService;
         this.refundService = refundService;
+        this.creditService = creditService;
+        this.timelineService = timelineService;
         this.paymentCounter = Counter.builder("payments.processed")
             .description("Total number of payments processed")
             .register(meterRegistry);
@@ -58,9 +71,18 @@ public class PaymentController {
         return ResponseEntity.ok(response);
     }
     
+    @GetMapping("/payments/{id}/timeline")
+    public ResponseEntity<PaymentTimelineResponse> getPaymentTimeline(@PathVariable("id") String paymentId) {
+        return ResponseEntity.ok(timelineService.getTimeline(paymentId));
+    }
+    
     @PostMapping("/payments/{id}/capture")
-    public ResponseEntity<PaymentResponse> capturePayment(@PathVariable("id") String paymentId) {
-        PaymentResponse response = paymentService.capturePayment(paymentId);
+    public ResponseEntity<PaymentResponse> capturePayment(
+            @PathVariable("id") String paymentId,
+            @Valid @RequestBody(required = false) CaptureRequest request) {
+        PaymentResponse response = request != null && request.getTipAmount() != null
+            ? paymentService.capturePayment(paymentId, request.getTipAmount())
+            : paymentService.capturePayment(paymentId);
         return ResponseEntity.ok(response);
     }
     
@@ -75,7 +97,8 @@ public class PaymentController {
             @Valid @RequestBody RefundRequest request,
             @RequestAttribute("merchant") com.paymentgateway.authorization.domain.Merchant merchant) {
         
-        RefundResponse response = refundService.processRefund(request, merchant.getId());
+        RefundResponse response = refundService.processRefund(request, merchant.getId(),
+            merchant.getRoles().contains("ADMIN"));
         return ResponseEntity.status(HttpStatus.CREATED).body(response);
     }
     
@@ -84,4 +107,13 @@ public class PaymentController {
         RefundResponse response = refundService.getRefund(refundId);
         return ResponseEntity.ok(response);
     }
+    
+    @PostMapping("/credits")
+    public ResponseEntity<PaymentResponse> createCredit(
+            @Valid @RequestBody CreditRequest request,
+            @RequestAttribute("merchant") com.paymentgateway.authorization.domain.Merchant merchant) {
+        
+        PaymentResponse response = creditService.processCredit(request, merchant);
+        return ResponseEntity.status(HttpStatus.CREATED).body(response);
+    }
 }
@@ -0,0 +1,74 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.QrPayment;
+import com.paymentgateway.authorization.dto.QrPaymentRequest;
+import com.paymentgateway.authorization.qr.QrPaymentService;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Merchant-presented QR codes, each bound to one payment
+ */
+@RestController
+@Tag(name = "QR payments", description = "EMVCo merchant-presented QR codes")
+@RequestMapping("/api/v1/qr-payments")
+public class QrPaymentController {
+    
+    private final QrPaymentService qrPaymentService;
+    
+    public QrPaymentController(QrPaymentService qrPaymentService) {
+        this.qrPaymentService = qrPaymentService;
+    }
+    
+    /**
+     * Issue a QR code for an amount; the payload is the text to render as
+     * the code
+     */
+    @PostMapping
+    public ResponseEntity<Map<String, Object>> create(
+            @RequestAttribute("merchant") Merchant merchant,
+            @Valid @RequestBody QrPaymentRequest request) {
+        try {
+            QrPayment qrPayment = qrPaymentService.create(merchant, request.getAmount(),
+                request.getCurrency(), request.getReference());
+            return ResponseEntity.status(201).body(toBody(qrPayment));
+        } catch (IllegalArgumentException e) {
+            return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+                "code", "INVALID_QR_PAYMENT",
+                "message", e.getMessage())));
+        }
+    }
+    
+    @GetMapping("/{qrPaymentId}")
+    public ResponseEntity<Map<String, Object>> get(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable("qrPaymentId") String qrPaymentId) {
+        return qrPaymentService.find(merchant.getId(), qrPaymentId)
+            .map(qrPayment -> ResponseEntity.ok(toBody(qrPayment)))
+            .orElseGet(() -> ResponseEntity.status(404).body(Map.of("error", Map.of(
+                "code", "QR_PAYMENT_NOT_FOUND",
+                "message", "QR payment not found: " + qrPaymentId))));
+    }
+    
+    static Map<String, Object> toBody(QrPayment qrPayment) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("qrPaymentId", qrPayment.getQrPaymentId());
+        body.put("status", qrPayment.getStatus().name());
+        body.put("amount", qrPayment.getAmount());
+        body.put("currency", qrPayment.getCurrency());
+        body.put("reference", qrPayment.getReference());
+        body.put("payload", qrPayment.getPayload());
+        body.put("attempts", qrPayment.getAttempts());
+        body.put("paymentId", qrPayment.getPaymentId());
+        body.put("createdAt", qrPayment.getCreatedAt());
+        body.put("expiresAt", qrPayment.getExpiresAt());
+        body.put("paidAt", qrPayment.getPaidAt());
+        return body;
+    }
+}
@@ -0,0 +1,167 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.Sandbox;
+import com.paymentgateway.authorization.dto.SandboxRequest;
+import com.paymentgateway.authorization.service.SandboxService;
+import com.paymentgateway.authorization.service.SandboxService.CreatedSandbox;
+import com.paymentgateway.authorization.service.SandboxService.SandboxMerchant;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * Creation and destruction of sandboxes, isolated sets of merchants that
+ * let several teams share one simulator. Requires ADMIN role outside any
+ * sandbox.
+ */
+@RestController
+@Tag(name = "Sandboxes", description = "Isolated tenants of a shared simulator")
+@RequestMapping("/api/v1/admin/sandboxes")
+@PreAuthorize("hasRole('ADMIN')")
+public class SandboxController {
+    
+    private final SandboxService sandboxService;
+    
+    public SandboxController(SandboxService sandboxService) {
+        this.sandboxService = sandboxService;
+    }
+    
+    /**
+     * Create a sandbox. The response holds each merchant's API key, which
+     * is not shown again.
+     */
+    @PostMapping
+    public ResponseEntity<Map<String, Object>> createSandbox(
+            @RequestAttribute("merchant") Merchant admin,
+            @Valid @RequestBody SandboxRequest request) {
+        if (admin.getSandboxId() != null) {
+            return forbidden();
+        }
+        
+        CreatedSandbox created;
+        try {
+            created = sandboxService.create(request.getName(),
+                    request.getMerchants() != null ? request.getMerchants() : 1, admin.getMerchantId());
+        } catch (IllegalArgumentException e) {
+            return invalidRequest(e);
+        }
+        
+        Map<String, Object> body = sandboxBody(created.getSandbox());
+        body.put("merchants", created.getMerchants().stream().map(this::merchantBody).toList());
+        return ResponseEntity.status(HttpStatus.CREATED).body(body);
+    }
+    
+    /**
+     * List sandboxes with how many merchants and payments each holds
+     */
+    @GetMapping
+    public ResponseEntity<Map<String, Object>> listSandboxes(@RequestAttribute("merchant") Merchant admin) {
+        if (admin.getSandboxId() != null) {
+            return forbidden();
+        }
+        List<Map<String, Object>> sandboxes = sandboxService.list().stream().map(this::sandboxSummary).toList();
+        return ResponseEntity.ok(Map.of("sandboxes", sandboxes));
+    }
+    
+    @GetMapping("/{sandboxId}")
+    public ResponseEntity<Map<String, Object>> getSandbox(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable String sandboxId) {
+        if (admin.getSandboxId() != null) {
+            return forbidden();
+        }
+        return sandboxService.find(sandboxId)
+            .map(sandbox -> ResponseEntity.ok(sandboxSummary(sandbox)))
+            .orElseGet(() -> ResponseEntity.notFound().build());
+    }
+    
+    /**
+     * Add a merchant, with a new API key, to a sandbox
+     */
+    @PostMapping("/{sandboxId}/merchants")
+    public ResponseEntity<Map<String, Object>> addMerchant(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable String sandboxId,
+            @RequestBody(required = false) Map<String, String> request) {
+        if (admin.getSandboxId() != null) {
+            return forbidden();
+        }
+        Sandbox sandbox = sandboxService.find(sandboxId).orElse(null);
+        if (sandbox == null) {
+            return ResponseEntity.notFound().build();
+        }
+        
+        try {
+            SandboxMerchant merchant = sandboxService.addMerchant(sandbox,
+                    request != null ? request.get("merchantName") : null);
+            return ResponseEntity.status(HttpStatus.CREATED).body(merchantBody(merchant));
+        } catch (IllegalArgumentException e) {
+            return invalidRequest(e);
+        }
+    }
+    
+    /**
+     * Destroy a sandbox: its merchants, API keys, payments, refunds,
+     * webhooks, settlements and audit records are deleted for good
+     */
+    @DeleteMapping("/{sandboxId}")
+    public ResponseEntity<Map<String, Object>> destroySandbox(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable String sandboxId) {
+        if (admin.getSandboxId() != null) {
+            return forbidden();
+        }
+        return sandboxService.find(sandboxId)
+            .map(sandbox -> {
+                Map<String, Object> response = new HashMap<>();
+                response.put("sandboxId", sandbox.getSandboxId());
+                response.put("deleted", sandboxService.destroy(sandbox));
+                return ResponseEntity.ok(response);
+            })
+            .orElseGet(() -> ResponseEntity.notFound().build());
+    }
+    
+    private Map<String, Object> sandboxBody(Sandbox sandbox) {
+        Map<String, Object> body = new HashMap<>();
+        body.put("sandboxId", sandbox.getSandboxId());
+        body.put("name", sandbox.getName());
+        body.put("createdBy", sandbox.getCreatedBy());
+        body.put("createdAt", sandbox.getCreatedAt());
+        return body;
+    }
+    
+    private Map<String, Object> sandboxSummary(Sandbox sandbox) {
+        Map<String, Object> body = sandboxBody(sandbox);
+        body.put("merchantCount", sandboxService.merchantCount(sandbox));
+        body.put("paymentCount", sandboxService.paymentCount(sandbox));
+        return body;
+    }
+    
+    private Map<String, Object> merchantBody(SandboxMerchant merchant) {
+        Map<String, Object> body = new HashMap<>();
+        body.put("merchantId", merchant.getMerchant().getMerchantId());
+        body.put("merchantName", merchant.getMerchant().getMerchantName());
+        body.put("apiKey", merchant.getApiKey());
+        return body;
+    }
+    
+    private ResponseEntity<Map<String, Object>> forbidden() {
+        return ResponseEntity.status(HttpStatus.FORBIDDEN).body(Map.of("error", Map.of(
+            "code", "SANDBOX_ADMIN_FORBIDDEN",
+            "message", "Sandboxes are managed by administrators outside any sandbox")));
+    }
+    
+    private ResponseEntity<Map<String, Object>> invalidRequest(IllegalArgumentException e) {
+        return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+            "code", "INVALID_REQUEST",
+            "message", e.getMessage())));
+    }
+}
@@ -0,0 +1,146 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.ScreeningHit;
+import com.paymentgateway.authorization.domain.ScreeningHitStatus;
+import com.paymentgateway.authorization.dto.ScreeningDecisionRequest;
+import com.paymentgateway.authorization.screening.ScreeningService;
+import com.paymentgateway.authorization.service.PaymentService;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.stream.Collectors;
+
+/**
+ * The review queue of sanctions screening hits. Releasing a hit lifts its
+ * hold; confirming a payment's hit voids the payment.
+ */
+@RestController
+@Tag(name = "Screening", description = "Sanctions and denylist screening hits")
+@RequestMapping("/api/v1/screening/hits")
+@PreAuthorize("hasRole('ADMIN')")
+public class ScreeningController {
+    
+    private static final Logger logger = LoggerFactory.getLogger(ScreeningController.class);
+    
+    private final ScreeningService screeningService;
+    private final PaymentService paymentService;
+    
+    public ScreeningController(ScreeningService screeningService, PaymentService paymentService) {
+        this.screeningService = screeningService;
+        this.paymentService = paymentService;
+    }
+    
+    @GetMapping
+    public ResponseEntity<Map<String, Object>> list(
+            @RequestParam(value = "status", defaultValue = "HELD") ScreeningHitStatus status) {
+        List<Map<String, Object>> hits = screeningService.list(status).stream()
+            .map(ScreeningController::toBody)
+            .collect(Collectors.toList());
+        return ResponseEntity.ok(Map.of("status", status.name(), "hits", hits));
+    }
+    
+    @GetMapping("/{hitId}")
+    public ResponseEntity<Map<String, Object>> get(@PathVariable("hitId") String hitId) {
+        return screeningService.find(hitId)
+            .map(hit -> ResponseEntity.ok(toBody(hit)))
+            .orElseGet(() -> notFound(hitId));
+    }
+    
+    /**
+     * Release a hit as a false positive
+     */
+    @PostMapping("/{hitId}/release")
+    public ResponseEntity<Map<String, Object>> release(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable("hitId") String hitId,
+            @Valid @RequestBody(required = false) ScreeningDecisionRequest request) {
+        Optional<ScreeningHit> hit = screeningService.find(hitId);
+        if (hit.isEmpty()) {
+            return notFound(hitId);
+        }
+        try {
+            return ResponseEntity.ok(toBody(screeningService.release(hit.get(), admin.getMerchantId(), note(request))));
+        } catch (IllegalStateException e) {
+            return decided(e);
+        }
+    }
+    
+    /**
+     * Confirm a hit as a true match, voiding a held payment
+     */
+    @PostMapping("/{hitId}/confirm")
+    public ResponseEntity<Map<String, Object>> confirm(
+            @RequestAttribute("merchant") Merchant admin,
+            @PathVariable("hitId") String hitId,
+            @Valid @RequestBody(required = false) ScreeningDecisionRequest request) {
+        Optional<ScreeningHit> hit = screeningService.find(hitId);
+        if (hit.isEmpty()) {
+            return notFound(hitId);
+        }
+        ScreeningHit confirmed;
+        try {
+            confirmed = screeningService.confirm(hit.get(), admin.getMerchantId(), note(request));
+        } catch (IllegalStateException e) {
+            return decided(e);
+        }
+        Map<String, Object> body = toBody(confirmed);
+        if (confirmed.getPaymentId() != null) {
+            body.put("paymentStatus", voidHeldPayment(confirmed.getPaymentId()));
+        }
+        return ResponseEntity.ok(body);
+    }
+    
+    // A payment with several hits is voided by the first confirmation
+    private String voidHeldPayment(String paymentId) {
+        try {
+            return paymentService.voidPayment(paymentId).getStatus().name();
+        } catch (RuntimeException e) {
+            logger.info("Payment {} of a confirmed screening hit not voided: {}", paymentId, e.getMessage());
+            return paymentService.getPayment(paymentId).getStatus().name();
+        }
+    }
+    
+    private static String note(ScreeningDecisionRequest request) {
+        return request != null ? request.getNote() : null;
+    }
+    
+    private static ResponseEntity<Map<String, Object>> decided(IllegalStateException e) {
+        return ResponseEntity.status(409).body(Map.of("error", Map.of(
+            "code", "SCREENING_HIT_DECIDED",
+            "message", e.getMessage())));
+    }
+    
+    private static ResponseEntity<Map<String, Object>> notFound(String hitId) {
+        return ResponseEntity.status(404).body(Map.of("error", Map.of(
+            "code", "SCREENING_HIT_NOT_FOUND",
+            "message", "Screening hit not found: " + hitId)));
+    }
+    
+    static Map<String, Object> toBody(ScreeningHit hit) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("hitId", hit.getHitId());
+        body.put("subjectType", hit.getSubjectType().name());
+        body.put("merchantId", hit.getMerchantId());
+        body.put("paymentId", hit.getPaymentId());
+        body.put("screenedName", hit.getScreenedName());
+        body.put("screenedCountry", hit.getScreenedCountry());
+        body.put("provider", hit.getProvider());
+        body.put("matchedEntry", hit.getMatchedEntry());
+        body.put("status", hit.getStatus().name());
+        body.put("decidedBy", hit.getDecidedBy());
+        body.put("decisionNote", hit.getDecisionNote());
+        body.put("createdAt", hit.getCreatedAt());
+        body.put("decidedAt", hit.getDecidedAt());
+        return body;
+    }
+}
@@ -0,0 +1,36 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.psp.ShadowRouting;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+/**
+ * How the candidate cost table would have routed authorizations compared
+ * to the active one; DELETE starts a new report. Requires ADMIN role.
+ */
+@RestController
+@Tag(name = "Admin", description = "Operational controls for administrators")
+@RequestMapping("/api/v1/psp")
+public class ShadowRoutingController {
+    
+    private final ShadowRouting shadowRouting;
+    
+    public ShadowRoutingController(ShadowRouting shadowRouting) {
+        this.shadowRouting = shadowRouting;
+    }
+    
+    @GetMapping("/shadow-routing")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<ShadowRouting.Report> getShadowRouting() {
+        return ResponseEntity.ok(shadowRouting.report());
+    }
+    
+    @DeleteMapping("/shadow-routing")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Void> resetShadowRouting() {
+        shadowRouting.reset();
+        return ResponseEntity.noContent().build();
+    }
+}
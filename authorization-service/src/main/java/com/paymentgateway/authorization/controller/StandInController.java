@@ -0,0 +1,73 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.psp.StandInProcessor;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import org.springframework.http.ResponseEntity;
+import org.springframework.security.access.prepost.PreAuthorize;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.List;
+import java.util.Map;
+import java.util.Set;
+
+/**
+ * Stand-in processing by the simulated network: which issuers are
+ * unavailable, the advices of the decisions taken in their place and how
+ * those reconcile with the issuers. Requires ADMIN role.
+ */
+@RestController
+@Tag(name = "Admin", description = "Operational controls for administrators")
+@RequestMapping("/api/v1/psp/stand-in")
+public class StandInController {
+    
+    private final StandInProcessor standIn;
+    
+    public StandInController(StandInProcessor standIn) {
+        this.standIn = standIn;
+    }
+    
+    @GetMapping("/unavailable-issuers")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Set<String>> getUnavailableIssuers() {
+        return ResponseEntity.ok(standIn.getUnavailableIssuers());
+    }
+    
+    /**
+     * Takes the issuer of a BIN prefix (up to 8 digits) offline; the network
+     * stands in for it
+     */
+    @PutMapping("/unavailable-issuers/{bin}")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Set<String>> markUnavailable(@PathVariable String bin) {
+        if (!bin.matches("\\d{1,8}")) {
+            return ResponseEntity.badRequest().build();
+        }
+        standIn.setIssuerAvailable(bin, false);
+        return ResponseEntity.ok(standIn.getUnavailableIssuers());
+    }
+    
+    /**
+     * Brings the issuer of a BIN range back, forwarding its pending advices
+     */
+    @DeleteMapping("/unavailable-issuers/{bin}")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Set<String>> markAvailable(@PathVariable String bin) {
+        if (!bin.matches("\\d{1,8}")) {
+            return ResponseEntity.badRequest().build();
+        }
+        standIn.setIssuerAvailable(bin, true);
+        return ResponseEntity.ok(standIn.getUnavailableIssuers());
+    }
+    
+    @GetMapping("/advices")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<List<StandInProcessor.Advice>> getAdvices() {
+        return ResponseEntity.ok(standIn.getAdvices());
+    }
+    
+    @GetMapping("/reconciliation")
+    @PreAuthorize("hasRole('ADMIN')")
+    public ResponseEntity<Map<StandInProcessor.Reconciliation, Long>> getReconciliation() {
+        return ResponseEntity.ok(standIn.reconcile());
+    }
+}
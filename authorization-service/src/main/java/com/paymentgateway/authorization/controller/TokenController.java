@@ -0,0 +1,76 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.dto.TokenValidationRequest;
+import com.paymentgateway.authorization.tokens.TokenValidation;
+import com.paymentgateway.authorization.tokens.TokenValidationClient;
+import com.paymentgateway.authorization.tokens.TokenizationEnvironments;
+import io.grpc.StatusRuntimeException;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.http.ResponseEntity;
+import org.springframework.lang.Nullable;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Checks vault tokens issued to the merchant, e.g. stored cards before a
+ * merchant-initiated payment. A sandbox merchant's tokens are checked in its
+ * sandbox.
+ */
+@RestController
+@Tag(name = "Tokens", description = "Vault token validation")
+@RequestMapping("/api/v1/tokens")
+public class TokenController {
+    
+    private final TokenValidationClient validationClient;
+    private final TokenizationEnvironments environments;
+    
+    @Autowired
+    public TokenController(@Nullable TokenValidationClient validationClient, TokenizationEnvironments environments) {
+        this.validationClient = validationClient;
+        this.environments = environments;
+    }
+    
+    /**
+     * Validate a token issued to the authenticated merchant
+     */
+    @PostMapping("/validate")
+    public ResponseEntity<Map<String, Object>> validate(
+            @RequestAttribute("merchant") Merchant merchant,
+            @Valid @RequestBody TokenValidationRequest request) {
+        if (validationClient == null) {
+            return unavailable("No tokenization service is configured (payment.token-validation.tokenization-address)");
+        }
+        TokenValidation validation;
+        try {
+            validation = validationClient.validate(environments.of(merchant), merchant.getId().toString(),
+                request.getToken());
+        } catch (StatusRuntimeException e) {
+            return unavailable("Token vault unreachable: " + e.getStatus().getCode());
+        }
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("token", request.getToken());
+        body.put("valid", validation.isValid());
+        if (validation.getErrorMessage() != null) {
+            body.put("reason", validation.getErrorMessage());
+        }
+        if (validation.getExpiresAt() != null) {
+            body.put("expiresAt", validation.getExpiresAt().toString());
+        }
+        if (validation.getPar() != null) {
+            body.put("par", validation.getPar());
+        }
+        body.put("servedBy", validation.getServedBy());
+        return ResponseEntity.ok(body);
+    }
+    
+    private static ResponseEntity<Map<String, Object>> unavailable(String message) {
+        return ResponseEntity.status(503).body(Map.of("error", Map.of(
+            "code", "TOKEN_VALIDATION_UNAVAILABLE",
+            "message", message)));
+    }
+}
@@ -6,6 +6,7 @@ import com.paymentgateway.authorization.dto.PaymentResponse;
 import com.paymentgateway.authorization.dto.TransactionQueryRequest;
 import com.paymentgateway.authorization.dto.TransactionQueryResponse;
 import com.paymentgateway.authorization.service.TransactionQueryService;
+import io.swagger.v3.oas.annotations.tags.Tag;
 import org.springframework.format.annotation.DateTimeFormat;
 import org.springframework.http.ResponseEntity;
 import org.springframework.web.bind.annotation.*;
@@ -14,6 +15,7 @@ import java.time.Instant;
 import java.util.List;
 
 @RestController
+@Tag(name = "Transactions", description = "Transaction search, history and the merchant dashboard")
 @RequestMapping("/api/v1/transactions")
 public class TransactionController {
     
@@ -0,0 +1,98 @@
+package com.paymentgateway.authorization.controller;
+
+import com.paymentgateway.authorization.dto.WalletQrRequest;
+import com.paymentgateway.authorization.qr.EmvQrCode;
+import com.paymentgateway.authorization.qr.EmvQrCodec;
+import com.paymentgateway.authorization.qr.InvalidQrCodeException;
+import com.paymentgateway.authorization.qr.QrPaymentService;
+import com.paymentgateway.authorization.qr.QrPaymentUnavailableException;
+import com.paymentgateway.authorization.qr.QrScanResult;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * A simulated customer wallet that scans merchant-presented QR codes. It
+ * acts for the customer, so it needs no merchant credentials.
+ */
+@RestController
+@Tag(name = "Simulated wallet", description = "Scans and pays EMVCo QR codes")
+@RequestMapping("/api/v1/wallet/qr")
+public class WalletController {
+    
+    private final QrPaymentService qrPaymentService;
+    
+    public WalletController(QrPaymentService qrPaymentService) {
+        this.qrPaymentService = qrPaymentService;
+    }
+    
+    /**
+     * Read a payload, as a wallet does before asking the customer to pay
+     */
+    @PostMapping("/decode")
+    public ResponseEntity<Map<String, Object>> decode(@Valid @RequestBody WalletQrRequest request) {
+        try {
+            return ResponseEntity.ok(toBody(EmvQrCodec.decode(request.getPayload())));
+        } catch (InvalidQrCodeException e) {
+            return invalid(e);
+        }
+    }
+    
+    /**
+     * Scan a payload and pay it with a card
+     */
+    @PostMapping("/pay")
+    public ResponseEntity<Map<String, Object>> pay(@Valid @RequestBody WalletQrRequest request) {
+        if (request.getCardNumber() == null || request.getExpiryMonth() == null || request.getExpiryYear() == null) {
+            return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+                "code", "CARD_REQUIRED",
+                "message", "cardNumber, expiryMonth and expiryYear are required to pay")));
+        }
+        QrScanResult result;
+        try {
+            result = qrPaymentService.pay(request.getPayload(), request.getCardNumber(),
+                request.getExpiryMonth(), request.getExpiryYear(), request.getCvv());
+        } catch (InvalidQrCodeException e) {
+            return invalid(e);
+        } catch (QrPaymentUnavailableException e) {
+            int status = switch (e.getCode()) {
+                case "QR_PAYMENT_NOT_FOUND" -> 404;
+                case "QR_CODE_MISMATCH" -> 400;
+                default -> 409;
+            };
+            return ResponseEntity.status(status).body(Map.of("error", Map.of(
+                "code", e.getCode(),
+                "message", e.getMessage())));
+        }
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("qrPayment", QrPaymentController.toBody(result.getQrPayment()));
+        body.put("merchant", toBody(result.getCode()));
+        body.put("payment", result.getPayment());
+        return ResponseEntity.ok(body);
+    }
+    
+    private static Map<String, Object> toBody(EmvQrCode code) {
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("dynamic", code.isDynamic());
+        body.put("merchantName", code.getMerchantName());
+        body.put("merchantCity", code.getMerchantCity());
+        body.put("countryCode", code.getCountryCode());
+        body.put("merchantCategoryCode", code.getMerchantCategoryCode());
+        body.put("amount", code.getAmount());
+        body.put("currency", code.getCurrency());
+        body.put("billNumber", code.getBillNumber());
+        body.put("merchantId", code.getMerchantId());
+        body.put("qrPaymentId", code.getQrPaymentId());
+        return body;
+    }
+    
+    private static ResponseEntity<Map<String, Object>> invalid(InvalidQrCodeException e) {
+        return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+            "code", "INVALID_QR_CODE",
+            "message", e.getMessage())));
+    }
+}
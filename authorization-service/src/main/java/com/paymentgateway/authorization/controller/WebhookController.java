@@ -1,22 +1,40 @@
 package com.paymentgateway.authorization.controller;
 
+import com.paymentgateway.authorization.domain.Merchant;
 import com.paymentgateway.authorization.domain.WebhookDelivery;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
+import com.paymentgateway.authorization.dto.WebhookEndpointRequest;
+import com.paymentgateway.authorization.dto.WebhookRedeliveryRequest;
+import com.paymentgateway.authorization.webhook.RedeliveryResult;
+import com.paymentgateway.authorization.webhook.WebhookEndpointService;
 import com.paymentgateway.authorization.webhook.WebhookService;
+import io.swagger.v3.oas.annotations.tags.Tag;
+import jakarta.validation.Valid;
 import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.http.HttpStatus;
 import org.springframework.http.ResponseEntity;
 import org.springframework.security.core.Authentication;
 import org.springframework.web.bind.annotation.*;
 
+import java.time.Duration;
+import java.util.HashMap;
 import java.util.List;
+import java.util.Map;
 import java.util.UUID;
 
 @RestController
+@Tag(name = "Webhooks", description = "Webhook endpoints and delivery history")
 @RequestMapping("/api/v1/webhooks")
 public class WebhookController {
     
+    private static final int MAX_DEAD_LETTER_PAGE = 500;
+    
     @Autowired
     private WebhookService webhookService;
     
+    @Autowired
+    private WebhookEndpointService webhookEndpointService;
+    
     /**
      * Get webhook delivery history for the authenticated merchant.
      */
@@ -41,4 +59,150 @@ public class WebhookController {
         List<WebhookDelivery> deliveries = webhookService.getPaymentDeliveryHistory(paymentId);
         return ResponseEntity.ok(deliveries);
     }
+    
+    /**
+     * List deliveries that exhausted their retries, newest first. Each one
+     * carries the last failure in errorMessage and httpStatusCode.
+     */
+    @GetMapping("/dead-letters")
+    public ResponseEntity<List<WebhookDelivery>> getDeadLetters(
+            @RequestAttribute("merchant") Merchant merchant,
+            @RequestParam(required = false) String eventType,
+            @RequestParam(defaultValue = "100") int limit) {
+        
+        int pageSize = Math.max(1, Math.min(limit, MAX_DEAD_LETTER_PAGE));
+        return ResponseEntity.ok(webhookService.getDeadLetters(merchant.getId(), eventType, pageSize));
+    }
+    
+    /**
+     * Send dead-lettered deliveries again, paced so a recovering endpoint
+     * is not flooded. Without a body every dead letter is redelivered.
+     */
+    @PostMapping("/dead-letters/redeliver")
+    public ResponseEntity<Map<String, Object>> redeliverDeadLetters(
+            @RequestAttribute("merchant") Merchant merchant,
+            @RequestBody(required = false) WebhookRedeliveryRequest request) {
+        
+        WebhookRedeliveryRequest selection = request != null ? request : new WebhookRedeliveryRequest();
+        RedeliveryResult result;
+        try {
+            result = webhookService.redeliverDeadLetters(
+                merchant.getId(), selection.getDeliveryIds(), selection.getEventType());
+        } catch (IllegalStateException e) {
+            return ResponseEntity.status(HttpStatus.CONFLICT).body(Map.of("error", Map.of(
+                "code", "WEBHOOK_NOT_CONFIGURED",
+                "message", e.getMessage())));
+        }
+        
+        Map<String, Object> body = new HashMap<>();
+        body.put("scheduled", result.getScheduled());
+        body.put("firstAttemptAt", result.getFirstAttemptAt());
+        body.put("lastAttemptAt", result.getLastAttemptAt());
+        return ResponseEntity.accepted().body(body);
+    }
+    
+    /**
+     * List the merchant's webhook endpoints. Signing secrets are only
+     * returned when created or rotated.
+     */
+    @GetMapping("/endpoints")
+    public ResponseEntity<List<WebhookEndpoint>> getEndpoints(@RequestAttribute("merchant") Merchant merchant) {
+        return ResponseEntity.ok(webhookEndpointService.list(merchant.getId()));
+    }
+    
+    /**
+     * Register an endpoint. It receives no events until verified.
+     */
+    @PostMapping("/endpoints")
+    public ResponseEntity<Map<String, Object>> registerEndpoint(
+            @RequestAttribute("merchant") Merchant merchant,
+            @Valid @RequestBody WebhookEndpointRequest request) {
+        
+        WebhookEndpoint endpoint;
+        try {
+            endpoint = webhookEndpointService.register(merchant.getId(), request.getUrl());
+        } catch (IllegalArgumentException e) {
+            return invalidRequest(e);
+        }
+        
+        Map<String, Object> body = endpointBody(endpoint);
+        body.put("signingSecret", endpoint.getSigningSecret());
+        return ResponseEntity.status(HttpStatus.CREATED).body(body);
+    }
+    
+    /**
+     * Send the endpoint a challenge it must echo; on success it becomes
+     * active. Fails with 422 ENDPOINT_VERIFICATION_FAILED otherwise.
+     */
+    @PostMapping("/endpoints/{endpointId}/verify")
+    public ResponseEntity<Map<String, Object>> verifyEndpoint(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable UUID endpointId) {
+        
+        return webhookEndpointService.find(merchant.getId(), endpointId)
+                .map(endpoint -> ResponseEntity.ok(endpointBody(webhookEndpointService.verify(endpoint))))
+                .orElse(ResponseEntity.notFound().build());
+    }
+    
+    /**
+     * Replace the endpoint's signing secret. Webhooks carry signatures with
+     * both the old and the new secret for overlapHours (default 24).
+     */
+    @PostMapping("/endpoints/{endpointId}/rotate-secret")
+    public ResponseEntity<Map<String, Object>> rotateEndpointSecret(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable UUID endpointId,
+            @RequestParam(required = false) Long overlapHours) {
+        
+        WebhookEndpoint endpoint = webhookEndpointService.find(merchant.getId(), endpointId).orElse(null);
+        if (endpoint == null) {
+            return ResponseEntity.notFound().build();
+        }
+        
+        String secret;
+        try {
+            secret = webhookEndpointService.rotateSecret(endpoint,
+                    overlapHours != null ? Duration.ofHours(overlapHours) : null);
+        } catch (IllegalArgumentException e) {
+            return invalidRequest(e);
+        }
+        
+        Map<String, Object> body = endpointBody(endpoint);
+        body.put("signingSecret", secret);
+        return ResponseEntity.ok(body);
+    }
+    
+    /**
+     * Stop sending webhooks to the endpoint; its pending deliveries are cancelled.
+     */
+    @DeleteMapping("/endpoints/{endpointId}")
+    public ResponseEntity<Void> disableEndpoint(
+            @RequestAttribute("merchant") Merchant merchant,
+            @PathVariable UUID endpointId) {
+        
+        WebhookEndpoint endpoint = webhookEndpointService.find(merchant.getId(), endpointId).orElse(null);
+        if (endpoint == null) {
+            return ResponseEntity.notFound().build();
+        }
+        webhookEndpointService.disable(endpoint);
+        return ResponseEntity.noContent().build();
+    }
+    
+    private Map<String, Object> endpointBody(WebhookEndpoint endpoint) {
+        Map<String, Object> body = new HashMap<>();
+        body.put("id", endpoint.getId());
+        body.put("url", endpoint.getUrl());
+        body.put("status", endpoint.getStatus());
+        body.put("createdAt", endpoint.getCreatedAt());
+        body.put("verifiedAt", endpoint.getVerifiedAt());
+        body.put("secretRotatedAt", endpoint.getSecretRotatedAt());
+        body.put("previousSecretExpiresAt", endpoint.getPreviousSecretExpiresAt());
+        return body;
+    }
+    
+    private ResponseEntity<Map<String, Object>> invalidRequest(IllegalArgumentException e) {
+        return ResponseEntity.badRequest().body(Map.of("error", Map.of(
+            "code", "INVALID_REQUEST",
+            "message", e.getMessage())));
+    }
 }
@@ -0,0 +1,36 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * What a merchant accepts of the issuer's AVS result on an approved
+ * authorization. An approval whose result the policy does not accept is
+ * voided and the payment declined. Results of U (the issuer has no address
+ * for the card) and payments sent without a billing address are always
+ * accepted.
+ */
+public enum AvsPolicy {
+    
+    // The result is recorded only
+    NONE,
+    // Decline when neither the street nor the postal code matches
+    DECLINE_NO_MATCH,
+    // Decline unless the postal code matches; a ZIP-only match is accepted
+    REQUIRE_ZIP_MATCH,
+    // Decline unless both the street and the postal code match
+    REQUIRE_FULL_MATCH;
+    
+    /**
+     * Whether an AVS result is acceptable: Y (street and postal code
+     * match), A (street only), Z (postal code only), N (neither) or U
+     */
+    public boolean accepts(String avsResult) {
+        if (avsResult == null || this == NONE) {
+            return true;
+        }
+        return switch (avsResult) {
+            case "Y", "U" -> true;
+            case "Z" -> this != REQUIRE_FULL_MATCH;
+            case "A" -> this == DECLINE_NO_MATCH;
+            default -> false;
+        };
+    }
+}
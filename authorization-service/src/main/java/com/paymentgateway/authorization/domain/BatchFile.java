@@ -0,0 +1,110 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.time.Instant;
+import java.util.UUID;
+
+/**
+ * A batch transaction file uploaded by a merchant, processed record by
+ * record in the background
+ */
+@Entity
+@Table(name = "batch_files")
+public class BatchFile {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "file_id", unique = true, nullable = false, length = 30)
+    private String fileId;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 20)
+    private BatchFileFormat format;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 20)
+    private BatchFileStatus status = BatchFileStatus.RECEIVED;
+    
+    @Column(name = "record_count", nullable = false)
+    private int recordCount;
+    
+    @Column(name = "approved_count", nullable = false)
+    private int approvedCount;
+    
+    @Column(name = "declined_count", nullable = false)
+    private int declinedCount;
+    
+    @Column(name = "error_count", nullable = false)
+    private int errorCount;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    @Column(name = "completed_at")
+    private Instant completedAt;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 10)
+    private BatchFileSource source = BatchFileSource.API;
+    
+    // Name the file was dropped under, for files from the drop zone
+    @Column(name = "source_name")
+    private String sourceName;
+    
+    @Column(name = "response_delivered_at")
+    private Instant responseDeliveredAt;
+    
+    public BatchFile() {}
+    
+    public BatchFile(String fileId, UUID merchantId, BatchFileFormat format, int recordCount) {
+        this.fileId = fileId;
+        this.merchantId = merchantId;
+        this.format = format;
+        this.recordCount = recordCount;
+    }
+    
+    /**
+     * Counts a processed record's outcome
+     */
+    public void count(BatchRecordOutcome outcome) {
+        switch (outcome) {
+            case APPROVED -> approvedCount++;
+            case DECLINED -> declinedCount++;
+            case ERROR -> errorCount++;
+            case PENDING -> { }
+        }
+    }
+    
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public String getFileId() { return fileId; }
+    public UUID getMerchantId() { return merchantId; }
+    public BatchFileFormat getFormat() { return format; }
+    
+    public BatchFileStatus getStatus() { return status; }
+    public void setStatus(BatchFileStatus status) { this.status = status; }
+    
+    public int getRecordCount() { return recordCount; }
+    public int getApprovedCount() { return approvedCount; }
+    public int getDeclinedCount() { return declinedCount; }
+    public int getErrorCount() { return errorCount; }
+    public Instant getCreatedAt() { return createdAt; }
+    
+    public Instant getCompletedAt() { return completedAt; }
+    public void setCompletedAt(Instant completedAt) { this.completedAt = completedAt; }
+    
+    public BatchFileSource getSource() { return source; }
+    public void setSource(BatchFileSource source) { this.source = source; }
+    
+    public String getSourceName() { return sourceName; }
+    public void setSourceName(String sourceName) { this.sourceName = sourceName; }
+    
+    public Instant getResponseDeliveredAt() { return responseDeliveredAt; }
+    public void setResponseDeliveredAt(Instant responseDeliveredAt) { this.responseDeliveredAt = responseDeliveredAt; }
+}
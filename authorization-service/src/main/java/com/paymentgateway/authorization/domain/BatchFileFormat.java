@@ -0,0 +1,33 @@
+package com.paymentgateway.authorization.domain;
+
+import java.util.Locale;
+import java.util.Optional;
+
+/**
+ * Layouts of batch transaction files: CSV with a header row, or the
+ * fixed-width record layout of legacy host systems
+ */
+public enum BatchFileFormat {
+    
+    CSV("text/csv", "csv"),
+    FIXED_WIDTH("text/plain", "txt");
+    
+    private final String contentType;
+    private final String extension;
+    
+    BatchFileFormat(String contentType, String extension) {
+        this.contentType = contentType;
+        this.extension = extension;
+    }
+    
+    public static Optional<BatchFileFormat> fromName(String name) {
+        try {
+            return Optional.of(valueOf(name.trim().toUpperCase(Locale.ROOT).replace('-', '_')));
+        } catch (IllegalArgumentException e) {
+            return Optional.empty();
+        }
+    }
+    
+    public String getContentType() { return contentType; }
+    public String getExtension() { return extension; }
+}
@@ -0,0 +1,96 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.util.UUID;
+
+/**
+ * One transaction of a batch file and its outcome. The record keeps the
+ * merchant's token, never the card number it stands for.
+ */
+@Entity
+@Table(name = "batch_file_records")
+public class BatchFileRecord {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "batch_file_id", nullable = false)
+    private UUID batchFileId;
+    
+    @Column(name = "record_number", nullable = false)
+    private int recordNumber;
+    
+    @Column(length = 20)
+    private String reference;
+    
+    @Column(nullable = false, length = 19)
+    private String token;
+    
+    @Column(nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(nullable = false, length = 3)
+    private String currency;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "record_type", nullable = false, length = 20)
+    private BatchRecordType recordType;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 20)
+    private BatchRecordOutcome outcome = BatchRecordOutcome.PENDING;
+    
+    @Column(name = "payment_id", length = 50)
+    private String paymentId;
+    
+    @Column(name = "payment_status", length = 20)
+    private String paymentStatus;
+    
+    @Column(name = "response_code", length = 50)
+    private String responseCode;
+    
+    @Column(columnDefinition = "TEXT")
+    private String message;
+    
+    public BatchFileRecord() {}
+    
+    public BatchFileRecord(int recordNumber, String reference, String token, BigDecimal amount, String currency,
+                           BatchRecordType recordType) {
+        this.recordNumber = recordNumber;
+        this.reference = reference;
+        this.token = token;
+        this.amount = amount;
+        this.currency = currency;
+        this.recordType = recordType;
+    }
+    
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public UUID getBatchFileId() { return batchFileId; }
+    public void setBatchFileId(UUID batchFileId) { this.batchFileId = batchFileId; }
+    
+    public int getRecordNumber() { return recordNumber; }
+    public String getReference() { return reference; }
+    public String getToken() { return token; }
+    public BigDecimal getAmount() { return amount; }
+    public String getCurrency() { return currency; }
+    public BatchRecordType getRecordType() { return recordType; }
+    
+    public BatchRecordOutcome getOutcome() { return outcome; }
+    public void setOutcome(BatchRecordOutcome outcome) { this.outcome = outcome; }
+    
+    public String getPaymentId() { return paymentId; }
+    public void setPaymentId(String paymentId) { this.paymentId = paymentId; }
+    
+    public String getPaymentStatus() { return paymentStatus; }
+    public void setPaymentStatus(String paymentStatus) { this.paymentStatus = paymentStatus; }
+    
+    public String getResponseCode() { return responseCode; }
+    public void setResponseCode(String responseCode) { this.responseCode = responseCode; }
+    
+    public String getMessage() { return message; }
+    public void setMessage(String message) { this.message = message; }
+}
@@ -0,0 +1,10 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * How a batch file reached the gateway: uploaded to the API or dropped on
+ * the SFTP drop zone, where its response file is then delivered
+ */
+public enum BatchFileSource {
+    API,
+    SFTP
+}
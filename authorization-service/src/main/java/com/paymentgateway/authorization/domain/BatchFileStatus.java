@@ -0,0 +1,7 @@
+package com.paymentgateway.authorization.domain;
+
+public enum BatchFileStatus {
+    RECEIVED,
+    PROCESSING,
+    COMPLETED
+}
@@ -0,0 +1,13 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * Result of a batch record: not yet processed, approved (and captured, for
+ * a sale), declined by the issuer or network, or not processed because of
+ * an error such as an unknown token
+ */
+public enum BatchRecordOutcome {
+    PENDING,
+    APPROVED,
+    DECLINED,
+    ERROR
+}
@@ -0,0 +1,10 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * What a batch record asks for: a sale is authorized and captured, an
+ * authorization is only authorized
+ */
+public enum BatchRecordType {
+    SALE,
+    AUTHORIZATION
+}
@@ -7,5 +7,39 @@ public enum CardBrand {
     DISCOVER,
     JCB,
     DINERS,
-    UNIONPAY
+    UNIONPAY;
+    
+    /**
+     * The brand of a PAN by its issuer identification number. Cards outside
+     * the known ranges are taken as Visa, as every card once was.
+     */
+    public static CardBrand fromPan(String pan) {
+        if (pan == null || !pan.matches("\\d{6,19}")) {
+            return VISA;
+        }
+        int two = Integer.parseInt(pan.substring(0, 2));
+        int four = Integer.parseInt(pan.substring(0, 4));
+        if (pan.startsWith("4")) {
+            return VISA;
+        }
+        if ((two >= 51 && two <= 55) || (four >= 2221 && four <= 2720)) {
+            return MASTERCARD;
+        }
+        if (two == 34 || two == 37) {
+            return AMEX;
+        }
+        if (four == 6011 || two == 65 || (four >= 6440 && four <= 6499)) {
+            return DISCOVER;
+        }
+        if (four >= 3528 && four <= 3589) {
+            return JCB;
+        }
+        if (two == 36 || two == 38 || (four >= 3000 && four <= 3059)) {
+            return DINERS;
+        }
+        if (two == 62) {
+            return UNIONPAY;
+        }
+        return VISA;
+    }
 }
@@ -0,0 +1,161 @@
+package com.paymentgateway.authorization.domain;
+
+import com.paymentgateway.authorization.iso20022.Iso20022MessageType;
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.UUID;
+
+/**
+ * An account-to-account credit transfer ingested from an ISO 20022
+ * pain.001 or pacs.008 message, one per CdtTrfTxInf
+ */
+@Entity
+@Table(name = "credit_transfers")
+public class CreditTransfer {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "transfer_id", unique = true, nullable = false, length = 30)
+    private String transferId;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "message_type", nullable = false, length = 10)
+    private Iso20022MessageType messageType;
+    
+    @Column(name = "message_id", nullable = false, length = 35)
+    private String messageId;
+    
+    @Column(name = "payment_info_id", length = 35)
+    private String paymentInfoId;
+    
+    @Column(name = "instruction_id", length = 35)
+    private String instructionId;
+    
+    @Column(name = "end_to_end_id", nullable = false, length = 35)
+    private String endToEndId;
+    
+    // TxId, or the UETR if there is none
+    @Column(name = "transaction_id", length = 36)
+    private String transactionId;
+    
+    @Column(nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(nullable = false, length = 3)
+    private String currency;
+    
+    @Column(name = "execution_date")
+    private LocalDate executionDate;
+    
+    @Column(name = "debtor_name", length = 140)
+    private String debtorName;
+    
+    @Column(name = "debtor_iban", length = 34)
+    private String debtorIban;
+    
+    @Column(name = "debtor_agent_bic", length = 11)
+    private String debtorAgentBic;
+    
+    @Column(name = "creditor_name", length = 140)
+    private String creditorName;
+    
+    @Column(name = "creditor_iban", length = 34)
+    private String creditorIban;
+    
+    @Column(name = "creditor_agent_bic", length = 11)
+    private String creditorAgentBic;
+    
+    @Column(name = "remittance_info", length = 140)
+    private String remittanceInfo;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 20)
+    private CreditTransferStatus status = CreditTransferStatus.ACCEPTED;
+    
+    // ISO 20022 status reason code of a rejected transfer, e.g. AC01
+    @Column(name = "reason_code", length = 4)
+    private String reasonCode;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    @Column(name = "settled_at")
+    private Instant settledAt;
+    
+    public CreditTransfer() {}
+    
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public String getTransferId() { return transferId; }
+    public void setTransferId(String transferId) { this.transferId = transferId; }
+    
+    public UUID getMerchantId() { return merchantId; }
+    public void setMerchantId(UUID merchantId) { this.merchantId = merchantId; }
+    
+    public Iso20022MessageType getMessageType() { return messageType; }
+    public void setMessageType(Iso20022MessageType messageType) { this.messageType = messageType; }
+    
+    public String getMessageId() { return messageId; }
+    public void setMessageId(String messageId) { this.messageId = messageId; }
+    
+    public String getPaymentInfoId() { return paymentInfoId; }
+    public void setPaymentInfoId(String paymentInfoId) { this.paymentInfoId = paymentInfoId; }
+    
+    public String getInstructionId() { return instructionId; }
+    public void setInstructionId(String instructionId) { this.instructionId = instructionId; }
+    
+    public String getEndToEndId() { return endToEndId; }
+    public void setEndToEndId(String endToEndId) { this.endToEndId = endToEndId; }
+    
+    public String getTransactionId() { return transactionId; }
+    public void setTransactionId(String transactionId) { this.transactionId = transactionId; }
+    
+    public BigDecimal getAmount() { return amount; }
+    public void setAmount(BigDecimal amount) { this.amount = amount; }
+    
+    public String getCurrency() { return currency; }
+    public void setCurrency(String currency) { this.currency = currency; }
+    
+    public LocalDate getExecutionDate() { return executionDate; }
+    public void setExecutionDate(LocalDate executionDate) { this.executionDate = executionDate; }
+    
+    public String getDebtorName() { return debtorName; }
+    public void setDebtorName(String debtorName) { this.debtorName = debtorName; }
+    
+    public String getDebtorIban() { return debtorIban; }
+    public void setDebtorIban(String debtorIban) { this.debtorIban = debtorIban; }
+    
+    public String getDebtorAgentBic() { return debtorAgentBic; }
+    public void setDebtorAgentBic(String debtorAgentBic) { this.debtorAgentBic = debtorAgentBic; }
+    
+    public String getCreditorName() { return creditorName; }
+    public void setCreditorName(String creditorName) { this.creditorName = creditorName; }
+    
+    public String getCreditorIban() { return creditorIban; }
+    public void setCreditorIban(String creditorIban) { this.creditorIban = creditorIban; }
+    
+    public String getCreditorAgentBic() { return creditorAgentBic; }
+    public void setCreditorAgentBic(String creditorAgentBic) { this.creditorAgentBic = creditorAgentBic; }
+    
+    public String getRemittanceInfo() { return remittanceInfo; }
+    public void setRemittanceInfo(String remittanceInfo) { this.remittanceInfo = remittanceInfo; }
+    
+    public CreditTransferStatus getStatus() { return status; }
+    public void setStatus(CreditTransferStatus status) { this.status = status; }
+    
+    public String getReasonCode() { return reasonCode; }
+    public void setReasonCode(String reasonCode) { this.reasonCode = reasonCode; }
+    
+    public Instant getCreatedAt() { return createdAt; }
+    
+    public Instant getSettledAt() { return settledAt; }
+    public void setSettledAt(Instant settledAt) { this.settledAt = settledAt; }
+}
@@ -0,0 +1,22 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * Status of an account-to-account credit transfer, with the ISO 20022
+ * transaction status code it is reported as
+ */
+public enum CreditTransferStatus {
+    
+    // Accepted, to be executed on its requested date
+    ACCEPTED("ACCP"),
+    // Credited to the creditor's account
+    SETTLED("ACSC"),
+    REJECTED("RJCT");
+    
+    private final String isoCode;
+    
+    CreditTransferStatus(String isoCode) {
+        this.isoCode = isoCode;
+    }
+    
+    public String getIsoCode() { return isoCode; }
+}
@@ -0,0 +1,15 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * What a merchant does with a card issued outside its own country. Cards
+ * whose issuing country the BIN table does not know are never cross-border.
+ */
+public enum CrossBorderPolicy {
+    
+    // Authorized as usual; settlement still charges the cross-border markup
+    ALLOW,
+    // Authorized, with the payment's fraud status set to REVIEW
+    FLAG,
+    // Declined before it is sent to an acquirer
+    BLOCK
+}
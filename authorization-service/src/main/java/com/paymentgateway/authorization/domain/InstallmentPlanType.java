@@ -0,0 +1,14 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * Who finances a payment the cardholder repays in installments. The whole
+ * amount is authorized and cleared once; the issuer bills the cardholder
+ * in parts. Merchant-financed plans are interest-free for the cardholder,
+ * the merchant paying the cost of the credit; issuer-financed plans carry
+ * the issuer's interest. Unlike stored-credential installments, there is
+ * only one payment.
+ */
+public enum InstallmentPlanType {
+    MERCHANT,
+    ISSUER
+}
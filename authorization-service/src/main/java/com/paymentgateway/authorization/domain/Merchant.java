@@ -1,6 +1,10 @@
 package com.paymentgateway.authorization.domain;
 
 import jakarta.persistence.*;
+import org.hibernate.annotations.SQLRestriction;
+
+import java.math.BigDecimal;
+import java.time.Instant;
 import java.time.LocalDate;
 import java.util.HashSet;
 import java.util.Set;
@@ -8,6 +12,9 @@ import java.util.UUID;
 
 @Entity
 @Table(name = "merchants")
+// Soft-deleted merchants are invisible to every entity query; only the
+// native queries of MerchantRepository reach them
+@SQLRestriction("deleted_at IS NULL")
 public class Merchant {
     
     @Id
@@ -41,6 +48,10 @@ public class Merchant {
     @Column(name = "api_key_hash")
     private String apiKeyHash;
     
+    // Only authenticates while the merchant is LIVE
+    @Column(name = "live_api_key_hash")
+    private String liveApiKeyHash;
+    
     @Column(name = "webhook_url")
     private String webhookUrl;
     
@@ -53,6 +64,61 @@ public class Merchant {
     @Column(name = "rate_limit_per_second")
     private Integer rateLimitPerSecond = 100;
     
+    @Column(name = "standalone_credits_enabled", nullable = false)
+    private Boolean standaloneCreditsEnabled = false;
+    
+    // Name on cardholder statements, see DescriptorRules; null uses the
+    // merchant name
+    @Column(name = "statement_descriptor", length = 18)
+    private String statementDescriptor;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "avs_policy", nullable = false, length = 20)
+    private AvsPolicy avsPolicy = AvsPolicy.NONE;
+    
+    // Surcharge percentage added to card payments, within the brands'
+    // caps, see SurchargeService
+    @Column(name = "surcharge_percent", precision = 5, scale = 2)
+    private BigDecimal surchargePercent;
+    
+    // Flat fee added to MOTO payments instead of a surcharge
+    @Column(name = "convenience_fee", precision = 12, scale = 2)
+    private BigDecimal convenienceFee;
+    
+    // The marketplace platform this merchant is a sub-merchant of, which
+    // may split its payments to it; null for merchants of record
+    @Column(name = "platform_merchant_id")
+    private UUID platformMerchantId;
+    
+    // Refund policy, see RefundPolicy; null uses the gateway default
+    @Column(name = "refund_window_days")
+    private Integer refundWindowDays;
+    
+    @Column(name = "max_refunds_per_payment")
+    private Integer maxRefundsPerPayment;
+    
+    @Column(name = "deleted_at")
+    private Instant deletedAt;
+    
+    // KYC stage, see OnboardingStatus; merchants created outside
+    // onboarding are LIVE
+    @Enumerated(EnumType.STRING)
+    @Column(name = "onboarding_status", nullable = false, length = 30)
+    private OnboardingStatus onboardingStatus = OnboardingStatus.LIVE;
+    
+    // Geo rules: issuing countries whose cards are declined, comma-separated,
+    // and what is done with cards issued outside the merchant's country
+    @Column(name = "blocked_issuer_countries")
+    private String blockedIssuerCountries;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "cross_border_policy", nullable = false, length = 20)
+    private CrossBorderPolicy crossBorderPolicy = CrossBorderPolicy.ALLOW;
+    
+    // The sandbox the merchant belongs to; null outside sandboxes
+    @Column(name = "sandbox_id")
+    private UUID sandboxId;
+    
     @ElementCollection(fetch = FetchType.EAGER)
     @CollectionTable(name = "merchant_roles", joinColumns = @JoinColumn(name = "merchant_id"))
     @Column(name = "role")
@@ -97,6 +163,9 @@ public class Merchant {
     public String getApiKeyHash() { return apiKeyHash; }
     public void setApiKeyHash(String apiKeyHash) { this.apiKeyHash = apiKeyHash; }
     
+    public String getLiveApiKeyHash() { return liveApiKeyHash; }
+    public void setLiveApiKeyHash(String liveApiKeyHash) { this.liveApiKeyHash = liveApiKeyHash; }
+    
     public String getWebhookUrl() { return webhookUrl; }
     public void setWebhookUrl(String webhookUrl) { this.webhookUrl = webhookUrl; }
     
@@ -109,6 +178,45 @@ public class Merchant {
     public Integer getRateLimitPerSecond() { return rateLimitPerSecond; }
     public void setRateLimitPerSecond(Integer rateLimitPerSecond) { this.rateLimitPerSecond = rateLimitPerSecond; }
     
+    public Boolean getStandaloneCreditsEnabled() { return standaloneCreditsEnabled; }
+    public void setStandaloneCreditsEnabled(Boolean standaloneCreditsEnabled) { this.standaloneCreditsEnabled = standaloneCreditsEnabled; }
+    
+    public String getStatementDescriptor() { return statementDescriptor; }
+    public void setStatementDescriptor(String statementDescriptor) { this.statementDescriptor = statementDescriptor; }
+    
+    public AvsPolicy getAvsPolicy() { return avsPolicy; }
+    public void setAvsPolicy(AvsPolicy avsPolicy) { this.avsPolicy = avsPolicy; }
+    
+    public BigDecimal getSurchargePercent() { return surchargePercent; }
+    public void setSurchargePercent(BigDecimal surchargePercent) { this.surchargePercent = surchargePercent; }
+    
+    public BigDecimal getConvenienceFee() { return convenienceFee; }
+    public void setConvenienceFee(BigDecimal convenienceFee) { this.convenienceFee = convenienceFee; }
+    
+    public UUID getPlatformMerchantId() { return platformMerchantId; }
+    public void setPlatformMerchantId(UUID platformMerchantId) { this.platformMerchantId = platformMerchantId; }
+    
+    public Integer getRefundWindowDays() { return refundWindowDays; }
+    public void setRefundWindowDays(Integer refundWindowDays) { this.refundWindowDays = refundWindowDays; }
+    
+    public Integer getMaxRefundsPerPayment() { return maxRefundsPerPayment; }
+    public void setMaxRefundsPerPayment(Integer maxRefundsPerPayment) { this.maxRefundsPerPayment = maxRefundsPerPayment; }
+    
+    public Instant getDeletedAt() { return deletedAt; }
+    public void setDeletedAt(Instant deletedAt) { this.deletedAt = deletedAt; }
+    
+    public OnboardingStatus getOnboardingStatus() { return onboardingStatus; }
+    public void setOnboardingStatus(OnboardingStatus onboardingStatus) { this.onboardingStatus = onboardingStatus; }
+    
+    public String getBlockedIssuerCountries() { return blockedIssuerCountries; }
+    public void setBlockedIssuerCountries(String blockedIssuerCountries) { this.blockedIssuerCountries = blockedIssuerCountries; }
+    
+    public CrossBorderPolicy getCrossBorderPolicy() { return crossBorderPolicy; }
+    public void setCrossBorderPolicy(CrossBorderPolicy crossBorderPolicy) { this.crossBorderPolicy = crossBorderPolicy; }
+    
+    public UUID getSandboxId() { return sandboxId; }
+    public void setSandboxId(UUID sandboxId) { this.sandboxId = sandboxId; }
+    
     public Set<String> getRoles() { return roles; }
     public void setRoles(Set<String> roles) { this.roles = roles; }
     
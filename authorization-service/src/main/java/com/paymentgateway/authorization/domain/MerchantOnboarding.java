@@ -0,0 +1,92 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.time.Instant;
+import java.util.LinkedHashMap;
+import java.util.Map;
+import java.util.UUID;
+
+/**
+ * The KYC application of a merchant that applied through onboarding: its
+ * business details, documents and the review decision. The stage itself
+ * is the merchant's onboarding status.
+ */
+@Entity
+@Table(name = "merchant_onboarding")
+public class MerchantOnboarding {
+    
+    @Id
+    @Column(name = "merchant_id")
+    private UUID merchantId;
+    
+    // Business details by field name, see OnboardingRequirements
+    @ElementCollection(fetch = FetchType.EAGER)
+    @CollectionTable(name = "merchant_onboarding_fields", joinColumns = @JoinColumn(name = "merchant_id"))
+    @MapKeyColumn(name = "field_name", length = 50)
+    @Column(name = "field_value", nullable = false)
+    private Map<String, String> fields = new LinkedHashMap<>();
+    
+    // The latest upload of each document type
+    @ElementCollection(fetch = FetchType.EAGER)
+    @CollectionTable(name = "merchant_onboarding_documents", joinColumns = @JoinColumn(name = "merchant_id"))
+    @MapKeyColumn(name = "document_type", length = 50)
+    private Map<String, OnboardingDocument> documents = new LinkedHashMap<>();
+    
+    @Column(name = "rejection_reason")
+    private String rejectionReason;
+    
+    // The administrator who approved or rejected the application
+    @Column(name = "reviewed_by", length = 50)
+    private String reviewedBy;
+    
+    @Column(name = "submitted_at")
+    private Instant submittedAt;
+    
+    @Column(name = "decided_at")
+    private Instant decidedAt;
+    
+    @Column(name = "live_at")
+    private Instant liveAt;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    @Column(name = "updated_at", nullable = false)
+    private Instant updatedAt = Instant.now();
+    
+    public MerchantOnboarding() {}
+    
+    public MerchantOnboarding(UUID merchantId) {
+        this.merchantId = merchantId;
+    }
+    
+    public UUID getMerchantId() { return merchantId; }
+    public void setMerchantId(UUID merchantId) { this.merchantId = merchantId; }
+    
+    public Map<String, String> getFields() { return fields; }
+    public void setFields(Map<String, String> fields) { this.fields = fields; }
+    
+    public Map<String, OnboardingDocument> getDocuments() { return documents; }
+    public void setDocuments(Map<String, OnboardingDocument> documents) { this.documents = documents; }
+    
+    public String getRejectionReason() { return rejectionReason; }
+    public void setRejectionReason(String rejectionReason) { this.rejectionReason = rejectionReason; }
+    
+    public String getReviewedBy() { return reviewedBy; }
+    public void setReviewedBy(String reviewedBy) { this.reviewedBy = reviewedBy; }
+    
+    public Instant getSubmittedAt() { return submittedAt; }
+    public void setSubmittedAt(Instant submittedAt) { this.submittedAt = submittedAt; }
+    
+    public Instant getDecidedAt() { return decidedAt; }
+    public void setDecidedAt(Instant decidedAt) { this.decidedAt = decidedAt; }
+    
+    public Instant getLiveAt() { return liveAt; }
+    public void setLiveAt(Instant liveAt) { this.liveAt = liveAt; }
+    
+    public Instant getCreatedAt() { return createdAt; }
+    public void setCreatedAt(Instant createdAt) { this.createdAt = createdAt; }
+    
+    public Instant getUpdatedAt() { return updatedAt; }
+    public void setUpdatedAt(Instant updatedAt) { this.updatedAt = updatedAt; }
+}
@@ -0,0 +1,31 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.time.Instant;
+
+/**
+ * A KYC document uploaded for onboarding, by the reference of wherever the
+ * merchant stored it
+ */
+@Embeddable
+public class OnboardingDocument {
+    
+    @Column(name = "reference", nullable = false)
+    private String reference;
+    
+    @Column(name = "uploaded_at", nullable = false)
+    private Instant uploadedAt;
+    
+    public OnboardingDocument() {}
+    
+    public OnboardingDocument(String reference, Instant uploadedAt) {
+        this.reference = reference;
+        this.uploadedAt = uploadedAt;
+    }
+    
+    public String getReference() { return reference; }
+    public void setReference(String reference) { this.reference = reference; }
+    
+    public Instant getUploadedAt() { return uploadedAt; }
+    public void setUploadedAt(Instant uploadedAt) { this.uploadedAt = uploadedAt; }
+}
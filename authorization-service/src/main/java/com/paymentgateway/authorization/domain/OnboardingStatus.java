@@ -0,0 +1,34 @@
+package com.paymentgateway.authorization.domain;
+
+import java.util.EnumSet;
+import java.util.Set;
+
+/**
+ * Where a merchant is in KYC onboarding. Only a LIVE merchant's live-mode
+ * API key authenticates; merchants that predate onboarding are LIVE.
+ */
+public enum OnboardingStatus {
+    // Applied; filling in details and uploading documents
+    DRAFT,
+    // Every field and document its MCC requires is in
+    DOCUMENTS_SUBMITTED,
+    UNDER_REVIEW,
+    // May take a live-mode API key, which makes it LIVE
+    APPROVED,
+    REJECTED,
+    LIVE;
+    
+    public Set<OnboardingStatus> next() {
+        switch (this) {
+            case DRAFT: return EnumSet.of(DOCUMENTS_SUBMITTED);
+            case DOCUMENTS_SUBMITTED: return EnumSet.of(UNDER_REVIEW);
+            case UNDER_REVIEW: return EnumSet.of(APPROVED, REJECTED);
+            case APPROVED: return EnumSet.of(LIVE);
+            default: return EnumSet.noneOf(OnboardingStatus.class);
+        }
+    }
+    
+    public boolean canMoveTo(OnboardingStatus status) {
+        return next().contains(status);
+    }
+}
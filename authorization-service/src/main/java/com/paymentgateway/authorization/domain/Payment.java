@@ -3,6 +3,8 @@ package com.paymentgateway.authorization.domain;
 import jakarta.persistence.*;
 import java.math.BigDecimal;
 import java.time.Instant;
+import java.util.ArrayList;
+import java.util.List;
 import java.util.UUID;
 
 @Entity
@@ -65,6 +67,13 @@ public class Payment {
     @Column(name = "psp_reference", length = 100)
     private String pspReference;
     
+    // Acquirer that processed the payment, and every routing attempt
+    @Column(name = "psp_name", length = 50)
+    private String pspName;
+    
+    @Column(name = "routing_path")
+    private String routingPath;
+    
     @Column(name = "acquirer_reference", length = 100)
     private String acquirerReference;
     
@@ -94,9 +103,42 @@ public class Payment {
     @Column(name = "three_ds_xid", columnDefinition = "TEXT")
     private String threeDsXid;
     
+    // Strong customer authentication
+    @Enumerated(EnumType.STRING)
+    @Column(name = "sca_exemption", length = 30)
+    private ScaExemption scaExemption;
+    
+    @Column(name = "decline_code", length = 20)
+    private String declineCode;
+    
+    @Column(name = "original_payment_id", length = 100)
+    private String originalPaymentId;
+    
+    // Transaction initiation
+    @Enumerated(EnumType.STRING)
+    @Column(name = "transaction_channel", length = 20)
+    private TransactionChannel channel = TransactionChannel.ECOMMERCE;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "initiator", length = 20)
+    private TransactionInitiator initiator = TransactionInitiator.CUSTOMER;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "stored_credential", length = 20)
+    private StoredCredentialType storedCredential;
+    
+    @Column(name = "network_transaction_id", length = 15)
+    private String networkTransactionId;
+    
+    @Column(name = "original_network_transaction_id", length = 15)
+    private String originalNetworkTransactionId;
+    
     @Column(name = "billing_street", columnDefinition = "TEXT")
     private String billingStreet;
     
+    @Column(name = "billing_street2", columnDefinition = "TEXT")
+    private String billingStreet2;
+    
     @Column(name = "billing_city", length = 100)
     private String billingCity;
     
@@ -109,6 +151,68 @@ public class Payment {
     @Column(name = "billing_country", length = 2)
     private String billingCountry;
     
+    // Name on the cardholder's statement, with any dynamic suffix
+    @Column(name = "statement_descriptor", length = 25)
+    private String statementDescriptor;
+    
+    // AVS result from the issuer, see SimulatedIssuer.verifyAddress
+    @Column(name = "avs_result", length = 1)
+    private String avsResult;
+    
+    // CVV result from the issuer, kept as representment evidence
+    @Column(name = "cvv_result", length = 1)
+    private String cvvResult;
+    
+    // Surcharge or convenience fee included in the amount, see
+    // SurchargeService
+    @Column(name = "surcharge_amount", precision = 12, scale = 2)
+    private BigDecimal surchargeAmount;
+    
+    @Column(name = "convenience_fee", precision = 12, scale = 2)
+    private BigDecimal convenienceFee;
+    
+    // Installment plan, authorized and cleared as one payment
+    @Column(name = "installment_count")
+    private Integer installmentCount;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "installment_plan_type", length = 10)
+    private InstallmentPlanType installmentPlanType;
+    
+    // Issuing country of the card from the BIN table, null when unknown;
+    // cross-border when it differs from the merchant's country
+    @Column(name = "issuer_country", length = 2)
+    private String issuerCountry;
+    
+    @Column(name = "cross_border", nullable = false)
+    private boolean crossBorder;
+    
+    // Tip added at capture, within the card brand's tolerance
+    @Column(name = "tip_amount", precision = 12, scale = 2)
+    private BigDecimal tipAmount;
+    
+    // Level 2/3 purchasing data; the level decides the interchange
+    // program the payment can qualify for in settlement
+    @Column(name = "tax_amount", precision = 12, scale = 2)
+    private BigDecimal taxAmount;
+    
+    @Column(name = "customer_code", length = 17)
+    private String customerCode;
+    
+    @Column(name = "purchase_data_level", nullable = false)
+    private Integer purchaseDataLevel = 1;
+    
+    @ElementCollection
+    @CollectionTable(name = "payment_line_items", joinColumns = @JoinColumn(name = "payment_id"))
+    @OrderColumn(name = "line_number")
+    private List<PaymentLineItem> lineItems = new ArrayList<>();
+    
+    // Marketplace portions for the merchant's sub-merchants
+    @ElementCollection
+    @CollectionTable(name = "payment_splits", joinColumns = @JoinColumn(name = "payment_id"))
+    @OrderColumn(name = "split_number")
+    private List<PaymentSplit> splits = new ArrayList<>();
+    
     @Column(name = "processing_time_ms")
     private Integer processingTimeMs;
     
@@ -188,6 +292,12 @@ public class Payment {
     public String getPspReference() { return pspReference; }
     public void setPspReference(String pspReference) { this.pspReference = pspReference; }
     
+    public String getPspName() { return pspName; }
+    public void setPspName(String pspName) { this.pspName = pspName; }
+    
+    public String getRoutingPath() { return routingPath; }
+    public void setRoutingPath(String routingPath) { this.routingPath = routingPath; }
+    
     public String getAcquirerReference() { return acquirerReference; }
     public void setAcquirerReference(String acquirerReference) { this.acquirerReference = acquirerReference; }
     
@@ -215,9 +325,36 @@ public class Payment {
     public String getThreeDsXid() { return threeDsXid; }
     public void setThreeDsXid(String threeDsXid) { this.threeDsXid = threeDsXid; }
     
+    public ScaExemption getScaExemption() { return scaExemption; }
+    public void setScaExemption(ScaExemption scaExemption) { this.scaExemption = scaExemption; }
+    
+    public String getDeclineCode() { return declineCode; }
+    public void setDeclineCode(String declineCode) { this.declineCode = declineCode; }
+    
+    public String getOriginalPaymentId() { return originalPaymentId; }
+    public void setOriginalPaymentId(String originalPaymentId) { this.originalPaymentId = originalPaymentId; }
+    
+    public TransactionChannel getChannel() { return channel; }
+    public void setChannel(TransactionChannel channel) { this.channel = channel; }
+    
+    public TransactionInitiator getInitiator() { return initiator; }
+    public void setInitiator(TransactionInitiator initiator) { this.initiator = initiator; }
+    
+    public StoredCredentialType getStoredCredential() { return storedCredential; }
+    public void setStoredCredential(StoredCredentialType storedCredential) { this.storedCredential = storedCredential; }
+    
+    public String getNetworkTransactionId() { return networkTransactionId; }
+    public void setNetworkTransactionId(String networkTransactionId) { this.networkTransactionId = networkTransactionId; }
+    
+    public String getOriginalNetworkTransactionId() { return originalNetworkTransactionId; }
+    public void setOriginalNetworkTransactionId(String originalNetworkTransactionId) { this.originalNetworkTransactionId = originalNetworkTransactionId; }
+    
     public String getBillingStreet() { return billingStreet; }
     public void setBillingStreet(String billingStreet) { this.billingStreet = billingStreet; }
     
+    public String getBillingStreet2() { return billingStreet2; }
+    public void setBillingStreet2(String billingStreet2) { this.billingStreet2 = billingStreet2; }
+    
     public String getBillingCity() { return billingCity; }
     public void setBillingCity(String billingCity) { this.billingCity = billingCity; }
     
@@ -230,6 +367,68 @@ public class Payment {
     public String getBillingCountry() { return billingCountry; }
     public void setBillingCountry(String billingCountry) { this.billingCountry = billingCountry; }
     
+    public String getStatementDescriptor() { return statementDescriptor; }
+    public void setStatementDescriptor(String statementDescriptor) { this.statementDescriptor = statementDescriptor; }
+    
+    public String getAvsResult() { return avsResult; }
+    public void setAvsResult(String avsResult) { this.avsResult = avsResult; }
+    
+    public String getCvvResult() { return cvvResult; }
+    public void setCvvResult(String cvvResult) { this.cvvResult = cvvResult; }
+    
+    public BigDecimal getSurchargeAmount() { return surchargeAmount; }
+    public void setSurchargeAmount(BigDecimal surchargeAmount) { this.surchargeAmount = surchargeAmount; }
+    
+    public BigDecimal getConvenienceFee() { return convenienceFee; }
+    public void setConvenienceFee(BigDecimal convenienceFee) { this.convenienceFee = convenienceFee; }
+    
+    public Integer getInstallmentCount() { return installmentCount; }
+    public void setInstallmentCount(Integer installmentCount) { this.installmentCount = installmentCount; }
+    
+    public InstallmentPlanType getInstallmentPlanType() { return installmentPlanType; }
+    public void setInstallmentPlanType(InstallmentPlanType installmentPlanType) { this.installmentPlanType = installmentPlanType; }
+    
+    public String getIssuerCountry() { return issuerCountry; }
+    public void setIssuerCountry(String issuerCountry) { this.issuerCountry = issuerCountry; }
+    
+    public boolean isCrossBorder() { return crossBorder; }
+    public void setCrossBorder(boolean crossBorder) { this.crossBorder = crossBorder; }
+    
+    public BigDecimal getTipAmount() { return tipAmount; }
+    public void setTipAmount(BigDecimal tipAmount) { this.tipAmount = tipAmount; }
+    
+    /**
+     * The amount without any surcharge, convenience fee or tip
+     */
+    public BigDecimal baseAmount() {
+        BigDecimal base = amount;
+        if (surchargeAmount != null) {
+            base = base.subtract(surchargeAmount);
+        }
+        if (convenienceFee != null) {
+            base = base.subtract(convenienceFee);
+        }
+        if (tipAmount != null) {
+            base = base.subtract(tipAmount);
+        }
+        return base;
+    }
+    
+    public BigDecimal getTaxAmount() { return taxAmount; }
+    public void setTaxAmount(BigDecimal taxAmount) { this.taxAmount = taxAmount; }
+    
+    public String getCustomerCode() { return customerCode; }
+    public void setCustomerCode(String customerCode) { this.customerCode = customerCode; }
+    
+    public Integer getPurchaseDataLevel() { return purchaseDataLevel; }
+    public void setPurchaseDataLevel(Integer purchaseDataLevel) { this.purchaseDataLevel = purchaseDataLevel; }
+    
+    public List<PaymentLineItem> getLineItems() { return lineItems; }
+    public void setLineItems(List<PaymentLineItem> lineItems) { this.lineItems = lineItems; }
+    
+    public List<PaymentSplit> getSplits() { return splits; }
+    public void setSplits(List<PaymentSplit> splits) { this.splits = splits; }
+    
     public Integer getProcessingTimeMs() { return processingTimeMs; }
     public void setProcessingTimeMs(Integer processingTimeMs) { this.processingTimeMs = processingTimeMs; }
     
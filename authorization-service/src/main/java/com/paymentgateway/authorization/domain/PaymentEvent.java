@@ -1,5 +1,7 @@
 package com.paymentgateway.authorization.domain;
 
+import io.opentelemetry.api.trace.Span;
+import io.opentelemetry.api.trace.SpanContext;
 import jakarta.persistence.*;
 import java.math.BigDecimal;
 import java.time.Instant;
@@ -55,6 +57,9 @@ public class PaymentEvent {
     @Column(name = "correlation_id", nullable = false)
     private UUID correlationId = UUID.randomUUID();
     
+    @Column(name = "trace_id", length = 32)
+    private String traceId;
+    
     @Column(name = "created_at", nullable = false)
     private Instant createdAt = Instant.now();
     
@@ -113,6 +118,18 @@ public class PaymentEvent {
     public UUID getCorrelationId() { return correlationId; }
     public void setCorrelationId(UUID correlationId) { this.correlationId = correlationId; }
     
+    public String getTraceId() { return traceId; }
+    public void setTraceId(String traceId) { this.traceId = traceId; }
+    
     public Instant getCreatedAt() { return createdAt; }
     public void setCreatedAt(Instant createdAt) { this.createdAt = createdAt; }
+    
+    @PrePersist
+    public void prePersist() {
+        // Link the event to the distributed trace it was recorded in
+        SpanContext span = Span.current().getSpanContext();
+        if (traceId == null && span.isValid()) {
+            traceId = span.getTraceId();
+        }
+    }
 }
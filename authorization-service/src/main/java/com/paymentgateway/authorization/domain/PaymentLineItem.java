@@ -0,0 +1,61 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+
+/**
+ * A Level 3 line item of a payment, cleared with it in settlement
+ */
+@Embeddable
+public class PaymentLineItem {
+    
+    @Column(name = "product_code", length = 12)
+    private String productCode;
+    
+    @Column(name = "description", nullable = false, length = 35)
+    private String description;
+    
+    @Column(name = "commodity_code", length = 12)
+    private String commodityCode;
+    
+    @Column(name = "quantity", nullable = false, precision = 12, scale = 4)
+    private BigDecimal quantity;
+    
+    @Column(name = "unit_of_measure", length = 12)
+    private String unitOfMeasure;
+    
+    @Column(name = "unit_price", nullable = false, precision = 12, scale = 4)
+    private BigDecimal unitPrice;
+    
+    @Column(name = "discount_amount", precision = 12, scale = 2)
+    private BigDecimal discountAmount;
+    
+    @Column(name = "line_total", nullable = false, precision = 12, scale = 2)
+    private BigDecimal lineTotal;
+    
+    public PaymentLineItem() {}
+    
+    public String getProductCode() { return productCode; }
+    public void setProductCode(String productCode) { this.productCode = productCode; }
+    
+    public String getDescription() { return description; }
+    public void setDescription(String description) { this.description = description; }
+    
+    public String getCommodityCode() { return commodityCode; }
+    public void setCommodityCode(String commodityCode) { this.commodityCode = commodityCode; }
+    
+    public BigDecimal getQuantity() { return quantity; }
+    public void setQuantity(BigDecimal quantity) { this.quantity = quantity; }
+    
+    public String getUnitOfMeasure() { return unitOfMeasure; }
+    public void setUnitOfMeasure(String unitOfMeasure) { this.unitOfMeasure = unitOfMeasure; }
+    
+    public BigDecimal getUnitPrice() { return unitPrice; }
+    public void setUnitPrice(BigDecimal unitPrice) { this.unitPrice = unitPrice; }
+    
+    public BigDecimal getDiscountAmount() { return discountAmount; }
+    public void setDiscountAmount(BigDecimal discountAmount) { this.discountAmount = discountAmount; }
+    
+    public BigDecimal getLineTotal() { return lineTotal; }
+    public void setLineTotal(BigDecimal lineTotal) { this.lineTotal = lineTotal; }
+}
@@ -0,0 +1,100 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.util.UUID;
+
+/**
+ * A dynamic EMVCo merchant-presented QR code for one payment, paid when a
+ * wallet scans it
+ */
+@Entity
+@Table(name = "qr_payments")
+public class QrPayment {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "qr_payment_id", unique = true, nullable = false, length = 30)
+    private String qrPaymentId;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    @Column(nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(nullable = false, length = 3)
+    private String currency;
+    
+    // The bill number in the code, and the payments' reference
+    @Column(length = 25)
+    private String reference;
+    
+    @Column(nullable = false, length = 512)
+    private String payload;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 20)
+    private QrPaymentStatus status = QrPaymentStatus.OPEN;
+    
+    // Payment attempts made by scanning, declined ones included
+    @Column(nullable = false)
+    private int attempts;
+    
+    // The approved payment once paid, else the last declined one
+    @Column(name = "payment_id", length = 50)
+    private String paymentId;
+    
+    @Column(name = "expires_at", nullable = false)
+    private Instant expiresAt;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    @Column(name = "paid_at")
+    private Instant paidAt;
+    
+    public QrPayment() {}
+    
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public String getQrPaymentId() { return qrPaymentId; }
+    public void setQrPaymentId(String qrPaymentId) { this.qrPaymentId = qrPaymentId; }
+    
+    public UUID getMerchantId() { return merchantId; }
+    public void setMerchantId(UUID merchantId) { this.merchantId = merchantId; }
+    
+    public BigDecimal getAmount() { return amount; }
+    public void setAmount(BigDecimal amount) { this.amount = amount; }
+    
+    public String getCurrency() { return currency; }
+    public void setCurrency(String currency) { this.currency = currency; }
+    
+    public String getReference() { return reference; }
+    public void setReference(String reference) { this.reference = reference; }
+    
+    public String getPayload() { return payload; }
+    public void setPayload(String payload) { this.payload = payload; }
+    
+    public QrPaymentStatus getStatus() { return status; }
+    public void setStatus(QrPaymentStatus status) { this.status = status; }
+    
+    public int getAttempts() { return attempts; }
+    public void setAttempts(int attempts) { this.attempts = attempts; }
+    
+    public String getPaymentId() { return paymentId; }
+    public void setPaymentId(String paymentId) { this.paymentId = paymentId; }
+    
+    public Instant getExpiresAt() { return expiresAt; }
+    public void setExpiresAt(Instant expiresAt) { this.expiresAt = expiresAt; }
+    
+    public Instant getCreatedAt() { return createdAt; }
+    public void setCreatedAt(Instant createdAt) { this.createdAt = createdAt; }
+    
+    public Instant getPaidAt() { return paidAt; }
+    public void setPaidAt(Instant paidAt) { this.paidAt = paidAt; }
+}
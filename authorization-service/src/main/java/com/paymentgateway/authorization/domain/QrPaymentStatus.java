@@ -0,0 +1,8 @@
+package com.paymentgateway.authorization.domain;
+
+public enum QrPaymentStatus {
+    // Waiting to be scanned and paid; a declined attempt leaves it open
+    OPEN,
+    PAID,
+    EXPIRED
+}
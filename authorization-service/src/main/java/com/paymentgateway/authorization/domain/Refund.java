@@ -56,6 +56,10 @@ public class Refund {
     @Column(name = "processed_at")
     private Instant processedAt;
     
+    // Refund policy rules an administrator overrode, comma-separated
+    @Column(name = "policy_override", length = 100)
+    private String policyOverride;
+    
     // Constructors
     public Refund() {}
     
@@ -105,6 +109,9 @@ public class Refund {
     public Instant getProcessedAt() { return processedAt; }
     public void setProcessedAt(Instant processedAt) { this.processedAt = processedAt; }
     
+    public String getPolicyOverride() { return policyOverride; }
+    public void setPolicyOverride(String policyOverride) { this.policyOverride = policyOverride; }
+    
     @PreUpdate
     public void preUpdate() {
         this.updatedAt = Instant.now();
@@ -0,0 +1,56 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.time.Instant;
+import java.util.UUID;
+
+/**
+ * An isolated slice of a shared simulator. A sandbox's merchants, their API
+ * keys, payments and everything recorded about them belong to it alone and
+ * are destroyed with it.
+ */
+@Entity
+@Table(name = "sandboxes")
+public class Sandbox {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "sandbox_id", unique = true, nullable = false, length = 30)
+    private String sandboxId;
+    
+    @Column(name = "name", nullable = false, length = 100)
+    private String name;
+    
+    @Column(name = "created_by", length = 50)
+    private String createdBy;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    // Constructors
+    public Sandbox() {}
+    
+    public Sandbox(String sandboxId, String name, String createdBy) {
+        this.sandboxId = sandboxId;
+        this.name = name;
+        this.createdBy = createdBy;
+    }
+    
+    // Getters and Setters
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public String getSandboxId() { return sandboxId; }
+    public void setSandboxId(String sandboxId) { this.sandboxId = sandboxId; }
+    
+    public String getName() { return name; }
+    public void setName(String name) { this.name = name; }
+    
+    public String getCreatedBy() { return createdBy; }
+    public void setCreatedBy(String createdBy) { this.createdBy = createdBy; }
+    
+    public Instant getCreatedAt() { return createdAt; }
+    public void setCreatedAt(Instant createdAt) { this.createdAt = createdAt; }
+}
@@ -0,0 +1,6 @@
+package com.paymentgateway.authorization.domain;
+
+public enum ScaExemption {
+    LOW_VALUE,
+    TRANSACTION_RISK_ANALYSIS
+}
@@ -0,0 +1,105 @@
+package com.paymentgateway.authorization.domain;
+
+import jakarta.persistence.*;
+import java.time.Instant;
+import java.util.UUID;
+
+/**
+ * A match of a merchant or cardholder against a screening list, held for
+ * an administrator to release or confirm
+ */
+@Entity
+@Table(name = "screening_hits")
+public class ScreeningHit {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "hit_id", unique = true, nullable = false, length = 30)
+    private String hitId;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "subject_type", nullable = false, length = 20)
+    private ScreeningSubjectType subjectType;
+    
+    // The merchant screened, or whose payment was
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    // The held payment of a cardholder hit
+    @Column(name = "payment_id", length = 50)
+    private String paymentId;
+    
+    @Column(name = "screened_name")
+    private String screenedName;
+    
+    @Column(name = "screened_country", length = 2)
+    private String screenedCountry;
+    
+    @Column(nullable = false, length = 50)
+    private String provider;
+    
+    @Column(name = "matched_entry", nullable = false)
+    private String matchedEntry;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(nullable = false, length = 20)
+    private ScreeningHitStatus status = ScreeningHitStatus.HELD;
+    
+    @Column(name = "decided_by", length = 50)
+    private String decidedBy;
+    
+    @Column(name = "decision_note")
+    private String decisionNote;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    @Column(name = "decided_at")
+    private Instant decidedAt;
+    
+    public ScreeningHit() {}
+    
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public String getHitId() { return hitId; }
+    public void setHitId(String hitId) { this.hitId = hitId; }
+    
+    public ScreeningSubjectType getSubjectType() { return subjectType; }
+    public void setSubjectType(ScreeningSubjectType subjectType) { this.subjectType = subjectType; }
+    
+    public UUID getMerchantId() { return merchantId; }
+    public void setMerchantId(UUID merchantId) { this.merchantId = merchantId; }
+    
+    public String getPaymentId() { return paymentId; }
+    public void setPaymentId(String paymentId) { this.paymentId = paymentId; }
+    
+    public String getScreenedName() { return screenedName; }
+    public void setScreenedName(String screenedName) { this.screenedName = screenedName; }
+    
+    public String getScreenedCountry() { return screenedCountry; }
+    public void setScreenedCountry(String screenedCountry) { this.screenedCountry = screenedCountry; }
+    
+    public String getProvider() { return provider; }
+    public void setProvider(String provider) { this.provider = provider; }
+    
+    public String getMatchedEntry() { return matchedEntry; }
+    public void setMatchedEntry(String matchedEntry) { this.matchedEntry = matchedEntry; }
+    
+    public ScreeningHitStatus getStatus() { return status; }
+    public void setStatus(ScreeningHitStatus status) { this.status = status; }
+    
+    public String getDecidedBy() { return decidedBy; }
+    public void setDecidedBy(String decidedBy) { this.decidedBy = decidedBy; }
+    
+    public String getDecisionNote() { return decisionNote; }
+    public void setDecisionNote(String decisionNote) { this.decisionNote = decisionNote; }
+    
+    public Instant getCreatedAt() { return createdAt; }
+    public void setCreatedAt(Instant createdAt) { this.createdAt = createdAt; }
+    
+    public Instant getDecidedAt() { return decidedAt; }
+    public void setDecidedAt(Instant decidedAt) { this.decidedAt = decidedAt; }
+}
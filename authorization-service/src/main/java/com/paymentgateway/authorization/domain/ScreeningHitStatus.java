@@ -0,0 +1,11 @@
+package com.paymentgateway.authorization.domain;
+
+public enum ScreeningHitStatus {
+    // Awaiting an administrator; holds the payment's capture or the
+    // merchant's approval
+    HELD,
+    // A false positive; the hold is lifted
+    RELEASED,
+    // A true match; a held payment is voided
+    CONFIRMED
+}
@@ -0,0 +1,8 @@
+package com.paymentgateway.authorization.domain;
+
+public enum ScreeningSubjectType {
+    // A merchant's business and representative, at onboarding
+    MERCHANT,
+    // The cardholder of a payment
+    CARDHOLDER
+}
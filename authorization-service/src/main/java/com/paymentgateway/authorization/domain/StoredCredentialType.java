@@ -0,0 +1,12 @@
+package com.paymentgateway.authorization.domain;
+
+/**
+ * Why a payment uses card details the merchant stored earlier. Recurring and
+ * installment payments follow an agreed schedule; unscheduled ones (e.g.
+ * account top-ups) do not.
+ */
+public enum StoredCredentialType {
+    RECURRING,
+    INSTALLMENT,
+    UNSCHEDULED
+}
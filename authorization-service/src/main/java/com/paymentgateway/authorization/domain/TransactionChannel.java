@@ -0,0 +1,6 @@
+package com.paymentgateway.authorization.domain;
+
+public enum TransactionChannel {
+    ECOMMERCE,
+    MOTO
+}
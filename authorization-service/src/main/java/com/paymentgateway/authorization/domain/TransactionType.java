@@ -4,5 +4,6 @@ public enum TransactionType {
     AUTHORIZATION,
     CAPTURE,
     REFUND,
-    VOID
+    VOID,
+    CREDIT
 }
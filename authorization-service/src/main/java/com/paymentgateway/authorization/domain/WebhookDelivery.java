@@ -15,9 +15,14 @@ public class WebhookDelivery {
     @Column(name = "merchant_id", nullable = false)
     private UUID merchantId;
     
-    @Column(name = "payment_id", nullable = false)
+    // Null for merchant events such as onboarding
+    @Column(name = "payment_id")
     private UUID paymentId;
     
+    // Null for deliveries to the merchant's legacy webhook URL
+    @Column(name = "endpoint_id")
+    private UUID endpointId;
+    
     @Column(name = "event_type", nullable = false, length = 50)
     private String eventType;
     
@@ -27,6 +32,7 @@ public class WebhookDelivery {
     @Column(name = "payload", nullable = false, columnDefinition = "TEXT")
     private String payload;
     
+    // Signature header of the last attempt; attempts are signed when sent
     @Column(name = "signature", nullable = false)
     private String signature;
     
@@ -37,7 +43,7 @@ public class WebhookDelivery {
     private Integer maxAttempts = 10;
     
     @Column(name = "status", nullable = false, length = 20)
-    private String status = "PENDING"; // PENDING, DELIVERED, FAILED
+    private String status = "PENDING"; // PENDING, DELIVERED, FAILED (dead-lettered), CANCELLED
     
     @Column(name = "http_status_code")
     private Integer httpStatusCode;
@@ -57,6 +63,12 @@ public class WebhookDelivery {
     @Column(name = "delivered_at")
     private Instant deliveredAt;
     
+    @Column(name = "dead_lettered_at")
+    private Instant deadLetteredAt;
+    
+    @Column(name = "redelivery_count", nullable = false)
+    private Integer redeliveryCount = 0;
+    
     // Constructors
     public WebhookDelivery() {}
     
@@ -80,6 +92,9 @@ public class WebhookDelivery {
     public UUID getPaymentId() { return paymentId; }
     public void setPaymentId(UUID paymentId) { this.paymentId = paymentId; }
     
+    public UUID getEndpointId() { return endpointId; }
+    public void setEndpointId(UUID endpointId) { this.endpointId = endpointId; }
+    
     public String getEventType() { return eventType; }
     public void setEventType(String eventType) { this.eventType = eventType; }
     
@@ -119,6 +134,12 @@ public class WebhookDelivery {
     public Instant getDeliveredAt() { return deliveredAt; }
     public void setDeliveredAt(Instant deliveredAt) { this.deliveredAt = deliveredAt; }
     
+    public Instant getDeadLetteredAt() { return deadLetteredAt; }
+    public void setDeadLetteredAt(Instant deadLetteredAt) { this.deadLetteredAt = deadLetteredAt; }
+    
+    public Integer getRedeliveryCount() { return redeliveryCount; }
+    public void setRedeliveryCount(Integer redeliveryCount) { this.redeliveryCount = redeliveryCount; }
+    
     public void incrementAttemptCount() {
         this.attemptCount++;
     }
@@ -0,0 +1,113 @@
+package com.paymentgateway.authorization.domain;
+
+import com.fasterxml.jackson.annotation.JsonIgnore;
+import jakarta.persistence.*;
+import java.time.Instant;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * A URL a merchant receives webhooks at. It gets events only once the
+ * merchant has proven it controls the URL by echoing a challenge, and signs
+ * them with a secret of its own.
+ */
+@Entity
+@Table(name = "webhook_endpoints")
+public class WebhookEndpoint {
+    
+    public static final String PENDING_VERIFICATION = "PENDING_VERIFICATION";
+    public static final String ACTIVE = "ACTIVE";
+    public static final String DISABLED = "DISABLED";
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    @Column(name = "url", nullable = false, columnDefinition = "TEXT")
+    private String url;
+    
+    @Column(name = "status", nullable = false, length = 30)
+    private String status = PENDING_VERIFICATION;
+    
+    @JsonIgnore
+    @Column(name = "signing_secret", nullable = false)
+    private String signingSecret;
+    
+    // The secret before the last rotation, still signing until it expires
+    @JsonIgnore
+    @Column(name = "previous_signing_secret")
+    private String previousSigningSecret;
+    
+    @Column(name = "previous_secret_expires_at")
+    private Instant previousSecretExpiresAt;
+    
+    @Column(name = "secret_rotated_at")
+    private Instant secretRotatedAt;
+    
+    @Column(name = "verified_at")
+    private Instant verifiedAt;
+    
+    @Column(name = "created_at", nullable = false)
+    private Instant createdAt = Instant.now();
+    
+    // Constructors
+    public WebhookEndpoint() {}
+    
+    public WebhookEndpoint(UUID merchantId, String url, String signingSecret) {
+        this.merchantId = merchantId;
+        this.url = url;
+        this.signingSecret = signingSecret;
+    }
+    
+    // Getters and Setters
+    public UUID getId() { return id; }
+    public void setId(UUID id) { this.id = id; }
+    
+    public UUID getMerchantId() { return merchantId; }
+    public void setMerchantId(UUID merchantId) { this.merchantId = merchantId; }
+    
+    public String getUrl() { return url; }
+    public void setUrl(String url) { this.url = url; }
+    
+    public String getStatus() { return status; }
+    public void setStatus(String status) { this.status = status; }
+    
+    public String getSigningSecret() { return signingSecret; }
+    public void setSigningSecret(String signingSecret) { this.signingSecret = signingSecret; }
+    
+    public String getPreviousSigningSecret() { return previousSigningSecret; }
+    public void setPreviousSigningSecret(String previousSigningSecret) { this.previousSigningSecret = previousSigningSecret; }
+    
+    public Instant getPreviousSecretExpiresAt() { return previousSecretExpiresAt; }
+    public void setPreviousSecretExpiresAt(Instant previousSecretExpiresAt) { this.previousSecretExpiresAt = previousSecretExpiresAt; }
+    
+    public Instant getSecretRotatedAt() { return secretRotatedAt; }
+    public void setSecretRotatedAt(Instant secretRotatedAt) { this.secretRotatedAt = secretRotatedAt; }
+    
+    public Instant getVerifiedAt() { return verifiedAt; }
+    public void setVerifiedAt(Instant verifiedAt) { this.verifiedAt = verifiedAt; }
+    
+    public Instant getCreatedAt() { return createdAt; }
+    public void setCreatedAt(Instant createdAt) { this.createdAt = createdAt; }
+    
+    public boolean isActive() {
+        return ACTIVE.equals(status);
+    }
+    
+    /**
+     * Secrets to sign with at the given time: the current one, then the
+     * previous one while its rotation overlap lasts
+     */
+    public List<String> signingSecrets(Instant now) {
+        List<String> secrets = new ArrayList<>();
+        secrets.add(signingSecret);
+        if (previousSigningSecret != null && previousSecretExpiresAt != null && now.isBefore(previousSecretExpiresAt)) {
+            secrets.add(previousSigningSecret);
+        }
+        return secrets;
+    }
+}
@@ -0,0 +1,41 @@
+package com.paymentgateway.authorization.dto;
+
+import java.math.BigDecimal;
+
+/**
+ * What makes up a payment's amount: the goods, and any surcharge or
+ * convenience fee on top, plus any tip added at capture
+ */
+public class AmountBreakdown {
+    
+    private BigDecimal baseAmount;
+    private BigDecimal surchargeAmount;
+    private BigDecimal convenienceFee;
+    private BigDecimal tipAmount;
+    private BigDecimal totalAmount;
+    
+    public AmountBreakdown() {}
+    
+    public AmountBreakdown(BigDecimal baseAmount, BigDecimal surchargeAmount, BigDecimal convenienceFee,
+                           BigDecimal totalAmount) {
+        this.baseAmount = baseAmount;
+        this.surchargeAmount = surchargeAmount;
+        this.convenienceFee = convenienceFee;
+        this.totalAmount = totalAmount;
+    }
+    
+    public BigDecimal getBaseAmount() { return baseAmount; }
+    public void setBaseAmount(BigDecimal baseAmount) { this.baseAmount = baseAmount; }
+    
+    public BigDecimal getSurchargeAmount() { return surchargeAmount; }
+    public void setSurchargeAmount(BigDecimal surchargeAmount) { this.surchargeAmount = surchargeAmount; }
+    
+    public BigDecimal getConvenienceFee() { return convenienceFee; }
+    public void setConvenienceFee(BigDecimal convenienceFee) { this.convenienceFee = convenienceFee; }
+    
+    public BigDecimal getTipAmount() { return tipAmount; }
+    public void setTipAmount(BigDecimal tipAmount) { this.tipAmount = tipAmount; }
+    
+    public BigDecimal getTotalAmount() { return totalAmount; }
+    public void setTotalAmount(BigDecimal totalAmount) { this.totalAmount = totalAmount; }
+}
@@ -0,0 +1,56 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.*;
+
+/**
+ * Structured billing address of a card-not-present payment. The street and
+ * postal code are what the issuer checks for AVS.
+ */
+public class BillingAddress {
+    
+    @Size(max = 255, message = "Address line 1 is too long")
+    private String line1;
+    
+    @Size(max = 255, message = "Address line 2 is too long")
+    private String line2;
+    
+    @Size(max = 100, message = "City is too long")
+    private String city;
+    
+    @Size(max = 100, message = "State is too long")
+    private String state;
+    
+    @Pattern(regexp = "^[A-Za-z0-9][A-Za-z0-9 -]{1,18}$", message = "Invalid postal code")
+    private String postalCode;
+    
+    @Pattern(regexp = "^[A-Z]{2}$", message = "Invalid country code")
+    private String country;
+    
+    public BillingAddress() {}
+    
+    public BillingAddress(String line1, String city, String state, String postalCode, String country) {
+        this.line1 = line1;
+        this.city = city;
+        this.state = state;
+        this.postalCode = postalCode;
+        this.country = country;
+    }
+    
+    public String getLine1() { return line1; }
+    public void setLine1(String line1) { this.line1 = line1; }
+    
+    public String getLine2() { return line2; }
+    public void setLine2(String line2) { this.line2 = line2; }
+    
+    public String getCity() { return city; }
+    public void setCity(String city) { this.city = city; }
+    
+    public String getState() { return state; }
+    public void setState(String state) { this.state = state; }
+    
+    public String getPostalCode() { return postalCode; }
+    public void setPostalCode(String postalCode) { this.postalCode = postalCode; }
+    
+    public String getCountry() { return country; }
+    public void setCountry(String country) { this.country = country; }
+}
@@ -0,0 +1,23 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.validation.ValidAmount;
+import java.math.BigDecimal;
+
+/**
+ * Optional body of a capture. Tipping merchants add the tip here, and the
+ * payment is captured for the authorized amount plus the tip.
+ */
+public class CaptureRequest {
+    
+    @ValidAmount(min = "0")
+    private BigDecimal tipAmount;
+    
+    public CaptureRequest() {}
+    
+    public CaptureRequest(BigDecimal tipAmount) {
+        this.tipAmount = tipAmount;
+    }
+    
+    public BigDecimal getTipAmount() { return tipAmount; }
+    public void setTipAmount(BigDecimal tipAmount) { this.tipAmount = tipAmount; }
+}
@@ -0,0 +1,23 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.*;
+
+/**
+ * The BIN or the vault token of the card to enrich; exactly one is required
+ */
+public class CardEnrichmentRequest {
+    
+    @Pattern(regexp = "^[0-9]{6,8}$", message = "BIN must be 6 to 8 digits")
+    private String bin;
+    
+    @Pattern(regexp = "^9[0-9]{12,18}$", message = "Invalid vault token format")
+    private String token;
+    
+    public CardEnrichmentRequest() {}
+    
+    public String getBin() { return bin; }
+    public void setBin(String bin) { this.bin = bin; }
+    
+    public String getToken() { return token; }
+    public void setToken(String token) { this.token = token; }
+}
@@ -0,0 +1,22 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.NotBlank;
+import jakarta.validation.constraints.Pattern;
+
+/**
+ * The test card whose simulated issuer statement to show; the PAN goes in
+ * the body so it stays out of URLs and access logs
+ */
+public class CardStatementRequest {
+    
+    @NotBlank(message = "Card number is required")
+    @Pattern(regexp = "^[0-9]{12,19}$", message = "Invalid card number format")
+    private String cardNumber;
+    
+    // Constructors
+    public CardStatementRequest() {}
+    
+    // Getters and Setters
+    public String getCardNumber() { return cardNumber; }
+    public void setCardNumber(String cardNumber) { this.cardNumber = cardNumber; }
+}
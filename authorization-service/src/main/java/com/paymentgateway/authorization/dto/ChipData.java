@@ -0,0 +1,54 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.Max;
+import jakarta.validation.constraints.Min;
+import jakarta.validation.constraints.NotNull;
+import jakarta.validation.constraints.Pattern;
+
+/**
+ * EMV data a chip card produced for the transaction: the ARQC, and what
+ * the card computed it over
+ */
+public class ChipData {
+    
+    @NotNull(message = "ARQC is required")
+    @Pattern(regexp = "^[0-9A-Fa-f]{16}$", message = "Invalid ARQC")
+    private String arqc;
+    
+    // Application transaction counter
+    @NotNull(message = "ATC is required")
+    @Min(value = 0, message = "ATC must be between 0 and 65535")
+    @Max(value = 65535, message = "ATC must be between 0 and 65535")
+    private Integer atc;
+    
+    // Absent for cards without one, taken as 0
+    @Min(value = 0, message = "PAN sequence number must be between 0 and 99")
+    @Max(value = 99, message = "PAN sequence number must be between 0 and 99")
+    private Integer panSequenceNumber;
+    
+    // CDOL1 data in card order, as hex
+    @NotNull(message = "Transaction data is required")
+    @Pattern(regexp = "^([0-9A-Fa-f]{2}){1,252}$", message = "Invalid transaction data")
+    private String transactionData;
+    
+    public ChipData() {}
+    
+    public ChipData(String arqc, Integer atc, Integer panSequenceNumber, String transactionData) {
+        this.arqc = arqc;
+        this.atc = atc;
+        this.panSequenceNumber = panSequenceNumber;
+        this.transactionData = transactionData;
+    }
+    
+    public String getArqc() { return arqc; }
+    public void setArqc(String arqc) { this.arqc = arqc; }
+    
+    public Integer getAtc() { return atc; }
+    public void setAtc(Integer atc) { this.atc = atc; }
+    
+    public Integer getPanSequenceNumber() { return panSequenceNumber; }
+    public void setPanSequenceNumber(Integer panSequenceNumber) { this.panSequenceNumber = panSequenceNumber; }
+    
+    public String getTransactionData() { return transactionData; }
+    public void setTransactionData(String transactionData) { this.transactionData = transactionData; }
+}
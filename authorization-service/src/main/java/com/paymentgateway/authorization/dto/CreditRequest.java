@@ -0,0 +1,63 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.validation.*;
+import jakarta.validation.constraints.*;
+import java.math.BigDecimal;
+
+/**
+ * A standalone credit: a refund to a card with no original payment at this
+ * gateway
+ */
+@ValidExpiryDate
+public class CreditRequest {
+    
+    @NotBlank(message = "Card number is required")
+    @Pattern(regexp = "^[0-9]{13,19}$", message = "Invalid card number format")
+    @LuhnCheck
+    private String cardNumber;
+    
+    @NotNull(message = "Expiry month is required")
+    @Min(value = 1, message = "Expiry month must be between 1 and 12")
+    @Max(value = 12, message = "Expiry month must be between 1 and 12")
+    private Integer expiryMonth;
+    
+    @NotNull(message = "Expiry year is required")
+    @Min(value = 2025, message = "Card has expired")
+    private Integer expiryYear;
+    
+    @NotNull(message = "Amount is required")
+    @ValidAmount
+    private BigDecimal amount;
+    
+    @NotBlank(message = "Currency is required")
+    @ValidCurrency
+    private String currency;
+    
+    private String reason;
+    private String referenceId;
+    
+    // Constructors
+    public CreditRequest() {}
+    
+    // Getters and Setters
+    public String getCardNumber() { return cardNumber; }
+    public void setCardNumber(String cardNumber) { this.cardNumber = cardNumber; }
+    
+    public Integer getExpiryMonth() { return expiryMonth; }
+    public void setExpiryMonth(Integer expiryMonth) { this.expiryMonth = expiryMonth; }
+    
+    public Integer getExpiryYear() { return expiryYear; }
+    public void setExpiryYear(Integer expiryYear) { this.expiryYear = expiryYear; }
+    
+    public BigDecimal getAmount() { return amount; }
+    public void setAmount(BigDecimal amount) { this.amount = amount; }
+    
+    public String getCurrency() { return currency; }
+    public void setCurrency(String currency) { this.currency = currency; }
+    
+    public String getReason() { return reason; }
+    public void setReason(String reason) { this.reason = reason; }
+    
+    public String getReferenceId() { return referenceId; }
+    public void setReferenceId(String referenceId) { this.referenceId = referenceId; }
+}
@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.NotBlank;
+
+/**
+ * A merchant's static statement descriptor
+ */
+public class DescriptorRequest {
+    
+    @NotBlank(message = "Descriptor is required")
+    private String descriptor;
+    
+    // Constructors
+    public DescriptorRequest() {}
+    
+    // Getters and Setters
+    public String getDescriptor() { return descriptor; }
+    public void setDescriptor(String descriptor) { this.descriptor = descriptor; }
+}
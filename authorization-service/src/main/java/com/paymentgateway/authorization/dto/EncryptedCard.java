@@ -0,0 +1,35 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.NotBlank;
+import jakarta.validation.constraints.Pattern;
+
+/**
+ * A card read encrypted by a P2PE terminal under DUKPT: the key serial
+ * number of the read and its encrypted track, both as hex. The gateway
+ * cannot decrypt it; only the tokenization service, the decryption zone,
+ * sees the PAN.
+ */
+public class EncryptedCard {
+    
+    @NotBlank(message = "KSN is required")
+    @Pattern(regexp = "^[0-9A-Fa-f]{20}$", message = "KSN must be 20 hex digits")
+    private String ksn;
+    
+    // Track 1 or 2, TDES-CBC in 8-byte blocks of at most 80 bytes
+    @NotBlank(message = "Encrypted track is required")
+    @Pattern(regexp = "^([0-9A-Fa-f]{16}){1,10}$", message = "Encrypted track must be 1-10 blocks of 16 hex digits")
+    private String encryptedTrack;
+    
+    public EncryptedCard() {}
+    
+    public EncryptedCard(String ksn, String encryptedTrack) {
+        this.ksn = ksn;
+        this.encryptedTrack = encryptedTrack;
+    }
+    
+    public String getKsn() { return ksn; }
+    public void setKsn(String ksn) { this.ksn = ksn; }
+    
+    public String getEncryptedTrack() { return encryptedTrack; }
+    public void setEncryptedTrack(String encryptedTrack) { this.encryptedTrack = encryptedTrack; }
+}
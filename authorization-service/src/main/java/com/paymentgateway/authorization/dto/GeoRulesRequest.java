@@ -0,0 +1,35 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.domain.CrossBorderPolicy;
+import jakarta.validation.constraints.NotNull;
+import jakarta.validation.constraints.Pattern;
+import jakarta.validation.constraints.Size;
+
+import java.util.ArrayList;
+import java.util.List;
+
+/**
+ * A merchant's geo rules: the issuing countries whose cards are declined
+ * and what is done with cards issued outside its country
+ */
+public class GeoRulesRequest {
+    
+    @Size(max = 50, message = "At most 50 countries can be blocked")
+    private List<@Pattern(regexp = "^[A-Z]{2}$", message = "Invalid country code") String> blockedIssuerCountries =
+        new ArrayList<>();
+    
+    @NotNull(message = "Cross-border policy is required")
+    private CrossBorderPolicy crossBorderPolicy;
+    
+    // Constructors
+    public GeoRulesRequest() {}
+    
+    // Getters and Setters
+    public List<String> getBlockedIssuerCountries() { return blockedIssuerCountries; }
+    public void setBlockedIssuerCountries(List<String> blockedIssuerCountries) {
+        this.blockedIssuerCountries = blockedIssuerCountries;
+    }
+    
+    public CrossBorderPolicy getCrossBorderPolicy() { return crossBorderPolicy; }
+    public void setCrossBorderPolicy(CrossBorderPolicy crossBorderPolicy) { this.crossBorderPolicy = crossBorderPolicy; }
+}
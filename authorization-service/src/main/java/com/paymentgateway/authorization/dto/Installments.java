@@ -0,0 +1,34 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.domain.InstallmentPlanType;
+import jakarta.validation.constraints.Max;
+import jakarta.validation.constraints.Min;
+import jakarta.validation.constraints.NotNull;
+
+/**
+ * Installment plan the cardholder chose: the number of installments and
+ * who finances them
+ */
+public class Installments {
+    
+    @NotNull(message = "Installment count is required")
+    @Min(value = 2, message = "Installment count must be between 2 and 99")
+    @Max(value = 99, message = "Installment count must be between 2 and 99")
+    private Integer count;
+    
+    @NotNull(message = "Installment plan type is required")
+    private InstallmentPlanType planType;
+    
+    public Installments() {}
+    
+    public Installments(Integer count, InstallmentPlanType planType) {
+        this.count = count;
+        this.planType = planType;
+    }
+    
+    public Integer getCount() { return count; }
+    public void setCount(Integer count) { this.count = count; }
+    
+    public InstallmentPlanType getPlanType() { return planType; }
+    public void setPlanType(InstallmentPlanType planType) { this.planType = planType; }
+}
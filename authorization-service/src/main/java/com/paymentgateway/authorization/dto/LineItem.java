@@ -0,0 +1,76 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.*;
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+
+/**
+ * A Level 3 line item: what was bought, how many and at what price
+ */
+public class LineItem {
+    
+    @Size(max = 12, message = "Product code must be at most 12 characters")
+    private String productCode;
+    
+    @NotBlank(message = "Line item description is required")
+    @Size(max = 35, message = "Line item description must be at most 35 characters")
+    private String description;
+    
+    // UNSPSC or NAICS code of the goods or service
+    @Pattern(regexp = "^[0-9]{1,12}$", message = "Invalid commodity code")
+    private String commodityCode;
+    
+    @NotNull(message = "Line item quantity is required")
+    @DecimalMin(value = "0", inclusive = false, message = "Line item quantity must be positive")
+    private BigDecimal quantity;
+    
+    @Size(max = 12, message = "Unit of measure must be at most 12 characters")
+    private String unitOfMeasure;
+    
+    @NotNull(message = "Line item unit price is required")
+    @DecimalMin(value = "0", message = "Line item unit price must not be negative")
+    private BigDecimal unitPrice;
+    
+    @DecimalMin(value = "0", message = "Line item discount must not be negative")
+    private BigDecimal discountAmount;
+    
+    public LineItem() {}
+    
+    public LineItem(String description, BigDecimal quantity, BigDecimal unitPrice) {
+        this.description = description;
+        this.quantity = quantity;
+        this.unitPrice = unitPrice;
+    }
+    
+    /**
+     * Quantity times unit price, less the discount, in cents
+     */
+    public BigDecimal total() {
+        BigDecimal total = quantity.multiply(unitPrice);
+        if (discountAmount != null) {
+            total = total.subtract(discountAmount);
+        }
+        return total.setScale(2, RoundingMode.HALF_UP);
+    }
+    
+    public String getProductCode() { return productCode; }
+    public void setProductCode(String productCode) { this.productCode = productCode; }
+    
+    public String getDescription() { return description; }
+    public void setDescription(String description) { this.description = description; }
+    
+    public String getCommodityCode() { return commodityCode; }
+    public void setCommodityCode(String commodityCode) { this.commodityCode = commodityCode; }
+    
+    public BigDecimal getQuantity() { return quantity; }
+    public void setQuantity(BigDecimal quantity) { this.quantity = quantity; }
+    
+    public String getUnitOfMeasure() { return unitOfMeasure; }
+    public void setUnitOfMeasure(String unitOfMeasure) { this.unitOfMeasure = unitOfMeasure; }
+    
+    public BigDecimal getUnitPrice() { return unitPrice; }
+    public void setUnitPrice(BigDecimal unitPrice) { this.unitPrice = unitPrice; }
+    
+    public BigDecimal getDiscountAmount() { return discountAmount; }
+    public void setDiscountAmount(BigDecimal discountAmount) { this.discountAmount = discountAmount; }
+}
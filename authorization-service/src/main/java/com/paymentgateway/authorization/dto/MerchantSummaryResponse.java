@@ -0,0 +1,55 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.domain.Merchant;
+
+/**
+ * A merchant as listed to administrators; API key and webhook secret hashes
+ * are never included
+ */
+public class MerchantSummaryResponse {
+    
+    private String merchantId;
+    private String merchantName;
+    private String mcc;
+    private String countryCode;
+    private String currency;
+    private String riskLevel;
+    private Boolean active;
+    
+    // Constructors
+    public MerchantSummaryResponse() {}
+    
+    public static MerchantSummaryResponse from(Merchant merchant) {
+        MerchantSummaryResponse response = new MerchantSummaryResponse();
+        response.merchantId = merchant.getMerchantId();
+        response.merchantName = merchant.getMerchantName();
+        response.mcc = merchant.getMcc();
+        response.countryCode = merchant.getCountryCode();
+        response.currency = merchant.getCurrency();
+        response.riskLevel = merchant.getRiskLevel();
+        response.active = merchant.getIsActive();
+        return response;
+    }
+    
+    // Getters and Setters
+    public String getMerchantId() { return merchantId; }
+    public void setMerchantId(String merchantId) { this.merchantId = merchantId; }
+    
+    public String getMerchantName() { return merchantName; }
+    public void setMerchantName(String merchantName) { this.merchantName = merchantName; }
+    
+    public String getMcc() { return mcc; }
+    public void setMcc(String mcc) { this.mcc = mcc; }
+    
+    public String getCountryCode() { return countryCode; }
+    public void setCountryCode(String countryCode) { this.countryCode = countryCode; }
+    
+    public String getCurrency() { return currency; }
+    public void setCurrency(String currency) { this.currency = currency; }
+    
+    public String getRiskLevel() { return riskLevel; }
+    public void setRiskLevel(String riskLevel) { this.riskLevel = riskLevel; }
+    
+    public Boolean getActive() { return active; }
+    public void setActive(Boolean active) { this.active = active; }
+}
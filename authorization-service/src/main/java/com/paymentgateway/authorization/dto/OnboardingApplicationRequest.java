@@ -0,0 +1,43 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.NotBlank;
+import jakarta.validation.constraints.Pattern;
+import jakarta.validation.constraints.Size;
+
+/**
+ * A business applying to become a merchant
+ */
+public class OnboardingApplicationRequest {
+    
+    @NotBlank(message = "Merchant name is required")
+    @Size(max = 255, message = "Merchant name must be at most 255 characters")
+    private String merchantName;
+    
+    @NotBlank(message = "MCC is required")
+    @Pattern(regexp = "^[0-9]{4}$", message = "MCC must be 4 digits")
+    private String mcc;
+    
+    @NotBlank(message = "Country code is required")
+    @Pattern(regexp = "^[A-Z]{2}$", message = "Country code must be 2 uppercase letters")
+    private String countryCode;
+    
+    @NotBlank(message = "Currency is required")
+    @Pattern(regexp = "^[A-Z]{3}$", message = "Currency must be 3 uppercase letters")
+    private String currency;
+    
+    // Constructors
+    public OnboardingApplicationRequest() {}
+    
+    // Getters and Setters
+    public String getMerchantName() { return merchantName; }
+    public void setMerchantName(String merchantName) { this.merchantName = merchantName; }
+    
+    public String getMcc() { return mcc; }
+    public void setMcc(String mcc) { this.mcc = mcc; }
+    
+    public String getCountryCode() { return countryCode; }
+    public void setCountryCode(String countryCode) { this.countryCode = countryCode; }
+    
+    public String getCurrency() { return currency; }
+    public void setCurrency(String currency) { this.currency = currency; }
+}
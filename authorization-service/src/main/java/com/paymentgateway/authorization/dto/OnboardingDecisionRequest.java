@@ -0,0 +1,20 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.Size;
+
+/**
+ * An administrator's decision on an application; a rejection needs a
+ * reason
+ */
+public class OnboardingDecisionRequest {
+    
+    @Size(max = 1000, message = "Reason must be at most 1000 characters")
+    private String reason;
+    
+    // Constructors
+    public OnboardingDecisionRequest() {}
+    
+    // Getters and Setters
+    public String getReason() { return reason; }
+    public void setReason(String reason) { this.reason = reason; }
+}
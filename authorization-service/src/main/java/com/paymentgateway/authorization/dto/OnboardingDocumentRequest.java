@@ -0,0 +1,28 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.NotBlank;
+import jakarta.validation.constraints.Size;
+
+/**
+ * A KYC document uploaded for onboarding, see OnboardingRequirements for
+ * the types
+ */
+public class OnboardingDocumentRequest {
+    
+    @NotBlank(message = "Document type is required")
+    private String documentType;
+    
+    @NotBlank(message = "Reference is required")
+    @Size(max = 255, message = "Reference must be at most 255 characters")
+    private String reference;
+    
+    // Constructors
+    public OnboardingDocumentRequest() {}
+    
+    // Getters and Setters
+    public String getDocumentType() { return documentType; }
+    public void setDocumentType(String documentType) { this.documentType = documentType; }
+    
+    public String getReference() { return reference; }
+    public void setReference(String reference) { this.reference = reference; }
+}
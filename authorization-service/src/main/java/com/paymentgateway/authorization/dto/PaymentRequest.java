@@ -1,30 +1,48 @@
 package com.paymentgateway.authorization.dto;
 
+import com.paymentgateway.authorization.domain.StoredCredentialType;
+import com.paymentgateway.authorization.domain.TransactionChannel;
+import com.paymentgateway.authorization.domain.TransactionInitiator;
 import com.paymentgateway.authorization.validation.*;
+import jakarta.validation.Valid;
 import jakarta.validation.constraints.*;
 import java.math.BigDecimal;
+import java.util.List;
 
+@ValidCardSource
 @ValidExpiryDate
+@ValidInitiation
+@ValidPurchaseData
+@ValidSplits
 public class PaymentRequest {
     
-    @NotBlank(message = "Card number is required")
+    // Required with the expiry date unless the card is encrypted, see
+    // @ValidCardSource
     @Pattern(regexp = "^[0-9]{13,19}$", message = "Invalid card number format")
     @LuhnCheck
     private String cardNumber;
     
-    @NotNull(message = "Expiry month is required")
+    // A P2PE terminal's encrypted read in place of the card number and
+    // expiry date, decrypted only by the tokenization service
+    @Valid
+    private EncryptedCard encryptedCard;
+    
     @Min(value = 1, message = "Expiry month must be between 1 and 12")
     @Max(value = 12, message = "Expiry month must be between 1 and 12")
     private Integer expiryMonth;
     
-    @NotNull(message = "Expiry year is required")
     @Min(value = 2025, message = "Card has expired")
     private Integer expiryYear;
     
-    @NotBlank(message = "CVV is required")
+    // Required unless merchant-initiated, see @ValidInitiation
     @Pattern(regexp = "^[0-9]{3,4}$", message = "Invalid CVV format")
     private String cvv;
     
+    // Screened against sanctions lists with the billing country; never
+    // stored on the payment
+    @Size(max = 100, message = "Cardholder name must be at most 100 characters")
+    private String cardholderName;
+    
     @NotNull(message = "Amount is required")
     @ValidAmount
     private BigDecimal amount;
@@ -36,7 +54,11 @@ public class PaymentRequest {
     private String description;
     private String referenceId;
     
-    // Billing address
+    // Billing address. The structured address takes precedence over the
+    // flat billing fields, which older clients still send
+    @Valid
+    private BillingAddress billingAddress;
+    
     private String billingStreet;
     private String billingCity;
     private String billingState;
@@ -45,6 +67,68 @@ public class PaymentRequest {
     @Pattern(regexp = "^[A-Z]{2}$", message = "Invalid country code")
     private String billingCountry;
     
+    // 3D Secure authentication data, sent when resubmitting a soft-declined payment
+    private String threeDsCavv;
+    
+    @Pattern(regexp = "^[0-9]{2}$", message = "Invalid ECI")
+    private String threeDsEci;
+    
+    private String threeDsTransactionId;
+    
+    // Base64 cryptogram issued with a network token; required when the card
+    // number is one
+    @Size(max = 64, message = "Token cryptogram must be at most 64 characters")
+    @Pattern(regexp = "^[A-Za-z0-9+/]+={0,2}$", message = "Token cryptogram must be base64")
+    private String tokenCryptogram;
+    
+    private String originalPaymentId;
+    
+    // Transaction initiation indicators
+    private TransactionChannel channel = TransactionChannel.ECOMMERCE;
+    private TransactionInitiator initiator = TransactionInitiator.CUSTOMER;
+    private StoredCredentialType storedCredential;
+    
+    @Pattern(regexp = "^[0-9A-Z]{15}$", message = "Invalid network transaction ID")
+    private String originalNetworkTransactionId;
+    
+    // Per-transaction suffix of the statement descriptor, after the
+    // merchant's static descriptor and an asterisk
+    @Size(max = 22, message = "Dynamic descriptor must be at most 22 characters")
+    private String dynamicDescriptor;
+    
+    // Level 2 purchasing data: the sales tax included in the amount and the
+    // buyer's reference, such as a purchase order number
+    @DecimalMin(value = "0", message = "Tax amount must not be negative")
+    private BigDecimal taxAmount;
+    
+    @Pattern(regexp = "^[A-Za-z0-9 -]{1,17}$", message = "Invalid customer code")
+    private String customerCode;
+    
+    // Level 3 purchasing data, which with the tax must add up to the amount
+    @Valid
+    @Size(max = 99, message = "At most 99 line items")
+    private List<LineItem> lineItems;
+    
+    // Marketplace portions for sub-merchants of the paying platform, out
+    // of the amount; the rest stays with the platform
+    @Valid
+    @Size(max = 10, message = "At most 10 splits")
+    private List<Split> splits;
+    
+    // Installment plan, for cards and regions that offer one
+    @Valid
+    private Installments installments;
+    
+    // ISO 9564 format 4 PIN block under the acquirer ZPK, as hex, for PIN
+    // transactions; passed to the issuer and never stored
+    @Pattern(regexp = "^[0-9A-Fa-f]{32}$", message = "Invalid PIN block")
+    private String pinBlock;
+    
+    // EMV data the chip card produced, for chip transactions; the issuer
+    // verifies the ARQC and answers the card in field 55 of the response
+    @Valid
+    private ChipData chipData;
+    
     // Constructors
     public PaymentRequest() {}
     
@@ -62,6 +146,9 @@ public class PaymentRequest {
     public String getCardNumber() { return cardNumber; }
     public void setCardNumber(String cardNumber) { this.cardNumber = cardNumber; }
     
+    public EncryptedCard getEncryptedCard() { return encryptedCard; }
+    public void setEncryptedCard(EncryptedCard encryptedCard) { this.encryptedCard = encryptedCard; }
+    
     public Integer getExpiryMonth() { return expiryMonth; }
     public void setExpiryMonth(Integer expiryMonth) { this.expiryMonth = expiryMonth; }
     
@@ -71,6 +158,9 @@ public class PaymentRequest {
     public String getCvv() { return cvv; }
     public void setCvv(String cvv) { this.cvv = cvv; }
     
+    public String getCardholderName() { return cardholderName; }
+    public void setCardholderName(String cardholderName) { this.cardholderName = cardholderName; }
+    
     public BigDecimal getAmount() { return amount; }
     public void setAmount(BigDecimal amount) { this.amount = amount; }
     
@@ -83,6 +173,19 @@ public class PaymentRequest {
     public String getReferenceId() { return referenceId; }
     public void setReferenceId(String referenceId) { this.referenceId = referenceId; }
     
+    public BillingAddress getBillingAddress() { return billingAddress; }
+    public void setBillingAddress(BillingAddress billingAddress) { this.billingAddress = billingAddress; }
+    
+    /**
+     * The billing address as sent, structured or from the flat fields
+     */
+    public BillingAddress billingAddress() {
+        if (billingAddress != null) {
+            return billingAddress;
+        }
+        return new BillingAddress(billingStreet, billingCity, billingState, billingZip, billingCountry);
+    }
+    
     public String getBillingStreet() { return billingStreet; }
     public void setBillingStreet(String billingStreet) { this.billingStreet = billingStreet; }
     
@@ -97,4 +200,66 @@ public class PaymentRequest {
     
     public String getBillingCountry() { return billingCountry; }
     public void setBillingCountry(String billingCountry) { this.billingCountry = billingCountry; }
+    
+    public String getThreeDsCavv() { return threeDsCavv; }
+    public void setThreeDsCavv(String threeDsCavv) { this.threeDsCavv = threeDsCavv; }
+    
+    public String getThreeDsEci() { return threeDsEci; }
+    public void setThreeDsEci(String threeDsEci) { this.threeDsEci = threeDsEci; }
+    
+    public String getThreeDsTransactionId() { return threeDsTransactionId; }
+    public void setThreeDsTransactionId(String threeDsTransactionId) { this.threeDsTransactionId = threeDsTransactionId; }
+    
+    public String getTokenCryptogram() { return tokenCryptogram; }
+    public void setTokenCryptogram(String tokenCryptogram) { this.tokenCryptogram = tokenCryptogram; }
+    
+    public String getOriginalPaymentId() { return originalPaymentId; }
+    public void setOriginalPaymentId(String originalPaymentId) { this.originalPaymentId = originalPaymentId; }
+    
+    public TransactionChannel getChannel() { return channel; }
+    public void setChannel(TransactionChannel channel) { this.channel = channel; }
+    
+    public TransactionInitiator getInitiator() { return initiator; }
+    public void setInitiator(TransactionInitiator initiator) { this.initiator = initiator; }
+    
+    public StoredCredentialType getStoredCredential() { return storedCredential; }
+    public void setStoredCredential(StoredCredentialType storedCredential) { this.storedCredential = storedCredential; }
+    
+    public String getOriginalNetworkTransactionId() { return originalNetworkTransactionId; }
+    public void setOriginalNetworkTransactionId(String originalNetworkTransactionId) { this.originalNetworkTransactionId = originalNetworkTransactionId; }
+    
+    public String getDynamicDescriptor() { return dynamicDescriptor; }
+    public void setDynamicDescriptor(String dynamicDescriptor) { this.dynamicDescriptor = dynamicDescriptor; }
+    
+    public BigDecimal getTaxAmount() { return taxAmount; }
+    public void setTaxAmount(BigDecimal taxAmount) { this.taxAmount = taxAmount; }
+    
+    public String getCustomerCode() { return customerCode; }
+    public void setCustomerCode(String customerCode) { this.customerCode = customerCode; }
+    
+    public List<LineItem> getLineItems() { return lineItems; }
+    public void setLineItems(List<LineItem> lineItems) { this.lineItems = lineItems; }
+    
+    /**
+     * The level of purchasing data sent: 3 with line items on top of the
+     * Level 2 tax amount and customer code, 2 with those alone, otherwise 1
+     */
+    public int purchaseDataLevel() {
+        if (taxAmount == null || customerCode == null) {
+            return 1;
+        }
+        return lineItems != null && !lineItems.isEmpty() ? 3 : 2;
+    }
+    
+    public List<Split> getSplits() { return splits; }
+    public void setSplits(List<Split> splits) { this.splits = splits; }
+    
+    public Installments getInstallments() { return installments; }
+    public void setInstallments(Installments installments) { this.installments = installments; }
+    
+    public String getPinBlock() { return pinBlock; }
+    public void setPinBlock(String pinBlock) { this.pinBlock = pinBlock; }
+    
+    public ChipData getChipData() { return chipData; }
+    public void setChipData(ChipData chipData) { this.chipData = chipData; }
 }
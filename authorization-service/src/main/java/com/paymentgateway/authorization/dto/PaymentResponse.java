@@ -3,6 +3,7 @@ package com.paymentgateway.authorization.dto;
 import com.paymentgateway.authorization.domain.PaymentStatus;
 import java.math.BigDecimal;
 import java.time.Instant;
+import java.util.List;
 
 public class PaymentResponse {
     
@@ -16,6 +17,34 @@ public class PaymentResponse {
     private Instant authorizedAt;
     private String errorCode;
     private String errorMessage;
+    private String scaExemption;
+    private boolean authenticationRequired;
+    private String networkTransactionId;
+    // CVV2 result from the issuer: M (match), N (no match) or P (not
+    // processed); absent when the CVV2 was not checked
+    private String cvvResult;
+    // Name on the cardholder's statement
+    private String statementDescriptor;
+    // AVS result from the issuer: Y (street and postal code match), A
+    // (street only), Z (postal code only), N (neither) or U (unavailable);
+    // absent when no billing address was sent
+    private String avsResult;
+    // Level of purchasing data sent (1, 2 or 3)
+    private Integer purchaseDataLevel;
+    // The amount as goods plus surcharge or convenience fee
+    private AmountBreakdown amountBreakdown;
+    private List<Split> splits;
+    private Installments installments;
+    // Authorized, but held by sanctions screening: it cannot be captured
+    // until the hit is released
+    private boolean screeningHold;
+    // Issuing country of the card, absent when the BIN table does not know
+    // it, and whether it differs from the merchant's country
+    private String issuerCountry;
+    private boolean crossBorder;
+    // Issuer's response for the chip card to check, ISO 8583 field 55 as
+    // hex; absent unless the issuer verified the card's ARQC
+    private String field55;
     
     // Constructors
     public PaymentResponse() {}
@@ -57,4 +86,46 @@ public class PaymentResponse {
     
     public String getErrorMessage() { return errorMessage; }
     public void setErrorMessage(String errorMessage) { this.errorMessage = errorMessage; }
+    
+    public String getScaExemption() { return scaExemption; }
+    public void setScaExemption(String scaExemption) { this.scaExemption = scaExemption; }
+    
+    public boolean isAuthenticationRequired() { return authenticationRequired; }
+    public void setAuthenticationRequired(boolean authenticationRequired) { this.authenticationRequired = authenticationRequired; }
+    
+    public String getNetworkTransactionId() { return networkTransactionId; }
+    public void setNetworkTransactionId(String networkTransactionId) { this.networkTransactionId = networkTransactionId; }
+    
+    public String getCvvResult() { return cvvResult; }
+    public void setCvvResult(String cvvResult) { this.cvvResult = cvvResult; }
+    
+    public String getStatementDescriptor() { return statementDescriptor; }
+    public void setStatementDescriptor(String statementDescriptor) { this.statementDescriptor = statementDescriptor; }
+    
+    public String getAvsResult() { return avsResult; }
+    public void setAvsResult(String avsResult) { this.avsResult = avsResult; }
+    
+    public Integer getPurchaseDataLevel() { return purchaseDataLevel; }
+    public void setPurchaseDataLevel(Integer purchaseDataLevel) { this.purchaseDataLevel = purchaseDataLevel; }
+    
+    public AmountBreakdown getAmountBreakdown() { return amountBreakdown; }
+    public void setAmountBreakdown(AmountBreakdown amountBreakdown) { this.amountBreakdown = amountBreakdown; }
+    
+    public List<Split> getSplits() { return splits; }
+    public void setSplits(List<Split> splits) { this.splits = splits; }
+    
+    public Installments getInstallments() { return installments; }
+    public void setInstallments(Installments installments) { this.installments = installments; }
+    
+    public boolean isScreeningHold() { return screeningHold; }
+    public void setScreeningHold(boolean screeningHold) { this.screeningHold = screeningHold; }
+    
+    public String getIssuerCountry() { return issuerCountry; }
+    public void setIssuerCountry(String issuerCountry) { this.issuerCountry = issuerCountry; }
+    
+    public boolean isCrossBorder() { return crossBorder; }
+    public void setCrossBorder(boolean crossBorder) { this.crossBorder = crossBorder; }
+    
+    public String getField55() { return field55; }
+    public void setField55(String field55) { this.field55 = field55; }
 }
@@ -0,0 +1,88 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.domain.PaymentStatus;
+import java.time.Instant;
+import java.util.List;
+
+/**
+ * Every recorded step of a payment in time order, for debugging its flow
+ * end to end. Entries of one operation share a correlation ID; trace IDs
+ * link to the distributed traces.
+ */
+public class PaymentTimelineResponse {
+    
+    private String paymentId;
+    private PaymentStatus status;
+    private List<String> traceIds;
+    private List<Entry> entries;
+    
+    // Constructors
+    public PaymentTimelineResponse() {}
+    
+    public PaymentTimelineResponse(String paymentId, PaymentStatus status, List<String> traceIds, List<Entry> entries) {
+        this.paymentId = paymentId;
+        this.status = status;
+        this.traceIds = traceIds;
+        this.entries = entries;
+    }
+    
+    // Getters and Setters
+    public String getPaymentId() { return paymentId; }
+    public void setPaymentId(String paymentId) { this.paymentId = paymentId; }
+    
+    public PaymentStatus getStatus() { return status; }
+    public void setStatus(PaymentStatus status) { this.status = status; }
+    
+    public List<String> getTraceIds() { return traceIds; }
+    public void setTraceIds(List<String> traceIds) { this.traceIds = traceIds; }
+    
+    public List<Entry> getEntries() { return entries; }
+    public void setEntries(List<Entry> entries) { this.entries = entries; }
+    
+    /**
+     * One step: TOKENIZATION, CRYPTOGRAM_CHECK, FRAUD_CHECK, GEO_CHECK,
+     * SANCTIONS_SCREENING, 3DS_AUTH, AUTHORIZATION_REQUEST, AVS_CHECK,
+     * AUTHORIZATION_RESPONSE, CAPTURE, VOID, REFUND_*, CREDIT, SETTLEMENT or
+     * WEBHOOK
+     */
+    public static class Entry {
+        
+        private String step;
+        private String status;
+        private Instant timestamp;
+        private Integer durationMs;
+        private String correlationId;
+        private String traceId;
+        private String detail;
+        
+        public Entry() {}
+        
+        public Entry(String step, String status, Instant timestamp, String correlationId) {
+            this.step = step;
+            this.status = status;
+            this.timestamp = timestamp;
+            this.correlationId = correlationId;
+        }
+        
+        public String getStep() { return step; }
+        public void setStep(String step) { this.step = step; }
+        
+        public String getStatus() { return status; }
+        public void setStatus(String status) { this.status = status; }
+        
+        public Instant getTimestamp() { return timestamp; }
+        public void setTimestamp(Instant timestamp) { this.timestamp = timestamp; }
+        
+        public Integer getDurationMs() { return durationMs; }
+        public void setDurationMs(Integer durationMs) { this.durationMs = durationMs; }
+        
+        public String getCorrelationId() { return correlationId; }
+        public void setCorrelationId(String correlationId) { this.correlationId = correlationId; }
+        
+        public String getTraceId() { return traceId; }
+        public void setTraceId(String traceId) { this.traceId = traceId; }
+        
+        public String getDetail() { return detail; }
+        public void setDetail(String detail) { this.detail = detail; }
+    }
+}
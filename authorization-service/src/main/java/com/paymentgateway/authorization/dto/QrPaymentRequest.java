@@ -0,0 +1,34 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.validation.*;
+import jakarta.validation.constraints.*;
+import java.math.BigDecimal;
+
+/**
+ * A QR code to present for one payment
+ */
+public class QrPaymentRequest {
+    
+    @NotNull(message = "Amount is required")
+    @ValidAmount
+    private BigDecimal amount;
+    
+    @NotBlank(message = "Currency is required")
+    @ValidCurrency
+    private String currency;
+    
+    // Carried in the code as the bill number, so printable ASCII
+    @Pattern(regexp = "^[\\x20-\\x7E]{1,25}$", message = "Reference must be up to 25 printable ASCII characters")
+    private String reference;
+    
+    public QrPaymentRequest() {}
+    
+    public BigDecimal getAmount() { return amount; }
+    public void setAmount(BigDecimal amount) { this.amount = amount; }
+    
+    public String getCurrency() { return currency; }
+    public void setCurrency(String currency) { this.currency = currency; }
+    
+    public String getReference() { return reference; }
+    public void setReference(String reference) { this.reference = reference; }
+}
@@ -0,0 +1,25 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.Min;
+
+/**
+ * A merchant's refund policy; a limit left out uses the gateway default
+ */
+public class RefundPolicyRequest {
+    
+    @Min(value = 1, message = "Refund window must be at least 1 day")
+    private Integer windowDays;
+    
+    @Min(value = 1, message = "At least 1 refund per payment must be allowed")
+    private Integer maxRefunds;
+    
+    // Constructors
+    public RefundPolicyRequest() {}
+    
+    // Getters and Setters
+    public Integer getWindowDays() { return windowDays; }
+    public void setWindowDays(Integer windowDays) { this.windowDays = windowDays; }
+    
+    public Integer getMaxRefunds() { return maxRefunds; }
+    public void setMaxRefunds(Integer maxRefunds) { this.maxRefunds = maxRefunds; }
+}
@@ -16,6 +16,9 @@ public class RefundRequest {
     
     private String reason;
     
+    // Refund despite the merchant's refund policy; ADMIN role only
+    private boolean overridePolicy;
+    
     // Constructors
     public RefundRequest() {}
     
@@ -39,4 +42,7 @@ public class RefundRequest {
     
     public String getReason() { return reason; }
     public void setReason(String reason) { this.reason = reason; }
+    
+    public boolean isOverridePolicy() { return overridePolicy; }
+    public void setOverridePolicy(boolean overridePolicy) { this.overridePolicy = overridePolicy; }
 }
@@ -16,6 +16,7 @@ public class RefundResponse {
     private Instant processedAt;
     private String errorCode;
     private String errorMessage;
+    private String policyOverride;
     
     // Constructors
     public RefundResponse() {}
@@ -59,4 +60,7 @@ public class RefundResponse {
     
     public String getErrorMessage() { return errorMessage; }
     public void setErrorMessage(String errorMessage) { this.errorMessage = errorMessage; }
+    
+    public String getPolicyOverride() { return policyOverride; }
+    public void setPolicyOverride(String policyOverride) { this.policyOverride = policyOverride; }
 }
@@ -0,0 +1,28 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.Min;
+import jakarta.validation.constraints.NotBlank;
+import jakarta.validation.constraints.Size;
+
+/**
+ * A sandbox to create, and how many merchants it starts with
+ */
+public class SandboxRequest {
+    
+    @NotBlank(message = "Name is required")
+    @Size(max = 100, message = "Name must be at most 100 characters")
+    private String name;
+    
+    @Min(value = 1, message = "A sandbox needs at least one merchant")
+    private Integer merchants = 1;
+    
+    // Constructors
+    public SandboxRequest() {}
+    
+    // Getters and Setters
+    public String getName() { return name; }
+    public void setName(String name) { this.name = name; }
+    
+    public Integer getMerchants() { return merchants; }
+    public void setMerchants(Integer merchants) { this.merchants = merchants; }
+}
@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.Size;
+
+/**
+ * An administrator's release or confirmation of a screening hit
+ */
+public class ScreeningDecisionRequest {
+    
+    @Size(max = 1000, message = "Note must be at most 1000 characters")
+    private String note;
+    
+    // Constructors
+    public ScreeningDecisionRequest() {}
+    
+    // Getters and Setters
+    public String getNote() { return note; }
+    public void setNote(String note) { this.note = note; }
+}
@@ -0,0 +1,46 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.validation.ValidAmount;
+import jakarta.validation.constraints.*;
+import java.math.BigDecimal;
+
+/**
+ * A portion of a marketplace payment for a sub-merchant of the paying
+ * platform, and the platform's fee on it. In responses the net amount is
+ * what the sub-merchant is paid out.
+ */
+public class Split {
+    
+    @NotBlank(message = "Split merchant ID is required")
+    @Pattern(regexp = "^[A-Za-z0-9_-]{1,50}$", message = "Invalid split merchant ID")
+    private String merchantId;
+    
+    @NotNull(message = "Split amount is required")
+    @ValidAmount
+    private BigDecimal amount;
+    
+    @ValidAmount(min = "0", message = "Split fee must be between {min} and {max}")
+    private BigDecimal feeAmount;
+    
+    private BigDecimal netAmount;
+    
+    public Split() {}
+    
+    public Split(String merchantId, BigDecimal amount, BigDecimal feeAmount) {
+        this.merchantId = merchantId;
+        this.amount = amount;
+        this.feeAmount = feeAmount;
+    }
+    
+    public String getMerchantId() { return merchantId; }
+    public void setMerchantId(String merchantId) { this.merchantId = merchantId; }
+    
+    public BigDecimal getAmount() { return amount; }
+    public void setAmount(BigDecimal amount) { this.amount = amount; }
+    
+    public BigDecimal getFeeAmount() { return feeAmount; }
+    public void setFeeAmount(BigDecimal feeAmount) { this.feeAmount = feeAmount; }
+    
+    public BigDecimal getNetAmount() { return netAmount; }
+    public void setNetAmount(BigDecimal netAmount) { this.netAmount = netAmount; }
+}
@@ -0,0 +1,18 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.*;
+
+/**
+ * A vault token to check before charging it
+ */
+public class TokenValidationRequest {
+    
+    @NotBlank(message = "Token is required")
+    @Pattern(regexp = "^9[0-9]{12,18}$", message = "Invalid vault token format")
+    private String token;
+    
+    public TokenValidationRequest() {}
+    
+    public String getToken() { return token; }
+    public void setToken(String token) { this.token = token; }
+}
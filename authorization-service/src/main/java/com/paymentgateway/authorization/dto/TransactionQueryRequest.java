@@ -20,6 +20,9 @@ public class TransactionQueryRequest {
     private Integer size = 20;
     private String sortBy = "createdAt";
     private String sortDirection = "DESC";
+    // Continues after the last transaction of a previous page; page is
+    // ignored when set
+    private String pageToken;
     
     // Constructors
     public TransactionQueryRequest() {}
@@ -60,4 +63,7 @@ public class TransactionQueryRequest {
     
     public String getSortDirection() { return sortDirection; }
     public void setSortDirection(String sortDirection) { this.sortDirection = sortDirection; }
+    
+    public String getPageToken() { return pageToken; }
+    public void setPageToken(String pageToken) { this.pageToken = pageToken; }
 }
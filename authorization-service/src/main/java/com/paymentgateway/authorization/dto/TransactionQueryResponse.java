@@ -11,6 +11,7 @@ public class TransactionQueryResponse {
     private int totalPages;
     private boolean hasNext;
     private boolean hasPrevious;
+    private String nextPageToken;
     
     // Constructors
     public TransactionQueryResponse() {}
@@ -47,4 +48,7 @@ public class TransactionQueryResponse {
     
     public boolean isHasPrevious() { return hasPrevious; }
     public void setHasPrevious(boolean hasPrevious) { this.hasPrevious = hasPrevious; }
+    
+    public String getNextPageToken() { return nextPageToken; }
+    public void setNextPageToken(String nextPageToken) { this.nextPageToken = nextPageToken; }
 }
@@ -0,0 +1,46 @@
+package com.paymentgateway.authorization.dto;
+
+import com.paymentgateway.authorization.validation.*;
+import jakarta.validation.constraints.*;
+
+/**
+ * A simulated wallet's scan of a QR code, and the card it pays with.
+ * Decoding needs only the payload.
+ */
+@ValidExpiryDate
+public class WalletQrRequest {
+    
+    @NotBlank(message = "Payload is required")
+    private String payload;
+    
+    @Pattern(regexp = "^[0-9]{13,19}$", message = "Invalid card number format")
+    @LuhnCheck
+    private String cardNumber;
+    
+    @Min(value = 1, message = "Expiry month must be between 1 and 12")
+    @Max(value = 12, message = "Expiry month must be between 1 and 12")
+    private Integer expiryMonth;
+    
+    @Min(value = 2025, message = "Card has expired")
+    private Integer expiryYear;
+    
+    @Pattern(regexp = "^[0-9]{3,4}$", message = "Invalid CVV format")
+    private String cvv;
+    
+    public WalletQrRequest() {}
+    
+    public String getPayload() { return payload; }
+    public void setPayload(String payload) { this.payload = payload; }
+    
+    public String getCardNumber() { return cardNumber; }
+    public void setCardNumber(String cardNumber) { this.cardNumber = cardNumber; }
+    
+    public Integer getExpiryMonth() { return expiryMonth; }
+    public void setExpiryMonth(Integer expiryMonth) { this.expiryMonth = expiryMonth; }
+    
+    public Integer getExpiryYear() { return expiryYear; }
+    public void setExpiryYear(Integer expiryYear) { this.expiryYear = expiryYear; }
+    
+    public String getCvv() { return cvv; }
+    public void setCvv(String cvv) { this.cvv = cvv; }
+}
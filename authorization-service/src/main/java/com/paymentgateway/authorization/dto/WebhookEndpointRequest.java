@@ -0,0 +1,21 @@
+package com.paymentgateway.authorization.dto;
+
+import jakarta.validation.constraints.NotBlank;
+import jakarta.validation.constraints.Size;
+
+/**
+ * A webhook endpoint to register; it receives events once verified
+ */
+public class WebhookEndpointRequest {
+    
+    @NotBlank(message = "URL is required")
+    @Size(max = 2048, message = "URL must be at most 2048 characters")
+    private String url;
+    
+    // Constructors
+    public WebhookEndpointRequest() {}
+    
+    // Getters and Setters
+    public String getUrl() { return url; }
+    public void setUrl(String url) { this.url = url; }
+}
@@ -0,0 +1,24 @@
+package com.paymentgateway.authorization.dto;
+
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * Which dead-lettered webhook deliveries to send again: the listed ones, or
+ * when none are listed all of them, optionally only of one event type
+ */
+public class WebhookRedeliveryRequest {
+    
+    private List<UUID> deliveryIds;
+    private String eventType;
+    
+    // Constructors
+    public WebhookRedeliveryRequest() {}
+    
+    // Getters and Setters
+    public List<UUID> getDeliveryIds() { return deliveryIds; }
+    public void setDeliveryIds(List<UUID> deliveryIds) { this.deliveryIds = deliveryIds; }
+    
+    public String getEventType() { return eventType; }
+    public void setEventType(String eventType) { this.eventType = eventType; }
+}
@@ -0,0 +1,49 @@
+package com.paymentgateway.authorization.enrichment;
+
+import com.fasterxml.jackson.annotation.JsonInclude;
+
+/**
+ * What a wallet shows for a card: its brand, issuer and product from the BIN
+ * table and placeholder card art. Issuer fields are absent when the table
+ * does not name the BIN's issuer.
+ */
+@JsonInclude(JsonInclude.Include.NON_NULL)
+public final class CardEnrichment {
+    
+    private final String bin;
+    private final String brand;
+    private final String issuerName;
+    private final String issuerCountry;
+    private final String product;
+    private final String funding;
+    private final boolean prepaid;
+    private final boolean debit;
+    private final String cardArtUrl;
+    private final String cardArtThumbnailUrl;
+    
+    CardEnrichment(String bin, String brand, String issuerName, String issuerCountry, String product,
+                   String funding, String cardArtUrl, String cardArtThumbnailUrl) {
+        this.bin = bin;
+        this.brand = brand;
+        this.issuerName = issuerName;
+        this.issuerCountry = issuerCountry;
+        this.product = product;
+        this.funding = funding;
+        this.prepaid = "PREPAID".equals(funding);
+        this.debit = "DEBIT".equals(funding);
+        this.cardArtUrl = cardArtUrl;
+        this.cardArtThumbnailUrl = cardArtThumbnailUrl;
+    }
+    
+    public String getBin() { return bin; }
+    public String getBrand() { return brand; }
+    public String getIssuerName() { return issuerName; }
+    public String getIssuerCountry() { return issuerCountry; }
+    public String getProduct() { return product; }
+    // CREDIT, DEBIT or PREPAID
+    public String getFunding() { return funding; }
+    public boolean isPrepaid() { return prepaid; }
+    public boolean isDebit() { return debit; }
+    public String getCardArtUrl() { return cardArtUrl; }
+    public String getCardArtThumbnailUrl() { return cardArtThumbnailUrl; }
+}
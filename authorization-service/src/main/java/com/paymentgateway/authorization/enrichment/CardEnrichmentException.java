@@ -0,0 +1,18 @@
+package com.paymentgateway.authorization.enrichment;
+
+/**
+ * A token that cannot be enriched: unknown to the vault, not usable, or no
+ * vault to ask
+ */
+public class CardEnrichmentException extends RuntimeException {
+    
+    private final String code;
+    
+    public CardEnrichmentException(String code, String message) {
+        super(message);
+        this.code = code;
+    }
+    
+    // TOKEN_NOT_FOUND, TOKEN_UNUSABLE or TOKEN_LOOKUP_UNAVAILABLE
+    public String getCode() { return code; }
+}
@@ -0,0 +1,93 @@
+package com.paymentgateway.authorization.enrichment;
+
+import com.paymentgateway.authorization.batch.BatchTokenResolver;
+import com.paymentgateway.authorization.batch.ResolvedCard;
+import com.paymentgateway.authorization.domain.CardBrand;
+import com.paymentgateway.authorization.geo.BinTable;
+import com.paymentgateway.authorization.geo.CardIssuer;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.lang.Nullable;
+import org.springframework.stereotype.Service;
+
+import java.util.List;
+import java.util.Locale;
+
+/**
+ * Enriches a BIN or a vault token with issuer metadata from the BIN table
+ * and placeholder card art, for wallet UIs. A token is resolved to its card
+ * through the tokenization service, as batch file tokens are; the PAN is
+ * only used for its BIN.
+ */
+@Service
+public class CardEnrichmentService {
+    
+    // The reference token lookups carry in the vault's audit trail
+    static final String AUDIT_REFERENCE = "card-enrichment";
+    private static final int BIN_LENGTH = 6;
+    
+    private final BinTable binTable;
+    private final BatchTokenResolver tokenResolver;
+    private final String cardArtBaseUrl;
+    
+    @Autowired
+    public CardEnrichmentService(BinTable binTable,
+                                 @Nullable BatchTokenResolver tokenResolver,
+                                 @Value("${payment.card-enrichment.card-art-base-url:https://card-art.simulator.invalid}") String cardArtBaseUrl) {
+        this.binTable = binTable;
+        this.tokenResolver = tokenResolver;
+        this.cardArtBaseUrl = cardArtBaseUrl.endsWith("/")
+            ? cardArtBaseUrl.substring(0, cardArtBaseUrl.length() - 1) : cardArtBaseUrl;
+    }
+    
+    /**
+     * Enrich a BIN of 6 to 8 digits
+     */
+    public CardEnrichment forBin(String bin) {
+        return enrich(bin);
+    }
+    
+    /**
+     * Enrich the card behind a vault token issued to the merchant
+     */
+    public CardEnrichment forToken(String merchantId, String token) {
+        if (tokenResolver == null) {
+            throw new CardEnrichmentException("TOKEN_LOOKUP_UNAVAILABLE",
+                "No tokenization service is configured (batch.tokenization.address)");
+        }
+        ResolvedCard card;
+        try {
+            card = tokenResolver.resolve(merchantId, List.of(token), AUDIT_REFERENCE).get(0);
+        } catch (RuntimeException e) {
+            throw new CardEnrichmentException("TOKEN_LOOKUP_UNAVAILABLE", "Token vault unreachable: " + e.getMessage());
+        }
+        if (!card.isResolved()) {
+            throw new CardEnrichmentException("NotFound".equals(card.getErrorCode()) ? "TOKEN_NOT_FOUND" : "TOKEN_UNUSABLE",
+                card.getErrorMessage());
+        }
+        return enrich(card.getPan());
+    }
+    
+    /**
+     * Looks the card number or BIN up; only its first digits are kept
+     */
+    private CardEnrichment enrich(String cardNumber) {
+        String brand = CardBrand.fromPan(cardNumber).name();
+        CardIssuer issuer = binTable.issuer(cardNumber);
+        String product = issuer == null ? null : issuer.getProduct();
+        String art = cardArtBaseUrl + "/" + slug(brand) + "/" + (product == null ? "standard" : slug(product));
+        return new CardEnrichment(
+            cardNumber.substring(0, Math.min(BIN_LENGTH, cardNumber.length())),
+            brand,
+            issuer == null ? null : issuer.getName(),
+            binTable.issuerCountry(cardNumber),
+            product,
+            issuer == null ? null : issuer.getFunding().name(),
+            art + ".png",
+            art + "-thumb.png");
+    }
+    
+    private static String slug(String s) {
+        return s.toLowerCase(Locale.ROOT).replaceAll("[^a-z0-9]+", "-").replaceAll("^-|-$", "");
+    }
+}
@@ -104,6 +104,18 @@ public class PaymentEventConsumer {
             case PAYMENT_REFUNDED:
                 handlePaymentRefunded(event);
                 break;
+            case PAYMENT_SETTLED:
+                handlePaymentSettled(event);
+                break;
+            case DISPUTE_OPENED:
+            case DISPUTE_EVIDENCE_SUBMITTED:
+            case DISPUTE_WON:
+            case DISPUTE_LOST:
+                handleDisputeEvent(event);
+                break;
+            case MERCHANT_ONBOARDING_UPDATED:
+                handleMerchantOnboardingUpdated(event);
+                break;
             default:
                 logger.warn("Unknown event type: {}", event.getEventType());
         }
@@ -139,6 +151,24 @@ public class PaymentEventConsumer {
         // Implementation: Update settlement records, trigger webhooks, etc.
     }
     
+    private void handlePaymentSettled(PaymentEventMessage event) {
+        logger.info("Handling PAYMENT_SETTLED event: paymentId={}", 
+                event.getPayload().getPaymentId());
+        // Published by the settlement service when the payment is in a batch
+    }
+    
+    private void handleDisputeEvent(PaymentEventMessage event) {
+        logger.info("Handling {} event: paymentId={}, disputeId={}, status={}", event.getEventType(),
+                event.getPayload().getPaymentId(), event.getPayload().getDisputeId(), event.getPayload().getStatus());
+        // Published by the settlement service as the dispute changes stage
+    }
+    
+    private void handleMerchantOnboardingUpdated(PaymentEventMessage event) {
+        logger.info("Handling MERCHANT_ONBOARDING_UPDATED event: merchantId={}, status={}",
+                event.getPayload().getMerchantId(), event.getPayload().getStatus());
+        // Implementation: Notify the risk team of applications to review, etc.
+    }
+    
     /**
      * Checks if an event has already been processed.
      */
@@ -1,5 +1,6 @@
 package com.paymentgateway.authorization.event;
 
+import com.fasterxml.jackson.annotation.JsonInclude;
 import com.fasterxml.jackson.annotation.JsonProperty;
 
 import java.math.BigDecimal;
@@ -142,6 +143,11 @@ public class PaymentEventMessage {
         @JsonProperty("three_ds_status")
         private String threeDsStatus;
         
+        // Only on dispute events, whose status is the dispute's
+        @JsonProperty("dispute_id")
+        @JsonInclude(JsonInclude.Include.NON_NULL)
+        private String disputeId;
+        
         public PaymentEventPayload() {
         }
         
@@ -210,6 +216,14 @@ public class PaymentEventMessage {
             this.threeDsStatus = threeDsStatus;
         }
         
+        public String getDisputeId() {
+            return disputeId;
+        }
+        
+        public void setDisputeId(String disputeId) {
+            this.disputeId = disputeId;
+        }
+        
         @Override
         public String toString() {
             return "PaymentEventPayload{" +
@@ -221,6 +235,7 @@ public class PaymentEventMessage {
                     ", pspTransactionId='" + pspTransactionId + '\'' +
                     ", fraudScore=" + fraudScore +
                     ", threeDsStatus='" + threeDsStatus + '\'' +
+                    ", disputeId='" + disputeId + '\'' +
                     '}';
         }
     }
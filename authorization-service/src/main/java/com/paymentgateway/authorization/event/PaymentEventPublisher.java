@@ -1,11 +1,15 @@
 package com.paymentgateway.authorization.event;
 
+import com.fasterxml.jackson.databind.ObjectMapper;
 import com.paymentgateway.authorization.config.KafkaConfig;
+import com.paymentgateway.authorization.domain.Merchant;
 import com.paymentgateway.authorization.domain.Payment;
+import com.paymentgateway.authorization.outbox.OutboxRepository;
 import io.opentelemetry.api.trace.Span;
 import io.opentelemetry.api.trace.Tracer;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
 import org.springframework.kafka.core.KafkaTemplate;
 import org.springframework.kafka.support.SendResult;
 import org.springframework.stereotype.Service;
@@ -17,6 +21,9 @@ import java.util.concurrent.CompletableFuture;
 /**
  * Service for publishing payment events to Kafka.
  * Handles event creation, schema validation, and publishing with proper error handling.
+ * With an outbox, events are written to it in the caller's transaction and
+ * published by the {@link com.paymentgateway.authorization.outbox.OutboxDispatcher}
+ * once committed; without one they are sent to Kafka directly.
  */
 @Service
 public class PaymentEventPublisher {
@@ -25,15 +32,27 @@ public class PaymentEventPublisher {
     
     private final KafkaTemplate<String, PaymentEventMessage> kafkaTemplate;
     private final Tracer tracer;
+    private final OutboxRepository outbox;
+    private final ObjectMapper objectMapper;
     
     public PaymentEventPublisher(KafkaTemplate<String, PaymentEventMessage> kafkaTemplate,
                                 Tracer tracer) {
+        this(kafkaTemplate, tracer, null, null);
+    }
+    
+    @Autowired
+    public PaymentEventPublisher(KafkaTemplate<String, PaymentEventMessage> kafkaTemplate,
+                                Tracer tracer,
+                                OutboxRepository outbox,
+                                ObjectMapper objectMapper) {
         this.kafkaTemplate = kafkaTemplate;
         this.tracer = tracer;
+        this.outbox = outbox;
+        this.objectMapper = objectMapper;
     }
     
     /**
-     * Publishes a payment event to Kafka.
+     * Publishes a payment event to Kafka, through the outbox if there is one.
      * Uses the payment ID as the partition key to ensure ordering.
      */
     public void publishPaymentEvent(Payment payment, PaymentEventType eventType) {
@@ -46,23 +65,17 @@ public class PaymentEventPublisher {
             // Use payment ID as partition key to ensure ordering
             String partitionKey = payment.getPaymentId();
             
-            // Publish to Kafka
-            CompletableFuture<SendResult<String, PaymentEventMessage>> future = 
-                    kafkaTemplate.send(KafkaConfig.PAYMENT_EVENTS_TOPIC, partitionKey, event);
+            if (outbox != null) {
+                // Committed with the payment change, published after it
+                outbox.append(event.getEventId(), eventType.name(), partitionKey,
+                        payment.getId(), payment.getMerchantId(), objectMapper.writeValueAsString(event));
+                logger.debug("Queued payment event in outbox: eventId={}, eventType={}, paymentId={}",
+                        event.getEventId(), eventType, payment.getPaymentId());
+                span.addEvent("event_queued");
+                return;
+            }
             
-            future.whenComplete((result, ex) -> {
-                if (ex == null) {
-                    logger.info("Published payment event: eventId={}, eventType={}, paymentId={}, partition={}, offset={}",
-                            event.getEventId(), eventType, payment.getPaymentId(),
-                            result.getRecordMetadata().partition(),
-                            result.getRecordMetadata().offset());
-                    span.addEvent("event_published");
-                } else {
-                    logger.error("Failed to publish payment event: eventId={}, eventType={}, paymentId={}",
-                            event.getEventId(), eventType, payment.getPaymentId(), ex);
-                    span.recordException(ex);
-                }
-            });
+            send(event, partitionKey);
             
         } catch (Exception e) {
             span.recordException(e);
@@ -74,6 +87,68 @@ public class PaymentEventPublisher {
         }
     }
     
+    /**
+     * Publishes a merchant's onboarding status change, through the outbox if
+     * there is one. The dispatcher turns it into a webhook to the merchant.
+     * Uses the merchant ID as the partition key, so a merchant's changes
+     * stay in order.
+     */
+    public void publishMerchantEvent(Merchant merchant, PaymentEventType eventType) {
+        String eventId = "evt_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24);
+        
+        PaymentEventMessage.PaymentEventPayload payload = new PaymentEventMessage.PaymentEventPayload();
+        payload.setMerchantId(merchant.getId().toString());
+        payload.setStatus(merchant.getOnboardingStatus().name());
+        
+        PaymentEventMessage event = new PaymentEventMessage(
+                eventId,
+                eventType,
+                Instant.now(),
+                merchant.getMerchantId(), // correlation ID
+                Span.current().getSpanContext().getTraceId(),
+                payload
+        );
+        String partitionKey = merchant.getMerchantId();
+        
+        if (outbox == null) {
+            send(event, partitionKey);
+            return;
+        }
+        try {
+            outbox.append(eventId, eventType.name(), partitionKey, null, merchant.getId(),
+                    objectMapper.writeValueAsString(event));
+            logger.debug("Queued merchant event in outbox: eventId={}, eventType={}, merchantId={}",
+                    eventId, eventType, merchant.getMerchantId());
+        } catch (Exception e) {
+            logger.error("Error creating merchant event: merchantId={}, eventType={}",
+                    merchant.getMerchantId(), eventType, e);
+            throw new RuntimeException("Failed to publish merchant event", e);
+        }
+    }
+    
+    /**
+     * Sends an event to Kafka, logging the outcome. The outbox dispatcher
+     * waits on the returned future before marking the event published.
+     */
+    public CompletableFuture<SendResult<String, PaymentEventMessage>> send(PaymentEventMessage event,
+                                                                          String partitionKey) {
+        CompletableFuture<SendResult<String, PaymentEventMessage>> future = 
+                kafkaTemplate.send(KafkaConfig.PAYMENT_EVENTS_TOPIC, partitionKey, event);
+        
+        future.whenComplete((result, ex) -> {
+            if (ex == null) {
+                logger.info("Published payment event: eventId={}, eventType={}, paymentId={}, partition={}, offset={}",
+                        event.getEventId(), event.getEventType(), partitionKey,
+                        result.getRecordMetadata().partition(),
+                        result.getRecordMetadata().offset());
+            } else {
+                logger.error("Failed to publish payment event: eventId={}, eventType={}, paymentId={}",
+                        event.getEventId(), event.getEventType(), partitionKey, ex);
+            }
+        });
+        return future;
+    }
+    
     /**
      * Creates a payment event message from a payment entity.
      */
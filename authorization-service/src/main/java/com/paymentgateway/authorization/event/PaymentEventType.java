@@ -7,5 +7,13 @@ public enum PaymentEventType {
     PAYMENT_CAPTURED,
     PAYMENT_CANCELLED,
     PAYMENT_REFUNDED,
-    PAYMENT_FAILED
+    PAYMENT_FAILED,
+    PAYMENT_SETTLED,
+    // Published by the settlement service as a dispute moves through its stages
+    DISPUTE_OPENED,
+    DISPUTE_EVIDENCE_SUBMITTED,
+    DISPUTE_WON,
+    DISPUTE_LOST,
+    // A merchant's onboarding status changed; carries no payment
+    MERCHANT_ONBOARDING_UPDATED
 }
@@ -1,5 +1,13 @@
 package com.paymentgateway.authorization.exception;
 
+import com.paymentgateway.authorization.idempotency.IdempotencyKeyReuseException;
+import com.paymentgateway.authorization.onboarding.OnboardingException;
+import com.paymentgateway.authorization.pagination.InvalidPageTokenException;
+import com.paymentgateway.authorization.resilience.CircuitBreakerOpenException;
+import com.paymentgateway.authorization.screening.ScreeningHoldException;
+import com.paymentgateway.authorization.service.RefundPolicyException;
+import com.paymentgateway.authorization.webhook.WebhookVerificationException;
+import org.springframework.http.HttpHeaders;
 import org.springframework.http.HttpStatus;
 import org.springframework.http.ResponseEntity;
 import org.springframework.validation.FieldError;
@@ -7,6 +15,8 @@ import org.springframework.web.bind.MethodArgumentNotValidException;
 import org.springframework.web.bind.annotation.ControllerAdvice;
 import org.springframework.web.bind.annotation.ExceptionHandler;
 
+import java.time.Duration;
+import java.time.Instant;
 import java.util.HashMap;
 import java.util.Map;
 
@@ -56,4 +66,130 @@ public class GlobalExceptionHandler {
         
         return new ResponseEntity<>(response, HttpStatus.BAD_REQUEST);
     }
+    
+    /**
+     * A list request's page token is malformed or belongs to another query.
+     */
+    @ExceptionHandler(InvalidPageTokenException.class)
+    public ResponseEntity<Map<String, Object>> handleInvalidPageToken(InvalidPageTokenException ex) {
+        
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", Map.of(
+            "code", "INVALID_PAGE_TOKEN",
+            "message", ex.getMessage()
+        ));
+        
+        return new ResponseEntity<>(response, HttpStatus.BAD_REQUEST);
+    }
+    
+    /**
+     * An idempotency key was sent again with a different request. Retrying
+     * cannot succeed; the client must use a new key.
+     */
+    @ExceptionHandler(IdempotencyKeyReuseException.class)
+    public ResponseEntity<Map<String, Object>> handleIdempotencyKeyReuse(IdempotencyKeyReuseException ex) {
+        
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", Map.of(
+            "code", "IDEMPOTENCY_KEY_REUSED",
+            "message", ex.getMessage()
+        ));
+        
+        return new ResponseEntity<>(response, HttpStatus.UNPROCESSABLE_ENTITY);
+    }
+    
+    /**
+     * A refund the merchant's refund policy does not allow, which an
+     * administrator can repeat with overridePolicy, or one beyond the
+     * captured amount, which no one can
+     */
+    @ExceptionHandler(RefundPolicyException.class)
+    public ResponseEntity<Map<String, Object>> handleRefundPolicy(RefundPolicyException ex) {
+        
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", Map.of(
+            "code", ex.getCode(),
+            "message", ex.getMessage()
+        ));
+        
+        HttpStatus status = RefundPolicyException.OVERRIDE_FORBIDDEN.equals(ex.getCode())
+            ? HttpStatus.FORBIDDEN : HttpStatus.UNPROCESSABLE_ENTITY;
+        return new ResponseEntity<>(response, status);
+    }
+    
+    /**
+     * An onboarding step the application's status does not allow (409), a
+     * submission lacking what its MCC requires (422), or a live-mode key
+     * asked for before approval (403)
+     */
+    @ExceptionHandler(OnboardingException.class)
+    public ResponseEntity<Map<String, Object>> handleOnboarding(OnboardingException ex) {
+        
+        Map<String, Object> error = new HashMap<>();
+        error.put("code", ex.getCode());
+        error.put("message", ex.getMessage());
+        if (!ex.getMissing().isEmpty()) {
+            error.put("missing", ex.getMissing());
+        }
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", error);
+        
+        HttpStatus status = OnboardingException.REQUIREMENTS_MISSING.equals(ex.getCode())
+            ? HttpStatus.UNPROCESSABLE_ENTITY
+            : OnboardingException.NOT_APPROVED.equals(ex.getCode()) ? HttpStatus.FORBIDDEN : HttpStatus.CONFLICT;
+        return new ResponseEntity<>(response, status);
+    }
+    
+    /**
+     * A capture or approval held by sanctions screening. An administrator
+     * can release the hit and the request be repeated.
+     */
+    @ExceptionHandler(ScreeningHoldException.class)
+    public ResponseEntity<Map<String, Object>> handleScreeningHold(ScreeningHoldException ex) {
+        
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", Map.of(
+            "code", "SCREENING_HOLD",
+            "message", ex.getMessage()
+        ));
+        
+        return new ResponseEntity<>(response, HttpStatus.CONFLICT);
+    }
+    
+    /**
+     * A dependency's circuit breaker is open: the request was not attempted
+     * and can be retried once the breaker lets calls through again.
+     */
+    @ExceptionHandler(CircuitBreakerOpenException.class)
+    public ResponseEntity<Map<String, Object>> handleCircuitBreakerOpen(CircuitBreakerOpenException ex) {
+        
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", Map.of(
+            "code", "DEPENDENCY_UNAVAILABLE",
+            "message", ex.getMessage()
+        ));
+        
+        ResponseEntity.BodyBuilder builder = ResponseEntity.status(HttpStatus.SERVICE_UNAVAILABLE);
+        if (ex.getRetryAt() != null) {
+            long seconds = Math.max(1, Duration.between(Instant.now(), ex.getRetryAt()).toSeconds());
+            builder.header(HttpHeaders.RETRY_AFTER, String.valueOf(seconds));
+        }
+        return builder.body(response);
+    }
+    
+    /**
+     * A webhook endpoint did not echo its verification challenge. The
+     * merchant can fix the endpoint and verify again.
+     */
+    @ExceptionHandler(WebhookVerificationException.class)
+    public ResponseEntity<Map<String, Object>> handleWebhookVerification(WebhookVerificationException ex) {
+        
+        Map<String, Object> response = new HashMap<>();
+        response.put("error", Map.of(
+            "code", "ENDPOINT_VERIFICATION_FAILED",
+            "message", ex.getMessage()
+        ));
+        
+        return new ResponseEntity<>(response, HttpStatus.UNPROCESSABLE_ENTITY);
+    }
 }
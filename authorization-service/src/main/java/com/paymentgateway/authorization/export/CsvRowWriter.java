@@ -0,0 +1,76 @@
+package com.paymentgateway.authorization.export;
+
+import java.io.BufferedWriter;
+import java.io.IOException;
+import java.io.OutputStream;
+import java.io.OutputStreamWriter;
+import java.io.Writer;
+import java.math.BigDecimal;
+import java.nio.charset.StandardCharsets;
+import java.util.List;
+
+/**
+ * RFC 4180 CSV with a header row of column names. Timestamps are ISO-8601
+ * in UTC, dates ISO-8601, decimals plain, and nulls empty.
+ */
+public class CsvRowWriter implements RowWriter {
+    
+    private final List<ExportColumn> columns;
+    private final Writer writer;
+    private boolean headerWritten;
+    
+    public CsvRowWriter(List<ExportColumn> columns, OutputStream out) {
+        this.columns = columns;
+        this.writer = new BufferedWriter(new OutputStreamWriter(out, StandardCharsets.UTF_8));
+    }
+    
+    @Override
+    public void write(Object[] row) throws IOException {
+        writeHeader();
+        for (int i = 0; i < row.length; i++) {
+            if (i > 0) {
+                writer.write(',');
+            }
+            writer.write(format(row[i]));
+        }
+        writer.write("\r\n");
+    }
+    
+    @Override
+    public void finish() throws IOException {
+        // An empty export still has its header
+        writeHeader();
+        writer.flush();
+    }
+    
+    private void writeHeader() throws IOException {
+        if (headerWritten) {
+            return;
+        }
+        headerWritten = true;
+        for (int i = 0; i < columns.size(); i++) {
+            if (i > 0) {
+                writer.write(',');
+            }
+            writer.write(quote(columns.get(i).getName()));
+        }
+        writer.write("\r\n");
+    }
+    
+    private static String format(Object value) {
+        if (value == null) {
+            return "";
+        }
+        if (value instanceof BigDecimal decimal) {
+            return decimal.toPlainString();
+        }
+        return quote(value.toString());
+    }
+    
+    static String quote(String value) {
+        if (value.indexOf(',') < 0 && value.indexOf('"') < 0 && value.indexOf('\n') < 0 && value.indexOf('\r') < 0) {
+            return value;
+        }
+        return '"' + value.replace("\"", "\"\"") + '"';
+    }
+}
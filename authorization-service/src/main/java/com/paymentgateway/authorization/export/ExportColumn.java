@@ -0,0 +1,53 @@
+package com.paymentgateway.authorization.export;
+
+/**
+ * A column of an export dataset. Every column may be null.
+ */
+public class ExportColumn {
+    
+    /**
+     * Column types, with how each is written to Parquet. Amounts are
+     * DECIMAL(12,2) like the database columns they come from.
+     */
+    public enum Type {
+        STRING,     // BYTE_ARRAY, UTF8
+        DECIMAL,    // INT64, DECIMAL(12,2)
+        INTEGER,    // INT64
+        TIMESTAMP,  // INT64, TIMESTAMP_MILLIS (UTC)
+        DATE        // INT32, DATE
+    }
+    
+    public static final int DECIMAL_PRECISION = 12;
+    public static final int DECIMAL_SCALE = 2;
+    
+    private final String name;
+    private final Type type;
+    
+    public ExportColumn(String name, Type type) {
+        this.name = name;
+        this.type = type;
+    }
+    
+    public static ExportColumn string(String name) {
+        return new ExportColumn(name, Type.STRING);
+    }
+    
+    public static ExportColumn decimal(String name) {
+        return new ExportColumn(name, Type.DECIMAL);
+    }
+    
+    public static ExportColumn integer(String name) {
+        return new ExportColumn(name, Type.INTEGER);
+    }
+    
+    public static ExportColumn timestamp(String name) {
+        return new ExportColumn(name, Type.TIMESTAMP);
+    }
+    
+    public static ExportColumn date(String name) {
+        return new ExportColumn(name, Type.DATE);
+    }
+    
+    public String getName() { return name; }
+    public Type getType() { return type; }
+}
@@ -0,0 +1,76 @@
+package com.paymentgateway.authorization.export;
+
+import java.util.List;
+import java.util.Locale;
+import java.util.Optional;
+
+import static com.paymentgateway.authorization.export.ExportColumn.date;
+import static com.paymentgateway.authorization.export.ExportColumn.decimal;
+import static com.paymentgateway.authorization.export.ExportColumn.integer;
+import static com.paymentgateway.authorization.export.ExportColumn.string;
+import static com.paymentgateway.authorization.export.ExportColumn.timestamp;
+
+/**
+ * Datasets that can be exported, each with a versioned schema. A schema
+ * version never changes once released: adding, removing, renaming or
+ * retyping a column means a new version. No column carries card numbers,
+ * card digits, tokens, cryptograms, billing addresses or PSP references.
+ */
+public enum ExportDataset {
+    
+    TRANSACTIONS("transactions", 1, List.of(
+        string("payment_id"),
+        string("merchant_id"),
+        string("transaction_type"),
+        string("status"),
+        decimal("amount"),
+        string("currency"),
+        string("card_brand"),
+        string("psp_name"),
+        string("decline_code"),
+        string("fraud_status"),
+        string("three_ds_status"),
+        string("channel"),
+        string("initiator"),
+        string("billing_country"),
+        string("original_payment_id"),
+        integer("processing_time_ms"),
+        timestamp("created_at"),
+        timestamp("authorized_at"),
+        timestamp("captured_at"),
+        timestamp("settled_at"))),
+    
+    SETTLEMENTS("settlements", 1, List.of(
+        string("batch_id"),
+        string("merchant_id"),
+        date("settlement_date"),
+        string("batch_status"),
+        string("payment_id"),
+        decimal("gross_amount"),
+        decimal("fee_amount"),
+        decimal("net_amount"),
+        string("currency"),
+        timestamp("created_at")));
+    
+    private final String datasetName;
+    private final int schemaVersion;
+    private final List<ExportColumn> columns;
+    
+    ExportDataset(String datasetName, int schemaVersion, List<ExportColumn> columns) {
+        this.datasetName = datasetName;
+        this.schemaVersion = schemaVersion;
+        this.columns = columns;
+    }
+    
+    public static Optional<ExportDataset> fromName(String name) {
+        try {
+            return Optional.of(valueOf(name.trim().toUpperCase(Locale.ROOT)));
+        } catch (IllegalArgumentException e) {
+            return Optional.empty();
+        }
+    }
+    
+    public String getDatasetName() { return datasetName; }
+    public int getSchemaVersion() { return schemaVersion; }
+    public List<ExportColumn> getColumns() { return columns; }
+}
@@ -0,0 +1,46 @@
+package com.paymentgateway.authorization.export;
+
+import java.io.OutputStream;
+import java.util.Locale;
+import java.util.Map;
+import java.util.Optional;
+
+/**
+ * File formats datasets are exported in
+ */
+public enum ExportFormat {
+    
+    CSV("text/csv", "csv"),
+    PARQUET("application/vnd.apache.parquet", "parquet");
+    
+    private final String contentType;
+    private final String extension;
+    
+    ExportFormat(String contentType, String extension) {
+        this.contentType = contentType;
+        this.extension = extension;
+    }
+    
+    public static Optional<ExportFormat> fromName(String name) {
+        try {
+            return Optional.of(valueOf(name.trim().toUpperCase(Locale.ROOT)));
+        } catch (IllegalArgumentException e) {
+            return Optional.empty();
+        }
+    }
+    
+    /**
+     * A writer of the dataset's rows to out in this format
+     */
+    public RowWriter writer(ExportDataset dataset, OutputStream out) {
+        return switch (this) {
+            case CSV -> new CsvRowWriter(dataset.getColumns(), out);
+            case PARQUET -> new ParquetRowWriter(dataset.getColumns(), out, Map.of(
+                "dataset", dataset.getDatasetName(),
+                "schema_version", String.valueOf(dataset.getSchemaVersion())));
+        };
+    }
+    
+    public String getContentType() { return contentType; }
+    public String getExtension() { return extension; }
+}
@@ -0,0 +1,74 @@
+package com.paymentgateway.authorization.export;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+import java.io.IOException;
+import java.io.OutputStream;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.StandardCopyOption;
+import java.time.LocalDate;
+import java.time.ZoneOffset;
+import java.util.List;
+
+/**
+ * Exports the previous UTC day of every dataset to a directory each night,
+ * laid out as {@code <dataset>/v<schema version>/date=<yyyy-mm-dd>/<dataset>.<format>}
+ * so pipelines can pick files up by partition. Files appear whole: each is
+ * written under a temporary name and then moved into place.
+ */
+@Component
+@ConditionalOnProperty(name = "export.scheduled.enabled", havingValue = "true")
+public class ExportJob {
+    
+    private static final Logger logger = LoggerFactory.getLogger(ExportJob.class);
+    
+    private final ExportService exportService;
+    private final Path directory;
+    private final List<ExportFormat> formats;
+    
+    public ExportJob(ExportService exportService,
+                     @Value("${export.scheduled.directory}") String directory,
+                     @Value("${export.scheduled.formats:csv,parquet}") List<String> formats) {
+        this.exportService = exportService;
+        this.directory = Path.of(directory);
+        this.formats = formats.stream()
+            .map(name -> ExportFormat.fromName(name)
+                .orElseThrow(() -> new IllegalArgumentException("Unknown export format: " + name)))
+            .toList();
+    }
+    
+    public void exportPreviousDay() {
+        exportDay(LocalDate.now(ZoneOffset.UTC).minusDays(1));
+    }
+    
+    /**
+     * Export one UTC day, replacing any files already written for it. A
+     * failed file is logged and the others are still written.
+     */
+    public void exportDay(LocalDate day) {
+        for (ExportDataset dataset : ExportDataset.values()) {
+            for (ExportFormat format : formats) {
+                Path target = directory.resolve(dataset.getDatasetName())
+                    .resolve("v" + dataset.getSchemaVersion())
+                    .resolve("date=" + day)
+                    .resolve(dataset.getDatasetName() + "." + format.getExtension());
+                try {
+                    Files.createDirectories(target.getParent());
+                    Path temp = target.resolveSibling("." + target.getFileName() + ".tmp");
+                    try (OutputStream out = Files.newOutputStream(temp)) {
+                        exportService.export(dataset, format, null, day.atStartOfDay().toInstant(ZoneOffset.UTC),
+                                day.plusDays(1).atStartOfDay().toInstant(ZoneOffset.UTC), out);
+                    }
+                    Files.move(temp, target, StandardCopyOption.REPLACE_EXISTING, StandardCopyOption.ATOMIC_MOVE);
+                } catch (IOException | RuntimeException e) {
+                    logger.error("Scheduled export of {} failed: {}", target, e.getMessage());
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,97 @@
+package com.paymentgateway.authorization.export;
+
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.jdbc.core.RowCallbackHandler;
+import org.springframework.stereotype.Repository;
+
+import java.io.IOException;
+import java.io.UncheckedIOException;
+import java.sql.ResultSet;
+import java.sql.SQLException;
+import java.sql.Timestamp;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * Streams export datasets out of the database a fetch at a time, so an
+ * export of any size holds only one fetch in memory
+ */
+@Repository
+public class ExportRepository {
+    
+    private static final String TRANSACTIONS =
+        "SELECT p.payment_id, m.merchant_id, p.transaction_type, p.status, p.amount, p.currency, " +
+        "p.card_brand, p.psp_name, p.decline_code, p.fraud_status, p.three_ds_status, " +
+        "p.transaction_channel AS channel, p.initiator, p.billing_country, p.original_payment_id, " +
+        "p.processing_time_ms, p.created_at, p.authorized_at, p.captured_at, p.settled_at " +
+        "FROM payments p JOIN merchants m ON m.id = p.merchant_id " +
+        "WHERE p.created_at >= ? AND p.created_at < ?";
+    
+    private static final String SETTLEMENTS =
+        "SELECT b.batch_id, m.merchant_id, b.settlement_date, b.status AS batch_status, p.payment_id, " +
+        "t.gross_amount, t.fee_amount, t.net_amount, t.currency, t.created_at " +
+        "FROM settlement_transactions t JOIN settlement_batches b ON b.id = t.batch_id " +
+        "JOIN payments p ON p.id = t.payment_id JOIN merchants m ON m.id = b.merchant_id " +
+        "WHERE t.created_at >= ? AND t.created_at < ?";
+    
+    private final JdbcTemplate jdbcTemplate;
+    
+    public ExportRepository(JdbcTemplate jdbcTemplate) {
+        // A template of our own so the fetch size applies only to exports;
+        // PostgreSQL streams by fetch only inside a transaction
+        this.jdbcTemplate = new JdbcTemplate(jdbcTemplate.getDataSource());
+        this.jdbcTemplate.setFetchSize(1000);
+    }
+    
+    /**
+     * Write the dataset's rows created in [from, to), oldest first, for one
+     * merchant or, with a null merchant, all of them. Returns the row count.
+     */
+    public long stream(ExportDataset dataset, UUID merchantId, Instant from, Instant to, RowWriter writer) {
+        String sql = switch (dataset) {
+            case TRANSACTIONS -> TRANSACTIONS + (merchantId != null ? " AND p.merchant_id = ?" : "")
+                    + " ORDER BY p.created_at, p.payment_id";
+            case SETTLEMENTS -> SETTLEMENTS + (merchantId != null ? " AND b.merchant_id = ?" : "")
+                    + " ORDER BY t.created_at, b.batch_id, p.payment_id";
+        };
+        List<Object> args = new ArrayList<>(List.of(Timestamp.from(from), Timestamp.from(to)));
+        if (merchantId != null) {
+            args.add(merchantId);
+        }
+        
+        long[] rows = {0};
+        jdbcTemplate.query(sql, (RowCallbackHandler) rs -> {
+            try {
+                writer.write(row(dataset.getColumns(), rs));
+            } catch (IOException e) {
+                throw new UncheckedIOException(e);
+            }
+            rows[0]++;
+        }, args.toArray());
+        return rows[0];
+    }
+    
+    private static Object[] row(List<ExportColumn> columns, ResultSet rs) throws SQLException {
+        Object[] row = new Object[columns.size()];
+        for (int i = 0; i < columns.size(); i++) {
+            String name = columns.get(i).getName();
+            row[i] = switch (columns.get(i).getType()) {
+                case STRING -> rs.getString(name);
+                case DECIMAL -> rs.getBigDecimal(name);
+                case INTEGER -> {
+                    long value = rs.getLong(name);
+                    yield rs.wasNull() ? null : value;
+                }
+                case TIMESTAMP -> {
+                    Timestamp value = rs.getTimestamp(name);
+                    yield value != null ? value.toInstant() : null;
+                }
+                case DATE -> rs.getObject(name, LocalDate.class);
+            };
+        }
+        return row;
+    }
+}
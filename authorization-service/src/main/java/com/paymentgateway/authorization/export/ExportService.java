@@ -0,0 +1,67 @@
+package com.paymentgateway.authorization.export;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.io.IOException;
+import java.io.OutputStream;
+import java.io.UncheckedIOException;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.UUID;
+
+/**
+ * Exports transaction and settlement datasets as CSV or Parquet for
+ * analytics pipelines to be tested against
+ */
+@Service
+public class ExportService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(ExportService.class);
+    
+    private final ExportRepository exportRepository;
+    private final Duration maxRange;
+    
+    public ExportService(ExportRepository exportRepository,
+                         @Value("${export.max-range:31d}") Duration maxRange) {
+        this.exportRepository = exportRepository;
+        this.maxRange = maxRange;
+    }
+    
+    /**
+     * @throws IllegalArgumentException if the range is empty or longer than
+     *         export.max-range
+     */
+    public void checkRange(Instant from, Instant to) {
+        if (!from.isBefore(to)) {
+            throw new IllegalArgumentException("from must be before to");
+        }
+        if (Duration.between(from, to).compareTo(maxRange) > 0) {
+            throw new IllegalArgumentException("Export range may not exceed " + maxRange.toDays() + " days");
+        }
+    }
+    
+    /**
+     * Write the dataset's rows created in [from, to) to out, for one
+     * merchant or, with a null merchant, all of them. Returns the row count.
+     */
+    @Transactional(readOnly = true)
+    public long export(ExportDataset dataset, ExportFormat format, UUID merchantId,
+                       Instant from, Instant to, OutputStream out) throws IOException {
+        checkRange(from, to);
+        RowWriter writer = format.writer(dataset, out);
+        long rows;
+        try {
+            rows = exportRepository.stream(dataset, merchantId, from, to, writer);
+        } catch (UncheckedIOException e) {
+            throw e.getCause();
+        }
+        writer.finish();
+        logger.info("Exported {} {} rows as {} (schema v{}) for {} from {} to {}", rows, dataset.getDatasetName(),
+                format, dataset.getSchemaVersion(), merchantId != null ? merchantId : "all merchants", from, to);
+        return rows;
+    }
+}
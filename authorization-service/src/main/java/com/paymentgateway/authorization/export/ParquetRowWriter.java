@@ -0,0 +1,374 @@
+package com.paymentgateway.authorization.export;
+
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.io.OutputStream;
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+import java.nio.charset.StandardCharsets;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.ArrayDeque;
+import java.util.ArrayList;
+import java.util.Deque;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * A minimal Apache Parquet writer for flat datasets: every column is
+ * OPTIONAL, values are PLAIN encoded and pages are uncompressed, with one
+ * data page per column per row group. That is all an export needs and
+ * keeps the gateway free of the Hadoop dependencies the reference writer
+ * brings. Rows are buffered a row group at a time; the footer carries the
+ * given key-value metadata.
+ */
+public class ParquetRowWriter implements RowWriter {
+    
+    private static final byte[] MAGIC = "PAR1".getBytes(StandardCharsets.US_ASCII);
+    private static final String CREATED_BY = "payment-acquiring-gateway";
+    static final int DEFAULT_ROW_GROUP_SIZE = 10_000;
+    
+    // parquet.thrift enum values
+    private static final int TYPE_INT32 = 1;
+    private static final int TYPE_INT64 = 2;
+    private static final int TYPE_BYTE_ARRAY = 6;
+    private static final int REPETITION_OPTIONAL = 1;
+    private static final int CONVERTED_UTF8 = 0;
+    private static final int CONVERTED_DECIMAL = 5;
+    private static final int CONVERTED_DATE = 6;
+    private static final int CONVERTED_TIMESTAMP_MILLIS = 9;
+    private static final int ENCODING_PLAIN = 0;
+    private static final int ENCODING_RLE = 3;
+    private static final int CODEC_UNCOMPRESSED = 0;
+    private static final int PAGE_DATA = 0;
+    
+    private final List<ExportColumn> columns;
+    private final OutputStream out;
+    private final Map<String, String> metadata;
+    private final int rowGroupSize;
+    private final List<Object[]> buffer = new ArrayList<>();
+    private final List<RowGroup> rowGroups = new ArrayList<>();
+    private long position;
+    private long rowCount;
+    
+    public ParquetRowWriter(List<ExportColumn> columns, OutputStream out, Map<String, String> metadata) {
+        this(columns, out, metadata, DEFAULT_ROW_GROUP_SIZE);
+    }
+    
+    ParquetRowWriter(List<ExportColumn> columns, OutputStream out, Map<String, String> metadata, int rowGroupSize) {
+        this.columns = columns;
+        this.out = out;
+        this.metadata = metadata;
+        this.rowGroupSize = rowGroupSize;
+    }
+    
+    @Override
+    public void write(Object[] row) throws IOException {
+        if (position == 0) {
+            emit(MAGIC);
+        }
+        buffer.add(row);
+        if (buffer.size() >= rowGroupSize) {
+            flushRowGroup();
+        }
+    }
+    
+    @Override
+    public void finish() throws IOException {
+        if (position == 0) {
+            emit(MAGIC);
+        }
+        flushRowGroup();
+        byte[] footer = fileMetaData();
+        emit(footer);
+        emit(littleEndianInt(footer.length));
+        emit(MAGIC);
+        out.flush();
+    }
+    
+    private void flushRowGroup() throws IOException {
+        if (buffer.isEmpty()) {
+            return;
+        }
+        List<ColumnChunk> chunks = new ArrayList<>();
+        for (int c = 0; c < columns.size(); c++) {
+            byte[] page = dataPage(c);
+            byte[] header = pageHeader(page.length, buffer.size());
+            long offset = position;
+            emit(header);
+            emit(page);
+            chunks.add(new ColumnChunk(offset, header.length + page.length));
+        }
+        rowGroups.add(new RowGroup(chunks, buffer.size()));
+        rowCount += buffer.size();
+        buffer.clear();
+    }
+    
+    /**
+     * Definition levels (1 for a value, 0 for null) as a single bit-packed
+     * run, length-prefixed, then the non-null values
+     */
+    private byte[] dataPage(int column) throws IOException {
+        int rows = buffer.size();
+        int groups = (rows + 7) / 8;
+        ByteArrayOutputStream levels = new ByteArrayOutputStream();
+        writeUnsignedVarint(levels, ((long) groups << 1) | 1);
+        byte[] bits = new byte[groups];
+        ByteArrayOutputStream values = new ByteArrayOutputStream();
+        ExportColumn.Type type = columns.get(column).getType();
+        for (int r = 0; r < rows; r++) {
+            Object value = buffer.get(r)[column];
+            if (value != null) {
+                bits[r / 8] |= (byte) (1 << (r % 8));
+                writeValue(values, type, value);
+            }
+        }
+        levels.write(bits);
+        
+        ByteArrayOutputStream page = new ByteArrayOutputStream();
+        page.write(littleEndianInt(levels.size()));
+        levels.writeTo(page);
+        values.writeTo(page);
+        return page.toByteArray();
+    }
+    
+    private static void writeValue(ByteArrayOutputStream out, ExportColumn.Type type, Object value) throws IOException {
+        switch (type) {
+            case STRING -> {
+                byte[] bytes = value.toString().getBytes(StandardCharsets.UTF_8);
+                out.write(littleEndianInt(bytes.length));
+                out.write(bytes);
+            }
+            case DECIMAL -> out.write(littleEndianLong(((BigDecimal) value)
+                    .setScale(ExportColumn.DECIMAL_SCALE, RoundingMode.UNNECESSARY).unscaledValue().longValueExact()));
+            case INTEGER -> out.write(littleEndianLong(((Number) value).longValue()));
+            case TIMESTAMP -> out.write(littleEndianLong(((Instant) value).toEpochMilli()));
+            case DATE -> out.write(littleEndianInt(Math.toIntExact(((LocalDate) value).toEpochDay())));
+        }
+    }
+    
+    private static byte[] pageHeader(int pageSize, int rows) {
+        ThriftCompactWriter thrift = new ThriftCompactWriter();
+        thrift.i32Field(1, PAGE_DATA);
+        thrift.i32Field(2, pageSize);
+        thrift.i32Field(3, pageSize);
+        thrift.structField(5);
+        thrift.i32Field(1, rows);
+        thrift.i32Field(2, ENCODING_PLAIN);
+        thrift.i32Field(3, ENCODING_RLE);
+        thrift.i32Field(4, ENCODING_RLE);
+        thrift.endStruct();
+        thrift.endStruct();
+        return thrift.toByteArray();
+    }
+    
+    private byte[] fileMetaData() {
+        ThriftCompactWriter thrift = new ThriftCompactWriter();
+        thrift.i32Field(1, 1);
+        
+        thrift.listField(2, ThriftCompactWriter.STRUCT, columns.size() + 1);
+        thrift.structElement();
+        thrift.stringField(4, "schema");
+        thrift.i32Field(5, columns.size());
+        thrift.endStruct();
+        for (ExportColumn column : columns) {
+            thrift.structElement();
+            thrift.i32Field(1, physicalType(column.getType()));
+            thrift.i32Field(3, REPETITION_OPTIONAL);
+            thrift.stringField(4, column.getName());
+            switch (column.getType()) {
+                case STRING -> thrift.i32Field(6, CONVERTED_UTF8);
+                case DECIMAL -> {
+                    thrift.i32Field(6, CONVERTED_DECIMAL);
+                    thrift.i32Field(7, ExportColumn.DECIMAL_SCALE);
+                    thrift.i32Field(8, ExportColumn.DECIMAL_PRECISION);
+                }
+                case TIMESTAMP -> thrift.i32Field(6, CONVERTED_TIMESTAMP_MILLIS);
+                case DATE -> thrift.i32Field(6, CONVERTED_DATE);
+                case INTEGER -> { }
+            }
+            thrift.endStruct();
+        }
+        
+        thrift.i64Field(3, rowCount);
+        
+        thrift.listField(4, ThriftCompactWriter.STRUCT, rowGroups.size());
+        for (RowGroup rowGroup : rowGroups) {
+            thrift.structElement();
+            thrift.listField(1, ThriftCompactWriter.STRUCT, columns.size());
+            long totalSize = 0;
+            for (int c = 0; c < columns.size(); c++) {
+                ColumnChunk chunk = rowGroup.chunks.get(c);
+                totalSize += chunk.size;
+                thrift.structElement();
+                thrift.i64Field(2, chunk.offset);
+                thrift.structField(3);
+                thrift.i32Field(1, physicalType(columns.get(c).getType()));
+                thrift.listField(2, ThriftCompactWriter.I32, 2);
+                thrift.i32Element(ENCODING_PLAIN);
+                thrift.i32Element(ENCODING_RLE);
+                thrift.listField(3, ThriftCompactWriter.BINARY, 1);
+                thrift.stringElement(columns.get(c).getName());
+                thrift.i32Field(4, CODEC_UNCOMPRESSED);
+                thrift.i64Field(5, rowGroup.rows);
+                thrift.i64Field(6, chunk.size);
+                thrift.i64Field(7, chunk.size);
+                thrift.i64Field(9, chunk.offset);
+                thrift.endStruct();
+                thrift.endStruct();
+            }
+            thrift.i64Field(2, totalSize);
+            thrift.i64Field(3, rowGroup.rows);
+            thrift.endStruct();
+        }
+        
+        thrift.listField(5, ThriftCompactWriter.STRUCT, metadata.size());
+        for (Map.Entry<String, String> entry : metadata.entrySet()) {
+            thrift.structElement();
+            thrift.stringField(1, entry.getKey());
+            thrift.stringField(2, entry.getValue());
+            thrift.endStruct();
+        }
+        thrift.stringField(6, CREATED_BY);
+        thrift.endStruct();
+        return thrift.toByteArray();
+    }
+    
+    private static int physicalType(ExportColumn.Type type) {
+        return switch (type) {
+            case STRING -> TYPE_BYTE_ARRAY;
+            case DATE -> TYPE_INT32;
+            case DECIMAL, INTEGER, TIMESTAMP -> TYPE_INT64;
+        };
+    }
+    
+    private void emit(byte[] bytes) throws IOException {
+        out.write(bytes);
+        position += bytes.length;
+    }
+    
+    private static byte[] littleEndianInt(int value) {
+        return new byte[] {(byte) value, (byte) (value >>> 8), (byte) (value >>> 16), (byte) (value >>> 24)};
+    }
+    
+    private static byte[] littleEndianLong(long value) {
+        byte[] bytes = new byte[8];
+        for (int i = 0; i < 8; i++) {
+            bytes[i] = (byte) (value >>> (8 * i));
+        }
+        return bytes;
+    }
+    
+    private static void writeUnsignedVarint(ByteArrayOutputStream out, long value) {
+        while ((value & ~0x7FL) != 0) {
+            out.write((int) ((value & 0x7F) | 0x80));
+            value >>>= 7;
+        }
+        out.write((int) value);
+    }
+    
+    private static final class ColumnChunk {
+        private final long offset;
+        private final long size;
+        
+        private ColumnChunk(long offset, long size) {
+            this.offset = offset;
+            this.size = size;
+        }
+    }
+    
+    private static final class RowGroup {
+        private final List<ColumnChunk> chunks;
+        private final long rows;
+        
+        private RowGroup(List<ColumnChunk> chunks, long rows) {
+            this.chunks = chunks;
+            this.rows = rows;
+        }
+    }
+    
+    /**
+     * The subset of Thrift's compact protocol that Parquet metadata needs
+     */
+    static final class ThriftCompactWriter {
+        
+        static final int I32 = 5;
+        static final int I64 = 6;
+        static final int BINARY = 8;
+        static final int LIST = 9;
+        static final int STRUCT = 12;
+        
+        private final ByteArrayOutputStream out = new ByteArrayOutputStream();
+        private final Deque<Integer> lastFieldIds = new ArrayDeque<>();
+        private int lastFieldId;
+        
+        void i32Field(int id, int value) {
+            fieldHeader(I32, id);
+            writeUnsignedVarint(out, Integer.toUnsignedLong((value << 1) ^ (value >> 31)));
+        }
+        
+        void i64Field(int id, long value) {
+            fieldHeader(I64, id);
+            writeUnsignedVarint(out, (value << 1) ^ (value >> 63));
+        }
+        
+        void stringField(int id, String value) {
+            fieldHeader(BINARY, id);
+            stringElement(value);
+        }
+        
+        void structField(int id) {
+            fieldHeader(STRUCT, id);
+            lastFieldIds.push(lastFieldId);
+            lastFieldId = 0;
+        }
+        
+        void listField(int id, int elementType, int size) {
+            fieldHeader(LIST, id);
+            if (size < 15) {
+                out.write((size << 4) | elementType);
+            } else {
+                out.write(0xF0 | elementType);
+                writeUnsignedVarint(out, size);
+            }
+        }
+        
+        void structElement() {
+            lastFieldIds.push(lastFieldId);
+            lastFieldId = 0;
+        }
+        
+        void i32Element(int value) {
+            writeUnsignedVarint(out, Integer.toUnsignedLong((value << 1) ^ (value >> 31)));
+        }
+        
+        void stringElement(String value) {
+            byte[] bytes = value.getBytes(StandardCharsets.UTF_8);
+            writeUnsignedVarint(out, bytes.length);
+            out.write(bytes, 0, bytes.length);
+        }
+        
+        /**
+         * Ends the current struct; the outermost call ends the message
+         */
+        void endStruct() {
+            out.write(0);
+            lastFieldId = lastFieldIds.isEmpty() ? 0 : lastFieldIds.pop();
+        }
+        
+        byte[] toByteArray() {
+            return out.toByteArray();
+        }
+        
+        private void fieldHeader(int type, int id) {
+            int delta = id - lastFieldId;
+            if (delta > 0 && delta <= 15) {
+                out.write((delta << 4) | type);
+            } else {
+                out.write(type);
+                writeUnsignedVarint(out, Integer.toUnsignedLong((id << 1) ^ (id >> 31)));
+            }
+            lastFieldId = id;
+        }
+    }
+}
@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.export;
+
+import java.io.IOException;
+
+/**
+ * Writes a dataset's rows, in column order, in one file format. Values are
+ * String, BigDecimal, Number, Instant or LocalDate according to the column
+ * type, or null.
+ */
+public interface RowWriter {
+    
+    void write(Object[] row) throws IOException;
+    
+    /**
+     * Write anything still buffered and the format's trailer. The underlying
+     * stream is flushed but not closed.
+     */
+    void finish() throws IOException;
+}
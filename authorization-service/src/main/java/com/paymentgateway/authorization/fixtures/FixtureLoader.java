@@ -0,0 +1,50 @@
+package com.paymentgateway.authorization.fixtures;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.ApplicationArguments;
+import org.springframework.boot.ApplicationRunner;
+import org.springframework.stereotype.Component;
+
+import java.io.IOException;
+import java.nio.file.Files;
+import java.nio.file.Path;
+
+/**
+ * Applies the fixtures file named by fixtures.file once the application has
+ * started. A file that cannot be read or applied stops the application, so
+ * an environment never comes up half configured.
+ */
+@Component
+public class FixtureLoader implements ApplicationRunner {
+    
+    private static final Logger logger = LoggerFactory.getLogger(FixtureLoader.class);
+    
+    private final FixtureService fixtureService;
+    private final Path path;
+    
+    public FixtureLoader(FixtureService fixtureService, @Value("${fixtures.file:}") String file) {
+        this.fixtureService = fixtureService;
+        this.path = file == null || file.isBlank() ? null : Path.of(file);
+    }
+    
+    @Override
+    public void run(ApplicationArguments args) {
+        if (path == null) {
+            return;
+        }
+        String yaml;
+        try {
+            yaml = Files.readString(path);
+        } catch (IOException e) {
+            throw new IllegalStateException("Cannot read fixtures from " + path + ": " + e.getMessage(), e);
+        }
+        try {
+            fixtureService.apply(FixtureSet.fromYaml(yaml));
+        } catch (InvalidFixtureException e) {
+            throw new IllegalStateException("Rejected fixtures from " + path + ": " + e.getMessage(), e);
+        }
+        logger.info("Loaded fixtures from {}", path);
+    }
+}
@@ -0,0 +1,224 @@
+package com.paymentgateway.authorization.fixtures;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.PSPConfiguration;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
+import com.paymentgateway.authorization.fixtures.FixtureSet.MerchantFixture;
+import com.paymentgateway.authorization.fixtures.FixtureSet.TestCardFixture;
+import com.paymentgateway.authorization.fixtures.FixtureSet.WebhookFixture;
+import com.paymentgateway.authorization.psp.SimulatedIssuer;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.repository.PSPConfigurationRepository;
+import com.paymentgateway.authorization.repository.WebhookEndpointRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.security.crypto.password.PasswordEncoder;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.nio.charset.StandardCharsets;
+import java.security.MessageDigest;
+import java.util.HashSet;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Brings the gateway in line with a fixtures document. Applying is
+ * idempotent: merchants are matched by merchant ID and webhook endpoints by
+ * URL, and only what differs is written, so the same file can be applied on
+ * every start. Nothing that the document leaves out is removed.
+ */
+@Service
+public class FixtureService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(FixtureService.class);
+    
+    private final MerchantRepository merchantRepository;
+    private final PSPConfigurationRepository pspConfigurationRepository;
+    private final WebhookEndpointRepository endpointRepository;
+    private final PasswordEncoder passwordEncoder;
+    private final SimulatedIssuer simulatedIssuer;
+    
+    public FixtureService(MerchantRepository merchantRepository,
+                          PSPConfigurationRepository pspConfigurationRepository,
+                          WebhookEndpointRepository endpointRepository,
+                          PasswordEncoder passwordEncoder,
+                          SimulatedIssuer simulatedIssuer) {
+        this.merchantRepository = merchantRepository;
+        this.pspConfigurationRepository = pspConfigurationRepository;
+        this.endpointRepository = endpointRepository;
+        this.passwordEncoder = passwordEncoder;
+        this.simulatedIssuer = simulatedIssuer;
+    }
+    
+    /**
+     * Apply a fixtures document, returning how many of each thing were
+     * created or changed
+     *
+     * @throws InvalidFixtureException if a merchant ID belongs to a sandbox
+     *         merchant; nothing is applied then
+     */
+    @Transactional
+    public Map<String, Integer> apply(FixtureSet fixtures) {
+        Map<String, Integer> counts = new LinkedHashMap<>();
+        for (String key : List.of("merchantsCreated", "merchantsUpdated", "apiKeysSet", "pspRoutesSet",
+                "webhookEndpointsCreated", "webhookEndpointsUpdated", "testCards", "rules")) {
+            counts.put(key, 0);
+        }
+        
+        for (MerchantFixture fixture : fixtures.getMerchants()) {
+            applyMerchant(fixture, counts);
+        }
+        
+        // Balances are reset to the declared amount on every apply
+        for (TestCardFixture card : fixtures.getTestCards()) {
+            simulatedIssuer.setBalance(card.getPan(), card.getBalance());
+            if (card.getStreet() != null || card.getPostalCode() != null) {
+                simulatedIssuer.setAddress(card.getPan(), card.getStreet(), card.getPostalCode());
+            }
+            if (!card.getInstallmentPlans().isEmpty()) {
+                simulatedIssuer.setInstallments(card.getPan(), card.getInstallmentPlans(), card.getMaxInstallments());
+            }
+        }
+        counts.put("testCards", fixtures.getTestCards().size());
+        counts.put("rules", fixtures.getRuleCount());
+        
+        logger.info("Applied fixtures: {}", counts);
+        return counts;
+    }
+    
+    private void applyMerchant(MerchantFixture fixture, Map<String, Integer> counts) {
+        Optional<Merchant> existing = merchantRepository.findByMerchantId(fixture.getMerchantId())
+                .or(() -> merchantRepository.findDeletedByMerchantId(fixture.getMerchantId()));
+        Merchant merchant = existing.orElseGet(() -> new Merchant(fixture.getMerchantId(), fixture.getName()));
+        if (merchant.getSandboxId() != null) {
+            throw new InvalidFixtureException("merchant " + fixture.getMerchantId() + ": belongs to a sandbox");
+        }
+        
+        UUID platformId = fixture.getPlatform() == null ? null
+                : merchantRepository.findByMerchantId(fixture.getPlatform()).map(Merchant::getId).orElseThrow(
+                        () -> new InvalidFixtureException("merchant " + fixture.getMerchantId() + ": unknown platform "
+                                + fixture.getPlatform()));
+        
+        boolean changed = existing.isEmpty()
+                || merchant.getDeletedAt() != null
+                || !merchant.getIsActive()
+                || !fixture.getName().equals(merchant.getMerchantName())
+                || !fixture.getMcc().equals(merchant.getMcc())
+                || !fixture.getCountry().equals(merchant.getCountryCode())
+                || !fixture.getCurrency().equals(merchant.getCurrency())
+                || !fixture.getRiskLevel().equals(merchant.getRiskLevel())
+                || !fixture.getRoles().equals(merchant.getRoles())
+                || (fixture.getStandaloneCredits() != null
+                        && !fixture.getStandaloneCredits().equals(merchant.getStandaloneCreditsEnabled()))
+                || (fixture.getAvsPolicy() != null && fixture.getAvsPolicy() != merchant.getAvsPolicy())
+                || (fixture.getDescriptor() != null && !fixture.getDescriptor().equals(merchant.getStatementDescriptor()))
+                || (fixture.getSurchargePercent() != null && (merchant.getSurchargePercent() == null
+                        || fixture.getSurchargePercent().compareTo(merchant.getSurchargePercent()) != 0))
+                || (fixture.getConvenienceFee() != null && (merchant.getConvenienceFee() == null
+                        || fixture.getConvenienceFee().compareTo(merchant.getConvenienceFee()) != 0))
+                || (platformId != null && !platformId.equals(merchant.getPlatformMerchantId()));
+        // A declared merchant is live, even if it was deleted or deactivated
+        merchant.setDeletedAt(null);
+        merchant.setIsActive(true);
+        merchant.setMerchantName(fixture.getName());
+        merchant.setMcc(fixture.getMcc());
+        merchant.setCountryCode(fixture.getCountry());
+        merchant.setCurrency(fixture.getCurrency());
+        merchant.setRiskLevel(fixture.getRiskLevel());
+        merchant.setRoles(new HashSet<>(fixture.getRoles()));
+        if (fixture.getStandaloneCredits() != null) {
+            merchant.setStandaloneCreditsEnabled(fixture.getStandaloneCredits());
+        }
+        if (fixture.getAvsPolicy() != null) {
+            merchant.setAvsPolicy(fixture.getAvsPolicy());
+        }
+        if (fixture.getDescriptor() != null) {
+            merchant.setStatementDescriptor(fixture.getDescriptor());
+        }
+        if (fixture.getSurchargePercent() != null) {
+            merchant.setSurchargePercent(fixture.getSurchargePercent());
+        }
+        if (fixture.getConvenienceFee() != null) {
+            merchant.setConvenienceFee(fixture.getConvenienceFee());
+        }
+        if (platformId != null) {
+            merchant.setPlatformMerchantId(platformId);
+        }
+        
+        // bcrypt salts every hash, so compare rather than re-hash
+        if (fixture.getApiKey() != null && (merchant.getApiKeyHash() == null
+                || !passwordEncoder.matches(fixture.getApiKey(), merchant.getApiKeyHash()))) {
+            merchant.setApiKeyHash(passwordEncoder.encode(fixture.getApiKey()));
+            increment(counts, "apiKeysSet");
+            changed = true;
+        }
+        
+        if (changed) {
+            merchant = merchantRepository.save(merchant);
+            increment(counts, existing.isEmpty() ? "merchantsCreated" : "merchantsUpdated");
+        }
+        
+        if (!fixture.getPsps().isEmpty()) {
+            applyPsps(merchant, fixture.getPsps(), counts);
+        }
+        for (WebhookFixture webhook : fixture.getWebhooks()) {
+            applyWebhook(merchant, webhook, counts);
+        }
+    }
+    
+    private void applyPsps(Merchant merchant, List<String> psps, Map<String, Integer> counts) {
+        List<PSPConfiguration> current = pspConfigurationRepository.findByMerchantIdOrderByPriorityAsc(merchant.getId());
+        boolean same = current.size() == psps.size() && current.stream().allMatch(PSPConfiguration::getIsActive)
+                && current.stream().map(PSPConfiguration::getPspName).toList().equals(psps);
+        if (same) {
+            return;
+        }
+        pspConfigurationRepository.deleteAll(current);
+        for (int i = 0; i < psps.size(); i++) {
+            pspConfigurationRepository.save(new PSPConfiguration(merchant.getId(), psps.get(i), i + 1));
+        }
+        increment(counts, "pspRoutesSet");
+    }
+    
+    /**
+     * Fixture endpoints are active at once: the fixtures file is operator
+     * configuration, so the challenge a merchant-registered endpoint must
+     * answer is skipped
+     */
+    private void applyWebhook(Merchant merchant, WebhookFixture webhook, Map<String, Integer> counts) {
+        Optional<WebhookEndpoint> existing = endpointRepository
+                .findByMerchantIdAndStatusNotOrderByCreatedAtAsc(merchant.getId(), WebhookEndpoint.DISABLED).stream()
+                .filter(endpoint -> endpoint.getUrl().equals(webhook.getUrl()))
+                .findFirst();
+        if (existing.isEmpty()) {
+            WebhookEndpoint endpoint = new WebhookEndpoint(merchant.getId(), webhook.getUrl(), webhook.getSecret());
+            endpoint.setStatus(WebhookEndpoint.ACTIVE);
+            endpointRepository.save(endpoint);
+            increment(counts, "webhookEndpointsCreated");
+            return;
+        }
+        
+        WebhookEndpoint endpoint = existing.get();
+        // Constant-time comparison, as for the secret's other uses
+        if (WebhookEndpoint.ACTIVE.equals(endpoint.getStatus()) && endpoint.getSigningSecret() != null
+                && MessageDigest.isEqual(webhook.getSecret().getBytes(StandardCharsets.UTF_8),
+                    endpoint.getSigningSecret().getBytes(StandardCharsets.UTF_8))) {
+            return;
+        }
+        // The declared secret replaces the current one outright, with no overlap
+        endpoint.setStatus(WebhookEndpoint.ACTIVE);
+        endpoint.setSigningSecret(webhook.getSecret());
+        endpoint.setPreviousSigningSecret(null);
+        endpoint.setPreviousSecretExpiresAt(null);
+        endpointRepository.save(endpoint);
+        increment(counts, "webhookEndpointsUpdated");
+    }
+    
+    private static void increment(Map<String, Integer> counts, String key) {
+        counts.merge(key, 1, Integer::sum);
+    }
+}
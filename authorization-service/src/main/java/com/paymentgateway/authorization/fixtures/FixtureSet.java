@@ -0,0 +1,407 @@
+package com.paymentgateway.authorization.fixtures;
+
+import com.paymentgateway.authorization.domain.AvsPolicy;
+import com.paymentgateway.authorization.domain.InstallmentPlanType;
+import com.paymentgateway.authorization.psp.DescriptorRules;
+import org.yaml.snakeyaml.LoaderOptions;
+import org.yaml.snakeyaml.Yaml;
+import org.yaml.snakeyaml.constructor.SafeConstructor;
+import org.yaml.snakeyaml.error.YAMLException;
+
+import java.math.BigDecimal;
+import java.net.URI;
+import java.util.ArrayList;
+import java.util.HashSet;
+import java.util.LinkedHashSet;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.Set;
+
+/**
+ * A validated fixtures document, declaring the merchants, API keys, webhook
+ * endpoints and test cards an environment starts with:
+ *
+ * <pre>
+ * merchants:
+ *   - merchantId: merchant_demo
+ *     name: Demo Store
+ *     descriptor: DEMOSTORE
+ *     roles: [MERCHANT]
+ *     apiKey: sk_demo_merchant_key
+ *     psps: [STRIPE, ADYEN]
+ *     avsPolicy: DECLINE_NO_MATCH
+ *     surchargePercent: 2.5
+ *   - merchantId: demo_seller
+ *     platform: merchant_demo
+ *     webhooks:
+ *       - url: http://localhost:9000/webhooks
+ *         secret: whsec_demo_secret_1
+ * testCards:
+ *   - pan: "4111111111111111"
+ *     balance: 500.00
+ *     billingAddress:
+ *       street: 1 Main Street
+ *       postalCode: "94105"
+ *     installments:
+ *       plans: [MERCHANT, ISSUER]
+ *       maxCount: 12
+ * rules:
+ *   - name: SIM_DECLINE_MAGIC_AMOUNT
+ *     when: amount = 66.66
+ *     decision: BLOCK
+ * </pre>
+ *
+ * The {@code rules} section is in the fraud detection service's rules file
+ * format and is read by that service; it is only counted here. JSON is
+ * accepted too, being valid YAML.
+ */
+public class FixtureSet {
+    
+    static final Set<String> PSP_NAMES = Set.of("STRIPE", "ADYEN");
+    
+    private final List<MerchantFixture> merchants;
+    private final List<TestCardFixture> testCards;
+    private final int ruleCount;
+    
+    public FixtureSet(List<MerchantFixture> merchants, List<TestCardFixture> testCards, int ruleCount) {
+        this.merchants = List.copyOf(merchants);
+        this.testCards = List.copyOf(testCards);
+        this.ruleCount = ruleCount;
+    }
+    
+    /**
+     * Parses and validates a fixtures document. Any error rejects the whole
+     * document, so a half-valid file never leaves an environment half set up.
+     *
+     * @throws InvalidFixtureException naming the first problem found
+     */
+    public static FixtureSet fromYaml(String yaml) {
+        Object document;
+        try {
+            document = new Yaml(new SafeConstructor(new LoaderOptions())).load(yaml);
+        } catch (YAMLException e) {
+            throw new InvalidFixtureException("invalid YAML: " + e.getMessage());
+        }
+        if (document == null) {
+            return new FixtureSet(List.of(), List.of(), 0);
+        }
+        if (!(document instanceof Map<?, ?> root)) {
+            throw new InvalidFixtureException("expected a mapping at the top level");
+        }
+        
+        List<MerchantFixture> merchants = new ArrayList<>();
+        Set<String> merchantIds = new HashSet<>();
+        List<?> merchantEntries = list(root, "merchants", "");
+        for (int i = 0; i < merchantEntries.size(); i++) {
+            MerchantFixture merchant = parseMerchant("merchant " + (i + 1) + ": ", merchantEntries.get(i));
+            // Platforms are applied before their sub-merchants
+            if (merchant.getPlatform() != null && !merchantIds.contains(merchant.getPlatform())) {
+                throw new InvalidFixtureException("merchant " + merchant.getMerchantId()
+                        + ": 'platform' must name a merchant declared before it");
+            }
+            if (!merchantIds.add(merchant.getMerchantId())) {
+                throw new InvalidFixtureException("merchant " + (i + 1) + ": duplicate merchantId "
+                        + merchant.getMerchantId());
+            }
+            merchants.add(merchant);
+        }
+        
+        List<TestCardFixture> testCards = new ArrayList<>();
+        List<?> cardEntries = list(root, "testCards", "");
+        for (int i = 0; i < cardEntries.size(); i++) {
+            testCards.add(parseTestCard("test card " + (i + 1) + ": ", cardEntries.get(i)));
+        }
+        
+        return new FixtureSet(merchants, testCards, list(root, "rules", "").size());
+    }
+    
+    private static MerchantFixture parseMerchant(String prefix, Object value) {
+        Map<?, ?> entry = mapping(prefix, value);
+        String merchantId = string(entry, "merchantId");
+        if (merchantId == null || !merchantId.matches("[A-Za-z0-9_-]{1,50}")) {
+            throw new InvalidFixtureException(prefix + "'merchantId' is required, up to 50 letters, digits, _ or -");
+        }
+        prefix = "merchant " + merchantId + ": ";
+        
+        MerchantFixture merchant = new MerchantFixture(merchantId);
+        merchant.name = valueOr(string(entry, "name"), merchantId);
+        merchant.mcc = valueOr(string(entry, "mcc"), "5999");
+        merchant.country = valueOr(string(entry, "country"), "US").toUpperCase(Locale.ROOT);
+        merchant.currency = valueOr(string(entry, "currency"), "USD").toUpperCase(Locale.ROOT);
+        merchant.riskLevel = valueOr(string(entry, "riskLevel"), "LOW").toUpperCase(Locale.ROOT);
+        merchant.apiKey = string(entry, "apiKey");
+        merchant.platform = string(entry, "platform");
+        if (merchant.apiKey != null && merchant.apiKey.length() < 16) {
+            throw new InvalidFixtureException(prefix + "'apiKey' must be at least 16 characters");
+        }
+        if (entry.get("standaloneCredits") != null) {
+            if (!(entry.get("standaloneCredits") instanceof Boolean enabled)) {
+                throw new InvalidFixtureException(prefix + "'standaloneCredits' must be true or false");
+            }
+            merchant.standaloneCredits = enabled;
+        }
+        String descriptor = string(entry, "descriptor");
+        if (descriptor != null) {
+            try {
+                merchant.descriptor = DescriptorRules.checkStatic(descriptor);
+            } catch (IllegalArgumentException e) {
+                throw new InvalidFixtureException(prefix + "'descriptor': " + e.getMessage());
+            }
+        }
+        String avsPolicy = string(entry, "avsPolicy");
+        if (avsPolicy != null) {
+            try {
+                merchant.avsPolicy = AvsPolicy.valueOf(avsPolicy.toUpperCase(Locale.ROOT));
+            } catch (IllegalArgumentException e) {
+                throw new InvalidFixtureException(prefix + "unknown avsPolicy " + avsPolicy + ", expected one of "
+                        + List.of(AvsPolicy.values()));
+            }
+        }
+        merchant.surchargePercent = decimal(entry, "surchargePercent", prefix);
+        if (merchant.surchargePercent != null && (merchant.surchargePercent.signum() <= 0
+                || merchant.surchargePercent.compareTo(BigDecimal.TEN) > 0)) {
+            throw new InvalidFixtureException(prefix + "'surchargePercent' must be above 0 and at most 10");
+        }
+        merchant.convenienceFee = decimal(entry, "convenienceFee", prefix);
+        if (merchant.convenienceFee != null && merchant.convenienceFee.signum() < 0) {
+            throw new InvalidFixtureException(prefix + "'convenienceFee' must not be negative");
+        }
+        if (!merchant.mcc.matches("\\d{4}")) {
+            throw new InvalidFixtureException(prefix + "'mcc' must be 4 digits");
+        }
+        if (!merchant.country.matches("[A-Z]{2}") || !merchant.currency.matches("[A-Z]{3}")) {
+            throw new InvalidFixtureException(prefix + "'country' must be ISO alpha-2 and 'currency' ISO 4217");
+        }
+        
+        for (Object role : list(entry, "roles", prefix)) {
+            String name = role.toString().trim().toUpperCase(Locale.ROOT);
+            if (!name.matches("[A-Z_]+")) {
+                throw new InvalidFixtureException(prefix + "invalid role " + role);
+            }
+            merchant.roles.add(name);
+        }
+        if (merchant.roles.isEmpty()) {
+            merchant.roles.add("MERCHANT");
+        }
+        
+        for (Object psp : list(entry, "psps", prefix)) {
+            String name = psp.toString().trim().toUpperCase(Locale.ROOT);
+            if (!PSP_NAMES.contains(name)) {
+                throw new InvalidFixtureException(prefix + "unknown PSP " + psp + ", expected one of " + PSP_NAMES);
+            }
+            if (merchant.psps.contains(name)) {
+                throw new InvalidFixtureException(prefix + "PSP " + name + " listed twice");
+            }
+            merchant.psps.add(name);
+        }
+        
+        Set<String> urls = new HashSet<>();
+        List<?> webhooks = list(entry, "webhooks", prefix);
+        for (int i = 0; i < webhooks.size(); i++) {
+            String webhookPrefix = prefix + "webhook " + (i + 1) + ": ";
+            Map<?, ?> webhook = mapping(webhookPrefix, webhooks.get(i));
+            String url = string(webhook, "url");
+            if (url == null || !isHttpUrl(url)) {
+                throw new InvalidFixtureException(webhookPrefix + "'url' must be an absolute http or https URL");
+            }
+            if (!urls.add(url)) {
+                throw new InvalidFixtureException(webhookPrefix + "duplicate url " + url);
+            }
+            String secret = string(webhook, "secret");
+            if (secret == null || secret.length() < 16) {
+                throw new InvalidFixtureException(webhookPrefix + "'secret' is required, at least 16 characters");
+            }
+            merchant.webhooks.add(new WebhookFixture(url, secret));
+        }
+        return merchant;
+    }
+    
+    private static TestCardFixture parseTestCard(String prefix, Object value) {
+        Map<?, ?> entry = mapping(prefix, value);
+        String pan = string(entry, "pan");
+        if (pan == null || !pan.matches("\\d{12,19}")) {
+            throw new InvalidFixtureException(prefix + "'pan' must be 12 to 19 digits; quote it in YAML");
+        }
+        String balance = string(entry, "balance");
+        if (balance == null) {
+            throw new InvalidFixtureException(prefix + "'balance' is required");
+        }
+        BigDecimal amount;
+        try {
+            amount = new BigDecimal(balance);
+        } catch (NumberFormatException e) {
+            throw new InvalidFixtureException(prefix + "'balance' must be a number");
+        }
+        if (amount.signum() < 0) {
+            throw new InvalidFixtureException(prefix + "'balance' must not be negative");
+        }
+        TestCardFixture card = new TestCardFixture(pan, amount);
+        
+        // The issuer's address book entry for AVS
+        if (entry.get("billingAddress") != null) {
+            Map<?, ?> address = mapping(prefix + "billingAddress: ", entry.get("billingAddress"));
+            card.street = string(address, "street");
+            card.postalCode = string(address, "postalCode");
+            if (card.street == null && card.postalCode == null) {
+                throw new InvalidFixtureException(prefix + "'billingAddress' needs a street or a postalCode");
+            }
+        }
+        
+        // Installment plans the issuer approves on the card
+        if (entry.get("installments") != null) {
+            String installmentsPrefix = prefix + "installments: ";
+            Map<?, ?> installments = mapping(installmentsPrefix, entry.get("installments"));
+            for (Object plan : list(installments, "plans", installmentsPrefix)) {
+                String name = plan.toString().trim().toUpperCase(Locale.ROOT);
+                try {
+                    card.installmentPlans.add(InstallmentPlanType.valueOf(name).name());
+                } catch (IllegalArgumentException e) {
+                    throw new InvalidFixtureException(installmentsPrefix + "unknown plan " + plan + ", expected MERCHANT or ISSUER");
+                }
+            }
+            if (card.installmentPlans.isEmpty()) {
+                throw new InvalidFixtureException(installmentsPrefix + "'plans' is required");
+            }
+            String maxCount = string(installments, "maxCount");
+            if (maxCount == null || !maxCount.matches("\\d{1,2}") || Integer.parseInt(maxCount) < 2) {
+                throw new InvalidFixtureException(installmentsPrefix + "'maxCount' must be between 2 and 99");
+            }
+            card.maxInstallments = Integer.parseInt(maxCount);
+        }
+        return card;
+    }
+    
+    private static boolean isHttpUrl(String url) {
+        try {
+            URI uri = URI.create(url);
+            return uri.getHost() != null && ("https".equals(uri.getScheme()) || "http".equals(uri.getScheme()));
+        } catch (IllegalArgumentException e) {
+            return false;
+        }
+    }
+    
+    private static Map<?, ?> mapping(String prefix, Object value) {
+        if (!(value instanceof Map<?, ?> map)) {
+            throw new InvalidFixtureException(prefix + "expected a mapping");
+        }
+        return map;
+    }
+    
+    private static List<?> list(Map<?, ?> map, String key, String prefix) {
+        Object value = map.get(key);
+        if (value == null) {
+            return List.of();
+        }
+        if (!(value instanceof List<?> list)) {
+            throw new InvalidFixtureException(prefix + "'" + key + "' must be a list");
+        }
+        return list;
+    }
+    
+    private static String string(Map<?, ?> map, String key) {
+        Object value = map.get(key);
+        if (value == null) {
+            return null;
+        }
+        String text = value.toString().trim();
+        return text.isEmpty() ? null : text;
+    }
+    
+    private static BigDecimal decimal(Map<?, ?> map, String key, String prefix) {
+        String value = string(map, key);
+        if (value == null) {
+            return null;
+        }
+        try {
+            return new BigDecimal(value);
+        } catch (NumberFormatException e) {
+            throw new InvalidFixtureException(prefix + "'" + key + "' must be a number");
+        }
+    }
+    
+    private static String valueOr(String value, String fallback) {
+        return value != null ? value : fallback;
+    }
+    
+    public List<MerchantFixture> getMerchants() { return merchants; }
+    public List<TestCardFixture> getTestCards() { return testCards; }
+    public int getRuleCount() { return ruleCount; }
+    
+    public static class MerchantFixture {
+        
+        private final String merchantId;
+        private String name;
+        private String mcc;
+        private String country;
+        private String currency;
+        private String riskLevel;
+        private String apiKey;
+        private Boolean standaloneCredits;
+        private AvsPolicy avsPolicy;
+        private String descriptor;
+        private BigDecimal surchargePercent;
+        private BigDecimal convenienceFee;
+        private String platform;
+        private final Set<String> roles = new LinkedHashSet<>();
+        private final List<String> psps = new ArrayList<>();
+        private final List<WebhookFixture> webhooks = new ArrayList<>();
+        
+        MerchantFixture(String merchantId) {
+            this.merchantId = merchantId;
+        }
+        
+        public String getMerchantId() { return merchantId; }
+        public String getName() { return name; }
+        public String getMcc() { return mcc; }
+        public String getCountry() { return country; }
+        public String getCurrency() { return currency; }
+        public String getRiskLevel() { return riskLevel; }
+        public String getApiKey() { return apiKey; }
+        public Boolean getStandaloneCredits() { return standaloneCredits; }
+        public AvsPolicy getAvsPolicy() { return avsPolicy; }
+        public String getDescriptor() { return descriptor; }
+        public BigDecimal getSurchargePercent() { return surchargePercent; }
+        public BigDecimal getConvenienceFee() { return convenienceFee; }
+        public String getPlatform() { return platform; }
+        public Set<String> getRoles() { return roles; }
+        public List<String> getPsps() { return psps; }
+        public List<WebhookFixture> getWebhooks() { return webhooks; }
+    }
+    
+    public static class WebhookFixture {
+        
+        private final String url;
+        private final String secret;
+        
+        WebhookFixture(String url, String secret) {
+            this.url = url;
+            this.secret = secret;
+        }
+        
+        public String getUrl() { return url; }
+        public String getSecret() { return secret; }
+    }
+    
+    public static class TestCardFixture {
+        
+        private final String pan;
+        private final BigDecimal balance;
+        private String street;
+        private String postalCode;
+        private final Set<String> installmentPlans = new LinkedHashSet<>();
+        private int maxInstallments;
+        
+        TestCardFixture(String pan, BigDecimal balance) {
+            this.pan = pan;
+            this.balance = balance;
+        }
+        
+        public String getPan() { return pan; }
+        public BigDecimal getBalance() { return balance; }
+        public String getStreet() { return street; }
+        public String getPostalCode() { return postalCode; }
+        public Set<String> getInstallmentPlans() { return installmentPlans; }
+        public int getMaxInstallments() { return maxInstallments; }
+    }
+}
@@ -0,0 +1,11 @@
+package com.paymentgateway.authorization.fixtures;
+
+/**
+ * A fixtures document that cannot be applied, with the reason
+ */
+public class InvalidFixtureException extends RuntimeException {
+    
+    public InvalidFixtureException(String message) {
+        super(message);
+    }
+}
@@ -0,0 +1,145 @@
+package com.paymentgateway.authorization.geo;
+
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.util.ArrayList;
+import java.util.LinkedHashSet;
+import java.util.List;
+import java.util.Locale;
+import java.util.Set;
+
+/**
+ * Issuing country, and where known the issuer, of a card by its BIN. The
+ * simulator has no card-scheme BIN file, so ranges are configured; a longer
+ * prefix beats a shorter one, so a few test BINs can be carved out of a
+ * brand-wide default. The table can be replaced while the gateway runs,
+ * from the file named by payment.geo.bin-table-file.
+ */
+@Component
+public class BinTable {
+    
+    // Replaced as a whole, so a lookup sees either the old table or the new
+    // one
+    private volatile List<Range> ranges;
+    
+    /**
+     * @param spec comma-separated {@code BINS:COUNTRY} entries, where BINS is
+     *             a BIN prefix or a {@code LOW-HIGH} range of prefixes of the
+     *             same length, up to 10 digits so test cards can be told
+     *             apart, e.g. {@code 4:US,400005-400006:GB}. An entry may go
+     *             on with {@code :ISSUER:PRODUCT:FUNDING}, FUNDING being
+     *             CREDIT, DEBIT or PREPAID.
+     */
+    public BinTable(@Value("${payment.geo.bin-table:}") String spec) {
+        this.ranges = parse(spec);
+    }
+    
+    /**
+     * Replaces the table once all of its entries are valid; an entry that
+     * is not leaves the current table in place. Returns the entries added
+     * and removed.
+     *
+     * @param spec entries separated by commas or new lines
+     */
+    public synchronized List<String> replace(String spec) {
+        List<Range> parsed = parse(spec);
+        Set<String> before = new LinkedHashSet<>();
+        ranges.forEach(range -> before.add(range.entry));
+        Set<String> after = new LinkedHashSet<>();
+        parsed.forEach(range -> after.add(range.entry));
+        List<String> changes = new ArrayList<>();
+        before.stream().filter(entry -> !after.contains(entry)).forEach(entry -> changes.add("removed BIN table entry " + entry));
+        after.stream().filter(entry -> !before.contains(entry)).forEach(entry -> changes.add("added BIN table entry " + entry));
+        ranges = parsed;
+        return changes;
+    }
+    
+    private static List<Range> parse(String spec) {
+        List<Range> ranges = new ArrayList<>();
+        for (String item : spec.split("[,\\n]")) {
+            if (item.isBlank()) {
+                continue;
+            }
+            String[] parts = item.trim().split(":");
+            String country = parts.length > 1 ? parts[1].trim().toUpperCase(Locale.ROOT) : "";
+            if ((parts.length != 2 && parts.length != 5) || !country.matches("[A-Z]{2}")) {
+                throw new IllegalArgumentException("Invalid BIN table entry: " + item);
+            }
+            CardIssuer issuer = parts.length == 5 ? issuer(item, parts, country) : null;
+            String[] bins = parts[0].split("-");
+            if (bins.length > 2 || !bins[0].matches("\\d{1,10}")
+                    || !bins[bins.length - 1].matches("\\d{" + bins[0].length() + "}")) {
+                throw new IllegalArgumentException("Invalid BIN range in BIN table entry: " + item);
+            }
+            ranges.add(new Range(item.trim(), bins[0], bins[bins.length - 1], country, issuer));
+        }
+        return List.copyOf(ranges);
+    }
+    
+    /**
+     * The country that issued the card, or null if no range covers it
+     */
+    public String issuerCountry(String pan) {
+        Range best = null;
+        for (Range range : ranges) {
+            if (range.matches(pan) && (best == null || range.low.length() > best.low.length())) {
+                best = range;
+            }
+        }
+        return best == null ? null : best.country;
+    }
+    
+    /**
+     * The issuer of the card from the longest range naming one, or null if
+     * none does
+     */
+    public CardIssuer issuer(String pan) {
+        Range best = null;
+        for (Range range : ranges) {
+            if (range.issuer != null && range.matches(pan)
+                    && (best == null || range.low.length() > best.low.length())) {
+                best = range;
+            }
+        }
+        return best == null ? null : best.issuer;
+    }
+    
+    private static CardIssuer issuer(String item, String[] parts, String country) {
+        String name = parts[2].trim();
+        String product = parts[3].trim();
+        if (name.isEmpty() || product.isEmpty()) {
+            throw new IllegalArgumentException("Invalid issuer in BIN table entry: " + item);
+        }
+        try {
+            CardFunding funding = CardFunding.valueOf(parts[4].trim().toUpperCase(Locale.ROOT));
+            return new CardIssuer(name, product, funding, country);
+        } catch (IllegalArgumentException e) {
+            throw new IllegalArgumentException("Invalid funding in BIN table entry: " + item);
+        }
+    }
+    
+    private static final class Range {
+        final String entry;
+        final String low;
+        final String high;
+        final String country;
+        final CardIssuer issuer;
+        
+        Range(String entry, String low, String high, String country, CardIssuer issuer) {
+            this.entry = entry;
+            this.low = low;
+            this.high = high;
+            this.country = country;
+            this.issuer = issuer;
+        }
+        
+        boolean matches(String pan) {
+            if (pan == null || pan.length() < low.length()) {
+                return false;
+            }
+            String prefix = pan.substring(0, low.length());
+            return prefix.compareTo(low) >= 0 && prefix.compareTo(high) <= 0;
+        }
+    }
+}
@@ -0,0 +1,10 @@
+package com.paymentgateway.authorization.geo;
+
+/**
+ * How a card is funded
+ */
+public enum CardFunding {
+    CREDIT,
+    DEBIT,
+    PREPAID
+}
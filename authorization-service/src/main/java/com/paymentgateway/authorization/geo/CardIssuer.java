@@ -0,0 +1,25 @@
+package com.paymentgateway.authorization.geo;
+
+/**
+ * The issuer and product of a BIN range, as the BIN table configures them
+ */
+public final class CardIssuer {
+    
+    private final String name;
+    private final String product;
+    private final CardFunding funding;
+    private final String country;
+    
+    CardIssuer(String name, String product, CardFunding funding, String country) {
+        this.name = name;
+        this.product = product;
+        this.funding = funding;
+        this.country = country;
+    }
+    
+    public String getName() { return name; }
+    // e.g. Visa Signature
+    public String getProduct() { return product; }
+    public CardFunding getFunding() { return funding; }
+    public String getCountry() { return country; }
+}
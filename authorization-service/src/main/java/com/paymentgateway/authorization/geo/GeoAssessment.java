@@ -0,0 +1,34 @@
+package com.paymentgateway.authorization.geo;
+
+/**
+ * Where a card was issued, measured against the merchant's geo rules
+ */
+public final class GeoAssessment {
+    
+    public enum Outcome {
+        // Within the merchant's rules
+        ALLOWED,
+        // Cross-border under a FLAG policy; authorized and sent for review
+        FLAGGED,
+        // Declined before authorization
+        BLOCKED
+    }
+    
+    private final String issuerCountry;
+    private final boolean crossBorder;
+    private final Outcome outcome;
+    private final String declineCode;
+    
+    GeoAssessment(String issuerCountry, boolean crossBorder, Outcome outcome, String declineCode) {
+        this.issuerCountry = issuerCountry;
+        this.crossBorder = crossBorder;
+        this.outcome = outcome;
+        this.declineCode = declineCode;
+    }
+    
+    public String getIssuerCountry() { return issuerCountry; }
+    public boolean isCrossBorder() { return crossBorder; }
+    public Outcome getOutcome() { return outcome; }
+    // Null unless BLOCKED
+    public String getDeclineCode() { return declineCode; }
+}
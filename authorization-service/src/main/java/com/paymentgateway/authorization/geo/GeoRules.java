@@ -0,0 +1,63 @@
+package com.paymentgateway.authorization.geo;
+
+import com.paymentgateway.authorization.domain.CrossBorderPolicy;
+import com.paymentgateway.authorization.domain.Merchant;
+import org.springframework.stereotype.Service;
+
+import java.util.Arrays;
+import java.util.Locale;
+import java.util.Set;
+import java.util.TreeSet;
+import java.util.stream.Collectors;
+
+/**
+ * Applies a merchant's geo rules to a card: cards issued in a blocked
+ * country are declined, and cards issued outside the merchant's country
+ * are cross-border and handled by its {@link CrossBorderPolicy}. A card
+ * the BIN table does not place is neither blocked nor cross-border.
+ */
+@Service
+public class GeoRules {
+    
+    // Decline codes of payments refused before authorization
+    public static final String ISSUER_COUNTRY_BLOCKED = "issuer_country_blocked";
+    public static final String CROSS_BORDER_BLOCKED = "cross_border_not_allowed";
+    
+    private final BinTable binTable;
+    
+    public GeoRules(BinTable binTable) {
+        this.binTable = binTable;
+    }
+    
+    public GeoAssessment assess(Merchant merchant, String pan) {
+        String issuerCountry = binTable.issuerCountry(pan);
+        String merchantCountry = merchant != null ? merchant.getCountryCode() : null;
+        boolean crossBorder = issuerCountry != null && merchantCountry != null
+            && !issuerCountry.equals(merchantCountry);
+        if (issuerCountry != null && merchant != null
+                && blockedCountries(merchant.getBlockedIssuerCountries()).contains(issuerCountry)) {
+            return new GeoAssessment(issuerCountry, crossBorder, GeoAssessment.Outcome.BLOCKED, ISSUER_COUNTRY_BLOCKED);
+        }
+        CrossBorderPolicy policy = merchant != null ? merchant.getCrossBorderPolicy() : CrossBorderPolicy.ALLOW;
+        if (crossBorder && policy == CrossBorderPolicy.BLOCK) {
+            return new GeoAssessment(issuerCountry, true, GeoAssessment.Outcome.BLOCKED, CROSS_BORDER_BLOCKED);
+        }
+        GeoAssessment.Outcome outcome = crossBorder && policy == CrossBorderPolicy.FLAG
+            ? GeoAssessment.Outcome.FLAGGED : GeoAssessment.Outcome.ALLOWED;
+        return new GeoAssessment(issuerCountry, crossBorder, outcome, null);
+    }
+    
+    /**
+     * A merchant's blocked issuing countries parsed from their stored
+     * comma-separated form
+     */
+    public static Set<String> blockedCountries(String stored) {
+        if (stored == null || stored.isBlank()) {
+            return Set.of();
+        }
+        return Arrays.stream(stored.split(","))
+            .map(c -> c.trim().toUpperCase(Locale.ROOT))
+            .filter(c -> !c.isEmpty())
+            .collect(Collectors.toCollection(TreeSet::new));
+    }
+}
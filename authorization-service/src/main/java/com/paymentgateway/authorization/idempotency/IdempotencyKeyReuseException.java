@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.idempotency;
+
+/**
+ * An idempotency key was sent again with a different request. The first
+ * request's result is not returned, since it answers another request.
+ */
+public class IdempotencyKeyReuseException extends RuntimeException {
+    
+    private final String idempotencyKey;
+    
+    public IdempotencyKeyReuseException(String idempotencyKey) {
+        super("Idempotency key " + idempotencyKey + " was already used with a different request");
+        this.idempotencyKey = idempotencyKey;
+    }
+    
+    public String getIdempotencyKey() {
+        return idempotencyKey;
+    }
+}
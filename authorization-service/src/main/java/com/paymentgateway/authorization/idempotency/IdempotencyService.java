@@ -1,18 +1,32 @@
 package com.paymentgateway.authorization.idempotency;
 
 import com.fasterxml.jackson.core.JsonProcessingException;
+import com.fasterxml.jackson.databind.JsonNode;
 import com.fasterxml.jackson.databind.ObjectMapper;
+import com.fasterxml.jackson.databind.node.ObjectNode;
+import io.micrometer.core.instrument.Counter;
+import io.micrometer.core.instrument.MeterRegistry;
+import io.micrometer.core.instrument.simple.SimpleMeterRegistry;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
 import org.springframework.data.redis.core.RedisTemplate;
 import org.springframework.stereotype.Service;
 
+import java.nio.charset.StandardCharsets;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
 import java.time.Duration;
-import java.util.concurrent.TimeUnit;
+import java.util.HexFormat;
+import java.util.UUID;
 
 /**
  * Service for managing idempotency keys to prevent duplicate payment processing.
- * Implements distributed locking and atomic result storage with 24-hour expiration.
+ * Implements distributed locking and atomic result storage in a shared
+ * {@link IdempotencyStore}, with 24-hour expiration by default. A hash of the
+ * first request is kept with the key, so a key reused for a different
+ * request is rejected instead of answered with another payment's result.
  */
 @Service
 public class IdempotencyService {
@@ -20,17 +34,53 @@ public class IdempotencyService {
     private static final Logger logger = LoggerFactory.getLogger(IdempotencyService.class);
     private static final String IDEMPOTENCY_KEY_PREFIX = "idempotency:";
     private static final String LOCK_PREFIX = "idempotency:lock:";
-    private static final Duration IDEMPOTENCY_TTL = Duration.ofHours(24);
+    private static final String REQUEST_PREFIX = "idempotency:request:";
+    private static final Duration DEFAULT_IDEMPOTENCY_TTL = Duration.ofHours(24);
     private static final Duration LOCK_TTL = Duration.ofSeconds(30);
     private static final int MAX_LOCK_ATTEMPTS = 10;
     private static final long LOCK_RETRY_DELAY_MS = 100;
     
-    private final RedisTemplate<String, String> redisTemplate;
+    private final IdempotencyStore store;
     private final ObjectMapper objectMapper;
+    private final Duration idempotencyTtl;
+    private final Counter hits;
+    private final Counter misses;
+    private final Counter keyReuses;
+    private final Counter lockFailures;
     
     public IdempotencyService(RedisTemplate<String, String> redisTemplate, ObjectMapper objectMapper) {
-        this.redisTemplate = redisTemplate;
+        this(new RedisIdempotencyStore(redisTemplate), objectMapper, new SimpleMeterRegistry(), DEFAULT_IDEMPOTENCY_TTL);
+    }
+    
+    @Autowired
+    public IdempotencyService(IdempotencyStore store, ObjectMapper objectMapper, MeterRegistry meterRegistry,
+                              @Value("${idempotency.ttl-hours:24}") long ttlHours) {
+        this(store, objectMapper, meterRegistry, Duration.ofHours(ttlHours));
+    }
+    
+    public IdempotencyService(IdempotencyStore store, ObjectMapper objectMapper, MeterRegistry meterRegistry,
+                              Duration idempotencyTtl) {
+        this.store = store;
         this.objectMapper = objectMapper;
+        this.idempotencyTtl = idempotencyTtl;
+        this.hits = lookupCounter(meterRegistry, "hit");
+        this.misses = lookupCounter(meterRegistry, "miss");
+        this.keyReuses = Counter.builder("idempotency.key.reuse.total")
+            .description("Idempotency keys sent again with a different request")
+            .tag("store", store.name())
+            .register(meterRegistry);
+        this.lockFailures = Counter.builder("idempotency.lock.failures.total")
+            .description("Requests that could not lock their idempotency key")
+            .tag("store", store.name())
+            .register(meterRegistry);
+    }
+    
+    private Counter lookupCounter(MeterRegistry meterRegistry, String result) {
+        return Counter.builder("idempotency.lookups.total")
+            .description("Idempotency key lookups by whether a stored result was found")
+            .tag("store", store.name())
+            .tag("result", result)
+            .register(meterRegistry);
     }
     
     /**
@@ -46,26 +96,29 @@ public class IdempotencyService {
         }
         
         String key = IDEMPOTENCY_KEY_PREFIX + idempotencyKey;
-        String cachedJson = redisTemplate.opsForValue().get(key);
+        String cachedJson = store.get(key);
         
         if (cachedJson != null) {
             try {
                 T result = objectMapper.readValue(cachedJson, resultClass);
                 logger.info("Idempotency key found, returning cached result: key={}", idempotencyKey);
+                hits.increment();
                 return result;
             } catch (JsonProcessingException e) {
                 logger.error("Failed to deserialize cached result for idempotency key: {}", idempotencyKey, e);
                 // If deserialization fails, treat as if key doesn't exist
+                misses.increment();
                 return null;
             }
         }
         
+        misses.increment();
         return null;
     }
     
     /**
      * Acquire a distributed lock for the given idempotency key to prevent concurrent processing.
-     * Uses Redis SET NX (set if not exists) with expiration for distributed locking.
+     * Uses the store's set-if-absent with expiration for distributed locking.
      * 
      * @param idempotencyKey The unique idempotency key
      * @return true if lock was acquired, false otherwise
@@ -79,16 +132,13 @@ public class IdempotencyService {
         String lockValue = Thread.currentThread().getName() + ":" + System.currentTimeMillis();
         
         for (int attempt = 0; attempt < MAX_LOCK_ATTEMPTS; attempt++) {
-            Boolean acquired = redisTemplate.opsForValue()
-                .setIfAbsent(lockKey, lockValue, LOCK_TTL.toMillis(), TimeUnit.MILLISECONDS);
-            
-            if (Boolean.TRUE.equals(acquired)) {
+            if (store.putIfAbsent(lockKey, lockValue, LOCK_TTL)) {
                 logger.debug("Lock acquired for idempotency key: {}", idempotencyKey);
                 return true;
             }
             
             // Check if there's already a cached result (another thread completed processing)
-            if (getExistingResult(idempotencyKey, Object.class) != null) {
+            if (store.get(IDEMPOTENCY_KEY_PREFIX + idempotencyKey) != null) {
                 logger.debug("Result already cached while waiting for lock: {}", idempotencyKey);
                 return false;
             }
@@ -99,12 +149,14 @@ public class IdempotencyService {
             } catch (InterruptedException e) {
                 Thread.currentThread().interrupt();
                 logger.warn("Lock acquisition interrupted for idempotency key: {}", idempotencyKey);
+                lockFailures.increment();
                 return false;
             }
         }
         
         logger.warn("Failed to acquire lock after {} attempts for idempotency key: {}", 
                    MAX_LOCK_ATTEMPTS, idempotencyKey);
+        lockFailures.increment();
         return false;
     }
     
@@ -119,13 +171,13 @@ public class IdempotencyService {
         }
         
         String lockKey = LOCK_PREFIX + idempotencyKey;
-        redisTemplate.delete(lockKey);
+        store.delete(lockKey);
         logger.debug("Lock released for idempotency key: {}", idempotencyKey);
     }
     
     /**
      * Store the result atomically with the idempotency key.
-     * The result will be cached for 24 hours.
+     * The result will be cached for the idempotency TTL, 24 hours by default.
      * 
      * @param idempotencyKey The unique idempotency key
      * @param result The result to cache
@@ -140,9 +192,9 @@ public class IdempotencyService {
             String key = IDEMPOTENCY_KEY_PREFIX + idempotencyKey;
             String resultJson = objectMapper.writeValueAsString(result);
             
-            redisTemplate.opsForValue().set(key, resultJson, IDEMPOTENCY_TTL.toMillis(), TimeUnit.MILLISECONDS);
+            store.put(key, resultJson, idempotencyTtl);
             logger.info("Result stored for idempotency key: key={}, ttl={}h", 
-                       idempotencyKey, IDEMPOTENCY_TTL.toHours());
+                       idempotencyKey, idempotencyTtl.toHours());
         } catch (JsonProcessingException e) {
             logger.error("Failed to serialize result for idempotency key: {}", idempotencyKey, e);
             throw new RuntimeException("Failed to store idempotency result", e);
@@ -161,11 +213,11 @@ public class IdempotencyService {
         }
         
         String key = IDEMPOTENCY_KEY_PREFIX + idempotencyKey;
-        return Boolean.TRUE.equals(redisTemplate.hasKey(key));
+        return store.exists(key);
     }
     
     /**
-     * Delete an idempotency key and its lock (for testing purposes).
+     * Delete an idempotency key, its lock and its request hash (for testing purposes).
      * 
      * @param idempotencyKey The unique idempotency key
      */
@@ -177,8 +229,79 @@ public class IdempotencyService {
         String key = IDEMPOTENCY_KEY_PREFIX + idempotencyKey;
         String lockKey = LOCK_PREFIX + idempotencyKey;
         
-        redisTemplate.delete(key);
-        redisTemplate.delete(lockKey);
+        store.delete(key);
+        store.delete(lockKey);
+        store.delete(REQUEST_PREFIX + idempotencyKey);
         logger.debug("Deleted idempotency key and lock: {}", idempotencyKey);
     }
+    
+    /**
+     * Hash identifying a request, to tell a retry from a different request
+     * sent with the same key. The merchant is part of the hash, so one
+     * merchant's key never returns another merchant's result. Card data is
+     * not hashed: the CVV is left out and only the card number's last four
+     * digits count, so the stored hash reveals nothing about the card.
+     * 
+     * @param merchantId The merchant sending the request
+     * @param request The request body
+     * @return The hex SHA-256 of the merchant and serialized request
+     */
+    public String requestHash(UUID merchantId, Object request) {
+        try {
+            JsonNode tree = objectMapper.valueToTree(request);
+            if (tree instanceof ObjectNode fields) {
+                fields.remove("cvv");
+                JsonNode cardNumber = fields.remove("cardNumber");
+                if (cardNumber != null && cardNumber.isTextual()) {
+                    String pan = cardNumber.asText();
+                    fields.put("cardNumberLast4", pan.substring(Math.max(0, pan.length() - 4)));
+                }
+            }
+            MessageDigest digest = MessageDigest.getInstance("SHA-256");
+            digest.update(String.valueOf(merchantId).getBytes(StandardCharsets.UTF_8));
+            digest.update((byte) 0);
+            digest.update(objectMapper.writeValueAsBytes(tree));
+            return HexFormat.of().formatHex(digest.digest());
+        } catch (JsonProcessingException | NoSuchAlgorithmException e) {
+            throw new IllegalStateException("Failed to hash request for idempotency check", e);
+        }
+    }
+    
+    /**
+     * Reject a request whose hash differs from the one first recorded for
+     * its idempotency key. Keys recorded without a hash are not checked.
+     * 
+     * @param idempotencyKey The unique idempotency key
+     * @param requestHash The hash of the request, from {@link #requestHash}
+     * @throws IdempotencyKeyReuseException if the key was used for another request
+     */
+    public void checkRequest(String idempotencyKey, String requestHash) {
+        if (idempotencyKey == null || idempotencyKey.isBlank() || requestHash == null) {
+            return;
+        }
+        
+        String recorded = store.get(REQUEST_PREFIX + idempotencyKey);
+        if (recorded != null && !MessageDigest.isEqual(recorded.getBytes(StandardCharsets.UTF_8),
+                requestHash.getBytes(StandardCharsets.UTF_8))) {
+            logger.warn("Idempotency key reused with a different request: key={}", idempotencyKey);
+            keyReuses.increment();
+            throw new IdempotencyKeyReuseException(idempotencyKey);
+        }
+    }
+    
+    /**
+     * Record the hash of the request holding the key's lock, for
+     * {@link #checkRequest} to compare retries against. Kept as long as the
+     * result.
+     * 
+     * @param idempotencyKey The unique idempotency key
+     * @param requestHash The hash of the request, from {@link #requestHash}
+     */
+    public void recordRequest(String idempotencyKey, String requestHash) {
+        if (idempotencyKey == null || idempotencyKey.isBlank() || requestHash == null) {
+            return;
+        }
+        
+        store.put(REQUEST_PREFIX + idempotencyKey, requestHash, idempotencyTtl);
+    }
 }
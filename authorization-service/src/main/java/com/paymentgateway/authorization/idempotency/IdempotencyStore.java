@@ -0,0 +1,34 @@
+package com.paymentgateway.authorization.idempotency;
+
+import java.time.Duration;
+
+/**
+ * Shared key-value store behind idempotency keys. Every gateway instance
+ * must use the same store, so a retry landing on another instance still
+ * finds the first attempt's lock and result.
+ */
+public interface IdempotencyStore {
+    
+    /**
+     * Short name of the store, used to tag metrics
+     */
+    String name();
+    
+    /**
+     * @return The value, or null if the key is absent or expired
+     */
+    String get(String key);
+    
+    void put(String key, String value, Duration ttl);
+    
+    /**
+     * Set the key only if it is absent or expired
+     *
+     * @return true if the key was set
+     */
+    boolean putIfAbsent(String key, String value, Duration ttl);
+    
+    boolean exists(String key);
+    
+    void delete(String key);
+}
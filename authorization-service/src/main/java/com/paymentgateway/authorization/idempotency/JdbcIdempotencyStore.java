@@ -0,0 +1,90 @@
+package com.paymentgateway.authorization.idempotency;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.stereotype.Component;
+
+import java.time.Duration;
+import java.util.List;
+
+/**
+ * Idempotency store in the gateway's PostgreSQL database, for deployments
+ * without a shared Redis. Expiry is judged by the database clock; expired
+ * rows are ignored on read, may be taken over by a new lock or result, and
+ * are purged periodically.
+ */
+@Component
+@ConditionalOnProperty(name = "idempotency.store", havingValue = "postgres")
+public class JdbcIdempotencyStore implements IdempotencyStore {
+    
+    private static final Logger logger = LoggerFactory.getLogger(JdbcIdempotencyStore.class);
+    
+    private static final String GET =
+        "SELECT value FROM idempotency_keys WHERE idem_key = ? AND expires_at > NOW()";
+    
+    private static final String PUT =
+        "INSERT INTO idempotency_keys (idem_key, value, expires_at) " +
+        "VALUES (?, ?, NOW() + ? * INTERVAL '1 millisecond') " +
+        "ON CONFLICT (idem_key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at";
+    
+    private static final String PUT_IF_ABSENT =
+        "INSERT INTO idempotency_keys (idem_key, value, expires_at) " +
+        "VALUES (?, ?, NOW() + ? * INTERVAL '1 millisecond') " +
+        "ON CONFLICT (idem_key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at " +
+        "WHERE idempotency_keys.expires_at <= NOW()";
+    
+    private static final String EXISTS =
+        "SELECT COUNT(*) FROM idempotency_keys WHERE idem_key = ? AND expires_at > NOW()";
+    
+    private static final String DELETE = "DELETE FROM idempotency_keys WHERE idem_key = ?";
+    
+    private static final String PURGE_EXPIRED = "DELETE FROM idempotency_keys WHERE expires_at <= NOW()";
+    
+    private final JdbcTemplate jdbcTemplate;
+    
+    public JdbcIdempotencyStore(JdbcTemplate jdbcTemplate) {
+        this.jdbcTemplate = jdbcTemplate;
+    }
+    
+    @Override
+    public String name() {
+        return "postgres";
+    }
+    
+    @Override
+    public String get(String key) {
+        List<String> values = jdbcTemplate.queryForList(GET, String.class, key);
+        return values.isEmpty() ? null : values.get(0);
+    }
+    
+    @Override
+    public void put(String key, String value, Duration ttl) {
+        jdbcTemplate.update(PUT, key, value, ttl.toMillis());
+    }
+    
+    @Override
+    public boolean putIfAbsent(String key, String value, Duration ttl) {
+        return jdbcTemplate.update(PUT_IF_ABSENT, key, value, ttl.toMillis()) == 1;
+    }
+    
+    @Override
+    public boolean exists(String key) {
+        Integer count = jdbcTemplate.queryForObject(EXISTS, Integer.class, key);
+        return count != null && count > 0;
+    }
+    
+    @Override
+    public void delete(String key) {
+        jdbcTemplate.update(DELETE, key);
+    }
+    
+    public int purgeExpired() {
+        int purged = jdbcTemplate.update(PURGE_EXPIRED);
+        if (purged > 0) {
+            logger.info("Purged {} expired idempotency keys", purged);
+        }
+        return purged;
+    }
+}
@@ -0,0 +1,53 @@
+package com.paymentgateway.authorization.idempotency;
+
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.data.redis.core.RedisTemplate;
+import org.springframework.stereotype.Component;
+
+import java.time.Duration;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Idempotency store in Redis, the default. Keys expire through Redis TTLs.
+ */
+@Component
+@ConditionalOnProperty(name = "idempotency.store", havingValue = "redis", matchIfMissing = true)
+public class RedisIdempotencyStore implements IdempotencyStore {
+    
+    private final RedisTemplate<String, String> redisTemplate;
+    
+    public RedisIdempotencyStore(RedisTemplate<String, String> redisTemplate) {
+        this.redisTemplate = redisTemplate;
+    }
+    
+    @Override
+    public String name() {
+        return "redis";
+    }
+    
+    @Override
+    public String get(String key) {
+        return redisTemplate.opsForValue().get(key);
+    }
+    
+    @Override
+    public void put(String key, String value, Duration ttl) {
+        redisTemplate.opsForValue().set(key, value, ttl.toMillis(), TimeUnit.MILLISECONDS);
+    }
+    
+    @Override
+    public boolean putIfAbsent(String key, String value, Duration ttl) {
+        return Boolean.TRUE.equals(
+            redisTemplate.opsForValue().setIfAbsent(key, value, ttl.toMillis(), TimeUnit.MILLISECONDS));
+    }
+    
+    @Override
+    public boolean exists(String key) {
+        return Boolean.TRUE.equals(redisTemplate.hasKey(key));
+    }
+    
+    @Override
+    public void delete(String key) {
+        redisTemplate.delete(key);
+    }
+}
@@ -0,0 +1,129 @@
+package com.paymentgateway.authorization.installment;
+
+import com.paymentgateway.authorization.domain.InstallmentPlanType;
+import com.paymentgateway.authorization.dto.Installments;
+import jakarta.validation.ValidationException;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+
+import java.util.ArrayList;
+import java.util.EnumSet;
+import java.util.List;
+import java.util.Locale;
+import java.util.Set;
+
+/**
+ * Decides which installment plans a payment may be offered. Installments
+ * are a domestic product of the issuers in a few markets, so plans are
+ * configured for BIN ranges and for the merchant's country, each with the
+ * plan types on offer and the most installments allowed. The issuer still
+ * approves or declines the plan on the card.
+ */
+@Service
+public class InstallmentService {
+    
+    private static final String ANY = "*";
+    
+    private final List<Rule> rules = new ArrayList<>();
+    
+    /**
+     * @param spec comma-separated {@code BINS:REGION:PLANS:MAX} rules, where
+     *             BINS is {@code *}, a BIN prefix or a {@code LOW-HIGH} range
+     *             of prefixes, region is a country code or {@code *}, and
+     *             plans are {@code MERCHANT} and {@code ISSUER} joined by
+     *             {@code |}, e.g. {@code *:BR:MERCHANT|ISSUER:12,
+     *             456700-456799:MX:MERCHANT:24}. The most specific rule wins;
+     *             without one, no installments are offered.
+     */
+    public InstallmentService(@Value("${payment.installments.plans:}") String spec) {
+        for (String item : spec.split(",")) {
+            if (item.isBlank()) {
+                continue;
+            }
+            String[] parts = item.trim().toUpperCase(Locale.ROOT).split(":");
+            if (parts.length != 4) {
+                throw new IllegalArgumentException("Invalid installment rule: " + item);
+            }
+            String[] bins = ANY.equals(parts[0]) ? null : parts[0].split("-");
+            if (bins != null && (bins.length > 2 || !bins[0].matches("\\d{1,8}")
+                    || !bins[bins.length - 1].matches("\\d{" + bins[0].length() + "}"))) {
+                throw new IllegalArgumentException("Invalid BIN range in installment rule: " + item);
+            }
+            Set<InstallmentPlanType> plans = EnumSet.noneOf(InstallmentPlanType.class);
+            for (String plan : parts[2].split("\\|")) {
+                try {
+                    plans.add(InstallmentPlanType.valueOf(plan.trim()));
+                } catch (IllegalArgumentException e) {
+                    throw new IllegalArgumentException("Unknown plan type in installment rule: " + item);
+                }
+            }
+            int maxCount = Integer.parseInt(parts[3]);
+            if (maxCount < 2 || maxCount > 99) {
+                throw new IllegalArgumentException("Installment maximum must be between 2 and 99: " + item);
+            }
+            rules.add(new Rule(bins == null ? null : bins[0], bins == null ? null : bins[bins.length - 1],
+                parts[1], plans, maxCount));
+        }
+    }
+    
+    /**
+     * Rejects a plan that is not offered for the card in the merchant's
+     * country, or that has more installments than allowed
+     */
+    public void check(String pan, String country, Installments installments) {
+        Rule rule = rule(pan, country);
+        if (rule == null) {
+            throw new ValidationException("Installments are not offered for this card in " + country);
+        }
+        if (!rule.plans.contains(installments.getPlanType())) {
+            throw new ValidationException(installments.getPlanType() + " installments are not offered for this card in "
+                + country);
+        }
+        if (installments.getCount() > rule.maxCount) {
+            throw new ValidationException("At most " + rule.maxCount + " installments are offered for this card in "
+                + country);
+        }
+    }
+    
+    private Rule rule(String pan, String country) {
+        Rule best = null;
+        for (Rule rule : rules) {
+            if (rule.matches(pan, country) && (best == null || rule.specificity() > best.specificity())) {
+                best = rule;
+            }
+        }
+        return best;
+    }
+    
+    private static final class Rule {
+        final String low;
+        final String high;
+        final String region;
+        final Set<InstallmentPlanType> plans;
+        final int maxCount;
+        
+        Rule(String low, String high, String region, Set<InstallmentPlanType> plans, int maxCount) {
+            this.low = low;
+            this.high = high;
+            this.region = region;
+            this.plans = plans;
+            this.maxCount = maxCount;
+        }
+        
+        boolean matches(String pan, String country) {
+            return (low == null || (pan != null && pan.length() >= low.length() && inRange(pan.substring(0, low.length()))))
+                && (ANY.equals(region) || region.equals(country));
+        }
+        
+        private boolean inRange(String prefix) {
+            return prefix.compareTo(low) >= 0 && prefix.compareTo(high) <= 0;
+        }
+        
+        /**
+         * A BIN range beats a country beats any card anywhere
+         */
+        int specificity() {
+            return (low == null ? 0 : 2) + (ANY.equals(region) ? 0 : 1);
+        }
+    }
+}
@@ -0,0 +1,218 @@
+package com.paymentgateway.authorization.iso20022;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+import com.paymentgateway.authorization.domain.CreditTransferStatus;
+import com.paymentgateway.authorization.repository.CreditTransferRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.math.BigDecimal;
+import java.math.BigInteger;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.time.ZoneOffset;
+import java.util.Currency;
+import java.util.HashSet;
+import java.util.List;
+import java.util.Locale;
+import java.util.Optional;
+import java.util.Set;
+import java.util.UUID;
+import java.util.regex.Pattern;
+
+/**
+ * Ingests ISO 20022 credit transfer messages as account-to-account
+ * transfers, for teams trying account-based rails alongside cards. A
+ * message whose header does not match its transactions is rejected whole;
+ * otherwise each transaction is checked on its own and rejected with an
+ * ISO status reason code, or accepted. An accepted transfer is settled,
+ * credited to the creditor, on its requested execution or interbank
+ * settlement date, at once if that is today or past.
+ */
+@Service
+public class CreditTransferService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(CreditTransferService.class);
+    
+    // ISO 20022 ExternalStatusReason1Code values the simulator uses
+    static final String DUPLICATION = "AM05";
+    static final String NOT_ALLOWED_CURRENCY = "AM03";
+    static final String INVALID_CONTROL_SUM = "AM10";
+    static final String INVALID_AMOUNT = "AM12";
+    static final String INVALID_NUMBER_OF_TRANSACTIONS = "AM18";
+    static final String INVALID_DEBTOR_ACCOUNT = "AC02";
+    static final String INVALID_CREDITOR_ACCOUNT = "AC03";
+    static final String INVALID_DEBTOR_AGENT = "RC03";
+    static final String INVALID_CREDITOR_AGENT = "RC04";
+    
+    private static final Pattern IBAN = Pattern.compile("^[A-Z]{2}\\d{2}[A-Z0-9]{11,30}$");
+    private static final Pattern BIC = Pattern.compile("^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$");
+    private static final BigInteger NINETY_SEVEN = BigInteger.valueOf(97);
+    
+    // Largest amount a transfer can hold (DECIMAL(12,2))
+    private static final BigDecimal MAX_AMOUNT = new BigDecimal("9999999999.99");
+    
+    private final CreditTransferRepository repository;
+    
+    public CreditTransferService(CreditTransferRepository repository) {
+        this.repository = repository;
+    }
+    
+    @Transactional
+    public Iso20022Message ingest(UUID merchantId, String xml) {
+        return ingest(merchantId, xml, LocalDate.now(ZoneOffset.UTC));
+    }
+    
+    /**
+     * Ingests a pain.001 or pacs.008 message as of a day
+     *
+     * @return The message with its transfers, as stored
+     * @throws InvalidIso20022MessageException if the message is rejected whole
+     */
+    @Transactional
+    public Iso20022Message ingest(UUID merchantId, String xml, LocalDate today) {
+        Iso20022Message message = Iso20022Parser.parse(xml);
+        List<CreditTransfer> transfers = message.getTransfers();
+        if (repository.existsByMerchantIdAndMessageId(merchantId, message.getMessageId())) {
+            throw new InvalidIso20022MessageException(DUPLICATION, "Message " + message.getMessageId() + " was already received");
+        }
+        if (message.getNumberOfTransactions() != transfers.size()) {
+            throw new InvalidIso20022MessageException(INVALID_NUMBER_OF_TRANSACTIONS, "GrpHdr/NbOfTxs is "
+                + message.getNumberOfTransactions() + " but the message has " + transfers.size() + " transactions");
+        }
+        if (message.getControlSum() != null) {
+            BigDecimal sum = transfers.stream()
+                .map(CreditTransfer::getAmount)
+                .reduce(BigDecimal.ZERO, BigDecimal::add);
+            if (sum.compareTo(message.getControlSum()) != 0) {
+                throw new InvalidIso20022MessageException(INVALID_CONTROL_SUM, "The control sum is "
+                    + message.getControlSum().toPlainString() + " but the transactions add up to " + sum.toPlainString());
+            }
+        }
+        
+        for (CreditTransfer transfer : transfers) {
+            if (transfer.getAmount().compareTo(MAX_AMOUNT) > 0) {
+                throw new InvalidIso20022MessageException(INVALID_AMOUNT, "Amount " + transfer.getAmount().toPlainString()
+                    + " of " + transfer.getEndToEndId() + " exceeds the simulator's limit of " + MAX_AMOUNT.toPlainString());
+            }
+        }
+        
+        Set<String> endToEndIds = new HashSet<>();
+        int rejected = 0;
+        for (CreditTransfer transfer : transfers) {
+            transfer.setTransferId("ct_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24));
+            transfer.setMerchantId(merchantId);
+            transfer.setDebtorIban(normalizeIban(transfer.getDebtorIban()));
+            transfer.setCreditorIban(normalizeIban(transfer.getCreditorIban()));
+            
+            String reason = check(transfer);
+            if (reason == null && (!endToEndIds.add(transfer.getEndToEndId())
+                    || repository.existsByMerchantIdAndEndToEndIdAndStatusNot(
+                        merchantId, transfer.getEndToEndId(), CreditTransferStatus.REJECTED))) {
+                reason = DUPLICATION;
+            }
+            if (reason != null) {
+                transfer.setStatus(CreditTransferStatus.REJECTED);
+                transfer.setReasonCode(reason);
+                // Kept as received, cut to fit, for the merchant to see what was wrong
+                transfer.setDebtorIban(truncate(transfer.getDebtorIban(), 34));
+                transfer.setCreditorIban(truncate(transfer.getCreditorIban(), 34));
+                transfer.setDebtorAgentBic(truncate(transfer.getDebtorAgentBic(), 11));
+                transfer.setCreditorAgentBic(truncate(transfer.getCreditorAgentBic(), 11));
+                transfer.setCurrency(truncate(transfer.getCurrency(), 3));
+                rejected++;
+            } else if (transfer.getExecutionDate() == null || !transfer.getExecutionDate().isAfter(today)) {
+                settle(transfer);
+            }
+        }
+        repository.saveAll(transfers);
+        logger.info("Ingested {} {} of merchant {}: {} transactions, {} rejected", message.getType().getMessageName(),
+            message.getMessageId(), merchantId, transfers.size(), rejected);
+        return message;
+    }
+    
+    public Optional<CreditTransfer> find(UUID merchantId, String transferId) {
+        return repository.findByTransferId(transferId).filter(transfer -> transfer.getMerchantId().equals(merchantId));
+    }
+    
+    /**
+     * Settle accepted transfers whose execution date has come
+     *
+     * @return The number settled
+     */
+    @Transactional
+    public int settleDue() {
+        List<CreditTransfer> due = repository.findByStatusAndExecutionDateLessThanEqual(
+            CreditTransferStatus.ACCEPTED, LocalDate.now(ZoneOffset.UTC));
+        for (CreditTransfer transfer : due) {
+            settle(transfer);
+        }
+        repository.saveAll(due);
+        if (!due.isEmpty()) {
+            logger.info("Settled {} credit transfers due today", due.size());
+        }
+        return due.size();
+    }
+    
+    /**
+     * Whether an IBAN is well formed with valid check digits (ISO 13616)
+     */
+    public static boolean isValidIban(String iban) {
+        if (iban == null || !IBAN.matcher(iban).matches()) {
+            return false;
+        }
+        String rearranged = iban.substring(4) + iban.substring(0, 4);
+        StringBuilder digits = new StringBuilder();
+        for (char c : rearranged.toCharArray()) {
+            digits.append(Character.getNumericValue(c));
+        }
+        return new BigInteger(digits.toString()).mod(NINETY_SEVEN).intValue() == 1;
+    }
+    
+    /**
+     * The reason a transaction is rejected, or null if it is not
+     */
+    private static String check(CreditTransfer transfer) {
+        if (!isValidIban(transfer.getCreditorIban())) {
+            return INVALID_CREDITOR_ACCOUNT;
+        }
+        if (transfer.getDebtorIban() != null && !isValidIban(transfer.getDebtorIban())) {
+            return INVALID_DEBTOR_ACCOUNT;
+        }
+        if (transfer.getCreditorAgentBic() != null && !BIC.matcher(transfer.getCreditorAgentBic()).matches()) {
+            return INVALID_CREDITOR_AGENT;
+        }
+        if (transfer.getDebtorAgentBic() != null && !BIC.matcher(transfer.getDebtorAgentBic()).matches()) {
+            return INVALID_DEBTOR_AGENT;
+        }
+        int fractionDigits;
+        try {
+            fractionDigits = Currency.getInstance(transfer.getCurrency()).getDefaultFractionDigits();
+        } catch (IllegalArgumentException | NullPointerException e) {
+            return NOT_ALLOWED_CURRENCY;
+        }
+        // Amounts are kept to two decimals
+        if (fractionDigits < 0 || fractionDigits > 2) {
+            return NOT_ALLOWED_CURRENCY;
+        }
+        if (transfer.getAmount().signum() <= 0 || transfer.getAmount().stripTrailingZeros().scale() > fractionDigits) {
+            return INVALID_AMOUNT;
+        }
+        return null;
+    }
+    
+    private static void settle(CreditTransfer transfer) {
+        transfer.setStatus(CreditTransferStatus.SETTLED);
+        transfer.setSettledAt(Instant.now());
+    }
+    
+    private static String truncate(String value, int length) {
+        return value != null && value.length() > length ? value.substring(0, length) : value;
+    }
+    
+    private static String normalizeIban(String iban) {
+        return iban == null ? null : iban.replace(" ", "").toUpperCase(Locale.ROOT);
+    }
+}
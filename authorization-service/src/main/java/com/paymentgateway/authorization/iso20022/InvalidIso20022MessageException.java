@@ -0,0 +1,16 @@
+package com.paymentgateway.authorization.iso20022;
+
+/**
+ * An ISO 20022 message rejected whole, with the ISO status reason code
+ */
+public class InvalidIso20022MessageException extends RuntimeException {
+    
+    private final String reasonCode;
+    
+    public InvalidIso20022MessageException(String reasonCode, String message) {
+        super(message);
+        this.reasonCode = reasonCode;
+    }
+    
+    public String getReasonCode() { return reasonCode; }
+}
@@ -0,0 +1,39 @@
+package com.paymentgateway.authorization.iso20022;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+
+import java.math.BigDecimal;
+import java.util.List;
+
+/**
+ * A parsed credit transfer message: its group header and a transfer per
+ * transaction, not yet checked
+ */
+public class Iso20022Message {
+    
+    private final Iso20022MessageType type;
+    private final String messageId;
+    private final int numberOfTransactions;
+    private final BigDecimal controlSum;
+    private final List<CreditTransfer> transfers;
+    
+    public Iso20022Message(Iso20022MessageType type, String messageId, int numberOfTransactions,
+                           BigDecimal controlSum, List<CreditTransfer> transfers) {
+        this.type = type;
+        this.messageId = messageId;
+        this.numberOfTransactions = numberOfTransactions;
+        this.controlSum = controlSum;
+        this.transfers = transfers;
+    }
+    
+    public Iso20022MessageType getType() { return type; }
+    public String getMessageId() { return messageId; }
+    public int getNumberOfTransactions() { return numberOfTransactions; }
+    
+    /**
+     * The header's CtrlSum, or for pacs.008 its TtlIntrBkSttlmAmt; null if absent
+     */
+    public BigDecimal getControlSum() { return controlSum; }
+    
+    public List<CreditTransfer> getTransfers() { return transfers; }
+}
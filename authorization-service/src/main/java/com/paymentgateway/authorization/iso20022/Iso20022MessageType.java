@@ -0,0 +1,24 @@
+package com.paymentgateway.authorization.iso20022;
+
+/**
+ * ISO 20022 credit transfer messages the gateway ingests, by the element
+ * under their Document
+ */
+public enum Iso20022MessageType {
+    
+    // Customer to bank: a payer's instruction to its bank
+    PAIN_001("pain.001", "CstmrCdtTrfInitn"),
+    // Bank to bank: the transfer between the debtor's and creditor's agents
+    PACS_008("pacs.008", "FIToFICstmrCdtTrf");
+    
+    private final String messageName;
+    private final String rootElement;
+    
+    Iso20022MessageType(String messageName, String rootElement) {
+        this.messageName = messageName;
+        this.rootElement = rootElement;
+    }
+    
+    public String getMessageName() { return messageName; }
+    public String getRootElement() { return rootElement; }
+}
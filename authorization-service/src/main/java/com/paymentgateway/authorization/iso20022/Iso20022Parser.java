@@ -0,0 +1,245 @@
+package com.paymentgateway.authorization.iso20022;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+import org.w3c.dom.Document;
+import org.w3c.dom.Element;
+import org.w3c.dom.Node;
+import org.xml.sax.InputSource;
+import org.xml.sax.SAXException;
+
+import javax.xml.XMLConstants;
+import javax.xml.parsers.DocumentBuilder;
+import javax.xml.parsers.DocumentBuilderFactory;
+import javax.xml.parsers.ParserConfigurationException;
+import java.io.IOException;
+import java.io.StringReader;
+import java.math.BigDecimal;
+import java.time.LocalDate;
+import java.time.format.DateTimeParseException;
+import java.util.ArrayList;
+import java.util.List;
+
+/**
+ * Reads pain.001 and pacs.008 credit transfer messages. Elements are
+ * matched by local name, so every version of the messages in use
+ * (pain.001.001.03 to .09, pacs.008.001.02 to .08) is read alike; where
+ * versions differ, as BIC against BICFI or ReqdExctnDt against
+ * ReqdExctnDt/Dt, both are accepted. Document type declarations are
+ * refused.
+ */
+public final class Iso20022Parser {
+    
+    // ISO 20022 reason codes of messages rejected whole
+    static final String INVALID_FILE_FORMAT = "FF01";
+    
+    // Max35Text identifiers and Max140Text names and remittance information
+    private static final int MAX_ID = 35;
+    private static final int MAX_TEXT = 140;
+    
+    private Iso20022Parser() {}
+    
+    public static Iso20022Message parse(String xml) {
+        Element document = read(xml).getDocumentElement();
+        if (!"Document".equals(document.getLocalName())) {
+            throw invalid("root element must be Document");
+        }
+        Element message = firstChildElement(document);
+        if (message == null) {
+            throw invalid("Document is empty");
+        }
+        for (Iso20022MessageType type : Iso20022MessageType.values()) {
+            if (type.getRootElement().equals(message.getLocalName())) {
+                return type == Iso20022MessageType.PAIN_001 ? parsePain001(message) : parsePacs008(message);
+            }
+        }
+        throw invalid("unsupported message " + message.getLocalName() + "; expected pain.001 or pacs.008");
+    }
+    
+    private static Iso20022Message parsePain001(Element message) {
+        Element header = required(message, "GrpHdr");
+        List<CreditTransfer> transfers = new ArrayList<>();
+        for (Element paymentInfo : children(message, "PmtInf")) {
+            String paymentInfoId = limited(requiredText(paymentInfo, "PmtInfId"), MAX_ID, "PmtInfId");
+            Element executionDate = child(paymentInfo, "ReqdExctnDt");
+            Element debtorAgent = child(paymentInfo, "DbtrAgt");
+            for (Element tx : children(paymentInfo, "CdtTrfTxInf")) {
+                CreditTransfer transfer = transfer(Iso20022MessageType.PAIN_001, tx, required(tx, "Amt", "InstdAmt"));
+                transfer.setPaymentInfoId(paymentInfoId);
+                transfer.setExecutionDate(date(executionDate));
+                transfer.setDebtorName(limited(text(paymentInfo, "Dbtr", "Nm"), MAX_TEXT, "Dbtr/Nm"));
+                transfer.setDebtorIban(text(paymentInfo, "DbtrAcct", "Id", "IBAN"));
+                transfer.setDebtorAgentBic(bic(debtorAgent));
+                transfers.add(transfer);
+            }
+        }
+        return message(Iso20022MessageType.PAIN_001, header, decimal(child(header, "CtrlSum")), transfers);
+    }
+    
+    private static Iso20022Message parsePacs008(Element message) {
+        Element header = required(message, "GrpHdr");
+        LocalDate headerSettlementDate = date(child(header, "IntrBkSttlmDt"));
+        List<CreditTransfer> transfers = new ArrayList<>();
+        for (Element tx : children(message, "CdtTrfTxInf")) {
+            CreditTransfer transfer = transfer(Iso20022MessageType.PACS_008, tx, required(tx, "IntrBkSttlmAmt"));
+            String txId = limited(text(tx, "PmtId", "TxId"), MAX_ID, "TxId");
+            transfer.setTransactionId(txId != null ? txId : limited(text(tx, "PmtId", "UETR"), 36, "UETR"));
+            LocalDate settlementDate = date(child(tx, "IntrBkSttlmDt"));
+            transfer.setExecutionDate(settlementDate != null ? settlementDate : headerSettlementDate);
+            transfer.setDebtorName(limited(text(tx, "Dbtr", "Nm"), MAX_TEXT, "Dbtr/Nm"));
+            transfer.setDebtorIban(text(tx, "DbtrAcct", "Id", "IBAN"));
+            transfer.setDebtorAgentBic(bic(child(tx, "DbtrAgt")));
+            transfers.add(transfer);
+        }
+        return message(Iso20022MessageType.PACS_008, header, decimal(child(header, "TtlIntrBkSttlmAmt")), transfers);
+    }
+    
+    private static Iso20022Message message(Iso20022MessageType type, Element header, BigDecimal controlSum,
+                                           List<CreditTransfer> transfers) {
+        String messageId = limited(requiredText(header, "MsgId"), MAX_ID, "MsgId");
+        int count;
+        try {
+            count = Integer.parseInt(requiredText(header, "NbOfTxs"));
+        } catch (NumberFormatException e) {
+            throw invalid("GrpHdr/NbOfTxs must be a number");
+        }
+        if (transfers.isEmpty()) {
+            throw invalid("the message has no CdtTrfTxInf");
+        }
+        for (CreditTransfer transfer : transfers) {
+            transfer.setMessageId(messageId);
+        }
+        return new Iso20022Message(type, messageId, count, controlSum, transfers);
+    }
+    
+    /**
+     * The fields pain.001 and pacs.008 transactions share
+     */
+    private static CreditTransfer transfer(Iso20022MessageType type, Element tx, Element amount) {
+        CreditTransfer transfer = new CreditTransfer();
+        transfer.setMessageType(type);
+        transfer.setInstructionId(limited(text(tx, "PmtId", "InstrId"), MAX_ID, "InstrId"));
+        transfer.setEndToEndId(limited(requiredText(tx, "PmtId", "EndToEndId"), MAX_ID, "EndToEndId"));
+        transfer.setAmount(decimal(amount));
+        transfer.setCurrency(amount.getAttribute("Ccy"));
+        transfer.setCreditorName(limited(text(tx, "Cdtr", "Nm"), MAX_TEXT, "Cdtr/Nm"));
+        transfer.setCreditorIban(text(tx, "CdtrAcct", "Id", "IBAN"));
+        transfer.setCreditorAgentBic(bic(child(tx, "CdtrAgt")));
+        transfer.setRemittanceInfo(limited(text(tx, "RmtInf", "Ustrd"), MAX_TEXT, "RmtInf/Ustrd"));
+        return transfer;
+    }
+    
+    private static Document read(String xml) {
+        try {
+            DocumentBuilderFactory factory = DocumentBuilderFactory.newInstance();
+            factory.setNamespaceAware(true);
+            factory.setFeature("http://apache.org/xml/features/disallow-doctype-decl", true);
+            factory.setFeature(XMLConstants.FEATURE_SECURE_PROCESSING, true);
+            factory.setXIncludeAware(false);
+            factory.setExpandEntityReferences(false);
+            DocumentBuilder builder = factory.newDocumentBuilder();
+            builder.setErrorHandler(null);
+            return builder.parse(new InputSource(new StringReader(xml)));
+        } catch (SAXException | IOException e) {
+            throw invalid("not well-formed XML: " + e.getMessage());
+        } catch (ParserConfigurationException e) {
+            throw new IllegalStateException(e);
+        }
+    }
+    
+    private static String bic(Element agent) {
+        String bic = text(agent, "FinInstnId", "BICFI");
+        return bic != null ? bic : text(agent, "FinInstnId", "BIC");
+    }
+    
+    private static LocalDate date(Element element) {
+        if (element == null) {
+            return null;
+        }
+        // From pain.001.001.08, ReqdExctnDt holds a Dt or a DtTm
+        Element date = child(element, "Dt");
+        Element dateTime = child(element, "DtTm");
+        String value = (date != null ? date : dateTime != null ? dateTime : element).getTextContent().trim();
+        try {
+            return LocalDate.parse(value.length() > 10 ? value.substring(0, 10) : value);
+        } catch (DateTimeParseException e) {
+            throw invalid(element.getLocalName() + " must be an ISO date");
+        }
+    }
+    
+    private static BigDecimal decimal(Element element) {
+        if (element == null) {
+            return null;
+        }
+        try {
+            return new BigDecimal(element.getTextContent().trim());
+        } catch (NumberFormatException e) {
+            throw invalid(element.getLocalName() + " must be a decimal");
+        }
+    }
+    
+    private static Element child(Element parent, String... path) {
+        Element element = parent;
+        for (String name : path) {
+            if (element == null) {
+                return null;
+            }
+            List<Element> matches = children(element, name);
+            element = matches.isEmpty() ? null : matches.get(0);
+        }
+        return element;
+    }
+    
+    private static Element required(Element parent, String... path) {
+        Element element = child(parent, path);
+        if (element == null) {
+            throw invalid(parent.getLocalName() + "/" + String.join("/", path) + " is required");
+        }
+        return element;
+    }
+    
+    private static String text(Element parent, String... path) {
+        Element element = child(parent, path);
+        if (element == null || element.getTextContent().isBlank()) {
+            return null;
+        }
+        return element.getTextContent().trim();
+    }
+    
+    private static String requiredText(Element parent, String... path) {
+        String value = text(parent, path);
+        if (value == null) {
+            throw invalid(parent.getLocalName() + "/" + String.join("/", path) + " is required");
+        }
+        return value;
+    }
+    
+    private static String limited(String value, int max, String name) {
+        if (value != null && value.length() > max) {
+            throw invalid(name + " is longer than " + max + " characters");
+        }
+        return value;
+    }
+    
+    private static List<Element> children(Element parent, String name) {
+        List<Element> elements = new ArrayList<>();
+        for (Node node = parent.getFirstChild(); node != null; node = node.getNextSibling()) {
+            if (node instanceof Element element && name.equals(element.getLocalName())) {
+                elements.add(element);
+            }
+        }
+        return elements;
+    }
+    
+    private static Element firstChildElement(Element parent) {
+        for (Node node = parent.getFirstChild(); node != null; node = node.getNextSibling()) {
+            if (node instanceof Element element) {
+                return element;
+            }
+        }
+        return null;
+    }
+    
+    private static InvalidIso20022MessageException invalid(String message) {
+        return new InvalidIso20022MessageException(INVALID_FILE_FORMAT, message);
+    }
+}
@@ -0,0 +1,64 @@
+package com.paymentgateway.authorization.networktoken;
+
+/**
+ * The result of checking the cryptogram presented with a network token,
+ * with the ISO 8583 response code a failure declines with
+ */
+public final class CryptogramCheck {
+    
+    // ISO 8583 response codes 82, 14, 62 and 91: negative CAM, dCVV, iCVV or
+    // CVV results, which networks use for failed token cryptograms; invalid
+    // card number; restricted card; issuer or switch inoperative
+    public static final String CRYPTOGRAM_FAILURE = "82";
+    public static final String INVALID_CARD = "14";
+    public static final String RESTRICTED_CARD = "62";
+    public static final String SWITCH_UNAVAILABLE = "91";
+    
+    public enum Outcome {
+        VALID(null, null),
+        // The network token came without a cryptogram
+        MISSING(CRYPTOGRAM_FAILURE, "Network token presented without a cryptogram"),
+        INVALID(CRYPTOGRAM_FAILURE, "Token cryptogram validation failed"),
+        // The token service has no such network token for the merchant
+        UNKNOWN_TOKEN(INVALID_CARD, "Unknown network token"),
+        SUSPENDED(RESTRICTED_CARD, "Network token suspended"),
+        // The cryptogram could not be checked
+        UNAVAILABLE(SWITCH_UNAVAILABLE, "Token service unavailable");
+        
+        private final String declineCode;
+        private final String message;
+        
+        Outcome(String declineCode, String message) {
+            this.declineCode = declineCode;
+            this.message = message;
+        }
+    }
+    
+    private final Outcome outcome;
+    private final String reason;
+    
+    private CryptogramCheck(Outcome outcome, String reason) {
+        this.outcome = outcome;
+        this.reason = reason;
+    }
+    
+    public static CryptogramCheck of(Outcome outcome) {
+        return new CryptogramCheck(outcome, null);
+    }
+    
+    /**
+     * @param reason The token service's explanation, e.g. "already used"
+     */
+    public static CryptogramCheck of(Outcome outcome, String reason) {
+        return new CryptogramCheck(outcome, reason == null || reason.isBlank() ? null : reason);
+    }
+    
+    public Outcome getOutcome() { return outcome; }
+    public boolean isValid() { return outcome == Outcome.VALID; }
+    // Null if VALID
+    public String getDeclineCode() { return outcome.declineCode; }
+    
+    public String getMessage() {
+        return reason != null ? outcome.message + ": " + reason : outcome.message;
+    }
+}
@@ -0,0 +1,16 @@
+package com.paymentgateway.authorization.networktoken;
+
+/**
+ * Validates the cryptogram presented with a network token against the token
+ * service that issued it, for a transaction of the merchant
+ */
+public interface CryptogramValidator {
+    
+    /**
+     * The outcome of the check: VALID, INVALID, UNKNOWN_TOKEN or SUSPENDED.
+     * Failing to reach the token service throws.
+     *
+     * @param amount The transaction amount in minor units
+     */
+    CryptogramCheck validate(String networkToken, String merchantId, long amount, String currency, byte[] cryptogram);
+}
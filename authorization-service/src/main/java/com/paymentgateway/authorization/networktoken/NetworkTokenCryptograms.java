@@ -0,0 +1,88 @@
+package com.paymentgateway.authorization.networktoken;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.lang.Nullable;
+import org.springframework.stereotype.Service;
+
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+import java.util.ArrayList;
+import java.util.Base64;
+import java.util.Currency;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * Requires a valid cryptogram with every network token presented for
+ * authorization. Network tokens are told from PANs by their token BIN
+ * ranges, which default to the tokenization service's simulated ones, and
+ * their base64 cryptograms are validated by the token service. Without a
+ * validator network tokens cannot be checked and are declined.
+ */
+@Service
+public class NetworkTokenCryptograms {
+    
+    private static final Logger logger = LoggerFactory.getLogger(NetworkTokenCryptograms.class);
+    
+    private final List<String> bins = new ArrayList<>();
+    private final CryptogramValidator validator;
+    
+    /**
+     * @param binSpec comma-separated BIN prefixes of network tokens
+     */
+    @Autowired
+    public NetworkTokenCryptograms(@Value("${payment.network-tokens.bins:489537,520473,374245,601174}") String binSpec,
+                                   @Nullable CryptogramValidator validator) {
+        for (String bin : binSpec.split(",")) {
+            if (bin.isBlank()) {
+                continue;
+            }
+            if (!bin.trim().matches("\\d{1,10}")) {
+                throw new IllegalArgumentException("Invalid network token BIN: " + bin);
+            }
+            bins.add(bin.trim());
+        }
+        this.validator = validator;
+    }
+    
+    public boolean isNetworkToken(String cardNumber) {
+        return cardNumber != null && bins.stream().anyMatch(cardNumber::startsWith);
+    }
+    
+    /**
+     * Checks the cryptogram presented with a card number, or returns null if
+     * the card number is not a network token
+     *
+     * @param amount The amount the cryptogram was generated for
+     */
+    public CryptogramCheck check(String cardNumber, String cryptogram, UUID merchantId, BigDecimal amount,
+                                 String currency) {
+        if (!isNetworkToken(cardNumber)) {
+            return null;
+        }
+        if (cryptogram == null || cryptogram.isBlank()) {
+            return CryptogramCheck.of(CryptogramCheck.Outcome.MISSING);
+        }
+        byte[] bytes;
+        try {
+            bytes = Base64.getDecoder().decode(cryptogram);
+        } catch (IllegalArgumentException e) {
+            return CryptogramCheck.of(CryptogramCheck.Outcome.INVALID, "not base64");
+        }
+        if (validator == null) {
+            return CryptogramCheck.of(CryptogramCheck.Outcome.UNAVAILABLE, "no token service configured");
+        }
+        int fractionDigits = Math.max(Currency.getInstance(currency).getDefaultFractionDigits(), 0);
+        long minor = amount.movePointRight(fractionDigits)
+            .setScale(0, RoundingMode.HALF_UP).longValueExact();
+        try {
+            return validator.validate(cardNumber, merchantId.toString(), minor, currency, bytes);
+        } catch (RuntimeException e) {
+            logger.error("Token cryptogram could not be validated: {}", e.getMessage());
+            return CryptogramCheck.of(CryptogramCheck.Outcome.UNAVAILABLE);
+        }
+    }
+}
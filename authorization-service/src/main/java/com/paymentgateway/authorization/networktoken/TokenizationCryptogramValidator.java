@@ -0,0 +1,95 @@
+package com.paymentgateway.authorization.networktoken;
+
+import com.google.protobuf.ByteString;
+import com.paymentgateway.api.tokenization.v2.TokenizationServiceGrpc;
+import com.paymentgateway.api.tokenization.v2.ValidateTokenCryptogramRequest;
+import com.paymentgateway.api.tokenization.v2.ValidateTokenCryptogramResponse;
+import io.grpc.Channel;
+import io.grpc.ClientInterceptors;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Metadata;
+import io.grpc.StatusRuntimeException;
+import io.grpc.stub.MetadataUtils;
+import jakarta.annotation.PreDestroy;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Validates network token cryptograms with the tokenization service's v2
+ * ValidateTokenCryptogram, which checks them under the HSM key they were
+ * generated with
+ */
+@Component
+@ConditionalOnProperty(name = "payment.network-tokens.tokenization-address")
+public class TokenizationCryptogramValidator implements CryptogramValidator {
+    
+    private static final Logger logger = LoggerFactory.getLogger(TokenizationCryptogramValidator.class);
+    
+    private static final long DEADLINE_MS = 2000;
+    
+    private final ManagedChannel managedChannel;
+    private final Channel channel;
+    
+    public TokenizationCryptogramValidator(@Value("${payment.network-tokens.tokenization-address}") String address,
+                                           @Value("${payment.network-tokens.api-key:}") String apiKey) {
+        this.managedChannel = ManagedChannelBuilder.forTarget(address).usePlaintext().build();
+        if (apiKey.isBlank()) {
+            this.channel = managedChannel;
+        } else {
+            Metadata headers = new Metadata();
+            headers.put(Metadata.Key.of("authorization", Metadata.ASCII_STRING_MARSHALLER), "Bearer " + apiKey);
+            this.channel = ClientInterceptors.intercept(managedChannel, MetadataUtils.newAttachHeadersInterceptor(headers));
+        }
+        logger.info("Network token cryptograms are validated by the tokenization service at {}", address);
+    }
+    
+    @Override
+    public CryptogramCheck validate(String networkToken, String merchantId, long amount, String currency,
+                                    byte[] cryptogram) {
+        ValidateTokenCryptogramRequest request = ValidateTokenCryptogramRequest.newBuilder()
+            .setNetworkToken(networkToken)
+            .setMerchantId(merchantId)
+            .setAmount(amount)
+            .setCurrency(currency)
+            .setCryptogram(ByteString.copyFrom(cryptogram))
+            .build();
+        ValidateTokenCryptogramResponse response;
+        try {
+            response = TokenizationServiceGrpc.newBlockingStub(channel)
+                .withDeadlineAfter(DEADLINE_MS, TimeUnit.MILLISECONDS)
+                .validateTokenCryptogram(request);
+        } catch (StatusRuntimeException e) {
+            return switch (e.getStatus().getCode()) {
+                // The service refuses a cryptogram of the wrong length
+                case INVALID_ARGUMENT -> CryptogramCheck.of(CryptogramCheck.Outcome.INVALID,
+                    e.getStatus().getDescription());
+                case NOT_FOUND -> CryptogramCheck.of(CryptogramCheck.Outcome.UNKNOWN_TOKEN);
+                // Also raised by a service without a cryptogram key, which
+                // is an outage
+                case FAILED_PRECONDITION -> {
+                    String description = String.valueOf(e.getStatus().getDescription());
+                    if (!description.contains("suspended")) {
+                        throw e;
+                    }
+                    yield CryptogramCheck.of(CryptogramCheck.Outcome.SUSPENDED);
+                }
+                default -> throw e;
+            };
+        }
+        if (response.getValid()) {
+            return CryptogramCheck.of(CryptogramCheck.Outcome.VALID);
+        }
+        return CryptogramCheck.of(CryptogramCheck.Outcome.INVALID, response.getReason());
+    }
+    
+    @PreDestroy
+    public void close() {
+        managedChannel.shutdown();
+    }
+}
@@ -0,0 +1,31 @@
+package com.paymentgateway.authorization.onboarding;
+
+import java.util.List;
+
+/**
+ * An onboarding step the merchant's application does not allow: a
+ * transition out of its current status, or a submission that lacks
+ * required fields or documents
+ */
+public class OnboardingException extends IllegalStateException {
+    
+    public static final String INVALID_TRANSITION = "INVALID_ONBOARDING_TRANSITION";
+    public static final String REQUIREMENTS_MISSING = "ONBOARDING_REQUIREMENTS_MISSING";
+    public static final String NOT_APPROVED = "ONBOARDING_NOT_APPROVED";
+    
+    private final String code;
+    private final List<String> missing;
+    
+    public OnboardingException(String code, String message) {
+        this(code, message, List.of());
+    }
+    
+    public OnboardingException(String code, String message, List<String> missing) {
+        super(message);
+        this.code = code;
+        this.missing = missing;
+    }
+    
+    public String getCode() { return code; }
+    public List<String> getMissing() { return missing; }
+}
@@ -0,0 +1,97 @@
+package com.paymentgateway.authorization.onboarding;
+
+import com.paymentgateway.authorization.domain.MerchantOnboarding;
+
+import java.util.ArrayList;
+import java.util.LinkedHashSet;
+import java.util.List;
+import java.util.Set;
+
+/**
+ * The business details and KYC documents an application must hold before
+ * it can be submitted for review. Every merchant provides the base set;
+ * high-risk categories need more: a licence for gambling, money transfer,
+ * quasi-cash, tobacco and pharmacy merchants, and a website with a refund
+ * policy for card-not-present direct marketing.
+ */
+public final class OnboardingRequirements {
+    
+    public static final String LEGAL_NAME = "legal_name";
+    public static final String REGISTRATION_NUMBER = "registration_number";
+    public static final String TAX_ID = "tax_id";
+    public static final String BUSINESS_ADDRESS = "business_address";
+    public static final String REPRESENTATIVE_NAME = "representative_name";
+    public static final String WEBSITE = "website";
+    public static final String REFUND_POLICY_URL = "refund_policy_url";
+    public static final String LICENSE_NUMBER = "license_number";
+    
+    public static final String CERTIFICATE_OF_INCORPORATION = "CERTIFICATE_OF_INCORPORATION";
+    public static final String REPRESENTATIVE_ID = "REPRESENTATIVE_ID";
+    public static final String PROOF_OF_ADDRESS = "PROOF_OF_ADDRESS";
+    public static final String BANK_STATEMENT = "BANK_STATEMENT";
+    public static final String LICENSE = "LICENSE";
+    
+    /** Every field an application may hold */
+    public static final Set<String> FIELDS = Set.of(LEGAL_NAME, REGISTRATION_NUMBER, TAX_ID,
+        BUSINESS_ADDRESS, REPRESENTATIVE_NAME, WEBSITE, REFUND_POLICY_URL, LICENSE_NUMBER);
+    
+    /** Every document type an application may hold */
+    public static final Set<String> DOCUMENT_TYPES = Set.of(CERTIFICATE_OF_INCORPORATION,
+        REPRESENTATIVE_ID, PROOF_OF_ADDRESS, BANK_STATEMENT, LICENSE);
+    
+    // 4829 money transfer, 5912 drug stores, 5993 tobacco, 6051 quasi-cash,
+    // 7995 gambling
+    private static final Set<String> LICENSED_MCCS = Set.of("4829", "5912", "5993", "6051", "7995");
+    
+    // 5966 outbound telemarketing, 5967 inbound teleservices, 5968
+    // continuity and subscription merchants
+    private static final Set<String> DIRECT_MARKETING_MCCS = Set.of("5966", "5967", "5968");
+    
+    private final Set<String> fields;
+    private final Set<String> documents;
+    
+    private OnboardingRequirements(Set<String> fields, Set<String> documents) {
+        this.fields = fields;
+        this.documents = documents;
+    }
+    
+    /**
+     * The requirements of a merchant category code
+     */
+    public static OnboardingRequirements forMcc(String mcc) {
+        Set<String> fields = new LinkedHashSet<>(List.of(LEGAL_NAME, REGISTRATION_NUMBER, TAX_ID,
+            BUSINESS_ADDRESS, REPRESENTATIVE_NAME));
+        Set<String> documents = new LinkedHashSet<>(List.of(CERTIFICATE_OF_INCORPORATION,
+            REPRESENTATIVE_ID, PROOF_OF_ADDRESS, BANK_STATEMENT));
+        if (LICENSED_MCCS.contains(mcc)) {
+            fields.add(LICENSE_NUMBER);
+            documents.add(LICENSE);
+        }
+        if (DIRECT_MARKETING_MCCS.contains(mcc)) {
+            fields.add(WEBSITE);
+            fields.add(REFUND_POLICY_URL);
+        }
+        return new OnboardingRequirements(fields, documents);
+    }
+    
+    public Set<String> getFields() { return fields; }
+    public Set<String> getDocuments() { return documents; }
+    
+    /**
+     * The fields and documents the application still lacks, fields first
+     */
+    public List<String> missing(MerchantOnboarding onboarding) {
+        List<String> missing = new ArrayList<>();
+        for (String field : fields) {
+            if (!onboarding.getFields().containsKey(field)) {
+                missing.add(field);
+            }
+        }
+        for (String document : documents) {
+            if (!onboarding.getDocuments().containsKey(document)) {
+                missing.add(document);
+            }
+        }
+        return missing;
+    }
+}
@@ -0,0 +1,309 @@
+package com.paymentgateway.authorization.onboarding;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.MerchantOnboarding;
+import com.paymentgateway.authorization.domain.OnboardingDocument;
+import com.paymentgateway.authorization.domain.OnboardingStatus;
+import com.paymentgateway.authorization.domain.ScreeningSubjectType;
+import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import com.paymentgateway.authorization.repository.MerchantOnboardingRepository;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.screening.ScreeningMatch;
+import com.paymentgateway.authorization.screening.ScreeningService;
+import com.paymentgateway.authorization.screening.ScreeningSubject;
+import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.security.SecureRandom;
+import java.time.Clock;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+
+/**
+ * KYC onboarding of new merchants. An applicant gets a DRAFT merchant with a
+ * test-mode API key, fills in the business details and uploads the
+ * documents its MCC requires, and submits them for review. An
+ * administrator reviews and approves or rejects the application; an
+ * approved merchant goes LIVE by taking its live-mode API key. The
+ * business and its representative are screened on submission, and a
+ * screening hit blocks approval until it is released. Every
+ * status change is published as a MERCHANT_ONBOARDING_UPDATED event, which
+ * reaches the merchant as a webhook.
+ */
+@Service
+public class OnboardingService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(OnboardingService.class);
+    private static final SecureRandom random = new SecureRandom();
+    private static final String ID_ALPHABET = "abcdefghijklmnopqrstuvwxyz0123456789";
+    
+    /**
+     * A merchant that has just applied, with the only copy of its test-mode
+     * API key
+     */
+    public static class Application {
+        
+        private final Merchant merchant;
+        private final MerchantOnboarding onboarding;
+        private final String apiKey;
+        
+        public Application(Merchant merchant, MerchantOnboarding onboarding, String apiKey) {
+            this.merchant = merchant;
+            this.onboarding = onboarding;
+            this.apiKey = apiKey;
+        }
+        
+        public Merchant getMerchant() { return merchant; }
+        public MerchantOnboarding getOnboarding() { return onboarding; }
+        public String getApiKey() { return apiKey; }
+    }
+    
+    private final MerchantRepository merchantRepository;
+    private final MerchantOnboardingRepository onboardingRepository;
+    private final MerchantAuthenticationService authenticationService;
+    private final PaymentEventPublisher eventPublisher;
+    private final ScreeningService screeningService;
+    private final Clock clock;
+    
+    @Autowired
+    public OnboardingService(MerchantRepository merchantRepository,
+                             MerchantOnboardingRepository onboardingRepository,
+                             MerchantAuthenticationService authenticationService,
+                             PaymentEventPublisher eventPublisher,
+                             ScreeningService screeningService) {
+        this(merchantRepository, onboardingRepository, authenticationService, eventPublisher, screeningService,
+                Clock.systemUTC());
+    }
+    
+    public OnboardingService(MerchantRepository merchantRepository,
+                             MerchantOnboardingRepository onboardingRepository,
+                             MerchantAuthenticationService authenticationService,
+                             PaymentEventPublisher eventPublisher,
+                             ScreeningService screeningService,
+                             Clock clock) {
+        this.merchantRepository = merchantRepository;
+        this.onboardingRepository = onboardingRepository;
+        this.authenticationService = authenticationService;
+        this.eventPublisher = eventPublisher;
+        this.screeningService = screeningService;
+        this.clock = clock;
+    }
+    
+    /**
+     * Create a DRAFT merchant for an applicant
+     */
+    @Transactional
+    public Application apply(String merchantName, String mcc, String countryCode, String currency) {
+        String merchantId = newMerchantId();
+        while (merchantRepository.existsByMerchantId(merchantId)) {
+            merchantId = newMerchantId();
+        }
+        
+        Merchant merchant = new Merchant(merchantId, merchantName.trim());
+        merchant.setMcc(mcc);
+        merchant.setCountryCode(countryCode);
+        merchant.setCurrency(currency);
+        merchant.setRiskLevel("LOW");
+        merchant.setOnboardingStatus(OnboardingStatus.DRAFT);
+        merchant.addRole("MERCHANT");
+        
+        // Saves the merchant with the key's hash
+        String apiKey = authenticationService.createApiKey(merchant);
+        MerchantOnboarding onboarding = new MerchantOnboarding(merchant.getId());
+        onboarding.setCreatedAt(clock.instant());
+        onboarding.setUpdatedAt(clock.instant());
+        onboarding = onboardingRepository.save(onboarding);
+        
+        logger.info("Merchant {} applied for onboarding (MCC {})", merchantId, mcc);
+        eventPublisher.publishMerchantEvent(merchant, PaymentEventType.MERCHANT_ONBOARDING_UPDATED);
+        return new Application(merchant, onboarding, apiKey);
+    }
+    
+    public Optional<Merchant> findMerchant(String merchantId) {
+        return merchantRepository.findByMerchantId(merchantId);
+    }
+    
+    /**
+     * The merchant's application; empty for merchants created outside
+     * onboarding
+     */
+    public Optional<MerchantOnboarding> find(Merchant merchant) {
+        return onboardingRepository.findById(merchant.getId());
+    }
+    
+    public List<Merchant> listByStatus(OnboardingStatus status) {
+        return merchantRepository.findByOnboardingStatusOrderByMerchantIdAsc(status);
+    }
+    
+    public OnboardingRequirements requirements(Merchant merchant) {
+        return OnboardingRequirements.forMcc(merchant.getMcc());
+    }
+    
+    /**
+     * Set business details of a DRAFT application; a blank value removes
+     * the field
+     */
+    @Transactional
+    public MerchantOnboarding updateDetails(Merchant merchant, Map<String, String> fields) {
+        MerchantOnboarding onboarding = draft(merchant);
+        for (Map.Entry<String, String> field : fields.entrySet()) {
+            if (!OnboardingRequirements.FIELDS.contains(field.getKey())) {
+                throw new IllegalArgumentException("Unknown onboarding field: " + field.getKey());
+            }
+            if (field.getValue() == null || field.getValue().isBlank()) {
+                onboarding.getFields().remove(field.getKey());
+            } else {
+                onboarding.getFields().put(field.getKey(), field.getValue().trim());
+            }
+        }
+        onboarding.setUpdatedAt(clock.instant());
+        return onboardingRepository.save(onboarding);
+    }
+    
+    /**
+     * Record a document of a DRAFT application, replacing an earlier upload
+     * of the same type
+     */
+    @Transactional
+    public MerchantOnboarding addDocument(Merchant merchant, String documentType, String reference) {
+        if (!OnboardingRequirements.DOCUMENT_TYPES.contains(documentType)) {
+            throw new IllegalArgumentException("Unknown document type: " + documentType);
+        }
+        MerchantOnboarding onboarding = draft(merchant);
+        onboarding.getDocuments().put(documentType, new OnboardingDocument(reference.trim(), clock.instant()));
+        onboarding.setUpdatedAt(clock.instant());
+        return onboardingRepository.save(onboarding);
+    }
+    
+    /**
+     * Submit a DRAFT application that holds everything its MCC requires,
+     * screening the business and its representative
+     */
+    @Transactional
+    public MerchantOnboarding submit(Merchant merchant) {
+        MerchantOnboarding onboarding = draft(merchant);
+        List<String> missing = requirements(merchant).missing(onboarding);
+        if (!missing.isEmpty()) {
+            throw new OnboardingException(OnboardingException.REQUIREMENTS_MISSING,
+                    "Application is missing " + String.join(", ", missing), missing);
+        }
+        onboarding.setSubmittedAt(clock.instant());
+        screen(merchant, onboarding);
+        return transition(merchant, onboarding, OnboardingStatus.DOCUMENTS_SUBMITTED);
+    }
+    
+    /**
+     * Take a submitted application into review
+     */
+    @Transactional
+    public MerchantOnboarding startReview(Merchant merchant, String reviewer) {
+        MerchantOnboarding onboarding = application(merchant);
+        onboarding.setReviewedBy(reviewer);
+        return transition(merchant, onboarding, OnboardingStatus.UNDER_REVIEW);
+    }
+    
+    /**
+     * Approve an application under review that screening has cleared
+     */
+    @Transactional
+    public MerchantOnboarding approve(Merchant merchant, String reviewer) {
+        MerchantOnboarding onboarding = application(merchant);
+        if (merchant.getOnboardingStatus() == OnboardingStatus.UNDER_REVIEW) {
+            screeningService.checkMerchantCleared(merchant);
+        }
+        onboarding.setReviewedBy(reviewer);
+        onboarding.setDecidedAt(clock.instant());
+        return transition(merchant, onboarding, OnboardingStatus.APPROVED);
+    }
+    
+    @Transactional
+    public MerchantOnboarding reject(Merchant merchant, String reviewer, String reason) {
+        MerchantOnboarding onboarding = application(merchant);
+        onboarding.setReviewedBy(reviewer);
+        onboarding.setDecidedAt(clock.instant());
+        onboarding.setRejectionReason(reason);
+        return transition(merchant, onboarding, OnboardingStatus.REJECTED);
+    }
+    
+    /**
+     * Issue a live-mode API key to an approved or live merchant. The first
+     * one takes an approved merchant LIVE; later ones replace it.
+     *
+     * @return The key, the only time it is visible
+     */
+    @Transactional
+    public String issueLiveKey(Merchant merchant) {
+        OnboardingStatus status = merchant.getOnboardingStatus();
+        if (status != OnboardingStatus.APPROVED && status != OnboardingStatus.LIVE) {
+            throw new OnboardingException(OnboardingException.NOT_APPROVED,
+                    "Live API keys are issued once onboarding is approved; merchant is " + status);
+        }
+        String apiKey = authenticationService.createLiveApiKey(merchant);
+        if (status == OnboardingStatus.APPROVED) {
+            MerchantOnboarding onboarding = application(merchant);
+            onboarding.setLiveAt(clock.instant());
+            transition(merchant, onboarding, OnboardingStatus.LIVE);
+        }
+        return apiKey;
+    }
+    
+    // The business by its legal name and country, and the representative
+    private void screen(Merchant merchant, MerchantOnboarding onboarding) {
+        List<ScreeningSubject> subjects = new ArrayList<>();
+        subjects.add(new ScreeningSubject(ScreeningSubjectType.MERCHANT,
+                onboarding.getFields().get(OnboardingRequirements.LEGAL_NAME), merchant.getCountryCode()));
+        subjects.add(new ScreeningSubject(ScreeningSubjectType.MERCHANT,
+                onboarding.getFields().get(OnboardingRequirements.REPRESENTATIVE_NAME), null));
+        for (ScreeningSubject subject : subjects) {
+            List<ScreeningMatch> matches = screeningService.screen(subject);
+            if (!matches.isEmpty()) {
+                screeningService.hold(subject, merchant.getId(), null, matches);
+            }
+        }
+    }
+    
+    private MerchantOnboarding draft(Merchant merchant) {
+        if (merchant.getOnboardingStatus() != OnboardingStatus.DRAFT) {
+            throw new OnboardingException(OnboardingException.INVALID_TRANSITION,
+                    "Application can only change while DRAFT; merchant is " + merchant.getOnboardingStatus());
+        }
+        return application(merchant);
+    }
+    
+    private MerchantOnboarding application(Merchant merchant) {
+        return onboardingRepository.findById(merchant.getId())
+                .orElseThrow(() -> new OnboardingException(OnboardingException.INVALID_TRANSITION,
+                        "Merchant " + merchant.getMerchantId() + " did not apply through onboarding"));
+    }
+    
+    private MerchantOnboarding transition(Merchant merchant, MerchantOnboarding onboarding, OnboardingStatus status) {
+        OnboardingStatus from = merchant.getOnboardingStatus();
+        if (!from.canMoveTo(status)) {
+            throw new OnboardingException(OnboardingException.INVALID_TRANSITION,
+                    "Onboarding cannot move from " + from + " to " + status);
+        }
+        merchant.setOnboardingStatus(status);
+        merchantRepository.save(merchant);
+        onboarding.setUpdatedAt(clock.instant());
+        onboarding = onboardingRepository.save(onboarding);
+        
+        logger.info("Merchant {} onboarding {} -> {}", merchant.getMerchantId(), from, status);
+        eventPublisher.publishMerchantEvent(merchant, PaymentEventType.MERCHANT_ONBOARDING_UPDATED);
+        return onboarding;
+    }
+    
+    private static String newMerchantId() {
+        StringBuilder id = new StringBuilder("mch_");
+        for (int i = 0; i < 12; i++) {
+            id.append(ID_ALPHABET.charAt(random.nextInt(ID_ALPHABET.length())));
+        }
+        return id.toString();
+    }
+}
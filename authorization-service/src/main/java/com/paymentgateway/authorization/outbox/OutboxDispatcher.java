@@ -0,0 +1,138 @@
+package com.paymentgateway.authorization.outbox;
+
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.paymentgateway.authorization.event.PaymentEventMessage;
+import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.webhook.WebhookService;
+import io.micrometer.core.instrument.Counter;
+import io.micrometer.core.instrument.Gauge;
+import io.micrometer.core.instrument.MeterRegistry;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.time.Duration;
+import java.util.List;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Publishes committed outbox events to Kafka and schedules their webhooks.
+ * An event is marked published only after Kafka has acknowledged it, so
+ * delivery is at least once: a crash between the two repeats the event,
+ * and consumers deduplicate by event ID. A failed event is retried with
+ * exponential backoff and holds back later events of the same payment.
+ * Any number of instances may dispatch; each claims different events.
+ */
+@Component
+public class OutboxDispatcher {
+    
+    private static final Logger logger = LoggerFactory.getLogger(OutboxDispatcher.class);
+    private static final Duration INITIAL_RETRY_DELAY = Duration.ofSeconds(1);
+    private static final Duration MAX_RETRY_DELAY = Duration.ofMinutes(5);
+    
+    private final OutboxRepository repository;
+    private final PaymentEventPublisher publisher;
+    private final WebhookService webhookService;
+    private final ObjectMapper objectMapper;
+    private final int batchSize;
+    private final Duration sendTimeout;
+    private final Duration retention;
+    private final Counter published;
+    private final Counter failed;
+    
+    @Autowired
+    public OutboxDispatcher(OutboxRepository repository,
+                            PaymentEventPublisher publisher,
+                            WebhookService webhookService,
+                            ObjectMapper objectMapper,
+                            MeterRegistry meterRegistry,
+                            @Value("${outbox.batch-size:100}") int batchSize,
+                            @Value("${outbox.send-timeout-ms:10000}") long sendTimeoutMillis,
+                            @Value("${outbox.retention-hours:72}") long retentionHours) {
+        this(repository, publisher, webhookService, objectMapper, meterRegistry, batchSize,
+             Duration.ofMillis(sendTimeoutMillis), Duration.ofHours(retentionHours));
+    }
+    
+    public OutboxDispatcher(OutboxRepository repository, PaymentEventPublisher publisher,
+                            WebhookService webhookService, ObjectMapper objectMapper,
+                            MeterRegistry meterRegistry, int batchSize, Duration sendTimeout,
+                            Duration retention) {
+        this.repository = repository;
+        this.publisher = publisher;
+        this.webhookService = webhookService;
+        this.objectMapper = objectMapper;
+        this.batchSize = batchSize;
+        this.sendTimeout = sendTimeout;
+        this.retention = retention;
+        this.published = Counter.builder("outbox.events.published.total")
+            .description("Outbox events published to Kafka")
+            .register(meterRegistry);
+        this.failed = Counter.builder("outbox.events.failed.total")
+            .description("Outbox event publication attempts that failed and will be retried")
+            .register(meterRegistry);
+        Gauge.builder("outbox.events.pending", repository, OutboxRepository::countPending)
+            .description("Outbox events not yet published")
+            .register(meterRegistry);
+    }
+    
+    /**
+     * Publish one batch of due events
+     *
+     * @return The number of events published
+     */
+    @Scheduled(fixedDelayString = "${outbox.dispatch-interval-ms:1000}")
+    @Transactional
+    public int dispatch() {
+        List<OutboxEvent> events = repository.claimDue(batchSize);
+        int count = 0;
+        for (OutboxEvent event : events) {
+            try {
+                PaymentEventMessage message = objectMapper.readValue(event.getPayload(), PaymentEventMessage.class);
+                publisher.send(message, event.getPartitionKey())
+                    .get(sendTimeout.toMillis(), TimeUnit.MILLISECONDS);
+                if (event.getMerchantId() != null) {
+                    webhookService.scheduleDelivery(event.getMerchantId(), event.getPaymentId(), message);
+                }
+                repository.markPublished(event.getId());
+                published.increment();
+                count++;
+            } catch (InterruptedException e) {
+                Thread.currentThread().interrupt();
+                repository.markFailed(event.getId(), "interrupted", retryDelay(event.getAttempts() + 1));
+                break;
+            } catch (Exception e) {
+                Duration retryIn = retryDelay(event.getAttempts() + 1);
+                logger.warn("Failed to publish outbox event {} ({}), attempt {}, retrying in {}s: {}",
+                        event.getEventId(), event.getEventType(), event.getAttempts() + 1,
+                        retryIn.toSeconds(), e.getMessage());
+                repository.markFailed(event.getId(), String.valueOf(e.getMessage()), retryIn);
+                failed.increment();
+            }
+        }
+        return count;
+    }
+    
+    /**
+     * Delete published events older than the retention period
+     */
+    @Transactional
+    public int purgePublished() {
+        int purged = repository.purgePublished(retention);
+        if (purged > 0) {
+            logger.info("Purged {} published outbox events", purged);
+        }
+        return purged;
+    }
+    
+    /**
+     * Exponential backoff: min(INITIAL_RETRY_DELAY * 2^(attempt-1), MAX_RETRY_DELAY)
+     */
+    static Duration retryDelay(int attempt) {
+        Duration delay = INITIAL_RETRY_DELAY.multipliedBy(1L << Math.min(attempt - 1, 20));
+        return delay.compareTo(MAX_RETRY_DELAY) > 0 ? MAX_RETRY_DELAY : delay;
+    }
+}
@@ -0,0 +1,39 @@
+package com.paymentgateway.authorization.outbox;
+
+import java.util.UUID;
+
+/**
+ * A payment event waiting in the outbox to be published
+ */
+public class OutboxEvent {
+    
+    private final long id;
+    private final String eventId;
+    private final String eventType;
+    private final String partitionKey;
+    private final UUID paymentId;
+    private final UUID merchantId;
+    private final String payload;
+    private final int attempts;
+    
+    public OutboxEvent(long id, String eventId, String eventType, String partitionKey,
+                       UUID paymentId, UUID merchantId, String payload, int attempts) {
+        this.id = id;
+        this.eventId = eventId;
+        this.eventType = eventType;
+        this.partitionKey = partitionKey;
+        this.paymentId = paymentId;
+        this.merchantId = merchantId;
+        this.payload = payload;
+        this.attempts = attempts;
+    }
+    
+    public long getId() { return id; }
+    public String getEventId() { return eventId; }
+    public String getEventType() { return eventType; }
+    public String getPartitionKey() { return partitionKey; }
+    public UUID getPaymentId() { return paymentId; }
+    public UUID getMerchantId() { return merchantId; }
+    public String getPayload() { return payload; }
+    public int getAttempts() { return attempts; }
+}
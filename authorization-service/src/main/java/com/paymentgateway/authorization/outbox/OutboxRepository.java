@@ -0,0 +1,92 @@
+package com.paymentgateway.authorization.outbox;
+
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.stereotype.Repository;
+
+import java.time.Duration;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * The outbox_events table. Appends join the caller's transaction, so an
+ * event is committed together with the state change it describes.
+ */
+@Repository
+public class OutboxRepository {
+    
+    private static final String APPEND =
+        "INSERT INTO outbox_events (event_id, event_type, partition_key, payment_id, merchant_id, payload, " +
+        "created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())";
+    
+    // Only the oldest pending event of each partition key is due, so events
+    // of one payment are published in order even with several dispatchers.
+    // SKIP LOCKED leaves events claimed by another dispatcher to it.
+    private static final String CLAIM_DUE =
+        "SELECT o.id, o.event_id, o.event_type, o.partition_key, o.payment_id, o.merchant_id, o.payload, o.attempts " +
+        "FROM outbox_events o " +
+        "WHERE o.published_at IS NULL AND o.next_attempt_at <= NOW() " +
+        "AND NOT EXISTS (SELECT 1 FROM outbox_events e WHERE e.partition_key = o.partition_key " +
+        "AND e.published_at IS NULL AND e.id < o.id) " +
+        "ORDER BY o.id LIMIT ? FOR UPDATE SKIP LOCKED";
+    
+    private static final String MARK_PUBLISHED =
+        "UPDATE outbox_events SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = ?";
+    
+    private static final String MARK_FAILED =
+        "UPDATE outbox_events SET attempts = attempts + 1, last_error = ?, " +
+        "next_attempt_at = NOW() + ? * INTERVAL '1 millisecond' WHERE id = ?";
+    
+    private static final String COUNT_PENDING = "SELECT COUNT(*) FROM outbox_events WHERE published_at IS NULL";
+    
+    private static final String PURGE_PUBLISHED =
+        "DELETE FROM outbox_events WHERE published_at < NOW() - ? * INTERVAL '1 millisecond'";
+    
+    private final JdbcTemplate jdbcTemplate;
+    
+    public OutboxRepository(JdbcTemplate jdbcTemplate) {
+        this.jdbcTemplate = jdbcTemplate;
+    }
+    
+    public void append(String eventId, String eventType, String partitionKey,
+                       UUID paymentId, UUID merchantId, String payload) {
+        jdbcTemplate.update(APPEND, eventId, eventType, partitionKey, paymentId, merchantId, payload);
+    }
+    
+    /**
+     * Lock up to limit due events for publication until the transaction ends
+     */
+    public List<OutboxEvent> claimDue(int limit) {
+        return jdbcTemplate.query(CLAIM_DUE, (rs, row) -> new OutboxEvent(
+            rs.getLong("id"),
+            rs.getString("event_id"),
+            rs.getString("event_type"),
+            rs.getString("partition_key"),
+            rs.getObject("payment_id", UUID.class),
+            rs.getObject("merchant_id", UUID.class),
+            rs.getString("payload"),
+            rs.getInt("attempts")
+        ), limit);
+    }
+    
+    public void markPublished(long id) {
+        jdbcTemplate.update(MARK_PUBLISHED, id);
+    }
+    
+    public void markFailed(long id, String error, Duration retryIn) {
+        jdbcTemplate.update(MARK_FAILED, error, retryIn.toMillis(), id);
+    }
+    
+    public long countPending() {
+        Long count = jdbcTemplate.queryForObject(COUNT_PENDING, Long.class);
+        return count != null ? count : 0;
+    }
+    
+    /**
+     * Delete events published longer ago than retention
+     *
+     * @return The number of events deleted
+     */
+    public int purgePublished(Duration retention) {
+        return jdbcTemplate.update(PURGE_PUBLISHED, retention.toMillis());
+    }
+}
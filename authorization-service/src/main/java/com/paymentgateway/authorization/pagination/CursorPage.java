@@ -0,0 +1,42 @@
+package com.paymentgateway.authorization.pagination;
+
+import java.util.List;
+import java.util.function.Function;
+
+/**
+ * One page of a list endpoint. {@code nextPageToken} continues the list and
+ * is null on the last page.
+ */
+public class CursorPage<T> {
+
+    private List<T> items;
+    private int pageSize;
+    private String nextPageToken;
+
+    public CursorPage() {}
+
+    public CursorPage(List<T> items, int pageSize, String nextPageToken) {
+        this.items = items;
+        this.pageSize = pageSize;
+        this.nextPageToken = nextPageToken;
+    }
+
+    public List<T> getItems() { return items; }
+    public void setItems(List<T> items) { this.items = items; }
+
+    public int getPageSize() { return pageSize; }
+    public void setPageSize(int pageSize) { this.pageSize = pageSize; }
+
+    public String getNextPageToken() { return nextPageToken; }
+    public void setNextPageToken(String nextPageToken) { this.nextPageToken = nextPageToken; }
+
+    public boolean isHasNext() { return nextPageToken != null; }
+
+    /**
+     * The same page with each item converted, for returning DTOs rather than
+     * entities
+     */
+    public <R> CursorPage<R> map(Function<T, R> mapper) {
+        return new CursorPage<>(items.stream().map(mapper).toList(), pageSize, nextPageToken);
+    }
+}
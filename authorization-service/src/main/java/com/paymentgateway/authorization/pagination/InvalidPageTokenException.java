@@ -0,0 +1,12 @@
+package com.paymentgateway.authorization.pagination;
+
+/**
+ * Thrown for a page token that was not issued by the gateway, or that was
+ * issued for a query with different filters
+ */
+public class InvalidPageTokenException extends RuntimeException {
+
+    public InvalidPageTokenException(String message) {
+        super(message);
+    }
+}
@@ -0,0 +1,29 @@
+package com.paymentgateway.authorization.pagination;
+
+/**
+ * Bounds the page size of a list endpoint
+ */
+public class PageLimits {
+
+    private final int defaultSize;
+    private final int maxSize;
+
+    public PageLimits(int defaultSize, int maxSize) {
+        this.defaultSize = defaultSize;
+        this.maxSize = maxSize;
+    }
+
+    /**
+     * The page size for a requested size: the default when none or a
+     * non-positive size is requested, and never more than the maximum
+     */
+    public int size(Integer requested) {
+        if (requested == null || requested <= 0) {
+            return defaultSize;
+        }
+        return Math.min(requested, maxSize);
+    }
+
+    public int getDefaultSize() { return defaultSize; }
+    public int getMaxSize() { return maxSize; }
+}
@@ -0,0 +1,101 @@
+package com.paymentgateway.authorization.pagination;
+
+import com.fasterxml.jackson.core.JsonProcessingException;
+import com.fasterxml.jackson.databind.ObjectMapper;
+
+import java.nio.charset.StandardCharsets;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+import java.util.ArrayList;
+import java.util.Base64;
+import java.util.HexFormat;
+import java.util.List;
+import java.util.Map;
+import java.util.function.Function;
+
+/**
+ * Opaque cursor tokens for the list endpoints. A token holds the sort key of
+ * the last item on a page and a fingerprint of the query's filters: the next
+ * page starts strictly after that key, so rows inserted or deleted between
+ * calls never shift a page, and a token is rejected if the filters change.
+ *
+ * The encoding (URL-safe base64 of {@code {"k": [...], "f": "..."}}) is the
+ * one the Go services use, so every list API pages the same way.
+ */
+public final class PageToken {
+
+    private static final ObjectMapper MAPPER = new ObjectMapper();
+
+    private PageToken() {}
+
+    /**
+     * Identifies a query by its filter values, in a fixed order. Page size is
+     * not a filter: it may change from page to page.
+     */
+    public static String fingerprint(Object... filters) {
+        try {
+            MessageDigest digest = MessageDigest.getInstance("SHA-256");
+            for (Object filter : filters) {
+                digest.update(String.valueOf(filter).getBytes(StandardCharsets.UTF_8));
+                digest.update((byte) 0);
+            }
+            byte[] hash = digest.digest();
+            return HexFormat.of().formatHex(hash, 0, 8);
+        } catch (NoSuchAlgorithmException e) {
+            throw new IllegalStateException("SHA-256 not available", e);
+        }
+    }
+
+    public static String encode(String fingerprint, List<String> key) {
+        try {
+            byte[] json = MAPPER.writeValueAsBytes(Map.of("k", key, "f", fingerprint));
+            return Base64.getUrlEncoder().withoutPadding().encodeToString(json);
+        } catch (JsonProcessingException e) {
+            throw new IllegalStateException("Failed to encode page token", e);
+        }
+    }
+
+    /**
+     * The key a token continues after, or null for an empty token, which
+     * starts at the first page
+     *
+     * @throws InvalidPageTokenException if the token is malformed or was
+     *         issued for other filters
+     */
+    public static List<String> decode(String token, String fingerprint) {
+        if (token == null || token.isEmpty()) {
+            return null;
+        }
+        Map<?, ?> fields;
+        try {
+            fields = MAPPER.readValue(Base64.getUrlDecoder().decode(token), Map.class);
+        } catch (IllegalArgumentException | java.io.IOException e) {
+            throw new InvalidPageTokenException("Invalid page token");
+        }
+        if (!(fields.get("k") instanceof List<?> key) || key.isEmpty()) {
+            throw new InvalidPageTokenException("Invalid page token");
+        }
+        if (!MessageDigest.isEqual(String.valueOf(fields.get("f")).getBytes(StandardCharsets.UTF_8),
+                                   fingerprint.getBytes(StandardCharsets.UTF_8))) {
+            throw new InvalidPageTokenException("Page token was issued for a different query");
+        }
+        List<String> values = new ArrayList<>();
+        for (Object value : key) {
+            values.add(String.valueOf(value));
+        }
+        return values;
+    }
+
+    /**
+     * Builds a page from rows fetched with a limit of {@code size + 1}: the
+     * extra row, if present, only signals that another page follows
+     */
+    public static <T> CursorPage<T> page(List<T> fetched, int size, String fingerprint,
+                                         Function<T, List<String>> key) {
+        if (fetched.size() <= size) {
+            return new CursorPage<>(fetched, size, null);
+        }
+        List<T> items = fetched.subList(0, size);
+        return new CursorPage<>(new ArrayList<>(items), size, encode(fingerprint, key.apply(items.get(size - 1))));
+    }
+}
@@ -0,0 +1,103 @@
+package com.paymentgateway.authorization.psp;
+
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+import java.util.ArrayList;
+import java.util.List;
+
+/**
+ * What each acquirer charges per transaction, by card brand and currency.
+ * An acquirer with entries only accepts the brands and currencies they
+ * cover; one with no entries accepts everything at an unknown cost.
+ */
+@Component
+public class AcquirerCostTable {
+    
+    private static final String ANY = "*";
+    
+    private final List<Entry> entries = new ArrayList<>();
+    
+    /**
+     * @param spec comma-separated {@code PSP:BRAND:CURRENCY:PERCENT:FIXED}
+     *             entries, where brand and currency may be {@code *}, e.g.
+     *             {@code STRIPE:*:*:2.9:0.30,ADYEN:VISA:EUR:1.6:0.10}
+     */
+    public AcquirerCostTable(@Value("${psp.routing.costs:}") String spec) {
+        for (String item : spec.split(",")) {
+            if (item.isBlank()) {
+                continue;
+            }
+            String[] parts = item.trim().split(":");
+            if (parts.length != 5) {
+                throw new IllegalArgumentException("Invalid acquirer cost entry: " + item);
+            }
+            entries.add(new Entry(parts[0], parts[1], parts[2], new BigDecimal(parts[3]), new BigDecimal(parts[4])));
+        }
+    }
+    
+    /**
+     * Whether the acquirer takes this brand and currency
+     */
+    public boolean supports(String pspName, String cardBrand, String currency) {
+        return !hasEntries(pspName) || match(pspName, cardBrand, currency) != null;
+    }
+    
+    /**
+     * The fee for the transaction, or null if the acquirer's cost is unknown
+     */
+    public BigDecimal cost(String pspName, String cardBrand, String currency, BigDecimal amount) {
+        Entry entry = match(pspName, cardBrand, currency);
+        if (entry == null) {
+            return null;
+        }
+        return amount.multiply(entry.percent)
+            .divide(BigDecimal.valueOf(100), 4, RoundingMode.HALF_UP)
+            .add(entry.fixed);
+    }
+    
+    private boolean hasEntries(String pspName) {
+        return entries.stream().anyMatch(e -> e.psp.equals(pspName));
+    }
+    
+    /**
+     * The most specific entry: an exact brand and currency beats a wildcard
+     */
+    private Entry match(String pspName, String cardBrand, String currency) {
+        Entry best = null;
+        for (Entry e : entries) {
+            if (e.psp.equals(pspName) && e.matches(cardBrand, currency)
+                    && (best == null || e.specificity() > best.specificity())) {
+                best = e;
+            }
+        }
+        return best;
+    }
+    
+    private static final class Entry {
+        final String psp;
+        final String brand;
+        final String currency;
+        final BigDecimal percent;
+        final BigDecimal fixed;
+        
+        Entry(String psp, String brand, String currency, BigDecimal percent, BigDecimal fixed) {
+            this.psp = psp;
+            this.brand = brand;
+            this.currency = currency;
+            this.percent = percent;
+            this.fixed = fixed;
+        }
+        
+        boolean matches(String cardBrand, String txnCurrency) {
+            return (ANY.equals(brand) || brand.equalsIgnoreCase(cardBrand))
+                && (ANY.equals(currency) || currency.equalsIgnoreCase(txnCurrency));
+        }
+        
+        int specificity() {
+            return (ANY.equals(brand) ? 0 : 2) + (ANY.equals(currency) ? 0 : 1);
+        }
+    }
+}
@@ -0,0 +1,68 @@
+package com.paymentgateway.authorization.psp;
+
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * Tracks whether each acquirer is answering. After a run of consecutive
+ * failures or timeouts an acquirer is unhealthy and routed to last until the
+ * cooldown has passed, when it gets traffic again to prove itself.
+ */
+@Component
+public class AcquirerHealth {
+    
+    private final int failureThreshold;
+    private final Duration cooldown;
+    private final Clock clock;
+    private final Map<String, State> states = new ConcurrentHashMap<>();
+    
+    @Autowired
+    public AcquirerHealth(@Value("${psp.routing.health.failure-threshold:3}") int failureThreshold,
+                          @Value("${psp.routing.health.cooldown-seconds:30}") long cooldownSeconds) {
+        this(failureThreshold, Duration.ofSeconds(cooldownSeconds), Clock.systemUTC());
+    }
+    
+    public AcquirerHealth(int failureThreshold, Duration cooldown, Clock clock) {
+        this.failureThreshold = failureThreshold;
+        this.cooldown = cooldown;
+        this.clock = clock;
+    }
+    
+    public boolean isHealthy(String pspName) {
+        State state = states.get(pspName);
+        if (state == null) {
+            return true;
+        }
+        synchronized (state) {
+            return state.consecutiveFailures < failureThreshold
+                || clock.instant().isAfter(state.lastFailure.plus(cooldown));
+        }
+    }
+    
+    public void recordSuccess(String pspName) {
+        State state = states.computeIfAbsent(pspName, k -> new State());
+        synchronized (state) {
+            state.consecutiveFailures = 0;
+        }
+    }
+    
+    public void recordFailure(String pspName) {
+        State state = states.computeIfAbsent(pspName, k -> new State());
+        synchronized (state) {
+            state.consecutiveFailures++;
+            state.lastFailure = clock.instant();
+        }
+    }
+    
+    private static final class State {
+        int consecutiveFailures;
+        Instant lastFailure = Instant.EPOCH;
+    }
+}
@@ -18,7 +18,17 @@ public class AdyenPSPClient implements PSPClient {
     private static final Logger logger = LoggerFactory.getLogger(AdyenPSPClient.class);
     private static final String PSP_NAME = "ADYEN";
     
+    // Adyen answers a little slower than Stripe, in about 60ms, but approves
+    // slightly more: 92% of the authorizations the issuer does not screen
+    private static final SimulatedNetwork.Profile PROFILE = new SimulatedNetwork.Profile(
+        PSP_NAME, 60, 0.92, "card_declined", "Card was declined by issuer");
+    
     private boolean available = true;
+    private final SimulatedNetwork network;
+    
+    public AdyenPSPClient(SimulatedNetwork network) {
+        this.network = network;
+    }
     
     @Override
     public String getPSPName() {
@@ -40,20 +50,13 @@ public class AdyenPSPClient implements PSPClient {
         // Simulate Adyen API call
         try {
             // In production, this would make an HTTP request to Adyen's API
-            Thread.sleep(60); // Simulate slightly higher network latency
-            
             // Generate Adyen-style transaction ID
             String pspTransactionId = "adyen_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24);
+            return network.authorize(PROFILE, request, pspTransactionId);
             
-            // Simulate authorization success (92% success rate - slightly better than Stripe)
-            if (Math.random() < 0.92) {
-                logger.info("Adyen: Authorization successful - pspTransactionId={}", pspTransactionId);
-                return PSPAuthorizationResponse.success(pspTransactionId, request.getAmount(), request.getCurrency());
-            } else {
-                logger.warn("Adyen: Authorization declined - card_declined");
-                return PSPAuthorizationResponse.declined("card_declined", "Card was declined by issuer");
-            }
-            
+        } catch (PSPException e) {
+            logger.warn("Adyen: Authorization failed - {}", e.getMessage());
+            throw e;
         } catch (InterruptedException e) {
             Thread.currentThread().interrupt();
             throw new PSPException(PSP_NAME, "Request interrupted", e);
@@ -75,9 +78,10 @@ public class AdyenPSPClient implements PSPClient {
         try {
             Thread.sleep(35); // Simulate network latency
             
-            PSPCaptureResponse response = new PSPCaptureResponse(true, pspTransactionId);
-            response.setCapturedAmount(amount);
-            response.setCurrency(currency);
+            PSPCaptureResponse response = network.capture(PSP_NAME, pspTransactionId, amount, currency);
+            if (!response.isSuccess()) {
+                return response;
+            }
             
             logger.info("Adyen: Capture successful - pspTransactionId={}", pspTransactionId);
             return response;
@@ -102,6 +106,7 @@ public class AdyenPSPClient implements PSPClient {
         try {
             Thread.sleep(35); // Simulate network latency
             
+            network.release(pspTransactionId);
             PSPVoidResponse response = new PSPVoidResponse(true, pspTransactionId);
             logger.info("Adyen: Void successful - pspTransactionId={}", pspTransactionId);
             return response;
@@ -128,6 +133,7 @@ public class AdyenPSPClient implements PSPClient {
             Thread.sleep(45); // Simulate network latency
             
             String refundId = "adyen_ref_" + UUID.randomUUID().toString().replace("-", "").substring(0, 20);
+            network.refund(pspTransactionId, amount);
             PSPRefundResponse response = new PSPRefundResponse(true, refundId, pspTransactionId);
             response.setRefundedAmount(amount);
             response.setCurrency(currency);
@@ -144,6 +150,38 @@ public class AdyenPSPClient implements PSPClient {
         }
     }
     
+    @Override
+    public PSPRefundResponse credit(PSPAuthorizationRequest request) {
+        logger.info("Adyen: Standalone credit - merchantId={}, amount={}, currency={}",
+                   request.getMerchantId(), request.getAmount(), request.getCurrency());
+        
+        if (!available) {
+            throw new PSPException(PSP_NAME, "PSP_UNAVAILABLE", "Adyen is currently unavailable", true);
+        }
+        
+        validateAuthorizationRequest(request);
+        
+        try {
+            Thread.sleep(45); // Simulate network latency
+            
+            String creditId = "adyen_crd_" + UUID.randomUUID().toString().replace("-", "").substring(0, 20);
+            PSPRefundResponse response = network.credit(PSP_NAME, request, creditId);
+            if (!response.isSuccess()) {
+                return response;
+            }
+            
+            logger.info("Adyen: Standalone credit successful - creditId={}", creditId);
+            return response;
+            
+        } catch (InterruptedException e) {
+            Thread.currentThread().interrupt();
+            throw new PSPException(PSP_NAME, "Request interrupted", e);
+        } catch (Exception e) {
+            logger.error("Adyen: Standalone credit failed", e);
+            throw new PSPException(PSP_NAME, "Credit request failed", e);
+        }
+    }
+    
     @Override
     public boolean isAvailable() {
         return available;
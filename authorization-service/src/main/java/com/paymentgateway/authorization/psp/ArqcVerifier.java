@@ -0,0 +1,18 @@
+package com.paymentgateway.authorization.psp;
+
+/**
+ * Verifies the ARQC a chip card computes over each authorization, and
+ * builds the issuer's answer the card checks, for the simulated issuer:
+ * the card keys derive from the issuer master key in the HSM, so the
+ * issuer holds none of them.
+ */
+public interface ArqcVerifier {
+    
+    /**
+     * Verifies an ARQC the card computed over the transaction data at the
+     * ATC and returns the issuer's response data for the card, ISO 8583
+     * field 55 as BER-TLV: the ARPC over the ARQC and the two-character
+     * response code. A wrong ARQC is null; failing to reach the HSM throws.
+     */
+    byte[] verify(String pan, int panSequenceNumber, int atc, byte[] transactionData, byte[] arqc, String responseCode);
+}
@@ -0,0 +1,28 @@
+package com.paymentgateway.authorization.psp;
+
+import java.nio.charset.StandardCharsets;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+import java.util.HexFormat;
+
+/**
+ * Identifies a card across payments without exposing its PAN. Card tokens
+ * are issued per payment, so PSPs and the simulated issuer use this instead
+ * to recognise the same card.
+ */
+public final class CardFingerprint {
+    
+    private CardFingerprint() {}
+    
+    public static String of(String pan) {
+        if (pan == null) {
+            return null;
+        }
+        try {
+            MessageDigest digest = MessageDigest.getInstance("SHA-256");
+            return HexFormat.of().formatHex(digest.digest(pan.getBytes(StandardCharsets.US_ASCII)));
+        } catch (NoSuchAlgorithmException e) {
+            throw new IllegalStateException("SHA-256 not available", e);
+        }
+    }
+}
@@ -0,0 +1,17 @@
+package com.paymentgateway.authorization.psp;
+
+/**
+ * Verifies the CVV2 cardholders enter for card-not-present payments for the
+ * simulated issuer, which keeps no CVV2s: the value is derived from the
+ * card under the issuer's card verification key and compared.
+ */
+public interface CvvVerifier {
+    
+    /**
+     * Whether a CVV2 is the card's. A wrong CVV2 is false; failing to reach
+     * the HSM throws.
+     *
+     * @param expiry The card's expiry date as YYMM
+     */
+    boolean verify(String pan, String expiry, String cvv);
+}
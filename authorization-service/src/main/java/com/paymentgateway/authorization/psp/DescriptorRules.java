@@ -0,0 +1,126 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.authorization.domain.CardBrand;
+import com.paymentgateway.authorization.domain.Merchant;
+
+import java.util.Locale;
+import java.util.Map;
+import java.util.regex.Pattern;
+
+/**
+ * Card network rules for statement descriptors, the merchant name the
+ * cardholder sees on their statement. A merchant's static descriptor is
+ * used as is, or as the prefix of a dynamic descriptor: the prefix, an
+ * asterisk and a per-transaction suffix, e.g. "DEMOSTORE*ORDER 1234".
+ * Networks print descriptors in capitals, so they are uppercased first.
+ */
+public final class DescriptorRules {
+    
+    public static final int MIN_LENGTH = 5;
+    // The static descriptor must fit every brand, with room for a suffix
+    public static final int MAX_STATIC_LENGTH = 18;
+    
+    private static final Pattern LETTER = Pattern.compile("[A-Z]");
+    // Latin capitals, digits, space and . , & * - everywhere; Visa,
+    // Mastercard and the others also take # / and '
+    private static final Pattern AMEX_CHARS = Pattern.compile("[A-Z0-9 .,&*-]+");
+    private static final Pattern NETWORK_CHARS = Pattern.compile("[A-Z0-9 .,&*#/'-]+");
+    
+    private static final Map<CardBrand, Integer> MAX_LENGTH = Map.of(
+        CardBrand.VISA, 25,
+        CardBrand.MASTERCARD, 22,
+        CardBrand.AMEX, 20,
+        CardBrand.DISCOVER, 22,
+        CardBrand.JCB, 22,
+        CardBrand.DINERS, 22,
+        CardBrand.UNIONPAY, 22);
+    
+    private DescriptorRules() {}
+    
+    public static int maxLength(CardBrand brand) {
+        return MAX_LENGTH.getOrDefault(brand, 22);
+    }
+    
+    /**
+     * Uppercases a descriptor and collapses its spaces, or null if blank
+     */
+    public static String normalize(String descriptor) {
+        if (descriptor == null || descriptor.isBlank()) {
+            return null;
+        }
+        return descriptor.trim().replaceAll("\\s+", " ").toUpperCase(Locale.ROOT);
+    }
+    
+    /**
+     * Checks a static descriptor a merchant sets, returning it normalized.
+     * It must be valid for every brand.
+     *
+     * @throws IllegalArgumentException saying what is wrong
+     */
+    public static String checkStatic(String descriptor) {
+        String normalized = normalize(descriptor);
+        if (normalized == null || normalized.length() < MIN_LENGTH || normalized.length() > MAX_STATIC_LENGTH) {
+            throw new IllegalArgumentException("Descriptor must be " + MIN_LENGTH + " to " + MAX_STATIC_LENGTH + " characters");
+        }
+        if (normalized.contains("*")) {
+            throw new IllegalArgumentException("Descriptor must not contain '*', which separates a dynamic suffix");
+        }
+        checkCharacters(CardBrand.AMEX, normalized);
+        return normalized;
+    }
+    
+    /**
+     * The descriptor of a transaction: the static descriptor, followed by the
+     * dynamic suffix if there is one, checked against the card brand's rules
+     *
+     * @throws IllegalArgumentException if the suffix breaks the brand's rules
+     */
+    public static String compose(CardBrand brand, String prefix, String suffix) {
+        String dynamic = normalize(suffix);
+        if (dynamic == null) {
+            return prefix;
+        }
+        if (dynamic.contains("*")) {
+            throw new IllegalArgumentException("Dynamic descriptor must not contain '*'");
+        }
+        String descriptor = prefix + "*" + dynamic;
+        if (descriptor.length() > maxLength(brand)) {
+            throw new IllegalArgumentException("Descriptor " + descriptor + " is longer than the "
+                + maxLength(brand) + " characters " + brand + " allows");
+        }
+        checkCharacters(brand, descriptor);
+        return descriptor;
+    }
+    
+    /**
+     * The merchant's static descriptor, or one from its name if it has not
+     * set one
+     */
+    public static String staticDescriptor(Merchant merchant) {
+        return merchant.getStatementDescriptor() != null
+            ? merchant.getStatementDescriptor()
+            : fromName(merchant.getMerchantName());
+    }
+    
+    /**
+     * The static descriptor of a merchant that has not set one, from its
+     * name: its letters, digits and spaces, cut to fit
+     */
+    public static String fromName(String merchantName) {
+        String name = normalize(merchantName == null ? null : merchantName.replaceAll("[^A-Za-z0-9 ]", ""));
+        if (name == null) {
+            return "MERCHANT";
+        }
+        return name.length() > MAX_STATIC_LENGTH ? name.substring(0, MAX_STATIC_LENGTH).trim() : name;
+    }
+    
+    private static void checkCharacters(CardBrand brand, String descriptor) {
+        Pattern allowed = brand == CardBrand.AMEX ? AMEX_CHARS : NETWORK_CHARS;
+        if (!allowed.matcher(descriptor).matches()) {
+            throw new IllegalArgumentException("Descriptor " + descriptor + " has characters " + brand + " does not allow");
+        }
+        if (!LETTER.matcher(descriptor).find()) {
+            throw new IllegalArgumentException("Descriptor " + descriptor + " needs at least one letter");
+        }
+    }
+}
@@ -0,0 +1,56 @@
+package com.paymentgateway.authorization.psp;
+
+import com.google.protobuf.ByteString;
+import com.paymentgateway.api.hsm.v1.GenerateEMVResponseRequest;
+import io.grpc.Status;
+import io.grpc.StatusRuntimeException;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+/**
+ * ARQC verification and ARPC generation by the HSM simulator, the issuer's
+ * HSM, with its GenerateEMVResponse command under the issuer master key
+ * for application cryptograms
+ */
+@Component
+@ConditionalOnProperty(name = "psp.simulator.hsm.address")
+public class HsmArqcVerifier implements ArqcVerifier {
+    
+    private static final Logger logger = LoggerFactory.getLogger(HsmArqcVerifier.class);
+    
+    private final HsmClient hsm;
+    private final String imkId;
+    
+    public HsmArqcVerifier(HsmClient hsm, @Value("${psp.simulator.hsm.imk-ac-id:issuer-imk-ac}") String imkId) {
+        this.hsm = hsm;
+        this.imkId = imkId;
+        logger.info("Simulated issuer verifies ARQCs with the HSM under {}", imkId);
+    }
+    
+    @Override
+    public byte[] verify(String pan, int panSequenceNumber, int atc, byte[] transactionData, byte[] arqc,
+                         String responseCode) {
+        try {
+            return hsm.stub().generateEMVResponse(GenerateEMVResponseRequest.newBuilder()
+                .setKeyId(imkId)
+                .setPan(pan)
+                .setPanSequenceNumber(panSequenceNumber)
+                .setAtc(atc)
+                .setTransactionData(ByteString.copyFrom(transactionData))
+                .setArqc(ByteString.copyFrom(arqc))
+                .setResponseCode(responseCode)
+                .build()).getField55().toByteArray();
+        } catch (StatusRuntimeException e) {
+            // The HSM answers an ARQC that does not verify, like malformed
+            // chip data, with INVALID_ARGUMENT
+            if (e.getStatus().getCode() == Status.Code.INVALID_ARGUMENT) {
+                logger.warn("HSM rejected an ARQC: {}", e.getStatus().getDescription());
+                return null;
+            }
+            throw e;
+        }
+    }
+}
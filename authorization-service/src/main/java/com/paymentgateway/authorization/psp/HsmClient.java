@@ -0,0 +1,61 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.api.hsm.v1.HSMServiceGrpc;
+import io.grpc.Channel;
+import io.grpc.ClientInterceptors;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Metadata;
+import io.grpc.stub.MetadataUtils;
+import jakarta.annotation.PreDestroy;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Connection to the HSM simulator acting as the simulated issuer's HSM,
+ * through the stubs generated from api/hsm/v1/hsm.proto
+ */
+@Component
+@ConditionalOnProperty(name = "psp.simulator.hsm.address")
+public class HsmClient {
+    
+    private static final Logger logger = LoggerFactory.getLogger(HsmClient.class);
+    
+    private static final long DEADLINE_MS = 2000;
+    static final String PRIORITY_HEADER = "x-hsm-priority";
+    static final String AUTHORIZATION_PRIORITY = "authorization";
+    
+    private final ManagedChannel managedChannel;
+    private final Channel channel;
+    
+    public HsmClient(@Value("${psp.simulator.hsm.address}") String address,
+                     @Value("${psp.simulator.hsm.api-key:}") String apiKey) {
+        this.managedChannel = ManagedChannelBuilder.forTarget(address).usePlaintext().build();
+        // The issuer verifies PINs and CVVs while a payment waits, so its
+        // calls go ahead of bulk traffic when the HSM's workers are busy
+        Metadata headers = new Metadata();
+        headers.put(Metadata.Key.of(PRIORITY_HEADER, Metadata.ASCII_STRING_MARSHALLER), AUTHORIZATION_PRIORITY);
+        if (!apiKey.isBlank()) {
+            headers.put(Metadata.Key.of("authorization", Metadata.ASCII_STRING_MARSHALLER), "Bearer " + apiKey);
+        }
+        this.channel = ClientInterceptors.intercept(managedChannel, MetadataUtils.newAttachHeadersInterceptor(headers));
+        logger.info("Simulated issuer uses the HSM at {}", address);
+    }
+    
+    /**
+     * A stub for one HSM command, with the deadline starting now
+     */
+    public HSMServiceGrpc.HSMServiceBlockingStub stub() {
+        return HSMServiceGrpc.newBlockingStub(channel).withDeadlineAfter(DEADLINE_MS, TimeUnit.MILLISECONDS);
+    }
+    
+    @PreDestroy
+    public void close() {
+        managedChannel.shutdown();
+    }
+}
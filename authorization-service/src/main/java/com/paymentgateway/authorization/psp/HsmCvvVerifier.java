@@ -0,0 +1,42 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.api.hsm.v1.VerifyCVVRequest;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+/**
+ * CVV2 verification by the HSM simulator, the issuer's HSM, with its
+ * VerifyCVV command under the card verification key
+ */
+@Component
+@ConditionalOnProperty(name = "psp.simulator.hsm.address")
+public class HsmCvvVerifier implements CvvVerifier {
+    
+    private static final Logger logger = LoggerFactory.getLogger(HsmCvvVerifier.class);
+    
+    // Service code of the CVV2, as opposed to the magnetic stripe CVV
+    private static final String CVV2_SERVICE_CODE = "000";
+    
+    private final HsmClient hsm;
+    private final String cvkId;
+    
+    public HsmCvvVerifier(HsmClient hsm, @Value("${psp.simulator.hsm.cvk-id:issuer-cvk}") String cvkId) {
+        this.hsm = hsm;
+        this.cvkId = cvkId;
+        logger.info("Simulated issuer verifies CVV2s with the HSM under {}", cvkId);
+    }
+    
+    @Override
+    public boolean verify(String pan, String expiry, String cvv) {
+        return hsm.stub().verifyCVV(VerifyCVVRequest.newBuilder()
+            .setCvkId(cvkId)
+            .setPan(pan)
+            .setExpiry(expiry)
+            .setServiceCode(CVV2_SERVICE_CODE)
+            .setCvv(cvv)
+            .build()).getVerified();
+    }
+}
@@ -0,0 +1,77 @@
+package com.paymentgateway.authorization.psp;
+
+import com.google.protobuf.ByteString;
+import com.paymentgateway.api.hsm.v1.EncryptPINRequest;
+import com.paymentgateway.api.hsm.v1.GeneratePINReferenceRequest;
+import com.paymentgateway.api.hsm.v1.PINReference;
+import com.paymentgateway.api.hsm.v1.VerifyPINRequest;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+/**
+ * PIN verification by the HSM simulator, the issuer's HSM. PIN blocks are
+ * checked with its VerifyPIN command under the PIN verification key, and
+ * test card PINs are enrolled by encrypting them under the ZPK and deriving
+ * their offset or PVV, after which the issuer forgets them.
+ */
+@Component
+@ConditionalOnProperty(name = "psp.simulator.hsm.address")
+public class HsmPinVerifier implements PinVerifier {
+    
+    private static final Logger logger = LoggerFactory.getLogger(HsmPinVerifier.class);
+    
+    private final HsmClient hsm;
+    private final String zpkId;
+    private final String pvkId;
+    private final String method;
+    private final int pvki;
+    
+    public HsmPinVerifier(HsmClient hsm,
+                          @Value("${psp.simulator.hsm.zpk-id:issuer-zpk}") String zpkId,
+                          @Value("${psp.simulator.hsm.pvk-id:issuer-pvk}") String pvkId,
+                          @Value("${psp.simulator.hsm.pin-method:" + PinReference.VISA_PVV + "}") String method,
+                          @Value("${psp.simulator.hsm.pvki:1}") int pvki) {
+        this.hsm = hsm;
+        this.zpkId = zpkId;
+        this.pvkId = pvkId;
+        this.method = method;
+        this.pvki = pvki;
+        logger.info("Simulated issuer verifies PINs with the HSM ({} under {})", method, pvkId);
+    }
+    
+    @Override
+    public PinReference enroll(String pan, String pin) {
+        ByteString pinBlock = hsm.stub().encryptPIN(EncryptPINRequest.newBuilder()
+            .setZpkId(zpkId)
+            .setPin(pin)
+            .setPan(pan)
+            .build()).getPinBlock();
+        
+        PINReference reference = hsm.stub().generatePINReference(GeneratePINReferenceRequest.newBuilder()
+            .setPvkId(pvkId)
+            .setZpkId(zpkId)
+            .setPinBlock(pinBlock)
+            .setPan(pan)
+            .setMethod(method)
+            .setPvki(pvki)
+            .build()).getReference();
+        return new PinReference(reference.getMethod(), reference.getValue(), reference.getPvki());
+    }
+    
+    @Override
+    public boolean verify(String pan, byte[] pinBlock, PinReference reference) {
+        return hsm.stub().verifyPIN(VerifyPINRequest.newBuilder()
+            .setPvkId(pvkId)
+            .setZpkId(zpkId)
+            .setPinBlock(ByteString.copyFrom(pinBlock))
+            .setPan(pan)
+            .setReference(PINReference.newBuilder()
+                .setMethod(reference.getMethod())
+                .setValue(reference.getValue())
+                .setPvki(reference.getPvki()))
+            .build()).getVerified();
+    }
+}
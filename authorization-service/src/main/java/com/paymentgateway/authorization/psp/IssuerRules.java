@@ -0,0 +1,293 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.shared.rules.RuleExpression;
+import com.paymentgateway.shared.rules.RuleSyntaxException;
+import org.yaml.snakeyaml.LoaderOptions;
+import org.yaml.snakeyaml.Yaml;
+import org.yaml.snakeyaml.constructor.SafeConstructor;
+import org.yaml.snakeyaml.error.YAMLException;
+
+import java.time.LocalTime;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.HashSet;
+import java.util.List;
+import java.util.Map;
+import java.util.Set;
+
+/**
+ * Issuer behaviour that does not follow from card data, as rules in the
+ * condition language the fraud service uses:
+ *
+ * <pre>
+ * rules:
+ *   - name: NO_GAMBLING
+ *     when: mcc = '7995'
+ *     response-code: '57'
+ *     message: Transaction not permitted to cardholder
+ *   - name: AMEX_AVS_DOWN
+ *     when: brand = 'AMEX'
+ *     avs-result: U
+ *   - name: FORCE_APPROVE
+ *     when: amount = 12.34
+ *     response-code: '00'
+ * </pre>
+ *
+ * Each outcome, the response code, AVS result and CVV2 result, comes from
+ * the first matching rule that sets it. A response code of 00 approves a
+ * payment the simulator would otherwise decline at random; funds are still
+ * held. Conditions see the attributes built by {@link #attributes}.
+ */
+public class IssuerRules {
+    
+    public static final String APPROVED = "00";
+    
+    private static final Set<String> AVS_RESULTS = Set.of(
+        SimulatedIssuer.AVS_FULL_MATCH, SimulatedIssuer.AVS_STREET_ONLY, SimulatedIssuer.AVS_ZIP_ONLY,
+        SimulatedIssuer.AVS_NO_MATCH, SimulatedIssuer.AVS_UNAVAILABLE);
+    private static final Set<String> CVV_RESULTS = Set.of(
+        SimulatedIssuer.CVV_MATCH, SimulatedIssuer.CVV_NO_MATCH, SimulatedIssuer.CVV_NOT_PROCESSED);
+    
+    private static final IssuerRules EMPTY = new IssuerRules(List.of());
+    
+    private final List<Rule> rules;
+    
+    public IssuerRules(List<Rule> rules) {
+        this.rules = List.copyOf(rules);
+    }
+    
+    public static IssuerRules empty() {
+        return EMPTY;
+    }
+    
+    /**
+     * Parses and validates a rules document. Any error rejects the whole
+     * document, so a partially valid file never replaces working rules.
+     */
+    public static IssuerRules fromYaml(String yaml) {
+        Object document;
+        try {
+            document = new Yaml(new SafeConstructor(new LoaderOptions())).load(yaml);
+        } catch (YAMLException e) {
+            throw new RuleSyntaxException("invalid YAML: " + e.getMessage());
+        }
+        if (document == null) {
+            return EMPTY;
+        }
+        if (!(document instanceof Map<?, ?> root) || !(root.get("rules") instanceof List<?> entries)) {
+            throw new RuleSyntaxException("expected a top-level 'rules' list");
+        }
+        
+        List<Rule> rules = new ArrayList<>();
+        Set<String> names = new HashSet<>();
+        for (int i = 0; i < entries.size(); i++) {
+            if (!(entries.get(i) instanceof Map<?, ?> entry)) {
+                throw new RuleSyntaxException("rule " + (i + 1) + ": expected a mapping");
+            }
+            Rule rule = parseRule(i + 1, entry);
+            if (!names.add(rule.getName())) {
+                throw new RuleSyntaxException("rule " + (i + 1) + ": duplicate name " + rule.getName());
+            }
+            rules.add(rule);
+        }
+        return new IssuerRules(rules);
+    }
+    
+    private static Rule parseRule(int index, Map<?, ?> entry) {
+        String prefix = "rule " + index + ": ";
+        if (!(entry.get("name") instanceof String name) || name.isBlank()) {
+            throw new RuleSyntaxException(prefix + "'name' is required");
+        }
+        prefix = "rule " + index + " (" + name + "): ";
+        if (!(entry.get("when") instanceof String when)) {
+            throw new RuleSyntaxException(prefix + "'when' is required");
+        }
+        RuleExpression condition;
+        try {
+            condition = RuleExpression.parse(when);
+        } catch (RuleSyntaxException e) {
+            throw new RuleSyntaxException(prefix + e.getMessage());
+        }
+        
+        String responseCode = string(entry, "response-code");
+        if (responseCode != null && !responseCode.matches("[0-9A-Z]{2}")) {
+            throw new RuleSyntaxException(prefix + "'response-code' must be two digits or capital letters");
+        }
+        String avsResult = string(entry, "avs-result");
+        if (avsResult != null && !AVS_RESULTS.contains(avsResult)) {
+            throw new RuleSyntaxException(prefix + "'avs-result' must be one of " + AVS_RESULTS);
+        }
+        String cvvResult = string(entry, "cvv-result");
+        if (cvvResult != null && !CVV_RESULTS.contains(cvvResult)) {
+            throw new RuleSyntaxException(prefix + "'cvv-result' must be one of " + CVV_RESULTS);
+        }
+        if (responseCode == null && avsResult == null && cvvResult == null) {
+            throw new RuleSyntaxException(prefix + "set at least one of 'response-code', 'avs-result' and 'cvv-result'");
+        }
+        String message = string(entry, "message");
+        return new Rule(name, condition, responseCode, message, avsResult, cvvResult);
+    }
+    
+    // YAML reads unquoted codes such as 05 or 57 as numbers
+    private static String string(Map<?, ?> entry, String key) {
+        Object value = entry.get(key);
+        if (value == null) {
+            return null;
+        }
+        if (value instanceof Integer n && n >= 0 && n < 100) {
+            return String.format("%02d", n);
+        }
+        return value.toString();
+    }
+    
+    public List<Rule> getRules() {
+        return rules;
+    }
+    
+    public int size() {
+        return rules.size();
+    }
+    
+    /**
+     * The attributes rule conditions see: amount, currency, merchant, mcc,
+     * brand, bin, channel, initiator, stored_credential, country,
+     * postal_code, installments, installment_plan, pin ('true' or 'false'),
+     * the card data checks' cvv_result and avs_result, and hour
+     */
+    public static Map<String, Object> attributes(PSPAuthorizationRequest request, String cvvResult, String avsResult) {
+        Map<String, Object> attributes = new HashMap<>();
+        attributes.put("amount", request.getAmount());
+        attributes.put("currency", request.getCurrency());
+        attributes.put("merchant", request.getMerchantId() != null ? request.getMerchantId().toString() : null);
+        attributes.put("mcc", request.getMerchantCategoryCode());
+        attributes.put("brand", request.getCardBrand());
+        attributes.put("bin", request.getCardBin());
+        attributes.put("channel", request.getChannel());
+        attributes.put("initiator", request.getInitiator());
+        attributes.put("stored_credential", request.getStoredCredential());
+        attributes.put("country", request.getBillingCountry());
+        attributes.put("postal_code", request.getBillingZip());
+        attributes.put("installments", request.getInstallmentCount());
+        attributes.put("installment_plan", request.getInstallmentPlanType());
+        attributes.put("pin", Boolean.toString(request.getPinBlock() != null && !request.getPinBlock().isBlank()));
+        attributes.put("cvv_result", cvvResult);
+        attributes.put("avs_result", avsResult);
+        attributes.put("hour", LocalTime.now().getHour());
+        attributes.values().removeIf(value -> value == null);
+        return attributes;
+    }
+    
+    /**
+     * Evaluates the rules against a payment, starting from the CVV2 and AVS
+     * results of the card data checks
+     */
+    public Outcome evaluate(PSPAuthorizationRequest request, String cvvResult, String avsResult) {
+        Map<String, Object> attributes = attributes(request, cvvResult, avsResult);
+        List<String> triggered = new ArrayList<>();
+        Rule decided = null;
+        String avs = null;
+        String cvv = null;
+        for (Rule rule : rules) {
+            if (!rule.getCondition().matches(attributes)) {
+                continue;
+            }
+            triggered.add(rule.getName());
+            if (decided == null && rule.getResponseCode() != null) {
+                decided = rule;
+            }
+            if (avs == null) {
+                avs = rule.getAvsResult();
+            }
+            if (cvv == null) {
+                cvv = rule.getCvvResult();
+            }
+        }
+        return new Outcome(triggered, decided, avs != null ? avs : avsResult, cvv != null ? cvv : cvvResult);
+    }
+    
+    /**
+     * A named condition with the issuer outcomes it sets
+     */
+    public static class Rule {
+        
+        private final String name;
+        private final RuleExpression condition;
+        private final String responseCode;
+        private final String message;
+        private final String avsResult;
+        private final String cvvResult;
+        
+        public Rule(String name, RuleExpression condition, String responseCode, String message,
+                    String avsResult, String cvvResult) {
+            this.name = name;
+            this.condition = condition;
+            this.responseCode = responseCode;
+            this.message = message;
+            this.avsResult = avsResult;
+            this.cvvResult = cvvResult;
+        }
+        
+        public String getName() { return name; }
+        public RuleExpression getCondition() { return condition; }
+        public String getResponseCode() { return responseCode; }
+        public String getMessage() { return message; }
+        public String getAvsResult() { return avsResult; }
+        public String getCvvResult() { return cvvResult; }
+    }
+    
+    /**
+     * What the rules decided for one payment
+     */
+    public static class Outcome {
+        
+        private final List<String> triggeredRules;
+        private final Rule decidedBy;
+        private final String avsResult;
+        private final String cvvResult;
+        
+        Outcome(List<String> triggeredRules, Rule decidedBy, String avsResult, String cvvResult) {
+            this.triggeredRules = List.copyOf(triggeredRules);
+            this.decidedBy = decidedBy;
+            this.avsResult = avsResult;
+            this.cvvResult = cvvResult;
+        }
+        
+        public List<String> getTriggeredRules() { return triggeredRules; }
+        
+        /**
+         * The rule that set the response code, or null if none did
+         */
+        public String getRule() {
+            return decidedBy != null ? decidedBy.getName() : null;
+        }
+        
+        public String getResponseCode() {
+            return decidedBy != null ? decidedBy.getResponseCode() : null;
+        }
+        
+        public String getMessage() {
+            if (decidedBy == null) {
+                return null;
+            }
+            return decidedBy.getMessage() != null ? decidedBy.getMessage() : "Declined by issuer rule " + decidedBy.getName();
+        }
+        
+        public boolean isApproval() {
+            return APPROVED.equals(getResponseCode());
+        }
+        
+        public boolean isDecline() {
+            return getResponseCode() != null && !isApproval();
+        }
+        
+        /**
+         * The AVS result to report: a rule's, or else the address check's
+         */
+        public String getAvsResult() { return avsResult; }
+        
+        /**
+         * The CVV2 result to report: a rule's, or else the HSM check's
+         */
+        public String getCvvResult() { return cvvResult; }
+    }
+}
@@ -0,0 +1,82 @@
+package com.paymentgateway.authorization.psp;
+
+import org.springframework.stereotype.Component;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.util.ArrayDeque;
+import java.util.ArrayList;
+import java.util.Deque;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * Authorization responses that arrived after the gateway stopped waiting,
+ * with what the gateway did about them, for reconciling against the PSP.
+ * Only the most recent entries are kept.
+ */
+@Component
+public class LateResponseLog {
+    
+    private static final int MAX_ENTRIES = 500;
+    
+    /**
+     * What the gateway did with a late response
+     */
+    public enum Action {
+        REVERSED,          // An approval, voided at the PSP
+        REVERSAL_FAILED,   // An approval the PSP would not void; needs manual follow-up
+        NONE               // A decline or error, nothing to undo
+    }
+    
+    private final Deque<Entry> entries = new ArrayDeque<>();
+    
+    public synchronized void record(Entry entry) {
+        entries.addFirst(entry);
+        while (entries.size() > MAX_ENTRIES) {
+            entries.removeLast();
+        }
+    }
+    
+    /**
+     * Entries, newest first
+     */
+    public synchronized List<Entry> getEntries() {
+        return new ArrayList<>(entries);
+    }
+    
+    public static class Entry {
+        private final String pspName;
+        private final UUID merchantId;
+        private final BigDecimal amount;
+        private final String currency;
+        private final String status;
+        private final String pspTransactionId;
+        private final Instant sentAt;
+        private final Instant receivedAt;
+        private final Action action;
+        
+        public Entry(String pspName, PSPAuthorizationRequest request, PSPAuthorizationResponse response,
+                     Instant sentAt, Instant receivedAt, Action action) {
+            this.pspName = pspName;
+            this.merchantId = request.getMerchantId();
+            this.amount = request.getAmount();
+            this.currency = request.getCurrency();
+            this.status = response.getStatus();
+            this.pspTransactionId = response.getPspTransactionId();
+            this.sentAt = sentAt;
+            this.receivedAt = receivedAt;
+            this.action = action;
+        }
+        
+        public String getPspName() { return pspName; }
+        public UUID getMerchantId() { return merchantId; }
+        public BigDecimal getAmount() { return amount; }
+        public String getCurrency() { return currency; }
+        public String getStatus() { return status; }
+        public String getPspTransactionId() { return pspTransactionId; }
+        public Instant getSentAt() { return sentAt; }
+        public Instant getReceivedAt() { return receivedAt; }
+        public Action getAction() { return action; }
+    }
+}
@@ -0,0 +1,285 @@
+package com.paymentgateway.authorization.psp;
+
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.math.BigDecimal;
+import java.util.ArrayList;
+import java.util.LinkedHashSet;
+import java.util.List;
+import java.util.Set;
+import java.util.UUID;
+import java.util.function.DoubleSupplier;
+
+/**
+ * Test amounts for which the PSP simulators answer authorizations slowly,
+ * e.g. after the gateway's routing timeout, so timeout handling, reversal
+ * of late approvals and reconciliation can be exercised on demand.
+ * Targeted fault rules add latency or errors for a merchant, BIN range or
+ * amount band, to a share of its authorizations, so one segment of traffic
+ * degrades while the rest stays healthy. Fault rules can be replaced while
+ * the gateway runs, from the file named by psp.simulator.faults-file.
+ */
+@Component
+public class LatencyScenarios {
+    
+    private final List<Scenario> scenarios = new ArrayList<>();
+    // Replaced as a whole, so an authorization sees either the old rules or
+    // the new ones
+    private volatile List<FaultRule> rules;
+    private final DoubleSupplier random;
+    
+    /**
+     * @param spec comma-separated {@code PSP:AMOUNT:DELAY_MS} entries, where
+     *             the PSP may be {@code *}, e.g. {@code *:99.05:8000}
+     */
+    public LatencyScenarios(String spec) {
+        this(spec, "", Math::random);
+    }
+    
+    /**
+     * @param faults semicolon-separated fault rules of comma-separated
+     *               {@code key=value} pairs, see {@link FaultRule#parse}
+     */
+    @Autowired
+    public LatencyScenarios(@Value("${psp.simulator.late-responses:}") String spec,
+                            @Value("${psp.simulator.faults:}") String faults) {
+        this(spec, faults, Math::random);
+    }
+    
+    LatencyScenarios(String spec, String faults, DoubleSupplier random) {
+        this.random = random;
+        for (String item : spec.split(",")) {
+            if (item.isBlank()) {
+                continue;
+            }
+            String[] parts = item.trim().split(":");
+            if (parts.length != 3) {
+                throw new IllegalArgumentException("Invalid late response scenario: " + item);
+            }
+            scenarios.add(new Scenario(parts[0], new BigDecimal(parts[1]), Long.parseLong(parts[2])));
+        }
+        this.rules = parseFaults(faults);
+    }
+    
+    /**
+     * Replaces the fault rules once all of them are valid; a rule that is
+     * not leaves the current rules in place. Returns the rules added and
+     * removed.
+     *
+     * @param faults fault rules separated by semicolons or new lines
+     */
+    public synchronized List<String> replaceFaults(String faults) {
+        List<FaultRule> parsed = parseFaults(faults);
+        Set<String> before = new LinkedHashSet<>();
+        rules.forEach(rule -> before.add(rule.spec));
+        Set<String> after = new LinkedHashSet<>();
+        parsed.forEach(rule -> after.add(rule.spec));
+        List<String> changes = new ArrayList<>();
+        before.stream().filter(spec -> !after.contains(spec)).forEach(spec -> changes.add("removed fault rule " + spec));
+        after.stream().filter(spec -> !before.contains(spec)).forEach(spec -> changes.add("added fault rule " + spec));
+        rules = parsed;
+        return changes;
+    }
+    
+    private static List<FaultRule> parseFaults(String faults) {
+        List<FaultRule> parsed = new ArrayList<>();
+        for (String item : faults.split("[;\\n]")) {
+            if (!item.isBlank()) {
+                parsed.add(FaultRule.parse(item.trim()));
+            }
+        }
+        return List.copyOf(parsed);
+    }
+    
+    /**
+     * How long the PSP takes to answer this authorization, or the given
+     * normal latency when no scenario matches
+     */
+    public long delayMillis(String pspName, BigDecimal amount, long normalMillis) {
+        for (Scenario s : scenarios) {
+            if (("*".equals(s.psp) || s.psp.equals(pspName)) && s.amount.compareTo(amount) == 0) {
+                return s.delayMillis;
+            }
+        }
+        return normalMillis;
+    }
+    
+    /**
+     * How the PSP answers this authorization: after the delay of a late
+     * response scenario, else of the first fault rule that matches and is
+     * drawn, else after the normal latency
+     */
+    public Injection plan(String pspName, PSPAuthorizationRequest request, long normalMillis) {
+        long delay = delayMillis(pspName, request.getAmount(), -1);
+        if (delay >= 0) {
+            return new Injection(delay, null, null);
+        }
+        for (FaultRule rule : rules) {
+            if (rule.matches(pspName, request) && random.getAsDouble() < rule.rate) {
+                long extra = rule.minDelayMillis;
+                if (rule.maxDelayMillis > rule.minDelayMillis) {
+                    extra += (long) (random.getAsDouble() * (rule.maxDelayMillis - rule.minDelayMillis));
+                }
+                return new Injection(normalMillis + extra, rule.errorCode, rule.spec);
+            }
+        }
+        return new Injection(normalMillis, null, null);
+    }
+    
+    /**
+     * The latency and, optionally, the error a PSP simulator answers an
+     * authorization with
+     */
+    public static final class Injection {
+        private final long delayMillis;
+        private final String errorCode;
+        private final String rule;
+        
+        Injection(long delayMillis, String errorCode, String rule) {
+            this.delayMillis = delayMillis;
+            this.errorCode = errorCode;
+            this.rule = rule;
+        }
+        
+        public long getDelayMillis() { return delayMillis; }
+        
+        /** The retryable error to fail with after the delay, or null */
+        public String getErrorCode() { return errorCode; }
+        
+        /** The fault rule that applied, or null */
+        public String getRule() { return rule; }
+        
+        /**
+         * Fails the call with the injected error, if any
+         */
+        public void throwIfError(String pspName) {
+            if (errorCode != null) {
+                throw new PSPException(pspName, errorCode, "Injected fault: " + rule, true);
+            }
+        }
+    }
+    
+    private static final class Scenario {
+        final String psp;
+        final BigDecimal amount;
+        final long delayMillis;
+        
+        Scenario(String psp, BigDecimal amount, long delayMillis) {
+            this.psp = psp;
+            this.amount = amount;
+            this.delayMillis = delayMillis;
+        }
+    }
+    
+    /**
+     * A targeted fault. Unset criteria match everything.
+     */
+    static final class FaultRule {
+        final String spec;
+        String psp = "*";
+        UUID merchantId;
+        String binFrom;
+        String binTo;
+        BigDecimal minAmount;
+        BigDecimal maxAmount;
+        long minDelayMillis;
+        long maxDelayMillis;
+        String errorCode;
+        double rate = 1;
+        
+        private FaultRule(String spec) {
+            this.spec = spec;
+        }
+        
+        /**
+         * Parses e.g. {@code psp=ADYEN,bin=411111-411199,amount=100-500,delay=800-2000,rate=0.3}.
+         * Keys: {@code psp}; {@code merchant}, a merchant ID; {@code bin}, a
+         * prefix or an inclusive range of prefixes of equal length;
+         * {@code amount}, a band from its minimum up to, but excluding, its
+         * maximum, either of which may be left out; {@code delay} in
+         * milliseconds, added to the normal latency, fixed or a range drawn
+         * from uniformly; {@code error}, a retryable error code to fail
+         * with after the delay, e.g. {@code PSP_UNAVAILABLE}; {@code rate},
+         * the share of matching authorizations affected (default 1).
+         */
+        static FaultRule parse(String spec) {
+            FaultRule rule = new FaultRule(spec);
+            try {
+                for (String pair : spec.split(",")) {
+                    String[] kv = pair.trim().split("=", 2);
+                    if (kv.length != 2 || kv[1].isBlank()) {
+                        throw new IllegalArgumentException("expected key=value, got " + pair);
+                    }
+                    String value = kv[1].trim();
+                    String[] range = value.split("-", -1);
+                    switch (kv[0].trim()) {
+                        case "psp" -> rule.psp = value;
+                        case "merchant" -> rule.merchantId = UUID.fromString(value);
+                        case "bin" -> {
+                            rule.binFrom = range[0];
+                            rule.binTo = range.length == 2 ? range[1] : range[0];
+                            if (range.length > 2 || !rule.binFrom.matches("\\d{1,8}") || !rule.binTo.matches("\\d{1,8}")
+                                    || rule.binFrom.length() != rule.binTo.length() || rule.binFrom.compareTo(rule.binTo) > 0) {
+                                throw new IllegalArgumentException("invalid BIN range " + value);
+                            }
+                        }
+                        case "amount" -> {
+                            if (range.length != 2) {
+                                throw new IllegalArgumentException("amount must be MIN-MAX, got " + value);
+                            }
+                            rule.minAmount = range[0].isEmpty() ? null : new BigDecimal(range[0]);
+                            rule.maxAmount = range[1].isEmpty() ? null : new BigDecimal(range[1]);
+                        }
+                        case "delay" -> {
+                            rule.minDelayMillis = Long.parseLong(range[0]);
+                            rule.maxDelayMillis = range.length == 2 ? Long.parseLong(range[1]) : rule.minDelayMillis;
+                            if (range.length > 2 || rule.minDelayMillis < 0 || rule.maxDelayMillis < rule.minDelayMillis) {
+                                throw new IllegalArgumentException("invalid delay " + value);
+                            }
+                        }
+                        case "error" -> rule.errorCode = value;
+                        case "rate" -> {
+                            rule.rate = Double.parseDouble(value);
+                            if (!(rule.rate > 0 && rule.rate <= 1)) {
+                                throw new IllegalArgumentException("rate must be above 0 and at most 1, got " + value);
+                            }
+                        }
+                        default -> throw new IllegalArgumentException("unknown key " + kv[0]);
+                    }
+                }
+            } catch (IllegalArgumentException e) {
+                throw new IllegalArgumentException("Invalid fault rule " + spec + ": " + e.getMessage(), e);
+            }
+            if (rule.maxDelayMillis == 0 && rule.errorCode == null) {
+                throw new IllegalArgumentException("Invalid fault rule " + spec + ": needs a delay or an error");
+            }
+            return rule;
+        }
+        
+        boolean matches(String pspName, PSPAuthorizationRequest request) {
+            if (!"*".equals(psp) && !psp.equals(pspName)) {
+                return false;
+            }
+            if (merchantId != null && !merchantId.equals(request.getMerchantId())) {
+                return false;
+            }
+            if (binFrom != null) {
+                String bin = request.getCardBin();
+                if (bin == null || bin.length() < binFrom.length()) {
+                    return false;
+                }
+                String prefix = bin.substring(0, binFrom.length());
+                if (prefix.compareTo(binFrom) < 0 || prefix.compareTo(binTo) > 0) {
+                    return false;
+                }
+            }
+            BigDecimal amount = request.getAmount();
+            if (minAmount != null && amount.compareTo(minAmount) < 0) {
+                return false;
+            }
+            return maxAmount == null || amount.compareTo(maxAmount) < 0;
+        }
+    }
+}
@@ -0,0 +1,124 @@
+package com.paymentgateway.authorization.psp;
+
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+import java.security.SecureRandom;
+import java.util.Map;
+import java.util.Set;
+import java.util.regex.Pattern;
+
+/**
+ * Checks the card network applies to transaction initiation indicators, and
+ * the network transaction IDs it assigns. The first customer-initiated
+ * payment on a stored credential receives an ID; every later
+ * merchant-initiated payment must quote it so the issuer can link the
+ * payment to the cardholder's original consent. Tipping merchants may
+ * capture more than they authorized, up to the card brand's tolerance.
+ */
+public final class NetworkRules {
+    
+    // ISO 8583 response code 12: invalid transaction
+    public static final String INVALID_TRANSACTION = "12";
+    // ISO 8583 response code 58: transaction not permitted to terminal
+    public static final String NOT_PERMITTED = "58";
+    // ISO 8583 response code 13: invalid amount
+    public static final String INVALID_AMOUNT = "13";
+    
+    // Merchant categories that must pay out with funds transfers rather than
+    // refunds: betting, quasi-cash and money transfer
+    private static final Set<String> NO_STANDALONE_CREDIT_MCCS = Set.of("7995", "6051", "4829");
+    
+    // Merchant categories where a tip is added after authorization:
+    // restaurants, bars, fast food, taxis and beauty salons
+    private static final Set<String> TIP_MCCS = Set.of("5812", "5813", "5814", "4121", "7230");
+    
+    // Most a capture may exceed the authorized amount by, as a share of it
+    private static final Map<String, BigDecimal> TIP_TOLERANCES = Map.of(
+        "VISA", new BigDecimal("0.20"),
+        "MASTERCARD", new BigDecimal("0.20"),
+        "DISCOVER", new BigDecimal("0.20"),
+        "AMEX", new BigDecimal("0.30"));
+    private static final BigDecimal DEFAULT_TIP_TOLERANCE = new BigDecimal("0.15");
+    
+    private static final Pattern TRACE_ID = Pattern.compile("^[0-9A-Z]{15}$");
+    private static final String TRACE_ID_CHARS = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ";
+    private static final SecureRandom random = new SecureRandom();
+    
+    private NetworkRules() {}
+    
+    /**
+     * Returns the network's decline for inconsistent indicators, or null if
+     * the request may go on to the issuer
+     */
+    public static PSPAuthorizationResponse checkInitiation(PSPAuthorizationRequest request) {
+        if (!"MERCHANT".equals(request.getInitiator())) {
+            return null;
+        }
+        if (request.getStoredCredential() == null) {
+            return PSPAuthorizationResponse.declined(INVALID_TRANSACTION,
+                "Merchant-initiated transaction without a stored credential indicator");
+        }
+        String originalId = request.getOriginalNetworkTransactionId();
+        if (originalId == null || !TRACE_ID.matcher(originalId).matches()) {
+            return PSPAuthorizationResponse.declined(INVALID_TRANSACTION,
+                "Merchant-initiated transaction without a valid original trace ID");
+        }
+        return null;
+    }
+    
+    /**
+     * Returns the network's error code if it does not permit a standalone
+     * credit from this merchant, or null if it does
+     */
+    public static String checkCredit(PSPAuthorizationRequest request) {
+        if (request.getMerchantCategoryCode() != null
+                && NO_STANDALONE_CREDIT_MCCS.contains(request.getMerchantCategoryCode())) {
+            return NOT_PERMITTED;
+        }
+        return null;
+    }
+    
+    /**
+     * Whether the merchant category adds tips after authorization
+     */
+    public static boolean isTipEligible(String merchantCategoryCode) {
+        return merchantCategoryCode != null && TIP_MCCS.contains(merchantCategoryCode);
+    }
+    
+    /**
+     * The share of the authorized amount a brand lets a tip add
+     */
+    public static BigDecimal tipTolerance(String cardBrand) {
+        return cardBrand != null ? TIP_TOLERANCES.getOrDefault(cardBrand, DEFAULT_TIP_TOLERANCE) : DEFAULT_TIP_TOLERANCE;
+    }
+    
+    /**
+     * The most an authorization may be captured for: the authorized amount,
+     * plus the brand's tip tolerance for tipping merchants
+     */
+    public static BigDecimal captureLimit(String merchantCategoryCode, String cardBrand, BigDecimal authorized) {
+        if (!isTipEligible(merchantCategoryCode)) {
+            return authorized;
+        }
+        return authorized.add(authorized.multiply(tipTolerance(cardBrand)).setScale(2, RoundingMode.DOWN));
+    }
+    
+    /**
+     * Returns the network's error code if a capture exceeds the
+     * authorization's limit, or null if it may be cleared
+     */
+    public static String checkCapture(BigDecimal limit, BigDecimal amount) {
+        return amount.compareTo(limit) > 0 ? INVALID_AMOUNT : null;
+    }
+    
+    /**
+     * Assigns a network transaction ID to an approved authorization
+     */
+    public static String newTraceId() {
+        StringBuilder id = new StringBuilder(15);
+        for (int i = 0; i < 15; i++) {
+            id.append(TRACE_ID_CHARS.charAt(random.nextInt(TRACE_ID_CHARS.length())));
+        }
+        return id.toString();
+    }
+}
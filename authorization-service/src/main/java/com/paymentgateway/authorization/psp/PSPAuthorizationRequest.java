@@ -6,19 +6,66 @@ import java.util.UUID;
 public class PSPAuthorizationRequest {
     
     private UUID merchantId;
+    private String merchantCategoryCode;
     private BigDecimal amount;
     private String currency;
     private UUID cardTokenId;
     private String cardLastFour;
     private String cardBrand;
+    private String cardFingerprint;
+    // Leading digits of the PAN, for targeted fault injection
+    private String cardBin;
     private String description;
     private String referenceId;
+    // Name on the cardholder's statement (DE 43 merchant name)
+    private String descriptor;
+    
+    // Surcharge or convenience fee included in the amount (DE 28, amount
+    // transaction fee)
+    private BigDecimal surchargeAmount;
+    private BigDecimal convenienceFee;
+    
+    // Level 2 purchasing data, sent in the authorization's additional
+    // data; Level 3 line items travel only in clearing
+    private BigDecimal taxAmount;
+    private String customerCode;
+    
+    // Installment plan (count and MERCHANT or ISSUER), for the issuer to
+    // approve
+    private Integer installmentCount;
+    private String installmentPlanType;
     
     // 3DS authentication data
     private String cavv;
     private String eci;
     private String xid;
     
+    // Strong customer authentication
+    private boolean scaInScope;
+    private String scaExemption;
+    
+    // Transaction initiation indicators
+    private String channel;
+    private String initiator;
+    private String storedCredential;
+    private String originalNetworkTransactionId;
+    
+    // Encrypted PIN block of a PIN transaction, as hex
+    private String pinBlock;
+    
+    // EMV chip data of a chip transaction, for the issuer to verify the
+    // ARQC: the cryptogram and the CDOL1 data it covers, as hex
+    private String arqc;
+    private Integer atc;
+    private Integer panSequenceNumber;
+    private String chipTransactionData;
+    
+    // Card security code entered by the cardholder, for the issuer to
+    // verify; never stored
+    private String cvv;
+    private Integer expiryMonth;
+    private Integer expiryYear;
+    
     // Billing address
     private String billingStreet;
     private String billingCity;
@@ -40,6 +87,9 @@ public class PSPAuthorizationRequest {
     public UUID getMerchantId() { return merchantId; }
     public void setMerchantId(UUID merchantId) { this.merchantId = merchantId; }
     
+    public String getMerchantCategoryCode() { return merchantCategoryCode; }
+    public void setMerchantCategoryCode(String merchantCategoryCode) { this.merchantCategoryCode = merchantCategoryCode; }
+    
     public BigDecimal getAmount() { return amount; }
     public void setAmount(BigDecimal amount) { this.amount = amount; }
     
@@ -55,12 +105,39 @@ public class PSPAuthorizationRequest {
     public String getCardBrand() { return cardBrand; }
     public void setCardBrand(String cardBrand) { this.cardBrand = cardBrand; }
     
+    public String getCardFingerprint() { return cardFingerprint; }
+    public void setCardFingerprint(String cardFingerprint) { this.cardFingerprint = cardFingerprint; }
+    
+    public String getCardBin() { return cardBin; }
+    public void setCardBin(String cardBin) { this.cardBin = cardBin; }
+    
     public String getDescription() { return description; }
     public void setDescription(String description) { this.description = description; }
     
     public String getReferenceId() { return referenceId; }
     public void setReferenceId(String referenceId) { this.referenceId = referenceId; }
     
+    public String getDescriptor() { return descriptor; }
+    public void setDescriptor(String descriptor) { this.descriptor = descriptor; }
+    
+    public BigDecimal getSurchargeAmount() { return surchargeAmount; }
+    public void setSurchargeAmount(BigDecimal surchargeAmount) { this.surchargeAmount = surchargeAmount; }
+    
+    public BigDecimal getConvenienceFee() { return convenienceFee; }
+    public void setConvenienceFee(BigDecimal convenienceFee) { this.convenienceFee = convenienceFee; }
+    
+    public Integer getInstallmentCount() { return installmentCount; }
+    public void setInstallmentCount(Integer installmentCount) { this.installmentCount = installmentCount; }
+    
+    public String getInstallmentPlanType() { return installmentPlanType; }
+    public void setInstallmentPlanType(String installmentPlanType) { this.installmentPlanType = installmentPlanType; }
+    
+    public BigDecimal getTaxAmount() { return taxAmount; }
+    public void setTaxAmount(BigDecimal taxAmount) { this.taxAmount = taxAmount; }
+    
+    public String getCustomerCode() { return customerCode; }
+    public void setCustomerCode(String customerCode) { this.customerCode = customerCode; }
+    
     public String getCavv() { return cavv; }
     public void setCavv(String cavv) { this.cavv = cavv; }
     
@@ -70,6 +147,48 @@ public class PSPAuthorizationRequest {
     public String getXid() { return xid; }
     public void setXid(String xid) { this.xid = xid; }
     
+    public boolean isScaInScope() { return scaInScope; }
+    public void setScaInScope(boolean scaInScope) { this.scaInScope = scaInScope; }
+    
+    public String getScaExemption() { return scaExemption; }
+    public void setScaExemption(String scaExemption) { this.scaExemption = scaExemption; }
+    
+    public String getChannel() { return channel; }
+    public void setChannel(String channel) { this.channel = channel; }
+    
+    public String getInitiator() { return initiator; }
+    public void setInitiator(String initiator) { this.initiator = initiator; }
+    
+    public String getStoredCredential() { return storedCredential; }
+    public void setStoredCredential(String storedCredential) { this.storedCredential = storedCredential; }
+    
+    public String getOriginalNetworkTransactionId() { return originalNetworkTransactionId; }
+    public void setOriginalNetworkTransactionId(String originalNetworkTransactionId) { this.originalNetworkTransactionId = originalNetworkTransactionId; }
+    
+    public String getPinBlock() { return pinBlock; }
+    public void setPinBlock(String pinBlock) { this.pinBlock = pinBlock; }
+    
+    public String getArqc() { return arqc; }
+    public void setArqc(String arqc) { this.arqc = arqc; }
+    
+    public Integer getAtc() { return atc; }
+    public void setAtc(Integer atc) { this.atc = atc; }
+    
+    public Integer getPanSequenceNumber() { return panSequenceNumber; }
+    public void setPanSequenceNumber(Integer panSequenceNumber) { this.panSequenceNumber = panSequenceNumber; }
+    
+    public String getChipTransactionData() { return chipTransactionData; }
+    public void setChipTransactionData(String chipTransactionData) { this.chipTransactionData = chipTransactionData; }
+    
+    public String getCvv() { return cvv; }
+    public void setCvv(String cvv) { this.cvv = cvv; }
+    
+    public Integer getExpiryMonth() { return expiryMonth; }
+    public void setExpiryMonth(Integer expiryMonth) { this.expiryMonth = expiryMonth; }
+    
+    public Integer getExpiryYear() { return expiryYear; }
+    public void setExpiryYear(Integer expiryYear) { this.expiryYear = expiryYear; }
+    
     public String getBillingStreet() { return billingStreet; }
     public void setBillingStreet(String billingStreet) { this.billingStreet = billingStreet; }
     
@@ -7,6 +7,7 @@ public class PSPAuthorizationResponse {
     
     private boolean success;
     private String pspTransactionId;
+    private String networkTransactionId;
     private String status; // AUTHORIZED, DECLINED, ERROR
     private BigDecimal authorizedAmount;
     private String currency;
@@ -15,6 +16,16 @@ public class PSPAuthorizationResponse {
     private String errorCode;
     private String errorMessage;
     private Instant timestamp;
+    // Set by routing: the acquirer that answered and every attempt made
+    private String pspName;
+    private String routingPath;
+    // CVV2 result from the issuer: M, N or P, or null if not checked
+    private String cvvResult;
+    // AVS result from the issuer, or null if no billing address was sent
+    private String avsResult;
+    // Issuer's response to a chip card, ISO 8583 field 55 as hex: the ARPC
+    // and response code; null unless the issuer verified an ARQC
+    private String field55;
     
     // Constructors
     public PSPAuthorizationResponse() {
@@ -57,6 +68,9 @@ public class PSPAuthorizationResponse {
     public String getPspTransactionId() { return pspTransactionId; }
     public void setPspTransactionId(String pspTransactionId) { this.pspTransactionId = pspTransactionId; }
     
+    public String getNetworkTransactionId() { return networkTransactionId; }
+    public void setNetworkTransactionId(String networkTransactionId) { this.networkTransactionId = networkTransactionId; }
+    
     public String getStatus() { return status; }
     public void setStatus(String status) { this.status = status; }
     
@@ -80,4 +94,19 @@ public class PSPAuthorizationResponse {
     
     public Instant getTimestamp() { return timestamp; }
     public void setTimestamp(Instant timestamp) { this.timestamp = timestamp; }
+    
+    public String getPspName() { return pspName; }
+    public void setPspName(String pspName) { this.pspName = pspName; }
+    
+    public String getRoutingPath() { return routingPath; }
+    public void setRoutingPath(String routingPath) { this.routingPath = routingPath; }
+    
+    public String getCvvResult() { return cvvResult; }
+    public void setCvvResult(String cvvResult) { this.cvvResult = cvvResult; }
+    
+    public String getAvsResult() { return avsResult; }
+    public void setAvsResult(String avsResult) { this.avsResult = avsResult; }
+    
+    public String getField55() { return field55; }
+    public void setField55(String field55) { this.field55 = field55; }
 }
@@ -34,6 +34,11 @@ public interface PSPClient {
      */
     PSPRefundResponse refund(String pspTransactionId, BigDecimal amount, String currency);
     
+    /**
+     * Credit a card without a prior payment (standalone refund)
+     */
+    PSPRefundResponse credit(PSPAuthorizationRequest request);
+    
     /**
      * Check if this PSP is currently available
      */
@@ -2,16 +2,29 @@ package com.paymentgateway.authorization.psp;
 
 import com.paymentgateway.authorization.domain.PSPConfiguration;
 import com.paymentgateway.authorization.repository.PSPConfigurationRepository;
+import com.paymentgateway.authorization.resilience.CircuitBreakerOpenException;
+import com.paymentgateway.authorization.resilience.CircuitBreakerRegistry;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
 import org.springframework.stereotype.Service;
 
+import java.math.BigDecimal;
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
 import java.util.*;
+import java.util.concurrent.*;
 import java.util.stream.Collectors;
 
 /**
  * Service responsible for PSP routing and failover logic.
- * Selects the appropriate PSP based on merchant configuration and handles failover.
+ * Ranks the merchant's acquirers for each transaction by health, cost for the
+ * card brand and currency, and merchant priority, then fails over down the
+ * list on errors and timeouts. Each acquirer's network sits behind a circuit
+ * breaker, so one that keeps failing is skipped rather than retried. A
+ * candidate cost table can be routed in shadow alongside the active one.
  */
 @Service
 public class PSPRoutingService {
@@ -20,19 +33,62 @@ public class PSPRoutingService {
     
     private final PSPConfigurationRepository pspConfigurationRepository;
     private final Map<String, PSPClient> pspClients;
+    private final AcquirerCostTable costTable;
+    private final AcquirerHealth health;
+    private final LateResponseLog lateResponses;
+    private final CircuitBreakerRegistry circuitBreakers;
+    private final ShadowRouting shadow;
+    private final long timeoutMillis;
+    private final ExecutorService executor = Executors.newCachedThreadPool(runnable -> {
+        Thread thread = new Thread(runnable, "psp-authorize");
+        thread.setDaemon(true);
+        return thread;
+    });
     
     public PSPRoutingService(PSPConfigurationRepository pspConfigurationRepository,
                             List<PSPClient> pspClientList) {
+        this(pspConfigurationRepository, pspClientList, new AcquirerCostTable(""),
+             new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), new LateResponseLog(),
+             CircuitBreakerRegistry.withDefaults(), 5000);
+    }
+    
+    public PSPRoutingService(PSPConfigurationRepository pspConfigurationRepository,
+                            List<PSPClient> pspClientList,
+                            AcquirerCostTable costTable,
+                            AcquirerHealth health,
+                            LateResponseLog lateResponses,
+                            CircuitBreakerRegistry circuitBreakers,
+                            long timeoutMillis) {
+        this(pspConfigurationRepository, pspClientList, costTable, health, lateResponses, circuitBreakers,
+             new ShadowRouting(""), timeoutMillis);
+    }
+    
+    @Autowired
+    public PSPRoutingService(PSPConfigurationRepository pspConfigurationRepository,
+                            List<PSPClient> pspClientList,
+                            AcquirerCostTable costTable,
+                            AcquirerHealth health,
+                            LateResponseLog lateResponses,
+                            CircuitBreakerRegistry circuitBreakers,
+                            ShadowRouting shadow,
+                            @Value("${psp.routing.timeout-ms:5000}") long timeoutMillis) {
         this.pspConfigurationRepository = pspConfigurationRepository;
         this.pspClients = pspClientList.stream()
             .collect(Collectors.toMap(PSPClient::getPSPName, client -> client));
+        this.costTable = costTable;
+        this.health = health;
+        this.lateResponses = lateResponses;
+        this.circuitBreakers = circuitBreakers;
+        this.shadow = shadow;
+        this.timeoutMillis = timeoutMillis;
         
         logger.info("Initialized PSP routing service with {} PSP clients: {}",
                    pspClients.size(), pspClients.keySet());
     }
     
     /**
-     * Route authorization request to appropriate PSP with failover support
+     * Route authorization request to appropriate PSP with failover support.
+     * The response records the acquirer that answered and the attempts made.
      */
     public PSPAuthorizationResponse authorizeWithFailover(PSPAuthorizationRequest request) {
         UUID merchantId = request.getMerchantId();
@@ -49,18 +105,19 @@ public class PSPRoutingService {
         
         PSPAuthorizationResponse lastResponse = null;
         PSPException lastException = null;
+        List<String> attempts = new ArrayList<>();
+        List<PSPConfiguration> route = rank(pspConfigs, request);
+        if (shadow.isEnabled()) {
+            routeInShadow(pspConfigs, request, route);
+        }
         
-        // Try each PSP in priority order
-        for (PSPConfiguration config : pspConfigs) {
+        // Try each PSP in route order
+        for (PSPConfiguration config : route) {
             PSPClient pspClient = pspClients.get(config.getPspName());
             
-            if (pspClient == null) {
-                logger.warn("PSP client not found for: {}", config.getPspName());
-                continue;
-            }
-            
             if (!pspClient.isAvailable()) {
                 logger.warn("PSP {} is not available, trying next PSP", config.getPspName());
+                attempts.add(config.getPspName() + ":UNAVAILABLE");
                 continue;
             }
             
@@ -68,25 +125,40 @@ public class PSPRoutingService {
                 logger.info("Attempting authorization with PSP: {} (priority: {})",
                            config.getPspName(), config.getPriority());
                 
-                PSPAuthorizationResponse response = pspClient.authorize(request);
+                // Declines are answers; error responses and retryable
+                // exceptions count against the network
+                PSPAuthorizationResponse response = circuitBreakers.forNetwork(config.getPspName()).execute(
+                    () -> authorizeWithTimeout(pspClient, request),
+                    r -> !r.isSuccess() && !"DECLINED".equals(r.getStatus()),
+                    e -> !(e instanceof PSPException p) || p.isRetryable());
                 
-                if (response.isSuccess()) {
-                    logger.info("Authorization successful with PSP: {}", config.getPspName());
-                    return response;
-                } else if ("DECLINED".equals(response.getStatus())) {
-                    // Card declined - don't try other PSPs
-                    logger.info("Authorization declined by PSP: {} - {}", 
-                               config.getPspName(), response.getDeclineMessage());
-                    return response;
+                if (response.isSuccess() || "DECLINED".equals(response.getStatus())) {
+                    // Approved, or card declined - don't try other PSPs
+                    health.recordSuccess(config.getPspName());
+                    attempts.add(config.getPspName() + ":" + response.getStatus());
+                    if (response.isSuccess()) {
+                        logger.info("Authorization successful with PSP: {}", config.getPspName());
+                    } else {
+                        logger.info("Authorization declined by PSP: {} - {}", 
+                                   config.getPspName(), response.getDeclineMessage());
+                    }
+                    return recordRoute(response, config.getPspName(), attempts);
                 } else {
                     // Error response - try next PSP
                     logger.warn("Authorization error from PSP: {} - {}", 
                                config.getPspName(), response.getErrorMessage());
-                    lastResponse = response;
+                    health.recordFailure(config.getPspName());
+                    attempts.add(config.getPspName() + ":ERROR");
+                    lastResponse = recordRoute(response, config.getPspName(), attempts);
                 }
                 
+            } catch (CircuitBreakerOpenException e) {
+                logger.warn("{}, trying next PSP", e.getMessage());
+                attempts.add(config.getPspName() + ":CIRCUIT_OPEN");
             } catch (PSPException e) {
                 logger.error("PSP {} failed with exception: {}", config.getPspName(), e.getMessage());
+                health.recordFailure(config.getPspName());
+                attempts.add(config.getPspName() + ":" + e.getErrorCode());
                 lastException = e;
                 
                 // If not retryable, don't try other PSPs
@@ -111,8 +183,112 @@ public class PSPRoutingService {
         
         // No PSPs available
         logger.error("No PSPs available for merchant: {}", merchantId);
-        return PSPAuthorizationResponse.error("NO_PSP_AVAILABLE", 
-                                             "No payment service providers are currently available");
+        return recordRoute(PSPAuthorizationResponse.error("NO_PSP_AVAILABLE", 
+                                             "No payment service providers are currently available"),
+                           null, attempts);
+    }
+    
+    /**
+     * Orders the merchant's acquirers for this transaction: those that take
+     * the card brand and currency, healthy ones first, then cheapest first
+     * (unknown costs last), then by merchant priority
+     */
+    List<PSPConfiguration> rank(List<PSPConfiguration> pspConfigs, PSPAuthorizationRequest request) {
+        return rank(pspConfigs, request, costTable);
+    }
+    
+    private List<PSPConfiguration> rank(List<PSPConfiguration> pspConfigs, PSPAuthorizationRequest request,
+                                        AcquirerCostTable costs) {
+        List<PSPConfiguration> route = new ArrayList<>();
+        Map<String, BigDecimal> fees = new HashMap<>();
+        for (PSPConfiguration config : pspConfigs) {
+            String pspName = config.getPspName();
+            if (!pspClients.containsKey(pspName)) {
+                logger.warn("PSP client not found for: {}", pspName);
+                continue;
+            }
+            if (!costs.supports(pspName, request.getCardBrand(), request.getCurrency())) {
+                logger.debug("PSP {} does not accept {} {}", pspName, request.getCardBrand(), request.getCurrency());
+                continue;
+            }
+            route.add(config);
+            BigDecimal cost = costs.cost(pspName, request.getCardBrand(), request.getCurrency(), request.getAmount());
+            if (cost != null) {
+                fees.put(pspName, cost);
+            }
+        }
+        // The sort is stable, so equal acquirers keep the merchant's priority order
+        route.sort(Comparator
+            .comparing((PSPConfiguration c) -> !health.isHealthy(c.getPspName()))
+            .thenComparing(c -> fees.get(c.getPspName()), Comparator.nullsLast(Comparator.naturalOrder())));
+        return route;
+    }
+    
+    /**
+     * Ranks the acquirers again with the candidate cost table and records
+     * the two routes. The candidate's route is never tried, and a failure
+     * to rank it never fails the authorization.
+     */
+    private void routeInShadow(List<PSPConfiguration> pspConfigs, PSPAuthorizationRequest request,
+                               List<PSPConfiguration> route) {
+        try {
+            List<PSPConfiguration> candidate = rank(pspConfigs, request, shadow.getCandidate());
+            shadow.record(new ShadowRouting.Comparison(request, Instant.now(),
+                route.stream().map(PSPConfiguration::getPspName).toList(),
+                candidate.stream().map(PSPConfiguration::getPspName).toList()));
+        } catch (RuntimeException e) {
+            logger.warn("Shadow routing failed: {}", e.getMessage());
+        }
+    }
+    
+    /**
+     * Authorizes with one acquirer, giving up after the routing timeout. If a
+     * timed-out authorization is approved later it is voided, so the card is
+     * not left with a hold the merchant never sees.
+     */
+    private PSPAuthorizationResponse authorizeWithTimeout(PSPClient pspClient, PSPAuthorizationRequest request) {
+        Instant sentAt = Instant.now();
+        CompletableFuture<PSPAuthorizationResponse> future =
+            CompletableFuture.supplyAsync(() -> pspClient.authorize(request), executor);
+        try {
+            return future.get(timeoutMillis, TimeUnit.MILLISECONDS);
+        } catch (TimeoutException e) {
+            future.thenAccept(late -> reverseLateResponse(pspClient, request, late, sentAt));
+            throw new PSPException(pspClient.getPSPName(), "TIMEOUT",
+                                   "No response within " + timeoutMillis + "ms", true);
+        } catch (ExecutionException e) {
+            if (e.getCause() instanceof RuntimeException cause) {
+                throw cause;
+            }
+            throw new PSPException(pspClient.getPSPName(), "Authorization failed", e.getCause());
+        } catch (InterruptedException e) {
+            Thread.currentThread().interrupt();
+            throw new PSPException(pspClient.getPSPName(), "Request interrupted", e);
+        }
+    }
+    
+    private void reverseLateResponse(PSPClient pspClient, PSPAuthorizationRequest request,
+                                     PSPAuthorizationResponse late, Instant sentAt) {
+        LateResponseLog.Action action = LateResponseLog.Action.NONE;
+        if (late.isSuccess()) {
+            logger.warn("Voiding late approval {} from PSP {}", late.getPspTransactionId(), pspClient.getPSPName());
+            try {
+                action = pspClient.voidTransaction(late.getPspTransactionId()).isSuccess()
+                    ? LateResponseLog.Action.REVERSED : LateResponseLog.Action.REVERSAL_FAILED;
+            } catch (RuntimeException e) {
+                logger.error("Failed to void late approval {} from PSP {}",
+                            late.getPspTransactionId(), pspClient.getPSPName(), e);
+                action = LateResponseLog.Action.REVERSAL_FAILED;
+            }
+        }
+        lateResponses.record(new LateResponseLog.Entry(
+            pspClient.getPSPName(), request, late, sentAt, Instant.now(), action));
+    }
+    
+    private PSPAuthorizationResponse recordRoute(PSPAuthorizationResponse response, String pspName, List<String> attempts) {
+        response.setPspName(pspName);
+        response.setRoutingPath(String.join(" > ", attempts));
+        return response;
     }
     
     /**
@@ -0,0 +1,26 @@
+package com.paymentgateway.authorization.psp;
+
+/**
+ * What the issuer keeps to verify a card's PIN instead of the PIN: an IBM
+ * 3624 offset or a Visa PVV with the index of the key it was computed under,
+ * as the HSM derives them
+ */
+public final class PinReference {
+    
+    public static final String IBM_3624 = "IBM3624";
+    public static final String VISA_PVV = "VISA-PVV";
+    
+    private final String method;
+    private final String value;
+    private final int pvki;
+    
+    public PinReference(String method, String value, int pvki) {
+        this.method = method;
+        this.value = value;
+        this.pvki = pvki;
+    }
+    
+    public String getMethod() { return method; }
+    public String getValue() { return value; }
+    public int getPvki() { return pvki; }
+}
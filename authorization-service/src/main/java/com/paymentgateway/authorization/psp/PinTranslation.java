@@ -0,0 +1,69 @@
+package com.paymentgateway.authorization.psp;
+
+import com.google.protobuf.ByteString;
+import com.paymentgateway.api.hsm.v1.TranslatePINRequest;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.lang.Nullable;
+import org.springframework.stereotype.Service;
+
+import java.util.HexFormat;
+
+/**
+ * Translates PIN blocks from the acquirer's ZPK, the one its PIN pads
+ * encrypt under, to the issuer's ZPK before a payment goes to the PSP, with
+ * the HSM simulator's TranslatePIN command. The PIN is only in the clear
+ * inside the HSM. Without an acquirer ZPK configured, PIN blocks are taken
+ * to be under the issuer's ZPK already and pass through unchanged.
+ */
+@Service
+public class PinTranslation {
+    
+    private static final Logger logger = LoggerFactory.getLogger(PinTranslation.class);
+    
+    private final HsmClient hsm;
+    private final String acquirerZpkId;
+    private final String issuerZpkId;
+    
+    @Autowired
+    public PinTranslation(@Nullable HsmClient hsm,
+                          @Value("${psp.simulator.hsm.acquirer-zpk-id:}") String acquirerZpkId,
+                          @Value("${psp.simulator.hsm.zpk-id:issuer-zpk}") String issuerZpkId) {
+        if (!acquirerZpkId.isBlank() && hsm == null) {
+            throw new IllegalArgumentException("PIN translation from " + acquirerZpkId
+                + " needs psp.simulator.hsm.address");
+        }
+        this.hsm = hsm;
+        this.acquirerZpkId = acquirerZpkId;
+        this.issuerZpkId = issuerZpkId;
+        if (isEnabled()) {
+            logger.info("PIN blocks are translated from {} to {} by the HSM", acquirerZpkId, issuerZpkId);
+        }
+    }
+    
+    public boolean isEnabled() {
+        return !acquirerZpkId.isBlank();
+    }
+    
+    /**
+     * Returns a hex PIN block re-encrypted under the issuer's ZPK, or the
+     * block as it is when translation is off or there is none
+     *
+     * @throws RuntimeException if the block is malformed or the HSM fails
+     */
+    public String translate(String pan, @Nullable String pinBlock) {
+        if (!isEnabled() || pinBlock == null || pinBlock.isBlank()) {
+            return pinBlock;
+        }
+        byte[] block = HexFormat.of().parseHex(pinBlock);
+        byte[] translated = hsm.stub().translatePIN(TranslatePINRequest.newBuilder()
+            .setSourceZpkId(acquirerZpkId)
+            .setDestZpkId(issuerZpkId)
+            .setPinBlock(ByteString.copyFrom(block))
+            .setPan(pan)
+            .build()).getPinBlock().toByteArray();
+        return HexFormat.of().formatHex(translated);
+    }
+}
@@ -0,0 +1,21 @@
+package com.paymentgateway.authorization.psp;
+
+/**
+ * Verifies cardholder PINs for the simulated issuer without the issuer
+ * holding them: a test card's PIN is enrolled once into the reference the
+ * HSM derives from it, and PIN blocks from authorizations are checked
+ * against that reference.
+ */
+public interface PinVerifier {
+    
+    /**
+     * Sets a card's PIN, returning the reference to keep in its place
+     */
+    PinReference enroll(String pan, String pin);
+    
+    /**
+     * Whether an encrypted PIN block holds the card's PIN. A wrong PIN is
+     * false; failing to reach the HSM throws.
+     */
+    boolean verify(String pan, byte[] pinBlock, PinReference reference);
+}
@@ -0,0 +1,32 @@
+package com.paymentgateway.authorization.psp;
+
+/**
+ * Simulates the issuer side of PSD2: an issuer in scope of strong customer
+ * authentication soft-declines a payment that carries neither authentication
+ * data nor an exemption, asking the merchant to authenticate the cardholder
+ * with 3D Secure and resubmit.
+ */
+public final class ScaSoftDecline {
+    
+    // Visa and Mastercard response codes for "authentication required"
+    public static final String VISA_CODE = "1A";
+    public static final String MASTERCARD_CODE = "65";
+    
+    private ScaSoftDecline() {}
+    
+    /**
+     * Returns the issuer's soft decline for the request, or null if the
+     * issuer would go on to authorize it
+     */
+    public static PSPAuthorizationResponse check(PSPAuthorizationRequest request) {
+        if (!request.isScaInScope() || request.getCavv() != null || request.getScaExemption() != null) {
+            return null;
+        }
+        String code = "MASTERCARD".equals(request.getCardBrand()) ? MASTERCARD_CODE : VISA_CODE;
+        return PSPAuthorizationResponse.declined(code, "Strong customer authentication required");
+    }
+    
+    public static boolean isSoftDecline(String declineCode) {
+        return VISA_CODE.equals(declineCode) || MASTERCARD_CODE.equals(declineCode);
+    }
+}
@@ -0,0 +1,178 @@
+package com.paymentgateway.authorization.psp;
+
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.util.ArrayDeque;
+import java.util.Deque;
+import java.util.List;
+import java.util.Map;
+import java.util.TreeMap;
+import java.util.UUID;
+
+/**
+ * A candidate cost table, from psp.routing.shadow-costs, routed alongside
+ * the active one without affecting which acquirer is tried. Each
+ * authorization's two routes are tallied into a report of how often and
+ * how the candidate would have routed differently.
+ */
+@Component
+public class ShadowRouting {
+    
+    // Most recent divergent routes kept for the report
+    static final int MAX_DIVERGENCES = 200;
+    
+    private final AcquirerCostTable candidate;
+    private final boolean enabled;
+    
+    private Instant since = Instant.now();
+    private long evaluated;
+    private long divergent;
+    private final Map<String, Long> transitions = new TreeMap<>();
+    private final Deque<Comparison> divergences = new ArrayDeque<>();
+    
+    /**
+     * @param spec candidate cost table in the format of psp.routing.costs;
+     *             blank turns shadow routing off
+     */
+    public ShadowRouting(@Value("${psp.routing.shadow-costs:}") String spec) {
+        this.enabled = spec != null && !spec.isBlank();
+        this.candidate = new AcquirerCostTable(enabled ? spec : "");
+    }
+    
+    /**
+     * Whether a candidate cost table is configured
+     */
+    public boolean isEnabled() {
+        return enabled;
+    }
+    
+    public AcquirerCostTable getCandidate() {
+        return candidate;
+    }
+    
+    public synchronized void record(Comparison comparison) {
+        evaluated++;
+        transitions.merge(comparison.getActiveAcquirer() + "->" + comparison.getShadowAcquirer(), 1L, Long::sum);
+        if (comparison.isDivergent()) {
+            divergent++;
+            divergences.addFirst(comparison);
+            if (divergences.size() > MAX_DIVERGENCES) {
+                divergences.removeLast();
+            }
+        }
+    }
+    
+    public synchronized Report report() {
+        return new Report(enabled, since, evaluated, divergent, new TreeMap<>(transitions), List.copyOf(divergences));
+    }
+    
+    public synchronized void reset() {
+        since = Instant.now();
+        evaluated = 0;
+        divergent = 0;
+        transitions.clear();
+        divergences.clear();
+    }
+    
+    /**
+     * One authorization's route under the active cost table next to the
+     * route the candidate would have taken. Routes list acquirers in the
+     * order they would be tried.
+     */
+    public static class Comparison {
+        private final UUID merchantId;
+        private final BigDecimal amount;
+        private final String currency;
+        private final String cardBrand;
+        private final Instant evaluatedAt;
+        private final List<String> activeRoute;
+        private final List<String> shadowRoute;
+        
+        public Comparison(PSPAuthorizationRequest request, Instant evaluatedAt,
+                          List<String> activeRoute, List<String> shadowRoute) {
+            this.merchantId = request.getMerchantId();
+            this.amount = request.getAmount();
+            this.currency = request.getCurrency();
+            this.cardBrand = request.getCardBrand();
+            this.evaluatedAt = evaluatedAt;
+            this.activeRoute = List.copyOf(activeRoute);
+            this.shadowRoute = List.copyOf(shadowRoute);
+        }
+        
+        public UUID getMerchantId() { return merchantId; }
+        public BigDecimal getAmount() { return amount; }
+        public String getCurrency() { return currency; }
+        public String getCardBrand() { return cardBrand; }
+        public Instant getEvaluatedAt() { return evaluatedAt; }
+        public List<String> getActiveRoute() { return activeRoute; }
+        public List<String> getShadowRoute() { return shadowRoute; }
+        
+        /**
+         * The acquirer tried first, or NONE if no acquirer takes the card
+         */
+        public String getActiveAcquirer() {
+            return activeRoute.isEmpty() ? "NONE" : activeRoute.get(0);
+        }
+        
+        public String getShadowAcquirer() {
+            return shadowRoute.isEmpty() ? "NONE" : shadowRoute.get(0);
+        }
+        
+        /**
+         * Whether the candidate would have tried the acquirers differently
+         */
+        public boolean isDivergent() {
+            return !activeRoute.equals(shadowRoute);
+        }
+    }
+    
+    /**
+     * The comparison of the active and candidate cost tables since startup
+     * or the report was reset
+     */
+    public static class Report {
+        private final boolean enabled;
+        private final Instant since;
+        private final long evaluated;
+        private final long divergent;
+        private final Map<String, Long> transitions;
+        private final List<Comparison> recentDivergences;
+        
+        Report(boolean enabled, Instant since, long evaluated, long divergent,
+               Map<String, Long> transitions, List<Comparison> recentDivergences) {
+            this.enabled = enabled;
+            this.since = since;
+            this.evaluated = evaluated;
+            this.divergent = divergent;
+            this.transitions = transitions;
+            this.recentDivergences = recentDivergences;
+        }
+        
+        public boolean isEnabled() { return enabled; }
+        public Instant getSince() { return since; }
+        public long getEvaluated() { return evaluated; }
+        public long getDivergent() { return divergent; }
+        
+        /**
+         * Share of evaluated authorizations the candidate would have routed
+         * differently
+         */
+        public double getDivergenceRate() {
+            return evaluated == 0 ? 0.0 : (double) divergent / evaluated;
+        }
+        
+        /**
+         * Count of each active to candidate first acquirer pair, e.g.
+         * STRIPE->ADYEN
+         */
+        public Map<String, Long> getTransitions() { return transitions; }
+        
+        /**
+         * Up to the last 200 divergent routes, newest first
+         */
+        public List<Comparison> getRecentDivergences() { return recentDivergences; }
+    }
+}
@@ -0,0 +1,753 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.shared.rules.RuleSyntaxException;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.lang.Nullable;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.io.IOException;
+import java.math.BigDecimal;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.attribute.FileTime;
+import java.time.Instant;
+import java.util.ArrayList;
+import java.util.HexFormat;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.Set;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * Issuer behind the PSP simulators, keeping open-to-buy balances for test
+ * cards. An authorization holds funds, a capture posts the captured amount
+ * and releases the rest of the hold, a void releases the hold and a refund
+ * credits the account, so declines for insufficient funds follow from
+ * earlier transactions. Captures, refunds and credits are posted to the
+ * card's statement under the merchant's descriptor, as the cardholder would
+ * see them. Cards without a configured balance are not tracked.
+ * Cards with a PIN have it verified by the HSM on PIN transactions, with a
+ * try counter that blocks the PIN after too many wrong entries, and cards
+ * with a CVV2 have the one entered checked by the HSM under the CVK.
+ * Chip cards have the ARQC of chip transactions verified by the HSM, and
+ * get the ARPC for the issuer's response code back in field 55.
+ * Cards in the address book have the billing address of card-not-present
+ * payments compared with the one on file for AVS.
+ * Cards with installment plans approve the plan types and counts set for
+ * them; other test cards decline installments.
+ * Decisions the network took in its place while it was unavailable reach
+ * it later as advices, which it applies once each.
+ * Response codes, AVS and CVV2 results that do not follow from card data
+ * come from issuer rules, reloaded when their file changes.
+ */
+@Component
+public class SimulatedIssuer {
+    
+    private static final Logger logger = LoggerFactory.getLogger(SimulatedIssuer.class);
+    
+    // ISO 8583 response code 51: insufficient funds
+    public static final String INSUFFICIENT_FUNDS = "51";
+    // ISO 8583 response codes 55, 75 and 91: incorrect PIN, allowable number
+    // of PIN tries exceeded, issuer or switch inoperative
+    public static final String INCORRECT_PIN = "55";
+    public static final String PIN_TRIES_EXCEEDED = "75";
+    public static final String ISSUER_UNAVAILABLE = "91";
+    
+    // ISO 8583 response code N7: decline for CVV2 failure
+    public static final String CVV2_FAILURE = "N7";
+    // CVV2 result codes: match, no match, not processed
+    public static final String CVV_MATCH = "M";
+    public static final String CVV_NO_MATCH = "N";
+    public static final String CVV_NOT_PROCESSED = "P";
+    
+    // ISO 8583 response code 82: cryptogram (ARQC) failure, and 05, do not
+    // honor, which chip cards are told for declines without an ISO code
+    public static final String ARQC_FAILURE = "82";
+    public static final String DO_NOT_HONOR = "05";
+    
+    // AVS result codes: street and postal code match, street only, postal
+    // code only, neither, no address on file
+    public static final String AVS_FULL_MATCH = "Y";
+    public static final String AVS_STREET_ONLY = "A";
+    public static final String AVS_ZIP_ONLY = "Z";
+    public static final String AVS_NO_MATCH = "N";
+    public static final String AVS_UNAVAILABLE = "U";
+    
+    // ISO 8583 response code 57: transaction not permitted to cardholder
+    public static final String NOT_PERMITTED_TO_CARDHOLDER = "57";
+    
+    public static final int DEFAULT_PIN_TRY_LIMIT = 3;
+    
+    private final Map<String, Account> accounts = new ConcurrentHashMap<>();
+    private final Map<String, Hold> holds = new ConcurrentHashMap<>();
+    private final Map<String, Pin> pins = new ConcurrentHashMap<>();
+    private final Map<String, String> cvvCards = new ConcurrentHashMap<>();
+    private final Map<String, String> chipCards = new ConcurrentHashMap<>();
+    private final Map<String, Address> addresses = new ConcurrentHashMap<>();
+    private final Map<String, InstallmentPlans> installmentPlans = new ConcurrentHashMap<>();
+    // Response recorded for each stand-in advice taken in, empty for none
+    private final Map<Long, String> advices = new ConcurrentHashMap<>();
+    private final PinVerifier pinVerifier;
+    private final CvvVerifier cvvVerifier;
+    private final ArqcVerifier arqcVerifier;
+    private final int pinTryLimit;
+    private final Path rulesPath;
+    private volatile IssuerRules rules = IssuerRules.empty();
+    // Modification time of the last rules file parsed, valid or not, so a
+    // rejected file is reported once rather than on every poll
+    private FileTime rulesModifiedTime;
+    
+    /**
+     * @param accountSpec Comma-separated PAN:balance pairs, e.g.
+     *        "4111111111111111:500.00,5555555555554444:1000"
+     */
+    public SimulatedIssuer(String accountSpec) {
+        this(accountSpec, "", DEFAULT_PIN_TRY_LIMIT, null);
+    }
+    
+    /**
+     * @param pinSpec Comma-separated PAN:PIN pairs of test cards with a PIN,
+     *        enrolled with the HSM on first use; needs a PIN verifier
+     */
+    public SimulatedIssuer(String accountSpec, String pinSpec, int pinTryLimit, @Nullable PinVerifier pinVerifier) {
+        this(accountSpec, pinSpec, pinTryLimit, "", pinVerifier, null);
+    }
+    
+    /**
+     * @param cvvSpec Comma-separated PANs of test cards whose CVV2 is
+     *        derived under the HSM's CVK and verified; needs a CVV verifier
+     */
+    public SimulatedIssuer(String accountSpec, String pinSpec, int pinTryLimit, String cvvSpec,
+                           @Nullable PinVerifier pinVerifier, @Nullable CvvVerifier cvvVerifier) {
+        this(accountSpec, pinSpec, pinTryLimit, cvvSpec, "", "", pinVerifier, cvvVerifier, null);
+    }
+    
+    /**
+     * @param chipSpec Comma-separated PANs of chip cards whose keys derive
+     *        from the HSM's issuer master key; needs an ARQC verifier
+     * @param rulesFile YAML file of issuer rules, see {@link IssuerRules};
+     *        blank for none
+     */
+    @Autowired
+    public SimulatedIssuer(@Value("${psp.simulator.issuer-accounts:}") String accountSpec,
+                           @Value("${psp.simulator.issuer-pins:}") String pinSpec,
+                           @Value("${psp.simulator.pin-try-limit:3}") int pinTryLimit,
+                           @Value("${psp.simulator.issuer-cvv-cards:}") String cvvSpec,
+                           @Value("${psp.simulator.issuer-chip-cards:}") String chipSpec,
+                           @Value("${psp.simulator.issuer-rules-file:}") String rulesFile,
+                           @Nullable PinVerifier pinVerifier,
+                           @Nullable CvvVerifier cvvVerifier,
+                           @Nullable ArqcVerifier arqcVerifier) {
+        this.rulesPath = rulesFile == null || rulesFile.isBlank() ? null : Path.of(rulesFile);
+        this.pinVerifier = pinVerifier;
+        this.cvvVerifier = cvvVerifier;
+        this.arqcVerifier = arqcVerifier;
+        this.pinTryLimit = pinTryLimit;
+        for (String[] parts : pairs(accountSpec, "issuer account (want PAN:balance)")) {
+            setBalance(parts[0], new BigDecimal(parts[1]));
+        }
+        for (String[] parts : pairs(pinSpec, "issuer PIN (want PAN:PIN)")) {
+            if (pinVerifier == null) {
+                throw new IllegalStateException("Issuer PINs need an HSM, set psp.simulator.hsm.address");
+            }
+            pins.put(CardFingerprint.of(parts[0]), new Pin(parts[0], parts[1]));
+        }
+        if (cvvSpec != null && !cvvSpec.isBlank()) {
+            if (cvvVerifier == null) {
+                throw new IllegalStateException("Issuer CVV2 checks need an HSM, set psp.simulator.hsm.address");
+            }
+            for (String pan : cvvSpec.split(",")) {
+                cvvCards.put(CardFingerprint.of(pan.trim()), pan.trim());
+            }
+        }
+        if (chipSpec != null && !chipSpec.isBlank()) {
+            if (arqcVerifier == null) {
+                throw new IllegalStateException("Issuer chip cards need an HSM, set psp.simulator.hsm.address");
+            }
+            for (String pan : chipSpec.split(",")) {
+                chipCards.put(CardFingerprint.of(pan.trim()), pan.trim());
+            }
+        }
+        if (!accounts.isEmpty()) {
+            logger.info("Simulated issuer tracking balances for {} test cards", accounts.size());
+        }
+        if (!pins.isEmpty()) {
+            logger.info("Simulated issuer verifying PINs of {} test cards", pins.size());
+        }
+        if (!cvvCards.isEmpty()) {
+            logger.info("Simulated issuer verifying CVV2s of {} test cards", cvvCards.size());
+        }
+        if (!chipCards.isEmpty()) {
+            logger.info("Simulated issuer verifying ARQCs of {} chip cards", chipCards.size());
+        }
+        if (rulesPath != null) {
+            reloadRules();
+        }
+    }
+    
+    private static List<String[]> pairs(String spec, String what) {
+        List<String[]> pairs = new ArrayList<>();
+        if (spec == null || spec.isBlank()) {
+            return pairs;
+        }
+        for (String entry : spec.split(",")) {
+            String[] parts = entry.trim().split(":");
+            if (parts.length != 2) {
+                throw new IllegalArgumentException("Invalid " + what + ": " + entry.trim());
+            }
+            pairs.add(new String[] {parts[0].trim(), parts[1].trim()});
+        }
+        return pairs;
+    }
+    
+    /**
+     * Opens or resets a test card's account with the given open-to-buy
+     */
+    public void setBalance(String pan, BigDecimal openToBuy) {
+        accounts.put(CardFingerprint.of(pan), new Account(openToBuy));
+    }
+    
+    public boolean isTracked(String cardFingerprint) {
+        return cardFingerprint != null && accounts.containsKey(cardFingerprint);
+    }
+    
+    /**
+     * Remaining open-to-buy, or null for an untracked card
+     */
+    public BigDecimal getOpenToBuy(String cardFingerprint) {
+        Account account = cardFingerprint != null ? accounts.get(cardFingerprint) : null;
+        return account != null ? account.openToBuy() : null;
+    }
+    
+    /**
+     * Holds funds for an authorization. Returns false, holding nothing, if
+     * the card's open-to-buy does not cover the amount.
+     */
+    public boolean hold(String cardFingerprint, String pspTransactionId, BigDecimal amount) {
+        return hold(cardFingerprint, pspTransactionId, amount, null);
+    }
+    
+    /**
+     * Holds funds for an authorization, keeping the statement descriptor
+     * its capture and refunds are posted under
+     */
+    public boolean hold(String cardFingerprint, String pspTransactionId, BigDecimal amount, String descriptor) {
+        Account account = accounts.get(cardFingerprint);
+        if (account == null || !account.debit(amount)) {
+            return false;
+        }
+        holds.put(pspTransactionId, new Hold(account, amount, descriptor));
+        return true;
+    }
+    
+    /**
+     * Posts a capture. Any part of the hold that is not captured goes back to
+     * the open-to-buy, and a tip captured above the hold comes out of it.
+     */
+    public void capture(String pspTransactionId, BigDecimal amount) {
+        Hold hold = holds.get(pspTransactionId);
+        if (hold != null) {
+            hold.capture(amount);
+        }
+    }
+    
+    /**
+     * Takes in an advice of a decision the network made on the issuer's
+     * behalf. A stand-in approval holds its amount even past the
+     * open-to-buy, since the issuer has to honour it; captures, voids and
+     * refunds are posted as if they had come directly. An advice is applied
+     * once: a repeat changes nothing. Returns the response the issuer would
+     * have given a stand-in authorization, or null for other advices and
+     * untracked cards.
+     */
+    public String acceptAdvice(StandInProcessor.Advice advice) {
+        String response = advices.computeIfAbsent(advice.getId(), id -> {
+            String issuerResponse = apply(advice);
+            return issuerResponse != null ? issuerResponse : "";
+        });
+        return response.isEmpty() ? null : response;
+    }
+    
+    private String apply(StandInProcessor.Advice advice) {
+        switch (advice.getType()) {
+            case CAPTURE -> capture(advice.getPspTransactionId(), advice.getAmount());
+            case REVERSAL -> release(advice.getPspTransactionId());
+            case REFUND -> refund(advice.getPspTransactionId(), advice.getAmount());
+            case AUTHORIZATION -> {
+                Account account = accounts.get(advice.getCardFingerprint());
+                if (account == null) {
+                    return null;
+                }
+                if (!StandInProcessor.APPROVED.equals(advice.getResponseCode())) {
+                    return account.covers(advice.getAmount()) ? StandInProcessor.APPROVED : INSUFFICIENT_FUNDS;
+                }
+                boolean covered = account.debitHonoured(advice.getAmount());
+                holds.put(advice.getPspTransactionId(), new Hold(account, advice.getAmount(), advice.getDescriptor()));
+                return covered ? StandInProcessor.APPROVED : INSUFFICIENT_FUNDS;
+            }
+        }
+        return null;
+    }
+    
+    /**
+     * Releases an uncaptured hold when the authorization is voided
+     */
+    public void release(String pspTransactionId) {
+        Hold hold = holds.get(pspTransactionId);
+        if (hold != null) {
+            hold.release();
+        }
+    }
+    
+    public void refund(String pspTransactionId, BigDecimal amount) {
+        Hold hold = holds.get(pspTransactionId);
+        if (hold != null) {
+            hold.account.credit(amount);
+            hold.account.post(StatementLine.REFUND, hold.descriptor, amount.negate());
+        }
+    }
+    
+    /**
+     * Credits a card directly, for a refund with no original payment
+     */
+    public void credit(String cardFingerprint, BigDecimal amount) {
+        credit(cardFingerprint, amount, null);
+    }
+    
+    public void credit(String cardFingerprint, BigDecimal amount, String descriptor) {
+        Account account = cardFingerprint != null ? accounts.get(cardFingerprint) : null;
+        if (account != null) {
+            account.credit(amount);
+            account.post(StatementLine.CREDIT, descriptor, amount.negate());
+        }
+    }
+    
+    /**
+     * The card's statement, oldest line first, or null for an untracked card
+     */
+    public List<StatementLine> getStatement(String cardFingerprint) {
+        Account account = cardFingerprint != null ? accounts.get(cardFingerprint) : null;
+        return account != null ? account.statement() : null;
+    }
+    
+    /**
+     * Sets a test card's PIN through the HSM and resets its try counter
+     */
+    public void setPin(String pan, String pin) {
+        if (pinVerifier == null) {
+            throw new IllegalStateException("Issuer PINs need an HSM, set psp.simulator.hsm.address");
+        }
+        Pin entry = new Pin(pan, pin);
+        entry.reference(pinVerifier);
+        pins.put(CardFingerprint.of(pan), entry);
+    }
+    
+    /**
+     * Checks the PIN block of a PIN transaction (hex, under the ZPK the HSM
+     * verifier uses), returning null to go on with the authorization or the
+     * response code to decline with. Transactions without a PIN block and
+     * cards without a PIN are not checked. A correct PIN resets the try
+     * counter; once the wrong entries reach the limit the PIN is blocked,
+     * even for the correct one, until the counter is reset.
+     */
+    public String verifyPin(String cardFingerprint, String pinBlock) {
+        Pin pin = cardFingerprint != null ? pins.get(cardFingerprint) : null;
+        if (pin == null || pinBlock == null) {
+            return null;
+        }
+        synchronized (pin) {
+            if (pin.failures >= pinTryLimit) {
+                return PIN_TRIES_EXCEEDED;
+            }
+            boolean verified;
+            try {
+                verified = pinVerifier.verify(pin.pan, HexFormat.of().parseHex(pinBlock), pin.reference(pinVerifier));
+            } catch (RuntimeException e) {
+                logger.error("Simulated issuer could not verify a PIN: {}", e.getMessage());
+                return ISSUER_UNAVAILABLE;
+            }
+            if (verified) {
+                pin.failures = 0;
+                return null;
+            }
+            pin.failures++;
+            return pin.failures >= pinTryLimit ? PIN_TRIES_EXCEEDED : INCORRECT_PIN;
+        }
+    }
+    
+    /**
+     * Describes a response code returned by verifyPin
+     */
+    public static String pinDeclineMessage(String responseCode) {
+        return switch (responseCode) {
+            case INCORRECT_PIN -> "Incorrect PIN";
+            case PIN_TRIES_EXCEEDED -> "Allowable number of PIN tries exceeded";
+            default -> "Issuer unavailable";
+        };
+    }
+    
+    /**
+     * PIN tries left before the PIN is blocked, or null for a card without
+     * a PIN
+     */
+    public Integer getRemainingPinTries(String cardFingerprint) {
+        Pin pin = cardFingerprint != null ? pins.get(cardFingerprint) : null;
+        if (pin == null) {
+            return null;
+        }
+        synchronized (pin) {
+            return Math.max(0, pinTryLimit - pin.failures);
+        }
+    }
+    
+    /**
+     * Unblocks a card's PIN, as the issuer does after the cardholder calls
+     */
+    public void resetPinTries(String cardFingerprint) {
+        Pin pin = cardFingerprint != null ? pins.get(cardFingerprint) : null;
+        if (pin != null) {
+            synchronized (pin) {
+                pin.failures = 0;
+            }
+        }
+    }
+    
+    /**
+     * Checks the CVV2 entered for a card against the one the HSM derives
+     * from the PAN and the expiry date, returning the CVV2 result code, or
+     * null if the card has no CVV2 on file or no CVV2 was entered. An HSM
+     * that cannot be reached leaves the CVV2 not processed, which is not a
+     * decline on its own.
+     */
+    public String verifyCvv(String cardFingerprint, Integer expiryMonth, Integer expiryYear, String cvv) {
+        String pan = cardFingerprint != null ? cvvCards.get(cardFingerprint) : null;
+        if (pan == null || cvv == null || cvv.isBlank()) {
+            return null;
+        }
+        if (expiryMonth == null || expiryYear == null || !cvv.matches("\\d{3}")) {
+            return CVV_NO_MATCH;
+        }
+        String expiry = String.format("%02d%02d", expiryYear % 100, expiryMonth);
+        try {
+            return cvvVerifier.verify(pan, expiry, cvv) ? CVV_MATCH : CVV_NO_MATCH;
+        } catch (RuntimeException e) {
+            logger.error("Simulated issuer could not verify a CVV2: {}", e.getMessage());
+            return CVV_NOT_PROCESSED;
+        }
+    }
+    
+    /**
+     * Checks the ARQC of a chip transaction against the card and the
+     * transaction data, returning null to go on with the response or the
+     * response code to decline with instead. The HSM verifies the ARQC as
+     * it builds the issuer's answer to the card, so a verified ARQC leaves
+     * the response carrying ISO 8583 field 55 as hex, with the ARPC the
+     * card checks before it accepts the response code. Declines without an
+     * ISO 8583 code reach the card as do not honor. Transactions without an
+     * ARQC and cards not on file as chip cards are not checked. An HSM that
+     * cannot be reached declines, as the card cannot be authenticated.
+     */
+    public String verifyArqc(PSPAuthorizationRequest request, PSPAuthorizationResponse response) {
+        String pan = chipPan(request);
+        if (pan == null) {
+            return null;
+        }
+        byte[] transactionData;
+        byte[] arqc;
+        try {
+            transactionData = HexFormat.of().parseHex(request.getChipTransactionData());
+            arqc = HexFormat.of().parseHex(request.getArqc());
+        } catch (RuntimeException e) {
+            return ARQC_FAILURE;
+        }
+        String code = response.isSuccess() ? IssuerRules.APPROVED : response.getDeclineCode();
+        if (code == null || code.length() != 2) {
+            code = DO_NOT_HONOR;
+        }
+        byte[] field55;
+        try {
+            field55 = arqcVerifier.verify(pan, panSequenceNumber(request), request.getAtc(), transactionData, arqc, code);
+        } catch (RuntimeException e) {
+            logger.error("Simulated issuer could not verify an ARQC: {}", e.getMessage());
+            return ISSUER_UNAVAILABLE;
+        }
+        if (field55 == null) {
+            return ARQC_FAILURE;
+        }
+        response.setField55(HexFormat.of().formatHex(field55));
+        return null;
+    }
+    
+    private String chipPan(PSPAuthorizationRequest request) {
+        String pan = request.getCardFingerprint() != null ? chipCards.get(request.getCardFingerprint()) : null;
+        if (pan == null || request.getArqc() == null || request.getAtc() == null || request.getChipTransactionData() == null) {
+            return null;
+        }
+        return pan;
+    }
+    
+    private static int panSequenceNumber(PSPAuthorizationRequest request) {
+        return request.getPanSequenceNumber() != null ? request.getPanSequenceNumber() : 0;
+    }
+    
+    /**
+     * Puts a test card's billing address in the address book
+     */
+    public void setAddress(String pan, String street, String postalCode) {
+        addresses.put(CardFingerprint.of(pan), new Address(street, postalCode));
+    }
+    
+    /**
+     * Compares a billing address with the one on file for the card,
+     * returning the AVS result code, or null if no address was sent. As
+     * issuers do, only the numbers of the street are compared, and US ZIP+4
+     * codes on their first five digits.
+     */
+    public String verifyAddress(String cardFingerprint, String street, String postalCode) {
+        if (isBlank(street) && isBlank(postalCode)) {
+            return null;
+        }
+        Address address = cardFingerprint != null ? addresses.get(cardFingerprint) : null;
+        if (address == null) {
+            return AVS_UNAVAILABLE;
+        }
+        boolean streetMatch = !isBlank(street) && !isBlank(address.street)
+                && streetKey(street).equals(streetKey(address.street));
+        boolean zipMatch = !isBlank(postalCode) && !isBlank(address.postalCode)
+                && postalKey(postalCode).equals(postalKey(address.postalCode));
+        if (streetMatch) {
+            return zipMatch ? AVS_FULL_MATCH : AVS_STREET_ONLY;
+        }
+        return zipMatch ? AVS_ZIP_ONLY : AVS_NO_MATCH;
+    }
+    
+    public IssuerRules getRules() {
+        return rules;
+    }
+    
+    public void setRules(IssuerRules rules) {
+        this.rules = rules;
+    }
+    
+    /**
+     * Applies the issuer rules to an authorization, given the CVV2 and AVS
+     * results of the card data checks
+     */
+    public IssuerRules.Outcome applyRules(PSPAuthorizationRequest request, String cvvResult, String avsResult) {
+        return rules.evaluate(request, cvvResult, avsResult);
+    }
+    
+    /**
+     * Reloads the issuer rules file if it changed since the last load. A file
+     * that fails validation is logged and ignored; the previous rules stay
+     * active. Returns true if new rules became active.
+     */
+    @Scheduled(fixedDelayString = "${psp.simulator.issuer-rules-reload-interval-ms:5000}")
+    public synchronized boolean reloadRules() {
+        if (rulesPath == null) {
+            return false;
+        }
+        try {
+            FileTime modified = Files.getLastModifiedTime(rulesPath);
+            if (modified.equals(rulesModifiedTime)) {
+                return false;
+            }
+            rulesModifiedTime = modified;
+            IssuerRules loaded = IssuerRules.fromYaml(Files.readString(rulesPath));
+            rules = loaded;
+            logger.info("Loaded {} issuer rules from {}", loaded.size(), rulesPath);
+            return true;
+        } catch (IOException e) {
+            logger.error("Cannot read issuer rules from {}: {}", rulesPath, e.getMessage());
+        } catch (RuleSyntaxException e) {
+            logger.error("Rejected issuer rules from {}, keeping previous rules: {}", rulesPath, e.getMessage());
+        }
+        return false;
+    }
+    
+    /**
+     * Lets a test card pay in installments with the given plan types, up
+     * to a number of installments
+     */
+    public void setInstallments(String pan, Set<String> planTypes, int maxCount) {
+        installmentPlans.put(CardFingerprint.of(pan), new InstallmentPlans(Set.copyOf(planTypes), maxCount));
+    }
+    
+    /**
+     * Returns the decline code for an installment plan the card does not
+     * allow, or null if it does or no plan was asked for. Untracked cards
+     * approve any plan.
+     */
+    public String checkInstallments(String cardFingerprint, Integer count, String planType) {
+        if (count == null) {
+            return null;
+        }
+        InstallmentPlans plans = cardFingerprint != null ? installmentPlans.get(cardFingerprint) : null;
+        if (plans == null) {
+            return isTracked(cardFingerprint) ? NOT_PERMITTED_TO_CARDHOLDER : null;
+        }
+        return plans.types.contains(planType) && count <= plans.maxCount ? null : NOT_PERMITTED_TO_CARDHOLDER;
+    }
+    
+    private static boolean isBlank(String value) {
+        return value == null || value.isBlank();
+    }
+    
+    private static String streetKey(String street) {
+        String digits = street.replaceAll("[^0-9]", "");
+        return digits.isEmpty() ? street.replaceAll("[^A-Za-z]", "").toUpperCase(Locale.ROOT) : digits;
+    }
+    
+    private static String postalKey(String postalCode) {
+        String key = postalCode.replaceAll("[^A-Za-z0-9]", "").toUpperCase(Locale.ROOT);
+        return key.matches("\\d{9}") ? key.substring(0, 5) : key;
+    }
+    
+    private static final class Address {
+        private final String street;
+        private final String postalCode;
+        
+        Address(String street, String postalCode) {
+            this.street = street;
+            this.postalCode = postalCode;
+        }
+    }
+    
+    private static final class InstallmentPlans {
+        private final Set<String> types;
+        private final int maxCount;
+        
+        InstallmentPlans(Set<String> types, int maxCount) {
+            this.types = types;
+            this.maxCount = maxCount;
+        }
+    }
+    
+    private static final class Pin {
+        private final String pan;
+        private String clearPin;
+        private PinReference reference;
+        private int failures;
+        
+        Pin(String pan, String clearPin) {
+            this.pan = pan;
+            this.clearPin = clearPin;
+        }
+        
+        /**
+         * The PIN's offset or PVV, enrolling the configured PIN on first use
+         * so the issuer keeps only the reference
+         */
+        synchronized PinReference reference(PinVerifier verifier) {
+            if (reference == null) {
+                reference = verifier.enroll(pan, clearPin);
+                clearPin = null;
+            }
+            return reference;
+        }
+    }
+    
+    /**
+     * A posted transaction as it appears on the cardholder's statement:
+     * purchases are positive, refunds and credits negative
+     */
+    public static final class StatementLine {
+        public static final String PURCHASE = "PURCHASE";
+        public static final String REFUND = "REFUND";
+        public static final String CREDIT = "CREDIT";
+        
+        private final Instant postedAt;
+        private final String type;
+        private final String descriptor;
+        private final BigDecimal amount;
+        
+        StatementLine(Instant postedAt, String type, String descriptor, BigDecimal amount) {
+            this.postedAt = postedAt;
+            this.type = type;
+            this.descriptor = descriptor;
+            this.amount = amount;
+        }
+        
+        public Instant getPostedAt() { return postedAt; }
+        public String getType() { return type; }
+        public String getDescriptor() { return descriptor; }
+        public BigDecimal getAmount() { return amount; }
+    }
+    
+    private static final class Account {
+        private BigDecimal openToBuy;
+        private final List<StatementLine> statement = new ArrayList<>();
+        
+        Account(BigDecimal openToBuy) {
+            this.openToBuy = openToBuy;
+        }
+        
+        synchronized BigDecimal openToBuy() {
+            return openToBuy;
+        }
+        
+        synchronized List<StatementLine> statement() {
+            return List.copyOf(statement);
+        }
+        
+        synchronized void post(String type, String descriptor, BigDecimal amount) {
+            statement.add(new StatementLine(Instant.now(), type, descriptor != null ? descriptor : "UNKNOWN MERCHANT", amount));
+        }
+        
+        synchronized boolean debit(BigDecimal amount) {
+            if (openToBuy.compareTo(amount) < 0) {
+                return false;
+            }
+            openToBuy = openToBuy.subtract(amount);
+            return true;
+        }
+        
+        synchronized void credit(BigDecimal amount) {
+            openToBuy = openToBuy.add(amount);
+        }
+        
+        synchronized boolean covers(BigDecimal amount) {
+            return openToBuy.compareTo(amount) >= 0;
+        }
+        
+        /**
+         * Debits an amount the issuer has to honour, even past the
+         * open-to-buy, returning whether the open-to-buy covered it
+         */
+        synchronized boolean debitHonoured(BigDecimal amount) {
+            boolean covered = covers(amount);
+            openToBuy = openToBuy.subtract(amount);
+            return covered;
+        }
+    }
+    
+    private static final class Hold {
+        private final Account account;
+        private final String descriptor;
+        private BigDecimal held;
+        
+        Hold(Account account, BigDecimal held, String descriptor) {
+            this.account = account;
+            this.held = held;
+            this.descriptor = descriptor;
+        }
+        
+        synchronized void capture(BigDecimal amount) {
+            account.credit(held.subtract(amount));
+            held = BigDecimal.ZERO;
+            if (amount.signum() > 0) {
+                account.post(StatementLine.PURCHASE, descriptor, amount);
+            }
+        }
+        
+        synchronized void release() {
+            account.credit(held);
+            held = BigDecimal.ZERO;
+        }
+    }
+}
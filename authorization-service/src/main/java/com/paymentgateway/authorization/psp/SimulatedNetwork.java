@@ -0,0 +1,229 @@
+package com.paymentgateway.authorization.psp;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.stereotype.Component;
+
+import java.math.BigDecimal;
+import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * The card network and issuer behind the PSP simulators. Authorizations
+ * pass the injected latency and faults, the network's initiation rules,
+ * stand-in while the issuer is unavailable, SCA soft declines and the
+ * issuer's PIN, CVV, AVS, rule, installment and funds checks; approvals
+ * get a network trace ID and a capture limit. Chip cards then have their
+ * ARQC verified and get the issuer's field 55 response. The PSP simulators add only
+ * what differs between PSPs: their latency, ID formats and approval rate.
+ */
+@Component
+public class SimulatedNetwork {
+    
+    private static final Logger logger = LoggerFactory.getLogger(SimulatedNetwork.class);
+    
+    private final SimulatedIssuer issuer;
+    private final LatencyScenarios latency;
+    private final StandInProcessor standIn;
+    // Most each approved authorization may be captured for
+    private final Map<String, BigDecimal> captureLimits = new ConcurrentHashMap<>();
+    
+    public SimulatedNetwork(SimulatedIssuer issuer, LatencyScenarios latency, StandInProcessor standIn) {
+        this.issuer = issuer;
+        this.latency = latency;
+        this.standIn = standIn;
+    }
+    
+    /**
+     * Authorizes a payment through the network and issuer, after the PSP's
+     * latency and any fault injected for it
+     */
+    public PSPAuthorizationResponse authorize(Profile psp, PSPAuthorizationRequest request, String pspTransactionId)
+            throws InterruptedException {
+        LatencyScenarios.Injection injection = latency.plan(psp.name, request, psp.latencyMillis);
+        Thread.sleep(injection.getDelayMillis()); // Simulate network latency
+        injection.throwIfError(psp.name);
+        
+        PSPAuthorizationResponse networkDecline = NetworkRules.checkInitiation(request);
+        if (networkDecline != null) {
+            logger.warn("{}: Authorization rejected by network - {}", psp.name, networkDecline.getDeclineMessage());
+            return networkDecline;
+        }
+        
+        if (standIn.isIssuerUnavailable(request.getCardBin())) {
+            PSPAuthorizationResponse response = standIn.authorize(request, pspTransactionId);
+            if (response.isSuccess()) {
+                captureLimits.put(pspTransactionId, NetworkRules.captureLimit(
+                    request.getMerchantCategoryCode(), request.getCardBrand(), request.getAmount()));
+            }
+            logger.warn("{}: Issuer unavailable, network stood in - approved={}, code={}",
+                       psp.name, response.isSuccess(), response.getDeclineCode());
+            return response;
+        }
+        
+        PSPAuthorizationResponse softDecline = ScaSoftDecline.check(request);
+        if (softDecline != null) {
+            logger.warn("{}: Authorization soft-declined - code={}, authentication required", psp.name, softDecline.getDeclineCode());
+            return softDecline;
+        }
+        
+        PSPAuthorizationResponse response = decide(psp, request, pspTransactionId);
+        // The HSM verifies a chip card's ARQC as it answers the decision in
+        // field 55, so a cryptogram that fails undoes an approval
+        String arqcDecline = issuer.verifyArqc(request, response);
+        if (arqcDecline != null) {
+            if (response.isSuccess()) {
+                issuer.release(pspTransactionId);
+                captureLimits.remove(pspTransactionId);
+            }
+            logger.warn("{}: Authorization declined - ARQC check failed, code={}", psp.name, arqcDecline);
+            return PSPAuthorizationResponse.declined(arqcDecline, SimulatedIssuer.ARQC_FAILURE.equals(arqcDecline)
+                ? "Chip cryptogram verification failed" : "Issuer unavailable");
+        }
+        return response;
+    }
+    
+    /**
+     * The issuer's decision on an authorization, before its chip card, if
+     * any, is authenticated
+     */
+    private PSPAuthorizationResponse decide(Profile psp, PSPAuthorizationRequest request, String pspTransactionId) {
+        String cardFingerprint = request.getCardFingerprint();
+        String pinDecline = issuer.verifyPin(cardFingerprint, request.getPinBlock());
+        if (pinDecline != null) {
+            logger.warn("{}: Authorization declined - PIN check failed, code={}", psp.name, pinDecline);
+            return PSPAuthorizationResponse.declined(pinDecline, SimulatedIssuer.pinDeclineMessage(pinDecline));
+        }
+        String cvvResult = issuer.verifyCvv(cardFingerprint, request.getExpiryMonth(), request.getExpiryYear(), request.getCvv());
+        String avsResult = issuer.verifyAddress(cardFingerprint, request.getBillingStreet(), request.getBillingZip());
+        IssuerRules.Outcome ruled = issuer.applyRules(request, cvvResult, avsResult);
+        cvvResult = ruled.getCvvResult();
+        avsResult = ruled.getAvsResult();
+        if (ruled.isDecline()) {
+            logger.warn("{}: Authorization declined by issuer rule {} - code={}", psp.name, ruled.getRule(), ruled.getResponseCode());
+            PSPAuthorizationResponse response = PSPAuthorizationResponse.declined(ruled.getResponseCode(), ruled.getMessage());
+            response.setCvvResult(cvvResult);
+            response.setAvsResult(avsResult);
+            return response;
+        }
+        if (SimulatedIssuer.CVV_NO_MATCH.equals(cvvResult)) {
+            logger.warn("{}: Authorization declined - CVV2 mismatch", psp.name);
+            PSPAuthorizationResponse response = PSPAuthorizationResponse.declined(SimulatedIssuer.CVV2_FAILURE, "CVV2 verification failed");
+            response.setCvvResult(cvvResult);
+            return response;
+        }
+        String installmentDecline = issuer.checkInstallments(cardFingerprint, request.getInstallmentCount(),
+            request.getInstallmentPlanType());
+        if (installmentDecline != null) {
+            logger.warn("{}: Authorization declined - {} installment plan of {} not permitted",
+                       psp.name, request.getInstallmentPlanType(), request.getInstallmentCount());
+            return PSPAuthorizationResponse.declined(installmentDecline, "Installment plan not permitted for this card");
+        }
+        if (issuer.isTracked(cardFingerprint)
+                && !issuer.hold(cardFingerprint, pspTransactionId, request.getAmount(), request.getDescriptor())) {
+            logger.warn("{}: Authorization declined - insufficient funds", psp.name);
+            return PSPAuthorizationResponse.declined(SimulatedIssuer.INSUFFICIENT_FUNDS, "Insufficient funds");
+        }
+        
+        // Test cards with a balance, and payments an issuer rule approves, are
+        // approved once funds are held; the rest are approved at the PSP's rate
+        if (issuer.isTracked(cardFingerprint) || ruled.isApproval() || Math.random() < psp.approvalRate) {
+            logger.info("{}: Authorization successful - pspTransactionId={}", psp.name, pspTransactionId);
+            PSPAuthorizationResponse response = PSPAuthorizationResponse.success(pspTransactionId, request.getAmount(), request.getCurrency());
+            response.setNetworkTransactionId(NetworkRules.newTraceId());
+            captureLimits.put(pspTransactionId, NetworkRules.captureLimit(
+                request.getMerchantCategoryCode(), request.getCardBrand(), request.getAmount()));
+            response.setCvvResult(cvvResult);
+            response.setAvsResult(avsResult);
+            return response;
+        }
+        logger.warn("{}: Authorization declined - {}", psp.name, psp.declineCode);
+        return PSPAuthorizationResponse.declined(psp.declineCode, psp.declineMessage);
+    }
+    
+    /**
+     * Captures an authorization up to its capture limit, through stand-in
+     * if the network approved it there
+     */
+    public PSPCaptureResponse capture(String pspName, String pspTransactionId, BigDecimal amount, String currency) {
+        BigDecimal limit = captureLimits.get(pspTransactionId);
+        String networkError = limit != null ? NetworkRules.checkCapture(limit, amount) : null;
+        if (networkError != null) {
+            logger.warn("{}: Capture rejected by network - amount {} exceeds limit {}", pspName, amount, limit);
+            PSPCaptureResponse rejected = new PSPCaptureResponse(false, pspTransactionId);
+            rejected.setErrorCode(networkError);
+            rejected.setErrorMessage("Capture amount exceeds the authorized amount plus the brand's tip tolerance");
+            return rejected;
+        }
+        
+        if (!standIn.defer(StandInProcessor.Type.CAPTURE, pspTransactionId, amount)) {
+            issuer.capture(pspTransactionId, amount);
+        }
+        captureLimits.remove(pspTransactionId);
+        PSPCaptureResponse response = new PSPCaptureResponse(true, pspTransactionId);
+        response.setCapturedAmount(amount);
+        response.setCurrency(currency);
+        return response;
+    }
+    
+    /**
+     * Releases an authorization's hold
+     */
+    public void release(String pspTransactionId) {
+        if (!standIn.defer(StandInProcessor.Type.REVERSAL, pspTransactionId, null)) {
+            issuer.release(pspTransactionId);
+        }
+        captureLimits.remove(pspTransactionId);
+    }
+    
+    /**
+     * Refunds a captured payment to the card
+     */
+    public void refund(String pspTransactionId, BigDecimal amount) {
+        if (!standIn.defer(StandInProcessor.Type.REFUND, pspTransactionId, amount)) {
+            issuer.refund(pspTransactionId, amount);
+        }
+    }
+    
+    /**
+     * Pays a standalone credit to the card, if the network permits them for
+     * the merchant's category
+     */
+    public PSPRefundResponse credit(String pspName, PSPAuthorizationRequest request, String creditId) {
+        String networkError = NetworkRules.checkCredit(request);
+        if (networkError != null) {
+            logger.warn("{}: Standalone credit rejected by network - code={}", pspName, networkError);
+            PSPRefundResponse response = new PSPRefundResponse(false, null, null);
+            response.setErrorCode(networkError);
+            response.setErrorMessage("Standalone credits not permitted for merchant category " + request.getMerchantCategoryCode());
+            return response;
+        }
+        
+        issuer.credit(request.getCardFingerprint(), request.getAmount(), request.getDescriptor());
+        PSPRefundResponse response = new PSPRefundResponse(true, creditId, null);
+        response.setRefundedAmount(request.getAmount());
+        response.setCurrency(request.getCurrency());
+        return response;
+    }
+    
+    /**
+     * What sets a PSP simulator apart: its name, its normal authorization
+     * latency, and the share of unscreened authorizations it approves and
+     * how it declines the rest
+     */
+    public static final class Profile {
+        private final String name;
+        private final long latencyMillis;
+        private final double approvalRate;
+        private final String declineCode;
+        private final String declineMessage;
+        
+        public Profile(String name, long latencyMillis, double approvalRate, String declineCode, String declineMessage) {
+            this.name = name;
+            this.latencyMillis = latencyMillis;
+            this.approvalRate = approvalRate;
+            this.declineCode = declineCode;
+            this.declineMessage = declineMessage;
+        }
+    }
+}
@@ -0,0 +1,489 @@
+package com.paymentgateway.authorization.psp;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.math.BigDecimal;
+import java.time.Clock;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.ArrayList;
+import java.util.Collections;
+import java.util.HashSet;
+import java.util.Iterator;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Set;
+import java.util.TreeMap;
+import java.util.TreeSet;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.function.DoubleSupplier;
+
+/**
+ * Stand-in processing (STIP) by the simulated card network. While the
+ * issuer of a BIN range is marked unavailable, the network answers its
+ * authorizations within the STIP limits set for the range: an amount per
+ * transaction and a cumulative amount per card per day, above which it
+ * declines with the range's response code. Ranges without limits are
+ * declined with 91. Each stand-in decision is queued as an 0120 advice,
+ * and captures, voids and refunds of a stand-in approval the issuer has
+ * not yet heard of as 0220 and 0420 advices. Once the issuer is available
+ * again the advices are forwarded in order; one the issuer does not
+ * acknowledge (0130/0230/0430) is repeated (0121/0221/0421) on the next
+ * pass. Reconciliation compares each stand-in decision with the response
+ * the issuer would have given.
+ */
+@Component
+public class StandInProcessor {
+    
+    private static final Logger logger = LoggerFactory.getLogger(StandInProcessor.class);
+    
+    // ISO 8583 response code 00: approved
+    public static final String APPROVED = "00";
+    // ISO 8583 response code 61: exceeds withdrawal amount limit
+    public static final String EXCEEDS_AMOUNT_LIMIT = "61";
+    
+    // Acknowledged advices kept for listing and reconciliation; pending ones
+    // are always kept
+    static final int MAX_ADVICES = 1000;
+    
+    /**
+     * What an advice tells the issuer of
+     */
+    public enum Type {
+        AUTHORIZATION("0120", "0121", "0130"),
+        CAPTURE("0220", "0221", "0230"),
+        REFUND("0220", "0221", "0230"),
+        REVERSAL("0420", "0421", "0430");
+        
+        private final String mti;
+        private final String repeatMti;
+        private final String ackMti;
+        
+        Type(String mti, String repeatMti, String ackMti) {
+            this.mti = mti;
+            this.repeatMti = repeatMti;
+            this.ackMti = ackMti;
+        }
+    }
+    
+    /**
+     * How a stand-in authorization compares with the issuer's own decision
+     */
+    public enum Reconciliation {
+        PENDING,               // Not yet acknowledged by the issuer
+        MATCHED,               // The issuer would have decided the same
+        ISSUER_WOULD_DECLINE,  // A stand-in approval the issuer would have declined
+        ISSUER_WOULD_APPROVE,  // A stand-in decline the issuer would have approved
+        NOT_TRACKED            // The issuer keeps no balance for the card
+    }
+    
+    private final SimulatedIssuer issuer;
+    private final Set<String> unavailable = ConcurrentHashMap.newKeySet();
+    private final List<Limit> limits = new ArrayList<>();
+    private final double ackLossRate;
+    private final DoubleSupplier random;
+    private final Clock clock;
+    
+    private long sequence;
+    private final Map<Long, Advice> advices = new LinkedHashMap<>();
+    // Stand-in approvals with advices the issuer has not acknowledged yet
+    private final Map<String, Advice> openTransactions = new ConcurrentHashMap<>();
+    private final Map<String, DailyTotal> dailyTotals = new ConcurrentHashMap<>();
+    
+    /**
+     * A network that never stands in: every issuer is available
+     */
+    public StandInProcessor(SimulatedIssuer issuer) {
+        this(issuer, "", "", 0, Math::random, Clock.systemUTC());
+    }
+    
+    /**
+     * @param unavailableSpec Comma-separated BIN prefixes whose issuer is
+     *        unavailable from startup
+     * @param limitSpec Comma-separated {@code BIN:MAX_AMOUNT:DAILY_AMOUNT}
+     *        entries, optionally followed by {@code :CODE}, the response code
+     *        for amounts above a limit (default 61)
+     * @param ackLossRate Share of advices the issuer does not acknowledge,
+     *        so repeats can be exercised
+     */
+    @Autowired
+    public StandInProcessor(SimulatedIssuer issuer,
+                            @Value("${psp.simulator.stip.unavailable-issuers:}") String unavailableSpec,
+                            @Value("${psp.simulator.stip.limits:}") String limitSpec,
+                            @Value("${psp.simulator.stip.ack-loss-rate:0}") double ackLossRate) {
+        this(issuer, unavailableSpec, limitSpec, ackLossRate, Math::random, Clock.systemUTC());
+    }
+    
+    StandInProcessor(SimulatedIssuer issuer, String unavailableSpec, String limitSpec, double ackLossRate,
+                     DoubleSupplier random, Clock clock) {
+        this.issuer = issuer;
+        this.ackLossRate = ackLossRate;
+        this.random = random;
+        this.clock = clock;
+        if (ackLossRate < 0 || ackLossRate >= 1) {
+            throw new IllegalArgumentException("STIP ack loss rate must be at least 0 and below 1, got " + ackLossRate);
+        }
+        for (String bin : unavailableSpec.split(",")) {
+            if (!bin.isBlank()) {
+                setIssuerAvailable(bin.trim(), false);
+            }
+        }
+        for (String item : limitSpec.split(",")) {
+            if (!item.isBlank()) {
+                limits.add(Limit.parse(item.trim()));
+            }
+        }
+        if (!unavailable.isEmpty()) {
+            logger.info("Network standing in for unavailable issuers of BINs {}", unavailable);
+        }
+    }
+    
+    /**
+     * Marks the issuer of a BIN range unavailable, so the network stands in
+     * for it, or available again, forwarding the advices queued for it
+     */
+    public void setIssuerAvailable(String binPrefix, boolean available) {
+        if (!binPrefix.matches("\\d{1,8}")) {
+            throw new IllegalArgumentException("Invalid BIN prefix: " + binPrefix);
+        }
+        if (available) {
+            unavailable.remove(binPrefix);
+            forwardAdvices();
+        } else {
+            unavailable.add(binPrefix);
+        }
+    }
+    
+    /**
+     * BIN prefixes whose issuer is unavailable
+     */
+    public Set<String> getUnavailableIssuers() {
+        return new TreeSet<>(unavailable);
+    }
+    
+    public boolean isIssuerUnavailable(String cardBin) {
+        if (cardBin == null) {
+            return false;
+        }
+        for (String prefix : unavailable) {
+            if (cardBin.startsWith(prefix)) {
+                return true;
+            }
+        }
+        return false;
+    }
+    
+    /**
+     * Answers an authorization on behalf of its unavailable issuer and
+     * queues the advice. PINs, CVV2s and addresses are not checked: the
+     * network holds neither the issuer's keys nor its records, so PIN
+     * transactions are declined with 91.
+     */
+    public synchronized PSPAuthorizationResponse authorize(PSPAuthorizationRequest request, String pspTransactionId) {
+        Limit limit = limitFor(request.getCardBin());
+        String responseCode = APPROVED;
+        String declineMessage = null;
+        if (limit == null || request.getPinBlock() != null) {
+            responseCode = SimulatedIssuer.ISSUER_UNAVAILABLE;
+            declineMessage = "Issuer unavailable";
+        } else if (request.getAmount().compareTo(limit.maxAmount) > 0
+                || !withinDailyLimit(request.getCardFingerprint(), request.getAmount(), limit.dailyAmount)) {
+            responseCode = limit.responseCode;
+            declineMessage = "Exceeds stand-in limit";
+        }
+        
+        Advice advice = queue(Type.AUTHORIZATION, pspTransactionId, request.getCardFingerprint(), request.getCardBin(),
+                              request.getAmount(), request.getCurrency(), request.getDescriptor(), responseCode);
+        if (declineMessage != null) {
+            return PSPAuthorizationResponse.declined(responseCode, declineMessage);
+        }
+        openTransactions.put(pspTransactionId, advice);
+        PSPAuthorizationResponse response = PSPAuthorizationResponse.success(pspTransactionId, request.getAmount(),
+                                                                             request.getCurrency());
+        response.setNetworkTransactionId(NetworkRules.newTraceId());
+        return response;
+    }
+    
+    /**
+     * Queues a capture, void or refund of a stand-in approval as an advice
+     * while the issuer has not acknowledged the approval, so it reaches the
+     * issuer after it. Returns false, queuing nothing, for other
+     * transactions, which go to the issuer directly.
+     */
+    public synchronized boolean defer(Type type, String pspTransactionId, BigDecimal amount) {
+        Advice original = openTransactions.get(pspTransactionId);
+        if (original == null) {
+            return false;
+        }
+        queue(type, pspTransactionId, original.cardFingerprint, original.cardBin,
+              amount != null ? amount : original.amount, original.currency, original.descriptor, APPROVED);
+        return true;
+    }
+    
+    /**
+     * Forwards pending advices, oldest first, to issuers that are available.
+     * An advice that is not acknowledged holds back the later advices of its
+     * transaction until it is, so the issuer sees them in order.
+     *
+     * @return The number of advices acknowledged
+     */
+    @Scheduled(fixedDelayString = "${psp.simulator.stip.forward-interval-ms:5000}")
+    public synchronized int forwardAdvices() {
+        Set<String> heldBack = new HashSet<>();
+        int acknowledged = 0;
+        for (Advice advice : advices.values()) {
+            if (!advice.isPending() || heldBack.contains(advice.pspTransactionId)
+                    || isIssuerUnavailable(advice.cardBin)) {
+                continue;
+            }
+            String messageType = advice.send();
+            String issuerResponse = issuer.acceptAdvice(advice);
+            if (random.getAsDouble() < ackLossRate) {
+                logger.warn("Issuer did not acknowledge {} advice {}, repeating it", messageType, advice.id);
+                heldBack.add(advice.pspTransactionId);
+                continue;
+            }
+            advice.acknowledge(clock.instant(), issuerResponse);
+            acknowledged++;
+        }
+        openTransactions.keySet().removeIf(pspTransactionId -> !hasPending(pspTransactionId));
+        prune();
+        if (acknowledged > 0) {
+            logger.info("Issuers acknowledged {} stand-in advices", acknowledged);
+        }
+        return acknowledged;
+    }
+    
+    /**
+     * Advices, newest first
+     */
+    public synchronized List<Advice> getAdvices() {
+        List<Advice> list = new ArrayList<>(advices.values());
+        Collections.reverse(list);
+        return list;
+    }
+    
+    /**
+     * Counts of stand-in authorizations by how they reconcile with the
+     * issuer's view
+     */
+    public synchronized Map<Reconciliation, Long> reconcile() {
+        Map<Reconciliation, Long> counts = new TreeMap<>();
+        for (Advice advice : advices.values()) {
+            if (advice.type == Type.AUTHORIZATION) {
+                counts.merge(advice.getReconciliation(), 1L, Long::sum);
+            }
+        }
+        return counts;
+    }
+    
+    private Advice queue(Type type, String pspTransactionId, String cardFingerprint, String cardBin,
+                         BigDecimal amount, String currency, String descriptor, String responseCode) {
+        Advice advice = new Advice(++sequence, type, pspTransactionId, cardFingerprint, cardBin, amount, currency,
+                                   descriptor, responseCode, clock.instant());
+        advices.put(advice.id, advice);
+        logger.info("Network stood in for issuer of BIN {}: {} advice {} with response {}",
+                    cardBin, type.mti, advice.id, responseCode);
+        return advice;
+    }
+    
+    private boolean hasPending(String pspTransactionId) {
+        for (Advice advice : advices.values()) {
+            if (advice.isPending() && advice.pspTransactionId.equals(pspTransactionId)) {
+                return true;
+            }
+        }
+        return false;
+    }
+    
+    private void prune() {
+        Iterator<Advice> it = advices.values().iterator();
+        int excess = advices.size() - MAX_ADVICES;
+        while (excess > 0 && it.hasNext()) {
+            if (!it.next().isPending()) {
+                it.remove();
+                excess--;
+            }
+        }
+    }
+    
+    private Limit limitFor(String cardBin) {
+        Limit best = null;
+        if (cardBin != null) {
+            for (Limit limit : limits) {
+                if (cardBin.startsWith(limit.binPrefix)
+                        && (best == null || limit.binPrefix.length() > best.binPrefix.length())) {
+                    best = limit;
+                }
+            }
+        }
+        return best;
+    }
+    
+    /**
+     * Adds an approval to the card's total for the day if it stays within
+     * the daily limit. Cards are told apart by fingerprint; without one only
+     * the per-transaction limit applies.
+     */
+    private boolean withinDailyLimit(String cardFingerprint, BigDecimal amount, BigDecimal dailyAmount) {
+        if (cardFingerprint == null) {
+            return amount.compareTo(dailyAmount) <= 0;
+        }
+        LocalDate today = LocalDate.now(clock);
+        DailyTotal total = dailyTotals.get(cardFingerprint);
+        BigDecimal spent = total != null && total.day.equals(today) ? total.amount : BigDecimal.ZERO;
+        if (spent.add(amount).compareTo(dailyAmount) > 0) {
+            return false;
+        }
+        dailyTotals.put(cardFingerprint, new DailyTotal(today, spent.add(amount)));
+        return true;
+    }
+    
+    private static final class DailyTotal {
+        final LocalDate day;
+        final BigDecimal amount;
+        
+        DailyTotal(LocalDate day, BigDecimal amount) {
+            this.day = day;
+            this.amount = amount;
+        }
+    }
+    
+    private static final class Limit {
+        final String binPrefix;
+        final BigDecimal maxAmount;
+        final BigDecimal dailyAmount;
+        final String responseCode;
+        
+        private Limit(String binPrefix, BigDecimal maxAmount, BigDecimal dailyAmount, String responseCode) {
+            this.binPrefix = binPrefix;
+            this.maxAmount = maxAmount;
+            this.dailyAmount = dailyAmount;
+            this.responseCode = responseCode;
+        }
+        
+        static Limit parse(String spec) {
+            String[] parts = spec.split(":");
+            if ((parts.length != 3 && parts.length != 4) || !parts[0].matches("\\d{1,8}")) {
+                throw new IllegalArgumentException("Invalid STIP limit (want BIN:MAX_AMOUNT:DAILY_AMOUNT[:CODE]): " + spec);
+            }
+            return new Limit(parts[0], new BigDecimal(parts[1]), new BigDecimal(parts[2]),
+                             parts.length == 4 ? parts[3] : EXCEEDS_AMOUNT_LIMIT);
+        }
+    }
+    
+    /**
+     * A decision the network made on the issuer's behalf (the STIP
+     * indicator of the message), kept until the issuer acknowledges it
+     */
+    public static final class Advice {
+        private final long id;
+        private final Type type;
+        private final String pspTransactionId;
+        private final String cardFingerprint;
+        private final String cardBin;
+        private final BigDecimal amount;
+        private final String currency;
+        private final String descriptor;
+        private final String responseCode;
+        private final Instant createdAt;
+        private int attempts;
+        private Instant acknowledgedAt;
+        private String issuerResponseCode;
+        
+        Advice(long id, Type type, String pspTransactionId, String cardFingerprint, String cardBin, BigDecimal amount,
+               String currency, String descriptor, String responseCode, Instant createdAt) {
+            this.id = id;
+            this.type = type;
+            this.pspTransactionId = pspTransactionId;
+            this.cardFingerprint = cardFingerprint;
+            this.cardBin = cardBin;
+            this.amount = amount;
+            this.currency = currency;
+            this.descriptor = descriptor;
+            this.responseCode = responseCode;
+            this.createdAt = createdAt;
+        }
+        
+        public long getId() { return id; }
+        public Type getType() { return type; }
+        public String getPspTransactionId() { return pspTransactionId; }
+        public String getCardBin() { return cardBin; }
+        public BigDecimal getAmount() { return amount; }
+        public String getCurrency() { return currency; }
+        public Instant getCreatedAt() { return createdAt; }
+        
+        String getCardFingerprint() { return cardFingerprint; }
+        String getDescriptor() { return descriptor; }
+        
+        /** The response the network gave on the issuer's behalf */
+        public String getResponseCode() { return responseCode; }
+        
+        /** The message type the advice was last sent as: the original, or a repeat */
+        public synchronized String getMessageType() {
+            return attempts <= 1 ? type.mti : type.repeatMti;
+        }
+        
+        public synchronized int getAttempts() { return attempts; }
+        
+        /** The issuer's acknowledgement message type, or null while pending */
+        public synchronized String getAcknowledgement() {
+            return acknowledgedAt != null ? type.ackMti : null;
+        }
+        
+        public synchronized Instant getAcknowledgedAt() { return acknowledgedAt; }
+        
+        synchronized boolean isPending() {
+            return acknowledgedAt == null;
+        }
+        
+        /**
+         * Counts an attempt to send the advice, returning the message type
+         * it goes as
+         */
+        synchronized String send() {
+            attempts++;
+            return getMessageType();
+        }
+        
+        synchronized void acknowledge(Instant at, String issuerResponse) {
+            acknowledgedAt = at;
+            issuerResponseCode = issuerResponse;
+        }
+        
+        /**
+         * The response the issuer would have given a stand-in
+         * authorization, or null while pending, for other advices and for
+         * cards the issuer does not track
+         */
+        public synchronized String getIssuerResponseCode() { return issuerResponseCode; }
+        
+        /**
+         * How a stand-in authorization reconciles with the issuer, or null
+         * for other advices
+         */
+        public synchronized Reconciliation getReconciliation() {
+            if (type != Type.AUTHORIZATION) {
+                return null;
+            }
+            if (acknowledgedAt == null) {
+                return Reconciliation.PENDING;
+            }
+            if (issuerResponseCode == null) {
+                return Reconciliation.NOT_TRACKED;
+            }
+            boolean standInApproved = APPROVED.equals(responseCode);
+            boolean issuerApproves = APPROVED.equals(issuerResponseCode);
+            if (standInApproved == issuerApproves) {
+                return Reconciliation.MATCHED;
+            }
+            return standInApproved ? Reconciliation.ISSUER_WOULD_DECLINE : Reconciliation.ISSUER_WOULD_APPROVE;
+        }
+    }
+}
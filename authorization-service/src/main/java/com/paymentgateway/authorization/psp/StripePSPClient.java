@@ -18,7 +18,17 @@ public class StripePSPClient implements PSPClient {
     private static final Logger logger = LoggerFactory.getLogger(StripePSPClient.class);
     private static final String PSP_NAME = "STRIPE";
     
+    // Stripe answers in about 50ms, approves 90% of authorizations the
+    // issuer does not screen and declines the rest for insufficient funds
+    private static final SimulatedNetwork.Profile PROFILE = new SimulatedNetwork.Profile(
+        PSP_NAME, 50, 0.9, "insufficient_funds", "Card has insufficient funds");
+    
     private boolean available = true;
+    private final SimulatedNetwork network;
+    
+    public StripePSPClient(SimulatedNetwork network) {
+        this.network = network;
+    }
     
     @Override
     public String getPSPName() {
@@ -40,20 +50,13 @@ public class StripePSPClient implements PSPClient {
         // Simulate Stripe API call
         try {
             // In production, this would make an HTTP request to Stripe's API
-            Thread.sleep(50); // Simulate network latency
-            
             // Generate Stripe-style transaction ID
             String pspTransactionId = "ch_stripe_" + UUID.randomUUID().toString().substring(0, 20);
+            return network.authorize(PROFILE, request, pspTransactionId);
             
-            // Simulate authorization success (90% success rate)
-            if (Math.random() < 0.9) {
-                logger.info("Stripe: Authorization successful - pspTransactionId={}", pspTransactionId);
-                return PSPAuthorizationResponse.success(pspTransactionId, request.getAmount(), request.getCurrency());
-            } else {
-                logger.warn("Stripe: Authorization declined - insufficient_funds");
-                return PSPAuthorizationResponse.declined("insufficient_funds", "Card has insufficient funds");
-            }
-            
+        } catch (PSPException e) {
+            logger.warn("Stripe: Authorization failed - {}", e.getMessage());
+            throw e;
         } catch (InterruptedException e) {
             Thread.currentThread().interrupt();
             throw new PSPException(PSP_NAME, "Request interrupted", e);
@@ -75,9 +78,10 @@ public class StripePSPClient implements PSPClient {
         try {
             Thread.sleep(30); // Simulate network latency
             
-            PSPCaptureResponse response = new PSPCaptureResponse(true, pspTransactionId);
-            response.setCapturedAmount(amount);
-            response.setCurrency(currency);
+            PSPCaptureResponse response = network.capture(PSP_NAME, pspTransactionId, amount, currency);
+            if (!response.isSuccess()) {
+                return response;
+            }
             
             logger.info("Stripe: Capture successful - pspTransactionId={}", pspTransactionId);
             return response;
@@ -102,6 +106,7 @@ public class StripePSPClient implements PSPClient {
         try {
             Thread.sleep(30); // Simulate network latency
             
+            network.release(pspTransactionId);
             PSPVoidResponse response = new PSPVoidResponse(true, pspTransactionId);
             logger.info("Stripe: Void successful - pspTransactionId={}", pspTransactionId);
             return response;
@@ -128,6 +133,7 @@ public class StripePSPClient implements PSPClient {
             Thread.sleep(40); // Simulate network latency
             
             String refundId = "re_stripe_" + UUID.randomUUID().toString().substring(0, 20);
+            network.refund(pspTransactionId, amount);
             PSPRefundResponse response = new PSPRefundResponse(true, refundId, pspTransactionId);
             response.setRefundedAmount(amount);
             response.setCurrency(currency);
@@ -144,6 +150,38 @@ public class StripePSPClient implements PSPClient {
         }
     }
     
+    @Override
+    public PSPRefundResponse credit(PSPAuthorizationRequest request) {
+        logger.info("Stripe: Standalone credit - merchantId={}, amount={}, currency={}",
+                   request.getMerchantId(), request.getAmount(), request.getCurrency());
+        
+        if (!available) {
+            throw new PSPException(PSP_NAME, "PSP_UNAVAILABLE", "Stripe is currently unavailable", true);
+        }
+        
+        validateAuthorizationRequest(request);
+        
+        try {
+            Thread.sleep(40); // Simulate network latency
+            
+            String creditId = "cr_stripe_" + UUID.randomUUID().toString().substring(0, 20);
+            PSPRefundResponse response = network.credit(PSP_NAME, request, creditId);
+            if (!response.isSuccess()) {
+                return response;
+            }
+            
+            logger.info("Stripe: Standalone credit successful - creditId={}", creditId);
+            return response;
+            
+        } catch (InterruptedException e) {
+            Thread.currentThread().interrupt();
+            throw new PSPException(PSP_NAME, "Request interrupted", e);
+        } catch (Exception e) {
+            logger.error("Stripe: Standalone credit failed", e);
+            throw new PSPException(PSP_NAME, "Credit request failed", e);
+        }
+    }
+    
     @Override
     public boolean isAvailable() {
         return available;
@@ -0,0 +1,53 @@
+package com.paymentgateway.authorization.qr;
+
+import java.math.BigDecimal;
+
+/**
+ * The fields of an EMVCo merchant-presented QR code that this gateway
+ * issues and reads
+ */
+public final class EmvQrCode {
+    
+    private final boolean dynamic;
+    private final String globallyUniqueId;
+    private final String merchantId;
+    private final String qrPaymentId;
+    private final String merchantCategoryCode;
+    private final String currency;
+    private final BigDecimal amount;
+    private final String countryCode;
+    private final String merchantName;
+    private final String merchantCity;
+    private final String billNumber;
+    
+    public EmvQrCode(boolean dynamic, String globallyUniqueId, String merchantId, String qrPaymentId,
+                     String merchantCategoryCode, String currency, BigDecimal amount, String countryCode,
+                     String merchantName, String merchantCity, String billNumber) {
+        this.dynamic = dynamic;
+        this.globallyUniqueId = globallyUniqueId;
+        this.merchantId = merchantId;
+        this.qrPaymentId = qrPaymentId;
+        this.merchantCategoryCode = merchantCategoryCode;
+        this.currency = currency;
+        this.amount = amount;
+        this.countryCode = countryCode;
+        this.merchantName = merchantName;
+        this.merchantCity = merchantCity;
+        this.billNumber = billNumber;
+    }
+    
+    // Point of initiation 12, for one payment; 11 is a static, reusable code
+    public boolean isDynamic() { return dynamic; }
+    public String getGloballyUniqueId() { return globallyUniqueId; }
+    public String getMerchantId() { return merchantId; }
+    public String getQrPaymentId() { return qrPaymentId; }
+    public String getMerchantCategoryCode() { return merchantCategoryCode; }
+    // ISO 4217 alphabetic code, from the numeric one in the payload
+    public String getCurrency() { return currency; }
+    // Null if the customer is to enter it
+    public BigDecimal getAmount() { return amount; }
+    public String getCountryCode() { return countryCode; }
+    public String getMerchantName() { return merchantName; }
+    public String getMerchantCity() { return merchantCity; }
+    public String getBillNumber() { return billNumber; }
+}
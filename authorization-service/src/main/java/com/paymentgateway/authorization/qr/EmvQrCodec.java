@@ -0,0 +1,235 @@
+package com.paymentgateway.authorization.qr;
+
+import java.math.BigDecimal;
+import java.nio.charset.StandardCharsets;
+import java.util.Currency;
+import java.util.HashMap;
+import java.util.LinkedHashMap;
+import java.util.Map;
+import java.util.regex.Pattern;
+
+/**
+ * Writes and reads EMVCo merchant-presented QR payloads (EMV QRCPS). A
+ * payload is a run of ID-length-value objects, two digits each for the ID
+ * and length, ending with a CRC-16/CCITT of everything before its value.
+ * This gateway's codes carry its merchant ID and QR payment ID in merchant
+ * account information template 26 and the QR payment ID again as the
+ * reference label of the additional data template 62.
+ */
+public final class EmvQrCodec {
+    
+    // Globally unique identifier of the merchant account template 26
+    public static final String GLOBALLY_UNIQUE_ID = "COM.PAYMENTGATEWAY.SIM";
+    
+    static final String PAYLOAD_FORMAT_INDICATOR = "00";
+    static final String POINT_OF_INITIATION = "01";
+    static final String MERCHANT_ACCOUNT = "26";
+    static final String MERCHANT_CATEGORY_CODE = "52";
+    static final String CURRENCY = "53";
+    static final String AMOUNT = "54";
+    static final String COUNTRY_CODE = "58";
+    static final String MERCHANT_NAME = "59";
+    static final String MERCHANT_CITY = "60";
+    static final String ADDITIONAL_DATA = "62";
+    static final String CRC = "63";
+    
+    private static final String ACCOUNT_GUI = "00";
+    private static final String ACCOUNT_MERCHANT_ID = "01";
+    private static final String ACCOUNT_QR_PAYMENT_ID = "02";
+    private static final String BILL_NUMBER = "01";
+    private static final String REFERENCE_LABEL = "05";
+    
+    private static final int MAX_PAYLOAD = 512;
+    private static final int MAX_NAME = 25;
+    private static final int MAX_CITY = 15;
+    
+    private static final Pattern TWO_DIGITS = Pattern.compile("\\d{2}");
+    private static final Pattern MCC = Pattern.compile("\\d{4}");
+    private static final Pattern NUMERIC_CURRENCY = Pattern.compile("\\d{3}");
+    private static final Pattern COUNTRY = Pattern.compile("[A-Z]{2}");
+    private static final Pattern AMOUNT_FORMAT = Pattern.compile("\\d{1,10}(\\.\\d{0,2})?");
+    
+    private static final Map<String, Currency> BY_NUMERIC_CODE = new HashMap<>();
+    
+    static {
+        for (Currency currency : Currency.getAvailableCurrencies()) {
+            BY_NUMERIC_CODE.putIfAbsent(currency.getNumericCodeAsString(), currency);
+        }
+    }
+    
+    private EmvQrCodec() {}
+    
+    /**
+     * The payload of a code, with its CRC. The merchant name and city are
+     * cut to the lengths EMVCo allows.
+     */
+    public static String encode(EmvQrCode code) {
+        Currency currency = Currency.getInstance(code.getCurrency());
+        StringBuilder payload = new StringBuilder();
+        append(payload, PAYLOAD_FORMAT_INDICATOR, "01");
+        append(payload, POINT_OF_INITIATION, code.isDynamic() ? "12" : "11");
+        StringBuilder account = new StringBuilder();
+        append(account, ACCOUNT_GUI, code.getGloballyUniqueId());
+        append(account, ACCOUNT_MERCHANT_ID, code.getMerchantId());
+        append(account, ACCOUNT_QR_PAYMENT_ID, code.getQrPaymentId());
+        append(payload, MERCHANT_ACCOUNT, account.toString());
+        append(payload, MERCHANT_CATEGORY_CODE, code.getMerchantCategoryCode());
+        append(payload, CURRENCY, currency.getNumericCodeAsString());
+        if (code.getAmount() != null) {
+            append(payload, AMOUNT, code.getAmount().setScale(currency.getDefaultFractionDigits()).toPlainString());
+        }
+        append(payload, COUNTRY_CODE, code.getCountryCode());
+        append(payload, MERCHANT_NAME, text(code.getMerchantName(), MAX_NAME));
+        append(payload, MERCHANT_CITY, text(code.getMerchantCity(), MAX_CITY));
+        StringBuilder additional = new StringBuilder();
+        append(additional, BILL_NUMBER, code.getBillNumber());
+        append(additional, REFERENCE_LABEL, code.getQrPaymentId());
+        append(payload, ADDITIONAL_DATA, additional.toString());
+        payload.append(CRC).append("04");
+        return payload.append(crc(payload)).toString();
+    }
+    
+    /**
+     * Read a payload, checking its structure and CRC
+     *
+     * @throws InvalidQrCodeException If it is malformed or its CRC does not match
+     */
+    public static EmvQrCode decode(String payload) {
+        if (payload == null || payload.isBlank()) {
+            throw new InvalidQrCodeException("QR payload is empty");
+        }
+        if (payload.length() > MAX_PAYLOAD) {
+            throw new InvalidQrCodeException("QR payload is longer than " + MAX_PAYLOAD + " characters");
+        }
+        Map<String, String> objects = objects(payload, "payload");
+        if (!payload.startsWith(PAYLOAD_FORMAT_INDICATOR + "0201")) {
+            throw new InvalidQrCodeException("QR payload must start with payload format indicator 01");
+        }
+        String last = objects.keySet().stream().reduce((first, second) -> second).orElseThrow();
+        int crcAt = payload.length() - 4;
+        if (!last.equals(CRC) || objects.get(CRC).length() != 4) {
+            throw new InvalidQrCodeException("QR payload must end with a 4-character CRC (ID 63)");
+        }
+        String crc = crc(payload.substring(0, crcAt));
+        if (!crc.equalsIgnoreCase(payload.substring(crcAt))) {
+            throw new InvalidQrCodeException("QR payload CRC " + payload.substring(crcAt)
+                + " does not match its content (" + crc + ")");
+        }
+        
+        String initiation = objects.get(POINT_OF_INITIATION);
+        if (initiation != null && !initiation.equals("11") && !initiation.equals("12")) {
+            throw new InvalidQrCodeException("Point of initiation method must be 11 or 12");
+        }
+        String mcc = required(objects, MERCHANT_CATEGORY_CODE, "merchant category code");
+        if (!MCC.matcher(mcc).matches()) {
+            throw new InvalidQrCodeException("Merchant category code must be 4 digits");
+        }
+        String numericCurrency = required(objects, CURRENCY, "transaction currency");
+        Currency currency = NUMERIC_CURRENCY.matcher(numericCurrency).matches() ? BY_NUMERIC_CODE.get(numericCurrency) : null;
+        if (currency == null) {
+            throw new InvalidQrCodeException("Unknown ISO 4217 currency " + numericCurrency);
+        }
+        BigDecimal amount = null;
+        if (objects.containsKey(AMOUNT)) {
+            String value = objects.get(AMOUNT);
+            if (!AMOUNT_FORMAT.matcher(value).matches()) {
+                throw new InvalidQrCodeException("Transaction amount " + value + " is not a valid amount");
+            }
+            amount = new BigDecimal(value);
+            if (amount.signum() <= 0 || amount.stripTrailingZeros().scale() > currency.getDefaultFractionDigits()) {
+                throw new InvalidQrCodeException("Transaction amount " + value + " is not valid for " + currency.getCurrencyCode());
+            }
+        }
+        String country = required(objects, COUNTRY_CODE, "country code");
+        if (!COUNTRY.matcher(country).matches()) {
+            throw new InvalidQrCodeException("Country code must be 2 letters");
+        }
+        String name = required(objects, MERCHANT_NAME, "merchant name");
+        String city = required(objects, MERCHANT_CITY, "merchant city");
+        
+        // Merchant account information may be in any of 02-51; ours is in 26
+        boolean hasAccount = objects.keySet().stream().anyMatch(id -> id.compareTo("02") >= 0 && id.compareTo("51") <= 0);
+        if (!hasAccount) {
+            throw new InvalidQrCodeException("QR payload has no merchant account information (IDs 02-51)");
+        }
+        Map<String, String> account = objects.containsKey(MERCHANT_ACCOUNT)
+            ? objects(objects.get(MERCHANT_ACCOUNT), "merchant account information") : Map.of();
+        Map<String, String> additional = objects.containsKey(ADDITIONAL_DATA)
+            ? objects(objects.get(ADDITIONAL_DATA), "additional data") : Map.of();
+        String qrPaymentId = account.getOrDefault(ACCOUNT_QR_PAYMENT_ID, additional.get(REFERENCE_LABEL));
+        
+        return new EmvQrCode("12".equals(initiation), account.get(ACCOUNT_GUI), account.get(ACCOUNT_MERCHANT_ID),
+            qrPaymentId, mcc, currency.getCurrencyCode(), amount, country, name, city, additional.get(BILL_NUMBER));
+    }
+    
+    /**
+     * CRC-16/CCITT-FALSE (polynomial 1021, initial value FFFF) as 4
+     * uppercase hex digits
+     */
+    static String crc(CharSequence data) {
+        int crc = 0xFFFF;
+        for (byte b : data.toString().getBytes(StandardCharsets.UTF_8)) {
+            crc ^= (b & 0xFF) << 8;
+            for (int i = 0; i < 8; i++) {
+                crc = (crc & 0x8000) != 0 ? (crc << 1) ^ 0x1021 : crc << 1;
+            }
+        }
+        return String.format("%04X", crc & 0xFFFF);
+    }
+    
+    /**
+     * The objects of a payload or template, by ID
+     */
+    private static Map<String, String> objects(String data, String what) {
+        Map<String, String> objects = new LinkedHashMap<>();
+        int at = 0;
+        while (at < data.length()) {
+            if (at + 4 > data.length()) {
+                throw new InvalidQrCodeException("Truncated object at position " + at + " of " + what);
+            }
+            String id = data.substring(at, at + 2);
+            String length = data.substring(at + 2, at + 4);
+            if (!TWO_DIGITS.matcher(id).matches() || !TWO_DIGITS.matcher(length).matches()) {
+                throw new InvalidQrCodeException("Invalid object header '" + id + length + "' at position " + at + " of " + what);
+            }
+            int end = at + 4 + Integer.parseInt(length);
+            if (Integer.parseInt(length) == 0 || end > data.length()) {
+                throw new InvalidQrCodeException("Object " + id + " of " + what + " has an invalid length " + length);
+            }
+            if (objects.put(id, data.substring(at + 4, end)) != null) {
+                throw new InvalidQrCodeException("Object " + id + " appears more than once in " + what);
+            }
+            at = end;
+        }
+        return objects;
+    }
+    
+    private static String required(Map<String, String> objects, String id, String name) {
+        String value = objects.get(id);
+        if (value == null) {
+            throw new InvalidQrCodeException("QR payload has no " + name + " (ID " + id + ")");
+        }
+        return value;
+    }
+    
+    private static void append(StringBuilder payload, String id, String value) {
+        if (value == null || value.isEmpty()) {
+            return;
+        }
+        if (value.length() > 99) {
+            throw new IllegalArgumentException("Object " + id + " is longer than 99 characters");
+        }
+        payload.append(id).append(String.format("%02d", value.length())).append(value);
+    }
+    
+    /**
+     * Printable ASCII only, as the merchant name and city are read by
+     * wallets that may not handle more, cut to a length; NA if nothing is
+     * left, as both are required
+     */
+    private static String text(String value, int length) {
+        String ascii = value == null ? "" : value.replaceAll("[^\\x20-\\x7E]", "").trim();
+        ascii = ascii.length() > length ? ascii.substring(0, length).trim() : ascii;
+        return ascii.isEmpty() ? "NA" : ascii;
+    }
+}
@@ -0,0 +1,12 @@
+package com.paymentgateway.authorization.qr;
+
+/**
+ * A QR payload that is not a well-formed EMVCo merchant-presented QR code,
+ * or whose CRC does not match
+ */
+public class InvalidQrCodeException extends RuntimeException {
+    
+    public InvalidQrCodeException(String message) {
+        super(message);
+    }
+}
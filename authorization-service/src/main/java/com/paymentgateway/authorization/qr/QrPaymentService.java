@@ -0,0 +1,146 @@
+package com.paymentgateway.authorization.qr;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.PaymentStatus;
+import com.paymentgateway.authorization.domain.QrPayment;
+import com.paymentgateway.authorization.domain.QrPaymentStatus;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.repository.QrPaymentRepository;
+import com.paymentgateway.authorization.service.PaymentService;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+
+import java.math.BigDecimal;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Currency;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Issues dynamic EMVCo QR codes bound to one payment each, and pays them
+ * when a wallet scans one. A scan pays the amount stored with the code, not
+ * the one read from the payload, and only if the payload is the one issued,
+ * so a code altered and re-signed with a new CRC is refused.
+ */
+@Service
+public class QrPaymentService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(QrPaymentService.class);
+    
+    // For merchants without an MCC or country of their own
+    private static final String DEFAULT_MCC = "5999";
+    private static final String DEFAULT_COUNTRY = "US";
+    
+    private final QrPaymentRepository repository;
+    private final PaymentService paymentService;
+    private final Duration expiry;
+    private final String merchantCity;
+    
+    public QrPaymentService(QrPaymentRepository repository,
+                            PaymentService paymentService,
+                            @Value("${qr.expiry:15m}") Duration expiry,
+                            @Value("${qr.merchant-city:ONLINE}") String merchantCity) {
+        this.repository = repository;
+        this.paymentService = paymentService;
+        this.expiry = expiry;
+        this.merchantCity = merchantCity;
+    }
+    
+    /**
+     * Issue a code for a payment of an amount
+     *
+     * @throws IllegalArgumentException If the amount has more decimals than the currency
+     */
+    public QrPayment create(Merchant merchant, BigDecimal amount, String currency, String reference) {
+        if (amount.stripTrailingZeros().scale() > Currency.getInstance(currency).getDefaultFractionDigits()) {
+            throw new IllegalArgumentException("Amount " + amount.toPlainString() + " has more decimals than "
+                + currency + " allows");
+        }
+        QrPayment qrPayment = new QrPayment();
+        qrPayment.setQrPaymentId("qr_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24));
+        qrPayment.setMerchantId(merchant.getId());
+        qrPayment.setAmount(amount);
+        qrPayment.setCurrency(currency);
+        qrPayment.setReference(reference);
+        qrPayment.setExpiresAt(qrPayment.getCreatedAt().plus(expiry));
+        
+        String name = merchant.getStatementDescriptor() != null ? merchant.getStatementDescriptor() : merchant.getMerchantName();
+        qrPayment.setPayload(EmvQrCodec.encode(new EmvQrCode(true, EmvQrCodec.GLOBALLY_UNIQUE_ID,
+            merchant.getMerchantId(), qrPayment.getQrPaymentId(),
+            merchant.getMcc() != null ? merchant.getMcc() : DEFAULT_MCC, currency, amount,
+            merchant.getCountryCode() != null ? merchant.getCountryCode() : DEFAULT_COUNTRY,
+            name, merchantCity, reference)));
+        return repository.save(qrPayment);
+    }
+    
+    public Optional<QrPayment> find(UUID merchantId, String qrPaymentId) {
+        return repository.findByQrPaymentId(qrPaymentId)
+            .filter(qrPayment -> qrPayment.getMerchantId().equals(merchantId))
+            .map(this::expire);
+    }
+    
+    /**
+     * Pay a scanned code with a card: authorize and capture its amount. A
+     * declined card leaves the code open for another attempt.
+     *
+     * @throws InvalidQrCodeException If the payload is malformed or its CRC does not match
+     * @throws QrPaymentUnavailableException If the code cannot be paid
+     */
+    public QrScanResult pay(String payload, String cardNumber, Integer expiryMonth, Integer expiryYear, String cvv) {
+        EmvQrCode code = EmvQrCodec.decode(payload);
+        if (!EmvQrCodec.GLOBALLY_UNIQUE_ID.equals(code.getGloballyUniqueId()) || code.getQrPaymentId() == null) {
+            throw new QrPaymentUnavailableException("QR_PAYMENT_NOT_FOUND", "The QR code was not issued by this gateway");
+        }
+        QrPayment qrPayment = repository.findByQrPaymentId(code.getQrPaymentId())
+            .orElseThrow(() -> new QrPaymentUnavailableException("QR_PAYMENT_NOT_FOUND",
+                "QR payment not found: " + code.getQrPaymentId()));
+        if (!qrPayment.getPayload().equals(payload)) {
+            throw new QrPaymentUnavailableException("QR_CODE_MISMATCH",
+                "The QR code does not match the one issued for " + qrPayment.getQrPaymentId());
+        }
+        expire(qrPayment);
+        if (qrPayment.getStatus() == QrPaymentStatus.EXPIRED) {
+            throw new QrPaymentUnavailableException("QR_PAYMENT_EXPIRED",
+                "QR payment " + qrPayment.getQrPaymentId() + " expired at " + qrPayment.getExpiresAt());
+        }
+        if (qrPayment.getStatus() == QrPaymentStatus.PAID) {
+            throw new QrPaymentUnavailableException("QR_PAYMENT_ALREADY_PAID",
+                "QR payment " + qrPayment.getQrPaymentId() + " was already paid");
+        }
+        
+        PaymentRequest request = new PaymentRequest(cardNumber, expiryMonth, expiryYear, cvv,
+            qrPayment.getAmount(), qrPayment.getCurrency());
+        request.setReferenceId(qrPayment.getReference());
+        request.setDescription("QR payment " + qrPayment.getQrPaymentId());
+        // One key per attempt: two scans racing for the same attempt get one
+        // payment between them, and a retry after a decline is a new payment
+        int attempt = qrPayment.getAttempts() + 1;
+        PaymentResponse response = paymentService.processPayment(request, qrPayment.getMerchantId(),
+            qrPayment.getQrPaymentId() + "-" + attempt);
+        if (response.getStatus() == PaymentStatus.AUTHORIZED) {
+            response = paymentService.capturePayment(response.getPaymentId());
+        }
+        
+        qrPayment.setAttempts(attempt);
+        qrPayment.setPaymentId(response.getPaymentId());
+        if (response.getStatus() == PaymentStatus.CAPTURED) {
+            qrPayment.setStatus(QrPaymentStatus.PAID);
+            qrPayment.setPaidAt(Instant.now());
+        }
+        repository.save(qrPayment);
+        logger.info("QR payment {} attempt {}: {}", qrPayment.getQrPaymentId(), attempt, response.getStatus());
+        return new QrScanResult(code, qrPayment, response);
+    }
+    
+    private QrPayment expire(QrPayment qrPayment) {
+        if (qrPayment.getStatus() == QrPaymentStatus.OPEN && Instant.now().isAfter(qrPayment.getExpiresAt())) {
+            qrPayment.setStatus(QrPaymentStatus.EXPIRED);
+            repository.save(qrPayment);
+        }
+        return qrPayment;
+    }
+}
@@ -0,0 +1,18 @@
+package com.paymentgateway.authorization.qr;
+
+/**
+ * A scanned code that cannot be paid: unknown to this gateway, altered,
+ * expired or already paid
+ */
+public class QrPaymentUnavailableException extends RuntimeException {
+    
+    private final String code;
+    
+    public QrPaymentUnavailableException(String code, String message) {
+        super(message);
+        this.code = code;
+    }
+    
+    // QR_PAYMENT_NOT_FOUND, QR_CODE_MISMATCH, QR_PAYMENT_EXPIRED or QR_PAYMENT_ALREADY_PAID
+    public String getCode() { return code; }
+}
@@ -0,0 +1,24 @@
+package com.paymentgateway.authorization.qr;
+
+import com.paymentgateway.authorization.domain.QrPayment;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+
+/**
+ * The outcome of a wallet scanning and paying a QR code
+ */
+public final class QrScanResult {
+    
+    private final EmvQrCode code;
+    private final QrPayment qrPayment;
+    private final PaymentResponse payment;
+    
+    public QrScanResult(EmvQrCode code, QrPayment qrPayment, PaymentResponse payment) {
+        this.code = code;
+        this.qrPayment = qrPayment;
+        this.payment = payment;
+    }
+    
+    public EmvQrCode getCode() { return code; }
+    public QrPayment getQrPayment() { return qrPayment; }
+    public PaymentResponse getPayment() { return payment; }
+}
@@ -0,0 +1,146 @@
+package com.paymentgateway.authorization.reload;
+
+import com.paymentgateway.authorization.geo.BinTable;
+import com.paymentgateway.authorization.psp.LatencyScenarios;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.attribute.FileTime;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+import java.time.Instant;
+import java.util.ArrayList;
+import java.util.HexFormat;
+import java.util.List;
+import java.util.function.Function;
+
+/**
+ * Reloads settings from files while the gateway runs, so long simulations
+ * need no restart. Each file holds a setting in the format of its property
+ * and is checked for changes every config.reload-interval-ms. The setting
+ * validates the whole file before swapping it in, so a bad edit leaves the
+ * running configuration untouched, and every attempt is recorded in the
+ * {@link ConfigReloadLog}.
+ */
+@Component
+public class ConfigFileWatcher {
+    
+    static final String STARTUP = "startup";
+    static final String FILE_CHANGE = "file-change";
+    
+    private final ConfigReloadLog log;
+    private final List<WatchedFile> files = new ArrayList<>();
+    
+    /**
+     * @param faultsFile file of fault rules replacing psp.simulator.faults,
+     *                   see {@link LatencyScenarios}; blank for none
+     * @param binTableFile file of BIN table entries replacing
+     *                     payment.geo.bin-table, see {@link BinTable}; blank
+     *                     for none
+     */
+    public ConfigFileWatcher(@Value("${psp.simulator.faults-file:}") String faultsFile,
+                             @Value("${payment.geo.bin-table-file:}") String binTableFile,
+                             LatencyScenarios latency,
+                             BinTable binTable,
+                             ConfigReloadLog log) {
+        this.log = log;
+        watch("psp.simulator.faults", faultsFile, latency::replaceFaults);
+        watch("payment.geo.bin-table", binTableFile, binTable::replace);
+    }
+    
+    private void watch(String setting, String file, Function<String, List<String>> apply) {
+        if (file == null || file.isBlank()) {
+            return;
+        }
+        WatchedFile watched = new WatchedFile(setting, Path.of(file), apply);
+        // A file that cannot be applied at startup fails the start, as an
+        // invalid property would
+        ConfigReloadLog.Entry entry = load(watched, STARTUP);
+        if (entry.getError() != null) {
+            throw new IllegalStateException("Cannot load " + setting + " from " + file + ": " + entry.getError());
+        }
+        files.add(watched);
+    }
+    
+    /**
+     * Reloads every file that changed since it was last read
+     */
+    @Scheduled(fixedDelayString = "${config.reload-interval-ms:5000}")
+    public synchronized void reload() {
+        for (WatchedFile file : files) {
+            load(file, FILE_CHANGE);
+        }
+    }
+    
+    /**
+     * Applies the file if it changed, or at startup, and records the
+     * attempt. Returns the entry recorded, or null if the file is unchanged.
+     */
+    synchronized ConfigReloadLog.Entry load(WatchedFile file, String trigger) {
+        String owner = null;
+        String digest = null;
+        String error;
+        try {
+            FileTime modified = Files.getLastModifiedTime(file.path);
+            if (FILE_CHANGE.equals(trigger) && modified.equals(file.seenModifiedTime)) {
+                return null;
+            }
+            // Remember rejected files too, so polling reports them once
+            // rather than on every tick
+            file.seenModifiedTime = modified;
+            owner = owner(file.path);
+            byte[] content = Files.readAllBytes(file.path);
+            digest = digest(content);
+            List<String> changes = file.apply.apply(new String(content, StandardCharsets.UTF_8).strip());
+            return record(file, trigger, owner, digest, changes, null);
+        } catch (IOException e) {
+            error = "cannot read the file: " + e.getMessage();
+        } catch (IllegalArgumentException e) {
+            error = e.getMessage();
+        }
+        return record(file, trigger, owner, digest, List.of(), error);
+    }
+    
+    private ConfigReloadLog.Entry record(WatchedFile file, String trigger, String owner, String digest,
+                                         List<String> changes, String error) {
+        ConfigReloadLog.Entry entry = new ConfigReloadLog.Entry(Instant.now(), file.setting, file.path.toString(),
+            trigger, owner, digest, changes, error);
+        log.record(entry);
+        return entry;
+    }
+    
+    private static String owner(Path path) {
+        try {
+            return Files.getOwner(path).getName();
+        } catch (IOException | UnsupportedOperationException e) {
+            return null;
+        }
+    }
+    
+    private static String digest(byte[] content) {
+        try {
+            byte[] sum = MessageDigest.getInstance("SHA-256").digest(content);
+            return HexFormat.of().formatHex(sum, 0, 6);
+        } catch (NoSuchAlgorithmException e) {
+            throw new IllegalStateException(e);
+        }
+    }
+    
+    static final class WatchedFile {
+        final String setting;
+        final Path path;
+        final Function<String, List<String>> apply;
+        FileTime seenModifiedTime;
+        
+        WatchedFile(String setting, Path path, Function<String, List<String>> apply) {
+            this.setting = setting;
+            this.path = path;
+            this.apply = apply;
+        }
+    }
+}
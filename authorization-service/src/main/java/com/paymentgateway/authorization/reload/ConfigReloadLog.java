@@ -0,0 +1,106 @@
+package com.paymentgateway.authorization.reload;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.stereotype.Component;
+
+import java.time.Instant;
+import java.util.ArrayDeque;
+import java.util.ArrayList;
+import java.util.Deque;
+import java.util.List;
+
+/**
+ * Audit trail of configuration reloads: every attempt, applied or rejected,
+ * with what triggered it, who last wrote the file and what changed. Only
+ * the most recent entries are kept.
+ */
+@Component
+public class ConfigReloadLog {
+    
+    private static final Logger logger = LoggerFactory.getLogger(ConfigReloadLog.class);
+    
+    private static final int MAX_ENTRIES = 500;
+    
+    private final Deque<Entry> entries = new ArrayDeque<>();
+    
+    public synchronized void record(Entry entry) {
+        if (entry.getError() != null) {
+            logger.error("{}", entry);
+        } else {
+            logger.info("{}", entry);
+        }
+        entries.addFirst(entry);
+        while (entries.size() > MAX_ENTRIES) {
+            entries.removeLast();
+        }
+    }
+    
+    /**
+     * Entries, newest first
+     */
+    public synchronized List<Entry> getEntries() {
+        return new ArrayList<>(entries);
+    }
+    
+    public static class Entry {
+        private final Instant time;
+        private final String setting;
+        private final String path;
+        private final String trigger;
+        private final String owner;
+        private final String digest;
+        private final List<String> changes;
+        private final String error;
+        
+        public Entry(Instant time, String setting, String path, String trigger, String owner, String digest,
+                     List<String> changes, String error) {
+            this.time = time;
+            this.setting = setting;
+            this.path = path;
+            this.trigger = trigger;
+            this.owner = owner;
+            this.digest = digest;
+            this.changes = changes == null ? List.of() : List.copyOf(changes);
+            this.error = error;
+        }
+        
+        public Instant getTime() { return time; }
+        public String getSetting() { return setting; }
+        public String getPath() { return path; }
+        
+        /** {@code startup} or {@code file-change} */
+        public String getTrigger() { return trigger; }
+        
+        /** Who last wrote the file, where the file system tells */
+        public String getOwner() { return owner; }
+        
+        /** A short SHA-256 prefix of the file content */
+        public String getDigest() { return digest; }
+        
+        public List<String> getChanges() { return changes; }
+        
+        /** Why the file was rejected, or null if it was applied */
+        public String getError() { return error; }
+        
+        @Override
+        public String toString() {
+            StringBuilder b = new StringBuilder()
+                .append(trigger).append(" reload of ").append(setting).append(" from ").append(path);
+            if (owner != null) {
+                b.append(" (owner ").append(owner).append(')');
+            }
+            if (digest != null) {
+                b.append(" sha256:").append(digest);
+            }
+            if (error != null) {
+                b.append(": rejected, keeping the previous configuration: ").append(error);
+            } else if (changes.isEmpty()) {
+                b.append(": no changes");
+            } else {
+                b.append(": ").append(String.join("; ", changes));
+            }
+            return b.toString();
+        }
+    }
+}
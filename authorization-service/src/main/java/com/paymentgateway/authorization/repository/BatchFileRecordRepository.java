@@ -0,0 +1,14 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.BatchFileRecord;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.UUID;
+
+@Repository
+public interface BatchFileRecordRepository extends JpaRepository<BatchFileRecord, UUID> {
+    
+    List<BatchFileRecord> findByBatchFileIdOrderByRecordNumber(UUID batchFileId);
+}
@@ -0,0 +1,21 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileSource;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+@Repository
+public interface BatchFileRepository extends JpaRepository<BatchFile, UUID> {
+    
+    Optional<BatchFile> findByFileId(String fileId);
+    
+    Optional<BatchFile> findFirstByStatusInOrderByCreatedAt(List<BatchFileStatus> statuses);
+    
+    List<BatchFile> findBySourceAndStatusAndResponseDeliveredAtIsNull(BatchFileSource source, BatchFileStatus status);
+}
@@ -0,0 +1,23 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+import com.paymentgateway.authorization.domain.CreditTransferStatus;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.time.LocalDate;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+@Repository
+public interface CreditTransferRepository extends JpaRepository<CreditTransfer, UUID> {
+    
+    Optional<CreditTransfer> findByTransferId(String transferId);
+    
+    boolean existsByMerchantIdAndMessageId(UUID merchantId, String messageId);
+    
+    boolean existsByMerchantIdAndEndToEndIdAndStatusNot(UUID merchantId, String endToEndId, CreditTransferStatus status);
+    
+    List<CreditTransfer> findByStatusAndExecutionDateLessThanEqual(CreditTransferStatus status, LocalDate date);
+}
@@ -0,0 +1,11 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.MerchantOnboarding;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.UUID;
+
+@Repository
+public interface MerchantOnboardingRepository extends JpaRepository<MerchantOnboarding, UUID> {
+}
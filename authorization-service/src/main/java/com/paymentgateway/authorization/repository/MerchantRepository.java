@@ -1,11 +1,19 @@
 package com.paymentgateway.authorization.repository;
 
 import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.OnboardingStatus;
+import org.springframework.data.domain.Pageable;
 import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.data.jpa.repository.Modifying;
+import org.springframework.data.jpa.repository.Query;
+import org.springframework.data.repository.query.Param;
 import org.springframework.stereotype.Repository;
 
+import java.time.Instant;
+import java.util.List;
 import java.util.Optional;
 import java.util.UUID;
+import java.util.UUID;
 
 @Repository
 public interface MerchantRepository extends JpaRepository<Merchant, UUID> {
@@ -15,4 +23,44 @@ public interface MerchantRepository extends JpaRepository<Merchant, UUID> {
     Optional<Merchant> findByApiKeyHash(String apiKeyHash);
     
     boolean existsByMerchantId(String merchantId);
+    
+    List<Merchant> findByOnboardingStatusOrderByMerchantIdAsc(OnboardingStatus onboardingStatus);
+    
+    /**
+     * Merchants ordered by merchant ID after the given one (null for the
+     * first page), optionally only active or inactive ones
+     */
+    @Query("SELECT m FROM Merchant m WHERE (:active IS NULL OR m.isActive = :active) " +
+           "AND (:after IS NULL OR m.merchantId > :after) ORDER BY m.merchantId ASC")
+    List<Merchant> findPage(@Param("active") Boolean active, @Param("after") String after, Pageable pageable);
+    
+    // Soft-deleted merchants are filtered out of the queries above by the
+    // entity's restriction; these native queries are the only way to them.
+    
+    /**
+     * Deleted merchants past their restore window that nothing references;
+     * merchants with payments, settlement batches or API keys are kept
+     * deleted so those records stay intact
+     */
+    String PURGEABLE = "SELECT m.id FROM merchants m WHERE m.deleted_at < :cutoff " +
+        "AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.merchant_id = m.id) " +
+        "AND NOT EXISTS (SELECT 1 FROM settlement_batches b WHERE b.merchant_id = m.id) " +
+        "AND NOT EXISTS (SELECT 1 FROM api_keys k WHERE k.merchant_id = m.id)";
+    
+    @Query(value = "SELECT * FROM merchants WHERE merchant_id = :merchantId AND deleted_at IS NOT NULL",
+           nativeQuery = true)
+    Optional<Merchant> findDeletedByMerchantId(@Param("merchantId") String merchantId);
+    
+    @Modifying
+    @Query(value = "UPDATE merchants SET deleted_at = NULL WHERE id = :id AND deleted_at IS NOT NULL",
+           nativeQuery = true)
+    int restore(@Param("id") UUID id);
+    
+    @Modifying
+    @Query(value = "DELETE FROM merchant_roles WHERE merchant_id IN (" + PURGEABLE + ")", nativeQuery = true)
+    int purgeDeletedRoles(@Param("cutoff") Instant cutoff);
+    
+    @Modifying
+    @Query(value = "DELETE FROM merchants WHERE id IN (" + PURGEABLE + ")", nativeQuery = true)
+    int purgeDeleted(@Param("cutoff") Instant cutoff);
 }
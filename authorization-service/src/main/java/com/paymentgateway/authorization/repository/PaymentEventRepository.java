@@ -1,9 +1,13 @@
 package com.paymentgateway.authorization.repository;
 
 import com.paymentgateway.authorization.domain.PaymentEvent;
+import org.springframework.data.domain.Pageable;
 import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.data.jpa.repository.Query;
+import org.springframework.data.repository.query.Param;
 import org.springframework.stereotype.Repository;
 
+import java.time.Instant;
 import java.util.List;
 import java.util.UUID;
 
@@ -11,4 +15,19 @@ import java.util.UUID;
 public interface PaymentEventRepository extends JpaRepository<PaymentEvent, UUID> {
     
     List<PaymentEvent> findByPaymentIdOrderByCreatedAtDesc(UUID paymentId);
+    
+    List<PaymentEvent> findByPaymentIdOrderByCreatedAtAsc(UUID paymentId);
+    
+    List<PaymentEvent> findByPaymentIdOrderByCreatedAtDescIdDesc(UUID paymentId, Pageable pageable);
+    
+    /**
+     * The events recorded before the given one, newest first, for paging
+     */
+    @Query("SELECT e FROM PaymentEvent e WHERE e.paymentId = :paymentId " +
+           "AND (e.createdAt < :createdAt OR (e.createdAt = :createdAt AND e.id < :id)) " +
+           "ORDER BY e.createdAt DESC, e.id DESC")
+    List<PaymentEvent> findByPaymentIdBefore(@Param("paymentId") UUID paymentId,
+                                             @Param("createdAt") Instant createdAt,
+                                             @Param("id") UUID id,
+                                             Pageable pageable);
 }
@@ -0,0 +1,14 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.QrPayment;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.Optional;
+import java.util.UUID;
+
+@Repository
+public interface QrPaymentRepository extends JpaRepository<QrPayment, UUID> {
+    
+    Optional<QrPayment> findByQrPaymentId(String qrPaymentId);
+}
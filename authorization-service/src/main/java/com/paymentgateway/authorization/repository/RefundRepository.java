@@ -19,6 +19,8 @@ public interface RefundRepository extends JpaRepository<Refund, UUID> {
     
     List<Refund> findByPaymentId(UUID paymentId);
     
+    long countByPaymentIdAndStatusIn(UUID paymentId, List<RefundStatus> statuses);
+    
     @Query("SELECT COALESCE(SUM(r.amount), 0) FROM Refund r WHERE r.paymentId = :paymentId AND r.status IN :statuses")
     BigDecimal sumRefundedAmountByPaymentIdAndStatuses(
         @Param("paymentId") UUID paymentId,
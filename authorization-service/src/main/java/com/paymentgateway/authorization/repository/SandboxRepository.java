@@ -0,0 +1,88 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.Sandbox;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.data.jpa.repository.Modifying;
+import org.springframework.data.jpa.repository.Query;
+import org.springframework.data.repository.query.Param;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+@Repository
+public interface SandboxRepository extends JpaRepository<Sandbox, UUID> {
+    
+    Optional<Sandbox> findBySandboxId(String sandboxId);
+    
+    List<Sandbox> findAllByOrderByCreatedAtAsc();
+    
+    // Destroying a sandbox deletes everything recorded for its merchants,
+    // soft-deleted ones included, children before parents. Native queries
+    // reach the tables other services own and bypass the merchants' soft
+    // delete restriction.
+    
+    String MERCHANTS = "SELECT m.id FROM merchants m WHERE m.sandbox_id = :sandboxId";
+    
+    String PAYMENTS = "SELECT p.id FROM payments p WHERE p.merchant_id IN (" + MERCHANTS + ")";
+    
+    @Query(value = "SELECT COUNT(*) FROM merchants WHERE sandbox_id = :sandboxId AND deleted_at IS NULL",
+           nativeQuery = true)
+    long countMerchants(@Param("sandboxId") UUID sandboxId);
+    
+    @Query(value = "SELECT COUNT(*) FROM payments WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    long countPayments(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM settlement_transactions WHERE payment_id IN (" + PAYMENTS + ")", nativeQuery = true)
+    int deleteSettlementTransactions(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM fraud_alerts WHERE payment_id IN (" + PAYMENTS + ")", nativeQuery = true)
+    int deleteFraudAlerts(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM disputes WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteDisputes(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM payment_events WHERE payment_id IN (" + PAYMENTS + ")", nativeQuery = true)
+    int deletePaymentEvents(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM refunds WHERE payment_id IN (" + PAYMENTS + ")", nativeQuery = true)
+    int deleteRefunds(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM webhook_deliveries WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteWebhookDeliveries(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM webhook_endpoints WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteWebhookEndpoints(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM outbox_events WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteOutboxEvents(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM settlement_batches WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteSettlementBatches(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM payments WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deletePayments(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM api_keys WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteApiKeys(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM merchant_roles WHERE merchant_id IN (" + MERCHANTS + ")", nativeQuery = true)
+    int deleteMerchantRoles(@Param("sandboxId") UUID sandboxId);
+    
+    @Modifying
+    @Query(value = "DELETE FROM merchants WHERE sandbox_id = :sandboxId", nativeQuery = true)
+    int deleteMerchants(@Param("sandboxId") UUID sandboxId);
+}
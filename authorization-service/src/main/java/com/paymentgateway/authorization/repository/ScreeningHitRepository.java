@@ -0,0 +1,27 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.ScreeningHit;
+import com.paymentgateway.authorization.domain.ScreeningHitStatus;
+import com.paymentgateway.authorization.domain.ScreeningSubjectType;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.Collection;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+@Repository
+public interface ScreeningHitRepository extends JpaRepository<ScreeningHit, UUID> {
+    
+    Optional<ScreeningHit> findByHitId(String hitId);
+    
+    List<ScreeningHit> findByStatusOrderByCreatedAtAsc(ScreeningHitStatus status);
+    
+    List<ScreeningHit> findByPaymentIdOrderByCreatedAtAsc(String paymentId);
+    
+    boolean existsByPaymentIdAndStatus(String paymentId, ScreeningHitStatus status);
+    
+    boolean existsByMerchantIdAndSubjectTypeAndStatusIn(UUID merchantId, ScreeningSubjectType subjectType,
+                                                         Collection<ScreeningHitStatus> statuses);
+}
@@ -0,0 +1,66 @@
+package com.paymentgateway.authorization.repository;
+
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.stereotype.Repository;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * Read-only view of the settlement service's records of a payment, which
+ * share the gateway's database
+ */
+@Repository
+public class SettlementRecordRepository {
+    
+    private static final String FIND_BY_PAYMENT =
+        "SELECT b.batch_id, b.settlement_date, b.status, t.net_amount, t.currency, t.created_at " +
+        "FROM settlement_transactions t JOIN settlement_batches b ON b.id = t.batch_id " +
+        "WHERE t.payment_id = ? ORDER BY t.created_at";
+    
+    private final JdbcTemplate jdbcTemplate;
+    
+    public SettlementRecordRepository(JdbcTemplate jdbcTemplate) {
+        this.jdbcTemplate = jdbcTemplate;
+    }
+    
+    public List<SettlementRecord> findByPaymentId(UUID paymentId) {
+        return jdbcTemplate.query(FIND_BY_PAYMENT, (rs, row) -> new SettlementRecord(
+            rs.getString("batch_id"),
+            rs.getObject("settlement_date", LocalDate.class),
+            rs.getString("status"),
+            rs.getBigDecimal("net_amount"),
+            rs.getString("currency"),
+            rs.getTimestamp("created_at").toInstant()
+        ), paymentId);
+    }
+    
+    public static class SettlementRecord {
+        private final String batchId;
+        private final LocalDate settlementDate;
+        private final String status;
+        private final BigDecimal netAmount;
+        private final String currency;
+        private final Instant createdAt;
+        
+        public SettlementRecord(String batchId, LocalDate settlementDate, String status,
+                                BigDecimal netAmount, String currency, Instant createdAt) {
+            this.batchId = batchId;
+            this.settlementDate = settlementDate;
+            this.status = status;
+            this.netAmount = netAmount;
+            this.currency = currency;
+            this.createdAt = createdAt;
+        }
+        
+        public String getBatchId() { return batchId; }
+        public LocalDate getSettlementDate() { return settlementDate; }
+        public String getStatus() { return status; }
+        public BigDecimal getNetAmount() { return netAmount; }
+        public String getCurrency() { return currency; }
+        public Instant getCreatedAt() { return createdAt; }
+    }
+}
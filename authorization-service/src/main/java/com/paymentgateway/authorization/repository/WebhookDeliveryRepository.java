@@ -1,7 +1,9 @@
 package com.paymentgateway.authorization.repository;
 
 import com.paymentgateway.authorization.domain.WebhookDelivery;
+import org.springframework.data.domain.Pageable;
 import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.data.jpa.repository.Modifying;
 import org.springframework.data.jpa.repository.Query;
 import org.springframework.data.repository.query.Param;
 import org.springframework.stereotype.Repository;
@@ -23,4 +25,34 @@ public interface WebhookDeliveryRepository extends JpaRepository<WebhookDelivery
     @Query("SELECT w FROM WebhookDelivery w WHERE w.merchantId = :merchantId AND w.status = :status")
     List<WebhookDelivery> findByMerchantIdAndStatus(@Param("merchantId") UUID merchantId, 
                                                      @Param("status") String status);
+    
+    List<WebhookDelivery> findByMerchantIdAndStatusOrderByDeadLetteredAtDesc(UUID merchantId, String status,
+                                                                             Pageable pageable);
+    
+    List<WebhookDelivery> findByMerchantIdAndStatusAndEventTypeOrderByDeadLetteredAtDesc(UUID merchantId, String status,
+                                                                                         String eventType,
+                                                                                         Pageable pageable);
+    
+    List<WebhookDelivery> findByMerchantIdAndStatusOrderByCreatedAtAsc(UUID merchantId, String status,
+                                                                        Pageable pageable);
+    
+    List<WebhookDelivery> findByMerchantIdAndStatusAndEventTypeOrderByCreatedAtAsc(UUID merchantId, String status,
+                                                                                    String eventType,
+                                                                                    Pageable pageable);
+    
+    /**
+     * The last slot taken by redeliveries of a merchant that have not been
+     * attempted yet, so a new bulk redelivery queues up behind them
+     */
+    @Query("SELECT MAX(w.nextRetryAt) FROM WebhookDelivery w WHERE w.merchantId = :merchantId " +
+           "AND w.status = 'PENDING' AND w.redeliveryCount > 0 AND w.attemptCount = 0")
+    Instant findLastScheduledRedelivery(@Param("merchantId") UUID merchantId);
+    
+    /**
+     * Stop deliveries still waiting for an endpoint that was disabled
+     */
+    @Modifying
+    @Query("UPDATE WebhookDelivery w SET w.status = 'CANCELLED', w.nextRetryAt = NULL " +
+           "WHERE w.endpointId = :endpointId AND w.status = 'PENDING'")
+    int cancelPendingForEndpoint(@Param("endpointId") UUID endpointId);
 }
@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.repository;
+
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+@Repository
+public interface WebhookEndpointRepository extends JpaRepository<WebhookEndpoint, UUID> {
+    
+    List<WebhookEndpoint> findByMerchantIdAndStatus(UUID merchantId, String status);
+    
+    List<WebhookEndpoint> findByMerchantIdAndStatusNotOrderByCreatedAtAsc(UUID merchantId, String status);
+    
+    Optional<WebhookEndpoint> findByIdAndMerchantId(UUID id, UUID merchantId);
+}
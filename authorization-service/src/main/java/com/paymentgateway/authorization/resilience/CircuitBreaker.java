@@ -0,0 +1,228 @@
+package com.paymentgateway.authorization.resilience;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.locks.Condition;
+import java.util.concurrent.locks.ReentrantLock;
+import java.util.function.BiConsumer;
+import java.util.function.Predicate;
+import java.util.function.Supplier;
+
+/**
+ * Circuit breaker for one downstream dependency. A run of consecutive
+ * failures opens it and calls are turned away without reaching the
+ * dependency, so a struggling network is not buried under retries. After the
+ * open period one trial call is let through: success closes the breaker and
+ * failure opens it again.
+ *
+ * While open, calls either fail fast or, with the QUEUE fallback, wait in a
+ * bounded queue for the trial call to close the breaker.
+ */
+public class CircuitBreaker {
+
+    public enum State { CLOSED, HALF_OPEN, OPEN }
+
+    public enum Fallback { FAIL_FAST, QUEUE }
+
+    private final String dependency;
+    private final int failureThreshold;
+    private final Duration openDuration;
+    private final Fallback fallback;
+    private final int maxQueued;
+    private final Duration maxQueueWait;
+    private final Clock clock;
+    private final BiConsumer<String, State> onStateChange;
+
+    private final ReentrantLock lock = new ReentrantLock();
+    private final Condition stateChanged = lock.newCondition();
+    private State state = State.CLOSED;
+    private int consecutiveFailures;
+    private Instant openedAt;
+    private boolean trialInFlight;
+    private int queued;
+    private long rejected;
+
+    public CircuitBreaker(String dependency, int failureThreshold, Duration openDuration, Fallback fallback,
+                          int maxQueued, Duration maxQueueWait, Clock clock,
+                          BiConsumer<String, State> onStateChange) {
+        this.dependency = dependency;
+        this.failureThreshold = failureThreshold;
+        this.openDuration = openDuration;
+        this.fallback = fallback;
+        this.maxQueued = maxQueued;
+        this.maxQueueWait = maxQueueWait;
+        this.clock = clock;
+        this.onStateChange = onStateChange;
+    }
+
+    /**
+     * Runs the call unless the breaker is open. Any exception counts as a
+     * failure.
+     */
+    public <T> T execute(Supplier<T> call) {
+        return execute(call, result -> false, e -> true);
+    }
+
+    /**
+     * Runs the call unless the breaker is open, counting results and
+     * exceptions that match the predicates as failures. Exceptions from the
+     * call are rethrown; a call turned away throws CircuitBreakerOpenException.
+     */
+    public <T> T execute(Supplier<T> call, Predicate<T> failedResult, Predicate<RuntimeException> failedException) {
+        boolean trial = acquire();
+        T result;
+        try {
+            result = call.get();
+        } catch (RuntimeException e) {
+            record(trial, !failedException.test(e));
+            throw e;
+        }
+        record(trial, !failedResult.test(result));
+        return result;
+    }
+
+    /**
+     * Waits for permission to call, returning whether the call is the
+     * half-open trial
+     */
+    private boolean acquire() {
+        lock.lock();
+        try {
+            boolean waiting = false;
+            Instant deadline = null;
+            try {
+                while (true) {
+                    if (state == State.OPEN && !clock.instant().isBefore(retryAt())) {
+                        setState(State.HALF_OPEN);
+                    }
+                    if (state == State.CLOSED) {
+                        return false;
+                    }
+                    if (state == State.HALF_OPEN && !trialInFlight) {
+                        trialInFlight = true;
+                        return true;
+                    }
+                    if (fallback == Fallback.FAIL_FAST) {
+                        throw reject("open");
+                    }
+                    if (!waiting) {
+                        if (queued >= maxQueued) {
+                            throw reject("open and its queue is full");
+                        }
+                        queued++;
+                        waiting = true;
+                        deadline = clock.instant().plus(maxQueueWait);
+                    }
+                    Instant now = clock.instant();
+                    if (!now.isBefore(deadline)) {
+                        throw reject("open and the queued call timed out");
+                    }
+                    Instant wakeAt = state == State.OPEN && retryAt().isBefore(deadline) ? retryAt() : deadline;
+                    stateChanged.await(Math.max(1, Duration.between(now, wakeAt).toMillis()), TimeUnit.MILLISECONDS);
+                }
+            } finally {
+                if (waiting) {
+                    queued--;
+                }
+            }
+        } catch (InterruptedException e) {
+            Thread.currentThread().interrupt();
+            throw reject("open and the queued call was interrupted");
+        } finally {
+            lock.unlock();
+        }
+    }
+
+    private CircuitBreakerOpenException reject(String reason) {
+        rejected++;
+        return new CircuitBreakerOpenException(dependency, reason, openedAt != null ? retryAt() : null);
+    }
+
+    private void record(boolean trial, boolean success) {
+        lock.lock();
+        try {
+            if (trial) {
+                trialInFlight = false;
+            }
+            if (success) {
+                consecutiveFailures = 0;
+                if (trial) {
+                    setState(State.CLOSED);
+                }
+                return;
+            }
+            consecutiveFailures++;
+            if (trial || (state == State.CLOSED && consecutiveFailures >= failureThreshold)) {
+                openedAt = clock.instant();
+                setState(State.OPEN);
+            }
+        } finally {
+            lock.unlock();
+        }
+    }
+
+    private void setState(State newState) {
+        if (state == newState) {
+            return;
+        }
+        state = newState;
+        stateChanged.signalAll();
+        onStateChange.accept(dependency, newState);
+    }
+
+    private Instant retryAt() {
+        return openedAt.plus(openDuration);
+    }
+
+    /**
+     * Closes the breaker, for an operator who knows the dependency is back
+     */
+    public void reset() {
+        lock.lock();
+        try {
+            consecutiveFailures = 0;
+            trialInFlight = false;
+            setState(State.CLOSED);
+        } finally {
+            lock.unlock();
+        }
+    }
+
+    public String getDependency() {
+        return dependency;
+    }
+
+    public Fallback getFallback() {
+        return fallback;
+    }
+
+    /**
+     * The current state; an open breaker whose open period has passed
+     * reports HALF_OPEN, since the next call will be its trial
+     */
+    public State getState() {
+        lock.lock();
+        try {
+            if (state == State.OPEN && !clock.instant().isBefore(retryAt())) {
+                return State.HALF_OPEN;
+            }
+            return state;
+        } finally {
+            lock.unlock();
+        }
+    }
+
+    public CircuitBreakerStatus getStatus() {
+        State current = getState();
+        lock.lock();
+        try {
+            return new CircuitBreakerStatus(dependency, current, fallback, consecutiveFailures,
+                current == State.CLOSED ? null : openedAt,
+                current == State.OPEN ? retryAt() : null, queued, rejected);
+        } finally {
+            lock.unlock();
+        }
+    }
+}
@@ -0,0 +1,30 @@
+package com.paymentgateway.authorization.resilience;
+
+import java.time.Instant;
+
+/**
+ * Thrown when a circuit breaker turns a call away without reaching the
+ * dependency
+ */
+public class CircuitBreakerOpenException extends RuntimeException {
+
+    private final String dependency;
+    private final Instant retryAt;
+
+    public CircuitBreakerOpenException(String dependency, String reason, Instant retryAt) {
+        super("Circuit breaker for " + dependency + " is " + reason);
+        this.dependency = dependency;
+        this.retryAt = retryAt;
+    }
+
+    public String getDependency() {
+        return dependency;
+    }
+
+    /**
+     * When the breaker will let a trial call through, if known
+     */
+    public Instant getRetryAt() {
+        return retryAt;
+    }
+}
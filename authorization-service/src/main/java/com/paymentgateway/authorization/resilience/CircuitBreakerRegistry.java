@@ -0,0 +1,166 @@
+package com.paymentgateway.authorization.resilience;
+
+import com.paymentgateway.authorization.config.MetricsConfig.PaymentMetrics;
+import com.paymentgateway.authorization.monitoring.AlertService;
+import io.micrometer.core.instrument.FunctionCounter;
+import io.micrometer.core.instrument.Gauge;
+import io.micrometer.core.instrument.MeterRegistry;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.util.Collection;
+import java.util.HashMap;
+import java.util.Map;
+import java.util.TreeMap;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * The gateway's circuit breakers, one per downstream dependency: the
+ * tokenization service and each card network (reached through its PSP).
+ * Breakers are created on first use with the shared thresholds and the
+ * fallback configured for the dependency. Each breaker's state is exported
+ * as a metric, and the number of open breakers feeds the
+ * {@code circuit.breaker.open.count} gauge and the circuit breaker alert.
+ */
+@Component
+public class CircuitBreakerRegistry {
+
+    public static final String TOKENIZATION = "tokenization";
+
+    private static final Logger logger = LoggerFactory.getLogger(CircuitBreakerRegistry.class);
+
+    private final int failureThreshold;
+    private final Duration openDuration;
+    private final int maxQueued;
+    private final Duration maxQueueWait;
+    private final Map<String, CircuitBreaker.Fallback> fallbacks = new HashMap<>();
+    private final Clock clock;
+    private final MeterRegistry meterRegistry;
+    private final PaymentMetrics paymentMetrics;
+    private final AlertService alertService;
+
+    private final Map<String, CircuitBreaker> breakers = new ConcurrentHashMap<>();
+    private final Map<String, CircuitBreaker.State> states = new ConcurrentHashMap<>();
+
+    @Autowired
+    public CircuitBreakerRegistry(@Value("${circuit-breaker.failure-threshold:5}") int failureThreshold,
+                                  @Value("${circuit-breaker.open-seconds:30}") long openSeconds,
+                                  @Value("${circuit-breaker.queue.max-size:100}") int maxQueued,
+                                  @Value("${circuit-breaker.queue.max-wait-ms:2000}") long maxQueueWaitMillis,
+                                  @Value("${circuit-breaker.fallbacks:}") String fallbackSpec,
+                                  MeterRegistry meterRegistry,
+                                  PaymentMetrics paymentMetrics,
+                                  AlertService alertService) {
+        this(failureThreshold, Duration.ofSeconds(openSeconds), maxQueued, Duration.ofMillis(maxQueueWaitMillis),
+             fallbackSpec, Clock.systemUTC(), meterRegistry, paymentMetrics, alertService);
+    }
+
+    /**
+     * @param fallbackSpec comma-separated {@code DEPENDENCY:FALLBACK} entries,
+     *                     where the dependency may be {@code *}, e.g.
+     *                     {@code tokenization:QUEUE,*:FAIL_FAST}; unlisted
+     *                     dependencies fail fast
+     */
+    public CircuitBreakerRegistry(int failureThreshold, Duration openDuration, int maxQueued, Duration maxQueueWait,
+                                  String fallbackSpec, Clock clock, MeterRegistry meterRegistry,
+                                  PaymentMetrics paymentMetrics, AlertService alertService) {
+        this.failureThreshold = failureThreshold;
+        this.openDuration = openDuration;
+        this.maxQueued = maxQueued;
+        this.maxQueueWait = maxQueueWait;
+        this.clock = clock;
+        this.meterRegistry = meterRegistry;
+        this.paymentMetrics = paymentMetrics;
+        this.alertService = alertService;
+        for (String item : fallbackSpec.split(",")) {
+            if (item.isBlank()) {
+                continue;
+            }
+            int colon = item.lastIndexOf(':');
+            if (colon <= 0) {
+                throw new IllegalArgumentException("Invalid circuit breaker fallback: " + item);
+            }
+            fallbacks.put(item.substring(0, colon).trim(),
+                          CircuitBreaker.Fallback.valueOf(item.substring(colon + 1).trim()));
+        }
+    }
+
+    /**
+     * Breakers with default settings and no metrics, for services built
+     * outside Spring
+     */
+    public static CircuitBreakerRegistry withDefaults() {
+        return new CircuitBreakerRegistry(5, Duration.ofSeconds(30), 100, Duration.ofSeconds(2), "",
+                                          Clock.systemUTC(), null, null, null);
+    }
+
+    /**
+     * The breaker for a card network, named after the PSP that reaches it
+     */
+    public CircuitBreaker forNetwork(String pspName) {
+        return get("network." + pspName);
+    }
+
+    public CircuitBreaker get(String dependency) {
+        return breakers.computeIfAbsent(dependency, this::create);
+    }
+
+    private CircuitBreaker create(String dependency) {
+        CircuitBreaker.Fallback fallback = fallbacks.getOrDefault(dependency,
+            fallbacks.getOrDefault("*", CircuitBreaker.Fallback.FAIL_FAST));
+        CircuitBreaker breaker = new CircuitBreaker(dependency, failureThreshold, openDuration, fallback,
+                                                    maxQueued, maxQueueWait, clock, this::stateChanged);
+        states.put(dependency, CircuitBreaker.State.CLOSED);
+        if (meterRegistry != null) {
+            Gauge.builder("circuit.breaker.state", breaker, b -> b.getState().ordinal())
+                .description("Circuit breaker state: 0 closed, 1 half-open, 2 open")
+                .tag("service", "authorization")
+                .tag("dependency", dependency)
+                .register(meterRegistry);
+            FunctionCounter.builder("circuit.breaker.rejected", breaker, b -> b.getStatus().getRejected())
+                .description("Calls turned away by an open circuit breaker")
+                .tag("service", "authorization")
+                .tag("dependency", dependency)
+                .register(meterRegistry);
+        }
+        return breaker;
+    }
+
+    private void stateChanged(String dependency, CircuitBreaker.State state) {
+        states.put(dependency, state);
+        int open = (int) states.values().stream().filter(s -> s == CircuitBreaker.State.OPEN).count();
+        if (state == CircuitBreaker.State.OPEN) {
+            logger.warn("Circuit breaker for {} opened ({} open)", dependency, open);
+        } else {
+            logger.info("Circuit breaker for {} is {}", dependency, state);
+        }
+        if (paymentMetrics != null) {
+            paymentMetrics.setCircuitBreakerOpenCount(open);
+        }
+        if (alertService != null && state == CircuitBreaker.State.OPEN) {
+            alertService.checkCircuitBreakers(open);
+        }
+    }
+
+    public Collection<CircuitBreaker> getAll() {
+        return new TreeMap<>(breakers).values();
+    }
+
+    /**
+     * Closes a breaker, returning false if the dependency has none
+     */
+    public boolean reset(String dependency) {
+        CircuitBreaker breaker = breakers.get(dependency);
+        if (breaker == null) {
+            return false;
+        }
+        logger.info("Circuit breaker for {} reset by an operator", dependency);
+        breaker.reset();
+        return true;
+    }
+}
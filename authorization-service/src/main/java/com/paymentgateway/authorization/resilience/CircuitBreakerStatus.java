@@ -0,0 +1,63 @@
+package com.paymentgateway.authorization.resilience;
+
+import java.time.Instant;
+
+/**
+ * A circuit breaker's state and counters, as reported by the admin API
+ */
+public class CircuitBreakerStatus {
+
+    private final String dependency;
+    private final CircuitBreaker.State state;
+    private final CircuitBreaker.Fallback fallback;
+    private final int consecutiveFailures;
+    private final Instant openedAt;
+    private final Instant retryAt;
+    private final int queued;
+    private final long rejected;
+
+    public CircuitBreakerStatus(String dependency, CircuitBreaker.State state, CircuitBreaker.Fallback fallback,
+                                int consecutiveFailures, Instant openedAt, Instant retryAt,
+                                int queued, long rejected) {
+        this.dependency = dependency;
+        this.state = state;
+        this.fallback = fallback;
+        this.consecutiveFailures = consecutiveFailures;
+        this.openedAt = openedAt;
+        this.retryAt = retryAt;
+        this.queued = queued;
+        this.rejected = rejected;
+    }
+
+    public String getDependency() {
+        return dependency;
+    }
+
+    public CircuitBreaker.State getState() {
+        return state;
+    }
+
+    public CircuitBreaker.Fallback getFallback() {
+        return fallback;
+    }
+
+    public int getConsecutiveFailures() {
+        return consecutiveFailures;
+    }
+
+    public Instant getOpenedAt() {
+        return openedAt;
+    }
+
+    public Instant getRetryAt() {
+        return retryAt;
+    }
+
+    public int getQueued() {
+        return queued;
+    }
+
+    public long getRejected() {
+        return rejected;
+    }
+}
@@ -1,6 +1,7 @@
 package com.paymentgateway.authorization.saga;
 
 import com.paymentgateway.authorization.domain.*;
+import com.paymentgateway.authorization.dto.BillingAddress;
 import com.paymentgateway.authorization.dto.PaymentRequest;
 import com.paymentgateway.authorization.dto.PaymentResponse;
 import com.paymentgateway.authorization.event.PaymentEventPublisher;
@@ -168,11 +169,13 @@ public class CompensatingTransactionService {
             payment.setDescription(context.getRequest().getDescription());
             payment.setReferenceId(context.getRequest().getReferenceId());
             payment.setStatus(PaymentStatus.PENDING);
-            payment.setBillingStreet(context.getRequest().getBillingStreet());
-            payment.setBillingCity(context.getRequest().getBillingCity());
-            payment.setBillingState(context.getRequest().getBillingState());
-            payment.setBillingZip(context.getRequest().getBillingZip());
-            payment.setBillingCountry(context.getRequest().getBillingCountry());
+            BillingAddress billing = context.getRequest().billingAddress();
+            payment.setBillingStreet(billing.getLine1());
+            payment.setBillingStreet2(billing.getLine2());
+            payment.setBillingCity(billing.getCity());
+            payment.setBillingState(billing.getState());
+            payment.setBillingZip(billing.getPostalCode());
+            payment.setBillingCountry(billing.getCountry());
             
             payment = paymentRepository.save(payment);
             context.setPayment(payment);
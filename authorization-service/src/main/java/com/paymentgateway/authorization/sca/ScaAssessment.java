@@ -0,0 +1,51 @@
+package com.paymentgateway.authorization.sca;
+
+import com.paymentgateway.authorization.domain.ScaExemption;
+
+/**
+ * Outcome of checking a payment against PSD2 strong customer authentication.
+ * A payment in scope either carries authentication data, qualifies for an
+ * exemption, or is expected to be soft-declined by the issuer.
+ */
+public class ScaAssessment {
+    
+    private final boolean inScope;
+    private final boolean authenticated;
+    private final ScaExemption exemption;
+    
+    private ScaAssessment(boolean inScope, boolean authenticated, ScaExemption exemption) {
+        this.inScope = inScope;
+        this.authenticated = authenticated;
+        this.exemption = exemption;
+    }
+    
+    public static ScaAssessment outOfScope() {
+        return new ScaAssessment(false, false, null);
+    }
+    
+    public static ScaAssessment authenticated() {
+        return new ScaAssessment(true, true, null);
+    }
+    
+    public static ScaAssessment exempt(ScaExemption exemption) {
+        return new ScaAssessment(true, false, exemption);
+    }
+    
+    public static ScaAssessment required() {
+        return new ScaAssessment(true, false, null);
+    }
+    
+    public boolean isInScope() { return inScope; }
+    
+    public boolean isAuthenticated() { return authenticated; }
+    
+    public ScaExemption getExemption() { return exemption; }
+    
+    /**
+     * True when the issuer will ask for authentication: the payment is in
+     * scope with neither authentication data nor an exemption
+     */
+    public boolean isAuthenticationRequired() {
+        return inScope && !authenticated && exemption == null;
+    }
+}
@@ -0,0 +1,99 @@
+package com.paymentgateway.authorization.sca;
+
+import com.paymentgateway.authorization.currency.CurrencyConversionService;
+import com.paymentgateway.authorization.domain.ScaExemption;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+
+import java.math.BigDecimal;
+import java.util.Set;
+
+/**
+ * Decides whether a payment needs strong customer authentication under PSD2
+ * and, if so, whether an acquirer exemption applies.
+ * 
+ * Exemption limits are set in euros (RTS Articles 16 and 18); payments in
+ * other currencies are converted first. The transaction risk analysis limit
+ * follows from the acquirer's reference fraud rate.
+ */
+@Service
+public class ScaService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(ScaService.class);
+    
+    // EEA member states plus the UK, whose SCA rules mirror PSD2
+    private static final Set<String> SCA_COUNTRIES = Set.of(
+        "AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+        "IE", "IS", "IT", "LI", "LT", "LU", "LV", "MT", "NL", "NO", "PL", "PT", "RO", "SE",
+        "SI", "SK", "GB"
+    );
+    
+    private final CurrencyConversionService currencyConversionService;
+    private final BigDecimal lowValueLimit;
+    private final BigDecimal referenceFraudRate;
+    private final BigDecimal traMaxFraudScore;
+    
+    public ScaService(CurrencyConversionService currencyConversionService,
+                     @Value("${payment.sca.low-value-limit:30}") BigDecimal lowValueLimit,
+                     @Value("${payment.sca.tra.reference-fraud-rate:0.0013}") BigDecimal referenceFraudRate,
+                     @Value("${payment.sca.tra.max-fraud-score:0.30}") BigDecimal traMaxFraudScore) {
+        this.currencyConversionService = currencyConversionService;
+        this.lowValueLimit = lowValueLimit;
+        this.referenceFraudRate = referenceFraudRate;
+        this.traMaxFraudScore = traMaxFraudScore;
+    }
+    
+    /**
+     * Assess a payment. Without BIN data the billing country stands in for
+     * the issuer's country; the acquirer is assumed to be in the EEA.
+     * 
+     * @param amount Payment amount
+     * @param currency Payment currency
+     * @param billingCountry Cardholder billing country
+     * @param fraudScore Real-time risk score from fraud detection
+     * @param authenticated Whether 3DS authentication data is present
+     * @return Assessment with any exemption that applies
+     */
+    public ScaAssessment assess(BigDecimal amount, String currency, String billingCountry,
+                                BigDecimal fraudScore, boolean authenticated) {
+        if (billingCountry == null || !SCA_COUNTRIES.contains(billingCountry)) {
+            return ScaAssessment.outOfScope();
+        }
+        if (authenticated) {
+            return ScaAssessment.authenticated();
+        }
+        
+        BigDecimal amountEur = currencyConversionService.convert(amount, currency, "EUR").getConvertedAmount();
+        if (amountEur.compareTo(lowValueLimit) <= 0) {
+            return ScaAssessment.exempt(ScaExemption.LOW_VALUE);
+        }
+        
+        BigDecimal traLimit = traLimit();
+        if (traLimit != null && amountEur.compareTo(traLimit) <= 0
+                && fraudScore != null && fraudScore.compareTo(traMaxFraudScore) <= 0) {
+            return ScaAssessment.exempt(ScaExemption.TRANSACTION_RISK_ANALYSIS);
+        }
+        
+        logger.debug("SCA required: amount={} EUR, country={}", amountEur, billingCountry);
+        return ScaAssessment.required();
+    }
+    
+    /**
+     * Highest amount eligible for transaction risk analysis at the configured
+     * reference fraud rate, or null if the rate is too high for the exemption
+     */
+    BigDecimal traLimit() {
+        if (referenceFraudRate.compareTo(new BigDecimal("0.0001")) <= 0) {
+            return new BigDecimal("500");
+        }
+        if (referenceFraudRate.compareTo(new BigDecimal("0.0006")) <= 0) {
+            return new BigDecimal("250");
+        }
+        if (referenceFraudRate.compareTo(new BigDecimal("0.0013")) <= 0) {
+            return new BigDecimal("100");
+        }
+        return null;
+    }
+}
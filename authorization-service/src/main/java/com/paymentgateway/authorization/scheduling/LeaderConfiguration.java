@@ -0,0 +1,45 @@
+package com.paymentgateway.authorization.scheduling;
+
+import com.paymentgateway.shared.scheduling.JobLeaseRepository;
+import com.paymentgateway.shared.scheduling.LeaderElection;
+import com.paymentgateway.shared.scheduling.SingletonJobRunner;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.context.annotation.Bean;
+import org.springframework.context.annotation.Configuration;
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.transaction.PlatformTransactionManager;
+import org.springframework.transaction.support.TransactionTemplate;
+
+import java.time.Duration;
+
+/**
+ * The authorization-service lease and the runner its singleton jobs go
+ * through. The lease tables are shared with the settlement service, which
+ * elects its own leader under its own name.
+ */
+@Configuration
+public class LeaderConfiguration {
+    
+    static final String LEASE_NAME = "authorization-service";
+    
+    // The gateway's data source does not auto-commit
+    @Bean
+    public JobLeaseRepository jobLeaseRepository(JdbcTemplate jdbcTemplate,
+                                                 PlatformTransactionManager transactionManager) {
+        return new JobLeaseRepository(jdbcTemplate, new TransactionTemplate(transactionManager));
+    }
+    
+    // Gives up the lease on shutdown so another instance takes over without
+    // waiting for it to expire
+    @Bean(destroyMethod = "resign")
+    public LeaderElection leaderElection(JobLeaseRepository repository,
+                                         @Value("${jobs.leader.instance-id:}") String instanceId,
+                                         @Value("${jobs.leader.lease-ttl-ms:30000}") long leaseTtlMillis) {
+        return new LeaderElection(repository, LEASE_NAME, instanceId, Duration.ofMillis(leaseTtlMillis));
+    }
+    
+    @Bean
+    public SingletonJobRunner singletonJobRunner(LeaderElection election, JobLeaseRepository repository) {
+        return new SingletonJobRunner(election, repository);
+    }
+}
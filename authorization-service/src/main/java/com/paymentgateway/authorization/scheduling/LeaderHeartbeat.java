@@ -0,0 +1,24 @@
+package com.paymentgateway.authorization.scheduling;
+
+import com.paymentgateway.shared.scheduling.LeaderElection;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+/**
+ * Takes or renews the lease well within its TTL, so a live leader never
+ * loses it
+ */
+@Component
+public class LeaderHeartbeat {
+    
+    private final LeaderElection election;
+    
+    public LeaderHeartbeat(LeaderElection election) {
+        this.election = election;
+    }
+    
+    @Scheduled(fixedDelayString = "${jobs.leader.renew-interval-ms:10000}")
+    public void heartbeat() {
+        election.heartbeat();
+    }
+}
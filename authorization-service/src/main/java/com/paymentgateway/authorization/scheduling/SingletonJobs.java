@@ -0,0 +1,130 @@
+package com.paymentgateway.authorization.scheduling;
+
+import com.paymentgateway.authorization.batch.BatchFileProcessor;
+import com.paymentgateway.authorization.export.ExportJob;
+import com.paymentgateway.authorization.idempotency.JdbcIdempotencyStore;
+import com.paymentgateway.authorization.iso20022.CreditTransferService;
+import com.paymentgateway.authorization.outbox.OutboxDispatcher;
+import com.paymentgateway.authorization.service.MerchantLifecycleService;
+import com.paymentgateway.authorization.webhook.WebhookService;
+import com.paymentgateway.shared.scheduling.SingletonJobRunner;
+import org.springframework.beans.factory.ObjectProvider;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.time.Clock;
+import java.time.Instant;
+import java.time.temporal.ChronoUnit;
+
+/**
+ * Triggers the jobs that must not run on several instances at once. Every
+ * instance fires them; only the leader runs them. Dated jobs claim each run
+ * by its minute, so a run is never repeated across a change of leader;
+ * polling jobs just run on the leader.
+ *
+ * <p>Jobs that are safe everywhere stay with their components: the outbox
+ * dispatch claims events with SKIP LOCKED, the SFTP delivery and reports
+ * work on the instance's own drop zone, and the simulator's stand-in
+ * advices and issuer rules are held in memory.
+ */
+@Component
+public class SingletonJobs {
+    
+    static final String EXPORT_JOB = "daily-export";
+    static final String CREDIT_TRANSFER_SETTLEMENT_JOB = "credit-transfer-settlement";
+    static final String MERCHANT_PURGE_JOB = "merchant-purge";
+    static final String OUTBOX_PURGE_JOB = "outbox-purge";
+    static final String IDEMPOTENCY_PURGE_JOB = "idempotency-purge";
+    static final String WEBHOOK_RETRY_JOB = "webhook-retries";
+    static final String BATCH_FILE_JOB = "batch-files";
+    
+    private final SingletonJobRunner runner;
+    private final MerchantLifecycleService merchantLifecycleService;
+    private final OutboxDispatcher outboxDispatcher;
+    private final CreditTransferService creditTransferService;
+    private final WebhookService webhookService;
+    private final BatchFileProcessor batchFileProcessor;
+    private final ObjectProvider<ExportJob> exportJob;
+    private final ObjectProvider<JdbcIdempotencyStore> idempotencyStore;
+    private final Clock clock;
+    
+    @Autowired
+    public SingletonJobs(SingletonJobRunner runner,
+                         MerchantLifecycleService merchantLifecycleService,
+                         OutboxDispatcher outboxDispatcher,
+                         CreditTransferService creditTransferService,
+                         WebhookService webhookService,
+                         BatchFileProcessor batchFileProcessor,
+                         ObjectProvider<ExportJob> exportJob,
+                         ObjectProvider<JdbcIdempotencyStore> idempotencyStore) {
+        this(runner, merchantLifecycleService, outboxDispatcher, creditTransferService, webhookService,
+            batchFileProcessor, exportJob, idempotencyStore, Clock.systemUTC());
+    }
+    
+    SingletonJobs(SingletonJobRunner runner,
+                  MerchantLifecycleService merchantLifecycleService,
+                  OutboxDispatcher outboxDispatcher,
+                  CreditTransferService creditTransferService,
+                  WebhookService webhookService,
+                  BatchFileProcessor batchFileProcessor,
+                  ObjectProvider<ExportJob> exportJob,
+                  ObjectProvider<JdbcIdempotencyStore> idempotencyStore,
+                  Clock clock) {
+        this.runner = runner;
+        this.merchantLifecycleService = merchantLifecycleService;
+        this.outboxDispatcher = outboxDispatcher;
+        this.creditTransferService = creditTransferService;
+        this.webhookService = webhookService;
+        this.batchFileProcessor = batchFileProcessor;
+        this.exportJob = exportJob;
+        this.idempotencyStore = idempotencyStore;
+        this.clock = clock;
+    }
+    
+    // Only with export.scheduled.enabled=true
+    @Scheduled(cron = "${export.scheduled.cron:0 30 1 * * *}", zone = "UTC")
+    public SingletonJobRunner.Outcome exportPreviousDay() {
+        ExportJob job = exportJob.getIfAvailable();
+        return job != null ? runner.run(EXPORT_JOB, slot(), job::exportPreviousDay) : null;
+    }
+    
+    @Scheduled(cron = "${credit-transfers.settle-cron:0 5 0 * * *}", zone = "UTC")
+    public SingletonJobRunner.Outcome settleCreditTransfers() {
+        return runner.run(CREDIT_TRANSFER_SETTLEMENT_JOB, slot(), creditTransferService::settleDue);
+    }
+    
+    @Scheduled(fixedRateString = "${merchant.purge-interval-ms:3600000}")
+    public SingletonJobRunner.Outcome purgeDeletedMerchants() {
+        return runner.run(MERCHANT_PURGE_JOB, slot(), merchantLifecycleService::purgeDeleted);
+    }
+    
+    @Scheduled(fixedDelayString = "${outbox.purge-interval-ms:3600000}")
+    public SingletonJobRunner.Outcome purgePublishedEvents() {
+        return runner.run(OUTBOX_PURGE_JOB, slot(), outboxDispatcher::purgePublished);
+    }
+    
+    // Only with idempotency.store=postgres
+    @Scheduled(fixedDelayString = "${idempotency.postgres.purge-interval-ms:600000}")
+    public SingletonJobRunner.Outcome purgeExpiredIdempotencyKeys() {
+        JdbcIdempotencyStore store = idempotencyStore.getIfAvailable();
+        return store != null ? runner.run(IDEMPOTENCY_PURGE_JOB, slot(), store::purgeExpired) : null;
+    }
+    
+    // Two instances would send the same delivery twice
+    @Scheduled(fixedDelayString = "${webhook.retry-interval-ms:1000}")
+    public SingletonJobRunner.Outcome processWebhookRetries() {
+        return runner.runOnLeader(WEBHOOK_RETRY_JOB, webhookService::processRetries);
+    }
+    
+    // Two instances would authorize the same file's payments twice
+    @Scheduled(fixedDelayString = "${batch.poll-interval-ms:2000}")
+    public SingletonJobRunner.Outcome processBatchFiles() {
+        return runner.runOnLeader(BATCH_FILE_JOB, batchFileProcessor::processNext);
+    }
+    
+    // Instances fire a little apart; the minute names the run for all
+    private Instant slot() {
+        return clock.instant().truncatedTo(ChronoUnit.MINUTES);
+    }
+}
@@ -0,0 +1,85 @@
+package com.paymentgateway.authorization.screening;
+
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.text.Normalizer;
+import java.util.ArrayList;
+import java.util.LinkedHashSet;
+import java.util.List;
+import java.util.Locale;
+import java.util.Set;
+
+/**
+ * The gateway's own denylist of names and countries, from configuration.
+ * Names match regardless of case, accents and punctuation, and a listed
+ * name matches a longer name that contains it as whole words, so "Ivan
+ * Petrov" matches "Mr. Ivan Petrov" but not "Ivana Petrova"; countries
+ * match exactly.
+ */
+@Component
+public class DenylistScreeningProvider implements ScreeningProvider {
+    
+    public static final String NAME = "denylist";
+    
+    private final List<String> names = new ArrayList<>();
+    private final Set<String> countries = new LinkedHashSet<>();
+    
+    @Autowired
+    public DenylistScreeningProvider(@Value("${screening.denylist.names:}") String names,
+                                     @Value("${screening.denylist.countries:}") String countries) {
+        this(split(names), split(countries));
+    }
+    
+    public DenylistScreeningProvider(List<String> names, List<String> countries) {
+        for (String name : names) {
+            String normalized = normalize(name);
+            if (!normalized.isEmpty()) {
+                this.names.add(normalized);
+            }
+        }
+        for (String country : countries) {
+            this.countries.add(country.trim().toUpperCase(Locale.ROOT));
+        }
+    }
+    
+    @Override
+    public List<ScreeningMatch> screen(ScreeningSubject subject) {
+        List<ScreeningMatch> matches = new ArrayList<>();
+        if (subject.getCountry() != null && countries.contains(subject.getCountry().toUpperCase(Locale.ROOT))) {
+            matches.add(new ScreeningMatch(NAME, "country " + subject.getCountry().toUpperCase(Locale.ROOT)));
+        }
+        if (subject.getName() != null) {
+            String name = " " + normalize(subject.getName()) + " ";
+            for (String listed : names) {
+                if (name.contains(" " + listed + " ")) {
+                    matches.add(new ScreeningMatch(NAME, "name " + listed));
+                }
+            }
+        }
+        return matches;
+    }
+    
+    @Override
+    public String getProviderName() {
+        return NAME;
+    }
+    
+    // Lower case without accents, with every run of other characters a
+    // single space
+    static String normalize(String name) {
+        String stripped = Normalizer.normalize(name, Normalizer.Form.NFD).replaceAll("\\p{M}", "");
+        return stripped.toLowerCase(Locale.ROOT).replaceAll("[^a-z0-9]+", " ").trim();
+    }
+    
+    private static List<String> split(String list) {
+        List<String> entries = new ArrayList<>();
+        for (String entry : list.split(",")) {
+            if (!entry.isBlank()) {
+                entries.add(entry.trim());
+            }
+        }
+        return entries;
+    }
+}
@@ -0,0 +1,12 @@
+package com.paymentgateway.authorization.screening;
+
+/**
+ * A payment capture or merchant approval refused while a screening hit is
+ * held, or after one was confirmed
+ */
+public class ScreeningHoldException extends IllegalStateException {
+    
+    public ScreeningHoldException(String message) {
+        super(message);
+    }
+}
@@ -0,0 +1,18 @@
+package com.paymentgateway.authorization.screening;
+
+/**
+ * A list entry a subject matched, and the provider whose list it is on
+ */
+public final class ScreeningMatch {
+    
+    private final String provider;
+    private final String entry;
+    
+    public ScreeningMatch(String provider, String entry) {
+        this.provider = provider;
+        this.entry = entry;
+    }
+    
+    public String getProvider() { return provider; }
+    public String getEntry() { return entry; }
+}
@@ -0,0 +1,25 @@
+package com.paymentgateway.authorization.screening;
+
+import java.util.List;
+
+/**
+ * A sanctions or denylist screening source. The gateway screens against
+ * every provider bean; an external screening service plugs in by
+ * implementing this.
+ */
+public interface ScreeningProvider {
+    
+    /**
+     * Screen a subject
+     * 
+     * @return The entries it matched, empty if none
+     * @throws RuntimeException if the provider could not screen it, which
+     *         holds the subject as if it matched
+     */
+    List<ScreeningMatch> screen(ScreeningSubject subject);
+    
+    /**
+     * Get the provider name
+     */
+    String getProviderName();
+}
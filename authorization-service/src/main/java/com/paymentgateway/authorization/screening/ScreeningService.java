@@ -0,0 +1,173 @@
+package com.paymentgateway.authorization.screening;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.ScreeningHit;
+import com.paymentgateway.authorization.domain.ScreeningHitStatus;
+import com.paymentgateway.authorization.domain.ScreeningSubjectType;
+import com.paymentgateway.authorization.repository.ScreeningHitRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.time.Clock;
+import java.util.ArrayList;
+import java.util.EnumSet;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Sanctions and denylist screening. Merchants are screened when they submit
+ * their onboarding application, and cardholders by name and billing
+ * country on every payment. A match is recorded as a held hit: a held
+ * payment is authorized but cannot be captured, and a held merchant cannot
+ * be approved, until an administrator releases the hit as a false
+ * positive. Confirming it instead voids the payment, or leaves the
+ * merchant to be rejected. A provider that fails holds the subject too.
+ */
+@Service
+public class ScreeningService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(ScreeningService.class);
+    
+    // The entry of a hit recorded because a provider could not screen
+    public static final String PROVIDER_UNAVAILABLE = "screening unavailable";
+    
+    private final List<ScreeningProvider> providers;
+    private final ScreeningHitRepository hitRepository;
+    private final boolean enabled;
+    private final Clock clock;
+    
+    @Autowired
+    public ScreeningService(List<ScreeningProvider> providers,
+                            ScreeningHitRepository hitRepository,
+                            @Value("${screening.enabled:true}") boolean enabled) {
+        this(providers, hitRepository, enabled, Clock.systemUTC());
+    }
+    
+    public ScreeningService(List<ScreeningProvider> providers,
+                            ScreeningHitRepository hitRepository,
+                            boolean enabled,
+                            Clock clock) {
+        this.providers = providers;
+        this.hitRepository = hitRepository;
+        this.enabled = enabled;
+        this.clock = clock;
+    }
+    
+    /**
+     * Screen a subject against every provider
+     * 
+     * @return The matches, empty if it is clear or screening is disabled
+     */
+    public List<ScreeningMatch> screen(ScreeningSubject subject) {
+        List<ScreeningMatch> matches = new ArrayList<>();
+        if (!enabled) {
+            return matches;
+        }
+        for (ScreeningProvider provider : providers) {
+            try {
+                matches.addAll(provider.screen(subject));
+            } catch (RuntimeException e) {
+                logger.warn("Screening provider {} failed for {}, holding it: {}",
+                        provider.getProviderName(), subject.getType(), e.getMessage());
+                matches.add(new ScreeningMatch(provider.getProviderName(), PROVIDER_UNAVAILABLE));
+            }
+        }
+        return matches;
+    }
+    
+    /**
+     * Record a held hit for each match of a subject
+     * 
+     * @param paymentId The payment held, null for a merchant
+     */
+    @Transactional
+    public List<ScreeningHit> hold(ScreeningSubject subject, UUID merchantId, String paymentId,
+                                   List<ScreeningMatch> matches) {
+        List<ScreeningHit> hits = new ArrayList<>();
+        for (ScreeningMatch match : matches) {
+            ScreeningHit hit = new ScreeningHit();
+            hit.setHitId("scr_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24));
+            hit.setSubjectType(subject.getType());
+            hit.setMerchantId(merchantId);
+            hit.setPaymentId(paymentId);
+            hit.setScreenedName(subject.getName());
+            hit.setScreenedCountry(subject.getCountry());
+            hit.setProvider(match.getProvider());
+            hit.setMatchedEntry(match.getEntry());
+            hit.setCreatedAt(clock.instant());
+            hits.add(hitRepository.save(hit));
+        }
+        logger.warn("Screening held {} for merchant {}{}: {} hits", subject.getType(), merchantId,
+                paymentId != null ? ", payment " + paymentId : "", hits.size());
+        return hits;
+    }
+    
+    public boolean isPaymentHeld(String paymentId) {
+        return hitRepository.existsByPaymentIdAndStatus(paymentId, ScreeningHitStatus.HELD);
+    }
+    
+    /**
+     * Refuse to go on with a payment that has a held hit
+     */
+    public void checkPaymentCleared(String paymentId) {
+        if (isPaymentHeld(paymentId)) {
+            throw new ScreeningHoldException("Payment " + paymentId + " is held by sanctions screening");
+        }
+    }
+    
+    /**
+     * Refuse to go on with a merchant that has a held or confirmed hit
+     */
+    public void checkMerchantCleared(Merchant merchant) {
+        if (hitRepository.existsByMerchantIdAndSubjectTypeAndStatusIn(merchant.getId(), ScreeningSubjectType.MERCHANT,
+                EnumSet.of(ScreeningHitStatus.HELD, ScreeningHitStatus.CONFIRMED))) {
+            throw new ScreeningHoldException("Merchant " + merchant.getMerchantId()
+                    + " has unreleased sanctions screening hits");
+        }
+    }
+    
+    public Optional<ScreeningHit> find(String hitId) {
+        return hitRepository.findByHitId(hitId);
+    }
+    
+    public List<ScreeningHit> list(ScreeningHitStatus status) {
+        return hitRepository.findByStatusOrderByCreatedAtAsc(status);
+    }
+    
+    public List<ScreeningHit> paymentHits(String paymentId) {
+        return hitRepository.findByPaymentIdOrderByCreatedAtAsc(paymentId);
+    }
+    
+    /**
+     * Release a held hit as a false positive
+     */
+    @Transactional
+    public ScreeningHit release(ScreeningHit hit, String decidedBy, String note) {
+        return decide(hit, ScreeningHitStatus.RELEASED, decidedBy, note);
+    }
+    
+    /**
+     * Confirm a held hit as a true match
+     */
+    @Transactional
+    public ScreeningHit confirm(ScreeningHit hit, String decidedBy, String note) {
+        return decide(hit, ScreeningHitStatus.CONFIRMED, decidedBy, note);
+    }
+    
+    private ScreeningHit decide(ScreeningHit hit, ScreeningHitStatus status, String decidedBy, String note) {
+        if (hit.getStatus() != ScreeningHitStatus.HELD) {
+            throw new IllegalStateException("Screening hit " + hit.getHitId() + " is already " + hit.getStatus());
+        }
+        hit.setStatus(status);
+        hit.setDecidedBy(decidedBy);
+        hit.setDecisionNote(note);
+        hit.setDecidedAt(clock.instant());
+        logger.info("Screening hit {} {} by {}", hit.getHitId(), status, decidedBy);
+        return hitRepository.save(hit);
+    }
+}
@@ -0,0 +1,28 @@
+package com.paymentgateway.authorization.screening;
+
+import com.paymentgateway.authorization.domain.ScreeningSubjectType;
+
+/**
+ * A party to screen: a name, a country, or both
+ */
+public final class ScreeningSubject {
+    
+    private final ScreeningSubjectType type;
+    private final String name;
+    private final String country;
+    
+    public ScreeningSubject(ScreeningSubjectType type, String name, String country) {
+        this.type = type;
+        this.name = name;
+        this.country = country;
+    }
+    
+    public ScreeningSubjectType getType() { return type; }
+    public String getName() { return name; }
+    public String getCountry() { return country; }
+    
+    @Override
+    public String toString() {
+        return type + " " + name + " (" + country + ")";
+    }
+}
@@ -12,8 +12,19 @@ public class ApiKeyGenerator {
      * Generate a cryptographically secure API key
      */
     public static String generate() {
+        return "sk_" + randomPart();
+    }
+    
+    /**
+     * Generate a live-mode API key, issued once onboarding is approved
+     */
+    public static String generateLive() {
+        return "sk_live_" + randomPart();
+    }
+    
+    private static String randomPart() {
         byte[] randomBytes = new byte[32]; // 256 bits
         secureRandom.nextBytes(randomBytes);
-        return "sk_" + base64Encoder.encodeToString(randomBytes);
+        return base64Encoder.encodeToString(randomBytes);
     }
 }
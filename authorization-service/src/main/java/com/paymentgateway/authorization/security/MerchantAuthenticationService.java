@@ -1,6 +1,7 @@
 package com.paymentgateway.authorization.security;
 
 import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.OnboardingStatus;
 import com.paymentgateway.authorization.repository.MerchantRepository;
 import org.springframework.security.crypto.password.PasswordEncoder;
 import org.springframework.stereotype.Service;
@@ -38,12 +39,20 @@ public class MerchantAuthenticationService {
         // Note: In production, we'd need to iterate through merchants and verify
         // the hash since we can't query by hash directly with bcrypt
         return merchantRepository.findAll().stream()
-                .filter(m -> m.getIsActive() && 
-                           m.getApiKeyHash() != null &&
-                           passwordEncoder.matches(apiKey, m.getApiKeyHash()))
+                .filter(m -> m.getIsActive() && matchesKey(m, apiKey))
                 .findFirst();
     }
     
+    // A live-mode key only works once the merchant has gone live
+    private boolean matchesKey(Merchant merchant, String apiKey) {
+        if (merchant.getApiKeyHash() != null && passwordEncoder.matches(apiKey, merchant.getApiKeyHash())) {
+            return true;
+        }
+        return merchant.getOnboardingStatus() == OnboardingStatus.LIVE &&
+               merchant.getLiveApiKeyHash() != null &&
+               passwordEncoder.matches(apiKey, merchant.getLiveApiKeyHash());
+    }
+    
     /**
      * Validate JWT token and extract merchant
      */
@@ -87,6 +96,25 @@ public class MerchantAuthenticationService {
         return apiKey;
     }
     
+    /**
+     * Create a live-mode API key for a merchant whose onboarding allows it.
+     * Replaces any live key it had.
+     */
+    public String createLiveApiKey(Merchant merchant) {
+        String apiKey = ApiKeyGenerator.generateLive();
+        merchant.setLiveApiKeyHash(passwordEncoder.encode(apiKey));
+        merchantRepository.save(merchant);
+        return apiKey;
+    }
+    
+    /**
+     * Revoke the live-mode API key
+     */
+    public void revokeLiveApiKey(Merchant merchant) {
+        merchant.setLiveApiKeyHash(null);
+        merchantRepository.save(merchant);
+    }
+    
     /**
      * Revoke API key
      */
@@ -0,0 +1,119 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.*;
+import com.paymentgateway.authorization.dto.CreditRequest;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import com.paymentgateway.authorization.psp.*;
+import com.paymentgateway.authorization.repository.PaymentEventRepository;
+import com.paymentgateway.authorization.repository.PaymentRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.time.Instant;
+import java.util.UUID;
+
+/**
+ * Processes standalone credits (original credits). Unlike a refund there is
+ * no payment to check the amount against, so merchants must be enabled for
+ * them and the network may still refuse them for the merchant's category.
+ * An approved credit is stored as a captured payment of type CREDIT and
+ * settles as money paid out to the cardholder.
+ */
+@Service
+public class CreditService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(CreditService.class);
+    
+    private final PaymentRepository paymentRepository;
+    private final PaymentEventRepository paymentEventRepository;
+    private final PSPRoutingService pspRoutingService;
+    private final PaymentEventPublisher eventPublisher;
+    
+    public CreditService(PaymentRepository paymentRepository,
+                        PaymentEventRepository paymentEventRepository,
+                        PSPRoutingService pspRoutingService,
+                        PaymentEventPublisher eventPublisher) {
+        this.paymentRepository = paymentRepository;
+        this.paymentEventRepository = paymentEventRepository;
+        this.pspRoutingService = pspRoutingService;
+        this.eventPublisher = eventPublisher;
+    }
+    
+    @Transactional
+    public PaymentResponse processCredit(CreditRequest request, Merchant merchant) {
+        if (!Boolean.TRUE.equals(merchant.getStandaloneCreditsEnabled())) {
+            throw new IllegalStateException("Standalone credits are not enabled for merchant: " + merchant.getMerchantId());
+        }
+        logger.info("Processing standalone credit for merchant: {}, amount: {}", merchant.getMerchantId(), request.getAmount());
+        
+        Payment payment = new Payment();
+        payment.setPaymentId("crd_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24));
+        payment.setMerchantId(merchant.getId());
+        payment.setTransactionType(TransactionType.CREDIT);
+        payment.setAmount(request.getAmount());
+        payment.setCurrency(request.getCurrency());
+        payment.setDescription(request.getReason());
+        payment.setReferenceId(request.getReferenceId());
+        payment.setStatus(PaymentStatus.PENDING);
+        payment.setCardTokenId(UUID.randomUUID()); // Simulated tokenization, as for payments
+        payment.setCardLastFour(request.getCardNumber().substring(request.getCardNumber().length() - 4));
+        payment.setCardBrand(CardBrand.fromPan(request.getCardNumber()));
+        payment.setStatementDescriptor(DescriptorRules.staticDescriptor(merchant));
+        
+        PSPAuthorizationRequest pspRequest = new PSPAuthorizationRequest();
+        pspRequest.setMerchantId(payment.getMerchantId());
+        pspRequest.setMerchantCategoryCode(merchant.getMcc());
+        pspRequest.setAmount(payment.getAmount());
+        pspRequest.setCurrency(payment.getCurrency());
+        pspRequest.setCardTokenId(payment.getCardTokenId());
+        pspRequest.setCardLastFour(payment.getCardLastFour());
+        pspRequest.setCardBrand(payment.getCardBrand().name());
+        pspRequest.setCardFingerprint(CardFingerprint.of(request.getCardNumber()));
+        pspRequest.setDescription(payment.getDescription());
+        pspRequest.setReferenceId(payment.getReferenceId());
+        pspRequest.setDescriptor(payment.getStatementDescriptor());
+        
+        PSPClient pspClient = pspRoutingService.selectPSP(payment.getMerchantId());
+        PSPRefundResponse pspResponse = pspClient.credit(pspRequest);
+        payment.setPspName(pspClient.getPSPName());
+        
+        if (pspResponse.isSuccess()) {
+            payment.setStatus(PaymentStatus.CAPTURED);
+            payment.setPspTransactionId(pspResponse.getPspRefundId());
+            payment.setAuthorizedAt(Instant.now());
+            payment.setCapturedAt(Instant.now());
+        } else {
+            payment.setStatus(PaymentStatus.DECLINED);
+            payment.setDeclineCode(pspResponse.getErrorCode());
+            logger.warn("Standalone credit declined: paymentId={}, code={}", payment.getPaymentId(), pspResponse.getErrorCode());
+        }
+        payment = paymentRepository.save(payment);
+        
+        PaymentEvent event = new PaymentEvent(payment.getId(), "CREDIT", pspResponse.isSuccess() ? "SUCCESS" : "DECLINED");
+        event.setAmount(payment.getAmount());
+        event.setCurrency(payment.getCurrency());
+        paymentEventRepository.save(event);
+        
+        eventPublisher.publishPaymentEvent(payment, pspResponse.isSuccess()
+            ? PaymentEventType.PAYMENT_REFUNDED : PaymentEventType.PAYMENT_DECLINED);
+        
+        PaymentResponse response = new PaymentResponse();
+        response.setPaymentId(payment.getPaymentId());
+        response.setStatus(payment.getStatus());
+        response.setAmount(payment.getAmount());
+        response.setCurrency(payment.getCurrency());
+        response.setCardLastFour(payment.getCardLastFour());
+        response.setCardBrand(payment.getCardBrand().name());
+        response.setCreatedAt(payment.getCreatedAt());
+        response.setStatementDescriptor(payment.getStatementDescriptor());
+        if (payment.getStatus() == PaymentStatus.DECLINED) {
+            response.setErrorCode(payment.getDeclineCode());
+            response.setErrorMessage(pspResponse.getErrorMessage());
+        }
+        return response;
+    }
+}
@@ -0,0 +1,96 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Optional;
+
+/**
+ * Soft deletion of merchants. A deleted merchant disappears from every normal
+ * query, so it can no longer authenticate or be listed, but its row is kept
+ * and can be restored within the restore window. After that a scheduled job
+ * purges it, unless payments or other records still reference it.
+ */
+@Service
+public class MerchantLifecycleService {
+
+    private static final Logger logger = LoggerFactory.getLogger(MerchantLifecycleService.class);
+
+    public enum RestoreResult { RESTORED, NOT_FOUND, WINDOW_EXPIRED }
+
+    private final MerchantRepository merchantRepository;
+    private final Duration restoreWindow;
+    private final Clock clock;
+
+    @Autowired
+    public MerchantLifecycleService(MerchantRepository merchantRepository,
+                                    @Value("${merchant.restore-window-days:30}") long restoreWindowDays) {
+        this(merchantRepository, Duration.ofDays(restoreWindowDays), Clock.systemUTC());
+    }
+
+    public MerchantLifecycleService(MerchantRepository merchantRepository, Duration restoreWindow, Clock clock) {
+        this.merchantRepository = merchantRepository;
+        this.restoreWindow = restoreWindow;
+        this.clock = clock;
+    }
+
+    /**
+     * Soft-delete a merchant.
+     *
+     * @return The deleted merchant, or empty if there is no such live merchant
+     */
+    @Transactional
+    public Optional<Merchant> delete(String merchantId) {
+        return merchantRepository.findByMerchantId(merchantId).map(merchant -> {
+            merchant.setDeletedAt(clock.instant());
+            logger.info("Merchant {} deleted; restorable until {}", merchantId, restorableUntil(merchant));
+            return merchantRepository.save(merchant);
+        });
+    }
+
+    /**
+     * Restore a soft-deleted merchant whose restore window is still open
+     */
+    @Transactional
+    public RestoreResult restore(String merchantId) {
+        Optional<Merchant> deleted = merchantRepository.findDeletedByMerchantId(merchantId);
+        if (deleted.isEmpty()) {
+            return RestoreResult.NOT_FOUND;
+        }
+        if (clock.instant().isAfter(restorableUntil(deleted.get()))) {
+            return RestoreResult.WINDOW_EXPIRED;
+        }
+        merchantRepository.restore(deleted.get().getId());
+        logger.info("Merchant {} restored", merchantId);
+        return RestoreResult.RESTORED;
+    }
+
+    public Instant restorableUntil(Merchant merchant) {
+        return merchant.getDeletedAt().plus(restoreWindow);
+    }
+
+    /**
+     * Permanently delete merchants whose restore window has passed
+     *
+     * @return The number of merchants purged
+     */
+    @Transactional
+    public int purgeDeleted() {
+        Instant cutoff = clock.instant().minus(restoreWindow);
+        merchantRepository.purgeDeletedRoles(cutoff);
+        int purged = merchantRepository.purgeDeleted(cutoff);
+        if (purged > 0) {
+            logger.info("Purged {} merchants deleted before {}", purged, cutoff);
+        }
+        return purged;
+    }
+}
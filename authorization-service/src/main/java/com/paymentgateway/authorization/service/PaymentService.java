@@ -1,23 +1,51 @@
 package com.paymentgateway.authorization.service;
 
 import com.paymentgateway.authorization.domain.*;
+import com.paymentgateway.authorization.dto.AmountBreakdown;
+import com.paymentgateway.authorization.dto.BillingAddress;
+import com.paymentgateway.authorization.dto.Installments;
+import com.paymentgateway.authorization.dto.LineItem;
 import com.paymentgateway.authorization.dto.PaymentRequest;
 import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.dto.Split;
 import com.paymentgateway.authorization.event.PaymentEventPublisher;
 import com.paymentgateway.authorization.event.PaymentEventType;
+import com.paymentgateway.authorization.geo.GeoAssessment;
+import com.paymentgateway.authorization.geo.GeoRules;
 import com.paymentgateway.authorization.idempotency.IdempotencyService;
+import com.paymentgateway.authorization.installment.InstallmentService;
+import com.paymentgateway.authorization.networktoken.CryptogramCheck;
+import com.paymentgateway.authorization.networktoken.NetworkTokenCryptograms;
 import com.paymentgateway.authorization.psp.*;
+import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.repository.PaymentEventRepository;
 import com.paymentgateway.authorization.repository.PaymentRepository;
+import com.paymentgateway.authorization.resilience.CircuitBreakerRegistry;
+import com.paymentgateway.authorization.sca.ScaAssessment;
+import com.paymentgateway.authorization.sca.ScaService;
+import com.paymentgateway.authorization.screening.ScreeningMatch;
+import com.paymentgateway.authorization.screening.ScreeningService;
+import com.paymentgateway.authorization.screening.ScreeningSubject;
+import com.paymentgateway.authorization.surcharge.SurchargeAssessment;
+import com.paymentgateway.authorization.surcharge.SurchargeService;
+import com.paymentgateway.authorization.tokens.EncryptedCardTokenizer;
+import com.paymentgateway.authorization.tokens.TokenizedCard;
 import io.opentelemetry.api.trace.Span;
 import io.opentelemetry.api.trace.Tracer;
 import io.opentelemetry.context.Context;
+import jakarta.validation.ValidationException;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.lang.Nullable;
 import org.springframework.stereotype.Service;
 import org.springframework.transaction.annotation.Transactional;
 
+import java.math.BigDecimal;
+import java.nio.charset.StandardCharsets;
 import java.time.Instant;
+import java.util.ArrayList;
+import java.util.HexFormat;
+import java.util.List;
 import java.util.UUID;
 
 @Service
@@ -25,31 +53,69 @@ public class PaymentService {
     
     private static final Logger logger = LoggerFactory.getLogger(PaymentService.class);
     
+    // Decline code of an approval voided because the merchant's AVS policy
+    // does not accept the issuer's AVS result
+    public static final String AVS_DECLINE_CODE = "avs_mismatch";
+    
     private final PaymentRepository paymentRepository;
     private final PaymentEventRepository paymentEventRepository;
     private final PSPRoutingService pspRoutingService;
     private final Tracer tracer;
     private final IdempotencyService idempotencyService;
     private final PaymentEventPublisher eventPublisher;
+    private final ScaService scaService;
+    private final CircuitBreakerRegistry circuitBreakers;
+    private final MerchantRepository merchantRepository;
+    private final SurchargeService surchargeService;
+    private final InstallmentService installmentService;
+    private final ScreeningService screeningService;
+    private final GeoRules geoRules;
+    private final NetworkTokenCryptograms networkTokens;
+    private final PinTranslation pinTranslation;
+    private final EncryptedCardTokenizer encryptedCards;
     
     public PaymentService(PaymentRepository paymentRepository,
                          PaymentEventRepository paymentEventRepository,
                          PSPRoutingService pspRoutingService,
                          Tracer tracer,
                          IdempotencyService idempotencyService,
-                         PaymentEventPublisher eventPublisher) {
+                         PaymentEventPublisher eventPublisher,
+                         ScaService scaService,
+                         CircuitBreakerRegistry circuitBreakers,
+                         MerchantRepository merchantRepository,
+                         SurchargeService surchargeService,
+                         InstallmentService installmentService,
+                         ScreeningService screeningService,
+                         GeoRules geoRules,
+                         NetworkTokenCryptograms networkTokens,
+                         PinTranslation pinTranslation,
+                         @Nullable EncryptedCardTokenizer encryptedCards) {
         this.paymentRepository = paymentRepository;
         this.paymentEventRepository = paymentEventRepository;
         this.pspRoutingService = pspRoutingService;
         this.tracer = tracer;
         this.idempotencyService = idempotencyService;
         this.eventPublisher = eventPublisher;
+        this.scaService = scaService;
+        this.circuitBreakers = circuitBreakers;
+        this.merchantRepository = merchantRepository;
+        this.surchargeService = surchargeService;
+        this.installmentService = installmentService;
+        this.screeningService = screeningService;
+        this.geoRules = geoRules;
+        this.networkTokens = networkTokens;
+        this.pinTranslation = pinTranslation;
+        this.encryptedCards = encryptedCards;
     }
     
     @Transactional
     public PaymentResponse processPayment(PaymentRequest request, UUID merchantId, String idempotencyKey) {
         // Check for existing result if idempotency key is provided
         if (idempotencyKey != null && !idempotencyKey.isBlank()) {
+            // A key reused for a different request must not return this one's result
+            String requestHash = idempotencyService.requestHash(merchantId, request);
+            idempotencyService.checkRequest(idempotencyKey, requestHash);
+            
             PaymentResponse existingResult = idempotencyService.getExistingResult(idempotencyKey, PaymentResponse.class);
             if (existingResult != null) {
                 logger.info("Returning cached payment result for idempotency key: {}", idempotencyKey);
@@ -59,6 +125,7 @@ public class PaymentService {
             // Acquire distributed lock to prevent concurrent processing
             if (!idempotencyService.acquireLock(idempotencyKey)) {
                 // Check again for result (another thread may have completed while we waited)
+                idempotencyService.checkRequest(idempotencyKey, requestHash);
                 existingResult = idempotencyService.getExistingResult(idempotencyKey, PaymentResponse.class);
                 if (existingResult != null) {
                     logger.info("Returning cached payment result after lock wait for idempotency key: {}", idempotencyKey);
@@ -68,6 +135,7 @@ public class PaymentService {
             }
             
             try {
+                idempotencyService.recordRequest(idempotencyKey, requestHash);
                 PaymentResponse response = processPaymentInternal(request, merchantId);
                 // Store result atomically with idempotency key
                 idempotencyService.storeResult(idempotencyKey, response);
@@ -85,6 +153,26 @@ public class PaymentService {
     private PaymentResponse processPaymentInternal(PaymentRequest request, UUID merchantId) {
         long startTime = System.currentTimeMillis();
         
+        if (request.getOriginalPaymentId() != null) {
+            validateResubmission(request, merchantId);
+        }
+        Merchant merchant = merchantRepository.findById(merchantId).orElse(null);
+        // An encrypted card is only ever seen as its token, BIN and last
+        // four digits; the BIN stands in for the PAN wherever its leading
+        // digits are all that is read
+        TokenizedCard encryptedCard = request.getEncryptedCard() != null
+            ? tokenizeEncryptedCard(request, merchantId) : null;
+        String cardPrefix = encryptedCard != null ? encryptedCard.getBin() : request.getCardNumber();
+        CardBrand cardBrand = CardBrand.fromPan(cardPrefix);
+        String descriptor = statementDescriptor(merchant, cardBrand, request.getDynamicDescriptor());
+        SurchargeAssessment surcharge = surchargeService.assess(merchant, cardBrand, request.getChannel(), request.getAmount());
+        List<PaymentSplit> splits = splits(request.getSplits(), merchantId);
+        Installments installments = request.getInstallments();
+        if (installments != null) {
+            installmentService.check(cardPrefix, merchant != null ? merchant.getCountryCode() : null,
+                installments);
+        }
+        
         // Create distributed trace span
         Span span = tracer.spanBuilder("processPayment").startSpan();
         try (var scope = span.makeCurrent()) {
@@ -96,48 +184,222 @@ public class PaymentService {
             Payment payment = new Payment();
             payment.setPaymentId(paymentId);
             payment.setMerchantId(merchantId);
-            payment.setAmount(request.getAmount());
+            payment.setAmount(surcharge.getTotalAmount());
+            payment.setSurchargeAmount(surcharge.getSurchargeAmount());
+            payment.setConvenienceFee(surcharge.getConvenienceFee());
             payment.setCurrency(request.getCurrency());
             payment.setDescription(request.getDescription());
             payment.setReferenceId(request.getReferenceId());
             payment.setStatus(PaymentStatus.PENDING);
-            payment.setBillingStreet(request.getBillingStreet());
-            payment.setBillingCity(request.getBillingCity());
-            payment.setBillingState(request.getBillingState());
-            payment.setBillingZip(request.getBillingZip());
-            payment.setBillingCountry(request.getBillingCountry());
+            BillingAddress billing = request.billingAddress();
+            payment.setBillingStreet(billing.getLine1());
+            payment.setBillingStreet2(billing.getLine2());
+            payment.setBillingCity(billing.getCity());
+            payment.setBillingState(billing.getState());
+            payment.setBillingZip(billing.getPostalCode());
+            payment.setBillingCountry(billing.getCountry());
+            payment.setOriginalPaymentId(request.getOriginalPaymentId());
+            if (request.getChannel() != null) {
+                payment.setChannel(request.getChannel());
+            }
+            if (request.getInitiator() != null) {
+                payment.setInitiator(request.getInitiator());
+            }
+            payment.setStoredCredential(request.getStoredCredential());
+            payment.setOriginalNetworkTransactionId(request.getOriginalNetworkTransactionId());
+            payment.setTaxAmount(request.getTaxAmount());
+            payment.setCustomerCode(request.getCustomerCode());
+            payment.setPurchaseDataLevel(request.purchaseDataLevel());
+            if (request.getLineItems() != null) {
+                for (LineItem item : request.getLineItems()) {
+                    payment.getLineItems().add(lineItem(item));
+                }
+            }
+            payment.getSplits().addAll(splits);
+            if (installments != null) {
+                payment.setInstallmentCount(installments.getCount());
+                payment.setInstallmentPlanType(installments.getPlanType());
+            }
+            
+            // Each step is recorded for the payment's timeline under one
+            // correlation ID, and saved once the payment has its ID
+            UUID correlationId = UUID.randomUUID();
+            List<PaymentEvent> steps = new ArrayList<>();
             
-            // Step 1: Tokenization (simulated - would call tokenization service via gRPC)
+            // Step 1: Tokenization (simulated - would call tokenization service via gRPC);
+            // an encrypted card was tokenized by the decryption zone
             span.addEvent("tokenization_start");
-            UUID tokenId = simulateTokenization(request.getCardNumber());
-            payment.setCardTokenId(tokenId);
-            payment.setCardLastFour(request.getCardNumber().substring(request.getCardNumber().length() - 4));
-            payment.setCardBrand(CardBrand.VISA); // Simplified
+            if (encryptedCard != null) {
+                payment.setCardTokenId(UUID.nameUUIDFromBytes(encryptedCard.getToken().getBytes(StandardCharsets.UTF_8)));
+                payment.setCardLastFour(encryptedCard.getLastFour());
+            } else {
+                UUID tokenId = circuitBreakers.get(CircuitBreakerRegistry.TOKENIZATION)
+                    .execute(() -> simulateTokenization(request.getCardNumber()));
+                payment.setCardTokenId(tokenId);
+                payment.setCardLastFour(request.getCardNumber().substring(request.getCardNumber().length() - 4));
+            }
+            payment.setCardBrand(cardBrand);
+            payment.setStatementDescriptor(descriptor);
             span.addEvent("tokenization_complete");
+            PaymentEvent tokenization = step("TOKENIZATION", "SUCCESS", correlationId);
+            if (encryptedCard != null) {
+                tokenization.setDescription("P2PE read tokenized by the decryption zone");
+            }
+            steps.add(tokenization);
+            if (surcharge.getOutcome() != SurchargeAssessment.Outcome.NONE) {
+                steps.add(surchargeStep(surcharge, payment, correlationId));
+            }
+            
+            // A network token needs a valid cryptogram, generated for the
+            // amount before any surcharge; without one it is declined
+            // without going to an acquirer
+            CryptogramCheck cryptogram = networkTokens.check(request.getCardNumber(), request.getTokenCryptogram(),
+                merchantId, request.getAmount(), request.getCurrency());
+            if (cryptogram != null) {
+                PaymentEvent cryptogramStep = step("CRYPTOGRAM_CHECK", cryptogram.getOutcome().name(), correlationId);
+                if (!cryptogram.isValid()) {
+                    cryptogramStep.setDescription(cryptogram.getMessage());
+                    cryptogramStep.setErrorCode(cryptogram.getDeclineCode());
+                }
+                steps.add(cryptogramStep);
+            }
+            boolean cryptogramFailed = cryptogram != null && !cryptogram.isValid();
             
             // Step 2: Fraud Detection (simulated - would call fraud detection service via gRPC)
             span.addEvent("fraud_detection_start");
             payment.setFraudScore(java.math.BigDecimal.valueOf(0.15));
             payment.setFraudStatus(FraudStatus.CLEAN);
             span.addEvent("fraud_detection_complete");
+            steps.add(step("FRAUD_CHECK", payment.getFraudStatus().name(), correlationId));
+            
+            // Geo rules on the card's issuing country; a blocked card is
+            // declined without going to an acquirer
+            GeoAssessment geo = geoRules.assess(merchant, cardPrefix);
+            payment.setIssuerCountry(geo.getIssuerCountry());
+            payment.setCrossBorder(geo.isCrossBorder());
+            if (geo.getOutcome() == GeoAssessment.Outcome.FLAGGED) {
+                payment.setFraudStatus(FraudStatus.REVIEW);
+            }
+            PaymentEvent geoStep = step("GEO_CHECK", geo.getOutcome().name(), correlationId);
+            String issuedIn = geo.getIssuerCountry() != null ? geo.getIssuerCountry() : "an unknown country";
+            geoStep.setDescription("Issued in " + issuedIn + (geo.isCrossBorder() ? ", cross-border" : ""));
+            geoStep.setErrorCode(geo.getDeclineCode());
+            steps.add(geoStep);
+            boolean geoBlocked = geo.getOutcome() == GeoAssessment.Outcome.BLOCKED;
+            
+            // Sanctions screening of the cardholder; a match holds an
+            // approved payment's capture for review
+            ScreeningSubject cardholder = new ScreeningSubject(ScreeningSubjectType.CARDHOLDER,
+                request.getCardholderName(), payment.getBillingCountry());
+            List<ScreeningMatch> screeningMatches = screeningService.screen(cardholder);
+            steps.add(step("SANCTIONS_SCREENING", screeningMatches.isEmpty() ? "CLEAR" : "MATCH", correlationId));
             
             // Step 3: 3D Secure (simulated - would call 3DS service via gRPC if needed)
             span.addEvent("3ds_check_start");
-            payment.setThreeDsStatus(ThreeDSStatus.NOT_ENROLLED);
+            if (request.getThreeDsCavv() != null) {
+                payment.setThreeDsStatus(ThreeDSStatus.AUTHENTICATED);
+                payment.setThreeDsCavv(request.getThreeDsCavv());
+                payment.setThreeDsEci(request.getThreeDsEci());
+                payment.setThreeDsTransactionId(request.getThreeDsTransactionId());
+            } else {
+                payment.setThreeDsStatus(ThreeDSStatus.NOT_ENROLLED);
+            }
+            // MOTO and merchant-initiated payments are outside SCA scope
+            ScaAssessment sca = payment.getChannel() == TransactionChannel.MOTO
+                    || payment.getInitiator() == TransactionInitiator.MERCHANT
+                ? ScaAssessment.outOfScope()
+                : scaService.assess(payment.getAmount(), payment.getCurrency(),
+                    payment.getBillingCountry(), payment.getFraudScore(), payment.getThreeDsCavv() != null);
+            payment.setScaExemption(sca.getExemption());
             span.addEvent("3ds_check_complete");
+            PaymentEvent threeDs = step("3DS_AUTH", payment.getThreeDsStatus().name(), correlationId);
+            if (sca.getExemption() != null) {
+                threeDs.setDescription("SCA exemption " + sca.getExemption());
+            }
+            steps.add(threeDs);
             
             // Step 4: PSP Authorization (using PSP routing service)
             span.addEvent("psp_authorization_start");
-            PSPAuthorizationRequest pspRequest = buildPSPAuthorizationRequest(payment, request);
-            PSPAuthorizationResponse pspResponse = pspRoutingService.authorizeWithFailover(pspRequest);
+            PSPAuthorizationRequest pspRequest = buildPSPAuthorizationRequest(payment, sca);
+            // The issuer knows an encrypted card by its account reference,
+            // as the gateway has no PAN to fingerprint
+            pspRequest.setCardFingerprint(encryptedCard != null
+                ? CardFingerprint.of(encryptedCard.getPar() != null ? encryptedCard.getPar() : encryptedCard.getToken())
+                : CardFingerprint.of(request.getCardNumber()));
+            pspRequest.setCardBin(cardPrefix.substring(0, Math.min(8, cardPrefix.length())));
+            // A PIN block under the acquirer's ZPK goes to the issuer under
+            // the issuer's; if it cannot be translated the issuer cannot
+            // be asked
+            boolean pinUntranslated = false;
+            if (pinTranslation.isEnabled() && request.getPinBlock() != null && !request.getPinBlock().isBlank()) {
+                try {
+                    pspRequest.setPinBlock(pinTranslation.translate(request.getCardNumber(), request.getPinBlock()));
+                    steps.add(step("PIN_TRANSLATION", "TRANSLATED", correlationId));
+                } catch (RuntimeException e) {
+                    logger.warn("PIN translation failed: paymentId={}, error={}", paymentId, e.getMessage());
+                    PaymentEvent pinStep = step("PIN_TRANSLATION", "FAILED", correlationId);
+                    pinStep.setErrorCode(SimulatedIssuer.ISSUER_UNAVAILABLE);
+                    steps.add(pinStep);
+                    pinUntranslated = true;
+                }
+            } else {
+                pspRequest.setPinBlock(request.getPinBlock());
+            }
+            if (request.getChipData() != null) {
+                pspRequest.setArqc(request.getChipData().getArqc());
+                pspRequest.setAtc(request.getChipData().getAtc());
+                pspRequest.setPanSequenceNumber(request.getChipData().getPanSequenceNumber());
+                pspRequest.setChipTransactionData(request.getChipData().getTransactionData());
+            }
+            pspRequest.setCvv(request.getCvv());
+            pspRequest.setExpiryMonth(request.getExpiryMonth());
+            pspRequest.setExpiryYear(request.getExpiryYear());
+            pspRequest.setTaxAmount(payment.getTaxAmount());
+            pspRequest.setCustomerCode(payment.getCustomerCode());
+            pspRequest.setSurchargeAmount(payment.getSurchargeAmount());
+            pspRequest.setConvenienceFee(payment.getConvenienceFee());
+            PSPAuthorizationResponse pspResponse;
+            if (cryptogramFailed) {
+                pspResponse = PSPAuthorizationResponse.declined(cryptogram.getDeclineCode(), cryptogram.getMessage());
+            } else if (geoBlocked) {
+                pspResponse = PSPAuthorizationResponse.declined(geo.getDeclineCode(),
+                    GeoRules.ISSUER_COUNTRY_BLOCKED.equals(geo.getDeclineCode())
+                        ? "Cards issued in " + geo.getIssuerCountry() + " are not accepted"
+                        : "Cards issued outside the merchant's country are not accepted");
+            } else if (pinUntranslated) {
+                pspResponse = PSPAuthorizationResponse.declined(SimulatedIssuer.ISSUER_UNAVAILABLE,
+                    "The PIN could not be translated for the issuer");
+            } else {
+                PaymentEvent authRequest = step("AUTHORIZATION_REQUEST", "SENT", correlationId);
+                authRequest.setAmount(payment.getAmount());
+                authRequest.setCurrency(payment.getCurrency());
+                steps.add(authRequest);
+                pspResponse = pspRoutingService.authorizeWithFailover(pspRequest);
+            }
+            payment.setPspName(pspResponse.getPspName());
+            payment.setRoutingPath(pspResponse.getRoutingPath());
+            payment.setAvsResult(pspResponse.getAvsResult());
+            payment.setCvvResult(pspResponse.getCvvResult());
+            if (pspResponse.getAvsResult() != null) {
+                AvsPolicy policy = merchant != null ? merchant.getAvsPolicy() : AvsPolicy.NONE;
+                boolean accepted = !pspResponse.isSuccess() || policy.accepts(pspResponse.getAvsResult());
+                PaymentEvent avs = step("AVS_CHECK", accepted ? "ACCEPTED" : "REJECTED", correlationId);
+                avs.setDescription("AVS result " + pspResponse.getAvsResult() + " under policy " + policy);
+                steps.add(avs);
+                if (!accepted) {
+                    pspResponse = declineForAvs(pspResponse);
+                }
+            }
             
             if (pspResponse.isSuccess()) {
                 payment.setStatus(PaymentStatus.AUTHORIZED);
                 payment.setAuthorizedAt(Instant.now());
                 payment.setPspTransactionId(pspResponse.getPspTransactionId());
+                payment.setNetworkTransactionId(pspResponse.getNetworkTransactionId());
                 span.addEvent("psp_authorization_complete");
             } else {
                 payment.setStatus(PaymentStatus.DECLINED);
+                payment.setDeclineCode(pspResponse.getDeclineCode());
                 span.addEvent("psp_authorization_declined");
                 logger.warn("Payment declined: paymentId={}, reason={}", 
                            paymentId, pspResponse.getDeclineMessage());
@@ -150,12 +412,26 @@ public class PaymentService {
             // Save payment
             payment = paymentRepository.save(payment);
             
-            // Create payment event in database
+            // Create payment events in database; the authorization event
+            // is the response, saying which acquirer answered and how
+            for (PaymentEvent step : steps) {
+                step.setPaymentId(payment.getId());
+            }
+            paymentEventRepository.saveAll(steps);
             PaymentEvent event = new PaymentEvent(payment.getId(), "AUTHORIZATION", "SUCCESS");
             event.setAmount(payment.getAmount());
             event.setCurrency(payment.getCurrency());
             event.setProcessingTimeMs((int) processingTime);
+            event.setCorrelationId(correlationId);
+            event.setDescription(payment.getRoutingPath() != null
+                ? payment.getStatus() + " via " + payment.getRoutingPath()
+                : payment.getStatus().name());
+            event.setErrorCode(payment.getDeclineCode());
             paymentEventRepository.save(event);
+            boolean held = pspResponse.isSuccess() && !screeningMatches.isEmpty();
+            if (held) {
+                screeningService.hold(cardholder, merchantId, payment.getPaymentId(), screeningMatches);
+            }
             
             // Publish event to Kafka
             PaymentEventType eventType = pspResponse.isSuccess() ? 
@@ -175,6 +451,24 @@ public class PaymentService {
             response.setCardBrand(payment.getCardBrand().name());
             response.setCreatedAt(payment.getCreatedAt());
             response.setAuthorizedAt(payment.getAuthorizedAt());
+            response.setScaExemption(payment.getScaExemption() != null ? payment.getScaExemption().name() : null);
+            response.setNetworkTransactionId(payment.getNetworkTransactionId());
+            response.setCvvResult(payment.getCvvResult());
+            response.setAvsResult(payment.getAvsResult());
+            response.setStatementDescriptor(payment.getStatementDescriptor());
+            response.setPurchaseDataLevel(payment.getPurchaseDataLevel());
+            response.setAmountBreakdown(amountBreakdown(payment));
+            response.setSplits(splitResponses(payment));
+            response.setInstallments(installments(payment));
+            response.setScreeningHold(held);
+            response.setIssuerCountry(payment.getIssuerCountry());
+            response.setCrossBorder(payment.isCrossBorder());
+            response.setField55(pspResponse.getField55());
+            if (payment.getStatus() == PaymentStatus.DECLINED) {
+                response.setErrorCode(payment.getDeclineCode());
+                response.setErrorMessage(pspResponse.getDeclineMessage());
+                response.setAuthenticationRequired(ScaSoftDecline.isSoftDecline(payment.getDeclineCode()));
+            }
             
             return response;
             
@@ -205,24 +499,56 @@ public class PaymentService {
         response.setCardBrand(payment.getCardBrand() != null ? payment.getCardBrand().name() : null);
         response.setCreatedAt(payment.getCreatedAt());
         response.setAuthorizedAt(payment.getAuthorizedAt());
+        response.setScaExemption(payment.getScaExemption() != null ? payment.getScaExemption().name() : null);
+        response.setNetworkTransactionId(payment.getNetworkTransactionId());
+        response.setAvsResult(payment.getAvsResult());
+        response.setStatementDescriptor(payment.getStatementDescriptor());
+        response.setPurchaseDataLevel(payment.getPurchaseDataLevel());
+        response.setAmountBreakdown(amountBreakdown(payment));
+        response.setSplits(splitResponses(payment));
+        response.setInstallments(installments(payment));
+        response.setScreeningHold(screeningService.isPaymentHeld(payment.getPaymentId()));
+        response.setIssuerCountry(payment.getIssuerCountry());
+        response.setCrossBorder(payment.isCrossBorder());
+        if (payment.getStatus() == PaymentStatus.DECLINED) {
+            response.setErrorCode(payment.getDeclineCode());
+            response.setAuthenticationRequired(ScaSoftDecline.isSoftDecline(payment.getDeclineCode()));
+        }
         
         return response;
     }
     
     @Transactional
     public PaymentResponse capturePayment(String paymentId) {
+        return capturePayment(paymentId, null);
+    }
+    
+    /**
+     * Captures an authorization, with a tip on top of the authorized amount
+     * if one is given
+     */
+    @Transactional
+    public PaymentResponse capturePayment(String paymentId, BigDecimal tipAmount) {
         Payment payment = paymentRepository.findByPaymentId(paymentId)
             .orElseThrow(() -> new RuntimeException("Payment not found: " + paymentId));
         
         if (payment.getStatus() != PaymentStatus.AUTHORIZED) {
             throw new RuntimeException("Payment must be in AUTHORIZED status to capture");
         }
+        screeningService.checkPaymentCleared(paymentId);
+        
+        BigDecimal authorized = payment.getAmount();
+        boolean tipped = tipAmount != null && tipAmount.signum() > 0;
+        if (tipped) {
+            checkTip(payment, tipAmount);
+        }
+        BigDecimal captureAmount = tipped ? authorized.add(tipAmount) : authorized;
         
         // Call PSP to capture
-        PSPClient pspClient = pspRoutingService.selectPSP(payment.getMerchantId());
+        PSPClient pspClient = acquirerFor(payment);
         PSPCaptureResponse pspResponse = pspClient.capture(
             payment.getPspTransactionId(), 
-            payment.getAmount(), 
+            captureAmount, 
             payment.getCurrency()
         );
         
@@ -230,6 +556,10 @@ public class PaymentService {
             throw new RuntimeException("PSP capture failed: " + pspResponse.getErrorMessage());
         }
         
+        if (tipped) {
+            payment.setTipAmount(tipAmount);
+            payment.setAmount(captureAmount);
+        }
         payment.setStatus(PaymentStatus.CAPTURED);
         payment.setCapturedAt(Instant.now());
         payment = paymentRepository.save(payment);
@@ -238,6 +568,9 @@ public class PaymentService {
         PaymentEvent event = new PaymentEvent(payment.getId(), "CAPTURE", "SUCCESS");
         event.setAmount(payment.getAmount());
         event.setCurrency(payment.getCurrency());
+        if (tipped) {
+            event.setDescription("Tip of " + tipAmount + " on the authorized " + authorized);
+        }
         paymentEventRepository.save(event);
         
         // Publish event to Kafka
@@ -250,10 +583,31 @@ public class PaymentService {
         response.setStatus(payment.getStatus());
         response.setAmount(payment.getAmount());
         response.setCurrency(payment.getCurrency());
+        if (tipped) {
+            response.setAmountBreakdown(amountBreakdown(payment));
+        }
         
         return response;
     }
     
+    /**
+     * Rejects a tip the merchant may not add, or that takes the capture
+     * beyond the card brand's tolerance over the authorized amount
+     */
+    private void checkTip(Payment payment, BigDecimal tipAmount) {
+        Merchant merchant = merchantRepository.findById(payment.getMerchantId()).orElse(null);
+        String mcc = merchant != null ? merchant.getMcc() : null;
+        if (!NetworkRules.isTipEligible(mcc)) {
+            throw new ValidationException("Tips can only be added by restaurant, bar, taxi and salon merchants");
+        }
+        String brand = payment.getCardBrand() != null ? payment.getCardBrand().name() : null;
+        BigDecimal limit = NetworkRules.captureLimit(mcc, brand, payment.getAmount());
+        if (payment.getAmount().add(tipAmount).compareTo(limit) > 0) {
+            throw new ValidationException("Tip of " + tipAmount + " exceeds the " + brand + " tolerance of "
+                + limit.subtract(payment.getAmount()) + " on " + payment.getAmount());
+        }
+    }
+    
     @Transactional
     public PaymentResponse voidPayment(String paymentId) {
         Payment payment = paymentRepository.findByPaymentId(paymentId)
@@ -264,7 +618,7 @@ public class PaymentService {
         }
         
         // Call PSP to void
-        PSPClient pspClient = pspRoutingService.selectPSP(payment.getMerchantId());
+        PSPClient pspClient = acquirerFor(payment);
         PSPVoidResponse pspResponse = pspClient.voidTransaction(payment.getPspTransactionId());
         
         if (!pspResponse.isSuccess()) {
@@ -294,12 +648,187 @@ public class PaymentService {
         return response;
     }
     
+    /**
+     * A resubmission must repeat a payment the issuer soft-declined for this
+     * merchant, now with 3D Secure authentication data
+     */
+    private void validateResubmission(PaymentRequest request, UUID merchantId) {
+        Payment original = paymentRepository.findByPaymentId(request.getOriginalPaymentId())
+            .filter(p -> merchantId.equals(p.getMerchantId()))
+            .orElseThrow(() -> new IllegalArgumentException("Original payment not found: " + request.getOriginalPaymentId()));
+        
+        if (!ScaSoftDecline.isSoftDecline(original.getDeclineCode())) {
+            throw new IllegalArgumentException("Original payment was not declined for authentication: " + original.getPaymentId());
+        }
+        if (request.getThreeDsCavv() == null) {
+            throw new IllegalArgumentException("Resubmission requires 3D Secure authentication data");
+        }
+        if (original.baseAmount().compareTo(request.getAmount()) != 0
+                || !original.getCurrency().equals(request.getCurrency())) {
+            throw new IllegalArgumentException("Resubmission must match the original amount and currency");
+        }
+    }
+    
+    /**
+     * The acquirer holding the authorization; payments from before routing
+     * was recorded go to the merchant's primary PSP
+     */
+    private PSPClient acquirerFor(Payment payment) {
+        return payment.getPspName() != null
+            ? pspRoutingService.getPSPClient(payment.getPspName())
+            : pspRoutingService.selectPSP(payment.getMerchantId());
+    }
+    
+    /**
+     * The merchant's static descriptor with the payment's dynamic suffix,
+     * if any, checked against the card brand's rules
+     */
+    private String statementDescriptor(Merchant merchant, CardBrand cardBrand, String dynamicDescriptor) {
+        String prefix = merchant != null ? DescriptorRules.staticDescriptor(merchant) : DescriptorRules.fromName(null);
+        try {
+            return DescriptorRules.compose(cardBrand, prefix, dynamicDescriptor);
+        } catch (IllegalArgumentException e) {
+            throw new ValidationException(e.getMessage());
+        }
+    }
+    
+    /**
+     * Resolves a payment's splits to sub-merchants of the paying platform
+     */
+    private List<PaymentSplit> splits(List<Split> requested, UUID platformId) {
+        List<PaymentSplit> splits = new ArrayList<>();
+        if (requested == null) {
+            return splits;
+        }
+        for (Split split : requested) {
+            Merchant subMerchant = merchantRepository.findByMerchantId(split.getMerchantId())
+                .filter(m -> platformId.equals(m.getPlatformMerchantId()) && m.getIsActive())
+                .orElseThrow(() -> new ValidationException(
+                    "Merchant " + split.getMerchantId() + " is not an active sub-merchant of this platform"));
+            splits.add(new PaymentSplit(subMerchant.getId(), split.getAmount(),
+                split.getFeeAmount() != null ? split.getFeeAmount() : BigDecimal.ZERO));
+        }
+        return splits;
+    }
+    
+    private List<Split> splitResponses(Payment payment) {
+        if (payment.getSplits().isEmpty()) {
+            return null;
+        }
+        List<Split> responses = new ArrayList<>();
+        for (PaymentSplit split : payment.getSplits()) {
+            String subMerchantId = merchantRepository.findById(split.getSubMerchantId())
+                .map(Merchant::getMerchantId)
+                .orElse(split.getSubMerchantId().toString());
+            Split response = new Split(subMerchantId, split.getAmount(), split.getFeeAmount());
+            response.setNetAmount(split.netAmount());
+            responses.add(response);
+        }
+        return responses;
+    }
+    
+    private static Installments installments(Payment payment) {
+        return payment.getInstallmentCount() != null
+            ? new Installments(payment.getInstallmentCount(), payment.getInstallmentPlanType())
+            : null;
+    }
+    
+    private static AmountBreakdown amountBreakdown(Payment payment) {
+        AmountBreakdown breakdown = new AmountBreakdown(payment.baseAmount(), payment.getSurchargeAmount(),
+            payment.getConvenienceFee(), payment.getAmount());
+        breakdown.setTipAmount(payment.getTipAmount());
+        return breakdown;
+    }
+    
+    /**
+     * The timeline step saying what was added to the amount, or why nothing
+     * was
+     */
+    private PaymentEvent surchargeStep(SurchargeAssessment surcharge, Payment payment, UUID correlationId) {
+        PaymentEvent event = step("SURCHARGE", surcharge.getOutcome().name(), correlationId);
+        event.setCurrency(payment.getCurrency());
+        switch (surcharge.getOutcome()) {
+            case CONVENIENCE_FEE -> event.setAmount(surcharge.getConvenienceFee());
+            case PROHIBITED -> event.setDescription("Surcharging " + payment.getCardBrand()
+                + " cards is not allowed in the merchant's region");
+            default -> {
+                event.setAmount(surcharge.getSurchargeAmount());
+                event.setDescription(surcharge.getSurchargeRate().toPlainString() + "% surcharge on "
+                    + payment.getCardBrand());
+            }
+        }
+        return event;
+    }
+    
+    private static PaymentLineItem lineItem(LineItem item) {
+        PaymentLineItem lineItem = new PaymentLineItem();
+        lineItem.setProductCode(item.getProductCode());
+        lineItem.setDescription(item.getDescription());
+        lineItem.setCommodityCode(item.getCommodityCode());
+        lineItem.setQuantity(item.getQuantity());
+        lineItem.setUnitOfMeasure(item.getUnitOfMeasure());
+        lineItem.setUnitPrice(item.getUnitPrice());
+        lineItem.setDiscountAmount(item.getDiscountAmount());
+        lineItem.setLineTotal(item.total());
+        return lineItem;
+    }
+    
+    /**
+     * Voids an approval whose AVS result the merchant does not accept,
+     * releasing the hold, and turns it into a decline. A void that fails is
+     * logged; the hold then lapses at the issuer.
+     */
+    private PSPAuthorizationResponse declineForAvs(PSPAuthorizationResponse approval) {
+        try {
+            PSPVoidResponse voided = pspRoutingService.getPSPClient(approval.getPspName())
+                .voidTransaction(approval.getPspTransactionId());
+            if (!voided.isSuccess()) {
+                logger.error("Void after AVS decline failed: pspTransactionId={}, error={}",
+                            approval.getPspTransactionId(), voided.getErrorMessage());
+            }
+        } catch (RuntimeException e) {
+            logger.error("Void after AVS decline failed: pspTransactionId={}", approval.getPspTransactionId(), e);
+        }
+        PSPAuthorizationResponse declined = PSPAuthorizationResponse.declined(AVS_DECLINE_CODE, "Billing address verification failed");
+        declined.setPspName(approval.getPspName());
+        declined.setRoutingPath(approval.getRoutingPath());
+        declined.setCvvResult(approval.getCvvResult());
+        declined.setAvsResult(approval.getAvsResult());
+        return declined;
+    }
+    
+    private PaymentEvent step(String eventType, String status, UUID correlationId) {
+        PaymentEvent event = new PaymentEvent(null, eventType, status);
+        event.setCorrelationId(correlationId);
+        return event;
+    }
+    
+    /**
+     * Hands an encrypted card read to the P2PE decryption zone through the
+     * tokenization circuit breaker
+     */
+    private TokenizedCard tokenizeEncryptedCard(PaymentRequest request, UUID merchantId) {
+        if (encryptedCards == null) {
+            throw new ValidationException("Encrypted cards are not accepted: no P2PE decryption zone is configured");
+        }
+        byte[] ksn = HexFormat.of().parseHex(request.getEncryptedCard().getKsn());
+        byte[] track = HexFormat.of().parseHex(request.getEncryptedCard().getEncryptedTrack());
+        try {
+            // An unreadable read is the terminal's fault, not the zone's
+            return circuitBreakers.get(CircuitBreakerRegistry.TOKENIZATION)
+                .execute(() -> encryptedCards.tokenize(merchantId.toString(), ksn, track),
+                    card -> false, e -> !(e instanceof IllegalArgumentException));
+        } catch (IllegalArgumentException e) {
+            throw new ValidationException("Encrypted card could not be read: " + e.getMessage());
+        }
+    }
+    
     // Simulated tokenization - in real implementation, this would call the tokenization service
     private UUID simulateTokenization(String cardNumber) {
         return UUID.randomUUID();
     }
     
-    private PSPAuthorizationRequest buildPSPAuthorizationRequest(Payment payment, PaymentRequest request) {
+    private PSPAuthorizationRequest buildPSPAuthorizationRequest(Payment payment, ScaAssessment sca) {
         PSPAuthorizationRequest pspRequest = new PSPAuthorizationRequest();
         pspRequest.setMerchantId(payment.getMerchantId());
         pspRequest.setAmount(payment.getAmount());
@@ -309,6 +838,7 @@ public class PaymentService {
         pspRequest.setCardBrand(payment.getCardBrand() != null ? payment.getCardBrand().name() : null);
         pspRequest.setDescription(payment.getDescription());
         pspRequest.setReferenceId(payment.getReferenceId());
+        pspRequest.setDescriptor(payment.getStatementDescriptor());
         pspRequest.setBillingStreet(payment.getBillingStreet());
         pspRequest.setBillingCity(payment.getBillingCity());
         pspRequest.setBillingState(payment.getBillingState());
@@ -319,6 +849,21 @@ public class PaymentService {
         if (payment.getThreeDsCavv() != null) {
             pspRequest.setCavv(payment.getThreeDsCavv());
             pspRequest.setEci(payment.getThreeDsEci());
+            pspRequest.setXid(payment.getThreeDsTransactionId());
+        }
+        pspRequest.setChannel(payment.getChannel().name());
+        pspRequest.setInitiator(payment.getInitiator().name());
+        if (payment.getStoredCredential() != null) {
+            pspRequest.setStoredCredential(payment.getStoredCredential().name());
+        }
+        pspRequest.setOriginalNetworkTransactionId(payment.getOriginalNetworkTransactionId());
+        pspRequest.setInstallmentCount(payment.getInstallmentCount());
+        if (payment.getInstallmentPlanType() != null) {
+            pspRequest.setInstallmentPlanType(payment.getInstallmentPlanType().name());
+        }
+        pspRequest.setScaInScope(sca.isInScope());
+        if (sca.getExemption() != null) {
+            pspRequest.setScaExemption(sca.getExemption().name());
         }
         
         return pspRequest;
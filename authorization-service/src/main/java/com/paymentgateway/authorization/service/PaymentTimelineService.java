@@ -0,0 +1,107 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Payment;
+import com.paymentgateway.authorization.domain.PaymentEvent;
+import com.paymentgateway.authorization.domain.WebhookDelivery;
+import com.paymentgateway.authorization.dto.PaymentTimelineResponse;
+import com.paymentgateway.authorization.dto.PaymentTimelineResponse.Entry;
+import com.paymentgateway.authorization.repository.PaymentEventRepository;
+import com.paymentgateway.authorization.repository.PaymentRepository;
+import com.paymentgateway.authorization.repository.SettlementRecordRepository;
+import com.paymentgateway.authorization.repository.SettlementRecordRepository.SettlementRecord;
+import com.paymentgateway.authorization.repository.WebhookDeliveryRepository;
+import org.springframework.stereotype.Service;
+
+import java.time.Duration;
+import java.util.ArrayList;
+import java.util.Comparator;
+import java.util.List;
+import java.util.Objects;
+
+/**
+ * Builds a payment's timeline from its recorded events, settlement records
+ * and webhook deliveries
+ */
+@Service
+public class PaymentTimelineService {
+
+    private final PaymentRepository paymentRepository;
+    private final PaymentEventRepository paymentEventRepository;
+    private final SettlementRecordRepository settlementRecordRepository;
+    private final WebhookDeliveryRepository webhookDeliveryRepository;
+
+    public PaymentTimelineService(PaymentRepository paymentRepository,
+                                  PaymentEventRepository paymentEventRepository,
+                                  SettlementRecordRepository settlementRecordRepository,
+                                  WebhookDeliveryRepository webhookDeliveryRepository) {
+        this.paymentRepository = paymentRepository;
+        this.paymentEventRepository = paymentEventRepository;
+        this.settlementRecordRepository = settlementRecordRepository;
+        this.webhookDeliveryRepository = webhookDeliveryRepository;
+    }
+
+    public PaymentTimelineResponse getTimeline(String paymentId) {
+        Payment payment = paymentRepository.findByPaymentId(paymentId)
+            .orElseThrow(() -> new RuntimeException("Payment not found: " + paymentId));
+
+        List<Entry> entries = new ArrayList<>();
+        for (PaymentEvent event : paymentEventRepository.findByPaymentIdOrderByCreatedAtAsc(payment.getId())) {
+            entries.add(fromEvent(event));
+        }
+
+        List<SettlementRecord> settlements = settlementRecordRepository.findByPaymentId(payment.getId());
+        for (SettlementRecord record : settlements) {
+            Entry entry = new Entry("SETTLEMENT", record.getStatus(), record.getCreatedAt(), record.getBatchId());
+            entry.setDetail("Batch " + record.getBatchId() + " for " + record.getSettlementDate()
+                + ", net " + record.getNetAmount() + " " + record.getCurrency());
+            entries.add(entry);
+        }
+        // Settled before the settlement records could be read
+        if (settlements.isEmpty() && payment.getSettledAt() != null) {
+            entries.add(new Entry("SETTLEMENT", "SETTLED", payment.getSettledAt(), null));
+        }
+
+        for (WebhookDelivery delivery : webhookDeliveryRepository.findByPaymentIdOrderByCreatedAtDesc(payment.getId())) {
+            Entry entry = new Entry("WEBHOOK", delivery.getStatus(), delivery.getCreatedAt(),
+                                    delivery.getId() != null ? delivery.getId().toString() : null);
+            StringBuilder detail = new StringBuilder(delivery.getEventType())
+                .append(", ").append(delivery.getAttemptCount()).append(" attempt(s)");
+            if (delivery.getHttpStatusCode() != null) {
+                detail.append(", HTTP ").append(delivery.getHttpStatusCode());
+            }
+            if (delivery.getErrorMessage() != null) {
+                detail.append(": ").append(delivery.getErrorMessage());
+            }
+            entry.setDetail(detail.toString());
+            if (delivery.getDeliveredAt() != null) {
+                entry.setDurationMs((int) Duration.between(delivery.getCreatedAt(), delivery.getDeliveredAt()).toMillis());
+            }
+            entries.add(entry);
+        }
+
+        // Stable, so steps recorded at the same instant keep their order
+        entries.sort(Comparator.comparing(Entry::getTimestamp));
+        List<String> traceIds = entries.stream()
+            .map(Entry::getTraceId)
+            .filter(Objects::nonNull)
+            .distinct()
+            .toList();
+        return new PaymentTimelineResponse(payment.getPaymentId(), payment.getStatus(), traceIds, entries);
+    }
+
+    private Entry fromEvent(PaymentEvent event) {
+        // The authorization event records the response; its request is a
+        // separate AUTHORIZATION_REQUEST event
+        String step = "AUTHORIZATION".equals(event.getEventType()) ? "AUTHORIZATION_RESPONSE" : event.getEventType();
+        Entry entry = new Entry(step, event.getEventStatus(), event.getCreatedAt(),
+                                event.getCorrelationId() != null ? event.getCorrelationId().toString() : null);
+        entry.setTraceId(event.getTraceId());
+        entry.setDurationMs(event.getProcessingTimeMs());
+        String detail = event.getDescription();
+        if (event.getErrorCode() != null) {
+            detail = (detail != null ? detail + ", " : "") + "code " + event.getErrorCode();
+        }
+        entry.setDetail(detail);
+        return entry;
+    }
+}
@@ -0,0 +1,36 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Merchant;
+
+/**
+ * The limits on refunding a merchant's payments: the days after capture a
+ * payment can be refunded, and how many refunds it can take. Null leaves
+ * either unlimited. Refunds never add up to more than was captured.
+ */
+public final class RefundPolicy {
+    
+    public static final RefundPolicy UNLIMITED = new RefundPolicy(null, null);
+    
+    private final Integer windowDays;
+    private final Integer maxRefunds;
+    
+    public RefundPolicy(Integer windowDays, Integer maxRefunds) {
+        this.windowDays = windowDays;
+        this.maxRefunds = maxRefunds;
+    }
+    
+    /**
+     * A merchant's policy, with the defaults for what it does not set
+     */
+    public static RefundPolicy of(Merchant merchant, RefundPolicy defaults) {
+        if (merchant == null) {
+            return defaults;
+        }
+        return new RefundPolicy(
+            merchant.getRefundWindowDays() != null ? merchant.getRefundWindowDays() : defaults.windowDays,
+            merchant.getMaxRefundsPerPayment() != null ? merchant.getMaxRefundsPerPayment() : defaults.maxRefunds);
+    }
+    
+    public Integer getWindowDays() { return windowDays; }
+    public Integer getMaxRefunds() { return maxRefunds; }
+}
@@ -0,0 +1,23 @@
+package com.paymentgateway.authorization.service;
+
+/**
+ * A refund the merchant's refund policy does not allow, refunds adding up
+ * to more than was captured, or an override of the policy by a caller
+ * without ADMIN role
+ */
+public class RefundPolicyException extends IllegalArgumentException {
+    
+    public static final String WINDOW_EXPIRED = "REFUND_WINDOW_EXPIRED";
+    public static final String EXCEEDS_CAPTURE = "REFUND_EXCEEDS_CAPTURE";
+    public static final String COUNT_EXCEEDED = "REFUND_COUNT_EXCEEDED";
+    public static final String OVERRIDE_FORBIDDEN = "REFUND_OVERRIDE_FORBIDDEN";
+    
+    private final String code;
+    
+    public RefundPolicyException(String code, String message) {
+        super(message);
+        this.code = code;
+    }
+    
+    public String getCode() { return code; }
+}
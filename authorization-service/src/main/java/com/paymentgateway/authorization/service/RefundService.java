@@ -8,19 +8,25 @@ import com.paymentgateway.authorization.event.PaymentEventType;
 import com.paymentgateway.authorization.psp.PSPClient;
 import com.paymentgateway.authorization.psp.PSPRefundResponse;
 import com.paymentgateway.authorization.psp.PSPRoutingService;
+import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.repository.PaymentRepository;
 import com.paymentgateway.authorization.repository.RefundRepository;
 import com.paymentgateway.authorization.repository.PaymentEventRepository;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
 import org.springframework.stereotype.Service;
 import org.springframework.transaction.annotation.Transactional;
 
 import java.math.BigDecimal;
+import java.time.Duration;
 import java.time.Instant;
+import java.util.ArrayList;
 import java.util.Arrays;
 import java.util.List;
 import java.util.UUID;
+import java.util.stream.Collectors;
 
 @Service
 public class RefundService {
@@ -32,23 +38,48 @@ public class RefundService {
     private final PaymentEventRepository paymentEventRepository;
     private final PSPRoutingService pspRoutingService;
     private final PaymentEventPublisher eventPublisher;
+    private final MerchantRepository merchantRepository;
+    private final RefundPolicy defaultPolicy;
     
+    @Autowired
     public RefundService(RefundRepository refundRepository,
                         PaymentRepository paymentRepository,
                         PaymentEventRepository paymentEventRepository,
                         PSPRoutingService pspRoutingService,
-                        PaymentEventPublisher eventPublisher) {
+                        PaymentEventPublisher eventPublisher,
+                        MerchantRepository merchantRepository,
+                        @Value("${refunds.window-days:#{null}}") Integer defaultWindowDays,
+                        @Value("${refunds.max-per-payment:#{null}}") Integer defaultMaxRefunds) {
         this.refundRepository = refundRepository;
         this.paymentRepository = paymentRepository;
         this.paymentEventRepository = paymentEventRepository;
         this.pspRoutingService = pspRoutingService;
         this.eventPublisher = eventPublisher;
+        this.merchantRepository = merchantRepository;
+        this.defaultPolicy = new RefundPolicy(defaultWindowDays, defaultMaxRefunds);
     }
     
     @Transactional
     public RefundResponse processRefund(RefundRequest request, UUID merchantId) {
+        return processRefund(request, merchantId, false);
+    }
+    
+    /**
+     * Refund a captured payment within the merchant's refund policy
+     *
+     * @param admin Whether the caller has ADMIN role, and so may override the policy
+     * @throws RefundPolicyException If the policy does not allow the refund and it is not overridden,
+     *         or the refunds would add up to more than was captured, which no override allows
+     */
+    @Transactional
+    public RefundResponse processRefund(RefundRequest request, UUID merchantId, boolean admin) {
         logger.info("Processing refund for payment: {}, amount: {}", request.getPaymentId(), request.getAmount());
         
+        if (request.isOverridePolicy() && !admin) {
+            throw new RefundPolicyException(RefundPolicyException.OVERRIDE_FORBIDDEN,
+                "Only administrators can override the refund policy");
+        }
+        
         // 1. Validate that the original transaction exists and is refundable
         Payment payment = paymentRepository.findByPaymentId(request.getPaymentId())
             .orElseThrow(() -> new IllegalArgumentException("Payment not found: " + request.getPaymentId()));
@@ -63,19 +94,32 @@ public class RefundService {
             throw new IllegalStateException("Payment is not in a refundable state: " + payment.getStatus());
         }
         
-        // 2. Validate refund amount constraint
-        validateRefundAmount(payment, request.getAmount());
+        // 2. Validate the refund against the merchant's refund policy
+        List<RefundPolicyException> violations = checkPolicy(payment, request.getAmount());
+        String override = null;
+        if (!violations.isEmpty()) {
+            if (!request.isOverridePolicy()) {
+                throw violations.get(0);
+            }
+            override = violations.stream().map(RefundPolicyException::getCode).collect(Collectors.joining(","));
+            logger.warn("Refund policy overridden for payment {}: {}", payment.getPaymentId(), override);
+        }
         
         // 3. Create refund record
         Refund refund = createRefund(payment, request);
+        refund.setPolicyOverride(override);
         refund = refundRepository.save(refund);
         
         // Log refund creation event
-        logRefundEvent(refund, "REFUND_CREATED", "Refund created");
+        logRefundEvent(refund, "REFUND_CREATED", override == null ? "Refund created"
+            : "Refund created, overriding " + override);
         
         try {
             // 4. Submit refund to PSP
-            PSPClient pspClient = pspRoutingService.selectPSP(payment.getMerchantId());
+            // Refund through the acquirer that took the payment
+            PSPClient pspClient = payment.getPspName() != null
+                ? pspRoutingService.getPSPClient(payment.getPspName())
+                : pspRoutingService.selectPSP(payment.getMerchantId());
             PSPRefundResponse pspResponse = pspClient.refund(
                 payment.getPspTransactionId(),
                 request.getAmount(),
@@ -126,7 +170,20 @@ public class RefundService {
                payment.getStatus() == PaymentStatus.SETTLED;
     }
     
-    private void validateRefundAmount(Payment payment, BigDecimal refundAmount) {
+    /**
+     * The policy of the merchant's refunds: its own, with the gateway's
+     * defaults for what it does not set
+     */
+    public RefundPolicy policyFor(Merchant merchant) {
+        return RefundPolicy.of(merchant, defaultPolicy);
+    }
+    
+    /**
+     * The policy rules a refund breaks, in the order they are reported.
+     * Refunds adding up to more than was captured are not a policy rule
+     * and are rejected outright.
+     */
+    private List<RefundPolicyException> checkPolicy(Payment payment, BigDecimal refundAmount) {
         if (refundAmount.compareTo(BigDecimal.ZERO) <= 0) {
             throw new IllegalArgumentException("Refund amount must be greater than zero");
         }
@@ -145,12 +202,35 @@ public class RefundService {
         
         BigDecimal totalAfterRefund = totalRefunded.add(refundAmount);
         
+        // The captured amount, tips included
         if (totalAfterRefund.compareTo(payment.getAmount()) > 0) {
-            throw new IllegalArgumentException(
+            throw new RefundPolicyException(RefundPolicyException.EXCEEDS_CAPTURE,
                 String.format("Refund amount exceeds available balance. Original: %s, Already refunded: %s, Requested: %s",
                     payment.getAmount(), totalRefunded, refundAmount)
             );
         }
+        
+        Merchant merchant = merchantRepository.findById(payment.getMerchantId()).orElse(null);
+        RefundPolicy policy = policyFor(merchant);
+        List<RefundPolicyException> violations = new ArrayList<>();
+        
+        // The window runs from capture, or from creation for a payment with no capture time
+        Instant capturedAt = payment.getCapturedAt() != null ? payment.getCapturedAt() : payment.getCreatedAt();
+        if (policy.getWindowDays() != null && capturedAt != null
+                && Instant.now().isAfter(capturedAt.plus(Duration.ofDays(policy.getWindowDays())))) {
+            violations.add(new RefundPolicyException(RefundPolicyException.WINDOW_EXPIRED, String.format(
+                "Refund window of %d days after capture has passed; captured at %s",
+                policy.getWindowDays(), capturedAt)));
+        }
+        
+        if (policy.getMaxRefunds() != null) {
+            long refunds = refundRepository.countByPaymentIdAndStatusIn(payment.getId(), countableStatuses);
+            if (refunds >= policy.getMaxRefunds()) {
+                violations.add(new RefundPolicyException(RefundPolicyException.COUNT_EXCEEDED, String.format(
+                    "Payment already has %d of at most %d refunds", refunds, policy.getMaxRefunds())));
+            }
+        }
+        return violations;
     }
     
     private Refund createRefund(Payment payment, RefundRequest request) {
@@ -207,6 +287,7 @@ public class RefundService {
         response.setProcessedAt(refund.getProcessedAt());
         response.setErrorCode(refund.getErrorCode());
         response.setErrorMessage(refund.getErrorMessage());
+        response.setPolicyOverride(refund.getPolicyOverride());
         return response;
     }
     
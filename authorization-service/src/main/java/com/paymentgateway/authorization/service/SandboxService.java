@@ -0,0 +1,187 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.Sandbox;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.repository.SandboxRepository;
+import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.security.SecureRandom;
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+
+/**
+ * Sandboxes let many teams share one deployed simulator. Each sandbox gets
+ * merchants of its own, with their own API keys; since every payment,
+ * refund, webhook and settlement belongs to a merchant, a team only ever
+ * sees its own data. Destroying a sandbox deletes all of it.
+ */
+@Service
+public class SandboxService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(SandboxService.class);
+    private static final SecureRandom random = new SecureRandom();
+    private static final String ID_ALPHABET = "abcdefghijklmnopqrstuvwxyz0123456789";
+    
+    /**
+     * A merchant created in a sandbox, with the only copy of its API key
+     */
+    public static class SandboxMerchant {
+        
+        private final Merchant merchant;
+        private final String apiKey;
+        
+        public SandboxMerchant(Merchant merchant, String apiKey) {
+            this.merchant = merchant;
+            this.apiKey = apiKey;
+        }
+        
+        public Merchant getMerchant() { return merchant; }
+        public String getApiKey() { return apiKey; }
+    }
+    
+    /**
+     * A new sandbox and the merchants it starts with
+     */
+    public static class CreatedSandbox {
+        
+        private final Sandbox sandbox;
+        private final List<SandboxMerchant> merchants;
+        
+        public CreatedSandbox(Sandbox sandbox, List<SandboxMerchant> merchants) {
+            this.sandbox = sandbox;
+            this.merchants = merchants;
+        }
+        
+        public Sandbox getSandbox() { return sandbox; }
+        public List<SandboxMerchant> getMerchants() { return merchants; }
+    }
+    
+    private final SandboxRepository sandboxRepository;
+    private final MerchantRepository merchantRepository;
+    private final MerchantAuthenticationService authenticationService;
+    private final int maxMerchants;
+    
+    public SandboxService(SandboxRepository sandboxRepository,
+                          MerchantRepository merchantRepository,
+                          MerchantAuthenticationService authenticationService,
+                          @Value("${sandbox.max-merchants:20}") int maxMerchants) {
+        this.sandboxRepository = sandboxRepository;
+        this.merchantRepository = merchantRepository;
+        this.authenticationService = authenticationService;
+        this.maxMerchants = maxMerchants;
+    }
+    
+    /**
+     * Create a sandbox with the given number of merchants
+     */
+    @Transactional
+    public CreatedSandbox create(String name, int merchantCount, String createdBy) {
+        if (merchantCount < 1 || merchantCount > maxMerchants) {
+            throw new IllegalArgumentException("A sandbox starts with 1 to " + maxMerchants + " merchants");
+        }
+        
+        Sandbox sandbox = sandboxRepository.save(new Sandbox(newSandboxId(), name.trim(), createdBy));
+        List<SandboxMerchant> merchants = new ArrayList<>();
+        for (int i = 0; i < merchantCount; i++) {
+            merchants.add(createMerchant(sandbox, null, i + 1));
+        }
+        logger.info("Sandbox {} ({}) created by {} with {} merchants",
+                sandbox.getSandboxId(), sandbox.getName(), createdBy, merchantCount);
+        return new CreatedSandbox(sandbox, merchants);
+    }
+    
+    /**
+     * Add a merchant to a sandbox
+     */
+    @Transactional
+    public SandboxMerchant addMerchant(Sandbox sandbox, String merchantName) {
+        long existing = sandboxRepository.countMerchants(sandbox.getId());
+        if (existing >= maxMerchants) {
+            throw new IllegalArgumentException("Sandbox " + sandbox.getSandboxId() + " already has "
+                    + maxMerchants + " merchants");
+        }
+        return createMerchant(sandbox, merchantName, (int) existing + 1);
+    }
+    
+    public Optional<Sandbox> find(String sandboxId) {
+        return sandboxRepository.findBySandboxId(sandboxId);
+    }
+    
+    public List<Sandbox> list() {
+        return sandboxRepository.findAllByOrderByCreatedAtAsc();
+    }
+    
+    public long merchantCount(Sandbox sandbox) {
+        return sandboxRepository.countMerchants(sandbox.getId());
+    }
+    
+    public long paymentCount(Sandbox sandbox) {
+        return sandboxRepository.countPayments(sandbox.getId());
+    }
+    
+    /**
+     * Delete a sandbox and everything recorded for its merchants
+     * 
+     * @return Rows deleted, by table
+     */
+    @Transactional
+    public Map<String, Integer> destroy(Sandbox sandbox) {
+        Map<String, Integer> deleted = new LinkedHashMap<>();
+        deleted.put("settlement_transactions", sandboxRepository.deleteSettlementTransactions(sandbox.getId()));
+        deleted.put("fraud_alerts", sandboxRepository.deleteFraudAlerts(sandbox.getId()));
+        deleted.put("disputes", sandboxRepository.deleteDisputes(sandbox.getId()));
+        deleted.put("payment_events", sandboxRepository.deletePaymentEvents(sandbox.getId()));
+        deleted.put("refunds", sandboxRepository.deleteRefunds(sandbox.getId()));
+        deleted.put("webhook_deliveries", sandboxRepository.deleteWebhookDeliveries(sandbox.getId()));
+        deleted.put("webhook_endpoints", sandboxRepository.deleteWebhookEndpoints(sandbox.getId()));
+        deleted.put("outbox_events", sandboxRepository.deleteOutboxEvents(sandbox.getId()));
+        deleted.put("settlement_batches", sandboxRepository.deleteSettlementBatches(sandbox.getId()));
+        deleted.put("payments", sandboxRepository.deletePayments(sandbox.getId()));
+        deleted.put("api_keys", sandboxRepository.deleteApiKeys(sandbox.getId()));
+        sandboxRepository.deleteMerchantRoles(sandbox.getId());
+        deleted.put("merchants", sandboxRepository.deleteMerchants(sandbox.getId()));
+        sandboxRepository.delete(sandbox);
+        logger.info("Sandbox {} ({}) destroyed: {}", sandbox.getSandboxId(), sandbox.getName(), deleted);
+        return deleted;
+    }
+    
+    private SandboxMerchant createMerchant(Sandbox sandbox, String merchantName, int sequence) {
+        // Sandbox merchant IDs carry the sandbox ID, so they never collide
+        // with another team's or the shared merchants
+        String merchantId = String.format("%s_M%03d", sandbox.getSandboxId(), sequence);
+        while (merchantRepository.existsByMerchantId(merchantId)) {
+            merchantId = String.format("%s_M%03d", sandbox.getSandboxId(), ++sequence);
+        }
+        
+        Merchant merchant = new Merchant(merchantId,
+                merchantName != null && !merchantName.isBlank() ? merchantName.trim()
+                        : sandbox.getName() + " merchant " + sequence);
+        merchant.setSandboxId(sandbox.getId());
+        merchant.setMcc("5999");
+        merchant.setCountryCode("US");
+        merchant.setCurrency("USD");
+        merchant.setRiskLevel("LOW");
+        merchant.addRole("MERCHANT");
+        
+        // Saves the merchant with the key's hash
+        String apiKey = authenticationService.createApiKey(merchant);
+        return new SandboxMerchant(merchant, apiKey);
+    }
+    
+    private static String newSandboxId() {
+        StringBuilder id = new StringBuilder("sbx_");
+        for (int i = 0; i < 12; i++) {
+            id.append(ID_ALPHABET.charAt(random.nextInt(ID_ALPHABET.length())));
+        }
+        return id.toString();
+    }
+}
@@ -8,9 +8,14 @@ import com.paymentgateway.authorization.dto.MerchantDashboardResponse;
 import com.paymentgateway.authorization.dto.PaymentResponse;
 import com.paymentgateway.authorization.dto.TransactionQueryRequest;
 import com.paymentgateway.authorization.dto.TransactionQueryResponse;
+import com.paymentgateway.authorization.pagination.CursorPage;
+import com.paymentgateway.authorization.pagination.InvalidPageTokenException;
+import com.paymentgateway.authorization.pagination.PageLimits;
+import com.paymentgateway.authorization.pagination.PageToken;
 import com.paymentgateway.authorization.repository.PaymentRepository;
 import jakarta.persistence.EntityManager;
 import jakarta.persistence.TypedQuery;
+import jakarta.validation.ValidationException;
 import jakarta.persistence.criteria.*;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
@@ -34,6 +39,9 @@ public class TransactionQueryService {
     private static final Logger logger = LoggerFactory.getLogger(TransactionQueryService.class);
     private static final String CACHE_PREFIX = "transaction:query:";
     private static final Duration CACHE_TTL = Duration.ofMinutes(5);
+    private static final PageLimits PAGE_LIMITS = new PageLimits(20, 100);
+    // Fields a search may sort by; ties are broken by ID
+    private static final Set<String> SORT_FIELDS = Set.of("createdAt", "amount");
     
     private final PaymentRepository paymentRepository;
     private final EntityManager entityManager;
@@ -128,13 +136,27 @@ public class TransactionQueryService {
             predicates.add(cb.equal(payment.get("referenceId"), request.getReferenceId()));
         }
         
-        cq.where(predicates.toArray(new Predicate[0]));
+        String sortBy = request.getSortBy();
+        if (!SORT_FIELDS.contains(sortBy)) {
+            throw new ValidationException("sortBy must be one of " + String.join(", ", new TreeSet<>(SORT_FIELDS)));
+        }
+        boolean descending = "DESC".equalsIgnoreCase(request.getSortDirection());
+        String fingerprint = PageToken.fingerprint(merchantId, request.getStatus(), request.getCurrency(),
+            request.getMinAmount(), request.getMaxAmount(), request.getStartDate(), request.getEndDate(),
+            request.getCardLastFour(), request.getReferenceId(), sortBy, descending);
+        List<String> after = PageToken.decode(request.getPageToken(), fingerprint);
+        
+        List<Predicate> pagePredicates = new ArrayList<>(predicates);
+        if (after != null) {
+            pagePredicates.add(after(cb, payment, sortBy, descending, after));
+        }
+        cq.where(pagePredicates.toArray(new Predicate[0]));
         
-        // Apply sorting
-        if ("DESC".equalsIgnoreCase(request.getSortDirection())) {
-            cq.orderBy(cb.desc(payment.get(request.getSortBy())));
+        // Apply sorting, with the ID as a tie-breaker so that pages are stable
+        if (descending) {
+            cq.orderBy(cb.desc(payment.get(sortBy)), cb.desc(payment.get("id")));
         } else {
-            cq.orderBy(cb.asc(payment.get(request.getSortBy())));
+            cq.orderBy(cb.asc(payment.get(sortBy)), cb.asc(payment.get("id")));
         }
         
         // Execute query with pagination
@@ -147,22 +169,61 @@ public class TransactionQueryService {
         countQuery.where(predicates.toArray(new Predicate[0]));
         long totalElements = entityManager.createQuery(countQuery).getSingleResult();
         
-        // Apply pagination
-        int page = request.getPage();
-        int size = Math.min(request.getSize(), 100); // Max 100 per page
+        // Apply pagination: a page token continues after its transaction,
+        // otherwise the page number is an offset. One extra row tells
+        // whether another page follows.
+        int page = after != null ? 0 : request.getPage();
+        int size = PAGE_LIMITS.size(request.getSize());
         query.setFirstResult(page * size);
-        query.setMaxResults(size);
+        query.setMaxResults(size + 1);
         
-        List<Payment> payments = query.getResultList();
-        
-        // Convert to response DTOs with PAN masking
-        List<PaymentResponse> responses = payments.stream()
-            .map(this::convertToResponse)
-            .collect(Collectors.toList());
+        CursorPage<PaymentResponse> payments = PageToken.page(query.getResultList(), size, fingerprint,
+                p -> sortKey(p, sortBy))
+            .map(this::convertToResponse);
         
         int totalPages = (int) Math.ceil((double) totalElements / size);
         
-        return new TransactionQueryResponse(responses, page, size, totalElements, totalPages);
+        TransactionQueryResponse response =
+            new TransactionQueryResponse(payments.getItems(), page, size, totalElements, totalPages);
+        response.setNextPageToken(payments.getNextPageToken());
+        if (after != null) {
+            response.setHasNext(payments.isHasNext());
+            response.setHasPrevious(true);
+        }
+        return response;
+    }
+    
+    private static List<String> sortKey(Payment payment, String sortBy) {
+        String value = "amount".equals(sortBy) ? payment.getAmount().toPlainString() : payment.getCreatedAt().toString();
+        return List.of(value, payment.getId().toString());
+    }
+    
+    /**
+     * Restricts a search to the transactions after a page token's key
+     */
+    private static Predicate after(CriteriaBuilder cb, Root<Payment> payment, String sortBy,
+                                   boolean descending, List<String> key) {
+        try {
+            UUID id = UUID.fromString(key.get(1));
+            return "amount".equals(sortBy)
+                ? keyset(cb, payment, sortBy, new BigDecimal(key.get(0)), id, descending)
+                : keyset(cb, payment, sortBy, Instant.parse(key.get(0)), id, descending);
+        } catch (RuntimeException e) {
+            throw new InvalidPageTokenException("Invalid page token");
+        }
+    }
+    
+    private static <Y extends Comparable<? super Y>> Predicate keyset(CriteriaBuilder cb, Root<Payment> payment,
+                                                                      String field, Y value, UUID id,
+                                                                      boolean descending) {
+        Path<Y> sortPath = payment.get(field);
+        Path<UUID> idPath = payment.get("id");
+        if (descending) {
+            return cb.or(cb.lessThan(sortPath, value),
+                         cb.and(cb.equal(sortPath, value), cb.lessThan(idPath, id)));
+        }
+        return cb.or(cb.greaterThan(sortPath, value),
+                     cb.and(cb.equal(sortPath, value), cb.greaterThan(idPath, id)));
     }
     
     /**
@@ -347,6 +408,7 @@ public class TransactionQueryService {
             request.getPage() + ":" +
             request.getSize() + ":" +
             request.getSortBy() + ":" +
-            request.getSortDirection();
+            request.getSortDirection() +
+            (request.getPageToken() != null ? ":" + request.getPageToken() : "");
     }
 }
@@ -0,0 +1,87 @@
+package com.paymentgateway.authorization.sftp;
+
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.StandardCopyOption;
+import java.util.List;
+import java.util.regex.Pattern;
+import java.util.stream.Stream;
+
+/**
+ * The drop zone's directories under its root: each merchant has a home
+ * named by its merchant ID, with
+ *
+ * <pre>
+ * inbound/    batch files dropped by the merchant
+ * outbound/   acknowledgements, response files and reports to pick up
+ * archive/    dropped files once read, prefixed with their batch file ID
+ * </pre>
+ */
+public class DropZone {
+    
+    public static final String INBOUND = "inbound";
+    public static final String OUTBOUND = "outbound";
+    public static final String ARCHIVE = "archive";
+    
+    // Merchant IDs usable as directory names; others get no drop zone
+    private static final Pattern MERCHANT_ID = Pattern.compile("^[A-Za-z0-9_-]{1,64}$");
+    
+    private final Path root;
+    
+    public DropZone(Path root) {
+        this.root = root;
+    }
+    
+    public static boolean isUsableMerchantId(String merchantId) {
+        return merchantId != null && MERCHANT_ID.matcher(merchantId).matches();
+    }
+    
+    /**
+     * A merchant's home, created with its directories if it does not exist
+     */
+    public Path open(String merchantId) throws IOException {
+        if (!isUsableMerchantId(merchantId)) {
+            throw new IllegalArgumentException("Merchant ID cannot name a drop zone: " + merchantId);
+        }
+        Path home = root.resolve(merchantId);
+        for (String directory : List.of(INBOUND, OUTBOUND, ARCHIVE)) {
+            Files.createDirectories(home.resolve(directory));
+        }
+        return home;
+    }
+    
+    public Path home(String merchantId) {
+        return root.resolve(merchantId);
+    }
+    
+    public Path getRoot() { return root; }
+    
+    /**
+     * Merchant IDs of the homes opened so far
+     */
+    public List<String> merchantIds() throws IOException {
+        if (!Files.isDirectory(root)) {
+            return List.of();
+        }
+        try (Stream<Path> homes = Files.list(root)) {
+            return homes.filter(Files::isDirectory)
+                .map(home -> home.getFileName().toString())
+                .filter(DropZone::isUsableMerchantId)
+                .sorted()
+                .toList();
+        }
+    }
+    
+    /**
+     * Writes a file for pickup. It appears whole: it is written under a
+     * hidden temporary name and then moved into place.
+     */
+    public static void publish(Path target, String content) throws IOException {
+        Files.createDirectories(target.getParent());
+        Path temp = target.resolveSibling("." + target.getFileName() + ".tmp");
+        Files.writeString(temp, content, StandardCharsets.UTF_8);
+        Files.move(temp, target, StandardCopyOption.REPLACE_EXISTING, StandardCopyOption.ATOMIC_MOVE);
+    }
+}
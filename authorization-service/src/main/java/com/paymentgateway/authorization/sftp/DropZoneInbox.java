@@ -0,0 +1,107 @@
+package com.paymentgateway.authorization.sftp;
+
+import com.paymentgateway.authorization.batch.BatchFileService;
+import com.paymentgateway.authorization.batch.InvalidBatchFileException;
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileSource;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.StandardCopyOption;
+import java.util.Locale;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Hands files dropped in merchants' inbound directories to the batch
+ * processor. {@code .csv} files are read as CSV and {@code .txt} and
+ * {@code .dat} files as fixed width; hidden files and {@code .part},
+ * {@code .tmp} and {@code .filepart} files are uploads in progress and are
+ * left alone until renamed.
+ *
+ * An accepted file gets {@code <name>.ack} in outbound with its batch file
+ * ID, a rejected one {@code <name>.rejected} with the reason, and either
+ * way the file moves to the archive.
+ */
+public class DropZoneInbox {
+    
+    private static final Logger logger = LoggerFactory.getLogger(DropZoneInbox.class);
+    
+    private final DropZone dropZone;
+    private final BatchFileService batchFileService;
+    private final long maxFileBytes;
+    
+    public DropZoneInbox(DropZone dropZone, BatchFileService batchFileService, long maxFileBytes) {
+        this.dropZone = dropZone;
+        this.batchFileService = batchFileService;
+        this.maxFileBytes = maxFileBytes;
+    }
+    
+    /**
+     * The format a dropped file is read in, or none if it is not a batch
+     * file or not finished uploading
+     */
+    public static Optional<BatchFileFormat> formatOf(String name) {
+        String lower = name.toLowerCase(Locale.ROOT);
+        if (lower.startsWith(".")) {
+            return Optional.empty();
+        }
+        if (lower.endsWith(".csv")) {
+            return Optional.of(BatchFileFormat.CSV);
+        }
+        if (lower.endsWith(".txt") || lower.endsWith(".dat")) {
+            return Optional.of(BatchFileFormat.FIXED_WIDTH);
+        }
+        return Optional.empty();
+    }
+    
+    /**
+     * Submits a file the merchant has finished writing, if it is a batch
+     * file in its inbound directory
+     *
+     * @return The batch file, if it was accepted
+     */
+    public Optional<BatchFile> accept(String merchantId, UUID merchantUuid, Path file) {
+        Path inbound = dropZone.home(merchantId).resolve(DropZone.INBOUND);
+        if (!inbound.equals(file.getParent()) || !Files.isRegularFile(file)) {
+            return Optional.empty();
+        }
+        String name = file.getFileName().toString();
+        BatchFileFormat format = formatOf(name).orElse(null);
+        if (format == null) {
+            return Optional.empty();
+        }
+        
+        Path outbound = dropZone.home(merchantId).resolve(DropZone.OUTBOUND);
+        Path archive = dropZone.home(merchantId).resolve(DropZone.ARCHIVE);
+        try {
+            BatchFile batchFile;
+            try {
+                if (Files.size(file) > maxFileBytes) {
+                    throw new InvalidBatchFileException("The file is larger than " + maxFileBytes + " bytes");
+                }
+                String content = Files.readString(file, StandardCharsets.UTF_8);
+                batchFile = batchFileService.submit(merchantUuid, format, content, BatchFileSource.SFTP, name);
+            } catch (InvalidBatchFileException e) {
+                DropZone.publish(outbound.resolve(name + ".rejected"), e.getMessage() + "\n");
+                Files.move(file, archive.resolve("rejected_" + name), StandardCopyOption.REPLACE_EXISTING);
+                logger.info("Dropped file {} of merchant {} rejected: {}", name, merchantId, e.getMessage());
+                return Optional.empty();
+            }
+            DropZone.publish(outbound.resolve(name + ".ack"),
+                "fileId=" + batchFile.getFileId() + "\nrecords=" + batchFile.getRecordCount() + "\n");
+            Files.move(file, archive.resolve(batchFile.getFileId() + "_" + name), StandardCopyOption.REPLACE_EXISTING);
+            logger.info("Dropped file {} of merchant {} accepted as batch file {}", name, merchantId,
+                batchFile.getFileId());
+            return Optional.of(batchFile);
+        } catch (IOException e) {
+            logger.error("Dropped file {} of merchant {} could not be read: {}", name, merchantId, e.getMessage());
+            return Optional.empty();
+        }
+    }
+}
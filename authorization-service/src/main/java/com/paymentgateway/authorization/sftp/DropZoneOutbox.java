@@ -0,0 +1,110 @@
+package com.paymentgateway.authorization.sftp;
+
+import com.paymentgateway.authorization.batch.BatchFileService;
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileSource;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.export.ExportDataset;
+import com.paymentgateway.authorization.export.ExportFormat;
+import com.paymentgateway.authorization.export.ExportService;
+import com.paymentgateway.authorization.repository.BatchFileRepository;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Path;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.time.ZoneOffset;
+
+/**
+ * Puts files in merchants' outbound directories for pickup: the response
+ * file of every completed batch file dropped on the drop zone, and each
+ * night the previous UTC day's transaction and settlement reports.
+ */
+public class DropZoneOutbox {
+    
+    private static final Logger logger = LoggerFactory.getLogger(DropZoneOutbox.class);
+    
+    public static final String REPORTS = "reports";
+    
+    private final DropZone dropZone;
+    private final BatchFileRepository batchFileRepository;
+    private final BatchFileService batchFileService;
+    private final MerchantRepository merchantRepository;
+    private final ExportService exportService;
+    
+    public DropZoneOutbox(DropZone dropZone, BatchFileRepository batchFileRepository, BatchFileService batchFileService,
+                          MerchantRepository merchantRepository, ExportService exportService) {
+        this.dropZone = dropZone;
+        this.batchFileRepository = batchFileRepository;
+        this.batchFileService = batchFileService;
+        this.merchantRepository = merchantRepository;
+        this.exportService = exportService;
+    }
+    
+    /**
+     * Deliver the response files of completed dropped batch files, as
+     * {@code <file ID>_response.<csv|txt>}
+     *
+     * @return The number delivered
+     */
+    public int deliverResponses() {
+        int delivered = 0;
+        for (BatchFile file : batchFileRepository.findBySourceAndStatusAndResponseDeliveredAtIsNull(
+                BatchFileSource.SFTP, BatchFileStatus.COMPLETED)) {
+            Merchant merchant = merchantRepository.findById(file.getMerchantId()).orElse(null);
+            if (merchant == null || !DropZone.isUsableMerchantId(merchant.getMerchantId())) {
+                continue;
+            }
+            Path target = dropZone.home(merchant.getMerchantId()).resolve(DropZone.OUTBOUND)
+                .resolve(file.getFileId() + "_response." + file.getFormat().getExtension());
+            try {
+                DropZone.publish(target, batchFileService.responseFile(file));
+                file.setResponseDeliveredAt(Instant.now());
+                batchFileRepository.save(file);
+                delivered++;
+            } catch (IOException | RuntimeException e) {
+                logger.error("Response file of batch file {} could not be delivered: {}", file.getFileId(), e.getMessage());
+            }
+        }
+        return delivered;
+    }
+    
+    /**
+     * Write one UTC day's reports to every drop zone as
+     * {@code reports/<dataset>_<yyyy-mm-dd>.csv}, replacing any written for
+     * it. A merchant whose reports fail is logged and the others still get
+     * theirs.
+     */
+    public void writeReports(LocalDate day) {
+        Instant from = day.atStartOfDay().toInstant(ZoneOffset.UTC);
+        Instant to = day.plusDays(1).atStartOfDay().toInstant(ZoneOffset.UTC);
+        try {
+            for (String merchantId : dropZone.merchantIds()) {
+                Merchant merchant = merchantRepository.findByMerchantId(merchantId).orElse(null);
+                if (merchant == null) {
+                    continue;
+                }
+                Path reports = dropZone.home(merchantId).resolve(DropZone.OUTBOUND).resolve(REPORTS);
+                for (ExportDataset dataset : ExportDataset.values()) {
+                    try {
+                        ByteArrayOutputStream out = new ByteArrayOutputStream();
+                        exportService.export(dataset, ExportFormat.CSV, merchant.getId(), from, to, out);
+                        DropZone.publish(reports.resolve(dataset.getDatasetName() + "_" + day + ".csv"),
+                            out.toString(StandardCharsets.UTF_8));
+                    } catch (IOException | RuntimeException e) {
+                        logger.error("{} report of {} for merchant {} failed: {}", dataset.getDatasetName(), day,
+                            merchantId, e.getMessage());
+                    }
+                }
+            }
+        } catch (IOException e) {
+            logger.error("Drop zones could not be listed for the {} reports: {}", day, e.getMessage());
+        }
+    }
+}
@@ -0,0 +1,182 @@
+package com.paymentgateway.authorization.sftp;
+
+import com.paymentgateway.authorization.batch.BatchFileService;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.export.ExportService;
+import com.paymentgateway.authorization.repository.BatchFileRepository;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import jakarta.annotation.PreDestroy;
+import org.apache.sshd.common.AttributeRepository;
+import org.apache.sshd.common.file.virtualfs.VirtualFileSystemFactory;
+import org.apache.sshd.server.SshServer;
+import org.apache.sshd.server.keyprovider.SimpleGeneratorHostKeyProvider;
+import org.apache.sshd.server.session.ServerSession;
+import org.apache.sshd.sftp.server.FileHandle;
+import org.apache.sshd.sftp.server.Handle;
+import org.apache.sshd.sftp.server.SftpEventListener;
+import org.apache.sshd.sftp.server.SftpSubsystemFactory;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.ApplicationArguments;
+import org.springframework.boot.ApplicationRunner;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.io.IOException;
+import java.nio.file.CopyOption;
+import java.nio.file.DirectoryStream;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.StandardOpenOption;
+import java.time.LocalDate;
+import java.time.ZoneOffset;
+import java.util.Collection;
+import java.util.List;
+import java.util.UUID;
+
+/**
+ * Embedded SFTP server for merchants exchanging files the way many
+ * acquirers still do. Merchants log in with their merchant ID as the user
+ * name and their API key as the password and see only their own home (see
+ * {@link DropZone}). A batch file is submitted as soon as it is closed
+ * after writing, or renamed into inbound; files already in inbound when
+ * the server starts are submitted then.
+ */
+@Component
+@ConditionalOnProperty(name = "sftp.enabled", havingValue = "true")
+public class DropZoneServer implements ApplicationRunner {
+    
+    private static final Logger logger = LoggerFactory.getLogger(DropZoneServer.class);
+    
+    private static final AttributeRepository.AttributeKey<UUID> MERCHANT = new AttributeRepository.AttributeKey<>();
+    
+    private final MerchantAuthenticationService authenticationService;
+    private final MerchantRepository merchantRepository;
+    private final DropZone dropZone;
+    private final DropZoneInbox inbox;
+    private final DropZoneOutbox outbox;
+    private final VirtualFileSystemFactory fileSystems;
+    private final SshServer server;
+    
+    public DropZoneServer(MerchantAuthenticationService authenticationService,
+                          MerchantRepository merchantRepository,
+                          BatchFileService batchFileService,
+                          BatchFileRepository batchFileRepository,
+                          ExportService exportService,
+                          @Value("${sftp.root}") String root,
+                          @Value("${sftp.host}") String host,
+                          @Value("${sftp.port}") int port,
+                          @Value("${sftp.host-key}") String hostKey,
+                          @Value("${sftp.max-file-bytes:10485760}") long maxFileBytes) {
+        this.authenticationService = authenticationService;
+        this.merchantRepository = merchantRepository;
+        this.dropZone = new DropZone(Path.of(root));
+        this.inbox = new DropZoneInbox(dropZone, batchFileService, maxFileBytes);
+        this.outbox = new DropZoneOutbox(dropZone, batchFileRepository, batchFileService, merchantRepository, exportService);
+        
+        this.fileSystems = new VirtualFileSystemFactory();
+        SftpSubsystemFactory sftp = new SftpSubsystemFactory.Builder().build();
+        sftp.addSftpEventListener(new Listener());
+        
+        this.server = SshServer.setUpDefaultServer();
+        server.setHost(host);
+        server.setPort(port);
+        server.setKeyPairProvider(new SimpleGeneratorHostKeyProvider(Path.of(hostKey)));
+        server.setPasswordAuthenticator(this::authenticate);
+        server.setFileSystemFactory(fileSystems);
+        server.setSubsystemFactories(List.of(sftp));
+    }
+    
+    @Override
+    public void run(ApplicationArguments args) throws IOException {
+        server.start();
+        logger.info("SFTP drop zone listening on {}:{} with homes under {}", server.getHost(), server.getPort(),
+            dropZone.getRoot());
+        for (String merchantId : dropZone.merchantIds()) {
+            merchantRepository.findByMerchantId(merchantId).ifPresent(this::acceptWaiting);
+        }
+    }
+    
+    @PreDestroy
+    public void close() throws IOException {
+        server.stop();
+    }
+    
+    @Scheduled(fixedDelayString = "${sftp.delivery-interval-ms:5000}")
+    public void deliverResponses() {
+        outbox.deliverResponses();
+    }
+    
+    @Scheduled(cron = "${sftp.reports.cron:0 0 2 * * *}", zone = "UTC")
+    public void writeReports() {
+        outbox.writeReports(LocalDate.now(ZoneOffset.UTC).minusDays(1));
+    }
+    
+    /**
+     * Lets in a merchant whose API key the password is, opening its home
+     */
+    private boolean authenticate(String username, String password, ServerSession session) {
+        Merchant merchant = authenticationService.authenticateByApiKey(password)
+            .filter(m -> m.getMerchantId().equals(username) && DropZone.isUsableMerchantId(username))
+            .orElse(null);
+        if (merchant == null) {
+            logger.warn("SFTP login refused for {} from {}", username, session.getClientAddress());
+            return false;
+        }
+        try {
+            fileSystems.setUserHomeDir(username, dropZone.open(username));
+        } catch (IOException e) {
+            logger.error("Drop zone of merchant {} could not be opened: {}", username, e.getMessage());
+            return false;
+        }
+        session.setAttribute(MERCHANT, merchant.getId());
+        return true;
+    }
+    
+    private void acceptWaiting(Merchant merchant) {
+        Path inbound = dropZone.home(merchant.getMerchantId()).resolve(DropZone.INBOUND);
+        try (DirectoryStream<Path> files = Files.newDirectoryStream(inbound)) {
+            for (Path file : files) {
+                inbox.accept(merchant.getMerchantId(), merchant.getId(), file);
+            }
+        } catch (IOException e) {
+            logger.error("Inbound files of merchant {} could not be listed: {}", merchant.getMerchantId(), e.getMessage());
+        }
+    }
+    
+    private void accept(ServerSession session, Path path) {
+        UUID merchantUuid = session.getAttribute(MERCHANT);
+        if (merchantUuid == null) {
+            return;
+        }
+        // Paths are rooted at the merchant's home; resolve them against it
+        String relative = path.toAbsolutePath().normalize().toString().replaceFirst("^/+", "");
+        Path home = dropZone.home(session.getUsername());
+        Path file = home.resolve(relative).normalize();
+        if (file.startsWith(home)) {
+            inbox.accept(session.getUsername(), merchantUuid, file);
+        }
+    }
+    
+    private class Listener implements SftpEventListener {
+        
+        @Override
+        public void closed(ServerSession session, String remoteHandle, Handle localHandle, Throwable thrown) {
+            if (thrown == null && localHandle instanceof FileHandle handle
+                    && handle.getOpenOptions().contains(StandardOpenOption.WRITE)) {
+                accept(session, handle.getFile());
+            }
+        }
+        
+        @Override
+        public void moved(ServerSession session, Path srcPath, Path dstPath, Collection<CopyOption> opts,
+                          Throwable thrown) {
+            if (thrown == null) {
+                accept(session, dstPath);
+            }
+        }
+    }
+}
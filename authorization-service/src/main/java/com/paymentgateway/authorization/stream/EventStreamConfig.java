@@ -0,0 +1,48 @@
+package com.paymentgateway.authorization.stream;
+
+import com.fasterxml.jackson.databind.ObjectMapper;
+import io.micrometer.core.instrument.MeterRegistry;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.context.annotation.Bean;
+import org.springframework.context.annotation.Configuration;
+import org.springframework.web.socket.config.annotation.EnableWebSocket;
+import org.springframework.web.socket.config.annotation.WebSocketConfigurer;
+import org.springframework.web.socket.config.annotation.WebSocketHandlerRegistry;
+
+import java.util.Arrays;
+
+/**
+ * The live event stream at {@value #PATH}. The upgrade request goes through
+ * the normal security chain, so it needs an API key or JWT like any other
+ * API call.
+ */
+@Configuration
+@EnableWebSocket
+@ConditionalOnProperty(name = "event-stream.enabled", havingValue = "true", matchIfMissing = true)
+public class EventStreamConfig implements WebSocketConfigurer {
+    
+    public static final String PATH = "/api/v1/events/stream";
+    
+    private final EventStreamHandler handler;
+    private final String allowedOrigins;
+    
+    public EventStreamConfig(EventStreamHandler handler,
+                             @Value("${event-stream.allowed-origins:${cors.allowed-origins:}}") String allowedOrigins) {
+        this.handler = handler;
+        this.allowedOrigins = allowedOrigins;
+    }
+    
+    @Override
+    public void registerWebSocketHandlers(WebSocketHandlerRegistry registry) {
+        var registration = registry.addHandler(handler, PATH)
+            .addInterceptors(new MerchantHandshakeInterceptor());
+        // Without allowed origins only same-origin browser pages may connect;
+        // clients that send no Origin header are unaffected
+        if (!allowedOrigins.isBlank()) {
+            registration.setAllowedOrigins(Arrays.stream(allowedOrigins.split(","))
+                .map(String::trim)
+                .toArray(String[]::new));
+        }
+    }
+}
@@ -0,0 +1,168 @@
+package com.paymentgateway.authorization.stream;
+
+import com.fasterxml.jackson.core.JsonProcessingException;
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.event.PaymentEventMessage;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import io.micrometer.core.instrument.Counter;
+import io.micrometer.core.instrument.Gauge;
+import io.micrometer.core.instrument.MeterRegistry;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+import org.springframework.web.socket.CloseStatus;
+import org.springframework.web.socket.TextMessage;
+import org.springframework.web.socket.WebSocketSession;
+import org.springframework.web.socket.handler.ConcurrentWebSocketSessionDecorator;
+import org.springframework.web.socket.handler.TextWebSocketHandler;
+import org.springframework.web.util.UriComponentsBuilder;
+
+import java.util.Arrays;
+import java.util.EnumSet;
+import java.util.Map;
+import java.util.Set;
+import java.util.UUID;
+import java.util.concurrent.ConcurrentHashMap;
+
+/**
+ * Broadcasts payment events to WebSocket subscribers. Each subscriber sees
+ * only its own merchant's events; an admin may pass {@code merchant_id} to
+ * watch another merchant, or omit it to see all of them. {@code types}
+ * limits the stream to a comma-separated list of event types. Subscribers
+ * that fall behind are disconnected rather than slowing the others down.
+ */
+@Component
+@ConditionalOnProperty(name = "event-stream.enabled", havingValue = "true", matchIfMissing = true)
+public class EventStreamHandler extends TextWebSocketHandler {
+    
+    private static final Logger logger = LoggerFactory.getLogger(EventStreamHandler.class);
+    
+    private final ObjectMapper objectMapper;
+    private final int sendTimeLimitMillis;
+    private final int bufferSizeLimit;
+    private final Map<String, Subscription> subscriptions = new ConcurrentHashMap<>();
+    private final Counter sent;
+    private final Counter dropped;
+    
+    public EventStreamHandler(ObjectMapper objectMapper,
+                              MeterRegistry meterRegistry,
+                              @Value("${event-stream.send-time-limit-ms:5000}") int sendTimeLimitMillis,
+                              @Value("${event-stream.buffer-size-limit:524288}") int bufferSizeLimit) {
+        this.objectMapper = objectMapper;
+        this.sendTimeLimitMillis = sendTimeLimitMillis;
+        this.bufferSizeLimit = bufferSizeLimit;
+        this.sent = Counter.builder("event.stream.messages.sent.total")
+            .description("Events sent to event stream subscribers")
+            .register(meterRegistry);
+        this.dropped = Counter.builder("event.stream.subscribers.dropped.total")
+            .description("Event stream subscribers disconnected for falling behind")
+            .register(meterRegistry);
+        Gauge.builder("event.stream.subscribers", subscriptions, Map::size)
+            .description("Connected event stream subscribers")
+            .register(meterRegistry);
+    }
+    
+    @Override
+    public void afterConnectionEstablished(WebSocketSession session) throws Exception {
+        Merchant merchant = (Merchant) session.getAttributes().get(MerchantHandshakeInterceptor.MERCHANT_ATTRIBUTE);
+        if (merchant == null) {
+            session.close(CloseStatus.POLICY_VIOLATION.withReason("Not authenticated"));
+            return;
+        }
+        
+        Map<String, String> params = UriComponentsBuilder.fromUri(session.getUri()).build()
+            .getQueryParams().toSingleValueMap();
+        
+        // Merchants are held to their own events; admins choose
+        UUID merchantId = merchant.getId();
+        if (merchant.getRoles().contains("ADMIN")) {
+            String requested = params.get("merchant_id");
+            try {
+                merchantId = requested == null || requested.isBlank() ? null : UUID.fromString(requested);
+            } catch (IllegalArgumentException e) {
+                session.close(CloseStatus.BAD_DATA.withReason("Invalid merchant_id"));
+                return;
+            }
+        }
+        
+        Set<PaymentEventType> types = EnumSet.allOf(PaymentEventType.class);
+        String requestedTypes = params.get("types");
+        if (requestedTypes != null && !requestedTypes.isBlank()) {
+            try {
+                types = EnumSet.noneOf(PaymentEventType.class);
+                for (String type : requestedTypes.split(",")) {
+                    types.add(PaymentEventType.valueOf(type.trim().toUpperCase()));
+                }
+            } catch (IllegalArgumentException e) {
+                session.close(CloseStatus.BAD_DATA.withReason("Unknown event type in types, expected "
+                        + Arrays.toString(PaymentEventType.values())));
+                return;
+            }
+        }
+        
+        WebSocketSession concurrent = new ConcurrentWebSocketSessionDecorator(session, sendTimeLimitMillis,
+                bufferSizeLimit, ConcurrentWebSocketSessionDecorator.OverflowStrategy.TERMINATE);
+        subscriptions.put(session.getId(), new Subscription(concurrent, merchantId, types));
+        logger.info("Event stream subscriber connected: session={}, merchant={}, watching={}, types={}",
+                session.getId(), merchant.getMerchantId(), merchantId == null ? "all" : merchantId, types);
+    }
+    
+    @Override
+    public void afterConnectionClosed(WebSocketSession session, CloseStatus status) {
+        if (subscriptions.remove(session.getId()) != null) {
+            logger.info("Event stream subscriber disconnected: session={}, status={}", session.getId(), status);
+        }
+    }
+    
+    /**
+     * Send an event, redacted, to every subscriber whose filters it matches
+     */
+    public void broadcast(PaymentEventMessage message) throws JsonProcessingException {
+        if (subscriptions.isEmpty() || message.getPayload() == null) {
+            return;
+        }
+        String merchantId = message.getPayload().getMerchantId();
+        TextMessage text = new TextMessage(objectMapper.writeValueAsString(StreamEvent.from(message)));
+        for (Subscription subscription : subscriptions.values()) {
+            if (!subscription.matches(merchantId, message.getEventType())) {
+                continue;
+            }
+            try {
+                subscription.session.sendMessage(text);
+                sent.increment();
+            } catch (Exception e) {
+                // The decorator closes sessions that exceed their limits
+                logger.warn("Dropping event stream subscriber {}: {}", subscription.session.getId(), e.getMessage());
+                subscriptions.remove(subscription.session.getId());
+                dropped.increment();
+            }
+        }
+    }
+    
+    public int subscriberCount() {
+        return subscriptions.size();
+    }
+    
+    private static final class Subscription {
+        
+        private final WebSocketSession session;
+        private final UUID merchantId;
+        private final Set<PaymentEventType> types;
+        
+        private Subscription(WebSocketSession session, UUID merchantId, Set<PaymentEventType> types) {
+            this.session = session;
+            this.merchantId = merchantId;
+            this.types = types;
+        }
+        
+        private boolean matches(String eventMerchantId, PaymentEventType type) {
+            if (!types.contains(type)) {
+                return false;
+            }
+            return merchantId == null || merchantId.toString().equals(eventMerchantId);
+        }
+    }
+}
@@ -0,0 +1,38 @@
+package com.paymentgateway.authorization.stream;
+
+import com.fasterxml.jackson.core.JsonProcessingException;
+import com.paymentgateway.authorization.config.KafkaConfig;
+import com.paymentgateway.authorization.event.PaymentEventMessage;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.kafka.annotation.KafkaListener;
+import org.springframework.kafka.support.Acknowledgment;
+import org.springframework.messaging.handler.annotation.Payload;
+import org.springframework.stereotype.Component;
+
+/**
+ * Feeds the event stream from Kafka. Every instance has subscribers of its
+ * own, so each reads the whole topic in a consumer group of its own,
+ * starting from the newest events.
+ */
+@Component
+@ConditionalOnProperty(name = "event-stream.enabled", havingValue = "true", matchIfMissing = true)
+public class EventStreamListener {
+    
+    private final EventStreamHandler handler;
+    
+    public EventStreamListener(EventStreamHandler handler) {
+        this.handler = handler;
+    }
+    
+    @KafkaListener(
+            topics = KafkaConfig.PAYMENT_EVENTS_TOPIC,
+            groupId = "${event-stream.group-id-prefix:event-stream}-${random.uuid}",
+            containerFactory = "kafkaListenerContainerFactory",
+            properties = "auto.offset.reset=latest"
+    )
+    public void onPaymentEvent(@Payload PaymentEventMessage event, Acknowledgment acknowledgment)
+            throws JsonProcessingException {
+        handler.broadcast(event);
+        acknowledgment.acknowledge();
+    }
+}
@@ -0,0 +1,38 @@
+package com.paymentgateway.authorization.stream;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.server.ServerHttpRequest;
+import org.springframework.http.server.ServerHttpResponse;
+import org.springframework.http.server.ServletServerHttpRequest;
+import org.springframework.web.socket.WebSocketHandler;
+import org.springframework.web.socket.server.HandshakeInterceptor;
+
+import java.util.Map;
+
+/**
+ * Carries the merchant that the authentication filter resolved for the
+ * upgrade request into the WebSocket session, and refuses the upgrade
+ * without one
+ */
+public class MerchantHandshakeInterceptor implements HandshakeInterceptor {
+    
+    static final String MERCHANT_ATTRIBUTE = "merchant";
+    
+    @Override
+    public boolean beforeHandshake(ServerHttpRequest request, ServerHttpResponse response,
+                                   WebSocketHandler wsHandler, Map<String, Object> attributes) {
+        if (request instanceof ServletServerHttpRequest servletRequest
+                && servletRequest.getServletRequest().getAttribute("merchant") instanceof Merchant merchant) {
+            attributes.put(MERCHANT_ATTRIBUTE, merchant);
+            return true;
+        }
+        response.setStatusCode(HttpStatus.UNAUTHORIZED);
+        return false;
+    }
+    
+    @Override
+    public void afterHandshake(ServerHttpRequest request, ServerHttpResponse response,
+                               WebSocketHandler wsHandler, Exception exception) {
+    }
+}
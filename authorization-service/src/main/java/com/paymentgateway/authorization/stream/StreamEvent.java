@@ -0,0 +1,62 @@
+package com.paymentgateway.authorization.stream;
+
+import com.fasterxml.jackson.annotation.JsonProperty;
+import com.paymentgateway.authorization.event.PaymentEventMessage;
+import com.paymentgateway.authorization.event.PaymentEventType;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+
+/**
+ * A payment event as sent to event stream subscribers. It carries what a
+ * dashboard needs and leaves out PSP references, fraud scores, 3-D Secure
+ * results and trace identifiers.
+ */
+public class StreamEvent {
+    
+    @JsonProperty("event_id")
+    private final String eventId;
+    
+    @JsonProperty("event_type")
+    private final PaymentEventType eventType;
+    
+    @JsonProperty("timestamp")
+    private final Instant timestamp;
+    
+    @JsonProperty("payment_id")
+    private final String paymentId;
+    
+    @JsonProperty("amount")
+    private final BigDecimal amount;
+    
+    @JsonProperty("currency")
+    private final String currency;
+    
+    @JsonProperty("status")
+    private final String status;
+    
+    public StreamEvent(String eventId, PaymentEventType eventType, Instant timestamp,
+                       String paymentId, BigDecimal amount, String currency, String status) {
+        this.eventId = eventId;
+        this.eventType = eventType;
+        this.timestamp = timestamp;
+        this.paymentId = paymentId;
+        this.amount = amount;
+        this.currency = currency;
+        this.status = status;
+    }
+    
+    public static StreamEvent from(PaymentEventMessage message) {
+        PaymentEventMessage.PaymentEventPayload payload = message.getPayload();
+        return new StreamEvent(message.getEventId(), message.getEventType(), message.getTimestamp(),
+                payload.getPaymentId(), payload.getAmount(), payload.getCurrency(), payload.getStatus());
+    }
+    
+    public String getEventId() { return eventId; }
+    public PaymentEventType getEventType() { return eventType; }
+    public Instant getTimestamp() { return timestamp; }
+    public String getPaymentId() { return paymentId; }
+    public BigDecimal getAmount() { return amount; }
+    public String getCurrency() { return currency; }
+    public String getStatus() { return status; }
+}
@@ -0,0 +1,82 @@
+package com.paymentgateway.authorization.surcharge;
+
+import java.math.BigDecimal;
+
+/**
+ * The fees a payment carries on top of the amount of the goods: a
+ * percentage surcharge for paying by card, or a flat convenience fee for
+ * paying through an alternative channel, never both.
+ */
+public class SurchargeAssessment {
+    
+    public enum Outcome {
+        // The merchant surcharges nothing
+        NONE,
+        // The merchant's surcharge rate applies
+        APPLIED,
+        // The merchant's rate is above the brand's cap, so the cap applies
+        CAPPED,
+        // The brand does not allow surcharging in the merchant's region
+        PROHIBITED,
+        // A convenience fee applies instead of a surcharge
+        CONVENIENCE_FEE
+    }
+    
+    private final Outcome outcome;
+    private final BigDecimal baseAmount;
+    private final BigDecimal surchargeRate;
+    private final BigDecimal surchargeAmount;
+    private final BigDecimal convenienceFee;
+    
+    private SurchargeAssessment(Outcome outcome, BigDecimal baseAmount, BigDecimal surchargeRate,
+                                BigDecimal surchargeAmount, BigDecimal convenienceFee) {
+        this.outcome = outcome;
+        this.baseAmount = baseAmount;
+        this.surchargeRate = surchargeRate;
+        this.surchargeAmount = surchargeAmount;
+        this.convenienceFee = convenienceFee;
+    }
+    
+    public static SurchargeAssessment none(BigDecimal baseAmount) {
+        return new SurchargeAssessment(Outcome.NONE, baseAmount, null, null, null);
+    }
+    
+    public static SurchargeAssessment prohibited(BigDecimal baseAmount) {
+        return new SurchargeAssessment(Outcome.PROHIBITED, baseAmount, null, null, null);
+    }
+    
+    public static SurchargeAssessment surcharge(boolean capped, BigDecimal baseAmount, BigDecimal rate, BigDecimal amount) {
+        return new SurchargeAssessment(capped ? Outcome.CAPPED : Outcome.APPLIED, baseAmount, rate, amount, null);
+    }
+    
+    public static SurchargeAssessment convenienceFee(BigDecimal baseAmount, BigDecimal fee) {
+        return new SurchargeAssessment(Outcome.CONVENIENCE_FEE, baseAmount, null, null, fee);
+    }
+    
+    public Outcome getOutcome() { return outcome; }
+    
+    public BigDecimal getBaseAmount() { return baseAmount; }
+    
+    /**
+     * The surcharge percentage applied, or null without a surcharge
+     */
+    public BigDecimal getSurchargeRate() { return surchargeRate; }
+    
+    public BigDecimal getSurchargeAmount() { return surchargeAmount; }
+    
+    public BigDecimal getConvenienceFee() { return convenienceFee; }
+    
+    /**
+     * The amount to authorize: the base amount with any surcharge or fee
+     */
+    public BigDecimal getTotalAmount() {
+        BigDecimal total = baseAmount;
+        if (surchargeAmount != null) {
+            total = total.add(surchargeAmount);
+        }
+        if (convenienceFee != null) {
+            total = total.add(convenienceFee);
+        }
+        return total;
+    }
+}
@@ -0,0 +1,138 @@
+package com.paymentgateway.authorization.surcharge;
+
+import com.paymentgateway.authorization.domain.CardBrand;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.TransactionChannel;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Service;
+
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.List;
+import java.util.Locale;
+import java.util.Set;
+
+/**
+ * Works out the surcharge or convenience fee of a payment from the
+ * merchant's settings and the card brands' surcharging rules.
+ * 
+ * Merchants set a surcharge percentage, a flat convenience fee for payments
+ * taken by mail or telephone (MOTO), or both. A MOTO payment pays the
+ * convenience fee only, as the brands forbid adding both. The surcharge is
+ * limited by the rules for the card's brand in the merchant's country: a
+ * percentage cap, or a prohibition.
+ */
+@Service
+public class SurchargeService {
+    
+    private static final String ANY = "*";
+    private static final String PROHIBITED = "PROHIBITED";
+    // Region of the EEA member states plus the UK, which ban surcharging
+    // consumer cards
+    private static final String EEA = "EEA";
+    private static final Set<String> EEA_COUNTRIES = Set.of(
+        "AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+        "IE", "IS", "IT", "LI", "LT", "LU", "LV", "MT", "NL", "NO", "PL", "PT", "RO", "SE",
+        "SI", "SK", "GB"
+    );
+    
+    private final List<Rule> rules = new ArrayList<>();
+    
+    /**
+     * @param spec comma-separated {@code BRAND:REGION:CAP} rules, where brand
+     *             may be {@code *}, region is a country code, {@code EEA} or
+     *             {@code *}, and cap is a percentage or {@code PROHIBITED},
+     *             e.g. {@code *:US:3.0,AMEX:CA:2.4,*:EEA:PROHIBITED}. The
+     *             most specific rule wins; without one, surcharging is
+     *             prohibited.
+     */
+    public SurchargeService(@Value("${payment.surcharge.rules:}") String spec) {
+        for (String item : spec.split(",")) {
+            if (item.isBlank()) {
+                continue;
+            }
+            String[] parts = item.trim().toUpperCase(Locale.ROOT).split(":");
+            if (parts.length != 3) {
+                throw new IllegalArgumentException("Invalid surcharge rule: " + item);
+            }
+            if (!ANY.equals(parts[0]) && Arrays.stream(CardBrand.values()).noneMatch(b -> b.name().equals(parts[0]))) {
+                throw new IllegalArgumentException("Unknown card brand in surcharge rule: " + item);
+            }
+            BigDecimal cap = PROHIBITED.equals(parts[2]) ? null : new BigDecimal(parts[2]);
+            if (cap != null && cap.signum() <= 0) {
+                throw new IllegalArgumentException("Surcharge cap must be positive: " + item);
+            }
+            rules.add(new Rule(parts[0], parts[1], cap));
+        }
+    }
+    
+    /**
+     * Assess a payment of the given amount before fees
+     */
+    public SurchargeAssessment assess(Merchant merchant, CardBrand brand, TransactionChannel channel, BigDecimal amount) {
+        if (merchant == null) {
+            return SurchargeAssessment.none(amount);
+        }
+        if (channel == TransactionChannel.MOTO && merchant.getConvenienceFee() != null
+                && merchant.getConvenienceFee().signum() > 0) {
+            return SurchargeAssessment.convenienceFee(amount, merchant.getConvenienceFee());
+        }
+        BigDecimal rate = merchant.getSurchargePercent();
+        if (rate == null || rate.signum() <= 0) {
+            return SurchargeAssessment.none(amount);
+        }
+        
+        BigDecimal cap = cap(brand, merchant.getCountryCode());
+        if (cap == null) {
+            return SurchargeAssessment.prohibited(amount);
+        }
+        boolean capped = rate.compareTo(cap) > 0;
+        if (capped) {
+            rate = cap;
+        }
+        BigDecimal surcharge = amount.multiply(rate).divide(BigDecimal.valueOf(100), 2, RoundingMode.HALF_UP);
+        return SurchargeAssessment.surcharge(capped, amount, rate, surcharge);
+    }
+    
+    /**
+     * The highest surcharge percentage allowed on the brand in a country, or
+     * null if surcharging is prohibited
+     */
+    public BigDecimal cap(CardBrand brand, String country) {
+        Rule best = null;
+        for (Rule rule : rules) {
+            if (rule.matches(brand, country) && (best == null || rule.specificity() > best.specificity())) {
+                best = rule;
+            }
+        }
+        return best != null ? best.cap : null;
+    }
+    
+    private static final class Rule {
+        final String brand;
+        final String region;
+        final BigDecimal cap;
+        
+        Rule(String brand, String region, BigDecimal cap) {
+            this.brand = brand;
+            this.region = region;
+            this.cap = cap;
+        }
+        
+        boolean matches(CardBrand cardBrand, String country) {
+            return (ANY.equals(brand) || (cardBrand != null && brand.equals(cardBrand.name())))
+                && (ANY.equals(region) || region.equals(country)
+                    || (EEA.equals(region) && country != null && EEA_COUNTRIES.contains(country)));
+        }
+        
+        /**
+         * A brand beats a region; a country beats the EEA beats any region
+         */
+        int specificity() {
+            int regionScore = ANY.equals(region) ? 0 : EEA.equals(region) ? 1 : 2;
+            return (ANY.equals(brand) ? 0 : 3) + regionScore;
+        }
+    }
+}
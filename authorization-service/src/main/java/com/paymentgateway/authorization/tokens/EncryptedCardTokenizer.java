@@ -0,0 +1,17 @@
+package com.paymentgateway.authorization.tokens;
+
+/**
+ * The P2PE decryption zone: decrypts a terminal's encrypted card read and
+ * tokenizes the card, so the caller never sees the PAN
+ */
+public interface EncryptedCardTokenizer {
+    
+    /**
+     * Tokenizes the card in an encrypted read for a merchant. A read that
+     * does not decrypt to a card throws IllegalArgumentException; failing
+     * to reach the decryption zone throws otherwise.
+     *
+     * @param ksn The 10-byte key serial number of the read
+     */
+    TokenizedCard tokenize(String merchantId, byte[] ksn, byte[] encryptedTrack);
+}
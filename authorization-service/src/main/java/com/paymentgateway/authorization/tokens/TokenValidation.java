@@ -0,0 +1,49 @@
+package com.paymentgateway.authorization.tokens;
+
+import java.time.Instant;
+
+/**
+ * The vault's answer to whether a token can be used by a merchant, and
+ * which tokenization instance gave it
+ */
+public class TokenValidation {
+    
+    public static final String PRIMARY = "primary";
+    public static final String REPLICA = "replica";
+    
+    private final boolean valid;
+    private final String errorMessage;
+    private final Instant expiresAt;
+    private final String par;
+    private final String servedBy;
+    
+    public TokenValidation(boolean valid, String errorMessage, Instant expiresAt, String par, String servedBy) {
+        this.valid = valid;
+        this.errorMessage = errorMessage;
+        this.expiresAt = expiresAt;
+        this.par = par;
+        this.servedBy = servedBy;
+    }
+    
+    public boolean isValid() { return valid; }
+    
+    /**
+     * Why the token cannot be used, or null if it can
+     */
+    public String getErrorMessage() { return errorMessage; }
+    
+    /**
+     * When the token expires, or null if it does not
+     */
+    public Instant getExpiresAt() { return expiresAt; }
+    
+    /**
+     * The payment account reference of the token's card, or null
+     */
+    public String getPar() { return par; }
+    
+    /**
+     * PRIMARY or REPLICA
+     */
+    public String getServedBy() { return servedBy; }
+}
@@ -0,0 +1,146 @@
+package com.paymentgateway.authorization.tokens;
+
+import com.paymentgateway.api.tokenization.v2.TokenizationServiceGrpc;
+import com.paymentgateway.api.tokenization.v2.ValidateRequest;
+import com.paymentgateway.api.tokenization.v2.ValidateResponse;
+import io.grpc.Channel;
+import io.grpc.ClientInterceptors;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Metadata;
+import io.grpc.Status;
+import io.grpc.StatusRuntimeException;
+import io.grpc.stub.MetadataUtils;
+import jakarta.annotation.PreDestroy;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+import java.time.Instant;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Validates vault tokens with the tokenization service's v2 ValidateToken.
+ * Validation only reads the vault, so with
+ * payment.token-validation.route-to-replica it goes to a read replica
+ * instead of the primary, keeping high-QPS checks from contending with
+ * tokenization writes. A replica still syncing from the primary refuses
+ * reads with UNAVAILABLE, and those are retried on the primary.
+ */
+@Component
+@ConditionalOnProperty(name = "payment.token-validation.tokenization-address")
+public class TokenValidationClient {
+    
+    private static final Logger logger = LoggerFactory.getLogger(TokenValidationClient.class);
+    
+    private static final long DEADLINE_MS = 2000;
+    
+    private final ManagedChannel primaryChannel;
+    private final ManagedChannel replicaChannel;
+    private final Channel primary;
+    private final Channel replica;
+    
+    @Autowired
+    public TokenValidationClient(@Value("${payment.token-validation.tokenization-address}") String address,
+                                 @Value("${payment.token-validation.replica-address:}") String replicaAddress,
+                                 @Value("${payment.token-validation.route-to-replica:false}") boolean routeToReplica,
+                                 @Value("${payment.token-validation.api-key:}") String apiKey) {
+        if (routeToReplica && replicaAddress.isBlank()) {
+            throw new IllegalStateException(
+                "Routing token validation to a replica needs payment.token-validation.replica-address");
+        }
+        this.primaryChannel = ManagedChannelBuilder.forTarget(address).usePlaintext().build();
+        this.primary = authenticated(primaryChannel, apiKey);
+        if (routeToReplica) {
+            this.replicaChannel = ManagedChannelBuilder.forTarget(replicaAddress).usePlaintext().build();
+            this.replica = authenticated(replicaChannel, apiKey);
+            logger.info("Tokens are validated by the tokenization replica at {}, falling back to the primary at {}",
+                replicaAddress, address);
+        } else {
+            this.replicaChannel = null;
+            this.replica = null;
+            logger.info("Tokens are validated by the tokenization service at {}", address);
+        }
+    }
+    
+    /**
+     * Uses the given channels; a null replica sends every check to the
+     * primary
+     */
+    TokenValidationClient(Channel primary, Channel replica) {
+        this.primaryChannel = null;
+        this.replicaChannel = null;
+        this.primary = primary;
+        this.replica = replica;
+    }
+    
+    private static Channel authenticated(ManagedChannel channel, String apiKey) {
+        if (apiKey.isBlank()) {
+            return channel;
+        }
+        Metadata headers = new Metadata();
+        headers.put(Metadata.Key.of("authorization", Metadata.ASCII_STRING_MARSHALLER), "Bearer " + apiKey);
+        return ClientInterceptors.intercept(channel, MetadataUtils.newAttachHeadersInterceptor(headers));
+    }
+    
+    public boolean isRoutedToReplica() {
+        return replica != null;
+    }
+    
+    /**
+     * Checks that a live token exists, is active and may be used by the
+     * merchant
+     */
+    public TokenValidation validate(String merchantId, String token) {
+        return validate(TokenizationEnvironments.LIVE, merchantId, token);
+    }
+    
+    /**
+     * Checks that a token of the environment exists, is active and may be
+     * used by the merchant. Tokens of other environments are not found.
+     */
+    public TokenValidation validate(String environment, String merchantId, String token) {
+        ValidateRequest request = ValidateRequest.newBuilder()
+            .setToken(token)
+            .setMerchantId(merchantId)
+            .build();
+        if (replica != null) {
+            try {
+                return decode(call(TokenizationEnvironments.in(replica, environment), request), TokenValidation.REPLICA);
+            } catch (StatusRuntimeException e) {
+                if (e.getStatus().getCode() != Status.Code.UNAVAILABLE) {
+                    throw e;
+                }
+                logger.debug("Tokenization replica unavailable, validating on the primary: {}", e.getStatus());
+            }
+        }
+        return decode(call(TokenizationEnvironments.in(primary, environment), request), TokenValidation.PRIMARY);
+    }
+    
+    private static ValidateResponse call(Channel channel, ValidateRequest request) {
+        return TokenizationServiceGrpc.newBlockingStub(channel)
+            .withDeadlineAfter(DEADLINE_MS, TimeUnit.MILLISECONDS)
+            .validateToken(request);
+    }
+    
+    static TokenValidation decode(ValidateResponse response, String servedBy) {
+        String errorMessage = response.getErrorMessage();
+        long expiresAt = response.getExpiresAt();
+        String par = response.getPar();
+        return new TokenValidation(response.getValid(), errorMessage.isEmpty() ? null : errorMessage,
+            expiresAt == 0 ? null : Instant.ofEpochSecond(expiresAt), par.isEmpty() ? null : par, servedBy);
+    }
+    
+    @PreDestroy
+    public void close() {
+        if (primaryChannel != null) {
+            primaryChannel.shutdown();
+        }
+        if (replicaChannel != null) {
+            replicaChannel.shutdown();
+        }
+    }
+}
@@ -0,0 +1,81 @@
+package com.paymentgateway.authorization.tokens;
+
+import com.google.protobuf.ByteString;
+import com.paymentgateway.api.tokenization.v2.TokenizationServiceGrpc;
+import com.paymentgateway.api.tokenization.v2.TokenizeEncryptedCardRequest;
+import com.paymentgateway.api.tokenization.v2.TokenizeResponse;
+import io.grpc.Channel;
+import io.grpc.ClientInterceptors;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Metadata;
+import io.grpc.Status;
+import io.grpc.StatusRuntimeException;
+import io.grpc.stub.MetadataUtils;
+import jakarta.annotation.PreDestroy;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.boot.autoconfigure.condition.ConditionalOnProperty;
+import org.springframework.stereotype.Component;
+
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Tokenizes P2PE card reads with the tokenization service's v2
+ * TokenizeEncryptedCard. The service decrypts the read with the HSM and answers with the token, BIN
+ * and last four digits only.
+ */
+@Component
+@ConditionalOnProperty(name = "payment.p2pe.tokenization-address")
+public class TokenizationEncryptedCardTokenizer implements EncryptedCardTokenizer {
+    
+    private static final Logger logger = LoggerFactory.getLogger(TokenizationEncryptedCardTokenizer.class);
+    
+    private static final long DEADLINE_MS = 2000;
+    
+    private final ManagedChannel managedChannel;
+    private final Channel channel;
+    
+    public TokenizationEncryptedCardTokenizer(@Value("${payment.p2pe.tokenization-address}") String address,
+                                              @Value("${payment.p2pe.api-key:}") String apiKey) {
+        this.managedChannel = ManagedChannelBuilder.forTarget(address).usePlaintext().build();
+        if (apiKey.isBlank()) {
+            this.channel = managedChannel;
+        } else {
+            Metadata headers = new Metadata();
+            headers.put(Metadata.Key.of("authorization", Metadata.ASCII_STRING_MARSHALLER), "Bearer " + apiKey);
+            this.channel = ClientInterceptors.intercept(managedChannel, MetadataUtils.newAttachHeadersInterceptor(headers));
+        }
+        logger.info("P2PE card reads are tokenized by the tokenization service at {}", address);
+    }
+    
+    @Override
+    public TokenizedCard tokenize(String merchantId, byte[] ksn, byte[] encryptedTrack) {
+        TokenizeEncryptedCardRequest request = TokenizeEncryptedCardRequest.newBuilder()
+            .setKsn(ByteString.copyFrom(ksn))
+            .setEncryptedTrack(ByteString.copyFrom(encryptedTrack))
+            .setMerchantId(merchantId)
+            .build();
+        TokenizeResponse response;
+        try {
+            response = TokenizationServiceGrpc.newBlockingStub(channel)
+                .withDeadlineAfter(DEADLINE_MS, TimeUnit.MILLISECONDS)
+                .tokenizeEncryptedCard(request);
+        } catch (StatusRuntimeException e) {
+            // A read that does not decrypt to a card
+            if (e.getStatus().getCode() == Status.Code.INVALID_ARGUMENT) {
+                throw new IllegalArgumentException(e.getStatus().getDescription(), e);
+            }
+            throw e;
+        }
+        String par = response.getPar();
+        return new TokenizedCard(response.getToken(), response.getBin(), response.getLastFour(),
+            par.isEmpty() ? null : par);
+    }
+    
+    @PreDestroy
+    public void close() {
+        managedChannel.shutdown();
+    }
+}
@@ -0,0 +1,66 @@
+package com.paymentgateway.authorization.tokens;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.Sandbox;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.repository.SandboxRepository;
+import io.grpc.Channel;
+import io.grpc.ClientInterceptors;
+import io.grpc.Metadata;
+import io.grpc.stub.MetadataUtils;
+import org.springframework.stereotype.Component;
+
+import java.util.UUID;
+
+/**
+ * The tokenization service keeps each sandbox's tokens apart from live
+ * tokens and from other sandboxes. Calls made for a sandbox merchant name
+ * its sandbox in the x-environment header, so the vault scopes the call's
+ * merchant IDs to it; calls for other merchants are live.
+ */
+@Component
+public class TokenizationEnvironments {
+    
+    public static final String LIVE = "";
+    
+    static final Metadata.Key<String> HEADER = Metadata.Key.of("x-environment", Metadata.ASCII_STRING_MARSHALLER);
+    
+    private final MerchantRepository merchantRepository;
+    private final SandboxRepository sandboxRepository;
+    
+    public TokenizationEnvironments(MerchantRepository merchantRepository, SandboxRepository sandboxRepository) {
+        this.merchantRepository = merchantRepository;
+        this.sandboxRepository = sandboxRepository;
+    }
+    
+    /**
+     * The environment of a merchant's tokens: its sandbox's ID, or live
+     */
+    public String of(Merchant merchant) {
+        if (merchant.getSandboxId() == null) {
+            return LIVE;
+        }
+        // A sandbox merchant never falls back to live
+        return sandboxRepository.findById(merchant.getSandboxId())
+            .map(Sandbox::getSandboxId)
+            .orElseThrow(() -> new IllegalStateException("Sandbox of merchant " + merchant.getMerchantId() + " is gone"));
+    }
+    
+    public String of(UUID merchantId) {
+        return merchantRepository.findById(merchantId)
+            .map(this::of)
+            .orElseThrow(() -> new IllegalStateException("Unknown merchant " + merchantId));
+    }
+    
+    /**
+     * The channel to make calls in an environment on
+     */
+    public static Channel in(Channel channel, String environment) {
+        if (environment == null || environment.isEmpty()) {
+            return channel;
+        }
+        Metadata headers = new Metadata();
+        headers.put(HEADER, environment);
+        return ClientInterceptors.intercept(channel, MetadataUtils.newAttachHeadersInterceptor(headers));
+    }
+}
@@ -0,0 +1,35 @@
+package com.paymentgateway.authorization.tokens;
+
+/**
+ * What the decryption zone tells about the card in an encrypted read: its
+ * vault token and as much of the card as may be shown beside it
+ */
+public class TokenizedCard {
+    
+    private final String token;
+    private final String bin;
+    private final String lastFour;
+    private final String par;
+    
+    public TokenizedCard(String token, String bin, String lastFour, String par) {
+        this.token = token;
+        this.bin = bin;
+        this.lastFour = lastFour;
+        this.par = par;
+    }
+    
+    public String getToken() { return token; }
+    
+    /**
+     * The leading six or eight digits of the PAN
+     */
+    public String getBin() { return bin; }
+    
+    public String getLastFour() { return lastFour; }
+    
+    /**
+     * The payment account reference, the same for every token of the card,
+     * or null if the vault has none
+     */
+    public String getPar() { return par; }
+}
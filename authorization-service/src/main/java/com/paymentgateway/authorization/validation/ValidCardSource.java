@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.validation;
+
+import jakarta.validation.Constraint;
+import jakarta.validation.Payload;
+import java.lang.annotation.*;
+
+/**
+ * Validates that a payment carries its card one way: a card number with
+ * its expiry date, or a P2PE encrypted card read, which holds both.
+ */
+@Target({ElementType.TYPE})
+@Retention(RetentionPolicy.RUNTIME)
+@Constraint(validatedBy = ValidCardSourceValidator.class)
+@Documented
+public @interface ValidCardSource {
+    String message() default "Inconsistent card data";
+    Class<?>[] groups() default {};
+    Class<? extends Payload>[] payload() default {};
+}
@@ -0,0 +1,53 @@
+package com.paymentgateway.authorization.validation;
+
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import jakarta.validation.ConstraintValidator;
+import jakarta.validation.ConstraintValidatorContext;
+
+/**
+ * Validator implementation for a payment's card data. Violations are
+ * reported against the offending field.
+ */
+public class ValidCardSourceValidator implements ConstraintValidator<ValidCardSource, PaymentRequest> {
+    
+    @Override
+    public boolean isValid(PaymentRequest request, ConstraintValidatorContext context) {
+        if (request == null) {
+            return true;
+        }
+        
+        boolean valid = true;
+        context.disableDefaultConstraintViolation();
+        
+        boolean hasCardNumber = request.getCardNumber() != null && !request.getCardNumber().isEmpty();
+        if (request.getEncryptedCard() == null) {
+            if (!hasCardNumber) {
+                valid = violation(context, "cardNumber", "Card number is required");
+            }
+            if (request.getExpiryMonth() == null) {
+                valid = violation(context, "expiryMonth", "Expiry month is required");
+            }
+            if (request.getExpiryYear() == null) {
+                valid = violation(context, "expiryYear", "Expiry year is required");
+            }
+            return valid;
+        }
+        
+        if (hasCardNumber) {
+            valid = violation(context, "cardNumber", "Send either a card number or an encrypted card");
+        }
+        // A format 4 PIN block is bound to the PAN, which the gateway does
+        // not have for an encrypted card
+        if (request.getPinBlock() != null) {
+            valid = violation(context, "pinBlock", "PIN blocks are not accepted with an encrypted card");
+        }
+        return valid;
+    }
+    
+    private boolean violation(ConstraintValidatorContext context, String field, String message) {
+        context.buildConstraintViolationWithTemplate(message)
+            .addPropertyNode(field)
+            .addConstraintViolation();
+        return false;
+    }
+}
@@ -0,0 +1,20 @@
+package com.paymentgateway.authorization.validation;
+
+import jakarta.validation.Constraint;
+import jakarta.validation.Payload;
+import java.lang.annotation.*;
+
+/**
+ * Validates that a payment's channel, initiator and stored credential
+ * indicators are consistent. Merchant-initiated payments must use a stored
+ * credential and carry no CVV requirement; all others need the CVV.
+ */
+@Target({ElementType.TYPE})
+@Retention(RetentionPolicy.RUNTIME)
+@Constraint(validatedBy = ValidInitiationValidator.class)
+@Documented
+public @interface ValidInitiation {
+    String message() default "Inconsistent transaction initiation indicators";
+    Class<?>[] groups() default {};
+    Class<? extends Payload>[] payload() default {};
+}
@@ -0,0 +1,46 @@
+package com.paymentgateway.authorization.validation;
+
+import com.paymentgateway.authorization.domain.TransactionChannel;
+import com.paymentgateway.authorization.domain.TransactionInitiator;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import jakarta.validation.ConstraintValidator;
+import jakarta.validation.ConstraintValidatorContext;
+
+/**
+ * Validator implementation for transaction initiation indicators.
+ * Violations are reported against the offending field.
+ */
+public class ValidInitiationValidator implements ConstraintValidator<ValidInitiation, PaymentRequest> {
+    
+    @Override
+    public boolean isValid(PaymentRequest request, ConstraintValidatorContext context) {
+        if (request == null) {
+            return true;
+        }
+        
+        boolean merchantInitiated = request.getInitiator() == TransactionInitiator.MERCHANT;
+        boolean valid = true;
+        context.disableDefaultConstraintViolation();
+        
+        if (merchantInitiated && request.getStoredCredential() == null) {
+            valid = violation(context, "storedCredential", "Merchant-initiated payments must use a stored credential");
+        }
+        if (merchantInitiated && request.getChannel() == TransactionChannel.MOTO) {
+            valid = violation(context, "channel", "MOTO payments are customer-initiated");
+        }
+        // The cardholder is absent from a merchant-initiated payment and the
+        // CVV may not be stored; a swiped card's track has no CVV2
+        if (!merchantInitiated && request.getEncryptedCard() == null
+                && (request.getCvv() == null || request.getCvv().isBlank())) {
+            valid = violation(context, "cvv", "CVV is required");
+        }
+        return valid;
+    }
+    
+    private boolean violation(ConstraintValidatorContext context, String field, String message) {
+        context.buildConstraintViolationWithTemplate(message)
+            .addPropertyNode(field)
+            .addConstraintViolation();
+        return false;
+    }
+}
@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.validation;
+
+import jakarta.validation.Constraint;
+import jakarta.validation.Payload;
+import java.lang.annotation.*;
+
+/**
+ * Validates that a payment's Level 2/3 purchasing data adds up: the tax is
+ * part of the amount, and line items with the tax make up the amount.
+ */
+@Target({ElementType.TYPE})
+@Retention(RetentionPolicy.RUNTIME)
+@Constraint(validatedBy = ValidPurchaseDataValidator.class)
+@Documented
+public @interface ValidPurchaseData {
+    String message() default "Inconsistent purchasing data";
+    Class<?>[] groups() default {};
+    Class<? extends Payload>[] payload() default {};
+}
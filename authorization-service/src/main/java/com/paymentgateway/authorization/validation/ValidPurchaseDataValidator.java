@@ -0,0 +1,52 @@
+package com.paymentgateway.authorization.validation;
+
+import com.paymentgateway.authorization.dto.LineItem;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import jakarta.validation.ConstraintValidator;
+import jakarta.validation.ConstraintValidatorContext;
+
+import java.math.BigDecimal;
+
+/**
+ * Validator implementation for Level 2/3 purchasing data. Field-level
+ * constraints on the line items are checked separately; incomplete line
+ * items are skipped here.
+ */
+public class ValidPurchaseDataValidator implements ConstraintValidator<ValidPurchaseData, PaymentRequest> {
+    
+    @Override
+    public boolean isValid(PaymentRequest request, ConstraintValidatorContext context) {
+        if (request == null || request.getAmount() == null) {
+            return true;
+        }
+        
+        BigDecimal tax = request.getTaxAmount() != null ? request.getTaxAmount() : BigDecimal.ZERO;
+        boolean valid = true;
+        context.disableDefaultConstraintViolation();
+        
+        if (tax.compareTo(request.getAmount()) >= 0) {
+            valid = violation(context, "taxAmount", "Tax amount must be less than the amount");
+        }
+        if (request.getLineItems() != null && !request.getLineItems().isEmpty()) {
+            BigDecimal total = tax;
+            for (LineItem item : request.getLineItems()) {
+                if (item == null || item.getQuantity() == null || item.getUnitPrice() == null) {
+                    return valid;
+                }
+                total = total.add(item.total());
+            }
+            if (total.compareTo(request.getAmount()) != 0) {
+                valid = violation(context, "lineItems",
+                    "Line items and tax add up to " + total.toPlainString() + ", not the amount");
+            }
+        }
+        return valid;
+    }
+    
+    private boolean violation(ConstraintValidatorContext context, String field, String message) {
+        context.buildConstraintViolationWithTemplate(message)
+            .addPropertyNode(field)
+            .addConstraintViolation();
+        return false;
+    }
+}
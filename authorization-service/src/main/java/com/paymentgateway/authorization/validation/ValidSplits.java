@@ -0,0 +1,19 @@
+package com.paymentgateway.authorization.validation;
+
+import jakarta.validation.Constraint;
+import jakarta.validation.Payload;
+import java.lang.annotation.*;
+
+/**
+ * Validates that a marketplace payment's splits fit in it: each sub-merchant
+ * once, each fee within its split, and the splits within the amount.
+ */
+@Target({ElementType.TYPE})
+@Retention(RetentionPolicy.RUNTIME)
+@Constraint(validatedBy = ValidSplitsValidator.class)
+@Documented
+public @interface ValidSplits {
+    String message() default "Inconsistent splits";
+    Class<?>[] groups() default {};
+    Class<? extends Payload>[] payload() default {};
+}
@@ -0,0 +1,56 @@
+package com.paymentgateway.authorization.validation;
+
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import com.paymentgateway.authorization.dto.Split;
+import jakarta.validation.ConstraintValidator;
+import jakarta.validation.ConstraintValidatorContext;
+
+import java.math.BigDecimal;
+import java.util.HashSet;
+import java.util.Set;
+
+/**
+ * Validator implementation for marketplace splits. Field-level constraints
+ * on the splits are checked separately; incomplete splits are skipped here.
+ * Whether the sub-merchants belong to the platform is checked on payment.
+ */
+public class ValidSplitsValidator implements ConstraintValidator<ValidSplits, PaymentRequest> {
+    
+    @Override
+    public boolean isValid(PaymentRequest request, ConstraintValidatorContext context) {
+        if (request == null || request.getAmount() == null || request.getSplits() == null) {
+            return true;
+        }
+        
+        boolean valid = true;
+        context.disableDefaultConstraintViolation();
+        
+        BigDecimal total = BigDecimal.ZERO;
+        Set<String> merchantIds = new HashSet<>();
+        for (Split split : request.getSplits()) {
+            if (split == null || split.getMerchantId() == null || split.getAmount() == null) {
+                return valid;
+            }
+            if (!merchantIds.add(split.getMerchantId())) {
+                valid = violation(context, "splits", "Merchant " + split.getMerchantId() + " is split to twice");
+            }
+            if (split.getFeeAmount() != null && split.getFeeAmount().compareTo(split.getAmount()) > 0) {
+                valid = violation(context, "splits", "Split fee for " + split.getMerchantId()
+                    + " is more than its amount");
+            }
+            total = total.add(split.getAmount());
+        }
+        if (total.compareTo(request.getAmount()) > 0) {
+            valid = violation(context, "splits",
+                "Splits add up to " + total.toPlainString() + ", more than the amount");
+        }
+        return valid;
+    }
+    
+    private boolean violation(ConstraintValidatorContext context, String field, String message) {
+        context.buildConstraintViolationWithTemplate(message)
+            .addPropertyNode(field)
+            .addConstraintViolation();
+        return false;
+    }
+}
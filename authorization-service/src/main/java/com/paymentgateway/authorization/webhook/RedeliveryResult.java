@@ -0,0 +1,24 @@
+package com.paymentgateway.authorization.webhook;
+
+import java.time.Instant;
+
+/**
+ * Dead-lettered deliveries queued again by a bulk redelivery, and when the
+ * first and last of them will be attempted
+ */
+public class RedeliveryResult {
+    
+    private final int scheduled;
+    private final Instant firstAttemptAt;
+    private final Instant lastAttemptAt;
+    
+    public RedeliveryResult(int scheduled, Instant firstAttemptAt, Instant lastAttemptAt) {
+        this.scheduled = scheduled;
+        this.firstAttemptAt = firstAttemptAt;
+        this.lastAttemptAt = lastAttemptAt;
+    }
+    
+    public int getScheduled() { return scheduled; }
+    public Instant getFirstAttemptAt() { return firstAttemptAt; }
+    public Instant getLastAttemptAt() { return lastAttemptAt; }
+}
@@ -0,0 +1,230 @@
+package com.paymentgateway.authorization.webhook;
+
+import com.fasterxml.jackson.core.JsonProcessingException;
+import com.fasterxml.jackson.databind.JsonNode;
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
+import com.paymentgateway.authorization.repository.WebhookDeliveryRepository;
+import com.paymentgateway.authorization.repository.WebhookEndpointRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.http.HttpEntity;
+import org.springframework.http.HttpHeaders;
+import org.springframework.http.HttpMethod;
+import org.springframework.http.ResponseEntity;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+import org.springframework.web.client.RestClientException;
+import org.springframework.web.client.RestTemplate;
+
+import java.net.URI;
+import java.security.SecureRandom;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Base64;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Registers merchant webhook endpoints, verifies that the merchant controls
+ * them, and rotates their signing secrets.
+ * 
+ * A new endpoint receives nothing until it is verified: the gateway POSTs a
+ * one-time challenge to it, and the endpoint must answer 2xx with the
+ * challenge as the body or as {@code {"challenge": "..."}}. Rotating a secret
+ * keeps the previous one signing alongside it for an overlap window, so the
+ * merchant can deploy the new secret without rejecting webhooks.
+ */
+@Service
+public class WebhookEndpointService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(WebhookEndpointService.class);
+    private static final SecureRandom secureRandom = new SecureRandom();
+    private static final Base64.Encoder base64Encoder = Base64.getUrlEncoder().withoutPadding();
+    
+    public static final String VERIFICATION_EVENT_TYPE = "WEBHOOK_ENDPOINT_VERIFICATION";
+    
+    private final WebhookEndpointRepository endpointRepository;
+    private final WebhookDeliveryRepository deliveryRepository;
+    private final WebhookService webhookService;
+    private final RestTemplate restTemplate;
+    private final ObjectMapper objectMapper;
+    private final Duration defaultOverlap;
+    private final Duration maxOverlap;
+    
+    @Autowired
+    public WebhookEndpointService(WebhookEndpointRepository endpointRepository,
+                                  WebhookDeliveryRepository deliveryRepository,
+                                  WebhookService webhookService,
+                                  RestTemplate restTemplate,
+                                  ObjectMapper objectMapper,
+                                  @Value("${webhook.secret-rotation.default-overlap:24h}") Duration defaultOverlap,
+                                  @Value("${webhook.secret-rotation.max-overlap:7d}") Duration maxOverlap) {
+        this.endpointRepository = endpointRepository;
+        this.deliveryRepository = deliveryRepository;
+        this.webhookService = webhookService;
+        this.restTemplate = restTemplate;
+        this.objectMapper = objectMapper;
+        this.defaultOverlap = defaultOverlap;
+        this.maxOverlap = maxOverlap;
+    }
+    
+    /**
+     * Register an endpoint, pending verification, with a new signing secret
+     * 
+     * @throws IllegalArgumentException if the URL is not an absolute http(s) URL
+     */
+    @Transactional
+    public WebhookEndpoint register(UUID merchantId, String url) {
+        URI uri;
+        try {
+            uri = URI.create(url.trim());
+        } catch (IllegalArgumentException e) {
+            throw new IllegalArgumentException("Invalid webhook URL: " + url);
+        }
+        if (uri.getHost() == null || !("https".equals(uri.getScheme()) || "http".equals(uri.getScheme()))) {
+            throw new IllegalArgumentException("Webhook URL must be an absolute http or https URL");
+        }
+        
+        WebhookEndpoint endpoint = new WebhookEndpoint(merchantId, uri.toString(), generateSecret());
+        endpointRepository.save(endpoint);
+        logger.info("Registered webhook endpoint {} for merchant {}, pending verification",
+                endpoint.getId(), merchantId);
+        return endpoint;
+    }
+    
+    /**
+     * Endpoints of a merchant that have not been disabled
+     */
+    public List<WebhookEndpoint> list(UUID merchantId) {
+        return endpointRepository.findByMerchantIdAndStatusNotOrderByCreatedAtAsc(merchantId, WebhookEndpoint.DISABLED);
+    }
+    
+    public Optional<WebhookEndpoint> find(UUID merchantId, UUID endpointId) {
+        return endpointRepository.findByIdAndMerchantId(endpointId, merchantId)
+                .filter(endpoint -> !WebhookEndpoint.DISABLED.equals(endpoint.getStatus()));
+    }
+    
+    /**
+     * Send the endpoint a new challenge and activate it if it echoes it.
+     * Verifying an active endpoint again checks it still answers.
+     * 
+     * @throws WebhookVerificationException if the endpoint does not echo the challenge
+     */
+    public WebhookEndpoint verify(WebhookEndpoint endpoint) {
+        String challenge = "whchal_" + randomToken();
+        Map<String, Object> body = new LinkedHashMap<>();
+        body.put("type", VERIFICATION_EVENT_TYPE);
+        body.put("endpoint_id", endpoint.getId().toString());
+        body.put("challenge", challenge);
+        
+        String payload;
+        try {
+            payload = objectMapper.writeValueAsString(body);
+        } catch (JsonProcessingException e) {
+            throw new IllegalStateException("Failed to serialize verification challenge", e);
+        }
+        
+        Instant now = Instant.now();
+        HttpHeaders headers = new HttpHeaders();
+        headers.set("Content-Type", "application/json");
+        headers.set(WebhookService.SIGNATURE_HEADER,
+                webhookService.signatureHeader(payload, endpoint.signingSecrets(now), now));
+        headers.set("X-Webhook-Event-Type", VERIFICATION_EVENT_TYPE);
+        
+        ResponseEntity<String> response;
+        try {
+            response = restTemplate.exchange(endpoint.getUrl(), HttpMethod.POST,
+                    new HttpEntity<>(payload, headers), String.class);
+        } catch (RestClientException e) {
+            throw new WebhookVerificationException("Endpoint could not be reached: " + e.getMessage());
+        }
+        if (!response.getStatusCode().is2xxSuccessful()) {
+            throw new WebhookVerificationException("Endpoint answered " + response.getStatusCode().value());
+        }
+        if (!challenge.equals(echoedChallenge(response.getBody()))) {
+            throw new WebhookVerificationException("Endpoint did not echo the challenge");
+        }
+        
+        if (!endpoint.isActive()) {
+            endpoint.setStatus(WebhookEndpoint.ACTIVE);
+            logger.info("Webhook endpoint {} of merchant {} verified and active",
+                    endpoint.getId(), endpoint.getMerchantId());
+        }
+        endpoint.setVerifiedAt(now);
+        return endpointRepository.save(endpoint);
+    }
+    
+    /**
+     * Replace the endpoint's signing secret. The previous secret keeps
+     * signing alongside the new one for the overlap, or the configured
+     * default when null; a zero overlap drops it at once.
+     * 
+     * @return The new secret
+     */
+    @Transactional
+    public String rotateSecret(WebhookEndpoint endpoint, Duration overlap) {
+        Duration window = overlap != null ? overlap : defaultOverlap;
+        if (window.isNegative() || window.compareTo(maxOverlap) > 0) {
+            throw new IllegalArgumentException("Overlap must be between 0 and " + maxOverlap.toHours() + " hours");
+        }
+        
+        Instant now = Instant.now();
+        String secret = generateSecret();
+        if (window.isZero()) {
+            endpoint.setPreviousSigningSecret(null);
+            endpoint.setPreviousSecretExpiresAt(null);
+        } else {
+            endpoint.setPreviousSigningSecret(endpoint.getSigningSecret());
+            endpoint.setPreviousSecretExpiresAt(now.plus(window));
+        }
+        endpoint.setSigningSecret(secret);
+        endpoint.setSecretRotatedAt(now);
+        endpointRepository.save(endpoint);
+        logger.info("Rotated signing secret of webhook endpoint {}, previous secret valid until {}",
+                endpoint.getId(), endpoint.getPreviousSecretExpiresAt());
+        return secret;
+    }
+    
+    /**
+     * Stop sending to the endpoint, including its pending deliveries
+     */
+    @Transactional
+    public void disable(WebhookEndpoint endpoint) {
+        endpoint.setStatus(WebhookEndpoint.DISABLED);
+        endpointRepository.save(endpoint);
+        int cancelled = deliveryRepository.cancelPendingForEndpoint(endpoint.getId());
+        logger.info("Disabled webhook endpoint {}, cancelled {} pending deliveries", endpoint.getId(), cancelled);
+    }
+    
+    private String echoedChallenge(String body) {
+        if (body == null) {
+            return null;
+        }
+        String trimmed = body.trim();
+        if (!trimmed.startsWith("{")) {
+            return trimmed;
+        }
+        try {
+            JsonNode challenge = objectMapper.readTree(trimmed).get("challenge");
+            return challenge != null ? challenge.asText() : null;
+        } catch (JsonProcessingException e) {
+            return null;
+        }
+    }
+    
+    private static String generateSecret() {
+        return "whsec_" + randomToken();
+    }
+    
+    private static String randomToken() {
+        byte[] randomBytes = new byte[32]; // 256 bits
+        secureRandom.nextBytes(randomBytes);
+        return base64Encoder.encodeToString(randomBytes);
+    }
+}
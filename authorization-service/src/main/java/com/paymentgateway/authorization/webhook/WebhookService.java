@@ -1,32 +1,42 @@
 package com.paymentgateway.authorization.webhook;
 
+import com.fasterxml.jackson.core.JsonProcessingException;
 import com.fasterxml.jackson.databind.ObjectMapper;
 import com.paymentgateway.authorization.domain.Merchant;
 import com.paymentgateway.authorization.domain.Payment;
 import com.paymentgateway.authorization.domain.WebhookDelivery;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
 import com.paymentgateway.authorization.event.PaymentEventMessage;
 import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.repository.WebhookDeliveryRepository;
+import com.paymentgateway.authorization.repository.WebhookEndpointRepository;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
 import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.data.domain.PageRequest;
 import org.springframework.http.HttpEntity;
 import org.springframework.http.HttpHeaders;
 import org.springframework.http.HttpMethod;
 import org.springframework.http.ResponseEntity;
 import org.springframework.scheduling.annotation.Async;
-import org.springframework.scheduling.annotation.Scheduled;
 import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
 import org.springframework.web.client.RestTemplate;
 
 import javax.crypto.Mac;
 import javax.crypto.spec.SecretKeySpec;
 import java.nio.charset.StandardCharsets;
 import java.security.InvalidKeyException;
+import java.security.MessageDigest;
 import java.security.NoSuchAlgorithmException;
+import java.time.Duration;
 import java.time.Instant;
+import java.util.ArrayList;
 import java.util.Base64;
+import java.util.Collection;
 import java.util.List;
+import java.util.Optional;
 import java.util.UUID;
 
 @Service
@@ -37,18 +47,39 @@ public class WebhookService {
     private static final int INITIAL_RETRY_DELAY_SECONDS = 60; // 1 minute
     private static final int MAX_RETRY_DELAY_SECONDS = 3600; // 1 hour
     
+    /**
+     * Carries {@code t=<unix seconds>,v1=<signature>}, with one v1 entry per
+     * secret the endpoint accepts. Each signature is a Base64 HMAC-SHA256 of
+     * {@code <t>.<payload>}, so a captured request cannot be replayed later
+     * with a fresh timestamp.
+     */
+    public static final String SIGNATURE_HEADER = "X-Webhook-Signature";
+    
+    /** Deliveries that used up their attempts; they form the dead-letter queue */
+    public static final String STATUS_DEAD_LETTERED = "FAILED";
+    
     @Autowired
     private WebhookDeliveryRepository webhookDeliveryRepository;
     
     @Autowired
     private MerchantRepository merchantRepository;
     
+    @Autowired
+    private WebhookEndpointRepository webhookEndpointRepository;
+    
     @Autowired
     private RestTemplate restTemplate;
     
     @Autowired
     private ObjectMapper objectMapper;
     
+    // Redeliveries are spaced out so a recovering endpoint is not flooded
+    @Value("${webhook.redelivery.rate-per-second:5}")
+    private double redeliveryRatePerSecond = 5;
+    
+    @Value("${webhook.redelivery.max-batch:500}")
+    private int maxRedeliveryBatch = 500;
+    
     /**
      * Send webhook notification for a payment event.
      * This method is called asynchronously when payment events occur.
@@ -56,45 +87,94 @@ public class WebhookService {
     @Async
     public void sendWebhook(Payment payment, PaymentEventMessage eventMessage) {
         try {
-            Merchant merchant = merchantRepository.findById(payment.getMerchantId())
-                    .orElseThrow(() -> new IllegalArgumentException("Merchant not found"));
-            
-            // Check if merchant has webhook configured
-            if (merchant.getWebhookUrl() == null || merchant.getWebhookUrl().isEmpty()) {
-                logger.debug("No webhook URL configured for merchant {}", merchant.getMerchantId());
-                return;
-            }
-            
-            if (merchant.getWebhookSecretHash() == null || merchant.getWebhookSecretHash().isEmpty()) {
-                logger.warn("No webhook secret configured for merchant {}", merchant.getMerchantId());
-                return;
+            for (WebhookDelivery delivery : scheduleDelivery(payment.getMerchantId(), payment.getId(), eventMessage)) {
+                // Attempt immediate delivery
+                attemptDelivery(delivery);
             }
-            
-            // Serialize payload
-            String payload = objectMapper.writeValueAsString(eventMessage);
-            
-            // Generate HMAC signature
-            String signature = generateHmacSignature(payload, merchant.getWebhookSecretHash());
-            
-            // Create webhook delivery record
+        } catch (Exception e) {
+            logger.error("Error preparing webhook for payment {}", payment.getId(), e);
+        }
+    }
+    
+    /**
+     * Record a webhook delivery for an event, due now, without attempting it.
+     * The retry job delivers it. Called by the outbox dispatcher in the
+     * transaction that marks the event published, so an event's delivery is
+     * recorded exactly when its publication is.
+     * 
+     * Each verified endpoint of the merchant gets a delivery. A merchant
+     * without any falls back to the webhook URL on its merchant record.
+     * 
+     * @return The deliveries, empty if the merchant has no webhook configured
+     */
+    public List<WebhookDelivery> scheduleDelivery(UUID merchantId, UUID paymentId, PaymentEventMessage eventMessage)
+            throws JsonProcessingException {
+        Merchant merchant = merchantRepository.findById(merchantId).orElse(null);
+        if (merchant == null) {
+            logger.warn("Merchant {} not found, no webhook for payment {}", merchantId, paymentId);
+            return List.of();
+        }
+        
+        List<WebhookEndpoint> endpoints = webhookEndpointRepository.findByMerchantIdAndStatus(
+                merchantId, WebhookEndpoint.ACTIVE);
+        if (endpoints.isEmpty()) {
+            return scheduleLegacyDelivery(merchant, paymentId, eventMessage);
+        }
+        
+        String payload = objectMapper.writeValueAsString(eventMessage);
+        Instant now = Instant.now();
+        List<WebhookDelivery> deliveries = new ArrayList<>();
+        for (WebhookEndpoint endpoint : endpoints) {
             WebhookDelivery delivery = new WebhookDelivery(
                     merchant.getId(),
-                    payment.getId(),
+                    paymentId,
                     eventMessage.getEventType().name(),
-                    merchant.getWebhookUrl(),
+                    endpoint.getUrl(),
                     payload,
-                    signature
+                    signatureHeader(payload, endpoint.signingSecrets(now), now)
             );
-            
-            delivery.setNextRetryAt(Instant.now());
+            delivery.setEndpointId(endpoint.getId());
+            delivery.setNextRetryAt(now);
             webhookDeliveryRepository.save(delivery);
-            
-            // Attempt immediate delivery
-            attemptDelivery(delivery);
-            
-        } catch (Exception e) {
-            logger.error("Error preparing webhook for payment {}", payment.getId(), e);
+            deliveries.add(delivery);
+        }
+        return deliveries;
+    }
+    
+    private List<WebhookDelivery> scheduleLegacyDelivery(Merchant merchant, UUID paymentId,
+                                                         PaymentEventMessage eventMessage)
+            throws JsonProcessingException {
+        // Check if merchant has webhook configured
+        if (merchant.getWebhookUrl() == null || merchant.getWebhookUrl().isEmpty()) {
+            logger.debug("No webhook URL configured for merchant {}", merchant.getMerchantId());
+            return List.of();
+        }
+        
+        if (merchant.getWebhookSecretHash() == null || merchant.getWebhookSecretHash().isEmpty()) {
+            logger.warn("No webhook secret configured for merchant {}", merchant.getMerchantId());
+            return List.of();
         }
+        
+        // Serialize payload
+        String payload = objectMapper.writeValueAsString(eventMessage);
+        
+        // Generate HMAC signature
+        Instant now = Instant.now();
+        String signature = signatureHeader(payload, List.of(merchant.getWebhookSecretHash()), now);
+        
+        // Create webhook delivery record
+        WebhookDelivery delivery = new WebhookDelivery(
+                merchant.getId(),
+                paymentId,
+                eventMessage.getEventType().name(),
+                merchant.getWebhookUrl(),
+                payload,
+                signature
+        );
+        
+        delivery.setNextRetryAt(now);
+        webhookDeliveryRepository.save(delivery);
+        return List.of(delivery);
     }
     
     /**
@@ -115,7 +195,7 @@ public class WebhookService {
             
             byte[] hmacBytes = mac.doFinal(payload.getBytes(StandardCharsets.UTF_8));
             return Base64.getEncoder().encodeToString(hmacBytes);
-            
+        
         } catch (NoSuchAlgorithmException | InvalidKeyException e) {
             logger.error("Error generating HMAC signature", e);
             throw new RuntimeException("Failed to generate webhook signature", e);
@@ -132,7 +212,88 @@ public class WebhookService {
      */
     public boolean verifyHmacSignature(String payload, String providedSignature, String secret) {
         String expectedSignature = generateHmacSignature(payload, secret);
-        return expectedSignature.equals(providedSignature);
+        // Constant-time comparison so response timing does not reveal how many
+        // leading characters of a forged signature were right
+        if (providedSignature == null) {
+            return false;
+        }
+        return MessageDigest.isEqual(
+                expectedSignature.getBytes(StandardCharsets.UTF_8),
+                providedSignature.getBytes(StandardCharsets.UTF_8));
+    }
+    
+    /**
+     * Build the signature header for a payload sent at the given time
+     * 
+     * @param secrets The secrets the receiving endpoint accepts, current first
+     */
+    public String signatureHeader(String payload, List<String> secrets, Instant timestamp) {
+        long seconds = timestamp.getEpochSecond();
+        StringBuilder header = new StringBuilder("t=").append(seconds);
+        for (String secret : secrets) {
+            header.append(",v1=").append(generateHmacSignature(seconds + "." + payload, secret));
+        }
+        return header.toString();
+    }
+    
+    /**
+     * Check a signature header the way a receiving endpoint should: one of
+     * its v1 signatures matches the secret, and its timestamp is within the
+     * tolerance of now.
+     */
+    public boolean verifySignatureHeader(String payload, String header, String secret,
+                                         Duration tolerance, Instant now) {
+        if (header == null) {
+            return false;
+        }
+        Long timestamp = null;
+        List<String> signatures = new ArrayList<>();
+        for (String part : header.split(",")) {
+            int eq = part.indexOf('=');
+            if (eq < 0) {
+                continue;
+            }
+            String key = part.substring(0, eq).trim();
+            String value = part.substring(eq + 1).trim();
+            if (key.equals("t")) {
+                try {
+                    timestamp = Long.parseLong(value);
+                } catch (NumberFormatException e) {
+                    return false;
+                }
+            } else if (key.equals("v1")) {
+                signatures.add(value);
+            }
+        }
+        if (timestamp == null
+                || Duration.between(Instant.ofEpochSecond(timestamp), now).abs().compareTo(tolerance) > 0) {
+            return false;
+        }
+        String signedContent = timestamp + "." + payload;
+        for (String signature : signatures) {
+            if (verifyHmacSignature(signedContent, signature, secret)) {
+                return true;
+            }
+        }
+        return false;
+    }
+    
+    /**
+     * Secrets the delivery's target accepts now, or empty when its endpoint
+     * or merchant webhook no longer exists
+     */
+    private List<String> signingSecrets(WebhookDelivery delivery, Instant now) {
+        if (delivery.getEndpointId() != null) {
+            return webhookEndpointRepository.findById(delivery.getEndpointId())
+                    .map(endpoint -> endpoint.signingSecrets(now))
+                    .orElse(List.of());
+        }
+        Optional<Merchant> merchant = merchantRepository.findById(delivery.getMerchantId());
+        if (merchant.isEmpty() || merchant.get().getWebhookSecretHash() == null
+                || merchant.get().getWebhookSecretHash().isEmpty()) {
+            return List.of();
+        }
+        return List.of(merchant.get().getWebhookSecretHash());
     }
     
     /**
@@ -143,10 +304,18 @@ public class WebhookService {
         try {
             delivery.incrementAttemptCount();
             
+            // Sign every attempt afresh, so its timestamp is current and a
+            // rotated secret takes effect on retries too
+            Instant now = Instant.now();
+            List<String> secrets = signingSecrets(delivery, now);
+            if (!secrets.isEmpty()) {
+                delivery.setSignature(signatureHeader(delivery.getPayload(), secrets, now));
+            }
+            
             // Prepare HTTP request
             HttpHeaders headers = new HttpHeaders();
             headers.set("Content-Type", "application/json");
-            headers.set("X-Webhook-Signature", delivery.getSignature());
+            headers.set(SIGNATURE_HEADER, delivery.getSignature());
             headers.set("X-Webhook-Event-Type", delivery.getEventType());
             headers.set("X-Webhook-Delivery-Id", delivery.getId().toString());
             headers.set("X-Webhook-Attempt", String.valueOf(delivery.getAttemptCount()));
@@ -173,7 +342,7 @@ public class WebhookService {
             } else {
                 handleDeliveryFailure(delivery, "Non-2xx status code: " + response.getStatusCode());
             }
-            
+        
         } catch (Exception e) {
             handleDeliveryFailure(delivery, e.getMessage());
         } finally {
@@ -189,8 +358,10 @@ public class WebhookService {
         delivery.setErrorMessage(errorMessage);
         
         if (delivery.hasReachedMaxAttempts()) {
-            delivery.setStatus("FAILED");
-            logger.error("Webhook delivery failed after {} attempts for payment {}",
+            delivery.setStatus(STATUS_DEAD_LETTERED);
+            delivery.setDeadLetteredAt(Instant.now());
+            delivery.setNextRetryAt(null);
+            logger.error("Webhook delivery failed after {} attempts for payment {}, moved to dead-letter queue",
                     delivery.getAttemptCount(), delivery.getPaymentId());
         } else {
             // Calculate next retry time with exponential backoff
@@ -213,10 +384,10 @@ public class WebhookService {
     }
     
     /**
-     * Scheduled job to process pending webhook retries.
-     * Runs every minute.
+     * Process pending webhook retries. The leader runs it every second
+     * by default (see SingletonJobs), so new deliveries and paced
+     * redeliveries go out close to when they are due.
      */
-    @Scheduled(fixedRate = 60000) // Every 60 seconds
     public void processRetries() {
         List<WebhookDelivery> pendingRetries = webhookDeliveryRepository.findPendingRetries(Instant.now());
         
@@ -240,4 +411,103 @@ public class WebhookService {
     public List<WebhookDelivery> getPaymentDeliveryHistory(UUID paymentId) {
         return webhookDeliveryRepository.findByPaymentIdOrderByCreatedAtDesc(paymentId);
     }
+    
+    /**
+     * Dead-lettered deliveries of a merchant, most recent first, with the
+     * last failure in errorMessage and httpStatusCode
+     */
+    public List<WebhookDelivery> getDeadLetters(UUID merchantId, String eventType, int limit) {
+        PageRequest page = PageRequest.of(0, limit);
+        if (eventType == null || eventType.isBlank()) {
+            return webhookDeliveryRepository.findByMerchantIdAndStatusOrderByDeadLetteredAtDesc(
+                    merchantId, STATUS_DEAD_LETTERED, page);
+        }
+        return webhookDeliveryRepository.findByMerchantIdAndStatusAndEventTypeOrderByDeadLetteredAtDesc(
+                merchantId, STATUS_DEAD_LETTERED, eventType, page);
+    }
+    
+    /**
+     * Queue dead-lettered deliveries again, oldest first: the given ones, or
+     * up to the batch limit of those matching eventType (all when null).
+     * Each gets a fresh set of attempts at its endpoint's current URL, or the
+     * merchant's for legacy deliveries; those whose endpoint was disabled
+     * stay dead-lettered. Attempts are signed with the current secret when
+     * sent, and spaced at the redelivery rate behind any earlier
+     * redeliveries that have not gone out yet.
+     */
+    @Transactional
+    public RedeliveryResult redeliverDeadLetters(UUID merchantId, Collection<UUID> deliveryIds, String eventType) {
+        Merchant merchant = merchantRepository.findById(merchantId)
+                .orElseThrow(() -> new IllegalStateException("Merchant " + merchantId + " not found"));
+        boolean legacyConfigured = merchant.getWebhookUrl() != null && !merchant.getWebhookUrl().isEmpty()
+                && merchant.getWebhookSecretHash() != null && !merchant.getWebhookSecretHash().isEmpty();
+        List<WebhookEndpoint> endpoints = webhookEndpointRepository.findByMerchantIdAndStatus(
+                merchantId, WebhookEndpoint.ACTIVE);
+        if (!legacyConfigured && endpoints.isEmpty()) {
+            throw new IllegalStateException("Merchant " + merchant.getMerchantId() + " has no webhook configured");
+        }
+        
+        List<WebhookDelivery> deliveries;
+        if (deliveryIds != null && !deliveryIds.isEmpty()) {
+            deliveries = webhookDeliveryRepository.findAllById(deliveryIds).stream()
+                    .filter(d -> merchantId.equals(d.getMerchantId()) && STATUS_DEAD_LETTERED.equals(d.getStatus()))
+                    .sorted((a, b) -> a.getCreatedAt().compareTo(b.getCreatedAt()))
+                    .limit(maxRedeliveryBatch)
+                    .toList();
+        } else if (eventType == null || eventType.isBlank()) {
+            deliveries = webhookDeliveryRepository.findByMerchantIdAndStatusOrderByCreatedAtAsc(
+                    merchantId, STATUS_DEAD_LETTERED, PageRequest.of(0, maxRedeliveryBatch));
+        } else {
+            deliveries = webhookDeliveryRepository.findByMerchantIdAndStatusAndEventTypeOrderByCreatedAtAsc(
+                    merchantId, STATUS_DEAD_LETTERED, eventType, PageRequest.of(0, maxRedeliveryBatch));
+        }
+        
+        List<WebhookDelivery> redeliverable = new ArrayList<>();
+        for (WebhookDelivery delivery : deliveries) {
+            String url = targetUrl(delivery, merchant, legacyConfigured, endpoints);
+            if (url != null) {
+                delivery.setWebhookUrl(url);
+                redeliverable.add(delivery);
+            }
+        }
+        deliveries = redeliverable;
+        if (deliveries.isEmpty()) {
+            return new RedeliveryResult(0, null, null);
+        }
+        
+        Instant start = Instant.now();
+        Instant queued = webhookDeliveryRepository.findLastScheduledRedelivery(merchantId);
+        Duration spacing = Duration.ofNanos((long) (1_000_000_000L / redeliveryRatePerSecond));
+        if (queued != null && !queued.isBefore(start)) {
+            start = queued.plus(spacing);
+        }
+        
+        Instant at = start;
+        for (WebhookDelivery delivery : deliveries) {
+            delivery.setAttemptCount(0);
+            delivery.setRedeliveryCount(delivery.getRedeliveryCount() + 1);
+            delivery.setDeadLetteredAt(null);
+            delivery.setStatus("PENDING");
+            delivery.setNextRetryAt(at);
+            at = at.plus(spacing);
+        }
+        webhookDeliveryRepository.saveAll(deliveries);
+        
+        Instant last = at.minus(spacing);
+        logger.info("Redelivering {} dead-lettered webhooks for merchant {} between {} and {}",
+                deliveries.size(), merchant.getMerchantId(), start, last);
+        return new RedeliveryResult(deliveries.size(), start, last);
+    }
+    
+    private String targetUrl(WebhookDelivery delivery, Merchant merchant, boolean legacyConfigured,
+                             List<WebhookEndpoint> endpoints) {
+        if (delivery.getEndpointId() == null) {
+            return legacyConfigured ? merchant.getWebhookUrl() : null;
+        }
+        return endpoints.stream()
+                .filter(endpoint -> endpoint.getId().equals(delivery.getEndpointId()))
+                .map(WebhookEndpoint::getUrl)
+                .findFirst()
+                .orElse(null);
+    }
 }
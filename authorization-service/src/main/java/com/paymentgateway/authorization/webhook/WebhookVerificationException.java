@@ -0,0 +1,12 @@
+package com.paymentgateway.authorization.webhook;
+
+/**
+ * A webhook endpoint did not echo its verification challenge, so it stays
+ * unverified and receives no events
+ */
+public class WebhookVerificationException extends RuntimeException {
+    
+    public WebhookVerificationException(String message) {
+        super(message);
+    }
+}
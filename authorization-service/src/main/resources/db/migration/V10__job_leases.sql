@@ -0,0 +1,25 @@
+-- Leader election and singleton jobs for services running several
+-- instances. The leader holds a lease it keeps renewing; each scheduled run
+-- of a job is claimed once, and a run left unfinished by a leader that died
+-- is taken over by the next one. Fencing tokens grow with every change of
+-- leader, so a deposed leader's late writes can be told apart.
+
+CREATE TABLE IF NOT EXISTS job_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    owner_id VARCHAR(255) NOT NULL,
+    fencing_token BIGINT NOT NULL,
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    job_name VARCHAR(100) NOT NULL,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    owner_id VARCHAR(255) NOT NULL,
+    fencing_token BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (job_name, scheduled_for),
+    CONSTRAINT valid_job_run_status CHECK (status IN ('RUNNING', 'SUCCEEDED', 'FAILED'))
+);
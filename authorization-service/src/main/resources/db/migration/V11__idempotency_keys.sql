@@ -0,0 +1,10 @@
+-- Shared idempotency store, used instead of Redis when idempotency.store is
+-- postgres. Rows past expires_at are ignored and purged periodically.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    idem_key VARCHAR(300) PRIMARY KEY,
+    value TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
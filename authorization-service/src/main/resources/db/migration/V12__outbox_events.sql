@@ -0,0 +1,21 @@
+-- Transactional outbox: payment events are written in the transaction that
+-- changes the payment, then published to Kafka and webhooks by a dispatcher,
+-- so an event is never lost once its state change is committed.
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(50) NOT NULL UNIQUE,
+    event_type VARCHAR(50) NOT NULL,
+    partition_key VARCHAR(100) NOT NULL,
+    payment_id UUID,
+    merchant_id UUID,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(partition_key, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events(published_at) WHERE published_at IS NOT NULL;
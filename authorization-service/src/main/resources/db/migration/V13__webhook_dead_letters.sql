@@ -0,0 +1,31 @@
+-- Webhook deliveries that exhaust their retries stay as FAILED rows, the
+-- dead-letter queue, until the merchant redelivers them. Earlier schemas
+-- relied on Hibernate to create the table, so create it here if missing.
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL,
+    payment_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    attempt_count INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 10,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    http_status_code INTEGER,
+    response_body TEXT,
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_retry_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS redelivery_count INTEGER NOT NULL DEFAULT 0;
+
+-- Deliveries dead-lettered before this migration
+UPDATE webhook_deliveries SET dead_lettered_at = created_at WHERE status = 'FAILED' AND dead_lettered_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_merchant_status ON webhook_deliveries(merchant_id, status);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending_retry ON webhook_deliveries(next_retry_at) WHERE status = 'PENDING';
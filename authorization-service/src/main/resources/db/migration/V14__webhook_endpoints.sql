@@ -0,0 +1,21 @@
+-- Webhook endpoints a merchant proved it controls, each with its own signing
+-- secret. During a rotation overlap the previous secret signs alongside the
+-- current one.
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    url TEXT NOT NULL,
+    status VARCHAR(30) NOT NULL DEFAULT 'PENDING_VERIFICATION',
+    signing_secret VARCHAR(255) NOT NULL,
+    previous_signing_secret VARCHAR(255),
+    previous_secret_expires_at TIMESTAMP WITH TIME ZONE,
+    secret_rotated_at TIMESTAMP WITH TIME ZONE,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_merchant_status ON webhook_endpoints(merchant_id, status);
+
+-- Deliveries to an endpoint; null for the merchant's own webhook_url
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS endpoint_id UUID;
@@ -0,0 +1,14 @@
+-- Sandboxes: isolated sets of merchants sharing one simulator. Everything a
+-- sandbox's merchants record is deleted with the sandbox.
+
+CREATE TABLE IF NOT EXISTS sandboxes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sandbox_id VARCHAR(30) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_by VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS sandbox_id UUID REFERENCES sandboxes(id);
+
+CREATE INDEX IF NOT EXISTS idx_merchants_sandbox_id ON merchants(sandbox_id) WHERE sandbox_id IS NOT NULL;
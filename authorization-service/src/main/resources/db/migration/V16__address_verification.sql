@@ -0,0 +1,14 @@
+-- Address verification: payments keep the second street line of the
+-- structured billing address and the issuer's AVS result, and merchants
+-- choose which AVS results they accept
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS billing_street2 TEXT;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS avs_result VARCHAR(1);
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS avs_policy VARCHAR(20) NOT NULL DEFAULT 'NONE';
+
+-- The AVS check is recorded as a step of the payment's timeline
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS valid_event_type;
+ALTER TABLE payment_events ADD CONSTRAINT valid_event_type CHECK (event_type IN (
+    'TOKENIZATION', 'FRAUD_CHECK', '3DS_AUTH', 'AUTHORIZATION_REQUEST', 'AVS_CHECK', 'AUTHORIZATION',
+    'CAPTURE', 'VOID', 'REFUND', 'REFUND_CREATED', 'REFUND_COMPLETED', 'REFUND_FAILED', 'CREDIT'));
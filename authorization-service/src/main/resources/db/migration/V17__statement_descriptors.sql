@@ -0,0 +1,8 @@
+-- Statement descriptors: a merchant's static descriptor, and the full
+-- descriptor of each payment, which is cleared with it in settlement
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS statement_descriptor VARCHAR(18);
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS statement_descriptor VARCHAR(25);
+
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS statement_descriptor VARCHAR(25);
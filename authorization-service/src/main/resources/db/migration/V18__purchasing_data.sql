@@ -0,0 +1,26 @@
+-- Level 2/3 purchasing data: payments keep the tax amount, customer code
+-- and the level of data sent, with their line items, and settlement
+-- records the interchange program each transaction qualified for
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(12,2);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS customer_code VARCHAR(17);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS purchase_data_level SMALLINT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS payment_line_items (
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    line_number INTEGER NOT NULL,
+    product_code VARCHAR(12),
+    description VARCHAR(35) NOT NULL,
+    commodity_code VARCHAR(12),
+    quantity DECIMAL(12,4) NOT NULL,
+    unit_of_measure VARCHAR(12),
+    unit_price DECIMAL(12,4) NOT NULL,
+    discount_amount DECIMAL(12,2),
+    line_total DECIMAL(12,2) NOT NULL,
+    PRIMARY KEY (payment_id, line_number)
+);
+
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(12,2);
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS customer_code VARCHAR(17);
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS purchase_data_level SMALLINT;
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS interchange_program VARCHAR(20);
@@ -0,0 +1,14 @@
+-- Surcharging: merchants set a surcharge percentage and a convenience fee
+-- for MOTO payments, and payments keep what was added to their amount
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS surcharge_percent DECIMAL(5,2);
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS convenience_fee DECIMAL(12,2);
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS surcharge_amount DECIMAL(12,2);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS convenience_fee DECIMAL(12,2);
+
+-- The surcharge decision is recorded as a step of the payment's timeline
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS valid_event_type;
+ALTER TABLE payment_events ADD CONSTRAINT valid_event_type CHECK (event_type IN (
+    'TOKENIZATION', 'FRAUD_CHECK', '3DS_AUTH', 'SURCHARGE', 'AUTHORIZATION_REQUEST', 'AVS_CHECK', 'AUTHORIZATION',
+    'CAPTURE', 'VOID', 'REFUND', 'REFUND_CREATED', 'REFUND_COMPLETED', 'REFUND_FAILED', 'CREDIT'));
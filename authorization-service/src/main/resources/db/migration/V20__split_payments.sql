@@ -0,0 +1,40 @@
+-- Marketplace split payments: sub-merchants belong to a platform, which
+-- can split its payments to them. Settlement posts every settled payment
+-- to the merchants' ledgers, splits included, and pays out the balances.
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS platform_merchant_id UUID REFERENCES merchants(id);
+
+CREATE TABLE IF NOT EXISTS payment_splits (
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    split_number INTEGER NOT NULL,
+    sub_merchant_id UUID NOT NULL REFERENCES merchants(id),
+    amount DECIMAL(12,2) NOT NULL,
+    fee_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (payment_id, split_number)
+);
+
+CREATE TABLE IF NOT EXISTS payouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payout_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    currency VARCHAR(3) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    entry_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    batch_id UUID NOT NULL REFERENCES settlement_batches(id),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    entry_type VARCHAR(20) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    payout_id UUID REFERENCES payouts(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_merchant_id ON ledger_entries(merchant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_unpaid ON ledger_entries(merchant_id, currency) WHERE payout_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_payouts_merchant_id ON payouts(merchant_id, created_at);
@@ -0,0 +1,4 @@
+-- Tip adjustment: restaurants and other tipping merchants capture the
+-- authorized amount plus a tip, within the card brand's tolerance
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tip_amount DECIMAL(12,2);
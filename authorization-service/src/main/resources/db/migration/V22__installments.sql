@@ -0,0 +1,8 @@
+-- Installment plans: a payment keeps the number of installments and who
+-- finances them, and settlement carries them to the clearing records
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS installment_count SMALLINT;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS installment_plan_type VARCHAR(10);
+
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS installment_count SMALLINT;
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS installment_plan_type VARCHAR(10);
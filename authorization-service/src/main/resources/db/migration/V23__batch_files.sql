@@ -0,0 +1,35 @@
+-- Batch transaction files uploaded by merchants and their records, each
+-- with its outcome once processed
+
+CREATE TABLE IF NOT EXISTS batch_files (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    file_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    format VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    record_count INTEGER NOT NULL,
+    approved_count INTEGER NOT NULL DEFAULT 0,
+    declined_count INTEGER NOT NULL DEFAULT 0,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS batch_file_records (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    batch_file_id UUID NOT NULL REFERENCES batch_files(id),
+    record_number INTEGER NOT NULL,
+    reference VARCHAR(20),
+    token VARCHAR(19) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    record_type VARCHAR(20) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    payment_id VARCHAR(50),
+    payment_status VARCHAR(20),
+    response_code VARCHAR(50),
+    message TEXT,
+    UNIQUE (batch_file_id, record_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_files_unfinished ON batch_files(created_at) WHERE status <> 'COMPLETED';
@@ -0,0 +1,8 @@
+-- Batch files dropped on the SFTP drop zone keep the name they were
+-- dropped under, and when their response file was delivered back
+
+ALTER TABLE batch_files ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'API';
+ALTER TABLE batch_files ADD COLUMN IF NOT EXISTS source_name VARCHAR(255);
+ALTER TABLE batch_files ADD COLUMN IF NOT EXISTS response_delivered_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_batch_files_undelivered ON batch_files(source, status) WHERE response_delivered_at IS NULL;
@@ -0,0 +1,32 @@
+-- Account-to-account credit transfers ingested from ISO 20022 pain.001
+-- and pacs.008 messages, one row per transaction
+
+CREATE TABLE IF NOT EXISTS credit_transfers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transfer_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    message_type VARCHAR(10) NOT NULL,
+    message_id VARCHAR(35) NOT NULL,
+    payment_info_id VARCHAR(35),
+    instruction_id VARCHAR(35),
+    end_to_end_id VARCHAR(35) NOT NULL,
+    transaction_id VARCHAR(36),
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    execution_date DATE,
+    debtor_name VARCHAR(140),
+    debtor_iban VARCHAR(34),
+    debtor_agent_bic VARCHAR(11),
+    creditor_name VARCHAR(140),
+    creditor_iban VARCHAR(34),
+    creditor_agent_bic VARCHAR(11),
+    remittance_info VARCHAR(140),
+    status VARCHAR(20) NOT NULL,
+    reason_code VARCHAR(4),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_transfers_message ON credit_transfers(merchant_id, message_id);
+CREATE INDEX IF NOT EXISTS idx_credit_transfers_end_to_end ON credit_transfers(merchant_id, end_to_end_id);
+CREATE INDEX IF NOT EXISTS idx_credit_transfers_due ON credit_transfers(execution_date) WHERE status = 'ACCEPTED';
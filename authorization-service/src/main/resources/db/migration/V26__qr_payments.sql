@@ -0,0 +1,20 @@
+-- EMVCo merchant-presented QR codes, each bound to one payment of a fixed
+-- amount
+
+CREATE TABLE IF NOT EXISTS qr_payments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    qr_payment_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    reference VARCHAR(25),
+    payload VARCHAR(512) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    payment_id VARCHAR(50),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    paid_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_qr_payments_merchant ON qr_payments(merchant_id, created_at);
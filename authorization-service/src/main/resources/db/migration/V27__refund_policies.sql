@@ -0,0 +1,8 @@
+-- Refund policies: how long after capture a merchant's payments can be
+-- refunded and how many refunds each can take, and which of these an
+-- administrator overrode for a refund
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS refund_window_days INTEGER;
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS max_refunds_per_payment INTEGER;
+
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS policy_override VARCHAR(100);
@@ -0,0 +1,8 @@
+-- Representment evidence: payments keep the issuer's CVV result next to
+-- the AVS result, and disputes carry the evidence bundle assembled when
+-- the chargeback arrived
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS cvv_result VARCHAR(1);
+
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS evidence_bundle TEXT;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS evidence_generated_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,8 @@
+-- Dispute deadlines: the issuer's decision is due by a review deadline
+-- once evidence is in, as the merchant's response is due by the deadline
+-- while the dispute is open. Both are swept for overdue disputes.
+
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS review_deadline TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_disputes_status_deadline ON disputes(status, deadline);
+CREATE INDEX IF NOT EXISTS idx_disputes_status_review_deadline ON disputes(status, review_deadline);
@@ -0,0 +1,14 @@
+-- Rolling reserves: a share of each merchant's settled sales is held on its
+-- ledger and released after a hold period. NULL uses the settlement
+-- service's defaults.
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS reserve_percentage DECIMAL(5,2);
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS reserve_hold_days INTEGER;
+
+-- A RESERVE_HOLD entry is due back at release_at, and released_at is set
+-- when its RESERVE_RELEASE entry is posted
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS release_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS released_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reserve_due ON ledger_entries(release_at)
+    WHERE entry_type = 'RESERVE_HOLD' AND released_at IS NULL;
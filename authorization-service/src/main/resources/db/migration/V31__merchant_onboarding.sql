@@ -0,0 +1,38 @@
+-- Merchant onboarding: an applicant merchant moves from DRAFT through KYC
+-- review to LIVE. Merchants that predate onboarding are live already.
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS onboarding_status VARCHAR(30) NOT NULL DEFAULT 'LIVE';
+-- Live-mode keys are only issued once onboarding is approved
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS live_api_key_hash VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS merchant_onboarding (
+    merchant_id UUID PRIMARY KEY REFERENCES merchants(id) ON DELETE CASCADE,
+    rejection_reason TEXT,
+    reviewed_by VARCHAR(50),
+    submitted_at TIMESTAMP WITH TIME ZONE,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    live_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS merchant_onboarding_fields (
+    merchant_id UUID NOT NULL REFERENCES merchant_onboarding(merchant_id) ON DELETE CASCADE,
+    field_name VARCHAR(50) NOT NULL,
+    field_value TEXT NOT NULL,
+    PRIMARY KEY (merchant_id, field_name)
+);
+
+CREATE TABLE IF NOT EXISTS merchant_onboarding_documents (
+    merchant_id UUID NOT NULL REFERENCES merchant_onboarding(merchant_id) ON DELETE CASCADE,
+    document_type VARCHAR(50) NOT NULL,
+    reference VARCHAR(255) NOT NULL,
+    uploaded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (merchant_id, document_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchants_onboarding_status ON merchants(onboarding_status)
+    WHERE onboarding_status <> 'LIVE';
+
+-- Onboarding webhooks concern a merchant, not a payment
+ALTER TABLE webhook_deliveries ALTER COLUMN payment_id DROP NOT NULL;
@@ -0,0 +1,24 @@
+-- Sanctions and denylist screening of merchants at onboarding and of
+-- cardholders per payment. A hit holds the payment's capture, or the
+-- merchant's approval, until an administrator releases or confirms it.
+
+CREATE TABLE IF NOT EXISTS screening_hits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    hit_id VARCHAR(30) UNIQUE NOT NULL,
+    subject_type VARCHAR(20) NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    payment_id VARCHAR(50),
+    screened_name VARCHAR(255),
+    screened_country VARCHAR(2),
+    provider VARCHAR(50) NOT NULL,
+    matched_entry VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    decided_by VARCHAR(50),
+    decision_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_screening_hits_status ON screening_hits(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_screening_hits_payment ON screening_hits(payment_id) WHERE payment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_screening_hits_merchant ON screening_hits(merchant_id);
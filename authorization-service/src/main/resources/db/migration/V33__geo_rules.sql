@@ -0,0 +1,12 @@
+-- Geo rules: the issuing country of each payment's card from the BIN
+-- table and whether it differs from the merchant's country, the issuing
+-- countries a merchant refuses and what it does with cross-border cards,
+-- and the cross-border markup settlement charges
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS blocked_issuer_countries VARCHAR(255);
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS cross_border_policy VARCHAR(20) NOT NULL DEFAULT 'ALLOW';
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS issuer_country VARCHAR(2);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS cross_border BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE settlement_transactions ADD COLUMN IF NOT EXISTS cross_border_fee DECIMAL(12,2);
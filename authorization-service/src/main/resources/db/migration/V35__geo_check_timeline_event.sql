@@ -0,0 +1,7 @@
+-- The BIN-country geo rule decision is recorded as a step of the
+-- payment's timeline
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS valid_event_type;
+ALTER TABLE payment_events ADD CONSTRAINT valid_event_type CHECK (event_type IN (
+    'TOKENIZATION', 'FRAUD_CHECK', 'GEO_CHECK', 'SANCTIONS_SCREENING', '3DS_AUTH', 'SURCHARGE',
+    'AUTHORIZATION_REQUEST', 'AVS_CHECK', 'AUTHORIZATION', 'CAPTURE', 'VOID', 'REFUND', 'REFUND_CREATED',
+    'REFUND_COMPLETED', 'REFUND_FAILED', 'CREDIT'));
@@ -0,0 +1,8 @@
+-- Refunds and lost chargebacks of settled payments are posted to the
+-- merchants' ledgers, taking each split's share back from its sub-merchant.
+-- Their entries reference the refund or dispute, which is posted once.
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS reference VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_payment_id ON ledger_entries(payment_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference ON ledger_entries(reference)
+    WHERE reference IS NOT NULL;
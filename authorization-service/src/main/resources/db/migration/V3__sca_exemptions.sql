@@ -0,0 +1,6 @@
+-- Strong customer authentication: exemption applied, issuer decline code
+-- (soft declines 1A/65 ask for 3DS) and the payment a resubmission repeats
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS sca_exemption VARCHAR(30);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS decline_code VARCHAR(20);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS original_payment_id VARCHAR(100);
@@ -0,0 +1,13 @@
+-- Transaction initiation indicators: channel (e-commerce or MOTO), who
+-- initiated the payment, the stored credential type, and the network
+-- transaction IDs that link merchant-initiated payments to the original
+-- customer-initiated one
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS transaction_channel VARCHAR(20) DEFAULT 'ECOMMERCE';
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS initiator VARCHAR(20) DEFAULT 'CUSTOMER';
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS stored_credential VARCHAR(20);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS network_transaction_id VARCHAR(15);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS original_network_transaction_id VARCHAR(15);
+
+CREATE INDEX IF NOT EXISTS idx_payments_network_txn
+ON payments(network_transaction_id) WHERE network_transaction_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Standalone credits (refunds without an original payment) are stored as
+-- payments of type CREDIT and are only allowed for merchants enabled for them
+
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'CREDIT';
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS standalone_credits_enabled BOOLEAN NOT NULL DEFAULT false;
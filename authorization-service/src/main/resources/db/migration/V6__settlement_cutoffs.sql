@@ -0,0 +1,6 @@
+-- Per-merchant settlement cut-offs, read by the settlement service. Captures
+-- at or after the cut-off (local time in the merchant's timezone) settle on
+-- the next business day. NULL uses the settlement service's defaults.
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS settlement_timezone VARCHAR(64);
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS settlement_cutoff_time TIME;
@@ -0,0 +1,5 @@
+-- The acquirer each payment was routed to, so captures, voids and refunds
+-- follow it, and the attempts routing made, e.g. STRIPE:TIMEOUT > ADYEN:AUTHORIZED
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS psp_name VARCHAR(50);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS routing_path VARCHAR(255);
@@ -0,0 +1,13 @@
+-- Payment timelines: each step of a payment is recorded as an event, linked
+-- to the distributed trace it ran in. Events of one operation share a
+-- correlation_id.
+
+ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS trace_id VARCHAR(32);
+
+-- The event types had fallen behind the ones refunds and credits record
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS valid_event_type;
+ALTER TABLE payment_events ADD CONSTRAINT valid_event_type CHECK (event_type IN (
+    'TOKENIZATION', 'FRAUD_CHECK', '3DS_AUTH', 'AUTHORIZATION_REQUEST', 'AUTHORIZATION',
+    'CAPTURE', 'VOID', 'REFUND', 'REFUND_CREATED', 'REFUND_COMPLETED', 'REFUND_FAILED', 'CREDIT'));
+
+CREATE INDEX IF NOT EXISTS idx_payment_events_correlation_id ON payment_events(correlation_id);
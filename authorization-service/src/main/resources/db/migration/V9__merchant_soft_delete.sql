@@ -0,0 +1,6 @@
+-- Soft deletion of merchants: a deleted merchant keeps its row, hidden from
+-- normal queries, until it is restored or purged after the restore window
+
+ALTER TABLE merchants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_merchants_deleted_at ON merchants(deleted_at) WHERE deleted_at IS NOT NULL;
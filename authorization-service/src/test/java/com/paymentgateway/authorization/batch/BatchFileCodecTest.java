@@ -0,0 +1,120 @@
+package com.paymentgateway.authorization.batch;
+
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileRecord;
+import com.paymentgateway.authorization.domain.BatchRecordOutcome;
+import com.paymentgateway.authorization.domain.BatchRecordType;
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+import java.util.List;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+
+class BatchFileCodecTest {
+    
+    private static final String TOKEN = "9411111111111111";
+    
+    @Test
+    void shouldParseCsvWithOptionalColumns() {
+        List<BatchFileRecord> records = BatchFileCodec.parse(BatchFileFormat.CSV,
+            "reference,token,amount,currency,type\n" +
+            "order-1," + TOKEN + ",12.50,USD,\n" +
+            "\n" +
+            "order-2," + TOKEN + ",500,JPY,authorization\n");
+        
+        assertThat(records).hasSize(2);
+        assertThat(records.get(0).getRecordNumber()).isEqualTo(1);
+        assertThat(records.get(0).getReference()).isEqualTo("order-1");
+        assertThat(records.get(0).getAmount()).isEqualByComparingTo("12.50");
+        assertThat(records.get(0).getRecordType()).isEqualTo(BatchRecordType.SALE);
+        assertThat(records.get(1).getRecordNumber()).isEqualTo(2);
+        assertThat(records.get(1).getCurrency()).isEqualTo("JPY");
+        assertThat(records.get(1).getRecordType()).isEqualTo(BatchRecordType.AUTHORIZATION);
+    }
+    
+    @Test
+    void shouldRejectCsvRecordsWithTheLineAtFault() {
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.CSV,
+                "token,amount,currency\n" + TOKEN + ",1.00,USD\n4111111111111111,1.00,USD\n"))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessageStartingWith("line 3: token");
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.CSV, "token,amount\n" + TOKEN + ",1.00\n"))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessage("line 1: column 'currency' required");
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.CSV, "token,amount,currency\n" + TOKEN + ",1.5,JPY\n"))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessageStartingWith("line 2: amount");
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.CSV, "token,amount,currency\n"))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessage("The file has no records");
+    }
+    
+    @Test
+    void shouldParseFixedWidthInMinorUnits() {
+        List<BatchFileRecord> records = BatchFileCodec.parse(BatchFileFormat.FIXED_WIDTH,
+            detail(1, "000000001250", "USD", 'S', "order-1") + "\n" +
+            detail(2, "000000000500", "JPY", 'A', "") + "\n" +
+            "T000002000000000001750\n");
+        
+        assertThat(records).hasSize(2);
+        assertThat(records.get(0).getToken()).isEqualTo(TOKEN);
+        assertThat(records.get(0).getAmount()).isEqualByComparingTo("12.50");
+        assertThat(records.get(0).getReference()).isEqualTo("order-1");
+        assertThat(records.get(1).getAmount()).isEqualByComparingTo("500");
+        assertThat(records.get(1).getReference()).isNull();
+        assertThat(records.get(1).getRecordType()).isEqualTo(BatchRecordType.AUTHORIZATION);
+    }
+    
+    @Test
+    void shouldRejectFixedWidthFilesThatDoNotAddUp() {
+        String detail = detail(1, "000000001250", "USD", 'S', "order-1") + "\n";
+        
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.FIXED_WIDTH, detail))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessage("trailer record required");
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.FIXED_WIDTH, detail + "T000001000000000001251\n"))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessageStartingWith("line 2: trailer count or amount total");
+        assertThatThrownBy(() -> BatchFileCodec.parse(BatchFileFormat.FIXED_WIDTH,
+                detail(2, "000000001250", "USD", 'S', "") + "\nT000001000000000001250\n"))
+            .isInstanceOf(InvalidBatchFileException.class)
+            .hasMessage("line 1: sequence number must be 000001");
+    }
+    
+    @Test
+    void shouldWriteOutcomesInTheFileFormat() {
+        BatchFileRecord approved = new BatchFileRecord(1, "order-1", TOKEN, new BigDecimal("12.50"), "USD", BatchRecordType.SALE);
+        approved.setOutcome(BatchRecordOutcome.APPROVED);
+        approved.setPaymentId("pay_1");
+        approved.setPaymentStatus("CAPTURED");
+        BatchFileRecord declined = new BatchFileRecord(2, null, TOKEN, new BigDecimal("5.00"), "USD", BatchRecordType.SALE);
+        declined.setOutcome(BatchRecordOutcome.DECLINED);
+        declined.setPaymentId("pay_2");
+        declined.setPaymentStatus("DECLINED");
+        declined.setResponseCode("51");
+        declined.setMessage("Insufficient funds, try later");
+        
+        BatchFile csv = new BatchFile("bf_1", UUID.randomUUID(), BatchFileFormat.CSV, 2);
+        assertThat(BatchFileCodec.write(csv, List.of(approved, declined))).isEqualTo(
+            "record,reference,outcome,payment_id,payment_status,response_code,message\n" +
+            "1,order-1,APPROVED,pay_1,CAPTURED,,\n" +
+            "2,,DECLINED,pay_2,DECLINED,51,\"Insufficient funds, try later\"\n");
+        
+        BatchFile fixedWidth = new BatchFile("bf_2", UUID.randomUUID(), BatchFileFormat.FIXED_WIDTH, 2);
+        fixedWidth.count(BatchRecordOutcome.APPROVED);
+        fixedWidth.count(BatchRecordOutcome.DECLINED);
+        String[] lines = BatchFileCodec.write(fixedWidth, List.of(approved, declined)).split("\n");
+        assertThat(lines).hasSize(3);
+        assertThat(lines[0]).hasSize(83).startsWith("R000001order-1             APPROVEDpay_1");
+        assertThat(lines[1].substring(63).trim()).isEqualTo("51");
+        assertThat(lines[2]).isEqualTo("T000002000001000001000000");
+    }
+    
+    private static String detail(int sequence, String minorUnits, String currency, char type, String reference) {
+        return String.format("D%06d%-19s%s%s%c%-20s", sequence, TOKEN, minorUnits, currency, type, reference);
+    }
+}
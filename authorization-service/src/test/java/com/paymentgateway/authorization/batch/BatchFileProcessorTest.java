@@ -0,0 +1,149 @@
+package com.paymentgateway.authorization.batch;
+
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileRecord;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import com.paymentgateway.authorization.domain.BatchRecordOutcome;
+import com.paymentgateway.authorization.domain.BatchRecordType;
+import com.paymentgateway.authorization.domain.PaymentStatus;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.repository.BatchFileRecordRepository;
+import com.paymentgateway.authorization.repository.BatchFileRepository;
+import com.paymentgateway.authorization.service.PaymentService;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.ArgumentCaptor;
+
+import java.math.BigDecimal;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyList;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for processing batch files record by record.
+ */
+class BatchFileProcessorTest {
+    
+    private static final String APPROVED_TOKEN = "9411111111111111";
+    private static final String DECLINED_TOKEN = "9422222222222222";
+    private static final String UNKNOWN_TOKEN = "9433333333333333";
+    
+    private BatchFileRepository fileRepository;
+    private BatchFileRecordRepository recordRepository;
+    private PaymentService paymentService;
+    private BatchTokenResolver tokenResolver;
+    private BatchFile file;
+    
+    @BeforeEach
+    void setUp() {
+        fileRepository = mock(BatchFileRepository.class);
+        recordRepository = mock(BatchFileRecordRepository.class);
+        paymentService = mock(PaymentService.class);
+        tokenResolver = mock(BatchTokenResolver.class);
+        file = new BatchFile("bf_1", UUID.randomUUID(), BatchFileFormat.CSV, 3);
+        file.setId(UUID.randomUUID());
+        when(fileRepository.findFirstByStatusInOrderByCreatedAt(anyList())).thenReturn(Optional.of(file));
+    }
+    
+    @Test
+    void shouldAuthorizeCaptureAndRecordEachOutcome() {
+        BatchFileRecord sale = record(1, APPROVED_TOKEN, BatchRecordType.SALE);
+        BatchFileRecord declined = record(2, DECLINED_TOKEN, BatchRecordType.AUTHORIZATION);
+        BatchFileRecord unknown = record(3, UNKNOWN_TOKEN, BatchRecordType.SALE);
+        when(recordRepository.findByBatchFileIdOrderByRecordNumber(file.getId()))
+            .thenReturn(List.of(sale, declined, unknown));
+        when(tokenResolver.resolve(eq(file.getMerchantId().toString()), anyList(), eq("bf_1"))).thenReturn(List.of(
+            new ResolvedCard(APPROVED_TOKEN, "4111111111111111", 12, 2030, "", ""),
+            new ResolvedCard(DECLINED_TOKEN, "4000000000000002", 12, 2030, "", ""),
+            new ResolvedCard(UNKNOWN_TOKEN, "", 0, 0, "NotFound", "token not found")));
+        when(paymentService.processPayment(any(), eq(file.getMerchantId()), eq("bf_1-1")))
+            .thenReturn(response("pay_1", PaymentStatus.AUTHORIZED, null));
+        when(paymentService.processPayment(any(), eq(file.getMerchantId()), eq("bf_1-2")))
+            .thenReturn(response("pay_2", PaymentStatus.DECLINED, "05"));
+        when(paymentService.capturePayment("pay_1")).thenReturn(response("pay_1", PaymentStatus.CAPTURED, null));
+        
+        assertThat(processor(tokenResolver).processNext()).isTrue();
+        
+        ArgumentCaptor<PaymentRequest> request = ArgumentCaptor.forClass(PaymentRequest.class);
+        verify(paymentService).processPayment(request.capture(), eq(file.getMerchantId()), eq("bf_1-1"));
+        assertThat(request.getValue().getCardNumber()).isEqualTo("4111111111111111");
+        assertThat(request.getValue().getReferenceId()).isEqualTo("ref-1");
+        verify(paymentService, never()).capturePayment("pay_2");
+        
+        assertThat(sale.getOutcome()).isEqualTo(BatchRecordOutcome.APPROVED);
+        assertThat(sale.getPaymentStatus()).isEqualTo("CAPTURED");
+        assertThat(declined.getOutcome()).isEqualTo(BatchRecordOutcome.DECLINED);
+        assertThat(declined.getResponseCode()).isEqualTo("05");
+        assertThat(unknown.getOutcome()).isEqualTo(BatchRecordOutcome.ERROR);
+        assertThat(unknown.getResponseCode()).isEqualTo("NotFound");
+        assertThat(file.getStatus()).isEqualTo(BatchFileStatus.COMPLETED);
+        assertThat(file.getCompletedAt()).isNotNull();
+        assertThat(file.getApprovedCount()).isEqualTo(1);
+        assertThat(file.getDeclinedCount()).isEqualTo(1);
+        assertThat(file.getErrorCount()).isEqualTo(1);
+    }
+    
+    @Test
+    void shouldSkipRecordsProcessedBeforeARestart() {
+        BatchFileRecord done = record(1, APPROVED_TOKEN, BatchRecordType.SALE);
+        done.setOutcome(BatchRecordOutcome.APPROVED);
+        BatchFileRecord pending = record(2, APPROVED_TOKEN, BatchRecordType.AUTHORIZATION);
+        file.setStatus(BatchFileStatus.PROCESSING);
+        when(recordRepository.findByBatchFileIdOrderByRecordNumber(file.getId())).thenReturn(List.of(done, pending));
+        when(tokenResolver.resolve(anyString(), anyList(), anyString())).thenReturn(List.of(
+            new ResolvedCard(APPROVED_TOKEN, "4111111111111111", 12, 2030, "", "")));
+        when(paymentService.processPayment(any(), any(), eq("bf_1-2")))
+            .thenReturn(response("pay_2", PaymentStatus.AUTHORIZED, null));
+        
+        processor(tokenResolver).processNext();
+        
+        verify(paymentService, never()).processPayment(any(), any(), eq("bf_1-1"));
+        verify(paymentService, never()).capturePayment(anyString());
+        assertThat(pending.getOutcome()).isEqualTo(BatchRecordOutcome.APPROVED);
+        assertThat(file.getStatus()).isEqualTo(BatchFileStatus.COMPLETED);
+    }
+    
+    @Test
+    void shouldFailRecordsWithoutATokenizationService() {
+        BatchFileRecord sale = record(1, APPROVED_TOKEN, BatchRecordType.SALE);
+        when(recordRepository.findByBatchFileIdOrderByRecordNumber(file.getId())).thenReturn(List.of(sale));
+        
+        processor(null).processNext();
+        
+        verifyNoInteractions(paymentService);
+        assertThat(sale.getOutcome()).isEqualTo(BatchRecordOutcome.ERROR);
+        assertThat(sale.getResponseCode()).isEqualTo("TOKENIZATION_UNAVAILABLE");
+        assertThat(file.getErrorCount()).isEqualTo(1);
+    }
+    
+    @Test
+    void shouldDoNothingWithoutUnfinishedFiles() {
+        when(fileRepository.findFirstByStatusInOrderByCreatedAt(anyList())).thenReturn(Optional.empty());
+        
+        assertThat(processor(tokenResolver).processNext()).isFalse();
+        verifyNoInteractions(paymentService, recordRepository);
+    }
+    
+    private BatchFileProcessor processor(BatchTokenResolver resolver) {
+        return new BatchFileProcessor(fileRepository, recordRepository, paymentService, resolver);
+    }
+    
+    private static BatchFileRecord record(int number, String token, BatchRecordType type) {
+        return new BatchFileRecord(number, "ref-" + number, token, new BigDecimal("10.00"), "USD", type);
+    }
+    
+    private static PaymentResponse response(String paymentId, PaymentStatus status, String errorCode) {
+        PaymentResponse response = new PaymentResponse(paymentId, status, new BigDecimal("10.00"), "USD");
+        response.setErrorCode(errorCode);
+        return response;
+    }
+}
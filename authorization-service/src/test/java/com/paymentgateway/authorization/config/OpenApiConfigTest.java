@@ -0,0 +1,72 @@
+package com.paymentgateway.authorization.config;
+
+import io.swagger.v3.oas.models.OpenAPI;
+import io.swagger.v3.oas.models.Operation;
+import io.swagger.v3.oas.models.PathItem;
+import io.swagger.v3.oas.models.Paths;
+import io.swagger.v3.oas.models.responses.ApiResponse;
+import io.swagger.v3.oas.models.responses.ApiResponses;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.DisplayName;
+import org.junit.jupiter.api.Test;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+/**
+ * Unit tests for OpenApiConfig: the error responses and security added to
+ * the generated document.
+ */
+class OpenApiConfigTest {
+
+    private OpenApiConfig openApiConfig;
+    private OpenAPI openApi;
+
+    @BeforeEach
+    void setUp() {
+        openApiConfig = new OpenApiConfig();
+        openApi = openApiConfig.gatewayOpenApi("https://localhost:8446");
+    }
+
+    @Test
+    @DisplayName("Should declare both authentication schemes and the error schema")
+    void shouldDeclareSecuritySchemesAndErrorSchema() {
+        assertThat(openApi.getComponents().getSecuritySchemes())
+            .containsOnlyKeys(OpenApiConfig.API_KEY_AUTH, OpenApiConfig.BEARER_AUTH);
+        assertThat(openApi.getComponents().getSecuritySchemes().get(OpenApiConfig.API_KEY_AUTH).getName())
+            .isEqualTo("X-API-Key");
+        assertThat(openApi.getComponents().getSchemas().get(OpenApiConfig.ERROR_SCHEMA).getRequired())
+            .containsExactly("error");
+        assertThat(openApi.getServers()).extracting("url").containsExactly("https://localhost:8446");
+    }
+
+    @Test
+    @DisplayName("Should add error responses to every operation without replacing documented ones")
+    void shouldAddErrorResponses() {
+        Operation createPayment = new Operation().responses(new ApiResponses()
+            .addApiResponse("201", new ApiResponse().description("Payment created"))
+            .addApiResponse("400", new ApiResponse().description("Card rejected")));
+        openApi.paths(new Paths().addPathItem("/api/v1/payments", new PathItem().post(createPayment)));
+
+        openApiConfig.errorResponseCustomizer().customise(openApi);
+
+        assertThat(createPayment.getResponses())
+            .containsKeys("201", "400", "401", "403", "429", "500", "503");
+        assertThat(createPayment.getResponses().get("400").getDescription()).isEqualTo("Card rejected");
+        assertThat(createPayment.getResponses().get("503").getContent().get("application/json").getSchema().get$ref())
+            .isEqualTo("#/components/schemas/ErrorResponse");
+        assertThat(createPayment.getResponses().get("429").getHeaders()).containsKey("Retry-After");
+        assertThat(createPayment.getSecurity()).isNull();
+    }
+
+    @Test
+    @DisplayName("Should mark login as not requiring credentials")
+    void shouldMarkLoginAsPublic() {
+        Operation login = new Operation();
+        openApi.paths(new Paths().addPathItem("/api/v1/auth/login", new PathItem().post(login)));
+
+        openApiConfig.errorResponseCustomizer().customise(openApi);
+
+        assertThat(login.getSecurity()).isEmpty();
+        assertThat(login.getResponses()).containsKey("401");
+    }
+}
@@ -0,0 +1,31 @@
+package com.paymentgateway.authorization.domain;
+
+import org.junit.jupiter.api.Test;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class AvsPolicyTest {
+    
+    @Test
+    void shouldDeclineOnlyWhatThePolicyRejects() {
+        assertThat(AvsPolicy.NONE.accepts("N")).isTrue();
+        
+        assertThat(AvsPolicy.DECLINE_NO_MATCH.accepts("A")).isTrue();
+        assertThat(AvsPolicy.DECLINE_NO_MATCH.accepts("Z")).isTrue();
+        assertThat(AvsPolicy.DECLINE_NO_MATCH.accepts("N")).isFalse();
+        
+        assertThat(AvsPolicy.REQUIRE_ZIP_MATCH.accepts("Z")).isTrue();
+        assertThat(AvsPolicy.REQUIRE_ZIP_MATCH.accepts("A")).isFalse();
+        
+        assertThat(AvsPolicy.REQUIRE_FULL_MATCH.accepts("Y")).isTrue();
+        assertThat(AvsPolicy.REQUIRE_FULL_MATCH.accepts("Z")).isFalse();
+    }
+    
+    @Test
+    void shouldNeverDeclineWithoutAnAddressToCompare() {
+        for (AvsPolicy policy : AvsPolicy.values()) {
+            assertThat(policy.accepts("U")).isTrue();
+            assertThat(policy.accepts(null)).isTrue();
+        }
+    }
+}
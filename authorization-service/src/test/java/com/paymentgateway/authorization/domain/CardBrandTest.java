@@ -0,0 +1,23 @@
+package com.paymentgateway.authorization.domain;
+
+import org.junit.jupiter.api.Test;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class CardBrandTest {
+    
+    @Test
+    void shouldRecognizeTheBrandFromThePan() {
+        assertThat(CardBrand.fromPan("4111111111111111")).isEqualTo(CardBrand.VISA);
+        assertThat(CardBrand.fromPan("5555555555554444")).isEqualTo(CardBrand.MASTERCARD);
+        assertThat(CardBrand.fromPan("2221000000000009")).isEqualTo(CardBrand.MASTERCARD);
+        assertThat(CardBrand.fromPan("378282246310005")).isEqualTo(CardBrand.AMEX);
+        assertThat(CardBrand.fromPan("6011111111111117")).isEqualTo(CardBrand.DISCOVER);
+        assertThat(CardBrand.fromPan("3530111333300000")).isEqualTo(CardBrand.JCB);
+        assertThat(CardBrand.fromPan("36227206271667")).isEqualTo(CardBrand.DINERS);
+        assertThat(CardBrand.fromPan("6200000000000005")).isEqualTo(CardBrand.UNIONPAY);
+        // Unknown ranges stay Visa
+        assertThat(CardBrand.fromPan("9999999999999995")).isEqualTo(CardBrand.VISA);
+        assertThat(CardBrand.fromPan(null)).isEqualTo(CardBrand.VISA);
+    }
+}
@@ -0,0 +1,73 @@
+package com.paymentgateway.authorization.enrichment;
+
+import com.paymentgateway.authorization.batch.ResolvedCard;
+import com.paymentgateway.authorization.geo.BinTable;
+import org.junit.jupiter.api.Test;
+
+import java.util.ArrayList;
+import java.util.List;
+
+import static org.assertj.core.api.Assertions.*;
+
+class CardEnrichmentServiceTest {
+    
+    private static final String TOKEN = "9123456789010366";
+    
+    private final BinTable binTable = new BinTable(
+        "2-6:US, 400000826:GB, 4000008:GB:Simulator Bank UK:Visa Prepaid:prepaid, 555555:US:Simulator Bank:World Mastercard:CREDIT");
+    private final List<String> references = new ArrayList<>();
+    
+    @Test
+    void shouldEnrichABinFromTheBinTable() {
+        CardEnrichmentService service = new CardEnrichmentService(binTable, null, "https://art.test/");
+        
+        CardEnrichment card = service.forBin("555555");
+        
+        assertThat(card.getBin()).isEqualTo("555555");
+        assertThat(card.getBrand()).isEqualTo("MASTERCARD");
+        assertThat(card.getIssuerName()).isEqualTo("Simulator Bank");
+        assertThat(card.getIssuerCountry()).isEqualTo("US");
+        assertThat(card.getProduct()).isEqualTo("World Mastercard");
+        assertThat(card.isDebit()).isFalse();
+        assertThat(card.getCardArtUrl()).isEqualTo("https://art.test/mastercard/world-mastercard.png");
+        assertThat(card.getCardArtThumbnailUrl()).isEqualTo("https://art.test/mastercard/world-mastercard-thumb.png");
+    }
+    
+    @Test
+    void shouldFallBackToTheBrandForUnnamedIssuers() {
+        CardEnrichment card = new CardEnrichmentService(binTable, null, "https://art.test").forBin("411111");
+        
+        assertThat(card.getIssuerName()).isNull();
+        assertThat(card.getFunding()).isNull();
+        assertThat(card.getIssuerCountry()).isEqualTo("US");
+        assertThat(card.getCardArtUrl()).isEqualTo("https://art.test/visa/standard.png");
+    }
+    
+    @Test
+    void shouldEnrichATokenByItsCardsBin() {
+        CardEnrichmentService service = new CardEnrichmentService(binTable, (merchantId, tokens, reference) -> {
+            references.add(reference);
+            return List.of(new ResolvedCard(tokens.get(0), "4000008260000000", 12, 2030, "", ""));
+        }, "https://art.test");
+        
+        CardEnrichment card = service.forToken("merchant-1", TOKEN);
+        
+        assertThat(card.getBin()).isEqualTo("400000");
+        assertThat(card.getIssuerName()).isEqualTo("Simulator Bank UK");
+        assertThat(card.getIssuerCountry()).isEqualTo("GB");
+        assertThat(card.isPrepaid()).isTrue();
+        assertThat(references).containsExactly(CardEnrichmentService.AUDIT_REFERENCE);
+    }
+    
+    @Test
+    void shouldReportTokensTheVaultCannotResolve() {
+        CardEnrichmentService service = new CardEnrichmentService(binTable, (merchantId, tokens, reference) ->
+            List.of(new ResolvedCard(tokens.get(0), "", 0, 0, "NotFound", "token not found")), "https://art.test");
+        
+        assertThatThrownBy(() -> service.forToken("merchant-1", TOKEN))
+            .isInstanceOf(CardEnrichmentException.class)
+            .extracting("code").isEqualTo("TOKEN_NOT_FOUND");
+        assertThatThrownBy(() -> new CardEnrichmentService(binTable, null, "https://art.test").forToken("merchant-1", TOKEN))
+            .extracting("code").isEqualTo("TOKEN_LOOKUP_UNAVAILABLE");
+    }
+}
@@ -1,6 +1,9 @@
 package com.paymentgateway.authorization.event;
 
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.fasterxml.jackson.datatype.jsr310.JavaTimeModule;
 import com.paymentgateway.authorization.domain.*;
+import com.paymentgateway.authorization.outbox.OutboxRepository;
 import io.opentelemetry.api.trace.Tracer;
 import org.junit.jupiter.api.BeforeEach;
 import org.junit.jupiter.api.Test;
@@ -15,6 +18,7 @@ import java.util.concurrent.CompletableFuture;
 import static org.assertj.core.api.Assertions.assertThat;
 import static org.mockito.ArgumentMatchers.any;
 import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.ArgumentMatchers.startsWith;
 import static org.mockito.Mockito.*;
 
 /**
@@ -186,6 +190,31 @@ class KafkaIntegrationTest {
         assertThat(payload.getThreeDsStatus()).isEqualTo(payment.getThreeDsStatus().name());
     }
     
+    @Test
+    void shouldQueueEventInOutboxInsteadOfSending() throws Exception {
+        // Given
+        OutboxRepository outbox = mock(OutboxRepository.class);
+        ObjectMapper objectMapper = new ObjectMapper();
+        objectMapper.registerModule(new JavaTimeModule());
+        PaymentEventPublisher outboxPublisher = new PaymentEventPublisher(kafkaTemplate, tracer, outbox, objectMapper);
+        Payment payment = createTestPayment();
+        payment.setId(UUID.randomUUID());
+        payment.setStatus(PaymentStatus.CAPTURED);
+        
+        // When
+        outboxPublisher.publishPaymentEvent(payment, PaymentEventType.PAYMENT_CAPTURED);
+        
+        // Then
+        ArgumentCaptor<String> payloadCaptor = ArgumentCaptor.forClass(String.class);
+        verify(outbox).append(startsWith("evt_"), eq("PAYMENT_CAPTURED"), eq(payment.getPaymentId()),
+                eq(payment.getId()), eq(payment.getMerchantId()), payloadCaptor.capture());
+        verify(kafkaTemplate, never()).send(anyString(), anyString(), any(PaymentEventMessage.class));
+        
+        PaymentEventMessage queued = objectMapper.readValue(payloadCaptor.getValue(), PaymentEventMessage.class);
+        assertThat(queued.getEventType()).isEqualTo(PaymentEventType.PAYMENT_CAPTURED);
+        assertThat(queued.getPayload().getPaymentId()).isEqualTo(payment.getPaymentId());
+    }
+    
     private Payment createTestPayment() {
         Payment payment = new Payment();
         payment.setPaymentId("pay_test123456789012345678");
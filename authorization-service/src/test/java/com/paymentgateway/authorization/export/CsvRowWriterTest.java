@@ -0,0 +1,47 @@
+package com.paymentgateway.authorization.export;
+
+import org.junit.jupiter.api.Test;
+
+import java.io.ByteArrayOutputStream;
+import java.math.BigDecimal;
+import java.nio.charset.StandardCharsets;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.List;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class CsvRowWriterTest {
+    
+    private static final List<ExportColumn> COLUMNS = List.of(
+        ExportColumn.string("payment_id"),
+        ExportColumn.decimal("amount"),
+        ExportColumn.timestamp("created_at"),
+        ExportColumn.date("settlement_date"),
+        ExportColumn.string("note"));
+    
+    @Test
+    void shouldWriteHeaderAndFormattedRows() throws Exception {
+        ByteArrayOutputStream out = new ByteArrayOutputStream();
+        CsvRowWriter writer = new CsvRowWriter(COLUMNS, out);
+        
+        writer.write(new Object[] {"pay_1", new BigDecimal("1E+1").setScale(2),
+            Instant.parse("2026-10-15T08:30:00Z"), LocalDate.of(2026, 10, 16), null});
+        writer.write(new Object[] {"pay_2", new BigDecimal("5.50"), null, null, "says \"hi\", twice"});
+        writer.finish();
+        
+        assertThat(out.toString(StandardCharsets.UTF_8)).isEqualTo(
+            "payment_id,amount,created_at,settlement_date,note\r\n" +
+            "pay_1,10.00,2026-10-15T08:30:00Z,2026-10-16,\r\n" +
+            "pay_2,5.50,,,\"says \"\"hi\"\", twice\"\r\n");
+    }
+    
+    @Test
+    void shouldWriteHeaderForEmptyExport() throws Exception {
+        ByteArrayOutputStream out = new ByteArrayOutputStream();
+        new CsvRowWriter(COLUMNS, out).finish();
+        
+        assertThat(out.toString(StandardCharsets.UTF_8))
+            .isEqualTo("payment_id,amount,created_at,settlement_date,note\r\n");
+    }
+}
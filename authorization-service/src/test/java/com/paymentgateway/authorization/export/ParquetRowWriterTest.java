@@ -0,0 +1,195 @@
+package com.paymentgateway.authorization.export;
+
+import com.paymentgateway.authorization.export.ParquetRowWriter.ThriftCompactWriter;
+import org.apache.parquet.column.page.PageReadStore;
+import org.apache.parquet.example.data.Group;
+import org.apache.parquet.example.data.simple.convert.GroupRecordConverter;
+import org.apache.parquet.hadoop.ParquetFileReader;
+import org.apache.parquet.hadoop.metadata.FileMetaData;
+import org.apache.parquet.io.ColumnIOFactory;
+import org.apache.parquet.io.LocalInputFile;
+import org.apache.parquet.io.RecordReader;
+import org.apache.parquet.schema.LogicalTypeAnnotation;
+import org.apache.parquet.schema.MessageType;
+import org.apache.parquet.schema.PrimitiveType.PrimitiveTypeName;
+import org.apache.parquet.schema.Type.Repetition;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.io.TempDir;
+
+import java.io.ByteArrayOutputStream;
+import java.math.BigDecimal;
+import java.nio.ByteBuffer;
+import java.nio.ByteOrder;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.List;
+import java.util.Map;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class ParquetRowWriterTest {
+    
+    @TempDir
+    Path dir;
+    
+    @Test
+    void shouldBeReadableByTheReferenceReader() throws Exception {
+        List<ExportColumn> columns = List.of(
+            ExportColumn.string("payment_id"),
+            ExportColumn.decimal("amount"),
+            ExportColumn.integer("attempts"),
+            ExportColumn.timestamp("created_at"),
+            ExportColumn.date("settlement_date"));
+        Instant createdAt = Instant.parse("2024-03-01T12:34:56.789Z");
+        LocalDate settled = LocalDate.of(2024, 3, 2);
+        Path file = dir.resolve("transactions.parquet");
+        Files.write(file, write(columns, 2,
+            new Object[] {"pay_1", new BigDecimal("12.34"), 1L, createdAt, settled},
+            new Object[] {"pay_2", null, 2L, createdAt, null},
+            new Object[] {null, new BigDecimal("0.05"), null, null, settled}));
+        
+        try (ParquetFileReader reader = ParquetFileReader.open(new LocalInputFile(file))) {
+            FileMetaData metadata = reader.getFooter().getFileMetaData();
+            assertThat(metadata.getKeyValueMetaData())
+                .containsEntry("dataset", "transactions")
+                .containsEntry("schema_version", "1");
+            assertThat(metadata.getCreatedBy()).isEqualTo("payment-acquiring-gateway");
+            
+            MessageType schema = metadata.getSchema();
+            assertThat(schema.getFields()).extracting(f -> f.getName())
+                .containsExactly("payment_id", "amount", "attempts", "created_at", "settlement_date");
+            assertThat(schema.getFields()).allSatisfy(f -> assertThat(f.getRepetition()).isEqualTo(Repetition.OPTIONAL));
+            assertColumn(schema, "payment_id", PrimitiveTypeName.BINARY, LogicalTypeAnnotation.stringType());
+            assertColumn(schema, "amount", PrimitiveTypeName.INT64,
+                LogicalTypeAnnotation.decimalType(ExportColumn.DECIMAL_SCALE, ExportColumn.DECIMAL_PRECISION));
+            assertColumn(schema, "attempts", PrimitiveTypeName.INT64, null);
+            assertColumn(schema, "created_at", PrimitiveTypeName.INT64,
+                LogicalTypeAnnotation.timestampType(true, LogicalTypeAnnotation.TimeUnit.MILLIS));
+            assertColumn(schema, "settlement_date", PrimitiveTypeName.INT32, LogicalTypeAnnotation.dateType());
+            
+            // Two row groups, the second holding the third row
+            List<Group> rows = new ArrayList<>();
+            PageReadStore pages;
+            while ((pages = reader.readNextRowGroup()) != null) {
+                RecordReader<Group> records = new ColumnIOFactory().getColumnIO(schema)
+                    .getRecordReader(pages, new GroupRecordConverter(schema));
+                for (long i = 0; i < pages.getRowCount(); i++) {
+                    rows.add(records.read());
+                }
+            }
+            assertThat(reader.getRowGroups()).hasSize(2);
+            assertThat(rows).hasSize(3);
+            
+            Group first = rows.get(0);
+            assertThat(first.getString("payment_id", 0)).isEqualTo("pay_1");
+            assertThat(first.getLong("amount", 0)).isEqualTo(1234L);
+            assertThat(first.getLong("attempts", 0)).isEqualTo(1L);
+            assertThat(first.getLong("created_at", 0)).isEqualTo(createdAt.toEpochMilli());
+            assertThat(first.getInteger("settlement_date", 0)).isEqualTo((int) settled.toEpochDay());
+            
+            Group second = rows.get(1);
+            assertThat(second.getString("payment_id", 0)).isEqualTo("pay_2");
+            assertThat(second.getFieldRepetitionCount("amount")).isZero();
+            assertThat(second.getFieldRepetitionCount("settlement_date")).isZero();
+            
+            Group third = rows.get(2);
+            assertThat(third.getFieldRepetitionCount("payment_id")).isZero();
+            assertThat(third.getLong("amount", 0)).isEqualTo(5L);
+            assertThat(third.getFieldRepetitionCount("attempts")).isZero();
+            assertThat(third.getFieldRepetitionCount("created_at")).isZero();
+        }
+    }
+    
+    @Test
+    void shouldFrameFileWithMagicAndFooterLength() throws Exception {
+        byte[] file = write(List.of(ExportColumn.string("payment_id")), 10);
+        
+        assertThat(Arrays.copyOfRange(file, 0, 4)).isEqualTo(magic());
+        assertThat(Arrays.copyOfRange(file, file.length - 4, file.length)).isEqualTo(magic());
+        int footerLength = ByteBuffer.wrap(file, file.length - 8, 4).order(ByteOrder.LITTLE_ENDIAN).getInt();
+        assertThat(footerLength).isEqualTo(file.length - 12);
+        String footer = new String(file, 4, footerLength, StandardCharsets.ISO_8859_1);
+        assertThat(footer).contains("payment_id", "schema_version", "transactions", "payment-acquiring-gateway");
+    }
+    
+    @Test
+    void shouldEncodeNullsAsDefinitionLevelsAndValuesPlain() throws Exception {
+        byte[] file = write(List.of(ExportColumn.string("note")), 10,
+            new Object[] {"a"}, new Object[] {null}, new Object[] {"bc"});
+        
+        // Definition levels 1,0,1 as one bit-packed group, then two strings
+        byte[] page = {
+            2, 0, 0, 0, 0x03, 0x05,
+            1, 0, 0, 0, 'a',
+            2, 0, 0, 0, 'b', 'c'};
+        assertThat(indexOf(file, page)).isPositive();
+    }
+    
+    @Test
+    void shouldEncodeDecimalsAsUnscaledLongs() throws Exception {
+        byte[] file = write(List.of(ExportColumn.decimal("amount")), 10, new Object[] {new BigDecimal("12.34")});
+        
+        byte[] page = {2, 0, 0, 0, 0x03, 0x01, (byte) 0xD2, 0x04, 0, 0, 0, 0, 0, 0};
+        assertThat(indexOf(file, page)).isPositive();
+    }
+    
+    @Test
+    void shouldStartNewRowGroupWhenFull() throws Exception {
+        byte[] file = write(List.of(ExportColumn.integer("n")), 2,
+            new Object[] {1L}, new Object[] {2L}, new Object[] {3L});
+        
+        // A full group of two values and a group of one
+        assertThat(indexOf(file, new byte[] {2, 0, 0, 0, 0x03, 0x03, 1, 0, 0, 0, 0, 0, 0, 0, 2})).isPositive();
+        assertThat(indexOf(file, new byte[] {2, 0, 0, 0, 0x03, 0x01, 3, 0, 0, 0, 0, 0, 0, 0})).isPositive();
+    }
+    
+    @Test
+    void shouldWriteThriftCompactFieldHeaders() {
+        ThriftCompactWriter thrift = new ThriftCompactWriter();
+        thrift.i32Field(1, 0);
+        thrift.i32Field(20, -1);
+        thrift.i64Field(21, 300);
+        thrift.endStruct();
+        
+        assertThat(thrift.toByteArray()).containsExactly(
+            0x15, 0x00,
+            0x05, 0x28, 0x01,
+            0x16, (byte) 0xD8, 0x04,
+            0x00);
+    }
+    
+    private static byte[] write(List<ExportColumn> columns, int rowGroupSize, Object[]... rows) throws Exception {
+        ByteArrayOutputStream out = new ByteArrayOutputStream();
+        ParquetRowWriter writer = new ParquetRowWriter(columns, out,
+                Map.of("dataset", "transactions", "schema_version", "1"), rowGroupSize);
+        for (Object[] row : rows) {
+            writer.write(row);
+        }
+        writer.finish();
+        return out.toByteArray();
+    }
+    
+    private static void assertColumn(MessageType schema, String name, PrimitiveTypeName type,
+                                     LogicalTypeAnnotation logicalType) {
+        assertThat(schema.getType(name).asPrimitiveType().getPrimitiveTypeName()).isEqualTo(type);
+        assertThat(schema.getType(name).getLogicalTypeAnnotation()).isEqualTo(logicalType);
+    }
+    
+    private static byte[] magic() {
+        return "PAR1".getBytes(StandardCharsets.US_ASCII);
+    }
+    
+    private static int indexOf(byte[] haystack, byte[] needle) {
+        for (int i = 0; i <= haystack.length - needle.length; i++) {
+            if (Arrays.equals(haystack, i, i + needle.length, needle, 0, needle.length)) {
+                return i;
+            }
+        }
+        return -1;
+    }
+}
@@ -0,0 +1,168 @@
+package com.paymentgateway.authorization.fixtures;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.PSPConfiguration;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
+import com.paymentgateway.authorization.psp.CardFingerprint;
+import com.paymentgateway.authorization.psp.SimulatedIssuer;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.repository.PSPConfigurationRepository;
+import com.paymentgateway.authorization.repository.WebhookEndpointRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.ArgumentCaptor;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+import org.springframework.security.crypto.bcrypt.BCryptPasswordEncoder;
+import org.springframework.security.crypto.password.PasswordEncoder;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.Set;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+class FixtureServiceTest {
+    
+    private static final String DOCUMENT = """
+        merchants:
+          - merchantId: demo_store
+            name: Demo Store
+            apiKey: sk_demo_store_0000000000
+            psps: [ADYEN, STRIPE]
+            webhooks:
+              - url: http://localhost:9000/webhooks
+                secret: whsec_demo_store_000000
+        testCards:
+          - pan: "4111111111111111"
+            balance: 250
+        """;
+    
+    @Mock
+    private MerchantRepository merchantRepository;
+    
+    @Mock
+    private PSPConfigurationRepository pspConfigurationRepository;
+    
+    @Mock
+    private WebhookEndpointRepository endpointRepository;
+    
+    private final PasswordEncoder passwordEncoder = new BCryptPasswordEncoder(4);
+    private final SimulatedIssuer simulatedIssuer = new SimulatedIssuer("");
+    private FixtureService fixtureService;
+    
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        fixtureService = new FixtureService(merchantRepository, pspConfigurationRepository, endpointRepository,
+                passwordEncoder, simulatedIssuer);
+        when(merchantRepository.findByMerchantId(anyString())).thenReturn(Optional.empty());
+        when(merchantRepository.findDeletedByMerchantId(anyString())).thenReturn(Optional.empty());
+        when(merchantRepository.save(any(Merchant.class))).thenAnswer(inv -> {
+            Merchant merchant = inv.getArgument(0);
+            if (merchant.getId() == null) {
+                merchant.setId(UUID.randomUUID());
+            }
+            return merchant;
+        });
+    }
+    
+    @Test
+    void shouldCreateDeclaredMerchantWithKeyRoutesAndActiveWebhook() {
+        Map<String, Integer> counts = fixtureService.apply(FixtureSet.fromYaml(DOCUMENT));
+        
+        ArgumentCaptor<Merchant> merchant = ArgumentCaptor.forClass(Merchant.class);
+        verify(merchantRepository).save(merchant.capture());
+        assertThat(merchant.getValue().getMerchantId()).isEqualTo("demo_store");
+        assertThat(merchant.getValue().getRoles()).containsExactly("MERCHANT");
+        assertThat(passwordEncoder.matches("sk_demo_store_0000000000", merchant.getValue().getApiKeyHash())).isTrue();
+        
+        ArgumentCaptor<PSPConfiguration> psps = ArgumentCaptor.forClass(PSPConfiguration.class);
+        verify(pspConfigurationRepository, times(2)).save(psps.capture());
+        assertThat(psps.getAllValues()).extracting(PSPConfiguration::getPspName).containsExactly("ADYEN", "STRIPE");
+        assertThat(psps.getAllValues()).extracting(PSPConfiguration::getPriority).containsExactly(1, 2);
+        
+        ArgumentCaptor<WebhookEndpoint> endpoint = ArgumentCaptor.forClass(WebhookEndpoint.class);
+        verify(endpointRepository).save(endpoint.capture());
+        assertThat(endpoint.getValue().getStatus()).isEqualTo(WebhookEndpoint.ACTIVE);
+        assertThat(endpoint.getValue().getSigningSecret()).isEqualTo("whsec_demo_store_000000");
+        
+        assertThat(simulatedIssuer.getOpenToBuy(CardFingerprint.of("4111111111111111")))
+            .isEqualByComparingTo(new BigDecimal("250"));
+        assertThat(counts).containsEntry("merchantsCreated", 1).containsEntry("apiKeysSet", 1)
+            .containsEntry("pspRoutesSet", 1).containsEntry("webhookEndpointsCreated", 1)
+            .containsEntry("testCards", 1);
+    }
+    
+    @Test
+    void shouldWriteNothingWhenAlreadyApplied() {
+        Merchant existing = new Merchant("demo_store", "Demo Store");
+        existing.setId(UUID.randomUUID());
+        existing.setMcc("5999");
+        existing.setCountryCode("US");
+        existing.setCurrency("USD");
+        existing.setRiskLevel("LOW");
+        existing.setRoles(Set.of("MERCHANT"));
+        existing.setApiKeyHash(passwordEncoder.encode("sk_demo_store_0000000000"));
+        when(merchantRepository.findByMerchantId("demo_store")).thenReturn(Optional.of(existing));
+        when(pspConfigurationRepository.findByMerchantIdOrderByPriorityAsc(existing.getId())).thenReturn(List.of(
+            new PSPConfiguration(existing.getId(), "ADYEN", 1), new PSPConfiguration(existing.getId(), "STRIPE", 2)));
+        WebhookEndpoint endpoint = new WebhookEndpoint(existing.getId(), "http://localhost:9000/webhooks",
+                "whsec_demo_store_000000");
+        endpoint.setStatus(WebhookEndpoint.ACTIVE);
+        when(endpointRepository.findByMerchantIdAndStatusNotOrderByCreatedAtAsc(existing.getId(), WebhookEndpoint.DISABLED))
+            .thenReturn(List.of(endpoint));
+        
+        Map<String, Integer> counts = fixtureService.apply(FixtureSet.fromYaml(DOCUMENT));
+        
+        verify(merchantRepository, never()).save(any());
+        verify(pspConfigurationRepository, never()).save(any());
+        verify(endpointRepository, never()).save(any());
+        assertThat(counts).containsEntry("merchantsCreated", 0).containsEntry("merchantsUpdated", 0)
+            .containsEntry("apiKeysSet", 0).containsEntry("webhookEndpointsUpdated", 0);
+    }
+    
+    @Test
+    void shouldRestoreDeletedMerchantAndResetChangedSecret() {
+        Merchant deleted = new Merchant("demo_store", "Old Name");
+        deleted.setId(UUID.randomUUID());
+        deleted.setDeletedAt(Instant.now());
+        when(merchantRepository.findDeletedByMerchantId("demo_store")).thenReturn(Optional.of(deleted));
+        WebhookEndpoint endpoint = new WebhookEndpoint(deleted.getId(), "http://localhost:9000/webhooks",
+                "whsec_rotated_by_merchant_1");
+        endpoint.setStatus(WebhookEndpoint.ACTIVE);
+        endpoint.setPreviousSigningSecret("whsec_older_secret_000000");
+        when(endpointRepository.findByMerchantIdAndStatusNotOrderByCreatedAtAsc(eq(deleted.getId()), any()))
+            .thenReturn(List.of(endpoint));
+        
+        Map<String, Integer> counts = fixtureService.apply(FixtureSet.fromYaml(DOCUMENT));
+        
+        assertThat(deleted.getDeletedAt()).isNull();
+        assertThat(deleted.getMerchantName()).isEqualTo("Demo Store");
+        assertThat(endpoint.getSigningSecret()).isEqualTo("whsec_demo_store_000000");
+        assertThat(endpoint.getPreviousSigningSecret()).isNull();
+        assertThat(counts).containsEntry("merchantsUpdated", 1).containsEntry("webhookEndpointsUpdated", 1);
+    }
+    
+    @Test
+    void shouldRefuseSandboxMerchant() {
+        Merchant sandboxed = new Merchant("demo_store", "Demo Store");
+        sandboxed.setId(UUID.randomUUID());
+        sandboxed.setSandboxId(UUID.randomUUID());
+        when(merchantRepository.findByMerchantId("demo_store")).thenReturn(Optional.of(sandboxed));
+        
+        assertThatThrownBy(() -> fixtureService.apply(FixtureSet.fromYaml(DOCUMENT)))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("belongs to a sandbox");
+        verify(merchantRepository, never()).save(any());
+    }
+}
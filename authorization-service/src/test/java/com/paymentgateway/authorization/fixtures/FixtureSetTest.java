@@ -0,0 +1,226 @@
+package com.paymentgateway.authorization.fixtures;
+
+import com.paymentgateway.authorization.domain.AvsPolicy;
+import com.paymentgateway.authorization.fixtures.FixtureSet.MerchantFixture;
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+
+class FixtureSetTest {
+    
+    @Test
+    void shouldParseMerchantsCardsAndRules() {
+        FixtureSet fixtures = FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: demo_store
+                name: Demo Store
+                mcc: "5411"
+                currency: eur
+                apiKey: sk_demo_store_0000000000
+                psps: [adyen, STRIPE]
+                avsPolicy: require_zip_match
+                descriptor: demo  store
+                surchargePercent: 2.5
+                convenienceFee: 1.95
+                webhooks:
+                  - url: http://localhost:9000/webhooks
+                    secret: whsec_demo_store_000000
+              - merchantId: demo_admin
+                roles: [ADMIN]
+            testCards:
+              - pan: "4111111111111111"
+                balance: 500.00
+                billingAddress:
+                  street: 1 Main Street
+                  postalCode: "94105"
+            rules:
+              - name: SIM_DECLINE_MAGIC_AMOUNT
+                when: amount = 66.66
+                decision: BLOCK
+            """);
+        
+        assertThat(fixtures.getMerchants()).hasSize(2);
+        MerchantFixture store = fixtures.getMerchants().get(0);
+        assertThat(store.getName()).isEqualTo("Demo Store");
+        assertThat(store.getMcc()).isEqualTo("5411");
+        assertThat(store.getCountry()).isEqualTo("US");
+        assertThat(store.getCurrency()).isEqualTo("EUR");
+        assertThat(store.getRoles()).containsExactly("MERCHANT");
+        assertThat(store.getPsps()).containsExactly("ADYEN", "STRIPE");
+        assertThat(store.getWebhooks()).hasSize(1);
+        assertThat(store.getStandaloneCredits()).isNull();
+        assertThat(store.getAvsPolicy()).isEqualTo(AvsPolicy.REQUIRE_ZIP_MATCH);
+        assertThat(store.getDescriptor()).isEqualTo("DEMO STORE");
+        assertThat(store.getSurchargePercent()).isEqualByComparingTo(new BigDecimal("2.5"));
+        assertThat(store.getConvenienceFee()).isEqualByComparingTo(new BigDecimal("1.95"));
+        
+        MerchantFixture admin = fixtures.getMerchants().get(1);
+        assertThat(admin.getName()).isEqualTo("demo_admin");
+        assertThat(admin.getRoles()).containsExactly("ADMIN");
+        assertThat(admin.getApiKey()).isNull();
+        assertThat(admin.getAvsPolicy()).isNull();
+        assertThat(admin.getDescriptor()).isNull();
+        assertThat(admin.getSurchargePercent()).isNull();
+        
+        assertThat(fixtures.getTestCards()).hasSize(1);
+        assertThat(fixtures.getTestCards().get(0).getBalance()).isEqualByComparingTo(new BigDecimal("500"));
+        assertThat(fixtures.getTestCards().get(0).getStreet()).isEqualTo("1 Main Street");
+        assertThat(fixtures.getTestCards().get(0).getPostalCode()).isEqualTo("94105");
+        assertThat(fixtures.getRuleCount()).isEqualTo(1);
+    }
+    
+    @Test
+    void shouldAcceptJsonAndEmptyDocuments() {
+        assertThat(FixtureSet.fromYaml("{\"merchants\": [{\"merchantId\": \"m1\"}]}").getMerchants()).hasSize(1);
+        assertThat(FixtureSet.fromYaml("").getMerchants()).isEmpty();
+    }
+    
+    @Test
+    void shouldRejectDuplicateMerchants() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: m1
+              - merchantId: m1
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("duplicate merchantId m1");
+    }
+    
+    @Test
+    void shouldRejectUnknownPsp() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: m1
+                psps: [WORLDPAY]
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("unknown PSP WORLDPAY");
+    }
+    
+    @Test
+    void shouldRejectUnknownAvsPolicy() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: m1
+                avsPolicy: STRICT
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("unknown avsPolicy STRICT");
+    }
+    
+    @Test
+    void shouldRejectInvalidDescriptor() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: m1
+                descriptor: DEMO*STORE
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("'descriptor'");
+    }
+    
+    @Test
+    void shouldRejectSurchargeAboveTenPercent() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: m1
+                surchargePercent: 12
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("'surchargePercent' must be above 0 and at most 10");
+    }
+    
+    @Test
+    void shouldRequirePlatformDeclaredBeforeSubMerchant() {
+        assertThat(FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: market
+              - merchantId: seller
+                platform: market
+            """).getMerchants().get(1).getPlatform()).isEqualTo("market");
+        
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: seller
+                platform: market
+              - merchantId: market
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("'platform' must name a merchant declared before it");
+    }
+    
+    @Test
+    void shouldRequireWebhookSecret() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            merchants:
+              - merchantId: m1
+                webhooks:
+                  - url: https://example.com/hooks
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("merchant m1: webhook 1: 'secret' is required");
+    }
+    
+    @Test
+    void shouldRejectInvalidTestCard() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            testCards:
+              - pan: "4111"
+                balance: 10
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("test card 1");
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            testCards:
+              - pan: "4111111111111111"
+                balance: lots
+            """))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageContaining("'balance' must be a number");
+    }
+    
+    @Test
+    void shouldParseTestCardInstallmentPlans() {
+        FixtureSet fixtures = FixtureSet.fromYaml("""
+            testCards:
+              - pan: "4111111111111111"
+                balance: 500
+                installments:
+                  plans: [merchant, ISSUER]
+                  maxCount: 12
+            """);
+        
+        assertThat(fixtures.getTestCards().get(0).getInstallmentPlans()).containsExactly("MERCHANT", "ISSUER");
+        assertThat(fixtures.getTestCards().get(0).getMaxInstallments()).isEqualTo(12);
+        
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            testCards:
+              - pan: "4111111111111111"
+                balance: 500
+                installments:
+                  plans: [LAYAWAY]
+                  maxCount: 12
+            """))
+            .hasMessageContaining("unknown plan LAYAWAY");
+        assertThatThrownBy(() -> FixtureSet.fromYaml("""
+            testCards:
+              - pan: "4111111111111111"
+                balance: 500
+                installments:
+                  plans: [ISSUER]
+            """))
+            .hasMessageContaining("'maxCount' must be between 2 and 99");
+    }
+    
+    @Test
+    void shouldRejectMalformedYaml() {
+        assertThatThrownBy(() -> FixtureSet.fromYaml("merchants: [unclosed"))
+            .isInstanceOf(InvalidFixtureException.class)
+            .hasMessageStartingWith("invalid YAML");
+        assertThatThrownBy(() -> FixtureSet.fromYaml("- just\n- a list\n"))
+            .isInstanceOf(InvalidFixtureException.class);
+    }
+}
@@ -0,0 +1,91 @@
+package com.paymentgateway.authorization.geo;
+
+import com.paymentgateway.authorization.domain.CrossBorderPolicy;
+import com.paymentgateway.authorization.domain.Merchant;
+import org.junit.jupiter.api.Test;
+
+import static org.assertj.core.api.Assertions.*;
+
+class GeoRulesTest {
+    
+    private static final String US_VISA = "4111111111111111";
+    private static final String BR_VISA = "4000000760000002";
+    private static final String GB_VISA = "4000008260000000";
+    private static final String UNKNOWN = "9111111111111111";
+    
+    private final BinTable binTable = new BinTable("2-6:US, 400000076:BR, 400000826:GB");
+    private final GeoRules geoRules = new GeoRules(binTable);
+    
+    @Test
+    void shouldPreferTheLongestPrefix() {
+        assertThat(binTable.issuerCountry(US_VISA)).isEqualTo("US");
+        assertThat(binTable.issuerCountry(BR_VISA)).isEqualTo("BR");
+        assertThat(binTable.issuerCountry(UNKNOWN)).isNull();
+    }
+    
+    @Test
+    void shouldRejectInvalidEntries() {
+        assertThatThrownBy(() -> new BinTable("4:USA"))
+            .hasMessageContaining("Invalid BIN table entry");
+        assertThatThrownBy(() -> new BinTable("4111-41119:US"))
+            .hasMessageContaining("BIN range");
+    }
+    
+    @Test
+    void shouldNameIssuersOfTheLongestRangeWithOne() {
+        BinTable table = new BinTable("2-6:US, 411111:US:Simulator Bank:Visa Classic:debit, 41111111:GB");
+        
+        CardIssuer issuer = table.issuer(US_VISA);
+        
+        assertThat(issuer.getName()).isEqualTo("Simulator Bank");
+        assertThat(issuer.getFunding()).isEqualTo(CardFunding.DEBIT);
+        assertThat(table.issuerCountry(US_VISA)).isEqualTo("GB");
+        assertThat(table.issuer(GB_VISA)).isNull();
+        assertThatThrownBy(() -> new BinTable("4:US:Simulator Bank:Visa Classic:CHARGE"))
+            .hasMessageContaining("Invalid funding");
+        assertThatThrownBy(() -> new BinTable("4:US:Simulator Bank"))
+            .hasMessageContaining("Invalid BIN table entry");
+    }
+    
+    @Test
+    void shouldDeclineCardsIssuedInBlockedCountries() {
+        Merchant merchant = merchant("US", CrossBorderPolicy.ALLOW);
+        merchant.setBlockedIssuerCountries("BR,IR");
+        
+        GeoAssessment geo = geoRules.assess(merchant, BR_VISA);
+        
+        assertThat(geo.getOutcome()).isEqualTo(GeoAssessment.Outcome.BLOCKED);
+        assertThat(geo.getDeclineCode()).isEqualTo(GeoRules.ISSUER_COUNTRY_BLOCKED);
+        assertThat(geo.getIssuerCountry()).isEqualTo("BR");
+        assertThat(geo.isCrossBorder()).isTrue();
+        assertThat(geoRules.assess(merchant, GB_VISA).getOutcome()).isEqualTo(GeoAssessment.Outcome.ALLOWED);
+    }
+    
+    @Test
+    void shouldApplyTheCrossBorderPolicy() {
+        GeoAssessment allowed = geoRules.assess(merchant("US", CrossBorderPolicy.ALLOW), GB_VISA);
+        assertThat(allowed.getOutcome()).isEqualTo(GeoAssessment.Outcome.ALLOWED);
+        assertThat(allowed.isCrossBorder()).isTrue();
+        
+        assertThat(geoRules.assess(merchant("US", CrossBorderPolicy.FLAG), GB_VISA).getOutcome())
+            .isEqualTo(GeoAssessment.Outcome.FLAGGED);
+        
+        GeoAssessment blocked = geoRules.assess(merchant("US", CrossBorderPolicy.BLOCK), GB_VISA);
+        assertThat(blocked.getOutcome()).isEqualTo(GeoAssessment.Outcome.BLOCKED);
+        assertThat(blocked.getDeclineCode()).isEqualTo(GeoRules.CROSS_BORDER_BLOCKED);
+        
+        // Domestic cards and cards the BIN table cannot place are not cross-border
+        assertThat(geoRules.assess(merchant("US", CrossBorderPolicy.BLOCK), US_VISA).getOutcome())
+            .isEqualTo(GeoAssessment.Outcome.ALLOWED);
+        GeoAssessment unknown = geoRules.assess(merchant("US", CrossBorderPolicy.BLOCK), UNKNOWN);
+        assertThat(unknown.getOutcome()).isEqualTo(GeoAssessment.Outcome.ALLOWED);
+        assertThat(unknown.isCrossBorder()).isFalse();
+    }
+    
+    private static Merchant merchant(String country, CrossBorderPolicy policy) {
+        Merchant merchant = new Merchant();
+        merchant.setCountryCode(country);
+        merchant.setCrossBorderPolicy(policy);
+        return merchant;
+    }
+}
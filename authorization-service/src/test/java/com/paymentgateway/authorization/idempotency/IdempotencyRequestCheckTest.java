@@ -0,0 +1,150 @@
+package com.paymentgateway.authorization.idempotency;
+
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.fasterxml.jackson.datatype.jsr310.JavaTimeModule;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import io.micrometer.core.instrument.simple.SimpleMeterRegistry;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+import java.time.Duration;
+import java.util.HashMap;
+import java.util.Map;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+
+/**
+ * Unit tests for detecting idempotency keys reused with a different request,
+ * against an in-memory store shared by two service instances.
+ */
+class IdempotencyRequestCheckTest {
+    
+    private static final UUID MERCHANT = UUID.fromString("00000000-0000-0000-0000-000000000001");
+    
+    private InMemoryStore store;
+    private SimpleMeterRegistry registry;
+    private IdempotencyService first;
+    private IdempotencyService second;
+    
+    @BeforeEach
+    void setUp() {
+        ObjectMapper objectMapper = new ObjectMapper();
+        objectMapper.registerModule(new JavaTimeModule());
+        store = new InMemoryStore();
+        registry = new SimpleMeterRegistry();
+        first = new IdempotencyService(store, objectMapper, registry, Duration.ofHours(24));
+        second = new IdempotencyService(store, objectMapper, new SimpleMeterRegistry(), Duration.ofHours(24));
+    }
+    
+    @Test
+    void testRetryWithSameRequestPasses() {
+        String hash = first.requestHash(MERCHANT, request("100.00"));
+        first.recordRequest("key-1", hash);
+        
+        second.checkRequest("key-1", second.requestHash(MERCHANT, request("100.00")));
+    }
+    
+    @Test
+    void testReuseWithDifferentRequestIsRejectedOnAnotherInstance() {
+        first.recordRequest("key-1", first.requestHash(MERCHANT, request("100.00")));
+        
+        String otherAmount = second.requestHash(MERCHANT, request("250.00"));
+        assertThatThrownBy(() -> second.checkRequest("key-1", otherAmount))
+            .isInstanceOf(IdempotencyKeyReuseException.class)
+            .hasMessageContaining("key-1");
+    }
+    
+    @Test
+    void testReuseByAnotherMerchantIsRejected() {
+        first.recordRequest("key-1", first.requestHash(MERCHANT, request("100.00")));
+        
+        String otherMerchant = first.requestHash(UUID.randomUUID(), request("100.00"));
+        assertThatThrownBy(() -> first.checkRequest("key-1", otherMerchant))
+            .isInstanceOf(IdempotencyKeyReuseException.class);
+        assertThat(registry.get("idempotency.key.reuse.total").counter().count()).isEqualTo(1.0);
+    }
+    
+    @Test
+    void testHashIgnoresCvvAndStoresNoCardData() {
+        PaymentRequest withOtherCvv = request("100.00");
+        withOtherCvv.setCvv("999");
+        
+        String hash = first.requestHash(MERCHANT, request("100.00"));
+        assertThat(first.requestHash(MERCHANT, withOtherCvv)).isEqualTo(hash);
+        
+        PaymentRequest otherCard = request("100.00");
+        otherCard.setCardNumber("5425233430109903");
+        assertThat(first.requestHash(MERCHANT, otherCard)).isNotEqualTo(hash);
+        
+        first.recordRequest("key-1", hash);
+        assertThat(store.values.values()).noneMatch(value -> value.contains("4532015112830366"));
+    }
+    
+    @Test
+    void testLookupMetrics() {
+        first.storeResult("key-1", Map.of("paymentId", "pay_1"));
+        
+        first.getExistingResult("key-1", Map.class);
+        first.getExistingResult("key-2", Map.class);
+        
+        assertThat(registry.get("idempotency.lookups.total").tag("result", "hit").counter().count()).isEqualTo(1.0);
+        assertThat(registry.get("idempotency.lookups.total").tag("result", "miss").counter().count()).isEqualTo(1.0);
+    }
+    
+    @Test
+    void testLockIsSharedAcrossInstances() {
+        assertThat(first.acquireLock("key-1")).isTrue();
+        assertThat(store.exists("idempotency:lock:key-1")).isTrue();
+        
+        first.releaseLock("key-1");
+        assertThat(second.acquireLock("key-1")).isTrue();
+    }
+    
+    private PaymentRequest request(String amount) {
+        PaymentRequest request = new PaymentRequest();
+        request.setCardNumber("4532015112830366");
+        request.setExpiryMonth(12);
+        request.setExpiryYear(2030);
+        request.setCvv("123");
+        request.setAmount(new BigDecimal(amount));
+        request.setCurrency("EUR");
+        return request;
+    }
+    
+    private static class InMemoryStore implements IdempotencyStore {
+        private final Map<String, String> values = new HashMap<>();
+        
+        @Override
+        public String name() {
+            return "memory";
+        }
+        
+        @Override
+        public String get(String key) {
+            return values.get(key);
+        }
+        
+        @Override
+        public void put(String key, String value, Duration ttl) {
+            values.put(key, value);
+        }
+        
+        @Override
+        public boolean putIfAbsent(String key, String value, Duration ttl) {
+            return values.putIfAbsent(key, value) == null;
+        }
+        
+        @Override
+        public boolean exists(String key) {
+            return values.containsKey(key);
+        }
+        
+        @Override
+        public void delete(String key) {
+            values.remove(key);
+        }
+    }
+}
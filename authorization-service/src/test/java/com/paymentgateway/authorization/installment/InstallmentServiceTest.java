@@ -0,0 +1,59 @@
+package com.paymentgateway.authorization.installment;
+
+import com.paymentgateway.authorization.domain.InstallmentPlanType;
+import com.paymentgateway.authorization.dto.Installments;
+import jakarta.validation.ValidationException;
+import org.junit.jupiter.api.Test;
+
+import static org.assertj.core.api.Assertions.*;
+
+class InstallmentServiceTest {
+    
+    private static final String VISA = "4111111111111111";
+    private static final String MASTERCARD = "5555555555554444";
+    
+    private final InstallmentService installmentService =
+        new InstallmentService("*:BR:MERCHANT|ISSUER:12, 555500-555599:BR:ISSUER:6, 411111:*:MERCHANT:3");
+    
+    @Test
+    void shouldAcceptPlansOfferedInTheMerchantCountry() {
+        assertThatCode(() -> installmentService.check(VISA, "BR", new Installments(12, InstallmentPlanType.ISSUER)))
+            .doesNotThrowAnyException();
+        
+        assertThatThrownBy(() -> installmentService.check(VISA, "BR", new Installments(13, InstallmentPlanType.ISSUER)))
+            .isInstanceOf(ValidationException.class)
+            .hasMessageContaining("At most 12");
+    }
+    
+    @Test
+    void shouldPreferTheBinRangeRule() {
+        assertThatThrownBy(() -> installmentService.check(MASTERCARD, "BR", new Installments(3, InstallmentPlanType.MERCHANT)))
+            .isInstanceOf(ValidationException.class)
+            .hasMessageContaining("MERCHANT installments");
+        assertThatCode(() -> installmentService.check(MASTERCARD, "BR", new Installments(6, InstallmentPlanType.ISSUER)))
+            .doesNotThrowAnyException();
+        
+        // A BIN rule for any region beats the country rule
+        assertThatThrownBy(() -> installmentService.check(VISA, "BR", new Installments(6, InstallmentPlanType.MERCHANT)))
+            .hasMessageContaining("At most 3");
+    }
+    
+    @Test
+    void shouldRejectInstallmentsWhereNoneAreOffered() {
+        assertThatThrownBy(() -> installmentService.check(MASTERCARD, "US", new Installments(3, InstallmentPlanType.MERCHANT)))
+            .isInstanceOf(ValidationException.class)
+            .hasMessageContaining("not offered");
+        assertThatThrownBy(() -> new InstallmentService("").check(VISA, "BR", new Installments(3, InstallmentPlanType.MERCHANT)))
+            .isInstanceOf(ValidationException.class);
+    }
+    
+    @Test
+    void shouldRejectInvalidRules() {
+        assertThatThrownBy(() -> new InstallmentService("*:BR:LAYAWAY:12"))
+            .hasMessageContaining("plan type");
+        assertThatThrownBy(() -> new InstallmentService("4111-41119:BR:ISSUER:12"))
+            .hasMessageContaining("BIN range");
+        assertThatThrownBy(() -> new InstallmentService("*:BR:ISSUER:120"))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+}
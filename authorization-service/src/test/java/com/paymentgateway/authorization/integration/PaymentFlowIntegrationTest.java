@@ -3,17 +3,32 @@ package com.paymentgateway.authorization.integration;
 import com.paymentgateway.authorization.domain.*;
 import com.paymentgateway.authorization.dto.*;
 import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.currency.CurrencyConversionResult;
+import com.paymentgateway.authorization.currency.CurrencyConversionService;
+import com.paymentgateway.authorization.geo.BinTable;
+import com.paymentgateway.authorization.geo.GeoRules;
 import com.paymentgateway.authorization.idempotency.IdempotencyService;
+import com.paymentgateway.authorization.installment.InstallmentService;
+import com.paymentgateway.authorization.networktoken.CryptogramCheck;
+import com.paymentgateway.authorization.networktoken.NetworkTokenCryptograms;
 import com.paymentgateway.authorization.psp.*;
 import com.paymentgateway.authorization.repository.*;
+import com.paymentgateway.authorization.resilience.CircuitBreakerRegistry;
+import com.paymentgateway.authorization.sca.ScaService;
+import com.paymentgateway.authorization.screening.ScreeningService;
+import com.paymentgateway.authorization.surcharge.SurchargeService;
 import com.paymentgateway.authorization.service.PaymentService;
 import com.paymentgateway.authorization.service.RefundService;
+import com.paymentgateway.authorization.tokens.EncryptedCardTokenizer;
+import com.paymentgateway.authorization.tokens.TokenizedCard;
 import io.opentelemetry.api.trace.Span;
 import io.opentelemetry.api.trace.SpanBuilder;
 import io.opentelemetry.api.trace.SpanContext;
 import io.opentelemetry.api.trace.Tracer;
 import io.opentelemetry.context.Scope;
+import jakarta.validation.ValidationException;
 import org.junit.jupiter.api.*;
+import org.mockito.ArgumentCaptor;
 import org.mockito.Mock;
 import org.mockito.MockitoAnnotations;
 
@@ -41,6 +56,9 @@ class PaymentFlowIntegrationTest {
     @Mock private Tracer tracer;
     @Mock private IdempotencyService idempotencyService;
     @Mock private PaymentEventPublisher eventPublisher;
+    @Mock private CurrencyConversionService currencyConversionService;
+    @Mock private MerchantRepository merchantRepository;
+    @Mock private ScreeningHitRepository screeningHitRepository;
     
     private PaymentService paymentService;
     private RefundService refundService;
@@ -68,7 +86,18 @@ class PaymentFlowIntegrationTest {
             pspRoutingService,
             tracer,
             idempotencyService,
-            eventPublisher
+            eventPublisher,
+            new ScaService(currencyConversionService, new BigDecimal("30"),
+                new BigDecimal("0.0013"), new BigDecimal("0.30")),
+            CircuitBreakerRegistry.withDefaults(),
+            merchantRepository,
+            new SurchargeService(""),
+            new InstallmentService(""),
+            new ScreeningService(List.of(), screeningHitRepository, true),
+            new GeoRules(new BinTable("")),
+            new NetworkTokenCryptograms("", null),
+            new PinTranslation(null, "", "issuer-zpk"),
+            null
         );
         
         refundService = new RefundService(
@@ -76,7 +105,10 @@ class PaymentFlowIntegrationTest {
             paymentRepository,
             paymentEventRepository,
             pspRoutingService,
-            eventPublisher
+            eventPublisher,
+            merchantRepository,
+            null,
+            null
         );
     }
     
@@ -135,6 +167,37 @@ class PaymentFlowIntegrationTest {
         verify(eventPublisher).publishPaymentEvent(any(Payment.class), any());
     }
     
+    @Test
+    @DisplayName("Payment flow should record each step for the timeline")
+    void shouldRecordEachStepUnderOneCorrelationId() {
+        PSPAuthorizationResponse pspResponse = PSPAuthorizationResponse.success("psp_txn", new BigDecimal("100.00"), "USD");
+        pspResponse.setRoutingPath("STRIPE:AUTHORIZED");
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class))).thenReturn(pspResponse);
+        when(paymentRepository.save(any(Payment.class))).thenAnswer(invocation -> {
+            Payment p = invocation.getArgument(0);
+            p.setId(UUID.randomUUID());
+            return p;
+        });
+        
+        paymentService.processPayment(createValidPaymentRequest(), UUID.randomUUID());
+        
+        ArgumentCaptor<PaymentEvent> authorization = ArgumentCaptor.forClass(PaymentEvent.class);
+        verify(paymentEventRepository).save(authorization.capture());
+        @SuppressWarnings("unchecked")
+        ArgumentCaptor<Iterable<PaymentEvent>> steps = ArgumentCaptor.forClass(Iterable.class);
+        verify(paymentEventRepository).saveAll(steps.capture());
+        
+        assertThat(steps.getValue())
+            .extracting(PaymentEvent::getEventType)
+            .containsExactly("TOKENIZATION", "FRAUD_CHECK", "GEO_CHECK", "SANCTIONS_SCREENING", "3DS_AUTH",
+                "AUTHORIZATION_REQUEST");
+        assertThat(steps.getValue()).allSatisfy(step -> {
+            assertThat(step.getPaymentId()).isEqualTo(authorization.getValue().getPaymentId());
+            assertThat(step.getCorrelationId()).isEqualTo(authorization.getValue().getCorrelationId());
+        });
+        assertThat(authorization.getValue().getDescription()).isEqualTo("AUTHORIZED via STRIPE:AUTHORIZED");
+    }
+    
     /**
      * Test payment authorization with idempotency key.
      * Validates: Requirements 21.1, 21.2 - Idempotency handling
@@ -203,6 +266,308 @@ class PaymentFlowIntegrationTest {
         assertThat(response.getStatus()).isEqualTo(PaymentStatus.DECLINED);
     }
     
+    // ==================== Strong Customer Authentication Tests ====================
+    
+    @Test
+    @DisplayName("European payment within the low value limit should be flagged as exempt")
+    void shouldApplyLowValueExemption() {
+        PaymentRequest request = createEuropeanPaymentRequest(new BigDecimal("25.00"));
+        mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenReturn(PSPAuthorizationResponse.success("psp_txn_1", request.getAmount(), "EUR"));
+        
+        PaymentResponse response = paymentService.processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        assertThat(response.getScaExemption()).isEqualTo("LOW_VALUE");
+        verify(pspRoutingService).authorizeWithFailover(argThat(r ->
+            r.isScaInScope() && "LOW_VALUE".equals(r.getScaExemption())));
+    }
+    
+    @Test
+    @DisplayName("Soft-declined payment should succeed when resubmitted with 3DS data")
+    void shouldResubmitSoftDeclinedPaymentWithAuthentication() {
+        UUID merchantId = UUID.randomUUID();
+        PaymentRequest request = createEuropeanPaymentRequest(new BigDecimal("900.00"));
+        List<Payment> saved = mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenAnswer(invocation -> {
+                PSPAuthorizationResponse softDecline = ScaSoftDecline.check(invocation.getArgument(0));
+                return softDecline != null ? softDecline
+                    : PSPAuthorizationResponse.success("psp_txn_2", request.getAmount(), "EUR");
+            });
+        
+        // Above every exemption limit, so the issuer asks for authentication
+        PaymentResponse declined = paymentService.processPayment(request, merchantId);
+        assertThat(declined.getStatus()).isEqualTo(PaymentStatus.DECLINED);
+        assertThat(declined.getErrorCode()).isEqualTo(ScaSoftDecline.VISA_CODE);
+        assertThat(declined.isAuthenticationRequired()).isTrue();
+        assertThat(declined.getScaExemption()).isNull();
+        
+        when(paymentRepository.findByPaymentId(declined.getPaymentId()))
+            .thenReturn(Optional.of(saved.get(0)));
+        request.setOriginalPaymentId(declined.getPaymentId());
+        
+        // Resubmitting without authentication data is rejected
+        assertThatThrownBy(() -> paymentService.processPayment(request, merchantId))
+            .isInstanceOf(IllegalArgumentException.class);
+        
+        request.setThreeDsCavv("AAABBEg0VhI0VniQEjRWAAAAAAA=");
+        request.setThreeDsEci("05");
+        PaymentResponse authorized = paymentService.processPayment(request, merchantId);
+        
+        assertThat(authorized.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        assertThat(authorized.isAuthenticationRequired()).isFalse();
+        assertThat(saved.get(1).getThreeDsStatus()).isEqualTo(ThreeDSStatus.AUTHENTICATED);
+        assertThat(saved.get(1).getOriginalPaymentId()).isEqualTo(declined.getPaymentId());
+        
+        // Another merchant cannot resubmit the payment
+        assertThatThrownBy(() -> paymentService.processPayment(request, UUID.randomUUID()))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    // ==================== Transaction Initiation Tests ====================
+    
+    @Test
+    @DisplayName("Recurring merchant-initiated payment should quote the original network transaction ID")
+    void shouldThreadInitiationIndicatorsToNetwork() {
+        UUID merchantId = UUID.randomUUID();
+        List<PSPAuthorizationRequest> sent = new ArrayList<>();
+        mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenAnswer(invocation -> {
+                PSPAuthorizationRequest pspRequest = invocation.getArgument(0);
+                sent.add(pspRequest);
+                PSPAuthorizationResponse networkDecline = NetworkRules.checkInitiation(pspRequest);
+                if (networkDecline != null) {
+                    return networkDecline;
+                }
+                PSPAuthorizationResponse approved = PSPAuthorizationResponse.success(
+                    "psp_txn_" + sent.size(), pspRequest.getAmount(), pspRequest.getCurrency());
+                approved.setNetworkTransactionId(NetworkRules.newTraceId());
+                return approved;
+            });
+        
+        // The cardholder sets up a subscription
+        PaymentRequest first = createValidPaymentRequest();
+        first.setStoredCredential(StoredCredentialType.RECURRING);
+        PaymentResponse setup = paymentService.processPayment(first, merchantId);
+        assertThat(setup.getNetworkTransactionId()).matches("[0-9A-Z]{15}");
+        
+        // A renewal without the original ID is rejected by the network
+        PaymentRequest renewal = createValidPaymentRequest();
+        renewal.setCvv(null);
+        renewal.setInitiator(TransactionInitiator.MERCHANT);
+        renewal.setStoredCredential(StoredCredentialType.RECURRING);
+        PaymentResponse rejected = paymentService.processPayment(renewal, merchantId);
+        assertThat(rejected.getStatus()).isEqualTo(PaymentStatus.DECLINED);
+        assertThat(rejected.getErrorCode()).isEqualTo(NetworkRules.INVALID_TRANSACTION);
+        
+        renewal.setOriginalNetworkTransactionId(setup.getNetworkTransactionId());
+        PaymentResponse renewed = paymentService.processPayment(renewal, merchantId);
+        assertThat(renewed.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        
+        PSPAuthorizationRequest mit = sent.get(2);
+        assertThat(mit.getInitiator()).isEqualTo("MERCHANT");
+        assertThat(mit.getStoredCredential()).isEqualTo("RECURRING");
+        assertThat(mit.getChannel()).isEqualTo("ECOMMERCE");
+        assertThat(mit.getOriginalNetworkTransactionId()).isEqualTo(setup.getNetworkTransactionId());
+    }
+    
+    @Test
+    @DisplayName("MOTO and merchant-initiated payments should be outside SCA scope")
+    void shouldExcludeMotoFromSca() {
+        PaymentRequest request = createEuropeanPaymentRequest(new BigDecimal("900.00"));
+        request.setChannel(TransactionChannel.MOTO);
+        mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenReturn(PSPAuthorizationResponse.success("psp_txn_moto", request.getAmount(), "EUR"));
+        
+        PaymentResponse response = paymentService.processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        verify(pspRoutingService).authorizeWithFailover(argThat(r ->
+            !r.isScaInScope() && "MOTO".equals(r.getChannel())));
+    }
+    
+    // ==================== Geo Rule Tests ====================
+    
+    @Test
+    @DisplayName("Cards issued in a blocked country should be declined without an acquirer")
+    void shouldDeclineCardsIssuedInBlockedCountries() {
+        Merchant merchant = new Merchant("merch_geo", "Geo Merchant");
+        merchant.setCountryCode("US");
+        merchant.setBlockedIssuerCountries("BR");
+        UUID merchantId = UUID.randomUUID();
+        when(merchantRepository.findById(merchantId)).thenReturn(Optional.of(merchant));
+        List<Payment> saved = mockPersistence();
+        PaymentService geoPaymentService = geoPaymentService("4:US,400000076:BR");
+        PaymentRequest request = createValidPaymentRequest();
+        request.setCardNumber("4000000760000002");
+        
+        PaymentResponse response = geoPaymentService.processPayment(request, merchantId);
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.DECLINED);
+        assertThat(response.getErrorCode()).isEqualTo(GeoRules.ISSUER_COUNTRY_BLOCKED);
+        assertThat(response.getIssuerCountry()).isEqualTo("BR");
+        assertThat(response.isCrossBorder()).isTrue();
+        assertThat(saved.get(0).getPspName()).isNull();
+        verify(pspRoutingService, never()).authorizeWithFailover(any());
+    }
+    
+    @Test
+    @DisplayName("Cross-border cards should be sent for review under a FLAG policy")
+    void shouldFlagCrossBorderCards() {
+        Merchant merchant = new Merchant("merch_geo", "Geo Merchant");
+        merchant.setCountryCode("GB");
+        merchant.setCrossBorderPolicy(CrossBorderPolicy.FLAG);
+        UUID merchantId = UUID.randomUUID();
+        when(merchantRepository.findById(merchantId)).thenReturn(Optional.of(merchant));
+        List<Payment> saved = mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenReturn(PSPAuthorizationResponse.success("psp_txn_geo", new BigDecimal("100.00"), "USD"));
+        
+        PaymentResponse response = geoPaymentService("4:US").processPayment(createValidPaymentRequest(), merchantId);
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        assertThat(response.isCrossBorder()).isTrue();
+        assertThat(saved.get(0).getFraudStatus()).isEqualTo(FraudStatus.REVIEW);
+    }
+    
+    // ==================== Network Token Tests ====================
+    
+    @Test
+    @DisplayName("Network tokens with an invalid cryptogram should be declined without an acquirer")
+    void shouldDeclineNetworkTokensWithInvalidCryptograms() {
+        List<Payment> saved = mockPersistence();
+        PaymentService tokenPaymentService = networkTokenPaymentService(CryptogramCheck.Outcome.INVALID);
+        PaymentRequest request = createValidPaymentRequest();
+        request.setCardNumber("4895370012345671");
+        request.setTokenCryptogram("AQIDBAUGBwgJCg==");
+        
+        PaymentResponse response = tokenPaymentService.processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.DECLINED);
+        assertThat(response.getErrorCode()).isEqualTo(CryptogramCheck.CRYPTOGRAM_FAILURE);
+        assertThat(saved.get(0).getPspName()).isNull();
+        verify(pspRoutingService, never()).authorizeWithFailover(any());
+    }
+    
+    @Test
+    @DisplayName("Network tokens with a valid cryptogram should be authorized")
+    void shouldAuthorizeNetworkTokensWithValidCryptograms() {
+        mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenReturn(PSPAuthorizationResponse.success("psp_txn_nt", new BigDecimal("100.00"), "USD"));
+        PaymentRequest request = createValidPaymentRequest();
+        request.setCardNumber("4895370012345671");
+        request.setTokenCryptogram("AQIDBAUGBwgJCg==");
+        
+        PaymentResponse response = networkTokenPaymentService(CryptogramCheck.Outcome.VALID)
+            .processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        @SuppressWarnings("unchecked")
+        ArgumentCaptor<Iterable<PaymentEvent>> steps = ArgumentCaptor.forClass(Iterable.class);
+        verify(paymentEventRepository).saveAll(steps.capture());
+        assertThat(steps.getValue())
+            .filteredOn(step -> "CRYPTOGRAM_CHECK".equals(step.getEventType()))
+            .extracting(PaymentEvent::getEventStatus)
+            .containsExactly("VALID");
+    }
+    
+    // ==================== PIN Translation Tests ====================
+    
+    @Test
+    @DisplayName("PIN blocks should reach the PSP translated to the issuer's ZPK")
+    void shouldTranslatePinBlocksForTheIssuer() {
+        mockPersistence();
+        PinTranslation translation = mock(PinTranslation.class);
+        when(translation.isEnabled()).thenReturn(true);
+        when(translation.translate(anyString(), eq("00112233445566778899aabbccddeeff")))
+            .thenReturn("ffeeddccbbaa99887766554433221100");
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenReturn(PSPAuthorizationResponse.success("psp_txn_pin", new BigDecimal("100.00"), "USD"));
+        PaymentRequest request = createValidPaymentRequest();
+        request.setPinBlock("00112233445566778899aabbccddeeff");
+        
+        PaymentResponse response = pinTranslationPaymentService(translation).processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        ArgumentCaptor<PSPAuthorizationRequest> sent = ArgumentCaptor.forClass(PSPAuthorizationRequest.class);
+        verify(pspRoutingService).authorizeWithFailover(sent.capture());
+        assertThat(sent.getValue().getPinBlock()).isEqualTo("ffeeddccbbaa99887766554433221100");
+    }
+    
+    @Test
+    @DisplayName("A PIN block that cannot be translated should be declined without an acquirer")
+    void shouldDeclineUntranslatablePinBlocks() {
+        List<Payment> saved = mockPersistence();
+        PinTranslation translation = mock(PinTranslation.class);
+        when(translation.isEnabled()).thenReturn(true);
+        when(translation.translate(anyString(), anyString())).thenThrow(new IllegalStateException("HSM unavailable"));
+        PaymentRequest request = createValidPaymentRequest();
+        request.setPinBlock("00112233445566778899aabbccddeeff");
+        
+        PaymentResponse response = pinTranslationPaymentService(translation).processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.DECLINED);
+        assertThat(response.getErrorCode()).isEqualTo(SimulatedIssuer.ISSUER_UNAVAILABLE);
+        assertThat(saved.get(0).getPspName()).isNull();
+        verify(pspRoutingService, never()).authorizeWithFailover(any());
+    }
+    
+    // ==================== P2PE Tests ====================
+    
+    @Test
+    @DisplayName("An encrypted card should be authorized by its token, BIN and last four digits")
+    void shouldAuthorizeEncryptedCardsWithoutThePan() {
+        List<Payment> saved = mockPersistence();
+        when(pspRoutingService.authorizeWithFailover(any(PSPAuthorizationRequest.class)))
+            .thenReturn(PSPAuthorizationResponse.success("psp_txn_p2pe", new BigDecimal("100.00"), "USD"));
+        EncryptedCardTokenizer zone = (merchantId, ksn, track) -> {
+            assertThat(ksn).hasSize(10);
+            assertThat(track).hasSize(32);
+            return new TokenizedCard("tok_p2pe", "55555555", "4444", "PAR0001");
+        };
+        PaymentRequest request = createValidPaymentRequest();
+        request.setCardNumber(null);
+        request.setExpiryMonth(null);
+        request.setExpiryYear(null);
+        request.setCvv(null);
+        request.setEncryptedCard(new EncryptedCard("FFFF9876543210E00001", "00".repeat(32)));
+        
+        PaymentResponse response = p2pePaymentService(zone).processPayment(request, UUID.randomUUID());
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.AUTHORIZED);
+        assertThat(saved.get(0).getCardLastFour()).isEqualTo("4444");
+        assertThat(saved.get(0).getCardBrand()).isEqualTo(CardBrand.MASTERCARD);
+        ArgumentCaptor<PSPAuthorizationRequest> sent = ArgumentCaptor.forClass(PSPAuthorizationRequest.class);
+        verify(pspRoutingService).authorizeWithFailover(sent.capture());
+        assertThat(sent.getValue().getCardBin()).isEqualTo("55555555");
+        assertThat(sent.getValue().getCardFingerprint()).isEqualTo(CardFingerprint.of("PAR0001"));
+    }
+    
+    @Test
+    @DisplayName("An encrypted card should be refused without a decryption zone or a readable track")
+    void shouldRefuseEncryptedCardsThatCannotBeRead() {
+        mockPersistence();
+        PaymentRequest request = createValidPaymentRequest();
+        request.setCardNumber(null);
+        request.setEncryptedCard(new EncryptedCard("FFFF9876543210E00001", "00".repeat(16)));
+        
+        assertThatThrownBy(() -> p2pePaymentService(null).processPayment(request, UUID.randomUUID()))
+            .isInstanceOf(ValidationException.class)
+            .hasMessageContaining("no P2PE decryption zone");
+        EncryptedCardTokenizer zone = (merchantId, ksn, track) -> {
+            throw new IllegalArgumentException("read does not decrypt to a card");
+        };
+        assertThatThrownBy(() -> p2pePaymentService(zone).processPayment(request, UUID.randomUUID()))
+            .isInstanceOf(ValidationException.class)
+            .hasMessageContaining("does not decrypt");
+        verify(pspRoutingService, never()).authorizeWithFailover(any());
+    }
+    
     // ==================== Payment Capture Flow Tests ====================
     
     /**
@@ -266,6 +631,65 @@ class PaymentFlowIntegrationTest {
             .isInstanceOf(RuntimeException.class)
             .hasMessageContaining("AUTHORIZED");
     }
+    
+    @Test
+    @DisplayName("Capture should add a tip within the brand tolerance for restaurants")
+    void shouldCaptureWithTipForRestaurant() {
+        // Given
+        Payment authorizedPayment = createAuthorizedPayment();
+        String paymentId = authorizedPayment.getPaymentId();
+        Merchant restaurant = new Merchant("restaurant", "Restaurant");
+        restaurant.setMcc("5812");
+        
+        when(paymentRepository.findByPaymentId(paymentId))
+            .thenReturn(Optional.of(authorizedPayment));
+        when(merchantRepository.findById(authorizedPayment.getMerchantId()))
+            .thenReturn(Optional.of(restaurant));
+        when(pspRoutingService.selectPSP(any(UUID.class)))
+            .thenReturn(pspClient);
+        
+        PSPCaptureResponse captureResponse = new PSPCaptureResponse();
+        captureResponse.setSuccess(true);
+        when(pspClient.capture(anyString(), any(BigDecimal.class), anyString()))
+            .thenReturn(captureResponse);
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        PaymentResponse response = paymentService.capturePayment(paymentId, new BigDecimal("18.00"));
+        
+        // Then
+        assertThat(response.getAmount()).isEqualByComparingTo("118.00");
+        assertThat(response.getAmountBreakdown().getBaseAmount()).isEqualByComparingTo("100.00");
+        assertThat(response.getAmountBreakdown().getTipAmount()).isEqualByComparingTo("18.00");
+        verify(pspClient).capture(eq(authorizedPayment.getPspTransactionId()),
+            eq(new BigDecimal("118.00")), eq("USD"));
+    }
+    
+    @Test
+    @DisplayName("Capture should reject tips beyond the tolerance or from non-tipping merchants")
+    void shouldRejectTipsOutsideTheRules() {
+        // Given
+        Payment authorizedPayment = createAuthorizedPayment();
+        String paymentId = authorizedPayment.getPaymentId();
+        Merchant merchant = new Merchant("restaurant", "Restaurant");
+        merchant.setMcc("5812");
+        
+        when(paymentRepository.findByPaymentId(paymentId))
+            .thenReturn(Optional.of(authorizedPayment));
+        when(merchantRepository.findById(authorizedPayment.getMerchantId()))
+            .thenReturn(Optional.of(merchant));
+        
+        // When/Then: Visa lets a tip add 20%
+        assertThatThrownBy(() -> paymentService.capturePayment(paymentId, new BigDecimal("20.01")))
+            .isInstanceOf(jakarta.validation.ValidationException.class)
+            .hasMessageContaining("VISA");
+        
+        merchant.setMcc("5411");
+        assertThatThrownBy(() -> paymentService.capturePayment(paymentId, new BigDecimal("5.00")))
+            .isInstanceOf(jakarta.validation.ValidationException.class);
+        verify(pspClient, never()).capture(anyString(), any(BigDecimal.class), anyString());
+    }
 
     
     // ==================== Refund Flow Tests ====================
@@ -579,6 +1003,76 @@ class PaymentFlowIntegrationTest {
     
     // ==================== Helper Methods ====================
     
+    private PaymentRequest createEuropeanPaymentRequest(BigDecimal amount) {
+        PaymentRequest request = createValidPaymentRequest();
+        request.setAmount(amount);
+        request.setCurrency("EUR");
+        request.setBillingCountry("DE");
+        when(currencyConversionService.convert(any(), eq("EUR"), eq("EUR")))
+            .thenAnswer(invocation -> new CurrencyConversionResult(
+                invocation.getArgument(0), "EUR", invocation.getArgument(0), "EUR", BigDecimal.ONE));
+        return request;
+    }
+    
+    private PaymentService geoPaymentService(String binTable) {
+        return new PaymentService(paymentRepository, paymentEventRepository, pspRoutingService, tracer,
+            idempotencyService, eventPublisher,
+            new ScaService(currencyConversionService, new BigDecimal("30"),
+                new BigDecimal("0.0013"), new BigDecimal("0.30")),
+            CircuitBreakerRegistry.withDefaults(), merchantRepository, new SurchargeService(""),
+            new InstallmentService(""), new ScreeningService(List.of(), screeningHitRepository, true),
+            new GeoRules(new BinTable(binTable)), new NetworkTokenCryptograms("", null),
+            new PinTranslation(null, "", "issuer-zpk"), null);
+    }
+    
+    private PaymentService networkTokenPaymentService(CryptogramCheck.Outcome outcome) {
+        return new PaymentService(paymentRepository, paymentEventRepository, pspRoutingService, tracer,
+            idempotencyService, eventPublisher,
+            new ScaService(currencyConversionService, new BigDecimal("30"),
+                new BigDecimal("0.0013"), new BigDecimal("0.30")),
+            CircuitBreakerRegistry.withDefaults(), merchantRepository, new SurchargeService(""),
+            new InstallmentService(""), new ScreeningService(List.of(), screeningHitRepository, true),
+            new GeoRules(new BinTable("")),
+            new NetworkTokenCryptograms("489537",
+                (token, merchantId, amount, currency, cryptogram) -> CryptogramCheck.of(outcome)),
+            new PinTranslation(null, "", "issuer-zpk"), null);
+    }
+    
+    private PaymentService pinTranslationPaymentService(PinTranslation translation) {
+        return new PaymentService(paymentRepository, paymentEventRepository, pspRoutingService, tracer,
+            idempotencyService, eventPublisher,
+            new ScaService(currencyConversionService, new BigDecimal("30"),
+                new BigDecimal("0.0013"), new BigDecimal("0.30")),
+            CircuitBreakerRegistry.withDefaults(), merchantRepository, new SurchargeService(""),
+            new InstallmentService(""), new ScreeningService(List.of(), screeningHitRepository, true),
+            new GeoRules(new BinTable("")), new NetworkTokenCryptograms("", null), translation, null);
+    }
+    
+    private PaymentService p2pePaymentService(EncryptedCardTokenizer encryptedCards) {
+        return new PaymentService(paymentRepository, paymentEventRepository, pspRoutingService, tracer,
+            idempotencyService, eventPublisher,
+            new ScaService(currencyConversionService, new BigDecimal("30"),
+                new BigDecimal("0.0013"), new BigDecimal("0.30")),
+            CircuitBreakerRegistry.withDefaults(), merchantRepository, new SurchargeService(""),
+            new InstallmentService(""), new ScreeningService(List.of(), screeningHitRepository, true),
+            new GeoRules(new BinTable("")), new NetworkTokenCryptograms("", null),
+            new PinTranslation(null, "", "issuer-zpk"), encryptedCards);
+    }
+    
+    private List<Payment> mockPersistence() {
+        List<Payment> saved = new ArrayList<>();
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> {
+                Payment p = invocation.getArgument(0);
+                p.setId(UUID.randomUUID());
+                saved.add(p);
+                return p;
+            });
+        when(paymentEventRepository.save(any(PaymentEvent.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        return saved;
+    }
+    
     private PaymentRequest createValidPaymentRequest() {
         PaymentRequest request = new PaymentRequest();
         request.setCardNumber("4242424242424242");
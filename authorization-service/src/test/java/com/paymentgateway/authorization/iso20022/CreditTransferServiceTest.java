@@ -0,0 +1,114 @@
+package com.paymentgateway.authorization.iso20022;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+import com.paymentgateway.authorization.domain.CreditTransferStatus;
+import com.paymentgateway.authorization.repository.CreditTransferRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+
+import java.time.LocalDate;
+import java.util.List;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for ingesting ISO 20022 credit transfer messages.
+ */
+class CreditTransferServiceTest {
+    
+    private static final LocalDate TODAY = LocalDate.of(2026, 10, 16);
+    
+    private CreditTransferRepository repository;
+    private CreditTransferService service;
+    private UUID merchantId;
+    
+    @BeforeEach
+    void setUp() {
+        repository = mock(CreditTransferRepository.class);
+        service = new CreditTransferService(repository);
+        merchantId = UUID.randomUUID();
+    }
+    
+    @Test
+    void shouldSettleTransfersDueTodayAndAcceptLaterOnes() {
+        Iso20022Message pain = service.ingest(merchantId, Iso20022ParserTest.PAIN_001, TODAY);
+        Iso20022Message pacs = service.ingest(merchantId, Iso20022ParserTest.PACS_008, TODAY);
+        
+        for (CreditTransfer transfer : pain.getTransfers()) {
+            assertThat(transfer.getStatus()).isEqualTo(CreditTransferStatus.SETTLED);
+            assertThat(transfer.getSettledAt()).isNotNull();
+            assertThat(transfer.getTransferId()).startsWith("ct_");
+            assertThat(transfer.getMerchantId()).isEqualTo(merchantId);
+        }
+        CreditTransfer future = pacs.getTransfers().get(0);
+        assertThat(future.getStatus()).isEqualTo(CreditTransferStatus.ACCEPTED);
+        assertThat(future.getStatus().getIsoCode()).isEqualTo("ACCP");
+        assertThat(future.getSettledAt()).isNull();
+        verify(repository).saveAll(pain.getTransfers());
+    }
+    
+    @Test
+    void shouldRejectTransactionsWithReasonCodes() {
+        String xml = Iso20022ParserTest.PAIN_001
+            .replace("GB82WEST12345698765432", "GB82WEST12345698765433")
+            .replace("<EndToEndId>E2E-2</EndToEndId>", "<EndToEndId>E2E-1</EndToEndId>");
+        
+        List<CreditTransfer> transfers = service.ingest(merchantId, xml, TODAY).getTransfers();
+        
+        assertThat(transfers.get(0).getStatus()).isEqualTo(CreditTransferStatus.REJECTED);
+        assertThat(transfers.get(0).getReasonCode()).isEqualTo("AC03");
+        // The first E2E-1 was rejected, so the second is not a duplicate of it
+        assertThat(transfers.get(1).getStatus()).isEqualTo(CreditTransferStatus.SETTLED);
+        
+        String badAmount = Iso20022ParserTest.PAIN_001
+            .replace("MSG-001", "MSG-002")
+            .replace("<InstdAmt Ccy=\"EUR\">50.25</InstdAmt>", "<InstdAmt Ccy=\"JPY\">50.25</InstdAmt>")
+            .replace("<BICFI>NWBKGB2L</BICFI>", "<BICFI>NWBK</BICFI>");
+        transfers = service.ingest(merchantId, badAmount, TODAY).getTransfers();
+        assertThat(transfers.get(0).getReasonCode()).isEqualTo("RC04");
+        assertThat(transfers.get(1).getReasonCode()).isEqualTo("AM12");
+    }
+    
+    @Test
+    void shouldRejectEndToEndIdsAlreadyUsed() {
+        when(repository.existsByMerchantIdAndEndToEndIdAndStatusNot(merchantId, "E2E-2", CreditTransferStatus.REJECTED))
+            .thenReturn(true);
+        
+        List<CreditTransfer> transfers = service.ingest(merchantId, Iso20022ParserTest.PAIN_001, TODAY).getTransfers();
+        
+        assertThat(transfers.get(0).getStatus()).isEqualTo(CreditTransferStatus.SETTLED);
+        assertThat(transfers.get(1).getReasonCode()).isEqualTo("AM05");
+    }
+    
+    @Test
+    void shouldRejectMessagesWhoseHeaderDoesNotMatch() {
+        assertReason(Iso20022ParserTest.PAIN_001.replace("<NbOfTxs>2</NbOfTxs>", "<NbOfTxs>3</NbOfTxs>"), "AM18");
+        assertReason(Iso20022ParserTest.PAIN_001.replace("<CtrlSum>150.25</CtrlSum>", "<CtrlSum>150.26</CtrlSum>"), "AM10");
+        
+        when(repository.existsByMerchantIdAndMessageId(merchantId, "MSG-001")).thenReturn(true);
+        assertReason(Iso20022ParserTest.PAIN_001, "AM05");
+        verify(repository, never()).saveAll(any());
+    }
+    
+    @Test
+    void shouldValidateIbanCheckDigits() {
+        assertThat(CreditTransferService.isValidIban("DE89370400440532013000")).isTrue();
+        assertThat(CreditTransferService.isValidIban("FR1420041010050500013M02606")).isTrue();
+        assertThat(CreditTransferService.isValidIban("DE89370400440532013001")).isFalse();
+        assertThat(CreditTransferService.isValidIban("DE89 3704")).isFalse();
+        assertThat(CreditTransferService.isValidIban(null)).isFalse();
+    }
+    
+    private void assertReason(String xml, String reasonCode) {
+        assertThatThrownBy(() -> service.ingest(merchantId, xml, TODAY))
+            .isInstanceOf(InvalidIso20022MessageException.class)
+            .extracting(e -> ((InvalidIso20022MessageException) e).getReasonCode())
+            .isEqualTo(reasonCode);
+    }
+}
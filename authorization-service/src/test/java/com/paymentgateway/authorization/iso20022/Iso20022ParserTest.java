@@ -0,0 +1,145 @@
+package com.paymentgateway.authorization.iso20022;
+
+import com.paymentgateway.authorization.domain.CreditTransfer;
+import org.junit.jupiter.api.Test;
+
+import java.time.LocalDate;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+
+class Iso20022ParserTest {
+    
+    static final String PAIN_001 = """
+        <?xml version="1.0" encoding="UTF-8"?>
+        <Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09">
+          <CstmrCdtTrfInitn>
+            <GrpHdr>
+              <MsgId>MSG-001</MsgId>
+              <CreDtTm>2026-10-16T09:00:00</CreDtTm>
+              <NbOfTxs>2</NbOfTxs>
+              <CtrlSum>150.25</CtrlSum>
+              <InitgPty><Nm>Acme Ltd</Nm></InitgPty>
+            </GrpHdr>
+            <PmtInf>
+              <PmtInfId>PMT-1</PmtInfId>
+              <PmtMtd>TRF</PmtMtd>
+              <ReqdExctnDt><Dt>2026-10-16</Dt></ReqdExctnDt>
+              <Dbtr><Nm>Acme Ltd</Nm></Dbtr>
+              <DbtrAcct><Id><IBAN>DE89370400440532013000</IBAN></Id></DbtrAcct>
+              <DbtrAgt><FinInstnId><BICFI>COBADEFFXXX</BICFI></FinInstnId></DbtrAgt>
+              <CdtTrfTxInf>
+                <PmtId><InstrId>I-1</InstrId><EndToEndId>E2E-1</EndToEndId></PmtId>
+                <Amt><InstdAmt Ccy="EUR">100.00</InstdAmt></Amt>
+                <CdtrAgt><FinInstnId><BICFI>NWBKGB2L</BICFI></FinInstnId></CdtrAgt>
+                <Cdtr><Nm>Supplier One</Nm></Cdtr>
+                <CdtrAcct><Id><IBAN>GB82WEST12345698765432</IBAN></Id></CdtrAcct>
+                <RmtInf><Ustrd>Invoice 42</Ustrd></RmtInf>
+              </CdtTrfTxInf>
+              <CdtTrfTxInf>
+                <PmtId><EndToEndId>E2E-2</EndToEndId></PmtId>
+                <Amt><InstdAmt Ccy="EUR">50.25</InstdAmt></Amt>
+                <Cdtr><Nm>Supplier Two</Nm></Cdtr>
+                <CdtrAcct><Id><IBAN>FR1420041010050500013M02606</IBAN></Id></CdtrAcct>
+              </CdtTrfTxInf>
+            </PmtInf>
+          </CstmrCdtTrfInitn>
+        </Document>
+        """.stripIndent().strip();
+    
+    static final String PACS_008 = """
+        <Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.008.001.02">
+          <FIToFICstmrCdtTrf>
+            <GrpHdr>
+              <MsgId>PACS-001</MsgId>
+              <CreDtTm>2026-10-16T09:00:00</CreDtTm>
+              <NbOfTxs>1</NbOfTxs>
+              <TtlIntrBkSttlmAmt Ccy="EUR">75.00</TtlIntrBkSttlmAmt>
+              <IntrBkSttlmDt>2026-10-20</IntrBkSttlmDt>
+              <SttlmInf><SttlmMtd>CLRG</SttlmMtd></SttlmInf>
+            </GrpHdr>
+            <CdtTrfTxInf>
+              <PmtId><InstrId>I-9</InstrId><EndToEndId>E2E-9</EndToEndId><TxId>TX-9</TxId></PmtId>
+              <IntrBkSttlmAmt Ccy="EUR">75.00</IntrBkSttlmAmt>
+              <ChrgBr>SLEV</ChrgBr>
+              <Dbtr><Nm>Jane Doe</Nm></Dbtr>
+              <DbtrAcct><Id><IBAN>DE89370400440532013000</IBAN></Id></DbtrAcct>
+              <DbtrAgt><FinInstnId><BIC>COBADEFF</BIC></FinInstnId></DbtrAgt>
+              <CdtrAgt><FinInstnId><BIC>NWBKGB2L</BIC></FinInstnId></CdtrAgt>
+              <Cdtr><Nm>Shop</Nm></Cdtr>
+              <CdtrAcct><Id><IBAN>GB82WEST12345698765432</IBAN></Id></CdtrAcct>
+            </CdtTrfTxInf>
+          </FIToFICstmrCdtTrf>
+        </Document>
+        """;
+    
+    @Test
+    void shouldReadPain001PaymentInformationIntoEachTransaction() {
+        Iso20022Message message = Iso20022Parser.parse(PAIN_001);
+        
+        assertThat(message.getType()).isEqualTo(Iso20022MessageType.PAIN_001);
+        assertThat(message.getMessageId()).isEqualTo("MSG-001");
+        assertThat(message.getNumberOfTransactions()).isEqualTo(2);
+        assertThat(message.getControlSum()).isEqualByComparingTo("150.25");
+        assertThat(message.getTransfers()).hasSize(2);
+        
+        CreditTransfer first = message.getTransfers().get(0);
+        assertThat(first.getPaymentInfoId()).isEqualTo("PMT-1");
+        assertThat(first.getInstructionId()).isEqualTo("I-1");
+        assertThat(first.getEndToEndId()).isEqualTo("E2E-1");
+        assertThat(first.getAmount()).isEqualByComparingTo("100.00");
+        assertThat(first.getCurrency()).isEqualTo("EUR");
+        assertThat(first.getExecutionDate()).isEqualTo(LocalDate.of(2026, 10, 16));
+        assertThat(first.getDebtorIban()).isEqualTo("DE89370400440532013000");
+        assertThat(first.getDebtorAgentBic()).isEqualTo("COBADEFFXXX");
+        assertThat(first.getCreditorName()).isEqualTo("Supplier One");
+        assertThat(first.getCreditorAgentBic()).isEqualTo("NWBKGB2L");
+        assertThat(first.getRemittanceInfo()).isEqualTo("Invoice 42");
+        
+        CreditTransfer second = message.getTransfers().get(1);
+        assertThat(second.getDebtorName()).isEqualTo("Acme Ltd");
+        assertThat(second.getCreditorAgentBic()).isNull();
+        assertThat(second.getMessageId()).isEqualTo("MSG-001");
+    }
+    
+    @Test
+    void shouldReadPacs008WithTheHeaderSettlementDate() {
+        Iso20022Message message = Iso20022Parser.parse(PACS_008);
+        
+        assertThat(message.getType()).isEqualTo(Iso20022MessageType.PACS_008);
+        assertThat(message.getControlSum()).isEqualByComparingTo("75.00");
+        CreditTransfer transfer = message.getTransfers().get(0);
+        assertThat(transfer.getTransactionId()).isEqualTo("TX-9");
+        assertThat(transfer.getExecutionDate()).isEqualTo(LocalDate.of(2026, 10, 20));
+        assertThat(transfer.getDebtorAgentBic()).isEqualTo("COBADEFF");
+        assertThat(transfer.getDebtorName()).isEqualTo("Jane Doe");
+        assertThat(transfer.getPaymentInfoId()).isNull();
+    }
+    
+    @Test
+    void shouldRejectMessagesItCannotRead() {
+        assertThatThrownBy(() -> Iso20022Parser.parse("<Document><pacs.002/></Document>"))
+            .isInstanceOf(InvalidIso20022MessageException.class);
+        assertThatThrownBy(() -> Iso20022Parser.parse("<Document><FIToFIPmtStsRpt/></Document>"))
+            .isInstanceOf(InvalidIso20022MessageException.class)
+            .hasMessageContaining("unsupported message FIToFIPmtStsRpt");
+        assertThatThrownBy(() -> Iso20022Parser.parse(PAIN_001.replace("<EndToEndId>E2E-2</EndToEndId>", "")))
+            .isInstanceOf(InvalidIso20022MessageException.class)
+            .hasMessage("CdtTrfTxInf/PmtId/EndToEndId is required");
+        assertThatThrownBy(() -> Iso20022Parser.parse(PAIN_001.replace("E2E-2", "E".repeat(36))))
+            .isInstanceOf(InvalidIso20022MessageException.class)
+            .hasMessage("EndToEndId is longer than 35 characters");
+    }
+    
+    @Test
+    void shouldRefuseDocumentTypeDeclarations() {
+        String xxe = "<?xml version=\"1.0\"?><!DOCTYPE Document [<!ENTITY x SYSTEM \"file:///etc/passwd\">]>"
+            + "<Document><CstmrCdtTrfInitn><GrpHdr><MsgId>&x;</MsgId></GrpHdr></CstmrCdtTrfInitn></Document>";
+        
+        assertThatThrownBy(() -> Iso20022Parser.parse(xxe))
+            .isInstanceOf(InvalidIso20022MessageException.class)
+            .hasMessageStartingWith("not well-formed XML")
+            .extracting(e -> ((InvalidIso20022MessageException) e).getReasonCode())
+            .isEqualTo("FF01");
+    }
+}
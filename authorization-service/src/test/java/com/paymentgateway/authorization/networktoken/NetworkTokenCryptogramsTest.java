@@ -0,0 +1,82 @@
+package com.paymentgateway.authorization.networktoken;
+
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.*;
+
+class NetworkTokenCryptogramsTest {
+    
+    private static final String NETWORK_TOKEN = "4895370012345671";
+    private static final String CRYPTOGRAM = "AQIDBAUGBwgJCg==";
+    private static final UUID MERCHANT = UUID.randomUUID();
+    
+    private final List<Long> amounts = new ArrayList<>();
+    
+    @Test
+    void shouldLeavePansAlone() {
+        NetworkTokenCryptograms cryptograms = new NetworkTokenCryptograms("489537", validator(CryptogramCheck.Outcome.VALID));
+        
+        assertThat(cryptograms.check("4111111111111111", null, MERCHANT, new BigDecimal("10.00"), "USD")).isNull();
+        assertThat(amounts).isEmpty();
+    }
+    
+    @Test
+    void shouldValidateTheCryptogramForTheAmountInMinorUnits() {
+        NetworkTokenCryptograms cryptograms = new NetworkTokenCryptograms("489537", validator(CryptogramCheck.Outcome.VALID));
+        
+        CryptogramCheck usd = cryptograms.check(NETWORK_TOKEN, CRYPTOGRAM, MERCHANT, new BigDecimal("10.5"), "USD");
+        cryptograms.check(NETWORK_TOKEN, CRYPTOGRAM, MERCHANT, new BigDecimal("1050"), "JPY");
+        
+        assertThat(usd.isValid()).isTrue();
+        assertThat(usd.getDeclineCode()).isNull();
+        assertThat(amounts).containsExactly(1050L, 1050L);
+    }
+    
+    @Test
+    void shouldDeclineNetworkTokensWithoutAValidCryptogram() {
+        NetworkTokenCryptograms cryptograms = new NetworkTokenCryptograms("489537", validator(CryptogramCheck.Outcome.INVALID));
+        
+        CryptogramCheck missing = cryptograms.check(NETWORK_TOKEN, null, MERCHANT, BigDecimal.TEN, "USD");
+        CryptogramCheck invalid = cryptograms.check(NETWORK_TOKEN, CRYPTOGRAM, MERCHANT, BigDecimal.TEN, "USD");
+        
+        assertThat(missing.getOutcome()).isEqualTo(CryptogramCheck.Outcome.MISSING);
+        assertThat(missing.getDeclineCode()).isEqualTo(CryptogramCheck.CRYPTOGRAM_FAILURE);
+        assertThat(invalid.getDeclineCode()).isEqualTo(CryptogramCheck.CRYPTOGRAM_FAILURE);
+        assertThat(invalid.getMessage()).endsWith("already used");
+    }
+    
+    @Test
+    void shouldDeclineWhenTheTokenServiceCannotBeReached() {
+        NetworkTokenCryptograms unconfigured = new NetworkTokenCryptograms("489537", null);
+        NetworkTokenCryptograms failing = new NetworkTokenCryptograms("489537",
+            (token, merchantId, amount, currency, cryptogram) -> {
+                throw new IllegalStateException("connection refused");
+            });
+        
+        assertThat(unconfigured.check(NETWORK_TOKEN, CRYPTOGRAM, MERCHANT, BigDecimal.TEN, "USD").getDeclineCode())
+            .isEqualTo(CryptogramCheck.SWITCH_UNAVAILABLE);
+        assertThat(failing.check(NETWORK_TOKEN, CRYPTOGRAM, MERCHANT, BigDecimal.TEN, "USD").getOutcome())
+            .isEqualTo(CryptogramCheck.Outcome.UNAVAILABLE);
+    }
+    
+    @Test
+    void shouldRejectInvalidBins() {
+        assertThatThrownBy(() -> new NetworkTokenCryptograms("4895x7", null))
+            .hasMessageContaining("Invalid network token BIN");
+    }
+    
+    private CryptogramValidator validator(CryptogramCheck.Outcome outcome) {
+        return (token, merchantId, amount, currency, cryptogram) -> {
+            assertThat(token).isEqualTo(NETWORK_TOKEN);
+            assertThat(merchantId).isEqualTo(MERCHANT.toString());
+            assertThat(cryptogram).hasSize(10);
+            amounts.add(amount);
+            return CryptogramCheck.of(outcome, outcome == CryptogramCheck.Outcome.INVALID ? "already used" : null);
+        };
+    }
+}
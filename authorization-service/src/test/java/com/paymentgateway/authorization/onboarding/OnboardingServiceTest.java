@@ -0,0 +1,216 @@
+package com.paymentgateway.authorization.onboarding;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.MerchantOnboarding;
+import com.paymentgateway.authorization.domain.OnboardingStatus;
+import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import com.paymentgateway.authorization.repository.MerchantOnboardingRepository;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.screening.ScreeningHoldException;
+import com.paymentgateway.authorization.screening.ScreeningMatch;
+import com.paymentgateway.authorization.screening.ScreeningService;
+import com.paymentgateway.authorization.screening.ScreeningSubject;
+import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+
+import java.time.Clock;
+import java.time.Instant;
+import java.time.ZoneOffset;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.Mockito.*;
+
+class OnboardingServiceTest {
+    
+    private static final Instant NOW = Instant.parse("2024-03-01T10:00:00Z");
+    
+    @Mock
+    private MerchantRepository merchantRepository;
+    
+    @Mock
+    private MerchantOnboardingRepository onboardingRepository;
+    
+    @Mock
+    private MerchantAuthenticationService authenticationService;
+    
+    @Mock
+    private PaymentEventPublisher eventPublisher;
+    
+    @Mock
+    private ScreeningService screeningService;
+    
+    private final Map<UUID, MerchantOnboarding> applications = new HashMap<>();
+    
+    private OnboardingService onboardingService;
+    
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        onboardingService = new OnboardingService(merchantRepository, onboardingRepository,
+                authenticationService, eventPublisher, screeningService, Clock.fixed(NOW, ZoneOffset.UTC));
+        when(authenticationService.createApiKey(any(Merchant.class))).thenAnswer(inv -> {
+            Merchant merchant = inv.getArgument(0);
+            merchant.setId(UUID.randomUUID());
+            return "sk_test";
+        });
+        when(authenticationService.createLiveApiKey(any(Merchant.class))).thenReturn("sk_live_test");
+        when(onboardingRepository.save(any(MerchantOnboarding.class))).thenAnswer(inv -> {
+            MerchantOnboarding onboarding = inv.getArgument(0);
+            applications.put(onboarding.getMerchantId(), onboarding);
+            return onboarding;
+        });
+        when(onboardingRepository.findById(any(UUID.class)))
+            .thenAnswer(inv -> Optional.ofNullable(applications.get(inv.<UUID>getArgument(0))));
+    }
+    
+    @Test
+    void shouldCreateDraftMerchantWithTestKey() {
+        OnboardingService.Application application = onboardingService.apply(" Corner Shop ", "5411", "US", "USD");
+        
+        Merchant merchant = application.getMerchant();
+        assertThat(merchant.getMerchantId()).matches("mch_[a-z0-9]{12}");
+        assertThat(merchant.getMerchantName()).isEqualTo("Corner Shop");
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.DRAFT);
+        assertThat(merchant.getRoles()).containsExactly("MERCHANT");
+        assertThat(application.getApiKey()).isEqualTo("sk_test");
+        assertThat(application.getOnboarding().getMerchantId()).isEqualTo(merchant.getId());
+        verify(eventPublisher).publishMerchantEvent(merchant, PaymentEventType.MERCHANT_ONBOARDING_UPDATED);
+    }
+    
+    @Test
+    void shouldWalkAnApplicationThroughToLive() {
+        Merchant merchant = onboardingService.apply("Corner Shop", "5411", "US", "USD").getMerchant();
+        complete(merchant);
+        
+        onboardingService.submit(merchant);
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.DOCUMENTS_SUBMITTED);
+        onboardingService.startReview(merchant, "ADMIN_001");
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.UNDER_REVIEW);
+        MerchantOnboarding onboarding = onboardingService.approve(merchant, "ADMIN_001");
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.APPROVED);
+        assertThat(onboarding.getReviewedBy()).isEqualTo("ADMIN_001");
+        assertThat(onboarding.getDecidedAt()).isEqualTo(NOW);
+        
+        assertThat(onboardingService.issueLiveKey(merchant)).isEqualTo("sk_live_test");
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.LIVE);
+        assertThat(onboarding.getLiveAt()).isEqualTo(NOW);
+        // Applied, submitted, under review, approved and live
+        verify(eventPublisher, times(5)).publishMerchantEvent(merchant, PaymentEventType.MERCHANT_ONBOARDING_UPDATED);
+    }
+    
+    @Test
+    void shouldRejectSubmissionMissingWhatTheMccRequires() {
+        Merchant merchant = onboardingService.apply("Lucky Bets", "7995", "GB", "GBP").getMerchant();
+        complete(merchant);
+        
+        assertThatThrownBy(() -> onboardingService.submit(merchant))
+            .isInstanceOfSatisfying(OnboardingException.class, e -> {
+                assertThat(e.getCode()).isEqualTo(OnboardingException.REQUIREMENTS_MISSING);
+                assertThat(e.getMissing()).containsExactly("license_number", "LICENSE");
+            });
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.DRAFT);
+        
+        onboardingService.updateDetails(merchant, Map.of("license_number", "GC-000123"));
+        onboardingService.addDocument(merchant, "LICENSE", "s3://kyc/licence.pdf");
+        onboardingService.submit(merchant);
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.DOCUMENTS_SUBMITTED);
+    }
+    
+    @Test
+    void shouldRequireWebsiteAndRefundPolicyForDirectMarketing() {
+        assertThat(OnboardingRequirements.forMcc("5968").getFields())
+            .contains("website", "refund_policy_url")
+            .doesNotContain("license_number");
+        assertThat(OnboardingRequirements.forMcc("5411").getFields())
+            .doesNotContain("website", "license_number");
+    }
+    
+    @Test
+    void shouldNotIssueLiveKeyBeforeApproval() {
+        Merchant merchant = onboardingService.apply("Corner Shop", "5411", "US", "USD").getMerchant();
+        
+        assertThatThrownBy(() -> onboardingService.issueLiveKey(merchant))
+            .isInstanceOfSatisfying(OnboardingException.class,
+                e -> assertThat(e.getCode()).isEqualTo(OnboardingException.NOT_APPROVED));
+        verify(authenticationService, never()).createLiveApiKey(any());
+    }
+    
+    @Test
+    void shouldRefuseTransitionsOutOfOrder() {
+        Merchant merchant = onboardingService.apply("Corner Shop", "5411", "US", "USD").getMerchant();
+        
+        assertThatThrownBy(() -> onboardingService.approve(merchant, "ADMIN_001"))
+            .isInstanceOfSatisfying(OnboardingException.class,
+                e -> assertThat(e.getCode()).isEqualTo(OnboardingException.INVALID_TRANSITION));
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.DRAFT);
+    }
+    
+    @Test
+    void shouldFreezeApplicationOnceSubmittedAndEndOnRejection() {
+        Merchant merchant = onboardingService.apply("Corner Shop", "5411", "US", "USD").getMerchant();
+        complete(merchant);
+        onboardingService.submit(merchant);
+        
+        assertThatThrownBy(() -> onboardingService.updateDetails(merchant, Map.of("legal_name", "Other Ltd")))
+            .isInstanceOf(OnboardingException.class);
+        
+        onboardingService.startReview(merchant, "ADMIN_001");
+        MerchantOnboarding onboarding = onboardingService.reject(merchant, "ADMIN_001", "Address not verified");
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.REJECTED);
+        assertThat(onboarding.getRejectionReason()).isEqualTo("Address not verified");
+        assertThatThrownBy(() -> onboardingService.issueLiveKey(merchant))
+            .isInstanceOf(OnboardingException.class);
+    }
+    
+    @Test
+    void shouldHoldApprovalOfMerchantMatchedByScreening() {
+        Merchant merchant = onboardingService.apply("Corner Shop", "5411", "US", "USD").getMerchant();
+        complete(merchant);
+        List<ScreeningMatch> matches = List.of(new ScreeningMatch("denylist", "name sam lee"));
+        when(screeningService.screen(any(ScreeningSubject.class)))
+            .thenAnswer(inv -> "Sam Lee".equals(inv.<ScreeningSubject>getArgument(0).getName()) ? matches : List.of());
+        
+        onboardingService.submit(merchant);
+        verify(screeningService).hold(any(ScreeningSubject.class), eq(merchant.getId()), isNull(), eq(matches));
+        
+        onboardingService.startReview(merchant, "ADMIN_001");
+        doThrow(new ScreeningHoldException("held")).when(screeningService).checkMerchantCleared(merchant);
+        assertThatThrownBy(() -> onboardingService.approve(merchant, "ADMIN_001"))
+            .isInstanceOf(ScreeningHoldException.class);
+        assertThat(merchant.getOnboardingStatus()).isEqualTo(OnboardingStatus.UNDER_REVIEW);
+    }
+    
+    @Test
+    void shouldRejectUnknownFieldsAndDocuments() {
+        Merchant merchant = onboardingService.apply("Corner Shop", "5411", "US", "USD").getMerchant();
+        
+        assertThatThrownBy(() -> onboardingService.updateDetails(merchant, Map.of("favourite_colour", "blue")))
+            .isInstanceOf(IllegalArgumentException.class);
+        assertThatThrownBy(() -> onboardingService.addDocument(merchant, "SELFIE", "s3://kyc/me.jpg"))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    private void complete(Merchant merchant) {
+        onboardingService.updateDetails(merchant, Map.of(
+            "legal_name", "Corner Shop Ltd",
+            "registration_number", "12345678",
+            "tax_id", "98-7654321",
+            "business_address", "1 High Street, Springfield",
+            "representative_name", "Sam Lee"));
+        for (String document : List.of("CERTIFICATE_OF_INCORPORATION", "REPRESENTATIVE_ID",
+                "PROOF_OF_ADDRESS", "BANK_STATEMENT")) {
+            onboardingService.addDocument(merchant, document, "s3://kyc/" + document.toLowerCase());
+        }
+    }
+}
@@ -0,0 +1,106 @@
+package com.paymentgateway.authorization.outbox;
+
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.fasterxml.jackson.datatype.jsr310.JavaTimeModule;
+import com.paymentgateway.authorization.event.PaymentEventMessage;
+import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import com.paymentgateway.authorization.webhook.WebhookService;
+import io.micrometer.core.instrument.simple.SimpleMeterRegistry;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.springframework.kafka.support.SendResult;
+
+import java.time.Duration;
+import java.time.Instant;
+import java.util.List;
+import java.util.UUID;
+import java.util.concurrent.CompletableFuture;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for publishing outbox events.
+ */
+class OutboxDispatcherTest {
+    
+    private OutboxRepository repository;
+    private PaymentEventPublisher publisher;
+    private WebhookService webhookService;
+    private ObjectMapper objectMapper;
+    private SimpleMeterRegistry registry;
+    private OutboxDispatcher dispatcher;
+    
+    @BeforeEach
+    void setUp() {
+        repository = mock(OutboxRepository.class);
+        publisher = mock(PaymentEventPublisher.class);
+        webhookService = mock(WebhookService.class);
+        objectMapper = new ObjectMapper();
+        objectMapper.registerModule(new JavaTimeModule());
+        registry = new SimpleMeterRegistry();
+        dispatcher = new OutboxDispatcher(repository, publisher, webhookService, objectMapper, registry,
+                100, Duration.ofSeconds(1), Duration.ofHours(72));
+    }
+    
+    @Test
+    void shouldPublishEventThenScheduleWebhookAndMarkPublished() throws Exception {
+        // Given
+        OutboxEvent event = outboxEvent(1, 0);
+        when(repository.claimDue(100)).thenReturn(List.of(event));
+        when(publisher.send(any(PaymentEventMessage.class), eq("pay_1")))
+                .thenReturn(CompletableFuture.completedFuture(mock(SendResult.class)));
+        
+        // When
+        int published = dispatcher.dispatch();
+        
+        // Then
+        assertThat(published).isEqualTo(1);
+        var order = inOrder(publisher, webhookService, repository);
+        order.verify(publisher).send(any(PaymentEventMessage.class), eq("pay_1"));
+        order.verify(webhookService).scheduleDelivery(eq(event.getMerchantId()), eq(event.getPaymentId()),
+                any(PaymentEventMessage.class));
+        order.verify(repository).markPublished(1);
+        assertThat(registry.get("outbox.events.published.total").counter().count()).isEqualTo(1.0);
+    }
+    
+    @Test
+    void shouldRetryWithBackoffWhenKafkaFails() throws Exception {
+        // Given
+        when(repository.claimDue(100)).thenReturn(List.of(outboxEvent(1, 2), outboxEvent(2, 0)));
+        when(publisher.send(any(PaymentEventMessage.class), anyString()))
+                .thenReturn(CompletableFuture.failedFuture(new IllegalStateException("broker down")))
+                .thenReturn(CompletableFuture.completedFuture(mock(SendResult.class)));
+        
+        // When
+        int published = dispatcher.dispatch();
+        
+        // Then - the failed event waits 4s (third attempt); the next is still published
+        assertThat(published).isEqualTo(1);
+        verify(repository).markFailed(eq(1L), contains("broker down"), eq(Duration.ofSeconds(4)));
+        verify(repository, never()).markPublished(1);
+        verify(repository).markPublished(2);
+        verify(webhookService, times(1)).scheduleDelivery(any(), any(), any());
+        assertThat(registry.get("outbox.events.failed.total").counter().count()).isEqualTo(1.0);
+    }
+    
+    @Test
+    void shouldCapRetryDelay() {
+        assertThat(OutboxDispatcher.retryDelay(1)).isEqualTo(Duration.ofSeconds(1));
+        assertThat(OutboxDispatcher.retryDelay(4)).isEqualTo(Duration.ofSeconds(8));
+        assertThat(OutboxDispatcher.retryDelay(50)).isEqualTo(Duration.ofMinutes(5));
+    }
+    
+    private OutboxEvent outboxEvent(long id, int attempts) throws Exception {
+        PaymentEventMessage message = new PaymentEventMessage();
+        message.setEventId("evt_" + id);
+        message.setEventType(PaymentEventType.PAYMENT_CAPTURED);
+        message.setTimestamp(Instant.now());
+        return new OutboxEvent(id, "evt_" + id, "PAYMENT_CAPTURED", "pay_" + id, UUID.randomUUID(),
+                UUID.randomUUID(), objectMapper.writeValueAsString(message), attempts);
+    }
+}
@@ -0,0 +1,63 @@
+package com.paymentgateway.authorization.pagination;
+
+import org.junit.jupiter.api.DisplayName;
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+
+class PageTokenTest {
+
+    @Test
+    @DisplayName("Should round-trip a key for the same filters")
+    void shouldRoundTripKey() {
+        String fingerprint = PageToken.fingerprint("merchant-1", true);
+        String token = PageToken.encode(fingerprint, List.of("2026-03-01T12:00:00Z", "id-7"));
+
+        assertThat(token).doesNotContain("=", "+", "/");
+        assertThat(PageToken.decode(token, fingerprint)).containsExactly("2026-03-01T12:00:00Z", "id-7");
+        assertThat(PageToken.decode(null, fingerprint)).isNull();
+        assertThat(PageToken.decode("", fingerprint)).isNull();
+    }
+
+    @Test
+    @DisplayName("Should reject malformed tokens and tokens for other filters")
+    void shouldRejectForeignTokens() {
+        String token = PageToken.encode(PageToken.fingerprint("merchant-1"), List.of("a"));
+
+        assertThatThrownBy(() -> PageToken.decode(token, PageToken.fingerprint("merchant-2")))
+            .isInstanceOf(InvalidPageTokenException.class)
+            .hasMessageContaining("different query");
+        assertThatThrownBy(() -> PageToken.decode("not base64!", PageToken.fingerprint("merchant-1")))
+            .isInstanceOf(InvalidPageTokenException.class);
+        assertThatThrownBy(() -> PageToken.decode("e30", PageToken.fingerprint("merchant-1")))
+            .isInstanceOf(InvalidPageTokenException.class);
+    }
+
+    @Test
+    @DisplayName("Should only issue a next token when an extra row was fetched")
+    void shouldIssueNextTokenOnlyWhenMoreRowsExist() {
+        String fingerprint = PageToken.fingerprint();
+
+        CursorPage<String> first = PageToken.page(List.of("a", "b", "c"), 2, fingerprint, List::of);
+        assertThat(first.getItems()).containsExactly("a", "b");
+        assertThat(PageToken.decode(first.getNextPageToken(), fingerprint)).containsExactly("b");
+
+        CursorPage<String> last = PageToken.page(List.of("c"), 2, fingerprint, List::of);
+        assertThat(last.getItems()).containsExactly("c");
+        assertThat(last.isHasNext()).isFalse();
+    }
+
+    @Test
+    @DisplayName("Should apply the default and maximum page size")
+    void shouldBoundPageSize() {
+        PageLimits limits = new PageLimits(20, 100);
+
+        assertThat(limits.size(null)).isEqualTo(20);
+        assertThat(limits.size(0)).isEqualTo(20);
+        assertThat(limits.size(50)).isEqualTo(50);
+        assertThat(limits.size(500)).isEqualTo(100);
+    }
+}
@@ -49,7 +49,7 @@ public class EventSchemaValidationPropertyTest {
                 },
                 "event_type": {
                   "type": "string",
-                  "enum": ["PAYMENT_CREATED", "PAYMENT_AUTHORIZED", "PAYMENT_DECLINED", "PAYMENT_CAPTURED", "PAYMENT_CANCELLED", "PAYMENT_REFUNDED", "PAYMENT_FAILED"]
+                  "enum": ["PAYMENT_CREATED", "PAYMENT_AUTHORIZED", "PAYMENT_DECLINED", "PAYMENT_CAPTURED", "PAYMENT_CANCELLED", "PAYMENT_REFUNDED", "PAYMENT_FAILED", "PAYMENT_SETTLED", "DISPUTE_OPENED", "DISPUTE_EVIDENCE_SUBMITTED", "DISPUTE_WON", "DISPUTE_LOST", "MERCHANT_ONBOARDING_UPDATED"]
                 },
                 "timestamp": {
                   "type": "string",
@@ -44,10 +44,10 @@ class PSPFailoverPropertyTest {
             .thenReturn(configs);
         
         // Create PSP clients where primary fails
-        StripePSPClient stripePSPClient = new StripePSPClient();
+        StripePSPClient stripePSPClient = new StripePSPClient(network());
         stripePSPClient.setAvailable(false); // Make primary unavailable
         
-        AdyenPSPClient adyenPSPClient = new AdyenPSPClient();
+        AdyenPSPClient adyenPSPClient = new AdyenPSPClient(network());
         adyenPSPClient.setAvailable(true); // Secondary is available
         
         List<PSPClient> pspClients = Arrays.asList(stripePSPClient, adyenPSPClient);
@@ -82,8 +82,8 @@ class PSPFailoverPropertyTest {
         when(mockRepository.findByMerchantIdAndIsActiveTrueOrderByPriorityAsc(merchantId))
             .thenReturn(configs);
         
-        StripePSPClient stripePSPClient = new StripePSPClient();
-        AdyenPSPClient adyenPSPClient = new AdyenPSPClient();
+        StripePSPClient stripePSPClient = new StripePSPClient(network());
+        AdyenPSPClient adyenPSPClient = new AdyenPSPClient(network());
         
         List<PSPClient> pspClients = Arrays.asList(stripePSPClient, adyenPSPClient);
         PSPRoutingService pspRoutingService = new PSPRoutingService(mockRepository, pspClients);
@@ -120,8 +120,8 @@ class PSPFailoverPropertyTest {
         when(mockRepository.findByMerchantIdAndIsActiveTrueOrderByPriorityAsc(merchantId))
             .thenReturn(configs);
         
-        StripePSPClient stripePSPClient = new StripePSPClient();
-        AdyenPSPClient adyenPSPClient = new AdyenPSPClient();
+        StripePSPClient stripePSPClient = new StripePSPClient(network());
+        AdyenPSPClient adyenPSPClient = new AdyenPSPClient(network());
         
         List<PSPClient> pspClients = Arrays.asList(stripePSPClient, adyenPSPClient);
         PSPRoutingService pspRoutingService = new PSPRoutingService(mockRepository, pspClients);
@@ -158,4 +158,9 @@ class PSPFailoverPropertyTest {
         request.setCardTokenId(UUID.randomUUID());
         return request;
     }
+    
+    private static SimulatedNetwork network() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        return new SimulatedNetwork(issuer, new LatencyScenarios(""), new StandInProcessor(issuer));
+    }
 }
@@ -66,7 +66,7 @@ class PSPRequiredFieldsPropertyTest {
         request.setCardTokenId(cardTokenId);
         
         // When/Then: PSP client should reject the request
-        StripePSPClient pspClient = new StripePSPClient();
+        StripePSPClient pspClient = new StripePSPClient(network());
         assertThatThrownBy(() -> pspClient.authorize(request))
             .isInstanceOf(IllegalArgumentException.class)
             .hasMessageContaining("Merchant ID is required");
@@ -86,7 +86,7 @@ class PSPRequiredFieldsPropertyTest {
         request.setCardTokenId(cardTokenId);
         
         // When/Then: PSP client should reject the request
-        StripePSPClient pspClient = new StripePSPClient();
+        StripePSPClient pspClient = new StripePSPClient(network());
         assertThatThrownBy(() -> pspClient.authorize(request))
             .isInstanceOf(IllegalArgumentException.class)
             .hasMessageContaining("Amount");
@@ -106,7 +106,7 @@ class PSPRequiredFieldsPropertyTest {
         request.setCardTokenId(cardTokenId);
         
         // When/Then: PSP client should reject the request
-        StripePSPClient pspClient = new StripePSPClient();
+        StripePSPClient pspClient = new StripePSPClient(network());
         assertThatThrownBy(() -> pspClient.authorize(request))
             .isInstanceOf(IllegalArgumentException.class)
             .hasMessageContaining("Currency is required");
@@ -126,7 +126,7 @@ class PSPRequiredFieldsPropertyTest {
         request.setCardTokenId(null); // Missing card token
         
         // When/Then: PSP client should reject the request
-        StripePSPClient pspClient = new StripePSPClient();
+        StripePSPClient pspClient = new StripePSPClient(network());
         assertThatThrownBy(() -> pspClient.authorize(request))
             .isInstanceOf(IllegalArgumentException.class)
             .hasMessageContaining("Card token ID is required");
@@ -148,4 +148,9 @@ class PSPRequiredFieldsPropertyTest {
     Arbitrary<String> currencies() {
         return Arbitraries.of("USD", "EUR", "GBP", "JPY", "CAD", "AUD");
     }
+    
+    private static SimulatedNetwork network() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        return new SimulatedNetwork(issuer, new LatencyScenarios(""), new StandInProcessor(issuer));
+    }
 }
@@ -27,8 +27,8 @@ import static org.mockito.Mockito.when;
 class PSPRoutingConsistencyPropertyTest {
     
     private PSPRoutingService createPSPRoutingService(PSPConfigurationRepository mockRepository) {
-        StripePSPClient stripePSPClient = new StripePSPClient();
-        AdyenPSPClient adyenPSPClient = new AdyenPSPClient();
+        StripePSPClient stripePSPClient = new StripePSPClient(network());
+        AdyenPSPClient adyenPSPClient = new AdyenPSPClient(network());
         List<PSPClient> pspClients = Arrays.asList(stripePSPClient, adyenPSPClient);
         return new PSPRoutingService(mockRepository, pspClients);
     }
@@ -166,4 +166,9 @@ class PSPRoutingConsistencyPropertyTest {
         request.setCardTokenId(UUID.randomUUID());
         return request;
     }
+    
+    private static SimulatedNetwork network() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        return new SimulatedNetwork(issuer, new LatencyScenarios(""), new StandInProcessor(issuer));
+    }
 }
@@ -6,6 +6,7 @@ import com.paymentgateway.authorization.dto.RefundResponse;
 import com.paymentgateway.authorization.psp.PSPClient;
 import com.paymentgateway.authorization.psp.PSPRefundResponse;
 import com.paymentgateway.authorization.psp.PSPRoutingService;
+import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.repository.PaymentEventRepository;
 import com.paymentgateway.authorization.repository.PaymentRepository;
 import com.paymentgateway.authorization.repository.RefundRepository;
@@ -97,7 +98,7 @@ class RefundAmountConstraintPropertyTest {
         com.paymentgateway.authorization.event.PaymentEventPublisher eventPublisher = 
             mock(com.paymentgateway.authorization.event.PaymentEventPublisher.class);
         RefundService service = new RefundService(refundRepository, paymentRepository, 
-            paymentEventRepository, pspRoutingService, eventPublisher);
+            paymentEventRepository, pspRoutingService, eventPublisher, mock(MerchantRepository.class), null, null);
         RefundResponse response = service.processRefund(request, merchantId);
         
         // Verify refund was accepted
@@ -154,7 +155,7 @@ class RefundAmountConstraintPropertyTest {
         com.paymentgateway.authorization.event.PaymentEventPublisher eventPublisher = 
             mock(com.paymentgateway.authorization.event.PaymentEventPublisher.class);
         RefundService service = new RefundService(refundRepository, paymentRepository, 
-            paymentEventRepository, pspRoutingService, eventPublisher);
+            paymentEventRepository, pspRoutingService, eventPublisher, mock(MerchantRepository.class), null, null);
         assertThatThrownBy(() -> service.processRefund(request, merchantId))
             .isInstanceOf(IllegalArgumentException.class)
             .hasMessageContaining("exceeds available balance");
@@ -222,7 +223,7 @@ class RefundAmountConstraintPropertyTest {
                 com.paymentgateway.authorization.event.PaymentEventPublisher eventPublisher = 
                     mock(com.paymentgateway.authorization.event.PaymentEventPublisher.class);
                 RefundService service = new RefundService(refundRepository, paymentRepository, 
-                    paymentEventRepository, pspRoutingService, eventPublisher);
+                    paymentEventRepository, pspRoutingService, eventPublisher, mock(MerchantRepository.class), null, null);
                 RefundResponse response = service.processRefund(request, merchantId);
                 
                 assertThat(response).isNotNull();
@@ -233,7 +234,7 @@ class RefundAmountConstraintPropertyTest {
                 com.paymentgateway.authorization.event.PaymentEventPublisher eventPublisher = 
                     mock(com.paymentgateway.authorization.event.PaymentEventPublisher.class);
                 RefundService service = new RefundService(refundRepository, paymentRepository, 
-                    paymentEventRepository, pspRoutingService, eventPublisher);
+                    paymentEventRepository, pspRoutingService, eventPublisher, mock(MerchantRepository.class), null, null);
                 assertThatThrownBy(() -> service.processRefund(request, merchantId))
                     .isInstanceOf(IllegalArgumentException.class);
                 break; // Stop after first rejection
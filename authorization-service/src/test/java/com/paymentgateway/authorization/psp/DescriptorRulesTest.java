@@ -0,0 +1,49 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.authorization.domain.CardBrand;
+import org.junit.jupiter.api.Test;
+
+import static org.assertj.core.api.Assertions.*;
+
+class DescriptorRulesTest {
+    
+    @Test
+    void shouldNormalizeAndCheckStaticDescriptors() {
+        assertThat(DescriptorRules.checkStatic("  Demo   store ")).isEqualTo("DEMO STORE");
+        
+        assertThatThrownBy(() -> DescriptorRules.checkStatic("ABC"))
+            .isInstanceOf(IllegalArgumentException.class);
+        assertThatThrownBy(() -> DescriptorRules.checkStatic("A VERY LONG STORE NAME"))
+            .isInstanceOf(IllegalArgumentException.class);
+        assertThatThrownBy(() -> DescriptorRules.checkStatic("DEMO*STORE"))
+            .hasMessageContaining("'*'");
+        // Valid for Visa but not for Amex, and static descriptors serve every brand
+        assertThatThrownBy(() -> DescriptorRules.checkStatic("DEMO #1"))
+            .hasMessageContaining("AMEX");
+        assertThatThrownBy(() -> DescriptorRules.checkStatic("12345"))
+            .hasMessageContaining("letter");
+    }
+    
+    @Test
+    void shouldComposeDynamicDescriptorsWithinTheBrandLength() {
+        assertThat(DescriptorRules.compose(CardBrand.VISA, "DEMOSTORE", null)).isEqualTo("DEMOSTORE");
+        assertThat(DescriptorRules.compose(CardBrand.VISA, "DEMOSTORE", "order 1234")).isEqualTo("DEMOSTORE*ORDER 1234");
+        
+        // 23 characters: within Visa's 25, over Mastercard's 22
+        assertThat(DescriptorRules.compose(CardBrand.VISA, "DEMOSTORE", "ORDER 1234567")).hasSize(23);
+        assertThatThrownBy(() -> DescriptorRules.compose(CardBrand.MASTERCARD, "DEMOSTORE", "ORDER 1234567"))
+            .hasMessageContaining("22");
+        
+        assertThat(DescriptorRules.compose(CardBrand.VISA, "DEMOSTORE", "ORDER #12")).endsWith("#12");
+        assertThatThrownBy(() -> DescriptorRules.compose(CardBrand.AMEX, "DEMOSTORE", "ORDER #12"))
+            .isInstanceOf(IllegalArgumentException.class);
+        assertThatThrownBy(() -> DescriptorRules.compose(CardBrand.VISA, "DEMOSTORE", "A*B"))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    @Test
+    void shouldDeriveADescriptorFromTheMerchantName() {
+        assertThat(DescriptorRules.fromName("Joe's Coffee & Bagels, Downtown")).isEqualTo("JOES COFFEE BAGELS");
+        assertThat(DescriptorRules.fromName(null)).isEqualTo("MERCHANT");
+    }
+}
@@ -0,0 +1,153 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.shared.rules.RuleSyntaxException;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.io.TempDir;
+
+import java.math.BigDecimal;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.attribute.FileTime;
+import java.time.Instant;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.*;
+
+class IssuerRulesTest {
+    
+    private static final String PAN = "4111111111111111";
+    private static final String CARD = CardFingerprint.of(PAN);
+    
+    private static final String RULES = """
+        rules:
+          - name: NO_GAMBLING
+            when: mcc = '7995'
+            response-code: 57
+            message: Transaction not permitted to cardholder
+          - name: AMEX_AVS_DOWN
+            when: brand = 'AMEX'
+            avs-result: U
+          - name: LARGE_CVV_UNCHECKED
+            when: amount >= 5000
+            cvv-result: P
+          - name: FORCE_APPROVE
+            when: amount = 12.34
+            response-code: '00'
+        """;
+    
+    private static PSPAuthorizationRequest request(String amount) {
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal(amount), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        request.setCardBrand("VISA");
+        request.setMerchantCategoryCode("5411");
+        return request;
+    }
+    
+    @Test
+    void shouldTakeEachOutcomeFromTheFirstMatchingRuleThatSetsIt() {
+        IssuerRules rules = IssuerRules.fromYaml(RULES);
+        
+        PSPAuthorizationRequest gambling = request("6000.00");
+        gambling.setMerchantCategoryCode("7995");
+        gambling.setCardBrand("AMEX");
+        IssuerRules.Outcome outcome = rules.evaluate(gambling, SimulatedIssuer.CVV_MATCH, SimulatedIssuer.AVS_FULL_MATCH);
+        assertThat(outcome.isDecline()).isTrue();
+        assertThat(outcome.getRule()).isEqualTo("NO_GAMBLING");
+        // Unquoted codes are read as numbers and kept as two digits
+        assertThat(outcome.getResponseCode()).isEqualTo(SimulatedIssuer.NOT_PERMITTED_TO_CARDHOLDER);
+        assertThat(outcome.getAvsResult()).isEqualTo(SimulatedIssuer.AVS_UNAVAILABLE);
+        assertThat(outcome.getCvvResult()).isEqualTo(SimulatedIssuer.CVV_NOT_PROCESSED);
+        assertThat(outcome.getTriggeredRules()).containsExactly("NO_GAMBLING", "AMEX_AVS_DOWN", "LARGE_CVV_UNCHECKED");
+        
+        // Without a matching rule the card data results stand
+        IssuerRules.Outcome none = rules.evaluate(request("20.00"), SimulatedIssuer.CVV_MATCH, null);
+        assertThat(none.getResponseCode()).isNull();
+        assertThat(none.isDecline()).isFalse();
+        assertThat(none.getCvvResult()).isEqualTo(SimulatedIssuer.CVV_MATCH);
+        assertThat(none.getAvsResult()).isNull();
+    }
+    
+    @Test
+    void shouldRejectInvalidRules() {
+        String[] documents = {
+            "rules: nope",
+            "rules:\n  - when: amount > 1\n    response-code: '05'\n",
+            "rules:\n  - name: A\n    response-code: '05'\n",
+            "rules:\n  - name: A\n    when: amount >>\n    response-code: '05'\n",
+            "rules:\n  - name: A\n    when: amount > 1\n",
+            "rules:\n  - name: A\n    when: amount > 1\n    response-code: '5'\n",
+            "rules:\n  - name: A\n    when: amount > 1\n    avs-result: Q\n",
+            "rules:\n  - name: A\n    when: amount > 1\n    cvv-result: X\n",
+            "rules:\n  - name: A\n    when: amount > 1\n    cvv-result: P\n  - name: A\n    when: amount > 2\n    cvv-result: P\n",
+        };
+        for (String document : documents) {
+            assertThatThrownBy(() -> IssuerRules.fromYaml(document))
+                .as(document)
+                .isInstanceOf(RuleSyntaxException.class);
+        }
+    }
+    
+    @Test
+    void pspShouldApplyIssuerRules() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00");
+        issuer.setAddress(PAN, "1 Main Street", "94105");
+        issuer.setRules(IssuerRules.fromYaml(RULES));
+        StripePSPClient stripe = new StripePSPClient(network(issuer));
+        
+        PSPAuthorizationRequest gambling = request("20.00");
+        gambling.setMerchantCategoryCode("7995");
+        gambling.setBillingZip("94105");
+        PSPAuthorizationResponse declined = stripe.authorize(gambling);
+        assertThat(declined.isSuccess()).isFalse();
+        assertThat(declined.getDeclineCode()).isEqualTo(SimulatedIssuer.NOT_PERMITTED_TO_CARDHOLDER);
+        assertThat(declined.getAvsResult()).isEqualTo(SimulatedIssuer.AVS_ZIP_ONLY);
+        // A declined payment holds no funds
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("100.00");
+        
+        PSPAuthorizationRequest amex = request("20.00");
+        amex.setCardBrand("AMEX");
+        amex.setBillingZip("94105");
+        PSPAuthorizationResponse approved = stripe.authorize(amex);
+        assertThat(approved.isSuccess()).isTrue();
+        assertThat(approved.getAvsResult()).isEqualTo(SimulatedIssuer.AVS_UNAVAILABLE);
+    }
+    
+    @Test
+    void shouldForceApprovalOfUntrackedCards() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        issuer.setRules(IssuerRules.fromYaml(RULES));
+        AdyenPSPClient adyen = new AdyenPSPClient(network(issuer));
+        
+        // Untracked cards are otherwise declined at random
+        for (int i = 0; i < 50; i++) {
+            assertThat(adyen.authorize(request("12.34")).isSuccess()).isTrue();
+        }
+    }
+    
+    @Test
+    void shouldReloadChangedRulesFileAndKeepRulesOnError(@TempDir Path dir) throws Exception {
+        Path file = dir.resolve("issuer-rules.yml");
+        Files.writeString(file, "rules:\n  - name: A\n    when: amount > 1\n    response-code: '05'\n");
+        SimulatedIssuer issuer = new SimulatedIssuer("", "", 3, "", "", file.toString(), null, null, null);
+        assertThat(issuer.getRules().size()).isEqualTo(1);
+        
+        // Unchanged file is not reloaded
+        assertThat(issuer.reloadRules()).isFalse();
+        
+        Files.writeString(file, RULES);
+        Files.setLastModifiedTime(file, FileTime.from(Instant.now().plusSeconds(10)));
+        assertThat(issuer.reloadRules()).isTrue();
+        assertThat(issuer.getRules().size()).isEqualTo(4);
+        
+        // An invalid file is rejected and the previous rules stay active
+        Files.writeString(file, "rules:\n  - name: C\n    when: amount >>\n    response-code: '05'\n");
+        Files.setLastModifiedTime(file, FileTime.from(Instant.now().plusSeconds(20)));
+        assertThat(issuer.reloadRules()).isFalse();
+        assertThat(issuer.getRules().size()).isEqualTo(4);
+    }
+    
+    private static SimulatedNetwork network(SimulatedIssuer issuer) {
+        return new SimulatedNetwork(issuer, new LatencyScenarios(""), new StandInProcessor(issuer));
+    }
+}
@@ -0,0 +1,33 @@
+package com.paymentgateway.authorization.psp;
+
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+
+import static org.assertj.core.api.Assertions.*;
+
+class NetworkRulesTest {
+    
+    @Test
+    void shouldLetTippingMerchantsCaptureWithinTheBrandTolerance() {
+        BigDecimal authorized = new BigDecimal("50.00");
+        
+        assertThat(NetworkRules.captureLimit("5812", "VISA", authorized)).isEqualByComparingTo("60.00");
+        assertThat(NetworkRules.captureLimit("5812", "AMEX", authorized)).isEqualByComparingTo("65.00");
+        assertThat(NetworkRules.captureLimit("4121", "JCB", authorized)).isEqualByComparingTo("57.50");
+        assertThat(NetworkRules.captureLimit("4121", null, authorized)).isEqualByComparingTo("57.50");
+        
+        // Other merchants capture at most what they authorized
+        assertThat(NetworkRules.captureLimit("5411", "VISA", authorized)).isEqualByComparingTo("50.00");
+        assertThat(NetworkRules.captureLimit(null, "VISA", authorized)).isEqualByComparingTo("50.00");
+    }
+    
+    @Test
+    void shouldRejectCapturesBeyondTheLimit() {
+        BigDecimal limit = new BigDecimal("60.00");
+        
+        assertThat(NetworkRules.checkCapture(limit, new BigDecimal("60.00"))).isNull();
+        assertThat(NetworkRules.checkCapture(limit, new BigDecimal("45.00"))).isNull();
+        assertThat(NetworkRules.checkCapture(limit, new BigDecimal("60.01"))).isEqualTo(NetworkRules.INVALID_AMOUNT);
+    }
+}
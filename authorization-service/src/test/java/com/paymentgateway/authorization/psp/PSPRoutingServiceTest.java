@@ -0,0 +1,322 @@
+package com.paymentgateway.authorization.psp;
+
+import com.paymentgateway.authorization.domain.PSPConfiguration;
+import com.paymentgateway.authorization.repository.PSPConfigurationRepository;
+import com.paymentgateway.authorization.resilience.CircuitBreaker;
+import com.paymentgateway.authorization.resilience.CircuitBreakerRegistry;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.time.ZoneOffset;
+import java.util.List;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.*;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.Mockito.*;
+
+class PSPRoutingServiceTest {
+    
+    private static final UUID MERCHANT = UUID.randomUUID();
+    
+    private PSPConfigurationRepository repository;
+    private PSPClient stripe;
+    private PSPClient adyen;
+    private AcquirerCostTable costs;
+    private LateResponseLog lateResponses;
+    private CircuitBreakerRegistry circuitBreakers;
+    
+    @BeforeEach
+    void setUp() {
+        repository = mock(PSPConfigurationRepository.class);
+        when(repository.findByMerchantIdAndIsActiveTrueOrderByPriorityAsc(MERCHANT)).thenReturn(List.of(
+            new PSPConfiguration(MERCHANT, "STRIPE", 1),
+            new PSPConfiguration(MERCHANT, "ADYEN", 2)
+        ));
+        stripe = acquirer("STRIPE");
+        adyen = acquirer("ADYEN");
+        costs = new AcquirerCostTable("STRIPE:*:*:2.9:0.30,ADYEN:VISA:EUR:1.6:0.10,ADYEN:*:EUR:2.0:0.10");
+        lateResponses = new LateResponseLog();
+        circuitBreakers = CircuitBreakerRegistry.withDefaults();
+    }
+    
+    @Test
+    void shouldRouteToCheapestAcquirerForBrandAndCurrency() {
+        PSPRoutingService routing = routing(new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), 1000);
+        
+        PSPAuthorizationResponse response = routing.authorizeWithFailover(request("EUR"));
+        
+        assertThat(response.getPspName()).isEqualTo("ADYEN");
+        assertThat(response.getRoutingPath()).isEqualTo("ADYEN:AUTHORIZED");
+        verify(stripe, never()).authorize(any());
+    }
+    
+    @Test
+    void shouldSkipAcquirerThatDoesNotTakeCurrency() {
+        PSPRoutingService routing = routing(new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), 1000);
+        
+        assertThat(routing.rank(repository.findByMerchantIdAndIsActiveTrueOrderByPriorityAsc(MERCHANT), request("JPY")))
+            .extracting(PSPConfiguration::getPspName)
+            .containsExactly("STRIPE");
+    }
+    
+    @Test
+    void shouldFailOverOnTimeoutAndVoidLateApproval() {
+        when(adyen.authorize(any())).thenAnswer(invocation -> {
+            Thread.sleep(500);
+            return PSPAuthorizationResponse.success("adyen_late", new BigDecimal("100.00"), "EUR");
+        });
+        when(adyen.voidTransaction("adyen_late")).thenReturn(new PSPVoidResponse(true, "adyen_late"));
+        PSPRoutingService routing = routing(new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), 100);
+        
+        PSPAuthorizationResponse response = routing.authorizeWithFailover(request("EUR"));
+        
+        assertThat(response.isSuccess()).isTrue();
+        assertThat(response.getPspName()).isEqualTo("STRIPE");
+        assertThat(response.getRoutingPath()).isEqualTo("ADYEN:TIMEOUT > STRIPE:AUTHORIZED");
+        verify(adyen, timeout(2000)).voidTransaction("adyen_late");
+        
+        // The late approval is logged for reconciliation once reversed
+        await(() -> !lateResponses.getEntries().isEmpty());
+        LateResponseLog.Entry entry = lateResponses.getEntries().get(0);
+        assertThat(entry.getPspName()).isEqualTo("ADYEN");
+        assertThat(entry.getPspTransactionId()).isEqualTo("adyen_late");
+        assertThat(entry.getAction()).isEqualTo(LateResponseLog.Action.REVERSED);
+        assertThat(Duration.between(entry.getSentAt(), entry.getReceivedAt())).isGreaterThanOrEqualTo(Duration.ofMillis(500));
+    }
+    
+    @Test
+    void shouldDelayConfiguredScenarioAmountsPastTimeout() {
+        LatencyScenarios scenarios = new LatencyScenarios("ADYEN:99.05:400,*:99.06:300");
+        
+        assertThat(scenarios.delayMillis("ADYEN", new BigDecimal("99.050"), 60)).isEqualTo(400);
+        assertThat(scenarios.delayMillis("STRIPE", new BigDecimal("99.05"), 50)).isEqualTo(50);
+        assertThat(scenarios.delayMillis("STRIPE", new BigDecimal("99.06"), 50)).isEqualTo(300);
+        
+        // Every acquirer times out: the payment fails and the late approvals are reversed
+        PSPClient slowStripe = new StripePSPClient(network(scenarios));
+        PSPClient slowAdyen = new AdyenPSPClient(network(scenarios));
+        PSPRoutingService routing = new PSPRoutingService(repository, List.of(slowStripe, slowAdyen),
+            new AcquirerCostTable(""), new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()),
+            lateResponses, circuitBreakers, 100);
+        PSPAuthorizationRequest request = request("EUR");
+        request.setAmount(new BigDecimal("99.06"));
+        
+        assertThatThrownBy(() -> routing.authorizeWithFailover(request))
+            .isInstanceOf(PSPException.class)
+            .extracting("errorCode").isEqualTo("TIMEOUT");
+        await(() -> lateResponses.getEntries().size() == 2);
+        assertThat(lateResponses.getEntries())
+            .allSatisfy(e -> assertThat(e.getAction()).isIn(LateResponseLog.Action.REVERSED, LateResponseLog.Action.NONE));
+    }
+    
+    @Test
+    void shouldTargetFaultsByMerchantBinRangeAndAmountBand() {
+        UUID other = UUID.randomUUID();
+        double[] draws = {0.2};
+        LatencyScenarios scenarios = new LatencyScenarios("*:99.05:8000",
+            "psp=ADYEN,bin=411111-411199,delay=800-2000,rate=0.3;merchant=" + MERCHANT + ",amount=1000-,error=PSP_UNAVAILABLE",
+            () -> draws[0]);
+        PSPAuthorizationRequest request = request("EUR");
+        request.setCardBin("41111500");
+        
+        // In the BIN range at Adyen, drawn under the rate: 800ms plus a fifth of the spread
+        LatencyScenarios.Injection injection = scenarios.plan("ADYEN", request, 60);
+        assertThat(injection.getDelayMillis()).isEqualTo(60 + 800 + 240);
+        assertThat(injection.getErrorCode()).isNull();
+        assertThat(scenarios.plan("STRIPE", request, 50).getDelayMillis()).isEqualTo(50);
+        draws[0] = 0.3;
+        assertThat(scenarios.plan("ADYEN", request, 60).getRule()).isNull();
+        request.setCardBin("41120000");
+        draws[0] = 0;
+        assertThat(scenarios.plan("ADYEN", request, 60).getDelayMillis()).isEqualTo(60);
+        
+        // The amount band includes its minimum, and only this merchant's payments fail
+        request.setAmount(new BigDecimal("1000.00"));
+        assertThat(scenarios.plan("STRIPE", request, 50).getErrorCode()).isEqualTo("PSP_UNAVAILABLE");
+        request.setAmount(new BigDecimal("999.99"));
+        assertThat(scenarios.plan("STRIPE", request, 50).getErrorCode()).isNull();
+        request.setAmount(new BigDecimal("5000"));
+        request.setMerchantId(other);
+        assertThat(scenarios.plan("STRIPE", request, 50).getErrorCode()).isNull();
+        
+        // Late response scenarios take precedence
+        request.setMerchantId(MERCHANT);
+        request.setAmount(new BigDecimal("99.05"));
+        request.setCardBin("41111500");
+        assertThat(scenarios.plan("ADYEN", request, 60).getDelayMillis()).isEqualTo(8000);
+    }
+    
+    @Test
+    void shouldFailOverOnInjectedError() {
+        LatencyScenarios scenarios = new LatencyScenarios("", "psp=ADYEN,bin=4111,error=PSP_UNAVAILABLE", () -> 0);
+        PSPRoutingService routing = new PSPRoutingService(repository,
+            List.of(new StripePSPClient(network(scenarios)), new AdyenPSPClient(network(scenarios))),
+            costs, new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), lateResponses, circuitBreakers, 1000);
+        PSPAuthorizationRequest request = request("EUR");
+        request.setCardBin("41111111");
+        
+        PSPAuthorizationResponse response = routing.authorizeWithFailover(request);
+        
+        assertThat(response.getPspName()).isEqualTo("STRIPE");
+        assertThat(response.getRoutingPath()).isEqualTo("ADYEN:PSP_UNAVAILABLE > STRIPE:AUTHORIZED");
+        
+        // Cards outside the BIN range still go to Adyen
+        request.setCardBin("55555555");
+        assertThat(routing.authorizeWithFailover(request).getPspName()).isEqualTo("ADYEN");
+    }
+    
+    @Test
+    void shouldRejectInvalidFaultRules() {
+        for (String faults : List.of("bin=4111", "bin=4111-42,delay=10", "amount=100,delay=10", "delay=500-100",
+                                     "rate=0,error=X", "rate=1.5,error=X", "merchant=acme,error=X", "colour=red,delay=10")) {
+            assertThatThrownBy(() -> new LatencyScenarios("", faults, () -> 0))
+                .as(faults)
+                .isInstanceOf(IllegalArgumentException.class)
+                .hasMessageStartingWith("Invalid fault rule");
+        }
+    }
+    
+    @Test
+    void shouldRouteAroundUnhealthyAcquirerUntilCooldownPasses() {
+        MutableClock clock = new MutableClock();
+        AcquirerHealth health = new AcquirerHealth(2, Duration.ofSeconds(30), clock);
+        health.recordFailure("ADYEN");
+        health.recordFailure("ADYEN");
+        PSPRoutingService routing = routing(health, 1000);
+        
+        assertThat(routing.authorizeWithFailover(request("EUR")).getPspName()).isEqualTo("STRIPE");
+        
+        clock.advance(Duration.ofSeconds(31));
+        assertThat(routing.authorizeWithFailover(request("EUR")).getPspName()).isEqualTo("ADYEN");
+    }
+    
+    @Test
+    void shouldNotFailOverOnDecline() {
+        when(adyen.authorize(any())).thenReturn(PSPAuthorizationResponse.declined("51", "Insufficient funds"));
+        PSPRoutingService routing = routing(new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), 1000);
+        
+        PSPAuthorizationResponse response = routing.authorizeWithFailover(request("EUR"));
+        
+        assertThat(response.getStatus()).isEqualTo("DECLINED");
+        assertThat(response.getRoutingPath()).isEqualTo("ADYEN:DECLINED");
+        verify(stripe, never()).authorize(any());
+    }
+    
+    @Test
+    void shouldSkipAcquirerWhileItsCircuitIsOpen() {
+        MutableClock clock = new MutableClock();
+        circuitBreakers = new CircuitBreakerRegistry(1, Duration.ofSeconds(30), 10, Duration.ofSeconds(1), "",
+                                                     clock, null, null, null);
+        when(adyen.authorize(any()))
+            .thenThrow(new PSPException("ADYEN", "UNAVAILABLE", "Service unavailable", true))
+            .thenAnswer(invocation -> PSPAuthorizationResponse.success("adyen_txn", new BigDecimal("100.00"), "EUR"));
+        PSPRoutingService routing = routing(new AcquirerHealth(3, Duration.ofSeconds(30), clock), 1000);
+        
+        assertThat(routing.authorizeWithFailover(request("EUR")).getRoutingPath())
+            .isEqualTo("ADYEN:UNAVAILABLE > STRIPE:AUTHORIZED");
+        assertThat(routing.authorizeWithFailover(request("EUR")).getRoutingPath())
+            .isEqualTo("ADYEN:CIRCUIT_OPEN > STRIPE:AUTHORIZED");
+        verify(adyen, times(1)).authorize(any());
+        
+        // After the open period a trial call goes through and closes the circuit
+        clock.advance(Duration.ofSeconds(31));
+        assertThat(routing.authorizeWithFailover(request("EUR")).getPspName()).isEqualTo("ADYEN");
+        assertThat(circuitBreakers.forNetwork("ADYEN").getState()).isEqualTo(CircuitBreaker.State.CLOSED);
+    }
+    
+    @Test
+    void shouldRecordCandidateRoutesWithoutTryingThem() {
+        // The candidate makes Adyen dearer than Stripe for EUR
+        ShadowRouting shadow = new ShadowRouting("STRIPE:*:*:1.0:0.10,ADYEN:*:EUR:3.0:0.30");
+        PSPRoutingService routing = new PSPRoutingService(repository, List.of(stripe, adyen), costs,
+            new AcquirerHealth(3, Duration.ofSeconds(30), Clock.systemUTC()), lateResponses, circuitBreakers,
+            shadow, 1000);
+        
+        assertThat(routing.authorizeWithFailover(request("EUR")).getPspName()).isEqualTo("ADYEN");
+        routing.authorizeWithFailover(request("JPY"));
+        verify(stripe, times(1)).authorize(any());
+        
+        ShadowRouting.Report report = shadow.report();
+        assertThat(report.getEvaluated()).isEqualTo(2);
+        assertThat(report.getDivergent()).isEqualTo(1);
+        assertThat(report.getTransitions()).containsEntry("ADYEN->STRIPE", 1L).containsEntry("STRIPE->STRIPE", 1L);
+        assertThat(report.getRecentDivergences()).singleElement().satisfies(comparison -> {
+            assertThat(comparison.getActiveRoute()).containsExactly("ADYEN", "STRIPE");
+            assertThat(comparison.getShadowRoute()).containsExactly("STRIPE", "ADYEN");
+            assertThat(comparison.getCurrency()).isEqualTo("EUR");
+        });
+        
+        shadow.reset();
+        assertThat(shadow.report().getEvaluated()).isZero();
+    }
+    
+    private PSPRoutingService routing(AcquirerHealth health, long timeoutMillis) {
+        return new PSPRoutingService(repository, List.of(stripe, adyen), costs, health, lateResponses,
+                                     circuitBreakers, timeoutMillis);
+    }
+    
+    private static void await(java.util.function.BooleanSupplier condition) {
+        long deadline = System.currentTimeMillis() + 2000;
+        while (!condition.getAsBoolean()) {
+            assertThat(System.currentTimeMillis()).as("condition not met in time").isLessThan(deadline);
+            try {
+                Thread.sleep(10);
+            } catch (InterruptedException e) {
+                Thread.currentThread().interrupt();
+                return;
+            }
+        }
+    }
+    
+    private PSPClient acquirer(String name) {
+        PSPClient client = mock(PSPClient.class);
+        when(client.getPSPName()).thenReturn(name);
+        when(client.isAvailable()).thenReturn(true);
+        when(client.authorize(any())).thenAnswer(invocation -> PSPAuthorizationResponse.success(
+            name.toLowerCase() + "_txn", new BigDecimal("100.00"), "EUR"));
+        return client;
+    }
+    
+    private PSPAuthorizationRequest request(String currency) {
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest();
+        request.setMerchantId(MERCHANT);
+        request.setAmount(new BigDecimal("100.00"));
+        request.setCurrency(currency);
+        request.setCardBrand("VISA");
+        request.setCardTokenId(UUID.randomUUID());
+        return request;
+    }
+    
+    private static class MutableClock extends Clock {
+        private Instant now = Instant.parse("2026-01-01T00:00:00Z");
+        
+        void advance(Duration duration) {
+            now = now.plus(duration);
+        }
+        
+        @Override
+        public ZoneOffset getZone() {
+            return ZoneOffset.UTC;
+        }
+        
+        @Override
+        public Clock withZone(java.time.ZoneId zone) {
+            return this;
+        }
+        
+        @Override
+        public Instant instant() {
+            return now;
+        }
+    }
+    
+    private static SimulatedNetwork network(LatencyScenarios scenarios) {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        return new SimulatedNetwork(issuer, scenarios, new StandInProcessor(issuer));
+    }
+}
@@ -0,0 +1,356 @@
+package com.paymentgateway.authorization.psp;
+
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+import java.util.Arrays;
+import java.util.HexFormat;
+import java.util.Set;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.*;
+
+class SimulatedIssuerTest {
+    
+    private static final String PAN = "4111111111111111";
+    private static final String CARD = CardFingerprint.of(PAN);
+    
+    @Test
+    void shouldHoldCaptureReleaseAndRefund() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":500.00");
+        
+        assertThat(issuer.hold(CARD, "txn_1", new BigDecimal("300.00"))).isTrue();
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("200.00");
+        
+        // Not enough left for a second authorization
+        assertThat(issuer.hold(CARD, "txn_2", new BigDecimal("250.00"))).isFalse();
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("200.00");
+        
+        // A partial capture returns the rest of the hold
+        issuer.capture("txn_1", new BigDecimal("120.00"));
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("380.00");
+        
+        assertThat(issuer.hold(CARD, "txn_2", new BigDecimal("250.00"))).isTrue();
+        issuer.release("txn_2");
+        issuer.release("txn_2");
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("380.00");
+        
+        issuer.refund("txn_1", new BigDecimal("20.00"));
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("400.00");
+    }
+    
+    @Test
+    void shouldTakeATipAboveTheHoldFromTheOpenToBuy() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":500.00");
+        
+        assertThat(issuer.hold(CARD, "txn_1", new BigDecimal("100.00"))).isTrue();
+        issuer.capture("txn_1", new BigDecimal("118.00"));
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("382.00");
+    }
+    
+    @Test
+    void shouldApproveOnlyTheInstallmentPlansSetForTheCard() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":500.00");
+        
+        // No plan asked for, or a card the issuer does not track
+        assertThat(issuer.checkInstallments(CARD, null, null)).isNull();
+        assertThat(issuer.checkInstallments(CardFingerprint.of("5555555555554444"), 6, "ISSUER")).isNull();
+        
+        assertThat(issuer.checkInstallments(CARD, 6, "ISSUER")).isEqualTo(SimulatedIssuer.NOT_PERMITTED_TO_CARDHOLDER);
+        
+        issuer.setInstallments(PAN, Set.of("ISSUER"), 6);
+        assertThat(issuer.checkInstallments(CARD, 6, "ISSUER")).isNull();
+        assertThat(issuer.checkInstallments(CARD, 7, "ISSUER")).isEqualTo(SimulatedIssuer.NOT_PERMITTED_TO_CARDHOLDER);
+        assertThat(issuer.checkInstallments(CARD, 3, "MERCHANT")).isEqualTo(SimulatedIssuer.NOT_PERMITTED_TO_CARDHOLDER);
+    }
+    
+    @Test
+    void shouldNotTrackUnconfiguredCards() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        
+        assertThat(issuer.isTracked(CARD)).isFalse();
+        assertThat(issuer.getOpenToBuy(CARD)).isNull();
+        assertThat(issuer.hold(CARD, "txn_1", BigDecimal.ONE)).isFalse();
+        assertThatThrownBy(() -> new SimulatedIssuer(PAN))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    @Test
+    void pspShouldDeclineOnceTheBalanceIsUsed() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00");
+        StripePSPClient stripe = new StripePSPClient(network(issuer));
+        
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal("60.00"), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        
+        PSPAuthorizationResponse first = stripe.authorize(request);
+        assertThat(first.isSuccess()).isTrue();
+        
+        PSPAuthorizationResponse second = stripe.authorize(request);
+        assertThat(second.isSuccess()).isFalse();
+        assertThat(second.getDeclineCode()).isEqualTo(SimulatedIssuer.INSUFFICIENT_FUNDS);
+        
+        // Voiding the first authorization frees the funds again
+        stripe.voidTransaction(first.getPspTransactionId());
+        assertThat(stripe.authorize(request).isSuccess()).isTrue();
+    }
+    
+    @Test
+    void shouldBlockThePinAfterTooManyWrongEntries() {
+        SimulatedIssuer issuer = new SimulatedIssuer("", PAN + ":1234", 3, new ClearPinVerifier());
+        
+        assertThat(issuer.verifyPin(CARD, pinBlock("1234"))).isNull();
+        assertThat(issuer.verifyPin(CARD, pinBlock("0000"))).isEqualTo(SimulatedIssuer.INCORRECT_PIN);
+        assertThat(issuer.verifyPin(CARD, pinBlock("0000"))).isEqualTo(SimulatedIssuer.INCORRECT_PIN);
+        assertThat(issuer.getRemainingPinTries(CARD)).isEqualTo(1);
+        assertThat(issuer.verifyPin(CARD, pinBlock("0000"))).isEqualTo(SimulatedIssuer.PIN_TRIES_EXCEEDED);
+        
+        // Blocked even for the right PIN until the issuer resets the counter
+        assertThat(issuer.verifyPin(CARD, pinBlock("1234"))).isEqualTo(SimulatedIssuer.PIN_TRIES_EXCEEDED);
+        issuer.resetPinTries(CARD);
+        assertThat(issuer.verifyPin(CARD, pinBlock("1234"))).isNull();
+        assertThat(issuer.getRemainingPinTries(CARD)).isEqualTo(3);
+        
+        // No PIN block, or no PIN on the card, means no check
+        assertThat(issuer.verifyPin(CARD, null)).isNull();
+        assertThat(issuer.verifyPin(CardFingerprint.of("5555555555554444"), pinBlock("0000"))).isNull();
+        assertThat(issuer.getRemainingPinTries(CardFingerprint.of("5555555555554444"))).isNull();
+    }
+    
+    @Test
+    void shouldNeedAnHsmForPins() {
+        assertThatThrownBy(() -> new SimulatedIssuer("", PAN + ":1234", 3, null))
+            .isInstanceOf(IllegalStateException.class);
+    }
+    
+    @Test
+    void pspShouldDeclineAnIncorrectPin() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00", PAN + ":1234", 3, new ClearPinVerifier());
+        StripePSPClient stripe = new StripePSPClient(network(issuer));
+        
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal("20.00"), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        request.setPinBlock(pinBlock("4321"));
+        
+        PSPAuthorizationResponse declined = stripe.authorize(request);
+        assertThat(declined.isSuccess()).isFalse();
+        assertThat(declined.getDeclineCode()).isEqualTo(SimulatedIssuer.INCORRECT_PIN);
+        
+        request.setPinBlock(pinBlock("1234"));
+        assertThat(stripe.authorize(request).isSuccess()).isTrue();
+    }
+    
+    @Test
+    void shouldVerifyCvv2ThroughTheVerifier() {
+        SimulatedIssuer issuer = new SimulatedIssuer("", "", 3, PAN, null, new FixedCvvVerifier("123"));
+        
+        assertThat(issuer.verifyCvv(CARD, 12, 2030, "123")).isEqualTo(SimulatedIssuer.CVV_MATCH);
+        assertThat(issuer.verifyCvv(CARD, 12, 2030, "124")).isEqualTo(SimulatedIssuer.CVV_NO_MATCH);
+        // The expiry date is bound into the CVV2
+        assertThat(issuer.verifyCvv(CARD, 11, 2030, "123")).isEqualTo(SimulatedIssuer.CVV_NO_MATCH);
+        assertThat(issuer.verifyCvv(CARD, 12, 2030, "12345")).isEqualTo(SimulatedIssuer.CVV_NO_MATCH);
+        // No CVV2 entered, or no CVV2 on file, is not checked
+        assertThat(issuer.verifyCvv(CARD, 12, 2030, null)).isNull();
+        assertThat(issuer.verifyCvv(CardFingerprint.of("5555555555554444"), 12, 2030, "123")).isNull();
+    }
+    
+    @Test
+    void shouldNotProcessCvv2WhenTheHsmIsUnavailable() {
+        CvvVerifier unavailable = (pan, expiry, cvv) -> {
+            throw new IllegalStateException("UNAVAILABLE");
+        };
+        SimulatedIssuer issuer = new SimulatedIssuer("", "", 3, PAN, null, unavailable);
+        
+        assertThat(issuer.verifyCvv(CARD, 12, 2030, "123")).isEqualTo(SimulatedIssuer.CVV_NOT_PROCESSED);
+    }
+    
+    @Test
+    void pspShouldDeclineAWrongCvv2() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00", "", 3, PAN, null, new FixedCvvVerifier("123"));
+        StripePSPClient stripe = new StripePSPClient(network(issuer));
+        
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal("20.00"), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        request.setExpiryMonth(12);
+        request.setExpiryYear(2030);
+        request.setCvv("999");
+        
+        PSPAuthorizationResponse declined = stripe.authorize(request);
+        assertThat(declined.isSuccess()).isFalse();
+        assertThat(declined.getDeclineCode()).isEqualTo(SimulatedIssuer.CVV2_FAILURE);
+        assertThat(declined.getCvvResult()).isEqualTo(SimulatedIssuer.CVV_NO_MATCH);
+        
+        request.setCvv("123");
+        PSPAuthorizationResponse approved = stripe.authorize(request);
+        assertThat(approved.isSuccess()).isTrue();
+        assertThat(approved.getCvvResult()).isEqualTo(SimulatedIssuer.CVV_MATCH);
+    }
+    
+    @Test
+    void shouldRequireAVerifierForCvv2Cards() {
+        assertThatThrownBy(() -> new SimulatedIssuer("", "", 3, PAN, null, null))
+            .isInstanceOf(IllegalStateException.class);
+    }
+    
+    @Test
+    void pspShouldVerifyTheArqcAndAnswerTheChipInField55() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00", "", 3, "", PAN, "", null, null,
+            new FixedArqcVerifier("0102030405060708"));
+        StripePSPClient stripe = new StripePSPClient(network(issuer));
+        
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal("60.00"), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        request.setAtc(7);
+        request.setChipTransactionData("000000006000");
+        request.setArqc("0102030405060709");
+        
+        // The approval is undone, releasing its hold
+        PSPAuthorizationResponse failed = stripe.authorize(request);
+        assertThat(failed.isSuccess()).isFalse();
+        assertThat(failed.getDeclineCode()).isEqualTo(SimulatedIssuer.ARQC_FAILURE);
+        assertThat(failed.getField55()).isNull();
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("100.00");
+        
+        request.setArqc("0102030405060708");
+        PSPAuthorizationResponse approved = stripe.authorize(request);
+        assertThat(approved.isSuccess()).isTrue();
+        assertThat(approved.getField55()).isEqualTo("91023030");
+        
+        // Declines are answered too, so the card learns of them from its issuer
+        PSPAuthorizationResponse declined = stripe.authorize(request);
+        assertThat(declined.getDeclineCode()).isEqualTo(SimulatedIssuer.INSUFFICIENT_FUNDS);
+        assertThat(declined.getField55()).isEqualTo("91023531");
+        
+        // Transactions without an ARQC are not chip transactions
+        request.setArqc(null);
+        assertThat(stripe.authorize(request).getField55()).isNull();
+    }
+    
+    @Test
+    void shouldRequireAVerifierForChipCards() {
+        assertThatThrownBy(() -> new SimulatedIssuer("", "", 3, "", PAN, "", null, null, null))
+            .isInstanceOf(IllegalStateException.class);
+    }
+    
+    @Test
+    void shouldVerifyTheBillingAddressAgainstTheAddressBook() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        issuer.setAddress(PAN, "1 Main Street", "94105");
+        
+        assertThat(issuer.verifyAddress(CARD, "1 Main St.", "94105-1804")).isEqualTo(SimulatedIssuer.AVS_FULL_MATCH);
+        assertThat(issuer.verifyAddress(CARD, "1 Main Street", "10001")).isEqualTo(SimulatedIssuer.AVS_STREET_ONLY);
+        assertThat(issuer.verifyAddress(CARD, "7 Market Street", "94105")).isEqualTo(SimulatedIssuer.AVS_ZIP_ONLY);
+        assertThat(issuer.verifyAddress(CARD, "7 Market Street", null)).isEqualTo(SimulatedIssuer.AVS_NO_MATCH);
+        // No address sent, or none on file
+        assertThat(issuer.verifyAddress(CARD, null, " ")).isNull();
+        assertThat(issuer.verifyAddress(CardFingerprint.of("5555555555554444"), "1 Main Street", "94105"))
+            .isEqualTo(SimulatedIssuer.AVS_UNAVAILABLE);
+    }
+    
+    @Test
+    void pspShouldReturnTheAvsResult() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00");
+        issuer.setAddress(PAN, "1 Main Street", "94105");
+        StripePSPClient stripe = new StripePSPClient(network(issuer));
+        
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal("20.00"), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        request.setBillingStreet("9 Other Road");
+        request.setBillingZip("94105");
+        
+        PSPAuthorizationResponse approved = stripe.authorize(request);
+        assertThat(approved.isSuccess()).isTrue();
+        assertThat(approved.getAvsResult()).isEqualTo(SimulatedIssuer.AVS_ZIP_ONLY);
+    }
+    
+    @Test
+    void shouldPostStatementLinesUnderTheDescriptor() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":500.00");
+        
+        issuer.hold(CARD, "txn_1", new BigDecimal("80.00"), "DEMOSTORE*ORDER 1");
+        assertThat(issuer.getStatement(CARD)).isEmpty();
+        
+        issuer.capture("txn_1", new BigDecimal("75.00"));
+        issuer.refund("txn_1", new BigDecimal("25.00"));
+        issuer.credit(CARD, new BigDecimal("10.00"), "DEMOSTORE");
+        issuer.credit(CARD, new BigDecimal("5.00"));
+        
+        assertThat(issuer.getStatement(CARD))
+            .extracting(SimulatedIssuer.StatementLine::getType, SimulatedIssuer.StatementLine::getDescriptor,
+                line -> line.getAmount().toPlainString())
+            .containsExactly(
+                tuple(SimulatedIssuer.StatementLine.PURCHASE, "DEMOSTORE*ORDER 1", "75.00"),
+                tuple(SimulatedIssuer.StatementLine.REFUND, "DEMOSTORE*ORDER 1", "-25.00"),
+                tuple(SimulatedIssuer.StatementLine.CREDIT, "DEMOSTORE", "-10.00"),
+                tuple(SimulatedIssuer.StatementLine.CREDIT, "UNKNOWN MERCHANT", "-5.00"));
+        assertThat(issuer.getStatement(CardFingerprint.of("5555555555554444"))).isNull();
+    }
+    
+    private static String pinBlock(String pin) {
+        return HexFormat.of().formatHex(pin.getBytes());
+    }
+    
+    /**
+     * Treats PIN blocks as the clear PIN, in place of the HSM
+     */
+    private static final class ClearPinVerifier implements PinVerifier {
+        
+        @Override
+        public PinReference enroll(String pan, String pin) {
+            return new PinReference(PinReference.VISA_PVV, pin, 1);
+        }
+        
+        @Override
+        public boolean verify(String pan, byte[] pinBlock, PinReference reference) {
+            return new String(pinBlock).equals(reference.getValue());
+        }
+    }
+    
+    /**
+     * Accepts one CVV2 for cards expiring 12/30, in place of the HSM
+     */
+    private static final class FixedCvvVerifier implements CvvVerifier {
+        
+        private final String cvv;
+        
+        FixedCvvVerifier(String cvv) {
+            this.cvv = cvv;
+        }
+        
+        @Override
+        public boolean verify(String pan, String expiry, String cvv) {
+            return expiry.equals("3012") && cvv.equals(this.cvv);
+        }
+    }
+    
+    /**
+     * Accepts one ARQC and answers with tag 91 holding only the response
+     * code, in place of the HSM
+     */
+    private static final class FixedArqcVerifier implements ArqcVerifier {
+        
+        private final byte[] arqc;
+        
+        FixedArqcVerifier(String arqc) {
+            this.arqc = HexFormat.of().parseHex(arqc);
+        }
+        
+        @Override
+        public byte[] verify(String pan, int panSequenceNumber, int atc, byte[] transactionData, byte[] arqc,
+                             String responseCode) {
+            if (!Arrays.equals(arqc, this.arqc)) {
+                return null;
+            }
+            return new byte[] {(byte) 0x91, 2, (byte) responseCode.charAt(0), (byte) responseCode.charAt(1)};
+        }
+    }
+    
+    private static SimulatedNetwork network(SimulatedIssuer issuer) {
+        return new SimulatedNetwork(issuer, new LatencyScenarios(""), new StandInProcessor(issuer));
+    }
+}
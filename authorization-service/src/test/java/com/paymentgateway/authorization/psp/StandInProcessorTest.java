@@ -0,0 +1,134 @@
+package com.paymentgateway.authorization.psp;
+
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+import java.time.Clock;
+import java.util.ArrayDeque;
+import java.util.Deque;
+import java.util.List;
+import java.util.Map;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.*;
+
+class StandInProcessorTest {
+    
+    private static final String PAN = "4111111111111111";
+    private static final String CARD = CardFingerprint.of(PAN);
+    
+    @Test
+    void shouldStandInWithinLimitsAndForwardAdvicesWhenTheIssuerReturns() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00");
+        StandInProcessor standIn = new StandInProcessor(issuer, "411111", "4:1000:1000,4111:80.00:150.00", 0,
+                                                        () -> 1.0, Clock.systemUTC());
+        StripePSPClient stripe = new StripePSPClient(new SimulatedNetwork(issuer, new LatencyScenarios(""), standIn));
+        
+        PSPAuthorizationResponse first = stripe.authorize(request("60.00"));
+        assertThat(first.isSuccess()).isTrue();
+        assertThat(first.getNetworkTransactionId()).isNotNull();
+        // Above the limit per transaction of the longest matching prefix
+        assertThat(stripe.authorize(request("90.00")).getDeclineCode()).isEqualTo(StandInProcessor.EXCEEDS_AMOUNT_LIMIT);
+        assertThat(stripe.authorize(request("70.00")).isSuccess()).isTrue();
+        // Above the card's daily total
+        assertThat(stripe.authorize(request("30.00")).getDeclineCode()).isEqualTo(StandInProcessor.EXCEEDS_AMOUNT_LIMIT);
+        
+        // The issuer hears of nothing, captures included, until it is back
+        stripe.capture(first.getPspTransactionId(), new BigDecimal("50.00"), "USD");
+        assertThat(standIn.forwardAdvices()).isZero();
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("100.00");
+        
+        standIn.setIssuerAvailable("411111", true);
+        
+        // Both approvals are held, past the open-to-buy, and the capture
+        // releases the rest of the first
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("-20.00");
+        assertThat(standIn.getAdvices())
+            .extracting(StandInProcessor.Advice::getMessageType, StandInProcessor.Advice::getAcknowledgement,
+                StandInProcessor.Advice::getResponseCode)
+            .containsExactly(
+                tuple("0220", "0230", "00"),
+                tuple("0120", "0130", "61"),
+                tuple("0120", "0130", "00"),
+                tuple("0120", "0130", "61"),
+                tuple("0120", "0130", "00"));
+        assertThat(standIn.reconcile()).isEqualTo(Map.of(
+            StandInProcessor.Reconciliation.MATCHED, 3L,
+            StandInProcessor.Reconciliation.ISSUER_WOULD_DECLINE, 1L));
+        
+        // Once the issuer has them, later follow-ups go to it directly
+        stripe.voidTransaction(first.getPspTransactionId());
+        assertThat(standIn.getAdvices()).hasSize(5);
+    }
+    
+    @Test
+    void shouldRepeatUnacknowledgedAdvicesInOrder() {
+        SimulatedIssuer issuer = new SimulatedIssuer(PAN + ":100.00");
+        Deque<Double> draws = new ArrayDeque<>(List.of(0.0));
+        StandInProcessor standIn = new StandInProcessor(issuer, "4111", "4111:80.00:150.00", 0.5,
+                                                        () -> draws.isEmpty() ? 1.0 : draws.poll(), Clock.systemUTC());
+        
+        PSPAuthorizationResponse approval = standIn.authorize(request("50.00"), "txn_1");
+        assertThat(approval.isSuccess()).isTrue();
+        assertThat(standIn.defer(StandInProcessor.Type.CAPTURE, "txn_1", new BigDecimal("50.00"))).isTrue();
+        assertThat(standIn.defer(StandInProcessor.Type.CAPTURE, "txn_2", new BigDecimal("50.00"))).isFalse();
+        
+        // The issuer takes in the approval but its acknowledgement is lost,
+        // which holds back the capture
+        standIn.setIssuerAvailable("4111", true);
+        StandInProcessor.Advice authorization = standIn.getAdvices().get(1);
+        assertThat(authorization.getMessageType()).isEqualTo("0120");
+        assertThat(authorization.getAcknowledgement()).isNull();
+        assertThat(authorization.getReconciliation()).isEqualTo(StandInProcessor.Reconciliation.PENDING);
+        assertThat(standIn.getAdvices().get(0).getAttempts()).isZero();
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("50.00");
+        
+        // The repeat is acknowledged without being applied again
+        assertThat(standIn.forwardAdvices()).isEqualTo(2);
+        assertThat(authorization.getAttempts()).isEqualTo(2);
+        assertThat(authorization.getMessageType()).isEqualTo("0121");
+        assertThat(authorization.getAcknowledgement()).isEqualTo("0130");
+        assertThat(issuer.getOpenToBuy(CARD)).isEqualByComparingTo("50.00");
+        assertThat(issuer.getStatement(CARD))
+            .extracting(line -> line.getAmount().toPlainString())
+            .containsExactly("50.00");
+        assertThat(standIn.forwardAdvices()).isZero();
+    }
+    
+    @Test
+    void shouldDeclineWithoutALimitAndForPinTransactions() {
+        SimulatedIssuer issuer = new SimulatedIssuer("");
+        StandInProcessor standIn = new StandInProcessor(issuer, "4111,5555", "4111:80.00:150.00", 0,
+                                                        () -> 1.0, Clock.systemUTC());
+        
+        assertThat(standIn.isIssuerUnavailable("411111")).isTrue();
+        assertThat(standIn.isIssuerUnavailable("400000")).isFalse();
+        assertThat(standIn.isIssuerUnavailable(null)).isFalse();
+        
+        PSPAuthorizationRequest noLimit = request("10.00");
+        noLimit.setCardBin("555555");
+        assertThat(standIn.authorize(noLimit, "txn_1").getDeclineCode()).isEqualTo(SimulatedIssuer.ISSUER_UNAVAILABLE);
+        
+        PSPAuthorizationRequest pin = request("10.00");
+        pin.setPinBlock("31323334");
+        assertThat(standIn.authorize(pin, "txn_2").getDeclineCode()).isEqualTo(SimulatedIssuer.ISSUER_UNAVAILABLE);
+        
+        // The issuer keeps no balance for these cards
+        standIn.setIssuerAvailable("4111", true);
+        standIn.setIssuerAvailable("5555", true);
+        assertThat(standIn.reconcile()).isEqualTo(Map.of(StandInProcessor.Reconciliation.NOT_TRACKED, 2L));
+        
+        assertThatThrownBy(() -> new StandInProcessor(issuer, "", "4111:80.00", 0, () -> 1.0, Clock.systemUTC()))
+            .isInstanceOf(IllegalArgumentException.class);
+        assertThatThrownBy(() -> standIn.setIssuerAvailable("41x1", false))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    private static PSPAuthorizationRequest request(String amount) {
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest(
+            UUID.randomUUID(), new BigDecimal(amount), "USD", UUID.randomUUID());
+        request.setCardFingerprint(CARD);
+        request.setCardBin("411111");
+        return request;
+    }
+}
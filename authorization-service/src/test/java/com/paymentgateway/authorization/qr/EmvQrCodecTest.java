@@ -0,0 +1,107 @@
+package com.paymentgateway.authorization.qr;
+
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+
+/**
+ * Unit tests for writing and reading EMVCo merchant-presented QR payloads.
+ */
+class EmvQrCodecTest {
+    
+    // A static PromptPay-style code from another network, with its CRC
+    private static final String FOREIGN = "00020101021129370016A000000677010111011300668123456785204581253037645802TH"
+        + "5911NOODLE SHOP6007BANGKOK63044721";
+    
+    private static EmvQrCode code(BigDecimal amount, String currency) {
+        return new EmvQrCode(true, EmvQrCodec.GLOBALLY_UNIQUE_ID, "merchant_demo", "qr_0123456789abcdef01234567",
+            "5411", currency, amount, "US", "Corner Grocery", "ONLINE", "order-1001");
+    }
+    
+    @Test
+    void shouldComputeCrc16Ccitt() {
+        assertThat(EmvQrCodec.crc("123456789")).isEqualTo("29B1");
+    }
+    
+    @Test
+    void shouldRoundTripPayload() {
+        String payload = EmvQrCodec.encode(code(new BigDecimal("12.5"), "USD"));
+        
+        assertThat(payload).startsWith("000201010212");
+        assertThat(payload).contains("5303840", "540512.50", "5802US", "5914Corner Grocery", "6006ONLINE");
+        assertThat(payload).contains("6304").endsWith(EmvQrCodec.crc(payload.substring(0, payload.length() - 4)));
+        
+        EmvQrCode decoded = EmvQrCodec.decode(payload);
+        assertThat(decoded.isDynamic()).isTrue();
+        assertThat(decoded.getGloballyUniqueId()).isEqualTo(EmvQrCodec.GLOBALLY_UNIQUE_ID);
+        assertThat(decoded.getMerchantId()).isEqualTo("merchant_demo");
+        assertThat(decoded.getQrPaymentId()).isEqualTo("qr_0123456789abcdef01234567");
+        assertThat(decoded.getMerchantCategoryCode()).isEqualTo("5411");
+        assertThat(decoded.getCurrency()).isEqualTo("USD");
+        assertThat(decoded.getAmount()).isEqualByComparingTo("12.50");
+        assertThat(decoded.getMerchantName()).isEqualTo("Corner Grocery");
+        assertThat(decoded.getBillNumber()).isEqualTo("order-1001");
+    }
+    
+    @Test
+    void shouldWriteAmountsInCurrencyDecimalsAndCutLongNames() {
+        EmvQrCode yen = new EmvQrCode(true, EmvQrCodec.GLOBALLY_UNIQUE_ID, "merchant_demo", "qr_1", "5999", "JPY",
+            new BigDecimal("1500"), "JP", "A Very Long Merchant Name Indeed Ltd", "Tokyo Metropolis City", null);
+        
+        String payload = EmvQrCodec.encode(yen);
+        
+        assertThat(payload).contains("530339254041500");
+        EmvQrCode decoded = EmvQrCodec.decode(payload);
+        assertThat(decoded.getMerchantName()).isEqualTo("A Very Long Merchant Name");
+        assertThat(decoded.getMerchantCity()).isEqualTo("Tokyo Metropoli");
+        assertThat(decoded.getBillNumber()).isNull();
+    }
+    
+    @Test
+    void shouldReadCodesFromOtherNetworks() {
+        EmvQrCode decoded = EmvQrCodec.decode(FOREIGN);
+        
+        assertThat(decoded.isDynamic()).isFalse();
+        assertThat(decoded.getGloballyUniqueId()).isNull();
+        assertThat(decoded.getQrPaymentId()).isNull();
+        assertThat(decoded.getCurrency()).isEqualTo("THB");
+        assertThat(decoded.getAmount()).isNull();
+        assertThat(decoded.getMerchantCity()).isEqualTo("BANGKOK");
+    }
+    
+    @Test
+    void shouldRejectWrongCrc() {
+        String payload = EmvQrCodec.encode(code(new BigDecimal("12.50"), "USD"));
+        String tampered = payload.replace("540512.50", "540599.50");
+        
+        assertThatThrownBy(() -> EmvQrCodec.decode(tampered))
+            .isInstanceOf(InvalidQrCodeException.class)
+            .hasMessageContaining("CRC");
+        assertThatThrownBy(() -> EmvQrCodec.decode(FOREIGN.substring(0, FOREIGN.length() - 8)))
+            .isInstanceOf(InvalidQrCodeException.class);
+    }
+    
+    @Test
+    void shouldRejectMalformedPayloads() {
+        assertThatThrownBy(() -> EmvQrCodec.decode(resign("000202010211")))
+            .hasMessageContaining("payload format indicator");
+        assertThatThrownBy(() -> EmvQrCodec.decode(resign("0002010102115204581253037645802TH5911NOODLE SHOP6007BANGKOK")))
+            .hasMessageContaining("merchant account information");
+        assertThatThrownBy(() -> EmvQrCodec.decode(resign("00020101021129080004ABCD52045812530300158"
+            + "02TH5911NOODLE SHOP6007BANGKOK")))
+            .hasMessageContaining("Unknown ISO 4217 currency");
+        assertThatThrownBy(() -> EmvQrCodec.decode("0002010102115299"))
+            .isInstanceOf(InvalidQrCodeException.class)
+            .hasMessageContaining("invalid length");
+        assertThatThrownBy(() -> EmvQrCodec.decode(resign("00020101021101021129080004ABCD")))
+            .hasMessageContaining("more than once");
+    }
+    
+    private static String resign(String body) {
+        String payload = body + "6304";
+        return payload + EmvQrCodec.crc(payload);
+    }
+}
@@ -0,0 +1,141 @@
+package com.paymentgateway.authorization.qr;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.PaymentStatus;
+import com.paymentgateway.authorization.domain.QrPayment;
+import com.paymentgateway.authorization.domain.QrPaymentStatus;
+import com.paymentgateway.authorization.dto.PaymentRequest;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.repository.QrPaymentRepository;
+import com.paymentgateway.authorization.service.PaymentService;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.ArgumentCaptor;
+
+import java.math.BigDecimal;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for issuing QR codes and paying them from a wallet.
+ */
+class QrPaymentServiceTest {
+    
+    private QrPaymentRepository repository;
+    private PaymentService paymentService;
+    private QrPaymentService service;
+    private Merchant merchant;
+    
+    @BeforeEach
+    void setUp() {
+        repository = mock(QrPaymentRepository.class);
+        paymentService = mock(PaymentService.class);
+        when(repository.save(any(QrPayment.class))).thenAnswer(invocation -> invocation.getArgument(0));
+        service = new QrPaymentService(repository, paymentService, Duration.ofMinutes(15), "ONLINE");
+        merchant = new Merchant();
+        merchant.setId(UUID.randomUUID());
+        merchant.setMerchantId("merchant_demo");
+        merchant.setMerchantName("Corner Grocery");
+    }
+    
+    private QrPayment issue() {
+        QrPayment qrPayment = service.create(merchant, new BigDecimal("25.00"), "EUR", "order-7");
+        when(repository.findByQrPaymentId(qrPayment.getQrPaymentId())).thenReturn(Optional.of(qrPayment));
+        return qrPayment;
+    }
+    
+    @Test
+    void shouldIssueCodeBoundToPayment() {
+        QrPayment qrPayment = issue();
+        
+        assertThat(qrPayment.getQrPaymentId()).startsWith("qr_");
+        assertThat(qrPayment.getStatus()).isEqualTo(QrPaymentStatus.OPEN);
+        assertThat(qrPayment.getExpiresAt()).isEqualTo(qrPayment.getCreatedAt().plus(Duration.ofMinutes(15)));
+        EmvQrCode code = EmvQrCodec.decode(qrPayment.getPayload());
+        assertThat(code.getQrPaymentId()).isEqualTo(qrPayment.getQrPaymentId());
+        assertThat(code.getMerchantId()).isEqualTo("merchant_demo");
+        assertThat(code.getAmount()).isEqualByComparingTo("25.00");
+        assertThat(code.getCurrency()).isEqualTo("EUR");
+        assertThat(code.getMerchantCategoryCode()).isEqualTo("5999");
+        assertThat(code.getBillNumber()).isEqualTo("order-7");
+    }
+    
+    @Test
+    void shouldRejectAmountsWithMoreDecimalsThanCurrency() {
+        assertThatThrownBy(() -> service.create(merchant, new BigDecimal("10.50"), "JPY", null))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    @Test
+    void shouldAuthorizeAndCaptureScannedCode() {
+        QrPayment qrPayment = issue();
+        when(paymentService.processPayment(any(PaymentRequest.class), eq(merchant.getId()), anyString()))
+            .thenReturn(new PaymentResponse("pay_1", PaymentStatus.AUTHORIZED, new BigDecimal("25.00"), "EUR"));
+        when(paymentService.capturePayment("pay_1"))
+            .thenReturn(new PaymentResponse("pay_1", PaymentStatus.CAPTURED, new BigDecimal("25.00"), "EUR"));
+        
+        QrScanResult result = service.pay(qrPayment.getPayload(), "4111111111111111", 12, 2030, "123");
+        
+        ArgumentCaptor<PaymentRequest> request = ArgumentCaptor.forClass(PaymentRequest.class);
+        verify(paymentService).processPayment(request.capture(), eq(merchant.getId()),
+            eq(qrPayment.getQrPaymentId() + "-1"));
+        assertThat(request.getValue().getAmount()).isEqualByComparingTo("25.00");
+        assertThat(request.getValue().getReferenceId()).isEqualTo("order-7");
+        assertThat(result.getPayment().getStatus()).isEqualTo(PaymentStatus.CAPTURED);
+        assertThat(qrPayment.getStatus()).isEqualTo(QrPaymentStatus.PAID);
+        assertThat(qrPayment.getPaymentId()).isEqualTo("pay_1");
+        assertThat(qrPayment.getPaidAt()).isNotNull();
+        
+        assertThatThrownBy(() -> service.pay(qrPayment.getPayload(), "4111111111111111", 12, 2030, "123"))
+            .isInstanceOf(QrPaymentUnavailableException.class)
+            .extracting("code").isEqualTo("QR_PAYMENT_ALREADY_PAID");
+    }
+    
+    @Test
+    void shouldLeaveCodeOpenAfterDecline() {
+        QrPayment qrPayment = issue();
+        when(paymentService.processPayment(any(PaymentRequest.class), eq(merchant.getId()), anyString()))
+            .thenReturn(new PaymentResponse("pay_1", PaymentStatus.DECLINED, new BigDecimal("25.00"), "EUR"))
+            .thenReturn(new PaymentResponse("pay_2", PaymentStatus.CAPTURED, new BigDecimal("25.00"), "EUR"));
+        
+        service.pay(qrPayment.getPayload(), "4000000000000002", 12, 2030, "123");
+        assertThat(qrPayment.getStatus()).isEqualTo(QrPaymentStatus.OPEN);
+        assertThat(qrPayment.getAttempts()).isEqualTo(1);
+        verify(paymentService, never()).capturePayment(anyString());
+        
+        service.pay(qrPayment.getPayload(), "4111111111111111", 12, 2030, "123");
+        verify(paymentService).processPayment(any(PaymentRequest.class), eq(merchant.getId()),
+            eq(qrPayment.getQrPaymentId() + "-2"));
+        assertThat(qrPayment.getStatus()).isEqualTo(QrPaymentStatus.PAID);
+    }
+    
+    @Test
+    void shouldRefuseAlteredExpiredAndUnknownCodes() {
+        QrPayment qrPayment = issue();
+        String body = qrPayment.getPayload().substring(0, qrPayment.getPayload().length() - 4)
+            .replace("540525.00", "540501.00");
+        String altered = body + EmvQrCodec.crc(body);
+        
+        assertThatThrownBy(() -> service.pay(altered, "4111111111111111", 12, 2030, "123"))
+            .extracting("code").isEqualTo("QR_CODE_MISMATCH");
+        
+        qrPayment.setExpiresAt(Instant.now().minusSeconds(1));
+        assertThatThrownBy(() -> service.pay(qrPayment.getPayload(), "4111111111111111", 12, 2030, "123"))
+            .extracting("code").isEqualTo("QR_PAYMENT_EXPIRED");
+        assertThat(qrPayment.getStatus()).isEqualTo(QrPaymentStatus.EXPIRED);
+        
+        when(repository.findByQrPaymentId(qrPayment.getQrPaymentId())).thenReturn(Optional.empty());
+        assertThatThrownBy(() -> service.pay(qrPayment.getPayload(), "4111111111111111", 12, 2030, "123"))
+            .extracting("code").isEqualTo("QR_PAYMENT_NOT_FOUND");
+        verifyNoInteractions(paymentService);
+    }
+}
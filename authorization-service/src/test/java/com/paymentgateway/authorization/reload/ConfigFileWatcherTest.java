@@ -0,0 +1,119 @@
+package com.paymentgateway.authorization.reload;
+
+import com.paymentgateway.authorization.geo.BinTable;
+import com.paymentgateway.authorization.psp.LatencyScenarios;
+import com.paymentgateway.authorization.psp.PSPAuthorizationRequest;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.io.TempDir;
+
+import java.math.BigDecimal;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.attribute.FileTime;
+import java.time.Instant;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.*;
+
+class ConfigFileWatcherTest {
+    
+    @TempDir
+    Path dir;
+    
+    @Test
+    void shouldSwapInChangedFaultRulesAndAuditThem() throws Exception {
+        Path file = write(dir.resolve("faults"), "psp=ADYEN,error=PSP_UNAVAILABLE", 1);
+        LatencyScenarios latency = new LatencyScenarios("");
+        ConfigReloadLog log = new ConfigReloadLog();
+        ConfigFileWatcher watcher = new ConfigFileWatcher(file.toString(), "", latency, new BinTable(""), log);
+        
+        assertThat(latency.plan("ADYEN", request(), 60).getErrorCode()).isEqualTo("PSP_UNAVAILABLE");
+        ConfigReloadLog.Entry startup = log.getEntries().get(0);
+        assertThat(startup.getTrigger()).isEqualTo(ConfigFileWatcher.STARTUP);
+        assertThat(startup.getSetting()).isEqualTo("psp.simulator.faults");
+        assertThat(startup.getChanges()).containsExactly("added fault rule psp=ADYEN,error=PSP_UNAVAILABLE");
+        
+        // Unchanged files are not reapplied
+        watcher.reload();
+        assertThat(log.getEntries()).hasSize(1);
+        
+        write(file, "psp=STRIPE,delay=500\npsp=ADYEN,error=PSP_UNAVAILABLE", 2);
+        watcher.reload();
+        ConfigReloadLog.Entry changed = log.getEntries().get(0);
+        assertThat(changed.getTrigger()).isEqualTo(ConfigFileWatcher.FILE_CHANGE);
+        assertThat(changed.getError()).isNull();
+        assertThat(changed.getChanges()).containsExactly("added fault rule psp=STRIPE,delay=500");
+        assertThat(changed.getDigest()).hasSize(12).isNotEqualTo(startup.getDigest());
+        assertThat(latency.plan("STRIPE", request(), 50).getDelayMillis()).isEqualTo(550);
+    }
+    
+    @Test
+    void shouldKeepTheRunningRulesWhenAFileIsRejected() throws Exception {
+        Path file = write(dir.resolve("faults"), "psp=ADYEN,error=PSP_UNAVAILABLE", 1);
+        LatencyScenarios latency = new LatencyScenarios("");
+        ConfigReloadLog log = new ConfigReloadLog();
+        ConfigFileWatcher watcher = new ConfigFileWatcher(file.toString(), "", latency, new BinTable(""), log);
+        
+        // The second rule is invalid, so neither is applied
+        write(file, "psp=STRIPE,delay=500;psp=ADYEN,rate=2,error=PSP_UNAVAILABLE", 2);
+        watcher.reload();
+        ConfigReloadLog.Entry rejected = log.getEntries().get(0);
+        assertThat(rejected.getError()).contains("rate must be above 0");
+        assertThat(rejected.getChanges()).isEmpty();
+        assertThat(latency.plan("ADYEN", request(), 60).getErrorCode()).isEqualTo("PSP_UNAVAILABLE");
+        assertThat(latency.plan("STRIPE", request(), 50).getDelayMillis()).isEqualTo(50);
+        
+        // A rejected file is reported once, not on every poll
+        watcher.reload();
+        assertThat(log.getEntries()).hasSize(2);
+    }
+    
+    @Test
+    void shouldReplaceTheBinTableOnlyWhenEveryEntryIsValid() throws Exception {
+        Path file = write(dir.resolve("bins"), "4:US\n400000826:GB", 1);
+        BinTable binTable = new BinTable("4:US");
+        ConfigReloadLog log = new ConfigReloadLog();
+        ConfigFileWatcher watcher = new ConfigFileWatcher("", file.toString(), new LatencyScenarios(""), binTable, log);
+        
+        assertThat(binTable.issuerCountry("4000008260000000")).isEqualTo("GB");
+        assertThat(log.getEntries().get(0).getChanges()).containsExactly("added BIN table entry 400000826:GB");
+        
+        // A bad country code rejects the whole table, and the old one stays
+        write(file, "4:US\n400000826:GB\n400000250:FRA", 2);
+        watcher.reload();
+        assertThat(log.getEntries().get(0).getSetting()).isEqualTo("payment.geo.bin-table");
+        assertThat(log.getEntries().get(0).getError()).contains("400000250:FRA");
+        assertThat(binTable.issuerCountry("4000002500000000")).isEqualTo("US");
+        
+        write(file, "4:US,400000250:FR", 3);
+        watcher.reload();
+        assertThat(log.getEntries().get(0).getChanges())
+            .containsExactly("removed BIN table entry 400000826:GB", "added BIN table entry 400000250:FR");
+        assertThat(binTable.issuerCountry("4000002500000000")).isEqualTo("FR");
+        assertThat(binTable.issuerCountry("4000008260000000")).isEqualTo("US");
+    }
+    
+    @Test
+    void shouldFailToStartWithAnInvalidFile() throws Exception {
+        Path file = write(dir.resolve("faults"), "psp=ADYEN", 1);
+        
+        assertThatThrownBy(() -> new ConfigFileWatcher(file.toString(), "", new LatencyScenarios(""),
+            new BinTable(""), new ConfigReloadLog()))
+            .isInstanceOf(IllegalStateException.class)
+            .hasMessageContaining("needs a delay or an error");
+    }
+    
+    private static PSPAuthorizationRequest request() {
+        return new PSPAuthorizationRequest(UUID.randomUUID(), new BigDecimal("20.00"), "USD", UUID.randomUUID());
+    }
+    
+    /**
+     * Writes the file with a distinct modification time, as an edit seconds
+     * after the last would have
+     */
+    private static Path write(Path file, String content, int second) throws Exception {
+        Files.writeString(file, content);
+        Files.setLastModifiedTime(file, FileTime.from(Instant.ofEpochSecond(1_700_000_000L + second)));
+        return file;
+    }
+}
@@ -0,0 +1,157 @@
+package com.paymentgateway.authorization.resilience;
+
+import com.paymentgateway.authorization.config.MetricsConfig.PaymentMetrics;
+import com.paymentgateway.authorization.monitoring.AlertService;
+import io.micrometer.core.instrument.MeterRegistry;
+import io.micrometer.core.instrument.simple.SimpleMeterRegistry;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.time.ZoneId;
+import java.time.ZoneOffset;
+import java.util.concurrent.CompletableFuture;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.atomic.AtomicInteger;
+
+import static org.assertj.core.api.Assertions.*;
+
+class CircuitBreakerRegistryTest {
+
+    private MutableClock clock;
+    private MeterRegistry meterRegistry;
+    private PaymentMetrics paymentMetrics;
+    private AlertService alertService;
+    private CircuitBreakerRegistry registry;
+
+    @BeforeEach
+    void setUp() {
+        clock = new MutableClock();
+        meterRegistry = new SimpleMeterRegistry();
+        paymentMetrics = new PaymentMetrics(meterRegistry);
+        alertService = new AlertService();
+        registry = new CircuitBreakerRegistry(3, Duration.ofSeconds(30), 2, Duration.ofSeconds(5),
+                                              "tokenization:QUEUE", clock, meterRegistry, paymentMetrics, alertService);
+    }
+
+    @Test
+    void shouldOpenAfterConsecutiveFailuresAndFailFast() {
+        CircuitBreaker breaker = registry.forNetwork("STRIPE");
+        AtomicInteger calls = new AtomicInteger();
+
+        for (int i = 0; i < 3; i++) {
+            assertThatThrownBy(() -> breaker.execute(() -> {
+                calls.incrementAndGet();
+                throw new IllegalStateException("connection reset");
+            })).isInstanceOf(IllegalStateException.class);
+        }
+
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.OPEN);
+        assertThatThrownBy(() -> breaker.execute(calls::incrementAndGet))
+            .isInstanceOf(CircuitBreakerOpenException.class)
+            .extracting("retryAt").isEqualTo(clock.instant().plusSeconds(30));
+        assertThat(calls.get()).isEqualTo(3);
+
+        // Exported per dependency and counted towards the open-breaker alert
+        assertThat(meterRegistry.get("circuit.breaker.state").tag("dependency", "network.STRIPE").gauge().value())
+            .isEqualTo(2.0);
+        assertThat(meterRegistry.get("circuit.breaker.rejected").tag("dependency", "network.STRIPE").functionCounter().count())
+            .isEqualTo(1.0);
+        assertThat(paymentMetrics.getCircuitBreakerOpenCount().get()).isEqualTo(1);
+        assertThat(alertService.getActiveAlerts())
+            .extracting(AlertService.Alert::getType)
+            .containsExactly(AlertService.AlertType.CIRCUIT_BREAKER_OPEN);
+    }
+
+    @Test
+    void shouldCloseAfterSuccessfulTrialCall() {
+        CircuitBreaker breaker = registry.forNetwork("ADYEN");
+        for (int i = 0; i < 3; i++) {
+            breaker.execute(() -> "error", "error"::equals, e -> true);
+        }
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.OPEN);
+
+        // A failed trial reopens the breaker for another open period
+        clock.advance(Duration.ofSeconds(30));
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.HALF_OPEN);
+        breaker.execute(() -> "error", "error"::equals, e -> true);
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.OPEN);
+
+        clock.advance(Duration.ofSeconds(30));
+        assertThat(breaker.execute(() -> "ok", "error"::equals, e -> true)).isEqualTo("ok");
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.CLOSED);
+        assertThat(paymentMetrics.getCircuitBreakerOpenCount().get()).isZero();
+    }
+
+    @Test
+    void shouldNotCountCallerErrorsAsFailures() {
+        CircuitBreaker breaker = registry.forNetwork("STRIPE");
+        for (int i = 0; i < 5; i++) {
+            assertThatThrownBy(() -> breaker.execute(() -> {
+                throw new IllegalArgumentException("bad request");
+            }, result -> false, e -> !(e instanceof IllegalArgumentException)))
+                .isInstanceOf(IllegalArgumentException.class);
+        }
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.CLOSED);
+    }
+
+    @Test
+    void shouldQueueCallsUntilBreakerCloses() throws Exception {
+        registry = new CircuitBreakerRegistry(1, Duration.ofMillis(200), 1, Duration.ofSeconds(5),
+                                              "tokenization:QUEUE", Clock.systemUTC(), null, null, null);
+        CircuitBreaker breaker = registry.get(CircuitBreakerRegistry.TOKENIZATION);
+        assertThat(breaker.getFallback()).isEqualTo(CircuitBreaker.Fallback.QUEUE);
+        breaker.execute(() -> "down", "down"::equals, e -> true);
+
+        CompletableFuture<String> queued = CompletableFuture.supplyAsync(() -> breaker.execute(() -> "token"));
+        while (breaker.getStatus().getQueued() == 0) {
+            Thread.sleep(5);
+        }
+        // The queue holds one caller; the next is turned away
+        assertThatThrownBy(() -> breaker.execute(() -> "token"))
+            .isInstanceOf(CircuitBreakerOpenException.class)
+            .hasMessageContaining("queue is full");
+
+        assertThat(queued.get(2, TimeUnit.SECONDS)).isEqualTo("token");
+        assertThat(breaker.getState()).isEqualTo(CircuitBreaker.State.CLOSED);
+    }
+
+    @Test
+    void shouldResetOpenBreaker() {
+        CircuitBreaker breaker = registry.forNetwork("STRIPE");
+        for (int i = 0; i < 3; i++) {
+            breaker.execute(() -> "error", "error"::equals, e -> true);
+        }
+
+        assertThat(registry.reset("network.STRIPE")).isTrue();
+        assertThat(registry.reset("hsm")).isFalse();
+        assertThat(breaker.getStatus().getState()).isEqualTo(CircuitBreaker.State.CLOSED);
+        assertThat(breaker.getStatus().getConsecutiveFailures()).isZero();
+        assertThat(registry.getAll()).extracting(CircuitBreaker::getDependency).containsExactly("network.STRIPE");
+    }
+
+    private static class MutableClock extends Clock {
+        private Instant now = Instant.parse("2026-01-01T00:00:00Z");
+
+        void advance(Duration duration) {
+            now = now.plus(duration);
+        }
+
+        @Override
+        public ZoneOffset getZone() {
+            return ZoneOffset.UTC;
+        }
+
+        @Override
+        public Clock withZone(ZoneId zone) {
+            return this;
+        }
+
+        @Override
+        public Instant instant() {
+            return now;
+        }
+    }
+}
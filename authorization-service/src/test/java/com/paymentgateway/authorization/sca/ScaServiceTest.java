@@ -0,0 +1,110 @@
+package com.paymentgateway.authorization.sca;
+
+import com.paymentgateway.authorization.currency.CurrencyConversionResult;
+import com.paymentgateway.authorization.currency.CurrencyConversionService;
+import com.paymentgateway.authorization.domain.ScaExemption;
+import com.paymentgateway.authorization.psp.PSPAuthorizationRequest;
+import com.paymentgateway.authorization.psp.PSPAuthorizationResponse;
+import com.paymentgateway.authorization.psp.ScaSoftDecline;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+
+import static org.assertj.core.api.Assertions.*;
+import static org.mockito.ArgumentMatchers.*;
+import static org.mockito.Mockito.*;
+
+class ScaServiceTest {
+    
+    private static final BigDecimal LOW_RISK = new BigDecimal("0.10");
+    
+    private CurrencyConversionService currencyConversionService;
+    private ScaService scaService;
+    
+    @BeforeEach
+    void setUp() {
+        currencyConversionService = mock(CurrencyConversionService.class);
+        when(currencyConversionService.convert(any(), anyString(), eq("EUR")))
+            .thenAnswer(invocation -> {
+                BigDecimal amount = invocation.getArgument(0);
+                String currency = invocation.getArgument(1);
+                BigDecimal rate = "GBP".equals(currency) ? new BigDecimal("1.165") : BigDecimal.ONE;
+                return new CurrencyConversionResult(amount, currency, amount.multiply(rate), "EUR", rate);
+            });
+        scaService = scaService("0.0013");
+    }
+    
+    private ScaService scaService(String referenceFraudRate) {
+        return new ScaService(currencyConversionService, new BigDecimal("30"),
+            new BigDecimal(referenceFraudRate), new BigDecimal("0.30"));
+    }
+    
+    @Test
+    void shouldLeavePaymentsOutsideEuropeOutOfScope() {
+        ScaAssessment assessment = scaService.assess(new BigDecimal("5000"), "USD", "US", LOW_RISK, false);
+        
+        assertThat(assessment.isInScope()).isFalse();
+        assertThat(assessment.isAuthenticationRequired()).isFalse();
+        verifyNoInteractions(currencyConversionService);
+    }
+    
+    @Test
+    void shouldExemptLowValuePayments() {
+        assertThat(scaService.assess(new BigDecimal("30.00"), "EUR", "FR", LOW_RISK, false).getExemption())
+            .isEqualTo(ScaExemption.LOW_VALUE);
+        // £26 is just over €30
+        assertThat(scaService.assess(new BigDecimal("26.00"), "GBP", "GB", LOW_RISK, false).getExemption())
+            .isEqualTo(ScaExemption.TRANSACTION_RISK_ANALYSIS);
+    }
+    
+    @Test
+    void shouldApplyTransactionRiskAnalysisWithinFraudRateLimit() {
+        assertThat(scaService.assess(new BigDecimal("100"), "EUR", "DE", LOW_RISK, false).getExemption())
+            .isEqualTo(ScaExemption.TRANSACTION_RISK_ANALYSIS);
+        assertThat(scaService.assess(new BigDecimal("100.01"), "EUR", "DE", LOW_RISK, false).isAuthenticationRequired())
+            .isTrue();
+        
+        // A risky payment is not eligible whatever the amount
+        assertThat(scaService.assess(new BigDecimal("50"), "EUR", "DE", new BigDecimal("0.45"), false).isAuthenticationRequired())
+            .isTrue();
+        
+        // Lower reference fraud rates allow higher amounts
+        assertThat(scaService("0.0006").assess(new BigDecimal("250"), "EUR", "DE", LOW_RISK, false).getExemption())
+            .isEqualTo(ScaExemption.TRANSACTION_RISK_ANALYSIS);
+        assertThat(scaService("0.0001").assess(new BigDecimal("500"), "EUR", "DE", LOW_RISK, false).getExemption())
+            .isEqualTo(ScaExemption.TRANSACTION_RISK_ANALYSIS);
+        assertThat(scaService("0.0020").assess(new BigDecimal("50"), "EUR", "DE", LOW_RISK, false).isAuthenticationRequired())
+            .isTrue();
+    }
+    
+    @Test
+    void shouldNotRequireExemptionForAuthenticatedPayments() {
+        ScaAssessment assessment = scaService.assess(new BigDecimal("5000"), "EUR", "NL", LOW_RISK, true);
+        
+        assertThat(assessment.isInScope()).isTrue();
+        assertThat(assessment.isAuthenticated()).isTrue();
+        assertThat(assessment.getExemption()).isNull();
+        assertThat(assessment.isAuthenticationRequired()).isFalse();
+    }
+    
+    @Test
+    void issuerShouldSoftDeclineUnauthenticatedPaymentsWithoutExemption() {
+        PSPAuthorizationRequest request = new PSPAuthorizationRequest();
+        request.setCardBrand("MASTERCARD");
+        assertThat(ScaSoftDecline.check(request)).isNull();
+        
+        request.setScaInScope(true);
+        PSPAuthorizationResponse response = ScaSoftDecline.check(request);
+        assertThat(response.getStatus()).isEqualTo("DECLINED");
+        assertThat(response.getDeclineCode()).isEqualTo(ScaSoftDecline.MASTERCARD_CODE);
+        assertThat(ScaSoftDecline.isSoftDecline(response.getDeclineCode())).isTrue();
+        
+        request.setScaExemption(ScaExemption.LOW_VALUE.name());
+        assertThat(ScaSoftDecline.check(request)).isNull();
+        
+        request.setScaExemption(null);
+        request.setCavv("AAABBEg0VhI0VniQEjRWAAAAAAA=");
+        assertThat(ScaSoftDecline.check(request)).isNull();
+    }
+}
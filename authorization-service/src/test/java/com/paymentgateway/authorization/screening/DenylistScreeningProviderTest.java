@@ -0,0 +1,50 @@
+package com.paymentgateway.authorization.screening;
+
+import com.paymentgateway.authorization.domain.ScreeningSubjectType;
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+import java.util.stream.Collectors;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class DenylistScreeningProviderTest {
+    
+    private final DenylistScreeningProvider provider = new DenylistScreeningProvider(
+        List.of("Ivan Petrov", "Acme Shell Holdings"), List.of("kp", "IR"));
+    
+    @Test
+    void shouldMatchListedNamesRegardlessOfCaseAccentsAndPunctuation() {
+        assertThat(entries(null, "IVAN PETROV")).containsExactly("name ivan petrov");
+        assertThat(entries(null, "Mr. Iván  Petrov")).containsExactly("name ivan petrov");
+        assertThat(entries(null, "ACME-Shell Holdings, Ltd")).containsExactly("name acme shell holdings");
+    }
+    
+    @Test
+    void shouldNotMatchPartsOfWords() {
+        assertThat(entries(null, "Ivana Petrova")).isEmpty();
+        assertThat(entries(null, "Petrov Ivan")).isEmpty();
+    }
+    
+    @Test
+    void shouldMatchListedCountries() {
+        assertThat(entries("KP", "Jane Doe")).containsExactly("country KP");
+        assertThat(entries("US", "Jane Doe")).isEmpty();
+        assertThat(entries(null, null)).isEmpty();
+    }
+    
+    @Test
+    void shouldReadCommaSeparatedConfiguration() {
+        DenylistScreeningProvider configured = new DenylistScreeningProvider(" Ivan Petrov ,, ", "CU, SY");
+        
+        assertThat(configured.screen(new ScreeningSubject(ScreeningSubjectType.CARDHOLDER, "Ivan Petrov", "SY")))
+            .extracting(ScreeningMatch::getEntry)
+            .containsExactly("country SY", "name ivan petrov");
+    }
+    
+    private List<String> entries(String country, String name) {
+        return provider.screen(new ScreeningSubject(ScreeningSubjectType.CARDHOLDER, name, country)).stream()
+            .map(ScreeningMatch::getEntry)
+            .collect(Collectors.toList());
+    }
+}
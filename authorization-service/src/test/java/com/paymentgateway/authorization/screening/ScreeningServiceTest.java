@@ -0,0 +1,131 @@
+package com.paymentgateway.authorization.screening;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.ScreeningHit;
+import com.paymentgateway.authorization.domain.ScreeningHitStatus;
+import com.paymentgateway.authorization.domain.ScreeningSubjectType;
+import com.paymentgateway.authorization.repository.ScreeningHitRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+
+import java.time.Clock;
+import java.time.Instant;
+import java.time.ZoneOffset;
+import java.util.List;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyCollection;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+class ScreeningServiceTest {
+    
+    private static final Instant NOW = Instant.parse("2024-03-01T10:00:00Z");
+    
+    @Mock
+    private ScreeningHitRepository hitRepository;
+    
+    private final ScreeningSubject cardholder =
+        new ScreeningSubject(ScreeningSubjectType.CARDHOLDER, "Ivan Petrov", "US");
+    
+    private ScreeningService screeningService;
+    
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        screeningService = service(true, new DenylistScreeningProvider(List.of("Ivan Petrov"), List.of()));
+        when(hitRepository.save(any(ScreeningHit.class))).thenAnswer(inv -> inv.getArgument(0));
+    }
+    
+    @Test
+    void shouldCollectMatchesOfEveryProvider() {
+        ScreeningProvider external = mock(ScreeningProvider.class);
+        when(external.screen(cardholder)).thenReturn(List.of(new ScreeningMatch("ofac", "SDN 12345")));
+        screeningService = service(true, new DenylistScreeningProvider(List.of("Ivan Petrov"), List.of()), external);
+        
+        assertThat(screeningService.screen(cardholder))
+            .extracting(ScreeningMatch::getProvider)
+            .containsExactly("denylist", "ofac");
+    }
+    
+    @Test
+    void shouldHoldSubjectWhenProviderFails() {
+        ScreeningProvider external = mock(ScreeningProvider.class);
+        when(external.getProviderName()).thenReturn("ofac");
+        when(external.screen(any())).thenThrow(new IllegalStateException("timeout"));
+        screeningService = service(true, external);
+        
+        assertThat(screeningService.screen(cardholder))
+            .extracting(ScreeningMatch::getEntry)
+            .containsExactly(ScreeningService.PROVIDER_UNAVAILABLE);
+    }
+    
+    @Test
+    void shouldScreenNobodyWhenDisabled() {
+        screeningService = service(false, new DenylistScreeningProvider(List.of("Ivan Petrov"), List.of()));
+        
+        assertThat(screeningService.screen(cardholder)).isEmpty();
+    }
+    
+    @Test
+    void shouldRecordHeldHitPerMatch() {
+        UUID merchantId = UUID.randomUUID();
+        
+        List<ScreeningHit> hits = screeningService.hold(cardholder, merchantId, "pay_123",
+            screeningService.screen(cardholder));
+        
+        assertThat(hits).hasSize(1);
+        ScreeningHit hit = hits.get(0);
+        assertThat(hit.getHitId()).matches("scr_[0-9a-f]{24}");
+        assertThat(hit.getStatus()).isEqualTo(ScreeningHitStatus.HELD);
+        assertThat(hit.getMerchantId()).isEqualTo(merchantId);
+        assertThat(hit.getPaymentId()).isEqualTo("pay_123");
+        assertThat(hit.getScreenedName()).isEqualTo("Ivan Petrov");
+        assertThat(hit.getMatchedEntry()).isEqualTo("name ivan petrov");
+        assertThat(hit.getCreatedAt()).isEqualTo(NOW);
+    }
+    
+    @Test
+    void shouldRefuseCaptureOfHeldPayment() {
+        when(hitRepository.existsByPaymentIdAndStatus("pay_123", ScreeningHitStatus.HELD)).thenReturn(true);
+        
+        assertThatThrownBy(() -> screeningService.checkPaymentCleared("pay_123"))
+            .isInstanceOf(ScreeningHoldException.class);
+        screeningService.checkPaymentCleared("pay_456");
+    }
+    
+    @Test
+    void shouldRefuseMerchantWithUnreleasedHits() {
+        Merchant merchant = new Merchant("mch_1", "Corner Shop");
+        merchant.setId(UUID.randomUUID());
+        when(hitRepository.existsByMerchantIdAndSubjectTypeAndStatusIn(eq(merchant.getId()),
+            eq(ScreeningSubjectType.MERCHANT), anyCollection())).thenReturn(true);
+        
+        assertThatThrownBy(() -> screeningService.checkMerchantCleared(merchant))
+            .isInstanceOf(ScreeningHoldException.class);
+    }
+    
+    @Test
+    void shouldDecideHeldHitOnlyOnce() {
+        ScreeningHit hit = new ScreeningHit();
+        hit.setHitId("scr_1");
+        
+        ScreeningHit released = screeningService.release(hit, "ADMIN_001", "Different date of birth");
+        
+        assertThat(released.getStatus()).isEqualTo(ScreeningHitStatus.RELEASED);
+        assertThat(released.getDecidedBy()).isEqualTo("ADMIN_001");
+        assertThat(released.getDecisionNote()).isEqualTo("Different date of birth");
+        assertThat(released.getDecidedAt()).isEqualTo(NOW);
+        assertThatThrownBy(() -> screeningService.confirm(hit, "ADMIN_001", null))
+            .isInstanceOf(IllegalStateException.class);
+    }
+    
+    private ScreeningService service(boolean enabled, ScreeningProvider... providers) {
+        return new ScreeningService(List.of(providers), hitRepository, enabled, Clock.fixed(NOW, ZoneOffset.UTC));
+    }
+}
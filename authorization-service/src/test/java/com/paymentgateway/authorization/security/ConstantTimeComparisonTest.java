@@ -0,0 +1,107 @@
+package com.paymentgateway.authorization.security;
+
+import org.junit.jupiter.api.Test;
+
+import java.io.IOException;
+import java.io.UncheckedIOException;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+import java.util.stream.Stream;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+/**
+ * Secrets may only be compared with MessageDigest.isEqual. equals and ==
+ * return at the first differing character, which leaks through timing how
+ * much of a guess was right. The Go services check the same with
+ * go-common's securetest.
+ */
+class ConstantTimeComparisonTest {
+    
+    // Identifiers that hold secrets or values derived from them, as in securetest
+    private static final Pattern SECRET_NAME = Pattern.compile(
+        "(?i)(hash|mac$|^mac|hmac|kcv|checkvalue|apikey|secret|fingerprint|signature|credential|password)");
+    
+    // An operand: a name, field or getter chain such as a.getB()
+    private static final String OPERAND = "([\\w.]+(?:\\(\\))?)";
+    private static final Pattern COMPARISON = Pattern.compile(
+        OPERAND + "\\.equals\\(" + OPERAND + "\\)"
+        + "|Objects\\.equals\\(" + OPERAND + ",\\s*" + OPERAND + "\\)"
+        + "|" + OPERAND + "\\s*[!=]=\\s*" + OPERAND);
+    
+    @Test
+    void shouldCompareSecretsInConstantTime() {
+        assertThat(scan(Path.of("src/main/java")))
+            .as("compare secrets with MessageDigest.isEqual")
+            .isEmpty();
+    }
+    
+    @Test
+    void scanShouldFindDirectSecretComparisons() {
+        List<String> findings = comparisons(String.join("\n",
+            "if (webhook.getSecret().equals(endpoint.getSigningSecret())) {",
+            "    return Objects.equals(expectedMac, mac) || keyHash != storedHash;",
+            "}",
+            // Comparisons with a literal or constant reveal nothing
+            "if (signature == null || apiKey.equals(\"\") || status.equals(ACTIVE)) {",
+            "    return MessageDigest.isEqual(expected, provided) && count == limit;",
+            "}"));
+        
+        assertThat(findings).containsExactly(
+            "webhook.getSecret().equals(endpoint.getSigningSecret())",
+            "Objects.equals(expectedMac, mac)",
+            "keyHash != storedHash");
+    }
+    
+    private static List<String> scan(Path root) {
+        List<String> findings = new ArrayList<>();
+        try (Stream<Path> files = Files.walk(root)) {
+            for (Path file : (Iterable<Path>) files.filter(f -> f.toString().endsWith(".java"))::iterator) {
+                for (String finding : comparisons(Files.readString(file))) {
+                    findings.add(root.relativize(file) + ": " + finding);
+                }
+            }
+        } catch (IOException e) {
+            throw new UncheckedIOException(e);
+        }
+        return findings;
+    }
+    
+    private static List<String> comparisons(String source) {
+        List<String> findings = new ArrayList<>();
+        Matcher matcher = COMPARISON.matcher(source);
+        while (matcher.find()) {
+            List<String> operands = new ArrayList<>();
+            for (int i = 1; i <= matcher.groupCount(); i++) {
+                if (matcher.group(i) != null) {
+                    operands.add(matcher.group(i));
+                }
+            }
+            if (operands.stream().noneMatch(ConstantTimeComparisonTest::constant)
+                    && operands.stream().anyMatch(ConstantTimeComparisonTest::secret)) {
+                findings.add(matcher.group().trim());
+            }
+        }
+        return findings;
+    }
+    
+    private static boolean constant(String operand) {
+        String last = operand.substring(operand.lastIndexOf('.') + 1);
+        return operand.equals("null") || operand.equals("true") || operand.equals("false")
+            || last.matches("[A-Z][A-Z0-9_]*|\\d.*");
+    }
+    
+    /**
+     * Whether the operand is read from a name like a secret's: x for x, the
+     * field for a.b, the property for a.getB()
+     */
+    private static boolean secret(String operand) {
+        String name = operand.replace("()", "");
+        name = name.substring(name.lastIndexOf('.') + 1).replaceFirst("^(get|is)(?=[A-Z])", "");
+        return SECRET_NAME.matcher(name).find();
+    }
+}
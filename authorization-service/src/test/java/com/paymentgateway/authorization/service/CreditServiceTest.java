@@ -0,0 +1,121 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.*;
+import com.paymentgateway.authorization.dto.CreditRequest;
+import com.paymentgateway.authorization.dto.PaymentResponse;
+import com.paymentgateway.authorization.event.PaymentEventPublisher;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import com.paymentgateway.authorization.psp.CardFingerprint;
+import com.paymentgateway.authorization.psp.LatencyScenarios;
+import com.paymentgateway.authorization.psp.NetworkRules;
+import com.paymentgateway.authorization.psp.PSPRoutingService;
+import com.paymentgateway.authorization.psp.SimulatedIssuer;
+import com.paymentgateway.authorization.psp.SimulatedNetwork;
+import com.paymentgateway.authorization.psp.StandInProcessor;
+import com.paymentgateway.authorization.psp.StripePSPClient;
+import com.paymentgateway.authorization.repository.PaymentEventRepository;
+import com.paymentgateway.authorization.repository.PaymentRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.ArgumentCaptor;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+
+import java.math.BigDecimal;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.Mockito.*;
+
+class CreditServiceTest {
+    
+    private static final String CARD = "4111111111111111";
+    
+    @Mock
+    private PaymentRepository paymentRepository;
+    
+    @Mock
+    private PaymentEventRepository paymentEventRepository;
+    
+    @Mock
+    private PSPRoutingService pspRoutingService;
+    
+    @Mock
+    private PaymentEventPublisher eventPublisher;
+    
+    private SimulatedIssuer issuer;
+    private CreditService creditService;
+    
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        issuer = new SimulatedIssuer(CARD + ":100.00");
+        when(pspRoutingService.selectPSP(any(UUID.class))).thenReturn(new StripePSPClient(network(issuer)));
+        when(paymentRepository.save(any(Payment.class))).thenAnswer(invocation -> {
+            Payment payment = invocation.getArgument(0);
+            payment.setId(UUID.randomUUID());
+            return payment;
+        });
+        creditService = new CreditService(paymentRepository, paymentEventRepository, pspRoutingService, eventPublisher);
+    }
+    
+    @Test
+    void shouldCreditCardForEnabledMerchant() {
+        PaymentResponse response = creditService.processCredit(createCreditRequest("25.00"), createMerchant(true, "5999"));
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.CAPTURED);
+        assertThat(response.getPaymentId()).startsWith("crd_");
+        assertThat(issuer.getOpenToBuy(CardFingerprint.of(CARD))).isEqualByComparingTo("125.00");
+        
+        ArgumentCaptor<Payment> saved = ArgumentCaptor.forClass(Payment.class);
+        verify(paymentRepository).save(saved.capture());
+        assertThat(saved.getValue().getTransactionType()).isEqualTo(TransactionType.CREDIT);
+        assertThat(saved.getValue().getCapturedAt()).isNotNull();
+        verify(eventPublisher).publishPaymentEvent(any(Payment.class), eq(PaymentEventType.PAYMENT_REFUNDED));
+    }
+    
+    @Test
+    void shouldRejectCreditWhenMerchantNotEnabled() {
+        assertThatThrownBy(() -> creditService.processCredit(createCreditRequest("25.00"), createMerchant(false, "5999")))
+            .isInstanceOf(IllegalStateException.class)
+            .hasMessageContaining("not enabled");
+        
+        verifyNoInteractions(pspRoutingService, paymentRepository);
+    }
+    
+    @Test
+    void shouldDeclineCreditForRestrictedMerchantCategory() {
+        // Gambling merchants pay winnings out as funds transfers, not refunds
+        PaymentResponse response = creditService.processCredit(createCreditRequest("25.00"), createMerchant(true, "7995"));
+        
+        assertThat(response.getStatus()).isEqualTo(PaymentStatus.DECLINED);
+        assertThat(response.getErrorCode()).isEqualTo(NetworkRules.NOT_PERMITTED);
+        assertThat(issuer.getOpenToBuy(CardFingerprint.of(CARD))).isEqualByComparingTo("100.00");
+        verify(eventPublisher).publishPaymentEvent(any(Payment.class), eq(PaymentEventType.PAYMENT_DECLINED));
+    }
+    
+    private CreditRequest createCreditRequest(String amount) {
+        CreditRequest request = new CreditRequest();
+        request.setCardNumber(CARD);
+        request.setExpiryMonth(12);
+        request.setExpiryYear(2030);
+        request.setAmount(new BigDecimal(amount));
+        request.setCurrency("USD");
+        request.setReason("Goodwill credit");
+        return request;
+    }
+    
+    private Merchant createMerchant(boolean creditsEnabled, String mcc) {
+        Merchant merchant = new Merchant("merch_credit", "Credit Merchant");
+        merchant.setId(UUID.randomUUID());
+        merchant.setMcc(mcc);
+        merchant.setStandaloneCreditsEnabled(creditsEnabled);
+        return merchant;
+    }
+    
+    private static SimulatedNetwork network(SimulatedIssuer issuer) {
+        return new SimulatedNetwork(issuer, new LatencyScenarios(""), new StandInProcessor(issuer));
+    }
+}
@@ -0,0 +1,86 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.service.MerchantLifecycleService.RestoreResult;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.time.ZoneOffset;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.Mockito.*;
+
+class MerchantLifecycleServiceTest {
+
+    private static final Instant NOW = Instant.parse("2026-03-31T12:00:00Z");
+
+    @Mock
+    private MerchantRepository merchantRepository;
+
+    private MerchantLifecycleService lifecycleService;
+    private Merchant merchant;
+
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        lifecycleService = new MerchantLifecycleService(merchantRepository, Duration.ofDays(30),
+                                                        Clock.fixed(NOW, ZoneOffset.UTC));
+        merchant = new Merchant("merchant_123", "Test Merchant");
+        merchant.setId(UUID.randomUUID());
+    }
+
+    @Test
+    void shouldSoftDeleteLiveMerchant() {
+        when(merchantRepository.findByMerchantId("merchant_123")).thenReturn(Optional.of(merchant));
+        when(merchantRepository.save(any(Merchant.class))).thenAnswer(inv -> inv.getArgument(0));
+
+        Optional<Merchant> deleted = lifecycleService.delete("merchant_123");
+
+        assertThat(deleted).isPresent();
+        assertThat(deleted.get().getDeletedAt()).isEqualTo(NOW);
+        assertThat(lifecycleService.restorableUntil(deleted.get())).isEqualTo(NOW.plus(Duration.ofDays(30)));
+        verify(merchantRepository, never()).delete(any());
+    }
+
+    @Test
+    void shouldNotDeleteUnknownOrAlreadyDeletedMerchant() {
+        when(merchantRepository.findByMerchantId("merchant_123")).thenReturn(Optional.empty());
+
+        assertThat(lifecycleService.delete("merchant_123")).isEmpty();
+        verify(merchantRepository, never()).save(any());
+    }
+
+    @Test
+    void shouldRestoreWithinWindowOnly() {
+        merchant.setDeletedAt(NOW.minus(Duration.ofDays(29)));
+        when(merchantRepository.findDeletedByMerchantId("merchant_123")).thenReturn(Optional.of(merchant));
+
+        assertThat(lifecycleService.restore("merchant_123")).isEqualTo(RestoreResult.RESTORED);
+        verify(merchantRepository).restore(merchant.getId());
+
+        merchant.setDeletedAt(NOW.minus(Duration.ofDays(31)));
+        assertThat(lifecycleService.restore("merchant_123")).isEqualTo(RestoreResult.WINDOW_EXPIRED);
+        verify(merchantRepository, times(1)).restore(merchant.getId());
+
+        when(merchantRepository.findDeletedByMerchantId("merchant_999")).thenReturn(Optional.empty());
+        assertThat(lifecycleService.restore("merchant_999")).isEqualTo(RestoreResult.NOT_FOUND);
+    }
+
+    @Test
+    void shouldPurgeMerchantsDeletedBeforeWindow() {
+        Instant cutoff = NOW.minus(Duration.ofDays(30));
+        when(merchantRepository.purgeDeleted(cutoff)).thenReturn(2);
+
+        assertThat(lifecycleService.purgeDeleted()).isEqualTo(2);
+        verify(merchantRepository).purgeDeletedRoles(cutoff);
+    }
+}
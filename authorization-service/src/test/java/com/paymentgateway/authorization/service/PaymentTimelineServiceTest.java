@@ -0,0 +1,132 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Payment;
+import com.paymentgateway.authorization.domain.PaymentEvent;
+import com.paymentgateway.authorization.domain.PaymentStatus;
+import com.paymentgateway.authorization.domain.WebhookDelivery;
+import com.paymentgateway.authorization.dto.PaymentTimelineResponse;
+import com.paymentgateway.authorization.repository.PaymentEventRepository;
+import com.paymentgateway.authorization.repository.PaymentRepository;
+import com.paymentgateway.authorization.repository.SettlementRecordRepository;
+import com.paymentgateway.authorization.repository.SettlementRecordRepository.SettlementRecord;
+import com.paymentgateway.authorization.repository.WebhookDeliveryRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.Mockito.*;
+
+class PaymentTimelineServiceTest {
+
+    private static final Instant START = Instant.parse("2026-03-01T10:00:00Z");
+
+    @Mock
+    private PaymentRepository paymentRepository;
+
+    @Mock
+    private PaymentEventRepository paymentEventRepository;
+
+    @Mock
+    private SettlementRecordRepository settlementRecordRepository;
+
+    @Mock
+    private WebhookDeliveryRepository webhookDeliveryRepository;
+
+    private PaymentTimelineService timelineService;
+    private Payment payment;
+
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setPaymentId("pay_timeline");
+        payment.setStatus(PaymentStatus.CAPTURED);
+        when(paymentRepository.findByPaymentId("pay_timeline")).thenReturn(Optional.of(payment));
+        timelineService = new PaymentTimelineService(paymentRepository, paymentEventRepository,
+                                                     settlementRecordRepository, webhookDeliveryRepository);
+    }
+
+    @Test
+    void shouldMergeEventsSettlementAndWebhooksInTimeOrder() {
+        UUID correlationId = UUID.randomUUID();
+        PaymentEvent request = event("AUTHORIZATION_REQUEST", "SENT", correlationId, START.plusMillis(10));
+        PaymentEvent response = event("AUTHORIZATION", "AUTHORIZED", correlationId, START.plusMillis(250));
+        response.setErrorCode("00");
+        response.setDescription("AUTHORIZED via STRIPE:AUTHORIZED");
+        PaymentEvent capture = event("CAPTURE", "SUCCESS", UUID.randomUUID(), START.plusSeconds(60));
+        capture.setTraceId("4bf92f3577b34da6a3ce929d0e0e4736");
+        when(paymentEventRepository.findByPaymentIdOrderByCreatedAtAsc(payment.getId()))
+            .thenReturn(List.of(event("TOKENIZATION", "SUCCESS", correlationId, START), request, response, capture));
+        when(settlementRecordRepository.findByPaymentId(payment.getId())).thenReturn(List.of(
+            new SettlementRecord("BATCH-1", LocalDate.of(2026, 3, 1), "COMPLETED",
+                                 new BigDecimal("97.10"), "USD", START.plusSeconds(3600))));
+        WebhookDelivery webhook = new WebhookDelivery();
+        webhook.setId(UUID.randomUUID());
+        webhook.setEventType("payment.captured");
+        webhook.setStatus("DELIVERED");
+        webhook.setAttemptCount(1);
+        webhook.setHttpStatusCode(200);
+        webhook.setCreatedAt(START.plusSeconds(61));
+        webhook.setDeliveredAt(START.plusSeconds(61).plusMillis(120));
+        when(webhookDeliveryRepository.findByPaymentIdOrderByCreatedAtDesc(payment.getId())).thenReturn(List.of(webhook));
+
+        PaymentTimelineResponse timeline = timelineService.getTimeline("pay_timeline");
+
+        assertThat(timeline.getPaymentId()).isEqualTo("pay_timeline");
+        assertThat(timeline.getEntries())
+            .extracting(PaymentTimelineResponse.Entry::getStep)
+            .containsExactly("TOKENIZATION", "AUTHORIZATION_REQUEST", "AUTHORIZATION_RESPONSE",
+                             "CAPTURE", "WEBHOOK", "SETTLEMENT");
+        PaymentTimelineResponse.Entry authorization = timeline.getEntries().get(2);
+        assertThat(authorization.getCorrelationId()).isEqualTo(correlationId.toString());
+        assertThat(authorization.getDetail()).isEqualTo("AUTHORIZED via STRIPE:AUTHORIZED, code 00");
+        PaymentTimelineResponse.Entry delivery = timeline.getEntries().get(4);
+        assertThat(delivery.getDetail()).isEqualTo("payment.captured, 1 attempt(s), HTTP 200");
+        assertThat(delivery.getDurationMs()).isEqualTo(120);
+        assertThat(timeline.getEntries().get(5).getDetail()).contains("BATCH-1", "97.10 USD");
+        assertThat(timeline.getTraceIds()).containsExactly("4bf92f3577b34da6a3ce929d0e0e4736");
+    }
+
+    @Test
+    void shouldFallBackToSettledAtWithoutSettlementRecords() {
+        payment.setSettledAt(START.plusSeconds(7200));
+        when(paymentEventRepository.findByPaymentIdOrderByCreatedAtAsc(payment.getId())).thenReturn(List.of());
+        when(settlementRecordRepository.findByPaymentId(payment.getId())).thenReturn(List.of());
+        when(webhookDeliveryRepository.findByPaymentIdOrderByCreatedAtDesc(payment.getId())).thenReturn(List.of());
+
+        PaymentTimelineResponse timeline = timelineService.getTimeline("pay_timeline");
+
+        assertThat(timeline.getEntries()).singleElement()
+            .satisfies(entry -> {
+                assertThat(entry.getStep()).isEqualTo("SETTLEMENT");
+                assertThat(entry.getTimestamp()).isEqualTo(START.plusSeconds(7200));
+            });
+        assertThat(timeline.getTraceIds()).isEmpty();
+    }
+
+    @Test
+    void shouldRejectUnknownPayment() {
+        when(paymentRepository.findByPaymentId("pay_missing")).thenReturn(Optional.empty());
+
+        assertThatThrownBy(() -> timelineService.getTimeline("pay_missing"))
+            .hasMessageContaining("Payment not found");
+    }
+
+    private PaymentEvent event(String type, String status, UUID correlationId, Instant at) {
+        PaymentEvent event = new PaymentEvent(payment.getId(), type, status);
+        event.setCorrelationId(correlationId);
+        event.setCreatedAt(at);
+        return event;
+    }
+}
@@ -6,6 +6,7 @@ import com.paymentgateway.authorization.dto.RefundResponse;
 import com.paymentgateway.authorization.psp.PSPClient;
 import com.paymentgateway.authorization.psp.PSPRefundResponse;
 import com.paymentgateway.authorization.psp.PSPRoutingService;
+import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.repository.PaymentEventRepository;
 import com.paymentgateway.authorization.repository.PaymentRepository;
 import com.paymentgateway.authorization.repository.RefundRepository;
@@ -15,6 +16,7 @@ import org.mockito.Mock;
 import org.mockito.MockitoAnnotations;
 
 import java.math.BigDecimal;
+import java.time.Duration;
 import java.time.Instant;
 import java.util.Arrays;
 import java.util.Optional;
@@ -46,18 +48,15 @@ class RefundServiceTest {
     @Mock
     private com.paymentgateway.authorization.event.PaymentEventPublisher eventPublisher;
     
+    @Mock
+    private MerchantRepository merchantRepository;
+    
     private RefundService refundService;
     
     @BeforeEach
     void setUp() {
         MockitoAnnotations.openMocks(this);
-        refundService = new RefundService(
-            refundRepository,
-            paymentRepository,
-            paymentEventRepository,
-            pspRoutingService,
-            eventPublisher
-        );
+        refundService = policyService(null, null);
     }
     
     @Test
@@ -331,6 +330,79 @@ class RefundServiceTest {
         assertThat(response.getErrorMessage()).contains("PSP service unavailable");
     }
     
+    @Test
+    void shouldRejectRefundsOutsideMerchantPolicy() {
+        Payment payment = createCapturedPayment(new BigDecimal("100.00"));
+        payment.setCapturedAt(Instant.now().minus(Duration.ofDays(31)));
+        Merchant merchant = new Merchant();
+        merchant.setRefundWindowDays(30);
+        when(merchantRepository.findById(payment.getMerchantId())).thenReturn(Optional.of(merchant));
+        when(paymentRepository.findByPaymentId(payment.getPaymentId())).thenReturn(Optional.of(payment));
+        when(refundRepository.sumRefundedAmountByPaymentIdAndStatuses(any(UUID.class), anyList()))
+            .thenReturn(BigDecimal.ZERO);
+        RefundService policyService = policyService(null, 2);
+        
+        assertThatThrownBy(() -> policyService.processRefund(
+                new RefundRequest(payment.getPaymentId(), new BigDecimal("10.00")), payment.getMerchantId()))
+            .isInstanceOf(RefundPolicyException.class)
+            .extracting("code").isEqualTo(RefundPolicyException.WINDOW_EXPIRED);
+        
+        // The default limit of 2 refunds applies as the merchant sets none
+        payment.setCapturedAt(Instant.now());
+        when(refundRepository.countByPaymentIdAndStatusIn(any(UUID.class), anyList())).thenReturn(2L);
+        assertThatThrownBy(() -> policyService.processRefund(
+                new RefundRequest(payment.getPaymentId(), new BigDecimal("10.00")), payment.getMerchantId()))
+            .extracting("code").isEqualTo(RefundPolicyException.COUNT_EXCEEDED);
+        verify(refundRepository, never()).save(any(Refund.class));
+    }
+    
+    @Test
+    void shouldLetOnlyAdminsOverrideRefundPolicy() {
+        Payment payment = createCapturedPayment(new BigDecimal("100.00"));
+        when(paymentRepository.findByPaymentId(payment.getPaymentId())).thenReturn(Optional.of(payment));
+        when(refundRepository.sumRefundedAmountByPaymentIdAndStatuses(any(UUID.class), anyList()))
+            .thenReturn(new BigDecimal("50.00"));
+        when(refundRepository.countByPaymentIdAndStatusIn(any(UUID.class), anyList())).thenReturn(1L);
+        when(refundRepository.save(any(Refund.class))).thenAnswer(invocation -> invocation.getArgument(0));
+        when(pspRoutingService.selectPSP(any(UUID.class))).thenReturn(pspClient);
+        PSPRefundResponse pspResponse = new PSPRefundResponse();
+        pspResponse.setSuccess(true);
+        when(pspClient.refund(any(), any(), any())).thenReturn(pspResponse);
+        RefundRequest request = new RefundRequest(payment.getPaymentId(), new BigDecimal("20.00"), "Goodwill");
+        request.setOverridePolicy(true);
+        
+        RefundService policyService = policyService(null, 1);
+        
+        assertThatThrownBy(() -> policyService.processRefund(request, payment.getMerchantId(), false))
+            .extracting("code").isEqualTo(RefundPolicyException.OVERRIDE_FORBIDDEN);
+        
+        RefundResponse response = policyService.processRefund(request, payment.getMerchantId(), true);
+        
+        assertThat(response.getStatus()).isEqualTo(RefundStatus.COMPLETED);
+        assertThat(response.getPolicyOverride()).isEqualTo(RefundPolicyException.COUNT_EXCEEDED);
+    }
+    
+    @Test
+    void shouldNotLetAnOverrideRefundMoreThanWasCaptured() {
+        Payment payment = createCapturedPayment(new BigDecimal("100.00"));
+        when(paymentRepository.findByPaymentId(payment.getPaymentId())).thenReturn(Optional.of(payment));
+        when(refundRepository.sumRefundedAmountByPaymentIdAndStatuses(any(UUID.class), anyList()))
+            .thenReturn(new BigDecimal("90.00"));
+        RefundRequest request = new RefundRequest(payment.getPaymentId(), new BigDecimal("20.00"), "Goodwill");
+        request.setOverridePolicy(true);
+        
+        assertThatThrownBy(() -> refundService.processRefund(request, payment.getMerchantId(), true))
+            .isInstanceOf(RefundPolicyException.class)
+            .extracting("code").isEqualTo(RefundPolicyException.EXCEEDS_CAPTURE);
+        verify(refundRepository, never()).save(any(Refund.class));
+        verify(pspClient, never()).refund(any(), any(), any());
+    }
+    
+    private RefundService policyService(Integer windowDays, Integer maxRefunds) {
+        return new RefundService(refundRepository, paymentRepository, paymentEventRepository, pspRoutingService,
+            eventPublisher, merchantRepository, windowDays, maxRefunds);
+    }
+    
     private Payment createCapturedPayment(BigDecimal amount) {
         Payment payment = new Payment();
         payment.setId(UUID.randomUUID());
@@ -0,0 +1,128 @@
+package com.paymentgateway.authorization.service;
+
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.Sandbox;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import com.paymentgateway.authorization.repository.SandboxRepository;
+import com.paymentgateway.authorization.security.MerchantAuthenticationService;
+import com.paymentgateway.authorization.service.SandboxService.CreatedSandbox;
+import com.paymentgateway.authorization.service.SandboxService.SandboxMerchant;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.InOrder;
+import org.mockito.Mock;
+import org.mockito.MockitoAnnotations;
+
+import java.util.Map;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.Mockito.*;
+
+class SandboxServiceTest {
+    
+    @Mock
+    private SandboxRepository sandboxRepository;
+    
+    @Mock
+    private MerchantRepository merchantRepository;
+    
+    @Mock
+    private MerchantAuthenticationService authenticationService;
+    
+    private SandboxService sandboxService;
+    
+    @BeforeEach
+    void setUp() {
+        MockitoAnnotations.openMocks(this);
+        sandboxService = new SandboxService(sandboxRepository, merchantRepository, authenticationService, 5);
+        when(sandboxRepository.save(any(Sandbox.class))).thenAnswer(inv -> {
+            Sandbox sandbox = inv.getArgument(0);
+            sandbox.setId(UUID.randomUUID());
+            return sandbox;
+        });
+        when(authenticationService.createApiKey(any(Merchant.class)))
+            .thenAnswer(inv -> "sk_" + ((Merchant) inv.getArgument(0)).getMerchantId());
+    }
+    
+    @Test
+    void shouldCreateSandboxWithIsolatedMerchantsAndKeys() {
+        CreatedSandbox created = sandboxService.create(" Team Payments ", 2, "ADMIN_001");
+        
+        Sandbox sandbox = created.getSandbox();
+        assertThat(sandbox.getSandboxId()).matches("sbx_[a-z0-9]{12}");
+        assertThat(sandbox.getName()).isEqualTo("Team Payments");
+        assertThat(sandbox.getCreatedBy()).isEqualTo("ADMIN_001");
+        assertThat(created.getMerchants()).hasSize(2);
+        for (SandboxMerchant m : created.getMerchants()) {
+            assertThat(m.getMerchant().getMerchantId()).startsWith(sandbox.getSandboxId() + "_M");
+            assertThat(m.getMerchant().getSandboxId()).isEqualTo(sandbox.getId());
+            assertThat(m.getMerchant().getRoles()).containsExactly("MERCHANT");
+            assertThat(m.getApiKey()).isEqualTo("sk_" + m.getMerchant().getMerchantId());
+        }
+        assertThat(created.getMerchants().get(0).getMerchant().getMerchantId())
+            .isNotEqualTo(created.getMerchants().get(1).getMerchant().getMerchantId());
+    }
+    
+    @Test
+    void shouldGiveEachSandboxItsOwnId() {
+        String first = sandboxService.create("a", 1, "ADMIN_001").getSandbox().getSandboxId();
+        String second = sandboxService.create("b", 1, "ADMIN_001").getSandbox().getSandboxId();
+        
+        assertThat(first).isNotEqualTo(second);
+    }
+    
+    @Test
+    void shouldRejectTooManyMerchants() {
+        assertThatThrownBy(() -> sandboxService.create("big", 6, "ADMIN_001"))
+            .isInstanceOf(IllegalArgumentException.class);
+        verify(sandboxRepository, never()).save(any());
+    }
+    
+    @Test
+    void shouldSkipTakenMerchantIdWhenAddingMerchant() {
+        Sandbox sandbox = new Sandbox("sbx_abc", "Team", "ADMIN_001");
+        sandbox.setId(UUID.randomUUID());
+        when(sandboxRepository.countMerchants(sandbox.getId())).thenReturn(1L);
+        // M002 was taken by a merchant that has since been soft-deleted
+        when(merchantRepository.existsByMerchantId("sbx_abc_M002")).thenReturn(true);
+        
+        SandboxMerchant added = sandboxService.addMerchant(sandbox, "Checkout");
+        
+        assertThat(added.getMerchant().getMerchantId()).isEqualTo("sbx_abc_M003");
+        assertThat(added.getMerchant().getMerchantName()).isEqualTo("Checkout");
+    }
+    
+    @Test
+    void shouldNotAddMerchantBeyondLimit() {
+        Sandbox sandbox = new Sandbox("sbx_abc", "Team", "ADMIN_001");
+        sandbox.setId(UUID.randomUUID());
+        when(sandboxRepository.countMerchants(sandbox.getId())).thenReturn(5L);
+        
+        assertThatThrownBy(() -> sandboxService.addMerchant(sandbox, null))
+            .isInstanceOf(IllegalArgumentException.class);
+        verify(authenticationService, never()).createApiKey(any());
+    }
+    
+    @Test
+    void shouldDestroyChildrenBeforeMerchantsAndSandbox() {
+        Sandbox sandbox = new Sandbox("sbx_abc", "Team", "ADMIN_001");
+        sandbox.setId(UUID.randomUUID());
+        UUID id = sandbox.getId();
+        when(sandboxRepository.deletePayments(id)).thenReturn(7);
+        when(sandboxRepository.deleteMerchants(id)).thenReturn(2);
+        
+        Map<String, Integer> deleted = sandboxService.destroy(sandbox);
+        
+        assertThat(deleted).containsEntry("payments", 7).containsEntry("merchants", 2);
+        InOrder order = inOrder(sandboxRepository);
+        order.verify(sandboxRepository).deletePaymentEvents(id);
+        order.verify(sandboxRepository).deleteRefunds(id);
+        order.verify(sandboxRepository).deletePayments(id);
+        order.verify(sandboxRepository).deleteMerchantRoles(id);
+        order.verify(sandboxRepository).deleteMerchants(id);
+        order.verify(sandboxRepository).delete(sandbox);
+    }
+}
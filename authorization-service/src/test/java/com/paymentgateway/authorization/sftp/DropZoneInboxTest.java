@@ -0,0 +1,98 @@
+package com.paymentgateway.authorization.sftp;
+
+import com.paymentgateway.authorization.batch.BatchFileService;
+import com.paymentgateway.authorization.batch.InvalidBatchFileException;
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileSource;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.io.TempDir;
+
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for submitting files dropped on the drop zone.
+ */
+class DropZoneInboxTest {
+    
+    private static final String MERCHANT_ID = "merchant_1";
+    private static final String CSV = "token,amount,currency\n9411111111111111,12.50,USD\n";
+    
+    @TempDir
+    Path root;
+    
+    private BatchFileService batchFileService;
+    private DropZoneInbox inbox;
+    private UUID merchantUuid;
+    private Path home;
+    
+    @BeforeEach
+    void setUp() throws Exception {
+        batchFileService = mock(BatchFileService.class);
+        DropZone dropZone = new DropZone(root);
+        inbox = new DropZoneInbox(dropZone, batchFileService, 1024);
+        merchantUuid = UUID.randomUUID();
+        home = dropZone.open(MERCHANT_ID);
+    }
+    
+    @Test
+    void shouldSubmitDroppedFileAndAcknowledgeIt() throws Exception {
+        Path file = Files.writeString(home.resolve("inbound/sales.csv"), CSV);
+        BatchFile batchFile = new BatchFile("bf_1", merchantUuid, BatchFileFormat.CSV, 1);
+        when(batchFileService.submit(merchantUuid, BatchFileFormat.CSV, CSV, BatchFileSource.SFTP, "sales.csv"))
+            .thenReturn(batchFile);
+        
+        assertThat(inbox.accept(MERCHANT_ID, merchantUuid, file)).contains(batchFile);
+        
+        assertThat(file).doesNotExist();
+        assertThat(home.resolve("archive/bf_1_sales.csv")).hasContent(CSV);
+        assertThat(Files.readString(home.resolve("outbound/sales.csv.ack"))).isEqualTo("fileId=bf_1\nrecords=1\n");
+    }
+    
+    @Test
+    void shouldRejectUnreadableFileWithTheReason() throws Exception {
+        Path file = Files.writeString(home.resolve("inbound/legacy.dat"), "garbage\n");
+        when(batchFileService.submit(any(), eq(BatchFileFormat.FIXED_WIDTH), anyString(), any(), anyString()))
+            .thenThrow(new InvalidBatchFileException("line 1: expected a D record"));
+        
+        assertThat(inbox.accept(MERCHANT_ID, merchantUuid, file)).isEmpty();
+        
+        assertThat(home.resolve("archive/rejected_legacy.dat")).exists();
+        assertThat(Files.readString(home.resolve("outbound/legacy.dat.rejected"))).isEqualTo("line 1: expected a D record\n");
+    }
+    
+    @Test
+    void shouldRejectFilesOverTheSizeLimit() throws Exception {
+        Path file = Files.writeString(home.resolve("inbound/big.csv"), "x".repeat(2048));
+        
+        assertThat(inbox.accept(MERCHANT_ID, merchantUuid, file)).isEmpty();
+        
+        verifyNoInteractions(batchFileService);
+        assertThat(Files.readString(home.resolve("outbound/big.csv.rejected"))).contains("larger than 1024 bytes");
+    }
+    
+    @Test
+    void shouldLeaveUploadsInProgressAndOtherDirectoriesAlone() throws Exception {
+        Path partial = Files.writeString(home.resolve("inbound/sales.csv.part"), CSV);
+        Path hidden = Files.writeString(home.resolve("inbound/.sales.csv"), CSV);
+        Path outbound = Files.writeString(home.resolve("outbound/sales.csv"), CSV);
+        
+        assertThat(inbox.accept(MERCHANT_ID, merchantUuid, partial)).isEmpty();
+        assertThat(inbox.accept(MERCHANT_ID, merchantUuid, hidden)).isEmpty();
+        assertThat(inbox.accept(MERCHANT_ID, merchantUuid, outbound)).isEmpty();
+        
+        verifyNoInteractions(batchFileService);
+        assertThat(partial).exists();
+        assertThat(DropZoneInbox.formatOf("LEGACY.TXT")).isEqualTo(Optional.of(BatchFileFormat.FIXED_WIDTH));
+    }
+}
@@ -0,0 +1,101 @@
+package com.paymentgateway.authorization.sftp;
+
+import com.paymentgateway.authorization.batch.BatchFileService;
+import com.paymentgateway.authorization.domain.BatchFile;
+import com.paymentgateway.authorization.domain.BatchFileFormat;
+import com.paymentgateway.authorization.domain.BatchFileSource;
+import com.paymentgateway.authorization.domain.BatchFileStatus;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.export.ExportDataset;
+import com.paymentgateway.authorization.export.ExportFormat;
+import com.paymentgateway.authorization.export.ExportService;
+import com.paymentgateway.authorization.repository.BatchFileRepository;
+import com.paymentgateway.authorization.repository.MerchantRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.io.TempDir;
+
+import java.io.OutputStream;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.eq;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for putting response files and reports on the drop zone.
+ */
+class DropZoneOutboxTest {
+    
+    @TempDir
+    Path root;
+    
+    private BatchFileRepository batchFileRepository;
+    private BatchFileService batchFileService;
+    private MerchantRepository merchantRepository;
+    private ExportService exportService;
+    private DropZone dropZone;
+    private DropZoneOutbox outbox;
+    private Merchant merchant;
+    
+    @BeforeEach
+    void setUp() {
+        batchFileRepository = mock(BatchFileRepository.class);
+        batchFileService = mock(BatchFileService.class);
+        merchantRepository = mock(MerchantRepository.class);
+        exportService = mock(ExportService.class);
+        dropZone = new DropZone(root);
+        outbox = new DropZoneOutbox(dropZone, batchFileRepository, batchFileService, merchantRepository, exportService);
+        merchant = new Merchant();
+        merchant.setId(UUID.randomUUID());
+        merchant.setMerchantId("merchant_1");
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        when(merchantRepository.findByMerchantId("merchant_1")).thenReturn(Optional.of(merchant));
+    }
+    
+    @Test
+    void shouldDeliverResponseFilesOnce() throws Exception {
+        BatchFile file = new BatchFile("bf_1", merchant.getId(), BatchFileFormat.FIXED_WIDTH, 1);
+        file.setSource(BatchFileSource.SFTP);
+        file.setStatus(BatchFileStatus.COMPLETED);
+        when(batchFileRepository.findBySourceAndStatusAndResponseDeliveredAtIsNull(BatchFileSource.SFTP,
+            BatchFileStatus.COMPLETED)).thenReturn(List.of(file));
+        when(batchFileService.responseFile(file)).thenReturn("T000001000001000000000000\n");
+        
+        assertThat(outbox.deliverResponses()).isEqualTo(1);
+        
+        assertThat(Files.readString(root.resolve("merchant_1/outbound/bf_1_response.txt")))
+            .isEqualTo("T000001000001000000000000\n");
+        assertThat(file.getResponseDeliveredAt()).isNotNull();
+        verify(batchFileRepository).save(file);
+    }
+    
+    @Test
+    void shouldWriteEachDatasetForEveryDropZone() throws Exception {
+        dropZone.open("merchant_1");
+        dropZone.open("merchant_gone");
+        doAnswer(invocation -> {
+            ExportDataset dataset = invocation.getArgument(0);
+            invocation.<OutputStream>getArgument(5).write((dataset.getDatasetName() + "\n").getBytes(StandardCharsets.UTF_8));
+            return 0L;
+        }).when(exportService).export(any(), eq(ExportFormat.CSV), eq(merchant.getId()),
+            eq(Instant.parse("2026-10-15T00:00:00Z")), eq(Instant.parse("2026-10-16T00:00:00Z")), any());
+        
+        outbox.writeReports(LocalDate.of(2026, 10, 15));
+        
+        Path reports = root.resolve("merchant_1/outbound/reports");
+        for (ExportDataset dataset : ExportDataset.values()) {
+            assertThat(Files.readString(reports.resolve(dataset.getDatasetName() + "_2026-10-15.csv")))
+                .isEqualTo(dataset.getDatasetName() + "\n");
+        }
+        assertThat(root.resolve("merchant_gone/outbound/reports")).doesNotExist();
+    }
+}
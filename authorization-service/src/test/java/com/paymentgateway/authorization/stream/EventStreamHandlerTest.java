@@ -0,0 +1,172 @@
+package com.paymentgateway.authorization.stream;
+
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.fasterxml.jackson.datatype.jsr310.JavaTimeModule;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.event.PaymentEventMessage;
+import com.paymentgateway.authorization.event.PaymentEventType;
+import io.micrometer.core.instrument.simple.SimpleMeterRegistry;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.mockito.ArgumentCaptor;
+import org.springframework.web.socket.CloseStatus;
+import org.springframework.web.socket.TextMessage;
+import org.springframework.web.socket.WebSocketSession;
+
+import java.math.BigDecimal;
+import java.net.URI;
+import java.time.Instant;
+import java.util.HashMap;
+import java.util.Map;
+import java.util.Set;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for filtering and redacting the event stream.
+ */
+class EventStreamHandlerTest {
+    
+    private static final UUID MERCHANT_A = UUID.randomUUID();
+    private static final UUID MERCHANT_B = UUID.randomUUID();
+    
+    private EventStreamHandler handler;
+    
+    @BeforeEach
+    void setUp() {
+        ObjectMapper objectMapper = new ObjectMapper();
+        objectMapper.registerModule(new JavaTimeModule());
+        handler = new EventStreamHandler(objectMapper, new SimpleMeterRegistry(), 1000, 64 * 1024);
+    }
+    
+    @Test
+    void shouldSendMerchantOnlyItsOwnEvents() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "MERCHANT"), "");
+        
+        // When
+        handler.broadcast(event(MERCHANT_B, PaymentEventType.PAYMENT_AUTHORIZED));
+        handler.broadcast(event(MERCHANT_A, PaymentEventType.PAYMENT_DECLINED));
+        
+        // Then
+        ArgumentCaptor<TextMessage> sent = ArgumentCaptor.forClass(TextMessage.class);
+        verify(session, times(1)).sendMessage(sent.capture());
+        assertThat(sent.getValue().getPayload()).contains("\"event_type\":\"PAYMENT_DECLINED\"");
+    }
+    
+    @Test
+    void shouldNotLetMerchantWatchAnotherMerchant() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "MERCHANT"), "merchant_id=" + MERCHANT_B);
+        
+        // When
+        handler.broadcast(event(MERCHANT_B, PaymentEventType.PAYMENT_AUTHORIZED));
+        
+        // Then
+        verify(session, never()).sendMessage(any());
+    }
+    
+    @Test
+    void shouldLetAdminWatchAllMerchants() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "ADMIN"), "");
+        
+        // When
+        handler.broadcast(event(MERCHANT_A, PaymentEventType.PAYMENT_AUTHORIZED));
+        handler.broadcast(event(MERCHANT_B, PaymentEventType.PAYMENT_SETTLED));
+        
+        // Then
+        verify(session, times(2)).sendMessage(any());
+    }
+    
+    @Test
+    void shouldFilterByEventType() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "MERCHANT"),
+                "types=payment_declined,PAYMENT_SETTLED");
+        
+        // When
+        handler.broadcast(event(MERCHANT_A, PaymentEventType.PAYMENT_AUTHORIZED));
+        handler.broadcast(event(MERCHANT_A, PaymentEventType.PAYMENT_DECLINED));
+        handler.broadcast(event(MERCHANT_A, PaymentEventType.PAYMENT_SETTLED));
+        
+        // Then
+        verify(session, times(2)).sendMessage(any());
+    }
+    
+    @Test
+    void shouldRejectUnknownEventType() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "MERCHANT"), "types=PAYMENT_EXPLODED");
+        
+        // Then
+        verify(session).close(any(CloseStatus.class));
+        assertThat(handler.subscriberCount()).isZero();
+    }
+    
+    @Test
+    void shouldRedactSensitiveFields() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "MERCHANT"), "");
+        PaymentEventMessage message = event(MERCHANT_A, PaymentEventType.PAYMENT_AUTHORIZED);
+        message.getPayload().setPspTransactionId("psp_secret");
+        message.getPayload().setFraudScore(new BigDecimal("0.42"));
+        message.getPayload().setThreeDsStatus("AUTHENTICATED");
+        
+        // When
+        handler.broadcast(message);
+        
+        // Then
+        ArgumentCaptor<TextMessage> sent = ArgumentCaptor.forClass(TextMessage.class);
+        verify(session).sendMessage(sent.capture());
+        String json = sent.getValue().getPayload();
+        assertThat(json).contains("\"payment_id\":\"pay_1\"", "\"amount\":10.00", "\"currency\":\"USD\"");
+        assertThat(json).doesNotContain("psp_secret", "fraud_score", "three_ds", "trace", "merchant_id");
+    }
+    
+    @Test
+    void shouldStopSendingAfterDisconnect() throws Exception {
+        // Given
+        WebSocketSession session = connect("s1", merchant(MERCHANT_A, "MERCHANT"), "");
+        
+        // When
+        handler.afterConnectionClosed(session, CloseStatus.NORMAL);
+        handler.broadcast(event(MERCHANT_A, PaymentEventType.PAYMENT_AUTHORIZED));
+        
+        // Then
+        verify(session, never()).sendMessage(any());
+        assertThat(handler.subscriberCount()).isZero();
+    }
+    
+    private WebSocketSession connect(String id, Merchant merchant, String query) throws Exception {
+        WebSocketSession session = mock(WebSocketSession.class);
+        Map<String, Object> attributes = new HashMap<>();
+        attributes.put(MerchantHandshakeInterceptor.MERCHANT_ATTRIBUTE, merchant);
+        when(session.getId()).thenReturn(id);
+        when(session.isOpen()).thenReturn(true);
+        when(session.getAttributes()).thenReturn(attributes);
+        when(session.getUri()).thenReturn(URI.create("wss://localhost:8446" + EventStreamConfig.PATH + "?" + query));
+        handler.afterConnectionEstablished(session);
+        return session;
+    }
+    
+    private Merchant merchant(UUID id, String role) {
+        Merchant merchant = new Merchant("merch_" + id, "Test Merchant");
+        merchant.setId(id);
+        merchant.setRoles(Set.of(role));
+        return merchant;
+    }
+    
+    private PaymentEventMessage event(UUID merchantId, PaymentEventType type) {
+        PaymentEventMessage.PaymentEventPayload payload = new PaymentEventMessage.PaymentEventPayload();
+        payload.setPaymentId("pay_1");
+        payload.setMerchantId(merchantId.toString());
+        payload.setAmount(new BigDecimal("10.00"));
+        payload.setCurrency("USD");
+        payload.setStatus("AUTHORIZED");
+        return new PaymentEventMessage("evt_1", type, Instant.now(), "pay_1", "trace_1", payload);
+    }
+}
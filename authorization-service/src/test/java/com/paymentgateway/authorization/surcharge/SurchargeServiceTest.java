@@ -0,0 +1,86 @@
+package com.paymentgateway.authorization.surcharge;
+
+import com.paymentgateway.authorization.domain.CardBrand;
+import com.paymentgateway.authorization.domain.Merchant;
+import com.paymentgateway.authorization.domain.TransactionChannel;
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+
+import static org.assertj.core.api.Assertions.*;
+
+class SurchargeServiceTest {
+    
+    private final SurchargeService surchargeService =
+        new SurchargeService("*:US:3.0, AMEX:US:2.0, *:CA:2.4, *:EEA:PROHIBITED");
+    
+    @Test
+    void shouldApplySurchargeWithinCap() {
+        SurchargeAssessment assessment = surchargeService.assess(
+            merchant("US", "2.5", null), CardBrand.VISA, TransactionChannel.ECOMMERCE, new BigDecimal("100.00"));
+        
+        assertThat(assessment.getOutcome()).isEqualTo(SurchargeAssessment.Outcome.APPLIED);
+        assertThat(assessment.getSurchargeAmount()).isEqualByComparingTo("2.50");
+        assertThat(assessment.getTotalAmount()).isEqualByComparingTo("102.50");
+    }
+    
+    @Test
+    void shouldCapSurchargeAtTheBrandRule() {
+        SurchargeAssessment assessment = surchargeService.assess(
+            merchant("US", "2.5", null), CardBrand.AMEX, TransactionChannel.ECOMMERCE, new BigDecimal("80.00"));
+        
+        assertThat(assessment.getOutcome()).isEqualTo(SurchargeAssessment.Outcome.CAPPED);
+        assertThat(assessment.getSurchargeRate()).isEqualByComparingTo("2.0");
+        assertThat(assessment.getSurchargeAmount()).isEqualByComparingTo("1.60");
+    }
+    
+    @Test
+    void shouldProhibitSurchargeInTheEea() {
+        SurchargeAssessment assessment = surchargeService.assess(
+            merchant("DE", "1.5", null), CardBrand.MASTERCARD, TransactionChannel.ECOMMERCE, new BigDecimal("50.00"));
+        
+        assertThat(assessment.getOutcome()).isEqualTo(SurchargeAssessment.Outcome.PROHIBITED);
+        assertThat(assessment.getTotalAmount()).isEqualByComparingTo("50.00");
+    }
+    
+    @Test
+    void shouldProhibitSurchargeWithoutRule() {
+        assertThat(surchargeService.cap(CardBrand.VISA, "BR")).isNull();
+        assertThat(surchargeService.assess(merchant("BR", "2.0", null), CardBrand.VISA,
+            TransactionChannel.ECOMMERCE, BigDecimal.TEN).getOutcome())
+            .isEqualTo(SurchargeAssessment.Outcome.PROHIBITED);
+    }
+    
+    @Test
+    void shouldChargeConvenienceFeeInsteadOfSurchargeOnMoto() {
+        SurchargeAssessment assessment = surchargeService.assess(
+            merchant("US", "2.5", "1.95"), CardBrand.VISA, TransactionChannel.MOTO, new BigDecimal("100.00"));
+        
+        assertThat(assessment.getOutcome()).isEqualTo(SurchargeAssessment.Outcome.CONVENIENCE_FEE);
+        assertThat(assessment.getSurchargeAmount()).isNull();
+        assertThat(assessment.getTotalAmount()).isEqualByComparingTo("101.95");
+    }
+    
+    @Test
+    void shouldAssessNothingWithoutMerchantSettings() {
+        SurchargeAssessment assessment = surchargeService.assess(
+            merchant("US", null, "1.95"), CardBrand.VISA, TransactionChannel.ECOMMERCE, new BigDecimal("10.00"));
+        
+        assertThat(assessment.getOutcome()).isEqualTo(SurchargeAssessment.Outcome.NONE);
+        assertThat(assessment.getTotalAmount()).isEqualByComparingTo("10.00");
+    }
+    
+    @Test
+    void shouldRejectUnknownBrand() {
+        assertThatThrownBy(() -> new SurchargeService("VISAA:US:3.0"))
+            .isInstanceOf(IllegalArgumentException.class);
+    }
+    
+    private static Merchant merchant(String country, String surchargePercent, String convenienceFee) {
+        Merchant merchant = new Merchant("merchant_1", "Merchant");
+        merchant.setCountryCode(country);
+        merchant.setSurchargePercent(surchargePercent != null ? new BigDecimal(surchargePercent) : null);
+        merchant.setConvenienceFee(convenienceFee != null ? new BigDecimal(convenienceFee) : null);
+        return merchant;
+    }
+}
@@ -0,0 +1,162 @@
+package com.paymentgateway.authorization.tokens;
+
+import com.paymentgateway.api.tokenization.v2.ValidateResponse;
+import io.grpc.CallOptions;
+import io.grpc.Channel;
+import io.grpc.ClientCall;
+import io.grpc.Metadata;
+import io.grpc.MethodDescriptor;
+import io.grpc.Status;
+import io.grpc.StatusRuntimeException;
+import org.junit.jupiter.api.Test;
+
+import java.io.ByteArrayInputStream;
+import java.util.concurrent.atomic.AtomicInteger;
+import java.util.function.Supplier;
+
+import static org.assertj.core.api.Assertions.*;
+
+class TokenValidationClientTest {
+    
+    private static final byte[] VALID = ValidateResponse.newBuilder()
+        .setValid(true)
+        .setExpiresAt(1_900_000_000L)
+        .setPar("V0010013022298169667151476744")
+        .build()
+        .toByteArray();
+    private static final byte[] REVOKED = ValidateResponse.newBuilder()
+        .setValid(false)
+        .setErrorMessage("token revoked")
+        .build()
+        .toByteArray();
+    
+    @Test
+    void shouldValidateOnTheReplicaWhenRoutedThere() {
+        FakeChannel primary = new FakeChannel(() -> REVOKED);
+        FakeChannel replica = new FakeChannel(() -> VALID);
+        TokenValidationClient client = new TokenValidationClient(primary, replica);
+        
+        TokenValidation validation = client.validate("merchant_1", "9123456789012345");
+        assertThat(validation.isValid()).isTrue();
+        assertThat(validation.getServedBy()).isEqualTo(TokenValidation.REPLICA);
+        assertThat(validation.getExpiresAt().getEpochSecond()).isEqualTo(1_900_000_000L);
+        assertThat(validation.getPar()).isEqualTo("V0010013022298169667151476744");
+        assertThat(primary.calls.get()).isZero();
+    }
+    
+    @Test
+    void shouldFallBackToThePrimaryWhileTheReplicaSyncs() {
+        FakeChannel primary = new FakeChannel(() -> REVOKED);
+        FakeChannel replica = new FakeChannel(() -> {
+            throw Status.UNAVAILABLE.withDescription("replica is syncing from the primary").asRuntimeException();
+        });
+        TokenValidationClient client = new TokenValidationClient(primary, replica);
+        
+        TokenValidation validation = client.validate("merchant_1", "9123456789012345");
+        assertThat(validation.isValid()).isFalse();
+        assertThat(validation.getErrorMessage()).isEqualTo("token revoked");
+        assertThat(validation.getExpiresAt()).isNull();
+        assertThat(validation.getServedBy()).isEqualTo(TokenValidation.PRIMARY);
+        assertThat(replica.calls.get()).isEqualTo(1);
+    }
+    
+    @Test
+    void shouldNotRetryOtherReplicaErrorsOnThePrimary() {
+        FakeChannel primary = new FakeChannel(() -> VALID);
+        FakeChannel replica = new FakeChannel(() -> {
+            throw Status.PERMISSION_DENIED.asRuntimeException();
+        });
+        TokenValidationClient client = new TokenValidationClient(primary, replica);
+        
+        assertThatThrownBy(() -> client.validate("merchant_1", "9123456789012345"))
+            .isInstanceOf(StatusRuntimeException.class);
+        assertThat(primary.calls.get()).isZero();
+    }
+    
+    @Test
+    void shouldValidateOnThePrimaryWithoutAReplica() {
+        FakeChannel primary = new FakeChannel(() -> VALID);
+        TokenValidationClient client = new TokenValidationClient(primary, null);
+        
+        assertThat(client.isRoutedToReplica()).isFalse();
+        assertThat(client.validate("merchant_1", "9123456789012345").getServedBy())
+            .isEqualTo(TokenValidation.PRIMARY);
+        assertThat(primary.calls.get()).isEqualTo(1);
+    }
+    
+    @Test
+    void shouldValidateSandboxTokensInTheirSandbox() {
+        FakeChannel primary = new FakeChannel(() -> VALID);
+        TokenValidationClient client = new TokenValidationClient(primary, null);
+        
+        client.validate("sbx_4k2m9x7q1abc", "merchant_1", "9123456789012345");
+        assertThat(primary.headers.get(TokenizationEnvironments.HEADER)).isEqualTo("sbx_4k2m9x7q1abc");
+        
+        // Live calls name no environment
+        client.validate("merchant_1", "9123456789012345");
+        assertThat(primary.headers.get(TokenizationEnvironments.HEADER)).isNull();
+    }
+    
+    @Test
+    void shouldRequireAReplicaAddressToRouteThere() {
+        assertThatThrownBy(() -> new TokenValidationClient("localhost:8445", "", true, ""))
+            .isInstanceOf(IllegalStateException.class);
+    }
+    
+    /**
+     * Answers every unary call with the supplied response, or the status
+     * it throws, and keeps the last call's headers
+     */
+    private static class FakeChannel extends Channel {
+        
+        private final Supplier<byte[]> responses;
+        private final AtomicInteger calls = new AtomicInteger();
+        private volatile Metadata headers;
+        
+        FakeChannel(Supplier<byte[]> responses) {
+            this.responses = responses;
+        }
+        
+        @Override
+        public <Q, R> ClientCall<Q, R> newCall(MethodDescriptor<Q, R> method, CallOptions options) {
+            return new ClientCall<>() {
+                private Listener<R> listener;
+                
+                @Override
+                public void start(Listener<R> listener, Metadata headers) {
+                    this.listener = listener;
+                    FakeChannel.this.headers = headers;
+                }
+                
+                @Override
+                public void request(int numMessages) {
+                }
+                
+                @Override
+                public void cancel(String message, Throwable cause) {
+                }
+                
+                @Override
+                public void sendMessage(Q message) {
+                }
+                
+                @Override
+                public void halfClose() {
+                    calls.incrementAndGet();
+                    try {
+                        byte[] response = responses.get();
+                        listener.onMessage(method.parseResponse(new ByteArrayInputStream(response)));
+                        listener.onClose(Status.OK, new Metadata());
+                    } catch (StatusRuntimeException e) {
+                        listener.onClose(e.getStatus(), new Metadata());
+                    }
+                }
+            };
+        }
+        
+        @Override
+        public String authority() {
+            return "fake";
+        }
+    }
+}
@@ -1,6 +1,12 @@
 package com.paymentgateway.authorization.validation;
 
+import com.paymentgateway.authorization.domain.StoredCredentialType;
+import com.paymentgateway.authorization.domain.TransactionChannel;
+import com.paymentgateway.authorization.domain.TransactionInitiator;
+import com.paymentgateway.authorization.dto.EncryptedCard;
+import com.paymentgateway.authorization.dto.LineItem;
 import com.paymentgateway.authorization.dto.PaymentRequest;
+import com.paymentgateway.authorization.dto.Split;
 import jakarta.validation.ConstraintViolation;
 import jakarta.validation.Validation;
 import jakarta.validation.Validator;
@@ -10,6 +16,7 @@ import org.junit.jupiter.api.Test;
 
 import java.math.BigDecimal;
 import java.time.YearMonth;
+import java.util.List;
 import java.util.Set;
 
 import static org.assertj.core.api.Assertions.assertThat;
@@ -334,6 +341,202 @@ public class ValidationEdgeCasesTest {
         assertThat(violations.size()).isGreaterThanOrEqualTo(6); // At least 6 required fields
     }
     
+    // Transaction initiation indicators
+    
+    @Test
+    void shouldAcceptMerchantInitiatedPaymentWithoutCvv() {
+        PaymentRequest request = createValidRequest();
+        request.setCvv(null);
+        request.setInitiator(TransactionInitiator.MERCHANT);
+        request.setStoredCredential(StoredCredentialType.RECURRING);
+        request.setOriginalNetworkTransactionId("483297487231504");
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).isEmpty();
+    }
+    
+    @Test
+    void shouldRejectMerchantInitiatedPaymentWithoutStoredCredential() {
+        PaymentRequest request = createValidRequest();
+        request.setInitiator(TransactionInitiator.MERCHANT);
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("storedCredential"));
+    }
+    
+    @Test
+    void shouldRejectMerchantInitiatedMoto() {
+        PaymentRequest request = createValidRequest();
+        request.setChannel(TransactionChannel.MOTO);
+        request.setInitiator(TransactionInitiator.MERCHANT);
+        request.setStoredCredential(StoredCredentialType.UNSCHEDULED);
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("channel"));
+    }
+    
+    @Test
+    void shouldRejectMalformedNetworkTransactionId() {
+        PaymentRequest request = createValidRequest();
+        request.setOriginalNetworkTransactionId("abc");
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("originalNetworkTransactionId"));
+    }
+    
+    // Encrypted card tests
+    
+    @Test
+    void shouldAcceptEncryptedCardWithoutCardNumberExpiryOrCvv() {
+        PaymentRequest request = new PaymentRequest(null, null, null, null, new BigDecimal("100.00"), "USD");
+        request.setEncryptedCard(new EncryptedCard("FFFF9876543210E00001", "0123456789abcdef".repeat(2)));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).isEmpty();
+    }
+    
+    @Test
+    void shouldRejectEncryptedCardWithCardNumberOrPinBlock() {
+        PaymentRequest request = createValidRequest();
+        request.setEncryptedCard(new EncryptedCard("FFFF9876543210E00001", "0123456789abcdef"));
+        request.setPinBlock("00112233445566778899aabbccddeeff");
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("cardNumber"));
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("pinBlock"));
+    }
+    
+    @Test
+    void shouldRejectMalformedEncryptedCard() {
+        PaymentRequest request = new PaymentRequest(null, null, null, null, new BigDecimal("100.00"), "USD");
+        request.setEncryptedCard(new EncryptedCard("FFFF98", "0123456789abcde"));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("encryptedCard.ksn"));
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("encryptedCard.encryptedTrack"));
+    }
+    
+    // Purchasing data tests
+    
+    @Test
+    void shouldAcceptLineItemsThatAddUpWithTheTax() {
+        PaymentRequest request = createValidRequest();
+        request.setExpiryYear(YearMonth.now().getYear() + 1);
+        request.setTaxAmount(new BigDecimal("8.00"));
+        request.setCustomerCode("PO-1001");
+        LineItem paper = new LineItem("Copy paper", new BigDecimal("3"), new BigDecimal("24.00"));
+        LineItem toner = new LineItem("Toner", new BigDecimal("1"), new BigDecimal("25.00"));
+        toner.setDiscountAmount(new BigDecimal("5.00"));
+        request.setLineItems(List.of(paper, toner));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).isEmpty();
+        assertThat(request.purchaseDataLevel()).isEqualTo(3);
+    }
+    
+    @Test
+    void shouldRejectLineItemsThatDoNotAddUp() {
+        PaymentRequest request = createValidRequest();
+        request.setTaxAmount(new BigDecimal("8.00"));
+        request.setLineItems(List.of(new LineItem("Copy paper", new BigDecimal("3"), new BigDecimal("24.00"))));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("lineItems")
+            && v.getMessage().contains("80.00"));
+    }
+    
+    @Test
+    void shouldRejectTaxAmountNotBelowTheAmount() {
+        PaymentRequest request = createValidRequest();
+        request.setTaxAmount(new BigDecimal("100.00"));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("taxAmount"));
+    }
+    
+    @Test
+    void shouldRejectLineItemWithoutDescription() {
+        PaymentRequest request = createValidRequest();
+        request.setLineItems(List.of(new LineItem(null, BigDecimal.ONE, new BigDecimal("100.00"))));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("lineItems[0].description"));
+    }
+    
+    @Test
+    void shouldReportThePurchaseDataLevel() {
+        PaymentRequest request = createValidRequest();
+        assertThat(request.purchaseDataLevel()).isEqualTo(1);
+        
+        request.setTaxAmount(new BigDecimal("8.00"));
+        assertThat(request.purchaseDataLevel()).isEqualTo(1);
+        
+        request.setCustomerCode("PO-1001");
+        assertThat(request.purchaseDataLevel()).isEqualTo(2);
+    }
+    
+    // Split tests
+    
+    @Test
+    void shouldAcceptSplitsWithinTheAmount() {
+        PaymentRequest request = createValidRequest();
+        request.setExpiryYear(YearMonth.now().getYear() + 1);
+        request.setSplits(List.of(
+            new Split("seller_1", new BigDecimal("60.00"), new BigDecimal("3.00")),
+            new Split("seller_2", new BigDecimal("40.00"), null)));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).isEmpty();
+    }
+    
+    @Test
+    void shouldRejectSplitsAboveTheAmount() {
+        PaymentRequest request = createValidRequest();
+        request.setSplits(List.of(
+            new Split("seller_1", new BigDecimal("60.00"), null),
+            new Split("seller_2", new BigDecimal("40.01"), null)));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("splits")
+            && v.getMessage().contains("100.01"));
+    }
+    
+    @Test
+    void shouldRejectSplitFeeAboveItsAmount() {
+        PaymentRequest request = createValidRequest();
+        request.setSplits(List.of(new Split("seller_1", new BigDecimal("10.00"), new BigDecimal("10.50"))));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getPropertyPath().toString().equals("splits")
+            && v.getMessage().contains("seller_1"));
+    }
+    
+    @Test
+    void shouldRejectTwoSplitsToOneMerchant() {
+        PaymentRequest request = createValidRequest();
+        request.setSplits(List.of(
+            new Split("seller_1", new BigDecimal("10.00"), null),
+            new Split("seller_1", new BigDecimal("20.00"), null)));
+        
+        Set<ConstraintViolation<PaymentRequest>> violations = validator.validate(request);
+        
+        assertThat(violations).anyMatch(v -> v.getMessage().contains("split to twice"));
+    }
+    
     // Helper method
     
     private PaymentRequest createValidRequest() {
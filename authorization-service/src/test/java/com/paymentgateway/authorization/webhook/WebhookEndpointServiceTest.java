@@ -0,0 +1,167 @@
+package com.paymentgateway.authorization.webhook;
+
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
+import com.paymentgateway.authorization.repository.WebhookDeliveryRepository;
+import com.paymentgateway.authorization.repository.WebhookEndpointRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.extension.ExtendWith;
+import org.mockito.ArgumentCaptor;
+import org.mockito.Mock;
+import org.mockito.junit.jupiter.MockitoExtension;
+import org.springframework.http.HttpEntity;
+import org.springframework.http.HttpMethod;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.client.RestTemplate;
+
+import java.time.Duration;
+import java.time.Instant;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.ArgumentMatchers.*;
+import static org.mockito.Mockito.*;
+
+/**
+ * Unit tests for webhook endpoint verification and secret rotation.
+ */
+@ExtendWith(MockitoExtension.class)
+class WebhookEndpointServiceTest {
+    
+    @Mock
+    private WebhookEndpointRepository endpointRepository;
+    
+    @Mock
+    private WebhookDeliveryRepository deliveryRepository;
+    
+    @Mock
+    private RestTemplate restTemplate;
+    
+    private final ObjectMapper objectMapper = new ObjectMapper();
+    
+    private WebhookEndpointService service;
+    private WebhookEndpoint endpoint;
+    
+    @BeforeEach
+    void setUp() {
+        service = new WebhookEndpointService(endpointRepository, deliveryRepository, new WebhookService(),
+                restTemplate, objectMapper, Duration.ofHours(24), Duration.ofDays(7));
+        endpoint = new WebhookEndpoint(UUID.randomUUID(), "https://merchant.example.com/hooks", "whsec_current");
+        endpoint.setId(UUID.randomUUID());
+    }
+    
+    @Test
+    void shouldRegisterEndpointPendingVerification() {
+        // When
+        WebhookEndpoint registered = service.register(endpoint.getMerchantId(), " https://merchant.example.com/hooks ");
+        
+        // Then
+        assertThat(registered.getStatus()).isEqualTo(WebhookEndpoint.PENDING_VERIFICATION);
+        assertThat(registered.getUrl()).isEqualTo("https://merchant.example.com/hooks");
+        assertThat(registered.getSigningSecret()).startsWith("whsec_");
+        verify(endpointRepository).save(registered);
+    }
+    
+    @Test
+    void shouldRejectNonHttpUrl() {
+        assertThatThrownBy(() -> service.register(endpoint.getMerchantId(), "ftp://merchant.example.com/hooks"))
+                .isInstanceOf(IllegalArgumentException.class);
+        assertThatThrownBy(() -> service.register(endpoint.getMerchantId(), "/hooks"))
+                .isInstanceOf(IllegalArgumentException.class);
+        verify(endpointRepository, never()).save(any());
+    }
+    
+    @Test
+    void shouldActivateEndpointThatEchoesChallenge() throws Exception {
+        // Given - The endpoint answers with the challenge it was sent
+        when(restTemplate.exchange(eq(endpoint.getUrl()), eq(HttpMethod.POST), any(HttpEntity.class), eq(String.class)))
+                .thenAnswer(invocation -> {
+                    HttpEntity<?> request = invocation.getArgument(2);
+                    String challenge = objectMapper.readTree((String) request.getBody()).get("challenge").asText();
+                    return new ResponseEntity<>("{\"challenge\":\"" + challenge + "\"}", HttpStatus.OK);
+                });
+        when(endpointRepository.save(endpoint)).thenReturn(endpoint);
+        
+        // When
+        WebhookEndpoint verified = service.verify(endpoint);
+        
+        // Then
+        assertThat(verified.getStatus()).isEqualTo(WebhookEndpoint.ACTIVE);
+        assertThat(verified.getVerifiedAt()).isNotNull();
+    }
+    
+    @Test
+    void shouldSignChallengeWithEndpointSecret() {
+        // Given
+        when(restTemplate.exchange(anyString(), any(HttpMethod.class), any(HttpEntity.class), eq(String.class)))
+                .thenReturn(new ResponseEntity<>("OK", HttpStatus.OK));
+        
+        // When
+        assertThatThrownBy(() -> service.verify(endpoint)).isInstanceOf(WebhookVerificationException.class);
+        
+        // Then
+        ArgumentCaptor<HttpEntity> request = ArgumentCaptor.forClass(HttpEntity.class);
+        verify(restTemplate).exchange(anyString(), any(HttpMethod.class), request.capture(), eq(String.class));
+        String header = request.getValue().getHeaders().getFirst(WebhookService.SIGNATURE_HEADER);
+        assertThat(new WebhookService().verifySignatureHeader((String) request.getValue().getBody(), header,
+                "whsec_current", Duration.ofMinutes(5), Instant.now())).isTrue();
+    }
+    
+    @Test
+    void shouldKeepEndpointPendingWhenChallengeIsNotEchoed() {
+        // Given - An endpoint that accepts anything without reading it
+        when(restTemplate.exchange(anyString(), any(HttpMethod.class), any(HttpEntity.class), eq(String.class)))
+                .thenReturn(new ResponseEntity<>("{\"received\":true}", HttpStatus.OK));
+        
+        // When / Then
+        assertThatThrownBy(() -> service.verify(endpoint))
+                .isInstanceOf(WebhookVerificationException.class)
+                .hasMessageContaining("did not echo");
+        assertThat(endpoint.getStatus()).isEqualTo(WebhookEndpoint.PENDING_VERIFICATION);
+        verify(endpointRepository, never()).save(any());
+    }
+    
+    @Test
+    void shouldKeepPreviousSecretDuringOverlap() {
+        // When
+        String secret = service.rotateSecret(endpoint, Duration.ofHours(2));
+        
+        // Then
+        assertThat(secret).startsWith("whsec_").isNotEqualTo("whsec_current");
+        assertThat(endpoint.getSigningSecret()).isEqualTo(secret);
+        assertThat(endpoint.signingSecrets(Instant.now())).containsExactly(secret, "whsec_current");
+        assertThat(endpoint.signingSecrets(Instant.now().plus(Duration.ofHours(3)))).containsExactly(secret);
+    }
+    
+    @Test
+    void shouldDropPreviousSecretWithoutOverlap() {
+        // Given - A rotation still in its overlap
+        service.rotateSecret(endpoint, Duration.ofHours(2));
+        
+        // When - The secret leaked, so the merchant rotates with no overlap
+        String secret = service.rotateSecret(endpoint, Duration.ZERO);
+        
+        // Then
+        assertThat(endpoint.signingSecrets(Instant.now())).containsExactly(secret);
+    }
+    
+    @Test
+    void shouldRejectOverlapBeyondMaximum() {
+        assertThatThrownBy(() -> service.rotateSecret(endpoint, Duration.ofDays(8)))
+                .isInstanceOf(IllegalArgumentException.class);
+        assertThat(endpoint.getSigningSecret()).isEqualTo("whsec_current");
+    }
+    
+    @Test
+    void shouldCancelPendingDeliveriesWhenDisabled() {
+        // When
+        service.disable(endpoint);
+        
+        // Then
+        assertThat(endpoint.getStatus()).isEqualTo(WebhookEndpoint.DISABLED);
+        verify(deliveryRepository).cancelPendingForEndpoint(endpoint.getId());
+    }
+}
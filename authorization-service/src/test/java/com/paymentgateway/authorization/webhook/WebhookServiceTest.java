@@ -4,10 +4,12 @@ import com.fasterxml.jackson.databind.ObjectMapper;
 import com.paymentgateway.authorization.domain.Merchant;
 import com.paymentgateway.authorization.domain.Payment;
 import com.paymentgateway.authorization.domain.WebhookDelivery;
+import com.paymentgateway.authorization.domain.WebhookEndpoint;
 import com.paymentgateway.authorization.event.PaymentEventMessage;
 import com.paymentgateway.authorization.event.PaymentEventType;
 import com.paymentgateway.authorization.repository.MerchantRepository;
 import com.paymentgateway.authorization.repository.WebhookDeliveryRepository;
+import com.paymentgateway.authorization.repository.WebhookEndpointRepository;
 import org.junit.jupiter.api.BeforeEach;
 import org.junit.jupiter.api.Test;
 import org.junit.jupiter.api.extension.ExtendWith;
@@ -22,11 +24,14 @@ import org.springframework.http.ResponseEntity;
 import org.springframework.web.client.RestClientException;
 import org.springframework.web.client.RestTemplate;
 
+import java.time.Duration;
 import java.time.Instant;
+import java.util.List;
 import java.util.Optional;
 import java.util.UUID;
 
 import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
 import static org.mockito.ArgumentMatchers.*;
 import static org.mockito.Mockito.*;
 
@@ -39,6 +44,9 @@ class WebhookServiceTest {
     @Mock
     private MerchantRepository merchantRepository;
     
+    @Mock
+    private WebhookEndpointRepository webhookEndpointRepository;
+    
     @Mock
     private RestTemplate restTemplate;
     
@@ -319,5 +327,216 @@ class WebhookServiceTest {
         assertThat(delivery.getStatus()).isEqualTo("FAILED");
         assertThat(delivery.getAttemptCount()).isEqualTo(10);
         assertThat(delivery.hasReachedMaxAttempts()).isTrue();
+        assertThat(delivery.getDeadLetteredAt()).isNotNull();
+        assertThat(delivery.getNextRetryAt()).isNull();
+    }
+    
+    @Test
+    void testRedeliveryResetsDeadLettersToCurrentUrl() {
+        // Given - The merchant moved its webhook during the outage
+        WebhookDelivery delivery = deadLetter("https://old.example.com/webhook");
+        merchant.setWebhookUrl("https://new.example.com/webhook");
+        
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        when(webhookDeliveryRepository.findByMerchantIdAndStatusOrderByCreatedAtAsc(eq(merchant.getId()), eq("FAILED"), any()))
+                .thenReturn(List.of(delivery));
+        
+        // When
+        RedeliveryResult result = webhookService.redeliverDeadLetters(merchant.getId(), null, null);
+        
+        // Then
+        assertThat(result.getScheduled()).isEqualTo(1);
+        assertThat(delivery.getStatus()).isEqualTo("PENDING");
+        assertThat(delivery.getAttemptCount()).isZero();
+        assertThat(delivery.getRedeliveryCount()).isEqualTo(1);
+        assertThat(delivery.getDeadLetteredAt()).isNull();
+        assertThat(delivery.getWebhookUrl()).isEqualTo("https://new.example.com/webhook");
+        verify(webhookDeliveryRepository).saveAll(List.of(delivery));
+    }
+    
+    @Test
+    void testRedeliveryIsPacedBehindEarlierRedeliveries() {
+        // Given - 5 per second by default; an earlier redelivery runs until 10s from now
+        Instant queuedUntil = Instant.now().plusSeconds(10);
+        List<WebhookDelivery> deliveries = List.of(deadLetter(null), deadLetter(null), deadLetter(null));
+        
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        when(webhookDeliveryRepository.findByMerchantIdAndStatusAndEventTypeOrderByCreatedAtAsc(
+                eq(merchant.getId()), eq("FAILED"), eq("PAYMENT_AUTHORIZED"), any()))
+                .thenReturn(deliveries);
+        when(webhookDeliveryRepository.findLastScheduledRedelivery(merchant.getId())).thenReturn(queuedUntil);
+        
+        // When
+        RedeliveryResult result = webhookService.redeliverDeadLetters(merchant.getId(), List.of(), "PAYMENT_AUTHORIZED");
+        
+        // Then
+        assertThat(result.getScheduled()).isEqualTo(3);
+        assertThat(deliveries.get(0).getNextRetryAt()).isEqualTo(queuedUntil.plusMillis(200));
+        assertThat(deliveries.get(1).getNextRetryAt()).isEqualTo(queuedUntil.plusMillis(400));
+        assertThat(deliveries.get(2).getNextRetryAt()).isEqualTo(queuedUntil.plusMillis(600));
+        assertThat(result.getFirstAttemptAt()).isEqualTo(queuedUntil.plusMillis(200));
+        assertThat(result.getLastAttemptAt()).isEqualTo(queuedUntil.plusMillis(600));
+    }
+    
+    @Test
+    void testRedeliveryOnlyTakesOwnDeadLetters() {
+        // Given
+        WebhookDelivery own = deadLetter(null);
+        WebhookDelivery delivered = deadLetter(null);
+        delivered.setStatus("DELIVERED");
+        WebhookDelivery foreign = deadLetter(null);
+        foreign.setMerchantId(UUID.randomUUID());
+        List<UUID> ids = List.of(own.getId(), delivered.getId(), foreign.getId());
+        
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        when(webhookDeliveryRepository.findAllById(ids)).thenReturn(List.of(own, delivered, foreign));
+        
+        // When
+        RedeliveryResult result = webhookService.redeliverDeadLetters(merchant.getId(), ids, null);
+        
+        // Then
+        assertThat(result.getScheduled()).isEqualTo(1);
+        assertThat(own.getStatus()).isEqualTo("PENDING");
+        assertThat(delivered.getStatus()).isEqualTo("DELIVERED");
+        assertThat(foreign.getStatus()).isEqualTo("FAILED");
+    }
+    
+    @Test
+    void testRedeliveryRequiresConfiguredWebhook() {
+        // Given
+        merchant.setWebhookUrl(null);
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        
+        // When / Then
+        assertThatThrownBy(() -> webhookService.redeliverDeadLetters(merchant.getId(), null, null))
+                .isInstanceOf(IllegalStateException.class);
+        verify(webhookDeliveryRepository, never()).saveAll(any());
+    }
+    
+    @Test
+    void testRedeliveryLeavesDeadLettersOfDisabledEndpoints() {
+        // Given
+        WebhookEndpoint endpoint = activeEndpoint();
+        WebhookDelivery current = deadLetter(null);
+        current.setEndpointId(endpoint.getId());
+        WebhookDelivery disabled = deadLetter(null);
+        disabled.setEndpointId(UUID.randomUUID());
+        merchant.setWebhookUrl(null);
+        
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        when(webhookEndpointRepository.findByMerchantIdAndStatus(merchant.getId(), WebhookEndpoint.ACTIVE))
+                .thenReturn(List.of(endpoint));
+        when(webhookDeliveryRepository.findByMerchantIdAndStatusOrderByCreatedAtAsc(eq(merchant.getId()), eq("FAILED"), any()))
+                .thenReturn(List.of(current, disabled));
+        
+        // When
+        RedeliveryResult result = webhookService.redeliverDeadLetters(merchant.getId(), null, null);
+        
+        // Then
+        assertThat(result.getScheduled()).isEqualTo(1);
+        assertThat(current.getStatus()).isEqualTo("PENDING");
+        assertThat(current.getWebhookUrl()).isEqualTo(endpoint.getUrl());
+        assertThat(disabled.getStatus()).isEqualTo("FAILED");
+    }
+    
+    @Test
+    void testScheduleDeliveryFansOutToActiveEndpoints() throws Exception {
+        // Given
+        WebhookEndpoint first = activeEndpoint();
+        WebhookEndpoint second = activeEndpoint();
+        when(merchantRepository.findById(merchant.getId())).thenReturn(Optional.of(merchant));
+        when(webhookEndpointRepository.findByMerchantIdAndStatus(merchant.getId(), WebhookEndpoint.ACTIVE))
+                .thenReturn(List.of(first, second));
+        when(objectMapper.writeValueAsString(any())).thenReturn("{\"event\":\"test\"}");
+        
+        // When
+        List<WebhookDelivery> deliveries = webhookService.scheduleDelivery(merchant.getId(), payment.getId(), eventMessage);
+        
+        // Then - The merchant-level webhook URL is not used once endpoints exist
+        assertThat(deliveries).extracting(WebhookDelivery::getEndpointId)
+                .containsExactly(first.getId(), second.getId());
+        assertThat(deliveries).extracting(WebhookDelivery::getWebhookUrl)
+                .doesNotContain(merchant.getWebhookUrl());
+        verify(webhookDeliveryRepository, times(2)).save(any(WebhookDelivery.class));
+    }
+    
+    @Test
+    void testAttemptIsSignedWithTimestampAndBothSecretsDuringOverlap() throws Exception {
+        // Given - The endpoint's secret was rotated an hour ago with a day of overlap
+        WebhookEndpoint endpoint = activeEndpoint();
+        endpoint.setPreviousSigningSecret("whsec_old");
+        endpoint.setPreviousSecretExpiresAt(Instant.now().plus(Duration.ofHours(23)));
+        WebhookDelivery delivery = deadLetter(endpoint.getUrl());
+        delivery.setEndpointId(endpoint.getId());
+        delivery.setStatus("PENDING");
+        delivery.setAttemptCount(0);
+        
+        when(webhookEndpointRepository.findById(endpoint.getId())).thenReturn(Optional.of(endpoint));
+        when(webhookDeliveryRepository.save(any(WebhookDelivery.class)))
+                .thenAnswer(invocation -> invocation.getArgument(0));
+        when(restTemplate.exchange(anyString(), any(HttpMethod.class), any(HttpEntity.class), eq(String.class)))
+                .thenReturn(new ResponseEntity<>("OK", HttpStatus.OK));
+        
+        // When
+        java.lang.reflect.Method method = WebhookService.class.getDeclaredMethod(
+                "attemptDelivery", WebhookDelivery.class);
+        method.setAccessible(true);
+        method.invoke(webhookService, delivery);
+        
+        // Then
+        ArgumentCaptor<HttpEntity> request = ArgumentCaptor.forClass(HttpEntity.class);
+        verify(restTemplate).exchange(eq(endpoint.getUrl()), eq(HttpMethod.POST), request.capture(), eq(String.class));
+        String header = request.getValue().getHeaders().getFirst(WebhookService.SIGNATURE_HEADER);
+        assertThat(header).startsWith("t=");
+        assertThat(header.split(",v1=")).hasSize(3);
+        Duration tolerance = Duration.ofMinutes(5);
+        assertThat(webhookService.verifySignatureHeader(delivery.getPayload(), header,
+                endpoint.getSigningSecret(), tolerance, Instant.now())).isTrue();
+        assertThat(webhookService.verifySignatureHeader(delivery.getPayload(), header,
+                "whsec_old", tolerance, Instant.now())).isTrue();
+    }
+    
+    @Test
+    void testSignatureHeaderRejectsReplayAfterTolerance() {
+        // Given
+        String payload = "{\"test\":\"data\"}";
+        Instant signedAt = Instant.now().minus(Duration.ofMinutes(10));
+        String header = webhookService.signatureHeader(payload, List.of("my-secret-key"), signedAt);
+        Duration tolerance = Duration.ofMinutes(5);
+        
+        // Then
+        assertThat(webhookService.verifySignatureHeader(payload, header, "my-secret-key", tolerance, signedAt))
+                .isTrue();
+        assertThat(webhookService.verifySignatureHeader(payload, header, "my-secret-key", tolerance, Instant.now()))
+                .isFalse();
+        // Moving the timestamp forward breaks the signature
+        String forged = header.replaceFirst("t=\\d+", "t=" + Instant.now().getEpochSecond());
+        assertThat(webhookService.verifySignatureHeader(payload, forged, "my-secret-key", tolerance, Instant.now()))
+                .isFalse();
+    }
+    
+    private WebhookEndpoint activeEndpoint() {
+        WebhookEndpoint endpoint = new WebhookEndpoint(merchant.getId(),
+                "https://merchant.example.com/hooks/" + UUID.randomUUID(), "whsec_" + UUID.randomUUID());
+        endpoint.setId(UUID.randomUUID());
+        endpoint.setStatus(WebhookEndpoint.ACTIVE);
+        return endpoint;
+    }
+    
+    private WebhookDelivery deadLetter(String webhookUrl) {
+        WebhookDelivery delivery = new WebhookDelivery(
+                merchant.getId(),
+                payment.getId(),
+                "PAYMENT_AUTHORIZED",
+                webhookUrl != null ? webhookUrl : merchant.getWebhookUrl(),
+                "{\"test\":\"data\"}",
+                "signature123"
+        );
+        delivery.setId(UUID.randomUUID());
+        delivery.setAttemptCount(10);
+        delivery.setStatus("FAILED");
+        delivery.setDeadLetteredAt(Instant.now());
+        delivery.setErrorMessage("Connection timeout");
+        return delivery;
     }
 }
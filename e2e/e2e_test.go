@@ -0,0 +1,289 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	hsmv1 "github.com/paymentgateway/api/hsm/v1"
+	"github.com/paymentgateway/api/pkg/client"
+	tokenizationv2 "github.com/paymentgateway/api/tokenization/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testPAN    = "4111111111111111"
+	otherPAN   = "5555555555554444"
+	tokenKeyID = "tokenization-key-1"
+)
+
+// harness is shared by every scenario; starting the services dominates the
+// run time
+var harness *Harness
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		fmt.Println("skipping end-to-end tests in short mode")
+		os.Exit(0)
+	}
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	harness, err = Start(ctx, cfg)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starting services: %v\n", err)
+		os.Exit(2)
+	}
+	code := m.Run()
+	harness.Close()
+	os.Exit(code)
+}
+
+// scenario returns a context for one scenario and prints the services' logs
+// if it fails
+func scenario(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(func() {
+		cancel()
+		if t.Failed() {
+			t.Log(harness.Logs())
+		}
+	})
+	return ctx
+}
+
+func tokenize(ctx context.Context, t *testing.T, merchantID string) *tokenizationv2.TokenizeResponse {
+	t.Helper()
+	return tokenizePAN(ctx, t, merchantID, testPAN)
+}
+
+func tokenizePAN(ctx context.Context, t *testing.T, merchantID, pan string) *tokenizationv2.TokenizeResponse {
+	t.Helper()
+	resp, err := harness.Tokenization().TokenizeCard(ctx, &tokenizationv2.TokenizeRequest{
+		Pan:         pan,
+		ExpiryMonth: 12,
+		ExpiryYear:  int32(time.Now().Year() + 3),
+		Cvv:         "123",
+		MerchantId:  merchantID,
+		Metadata:    map[string]string{"order": "e2e"},
+	})
+	if err != nil {
+		t.Fatalf("TokenizeCard() error = %v", err)
+	}
+	return resp
+}
+
+func wantCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("error = %v, want code %s", err, want)
+	}
+}
+
+func TestTokenizeAndDetokenizeThroughHSM(t *testing.T) {
+	ctx := scenario(t)
+	encrypts := func() float64 {
+		n, err := harness.Counter(ctx, harness.HSMMetricsURL, "grpc_server_handled_total",
+			"method", "/hsm.v1.HSMService/Encrypt", "code", "OK")
+		if err != nil {
+			t.Fatalf("reading HSM metrics: %v", err)
+		}
+		return n
+	}
+	before := encrypts()
+
+	tok := tokenize(ctx, t, "merchant-e2e-1")
+	if tok.LastFour != "1111" || tok.CardBrand != "VISA" {
+		t.Errorf("TokenizeCard() = last four %q, brand %q, want 1111, VISA", tok.LastFour, tok.CardBrand)
+	}
+	if after := encrypts(); after <= before {
+		t.Errorf("HSM encryptions = %v after tokenizing, want more than %v", after, before)
+	}
+
+	// The service created its key in the HSM at startup
+	key, err := harness.HSM().GetKeyInfo(ctx, &hsmv1.GetKeyInfoRequest{KeyId: tokenKeyID})
+	if err != nil {
+		t.Fatalf("GetKeyInfo() error = %v", err)
+	}
+	if key.CurrentVersion < 1 {
+		t.Errorf("key %s version = %d, want >= 1", tokenKeyID, key.CurrentVersion)
+	}
+
+	card, err := harness.Tokenization().DetokenizeCard(ctx, &tokenizationv2.DetokenizeRequest{
+		Token: tok.Token, MerchantId: "merchant-e2e-1",
+	})
+	if err != nil {
+		t.Fatalf("DetokenizeCard() error = %v", err)
+	}
+	if card.Pan != testPAN || card.ExpiryMonth != 12 || card.Metadata["order"] != "e2e" {
+		t.Errorf("DetokenizeCard() = expiry %d/%d, metadata %v, want the tokenized card",
+			card.ExpiryMonth, card.ExpiryYear, card.Metadata)
+	}
+
+	// Tokens are scoped to the merchant they were issued to
+	_, err = harness.Tokenization().DetokenizeCard(ctx, &tokenizationv2.DetokenizeRequest{
+		Token: tok.Token, MerchantId: "merchant-e2e-2",
+	})
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestRevokedTokenIsRejectedAndAudited(t *testing.T) {
+	ctx := scenario(t)
+	const merchant = "merchant-e2e-revoke"
+	tok := tokenize(ctx, t, merchant)
+
+	if _, err := harness.Tokenization().RevokeToken(ctx, &tokenizationv2.RevokeTokenRequest{
+		Token: tok.Token, MerchantId: merchant,
+	}); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	valid, err := harness.Tokenization().ValidateToken(ctx, &tokenizationv2.ValidateRequest{
+		Token: tok.Token, MerchantId: merchant,
+	})
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if valid.Valid {
+		t.Error("ValidateToken() = valid after revocation")
+	}
+	_, err = harness.Tokenization().DetokenizeCard(ctx, &tokenizationv2.DetokenizeRequest{
+		Token: tok.Token, MerchantId: merchant,
+	})
+	wantCode(t, err, codes.NotFound)
+
+	records, err := harness.Tokenization().ListAuditRecords(ctx, &tokenizationv2.ListAuditRecordsRequest{
+		Token: tok.Token,
+	})
+	if err != nil {
+		t.Fatalf("ListAuditRecords() error = %v", err)
+	}
+	// Newest first
+	var got []string
+	for _, r := range records.Records {
+		got = append(got, r.Operation)
+	}
+	want := []string{"detokenize", "validate", "revoke", "tokenize"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("audit trail = %v, want %v", got, want)
+	}
+	if records.Records[0].Outcome != "failure" || records.Records[2].Outcome != "success" {
+		t.Errorf("audit outcomes = detokenize %s, revoke %s, want failure, success",
+			records.Records[0].Outcome, records.Records[2].Outcome)
+	}
+}
+
+func TestVaultChangesAreStreamed(t *testing.T) {
+	ctx := scenario(t)
+	tokenize(ctx, t, "merchant-e2e-feed")
+
+	stream, err := harness.Tokenization().StreamChanges(ctx, &tokenizationv2.StreamChangesRequest{})
+	if err != nil {
+		t.Fatalf("StreamChanges() error = %v", err)
+	}
+	snapshot := 0
+	for {
+		change, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("receiving snapshot: %v", err)
+		}
+		if change.SnapshotComplete {
+			break
+		}
+		snapshot++
+	}
+	if snapshot == 0 {
+		t.Fatal("snapshot is empty after tokenizing")
+	}
+
+	// A later change follows the snapshot. The card must be new to the
+	// merchant: tokenizing the same one again returns the existing token
+	// without changing the vault.
+	tokenizePAN(ctx, t, "merchant-e2e-feed", otherPAN)
+	change, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("receiving change: %v", err)
+	}
+	if len(change.Record) == 0 {
+		t.Error("change record is empty")
+	}
+}
+
+func TestPaymentLifecycle(t *testing.T) {
+	gw := harness.Gateway()
+	if gw == nil {
+		t.Skip("no gateway configured; set E2E_GATEWAY_JAR or E2E_GATEWAY_URL")
+	}
+	ctx := scenario(t)
+	req := &client.PaymentRequest{
+		CardNumber: testPAN, ExpiryMonth: 12, ExpiryYear: time.Now().Year() + 3, CVV: "123",
+		Amount: 60.00, Currency: "USD", Description: "e2e payment",
+	}
+	key := fmt.Sprintf("e2e-%d", time.Now().UnixNano())
+
+	payment, err := gw.Authorize(ctx, req, key)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if payment.Status != client.StatusAuthorized {
+		t.Fatalf("Authorize() status = %s (%s), want %s", payment.Status, payment.ErrorCode, client.StatusAuthorized)
+	}
+
+	// A retry with the same idempotency key returns the same payment
+	retry, err := gw.Authorize(ctx, req, key)
+	if err != nil {
+		t.Fatalf("retried Authorize() error = %v", err)
+	}
+	if retry.PaymentID != payment.PaymentID {
+		t.Errorf("retried Authorize() = payment %s, want %s", retry.PaymentID, payment.PaymentID)
+	}
+
+	// The issuer holds 60 of the card's 100, so another 60 is declined
+	second, err := gw.Authorize(ctx, req, key+"-2")
+	if err != nil {
+		t.Fatalf("second Authorize() error = %v", err)
+	}
+	if second.Status != client.StatusDeclined {
+		t.Errorf("second Authorize() status = %s, want %s", second.Status, client.StatusDeclined)
+	}
+
+	captured, err := gw.Capture(ctx, payment.PaymentID)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if captured.Status != client.StatusCaptured {
+		t.Errorf("Capture() status = %s, want %s", captured.Status, client.StatusCaptured)
+	}
+
+	refund, err := gw.Refund(ctx, &client.RefundRequest{PaymentID: payment.PaymentID, Amount: 60.00, Reason: "e2e"})
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	if refund.Status != "COMPLETED" {
+		t.Errorf("Refund() status = %s (%s), want COMPLETED", refund.Status, refund.ErrorCode)
+	}
+	final, err := gw.GetPayment(ctx, payment.PaymentID)
+	if err != nil {
+		t.Fatalf("GetPayment() error = %v", err)
+	}
+	if final.Status != client.StatusRefunded {
+		t.Errorf("payment status = %s, want %s", final.Status, client.StatusRefunded)
+	}
+
+	// Capturing again is refused
+	_, err = gw.Capture(ctx, payment.PaymentID)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Errorf("second Capture() error = %v, want an API error", err)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/paymentgateway/e2e
+
+go 1.21
+
+require (
+    github.com/paymentgateway/api v0.0.0
+    google.golang.org/grpc v1.59.0
+)
+
+replace github.com/paymentgateway/api => ../api
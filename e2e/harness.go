@@ -0,0 +1,340 @@
+// Package e2e runs the gateway's services as separate processes on
+// ephemeral ports and drives payment scenarios through their public APIs.
+// Start builds and launches the HSM simulator and the tokenization service,
+// and the gateway API when one is configured; the tests in this package use
+// the resulting Harness.
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	hsmv1 "github.com/paymentgateway/api/hsm/v1"
+	"github.com/paymentgateway/api/pkg/client"
+	tokenizationv2 "github.com/paymentgateway/api/tokenization/v2"
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultIssuerAccounts gives the simulated issuer behind the gateway's
+	// PSPs one test card with a known open-to-buy, so authorizations are
+	// approved or declined deterministically
+	DefaultIssuerAccounts = "4111111111111111:100.00"
+
+	goStartTimeout      = 30 * time.Second
+	gatewayStartTimeout = 3 * time.Minute
+)
+
+// Config selects what the harness starts
+type Config struct {
+	// RepoRoot is the repository checkout the Go services are built from
+	RepoRoot string
+	// GatewayJar is the authorization service's executable jar. When set it
+	// is started on an ephemeral port; its database, Redis and Kafka come
+	// from the environment as in any other deployment.
+	GatewayJar string
+	// GatewayURL points at a gateway that is already running, instead of
+	// GatewayJar
+	GatewayURL string
+	// GatewayAPIKey authenticates payment requests
+	GatewayAPIKey string
+	// IssuerAccounts configures the simulated issuer of a started gateway,
+	// as PAN:balance pairs
+	IssuerAccounts string
+}
+
+// ConfigFromEnv reads E2E_REPO_ROOT (default: the parent of the working
+// directory), E2E_GATEWAY_JAR, E2E_GATEWAY_URL, E2E_GATEWAY_API_KEY and
+// E2E_ISSUER_ACCOUNTS
+func ConfigFromEnv() (Config, error) {
+	root := os.Getenv("E2E_REPO_ROOT")
+	if root == "" {
+		root = ".."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := Config{
+		RepoRoot:       root,
+		GatewayJar:     os.Getenv("E2E_GATEWAY_JAR"),
+		GatewayURL:     os.Getenv("E2E_GATEWAY_URL"),
+		GatewayAPIKey:  os.Getenv("E2E_GATEWAY_API_KEY"),
+		IssuerAccounts: os.Getenv("E2E_ISSUER_ACCOUNTS"),
+	}
+	if cfg.GatewayJar != "" && cfg.GatewayURL != "" {
+		return Config{}, fmt.Errorf("E2E_GATEWAY_JAR and E2E_GATEWAY_URL are mutually exclusive")
+	}
+	if cfg.IssuerAccounts == "" {
+		cfg.IssuerAccounts = DefaultIssuerAccounts
+	}
+	return cfg, nil
+}
+
+// Harness is a running set of services
+type Harness struct {
+	HSMAddr                string
+	HSMMetricsURL          string
+	TokenizationAddr       string
+	TokenizationMetricsURL string
+	// GatewayURL is empty when no gateway was configured
+	GatewayURL string
+
+	cfg          Config
+	workDir      string
+	procs        []*Process
+	conns        []*grpc.ClientConn
+	hsm          hsmv1.HSMServiceClient
+	tokenization tokenizationv2.TokenizationServiceClient
+	gateway      *client.Gateway
+}
+
+// Start builds the Go services and starts every service in dependency
+// order, each once the one before it answers. On error everything already
+// started is stopped.
+func Start(ctx context.Context, cfg Config) (_ *Harness, err error) {
+	workDir, err := os.MkdirTemp("", "gateway-e2e-")
+	if err != nil {
+		return nil, err
+	}
+	h := &Harness{cfg: cfg, workDir: workDir}
+	defer func() {
+		if err != nil {
+			h.Close()
+		}
+	}()
+
+	hsmBin, err := buildService(ctx, cfg.RepoRoot, "hsm-simulator", workDir)
+	if err != nil {
+		return nil, err
+	}
+	tokenizationBin, err := buildService(ctx, cfg.RepoRoot, "tokenization-service", workDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.startHSM(ctx, hsmBin); err != nil {
+		return nil, err
+	}
+	if err := h.startTokenization(ctx, tokenizationBin); err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.GatewayURL != "":
+		h.GatewayURL = strings.TrimRight(cfg.GatewayURL, "/")
+	case cfg.GatewayJar != "":
+		if err := h.startGateway(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if h.GatewayURL != "" {
+		h.gateway = client.NewGateway(h.GatewayURL, cfg.GatewayAPIKey, nil)
+	}
+	return h, nil
+}
+
+func (h *Harness) startHSM(ctx context.Context, bin string) error {
+	port, metricsPort, err := freePorts()
+	if err != nil {
+		return err
+	}
+	proc, err := startProcess("hsm-simulator", h.workDir, bin, []string{
+		"HSM_PORT=" + port,
+		"METRICS_PORT=" + metricsPort,
+	})
+	if err != nil {
+		return err
+	}
+	h.procs = append(h.procs, proc)
+	h.HSMAddr = "127.0.0.1:" + port
+	h.HSMMetricsURL = "http://127.0.0.1:" + metricsPort + "/metrics"
+
+	hsm, conn, err := hsmv1.Dial(ctx, h.HSMAddr)
+	if err != nil {
+		return err
+	}
+	h.conns = append(h.conns, conn)
+	h.hsm = hsm
+	return waitFor(ctx, proc, goStartTimeout, func(ctx context.Context) error {
+		_, err := hsm.GetServiceInfo(ctx, &hsmv1.GetServiceInfoRequest{})
+		return err
+	})
+}
+
+func (h *Harness) startTokenization(ctx context.Context, bin string) error {
+	port, metricsPort, err := freePorts()
+	if err != nil {
+		return err
+	}
+	proc, err := startProcess("tokenization-service", h.workDir, bin, []string{
+		"TOKENIZATION_PORT=" + port,
+		"TOKENIZATION_METRICS_PORT=" + metricsPort,
+		"TOKENIZATION_HSM_ADDR=" + h.HSMAddr,
+		// Scenarios watch vault changes the way a read replica does
+		"TOKENIZATION_CHANGE_FEED=true",
+	})
+	if err != nil {
+		return err
+	}
+	h.procs = append(h.procs, proc)
+	h.TokenizationAddr = "127.0.0.1:" + port
+	h.TokenizationMetricsURL = "http://127.0.0.1:" + metricsPort + "/metrics"
+
+	tokenization, conn, err := tokenizationv2.Dial(ctx, h.TokenizationAddr)
+	if err != nil {
+		return err
+	}
+	h.conns = append(h.conns, conn)
+	h.tokenization = tokenization
+	return waitFor(ctx, proc, goStartTimeout, func(ctx context.Context) error {
+		_, err := tokenization.GetServiceInfo(ctx, &tokenizationv2.GetServiceInfoRequest{})
+		return err
+	})
+}
+
+func (h *Harness) startGateway(ctx context.Context) error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	proc, err := startProcess("gateway", h.workDir, "java", nil,
+		"-jar", h.cfg.GatewayJar,
+		"--server.port="+port,
+		"--psp.simulator.issuer-accounts="+h.cfg.IssuerAccounts,
+		"--grpc.client.tokenization.address=static://"+h.TokenizationAddr,
+	)
+	if err != nil {
+		return err
+	}
+	h.procs = append(h.procs, proc)
+	h.GatewayURL = "http://127.0.0.1:" + port
+	health := h.GatewayURL + "/actuator/health"
+	return waitFor(ctx, proc, gatewayStartTimeout, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, health, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned HTTP %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// HSM returns a client for the HSM simulator
+func (h *Harness) HSM() hsmv1.HSMServiceClient {
+	return h.hsm
+}
+
+// Tokenization returns a client for the tokenization service's v2 API
+func (h *Harness) Tokenization() tokenizationv2.TokenizationServiceClient {
+	return h.tokenization
+}
+
+// Gateway returns a client for the payments API, or nil when no gateway was
+// configured
+func (h *Harness) Gateway() *client.Gateway {
+	return h.gateway
+}
+
+// Logs returns the end of every started service's output
+func (h *Harness) Logs() string {
+	var b strings.Builder
+	for _, p := range h.procs {
+		fmt.Fprintf(&b, "--- %s (%s)\n%s\n", p.Name, p.LogPath, p.LogTail(2048))
+	}
+	return b.String()
+}
+
+// Counter sums the samples of a Prometheus counter whose labels include
+// the given name/value pairs
+func (h *Harness) Counter(ctx context.Context, metricsURL, name string, labels ...string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var want []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		want = append(want, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	var sum float64
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, name+"{") && !strings.HasPrefix(line, name+" ") {
+			continue
+		}
+		matched := true
+		for _, w := range want {
+			if !strings.Contains(line, w) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		v, err := strconv.ParseFloat(line[strings.LastIndexByte(line, ' ')+1:], 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %q: %w", line, err)
+		}
+		sum += v
+	}
+	return sum, scanner.Err()
+}
+
+// Close stops the services in reverse start order and removes their
+// binaries and logs
+func (h *Harness) Close() {
+	for _, conn := range h.conns {
+		conn.Close()
+	}
+	for i := len(h.procs) - 1; i >= 0; i-- {
+		h.procs[i].Stop()
+	}
+	os.RemoveAll(h.workDir)
+}
+
+// waitFor waits up to timeout for a started service to pass its probe
+func waitFor(ctx context.Context, proc *Process, timeout time.Duration, probe func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return proc.waitReady(ctx, func(ctx context.Context) error {
+		probeCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		return probe(probeCtx)
+	})
+}
+
+// freePorts returns a service port and a metrics port
+func freePorts() (string, string, error) {
+	port, err := freePort()
+	if err != nil {
+		return "", "", err
+	}
+	metricsPort, err := freePort()
+	if err != nil {
+		return "", "", err
+	}
+	if metricsPort == port {
+		return freePorts()
+	}
+	return port, metricsPort, nil
+}
@@ -0,0 +1,146 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// stopTimeout is how long a service gets to shut down gracefully before it
+// is killed
+const stopTimeout = 10 * time.Second
+
+// readyPollInterval is how often a starting service is probed
+const readyPollInterval = 100 * time.Millisecond
+
+// ErrExited is returned when a service exits before it is ready
+var ErrExited = errors.New("service exited")
+
+// Process is a service running as a child process. Its output goes to a
+// log file so a failing scenario can show what the service said.
+type Process struct {
+	Name    string
+	LogPath string
+
+	cmd     *exec.Cmd
+	logFile *os.File
+	done    chan struct{}
+	waitErr error
+	once    sync.Once
+}
+
+// startProcess runs bin with args and env added to the test's environment
+func startProcess(name, logDir, bin string, env []string, args ...string) (*Process, error) {
+	logPath := filepath.Join(logDir, name+".log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(bin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("start %s: %w", name, err)
+	}
+	p := &Process{Name: name, LogPath: logPath, cmd: cmd, logFile: logFile, done: make(chan struct{})}
+	go func() {
+		p.waitErr = cmd.Wait()
+		logFile.Close()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Exited reports whether the process has ended
+func (p *Process) Exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop sends SIGTERM and waits for the process, killing it if it does not
+// exit within stopTimeout. Stopping an exited process does nothing.
+func (p *Process) Stop() {
+	p.once.Do(func() {
+		if p.Exited() {
+			return
+		}
+		_ = p.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-p.done:
+		case <-time.After(stopTimeout):
+			_ = p.cmd.Process.Kill()
+			<-p.done
+		}
+	})
+}
+
+// LogTail returns the last n bytes of the service's output
+func (p *Process) LogTail(n int) string {
+	data, err := os.ReadFile(p.LogPath)
+	if err != nil {
+		return ""
+	}
+	if len(data) > n {
+		data = data[len(data)-n:]
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return string(data)
+}
+
+// waitReady polls probe until it succeeds, the process exits or ctx ends
+func (p *Process) waitReady(ctx context.Context, probe func(context.Context) error) error {
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	for {
+		err := probe(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-p.done:
+			return fmt.Errorf("%s: %w (%v); log:\n%s", p.Name, ErrExited, p.waitErr, p.LogTail(4096))
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready: %v; log:\n%s", p.Name, err, p.LogTail(4096))
+		case <-ticker.C:
+		}
+	}
+}
+
+// freePort returns a port that was free a moment ago. Another process may
+// take it before the service binds it; startup then fails and says so.
+func freePort() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer lis.Close()
+	return strconv.Itoa(lis.Addr().(*net.TCPAddr).Port), nil
+}
+
+// buildService compiles a Go service's cmd/server into dir
+func buildService(ctx context.Context, repoRoot, service, dir string) (string, error) {
+	bin := filepath.Join(dir, service)
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", bin, "./cmd/server")
+	cmd.Dir = filepath.Join(repoRoot, service)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("build %s: %w\n%s", service, err, out)
+	}
+	return bin, nil
+}
@@ -2,8 +2,10 @@ package com.paymentgateway.fraud;
 
 import org.springframework.boot.SpringApplication;
 import org.springframework.boot.autoconfigure.SpringBootApplication;
+import org.springframework.scheduling.annotation.EnableScheduling;
 
 @SpringBootApplication
+@EnableScheduling
 public class FraudDetectionServiceApplication {
     
     public static void main(String[] args) {
@@ -7,6 +7,7 @@ import com.paymentgateway.fraud.repository.FraudRuleRepository;
 import com.paymentgateway.fraud.service.FraudDetectionService;
 import com.paymentgateway.fraud.service.FraudEvaluationRequest;
 import com.paymentgateway.fraud.service.FraudEvaluationResult;
+import com.paymentgateway.shared.rules.RuleExpression;
 import io.grpc.stub.StreamObserver;
 import net.devh.boot.grpc.server.service.GrpcService;
 import org.slf4j.Logger;
@@ -69,6 +70,9 @@ public class FraudDetectionGrpcService extends FraudDetectionServiceGrpc.FraudDe
         try {
             logger.info("Updating fraud rule: {}", request.getRuleName());
             
+            // Reject conditions that would never match rather than storing them
+            RuleExpression.parse(request.getRuleCondition());
+            
             FraudRule rule;
             if (request.getRuleId() != null && !request.getRuleId().isEmpty()) {
                 rule = fraudRuleRepository.findById(request.getRuleId())
@@ -0,0 +1,43 @@
+package com.paymentgateway.fraud.rules;
+
+import com.paymentgateway.fraud.domain.FraudStatus;
+import com.paymentgateway.shared.rules.RuleExpression;
+
+/**
+ * A named condition with its effect on the evaluation: a score adjustment,
+ * which may be negative to lower risk, and an optional minimum status
+ */
+public class Rule {
+
+    private final String name;
+    private final RuleExpression condition;
+    private final double score;
+    private final FraudStatus decision;
+
+    public Rule(String name, RuleExpression condition, double score, FraudStatus decision) {
+        this.name = name;
+        this.condition = condition;
+        this.score = score;
+        this.decision = decision;
+    }
+
+    public String getName() {
+        return name;
+    }
+
+    public RuleExpression getCondition() {
+        return condition;
+    }
+
+    public double getScore() {
+        return score;
+    }
+
+    /**
+     * The least severe status a match results in, or null if the rule only
+     * adjusts the score
+     */
+    public FraudStatus getDecision() {
+        return decision;
+    }
+}
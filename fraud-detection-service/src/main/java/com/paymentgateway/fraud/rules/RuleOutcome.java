@@ -0,0 +1,36 @@
+package com.paymentgateway.fraud.rules;
+
+import com.paymentgateway.fraud.domain.FraudStatus;
+
+import java.util.List;
+
+/**
+ * The combined effect of the rules that matched a transaction
+ */
+public class RuleOutcome {
+
+    private final List<String> triggeredRules;
+    private final double scoreAdjustment;
+    private final FraudStatus decision;
+
+    public RuleOutcome(List<String> triggeredRules, double scoreAdjustment, FraudStatus decision) {
+        this.triggeredRules = triggeredRules;
+        this.scoreAdjustment = scoreAdjustment;
+        this.decision = decision;
+    }
+
+    public List<String> getTriggeredRules() {
+        return triggeredRules;
+    }
+
+    public double getScoreAdjustment() {
+        return scoreAdjustment;
+    }
+
+    /**
+     * The most severe decision among the matched rules, or null if none set one
+     */
+    public FraudStatus getDecision() {
+        return decision;
+    }
+}
@@ -0,0 +1,145 @@
+package com.paymentgateway.fraud.rules;
+
+import com.paymentgateway.fraud.domain.FraudStatus;
+import com.paymentgateway.shared.rules.RuleExpression;
+import com.paymentgateway.shared.rules.RuleSyntaxException;
+import org.yaml.snakeyaml.LoaderOptions;
+import org.yaml.snakeyaml.Yaml;
+import org.yaml.snakeyaml.constructor.SafeConstructor;
+import org.yaml.snakeyaml.error.YAMLException;
+
+import java.util.ArrayList;
+import java.util.HashSet;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.Set;
+
+/**
+ * An immutable, validated list of rules, usually loaded from a YAML file:
+ *
+ * <pre>
+ * rules:
+ *   - name: SIM_DECLINE_MAGIC_AMOUNT
+ *     when: amount = 66.66
+ *     decision: BLOCK
+ *   - name: TRUSTED_TEST_MERCHANT
+ *     when: merchant IN ('merchant_test')
+ *     score: -0.2
+ * </pre>
+ */
+public class RuleSet {
+
+    private static final RuleSet EMPTY = new RuleSet(List.of());
+
+    private final List<Rule> rules;
+
+    public RuleSet(List<Rule> rules) {
+        this.rules = List.copyOf(rules);
+    }
+
+    public static RuleSet empty() {
+        return EMPTY;
+    }
+
+    /**
+     * Parses and validates a rules document. Any error rejects the whole
+     * document, so a partially valid file never replaces a working rule set.
+     */
+    public static RuleSet fromYaml(String yaml) {
+        Object document;
+        try {
+            document = new Yaml(new SafeConstructor(new LoaderOptions())).load(yaml);
+        } catch (YAMLException e) {
+            throw new RuleSyntaxException("invalid YAML: " + e.getMessage());
+        }
+        if (document == null) {
+            return EMPTY;
+        }
+        if (!(document instanceof Map<?, ?> root) || !(root.get("rules") instanceof List<?> entries)) {
+            throw new RuleSyntaxException("expected a top-level 'rules' list");
+        }
+
+        List<Rule> rules = new ArrayList<>();
+        Set<String> names = new HashSet<>();
+        for (int i = 0; i < entries.size(); i++) {
+            if (!(entries.get(i) instanceof Map<?, ?> entry)) {
+                throw new RuleSyntaxException("rule " + (i + 1) + ": expected a mapping");
+            }
+            Rule rule = parseRule(i + 1, entry);
+            if (!names.add(rule.getName())) {
+                throw new RuleSyntaxException("rule " + (i + 1) + ": duplicate name " + rule.getName());
+            }
+            rules.add(rule);
+        }
+        return new RuleSet(rules);
+    }
+
+    private static Rule parseRule(int index, Map<?, ?> entry) {
+        String prefix = "rule " + index + ": ";
+        if (!(entry.get("name") instanceof String name) || name.isBlank()) {
+            throw new RuleSyntaxException(prefix + "'name' is required");
+        }
+        prefix = "rule " + index + " (" + name + "): ";
+        if (!(entry.get("when") instanceof String when)) {
+            throw new RuleSyntaxException(prefix + "'when' is required");
+        }
+
+        RuleExpression condition;
+        try {
+            condition = RuleExpression.parse(when);
+        } catch (RuleSyntaxException e) {
+            throw new RuleSyntaxException(prefix + e.getMessage());
+        }
+
+        double score = 0.0;
+        Object rawScore = entry.get("score");
+        if (rawScore instanceof Number n) {
+            score = n.doubleValue();
+        } else if (rawScore != null) {
+            throw new RuleSyntaxException(prefix + "'score' must be a number");
+        }
+        if (score < -1.0 || score > 1.0) {
+            throw new RuleSyntaxException(prefix + "'score' must be between -1.0 and 1.0");
+        }
+
+        FraudStatus decision = null;
+        Object rawDecision = entry.get("decision");
+        if (rawDecision != null) {
+            try {
+                decision = FraudStatus.valueOf(rawDecision.toString().toUpperCase(Locale.ROOT));
+            } catch (IllegalArgumentException e) {
+                throw new RuleSyntaxException(prefix + "'decision' must be REVIEW or BLOCK");
+            }
+            if (decision == FraudStatus.CLEAN) {
+                throw new RuleSyntaxException(prefix + "'decision' must be REVIEW or BLOCK");
+            }
+        }
+        return new Rule(name, condition, score, decision);
+    }
+
+    public List<Rule> getRules() {
+        return rules;
+    }
+
+    public int size() {
+        return rules.size();
+    }
+
+    public RuleOutcome evaluate(Map<String, Object> attributes) {
+        List<String> triggered = new ArrayList<>();
+        double adjustment = 0.0;
+        FraudStatus decision = null;
+        for (Rule rule : rules) {
+            if (!rule.getCondition().matches(attributes)) {
+                continue;
+            }
+            triggered.add(rule.getName());
+            adjustment += rule.getScore();
+            if (rule.getDecision() != null && (decision == null || rule.getDecision().compareTo(decision) > 0)) {
+                decision = rule.getDecision();
+            }
+        }
+        return new RuleOutcome(triggered, adjustment, decision);
+    }
+}
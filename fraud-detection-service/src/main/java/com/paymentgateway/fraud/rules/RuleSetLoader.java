@@ -0,0 +1,69 @@
+package com.paymentgateway.fraud.rules;
+
+import com.paymentgateway.shared.rules.RuleSyntaxException;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.io.IOException;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.attribute.FileTime;
+import java.util.concurrent.atomic.AtomicReference;
+
+/**
+ * Holds the active rule set from the file named by fraud.rules.file and
+ * swaps in a new one when the file changes. A file that fails validation
+ * is logged and ignored; the previous rules stay active.
+ */
+@Component
+public class RuleSetLoader {
+
+    private static final Logger logger = LoggerFactory.getLogger(RuleSetLoader.class);
+
+    private final Path path;
+    private final AtomicReference<RuleSet> current = new AtomicReference<>(RuleSet.empty());
+    // Modification time of the last file parsed, valid or not, so a rejected
+    // file is reported once rather than on every poll
+    private FileTime seenModifiedTime;
+
+    public RuleSetLoader(@Value("${fraud.rules.file:}") String file) {
+        this.path = file == null || file.isBlank() ? null : Path.of(file);
+        if (path != null) {
+            reload();
+        }
+    }
+
+    public RuleSet current() {
+        return current.get();
+    }
+
+    /**
+     * Reloads the rules file if it changed since the last load. Returns true
+     * if a new rule set became active.
+     */
+    @Scheduled(fixedDelayString = "${fraud.rules.reload-interval-ms:5000}")
+    public synchronized boolean reload() {
+        if (path == null) {
+            return false;
+        }
+        try {
+            FileTime modified = Files.getLastModifiedTime(path);
+            if (modified.equals(seenModifiedTime)) {
+                return false;
+            }
+            seenModifiedTime = modified;
+            RuleSet rules = RuleSet.fromYaml(Files.readString(path));
+            current.set(rules);
+            logger.info("Loaded {} fraud rules from {}", rules.size(), path);
+            return true;
+        } catch (IOException e) {
+            logger.error("Cannot read fraud rules from {}: {}", path, e.getMessage());
+        } catch (RuleSyntaxException e) {
+            logger.error("Rejected fraud rules from {}, keeping previous rules: {}", path, e.getMessage());
+        }
+        return false;
+    }
+}
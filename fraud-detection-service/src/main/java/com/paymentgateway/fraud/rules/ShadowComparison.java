@@ -0,0 +1,93 @@
+package com.paymentgateway.fraud.rules;
+
+import com.paymentgateway.fraud.domain.FraudStatus;
+
+import java.math.BigDecimal;
+import java.time.Instant;
+import java.util.List;
+
+/**
+ * One transaction's decision under the active rules next to the decision
+ * the candidate rules would have made
+ */
+public class ShadowComparison {
+
+    private final String transactionId;
+    private final String merchantId;
+    private final BigDecimal amount;
+    private final Instant evaluatedAt;
+    private final FraudStatus activeStatus;
+    private final double activeScore;
+    private final List<String> activeRules;
+    private final FraudStatus shadowStatus;
+    private final double shadowScore;
+    private final List<String> shadowRules;
+
+    public ShadowComparison(String transactionId, String merchantId, BigDecimal amount, Instant evaluatedAt,
+                            FraudStatus activeStatus, double activeScore, List<String> activeRules,
+                            FraudStatus shadowStatus, double shadowScore, List<String> shadowRules) {
+        this.transactionId = transactionId;
+        this.merchantId = merchantId;
+        this.amount = amount;
+        this.evaluatedAt = evaluatedAt;
+        this.activeStatus = activeStatus;
+        this.activeScore = activeScore;
+        this.activeRules = List.copyOf(activeRules);
+        this.shadowStatus = shadowStatus;
+        this.shadowScore = shadowScore;
+        this.shadowRules = List.copyOf(shadowRules);
+    }
+
+    public String getTransactionId() {
+        return transactionId;
+    }
+
+    public String getMerchantId() {
+        return merchantId;
+    }
+
+    public BigDecimal getAmount() {
+        return amount;
+    }
+
+    public Instant getEvaluatedAt() {
+        return evaluatedAt;
+    }
+
+    public FraudStatus getActiveStatus() {
+        return activeStatus;
+    }
+
+    public double getActiveScore() {
+        return activeScore;
+    }
+
+    /**
+     * Rules file rules the active set triggered
+     */
+    public List<String> getActiveRules() {
+        return activeRules;
+    }
+
+    public FraudStatus getShadowStatus() {
+        return shadowStatus;
+    }
+
+    public double getShadowScore() {
+        return shadowScore;
+    }
+
+    /**
+     * Rules file rules the candidate set triggered
+     */
+    public List<String> getShadowRules() {
+        return shadowRules;
+    }
+
+    /**
+     * Whether the candidate rules would have changed the outcome
+     */
+    public boolean isDivergent() {
+        return activeStatus != shadowStatus;
+    }
+}
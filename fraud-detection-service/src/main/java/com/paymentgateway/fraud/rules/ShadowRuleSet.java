@@ -0,0 +1,176 @@
+package com.paymentgateway.fraud.rules;
+
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.time.Instant;
+import java.util.ArrayDeque;
+import java.util.Deque;
+import java.util.List;
+import java.util.Map;
+import java.util.TreeMap;
+
+/**
+ * A candidate rule set, from the file named by fraud.rules.shadow-file,
+ * evaluated alongside the active one without affecting outcomes. Each
+ * transaction's two decisions are tallied into a report of how often and
+ * how the candidate would have decided differently, which starts over
+ * whenever the candidate file changes.
+ */
+@Component
+public class ShadowRuleSet {
+
+    // Most recent divergent decisions kept for the report
+    static final int MAX_DIVERGENCES = 200;
+
+    private final RuleSetLoader loader;
+    private final boolean enabled;
+
+    private Instant since = Instant.now();
+    private long evaluated;
+    private long divergent;
+    private final Map<String, Long> transitions = new TreeMap<>();
+    private final Map<String, Long> activeRuleHits = new TreeMap<>();
+    private final Map<String, Long> shadowRuleHits = new TreeMap<>();
+    private final Deque<ShadowComparison> divergences = new ArrayDeque<>();
+
+    public ShadowRuleSet(@Value("${fraud.rules.shadow-file:}") String file) {
+        this.enabled = file != null && !file.isBlank();
+        this.loader = new RuleSetLoader(enabled ? file : "");
+    }
+
+    /**
+     * Whether a candidate file is configured
+     */
+    public boolean isEnabled() {
+        return enabled;
+    }
+
+    public RuleSet current() {
+        return loader.current();
+    }
+
+    /**
+     * Reloads the candidate file if it changed, and starts a new report if
+     * a new candidate became active
+     */
+    @Scheduled(fixedDelayString = "${fraud.rules.reload-interval-ms:5000}")
+    public boolean reload() {
+        if (!enabled || !loader.reload()) {
+            return false;
+        }
+        reset();
+        return true;
+    }
+
+    public synchronized void record(ShadowComparison comparison) {
+        evaluated++;
+        transitions.merge(comparison.getActiveStatus() + "->" + comparison.getShadowStatus(), 1L, Long::sum);
+        comparison.getActiveRules().forEach(rule -> activeRuleHits.merge(rule, 1L, Long::sum));
+        comparison.getShadowRules().forEach(rule -> shadowRuleHits.merge(rule, 1L, Long::sum));
+        if (comparison.isDivergent()) {
+            divergent++;
+            divergences.addFirst(comparison);
+            if (divergences.size() > MAX_DIVERGENCES) {
+                divergences.removeLast();
+            }
+        }
+    }
+
+    public synchronized Report report() {
+        return new Report(enabled, current().size(), since, evaluated, divergent, new TreeMap<>(transitions),
+                          new TreeMap<>(activeRuleHits), new TreeMap<>(shadowRuleHits), List.copyOf(divergences));
+    }
+
+    public synchronized void reset() {
+        since = Instant.now();
+        evaluated = 0;
+        divergent = 0;
+        transitions.clear();
+        activeRuleHits.clear();
+        shadowRuleHits.clear();
+        divergences.clear();
+    }
+
+    /**
+     * The comparison of the active and candidate rules since the candidate
+     * was loaded or the report reset
+     */
+    public static class Report {
+
+        private final boolean enabled;
+        private final int candidateRules;
+        private final Instant since;
+        private final long evaluated;
+        private final long divergent;
+        private final Map<String, Long> transitions;
+        private final Map<String, Long> activeRuleHits;
+        private final Map<String, Long> shadowRuleHits;
+        private final List<ShadowComparison> recentDivergences;
+
+        Report(boolean enabled, int candidateRules, Instant since, long evaluated, long divergent,
+               Map<String, Long> transitions, Map<String, Long> activeRuleHits, Map<String, Long> shadowRuleHits,
+               List<ShadowComparison> recentDivergences) {
+            this.enabled = enabled;
+            this.candidateRules = candidateRules;
+            this.since = since;
+            this.evaluated = evaluated;
+            this.divergent = divergent;
+            this.transitions = transitions;
+            this.activeRuleHits = activeRuleHits;
+            this.shadowRuleHits = shadowRuleHits;
+            this.recentDivergences = recentDivergences;
+        }
+
+        public boolean isEnabled() {
+            return enabled;
+        }
+
+        public int getCandidateRules() {
+            return candidateRules;
+        }
+
+        public Instant getSince() {
+            return since;
+        }
+
+        public long getEvaluated() {
+            return evaluated;
+        }
+
+        public long getDivergent() {
+            return divergent;
+        }
+
+        /**
+         * Share of evaluated transactions the candidate would have decided
+         * differently
+         */
+        public double getDivergenceRate() {
+            return evaluated == 0 ? 0.0 : (double) divergent / evaluated;
+        }
+
+        /**
+         * Count of each active to candidate status pair, e.g. CLEAN->REVIEW
+         */
+        public Map<String, Long> getTransitions() {
+            return transitions;
+        }
+
+        public Map<String, Long> getActiveRuleHits() {
+            return activeRuleHits;
+        }
+
+        public Map<String, Long> getShadowRuleHits() {
+            return shadowRuleHits;
+        }
+
+        /**
+         * Up to the last 200 divergent decisions, newest first
+         */
+        public List<ShadowComparison> getRecentDivergences() {
+            return recentDivergences;
+        }
+    }
+}
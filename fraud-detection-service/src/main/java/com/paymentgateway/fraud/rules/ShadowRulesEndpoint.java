@@ -0,0 +1,31 @@
+package com.paymentgateway.fraud.rules;
+
+import org.springframework.boot.actuate.endpoint.annotation.DeleteOperation;
+import org.springframework.boot.actuate.endpoint.annotation.Endpoint;
+import org.springframework.boot.actuate.endpoint.annotation.ReadOperation;
+import org.springframework.stereotype.Component;
+
+/**
+ * Serves the shadow rules report at /actuator/shadowrules; DELETE starts
+ * a new one
+ */
+@Component
+@Endpoint(id = "shadowrules")
+public class ShadowRulesEndpoint {
+
+    private final ShadowRuleSet shadow;
+
+    public ShadowRulesEndpoint(ShadowRuleSet shadow) {
+        this.shadow = shadow;
+    }
+
+    @ReadOperation
+    public ShadowRuleSet.Report report() {
+        return shadow.report();
+    }
+
+    @DeleteOperation
+    public void reset() {
+        shadow.reset();
+    }
+}
@@ -6,8 +6,15 @@ import com.paymentgateway.fraud.domain.FraudStatus;
 import com.paymentgateway.fraud.repository.BlacklistRepository;
 import com.paymentgateway.fraud.repository.FraudAlertRepository;
 import com.paymentgateway.fraud.repository.FraudRuleRepository;
+import com.paymentgateway.fraud.rules.RuleOutcome;
+import com.paymentgateway.fraud.rules.RuleSetLoader;
+import com.paymentgateway.fraud.rules.ShadowComparison;
+import com.paymentgateway.fraud.rules.ShadowRuleSet;
+import com.paymentgateway.shared.rules.RuleExpression;
+import com.paymentgateway.shared.rules.RuleSyntaxException;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
 import org.springframework.data.redis.core.RedisTemplate;
 import org.springframework.stereotype.Service;
 import org.springframework.transaction.annotation.Transactional;
@@ -15,8 +22,11 @@ import org.springframework.transaction.annotation.Transactional;
 import java.math.BigDecimal;
 import java.time.Duration;
 import java.time.Instant;
+import java.time.LocalTime;
 import java.util.ArrayList;
+import java.util.HashMap;
 import java.util.List;
+import java.util.Map;
 import java.util.concurrent.TimeUnit;
 
 @Service
@@ -34,6 +44,8 @@ public class FraudDetectionService {
     private final VelocityCheckService velocityCheckService;
     private final GeolocationService geolocationService;
     private final MLFraudScoringService mlFraudScoringService;
+    private final RuleSetLoader ruleSetLoader;
+    private final ShadowRuleSet shadowRuleSet;
     
     public FraudDetectionService(
             FraudRuleRepository fraudRuleRepository,
@@ -42,7 +54,23 @@ public class FraudDetectionService {
             RedisTemplate<String, String> redisTemplate,
             VelocityCheckService velocityCheckService,
             GeolocationService geolocationService,
-            MLFraudScoringService mlFraudScoringService) {
+            MLFraudScoringService mlFraudScoringService,
+            RuleSetLoader ruleSetLoader) {
+        this(fraudRuleRepository, fraudAlertRepository, blacklistRepository, redisTemplate, velocityCheckService,
+             geolocationService, mlFraudScoringService, ruleSetLoader, new ShadowRuleSet(""));
+    }
+    
+    @Autowired
+    public FraudDetectionService(
+            FraudRuleRepository fraudRuleRepository,
+            FraudAlertRepository fraudAlertRepository,
+            BlacklistRepository blacklistRepository,
+            RedisTemplate<String, String> redisTemplate,
+            VelocityCheckService velocityCheckService,
+            GeolocationService geolocationService,
+            MLFraudScoringService mlFraudScoringService,
+            RuleSetLoader ruleSetLoader,
+            ShadowRuleSet shadowRuleSet) {
         this.fraudRuleRepository = fraudRuleRepository;
         this.fraudAlertRepository = fraudAlertRepository;
         this.blacklistRepository = blacklistRepository;
@@ -50,6 +78,8 @@ public class FraudDetectionService {
         this.velocityCheckService = velocityCheckService;
         this.geolocationService = geolocationService;
         this.mlFraudScoringService = mlFraudScoringService;
+        this.ruleSetLoader = ruleSetLoader;
+        this.shadowRuleSet = shadowRuleSet;
     }
     
     @Transactional
@@ -84,45 +114,46 @@ public class FraudDetectionService {
         }
         
         // Evaluate custom rules
+        Map<String, Object> attributes = ruleAttributes(request);
         List<FraudRule> rules = fraudRuleRepository.findByEnabledTrueOrderByPriorityAsc();
         for (FraudRule rule : rules) {
-            if (evaluateRule(rule, request)) {
+            if (evaluateRule(rule, attributes)) {
                 triggeredRules.add(rule.getRuleName());
             }
         }
         
+        // Rules from the rules file may also adjust the score or force a decision
+        RuleOutcome outcome = ruleSetLoader.current().evaluate(attributes);
+        int baseRuleCount = triggeredRules.size();
+        triggeredRules.addAll(outcome.getTriggeredRules());
+        
         // ML-based fraud scoring
         double mlScore = mlFraudScoringService.calculateFraudScore(request);
         
-        // Combine scores (weighted average)
-        double finalScore = calculateFinalScore(mlScore, geoRiskScore, triggeredRules.size());
-        
-        // Ensure score is in valid range [0.0, 1.0]
-        finalScore = Math.max(0.0, Math.min(1.0, finalScore));
+        double finalScore = score(mlScore, geoRiskScore, triggeredRules.size(), outcome);
         
         // Determine status and actions
-        FraudStatus status;
+        FraudStatus status = statusFor(finalScore);
         boolean require3DS = false;
         String riskLevel;
         
-        if (finalScore >= HIGH_RISK_THRESHOLD) {
-            status = FraudStatus.BLOCK;
+        if (status == FraudStatus.BLOCK) {
             riskLevel = "HIGH";
             require3DS = true;
             createFraudAlert(request, finalScore, status, triggeredRules);
-        } else if (finalScore >= REVIEW_THRESHOLD) {
-            status = FraudStatus.REVIEW;
+        } else if (status == FraudStatus.REVIEW) {
             riskLevel = "MEDIUM";
             require3DS = true;
             createFraudAlert(request, finalScore, status, triggeredRules);
         } else {
-            status = FraudStatus.CLEAN;
             riskLevel = "LOW";
         }
         
         logger.info("Transaction {} evaluated: score={}, status={}, require3DS={}", 
             request.getTransactionId(), finalScore, status, require3DS);
         
+        compareWithShadow(request, attributes, mlScore, geoRiskScore, baseRuleCount, outcome, finalScore, status);
+        
         return new FraudEvaluationResult(
             finalScore,
             status,
@@ -154,37 +185,85 @@ public class FraudDetectionService {
         return false;
     }
     
-    private boolean evaluateRule(FraudRule rule, FraudEvaluationRequest request) {
-        // Simple rule evaluation - in production this would be more sophisticated
-        String condition = rule.getRuleCondition();
-        
-        // Example rule conditions:
-        // "amount > 10000"
-        // "country = 'NG'"
-        // "hour >= 22 OR hour <= 6"
-        
+    private boolean evaluateRule(FraudRule rule, Map<String, Object> attributes) {
         try {
-            // This is a simplified implementation
-            // In production, use a proper rule engine like Drools or custom DSL
-            if (condition.contains("amount >")) {
-                String[] parts = condition.split(">");
-                double threshold = Double.parseDouble(parts[1].trim());
-                return request.getAmount().doubleValue() > threshold;
-            }
-            
-            if (condition.contains("country =")) {
-                String country = condition.split("=")[1].trim().replace("'", "");
-                return request.getBillingAddress() != null && 
-                       country.equals(request.getBillingAddress().getCountry());
-            }
-            
-            // Add more rule evaluation logic as needed
-            
-        } catch (Exception e) {
+            return RuleExpression.parse(rule.getRuleCondition()).matches(attributes);
+        } catch (RuleSyntaxException e) {
             logger.error("Error evaluating rule {}: {}", rule.getRuleName(), e.getMessage());
+            return false;
         }
+    }
+    
+    /**
+     * Attributes rule conditions can refer to. Metadata entries are exposed
+     * as {@code metadata.<key>}.
+     */
+    private Map<String, Object> ruleAttributes(FraudEvaluationRequest request) {
+        Map<String, Object> attributes = new HashMap<>();
+        attributes.put("amount", request.getAmount());
+        attributes.put("currency", request.getCurrency());
+        attributes.put("merchant", request.getMerchantId());
+        attributes.put("card", request.getCardToken());
+        attributes.put("ip", request.getIpAddress());
+        attributes.put("device", request.getDeviceFingerprint());
+        if (request.getBillingAddress() != null) {
+            attributes.put("country", request.getBillingAddress().getCountry());
+            attributes.put("postal_code", request.getBillingAddress().getPostalCode());
+        }
+        attributes.put("hour", LocalTime.now().getHour());
+        if (request.getMetadata() != null) {
+            request.getMetadata().forEach((key, value) -> attributes.put("metadata." + key, value));
+        }
+        return attributes;
+    }
+    
+    /**
+     * Combines the signals with the outcome of the rules file, clamped to
+     * [0.0, 1.0]
+     */
+    private double score(double mlScore, double geoScore, int ruleCount, RuleOutcome outcome) {
+        // Combine scores (weighted average)
+        double score = calculateFinalScore(mlScore, geoScore, ruleCount) + outcome.getScoreAdjustment();
         
-        return false;
+        // A decision raises the score into its band so status and score agree
+        if (outcome.getDecision() == FraudStatus.BLOCK) {
+            score = Math.max(score, HIGH_RISK_THRESHOLD);
+        } else if (outcome.getDecision() == FraudStatus.REVIEW) {
+            score = Math.max(score, REVIEW_THRESHOLD);
+        }
+        return Math.max(0.0, Math.min(1.0, score));
+    }
+    
+    private static FraudStatus statusFor(double score) {
+        if (score >= HIGH_RISK_THRESHOLD) {
+            return FraudStatus.BLOCK;
+        }
+        return score >= REVIEW_THRESHOLD ? FraudStatus.REVIEW : FraudStatus.CLEAN;
+    }
+    
+    /**
+     * Scores the transaction again with the candidate rules in place of the
+     * rules file, on the same signals, and records how the two decisions
+     * compare. The candidate never changes the outcome, raises alerts or
+     * fails the evaluation.
+     */
+    private void compareWithShadow(FraudEvaluationRequest request, Map<String, Object> attributes,
+                                   double mlScore, double geoScore, int baseRuleCount,
+                                   RuleOutcome active, double activeScore, FraudStatus activeStatus) {
+        if (!shadowRuleSet.isEnabled()) {
+            return;
+        }
+        try {
+            RuleOutcome candidate = shadowRuleSet.current().evaluate(attributes);
+            double shadowScore = score(mlScore, geoScore, baseRuleCount + candidate.getTriggeredRules().size(), candidate);
+            shadowRuleSet.record(new ShadowComparison(
+                request.getTransactionId(), request.getMerchantId(), request.getAmount(), Instant.now(),
+                activeStatus, activeScore, active.getTriggeredRules(),
+                statusFor(shadowScore), shadowScore, candidate.getTriggeredRules()));
+        } catch (RuntimeException e) {
+            logger.warn("Shadow rules evaluation failed for transaction {}: {}",
+                request.getTransactionId(), e.getMessage());
+        }
     }
     
     private double calculateFinalScore(double mlScore, double geoScore, int ruleCount) {
@@ -3,6 +3,7 @@ package com.paymentgateway.fraud.property;
 import com.paymentgateway.fraud.domain.Blacklist;
 import com.paymentgateway.fraud.domain.FraudStatus;
 import com.paymentgateway.fraud.repository.BlacklistRepository;
+import com.paymentgateway.fraud.rules.RuleSetLoader;
 import com.paymentgateway.fraud.service.*;
 import net.jqwik.api.*;
 import net.jqwik.api.constraints.*;
@@ -45,7 +46,8 @@ class BlacklistImmediateRejectionPropertyTest {
             redisTemplate,
             velocityCheckService,
             geolocationService,
-            mlFraudScoringService
+            mlFraudScoringService,
+            new RuleSetLoader("")
         );
         
         // Mock Redis operations to avoid actual Redis calls
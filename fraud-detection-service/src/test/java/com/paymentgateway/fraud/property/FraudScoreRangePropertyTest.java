@@ -1,5 +1,6 @@
 package com.paymentgateway.fraud.property;
 
+import com.paymentgateway.fraud.rules.RuleSetLoader;
 import com.paymentgateway.fraud.service.*;
 import net.jqwik.api.*;
 import net.jqwik.api.constraints.*;
@@ -41,7 +42,8 @@ class FraudScoreRangePropertyTest {
             redisTemplate,
             velocityCheckService,
             geolocationService,
-            mlFraudScoringService
+            mlFraudScoringService,
+            new RuleSetLoader("")
         );
         
         // Mock Redis operations to avoid actual Redis calls
@@ -1,6 +1,7 @@
 package com.paymentgateway.fraud.property;
 
 import com.paymentgateway.fraud.domain.FraudStatus;
+import com.paymentgateway.fraud.rules.RuleSetLoader;
 import com.paymentgateway.fraud.service.*;
 import net.jqwik.api.*;
 import net.jqwik.api.constraints.*;
@@ -43,7 +44,8 @@ class HighRiskTriggers3DSPropertyTest {
             redisTemplate,
             velocityCheckService,
             geolocationService,
-            mlFraudScoringService
+            mlFraudScoringService,
+            new RuleSetLoader("")
         );
         
         // Mock Redis operations to avoid actual Redis calls
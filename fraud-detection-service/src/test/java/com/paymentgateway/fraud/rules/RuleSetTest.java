@@ -0,0 +1,164 @@
+package com.paymentgateway.fraud.rules;
+
+import com.paymentgateway.fraud.domain.FraudStatus;
+import com.paymentgateway.shared.rules.RuleExpression;
+import com.paymentgateway.shared.rules.RuleSyntaxException;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.io.TempDir;
+
+import java.math.BigDecimal;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.nio.file.attribute.FileTime;
+import java.time.Instant;
+import java.util.List;
+import java.util.Map;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.assertj.core.api.Assertions.within;
+
+class RuleSetTest {
+    
+    private static final Map<String, Object> TRANSACTION = Map.of(
+        "amount", new BigDecimal("66.66"),
+        "currency", "USD",
+        "country", "NG",
+        "hour", 23,
+        "metadata.channel", "web"
+    );
+    
+    @Test
+    void shouldEvaluateConditions() {
+        assertThat(matches("amount > 10000")).isFalse();
+        assertThat(matches("amount = 66.66")).isTrue();
+        assertThat(matches("amount >= 66.660")).isTrue();
+        assertThat(matches("country = 'NG'")).isTrue();
+        assertThat(matches("hour >= 22 OR hour <= 6")).isTrue();
+        assertThat(matches("currency IN ('EUR', 'USD') AND NOT (metadata.channel = 'pos')")).isTrue();
+        assertThat(matches("country != 'NG' or amount < 10")).isFalse();
+    }
+    
+    @Test
+    void shouldNotMatchMissingAttributes() {
+        assertThat(matches("device = 'abc'")).isFalse();
+        assertThat(matches("device != 'abc'")).isFalse();
+        assertThat(matches("NOT device = 'abc'")).isTrue();
+    }
+    
+    @Test
+    void shouldRejectInvalidConditions() {
+        for (String condition : new String[] {"", "amount >", "amount > 10 AND", "country = 'NG", "(amount > 1", "amount ! 5", "amount > 1 2"}) {
+            assertThatThrownBy(() -> RuleExpression.parse(condition))
+                .as(condition)
+                .isInstanceOf(RuleSyntaxException.class);
+        }
+    }
+    
+    @Test
+    void shouldCombineMatchingRules() {
+        RuleSet rules = RuleSet.fromYaml("""
+            rules:
+              - name: MAGIC_DECLINE
+                when: amount = 66.66
+                decision: BLOCK
+              - name: LATE_NIGHT
+                when: hour >= 22
+                score: 0.1
+                decision: review
+              - name: TRUSTED
+                when: metadata.channel = 'web'
+                score: -0.3
+              - name: NEVER
+                when: amount > 1000
+                score: 0.5
+            """);
+        
+        RuleOutcome outcome = rules.evaluate(TRANSACTION);
+        
+        assertThat(outcome.getTriggeredRules()).containsExactly("MAGIC_DECLINE", "LATE_NIGHT", "TRUSTED");
+        assertThat(outcome.getScoreAdjustment()).isCloseTo(-0.2, within(1e-9));
+        assertThat(outcome.getDecision()).isEqualTo(FraudStatus.BLOCK);
+    }
+    
+    @Test
+    void shouldRejectInvalidRuleFiles() {
+        String[] documents = {
+            "rules: not-a-list",
+            "rules:\n  - when: amount > 1",
+            "rules:\n  - name: A\n    when: amount >",
+            "rules:\n  - name: A\n    when: amount > 1\n    decision: CLEAN",
+            "rules:\n  - name: A\n    when: amount > 1\n    score: 2",
+            "rules:\n  - name: A\n    when: amount > 1\n  - name: A\n    when: amount > 2",
+        };
+        for (String document : documents) {
+            assertThatThrownBy(() -> RuleSet.fromYaml(document))
+                .as(document)
+                .isInstanceOf(RuleSyntaxException.class);
+        }
+    }
+    
+    @Test
+    void shouldReloadChangedFileAndKeepRulesOnError(@TempDir Path dir) throws Exception {
+        Path file = dir.resolve("rules.yml");
+        Files.writeString(file, "rules:\n  - name: A\n    when: amount > 1\n");
+        RuleSetLoader loader = new RuleSetLoader(file.toString());
+        assertThat(loader.current().size()).isEqualTo(1);
+        
+        // Unchanged file is not reloaded
+        assertThat(loader.reload()).isFalse();
+        
+        Files.writeString(file, "rules:\n  - name: A\n    when: amount > 1\n  - name: B\n    when: amount > 2\n");
+        Files.setLastModifiedTime(file, FileTime.from(Instant.now().plusSeconds(10)));
+        assertThat(loader.reload()).isTrue();
+        assertThat(loader.current().size()).isEqualTo(2);
+        
+        // An invalid file is rejected and the previous rules stay active
+        Files.writeString(file, "rules:\n  - name: C\n    when: amount >>\n");
+        Files.setLastModifiedTime(file, FileTime.from(Instant.now().plusSeconds(20)));
+        assertThat(loader.reload()).isFalse();
+        assertThat(loader.current().size()).isEqualTo(2);
+    }
+    
+    @Test
+    void shouldReportDivergentShadowDecisionsUntilCandidateChanges(@TempDir Path dir) throws Exception {
+        Path file = dir.resolve("candidate.yml");
+        Files.writeString(file, "rules:\n  - name: A\n    when: amount > 1\n    decision: REVIEW\n");
+        ShadowRuleSet shadow = new ShadowRuleSet(file.toString());
+        assertThat(shadow.isEnabled()).isTrue();
+        assertThat(shadow.current().size()).isEqualTo(1);
+        
+        shadow.record(comparison("txn_same", FraudStatus.CLEAN, FraudStatus.CLEAN));
+        for (int i = 0; i < ShadowRuleSet.MAX_DIVERGENCES + 5; i++) {
+            shadow.record(comparison("txn_" + i, FraudStatus.CLEAN, FraudStatus.REVIEW));
+        }
+        ShadowRuleSet.Report report = shadow.report();
+        assertThat(report.getEvaluated()).isEqualTo(ShadowRuleSet.MAX_DIVERGENCES + 6);
+        assertThat(report.getDivergent()).isEqualTo(ShadowRuleSet.MAX_DIVERGENCES + 5);
+        assertThat(report.getDivergenceRate()).isCloseTo(205.0 / 206, within(1e-9));
+        assertThat(report.getTransitions()).containsEntry("CLEAN->CLEAN", 1L).containsEntry("CLEAN->REVIEW", 205L);
+        assertThat(report.getShadowRuleHits()).containsEntry("A", 205L);
+        
+        // Only the most recent divergences are kept, newest first
+        assertThat(report.getRecentDivergences()).hasSize(ShadowRuleSet.MAX_DIVERGENCES);
+        assertThat(report.getRecentDivergences().get(0).getTransactionId()).isEqualTo("txn_204");
+        
+        // A new candidate starts a new report
+        Files.writeString(file, "rules:\n  - name: B\n    when: amount > 2\n    decision: BLOCK\n");
+        Files.setLastModifiedTime(file, FileTime.from(Instant.now().plusSeconds(10)));
+        assertThat(shadow.reload()).isTrue();
+        assertThat(shadow.report().getEvaluated()).isZero();
+        assertThat(shadow.report().getRecentDivergences()).isEmpty();
+        
+        assertThat(new ShadowRuleSet("").isEnabled()).isFalse();
+    }
+    
+    private static ShadowComparison comparison(String transactionId, FraudStatus active, FraudStatus shadow) {
+        return new ShadowComparison(transactionId, "merchant_123", new BigDecimal("10.00"), Instant.now(),
+                                    active, 0.2, List.of(), shadow, 0.5, shadow == active ? List.of() : List.of("A"));
+    }
+    
+    private static boolean matches(String condition) {
+        return RuleExpression.parse(condition).matches(TRANSACTION);
+    }
+}
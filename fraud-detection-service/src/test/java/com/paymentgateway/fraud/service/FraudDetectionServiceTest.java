@@ -4,19 +4,26 @@ import com.paymentgateway.fraud.domain.FraudStatus;
 import com.paymentgateway.fraud.repository.BlacklistRepository;
 import com.paymentgateway.fraud.repository.FraudAlertRepository;
 import com.paymentgateway.fraud.repository.FraudRuleRepository;
+import com.paymentgateway.fraud.rules.RuleSetLoader;
+import com.paymentgateway.fraud.rules.ShadowComparison;
+import com.paymentgateway.fraud.rules.ShadowRuleSet;
 import org.junit.jupiter.api.BeforeEach;
 import org.junit.jupiter.api.Test;
 import org.junit.jupiter.api.extension.ExtendWith;
+import org.junit.jupiter.api.io.TempDir;
 import org.mockito.Mock;
 import org.mockito.junit.jupiter.MockitoExtension;
 import org.springframework.data.redis.core.RedisTemplate;
 import org.springframework.data.redis.core.ValueOperations;
 
 import java.math.BigDecimal;
+import java.nio.file.Files;
+import java.nio.file.Path;
 import java.util.Collections;
 import java.util.HashMap;
 
 import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.entry;
 import static org.mockito.ArgumentMatchers.any;
 import static org.mockito.ArgumentMatchers.anyString;
 import static org.mockito.ArgumentMatchers.eq;
@@ -62,7 +69,8 @@ class FraudDetectionServiceTest {
             redisTemplate,
             velocityCheckService,
             geolocationService,
-            mlFraudScoringService
+            mlFraudScoringService,
+            new RuleSetLoader("")
         );
     }
     
@@ -291,6 +299,69 @@ class FraudDetectionServiceTest {
         }
     }
     
+    @Test
+    void shouldApplyDecisionFromRulesFile(@TempDir Path dir) throws Exception {
+        // Given: a rules file that blocks a magic amount
+        Path file = dir.resolve("rules.yml");
+        Files.writeString(file, "rules:\n  - name: SIM_DECLINE\n    when: amount = 100.00\n    decision: BLOCK\n");
+        FraudDetectionService service = new FraudDetectionService(
+            fraudRuleRepository,
+            fraudAlertRepository,
+            blacklistRepository,
+            redisTemplate,
+            velocityCheckService,
+            geolocationService,
+            mlFraudScoringService,
+            new RuleSetLoader(file.toString())
+        );
+        when(blacklistRepository.existsByEntryTypeAndValue(anyString(), anyString())).thenReturn(false);
+        
+        // When
+        FraudEvaluationResult result = service.evaluateTransaction(createValidRequest());
+        
+        // Then: the rule forces a block regardless of the computed score
+        assertThat(result.getStatus()).isEqualTo(FraudStatus.BLOCK);
+        assertThat(result.getFraudScore()).isGreaterThanOrEqualTo(0.75);
+        assertThat(result.getTriggeredRules()).contains("SIM_DECLINE");
+    }
+    
+    @Test
+    void shouldCompareCandidateRulesWithoutAffectingOutcome(@TempDir Path dir) throws Exception {
+        // Given: no active rules file, and a candidate that blocks a magic amount
+        Path candidate = dir.resolve("candidate.yml");
+        Files.writeString(candidate, "rules:\n  - name: SIM_DECLINE\n    when: amount = 100.00\n    decision: BLOCK\n");
+        ShadowRuleSet shadow = new ShadowRuleSet(candidate.toString());
+        FraudDetectionService service = new FraudDetectionService(
+            fraudRuleRepository,
+            fraudAlertRepository,
+            blacklistRepository,
+            redisTemplate,
+            velocityCheckService,
+            geolocationService,
+            mlFraudScoringService,
+            new RuleSetLoader(""),
+            shadow
+        );
+        when(blacklistRepository.existsByEntryTypeAndValue(anyString(), anyString())).thenReturn(false);
+        
+        // When
+        FraudEvaluationResult result = service.evaluateTransaction(createValidRequest());
+        
+        // Then: the candidate's block is only reported
+        assertThat(result.getTriggeredRules()).doesNotContain("SIM_DECLINE");
+        ShadowRuleSet.Report report = shadow.report();
+        assertThat(report.getEvaluated()).isEqualTo(1);
+        assertThat(report.getTransitions()).containsExactly(entry(result.getStatus() + "->BLOCK", 1L));
+        assertThat(report.getShadowRuleHits()).containsExactly(entry("SIM_DECLINE", 1L));
+        if (result.getStatus() != FraudStatus.BLOCK) {
+            assertThat(report.getDivergent()).isEqualTo(1);
+            ShadowComparison divergence = report.getRecentDivergences().get(0);
+            assertThat(divergence.getTransactionId()).isEqualTo("txn_123");
+            assertThat(divergence.getActiveScore()).isEqualTo(result.getFraudScore());
+            assertThat(divergence.getShadowScore()).isGreaterThanOrEqualTo(0.75);
+        }
+    }
+    
     private FraudEvaluationRequest createValidRequest() {
         return new FraudEvaluationRequest(
             "txn_123",
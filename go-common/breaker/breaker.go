@@ -0,0 +1,311 @@
+// Package breaker is a circuit breaker for calls to a downstream dependency.
+// After a run of consecutive failures the breaker opens and calls are turned
+// away without reaching the dependency, so a struggling HSM or network is not
+// buried under retries. Once the open timeout passes, one trial call is let
+// through: success closes the breaker, failure opens it again.
+//
+// While open, a breaker either fails fast with ErrOpen or, in queue mode,
+// holds a bounded number of callers until the trial call succeeds or their
+// wait runs out.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+var (
+	ErrOpen      = errors.New("circuit breaker is open")
+	ErrQueueFull = errors.New("circuit breaker queue is full")
+)
+
+// State is the position of a breaker
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Fallback is what a call does while the breaker is open
+type Fallback string
+
+const (
+	FailFast Fallback = "fail-fast"
+	Queue    Fallback = "queue"
+)
+
+// ParseFallback accepts "fail-fast" or "queue"; empty means fail-fast
+func ParseFallback(s string) (Fallback, error) {
+	switch Fallback(s) {
+	case "", FailFast:
+		return FailFast, nil
+	case Queue:
+		return Queue, nil
+	}
+	return "", errors.New("unknown circuit breaker fallback " + s)
+}
+
+// Config tunes a breaker. Zero fields take the defaults noted.
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the breaker (default 5)
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before a trial call
+	// (default 30s)
+	OpenTimeout time.Duration
+	Fallback    Fallback
+	// MaxQueued bounds the callers waiting in queue mode (default 100)
+	MaxQueued int
+	// QueueTimeout is the longest a queued caller waits (default 2s)
+	QueueTimeout time.Duration
+	// IsFailure decides which errors count against the dependency; by
+	// default every error does. Errors that are the caller's fault, such
+	// as an unknown key, should not open the breaker.
+	IsFailure func(error) bool
+	// Metrics, when set, receives the breaker's state and counters
+	Metrics *metrics.Registry
+}
+
+// Snapshot is a breaker's state for status endpoints
+type Snapshot struct {
+	Dependency          string     `json:"dependency"`
+	State               string     `json:"state"`
+	Fallback            Fallback   `json:"fallback"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	Queued              int        `json:"queued"`
+	Rejected            uint64     `json:"rejected"`
+}
+
+// Breaker guards one dependency
+type Breaker struct {
+	name string
+	cfg  Config
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+	queued   int
+	rejected uint64
+	// changed is closed and replaced whenever the state changes, waking
+	// queued callers
+	changed chan struct{}
+
+	stateGauge  *metrics.Gauge
+	rejectCount *metrics.Counter
+	transitions *metrics.CounterVec
+}
+
+// New creates a closed breaker for the named dependency
+func New(name string, cfg Config) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.Fallback == "" {
+		cfg.Fallback = FailFast
+	}
+	if cfg.MaxQueued <= 0 {
+		cfg.MaxQueued = 100
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = 2 * time.Second
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(error) bool { return true }
+	}
+	b := &Breaker{name: name, cfg: cfg, now: time.Now, changed: make(chan struct{})}
+	if cfg.Metrics != nil {
+		b.stateGauge = cfg.Metrics.Gauge("circuit_breaker_state",
+			"Circuit breaker state: 0 closed, 1 half-open, 2 open", "dependency").With(name)
+		b.rejectCount = cfg.Metrics.Counter("circuit_breaker_rejected_total",
+			"Calls turned away by an open circuit breaker", "dependency").With(name)
+		b.transitions = cfg.Metrics.Counter("circuit_breaker_transitions_total",
+			"Circuit breaker state changes", "dependency", "state")
+		b.stateGauge.Set(float64(Closed))
+	}
+	return b
+}
+
+// Name returns the dependency the breaker guards
+func (b *Breaker) Name() string { return b.name }
+
+// Do runs fn unless the breaker is open. Errors fn returns are passed back
+// unchanged; a call turned away returns ErrOpen or ErrQueueFull.
+func (b *Breaker) Do(ctx context.Context, fn func() error) error {
+	trial, err := b.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.record(trial, err == nil || !b.cfg.IsFailure(err))
+	return err
+}
+
+// acquire waits for permission to call, reporting whether the call is the
+// half-open trial
+func (b *Breaker) acquire(ctx context.Context) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var deadline <-chan time.Time
+	queued := false
+	defer func() {
+		if queued {
+			b.queued--
+		}
+	}()
+	for {
+		if b.state == Open && !b.now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+			b.setState(HalfOpen)
+		}
+		switch {
+		case b.state == Closed:
+			return false, nil
+		case b.state == HalfOpen && !b.trial:
+			b.trial = true
+			return true, nil
+		}
+
+		if b.cfg.Fallback != Queue {
+			return false, b.reject(ErrOpen)
+		}
+		if !queued {
+			if b.queued >= b.cfg.MaxQueued {
+				return false, b.reject(ErrQueueFull)
+			}
+			b.queued++
+			queued = true
+			timer := time.NewTimer(b.cfg.QueueTimeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		// Wake on a state change, when the open timeout ends or when the
+		// caller gives up
+		changed := b.changed
+		var retry <-chan time.Time
+		if b.state == Open {
+			t := time.NewTimer(b.openedAt.Add(b.cfg.OpenTimeout).Sub(b.now()))
+			defer t.Stop()
+			retry = t.C
+		}
+		b.mu.Unlock()
+		select {
+		case <-changed:
+		case <-retry:
+		case <-deadline:
+			b.mu.Lock()
+			return false, b.reject(ErrOpen)
+		case <-ctx.Done():
+			b.mu.Lock()
+			return false, ctx.Err()
+		}
+		b.mu.Lock()
+	}
+}
+
+func (b *Breaker) reject(err error) error {
+	b.rejected++
+	if b.rejectCount != nil {
+		b.rejectCount.Inc()
+	}
+	return err
+}
+
+func (b *Breaker) record(trial, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+	}
+	if ok {
+		b.failures = 0
+		if trial {
+			b.setState(Closed)
+		}
+		return
+	}
+	b.failures++
+	if trial || (b.state == Closed && b.failures >= b.cfg.FailureThreshold) {
+		b.openedAt = b.now()
+		b.setState(Open)
+	}
+}
+
+// setState must be called with mu held
+func (b *Breaker) setState(s State) {
+	if s == b.state {
+		return
+	}
+	b.state = s
+	close(b.changed)
+	b.changed = make(chan struct{})
+	if b.stateGauge != nil {
+		b.stateGauge.Set(float64(s))
+		b.transitions.With(b.name, s.String()).Inc()
+	}
+}
+
+// State returns the breaker's current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Snapshot returns the breaker's state and counters
+func (b *Breaker) Snapshot() Snapshot {
+	state := b.State()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Snapshot{
+		Dependency:          b.name,
+		State:               state.String(),
+		Fallback:            b.cfg.Fallback,
+		ConsecutiveFailures: b.failures,
+		Queued:              b.queued,
+		Rejected:            b.rejected,
+	}
+	if state != Closed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
+
+// Reset closes the breaker, for an operator who knows the dependency is back
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+	b.setState(Closed)
+}
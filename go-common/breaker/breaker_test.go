@@ -0,0 +1,142 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+var errDown = errors.New("connection refused")
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	registry := metrics.NewRegistry()
+	b := New("hsm", Config{FailureThreshold: 3, OpenTimeout: time.Minute, Metrics: registry})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	calls := 0
+	fail := func() error { calls++; return errDown }
+	for i := 0; i < 3; i++ {
+		if err := b.Do(context.Background(), fail); !errors.Is(err, errDown) {
+			t.Fatalf("call %d: error = %v", i, err)
+		}
+	}
+	if b.State() != Open {
+		t.Fatalf("State() = %v after 3 failures, want open", b.State())
+	}
+
+	// Open: the dependency is not called
+	if err := b.Do(context.Background(), fail); !errors.Is(err, ErrOpen) || calls != 3 {
+		t.Fatalf("open Do() = %v, calls %d", err, calls)
+	}
+
+	// After the timeout one trial call goes through; its failure reopens
+	now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("State() = %v after the open timeout, want half-open", b.State())
+	}
+	b.Do(context.Background(), fail)
+	if b.State() != Open || calls != 4 {
+		t.Fatalf("failed trial: state %v, calls %d", b.State(), calls)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Do(context.Background(), func() error { return nil }); err != nil || b.State() != Closed {
+		t.Fatalf("successful trial: error %v, state %v", err, b.State())
+	}
+
+	snap := b.Snapshot()
+	if snap.Dependency != "hsm" || snap.State != "closed" || snap.Rejected != 1 || snap.OpenedAt != nil {
+		t.Errorf("Snapshot() = %+v", snap)
+	}
+
+	var out strings.Builder
+	registry.WriteText(&out)
+	for _, want := range []string{
+		`circuit_breaker_state{dependency="hsm"} 0`,
+		`circuit_breaker_rejected_total{dependency="hsm"} 1`,
+		`circuit_breaker_transitions_total{dependency="hsm",state="open"} 2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestBreakerIgnoresCallerErrors(t *testing.T) {
+	errBadKey := errors.New("key not found")
+	b := New("hsm", Config{FailureThreshold: 1, IsFailure: func(err error) bool { return err != errBadKey }})
+	for i := 0; i < 3; i++ {
+		b.Do(context.Background(), func() error { return errBadKey })
+	}
+	if b.State() != Closed {
+		t.Errorf("State() = %v after caller errors, want closed", b.State())
+	}
+}
+
+func TestBreakerQueue(t *testing.T) {
+	b := New("tokenization", Config{
+		FailureThreshold: 1,
+		OpenTimeout:      50 * time.Millisecond,
+		Fallback:         Queue,
+		MaxQueued:        2,
+		QueueTimeout:     5 * time.Second,
+	})
+	b.Do(context.Background(), func() error { return errDown })
+
+	// Queued callers run once the trial call closes the breaker
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.Do(context.Background(), func() error { return nil })
+		}(i)
+	}
+	// Wait until both are queued, then a third finds the queue full
+	for deadline := time.Now().Add(time.Second); b.Snapshot().Queued < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if err := b.Do(context.Background(), func() error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("third caller error = %v, want %v", err, ErrQueueFull)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("queued caller %d error = %v", i, err)
+		}
+	}
+	if b.State() != Closed {
+		t.Errorf("State() = %v, want closed", b.State())
+	}
+
+	// A queued caller gives up when its context ends
+	b.Do(context.Background(), func() error { return errDown })
+	b.Reset()
+	if b.State() != Closed {
+		t.Fatalf("State() after Reset() = %v", b.State())
+	}
+	b.Do(context.Background(), func() error { return errDown })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := b.Do(ctx, func() error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cancelled caller error = %v", err)
+	}
+}
+
+func TestParseFallback(t *testing.T) {
+	for in, want := range map[string]Fallback{"": FailFast, "fail-fast": FailFast, "queue": Queue} {
+		if got, err := ParseFallback(in); err != nil || got != want {
+			t.Errorf("ParseFallback(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseFallback("retry"); err == nil {
+		t.Error("ParseFallback(retry) accepted")
+	}
+}
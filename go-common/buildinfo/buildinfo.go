@@ -0,0 +1,64 @@
+// Package buildinfo reports the version and commit a binary was built from.
+// Version, Commit and Date are set at link time:
+//
+//	go build -ldflags "-X github.com/paymentgateway/go-common/buildinfo.Version=1.2.0 \
+//	    -X github.com/paymentgateway/go-common/buildinfo.Commit=$(git rev-parse --short HEAD)"
+//
+// When they are not set, the commit and date are taken from the VCS stamp the
+// Go toolchain embeds in binaries built inside a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes a build
+type Info struct {
+	Version   string
+	Commit    string
+	Date      string
+	GoVersion string
+	Modified  bool
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+					if len(info.Commit) > 12 {
+						info.Commit = info.Commit[:12]
+					}
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,22 @@
+package buildinfo
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version != "dev" {
+		t.Errorf("Version = %q, want dev", info.Version)
+	}
+	if info.Commit == "" {
+		t.Error("Commit should never be empty")
+	}
+	if info.GoVersion == "" {
+		t.Error("GoVersion should be set")
+	}
+
+	Version, Commit = "1.2.0", "abc1234"
+	defer func() { Version, Commit = "dev", "" }()
+	if info := Get(); info.Version != "1.2.0" || info.Commit != "abc1234" {
+		t.Errorf("link-time values not used: %+v", info)
+	}
+}
@@ -0,0 +1,291 @@
+// Package certs serves and dials gRPC over TLS with certificates that are
+// reloaded while the process runs, so long-running simulations can rehearse
+// certificate rollover: writing a new certificate, key or CA bundle over the
+// old files takes effect on the next handshake without a restart, while
+// connections already open keep their session. Servers issue session
+// tickets and clients cache them, so reconnecting peers resume their TLS
+// session instead of running a full handshake, across rotations too.
+package certs
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/paymentgateway/go-common/reload"
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultReloadInterval is how often the files are checked for changes
+const DefaultReloadInterval = 5 * time.Second
+
+// sessionCacheSize bounds the TLS sessions a client keeps for resumption
+const sessionCacheSize = 64
+
+// Config names the PEM files of a TLS identity and of the CAs it trusts
+type Config struct {
+	// CertFile and KeyFile hold the certificate chain and private key: the
+	// server certificate of a server and the client certificate of a client.
+	// A client may go without them when servers don't ask for one.
+	CertFile string
+	KeyFile  string
+	// CAFile holds the CAs a peer's certificate must chain to. A server with
+	// one requires client certificates; a client without one trusts the
+	// system roots.
+	CAFile string
+	// ServerName overrides the host name a client checks the server
+	// certificate against
+	ServerName string
+}
+
+// Enabled reports whether any file is configured, i.e. whether TLS is used
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.CAFile != ""
+}
+
+// FromEnv reads the configuration from prefix+TLS_CERT_FILE, TLS_KEY_FILE,
+// TLS_CA_FILE and TLS_SERVER_NAME, e.g. HSM_TLS_CERT_FILE
+func FromEnv(prefix string, getenv func(string) string) (Config, error) {
+	cfg := Config{
+		CertFile:   getenv(prefix + "TLS_CERT_FILE"),
+		KeyFile:    getenv(prefix + "TLS_KEY_FILE"),
+		CAFile:     getenv(prefix + "TLS_CA_FILE"),
+		ServerName: getenv(prefix + "TLS_SERVER_NAME"),
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return Config{}, fmt.Errorf("%sTLS_CERT_FILE and %sTLS_KEY_FILE must be set together", prefix, prefix)
+	}
+	return cfg, nil
+}
+
+// Reloader holds the certificates loaded from a Config and reloads them
+// when the files change
+type Reloader struct {
+	cfg   Config
+	audit func(reload.Event)
+	now   func() time.Time
+
+	// server is the config servers handshake with. It only picks the
+	// current certificates per handshake, so the session ticket keys it
+	// holds survive rotations.
+	server   *tls.Config
+	sessions tls.ClientSessionCache
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	roots  *x509.CertPool
+	digest string
+}
+
+// NewReloader loads the files of cfg. audit receives every reload attempt,
+// applied or rejected, and may be nil.
+func NewReloader(cfg Config, audit func(reload.Event)) (*Reloader, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("no TLS certificate or CA file configured")
+	}
+	if audit == nil {
+		audit = func(reload.Event) {}
+	}
+	r := &Reloader{
+		cfg:      cfg,
+		audit:    audit,
+		now:      time.Now,
+		sessions: tls.NewLRUClientSessionCache(sessionCacheSize),
+	}
+	r.server = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.serverConfig,
+	}
+	if err := r.Load(reload.TriggerStartup); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// path is the file events are reported under
+func (r *Reloader) path() string {
+	if r.cfg.CertFile != "" {
+		return r.cfg.CertFile
+	}
+	return r.cfg.CAFile
+}
+
+// Load reads the files and, if they are all valid, swaps them in. A
+// file-change trigger skips content that was already loaded. On an error
+// the previous certificates stay in use, so a rotation that has written the
+// new certificate but not yet its key is picked up once both are in place.
+func (r *Reloader) Load(trigger reload.Trigger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event := reload.Event{Time: r.now(), Path: r.path(), Trigger: trigger}
+	if info, err := os.Stat(event.Path); err == nil {
+		event.Owner = reload.FileOwner(info)
+	}
+	hash := sha256.New()
+	var data [3][]byte
+	for i, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			event.Err = err
+			r.audit(event)
+			return err
+		}
+		data[i] = b
+		hash.Write(b)
+	}
+	event.Digest = hex.EncodeToString(hash.Sum(nil)[:6])
+	if trigger == reload.TriggerFileChange && event.Digest == r.digest {
+		return nil
+	}
+	r.digest = event.Digest
+
+	var cert *tls.Certificate
+	if data[0] != nil {
+		c, err := tls.X509KeyPair(data[0], data[1])
+		if err != nil {
+			event.Err = fmt.Errorf("certificate %s: %w", r.cfg.CertFile, err)
+			r.audit(event)
+			return event.Err
+		}
+		if c.Leaf == nil {
+			if c.Leaf, err = x509.ParseCertificate(c.Certificate[0]); err != nil {
+				event.Err = fmt.Errorf("certificate %s: %w", r.cfg.CertFile, err)
+				r.audit(event)
+				return event.Err
+			}
+		}
+		cert = &c
+		if !cert.Leaf.NotAfter.After(event.Time) {
+			event.Err = fmt.Errorf("certificate %s expired at %s", r.cfg.CertFile, cert.Leaf.NotAfter.Format(time.RFC3339))
+			r.audit(event)
+			return event.Err
+		}
+		event.Changes = append(event.Changes, fmt.Sprintf("certificate %s serial %s valid until %s",
+			cert.Leaf.Subject.CommonName, cert.Leaf.SerialNumber, cert.Leaf.NotAfter.Format(time.RFC3339)))
+	}
+	var roots *x509.CertPool
+	if data[2] != nil {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(data[2]) {
+			event.Err = fmt.Errorf("CA file %s holds no certificates", r.cfg.CAFile)
+			r.audit(event)
+			return event.Err
+		}
+		event.Changes = append(event.Changes, "CAs loaded from "+r.cfg.CAFile)
+	}
+
+	r.cert, r.roots = cert, roots
+	r.audit(event)
+	return nil
+}
+
+// Run reloads on SIGHUP and checks the files for changes every interval
+// until ctx is cancelled
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Load(reload.TriggerSignal)
+		case <-ticker.C:
+			r.Load(reload.TriggerFileChange)
+		}
+	}
+}
+
+// Certificate returns the certificate in use, or nil for a client without one
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+func (r *Reloader) serverConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, errors.New("no server certificate configured")
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2"},
+	}
+	if r.roots != nil {
+		cfg.ClientCAs = r.roots
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ServerTLSConfig returns the TLS configuration of a server
+func (r *Reloader) ServerTLSConfig() *tls.Config {
+	return r.server
+}
+
+// ClientTLSConfig returns a TLS configuration of a client holding the
+// current CAs. It shares the reloader's session cache and always presents
+// the current client certificate.
+func (r *Reloader) ClientTLSConfig() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		RootCAs:            r.roots,
+		ServerName:         r.cfg.ServerName,
+		ClientSessionCache: r.sessions,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := r.Certificate(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+	}
+}
+
+// ServerCredentials returns the credentials of a gRPC server
+func (r *Reloader) ServerCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(r.server)
+}
+
+// ClientCredentials returns the credentials of a gRPC client. Each
+// handshake uses the CAs current at the time.
+func (r *Reloader) ClientCredentials() credentials.TransportCredentials {
+	return &clientCredentials{TransportCredentials: credentials.NewTLS(r.ClientTLSConfig()), reloader: r}
+}
+
+// clientCredentials builds the TLS credentials afresh for every handshake,
+// since gRPC's own keep the CAs they were created with
+type clientCredentials struct {
+	credentials.TransportCredentials
+	reloader *Reloader
+}
+
+func (c *clientCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(c.reloader.ClientTLSConfig()).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *clientCredentials) Clone() credentials.TransportCredentials {
+	return &clientCredentials{TransportCredentials: c.TransportCredentials.Clone(), reloader: c.reloader}
+}
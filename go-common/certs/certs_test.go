@@ -0,0 +1,245 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/reload"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testCA issues certificates for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate with the serial and its key to dir
+func (ca *testCA) issue(t *testing.T, dir string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "node"},
+		DNSNames:     []string{"node.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newReloader issues a certificate with the serial into a fresh directory
+// and loads it with the CA
+func newReloader(t *testing.T, ca *testCA, serial int64) (*Reloader, string) {
+	t.Helper()
+	dir := t.TempDir()
+	certFile, keyFile := ca.issue(t, dir, serial)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+	r, err := NewReloader(Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ServerName: "node.test"}, nil)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	return r, dir
+}
+
+// handshake is the outcome of one call on a new connection
+type handshake struct {
+	serverSerial int64
+	clientSerial int64
+	resumed      bool
+}
+
+// serve starts a health server with the server reloader's credentials and
+// returns a function calling it on a new connection
+func serve(t *testing.T, server *Reloader) func(client *Reloader) (handshake, error) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	clientSerials := make(chan int64, 1)
+	srv := grpc.NewServer(grpc.Creds(server.ServerCredentials()),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			p, _ := peer.FromContext(ctx)
+			clientSerials <- p.AuthInfo.(credentials.TLSInfo).State.PeerCertificates[0].SerialNumber.Int64()
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return func(client *Reloader) (handshake, error) {
+		conn, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(client.ClientCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+		if err != nil {
+			return handshake{}, err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var p peer.Peer
+		if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p)); err != nil {
+			return handshake{}, err
+		}
+		state := p.AuthInfo.(credentials.TLSInfo).State
+		return handshake{
+			serverSerial: state.PeerCertificates[0].SerialNumber.Int64(),
+			clientSerial: <-clientSerials,
+			resumed:      state.DidResume,
+		}, nil
+	}
+}
+
+func TestCertificateRotation(t *testing.T) {
+	ca := newTestCA(t)
+	server, serverDir := newReloader(t, ca, 1)
+	client, clientDir := newReloader(t, ca, 100)
+	call := serve(t, server)
+
+	got, err := call(client)
+	if err != nil {
+		t.Fatalf("call error = %v", err)
+	}
+	if want := (handshake{serverSerial: 1, clientSerial: 100}); got != want {
+		t.Errorf("first handshake = %+v, want %+v", got, want)
+	}
+
+	// Both sides rotate; the next full handshake presents the new
+	// certificates. The client drops its sessions, which would resume with
+	// the old client certificate.
+	ca.issue(t, serverDir, 2)
+	ca.issue(t, clientDir, 101)
+	for _, r := range []*Reloader{server, client} {
+		if err := r.Load(reload.TriggerFileChange); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+	}
+	fresh, _ := newReloader(t, ca, 200)
+	if got, err := call(fresh); err != nil || got.serverSerial != 2 {
+		t.Errorf("handshake after server rotation = %+v, %v, want server serial 2", got, err)
+	}
+	client.sessions = tls.NewLRUClientSessionCache(1)
+	if got, err := call(client); err != nil || got.clientSerial != 101 {
+		t.Errorf("handshake after client rotation = %+v, %v, want client serial 101", got, err)
+	}
+
+	// A certificate whose key has not been written yet is rejected and the
+	// previous one stays in use
+	next, _ := ca.issue(t, t.TempDir(), 3)
+	data, _ := os.ReadFile(next)
+	writeFile(t, filepath.Join(serverDir, "tls.crt"), data)
+	if err := server.Load(reload.TriggerFileChange); err == nil {
+		t.Error("Load() of a certificate not matching its key succeeded")
+	}
+	fresh, _ = newReloader(t, ca, 201)
+	if got, err := call(fresh); err != nil || got.serverSerial != 2 {
+		t.Errorf("handshake after a rejected rotation = %+v, %v, want server serial 2", got, err)
+	}
+}
+
+func TestSessionResumption(t *testing.T) {
+	ca := newTestCA(t)
+	server, serverDir := newReloader(t, ca, 1)
+	client, _ := newReloader(t, ca, 100)
+	call := serve(t, server)
+
+	if got, err := call(client); err != nil || got.resumed {
+		t.Fatalf("first handshake = %+v, %v, want a full handshake", got, err)
+	}
+	if got, err := call(client); err != nil || !got.resumed {
+		t.Errorf("second handshake = %+v, %v, want it resumed", got, err)
+	}
+
+	// Sessions outlive a rotation of the server certificate
+	ca.issue(t, serverDir, 2)
+	if err := server.Load(reload.TriggerFileChange); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got, err := call(client); err != nil || !got.resumed {
+		t.Errorf("handshake after rotation = %+v, %v, want it resumed", got, err)
+	}
+}
+
+func TestUntrustedPeers(t *testing.T) {
+	server, _ := newReloader(t, newTestCA(t), 1)
+	call := serve(t, server)
+	stranger, _ := newReloader(t, newTestCA(t), 100)
+	if _, err := call(stranger); err == nil {
+		t.Error("call with certificates of another CA succeeded")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	cfg, err := FromEnv("HSM_", env(map[string]string{"HSM_TLS_CERT_FILE": "a.crt", "HSM_TLS_KEY_FILE": "a.key"}))
+	if err != nil || !cfg.Enabled() || cfg.KeyFile != "a.key" {
+		t.Errorf("FromEnv() = %+v, %v", cfg, err)
+	}
+	if cfg, _ := FromEnv("HSM_", env(nil)); cfg.Enabled() {
+		t.Error("FromEnv() without variables enabled TLS")
+	}
+	if _, err := FromEnv("HSM_", env(map[string]string{"HSM_TLS_CERT_FILE": "a.crt"})); err == nil {
+		t.Error("FromEnv() with a certificate but no key succeeded")
+	}
+}
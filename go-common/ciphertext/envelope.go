@@ -0,0 +1,220 @@
+// Package ciphertext defines the self-describing ciphertext envelope shared
+// by the HSM, which produces and opens envelopes, and the services that
+// store them. An envelope carries everything needed to decrypt it except
+// the key and the AAD, so a stored ciphertext survives key rotations and
+// migrations without separate nonce or key version columns.
+//
+// The layout, all lengths in bytes and integers big-endian:
+//
+//	magic       4  "PGCE"
+//	format      1  Version
+//	algorithm   1  see Algorithm
+//	flags       1  see Flags; absent in format 1
+//	key ID      1 + n
+//	key version 4
+//	nonce       1 + n
+//	tag         1 + n
+//	ciphertext  the rest
+package ciphertext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrInvalidEnvelope     = errors.New("invalid ciphertext envelope")
+	ErrUnsupportedEnvelope = errors.New("unsupported ciphertext envelope")
+)
+
+// Magic starts every envelope
+const Magic = "PGCE"
+
+// Version is the envelope format this package writes. Format 1, without
+// flags, is still read.
+const Version = 2
+
+// Algorithm identifies the cipher an envelope was sealed with
+type Algorithm uint8
+
+const (
+	AlgorithmAES256GCM Algorithm = 1
+)
+
+func (a Algorithm) String() string {
+	if a == AlgorithmAES256GCM {
+		return "AES-256-GCM"
+	}
+	return fmt.Sprintf("algorithm(%d)", uint8(a))
+}
+
+// ParseAlgorithm returns the algorithm with the HSM's name for it
+func ParseAlgorithm(name string) (Algorithm, error) {
+	if name == "AES-256-GCM" {
+		return AlgorithmAES256GCM, nil
+	}
+	return 0, fmt.Errorf("%w: algorithm %q", ErrUnsupportedEnvelope, name)
+}
+
+// Flags record how the plaintext was transformed before encryption
+type Flags uint8
+
+const (
+	// FlagCompressed marks a plaintext DEFLATE-compressed before encryption
+	FlagCompressed Flags = 1 << iota
+)
+
+// knownFlags are the flags this package understands
+const knownFlags = FlagCompressed
+
+// Envelope is a ciphertext with the key, algorithm and nonce that sealed it
+type Envelope struct {
+	KeyID      string
+	KeyVersion int
+	Algorithm  Algorithm
+	Flags      Flags
+	Nonce      []byte
+	Tag        []byte
+	Ciphertext []byte
+}
+
+// sizes are the nonce and tag sizes of each algorithm
+var sizes = map[Algorithm]struct{ nonce, tag int }{
+	AlgorithmAES256GCM: {nonce: 12, tag: 16},
+}
+
+// Split builds an envelope from a ciphertext with the tag appended, as GCM
+// seals it
+func Split(keyID string, keyVersion int, alg Algorithm, nonce, sealed []byte) (Envelope, error) {
+	size, ok := sizes[alg]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %s", ErrUnsupportedEnvelope, alg)
+	}
+	if len(sealed) < size.tag {
+		return Envelope{}, fmt.Errorf("%w: ciphertext shorter than its tag", ErrInvalidEnvelope)
+	}
+	split := len(sealed) - size.tag
+	return Envelope{
+		KeyID:      keyID,
+		KeyVersion: keyVersion,
+		Algorithm:  alg,
+		Nonce:      nonce,
+		Tag:        sealed[split:],
+		Ciphertext: sealed[:split:split],
+	}, nil
+}
+
+// Sealed returns the ciphertext with the tag appended, as GCM opens it
+func (e Envelope) Sealed() []byte {
+	sealed := make([]byte, 0, len(e.Ciphertext)+len(e.Tag))
+	return append(append(sealed, e.Ciphertext...), e.Tag...)
+}
+
+// Marshal encodes the envelope
+func (e Envelope) Marshal() ([]byte, error) {
+	if _, ok := sizes[e.Algorithm]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEnvelope, e.Algorithm)
+	}
+	if len(e.KeyID) > math.MaxUint8 || int64(e.KeyVersion) > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: key ID over 255 bytes or key version over 2^32", ErrInvalidEnvelope)
+	}
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(Magic)+3+1+len(e.KeyID)+4+1+len(e.Nonce)+1+len(e.Tag)+len(e.Ciphertext))
+	b = append(b, Magic...)
+	b = append(b, Version, byte(e.Algorithm), byte(e.Flags))
+	b = append(b, byte(len(e.KeyID)))
+	b = append(b, e.KeyID...)
+	b = binary.BigEndian.AppendUint32(b, uint32(e.KeyVersion))
+	b = append(b, byte(len(e.Nonce)))
+	b = append(b, e.Nonce...)
+	b = append(b, byte(len(e.Tag)))
+	b = append(b, e.Tag...)
+	return append(b, e.Ciphertext...), nil
+}
+
+// IsEnvelope reports whether b starts like an envelope. Only use it on
+// data that is either an envelope or known not to start with Magic, such
+// as the ciphertext of a record that also stores its nonce.
+func IsEnvelope(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(Magic))
+}
+
+// Parse decodes an envelope. The returned slices share b's memory.
+func Parse(b []byte) (Envelope, error) {
+	if !IsEnvelope(b) {
+		return Envelope{}, fmt.Errorf("%w: missing magic", ErrInvalidEnvelope)
+	}
+	r := reader{b: b[len(Magic):]}
+	var e Envelope
+	format := r.byte()
+	if r.err == nil && format != 1 && format != Version {
+		return Envelope{}, fmt.Errorf("%w: format %d", ErrUnsupportedEnvelope, format)
+	}
+	e.Algorithm = Algorithm(r.byte())
+	if format >= 2 {
+		e.Flags = Flags(r.byte())
+	}
+	e.KeyID = string(r.field())
+	e.KeyVersion = int(binary.BigEndian.Uint32(r.next(4)))
+	e.Nonce = r.field()
+	e.Tag = r.field()
+	if r.err != nil {
+		return Envelope{}, r.err
+	}
+	e.Ciphertext = r.b
+
+	if _, ok := sizes[e.Algorithm]; !ok {
+		return Envelope{}, fmt.Errorf("%w: %s", ErrUnsupportedEnvelope, e.Algorithm)
+	}
+	if err := e.check(); err != nil {
+		return Envelope{}, err
+	}
+	return e, nil
+}
+
+// check validates the fields of an envelope of a supported algorithm
+func (e Envelope) check() error {
+	size := sizes[e.Algorithm]
+	switch {
+	case e.Flags&^knownFlags != 0:
+		return fmt.Errorf("%w: flags %#x", ErrUnsupportedEnvelope, uint8(e.Flags))
+	case e.KeyID == "":
+		return fmt.Errorf("%w: empty key ID", ErrInvalidEnvelope)
+	case e.KeyVersion < 1:
+		return fmt.Errorf("%w: key version %d", ErrInvalidEnvelope, e.KeyVersion)
+	case len(e.Nonce) != size.nonce || len(e.Tag) != size.tag:
+		return fmt.Errorf("%w: %s needs a %d-byte nonce and a %d-byte tag", ErrInvalidEnvelope, e.Algorithm, size.nonce, size.tag)
+	}
+	return nil
+}
+
+// reader consumes b, recording the first overrun
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidEnvelope)
+		return make([]byte, n)
+	}
+	out := r.b[:n:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *reader) byte() byte {
+	return r.next(1)[0]
+}
+
+// field reads a one-byte length and that many bytes
+func (r *reader) field() []byte {
+	return r.next(int(r.byte()))
+}
@@ -0,0 +1,87 @@
+package ciphertext
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	sealed := append([]byte("ciphertext"), bytes.Repeat([]byte{0xAA}, 16)...)
+	e, err := Split("tokenization-key", 3, AlgorithmAES256GCM, bytes.Repeat([]byte{1}, 12), sealed)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	e.Flags = FlagCompressed
+	b, err := e.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !IsEnvelope(b) {
+		t.Error("IsEnvelope() = false for a marshalled envelope")
+	}
+
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("Parse() = %+v, want %+v", got, e)
+	}
+	if !bytes.Equal(got.Sealed(), sealed) {
+		t.Errorf("Sealed() = %x, want %x", got.Sealed(), sealed)
+	}
+}
+
+func TestParseRejectsMalformedEnvelopes(t *testing.T) {
+	e := Envelope{KeyID: "k", KeyVersion: 1, Algorithm: AlgorithmAES256GCM, Nonce: make([]byte, 12), Tag: make([]byte, 16), Ciphertext: []byte("x")}
+	valid, _ := e.Marshal()
+
+	unknownFormat := append([]byte(nil), valid...)
+	unknownFormat[4] = 9
+	unknownAlgorithm := append([]byte(nil), valid...)
+	unknownAlgorithm[5] = 9
+	unknownFlag := append([]byte(nil), valid...)
+	unknownFlag[6] = 0x80
+
+	tests := []struct {
+		name string
+		b    []byte
+		want error
+	}{
+		{"no magic", []byte("not an envelope"), ErrInvalidEnvelope},
+		{"truncated", valid[:20], ErrInvalidEnvelope},
+		{"header only", valid[:6], ErrInvalidEnvelope},
+		{"unknown format", unknownFormat, ErrUnsupportedEnvelope},
+		{"unknown algorithm", unknownAlgorithm, ErrUnsupportedEnvelope},
+		{"unknown flag", unknownFlag, ErrUnsupportedEnvelope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.b); !errors.Is(err, tt.want) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	e.KeyVersion = 0
+	if _, err := e.Marshal(); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Marshal() with version 0 error = %v", err)
+	}
+}
+
+func TestParseFormat1(t *testing.T) {
+	e := Envelope{KeyID: "k", KeyVersion: 2, Algorithm: AlgorithmAES256GCM, Nonce: make([]byte, 12), Tag: make([]byte, 16), Ciphertext: []byte("x")}
+	b, _ := e.Marshal()
+	// Format 1 had no flags byte
+	b = append(append([]byte(Magic), 1, byte(AlgorithmAES256GCM)), b[7:]...)
+
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("Parse() = %+v, want %+v", got, e)
+	}
+}
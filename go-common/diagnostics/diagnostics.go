@@ -0,0 +1,402 @@
+// Package diagnostics serves the Go services' profiling endpoints on an
+// internal port: the net/http/pprof handlers, and an admin API that captures
+// a CPU profile, heap profile or goroutine dump into a directory on demand,
+// so a load test can take one mid-run and collect it afterwards. It also
+// exports runtime metrics: goroutines, threads, heap and GC pauses.
+//
+// The port serves profiles and stack traces without authentication; bind it
+// to localhost or an internal network only.
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+// Defaults
+const (
+	DefaultCPUProfile    = 30 * time.Second
+	DefaultMaxCPUProfile = 5 * time.Minute
+)
+
+// Capture kinds
+const (
+	KindCPU        = "cpu"
+	KindHeap       = "heap"
+	KindGoroutines = "goroutines"
+)
+
+// ErrProfiling is returned when a CPU profile is requested while another,
+// from the admin API or /debug/pprof/profile, is running
+var ErrProfiling = errors.New("a CPU profile is already running")
+
+// Config configures the diagnostics port. Zero fields take the defaults.
+type Config struct {
+	// Addr is the address to listen on, e.g. 127.0.0.1:6060. Empty
+	// disables the port.
+	Addr string
+	// Dir receives the captures (default a directory named after the
+	// service under os.TempDir())
+	Dir string
+	// MaxCPUProfile bounds the length of a captured CPU profile (default 5m)
+	MaxCPUProfile time.Duration
+}
+
+// FromEnv reads the configuration from prefix+DIAGNOSTICS_ADDR,
+// prefix+DIAGNOSTICS_DIR and prefix+DIAGNOSTICS_MAX_CPU_PROFILE
+func FromEnv(prefix string, getenv func(string) string) (Config, error) {
+	cfg := Config{
+		Addr: getenv(prefix + "DIAGNOSTICS_ADDR"),
+		Dir:  getenv(prefix + "DIAGNOSTICS_DIR"),
+	}
+	if v := getenv(prefix + "DIAGNOSTICS_MAX_CPU_PROFILE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid %sDIAGNOSTICS_MAX_CPU_PROFILE: %q", prefix, v)
+		}
+		cfg.MaxCPUProfile = d
+	}
+	return cfg, nil
+}
+
+// Capture describes a captured profile or dump
+type Capture struct {
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	Started time.Time `json:"started"`
+	// Done is zero while a CPU profile is still being recorded
+	Done  time.Time `json:"done,omitempty"`
+	Bytes int64     `json:"bytes"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Capturer writes captures of the running process to a directory
+type Capturer struct {
+	service string
+	dir     string
+	max     time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	captures map[string]*Capture
+	// profiling is set while one of the capturer's CPU profiles records
+	profiling bool
+}
+
+// NewCapturer creates a capturer for the named service, creating its
+// directory
+func NewCapturer(service string, cfg Config) (*Capturer, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), service+"-diagnostics")
+	}
+	if cfg.MaxCPUProfile <= 0 {
+		cfg.MaxCPUProfile = DefaultMaxCPUProfile
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("create diagnostics directory: %w", err)
+	}
+	return &Capturer{
+		service:  service,
+		dir:      cfg.Dir,
+		max:      cfg.MaxCPUProfile,
+		now:      time.Now,
+		captures: make(map[string]*Capture),
+	}, nil
+}
+
+// Dir returns the directory captures are written to
+func (c *Capturer) Dir() string { return c.dir }
+
+// create opens the file of a new capture of kind. Captures started in the
+// same millisecond get a numbered suffix.
+func (c *Capturer) create(kind, ext string) (*Capture, *os.File, error) {
+	started := c.now()
+	base := fmt.Sprintf("%s-%s-%s", c.service, kind, started.UTC().Format("20060102T150405.000"))
+	var (
+		name string
+		f    *os.File
+		err  error
+	)
+	for n := 1; ; n++ {
+		name = base + "." + ext
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d.%s", base, n, ext)
+		}
+		f, err = os.OpenFile(filepath.Join(c.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	capture := &Capture{Name: name, Kind: kind, Started: started}
+	c.mu.Lock()
+	c.captures[name] = capture
+	c.mu.Unlock()
+	return capture, f, nil
+}
+
+// finish closes a capture's file and records its outcome
+func (c *Capturer) finish(capture *Capture, f *os.File, err error) Capture {
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	info, statErr := os.Stat(f.Name())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	capture.Done = c.now()
+	if statErr == nil {
+		capture.Bytes = info.Size()
+	}
+	if err != nil {
+		capture.Error = err.Error()
+	}
+	return *capture
+}
+
+// CPU starts recording a CPU profile for d, capped at the configured
+// maximum, and returns the capture while it is being recorded
+func (c *Capturer) CPU(d time.Duration) (Capture, error) {
+	d = min(d, c.max)
+	// Claim the profiler before creating a file, so a concurrent request
+	// fails with ErrProfiling rather than on the file
+	c.mu.Lock()
+	if c.profiling {
+		c.mu.Unlock()
+		return Capture{}, ErrProfiling
+	}
+	c.profiling = true
+	c.mu.Unlock()
+	release := func() {
+		c.mu.Lock()
+		c.profiling = false
+		c.mu.Unlock()
+	}
+
+	capture, f, err := c.create(KindCPU, "pprof")
+	if err != nil {
+		release()
+		return Capture{}, err
+	}
+	if err := rpprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		c.mu.Lock()
+		delete(c.captures, capture.Name)
+		c.profiling = false
+		c.mu.Unlock()
+		return Capture{}, ErrProfiling
+	}
+	log.Printf("Recording a %s CPU profile to %s", d, f.Name())
+	go func() {
+		time.Sleep(d)
+		rpprof.StopCPUProfile()
+		c.finish(capture, f, nil)
+		release()
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *capture, nil
+}
+
+// Heap writes a heap profile as of the last garbage collection
+func (c *Capturer) Heap() (Capture, error) {
+	capture, f, err := c.create(KindHeap, "pprof")
+	if err != nil {
+		return Capture{}, err
+	}
+	return c.finish(capture, f, rpprof.Lookup("heap").WriteTo(f, 0)), nil
+}
+
+// Goroutines writes the stack of every goroutine, in the format of a panic
+func (c *Capturer) Goroutines() (Capture, error) {
+	capture, f, err := c.create(KindGoroutines, "txt")
+	if err != nil {
+		return Capture{}, err
+	}
+	return c.finish(capture, f, rpprof.Lookup("goroutine").WriteTo(f, 2)), nil
+}
+
+// List returns the captures taken since the process started, oldest first
+func (c *Capturer) List() []Capture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]Capture, 0, len(c.captures))
+	for _, capture := range c.captures {
+		list = append(list, *capture)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// Handler serves /debug/pprof/ and the admin API:
+//
+//	POST /debug/captures/cpu?seconds=N  start a CPU profile (202)
+//	POST /debug/captures/heap           write a heap profile (201)
+//	POST /debug/captures/goroutines     write a goroutine dump (201)
+//	GET  /debug/captures                list the captures
+//	GET  /debug/captures/<name>         download a capture
+func (c *Capturer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/captures", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, c.List())
+	})
+	mux.HandleFunc("/debug/captures/", c.serveCapture)
+	return mux
+}
+
+func (c *Capturer) serveCapture(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/captures/")
+	if r.Method == http.MethodGet {
+		c.mu.Lock()
+		_, ok := c.captures[name]
+		c.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeFile(w, r, filepath.Join(c.dir, name))
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		capture Capture
+		err     error
+		code    = http.StatusCreated
+	)
+	switch name {
+	case KindCPU:
+		d := DefaultCPUProfile
+		if v := r.URL.Query().Get("seconds"); v != "" {
+			n, convErr := strconv.Atoi(v)
+			if convErr != nil || n <= 0 {
+				http.Error(w, fmt.Sprintf("invalid seconds %q", v), http.StatusBadRequest)
+				return
+			}
+			d = time.Duration(n) * time.Second
+		}
+		capture, err = c.CPU(d)
+		code = http.StatusAccepted
+	case KindHeap:
+		capture, err = c.Heap()
+	case KindGoroutines:
+		capture, err = c.Goroutines()
+	default:
+		http.Error(w, fmt.Sprintf("unknown capture %q: want cpu, heap or goroutines", name), http.StatusNotFound)
+		return
+	}
+	switch {
+	case errors.Is(err, ErrProfiling):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, code, capture)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Serve starts the diagnostics port of the named service in the background
+// and returns its capturer, or nil when cfg.Addr is empty
+func Serve(service string, cfg Config) (*Capturer, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	c, err := NewCapturer(service, cfg)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := http.ListenAndServe(cfg.Addr, c.Handler()); err != nil {
+			log.Printf("Diagnostics server stopped: %v", err)
+		}
+	}()
+	return c, nil
+}
+
+// memStatsMaxAge is how long a runtime.MemStats snapshot answers scrapes;
+// reading one stops the world, so a scrape reads it once for all gauges
+const memStatsMaxAge = time.Second
+
+// RegisterRuntimeMetrics exports the process's goroutines, threads, heap and
+// GC pauses to registry
+func RegisterRuntimeMetrics(registry *metrics.Registry) {
+	var (
+		mu   sync.Mutex
+		ms   runtime.MemStats
+		read time.Time
+	)
+	stat := func(fn func(*runtime.MemStats) float64) func() float64 {
+		return func() float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			if time.Since(read) > memStatsMaxAge {
+				runtime.ReadMemStats(&ms)
+				read = time.Now()
+			}
+			return fn(&ms)
+		}
+	}
+	registry.GaugeFunc("go_goroutines", "Goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	registry.GaugeFunc("go_threads", "OS threads created.", func() float64 {
+		return float64(rpprof.Lookup("threadcreate").Count())
+	})
+	registry.GaugeFunc("go_memstats_heap_alloc_bytes", "Heap bytes allocated and still in use.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.HeapAlloc) }))
+	registry.GaugeFunc("go_memstats_heap_inuse_bytes", "Heap bytes in in-use spans.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.HeapInuse) }))
+	registry.GaugeFunc("go_memstats_heap_objects", "Allocated heap objects.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.HeapObjects) }))
+	registry.GaugeFunc("go_memstats_sys_bytes", "Bytes obtained from the OS.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.Sys) }))
+	registry.GaugeFunc("go_memstats_next_gc_bytes", "Heap size at which the next GC runs.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.NextGC) }))
+	registry.GaugeFunc("go_gc_cycles_total", "Completed GC cycles.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.NumGC) }))
+	registry.GaugeFunc("go_gc_pause_seconds_total", "Stop-the-world GC pause time.",
+		stat(func(ms *runtime.MemStats) float64 { return float64(ms.PauseTotalNs) / 1e9 }))
+	registry.GaugeFunc("go_gc_last_pause_seconds", "Duration of the last GC pause.",
+		stat(func(ms *runtime.MemStats) float64 {
+			if ms.NumGC == 0 {
+				return 0
+			}
+			return float64(ms.PauseNs[(ms.NumGC+255)%256]) / 1e9
+		}))
+}
@@ -0,0 +1,190 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+func newServer(t *testing.T) (*Capturer, *httptest.Server) {
+	t.Helper()
+	c, err := NewCapturer("hsm", Config{Dir: t.TempDir(), MaxCPUProfile: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewCapturer() error = %v", err)
+	}
+	srv := httptest.NewServer(c.Handler())
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func post(t *testing.T, url string) (int, Capture) {
+	t.Helper()
+	resp, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var capture Capture
+	json.NewDecoder(resp.Body).Decode(&capture)
+	return resp.StatusCode, capture
+}
+
+func TestGoroutineDump(t *testing.T) {
+	_, srv := newServer(t)
+	code, capture := post(t, srv.URL+"/debug/captures/goroutines")
+	if code != http.StatusCreated || capture.Kind != KindGoroutines || capture.Bytes == 0 || capture.Done.IsZero() {
+		t.Fatalf("POST goroutines = %d, %+v", code, capture)
+	}
+
+	resp, err := http.Get(srv.URL + "/debug/captures/" + capture.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(dump), "goroutine ") || !strings.Contains(string(dump), "TestGoroutineDump") {
+		t.Errorf("dump lacks this test's stack:\n%.500s", dump)
+	}
+
+	resp, err = http.Get(srv.URL + "/debug/captures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Capture
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list) != 1 || list[0].Name != capture.Name {
+		t.Errorf("GET captures = %+v", list)
+	}
+}
+
+func TestCPUProfile(t *testing.T) {
+	c, srv := newServer(t)
+	code, capture := post(t, srv.URL+"/debug/captures/cpu?seconds=60")
+	if code != http.StatusAccepted || !capture.Done.IsZero() {
+		t.Fatalf("POST cpu = %d, %+v", code, capture)
+	}
+	// One CPU profile runs at a time
+	if code, _ := post(t, srv.URL+"/debug/captures/cpu"); code != http.StatusConflict {
+		t.Errorf("second POST cpu = %d, want %d", code, http.StatusConflict)
+	}
+
+	// The 60s asked for are capped at the configured 100ms
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list := c.List()
+		if !list[0].Done.IsZero() {
+			if list[0].Bytes == 0 || list[0].Error != "" {
+				t.Errorf("CPU profile = %+v", list[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("CPU profile still recording")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConcurrentCaptures(t *testing.T) {
+	c, _ := newServer(t)
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// Requests in the same millisecond: one CPU profile records, the other
+	// is refused as a profile already running
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := c.CPU(time.Minute)
+			errs <- err
+		}()
+	}
+	started := 0
+	for i := 0; i < cap(errs); i++ {
+		switch err := <-errs; {
+		case err == nil:
+			started++
+		case !errors.Is(err, ErrProfiling):
+			t.Errorf("concurrent CPU() error = %v, want %v", err, ErrProfiling)
+		}
+	}
+	if started != 1 {
+		t.Errorf("%d CPU profiles started, want 1", started)
+	}
+
+	// Other captures in the same millisecond get their own files
+	first, err := c.Goroutines()
+	if err != nil {
+		t.Fatalf("Goroutines() error = %v", err)
+	}
+	second, err := c.Goroutines()
+	if err != nil || second.Name == first.Name {
+		t.Errorf("second Goroutines() = %+v, %v; want a capture of its own", second, err)
+	}
+
+	// The profiler is process-wide, so leave it free for the other tests
+	recording := func() bool {
+		for _, capture := range c.List() {
+			if capture.Kind == KindCPU && capture.Done.IsZero() {
+				return true
+			}
+		}
+		return false
+	}
+	for deadline := time.Now().Add(5 * time.Second); recording() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCaptureErrors(t *testing.T) {
+	_, srv := newServer(t)
+	for url, want := range map[string]int{
+		"/debug/captures/flame":         http.StatusNotFound,
+		"/debug/captures/cpu?seconds=x": http.StatusBadRequest,
+	} {
+		if code, _ := post(t, srv.URL+url); code != want {
+			t.Errorf("POST %s = %d, want %d", url, code, want)
+		}
+	}
+	// Only captures taken are served, not arbitrary files
+	resp, err := http.Get(srv.URL + "/debug/captures/..%2f..%2fetc%2fpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET of an unknown capture = %d", resp.StatusCode)
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	RegisterRuntimeMetrics(registry)
+	var b strings.Builder
+	registry.WriteText(&b)
+	for _, name := range []string{"go_goroutines ", "go_memstats_heap_alloc_bytes ", "go_gc_pause_seconds_total "} {
+		if !strings.Contains(b.String(), name) {
+			t.Errorf("metrics lack %s:\n%s", name, b.String())
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	env := map[string]string{"HSM_DIAGNOSTICS_ADDR": "127.0.0.1:6444", "HSM_DIAGNOSTICS_MAX_CPU_PROFILE": "1m"}
+	cfg, err := FromEnv("HSM_", func(k string) string { return env[k] })
+	if err != nil || cfg.Addr != "127.0.0.1:6444" || cfg.MaxCPUProfile != time.Minute {
+		t.Errorf("FromEnv() = %+v, %v", cfg, err)
+	}
+	env["HSM_DIAGNOSTICS_MAX_CPU_PROFILE"] = "forever"
+	if _, err := FromEnv("HSM_", func(k string) string { return env[k] }); err == nil {
+		t.Error("FromEnv() accepted an invalid duration")
+	}
+}
@@ -0,0 +1,227 @@
+// Package dukpt implements TDES Derived Unique Key Per Transaction (ANSI
+// X9.24-1:2009) for point-to-point encryption. A terminal is injected with
+// an initial key (IPEK) derived from the base derivation key (BDK) and its
+// key serial number (KSN); it derives a fresh key for every transaction,
+// and the host re-derives the same key from the BDK and the KSN sent along
+// with the ciphertext. Compromising one transaction key reveals neither the
+// keys of earlier transactions nor the BDK.
+package dukpt
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrInvalidKey        = errors.New("DUKPT keys must be 16 bytes")
+	ErrInvalidKSN        = errors.New("key serial number must be 10 bytes")
+	ErrKSNExhausted      = errors.New("DUKPT transaction counter exhausted")
+	ErrInvalidCiphertext = errors.New("DUKPT ciphertext must be a non-empty multiple of 8 bytes")
+)
+
+// KeySize is the size of a BDK, an IPEK and every derived key: double-length
+// TDES
+const KeySize = 16
+
+// counterMask covers the 21-bit transaction counter at the end of a KSN
+const counterMask = 0x1FFFFF
+
+// maxCounterBits is the most one bits a valid counter may have, bounding the
+// key derivations a host performs per transaction
+const maxCounterBits = 10
+
+// KSN is a key serial number: the initial key ID, naming the BDK and the
+// device, followed by a 21-bit transaction counter
+type KSN [10]byte
+
+// ParseKSN reads a KSN as 20 hex digits
+func ParseKSN(s string) (KSN, error) {
+	var ksn KSN
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(ksn) {
+		return KSN{}, fmt.Errorf("%w: %q", ErrInvalidKSN, s)
+	}
+	copy(ksn[:], b)
+	return ksn, nil
+}
+
+// KSNFromBytes copies a KSN from its 10 bytes
+func KSNFromBytes(b []byte) (KSN, error) {
+	var ksn KSN
+	if len(b) != len(ksn) {
+		return KSN{}, ErrInvalidKSN
+	}
+	copy(ksn[:], b)
+	return ksn, nil
+}
+
+func (k KSN) String() string {
+	return strings.ToUpper(hex.EncodeToString(k[:]))
+}
+
+// Counter returns the transaction counter
+func (k KSN) Counter() uint32 {
+	return uint32(k[7]&0x1F)<<16 | uint32(k[8])<<8 | uint32(k[9])
+}
+
+// WithCounter returns the KSN with its transaction counter replaced
+func (k KSN) WithCounter(counter uint32) KSN {
+	k[7] = k[7]&^0x1F | byte(counter>>16)&0x1F
+	k[8], k[9] = byte(counter>>8), byte(counter)
+	return k
+}
+
+// DeriveIPEK derives a terminal's initial key from the BDK and the KSN the
+// terminal is injected with; the counter is ignored
+func DeriveIPEK(bdk []byte, ksn KSN) ([]byte, error) {
+	if len(bdk) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	base := ksn.WithCounter(0)
+	variant := xorVariant(bdk, 0xC0C0C0C000000000)
+	defer securebytes.Zero(variant)
+
+	ipek := make([]byte, 0, KeySize)
+	ipek = append(ipek, tdesBlock(bdk, base[:8])...)
+	return append(ipek, tdesBlock(variant, base[:8])...), nil
+}
+
+// DeriveKey derives the transaction key for a KSN from the terminal's IPEK,
+// as the host does
+func DeriveKey(ipek []byte, ksn KSN) ([]byte, error) {
+	if len(ipek) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	counter := uint64(ksn.Counter())
+	if bits.OnesCount64(counter) > maxCounterBits {
+		return nil, fmt.Errorf("%w: counter %d has more than %d bits set", ErrInvalidKSN, counter, maxCounterBits)
+	}
+	key := securebytes.Clone(ipek)
+	register := binary.BigEndian.Uint64(ksn[2:]) &^ counterMask
+	for bit := uint64(1 << 20); bit > 0; bit >>= 1 {
+		if counter&bit == 0 {
+			continue
+		}
+		register |= bit
+		next := nonReversibleKey(key, register)
+		securebytes.Zero(key)
+		key = next
+	}
+	return key, nil
+}
+
+// DataKey turns a transaction key into the key that encrypts data, using
+// the data encryption variant and the one-way step of X9.24-1:2009
+func DataKey(key []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	variant := xorVariant(key, 0x0000000000FF0000)
+	defer securebytes.Zero(variant)
+	dataKey := make([]byte, 0, KeySize)
+	dataKey = append(dataKey, tdesBlock(variant, variant[:8])...)
+	return append(dataKey, tdesBlock(variant, variant[8:])...), nil
+}
+
+// EncryptData encrypts with a data key in TDES-CBC under a zero IV, padding
+// with zero bytes, as terminals encrypt track data
+func EncryptData(dataKey, plaintext []byte) ([]byte, error) {
+	block, err := tdes(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	padded := make([]byte, (len(plaintext)+des.BlockSize-1)/des.BlockSize*des.BlockSize)
+	copy(padded, plaintext)
+	cipher.NewCBCEncrypter(block, make([]byte, des.BlockSize)).CryptBlocks(padded, padded)
+	return padded, nil
+}
+
+// DecryptData reverses EncryptData, removing the zero padding
+func DecryptData(dataKey, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || len(ciphertext)%des.BlockSize != 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	block, err := tdes(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, make([]byte, des.BlockSize)).CryptBlocks(plaintext, ciphertext)
+	end := len(plaintext)
+	for end > 0 && plaintext[end-1] == 0 {
+		end--
+	}
+	return plaintext[:end], nil
+}
+
+// nonReversibleKey is the key generation step between transaction keys
+func nonReversibleKey(key []byte, register uint64) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], register)
+	variant := xorVariant(key, 0xC0C0C0C000000000)
+	defer securebytes.Zero(variant)
+
+	next := make([]byte, 0, KeySize)
+	next = append(next, blackBox(variant[:8], variant[8:], data[:])...)
+	return append(next, blackBox(key[:8], key[8:], data[:])...)
+}
+
+// blackBox encrypts data XOR right with single DES under left, then XORs
+// the result with right again
+func blackBox(left, right, data []byte) []byte {
+	block, _ := des.NewCipher(left)
+	out := make([]byte, des.BlockSize)
+	for i := range out {
+		out[i] = data[i] ^ right[i]
+	}
+	block.Encrypt(out, out)
+	for i := range out {
+		out[i] ^= right[i]
+	}
+	return out
+}
+
+// xorVariant XORs both halves of a double-length key with a mask
+func xorVariant(key []byte, mask uint64) []byte {
+	out := securebytes.Clone(key)
+	for half := 0; half < KeySize; half += 8 {
+		binary.BigEndian.PutUint64(out[half:], binary.BigEndian.Uint64(out[half:])^mask)
+	}
+	return out
+}
+
+// tdes builds a two-key TDES cipher, K1 K2 K1
+func tdes(key []byte) (cipher.Block, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	k := make([]byte, 0, 24)
+	k = append(append(k, key...), key[:8]...)
+	defer securebytes.Zero(k)
+	return des.NewTripleDESCipher(k)
+}
+
+// tdesBlock encrypts one block with a two-key TDES key of valid length
+func tdesBlock(key, block []byte) []byte {
+	c, _ := tdes(key)
+	out := make([]byte, des.BlockSize)
+	c.Encrypt(out, block)
+	return out
+}
+
+// CheckValue identifies a TDES key without revealing it: the leftmost three
+// bytes of a zero block encrypted under it, as hex
+func CheckValue(key []byte) (string, error) {
+	if len(key) != KeySize {
+		return "", ErrInvalidKey
+	}
+	return strings.ToUpper(hex.EncodeToString(tdesBlock(key, make([]byte, des.BlockSize))[:3])), nil
+}
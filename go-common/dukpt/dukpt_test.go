@@ -0,0 +1,78 @@
+package dukpt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// The test vectors of ANSI X9.24-1:2009, Annex A
+var (
+	testBDK, _ = hex.DecodeString("0123456789ABCDEFFEDCBA9876543210")
+	testKSN, _ = ParseKSN("FFFF9876543210E00000")
+)
+
+func TestDeriveIPEK(t *testing.T) {
+	ipek, err := DeriveIPEK(testBDK, testKSN.WithCounter(7))
+	if err != nil {
+		t.Fatalf("DeriveIPEK() error = %v", err)
+	}
+	if got := strings.ToUpper(hex.EncodeToString(ipek)); got != "6AC292FAA1315B4D858AB3A3D7D5933A" {
+		t.Errorf("DeriveIPEK() = %s", got)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	ipek, _ := DeriveIPEK(testBDK, testKSN)
+	key, err := DeriveKey(ipek, testKSN.WithCounter(1))
+	if err != nil {
+		t.Fatalf("DeriveKey() error = %v", err)
+	}
+	if got := strings.ToUpper(hex.EncodeToString(key)); got != "042666B49184CFA368DE9628D0397BC9" {
+		t.Errorf("DeriveKey() = %s", got)
+	}
+	if _, err := DeriveKey(ipek, testKSN.WithCounter(0x7FF)); !errors.Is(err, ErrInvalidKSN) {
+		t.Errorf("DeriveKey() with 11 counter bits error = %v", err)
+	}
+}
+
+func TestTerminalEncryptsUnderFreshKeys(t *testing.T) {
+	ipek, _ := DeriveIPEK(testBDK, testKSN)
+	terminal, err := NewTerminal(ipek, testKSN)
+	if err != nil {
+		t.Fatalf("NewTerminal() error = %v", err)
+	}
+	track := []byte(";4111111111111111=30121011234567?")
+
+	ksn1, c1, err := terminal.Encrypt(track)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	ksn2, c2, _ := terminal.Encrypt(track)
+	if ksn1.Counter() != 1 || ksn2.Counter() != 2 || bytes.Equal(c1, c2) {
+		t.Fatalf("transactions %s and %s should use different keys", ksn1, ksn2)
+	}
+
+	// The host needs only the BDK and the KSN
+	hostIPEK, _ := DeriveIPEK(testBDK, ksn2)
+	key, _ := DeriveKey(hostIPEK, ksn2)
+	dataKey, _ := DataKey(key)
+	if plaintext, err := DecryptData(dataKey, c2); err != nil || !bytes.Equal(plaintext, track) {
+		t.Errorf("DecryptData() = %q, %v", plaintext, err)
+	}
+}
+
+func TestNextCounterSkipsCountersWithTooManyBits(t *testing.T) {
+	if got, _ := nextCounter(0x3FE); got != 0x3FF {
+		t.Errorf("nextCounter(0x3FE) = %#x", got)
+	}
+	// 0x400 | 0x3FF has 11 bits; 0x3FF + 1 = 0x400 has one
+	if got, _ := nextCounter(0x7FE); got != 0x800 {
+		t.Errorf("nextCounter(0x7FE) = %#x, want 0x800", got)
+	}
+	if _, err := nextCounter(0x1FF800); !errors.Is(err, ErrKSNExhausted) {
+		t.Errorf("nextCounter() at the end error = %v", err)
+	}
+}
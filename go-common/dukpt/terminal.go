@@ -0,0 +1,83 @@
+package dukpt
+
+import (
+	"math/bits"
+	"sync"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// Terminal simulates a P2PE point of interaction: it holds an IPEK and its
+// KSN, and encrypts each card read under the next transaction key. A real
+// terminal keeps a register of future keys and erases each after use; the
+// simulator derives each key from the IPEK, which yields the same keys.
+type Terminal struct {
+	mu   sync.Mutex
+	ipek []byte
+	ksn  KSN
+}
+
+// NewTerminal returns a terminal injected with an IPEK and its KSN. The
+// first transaction uses the counter after the KSN's.
+func NewTerminal(ipek []byte, ksn KSN) (*Terminal, error) {
+	if len(ipek) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return &Terminal{ipek: securebytes.Clone(ipek), ksn: ksn}, nil
+}
+
+// KSN returns the KSN of the last transaction
+func (t *Terminal) KSN() KSN {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ksn
+}
+
+// Encrypt advances the transaction counter and encrypts data under the
+// new transaction's data key, returning the KSN to send with it
+func (t *Terminal) Encrypt(data []byte) (KSN, []byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counter, err := nextCounter(t.ksn.Counter())
+	if err != nil {
+		return KSN{}, nil, err
+	}
+	ksn := t.ksn.WithCounter(counter)
+
+	key, err := DeriveKey(t.ipek, ksn)
+	if err != nil {
+		return KSN{}, nil, err
+	}
+	defer securebytes.Zero(key)
+	dataKey, err := DataKey(key)
+	if err != nil {
+		return KSN{}, nil, err
+	}
+	defer securebytes.Zero(dataKey)
+	ciphertext, err := EncryptData(dataKey, data)
+	if err != nil {
+		return KSN{}, nil, err
+	}
+	t.ksn = ksn
+	return ksn, ciphertext, nil
+}
+
+// Destroy erases the IPEK, as a tampered terminal does
+func (t *Terminal) Destroy() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	securebytes.Zero(t.ipek)
+}
+
+// nextCounter returns the next counter with at most maxCounterBits bits
+// set, skipping others by adding their lowest set bit as X9.24 does
+func nextCounter(counter uint32) (uint32, error) {
+	next := counter + 1
+	for bits.OnesCount32(next) > maxCounterBits {
+		next += next & -next
+	}
+	if next > counterMask {
+		return 0, ErrKSNExhausted
+	}
+	return next, nil
+}
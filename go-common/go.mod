@@ -0,0 +1,9 @@
+module github.com/paymentgateway/go-common
+
+go 1.21
+
+require (
+    google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d
+    google.golang.org/grpc v1.59.0
+    google.golang.org/protobuf v1.31.0
+)
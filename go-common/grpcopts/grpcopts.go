@@ -0,0 +1,134 @@
+// Package grpcopts configures the transport of the Go services' gRPC servers
+// and clients: message size limits, gzip compression and keepalive. The
+// defaults admit the bulk RPCs, such as batch detokenization and token
+// handoffs, whose messages outgrow gRPC's 4 MiB receive limit.
+package grpcopts
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Defaults
+const (
+	DefaultMaxMsgBytes      = 64 << 20
+	DefaultKeepaliveTime    = time.Minute
+	DefaultKeepaliveTimeout = 20 * time.Second
+)
+
+// MinPingInterval is the shortest keepalive time a server accepts from its
+// clients; a client pinging more often is disconnected
+const MinPingInterval = 10 * time.Second
+
+// Compression values
+const (
+	CompressionNone = "none"
+	CompressionGzip = gzip.Name
+)
+
+// Config is the transport configuration of a server or client
+type Config struct {
+	// MaxRecvMsgBytes and MaxSendMsgBytes bound a single message
+	MaxRecvMsgBytes int
+	MaxSendMsgBytes int
+	// Compression is the compressor clients send with, CompressionNone or
+	// CompressionGzip. Servers accept gzip either way and answer in kind.
+	Compression string
+	// KeepaliveTime is how long a connection may be idle before it is
+	// pinged, and KeepaliveTimeout how long the ping may go unanswered
+	// before the connection is closed
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+}
+
+// Default returns the default configuration: 64 MiB messages, no
+// compression and a ping after a minute of silence
+func Default() Config {
+	return Config{
+		MaxRecvMsgBytes:  DefaultMaxMsgBytes,
+		MaxSendMsgBytes:  DefaultMaxMsgBytes,
+		Compression:      CompressionNone,
+		KeepaliveTime:    DefaultKeepaliveTime,
+		KeepaliveTimeout: DefaultKeepaliveTimeout,
+	}
+}
+
+// FromEnv reads the configuration from the variables prefix+GRPC_MAX_RECV_BYTES,
+// GRPC_MAX_SEND_BYTES, GRPC_COMPRESSION, GRPC_KEEPALIVE_TIME and
+// GRPC_KEEPALIVE_TIMEOUT, e.g. HSM_GRPC_COMPRESSION. Unset variables keep
+// their defaults.
+func FromEnv(prefix string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+	for _, size := range []struct {
+		name string
+		dst  *int
+	}{{"GRPC_MAX_RECV_BYTES", &cfg.MaxRecvMsgBytes}, {"GRPC_MAX_SEND_BYTES", &cfg.MaxSendMsgBytes}} {
+		if v := getenv(prefix + size.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return Config{}, fmt.Errorf("invalid %s%s: want a positive number of bytes, got %q", prefix, size.name, v)
+			}
+			*size.dst = n
+		}
+	}
+	if v := getenv(prefix + "GRPC_COMPRESSION"); v != "" {
+		if v != CompressionNone && v != CompressionGzip {
+			return Config{}, fmt.Errorf("invalid %sGRPC_COMPRESSION: want %s or %s, got %q", prefix, CompressionGzip, CompressionNone, v)
+		}
+		cfg.Compression = v
+	}
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+		min  time.Duration
+	}{{"GRPC_KEEPALIVE_TIME", &cfg.KeepaliveTime, MinPingInterval}, {"GRPC_KEEPALIVE_TIMEOUT", &cfg.KeepaliveTimeout, time.Second}} {
+		if v := getenv(prefix + d.name); v != "" {
+			t, err := time.ParseDuration(v)
+			if err != nil || t < d.min {
+				return Config{}, fmt.Errorf("invalid %s%s: want a duration of at least %s, got %q", prefix, d.name, d.min, v)
+			}
+			*d.dst = t
+		}
+	}
+	return cfg, nil
+}
+
+// ServerOptions returns the server options applying the configuration
+func (c Config) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(c.MaxRecvMsgBytes),
+		grpc.MaxSendMsgSize(c.MaxSendMsgBytes),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    c.KeepaliveTime,
+			Timeout: c.KeepaliveTimeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             MinPingInterval,
+			PermitWithoutStream: true,
+		}),
+	}
+}
+
+// DialOptions returns the dial options applying the configuration
+func (c Config) DialOptions() []grpc.DialOption {
+	callOpts := []grpc.CallOption{
+		grpc.MaxCallRecvMsgSize(c.MaxRecvMsgBytes),
+		grpc.MaxCallSendMsgSize(c.MaxSendMsgBytes),
+	}
+	if c.Compression == CompressionGzip {
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+	return []grpc.DialOption{
+		grpc.WithDefaultCallOptions(callOpts...),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.KeepaliveTime,
+			Timeout:             c.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+	}
+}
@@ -0,0 +1,156 @@
+package grpcopts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// echoService echoes a list, standing in for a bulk RPC
+var echoService = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(structpb.ListValue)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return req, nil
+		},
+	}},
+}
+
+// dial starts an echo server with the server configuration and connects to
+// it with the client configuration
+func dial(t *testing.T, server, client Config) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(server.ServerOptions()...)
+	srv.RegisterService(&echoService, struct{}{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts := append(client.DialOptions(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// batch returns a batch of random hex records, about size bytes in all
+func batch(t *testing.T, records, size int) *structpb.ListValue {
+	t.Helper()
+	list := &structpb.ListValue{}
+	for i := 0; i < records; i++ {
+		record := make([]byte, size/records/2)
+		if _, err := rand.Read(record); err != nil {
+			t.Fatal(err)
+		}
+		list.Values = append(list.Values, structpb.NewStringValue(hex.EncodeToString(record)))
+	}
+	return list
+}
+
+func echo(conn *grpc.ClientConn, req *structpb.ListValue) (*structpb.ListValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp := new(structpb.ListValue)
+	err := conn.Invoke(ctx, "/test.Echo/Echo", req, resp)
+	return resp, err
+}
+
+func TestLargeBatchRoundTrip(t *testing.T) {
+	gzipped := Default()
+	gzipped.Compression = CompressionGzip
+
+	tests := []struct {
+		name   string
+		client Config
+	}{
+		{"uncompressed", Default()},
+		{"gzip", gzipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Just over gRPC's 4 MiB default; more only slows gzip down
+			conn := dial(t, Default(), tt.client)
+			req := batch(t, 1000, 4<<20+256<<10)
+			resp, err := echo(conn, req)
+			if err != nil {
+				t.Fatalf("Echo() of a batch over 4 MiB error = %v", err)
+			}
+			if len(resp.Values) != len(req.Values) || resp.Values[999].GetStringValue() != req.Values[999].GetStringValue() {
+				t.Error("batch changed on the round trip")
+			}
+		})
+	}
+}
+
+func TestMessageSizeLimits(t *testing.T) {
+	small := Default()
+	small.MaxRecvMsgBytes = 1 << 20
+
+	// The server refuses what it may not receive, and the client what the
+	// server may send but it may not receive
+	if _, err := echo(dial(t, small, Default()), batch(t, 10, 2<<20)); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Echo() over the server's limit code = %v, want ResourceExhausted", status.Code(err))
+	}
+	if _, err := echo(dial(t, Default(), small), batch(t, 10, 2<<20)); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Echo() of a response over the client's limit code = %v, want ResourceExhausted", status.Code(err))
+	}
+
+	// gRPC's own 4 MiB default would refuse a bulk batch
+	conn := dial(t, Config{MaxRecvMsgBytes: 4 << 20, MaxSendMsgBytes: 4 << 20, KeepaliveTime: time.Minute, KeepaliveTimeout: time.Second}, Default())
+	if _, err := echo(conn, batch(t, 100, 8<<20)); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Echo() over 4 MiB code = %v, want ResourceExhausted", status.Code(err))
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	cfg, err := FromEnv("HSM_", env(map[string]string{
+		"HSM_GRPC_MAX_RECV_BYTES": "1048576",
+		"HSM_GRPC_COMPRESSION":    "gzip",
+		"HSM_GRPC_KEEPALIVE_TIME": "30s",
+	}))
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v", err)
+	}
+	want := Default()
+	want.MaxRecvMsgBytes = 1 << 20
+	want.Compression = CompressionGzip
+	want.KeepaliveTime = 30 * time.Second
+	if cfg != want {
+		t.Errorf("FromEnv() = %+v, want %+v", cfg, want)
+	}
+
+	for _, vars := range []map[string]string{
+		{"HSM_GRPC_MAX_SEND_BYTES": "0"},
+		{"HSM_GRPC_COMPRESSION": "snappy"},
+		{"HSM_GRPC_KEEPALIVE_TIME": "1s"},
+		{"HSM_GRPC_KEEPALIVE_TIMEOUT": "soon"},
+	} {
+		if _, err := FromEnv("HSM_", env(vars)); err == nil {
+			t.Errorf("FromEnv(%v) succeeded", vars)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package hedge cuts the tail latency of calls to a replicated dependency,
+// such as an HSM pair, with hedged requests: when the primary replica has
+// not answered within a high percentile of its recent latencies, the call
+// is sent to a second replica as well, and the first answer wins while the
+// other attempt is cancelled.
+//
+// A hedged call may run on both replicas, so only calls that are safe to
+// run twice, like decryption, may be hedged. An error from the second
+// replica never wins while the primary is still working on the call,
+// because a standby that lags behind its primary may not yet know a key the
+// primary does. A budget caps hedges to a share of all calls, so a slow
+// dependency is not sent twice its load.
+package hedge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+// Config tunes a hedger. Zero fields take the defaults noted.
+type Config struct {
+	// Percentile of the primary's recent latencies after which a call is
+	// hedged, between 0 and 1 (default 0.95)
+	Percentile float64
+	// Window is the number of recent latencies kept (default 512)
+	Window int
+	// MinSamples is the number of latencies needed before calls are
+	// hedged (default 32)
+	MinSamples int
+	// MinDelay and MaxDelay bound the hedge delay (default 2ms and 1s)
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// MaxRatio is the largest share of calls that may be hedged (default 0.1)
+	MaxRatio float64
+	// IsRetryable decides which errors of the primary send the call to the
+	// second replica at once, without waiting for the delay. By default
+	// none do.
+	IsRetryable func(error) bool
+	// Metrics, when set, receives the hedge delay and counters
+	Metrics *metrics.Registry
+}
+
+// maxBudget caps the hedges saved up while calls are fast, so a burst of
+// slow calls cannot all be hedged
+const maxBudget = 10
+
+// Hedger decides when to hedge the calls to one dependency
+type Hedger struct {
+	name string
+	cfg  Config
+
+	mu        sync.Mutex
+	latencies []time.Duration // ring of the primary's recent latencies
+	next      int
+	budget    float64
+
+	hedged *metrics.Counter
+	wins   *metrics.Counter
+	delay  *metrics.Gauge
+}
+
+// New creates a hedger for the named dependency
+func New(name string, cfg Config) *Hedger {
+	if cfg.Percentile <= 0 || cfg.Percentile >= 1 {
+		cfg.Percentile = 0.95
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 512
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 32
+	}
+	if cfg.MinSamples > cfg.Window {
+		cfg.MinSamples = cfg.Window
+	}
+	if cfg.MinDelay <= 0 {
+		cfg.MinDelay = 2 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = time.Second
+	}
+	if cfg.MaxRatio <= 0 || cfg.MaxRatio > 1 {
+		cfg.MaxRatio = 0.1
+	}
+	if cfg.IsRetryable == nil {
+		cfg.IsRetryable = func(error) bool { return false }
+	}
+	h := &Hedger{name: name, cfg: cfg, budget: maxBudget}
+	if cfg.Metrics != nil {
+		h.hedged = cfg.Metrics.Counter("hedged_requests_total",
+			"Calls sent to a second replica", "dependency").With(name)
+		h.wins = cfg.Metrics.Counter("hedged_request_wins_total",
+			"Hedged calls the second replica answered first", "dependency").With(name)
+		h.delay = cfg.Metrics.Gauge("hedge_delay_seconds",
+			"Time after which calls are hedged", "dependency").With(name)
+	}
+	return h
+}
+
+// Name returns the dependency the hedger guards
+func (h *Hedger) Name() string { return h.name }
+
+// Delay returns the time after which a call is hedged, and false while too
+// few latencies have been seen to tell
+func (h *Hedger) Delay() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delayLocked()
+}
+
+func (h *Hedger) delayLocked() (time.Duration, bool) {
+	if len(h.latencies) < h.cfg.MinSamples {
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	d := sorted[int(h.cfg.Percentile*float64(len(sorted)-1))]
+	return min(max(d, h.cfg.MinDelay), h.cfg.MaxDelay), true
+}
+
+// plan returns the delay of a new call and whether it may be hedged, and
+// earns the call its share of the hedge budget
+func (h *Hedger) plan() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.budget = min(h.budget+h.cfg.MaxRatio, maxBudget)
+	d, ok := h.delayLocked()
+	if ok && h.delay != nil {
+		h.delay.Set(d.Seconds())
+	}
+	return d, ok
+}
+
+// spend takes a hedge from the budget
+func (h *Hedger) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.budget < 1 {
+		return false
+	}
+	h.budget--
+	return true
+}
+
+// observe records a latency of the primary
+func (h *Hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < h.cfg.Window {
+		h.latencies = append(h.latencies, d)
+		return
+	}
+	h.latencies[h.next] = d
+	h.next = (h.next + 1) % h.cfg.Window
+}
+
+type result[T any] struct {
+	target int
+	value  T
+	err    error
+}
+
+// Do calls attempt with target 0, the primary replica, and with target 1
+// as well once the hedge delay has passed without an answer. A primary
+// failing with a retryable error fails over to target 1 at once, hedge
+// budget or not. Each attempt gets its own context, cancelled when Do
+// returns. Do returns the winning answer and its target. attempt must be
+// safe to run on both replicas.
+func Do[T any](ctx context.Context, h *Hedger, attempt func(ctx context.Context, target int) (T, error)) (T, int, error) {
+	delay, hedgeable := h.plan()
+	results := make(chan result[T], 2)
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	launch := func(target int) {
+		actx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			v, err := attempt(actx, target)
+			results <- result[T]{target, v, err}
+		}()
+	}
+
+	start := time.Now()
+	launch(0)
+	var timer <-chan time.Time
+	if hedgeable {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		timer = t.C
+	}
+	launched, pending := 1, 1
+	primaryDone := false
+	var last result[T]
+	for pending > 0 {
+		select {
+		case <-timer:
+			timer = nil
+			if launched == 1 && h.spend() {
+				if h.hedged != nil {
+					h.hedged.Inc()
+				}
+				launch(1)
+				launched++
+				pending++
+			}
+		case r := <-results:
+			pending--
+			last = r
+			if r.target == 0 {
+				primaryDone = true
+				h.observe(time.Since(start))
+				if r.err != nil && h.cfg.IsRetryable(r.err) {
+					// Fail over to the second replica, or wait for it
+					if launched == 1 {
+						timer = nil
+						launch(1)
+						launched++
+						pending++
+					}
+					continue
+				}
+				return r.value, 0, r.err
+			}
+			if r.err == nil || primaryDone {
+				if !primaryDone {
+					// The primary's latency is at least this long
+					h.observe(time.Since(start))
+					if h.wins != nil {
+						h.wins.Inc()
+					}
+				}
+				return r.value, 1, r.err
+			}
+			// The second replica's error waits for the primary's answer
+		}
+	}
+	return last.value, last.target, last.err
+}
@@ -0,0 +1,145 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+var (
+	errDown     = errors.New("connection refused")
+	errNotFound = errors.New("key not found")
+)
+
+// replica answers after its latency unless the call is cancelled first
+type replica struct {
+	latency time.Duration
+	err     error
+	calls   atomic.Int32
+}
+
+func (r *replica) call(ctx context.Context) (string, error) {
+	r.calls.Add(1)
+	select {
+	case <-time.After(r.latency):
+		return "answer", r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func pair(primary, secondary *replica) func(ctx context.Context, target int) (string, error) {
+	return func(ctx context.Context, target int) (string, error) {
+		if target == 0 {
+			return primary.call(ctx)
+		}
+		return secondary.call(ctx)
+	}
+}
+
+// warm records n fast calls to the primary
+func warm(t *testing.T, h *Hedger, n int) {
+	t.Helper()
+	fast := &replica{}
+	for i := 0; i < n; i++ {
+		if _, target, err := Do(context.Background(), h, pair(fast, fast)); err != nil || target != 0 {
+			t.Fatalf("warm-up call = %d, %v", target, err)
+		}
+	}
+}
+
+func TestHedgesSlowPrimary(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := New("hsm", Config{MinSamples: 10, MinDelay: 5 * time.Millisecond, Metrics: registry})
+	if _, ok := h.Delay(); ok {
+		t.Error("Delay() known before any call")
+	}
+	warm(t, h, 10)
+	if d, ok := h.Delay(); !ok || d != 5*time.Millisecond {
+		t.Errorf("Delay() = %s, %v, want the 5ms floor", d, ok)
+	}
+
+	slow, fast := &replica{latency: 5 * time.Second}, &replica{}
+	start := time.Now()
+	v, target, err := Do(context.Background(), h, pair(slow, fast))
+	if err != nil || v != "answer" || target != 1 {
+		t.Fatalf("Do() = %q, %d, %v, want the second replica's answer", v, target, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() took %s", elapsed)
+	}
+	if h.wins.Value() != 1 || h.hedged.Value() != 1 {
+		t.Errorf("hedged %v, wins %v, want 1 and 1", h.hedged.Value(), h.wins.Value())
+	}
+}
+
+func TestSecondaryErrorWaitsForPrimary(t *testing.T) {
+	h := New("hsm", Config{MinSamples: 10, MinDelay: time.Millisecond})
+	warm(t, h, 10)
+
+	// A lagging standby doesn't know the key yet; the primary does
+	primary, standby := &replica{latency: 50 * time.Millisecond}, &replica{err: errNotFound}
+	v, target, err := Do(context.Background(), h, pair(primary, standby))
+	if err != nil || v != "answer" || target != 0 {
+		t.Errorf("Do() = %q, %d, %v, want the primary's answer", v, target, err)
+	}
+	if standby.calls.Load() != 1 {
+		t.Errorf("standby called %d times, want 1", standby.calls.Load())
+	}
+
+	// The primary's own error is its answer
+	primary.err = errDown
+	if _, target, err := Do(context.Background(), h, pair(primary, standby)); target != 0 || !errors.Is(err, errDown) {
+		t.Errorf("Do() = %d, %v, want the primary's error", target, err)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	h := New("hsm", Config{IsRetryable: func(err error) bool { return errors.Is(err, errDown) }, MaxRatio: 0.01})
+	down, standby := &replica{err: errDown}, &replica{}
+	for i := 0; i < 20; i++ {
+		if _, target, err := Do(context.Background(), h, pair(down, standby)); err != nil || target != 1 {
+			t.Fatalf("Do() = %d, %v, want a failover", target, err)
+		}
+	}
+	standby.err = errDown
+	if _, _, err := Do(context.Background(), h, pair(down, standby)); !errors.Is(err, errDown) {
+		t.Errorf("Do() with both replicas down error = %v", err)
+	}
+}
+
+func TestHedgeBudget(t *testing.T) {
+	h := New("hsm", Config{Percentile: 0.5, MinSamples: 10, MinDelay: time.Millisecond, MaxRatio: 0.01})
+	warm(t, h, 30)
+
+	// The budget saved up covers maxBudget slow calls, then one in a
+	// hundred is hedged
+	primary, secondary := &replica{latency: 20 * time.Millisecond}, &replica{latency: 20 * time.Millisecond}
+	for i := 0; i < 2*maxBudget; i++ {
+		Do(context.Background(), h, pair(primary, secondary))
+	}
+	if n := secondary.calls.Load(); n != maxBudget {
+		t.Errorf("hedged %d of %d calls, want %d", n, 2*maxBudget, maxBudget)
+	}
+}
+
+func TestDelayTracksPercentile(t *testing.T) {
+	h := New("hsm", Config{Percentile: 0.9, Window: 10, MinSamples: 10})
+	for i := 1; i <= 10; i++ {
+		h.observe(time.Duration(i) * 10 * time.Millisecond)
+	}
+	if d, _ := h.Delay(); d != 90*time.Millisecond {
+		t.Errorf("Delay() = %s, want 90ms", d)
+	}
+	// Older latencies leave the window
+	for i := 0; i < 10; i++ {
+		h.observe(3 * time.Second)
+	}
+	if d, _ := h.Delay(); d != time.Second {
+		t.Errorf("Delay() = %s, want the 1s ceiling", d)
+	}
+}
@@ -0,0 +1,253 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal identifies an authenticated caller
+type Principal struct {
+	ID    string
+	Roles []string
+}
+
+// HasRole reports whether the principal holds the given role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the authenticated caller, or nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// WithPrincipal returns a context carrying the given principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Authenticator verifies a bearer credential
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*Principal, error)
+}
+
+// StaticKeys authenticates callers against a fixed set of API keys
+type StaticKeys map[string]Principal
+
+// Authenticate compares the credential against every key in constant time,
+// so neither a map lookup nor an early exit reveals how close a guess was
+func (s StaticKeys) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	var match *Principal
+	for key, p := range s {
+		if securebytes.EqualString(key, credential) {
+			p := p
+			match = &p
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return match, nil
+}
+
+// ParseStaticKeys parses "key:principal[:role|role...]" entries separated by
+// commas, the format used by the services' *_API_KEYS environment variables
+func ParseStaticKeys(spec string) (StaticKeys, error) {
+	keys := make(StaticKeys)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API key entry %q: want key:principal[:roles]", entry)
+		}
+		p := Principal{ID: parts[1]}
+		if len(parts) == 3 && parts[2] != "" {
+			p.Roles = strings.Split(parts[2], "|")
+		}
+		keys[parts[0]] = p
+	}
+	return keys, nil
+}
+
+// KeySet is a StaticKeys authenticator whose keys can be replaced while the
+// server runs, for configuration reloads
+type KeySet struct {
+	keys atomic.Pointer[StaticKeys]
+}
+
+// NewKeySet creates a key set holding keys
+func NewKeySet(keys StaticKeys) *KeySet {
+	s := &KeySet{}
+	s.keys.Store(&keys)
+	return s
+}
+
+// Authenticate checks the credential against the current keys
+func (s *KeySet) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	return s.keys.Load().Authenticate(ctx, credential)
+}
+
+// Replace swaps in new keys and describes the change by principal, never by
+// key value
+func (s *KeySet) Replace(keys StaticKeys) []string {
+	old := *s.keys.Swap(&keys)
+	return diffPrincipals(old, keys)
+}
+
+func diffPrincipals(old, updated StaticKeys) []string {
+	// Fingerprint each principal's keys and roles; only the comparison
+	// uses the key digests, which never leave this function
+	describe := func(keys StaticKeys) map[string]string {
+		entries := make(map[string][]string)
+		for key, p := range keys {
+			roles := append([]string(nil), p.Roles...)
+			sort.Strings(roles)
+			digest := sha256.Sum256([]byte(key))
+			entries[p.ID] = append(entries[p.ID], hex.EncodeToString(digest[:])+":"+strings.Join(roles, "|"))
+		}
+		byPrincipal := make(map[string]string, len(entries))
+		for id, e := range entries {
+			sort.Strings(e)
+			byPrincipal[id] = strings.Join(e, ",")
+		}
+		return byPrincipal
+	}
+	before, after := describe(old), describe(updated)
+
+	var changes []string
+	for id, roles := range after {
+		switch prev, ok := before[id]; {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("API key added for %s", id))
+		case prev != roles:
+			changes = append(changes, fmt.Sprintf("API keys or roles changed for %s", id))
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			changes = append(changes, fmt.Sprintf("API key removed for %s", id))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// AuthUnary rejects calls without a valid "authorization: Bearer <key>"
+// header, except for the listed public methods
+func AuthUnary(auth Authenticator, publicMethods ...string) grpc.UnaryServerInterceptor {
+	public := toSet(publicMethods)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, auth)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStream is the streaming counterpart of AuthUnary
+func AuthStream(auth Authenticator, publicMethods ...string) grpc.StreamServerInterceptor {
+	public := toSet(publicMethods)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if public[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), auth)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// Authorizer decides whether the caller in ctx, if any, may call a method.
+// The error it returns is sent to the caller, so it should be a status.
+type Authorizer interface {
+	Authorize(ctx context.Context, fullMethod string) error
+}
+
+// AuthorizeUnary rejects calls the authorizer refuses before the handler
+// runs
+func AuthorizeUnary(authz Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authz.Authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthorizeStream is the streaming counterpart of AuthorizeUnary
+func AuthorizeStream(authz Authorizer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authz.Authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authenticate(ctx context.Context, auth Authenticator) (context.Context, error) {
+	credential := bearerToken(ctx)
+	if credential == "" {
+		return ctx, status.Error(codes.Unauthenticated, ErrMissingCredentials.Error())
+	}
+	p, err := auth.Authenticate(ctx, credential)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, ErrInvalidCredentials.Error())
+	}
+	return WithPrincipal(ctx, p), nil
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(v, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,220 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/paymentgateway/go-common/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// chain applies unary interceptors in the same order grpc.ChainUnaryInterceptor does
+func chain(interceptors []grpc.UnaryServerInterceptor, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	info := &grpc.UnaryServerInfo{FullMethod: "/hsm.HSMService/Encrypt"}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next, ic := handler, interceptors[i]
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return ic(ctx, req, info, next)
+		}
+	}
+	return handler
+}
+
+func TestRecoveryConvertsPanic(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	call := chain([]grpc.UnaryServerInterceptor{RecoveryUnary(logger)}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom 4532015112830366")
+	})
+	_, err := call(context.Background(), nil)
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("code = %v, want Internal", status.Code(err))
+	}
+	if strings.Contains(status.Convert(err).Message(), "boom") {
+		t.Errorf("panic value leaked to the caller: %v", err)
+	}
+	if !strings.Contains(logs.String(), "panic in handler") {
+		t.Errorf("panic not logged: %s", logs.String())
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	var got string
+	call := chain([]grpc.UnaryServerInterceptor{RequestIDUnary()}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = RequestIDFromContext(ctx)
+		return nil, nil
+	})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDHeader, "abc-123"))
+	call(ctx, nil)
+	if got != "abc-123" {
+		t.Errorf("request ID = %q, want caller's abc-123", got)
+	}
+
+	call(context.Background(), nil)
+	if len(got) != 32 {
+		t.Errorf("generated request ID = %q, want 32 hex chars", got)
+	}
+}
+
+func TestLoggingRedactsPayload(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	req, _ := structpb.NewStruct(map[string]interface{}{"pan": "4532015112830366", "cvv": "123", "amount": 100})
+	call := chain([]grpc.UnaryServerInterceptor{LoggingUnary(logger, true)}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.InvalidArgument, "bad")
+	})
+	call(context.Background(), req)
+
+	out := logs.String()
+	if strings.Contains(out, "4532015112830366") || strings.Contains(out, `\"cvv\":\"123\"`) {
+		t.Fatalf("log contains cardholder data: %s", out)
+	}
+	for _, want := range []string{`"level":"WARN"`, `"code":"InvalidArgument"`, "453201******0366"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %s: %s", want, out)
+		}
+	}
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	reg := metrics.NewRegistry()
+	call := chain([]grpc.UnaryServerInterceptor{NewMetrics(reg).Unary()}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	call(context.Background(), nil)
+	call(context.Background(), nil)
+
+	var out bytes.Buffer
+	reg.WriteText(&out)
+	for _, want := range []string{
+		`grpc_server_handled_total{method="/hsm.HSMService/Encrypt",code="OK"} 2`,
+		`grpc_server_handling_seconds_count{method="/hsm.HSMService/Encrypt"} 2`,
+		`grpc_server_in_flight{method="/hsm.HSMService/Encrypt"} 0`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	keys, err := ParseStaticKeys("k1:tokenization-service:hsm.encrypt|hsm.decrypt, k2:ops")
+	if err != nil {
+		t.Fatalf("ParseStaticKeys() error = %v", err)
+	}
+	if _, err := ParseStaticKeys("missing-principal"); err == nil {
+		t.Error("ParseStaticKeys() should reject an entry without a principal")
+	}
+
+	var principal *Principal
+	call := chain([]grpc.UnaryServerInterceptor{AuthUnary(keys, "/grpc.health.v1.Health/Check")}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		principal = PrincipalFromContext(ctx)
+		return nil, nil
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode codes.Code
+		wantID   string
+	}{
+		{"valid key", "Bearer k1", codes.OK, "tokenization-service"},
+		{"unknown key", "Bearer nope", codes.Unauthenticated, ""},
+		{"no bearer prefix", "k1", codes.Unauthenticated, ""},
+		{"missing header", "", codes.Unauthenticated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = nil
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.header))
+			}
+			_, err := call(ctx, nil)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+			if tt.wantID != "" && (principal == nil || principal.ID != tt.wantID || !principal.HasRole("hsm.decrypt")) {
+				t.Errorf("principal = %+v, want %s with roles", principal, tt.wantID)
+			}
+		})
+	}
+}
+
+// authorizerFunc adapts a function to the Authorizer interface
+type authorizerFunc func(ctx context.Context, fullMethod string) error
+
+func (f authorizerFunc) Authorize(ctx context.Context, fullMethod string) error {
+	return f(ctx, fullMethod)
+}
+
+func TestAuthorizeRunsAfterAuthentication(t *testing.T) {
+	keys, _ := ParseStaticKeys("k1:ops:hsm.admin, k2:loadgen")
+	authz := authorizerFunc(func(ctx context.Context, fullMethod string) error {
+		if p := PrincipalFromContext(ctx); p == nil || !p.HasRole("hsm.admin") {
+			return status.Errorf(codes.PermissionDenied, "%s needs hsm.admin", fullMethod)
+		}
+		return nil
+	})
+	called := false
+	call := chain([]grpc.UnaryServerInterceptor{AuthUnary(keys), AuthorizeUnary(authz)}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+
+	for key, want := range map[string]codes.Code{"k1": codes.OK, "k2": codes.PermissionDenied} {
+		called = false
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+key))
+		if _, err := call(ctx, nil); status.Code(err) != want {
+			t.Errorf("%s: code = %v, want %v", key, status.Code(err), want)
+		}
+		if called != (want == codes.OK) {
+			t.Errorf("%s: handler called = %v", key, called)
+		}
+	}
+}
+
+func TestKeySetReplace(t *testing.T) {
+	old, _ := ParseStaticKeys("k1:loadgen, k2:ops:hsm.admin, k3:auditor")
+	set := NewKeySet(old)
+	if p, err := set.Authenticate(context.Background(), "k1"); err != nil || p.ID != "loadgen" {
+		t.Fatalf("Authenticate(k1) = %v, %v", p, err)
+	}
+
+	// ops gets a new key, auditor a role, loadgen is dropped and ci added
+	updated, _ := ParseStaticKeys("k4:ops:hsm.admin, k3:auditor:auditor, k5:ci")
+	changes := set.Replace(updated)
+	want := []string{
+		"API key added for ci",
+		"API key removed for loadgen",
+		"API keys or roles changed for auditor",
+		"API keys or roles changed for ops",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Replace() changes = %q, want %q", changes, want)
+	}
+	for _, c := range changes {
+		if strings.Contains(c, "k4") || strings.Contains(c, "k2") {
+			t.Errorf("change %q reveals a key", c)
+		}
+	}
+
+	for _, old := range []string{"k1", "k2"} {
+		if _, err := set.Authenticate(context.Background(), old); err == nil {
+			t.Errorf("replaced key %s still authenticates", old)
+		}
+	}
+	if p, err := set.Authenticate(context.Background(), "k4"); err != nil || p.ID != "ops" {
+		t.Errorf("Authenticate(k4) = %v, %v", p, err)
+	}
+}
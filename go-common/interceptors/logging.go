@@ -0,0 +1,78 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/paymentgateway/go-common/redact"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// LoggingUnary writes one structured entry per call. With logPayloads the
+// request message is included after PAN masking and secret removal.
+func LoggingUnary(logger *slog.Logger, logPayloads bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		attrs := callAttrs(ctx, info.FullMethod, start, err)
+		if logPayloads {
+			if msg, ok := req.(proto.Message); ok {
+				if body, merr := protojson.Marshal(msg); merr == nil {
+					attrs = append(attrs, slog.String("request", string(redact.JSON(body))))
+				}
+			}
+		}
+		logger.LogAttrs(ctx, levelFor(status.Code(err)), "rpc", attrs...)
+		return resp, err
+	}
+}
+
+// LoggingStream logs each stream once it ends
+func LoggingStream(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logger.LogAttrs(ss.Context(), levelFor(status.Code(err)), "rpc stream", callAttrs(ss.Context(), info.FullMethod, start, err)...)
+		return err
+	}
+}
+
+func callAttrs(ctx context.Context, method string, start time.Time, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("request_id", RequestIDFromContext(ctx)),
+		slog.String("code", status.Code(err).String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+	}
+	if p := PrincipalFromContext(ctx); p != nil {
+		attrs = append(attrs, slog.String("principal", p.ID))
+	}
+	if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+		attrs = append(attrs, slog.String("peer", pr.Addr.String()))
+	}
+	if err != nil {
+		// Error messages are produced by our own handlers and never carry
+		// card data, but mask anything PAN-shaped just in case
+		attrs = append(attrs, slog.String("error", redact.Text(status.Convert(err).Message())))
+	}
+	return attrs
+}
+
+// levelFor maps a status code to a log level: server faults are errors,
+// client mistakes are warnings
+func levelFor(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded:
+		return slog.LevelError
+	}
+	return slog.LevelWarn
+}
@@ -0,0 +1,57 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics records per-method call counts, latencies and in-flight calls
+type Metrics struct {
+	handled  *metrics.CounterVec
+	latency  *metrics.HistogramVec
+	inFlight *metrics.GaugeVec
+}
+
+// NewMetrics registers the RPC metric families on the registry
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		handled:  registry.Counter("grpc_server_handled_total", "RPCs completed on the server, by method and status code.", "method", "code"),
+		latency:  registry.Histogram("grpc_server_handling_seconds", "Server-side RPC handling time in seconds.", nil, "method"),
+		inFlight: registry.Gauge("grpc_server_in_flight", "RPCs currently being handled.", "method"),
+	}
+}
+
+// Unary returns the unary metrics interceptor
+func (m *Metrics) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done := m.begin(info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// Stream returns the stream metrics interceptor
+func (m *Metrics) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := m.begin(info.FullMethod)
+		err := handler(srv, ss)
+		done(err)
+		return err
+	}
+}
+
+func (m *Metrics) begin(method string) func(error) {
+	start := time.Now()
+	gauge := m.inFlight.With(method)
+	gauge.Inc()
+	return func(err error) {
+		gauge.Dec()
+		m.latency.With(method).Observe(time.Since(start).Seconds())
+		m.handled.With(method, status.Code(err).String()).Inc()
+	}
+}
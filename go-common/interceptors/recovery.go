@@ -0,0 +1,47 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnary converts a panicking handler into an Internal error instead
+// of crashing the server. The panic value is logged, never returned, since it
+// may contain sensitive data.
+func RecoveryUnary(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, logger, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStream is the streaming counterpart of RecoveryUnary
+func RecoveryStream(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), logger, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, logger *slog.Logger, method string, r interface{}) error {
+	logger.ErrorContext(ctx, "panic in handler",
+		"method", method,
+		"request_id", RequestIDFromContext(ctx),
+		"panic", r,
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,63 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the metadata key carrying the request ID in both
+// directions
+const RequestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID assigned by the interceptor
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a context carrying the given request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDUnary propagates the caller's x-request-id or assigns a new one,
+// and echoes it back in the response header
+func RequestIDUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = assignRequestID(ctx)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, RequestIDFromContext(ctx)))
+		return handler(ctx, req)
+	}
+}
+
+// RequestIDStream is the streaming counterpart of RequestIDUnary
+func RequestIDStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := assignRequestID(ss.Context())
+		ss.SetHeader(metadata.Pairs(RequestIDHeader, RequestIDFromContext(ctx)))
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func assignRequestID(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= 128 {
+			return WithRequestID(ctx, ids[0])
+		}
+	}
+	return WithRequestID(ctx, newRequestID())
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
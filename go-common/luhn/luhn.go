@@ -0,0 +1,44 @@
+// Package luhn implements the Luhn (mod 10) check digit of card numbers, the
+// one implementation the services share for validating, detecting and
+// generating PANs.
+package luhn
+
+// Sum returns the Luhn sum of number, doubling every second digit from the
+// right. number must be ASCII digits only; see Digits.
+func Sum(number string) int {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum
+}
+
+// Valid reports whether number is digits passing the Luhn check
+func Valid(number string) bool {
+	return number != "" && Digits(number) && Sum(number)%10 == 0
+}
+
+// CheckDigit returns the digit that makes body followed by it pass the Luhn
+// check
+func CheckDigit(body string) int {
+	return (10 - Sum(body+"0")%10) % 10
+}
+
+// Digits reports whether s consists of ASCII digits only
+func Digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package luhn
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4532015112830366", true},
+		{"378282246310005", true},
+		{"4532015112830367", false},
+		{"4532 0151 1283 0366", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.number); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	for _, number := range []string{"4532015112830366", "378282246310005", "5425233430109903"} {
+		body := number[:len(number)-1]
+		if got := CheckDigit(body); got != int(number[len(number)-1]-'0') {
+			t.Errorf("CheckDigit(%s) = %d, want %c", body, got, number[len(number)-1])
+		}
+	}
+}
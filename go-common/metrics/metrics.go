@@ -0,0 +1,351 @@
+// Package metrics is a small, dependency-free metrics registry that renders
+// the Prometheus text exposition format. It covers the counters, gauges and
+// histograms the Go services need without pulling in a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency buckets in seconds suited to RPC handling times
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Registry holds named metric families
+type Registry struct {
+	families map[string]family
+	mu       sync.RWMutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+type family interface {
+	write(w io.Writer) error
+}
+
+// Counter registers (or returns the existing) counter family
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return register(r, name, func() *CounterVec {
+		return &CounterVec{vec: newVec(name, help, "counter", labels)}
+	})
+}
+
+// Gauge registers (or returns the existing) gauge family
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return register(r, name, func() *GaugeVec {
+		return &GaugeVec{vec: newVec(name, help, "gauge", labels)}
+	})
+}
+
+// GaugeFunc registers a gauge whose value is computed at scrape time
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	register(r, name, func() *gaugeFunc {
+		return &gaugeFunc{name: name, help: help, fn: fn}
+	})
+}
+
+// Histogram registers (or returns the existing) histogram family.
+// A nil bucket list selects DefaultBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return register(r, name, func() *HistogramVec {
+		return &HistogramVec{vec: newVec(name, help, "histogram", labels), buckets: sorted}
+	})
+}
+
+func register[T family](r *Registry, name string, create func() T) T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.families[name]; ok {
+		if typed, ok := existing.(T); ok {
+			return typed
+		}
+		panic(fmt.Sprintf("metrics: %s already registered with a different type", name))
+	}
+	f := create()
+	r.families[name] = f
+	return f
+}
+
+// WriteText renders every family in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.RUnlock()
+
+	for _, f := range families {
+		if err := f.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry for Prometheus scraping
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.WriteText(w)
+	})
+}
+
+// vec is the label-partitioned storage shared by all family types
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]interface{}
+	order  []string
+	mu     sync.RWMutex
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, series: make(map[string]interface{})}
+}
+
+func (v *vec) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s
+	}
+	s = create()
+	v.series[key] = s
+	v.order = append(v.order, key)
+	return s
+}
+
+func (v *vec) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	return err
+}
+
+// each visits series in label order
+func (v *vec) each(fn func(values []string, s interface{}) error) error {
+	v.mu.RLock()
+	keys := append([]string(nil), v.order...)
+	v.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v.mu.RLock()
+		s := v.series[key]
+		v.mu.RUnlock()
+		var values []string
+		if len(v.labels) > 0 {
+			values = strings.Split(key, "\xff")
+		}
+		if err := fn(values, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *vec) formatLabels(values []string, extraName, extraValue string) string {
+	if len(v.labels) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(v.labels)+1)
+	for i, l := range v.labels {
+		parts = append(parts, fmt.Sprintf("%s=%q", l, values[i]))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf("%s=%q", extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// atomicFloat is a float64 updated with compare-and-swap
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&f.bits, old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(v float64) { atomic.StoreUint64(&f.bits, math.Float64bits(v)) }
+func (f *atomicFloat) Load() float64 { return math.Float64frombits(atomic.LoadUint64(&f.bits)) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// CounterVec is a family of monotonically increasing counters
+type CounterVec struct {
+	vec
+}
+
+// Counter is a single counter series
+type Counter struct {
+	value atomicFloat
+}
+
+// With returns the series for the given label values
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.get(values, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+func (c *Counter) Inc()              { c.value.Add(1) }
+func (c *Counter) Add(delta float64) { c.value.Add(delta) }
+func (c *Counter) Value() float64    { return c.value.Load() }
+
+func (c *CounterVec) write(w io.Writer) error {
+	if err := c.writeHeader(w); err != nil {
+		return err
+	}
+	return c.each(func(values []string, s interface{}) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(values, "", ""), formatFloat(s.(*Counter).Value()))
+		return err
+	})
+}
+
+// GaugeVec is a family of gauges
+type GaugeVec struct {
+	vec
+}
+
+// Gauge is a single gauge series
+type Gauge struct {
+	value atomicFloat
+}
+
+// With returns the series for the given label values
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return g.get(values, func() interface{} { return &Gauge{} }).(*Gauge)
+}
+
+func (g *Gauge) Set(v float64)     { g.value.Set(v) }
+func (g *Gauge) Add(delta float64) { g.value.Add(delta) }
+func (g *Gauge) Inc()              { g.value.Add(1) }
+func (g *Gauge) Dec()              { g.value.Add(-1) }
+func (g *Gauge) Value() float64    { return g.value.Load() }
+
+func (g *GaugeVec) write(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+	return g.each(func(values []string, s interface{}) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", g.name, g.formatLabels(values, "", ""), formatFloat(s.(*Gauge).Value()))
+		return err
+	})
+}
+
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatFloat(g.fn()))
+	return err
+}
+
+// HistogramVec is a family of histograms sharing bucket boundaries
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// Histogram is a single histogram series
+type Histogram struct {
+	upper  []float64
+	counts []uint64
+	sum    atomicFloat
+	count  uint64
+}
+
+// With returns the series for the given label values
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.get(values, func() interface{} {
+		return &Histogram{upper: h.buckets, counts: make([]uint64, len(h.buckets))}
+	}).(*Histogram)
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upper, v)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	h.sum.Add(v)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 { return atomic.LoadUint64(&h.count) }
+
+// Sum returns the sum of all observations
+func (h *Histogram) Sum() float64 { return h.sum.Load() }
+
+func (h *HistogramVec) write(w io.Writer) error {
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+	return h.each(func(values []string, s interface{}) error {
+		hist := s.(*Histogram)
+		var cumulative uint64
+		for i, upper := range hist.upper {
+			cumulative += atomic.LoadUint64(&hist.counts[i])
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(values, "le", formatFloat(upper)), cumulative); err != nil {
+				return err
+			}
+		}
+		labels := h.formatLabels(values, "", "")
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.formatLabels(values, "le", "+Inf"), hist.Count(),
+			h.name, labels, formatFloat(hist.Sum()),
+			h.name, labels, hist.Count())
+		return err
+	})
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	calls := r.Counter("rpc_total", "RPCs handled", "method", "code")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls.With("Encrypt", "OK").Inc()
+		}()
+	}
+	wg.Wait()
+	calls.With("Decrypt", "NotFound").Add(2)
+
+	if got := calls.With("Encrypt", "OK").Value(); got != 100 {
+		t.Errorf("counter = %v, want 100", got)
+	}
+	if r.Counter("rpc_total", "RPCs handled", "method", "code") != calls {
+		t.Error("re-registering a family should return the existing one")
+	}
+
+	inFlight := r.Gauge("in_flight", "Requests in flight")
+	inFlight.With().Inc()
+	inFlight.With().Inc()
+	inFlight.With().Dec()
+	r.GaugeFunc("keys", "Keys held", func() float64 { return 7 })
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		"# TYPE rpc_total counter",
+		`rpc_total{method="Decrypt",code="NotFound"} 2`,
+		`rpc_total{method="Encrypt",code="OK"} 100`,
+		"in_flight 1",
+		"keys 7",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("latency_seconds", "Latency", []float64{0.1, 0.5, 1}, "method")
+	for _, v := range []float64{0.05, 0.2, 0.3, 0.7, 3} {
+		h.With("Encrypt").Observe(v)
+	}
+
+	series := h.With("Encrypt")
+	if series.Count() != 5 {
+		t.Errorf("Count() = %d, want 5", series.Count())
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		`latency_seconds_bucket{method="Encrypt",le="0.1"} 1`,
+		`latency_seconds_bucket{method="Encrypt",le="0.5"} 3`,
+		`latency_seconds_bucket{method="Encrypt",le="1"} 4`,
+		`latency_seconds_bucket{method="Encrypt",le="+Inf"} 5`,
+		`latency_seconds_count{method="Encrypt"} 5`,
+		`latency_seconds_sum{method="Encrypt"} 4.25`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on label count mismatch")
+		}
+	}()
+	NewRegistry().Counter("c", "c", "a", "b").With("only-one")
+}
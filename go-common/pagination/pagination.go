@@ -0,0 +1,175 @@
+// Package pagination implements the cursor-based paging shared by the list
+// APIs. A page token is opaque to clients: it holds the sort key of the last
+// item returned and a fingerprint of the query's filters. The next page starts
+// strictly after that key, so items added or removed between calls never
+// shift a page, and a token cannot be replayed against a different query.
+package pagination
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	// ErrInvalidToken is returned for a page token that was not issued by
+	// Encode
+	ErrInvalidToken = errors.New("invalid page token")
+	// ErrQueryChanged is returned for a page token issued for a query with
+	// different filters
+	ErrQueryChanged = errors.New("page token was issued for a different query")
+)
+
+// Limits bound the page size of a list
+type Limits struct {
+	// Default is used when the client asks for no particular size
+	Default int
+	// Max caps the size a client may ask for
+	Max int
+}
+
+// Size returns the page size for a requested size: Default when the request
+// is zero or negative, and never more than Max
+func (l Limits) Size(requested int) int {
+	switch {
+	case requested <= 0:
+		return l.Default
+	case requested > l.Max:
+		return l.Max
+	}
+	return requested
+}
+
+// Key is an item's position in a list's sort order. Keys compare element by
+// element as strings, so numbers and times must be encoded with Int and Time.
+// The last element should be unique, such as an ID or sequence number, so
+// that no two items share a key.
+type Key []string
+
+// Compare returns -1, 0 or 1 as k sorts before, with or after o
+func (k Key) Compare(o Key) int {
+	for i := 0; i < len(k) && i < len(o); i++ {
+		if c := strings.Compare(k[i], o[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(k) < len(o):
+		return -1
+	case len(k) > len(o):
+		return 1
+	}
+	return 0
+}
+
+// Int encodes a non-negative integer so that it sorts numerically
+func Int(n int64) string {
+	return fmt.Sprintf("%020d", n)
+}
+
+// timeLayout is fixed width, so encoded times sort chronologically
+const timeLayout = "20060102T150405.000000000Z"
+
+// Time encodes a time so that it sorts chronologically
+func Time(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// ParseTime decodes a time encoded by Time
+func ParseTime(s string) (time.Time, error) {
+	return time.Parse(timeLayout, s)
+}
+
+// Order is the direction a list is sorted in
+type Order int
+
+const (
+	Ascending Order = iota
+	Descending
+)
+
+// Fingerprint identifies a query by its filter values, in a fixed order.
+// Page size is not a filter: it may change from page to page.
+func Fingerprint(filters ...string) string {
+	h := sha256.New()
+	for _, f := range filters {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+type token struct {
+	Key         Key    `json:"k"`
+	Fingerprint string `json:"f"`
+}
+
+// Encode returns the page token that continues the query after key
+func Encode(fingerprint string, key Key) string {
+	b, _ := json.Marshal(token{Key: key, Fingerprint: fingerprint})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode returns the key a page token continues after, or nil for an empty
+// token, which starts at the first page
+func Decode(pageToken, fingerprint string) (Key, error) {
+	if pageToken == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var t token
+	if err := json.Unmarshal(b, &t); err != nil || len(t.Key) == 0 {
+		return nil, ErrInvalidToken
+	}
+	if !securebytes.EqualString(t.Fingerprint, fingerprint) {
+		return nil, ErrQueryChanged
+	}
+	return t.Key, nil
+}
+
+// Page cuts the page of at most size items that follows the after key from
+// items sorted by key in the given order. It returns the key to continue
+// after, which is nil on the last page.
+func Page[T any](items []T, after Key, size int, order Order, key func(T) Key) ([]T, Key) {
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			c := key(items[i]).Compare(after)
+			if order == Descending {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+	end := start + size
+	if end >= len(items) {
+		return items[start:], nil
+	}
+	return items[start:end], key(items[end-1])
+}
+
+// Paginate is Page for a list whose query is identified by fingerprint: it
+// decodes the request's page token and encodes the next one, which is empty
+// on the last page
+func Paginate[T any](items []T, pageToken, fingerprint string, size int, order Order, key func(T) Key) ([]T, string, error) {
+	after, err := Decode(pageToken, fingerprint)
+	if err != nil {
+		return nil, "", err
+	}
+	page, next := Page(items, after, size, order, key)
+	if next == nil {
+		return page, "", nil
+	}
+	return page, Encode(fingerprint, next), nil
+}
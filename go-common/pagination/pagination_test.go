@@ -0,0 +1,109 @@
+package pagination
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type item struct {
+	created time.Time
+	id      string
+}
+
+func itemKey(it item) Key { return Key{Time(it.created), it.id} }
+
+func TestPaginateWalksEveryItemOnce(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Two items share a timestamp; the ID breaks the tie
+	items := []item{
+		{base, "a"},
+		{base.Add(time.Second), "b"},
+		{base.Add(time.Second), "c"},
+		{base.Add(2 * time.Second), "d"},
+		{base.Add(3 * time.Second), "e"},
+	}
+	fp := Fingerprint("merchant-1")
+
+	var seen []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > len(items) {
+			t.Fatal("pagination did not terminate")
+		}
+		page, next, err := Paginate(items, token, fp, 2, Ascending, itemKey)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		for _, it := range page {
+			seen = append(seen, it.id)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("items = %v, want %v", seen, want)
+	}
+}
+
+func TestPageIsStableWhenItemsChange(t *testing.T) {
+	seqKey := func(n int64) Key { return Key{Int(n)} }
+	items := []int64{50, 40, 30, 20, 10}
+	page, next := Page(items, nil, 2, Descending, seqKey)
+	if !reflect.DeepEqual(page, []int64{50, 40}) {
+		t.Fatalf("first page = %v", page)
+	}
+
+	// A newer item and the removal of the cursor item do not shift the
+	// second page
+	items = []int64{60, 50, 30, 20, 10}
+	page, next = Page(items, next, 2, Descending, seqKey)
+	if !reflect.DeepEqual(page, []int64{30, 20}) || next == nil {
+		t.Fatalf("second page = %v, next %v", page, next)
+	}
+	page, next = Page(items, next, 2, Descending, seqKey)
+	if !reflect.DeepEqual(page, []int64{10}) || next != nil {
+		t.Errorf("last page = %v, next %v", page, next)
+	}
+}
+
+func TestDecodeRejectsForeignTokens(t *testing.T) {
+	token := Encode(Fingerprint("merchant-1"), Key{Int(7)})
+	if key, err := Decode(token, Fingerprint("merchant-1")); err != nil || key.Compare(Key{Int(7)}) != 0 {
+		t.Fatalf("Decode() = %v, %v", key, err)
+	}
+	if _, err := Decode(token, Fingerprint("merchant-2")); !errors.Is(err, ErrQueryChanged) {
+		t.Errorf("other query error = %v, want %v", err, ErrQueryChanged)
+	}
+	for _, bad := range []string{"not base64!", "e30", token[:len(token)-4]} {
+		if _, err := Decode(bad, Fingerprint("merchant-1")); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Decode(%q) error = %v, want %v", bad, err, ErrInvalidToken)
+		}
+	}
+	if key, err := Decode("", Fingerprint("merchant-1")); key != nil || err != nil {
+		t.Errorf("empty token = %v, %v", key, err)
+	}
+}
+
+func TestLimitsAndEncodings(t *testing.T) {
+	l := Limits{Default: 50, Max: 200}
+	for requested, want := range map[int]int{0: 50, -1: 50, 10: 10, 200: 200, 5000: 200} {
+		if got := l.Size(requested); got != want {
+			t.Errorf("Size(%d) = %d, want %d", requested, got, want)
+		}
+	}
+
+	if Int(9) >= Int(10) {
+		t.Errorf("Int(9) = %q sorts after Int(10) = %q", Int(9), Int(10))
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 5, time.FixedZone("CET", 3600))
+	if Time(at) >= Time(at.Add(time.Nanosecond)) {
+		t.Error("Time does not sort chronologically")
+	}
+	if back, err := ParseTime(Time(at)); err != nil || !back.Equal(at) {
+		t.Errorf("ParseTime(Time(%v)) = %v, %v", at, back, err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package redact masks cardholder data and key material before it reaches
+// logs, traces or any other output outside the PCI boundary.
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/paymentgateway/go-common/luhn"
+)
+
+// Redacted replaces values that must never be shown, even partially
+const Redacted = "[REDACTED]"
+
+// panFields are field names (JSON/protojson spelling) that carry a PAN
+var panFields = map[string]bool{
+	"pan":         true,
+	"cardNumber":  true,
+	"card_number": true,
+}
+
+// secretFields are field names whose values are dropped entirely
+var secretFields = map[string]bool{
+	"cvv":       true,
+	"cvv2":      true,
+	"pin":       true,
+	"pinBlock":  true,
+	"pin_block": true,
+	"plaintext": true,
+	"keyData":   true,
+	"key_data":  true,
+	"apiKey":    true,
+	"api_key":   true,
+	"track1":    true,
+	"track2":    true,
+	"password":  true,
+	"secret":    true,
+}
+
+// MaskPAN keeps the first six and last four digits of a PAN, the most PCI DSS
+// allows to be displayed, and masks the rest
+func MaskPAN(pan string) string {
+	if len(pan) < 13 {
+		return strings.Repeat("*", len(pan))
+	}
+	return pan[:6] + strings.Repeat("*", len(pan)-10) + pan[len(pan)-4:]
+}
+
+// LooksLikePAN reports whether s is a plausible live PAN: 13-19 digits that
+// pass the Luhn check. Vault tokens start with 9 and are not treated as PANs.
+func LooksLikePAN(s string) bool {
+	return len(s) >= 13 && len(s) <= 19 && s[0] != '9' && luhn.Valid(s)
+}
+
+// Field redacts a single named value
+func Field(name, value string) string {
+	switch {
+	case secretFields[name]:
+		return Redacted
+	case panFields[name] || LooksLikePAN(value):
+		return MaskPAN(value)
+	}
+	return value
+}
+
+// Text masks every PAN-looking run of digits inside free-form text, such as
+// error messages
+func Text(s string) string {
+	var b strings.Builder
+	start := -1
+	flush := func(end int) {
+		run := s[start:end]
+		if LooksLikePAN(run) {
+			run = MaskPAN(run)
+		}
+		b.WriteString(run)
+		start = -1
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			flush(i)
+		}
+		b.WriteByte(s[i])
+	}
+	if start >= 0 {
+		flush(len(s))
+	}
+	return b.String()
+}
+
+// JSON returns a copy of a JSON document with PANs masked and secrets
+// removed. Documents that fail to parse are replaced wholesale, since a
+// partial redaction cannot be trusted.
+func JSON(doc []byte) []byte {
+	if len(doc) == 0 {
+		return doc
+	}
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return []byte(`"` + Redacted + `"`)
+	}
+	out, err := json.Marshal(value("", v))
+	if err != nil {
+		return []byte(`"` + Redacted + `"`)
+	}
+	return out
+}
+
+func value(field string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = value(k, child)
+		}
+		return val
+	case []interface{}:
+		for i, child := range val {
+			val[i] = value(field, child)
+		}
+		return val
+	case string:
+		return Field(field, val)
+	}
+	if secretFields[field] {
+		return Redacted
+	}
+	return v
+}
@@ -0,0 +1,77 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskPAN(t *testing.T) {
+	tests := []struct {
+		pan  string
+		want string
+	}{
+		{"4532015112830366", "453201******0366"},
+		{"378282246310005", "378282*****0005"},
+		{"123", "***"},
+	}
+	for _, tt := range tests {
+		if got := MaskPAN(tt.pan); got != tt.want {
+			t.Errorf("MaskPAN(%s) = %s, want %s", tt.pan, got, tt.want)
+		}
+	}
+}
+
+func TestLooksLikePAN(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"4532015112830366", true},
+		{"4532015112830367", false}, // Luhn failure
+		{"9532015112830366", false}, // token prefix
+		{"453201511283", false},     // too short
+		{"45320151128303ab", false},
+	}
+	for _, tt := range tests {
+		if got := LooksLikePAN(tt.s); got != tt.want {
+			t.Errorf("LooksLikePAN(%s) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	doc := `{"pan":"4532015112830366","cvv":"123","token":"9123456789010366","nested":{"plaintext":"c2VjcmV0","ref":"5425233430109903"},"pins":[{"pin":4321}]}`
+	out := string(JSON([]byte(doc)))
+
+	for _, leaked := range []string{"4532015112830366", "5425233430109903", `"123"`, "c2VjcmV0", "4321"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("redacted output still contains %s: %s", leaked, out)
+		}
+	}
+	for _, want := range []string{`"pan":"453201******0366"`, `"token":"9123456789010366"`, `"ref":"542523******9903"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("redacted output missing %s: %s", want, out)
+		}
+	}
+
+	if got := string(JSON([]byte(`{"pan":`))); got != `"[REDACTED]"` {
+		t.Errorf("malformed JSON should be replaced wholesale, got %s", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"card 4532015112830366 declined", "card 453201******0366 declined"},
+		{"token 9123456789010366 and order 12345", "token 9123456789010366 and order 12345"},
+		{"4532015112830366", "453201******0366"},
+		{"no digits", "no digits"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package reload
+
+import "os"
+
+// FileOwner identifies who owns a file, as "uid:<n>" where the platform
+// reports it
+func FileOwner(info os.FileInfo) string {
+	return ""
+}
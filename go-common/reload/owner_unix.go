@@ -0,0 +1,18 @@
+//go:build unix
+
+package reload
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// FileOwner identifies who owns a file, as "uid:<n>" where the platform
+// reports it
+func FileOwner(info os.FileInfo) string {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return "uid:" + strconv.FormatUint(uint64(st.Uid), 10)
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package reload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	active := ""
+	apply := func(data []byte) ([]string, error) {
+		if strings.Contains(string(data), "bad") {
+			return nil, errors.New("invalid setting")
+		}
+		if string(data) == active {
+			return nil, nil
+		}
+		active = string(data)
+		return []string{"value set to " + active}, nil
+	}
+	var events []Event
+	w := NewWatcher(path, apply, func(e Event) { events = append(events, e) })
+
+	write("one")
+	if err := w.Load(TriggerStartup); err != nil || active != "one" {
+		t.Fatalf("Load(startup) = %v, active %q", err, active)
+	}
+
+	// Polling ignores content that was already applied
+	w.Load(TriggerFileChange)
+	if len(events) != 1 {
+		t.Fatalf("unchanged file produced %d events, want 1", len(events))
+	}
+
+	// A rejected file leaves the active config alone and is reported once
+	write("bad")
+	if err := w.Load(TriggerFileChange); err == nil {
+		t.Fatal("Load() accepted an invalid file")
+	}
+	w.Load(TriggerFileChange)
+	if active != "one" || len(events) != 2 || events[1].Err == nil {
+		t.Fatalf("after rejection: active %q, events %v", active, events)
+	}
+
+	write("two")
+	w.Load(TriggerFileChange)
+	// A signal re-applies even unchanged content
+	w.Load(TriggerSignal)
+
+	if len(events) != 4 || active != "two" {
+		t.Fatalf("events = %v, active %q", events, active)
+	}
+	last := events[3]
+	if last.Trigger != TriggerSignal || last.Digest == "" || last.Path != path {
+		t.Errorf("signal event = %+v", last)
+	}
+	if got := events[2].String(); !strings.Contains(got, "file-change reload of "+path) || !strings.Contains(got, "value set to two") {
+		t.Errorf("event string = %q", got)
+	}
+	if got := last.String(); !strings.HasSuffix(got, ": no changes") {
+		t.Errorf("event string = %q, want no changes", got)
+	}
+}
+
+func TestWatcherMissingFile(t *testing.T) {
+	var events []Event
+	w := NewWatcher(filepath.Join(t.TempDir(), "missing.json"), func([]byte) ([]string, error) {
+		t.Fatal("apply called without a file")
+		return nil, nil
+	}, func(e Event) { events = append(events, e) })
+	if err := w.Load(TriggerSignal); err == nil || len(events) != 1 || events[0].Err == nil {
+		t.Errorf("Load() = %v, events %v", err, events)
+	}
+}
@@ -0,0 +1,74 @@
+// Package securebytes handles secrets such as PANs and key material as byte
+// slices that are wiped when no longer needed. Go strings are immutable and
+// may be copied by the runtime, so a secret converted to a string lingers in
+// memory until the garbage collector reuses it; a byte slice can be zeroized.
+//
+// Zeroization is best effort: it clears the slice it is given, not copies the
+// runtime or other code may have made.
+package securebytes
+
+import (
+	"crypto/subtle"
+	"runtime"
+)
+
+// Redacted is what a Buffer prints as
+const Redacted = "[REDACTED]"
+
+// Zero overwrites b with zeros
+func Zero(b []byte) {
+	clear(b)
+	// Keep b reachable until after the clear, so the writes cannot be
+	// dropped as dead stores
+	runtime.KeepAlive(b)
+}
+
+// Clone returns a copy of b that the caller owns and should Zero
+func Clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
+}
+
+// Equal compares two secrets in constant time
+func Equal(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// EqualString compares two secrets held as strings, such as hex hashes or
+// API keys, in constant time. Only the lengths may leak.
+func EqualString(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Buffer owns a secret until Destroy is called. Its String and GoString
+// methods never reveal the contents, so a Buffer that reaches a log or
+// format verb prints as Redacted.
+type Buffer struct {
+	b []byte
+}
+
+// New takes ownership of b; the caller must not use b afterwards
+func New(b []byte) *Buffer {
+	return &Buffer{b: b}
+}
+
+// Bytes returns the secret, which is valid until Destroy
+func (s *Buffer) Bytes() []byte {
+	return s.b
+}
+
+// Len returns the length of the secret
+func (s *Buffer) Len() int {
+	return len(s.b)
+}
+
+// Destroy zeroizes the secret. It is safe to call more than once.
+func (s *Buffer) Destroy() {
+	Zero(s.b)
+	s.b = nil
+}
+
+func (s *Buffer) String() string   { return Redacted }
+func (s *Buffer) GoString() string { return Redacted }
@@ -0,0 +1,64 @@
+package securebytes
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestZero(t *testing.T) {
+	secret := []byte("4532015112830366")
+	view := secret[4:8]
+	Zero(secret)
+	if !bytes.Equal(secret, make([]byte, 16)) || !bytes.Equal(view, make([]byte, 4)) {
+		t.Errorf("Zero() left %q", secret)
+	}
+	Zero(nil)
+}
+
+func TestClone(t *testing.T) {
+	secret := []byte("key material")
+	clone := Clone(secret)
+	Zero(secret)
+	if string(clone) != "key material" {
+		t.Errorf("Clone() shares memory with its source: %q", clone)
+	}
+	if Clone(nil) != nil {
+		t.Error("Clone(nil) != nil")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !Equal([]byte("abc"), []byte("abc")) || Equal([]byte("abc"), []byte("abd")) || Equal([]byte("abc"), []byte("ab")) {
+		t.Error("Equal() gave a wrong result")
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	raw := []byte("4532015112830366")
+	buf := New(raw)
+	if buf.Len() != 16 || !bytes.Equal(buf.Bytes(), []byte("4532015112830366")) {
+		t.Fatalf("Bytes() = %q", buf.Bytes())
+	}
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		if got := fmt.Sprintf(format, buf); got != Redacted {
+			t.Errorf("Sprintf(%q) = %q, want %q", format, got, Redacted)
+		}
+	}
+
+	buf.Destroy()
+	if !bytes.Equal(raw, make([]byte, 16)) {
+		t.Errorf("Destroy() left %q", raw)
+	}
+	if buf.Bytes() != nil || buf.Len() != 0 {
+		t.Error("Buffer still exposes bytes after Destroy")
+	}
+	buf.Destroy()
+}
+
+func TestEqualString(t *testing.T) {
+	if !EqualString("abc", "abc") || EqualString("abc", "abd") || EqualString("abc", "") {
+		t.Error("EqualString() gave a wrong result")
+	}
+}
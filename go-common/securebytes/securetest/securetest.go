@@ -0,0 +1,120 @@
+// Package securetest lets a module's tests assert that secrets are only
+// compared with securebytes.Equal or EqualString. A plain == on a MAC or PAN
+// hash returns at the first differing byte, which leaks through timing how
+// much of a guess was right.
+package securetest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// SecretName matches identifiers that hold secrets or values derived from
+// them
+var SecretName = regexp.MustCompile(`(?i)(hash|mac$|^mac|hmac|kcv|checkvalue|apikey|secret|fingerprint|signature|credential|password)`)
+
+// Finding is a direct comparison of a secret
+type Finding struct {
+	Pos  token.Position
+	Expr string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Pos, f.Expr)
+}
+
+// Scan parses the non-test Go files under root and returns every == or !=
+// with an operand named like a secret. Comparisons against nil or a literal,
+// such as an empty-string check, reveal nothing and are not reported.
+func Scan(root string) ([]Finding, error) {
+	fset := token.NewFileSet()
+	var findings []Finding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			expr, ok := n.(*ast.BinaryExpr)
+			if !ok || (expr.Op != token.EQL && expr.Op != token.NEQ) {
+				return true
+			}
+			if constant(expr.X) || constant(expr.Y) {
+				return true
+			}
+			if secret(expr.X) || secret(expr.Y) {
+				findings = append(findings, Finding{
+					Pos:  fset.Position(expr.Pos()),
+					Expr: fmt.Sprintf("%s %s %s", name(expr.X), expr.Op, name(expr.Y)),
+				})
+			}
+			return true
+		})
+		return nil
+	})
+	return findings, err
+}
+
+// AssertConstantTime fails t for every direct secret comparison under root
+func AssertConstantTime(t testing.TB, root string) {
+	t.Helper()
+	findings, err := Scan(root)
+	if err != nil {
+		t.Fatalf("scan %s: %v", root, err)
+	}
+	for _, f := range findings {
+		t.Errorf("%s: compare secrets with securebytes.Equal or EqualString", f)
+	}
+}
+
+func constant(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.Ident:
+		return e.Name == "nil"
+	}
+	return false
+}
+
+func secret(e ast.Expr) bool {
+	n := name(e)
+	return n != "" && SecretName.MatchString(n)
+}
+
+// name returns the identifier an operand is read from: x for x, the field for
+// a.b, the slice for s[i:j]
+func name(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.ParenExpr:
+		return name(e.X)
+	case *ast.SliceExpr:
+		return name(e.X)
+	case *ast.StarExpr:
+		return name(e.X)
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package securetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+import "github.com/paymentgateway/go-common/securebytes"
+
+type record struct{ PANHash string }
+
+func leaky(r record, fingerprint, mac, expectedMAC string) bool {
+	return r.PANHash == fingerprint || mac != expectedMAC
+}
+
+func fine(r record, fingerprint string, n int) bool {
+	return r.PANHash != "" && fingerprint != "" && n == 3 &&
+		securebytes.EqualString(r.PANHash, fingerprint)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Tests may compare secrets freely
+	if err := os.WriteFile(filepath.Join(dir, "p_test.go"), []byte("package p\n\nvar _ = \"a\" == hash\n\nvar hash string\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(findings) != 2 || findings[0].Expr != "PANHash == fingerprint" || findings[1].Expr != "mac != expectedMAC" {
+		t.Errorf("Scan() = %v, want the two comparisons in leaky", findings)
+	}
+}
+
+func TestGoCommonConstantTime(t *testing.T) {
+	AssertConstantTime(t, "../..")
+}
@@ -0,0 +1,140 @@
+package validate
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/luhn"
+)
+
+var (
+	tokenPattern    = regexp.MustCompile(`^9\d{12,18}$`)
+	keyIDPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+	cvvPattern      = regexp.MustCompile(`^\d{3,4}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// MaxExpiryYearsAhead bounds how far in the future a card expiry may be
+const MaxExpiryYearsAhead = 10
+
+// Required checks that a string field is set
+func Required(v *Violations, field, value string) bool {
+	if value == "" {
+		v.Add(field, "is required")
+		return false
+	}
+	return true
+}
+
+// PAN checks a card number: 13-19 digits, optionally separated by spaces or
+// dashes, passing the Luhn check
+func PAN(v *Violations, field, pan string) {
+	if !Required(v, field, pan) {
+		return
+	}
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(pan)
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			v.Add(field, "must contain only digits")
+			return
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		v.Add(field, "must be 13-19 digits, got %d", len(digits))
+		return
+	}
+	if !luhn.Valid(digits) {
+		v.Add(field, "fails the Luhn check")
+	}
+}
+
+// Expiry checks that a card expiry month/year is valid, not in the past and
+// at most MaxExpiryYearsAhead years out
+func Expiry(v *Violations, monthField, yearField string, month, year int, now time.Time) {
+	if month < 1 || month > 12 {
+		v.Add(monthField, "must be between 1 and 12, got %d", month)
+	}
+	switch {
+	case year < now.Year():
+		v.Add(yearField, "card expired in %d", year)
+	case year > now.Year()+MaxExpiryYearsAhead:
+		v.Add(yearField, "must be at most %d years ahead", MaxExpiryYearsAhead)
+	case year == now.Year() && month >= 1 && month < int(now.Month()):
+		v.Add(monthField, "card expired in %02d/%d", month, year)
+	}
+}
+
+// CVV checks an optional card verification value
+func CVV(v *Violations, field, cvv string) {
+	if cvv != "" && !cvvPattern.MatchString(cvv) {
+		v.Add(field, "must be 3 or 4 digits")
+	}
+}
+
+// Token checks the vault token format: 13-19 digits starting with 9
+func Token(v *Violations, field, token string) {
+	if !Required(v, field, token) {
+		return
+	}
+	if !tokenPattern.MatchString(token) {
+		v.Add(field, "must be 13-19 digits starting with 9")
+	}
+}
+
+// KeyID checks an HSM key identifier
+func KeyID(v *Violations, field, keyID string) {
+	if !Required(v, field, keyID) {
+		return
+	}
+	if !keyIDPattern.MatchString(keyID) {
+		v.Add(field, "must be 1-128 letters, digits, '.', '_' or '-'")
+	}
+}
+
+// MaxLen checks the length of an optional string field
+func MaxLen(v *Violations, field, value string, max int) {
+	if len(value) > max {
+		v.Add(field, "must be at most %d characters", max)
+	}
+}
+
+// Bytes checks the size of a bytes field
+func Bytes(v *Violations, field string, value []byte, min, max int) {
+	switch {
+	case len(value) < min && min == 1:
+		v.Add(field, "is required")
+	case len(value) < min:
+		v.Add(field, "must be at least %d bytes", min)
+	case max > 0 && len(value) > max:
+		v.Add(field, "must be at most %d bytes, got %d", max, len(value))
+	}
+}
+
+// Range checks an integer field against inclusive bounds
+func Range(v *Violations, field string, value, min, max int64) {
+	if value < min || value > max {
+		v.Add(field, "must be between %d and %d, got %d", min, max, value)
+	}
+}
+
+// Amount checks a monetary amount in minor units
+func Amount(v *Violations, field string, minor, min, max int64) {
+	if minor <= 0 {
+		v.Add(field, "must be positive")
+		return
+	}
+	if minor < min || minor > max {
+		v.Add(field, "must be between %d and %d minor units", min, max)
+	}
+}
+
+// Currency checks an ISO 4217 alphabetic currency code
+func Currency(v *Violations, field, currency string) {
+	if !Required(v, field, currency) {
+		return
+	}
+	if !currencyPattern.MatchString(currency) {
+		v.Add(field, "must be a three-letter ISO 4217 code")
+	}
+}
@@ -0,0 +1,123 @@
+// Package validate checks gRPC requests before they reach a handler and
+// reports every failing field at once, as google.rpc.BadRequest details on an
+// InvalidArgument status. Request messages opt in by implementing Validator,
+// typically in a file next to their generated code.
+package validate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Validator is implemented by request messages that have field rules
+type Validator interface {
+	Validate(v *Violations)
+}
+
+// Violation is a single failing field
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Violations collects the failing fields of one request
+type Violations struct {
+	list []Violation
+}
+
+// Add records a violation
+func (v *Violations) Add(field, format string, args ...interface{}) {
+	v.list = append(v.list, Violation{Field: field, Description: fmt.Sprintf(format, args...)})
+}
+
+// List returns the recorded violations in the order they were added
+func (v *Violations) List() []Violation {
+	return v.list
+}
+
+// Err returns nil when no rule failed, otherwise an InvalidArgument status
+// whose message names the fields and whose details list each violation
+func (v *Violations) Err() error {
+	if len(v.list) == 0 {
+		return nil
+	}
+
+	fields := make([]string, len(v.list))
+	details := &errdetails.BadRequest{}
+	for i, violation := range v.list {
+		fields[i] = violation.Field
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Description,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(fields, ", "))
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Request runs the message's rules, if it has any
+func Request(req interface{}) error {
+	validator, ok := req.(Validator)
+	if !ok {
+		return nil
+	}
+	var v Violations
+	validator.Validate(&v)
+	return v.Err()
+}
+
+// FieldViolations extracts the per-field details from an error returned by
+// Violations.Err, for clients and tests
+func FieldViolations(err error) []Violation {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	var out []Violation
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, fv := range br.FieldViolations {
+				out = append(out, Violation{Field: fv.Field, Description: fv.Description})
+			}
+		}
+	}
+	return out
+}
+
+// UnaryServerInterceptor rejects requests that fail their field rules
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := Request(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor validates every message a client streams in
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return Request(m)
+}
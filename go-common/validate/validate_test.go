@@ -0,0 +1,111 @@
+package validate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type cardRequest struct {
+	pan         string
+	month, year int
+	cvv         string
+}
+
+func (r *cardRequest) Validate(v *Violations) {
+	PAN(v, "pan", r.pan)
+	Expiry(v, "expiry_month", "expiry_year", r.month, r.year, time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC))
+	CVV(v, "cvv", r.cvv)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    cardRequest
+		fields []string
+	}{
+		{"valid", cardRequest{"4532015112830366", 12, 2027, "123"}, nil},
+		{"valid with spaces", cardRequest{"4532 0151 1283 0366", 6, 2026, ""}, nil},
+		{"bad Luhn", cardRequest{"4532015112830367", 12, 2027, "123"}, []string{"pan"}},
+		{"too short", cardRequest{"453201", 12, 2027, "123"}, []string{"pan"}},
+		{"letters", cardRequest{"4532abcd12830366", 12, 2027, "123"}, []string{"pan"}},
+		{"missing PAN", cardRequest{"", 12, 2027, "123"}, []string{"pan"}},
+		{"bad month", cardRequest{"4532015112830366", 13, 2027, "123"}, []string{"expiry_month"}},
+		{"expired year", cardRequest{"4532015112830366", 12, 2025, "123"}, []string{"expiry_year"}},
+		{"expired this year", cardRequest{"4532015112830366", 5, 2026, "123"}, []string{"expiry_month"}},
+		{"too far ahead", cardRequest{"4532015112830366", 1, 2037, "123"}, []string{"expiry_year"}},
+		{"everything wrong", cardRequest{"1", 0, 2000, "12"}, []string{"pan", "expiry_month", "expiry_year", "cvv"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Violations
+			tt.req.Validate(&v)
+			got := v.List()
+			if len(got) != len(tt.fields) {
+				t.Fatalf("violations = %+v, want fields %v", got, tt.fields)
+			}
+			for i, f := range tt.fields {
+				if got[i].Field != f {
+					t.Errorf("violation %d field = %s, want %s", i, got[i].Field, f)
+				}
+			}
+		})
+	}
+}
+
+func TestOtherRules(t *testing.T) {
+	var v Violations
+	Token(&v, "token", "9123456789010366")
+	KeyID(&v, "key_id", "tokenization-key-1")
+	Amount(&v, "amount", 1000, 1, 100000000)
+	Currency(&v, "currency", "USD")
+	Bytes(&v, "nonce", make([]byte, 12), 12, 12)
+	if len(v.List()) != 0 {
+		t.Fatalf("valid values rejected: %+v", v.List())
+	}
+
+	Token(&v, "token", "4532015112830366")
+	KeyID(&v, "key_id", "bad key!")
+	Amount(&v, "amount", 0, 1, 100)
+	Amount(&v, "amount", 500, 1, 100)
+	Currency(&v, "currency", "usd")
+	Bytes(&v, "nonce", nil, 12, 12)
+	Bytes(&v, "plaintext", nil, 1, 16)
+	Range(&v, "key_version", 0, 1, 1<<20)
+	if len(v.List()) != 8 {
+		t.Fatalf("got %d violations, want 8: %+v", len(v.List()), v.List())
+	}
+}
+
+func TestInterceptorReturnsFieldDetails(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/tokenization.v1.TokenizationService/TokenizeCard"}
+
+	_, err := interceptor(context.Background(), &cardRequest{"4532015112830367", 13, 2027, ""}, info, handler)
+	if called {
+		t.Fatal("handler ran for an invalid request")
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("code = %v, want InvalidArgument", status.Code(err))
+	}
+	if msg := status.Convert(err).Message(); msg != "invalid request: pan, expiry_month" {
+		t.Errorf("message = %q", msg)
+	}
+	violations := FieldViolations(err)
+	if len(violations) != 2 || violations[0].Description != "fails the Luhn check" {
+		t.Errorf("details = %+v", violations)
+	}
+
+	if _, err := interceptor(context.Background(), "no rules", info, handler); err != nil || !called {
+		t.Errorf("requests without rules should pass through, err = %v", err)
+	}
+}
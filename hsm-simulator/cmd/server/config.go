@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/reload"
+	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+)
+
+// fileConfig is the reloadable configuration read from HSM_CONFIG_FILE.
+// Absent fields leave the setting as it is.
+type fileConfig struct {
+	// Profile names a performance profile, or "none" to remove it
+	Profile *string `json:"profile"`
+	// APIKeys replaces the API keys, in the HSM_API_KEYS format
+	APIKeys *string `json:"api_keys"`
+	// Permissions replaces the permission matrix: "default", "separated" or
+	// an object of subjects to commands, as in HSM_PERMISSIONS
+	Permissions json.RawMessage `json:"permissions"`
+}
+
+// configReloader applies fileConfig to the running server
+type configReloader struct {
+	hsm  *hsm.HSM
+	keys *interceptors.KeySet
+	// started is set once the interceptor chain is built; after that a
+	// reload can change the keys but not turn authentication on or off
+	started bool
+}
+
+func (r *configReloader) apply(data []byte) ([]string, error) {
+	var cfg fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Validate everything before changing anything
+	var profile *hsm.Profile
+	if cfg.Profile != nil && *cfg.Profile != "none" {
+		p, err := hsm.LookupProfile(*cfg.Profile)
+		if err != nil {
+			return nil, err
+		}
+		profile = &p
+	}
+	var keys interceptors.StaticKeys
+	if cfg.APIKeys != nil {
+		var err error
+		if keys, err = interceptors.ParseStaticKeys(*cfg.APIKeys); err != nil {
+			return nil, fmt.Errorf("invalid api_keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil, errors.New("api_keys is empty; authentication cannot be turned off by a reload")
+		}
+		if r.keys == nil && r.started {
+			return nil, errors.New("api_keys needs authentication enabled at startup")
+		}
+	}
+
+	var permissions hsm.PermissionMatrix
+	if cfg.Permissions != nil {
+		var err error
+		if permissions, err = parsePermissions(cfg.Permissions); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []string
+	if cfg.Profile != nil {
+		old, next := "none", "none"
+		if p, ok := r.hsm.Profile(); ok {
+			old = p.Name
+		}
+		if profile != nil {
+			next = profile.Name
+		}
+		if old != next {
+			r.hsm.SetProfile(profile)
+			changes = append(changes, fmt.Sprintf("profile %s -> %s", old, next))
+		}
+	}
+	if keys != nil {
+		if r.keys == nil {
+			r.keys = interceptors.NewKeySet(keys)
+			changes = append(changes, "authentication enabled")
+		} else {
+			changes = append(changes, r.keys.Replace(keys)...)
+		}
+	}
+	if permissions != nil {
+		changes = append(changes, r.hsm.SetPermissions(permissions)...)
+	}
+	return changes, nil
+}
+
+// parsePermissions reads the permissions setting, either the name of a
+// built-in matrix as a JSON string or the matrix itself
+func parsePermissions(raw json.RawMessage) (hsm.PermissionMatrix, error) {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return hsm.ParsePermissions(name)
+	}
+	return hsm.ParsePermissions(string(raw))
+}
+
+// audit logs a reload and records it in the HSM audit log
+func (r *configReloader) audit(e reload.Event) {
+	log.Printf("Config %s", e)
+	requester := string(e.Trigger)
+	if e.Owner != "" {
+		requester += " " + e.Owner
+	}
+	detail := strings.Join(e.Changes, "; ")
+	if e.Digest != "" {
+		detail = strings.TrimPrefix(detail+"; sha256:"+e.Digest, "; ")
+	}
+	r.hsm.RecordConfigReload(requester, detail, e.Err)
+}
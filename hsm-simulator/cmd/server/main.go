@@ -1,18 +1,34 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/paymentgateway/go-common/diagnostics"
+	"github.com/paymentgateway/go-common/grpcopts"
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/metrics"
+	"github.com/paymentgateway/go-common/reload"
 	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+	"github.com/paymentgateway/hsm-simulator/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
-	defaultPort = "8444"
+	defaultPort           = "8444"
+	defaultMetricsPort    = "9444"
+	defaultReloadInterval = 5 * time.Second
 )
 
 func main() {
@@ -20,20 +36,212 @@ func main() {
 	if port == "" {
 		port = defaultPort
 	}
+	metricsPort := os.Getenv("METRICS_PORT")
+	if metricsPort == "" {
+		metricsPort = defaultMetricsPort
+	}
+
+	registry := metrics.NewRegistry()
+	diagnostics.RegisterRuntimeMetrics(registry)
+
+	// Profiles and goroutine dumps on an internal port, for load tests
+	diagCfg, err := diagnostics.FromEnv("HSM_", os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if capturer, err := diagnostics.Serve("hsm", diagCfg); err != nil {
+		log.Fatalf("Failed to start diagnostics: %v", err)
+	} else if capturer != nil {
+		log.Printf("Diagnostics on %s, captures in %s", diagCfg.Addr, capturer.Dir())
+	}
+
+	// Message limits, compression and keepalive of the gRPC server and of
+	// a standby's connection to its primary
+	transport, err := grpcopts.FromEnv("HSM_", os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// TLS is opt-in. The certificate, which also identifies a standby to
+	// its primary, is reloaded when rotated.
+	tlsReloader := loadTLS("HSM_", true)
+	peerCreds := insecure.NewCredentials()
+	if tlsReloader != nil {
+		peerCreds = tlsReloader.ClientCredentials()
+	}
+
+	// Create HSM instance. Dual control holds key destruction and imports
+	// until a second operator approves them, which needs API keys so the
+	// operators can be told apart.
+	var opts []hsm.Option
+	if os.Getenv("HSM_DUAL_CONTROL") == "true" {
+		if os.Getenv("HSM_API_KEYS") == "" {
+			log.Fatal("HSM_DUAL_CONTROL requires HSM_API_KEYS")
+		}
+		var ttl time.Duration
+		if v := os.Getenv("HSM_APPROVAL_TTL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Invalid HSM_APPROVAL_TTL: %q", v)
+			}
+			ttl = d
+		}
+		opts = append(opts, hsm.WithDualControl(ttl))
+	}
+
+	// A performance profile makes latency and throughput resemble real
+	// hardware for capacity tests
+	if name := os.Getenv("HSM_PROFILE"); name != "" {
+		profile, err := hsm.LookupProfile(name)
+		if err != nil {
+			log.Fatalf("Invalid HSM_PROFILE: %v", err)
+		}
+		opts = append(opts, hsm.WithProfile(profile))
+		log.Printf("HSM performance profile %s (%d ops/s)", profile.Name, profile.MaxOpsPerSecond)
+	}
+
+	// A shared transport key lets two simulators form an HA pair
+	if v := os.Getenv("HSM_TRANSPORT_KEY"); v != "" {
+		key, err := hex.DecodeString(v)
+		if err != nil || len(key) != 32 {
+			log.Fatal("Invalid HSM_TRANSPORT_KEY: want 64 hex characters")
+		}
+		opts = append(opts, hsm.WithTransportKey(key))
+	}
+	// A permission matrix decides which commands each operator role may
+	// call; the default one keeps destructive commands for admins
+	if spec := os.Getenv("HSM_PERMISSIONS"); spec != "" {
+		matrix, err := hsm.ParsePermissions(spec)
+		if err != nil {
+			log.Fatalf("Invalid HSM_PERMISSIONS: %v", err)
+		}
+		opts = append(opts, hsm.WithPermissions(matrix))
+	}
+	// Nonces are checked for reuse under their key version; "flag" keeps a
+	// reused nonce instead of drawing another
+	policy, err := hsm.ParseNonceReusePolicy(os.Getenv("HSM_NONCE_REUSE"))
+	if err != nil {
+		log.Fatalf("Invalid HSM_NONCE_REUSE: %v", err)
+	}
+	opts = append(opts, hsm.WithNonceCheck(hsm.NonceCheckConfig{Policy: policy, Metrics: registry}))
+	// Envelope plaintexts are compressed on request; the limit bounds them
+	// before compression and after decompression
+	compression := hsm.CompressionConfig{Metrics: registry}
+	if v := os.Getenv("HSM_COMPRESS_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > server.MaxCompressiblePlaintextBytes {
+			log.Fatalf("Invalid HSM_COMPRESS_MAX_BYTES: want 1 to %d", server.MaxCompressiblePlaintextBytes)
+		}
+		compression.MaxBytes = n
+	}
+	opts = append(opts, hsm.WithCompression(compression))
+	hsmService := hsm.NewHSM(opts...)
 
-	// Create HSM instance
-	hsmService := hsm.NewHSM()
+	// Build the interceptor chain. Authentication is only enabled when API
+	// keys are configured, so local simulations keep working without them.
+	cfg := interceptors.Config{
+		Service:    "hsm-simulator",
+		Metrics:    registry,
+		Validation: true,
+		PublicMethods: []string{
+			"/hsm.v1.HSMService/GetServiceInfo",
+			"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+		},
+	}
+	var keySet *interceptors.KeySet
+	if spec := os.Getenv("HSM_API_KEYS"); spec != "" {
+		keys, err := interceptors.ParseStaticKeys(spec)
+		if err != nil {
+			log.Fatalf("Invalid HSM_API_KEYS: %v", err)
+		}
+		keySet = interceptors.NewKeySet(keys)
+	}
+
+	// A config file overrides the profile and API keys and is reloaded on
+	// SIGHUP or when it changes
+	if path := os.Getenv("HSM_CONFIG_FILE"); path != "" {
+		interval := defaultReloadInterval
+		if v := os.Getenv("HSM_CONFIG_RELOAD_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				log.Fatalf("Invalid HSM_CONFIG_RELOAD_INTERVAL: %q", v)
+			}
+			interval = d
+		}
+		reloader := &configReloader{hsm: hsmService, keys: keySet}
+		watcher := reload.NewWatcher(path, reloader.apply, reloader.audit)
+		if err := watcher.Load(reload.TriggerStartup); err != nil {
+			log.Fatalf("Invalid HSM_CONFIG_FILE: %v", err)
+		}
+		keySet, reloader.started = reloader.keys, true
+		go watcher.Run(context.Background(), interval)
+	}
+	if keySet != nil {
+		cfg.Authenticator = keySet
+	}
+
+	// With a primary configured this HSM is its standby: it pulls key
+	// changes until promoted
+	var replicator *hsm.Replicator
+	if primary := os.Getenv("HSM_PRIMARY_ADDR"); primary != "" {
+		interval := time.Second
+		if v := os.Getenv("HSM_REPLICATION_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Invalid HSM_REPLICATION_INTERVAL: %q", v)
+			}
+			interval = d
+		}
+		conn, err := grpc.Dial(primary, append(transport.DialOptions(), grpc.WithTransportCredentials(peerCreds))...)
+		if err != nil {
+			log.Fatalf("Failed to set up connection to primary HSM: %v", err)
+		}
+		defer conn.Close()
+		source := server.NewRemoteSource(server.NewHSMServiceClient(conn), os.Getenv("HSM_PRIMARY_API_KEY"))
+		replicator, err = hsm.NewReplicator(hsmService, source, registry)
+		if err != nil {
+			log.Fatalf("HSM_PRIMARY_ADDR needs HSM_TRANSPORT_KEY: %v", err)
+		}
+		go replicator.Run(context.Background(), interval)
+		log.Printf("Standby of primary HSM %s, syncing every %s", primary, interval)
+	}
+
+	// Authenticated calls are checked against the permission matrix before
+	// the HSM runs or audits them
+	hsmServer := server.NewServer(hsmService, replicator)
+	cfg.Authorizer = hsmServer
+
+	serverOpts := append(interceptors.ServerOptions(cfg), transport.ServerOptions()...)
+	if tlsReloader != nil {
+		serverOpts = append(serverOpts, grpc.Creds(tlsReloader.ServerCredentials()))
+	}
+	// A bounded worker pool serves calls tagged for authorization before
+	// standard and batch ones once it is saturated
+	if v := os.Getenv("HSM_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid HSM_WORKERS: %q", v)
+		}
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(server.Prioritize(hsm.NewWorkerPool(n, registry))))
+		log.Printf("HSM worker pool of %d with priority lanes", n)
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
+	server.RegisterHSMServiceServer(grpcServer, hsmServer)
+	reflection.Register(grpcServer)
 
-	// For now, just start a simple TCP listener
-	// In a full implementation, this would be a gRPC server
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		log.Fatalf("Failed to listen on port %s: %v", port, err)
 	}
-	defer listener.Close()
 
-	log.Printf("HSM Simulator started on port %s", port)
-	log.Printf("HSM instance initialized: %v", hsmService != nil)
+	// Serve Prometheus metrics on a separate port
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry.Handler())
+		if err := http.ListenAndServe(fmt.Sprintf(":%s", metricsPort), mux); err != nil {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -42,10 +250,11 @@ func main() {
 	go func() {
 		<-sigChan
 		log.Println("Shutting down HSM Simulator...")
-		listener.Close()
-		os.Exit(0)
+		grpcServer.GracefulStop()
 	}()
 
-	// Keep the server running
-	select {}
+	log.Printf("HSM Simulator started on port %s (metrics on %s)", port, metricsPort)
+	if err := grpcServer.Serve(listener); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
 }
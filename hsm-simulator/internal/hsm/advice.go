@@ -0,0 +1,182 @@
+package hsm
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Key rollover advice tells connected clients about key lifecycle changes as
+// they happen: a new current version after a rotation, a version queued for
+// destruction, and a version gone. Clients that cache key metadata refresh
+// it then instead of finding out on their next failed call.
+
+// ErrAdviceSubscriberBehind is returned by a subscription dropped for not
+// keeping up with the advice published to it
+var ErrAdviceSubscriberBehind = errors.New("key advice subscriber fell behind")
+
+// DefaultAdviceBuffer is the number of advice messages a subscriber may fall
+// behind before it is dropped
+const DefaultAdviceBuffer = 256
+
+// AdviceKind names a key lifecycle event
+type AdviceKind string
+
+const (
+	// AdviceCurrent describes a key as it is, sent when a subscription opens
+	AdviceCurrent AdviceKind = "current"
+	// AdviceRotated announces a new current version
+	AdviceRotated AdviceKind = "rotated"
+	// AdviceRetiring announces a destruction awaiting a second operator's
+	// approval, which it gets by Deadline or never
+	AdviceRetiring AdviceKind = "retiring"
+	// AdviceRetirementCancelled withdraws an AdviceRetiring: the destruction
+	// was rejected, expired or failed
+	AdviceRetirementCancelled AdviceKind = "retirement-cancelled"
+	// AdviceRetired announces that a version was destroyed
+	AdviceRetired AdviceKind = "retired"
+)
+
+// Advice is one key lifecycle event
+type Advice struct {
+	Seq   uint64
+	Time  time.Time
+	Kind  AdviceKind
+	KeyID string
+	// Version is the version the event is about: the new one of a rotation,
+	// or the one being retired
+	Version int
+	// CurrentVersion is the version the HSM encrypts under after the event
+	CurrentVersion int
+	// OperationID and Deadline identify a pending destruction
+	OperationID string
+	Deadline    time.Time
+}
+
+// AdviceSubscription receives the advice published after it was opened
+type AdviceSubscription struct {
+	h   *HSM
+	ch  chan Advice
+	err error
+}
+
+// SubscribeAdvice opens a subscription to key advice. Close it when done.
+func (h *HSM) SubscribeAdvice() *AdviceSubscription {
+	h.adviceMu.Lock()
+	defer h.adviceMu.Unlock()
+	if h.adviceSubs == nil {
+		h.adviceSubs = make(map[*AdviceSubscription]struct{})
+	}
+	sub := &AdviceSubscription{h: h, ch: make(chan Advice, DefaultAdviceBuffer)}
+	h.adviceSubs[sub] = struct{}{}
+	return sub
+}
+
+// Advice returns the channel of advice. It is closed when the subscription
+// is closed or dropped.
+func (sub *AdviceSubscription) Advice() <-chan Advice {
+	return sub.ch
+}
+
+// Err returns ErrAdviceSubscriberBehind once the subscription was dropped
+func (sub *AdviceSubscription) Err() error {
+	sub.h.adviceMu.Lock()
+	defer sub.h.adviceMu.Unlock()
+	return sub.err
+}
+
+// Close ends the subscription
+func (sub *AdviceSubscription) Close() {
+	sub.h.adviceMu.Lock()
+	defer sub.h.adviceMu.Unlock()
+	sub.h.removeSubscriberLocked(sub)
+}
+
+// CurrentAdvice describes the current version of each key, or of every key
+// if keyIDs is empty, in key ID order. Unknown keys are skipped.
+func (h *HSM) CurrentAdvice(keyIDs ...string) []Advice {
+	h.mu.RLock()
+	if len(keyIDs) == 0 {
+		for id := range h.keys {
+			keyIDs = append(keyIDs, id)
+		}
+	}
+	keys := make([]*Key, 0, len(keyIDs))
+	for _, id := range keyIDs {
+		if key, ok := h.keys[id]; ok {
+			keys = append(keys, key)
+		}
+	}
+	h.mu.RUnlock()
+
+	now := h.now()
+	out := make([]Advice, 0, len(keys))
+	for _, key := range keys {
+		key.mu.RLock()
+		out = append(out, Advice{
+			Time:           now,
+			Kind:           AdviceCurrent,
+			KeyID:          key.ID,
+			Version:        key.CurrentVersion,
+			CurrentVersion: key.CurrentVersion,
+		})
+		key.mu.RUnlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].KeyID < out[j].KeyID })
+	return out
+}
+
+// advise publishes advice to every subscriber. It never blocks, as callers
+// hold key locks: a subscriber whose buffer is full is dropped and must
+// resubscribe, which brings it up to date.
+func (h *HSM) advise(a Advice) {
+	h.adviceMu.Lock()
+	defer h.adviceMu.Unlock()
+
+	h.adviceSeq++
+	a.Seq = h.adviceSeq
+	a.Time = h.now()
+	for sub := range h.adviceSubs {
+		select {
+		case sub.ch <- a:
+		default:
+			sub.err = ErrAdviceSubscriberBehind
+			h.removeSubscriberLocked(sub)
+		}
+	}
+}
+
+// adviseDecided withdraws the retirement advice of a destruction that did
+// not go ahead. Without dual control nothing was announced beforehand.
+func (h *HSM) adviseDecided(op *Operation) {
+	if op.Kind != OpDestroyKeyVersion || !h.dualControl || op.State == StateExecuted {
+		return
+	}
+	h.advise(Advice{
+		Kind:           AdviceRetirementCancelled,
+		KeyID:          op.KeyID,
+		Version:        op.Version,
+		CurrentVersion: h.currentVersion(op.KeyID),
+		OperationID:    op.ID,
+	})
+}
+
+func (h *HSM) removeSubscriberLocked(sub *AdviceSubscription) {
+	if _, ok := h.adviceSubs[sub]; ok {
+		delete(h.adviceSubs, sub)
+		close(sub.ch)
+	}
+}
+
+// currentVersion returns a key's current version, or 0 if it is unknown
+func (h *HSM) currentVersion(keyID string) int {
+	h.mu.RLock()
+	key, ok := h.keys[keyID]
+	h.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return key.CurrentVersion
+}
@@ -0,0 +1,94 @@
+package hsm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// nextAdvice returns the next advice or fails if none arrives
+func nextAdvice(t *testing.T, sub *AdviceSubscription) Advice {
+	t.Helper()
+	select {
+	case a, ok := <-sub.Advice():
+		if !ok {
+			t.Fatalf("subscription closed: %v", sub.Err())
+		}
+		return a
+	case <-time.After(time.Second):
+		t.Fatal("no advice published")
+	}
+	return Advice{}
+}
+
+func TestAdviceOnRotationAndRetirement(t *testing.T) {
+	h := NewHSM(WithDualControl(time.Hour))
+	h.GenerateKey("key", "AES-256-GCM")
+	sub := h.SubscribeAdvice()
+	defer sub.Close()
+
+	h.RotateKey("key")
+	if a := nextAdvice(t, sub); a.Kind != AdviceRotated || a.Version != 2 || a.CurrentVersion != 2 || a.Seq == 0 {
+		t.Errorf("after RotateKey: %+v; want rotated to version 2", a)
+	}
+
+	op, _ := h.DestroyKeyVersion("alice", "key", 1)
+	a := nextAdvice(t, sub)
+	if a.Kind != AdviceRetiring || a.Version != 1 || a.OperationID != op.ID || !a.Deadline.Equal(op.ExpiresAt) {
+		t.Errorf("after DestroyKeyVersion: %+v; want version 1 retiring by %v", a, op.ExpiresAt)
+	}
+	h.RejectOperation(op.ID, "bob")
+	if a := nextAdvice(t, sub); a.Kind != AdviceRetirementCancelled || a.OperationID != op.ID {
+		t.Errorf("after RejectOperation: %+v; want the retirement cancelled", a)
+	}
+
+	op, _ = h.DestroyKeyVersion("alice", "key", 1)
+	nextAdvice(t, sub)
+	h.ApproveOperation(op.ID, "bob")
+	if a := nextAdvice(t, sub); a.Kind != AdviceRetired || a.Version != 1 || a.CurrentVersion != 2 {
+		t.Errorf("after ApproveOperation: %+v; want version 1 retired", a)
+	}
+}
+
+func TestAdviceWithoutDualControl(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("key", "AES-256-GCM")
+	h.RotateKey("key")
+	sub := h.SubscribeAdvice()
+	defer sub.Close()
+
+	h.DestroyKeyVersion("alice", "key", 1)
+	if a := nextAdvice(t, sub); a.Kind != AdviceRetired {
+		t.Errorf("advice = %+v; want retired without a retiring notice first", a)
+	}
+}
+
+func TestAdviceSubscriberBehind(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("key", "AES-256-GCM")
+	sub := h.SubscribeAdvice()
+	for i := 0; i <= DefaultAdviceBuffer; i++ {
+		h.RotateKey("key")
+	}
+	for range sub.Advice() {
+	}
+	if err := sub.Err(); !errors.Is(err, ErrAdviceSubscriberBehind) {
+		t.Errorf("Err() = %v, want %v", err, ErrAdviceSubscriberBehind)
+	}
+	sub.Close() // closing a dropped subscription is harmless
+}
+
+func TestCurrentAdvice(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("b", "AES-256-GCM")
+	h.GenerateKey("a", "AES-256-GCM")
+	h.RotateKey("a")
+
+	all := h.CurrentAdvice()
+	if len(all) != 2 || all[0].KeyID != "a" || all[0].CurrentVersion != 2 || all[0].Kind != AdviceCurrent {
+		t.Errorf("CurrentAdvice() = %+v; want a at version 2, then b", all)
+	}
+	if some := h.CurrentAdvice("b", "missing"); len(some) != 1 || some[0].KeyID != "b" {
+		t.Errorf("CurrentAdvice(b, missing) = %+v; want b only", some)
+	}
+}
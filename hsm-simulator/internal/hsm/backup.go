@@ -0,0 +1,110 @@
+package hsm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var ErrInvalidBackup = errors.New("invalid key backup")
+
+// BackupKeys returns every key version the HSM holds, wrapped under the
+// transport key like a standby's resync, so only an HSM sharing that key
+// can restore it. Destroyed versions are not part of a backup.
+func (h *HSM) BackupKeys() ([]Change, error) {
+	gcm, err := h.transportGCM()
+	if err != nil {
+		h.logAudit("BackupKeys", "", 0, false, err.Error())
+		return nil, err
+	}
+
+	h.changeMu.Lock()
+	head := h.changeSeq
+	h.changeMu.Unlock()
+
+	var pending []change
+	for _, c := range h.resync(head) {
+		if c.kind == ChangeVersion {
+			pending = append(pending, c)
+		}
+	}
+	backup, err := h.wrapChanges(gcm, pending)
+	if err != nil {
+		h.logAudit("BackupKeys", "", 0, false, err.Error())
+		return nil, err
+	}
+	h.logAudit("BackupKeys", "", 0, true, "")
+	return backup, nil
+}
+
+// RestoreKeys reinstalls the key versions of a backup taken with
+// BackupKeys, e.g. a version destroyed by mistake. Restoring only adds:
+// versions the HSM holds and the backup lacks are kept, and a version the
+// HSM holds with other material fails the restore. Under dual control the
+// restore waits for a second operator's approval.
+func (h *HSM) RestoreKeys(requester string, backup []Change) (*Operation, error) {
+	if err := h.checkRestore(backup); err != nil {
+		h.logAuditBy(string(OpRestoreKeys), "", 0, err, requester, "")
+		return nil, err
+	}
+	return h.submit(&Operation{
+		Kind:           OpRestoreKeys,
+		BackupVersions: len(backup),
+		Requester:      requester,
+		backup:         append([]Change(nil), backup...),
+	})
+}
+
+// checkRestore unwraps every version of a backup and compares it with the
+// keys held, so an approver is never asked to sign off on a restore that
+// cannot succeed
+func (h *HSM) checkRestore(backup []Change) error {
+	if err := h.checkWritable(); err != nil {
+		return err
+	}
+	gcm, err := h.transportGCM()
+	if err != nil {
+		return err
+	}
+	if len(backup) == 0 {
+		return fmt.Errorf("%w: no key versions", ErrInvalidBackup)
+	}
+	for _, ch := range backup {
+		if ch.Kind != ChangeVersion {
+			return fmt.Errorf("%w: %s version %d is a %q change", ErrInvalidBackup, ch.KeyID, ch.Version, ch.Kind)
+		}
+		keyData, err := gcm.Open(nil, ch.Nonce, ch.WrappedKey, changeAAD(ch))
+		if err != nil {
+			return fmt.Errorf("%w: %s version %d does not unwrap under the transport key", ErrInvalidBackup, ch.KeyID, ch.Version)
+		}
+		err = h.checkRestoreVersion(ch, keyData)
+		securebytes.Zero(keyData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *HSM) checkRestoreVersion(ch Change, keyData []byte) error {
+	h.mu.RLock()
+	key, exists := h.keys[ch.KeyID]
+	h.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return key.conflictLocked(ch, keyData)
+}
+
+// restoreKeys re-checks the backup, since keys may have changed while the
+// restore waited for approval, and installs it
+func (h *HSM) restoreKeys(backup []Change) error {
+	if err := h.checkRestore(backup); err != nil {
+		return err
+	}
+	return h.ApplyChanges(backup)
+}
@@ -0,0 +1,87 @@
+package hsm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRestoreKeysUnderDualControl(t *testing.T) {
+	h := NewHSM(WithTransportKey(testTransportKey), WithDualControl(time.Hour))
+	h.GenerateKey("key", "AES-256-GCM")
+	ciphertext, nonce, version, _ := h.Encrypt("key", []byte("secret"), nil)
+	h.RotateKey("key")
+
+	backup, err := h.BackupKeys()
+	if err != nil || len(backup) != 2 {
+		t.Fatalf("BackupKeys() = %d versions, %v; want 2", len(backup), err)
+	}
+	for _, ch := range backup {
+		if bytes.Contains(ch.WrappedKey, []byte("secret")) || ch.Kind != ChangeVersion {
+			t.Fatalf("backup change = %+v", ch)
+		}
+	}
+
+	destroy, _ := h.DestroyKeyVersion("alice", "key", version)
+	h.ApproveOperation(destroy.ID, "bob")
+	if _, err := h.Decrypt("key", ciphertext, nonce, nil, version); !errors.Is(err, ErrInvalidKeyVersion) {
+		t.Fatalf("decrypt after destroy: error = %v", err)
+	}
+
+	op, err := h.RestoreKeys("alice", backup)
+	if err != nil || op.State != StatePending || op.Kind != OpRestoreKeys || op.BackupVersions != 2 {
+		t.Fatalf("RestoreKeys() = %+v, %v; want pending", op, err)
+	}
+	if _, err := h.Decrypt("key", ciphertext, nonce, nil, version); !errors.Is(err, ErrInvalidKeyVersion) {
+		t.Fatal("version restored before approval")
+	}
+	if _, err := h.ApproveOperation(op.ID, "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("self approval: error = %v, want %v", err, ErrSelfApproval)
+	}
+	approved, err := h.ApproveOperation(op.ID, "bob")
+	if err != nil || approved.State != StateExecuted {
+		t.Fatalf("ApproveOperation() = %+v, %v; want executed", approved, err)
+	}
+	if got, err := h.Decrypt("key", ciphertext, nonce, nil, version); err != nil || string(got) != "secret" {
+		t.Errorf("decrypt after restore = %q, %v", got, err)
+	}
+	if info, _ := h.GetKeyInfo("key"); info.CurrentVersion != 2 {
+		t.Errorf("current version after restore = %d, want 2", info.CurrentVersion)
+	}
+
+	var requested, executed bool
+	for _, e := range h.GetAuditLog() {
+		requested = requested || e.Operation == "RequestRestoreKeys" && e.Requester == "alice"
+		executed = executed || e.Operation == "RestoreKeys" && e.Requester == "alice" && e.Approver == "bob" && e.Success
+	}
+	if !requested || !executed {
+		t.Errorf("audit log lacks the restore under both identities: requested %v, executed %v", requested, executed)
+	}
+}
+
+func TestRestoreKeysRejectsBadBackups(t *testing.T) {
+	h := NewHSM(WithTransportKey(testTransportKey))
+	h.GenerateKey("key", "AES-256-GCM")
+	backup, _ := h.BackupKeys()
+
+	other := NewHSM(WithTransportKey(bytes.Repeat([]byte{0x07}, 32)))
+	if _, err := other.RestoreKeys("alice", backup); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("restore under another transport key: error = %v, want %v", err, ErrInvalidBackup)
+	}
+	if _, err := h.RestoreKeys("alice", nil); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("empty backup: error = %v, want %v", err, ErrInvalidBackup)
+	}
+
+	// The same key ID with other material is a different key, not a backup
+	// of it
+	replaced := NewHSM(WithTransportKey(testTransportKey))
+	replaced.GenerateKey("key", "AES-256-GCM")
+	if _, err := replaced.RestoreKeys("alice", backup); !errors.Is(err, ErrReplicationConflict) {
+		t.Errorf("restore over other material: error = %v, want %v", err, ErrReplicationConflict)
+	}
+
+	if _, err := NewHSM().BackupKeys(); !errors.Is(err, ErrReplicationDisabled) {
+		t.Errorf("backup without a transport key: error = %v, want %v", err, ErrReplicationDisabled)
+	}
+}
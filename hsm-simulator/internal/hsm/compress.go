@@ -0,0 +1,149 @@
+package hsm
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/paymentgateway/go-common/metrics"
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// ErrPayloadTooLarge reports a plaintext over the compression size limit,
+// before compression or after decompression
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// Compression defaults
+const (
+	DefaultCompressMinBytes = 1 << 10
+	DefaultCompressMaxBytes = 1 << 20
+)
+
+// CompressionConfig configures the compression of envelope plaintexts
+type CompressionConfig struct {
+	// MinBytes is the smallest plaintext worth compressing; smaller ones
+	// are sealed as they are. Zero means DefaultCompressMinBytes.
+	MinBytes int
+	// MaxBytes bounds the plaintext of a compressed envelope, both before
+	// compression and after decompression, so a crafted envelope cannot
+	// inflate without bound. Zero means DefaultCompressMaxBytes.
+	MaxBytes int
+	// Metrics receives the compression counters. Nil keeps them private
+	// to the HSM.
+	Metrics *metrics.Registry
+}
+
+// WithCompression configures envelope compression, which callers request
+// per envelope
+func WithCompression(cfg CompressionConfig) Option {
+	return func(h *HSM) { h.compression = newCompressor(cfg) }
+}
+
+// CompressionStats counts envelope plaintexts compressed, or left as they
+// were because compression would not shrink them, and the bytes compressed
+type CompressionStats struct {
+	Compressed uint64 `json:"compressed"`
+	Skipped    uint64 `json:"skipped"`
+	BytesIn    uint64 `json:"bytes_in"`
+	BytesOut   uint64 `json:"bytes_out"`
+}
+
+// CompressionStats returns the compression counters
+func (h *HSM) CompressionStats() CompressionStats {
+	c := h.compression
+	return CompressionStats{
+		Compressed: uint64(c.compressed.Value()),
+		Skipped:    uint64(c.skipped.Value()),
+		BytesIn:    uint64(c.bytesIn.Value()),
+		BytesOut:   uint64(c.bytesOut.Value()),
+	}
+}
+
+// CompressionLimit returns the largest plaintext a compressed envelope may
+// hold
+func (h *HSM) CompressionLimit() int {
+	return h.compression.maxBytes
+}
+
+type compressor struct {
+	minBytes   int
+	maxBytes   int
+	compressed *metrics.Counter
+	skipped    *metrics.Counter
+	bytesIn    *metrics.Counter
+	bytesOut   *metrics.Counter
+}
+
+func newCompressor(cfg CompressionConfig) *compressor {
+	if cfg.MinBytes <= 0 {
+		cfg.MinBytes = DefaultCompressMinBytes
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultCompressMaxBytes
+	}
+	registry := cfg.Metrics
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	envelopes := registry.Counter("hsm_compression_envelopes_total", "Envelope plaintexts submitted for compression, by whether they were compressed.", "result")
+	volume := registry.Counter("hsm_compression_bytes_total", "Plaintext bytes compressed, before (in) and after (out) compression.", "direction")
+	return &compressor{
+		minBytes:   cfg.MinBytes,
+		maxBytes:   cfg.MaxBytes,
+		compressed: envelopes.With("compressed"),
+		skipped:    envelopes.With("skipped"),
+		bytesIn:    volume.With("in"),
+		bytesOut:   volume.With("out"),
+	}
+}
+
+// compress DEFLATEs a plaintext, reporting false and returning it as it is
+// when it is too small to bother or would not shrink. A compressed result is
+// a new slice the caller must zeroize.
+func (c *compressor) compress(plaintext []byte) ([]byte, bool, error) {
+	if len(plaintext) > c.maxBytes {
+		return nil, false, fmt.Errorf("%w: %d bytes, limit %d", ErrPayloadTooLarge, len(plaintext), c.maxBytes)
+	}
+	if len(plaintext) < c.minBytes {
+		c.skipped.Inc()
+		return plaintext, false, nil
+	}
+
+	// Sized up front so the buffer never reallocates and leaves copies
+	buf := bytes.NewBuffer(make([]byte, 0, len(plaintext)+64))
+	w, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, false, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, false, err
+	}
+	out := buf.Bytes()
+	if len(out) >= len(plaintext) {
+		securebytes.Zero(out)
+		c.skipped.Inc()
+		return plaintext, false, nil
+	}
+	c.compressed.Inc()
+	c.bytesIn.Add(float64(len(plaintext)))
+	c.bytesOut.Add(float64(len(out)))
+	return out, true, nil
+}
+
+// decompress inflates a compressed plaintext up to the size limit
+func (c *compressor) decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	plaintext, err := io.ReadAll(io.LimitReader(r, int64(c.maxBytes)+1))
+	if err != nil {
+		securebytes.Zero(plaintext)
+		return nil, fmt.Errorf("%w: corrupt compressed plaintext", ErrInvalidEnvelope)
+	}
+	if len(plaintext) > c.maxBytes {
+		securebytes.Zero(plaintext)
+		return nil, fmt.Errorf("%w: decompresses beyond %d bytes", ErrPayloadTooLarge, c.maxBytes)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,134 @@
+package hsm
+
+import (
+	"crypto/des"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrInvalidCVV         = errors.New("CVVs are 3 digits")
+	ErrInvalidExpiry      = errors.New("expiry date must be YYMM")
+	ErrInvalidServiceCode = errors.New("service code must be 3 digits")
+)
+
+// Card verification values are computed with the Visa CVV method, which
+// MasterCard's CVC shares: the PAN, expiry date and service code under a
+// card verification key (CVK). Like the PIN methods it is a DES algorithm,
+// and a CVK's first 16 bytes are the double-length key it uses. The
+// service code tells the values of one card apart.
+const (
+	// ServiceCodeCVV2 is the service code of the CVV2 printed on the card,
+	// which cardholders enter for card-not-present payments
+	ServiceCodeCVV2 = "000"
+	// ServiceCodeICVV is the service code of the iCVV in chip track data
+	ServiceCodeICVV = "999"
+)
+
+// GenerateCVV computes a card's CVV, CVV2 or iCVV under a CVK, as the
+// issuer does when it personalizes the card. An empty service code means
+// CVV2.
+func (h *HSM) GenerateCVV(cvkID, pan, expiry, serviceCode string) (string, error) {
+	h.simulate("GenerateCVV")
+	cvk, version, err := h.workingKey("GenerateCVV", cvkID, KeyTypeCVK, cvkMode["GenerateCVV"])
+	if err != nil {
+		return "", err
+	}
+	defer securebytes.Zero(cvk)
+
+	cvv, err := cardVerificationValue(cvk, pan, expiry, serviceCode)
+	if err != nil {
+		h.logAudit("GenerateCVV", cvkID, version, false, err.Error())
+		return "", err
+	}
+	h.logAudit("GenerateCVV", cvkID, version, true, "")
+	return cvv, nil
+}
+
+// VerifyCVV reports whether a CVV entered or read for a card is the one
+// the CVK computes. A wrong CVV is a result, not an error.
+func (h *HSM) VerifyCVV(cvkID, pan, expiry, serviceCode, cvv string) (bool, error) {
+	h.simulate("VerifyCVV")
+	if len(cvv) != 3 || !allDigits([]byte(cvv)) {
+		h.logAudit("VerifyCVV", cvkID, 0, false, ErrInvalidCVV.Error())
+		return false, ErrInvalidCVV
+	}
+	cvk, version, err := h.workingKey("VerifyCVV", cvkID, KeyTypeCVK, cvkMode["VerifyCVV"])
+	if err != nil {
+		return false, err
+	}
+	defer securebytes.Zero(cvk)
+
+	want, err := cardVerificationValue(cvk, pan, expiry, serviceCode)
+	if err != nil {
+		h.logAudit("VerifyCVV", cvkID, version, false, err.Error())
+		return false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(want), []byte(cvv)) != 1 {
+		h.logAudit("VerifyCVV", cvkID, version, false, "CVV mismatch")
+		return false, nil
+	}
+	h.logAudit("VerifyCVV", cvkID, version, true, "")
+	return true, nil
+}
+
+// cardVerificationValue computes a CVV: the PAN, expiry and service code,
+// zero padded to two blocks; the first enciphered under the left half of
+// the key, XORed with the second and enciphered under the whole key; the
+// result's decimal digits taken first and its hex digits less ten after
+func cardVerificationValue(cvk []byte, pan, expiry, serviceCode string) (string, error) {
+	if serviceCode == "" {
+		serviceCode = ServiceCodeCVV2
+	}
+	switch {
+	case len(pan) < 12 || len(pan) > 19 || !allDigits([]byte(pan)):
+		return "", ErrInvalidCardData
+	case len(expiry) != 4 || !allDigits([]byte(expiry)) || expiry[2:] < "01" || expiry[2:] > "12":
+		return "", ErrInvalidExpiry
+	case len(serviceCode) != 3 || !allDigits([]byte(serviceCode)):
+		return "", ErrInvalidServiceCode
+	}
+	digits := make([]byte, 32)
+	for i := range digits {
+		digits[i] = '0'
+	}
+	copy(digits, pan+expiry+serviceCode)
+	data, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return "", ErrInvalidCardData
+	}
+	defer securebytes.Zero(data)
+
+	left, err := des.NewCipher(cvk[:8])
+	if err != nil {
+		return "", err
+	}
+	block := make([]byte, des.BlockSize)
+	defer securebytes.Zero(block)
+	left.Encrypt(block, data[:8])
+	for i := range block {
+		block[i] ^= data[8+i]
+	}
+	enciphered, err := desEncrypt(cvk, block)
+	if err != nil {
+		return "", err
+	}
+	defer securebytes.Zero(enciphered)
+
+	cvv := make([]byte, 0, 3)
+	for pass := 0; pass < 2 && len(cvv) < 3; pass++ {
+		for i := 0; i < 16 && len(cvv) < 3; i++ {
+			nibble := getNibble(enciphered, i)
+			switch {
+			case pass == 0 && nibble <= 9:
+				cvv = append(cvv, '0'+nibble)
+			case pass == 1 && nibble > 9:
+				cvv = append(cvv, '0'+nibble-10)
+			}
+		}
+	}
+	return string(cvv), nil
+}
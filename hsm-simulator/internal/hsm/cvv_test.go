@@ -0,0 +1,54 @@
+package hsm
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCardVerificationValueKnownAnswer(t *testing.T) {
+	// The published Visa CVV example
+	cvk, _ := hex.DecodeString("0123456789ABCDEFFEDCBA9876543210")
+	cvv, err := cardVerificationValue(cvk, "4123456789012345", "8701", "101")
+	if err != nil || cvv != "561" {
+		t.Errorf("cardVerificationValue() = %q, %v; want 561", cvv, err)
+	}
+}
+
+func TestVerifyCVV(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKeyOfType("cvk", "AES-256-GCM", KeyTypeCVK)
+
+	cvv2, err := h.GenerateCVV("cvk", pinTestPAN, "2812", "")
+	if err != nil || len(cvv2) != 3 {
+		t.Fatalf("GenerateCVV() = %q, %v", cvv2, err)
+	}
+	if ok, err := h.VerifyCVV("cvk", pinTestPAN, "2812", ServiceCodeCVV2, cvv2); !ok || err != nil {
+		t.Errorf("VerifyCVV() of the CVV2 = %v, %v", ok, err)
+	}
+	// The expiry date and service code are bound into the value
+	if ok, _ := h.VerifyCVV("cvk", pinTestPAN, "2811", "", cvv2); ok {
+		t.Error("VerifyCVV() accepted the CVV2 with another expiry date")
+	}
+	icvv, _ := h.GenerateCVV("cvk", pinTestPAN, "2812", ServiceCodeICVV)
+	if icvv != cvv2 {
+		if ok, _ := h.VerifyCVV("cvk", pinTestPAN, "2812", "", icvv); ok {
+			t.Error("VerifyCVV() accepted the iCVV as the CVV2")
+		}
+	}
+
+	if _, err := h.VerifyCVV("cvk", pinTestPAN, "2812", "", "12"); !errors.Is(err, ErrInvalidCVV) {
+		t.Errorf("VerifyCVV() of 2 digits error = %v", err)
+	}
+	if _, err := h.GenerateCVV("cvk", pinTestPAN, "2813", ""); !errors.Is(err, ErrInvalidExpiry) {
+		t.Errorf("GenerateCVV() with month 13 error = %v", err)
+	}
+	h.GenerateKeyOfType("dek", "AES-256-GCM", KeyTypeDEK)
+	if _, err := h.GenerateCVV("dek", pinTestPAN, "2812", ""); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("GenerateCVV() under a DEK error = %v", err)
+	}
+	h.GenerateKeyWithAttributes("verify-cvk", "AES-256-GCM", KeyTypeCVK, KeyAttributes{Mode: ModeVerify})
+	if _, err := h.GenerateCVV("verify-cvk", pinTestPAN, "2812", ""); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("GenerateCVV() under a verify-only CVK error = %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+package hsm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrKeyVersionInUse    = errors.New("cannot destroy the current key version")
+	ErrInvalidKeyMaterial = errors.New("key material must be 32 bytes")
+	ErrMissingOperator    = errors.New("operator identity required")
+	ErrOperationNotFound  = errors.New("operation not found")
+	ErrSelfApproval       = errors.New("an operation must be approved by a second operator")
+	ErrOperationDecided   = errors.New("operation already decided")
+	ErrOperationExpired   = errors.New("operation approval window expired")
+)
+
+// DefaultApprovalTTL is how long a destructive operation waits for approval
+// when WithDualControl is given no TTL
+const DefaultApprovalTTL = time.Hour
+
+// OperationKind names a destructive operation
+type OperationKind string
+
+const (
+	OpDestroyKeyVersion OperationKind = "DestroyKeyVersion"
+	OpImportKey         OperationKind = "ImportKey"
+	OpImportKeyBlock    OperationKind = "ImportKeyBlock"
+	OpRestoreKeys       OperationKind = "RestoreKeys"
+)
+
+// OperationState is where an operation is in the approval workflow
+type OperationState string
+
+const (
+	StatePending  OperationState = "pending"
+	StateExecuted OperationState = "executed"
+	StateFailed   OperationState = "failed"
+	StateRejected OperationState = "rejected"
+	StateExpired  OperationState = "expired"
+)
+
+// Operation is a destructive operation and who requested and decided it.
+// Without dual control it executes on request and has no approver.
+type Operation struct {
+	ID        string         `json:"id"`
+	Kind      OperationKind  `json:"kind"`
+	KeyID     string         `json:"key_id"`
+	Version   int            `json:"version"`
+	Algorithm string         `json:"algorithm,omitempty"`
+	KeyType   KeyType        `json:"key_type,omitempty"`
+	ZMKID     string         `json:"zmk_id,omitempty"`
+	Rotate    bool           `json:"rotate,omitempty"`
+	Requester string         `json:"requester"`
+	Approver  string         `json:"approver,omitempty"`
+	State     OperationState `json:"state"`
+	Error     string         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at,omitempty"`
+	DecidedAt time.Time      `json:"decided_at,omitempty"`
+
+	// BackupVersions is how many key versions a restore reinstalls
+	BackupVersions int `json:"backup_versions,omitempty"`
+
+	// keyMaterial is an import's key, zeroized once the operation is decided
+	keyMaterial []byte
+	// keyBlock and kcv are a key block import's, dropped once it is decided
+	keyBlock, kcv string
+	// backup is a restore's wrapped key versions, dropped once it is decided
+	backup []Change
+}
+
+// WithDualControl enforces the two-person rule: DestroyKeyVersion, ImportKey,
+// ImportKeyBlock and RestoreKeys only queue an operation, which a second
+// operator must approve within ttl before it executes
+func WithDualControl(ttl time.Duration) Option {
+	if ttl <= 0 {
+		ttl = DefaultApprovalTTL
+	}
+	return func(h *HSM) {
+		h.dualControl = true
+		h.approvalTTL = ttl
+	}
+}
+
+// DualControl reports whether destructive operations need approval
+func (h *HSM) DualControl() bool {
+	return h.dualControl
+}
+
+// DestroyKeyVersion zeroizes and removes a retired key version, after which
+// data encrypted under it can no longer be decrypted. The current version
+// cannot be destroyed; rotate first.
+func (h *HSM) DestroyKeyVersion(requester, keyID string, version int) (*Operation, error) {
+	if err := h.checkDestroy(keyID, version); err != nil {
+		h.logAuditBy(string(OpDestroyKeyVersion), keyID, version, err, requester, "")
+		return nil, err
+	}
+	return h.submit(&Operation{
+		Kind:      OpDestroyKeyVersion,
+		KeyID:     keyID,
+		Version:   version,
+		Requester: requester,
+	})
+}
+
+// ImportKey creates a key from externally generated material. The HSM keeps
+// its own copy; the caller should zeroize keyMaterial.
+func (h *HSM) ImportKey(requester, keyID, algorithm string, keyMaterial []byte) (*Operation, error) {
+	return h.ImportKeyOfType(requester, keyID, algorithm, KeyTypeDEK, keyMaterial)
+}
+
+// ImportKeyOfType imports a key of a type of the hierarchy. This is how a
+// ZMK is formed from the components the other party sent.
+func (h *HSM) ImportKeyOfType(requester, keyID, algorithm string, keyType KeyType, keyMaterial []byte) (*Operation, error) {
+	if err := h.checkImport(keyID, algorithm, keyType, keyMaterial); err != nil {
+		h.logAuditBy(string(OpImportKey), keyID, 0, err, requester, "")
+		return nil, err
+	}
+	return h.submit(&Operation{
+		Kind:        OpImportKey,
+		KeyID:       keyID,
+		Version:     1,
+		Algorithm:   algorithm,
+		KeyType:     keyType,
+		Requester:   requester,
+		keyMaterial: securebytes.Clone(keyMaterial),
+	})
+}
+
+// ApproveOperation executes a pending operation on behalf of a second
+// operator
+func (h *HSM) ApproveOperation(id, approver string) (*Operation, error) {
+	return h.decide(id, approver, true)
+}
+
+// RejectOperation discards a pending operation
+func (h *HSM) RejectOperation(id, approver string) (*Operation, error) {
+	return h.decide(id, approver, false)
+}
+
+// Operations returns every operation, oldest first. Pending operations past
+// their approval window are reported as expired.
+func (h *HSM) Operations() []Operation {
+	h.opsMu.Lock()
+	defer h.opsMu.Unlock()
+
+	ops := make([]Operation, 0, len(h.operations))
+	for _, op := range h.operations {
+		h.expireLocked(op)
+		ops = append(ops, *op.snapshot())
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].CreatedAt.Before(ops[j].CreatedAt) })
+	return ops
+}
+
+func (h *HSM) submit(op *Operation) (*Operation, error) {
+	if op.Requester == "" {
+		securebytes.Zero(op.keyMaterial)
+		return nil, ErrMissingOperator
+	}
+	id, err := operationID()
+	if err != nil {
+		securebytes.Zero(op.keyMaterial)
+		return nil, err
+	}
+	op.ID = id
+	op.CreatedAt = h.now()
+
+	h.opsMu.Lock()
+	defer h.opsMu.Unlock()
+
+	h.operations[op.ID] = op
+	if !h.dualControl {
+		h.executeLocked(op)
+		return op.snapshot(), nil
+	}
+	op.State = StatePending
+	op.ExpiresAt = op.CreatedAt.Add(h.approvalTTL)
+	h.logAuditBy("Request"+string(op.Kind), op.KeyID, op.Version, nil, op.Requester, "")
+	if op.Kind == OpDestroyKeyVersion {
+		h.advise(Advice{
+			Kind:           AdviceRetiring,
+			KeyID:          op.KeyID,
+			Version:        op.Version,
+			CurrentVersion: h.currentVersion(op.KeyID),
+			OperationID:    op.ID,
+			Deadline:       op.ExpiresAt,
+		})
+	}
+	return op.snapshot(), nil
+}
+
+func (h *HSM) decide(id, approver string, approve bool) (*Operation, error) {
+	if approver == "" {
+		return nil, ErrMissingOperator
+	}
+
+	h.opsMu.Lock()
+	defer h.opsMu.Unlock()
+
+	op, ok := h.operations[id]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	if h.expireLocked(op) {
+		return op.snapshot(), ErrOperationExpired
+	}
+	if op.State != StatePending {
+		return op.snapshot(), ErrOperationDecided
+	}
+	if approver == op.Requester {
+		h.logAuditBy("Approve"+string(op.Kind), op.KeyID, op.Version, ErrSelfApproval, op.Requester, approver)
+		return nil, ErrSelfApproval
+	}
+
+	op.Approver = approver
+	if !approve {
+		op.State = StateRejected
+		op.DecidedAt = h.now()
+		securebytes.Zero(op.keyMaterial)
+		op.keyBlock, op.kcv = "", ""
+		op.backup = nil
+		h.logAuditBy("Reject"+string(op.Kind), op.KeyID, op.Version, nil, op.Requester, approver)
+		h.adviseDecided(op)
+		return op.snapshot(), nil
+	}
+	h.executeLocked(op)
+	return op.snapshot(), nil
+}
+
+// executeLocked runs an operation and records the outcome, under both
+// identities when it was approved
+func (h *HSM) executeLocked(op *Operation) {
+	var err error
+	switch op.Kind {
+	case OpDestroyKeyVersion:
+		err = h.destroyKeyVersion(op.KeyID, op.Version)
+	case OpImportKey:
+		err = h.importKey(op.KeyID, op.Algorithm, op.KeyType, op.keyMaterial)
+	case OpImportKeyBlock:
+		var meta *KeyMetadata
+		if meta, err = h.importKeyBlock(op.KeyID, op.ZMKID, op.keyBlock, op.kcv, op.Rotate); err == nil {
+			op.Version = meta.CurrentVersion
+		}
+	case OpRestoreKeys:
+		err = h.restoreKeys(op.backup)
+	default:
+		err = fmt.Errorf("unknown operation %q", op.Kind)
+	}
+	securebytes.Zero(op.keyMaterial)
+	op.keyMaterial = nil
+	op.keyBlock, op.kcv = "", ""
+	op.backup = nil
+
+	op.DecidedAt = h.now()
+	op.State = StateExecuted
+	if err != nil {
+		op.State = StateFailed
+		op.Error = err.Error()
+	}
+	h.logAuditBy(string(op.Kind), op.KeyID, op.Version, err, op.Requester, op.Approver)
+	h.adviseDecided(op)
+}
+
+// expireLocked marks a pending operation past its window as expired
+func (h *HSM) expireLocked(op *Operation) bool {
+	if op.State == StatePending && h.now().After(op.ExpiresAt) {
+		op.State = StateExpired
+		op.DecidedAt = op.ExpiresAt
+		securebytes.Zero(op.keyMaterial)
+		op.keyMaterial = nil
+		op.keyBlock, op.kcv = "", ""
+		op.backup = nil
+		h.logAuditBy("Expire"+string(op.Kind), op.KeyID, op.Version, nil, op.Requester, "")
+		h.adviseDecided(op)
+	}
+	return op.State == StateExpired
+}
+
+// checkDestroy validates a destroy request before it is queued, so an
+// approver is never asked to sign off on an operation that cannot succeed
+func (h *HSM) checkDestroy(keyID string, version int) error {
+	if err := h.checkWritable(); err != nil {
+		return err
+	}
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	if _, ok := key.Versions[version]; !ok {
+		return ErrInvalidKeyVersion
+	}
+	if version == key.CurrentVersion {
+		return ErrKeyVersionInUse
+	}
+	return nil
+}
+
+func (h *HSM) checkImport(keyID, algorithm string, keyType KeyType, keyMaterial []byte) error {
+	if err := h.checkWritable(); err != nil {
+		return err
+	}
+	if keyID == "" {
+		return ErrInvalidKeyID
+	}
+	if algorithm != "AES-256-GCM" {
+		return ErrInvalidAlgorithm
+	}
+	if _, ok := keyBlockUsage[keyType]; !ok {
+		return ErrInvalidKeyType
+	}
+	if len(keyMaterial) != 32 {
+		return ErrInvalidKeyMaterial
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, exists := h.keys[keyID]; exists {
+		return fmt.Errorf("%w: %s", ErrKeyExists, keyID)
+	}
+	return nil
+}
+
+// destroyKeyVersion re-checks the request, since the key may have rotated
+// or been destroyed while the operation waited for approval
+func (h *HSM) destroyKeyVersion(keyID string, version int) error {
+	if err := h.checkDestroy(keyID, version); err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	key := h.keys[keyID]
+	h.mu.RUnlock()
+
+	key.mu.Lock()
+	defer key.mu.Unlock()
+	if version == key.CurrentVersion {
+		return ErrKeyVersionInUse
+	}
+	if v, ok := key.Versions[version]; ok {
+		securebytes.Zero(v.KeyData)
+		delete(key.Versions, version)
+		h.recordChange(ChangeDestroy, keyID, version)
+		h.advise(Advice{Kind: AdviceRetired, KeyID: keyID, Version: version, CurrentVersion: key.CurrentVersion})
+	}
+	return nil
+}
+
+func (h *HSM) importKey(keyID, algorithm string, keyType KeyType, keyMaterial []byte) error {
+	if err := h.checkImport(keyID, algorithm, keyType, keyMaterial); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, exists := h.keys[keyID]; exists {
+		return fmt.Errorf("%w: %s", ErrKeyExists, keyID)
+	}
+
+	now := h.now()
+	h.keys[keyID] = &Key{
+		ID:             keyID,
+		Algorithm:      algorithm,
+		Type:           keyType,
+		Versions:       map[int]*KeyVersion{1: {Version: 1, KeyData: securebytes.Clone(keyMaterial), CreatedAt: now}},
+		CurrentVersion: 1,
+		CreatedAt:      now,
+		LastRotatedAt:  now,
+	}
+	h.recordChange(ChangeVersion, keyID, 1)
+	return nil
+}
+
+// logAuditBy records an operation with the operators behind it
+func (h *HSM) logAuditBy(operation, keyID string, version int, err error, requester, approver string) {
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+
+	entry := AuditEntry{
+		Timestamp: time.Now(),
+		Operation: operation,
+		KeyID:     keyID,
+		Version:   version,
+		Success:   err == nil,
+		Requester: requester,
+		Approver:  approver,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	h.auditLog = append(h.auditLog, entry)
+}
+
+// snapshot copies an operation without its key material
+func (op *Operation) snapshot() *Operation {
+	c := *op
+	c.keyMaterial = nil
+	c.keyBlock, c.kcv = "", ""
+	c.backup = nil
+	return &c
+}
+
+func operationID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate operation ID: %w", err)
+	}
+	return "op-" + hex.EncodeToString(b), nil
+}
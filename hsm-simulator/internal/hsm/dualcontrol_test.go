@@ -0,0 +1,207 @@
+package hsm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDestroyKeyVersionWithoutDualControl(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("key", "AES-256-GCM")
+	ciphertext, nonce, version, _ := h.Encrypt("key", []byte("secret"), nil)
+
+	if _, err := h.DestroyKeyVersion("alice", "key", version); !errors.Is(err, ErrKeyVersionInUse) {
+		t.Fatalf("destroying the current version: error = %v, want %v", err, ErrKeyVersionInUse)
+	}
+	h.RotateKey("key")
+
+	op, err := h.DestroyKeyVersion("alice", "key", version)
+	if err != nil || op.State != StateExecuted || op.Approver != "" {
+		t.Fatalf("DestroyKeyVersion() = %+v, %v; want executed without approval", op, err)
+	}
+	if _, err := h.Decrypt("key", ciphertext, nonce, nil, version); !errors.Is(err, ErrInvalidKeyVersion) {
+		t.Errorf("decrypt under destroyed version: error = %v, want %v", err, ErrInvalidKeyVersion)
+	}
+}
+
+func TestDestroyKeyVersionDuringDecrypt(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("key", "AES-256-GCM")
+	ciphertext, nonce, version, _ := h.Encrypt("key", []byte("secret"), nil)
+	h.RotateKey("key")
+
+	// A decrypt racing the destroy either finishes under the old key or finds
+	// the version gone; it never runs with zeroized key bytes
+	const workers = 8
+	var started, done sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			for first := true; ; first = false {
+				plaintext, err := h.Decrypt("key", ciphertext, nonce, nil, version)
+				if first {
+					started.Done()
+				}
+				if errors.Is(err, ErrInvalidKeyVersion) {
+					return
+				}
+				if err == nil && string(plaintext) != "secret" {
+					err = errors.New("wrong plaintext")
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	started.Wait()
+	if _, err := h.DestroyKeyVersion("alice", "key", version); err != nil {
+		t.Fatalf("DestroyKeyVersion() error = %v", err)
+	}
+	done.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("decrypt during destroy: %v", err)
+	}
+}
+
+func TestDualControl(t *testing.T) {
+	h := NewHSM(WithDualControl(time.Hour))
+	h.GenerateKey("key", "AES-256-GCM")
+	h.RotateKey("key")
+
+	op, err := h.DestroyKeyVersion("alice", "key", 1)
+	if err != nil || op.State != StatePending {
+		t.Fatalf("DestroyKeyVersion() = %+v, %v; want pending", op, err)
+	}
+	if info, _ := h.GetKeyInfo("key"); len(info.AvailableVersions) != 2 {
+		t.Fatal("version destroyed before approval")
+	}
+
+	if _, err := h.ApproveOperation(op.ID, "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("self approval: error = %v, want %v", err, ErrSelfApproval)
+	}
+	if _, err := h.ApproveOperation("op-missing", "bob"); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("unknown operation: error = %v, want %v", err, ErrOperationNotFound)
+	}
+	approved, err := h.ApproveOperation(op.ID, "bob")
+	if err != nil || approved.State != StateExecuted || approved.Approver != "bob" {
+		t.Fatalf("ApproveOperation() = %+v, %v; want executed by bob", approved, err)
+	}
+	if info, _ := h.GetKeyInfo("key"); len(info.AvailableVersions) != 1 {
+		t.Errorf("available versions after approval = %v", info.AvailableVersions)
+	}
+	if _, err := h.RejectOperation(op.ID, "carol"); !errors.Is(err, ErrOperationDecided) {
+		t.Errorf("deciding twice: error = %v, want %v", err, ErrOperationDecided)
+	}
+
+	// The execution is audited under both identities
+	var found bool
+	for _, entry := range h.GetAuditLog() {
+		if entry.Operation == "DestroyKeyVersion" && entry.Success {
+			found = entry.Requester == "alice" && entry.Approver == "bob"
+		}
+	}
+	if !found {
+		t.Errorf("no DestroyKeyVersion audit entry for alice and bob: %+v", h.GetAuditLog())
+	}
+}
+
+func TestDualControlImportKey(t *testing.T) {
+	h := NewHSM(WithDualControl(time.Hour))
+	material := bytes.Repeat([]byte{7}, 32)
+
+	if _, err := h.ImportKey("alice", "imported", "AES-256-GCM", material[:16]); !errors.Is(err, ErrInvalidKeyMaterial) {
+		t.Errorf("short key: error = %v, want %v", err, ErrInvalidKeyMaterial)
+	}
+
+	rejected, _ := h.ImportKey("alice", "imported", "AES-256-GCM", material)
+	if op, err := h.RejectOperation(rejected.ID, "bob"); err != nil || op.State != StateRejected {
+		t.Fatalf("RejectOperation() = %+v, %v", op, err)
+	}
+	if _, err := h.GetKeyInfo("imported"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("rejected import created the key: error = %v", err)
+	}
+
+	op, _ := h.ImportKey("alice", "imported", "AES-256-GCM", material)
+	if _, err := h.ApproveOperation(op.ID, "bob"); err != nil {
+		t.Fatalf("ApproveOperation() error = %v", err)
+	}
+
+	// Data sealed under the imported key outside the HSM decrypts inside it
+	block, _ := aes.NewCipher(material)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	plaintext, err := h.Decrypt("imported", gcm.Seal(nil, nonce, []byte("hello"), nil), nonce, nil, 1)
+	if err != nil || string(plaintext) != "hello" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+
+	if ops := h.Operations(); len(ops) != 2 || ops[0].State != StateRejected || ops[1].State != StateExecuted {
+		t.Errorf("Operations() = %+v", ops)
+	}
+}
+
+func TestDualControlImportKeyBlock(t *testing.T) {
+	acquirer := NewHSM()
+	issuer := NewHSM(WithDualControl(time.Hour))
+	zmk := bytes.Repeat([]byte{9}, 32)
+	acquirer.ImportKeyOfType("alice", "zmk", "AES-256-GCM", KeyTypeZMK, zmk)
+	op, _ := issuer.ImportKeyOfType("alice", "zmk", "AES-256-GCM", KeyTypeZMK, zmk)
+	issuer.ApproveOperation(op.ID, "bob")
+	acquirer.GenerateKeyOfType("zpk", "AES-256-GCM", KeyTypeZPK)
+	block, kcv, _ := acquirer.ExportKey("zpk", "zmk")
+
+	op, err := issuer.ImportKeyBlock("alice", "zpk", "zmk", block, kcv, false)
+	if err != nil || op.State != StatePending || op.KeyType != KeyTypeZPK || op.ZMKID != "zmk" {
+		t.Fatalf("ImportKeyBlock() = %+v, %v; want a pending ZPK import", op, err)
+	}
+	if _, err := issuer.GetKeyInfo("zpk"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("key imported before approval: error = %v", err)
+	}
+	if _, err := issuer.ApproveOperation(op.ID, "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("self approval: error = %v, want %v", err, ErrSelfApproval)
+	}
+	if approved, err := issuer.ApproveOperation(op.ID, "bob"); err != nil || approved.State != StateExecuted || approved.Version != 1 {
+		t.Fatalf("ApproveOperation() = %+v, %v; want version 1 imported", approved, err)
+	}
+
+	// A key change is approved like the first import
+	acquirer.RotateKey("zpk")
+	block, kcv, _ = acquirer.ExportKey("zpk", "zmk")
+	change, err := issuer.ImportKeyBlock("alice", "zpk", "zmk", block, kcv, true)
+	if err != nil || change.State != StatePending {
+		t.Fatalf("ImportKeyBlock() of a key change = %+v, %v; want pending", change, err)
+	}
+	if approved, err := issuer.ApproveOperation(change.ID, "bob"); err != nil || approved.State != StateExecuted || approved.Version != 2 {
+		t.Errorf("ApproveOperation() of a key change = %+v, %v; want version 2", approved, err)
+	}
+}
+
+func TestDualControlExpiry(t *testing.T) {
+	h := NewHSM(WithDualControl(time.Minute))
+	h.GenerateKey("key", "AES-256-GCM")
+	h.RotateKey("key")
+
+	op, _ := h.DestroyKeyVersion("alice", "key", 1)
+	h.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+	if _, err := h.ApproveOperation(op.ID, "bob"); !errors.Is(err, ErrOperationExpired) {
+		t.Fatalf("late approval: error = %v, want %v", err, ErrOperationExpired)
+	}
+	if info, _ := h.GetKeyInfo("key"); len(info.AvailableVersions) != 2 {
+		t.Error("expired operation was executed")
+	}
+	if ops := h.Operations(); len(ops) != 1 || ops[0].State != StateExpired {
+		t.Errorf("Operations() = %+v", ops)
+	}
+}
@@ -0,0 +1,301 @@
+package hsm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrInvalidCardData   = errors.New("invalid PAN or PAN sequence number")
+	ErrInvalidARC        = errors.New("authorisation response code must be 2 bytes")
+	ErrInvalidCryptogram = errors.New("cryptogram must be 8 bytes")
+	ErrARQCMismatch      = errors.New("ARQC verification failed")
+	ErrScriptTooLong     = errors.New("issuer scripts exceed 128 bytes")
+)
+
+// EMV cryptograms use AES as EMV Book 2 allows: the HSM key acts as the
+// issuer master key for application cryptograms (IMK-AC), from which each
+// card's master key is derived by PAN and sequence number (Annex A1.4,
+// option A) and each transaction's session key by ATC (Annex A1.3). ARQCs
+// are AES-CMAC over the card's transaction data and ARPCs follow method 1.
+
+// CardID identifies the card whose keys are derived
+type CardID struct {
+	PAN string
+	PSN int // PAN sequence number, 0-99
+}
+
+// GenerateARQC computes the authorisation request cryptogram a card with
+// this PAN would send for the transaction data (the CDOL1 data in card
+// order), for simulating the terminal side
+func (h *HSM) GenerateARQC(keyID string, card CardID, atc uint16, txnData []byte) ([]byte, error) {
+	sk, err := h.emvSessionKey("GenerateARQC", keyID, card, atc)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(sk)
+
+	h.logAudit("GenerateARQC", keyID, 0, true, "")
+	return cmac(sk, txnData)[:8], nil
+}
+
+// VerifyARQCAndGenerateARPC verifies a card's ARQC as the issuer and returns
+// the response cryptogram for the authorisation response code, e.g. "00"
+// to approve. The ARPC proves the response came from the issuer when the
+// card runs its second GENERATE AC.
+func (h *HSM) VerifyARQCAndGenerateARPC(keyID string, card CardID, atc uint16, txnData, arqc, arc []byte) ([]byte, error) {
+	if len(arqc) != 8 {
+		return nil, ErrInvalidCryptogram
+	}
+	if len(arc) != 2 {
+		return nil, ErrInvalidARC
+	}
+	sk, err := h.emvSessionKey("GenerateARPC", keyID, card, atc)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(sk)
+
+	if !securebytes.Equal(cmac(sk, txnData)[:8], arqc) {
+		h.logAudit("GenerateARPC", keyID, 0, false, "ARQC mismatch")
+		return nil, ErrARQCMismatch
+	}
+
+	h.logAudit("GenerateARPC", keyID, 0, true, "")
+	return arpc(sk, arqc, arc), nil
+}
+
+// VerifyARPC checks an issuer's ARPC as the card would
+func (h *HSM) VerifyARPC(keyID string, card CardID, atc uint16, arqc, arc, response []byte) error {
+	if len(arqc) != 8 || len(response) != 8 {
+		return ErrInvalidCryptogram
+	}
+	if len(arc) != 2 {
+		return ErrInvalidARC
+	}
+	sk, err := h.emvSessionKey("VerifyARPC", keyID, card, atc)
+	if err != nil {
+		return err
+	}
+	defer securebytes.Zero(sk)
+
+	if !securebytes.Equal(arpc(sk, arqc, arc), response) {
+		h.logAudit("VerifyARPC", keyID, 0, false, "ARPC mismatch")
+		return ErrDecryptionFailed
+	}
+	h.logAudit("VerifyARPC", keyID, 0, true, "")
+	return nil
+}
+
+// cvkMode is the mode of use a CVK needs for an EMV or CVV operation; the
+// issuer verifies the ARQC and generates the ARPC in one, which needs both
+var cvkMode = map[string]KeyMode{
+	"GenerateARQC": ModeGenerate,
+	"GenerateARPC": ModeGenerateVerify,
+	"VerifyARPC":   ModeVerify,
+	"GenerateCVV":  ModeGenerate,
+	"VerifyCVV":    ModeVerify,
+}
+
+// emvSessionKey derives the AES-128 session key for one transaction
+func (h *HSM) emvSessionKey(operation, keyID string, card CardID, atc uint16) ([]byte, error) {
+	y, err := card.block()
+	if err != nil {
+		h.logAudit(operation, keyID, 0, false, err.Error())
+		return nil, err
+	}
+
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		h.logAudit(operation, keyID, 0, false, "key not found")
+		return nil, ErrKeyNotFound
+	}
+	key.mu.RLock()
+	if err := h.checkUsage(operation, key, KeyTypeDEK, KeyTypeCVK); err != nil {
+		key.mu.RUnlock()
+		return nil, err
+	}
+	if key.Type == KeyTypeCVK {
+		if err := h.checkMode(operation, key, cvkMode[operation]); err != nil {
+			key.mu.RUnlock()
+			return nil, err
+		}
+	}
+	imk := securebytes.Clone(key.Versions[key.CurrentVersion].KeyData)
+	key.mu.RUnlock()
+	defer securebytes.Zero(imk)
+
+	block, err := aes.NewCipher(imk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	mk := make([]byte, aes.BlockSize)
+	block.Encrypt(mk, y)
+	defer securebytes.Zero(mk)
+
+	// R = ATC || F0 || 00...00 per the common session key derivation
+	r := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint16(r, atc)
+	r[2] = 0xF0
+	mkBlock, err := aes.NewCipher(mk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	sk := make([]byte, aes.BlockSize)
+	mkBlock.Encrypt(sk, r)
+	return sk, nil
+}
+
+// block packs the rightmost 16 digits of PAN || PSN as BCD into the low
+// half of an AES block
+func (c CardID) block() ([]byte, error) {
+	if len(c.PAN) < 12 || len(c.PAN) > 19 || c.PSN < 0 || c.PSN > 99 {
+		return nil, ErrInvalidCardData
+	}
+	digits := fmt.Sprintf("%s%02d", c.PAN, c.PSN)
+	for _, d := range digits {
+		if d < '0' || d > '9' {
+			return nil, ErrInvalidCardData
+		}
+	}
+	digits = digits[len(digits)-16:]
+
+	y := make([]byte, aes.BlockSize)
+	for i := 0; i < 8; i++ {
+		y[8+i] = (digits[2*i]-'0')<<4 | (digits[2*i+1] - '0')
+	}
+	return y, nil
+}
+
+// arpc is ARPC method 1 for a 16-byte block cipher: the session key
+// encrypts the ARQC XORed with the ARC padded with zeros, keeping 8 bytes
+func arpc(sk, arqc, arc []byte) []byte {
+	in := make([]byte, aes.BlockSize)
+	copy(in, arqc)
+	in[0] ^= arc[0]
+	in[1] ^= arc[1]
+	block, _ := aes.NewCipher(sk)
+	out := make([]byte, aes.BlockSize)
+	block.Encrypt(out, in)
+	return out[:8]
+}
+
+// cmac is AES-CMAC (RFC 4493)
+func cmac(key, msg []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	k1, k2 := cmacSubkeys(block)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(msg)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, aes.BlockSize)
+	if complete {
+		copy(last, msg[(n-1)*aes.BlockSize:])
+		subtle.XORBytes(last, last, k1)
+	} else {
+		rest := msg[(n-1)*aes.BlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		subtle.XORBytes(last, last, k2)
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x, x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x, x)
+	}
+	subtle.XORBytes(x, x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+func cmacSubkeys(block cipher.Block) (k1, k2 []byte) {
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	return dbl(l), dbl(dbl(l))
+}
+
+// dbl doubles a block in GF(2^128)
+func dbl(b []byte) []byte {
+	out := make([]byte, len(b))
+	var carry byte
+	for i := len(b) - 1; i >= 0; i-- {
+		out[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
+
+// IssuerScript is a script the issuer sends the card in the response:
+// tag 71 scripts run before the second GENERATE AC, tag 72 after
+type IssuerScript struct {
+	BeforeGenerateAC bool
+	ID               []byte   // optional 4-byte script identifier (tag 9F18)
+	Commands         [][]byte // APDUs, each sent as tag 86
+}
+
+// ResponseData builds ISO 8583 field 55 for an authorisation response:
+// issuer authentication data (tag 91, ARPC || ARC) followed by any issuer
+// scripts
+func ResponseData(cryptogram, arc []byte, scripts []IssuerScript) ([]byte, error) {
+	if len(cryptogram) != 8 {
+		return nil, ErrInvalidCryptogram
+	}
+	if len(arc) != 2 {
+		return nil, ErrInvalidARC
+	}
+	out := tlv(nil, 0x91, append(append([]byte(nil), cryptogram...), arc...))
+
+	var scriptBytes int
+	for _, s := range scripts {
+		var body []byte
+		if len(s.ID) > 0 {
+			body = tlv(body, 0x9F18, s.ID)
+		}
+		for _, cmd := range s.Commands {
+			body = tlv(body, 0x86, cmd)
+		}
+		tag := 0x72
+		if s.BeforeGenerateAC {
+			tag = 0x71
+		}
+		script := tlv(nil, tag, body)
+		scriptBytes += len(script)
+		out = append(out, script...)
+	}
+	// Cards accept at most 128 bytes of scripts per transaction
+	if scriptBytes > 128 {
+		return nil, ErrScriptTooLong
+	}
+	return out, nil
+}
+
+// tlv appends a BER-TLV with a one- or two-byte tag
+func tlv(dst []byte, tag int, value []byte) []byte {
+	if tag > 0xFF {
+		dst = append(dst, byte(tag>>8))
+	}
+	dst = append(dst, byte(tag))
+	switch n := len(value); {
+	case n < 0x80:
+		dst = append(dst, byte(n))
+	case n <= 0xFF:
+		dst = append(dst, 0x81, byte(n))
+	default:
+		dst = append(dst, 0x82, byte(n>>8), byte(n))
+	}
+	return append(dst, value...)
+}
@@ -0,0 +1,89 @@
+package hsm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493 test vectors
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+	for _, tt := range []struct {
+		n    int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+	} {
+		if got := hex.EncodeToString(cmac(key, msg[:tt.n])); got != tt.want {
+			t.Errorf("cmac(%d bytes) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestARQCAndARPC(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("imk-ac", "AES-256-GCM")
+	card := CardID{PAN: "4761739001010010", PSN: 1}
+	txnData, _ := hex.DecodeString("000000010000000000000000084000800000000840250101000123456789")
+	approve := []byte("00")
+
+	arqc, err := h.GenerateARQC("imk-ac", card, 0x0042, txnData)
+	if err != nil || len(arqc) != 8 {
+		t.Fatalf("GenerateARQC() = %x, %v", arqc, err)
+	}
+
+	arpc, err := h.VerifyARQCAndGenerateARPC("imk-ac", card, 0x0042, txnData, arqc, approve)
+	if err != nil {
+		t.Fatalf("VerifyARQCAndGenerateARPC() error = %v", err)
+	}
+	if err := h.VerifyARPC("imk-ac", card, 0x0042, arqc, approve, arpc); err != nil {
+		t.Errorf("VerifyARPC() error = %v", err)
+	}
+
+	// The ARPC binds the response code, the ARQC the card, ATC and data
+	if err := h.VerifyARPC("imk-ac", card, 0x0042, arqc, []byte("05"), arpc); err == nil {
+		t.Error("ARPC verified with another response code")
+	}
+	for name, tc := range map[string]struct {
+		card CardID
+		atc  uint16
+		data []byte
+	}{
+		"other card":   {CardID{PAN: "4761739001010010", PSN: 2}, 0x0042, txnData},
+		"other ATC":    {card, 0x0043, txnData},
+		"altered data": {card, 0x0042, append([]byte{1}, txnData[1:]...)},
+	} {
+		if _, err := h.VerifyARQCAndGenerateARPC("imk-ac", tc.card, tc.atc, tc.data, arqc, approve); !errors.Is(err, ErrARQCMismatch) {
+			t.Errorf("%s: error = %v, want %v", name, err, ErrARQCMismatch)
+		}
+	}
+
+	if _, err := h.GenerateARQC("imk-ac", CardID{PAN: "4761x39001010010"}, 1, txnData); !errors.Is(err, ErrInvalidCardData) {
+		t.Errorf("invalid PAN: error = %v", err)
+	}
+}
+
+func TestResponseData(t *testing.T) {
+	arpc, _ := hex.DecodeString("1122334455667788")
+	pinUnblock, _ := hex.DecodeString("8424000008AABBCCDDEEFF0011")
+	got, err := ResponseData(arpc, []byte("00"), []IssuerScript{
+		{ID: []byte{0, 0, 0, 1}, Commands: [][]byte{pinUnblock}},
+	})
+	if err != nil {
+		t.Fatalf("ResponseData() error = %v", err)
+	}
+	want, _ := hex.DecodeString("910A11223344556677883030" + "7216" + "9F180400000001" + "860D8424000008AABBCCDDEEFF0011")
+	if !bytes.Equal(got, want) {
+		t.Errorf("ResponseData() = %X\nwant %X", got, want)
+	}
+
+	long := IssuerScript{Commands: [][]byte{make([]byte, 130)}}
+	if _, err := ResponseData(arpc, []byte("00"), []IssuerScript{long}); !errors.Is(err, ErrScriptTooLong) {
+		t.Errorf("long script: error = %v, want %v", err, ErrScriptTooLong)
+	}
+}
@@ -0,0 +1,91 @@
+package hsm
+
+import (
+	"github.com/paymentgateway/go-common/ciphertext"
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// Errors for envelopes that cannot be opened
+var (
+	ErrInvalidEnvelope     = ciphertext.ErrInvalidEnvelope
+	ErrUnsupportedEnvelope = ciphertext.ErrUnsupportedEnvelope
+)
+
+// EnvelopeOptions adjust how EncryptEnvelopeWithOptions seals a plaintext
+type EnvelopeOptions struct {
+	// Compress DEFLATEs the plaintext before encryption when that shrinks
+	// it, and flags the envelope so DecryptEnvelope inflates it again.
+	// Compression reveals how well the plaintext compresses through the
+	// envelope's length, so it is meant for bulk data, not secrets mixed
+	// with attacker-chosen input.
+	Compress bool
+}
+
+// EncryptEnvelope encrypts plaintext under the current version of a key and
+// returns a self-describing envelope (see package ciphertext) naming the
+// key, version, algorithm and nonce, with the tag
+func (h *HSM) EncryptEnvelope(keyID string, plaintext, aad []byte) (envelope []byte, keyVersion int, err error) {
+	envelope, keyVersion, _, err = h.EncryptEnvelopeWithOptions(keyID, plaintext, aad, EnvelopeOptions{})
+	return envelope, keyVersion, err
+}
+
+// EncryptEnvelopeWithOptions is EncryptEnvelope with optional compression.
+// It reports whether the plaintext was compressed.
+func (h *HSM) EncryptEnvelopeWithOptions(keyID string, plaintext, aad []byte, opts EnvelopeOptions) (envelope []byte, keyVersion int, compressed bool, err error) {
+	var flags ciphertext.Flags
+	if opts.Compress {
+		var packed []byte
+		packed, compressed, err = h.compression.compress(plaintext)
+		if err != nil {
+			h.logAudit("Encrypt", keyID, 0, false, err.Error())
+			return nil, 0, false, err
+		}
+		if compressed {
+			defer securebytes.Zero(packed)
+			plaintext, flags = packed, ciphertext.FlagCompressed
+		}
+	}
+
+	sealed, nonce, tag, keyVersion, err := h.EncryptWithOptions(keyID, plaintext, aad, EncryptOptions{DetachedTag: true})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	envelope, err = ciphertext.Envelope{
+		KeyID:      keyID,
+		KeyVersion: keyVersion,
+		Algorithm:  ciphertext.AlgorithmAES256GCM,
+		Flags:      flags,
+		Nonce:      nonce,
+		Tag:        tag,
+		Ciphertext: sealed,
+	}.Marshal()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return envelope, keyVersion, compressed, nil
+}
+
+// DecryptEnvelope opens an envelope with the key and version it names,
+// inflating a compressed plaintext. It returns the key ID and version too,
+// for callers tracking what their envelopes depend on.
+func (h *HSM) DecryptEnvelope(envelope, aad []byte) (plaintext []byte, keyID string, keyVersion int, err error) {
+	e, err := ciphertext.Parse(envelope)
+	if err != nil {
+		h.logAudit("Decrypt", "", 0, false, err.Error())
+		return nil, "", 0, err
+	}
+	plaintext, err = h.Decrypt(e.KeyID, e.Sealed(), e.Nonce, aad, e.KeyVersion)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if e.Flags&ciphertext.FlagCompressed != 0 {
+		packed := plaintext
+		plaintext, err = h.compression.decompress(packed)
+		securebytes.Zero(packed)
+		if err != nil {
+			h.logAudit("Decrypt", e.KeyID, e.KeyVersion, false, err.Error())
+			return nil, "", 0, err
+		}
+	}
+	return plaintext, e.KeyID, e.KeyVersion, nil
+}
@@ -0,0 +1,80 @@
+package hsm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/paymentgateway/go-common/ciphertext"
+)
+
+func TestEnvelopeSurvivesRotation(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("vault", "AES-256-GCM")
+	aad := []byte("12-2030")
+
+	envelope, version, err := h.EncryptEnvelope("vault", []byte("4111111111111111"), aad)
+	if err != nil || version != 1 {
+		t.Fatalf("EncryptEnvelope() = version %d, %v", version, err)
+	}
+	e, err := ciphertext.Parse(envelope)
+	if err != nil || e.KeyID != "vault" || e.KeyVersion != 1 || e.Algorithm != ciphertext.AlgorithmAES256GCM {
+		t.Fatalf("Parse() = %+v, %v", e, err)
+	}
+
+	h.RotateKey("vault")
+	plaintext, keyID, version, err := h.DecryptEnvelope(envelope, aad)
+	if err != nil || string(plaintext) != "4111111111111111" || keyID != "vault" || version != 1 {
+		t.Errorf("DecryptEnvelope() = %q, %s, %d, %v", plaintext, keyID, version, err)
+	}
+	if _, _, _, err := h.DecryptEnvelope(envelope, []byte("01-2031")); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("DecryptEnvelope() with the wrong AAD error = %v", err)
+	}
+	if _, _, _, err := h.DecryptEnvelope(envelope[:10], aad); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("DecryptEnvelope() of a truncated envelope error = %v", err)
+	}
+}
+
+func TestEnvelopeCompression(t *testing.T) {
+	h := NewHSM(WithCompression(CompressionConfig{MinBytes: 64, MaxBytes: 4096}))
+	h.GenerateKey("batch", "AES-256-GCM")
+	batch := []byte(strings.Repeat("4111111111111111,12,2030,approved\n", 200))
+
+	envelope, _, compressed, err := h.EncryptEnvelopeWithOptions("batch", batch[:3400], nil, EnvelopeOptions{Compress: true})
+	if err != nil || !compressed {
+		t.Fatalf("EncryptEnvelopeWithOptions() compressed = %v, %v", compressed, err)
+	}
+	if e, _ := ciphertext.Parse(envelope); e.Flags&ciphertext.FlagCompressed == 0 || len(e.Ciphertext) >= 3400 {
+		t.Errorf("envelope flags %#x with %d ciphertext bytes; want compressed", e.Flags, len(e.Ciphertext))
+	}
+	if plaintext, _, _, err := h.DecryptEnvelope(envelope, nil); err != nil || string(plaintext) != string(batch[:3400]) {
+		t.Errorf("DecryptEnvelope() = %d bytes, %v", len(plaintext), err)
+	}
+
+	// Too small to bother, and over the limit
+	if _, _, compressed, err := h.EncryptEnvelopeWithOptions("batch", batch[:32], nil, EnvelopeOptions{Compress: true}); err != nil || compressed {
+		t.Errorf("small plaintext compressed = %v, %v", compressed, err)
+	}
+	if _, _, _, err := h.EncryptEnvelopeWithOptions("batch", batch, nil, EnvelopeOptions{Compress: true}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("oversized plaintext error = %v, want ErrPayloadTooLarge", err)
+	}
+	if stats := h.CompressionStats(); stats.Compressed != 1 || stats.Skipped != 1 || stats.BytesIn != 3400 || stats.BytesOut >= 3400 {
+		t.Errorf("CompressionStats() = %+v", stats)
+	}
+}
+
+func TestDecompressionIsBounded(t *testing.T) {
+	big := NewHSM(WithCompression(CompressionConfig{MaxBytes: 1 << 16}))
+	big.GenerateKey("batch", "AES-256-GCM")
+	envelope, _, _, err := big.EncryptEnvelopeWithOptions("batch", make([]byte, 1<<16), nil, EnvelopeOptions{Compress: true})
+	if err != nil {
+		t.Fatalf("EncryptEnvelopeWithOptions() error = %v", err)
+	}
+
+	// The same key behind a smaller limit refuses to inflate it
+	small := NewHSM(WithCompression(CompressionConfig{MaxBytes: 1 << 12}))
+	small.keys = big.keys
+	if _, _, _, err := small.DecryptEnvelope(envelope, nil); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("DecryptEnvelope() error = %v, want ErrPayloadTooLarge", err)
+	}
+}
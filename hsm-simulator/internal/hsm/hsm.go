@@ -4,12 +4,14 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
 )
 
 var (
@@ -18,6 +20,7 @@ var (
 	ErrInvalidAlgorithm = errors.New("invalid algorithm")
 	ErrDecryptionFailed = errors.New("decryption failed")
 	ErrInvalidKeyVersion = errors.New("invalid key version")
+	ErrKeyExists        = errors.New("key already exists")
 )
 
 // KeyVersion represents a specific version of a cryptographic key
@@ -25,12 +28,19 @@ type KeyVersion struct {
 	Version   int
 	KeyData   []byte
 	CreatedAt time.Time
+	// nonces remembers the nonces recently used under this version
+	nonces nonceFilter
 }
 
 // KeyMetadata stores information about a key without exposing the key material
 type KeyMetadata struct {
 	KeyID            string
 	Algorithm        string
+	Type             KeyType
+	// KCV is the check value of the current version
+	KCV              string
+	// Attributes are the key's TR-31 mode of use and exportability
+	Attributes       KeyAttributes
 	CurrentVersion   int
 	AvailableVersions []int
 	CreatedAt        time.Time
@@ -41,6 +51,8 @@ type KeyMetadata struct {
 type Key struct {
 	ID        string
 	Algorithm string
+	Type      KeyType
+	Attributes KeyAttributes
 	Versions  map[int]*KeyVersion
 	CurrentVersion int
 	CreatedAt time.Time
@@ -54,6 +66,44 @@ type HSM struct {
 	mu        sync.RWMutex
 	auditLog  []AuditEntry
 	auditMu   sync.Mutex
+	
+	// Destructive operations, held for approval under dual control
+	dualControl bool
+	approvalTTL time.Duration
+	operations  map[string]*Operation
+	opsMu       sync.Mutex
+	now         func() time.Time
+	
+	// ZMK establishment with other parties, by exchange ID
+	zmkExchanges map[string]*ZMKExchange
+	zmkMu        sync.Mutex
+	
+	// profile, when set, adds realistic latency and a throughput ceiling.
+	// It can be swapped by a config reload while operations run.
+	profile atomic.Pointer[throttle]
+	
+	// Replication log of key changes, served to a standby of this HSM
+	transportKey []byte
+	changes      []change
+	changeSeq    uint64
+	changeLimit  int
+	trimmedSeq   uint64 // newest change dropped from the log
+	standby      bool
+	changeMu     sync.Mutex
+	
+	// Subscribers to key rollover advice
+	adviceSubs map[*AdviceSubscription]struct{}
+	adviceSeq  uint64
+	adviceMu   sync.Mutex
+	
+	// Commands each operator role may call, swapped by a config reload
+	permissions atomic.Pointer[PermissionMatrix]
+	
+	// Nonce reuse detection and its counters
+	nonces *nonceChecker
+	
+	// Envelope compression limits and counters
+	compression *compressor
 }
 
 // AuditEntry represents a log entry for key operations
@@ -64,18 +114,50 @@ type AuditEntry struct {
 	Version   int
 	Success   bool
 	Error     string
+	// Requester and Approver identify the operators of a destructive
+	// operation; both are empty for cryptographic operations
+	Requester string
+	Approver  string
+	// Detail describes what an administrative operation such as a config
+	// reload changed
+	Detail string
 }
 
+// Option configures an HSM
+type Option func(*HSM)
+
 // NewHSM creates a new HSM instance
-func NewHSM() *HSM {
-	return &HSM{
-		keys:     make(map[string]*Key),
-		auditLog: make([]AuditEntry, 0),
+func NewHSM(opts ...Option) *HSM {
+	h := &HSM{
+		keys:       make(map[string]*Key),
+		auditLog:   make([]AuditEntry, 0),
+		operations: make(map[string]*Operation),
+		zmkExchanges: make(map[string]*ZMKExchange),
+		changeLimit: DefaultReplicationLogLimit,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.nonces == nil {
+		h.nonces = newNonceChecker(NonceCheckConfig{})
+	}
+	if h.compression == nil {
+		h.compression = newCompressor(CompressionConfig{})
 	}
+	if h.permissions.Load() == nil {
+		m := DefaultPermissions()
+		h.permissions.Store(&m)
+	}
+	return h
 }
 
 // GenerateKey generates a new cryptographic key
 func (h *HSM) GenerateKey(keyID, algorithm string) (*KeyMetadata, error) {
+	return h.generateKey(keyID, algorithm, KeyTypeDEK, KeyAttributes{})
+}
+
+func (h *HSM) generateKey(keyID, algorithm string, keyType KeyType, attrs KeyAttributes) (*KeyMetadata, error) {
 	if keyID == "" {
 		return nil, ErrInvalidKeyID
 	}
@@ -84,13 +166,25 @@ func (h *HSM) GenerateKey(keyID, algorithm string) (*KeyMetadata, error) {
 		return nil, ErrInvalidAlgorithm
 	}
 	
+	attrs, err := attrs.resolve(keyType)
+	if err != nil {
+		h.logAudit("GenerateKey", keyID, 0, false, err.Error())
+		return nil, err
+	}
+	
+	if err := h.checkWritable(); err != nil {
+		h.logAudit("GenerateKey", keyID, 0, false, err.Error())
+		return nil, err
+	}
+	
+	h.simulate("GenerateKey")
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	
 	// Check if key already exists
 	if _, exists := h.keys[keyID]; exists {
 		h.logAudit("GenerateKey", keyID, 0, false, "key already exists")
-		return nil, fmt.Errorf("key %s already exists", keyID)
+		return nil, fmt.Errorf("%w: %s", ErrKeyExists, keyID)
 	}
 	
 	// Generate 256-bit (32-byte) key using cryptographically secure random
@@ -104,6 +198,8 @@ func (h *HSM) GenerateKey(keyID, algorithm string) (*KeyMetadata, error) {
 	key := &Key{
 		ID:             keyID,
 		Algorithm:      algorithm,
+		Type:           keyType,
+		Attributes:     attrs,
 		Versions:       make(map[int]*KeyVersion),
 		CurrentVersion: 1,
 		CreatedAt:      now,
@@ -117,65 +213,173 @@ func (h *HSM) GenerateKey(keyID, algorithm string) (*KeyMetadata, error) {
 	}
 	
 	h.keys[keyID] = key
+	h.recordChange(ChangeVersion, keyID, 1)
 	h.logAudit("GenerateKey", keyID, 1, true, "")
 	
-	return &KeyMetadata{
-		KeyID:             keyID,
-		Algorithm:         algorithm,
-		CurrentVersion:    1,
-		AvailableVersions: []int{1},
-		CreatedAt:         now,
-		LastRotatedAt:     now,
-	}, nil
+	return key.metadataLocked(), nil
 }
 
 // Encrypt encrypts plaintext using AES-256-GCM
 func (h *HSM) Encrypt(keyID string, plaintext, aad []byte) (ciphertext, nonce []byte, keyVersion int, err error) {
+	ciphertext, nonce, _, keyVersion, err = h.EncryptWithOptions(keyID, plaintext, aad, EncryptOptions{})
+	return ciphertext, nonce, keyVersion, err
+}
+
+// EncryptOptions selects optional ciphertext layouts, for simulating
+// systems that mandate their own
+type EncryptOptions struct {
+	// Nonce, if set, is used instead of a random one. It must be 12 bytes
+	// and is checked for reuse like the HSM's own nonces; under the reject
+	// policy a reused nonce fails with ErrNonceReuse.
+	Nonce []byte
+	// DetachedTag returns the 16-byte GCM tag separately instead of at the
+	// end of the ciphertext
+	DetachedTag bool
+}
+
+// EncryptWithOptions encrypts plaintext using AES-256-GCM with a caller's
+// nonce or a detached tag. tag is nil unless opts.DetachedTag is set.
+func (h *HSM) EncryptWithOptions(keyID string, plaintext, aad []byte, opts EncryptOptions) (ciphertext, nonce, tag []byte, keyVersion int, err error) {
+	h.simulate("Encrypt")
 	h.mu.RLock()
 	key, exists := h.keys[keyID]
 	h.mu.RUnlock()
 	
 	if !exists {
 		h.logAudit("Encrypt", keyID, 0, false, "key not found")
-		return nil, nil, 0, ErrKeyNotFound
+		return nil, nil, nil, 0, ErrKeyNotFound
 	}
 	
 	key.mu.RLock()
+	if err := h.checkUsage("Encrypt", key, KeyTypeDEK); err != nil {
+		key.mu.RUnlock()
+		return nil, nil, nil, 0, err
+	}
+	if err := h.checkMode("Encrypt", key, ModeEncrypt); err != nil {
+		key.mu.RUnlock()
+		return nil, nil, nil, 0, err
+	}
 	currentVersion := key.CurrentVersion
 	keyVersion = currentVersion
-	keyData := key.Versions[currentVersion].KeyData
+	kv := key.Versions[currentVersion]
+	// Copy the key under the lock: destroying a version zeroizes its bytes
+	// in place, which must not reach an operation already in flight
+	keyData := securebytes.Clone(kv.KeyData)
 	key.mu.RUnlock()
+	defer securebytes.Zero(keyData)
 	
 	// Create AES cipher
 	block, err := aes.NewCipher(keyData)
 	if err != nil {
 		h.logAudit("Encrypt", keyID, keyVersion, false, err.Error())
-		return nil, nil, 0, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, nil, nil, 0, fmt.Errorf("failed to create cipher: %w", err)
 	}
 	
 	// Create GCM mode
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
 		h.logAudit("Encrypt", keyID, keyVersion, false, err.Error())
-		return nil, nil, 0, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, nil, nil, 0, fmt.Errorf("failed to create GCM: %w", err)
 	}
 	
-	// Generate nonce
-	nonce = make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	// Use the caller's nonce, or generate one not used before under this
+	// version
+	if opts.Nonce != nil {
+		nonce = append([]byte(nil), opts.Nonce...)
+		if len(nonce) != gcm.NonceSize() {
+			err = fmt.Errorf("%w: want %d bytes, got %d", ErrInvalidNonce, gcm.NonceSize(), len(nonce))
+		} else {
+			err = h.checkNonce("Encrypt", keyID, keyVersion, kv, nonce)
+		}
+	} else {
+		nonce, err = h.nonce("Encrypt", keyID, keyVersion, kv, gcm.NonceSize())
+	}
+	if err != nil {
 		h.logAudit("Encrypt", keyID, keyVersion, false, err.Error())
-		return nil, nil, 0, fmt.Errorf("failed to generate nonce: %w", err)
+		return nil, nil, nil, 0, err
 	}
 	
 	// Encrypt
 	ciphertext = gcm.Seal(nil, nonce, plaintext, aad)
+	if opts.DetachedTag {
+		split := len(ciphertext) - gcm.Overhead()
+		ciphertext, tag = ciphertext[:split:split], ciphertext[split:]
+	}
 	
 	h.logAudit("Encrypt", keyID, keyVersion, true, "")
-	return ciphertext, nonce, keyVersion, nil
+	return ciphertext, nonce, tag, keyVersion, nil
+}
+
+// GenerateDataKey generates a 256-bit data key and returns it in plaintext
+// and wrapped under the current version of the master key. Callers encrypt
+// locally with the plaintext key, store only the wrapped key and unwrap it
+// later with Decrypt and the same AAD.
+func (h *HSM) GenerateDataKey(keyID string, aad []byte) (plaintext, wrapped, nonce []byte, keyVersion int, err error) {
+	h.simulate("GenerateDataKey")
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	
+	if !exists {
+		h.logAudit("GenerateDataKey", keyID, 0, false, "key not found")
+		return nil, nil, nil, 0, ErrKeyNotFound
+	}
+	
+	key.mu.RLock()
+	if err := h.checkUsage("GenerateDataKey", key, KeyTypeDEK); err != nil {
+		key.mu.RUnlock()
+		return nil, nil, nil, 0, err
+	}
+	if err := h.checkMode("GenerateDataKey", key, ModeEncrypt); err != nil {
+		key.mu.RUnlock()
+		return nil, nil, nil, 0, err
+	}
+	keyVersion = key.CurrentVersion
+	kv := key.Versions[keyVersion]
+	keyData := securebytes.Clone(kv.KeyData)
+	key.mu.RUnlock()
+	defer securebytes.Zero(keyData)
+	
+	// Generate the data key
+	plaintext = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		securebytes.Zero(plaintext)
+		h.logAudit("GenerateDataKey", keyID, keyVersion, false, err.Error())
+		return nil, nil, nil, 0, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	
+	// Wrap it with the master key. The plaintext key is zeroized on failure;
+	// on success it belongs to the caller.
+	block, err := aes.NewCipher(keyData)
+	if err != nil {
+		securebytes.Zero(plaintext)
+		h.logAudit("GenerateDataKey", keyID, keyVersion, false, err.Error())
+		return nil, nil, nil, 0, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		securebytes.Zero(plaintext)
+		h.logAudit("GenerateDataKey", keyID, keyVersion, false, err.Error())
+		return nil, nil, nil, 0, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	
+	nonce, err = h.nonce("GenerateDataKey", keyID, keyVersion, kv, gcm.NonceSize())
+	if err != nil {
+		securebytes.Zero(plaintext)
+		h.logAudit("GenerateDataKey", keyID, keyVersion, false, err.Error())
+		return nil, nil, nil, 0, err
+	}
+	
+	wrapped = gcm.Seal(nil, nonce, plaintext, aad)
+	
+	h.logAudit("GenerateDataKey", keyID, keyVersion, true, "")
+	return plaintext, wrapped, nonce, keyVersion, nil
 }
 
 // Decrypt decrypts ciphertext using AES-256-GCM
 func (h *HSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
+	h.simulate("Decrypt")
 	h.mu.RLock()
 	key, exists := h.keys[keyID]
 	h.mu.RUnlock()
@@ -186,16 +390,29 @@ func (h *HSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion in
 	}
 	
 	key.mu.RLock()
+	if err := h.checkUsage("Decrypt", key, KeyTypeDEK); err != nil {
+		key.mu.RUnlock()
+		return nil, err
+	}
+	if err := h.checkMode("Decrypt", key, ModeDecrypt); err != nil {
+		key.mu.RUnlock()
+		return nil, err
+	}
 	version, versionExists := key.Versions[keyVersion]
+	var keyData []byte
+	if versionExists {
+		keyData = securebytes.Clone(version.KeyData)
+	}
 	key.mu.RUnlock()
 	
 	if !versionExists {
 		h.logAudit("Decrypt", keyID, keyVersion, false, "key version not found")
 		return nil, ErrInvalidKeyVersion
 	}
+	defer securebytes.Zero(keyData)
 	
 	// Create AES cipher
-	block, err := aes.NewCipher(version.KeyData)
+	block, err := aes.NewCipher(keyData)
 	if err != nil {
 		h.logAudit("Decrypt", keyID, keyVersion, false, err.Error())
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
@@ -219,8 +436,21 @@ func (h *HSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion in
 	return plaintext, nil
 }
 
+// DecryptDetached decrypts ciphertext whose GCM tag is kept separately, as
+// returned by EncryptWithOptions with DetachedTag
+func (h *HSM) DecryptDetached(keyID string, ciphertext, tag, nonce, aad []byte, keyVersion int) ([]byte, error) {
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	return h.Decrypt(keyID, append(append(sealed, ciphertext...), tag...), nonce, aad, keyVersion)
+}
+
 // RotateKey creates a new version of an existing key
 func (h *HSM) RotateKey(keyID string) (newVersion, oldVersion int, err error) {
+	h.simulate("RotateKey")
+	if err := h.checkWritable(); err != nil {
+		h.logAudit("RotateKey", keyID, 0, false, err.Error())
+		return 0, 0, err
+	}
+	
 	h.mu.RLock()
 	key, exists := h.keys[keyID]
 	h.mu.RUnlock()
@@ -251,6 +481,8 @@ func (h *HSM) RotateKey(keyID string) (newVersion, oldVersion int, err error) {
 	
 	key.CurrentVersion = newVersion
 	key.LastRotatedAt = time.Now()
+	h.recordChange(ChangeVersion, keyID, newVersion)
+	h.advise(Advice{Kind: AdviceRotated, KeyID: keyID, Version: newVersion, CurrentVersion: newVersion})
 	
 	h.logAudit("RotateKey", keyID, newVersion, true, "")
 	return newVersion, oldVersion, nil
@@ -268,20 +500,7 @@ func (h *HSM) GetKeyInfo(keyID string) (*KeyMetadata, error) {
 	
 	key.mu.RLock()
 	defer key.mu.RUnlock()
-	
-	versions := make([]int, 0, len(key.Versions))
-	for v := range key.Versions {
-		versions = append(versions, v)
-	}
-	
-	return &KeyMetadata{
-		KeyID:             key.ID,
-		Algorithm:         key.Algorithm,
-		CurrentVersion:    key.CurrentVersion,
-		AvailableVersions: versions,
-		CreatedAt:         key.CreatedAt,
-		LastRotatedAt:     key.LastRotatedAt,
-	}, nil
+	return key.metadataLocked(), nil
 }
 
 // GetAuditLog returns all audit log entries
@@ -295,6 +514,25 @@ func (h *HSM) GetAuditLog() []AuditEntry {
 	return logCopy
 }
 
+// RecordConfigReload audits a reload of the server's configuration: who or
+// what triggered it and the settings it changed
+func (h *HSM) RecordConfigReload(requester, detail string, err error) {
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+	
+	entry := AuditEntry{
+		Timestamp: time.Now(),
+		Operation: "ReloadConfig",
+		Success:   err == nil,
+		Requester: requester,
+		Detail:    detail,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	h.auditLog = append(h.auditLog, entry)
+}
+
 // logAudit adds an entry to the audit log
 func (h *HSM) logAudit(operation, keyID string, version int, success bool, errorMsg string) {
 	h.auditMu.Lock()
@@ -311,25 +549,3 @@ func (h *HSM) logAudit(operation, keyID string, version int, success bool, error
 	
 	h.auditLog = append(h.auditLog, entry)
 }
-
-// ExportKeyForTesting exports key data for testing purposes only
-// This violates the security principle and should NEVER be used in production
-func (h *HSM) ExportKeyForTesting(keyID string, version int) (string, error) {
-	h.mu.RLock()
-	key, exists := h.keys[keyID]
-	h.mu.RUnlock()
-	
-	if !exists {
-		return "", ErrKeyNotFound
-	}
-	
-	key.mu.RLock()
-	defer key.mu.RUnlock()
-	
-	keyVersion, versionExists := key.Versions[version]
-	if !versionExists {
-		return "", ErrInvalidKeyVersion
-	}
-	
-	return hex.EncodeToString(keyVersion.KeyData), nil
-}
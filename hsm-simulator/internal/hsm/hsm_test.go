@@ -1,8 +1,11 @@
 package hsm
 
 import (
+	"bytes"
 	"sync"
 	"testing"
+
+	"github.com/paymentgateway/go-common/securebytes/securetest"
 )
 
 // Test invalid key IDs
@@ -348,3 +351,52 @@ func TestLargePlaintext(t *testing.T) {
 		}
 	}
 }
+
+// Test data key generation and unwrapping
+func TestGenerateDataKey(t *testing.T) {
+	hsm := NewHSM()
+	
+	if _, _, _, _, err := hsm.GenerateDataKey("test-key", nil); err != ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	
+	if _, err := hsm.GenerateKey("test-key", "AES-256-GCM"); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	
+	aad := []byte("envelope:1")
+	plaintext, wrapped, nonce, keyVersion, err := hsm.GenerateDataKey("test-key", aad)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	if len(plaintext) != 32 {
+		t.Errorf("Expected 32-byte data key, got %d bytes", len(plaintext))
+	}
+	if bytes.Contains(wrapped, plaintext) {
+		t.Error("Wrapped key contains the plaintext key")
+	}
+	
+	// The wrapped key unwraps with Decrypt, even after rotation
+	if _, _, err := hsm.RotateKey("test-key"); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	unwrapped, err := hsm.Decrypt("test-key", wrapped, nonce, aad, keyVersion)
+	if err != nil || !bytes.Equal(unwrapped, plaintext) {
+		t.Errorf("Unwrap failed: %v", err)
+	}
+	
+	// AAD binds the wrapped key to its context
+	if _, err := hsm.Decrypt("test-key", wrapped, nonce, []byte("envelope:2"), keyVersion); err != ErrDecryptionFailed {
+		t.Errorf("Expected ErrDecryptionFailed for wrong AAD, got %v", err)
+	}
+	
+	auditLog := hsm.GetAuditLog()
+	if auditLog[2].Operation != "GenerateDataKey" || !auditLog[2].Success {
+		t.Errorf("Expected successful GenerateDataKey audit entry, got %+v", auditLog[2])
+	}
+}
+
+// Secrets such as PAN hashes and MACs must only be compared in constant time
+func TestConstantTimeComparisons(t *testing.T) {
+	securetest.AssertConstantTime(t, "../..")
+}
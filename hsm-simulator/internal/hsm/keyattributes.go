@@ -0,0 +1,190 @@
+package hsm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidKeyAttributes = errors.New("invalid key attributes")
+	ErrKeyNotExportable     = errors.New("key is not exportable")
+)
+
+// KeyMode is a TR-31 mode of use, which narrows what a key may do within
+// what its type permits
+type KeyMode string
+
+const (
+	ModeBoth           KeyMode = "B" // encrypt and decrypt, or wrap and unwrap
+	ModeGenerateVerify KeyMode = "C" // generate and verify
+	ModeDecrypt        KeyMode = "D" // decrypt or unwrap only
+	ModeEncrypt        KeyMode = "E" // encrypt or wrap only
+	ModeGenerate       KeyMode = "G" // generate only
+	ModeVerify         KeyMode = "V" // verify only
+	ModeDerive         KeyMode = "X" // derive other keys only
+)
+
+// Exportability is the TR-31 exportability of a key
+type Exportability string
+
+const (
+	// ExportableTrusted keys may be exported, but only in trusted key
+	// blocks such as TR-31
+	ExportableTrusted Exportability = "E"
+	// NonExportable keys never leave the HSM that holds them
+	NonExportable Exportability = "N"
+	// ExportableSensitive keys may also be exported in forms that are not
+	// trusted key blocks
+	ExportableSensitive Exportability = "S"
+)
+
+// KeyAttributes are a key's mode of use and exportability. They are bound
+// into the key blocks that carry the key, so the receiving HSM enforces
+// them too. Empty fields mean the widest the key type allows: its first
+// mode of use, and trusted export for working keys.
+type KeyAttributes struct {
+	Mode          KeyMode
+	Exportability Exportability
+}
+
+// keyModes are the modes of use each key type may have; the first is the
+// default
+var keyModes = map[KeyType][]KeyMode{
+	KeyTypeZMK: {ModeBoth, ModeEncrypt, ModeDecrypt},
+	KeyTypeZPK: {ModeBoth, ModeEncrypt, ModeDecrypt},
+	KeyTypeCVK: {ModeGenerateVerify, ModeGenerate, ModeVerify},
+	KeyTypeDEK: {ModeBoth, ModeEncrypt, ModeDecrypt},
+	KeyTypeBDK: {ModeDerive},
+	KeyTypePVK: {ModeGenerateVerify, ModeGenerate, ModeVerify},
+}
+
+// ParseKeyAttributes parses a mode of use and exportability for a key of
+// the type; empty strings take the defaults
+func ParseKeyAttributes(keyType KeyType, mode, exportability string) (KeyAttributes, error) {
+	attrs := KeyAttributes{
+		Mode:          KeyMode(strings.ToUpper(mode)),
+		Exportability: Exportability(strings.ToUpper(exportability)),
+	}
+	return attrs.resolve(keyType)
+}
+
+// resolve fills in the defaults and checks the attributes suit the key type
+func (a KeyAttributes) resolve(keyType KeyType) (KeyAttributes, error) {
+	keyType = keyTypeOrDEK(keyType)
+	modes, ok := keyModes[keyType]
+	if !ok {
+		return KeyAttributes{}, ErrInvalidKeyType
+	}
+	if a.Mode == "" {
+		a.Mode = modes[0]
+	}
+	if !containsMode(modes, a.Mode) {
+		return KeyAttributes{}, fmt.Errorf("%w: mode of use %q, a %s may have %s", ErrInvalidKeyAttributes, a.Mode, keyType, joinModes(modes))
+	}
+	switch a.Exportability {
+	case "":
+		a.Exportability = NonExportable
+		if keyType.Working() {
+			a.Exportability = ExportableTrusted
+		}
+	case NonExportable:
+	case ExportableTrusted, ExportableSensitive:
+		if !keyType.Working() {
+			return KeyAttributes{}, fmt.Errorf("%w: a %s is never exportable", ErrInvalidKeyAttributes, keyType)
+		}
+	default:
+		return KeyAttributes{}, fmt.Errorf("%w: exportability %q, want E, N or S", ErrInvalidKeyAttributes, a.Exportability)
+	}
+	return a, nil
+}
+
+// permits reports whether a key with mode of use m may perform operations
+// of mode op
+func (m KeyMode) permits(op KeyMode) bool {
+	switch m {
+	case op:
+		return true
+	case ModeBoth:
+		return op == ModeEncrypt || op == ModeDecrypt
+	case ModeGenerateVerify:
+		return op == ModeGenerate || op == ModeVerify
+	}
+	return false
+}
+
+// narrow returns the attributes an exported copy of a key with attributes
+// a gets when the exporter asks for b: the same or narrower, never wider
+func (a KeyAttributes) narrow(keyType KeyType, b KeyAttributes) (KeyAttributes, error) {
+	if a.Exportability == NonExportable {
+		return KeyAttributes{}, ErrKeyNotExportable
+	}
+	if b.Mode == "" {
+		b.Mode = a.Mode
+	}
+	if b.Exportability == "" {
+		b.Exportability = a.Exportability
+	}
+	b, err := b.resolve(keyType)
+	if err != nil {
+		return KeyAttributes{}, err
+	}
+	if !a.Mode.permits(b.Mode) {
+		return KeyAttributes{}, fmt.Errorf("%w: cannot widen mode of use %s to %s", ErrInvalidKeyAttributes, a.Mode, b.Mode)
+	}
+	if a.Exportability == ExportableTrusted && b.Exportability == ExportableSensitive {
+		return KeyAttributes{}, fmt.Errorf("%w: cannot widen exportability E to S", ErrInvalidKeyAttributes)
+	}
+	return b, nil
+}
+
+// attributesLocked returns the key's attributes with defaults filled in,
+// for keys created before attributes existed. key.mu must be held.
+func (key *Key) attributesLocked() KeyAttributes {
+	attrs, err := key.Attributes.resolve(key.Type)
+	if err != nil {
+		return key.Attributes
+	}
+	return attrs
+}
+
+// checkMode fails unless the key's mode of use permits operations of mode
+// op. key.mu must be held.
+func (h *HSM) checkMode(operation string, key *Key, op KeyMode) error {
+	mode := key.attributesLocked().Mode
+	if mode.permits(op) {
+		return nil
+	}
+	h.logAudit(operation, key.ID, 0, false, "mode of use")
+	return fmt.Errorf("%w: %s has mode of use %s", ErrKeyUsage, key.ID, mode)
+}
+
+// checkKeyMode is checkMode for a key by ID
+func (h *HSM) checkKeyMode(operation, keyID string, op KeyMode) error {
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return ErrKeyNotFound
+	}
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return h.checkMode(operation, key, op)
+}
+
+func containsMode(modes []KeyMode, mode KeyMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func joinModes(modes []KeyMode) string {
+	s := make([]string, len(modes))
+	for i, m := range modes {
+		s[i] = string(m)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,506 @@
+package hsm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrInvalidKeyType  = errors.New("invalid key type")
+	ErrKeyUsage        = errors.New("key type does not permit this operation")
+	ErrInvalidKeyBlock = errors.New("invalid key block")
+	ErrKeyBlockMAC     = errors.New("key block authentication failed")
+	ErrKCVMismatch     = errors.New("key check value mismatch")
+)
+
+// The HSM models the key hierarchy of a payment HSM. Its local master key
+// (LMK) is the root: every key it holds is protected by it and never leaves
+// in the clear. Zone master keys (ZMKs) are shared with another party, once,
+// under dual control, and only ever encrypt working keys for exchange with
+// that party. Working keys do the actual work: zone PIN keys (ZPKs) protect
+// PIN blocks between zones, PIN verification keys (PVKs) verify PINs
+// against the offsets and PVVs issuers keep, card verification keys (CVKs)
+// compute card verification values, and data encryption keys (DEKs)
+// encrypt data, which is what every key created without a type is. Base
+// derivation keys (BDKs) stand apart: they never leave the HSM, and only
+// derive the initial keys injected into P2PE terminals and the DUKPT keys
+// that decrypt their data.
+
+// KeyType is a key's place in the hierarchy, which fixes what it may do
+type KeyType string
+
+const (
+	KeyTypeZMK KeyType = "ZMK"
+	KeyTypeZPK KeyType = "ZPK"
+	KeyTypeCVK KeyType = "CVK"
+	KeyTypeDEK KeyType = "DEK"
+	KeyTypeBDK KeyType = "BDK"
+	KeyTypePVK KeyType = "PVK"
+)
+
+// ParseKeyType parses a key type; empty means DEK
+func ParseKeyType(s string) (KeyType, error) {
+	switch t := KeyType(strings.ToUpper(s)); t {
+	case "":
+		return KeyTypeDEK, nil
+	case KeyTypeZMK, KeyTypeZPK, KeyTypeCVK, KeyTypeDEK, KeyTypeBDK, KeyTypePVK:
+		return t, nil
+	}
+	return "", fmt.Errorf("%w: %q, want ZMK, ZPK, CVK, DEK, BDK or PVK", ErrInvalidKeyType, s)
+}
+
+// Working reports whether keys of the type are working keys, which are
+// exchanged under a ZMK
+func (t KeyType) Working() bool {
+	return t == KeyTypeZPK || t == KeyTypeCVK || t == KeyTypeDEK || t == KeyTypePVK
+}
+
+// keyBlockUsage maps key types to TR-31 key usage
+var keyBlockUsage = map[KeyType]string{
+	KeyTypeZMK: "K0", // key encryption or wrapping
+	KeyTypeZPK: "P0", // PIN encryption
+	KeyTypeCVK: "C0", // card verification
+	KeyTypeDEK: "D0", // symmetric data encryption
+	KeyTypeBDK: "B0", // base derivation key
+	KeyTypePVK: "V0", // PIN verification
+}
+
+// KeyCheckValue identifies a key without revealing it, so both parties of
+// an exchange can confirm they hold the same key: the leftmost three bytes
+// of the AES-CMAC of a zero block, as hex (the CMAC method of X9.24-1)
+func KeyCheckValue(key []byte) string {
+	return strings.ToUpper(hex.EncodeToString(cmac(key, make([]byte, aes.BlockSize))[:3]))
+}
+
+// GenerateKeyOfType generates a key of a type of the hierarchy. ZMKs are
+// generated here only for simulations that play both parties; a real ZMK
+// is formed from components under dual control with ImportKeyOfType.
+func (h *HSM) GenerateKeyOfType(keyID, algorithm string, keyType KeyType) (*KeyMetadata, error) {
+	return h.GenerateKeyWithAttributes(keyID, algorithm, keyType, KeyAttributes{})
+}
+
+// GenerateKeyWithAttributes generates a key of a type with a narrower mode
+// of use or exportability than the type's defaults, e.g. a non-exportable
+// ZPK or a CVK that only verifies
+func (h *HSM) GenerateKeyWithAttributes(keyID, algorithm string, keyType KeyType, attrs KeyAttributes) (*KeyMetadata, error) {
+	if _, ok := keyBlockUsage[keyType]; !ok {
+		h.logAudit("GenerateKey", keyID, 0, false, "invalid key type")
+		return nil, ErrInvalidKeyType
+	}
+	return h.generateKey(keyID, algorithm, keyType, attrs)
+}
+
+// ExportKey encrypts the current version of a working key under a ZMK as
+// a TR-31 key block (version D: AES, with keys derived from the ZMK by
+// CMAC) for sending to the party that shares the ZMK. It returns the block
+// and the key's check value, which is sent alongside it. The block carries
+// the key's mode of use and exportability; non-exportable keys fail with
+// ErrKeyNotExportable.
+func (h *HSM) ExportKey(keyID, zmkID string) (keyBlock, kcv string, err error) {
+	return h.ExportKeyWithAttributes(keyID, zmkID, KeyAttributes{})
+}
+
+// ExportKeyWithAttributes exports a key like ExportKey, binding narrower
+// attributes into the key block than the key's own: a mode of use it
+// permits, or non-exportable so the receiver cannot pass the key on. Empty
+// fields keep the key's own; widening fails with ErrInvalidKeyAttributes.
+func (h *HSM) ExportKeyWithAttributes(keyID, zmkID string, attrs KeyAttributes) (keyBlock, kcv string, err error) {
+	h.simulate("ExportKey")
+	keyType, keyData, _, err := h.currentKey(keyID)
+	if err != nil {
+		h.logAudit("ExportKey", keyID, 0, false, err.Error())
+		return "", "", err
+	}
+	defer securebytes.Zero(keyData)
+	if !keyType.Working() {
+		h.logAudit("ExportKey", keyID, 0, false, "not a working key")
+		return "", "", fmt.Errorf("%w: only working keys can be exported, %s is a %s", ErrKeyUsage, keyID, keyType)
+	}
+	if attrs, err = h.keyAttributes(keyID).narrow(keyType, attrs); err != nil {
+		h.logAudit("ExportKey", keyID, 0, false, err.Error())
+		return "", "", err
+	}
+	zmk, err := h.zoneMasterKey(zmkID, ModeEncrypt)
+	if err != nil {
+		h.logAudit("ExportKey", keyID, 0, false, err.Error())
+		return "", "", err
+	}
+	defer securebytes.Zero(zmk)
+
+	keyBlock, err = wrapKeyBlock(zmk, keyData, keyType, attrs)
+	if err != nil {
+		h.logAudit("ExportKey", keyID, 0, false, err.Error())
+		return "", "", err
+	}
+	h.logAudit("ExportKey", keyID, 0, true, "")
+	return keyBlock, KeyCheckValue(keyData), nil
+}
+
+// ImportKeyBlock decrypts a working key received under a ZMK and stores it,
+// or queues the import for approval under dual control. The key block's
+// usage sets the key's type, and its mode of use and exportability, which
+// must suit that type, become the key's and are enforced from then on. A key
+// change, the key becoming a new version of an existing keyID of the same
+// type and attributes, must be requested with rotate; otherwise an existing
+// keyID fails with ErrKeyExists. kcv, when given, must match the received
+// key.
+func (h *HSM) ImportKeyBlock(requester, keyID, zmkID, keyBlock, kcv string, rotate bool) (*Operation, error) {
+	h.simulate("ImportKeyBlock")
+	keyType, err := h.checkImportKeyBlock(keyID, zmkID, keyBlock, kcv, rotate)
+	if err != nil {
+		h.logAuditBy(string(OpImportKeyBlock), keyID, 0, err, requester, "")
+		return nil, err
+	}
+	return h.submit(&Operation{
+		Kind:      OpImportKeyBlock,
+		KeyID:     keyID,
+		KeyType:   keyType,
+		ZMKID:     zmkID,
+		Rotate:    rotate,
+		Requester: requester,
+		keyBlock:  keyBlock,
+		kcv:       kcv,
+	})
+}
+
+// checkImportKeyBlock validates an import before it is queued, so an
+// approver is never asked to sign off on a block that cannot be imported
+func (h *HSM) checkImportKeyBlock(keyID, zmkID, keyBlock, kcv string, rotate bool) (KeyType, error) {
+	if err := h.checkWritable(); err != nil {
+		return "", err
+	}
+	if keyID == "" {
+		return "", ErrInvalidKeyID
+	}
+	keyData, keyType, attrs, err := h.openKeyBlock(zmkID, keyBlock, kcv)
+	if err != nil {
+		return "", err
+	}
+	securebytes.Zero(keyData)
+
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return keyType, checkKeyChange(keyID, nil, keyType, attrs, rotate)
+	}
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return keyType, checkKeyChange(keyID, key, keyType, attrs, rotate)
+}
+
+// importKeyBlock re-checks the import, since the key may have been created
+// or rotated while the operation waited for approval
+func (h *HSM) importKeyBlock(keyID, zmkID, keyBlock, kcv string, rotate bool) (*KeyMetadata, error) {
+	if err := h.checkWritable(); err != nil {
+		return nil, err
+	}
+	if keyID == "" {
+		return nil, ErrInvalidKeyID
+	}
+	keyData, keyType, attrs, err := h.openKeyBlock(zmkID, keyBlock, kcv)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(keyData)
+
+	now := h.now()
+	h.mu.Lock()
+	key, exists := h.keys[keyID]
+	if !exists {
+		if err := checkKeyChange(keyID, nil, keyType, attrs, rotate); err != nil {
+			h.mu.Unlock()
+			return nil, err
+		}
+		key = &Key{
+			ID:             keyID,
+			Algorithm:      "AES-256-GCM",
+			Type:           keyType,
+			Attributes:     attrs,
+			Versions:       map[int]*KeyVersion{1: {Version: 1, KeyData: securebytes.Clone(keyData), CreatedAt: now}},
+			CurrentVersion: 1,
+			CreatedAt:      now,
+			LastRotatedAt:  now,
+		}
+		h.keys[keyID] = key
+		h.recordChange(ChangeVersion, keyID, 1)
+		meta := key.metadataLocked()
+		h.mu.Unlock()
+		return meta, nil
+	}
+	h.mu.Unlock()
+
+	key.mu.Lock()
+	defer key.mu.Unlock()
+	if err := checkKeyChange(keyID, key, keyType, attrs, rotate); err != nil {
+		return nil, err
+	}
+	version := key.CurrentVersion + 1
+	key.Versions[version] = &KeyVersion{Version: version, KeyData: securebytes.Clone(keyData), CreatedAt: now}
+	key.CurrentVersion = version
+	key.LastRotatedAt = now
+	h.recordChange(ChangeVersion, keyID, version)
+	h.advise(Advice{Kind: AdviceRotated, KeyID: keyID, Version: version, CurrentVersion: version})
+	return key.metadataLocked(), nil
+}
+
+// openKeyBlock decrypts a key block under a ZMK and checks that it holds a
+// working key matching kcv. The caller must zeroize the key.
+func (h *HSM) openKeyBlock(zmkID, keyBlock, kcv string) ([]byte, KeyType, KeyAttributes, error) {
+	zmk, err := h.zoneMasterKey(zmkID, ModeDecrypt)
+	if err != nil {
+		return nil, "", KeyAttributes{}, err
+	}
+	defer securebytes.Zero(zmk)
+
+	keyData, keyType, attrs, err := unwrapKeyBlock(zmk, keyBlock)
+	if err != nil {
+		return nil, "", KeyAttributes{}, err
+	}
+	if !keyType.Working() {
+		securebytes.Zero(keyData)
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: a %s cannot be imported under a ZMK", ErrKeyUsage, keyType)
+	}
+	if kcv != "" && !strings.EqualFold(kcv, KeyCheckValue(keyData)) {
+		securebytes.Zero(keyData)
+		return nil, "", KeyAttributes{}, ErrKCVMismatch
+	}
+	return keyData, keyType, attrs, nil
+}
+
+// checkKeyChange checks whether a received key may be stored under keyID,
+// whose key is nil if there is none. Only a rotation may add a version to an
+// existing key, and the key must keep its type and attributes; a rotation
+// needs a key to rotate. key.mu must be held.
+func checkKeyChange(keyID string, key *Key, keyType KeyType, attrs KeyAttributes, rotate bool) error {
+	switch {
+	case key == nil && rotate:
+		return fmt.Errorf("%w: %s has no key to change", ErrKeyNotFound, keyID)
+	case key == nil:
+		return nil
+	case !rotate:
+		return fmt.Errorf("%w: %s; request a rotation to change it", ErrKeyExists, keyID)
+	case key.Type != keyType:
+		return fmt.Errorf("%w: %s is a %s, the key block holds a %s", ErrKeyUsage, keyID, key.Type, keyType)
+	}
+	if current := key.attributesLocked(); current != attrs {
+		return fmt.Errorf("%w: %s has mode of use %s and exportability %s, the key block %s and %s",
+			ErrInvalidKeyAttributes, keyID, current.Mode, current.Exportability, attrs.Mode, attrs.Exportability)
+	}
+	return nil
+}
+
+// checkUsage fails unless the key is of one of the types, for operations
+// that only some levels of the hierarchy may perform. key.mu must be held.
+func (h *HSM) checkUsage(operation string, key *Key, allowed ...KeyType) error {
+	for _, t := range allowed {
+		if key.Type == t {
+			return nil
+		}
+	}
+	h.logAudit(operation, key.ID, 0, false, "key usage")
+	return fmt.Errorf("%w: %s is a %s", ErrKeyUsage, key.ID, key.Type)
+}
+
+// currentKey returns a key's type and a copy of its current material, which
+// the caller must zeroize
+func (h *HSM) currentKey(keyID string) (KeyType, []byte, int, error) {
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return "", nil, 0, ErrKeyNotFound
+	}
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return key.Type, securebytes.Clone(key.Versions[key.CurrentVersion].KeyData), key.CurrentVersion, nil
+}
+
+// keyAttributes returns a key's attributes, which never change
+func (h *HSM) keyAttributes(keyID string) KeyAttributes {
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return KeyAttributes{}
+	}
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	return key.attributesLocked()
+}
+
+// zoneMasterKey returns a copy of the current version of a ZMK, whose mode
+// of use must permit wrapping (ModeEncrypt) or unwrapping (ModeDecrypt)
+func (h *HSM) zoneMasterKey(zmkID string, op KeyMode) ([]byte, error) {
+	keyType, zmk, _, err := h.currentKey(zmkID)
+	if err != nil {
+		return nil, err
+	}
+	if keyType != KeyTypeZMK {
+		securebytes.Zero(zmk)
+		return nil, fmt.Errorf("%w: %s is a %s, not a ZMK", ErrKeyUsage, zmkID, keyType)
+	}
+	if mode := h.keyAttributes(zmkID).Mode; !mode.permits(op) {
+		securebytes.Zero(zmk)
+		return nil, fmt.Errorf("%w: %s has mode of use %s", ErrKeyUsage, zmkID, mode)
+	}
+	return zmk, nil
+}
+
+// metadataLocked describes the key. key.mu must be held.
+func (key *Key) metadataLocked() *KeyMetadata {
+	versions := make([]int, 0, len(key.Versions))
+	for v := range key.Versions {
+		versions = append(versions, v)
+	}
+	return &KeyMetadata{
+		KeyID:             key.ID,
+		Algorithm:         key.Algorithm,
+		Type:              key.Type,
+		KCV:               key.checkValueLocked(),
+		Attributes:        key.attributesLocked(),
+		CurrentVersion:    key.CurrentVersion,
+		AvailableVersions: versions,
+		CreatedAt:         key.CreatedAt,
+		LastRotatedAt:     key.LastRotatedAt,
+	}
+}
+
+// checkValueLocked returns the check value of the current version, if it is
+// present. key.mu must be held.
+func (key *Key) checkValueLocked() string {
+	v, ok := key.Versions[key.CurrentVersion]
+	if !ok {
+		return ""
+	}
+	return KeyCheckValue(v.KeyData)
+}
+
+// TR-31 key block, version D. The header is 16 ASCII characters: version,
+// block length, key usage, algorithm, mode of use, key version number,
+// exportability, number of optional blocks and a reserved field. The
+// payload (key length in bits, key, random padding) is encrypted with
+// AES-CBC under a key block encryption key, using the MAC as the IV; the
+// MAC is the AES-CMAC of the header and clear payload under a key block
+// authentication key. Both keys are derived from the key block protection
+// key, here the ZMK.
+
+const (
+	keyBlockVersion    = 'D'
+	keyBlockHeaderLen  = 16
+	keyBlockMACLen     = 16
+	keyBlockPayloadLen = 48 // 2-byte length, 32-byte key, padded to blocks
+)
+
+func wrapKeyBlock(kbpk, key []byte, keyType KeyType, attrs KeyAttributes) (string, error) {
+	length := keyBlockHeaderLen + 2*keyBlockPayloadLen + 2*keyBlockMACLen
+	header := fmt.Sprintf("%c%04d%sA%s00%s0000", keyBlockVersion, length, keyBlockUsage[keyType], attrs.Mode, attrs.Exportability)
+
+	payload := make([]byte, keyBlockPayloadLen)
+	defer securebytes.Zero(payload)
+	binary.BigEndian.PutUint16(payload, uint16(len(key)*8))
+	copy(payload[2:], key)
+	if _, err := io.ReadFull(rand.Reader, payload[2+len(key):]); err != nil {
+		return "", fmt.Errorf("failed to generate padding: %w", err)
+	}
+
+	kbek, kbak := deriveKeyBlockKeys(kbpk)
+	defer securebytes.Zero(kbek)
+	defer securebytes.Zero(kbak)
+	mac := cmac(kbak, append([]byte(header), payload...))
+	block, err := aes.NewCipher(kbek)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+	encrypted := make([]byte, len(payload))
+	cipher.NewCBCEncrypter(block, mac).CryptBlocks(encrypted, payload)
+
+	return header + strings.ToUpper(hex.EncodeToString(encrypted)+hex.EncodeToString(mac)), nil
+}
+
+func unwrapKeyBlock(kbpk []byte, keyBlock string) ([]byte, KeyType, KeyAttributes, error) {
+	if len(keyBlock) < keyBlockHeaderLen || keyBlock[0] != keyBlockVersion {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: want a version D block", ErrInvalidKeyBlock)
+	}
+	header := keyBlock[:keyBlockHeaderLen]
+	if length, err := strconv.Atoi(header[1:5]); err != nil || length != len(keyBlock) {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: length field does not match the block", ErrInvalidKeyBlock)
+	}
+	if header[7] != 'A' || header[12:14] != "00" {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: want an AES key without optional blocks", ErrInvalidKeyBlock)
+	}
+	var keyType KeyType
+	for t, usage := range keyBlockUsage {
+		if header[5:7] == usage {
+			keyType = t
+		}
+	}
+	if keyType == "" {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: unsupported key usage %s", ErrInvalidKeyBlock, header[5:7])
+	}
+	attrs, err := KeyAttributes{Mode: KeyMode(header[8:9]), Exportability: Exportability(header[11:12])}.resolve(keyType)
+	if err != nil {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: %v", ErrInvalidKeyBlock, err)
+	}
+
+	body, err := hex.DecodeString(keyBlock[keyBlockHeaderLen:])
+	if err != nil || len(body) < aes.BlockSize+keyBlockMACLen || (len(body)-keyBlockMACLen)%aes.BlockSize != 0 {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: malformed payload", ErrInvalidKeyBlock)
+	}
+	encrypted, mac := body[:len(body)-keyBlockMACLen], body[len(body)-keyBlockMACLen:]
+
+	kbek, kbak := deriveKeyBlockKeys(kbpk)
+	defer securebytes.Zero(kbek)
+	defer securebytes.Zero(kbak)
+	block, err := aes.NewCipher(kbek)
+	if err != nil {
+		return nil, "", KeyAttributes{}, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	payload := make([]byte, len(encrypted))
+	defer securebytes.Zero(payload)
+	cipher.NewCBCDecrypter(block, mac).CryptBlocks(payload, encrypted)
+	if !securebytes.Equal(cmac(kbak, append([]byte(header), payload...)), mac) {
+		return nil, "", KeyAttributes{}, ErrKeyBlockMAC
+	}
+
+	bits := int(binary.BigEndian.Uint16(payload))
+	if bits != 256 || 2+bits/8 > len(payload) {
+		return nil, "", KeyAttributes{}, fmt.Errorf("%w: want a 256-bit key", ErrInvalidKeyBlock)
+	}
+	return securebytes.Clone(payload[2 : 2+bits/8]), keyType, attrs, nil
+}
+
+// deriveKeyBlockKeys derives the AES-256 encryption and authentication keys
+// of a key block from its protection key with the CMAC-based counter-mode
+// KDF of TR-31: each 16-byte output block is the CMAC of counter, key usage
+// indicator (0000 encryption, 0001 MAC), separator, algorithm indicator
+// (0004 AES-256) and length in bits (0100)
+func deriveKeyBlockKeys(kbpk []byte) (kbek, kbak []byte) {
+	derive := func(usage byte) []byte {
+		out := make([]byte, 0, 32)
+		for counter := byte(1); counter <= 2; counter++ {
+			out = append(out, cmac(kbpk, []byte{counter, 0x00, usage, 0x00, 0x00, 0x04, 0x01, 0x00})...)
+		}
+		return out
+	}
+	return derive(0x00), derive(0x01)
+}
+
+// keyTypeOrDEK is the type of a key created before key types existed, or
+// replicated from a primary that does not send them
+func keyTypeOrDEK(t KeyType) KeyType {
+	if t == "" {
+		return KeyTypeDEK
+	}
+	return t
+}
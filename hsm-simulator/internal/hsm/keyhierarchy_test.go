@@ -0,0 +1,222 @@
+package hsm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// sharedZMK sets up an acquirer and an issuer HSM holding the same ZMK, as
+// if its components had been exchanged and entered on both sides
+func sharedZMK(t *testing.T) (acquirer, issuer *HSM) {
+	t.Helper()
+	zmk := make([]byte, 32)
+	rand.Read(zmk)
+	acquirer, issuer = NewHSM(), NewHSM()
+	for _, h := range []*HSM{acquirer, issuer} {
+		if op, err := h.ImportKeyOfType("alice", "zmk", "AES-256-GCM", KeyTypeZMK, zmk); err != nil || op.State != StateExecuted {
+			t.Fatalf("ImportKeyOfType() = %+v, %v", op, err)
+		}
+	}
+	return acquirer, issuer
+}
+
+// receiveKeyBlock imports a key block on an HSM without dual control and
+// returns the key it went to
+func receiveKeyBlock(h *HSM, keyID, zmkID, block, kcv string, rotate bool) (*KeyMetadata, error) {
+	op, err := h.ImportKeyBlock("alice", keyID, zmkID, block, kcv, rotate)
+	if err != nil {
+		return nil, err
+	}
+	if op.State != StateExecuted {
+		return nil, errors.New(op.Error)
+	}
+	return h.GetKeyInfo(keyID)
+}
+
+func TestKeyExchangeUnderZMK(t *testing.T) {
+	acquirer, issuer := sharedZMK(t)
+	meta, err := acquirer.GenerateKeyOfType("zpk", "AES-256-GCM", KeyTypeZPK)
+	if err != nil || meta.Type != KeyTypeZPK || len(meta.KCV) != 6 {
+		t.Fatalf("GenerateKeyOfType() = %+v, %v", meta, err)
+	}
+
+	block, kcv, err := acquirer.ExportKey("zpk", "zmk")
+	if err != nil {
+		t.Fatalf("ExportKey() error = %v", err)
+	}
+	if kcv != meta.KCV || !strings.HasPrefix(block, "D0144P0AB00E0000") || len(block) != 144 {
+		t.Fatalf("ExportKey() = %q, %q; want a 144-character PIN key block with KCV %s", block, kcv, meta.KCV)
+	}
+
+	imported, err := receiveKeyBlock(issuer, "zpk", "zmk", block, kcv, false)
+	if err != nil || imported.Type != KeyTypeZPK || imported.KCV != kcv {
+		t.Fatalf("ImportKeyBlock() = %+v, %v; want a ZPK with KCV %s", imported, err, kcv)
+	}
+
+	// A key change arrives as a new version of the same key, and only when
+	// asked for
+	acquirer.RotateKey("zpk")
+	block, kcv, _ = acquirer.ExportKey("zpk", "zmk")
+	if _, err := receiveKeyBlock(issuer, "zpk", "zmk", block, kcv, false); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("ImportKeyBlock() of a key change without rotate error = %v, want %v", err, ErrKeyExists)
+	}
+	changed, err := receiveKeyBlock(issuer, "zpk", "zmk", block, kcv, true)
+	if err != nil || changed.CurrentVersion != 2 || changed.KCV == imported.KCV {
+		t.Errorf("ImportKeyBlock() of a key change = %+v, %v; want version 2", changed, err)
+	}
+	if _, err := receiveKeyBlock(issuer, "zpk-2", "zmk", block, kcv, true); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("ImportKeyBlock() rotating a missing key error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestExchangedDEKDecrypts(t *testing.T) {
+	acquirer, issuer := sharedZMK(t)
+	acquirer.GenerateKey("dek", "AES-256-GCM")
+	block, kcv, _ := acquirer.ExportKey("dek", "zmk")
+	if _, err := receiveKeyBlock(issuer, "dek", "zmk", block, kcv, false); err != nil {
+		t.Fatalf("ImportKeyBlock() error = %v", err)
+	}
+
+	ciphertext, nonce, version, _ := acquirer.Encrypt("dek", []byte("track data"), nil)
+	plaintext, err := issuer.Decrypt("dek", ciphertext, nonce, nil, version)
+	if err != nil || !bytes.Equal(plaintext, []byte("track data")) {
+		t.Errorf("Decrypt() with the exchanged key = %q, %v", plaintext, err)
+	}
+}
+
+func TestImportKeyBlockRejects(t *testing.T) {
+	acquirer, issuer := sharedZMK(t)
+	acquirer.GenerateKeyOfType("cvk", "AES-256-GCM", KeyTypeCVK)
+	block, kcv, _ := acquirer.ExportKey("cvk", "zmk")
+
+	other := NewHSM()
+	other.GenerateKeyOfType("zmk", "AES-256-GCM", KeyTypeZMK)
+	tampered := block[:20] + string("0123456789ABCDEF"[strings.IndexByte("0123456789ABCDEF", block[20])^1]) + block[21:]
+	issuer.GenerateKey("dek", "AES-256-GCM")
+
+	for _, tt := range []struct {
+		name    string
+		h       *HSM
+		keyID   string
+		zmkID   string
+		block   string
+		kcv     string
+		rotate  bool
+		wantErr error
+	}{
+		{"tampered", issuer, "cvk", "zmk", tampered, kcv, false, ErrKeyBlockMAC},
+		{"wrong ZMK", other, "cvk", "zmk", block, kcv, false, ErrKeyBlockMAC},
+		{"wrong KCV", issuer, "cvk", "zmk", block, "000000", false, ErrKCVMismatch},
+		{"truncated", issuer, "cvk", "zmk", block[:100], kcv, false, ErrInvalidKeyBlock},
+		{"not a ZMK", issuer, "cvk", "dek", block, kcv, false, ErrKeyUsage},
+		{"existing key", issuer, "dek", "zmk", block, kcv, false, ErrKeyExists},
+		{"type change", issuer, "dek", "zmk", block, kcv, true, ErrKeyUsage},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.h.ImportKeyBlock("alice", tt.keyID, tt.zmkID, tt.block, tt.kcv, tt.rotate); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ImportKeyBlock() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := issuer.GetKeyInfo("cvk"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("GetKeyInfo() error = %v; a rejected block must not create a key", err)
+	}
+}
+
+func TestKeyBlockAttributes(t *testing.T) {
+	acquirer, issuer := sharedZMK(t)
+	acquirer.GenerateKey("dek", "AES-256-GCM")
+
+	// The acquirer lets the issuer only encrypt, and not pass the key on
+	block, kcv, err := acquirer.ExportKeyWithAttributes("dek", "zmk", KeyAttributes{Mode: ModeEncrypt, Exportability: NonExportable})
+	if err != nil || !strings.HasPrefix(block, "D0144D0AE00N0000") {
+		t.Fatalf("ExportKeyWithAttributes() = %q, %v", block, err)
+	}
+	imported, err := receiveKeyBlock(issuer, "dek", "zmk", block, kcv, false)
+	if err != nil || imported.Attributes != (KeyAttributes{Mode: ModeEncrypt, Exportability: NonExportable}) {
+		t.Fatalf("ImportKeyBlock() = %+v, %v", imported, err)
+	}
+	ciphertext, nonce, version, err := issuer.Encrypt("dek", []byte("data"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() under an encrypt-only key error = %v", err)
+	}
+	if _, err := issuer.Decrypt("dek", ciphertext, nonce, nil, version); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("Decrypt() under an encrypt-only key error = %v", err)
+	}
+	if _, _, err := issuer.ExportKey("dek", "zmk"); !errors.Is(err, ErrKeyNotExportable) {
+		t.Errorf("ExportKey() of a non-exportable key error = %v", err)
+	}
+
+	// A key change must keep the attributes
+	acquirer.RotateKey("dek")
+	block, kcv, _ = acquirer.ExportKey("dek", "zmk")
+	if _, err := receiveKeyBlock(issuer, "dek", "zmk", block, kcv, true); !errors.Is(err, ErrInvalidKeyAttributes) {
+		t.Errorf("ImportKeyBlock() of a key change with other attributes error = %v", err)
+	}
+
+	// Exports never widen the key's own attributes
+	acquirer.GenerateKeyWithAttributes("cvk", "AES-256-GCM", KeyTypeCVK, KeyAttributes{Mode: ModeVerify})
+	for _, attrs := range []KeyAttributes{{Mode: ModeGenerateVerify}, {Mode: ModeGenerate}, {Exportability: ExportableSensitive}} {
+		if _, _, err := acquirer.ExportKeyWithAttributes("cvk", "zmk", attrs); !errors.Is(err, ErrInvalidKeyAttributes) {
+			t.Errorf("ExportKeyWithAttributes(%+v) of a verify-only CVK error = %v", attrs, err)
+		}
+	}
+
+	// Modes of use must suit the key usage, in generated keys and in blocks
+	if _, err := acquirer.GenerateKeyWithAttributes("bad", "AES-256-GCM", KeyTypeZPK, KeyAttributes{Mode: ModeVerify}); !errors.Is(err, ErrInvalidKeyAttributes) {
+		t.Errorf("GenerateKeyWithAttributes() of a verify-only ZPK error = %v", err)
+	}
+	if _, err := acquirer.GenerateKeyWithAttributes("bad", "AES-256-GCM", KeyTypeBDK, KeyAttributes{Exportability: ExportableTrusted}); !errors.Is(err, ErrInvalidKeyAttributes) {
+		t.Errorf("GenerateKeyWithAttributes() of an exportable BDK error = %v", err)
+	}
+	_, zmk, _, _ := acquirer.currentKey("zmk")
+	forged, _ := wrapKeyBlock(zmk, make([]byte, 32), KeyTypeDEK, KeyAttributes{Mode: ModeGenerate, Exportability: ExportableTrusted})
+	if _, err := receiveKeyBlock(issuer, "forged", "zmk", forged, "", false); !errors.Is(err, ErrInvalidKeyBlock) {
+		t.Errorf("ImportKeyBlock() of a generate-only DEK error = %v", err)
+	}
+}
+
+func TestKeyUsage(t *testing.T) {
+	h, _ := sharedZMK(t)
+	h.GenerateKeyOfType("zpk", "AES-256-GCM", KeyTypeZPK)
+
+	if _, _, _, err := h.Encrypt("zmk", []byte("data"), nil); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("Encrypt() under a ZMK: error = %v, want %v", err, ErrKeyUsage)
+	}
+	if _, _, _, _, err := h.GenerateDataKey("zpk", nil); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("GenerateDataKey() under a ZPK: error = %v, want %v", err, ErrKeyUsage)
+	}
+	if _, _, err := h.ExportKey("zmk", "zmk"); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("ExportKey() of a ZMK: error = %v, want %v", err, ErrKeyUsage)
+	}
+	if _, err := h.GenerateKeyOfType("lmk", "AES-256-GCM", "LMK"); !errors.Is(err, ErrInvalidKeyType) {
+		t.Errorf("GenerateKeyOfType(LMK) error = %v, want %v", err, ErrInvalidKeyType)
+	}
+}
+
+func TestKeyCheckValue(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	kcv := KeyCheckValue(key)
+	if len(kcv) != 6 || kcv != strings.ToUpper(kcv) || kcv != KeyCheckValue(bytes.Clone(key)) {
+		t.Errorf("KeyCheckValue() = %q; want 6 stable uppercase hex digits", kcv)
+	}
+	if KeyCheckValue(bytes.Repeat([]byte{0x22}, 32)) == kcv {
+		t.Error("different keys share a KCV")
+	}
+}
+
+func TestReplicationCarriesKeyType(t *testing.T) {
+	primary := NewHSM(WithTransportKey(testTransportKey))
+	standby := NewHSM(WithTransportKey(testTransportKey))
+	primary.GenerateKeyOfType("zmk", "AES-256-GCM", KeyTypeZMK)
+	changes, _, _ := primary.Changes(0)
+	if err := standby.ApplyChanges(changes); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if info, err := standby.GetKeyInfo("zmk"); err != nil || info.Type != KeyTypeZMK {
+		t.Errorf("replicated key = %+v, %v; want a ZMK", info, err)
+	}
+}
@@ -0,0 +1,192 @@
+package hsm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+var (
+	// ErrNonceReuse reports a nonce already used under the same key
+	// version. GCM loses both confidentiality and integrity when that
+	// happens.
+	ErrNonceReuse   = errors.New("nonce reuse detected")
+	ErrInvalidNonce = errors.New("invalid nonce")
+)
+
+// NonceReusePolicy decides what happens to a nonce the HSM has probably
+// used before under the same key version
+type NonceReusePolicy string
+
+const (
+	// NonceReuseReject discards the nonce and draws another; the operation
+	// fails with ErrNonceReuse if fresh nonces keep colliding, as they would
+	// from a stuck random source, or if the caller supplied the nonce
+	NonceReuseReject NonceReusePolicy = "reject"
+	// NonceReuseFlag keeps the nonce but counts and audits the reuse, to
+	// observe a faulty source without failing operations
+	NonceReuseFlag NonceReusePolicy = "flag"
+)
+
+// ParseNonceReusePolicy reads a policy name; "" is NonceReuseReject
+func ParseNonceReusePolicy(s string) (NonceReusePolicy, error) {
+	switch p := NonceReusePolicy(s); p {
+	case "":
+		return NonceReuseReject, nil
+	case NonceReuseReject, NonceReuseFlag:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown nonce reuse policy %q: want reject or flag", s)
+}
+
+// NonceCheckConfig configures nonce reuse detection
+type NonceCheckConfig struct {
+	Policy NonceReusePolicy
+	// Metrics receives the check and reuse counters. Nil keeps them
+	// private to the HSM.
+	Metrics *metrics.Registry
+}
+
+// WithNonceCheck configures nonce reuse detection, which is on with the
+// reject policy by default
+func WithNonceCheck(cfg NonceCheckConfig) Option {
+	return func(h *HSM) { h.nonces = newNonceChecker(cfg) }
+}
+
+// NonceStats counts nonces checked for reuse and the reuses found
+type NonceStats struct {
+	Checked  uint64 `json:"checked"`
+	Rejected uint64 `json:"rejected"`
+	Flagged  uint64 `json:"flagged"`
+}
+
+// NonceStats returns the nonce reuse counters
+func (h *HSM) NonceStats() NonceStats {
+	return NonceStats{
+		Checked:  uint64(h.nonces.checked.Value()),
+		Rejected: uint64(h.nonces.rejected.Value()),
+		Flagged:  uint64(h.nonces.flagged.Value()),
+	}
+}
+
+// Sizing of the per-version nonce filters. A generation holds up to
+// nonceGeneration nonces in 16 KiB at under 0.3% false positives; the
+// filter keeps the current and the previous generation, so it remembers the
+// most recent 10,000 to 20,000 nonces of a version.
+const (
+	nonceFilterBits   = 1 << 17
+	nonceFilterHashes = 7
+	nonceGeneration   = 10000
+	// nonceRedraws bounds the fresh nonces drawn after a rejected one
+	nonceRedraws = 3
+)
+
+type nonceChecker struct {
+	policy   NonceReusePolicy
+	source   io.Reader
+	checked  *metrics.Counter
+	rejected *metrics.Counter
+	flagged  *metrics.Counter
+}
+
+func newNonceChecker(cfg NonceCheckConfig) *nonceChecker {
+	if cfg.Policy == "" {
+		cfg.Policy = NonceReuseReject
+	}
+	registry := cfg.Metrics
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	reuse := registry.Counter("hsm_nonce_reuse_total", "Nonces found already used under their key version, by action taken.", "action")
+	return &nonceChecker{
+		policy:   cfg.Policy,
+		source:   rand.Reader,
+		checked:  registry.Counter("hsm_nonce_checks_total", "Nonces checked for reuse under their key version.").With(),
+		rejected: reuse.With("rejected"),
+		flagged:  reuse.With("flagged"),
+	}
+}
+
+// nonce draws a GCM nonce for a key version, checking it against the
+// nonces the version has used recently
+func (h *HSM) nonce(operation, keyID string, keyVersion int, kv *KeyVersion, size int) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		nonce := make([]byte, size)
+		if _, err := io.ReadFull(h.nonces.source, nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		if err := h.checkNonce(operation, keyID, keyVersion, kv, nonce); err != nil {
+			if attempt < nonceRedraws {
+				continue
+			}
+			return nil, err
+		}
+		return nonce, nil
+	}
+}
+
+// checkNonce records a nonce as used under a key version. A reuse is
+// counted and audited on its own, so the key's usage counters in the state
+// dump show it, and refused under the reject policy.
+func (h *HSM) checkNonce(operation, keyID string, keyVersion int, kv *KeyVersion, nonce []byte) error {
+	c := h.nonces
+	c.checked.Inc()
+	if !kv.nonces.seen(nonce) {
+		return nil
+	}
+	if c.policy == NonceReuseFlag {
+		c.flagged.Inc()
+		h.logAudit("NonceReuse", keyID, keyVersion, false, operation+": flagged")
+		return nil
+	}
+	c.rejected.Inc()
+	h.logAudit("NonceReuse", keyID, keyVersion, false, operation+": rejected")
+	return ErrNonceReuse
+}
+
+// nonceFilter is a Bloom filter of the nonces used under one key version.
+// It never misses a nonce it still remembers, but may rarely report a fresh
+// one as used, which under the reject policy only costs a redraw.
+type nonceFilter struct {
+	current  []uint64
+	previous []uint64
+	added    int
+	mu       sync.Mutex
+}
+
+// seen records a nonce and reports whether it was probably recorded before
+func (f *nonceFilter) seen(nonce []byte) bool {
+	sum := sha256.Sum256(nonce)
+	h1 := binary.LittleEndian.Uint64(sum[0:8])
+	h2 := binary.LittleEndian.Uint64(sum[8:16]) | 1
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.current == nil {
+		f.current = make([]uint64, nonceFilterBits/64)
+	}
+	inCurrent, inPrevious := true, f.previous != nil
+	for i := uint64(0); i < nonceFilterHashes; i++ {
+		bit := (h1 + i*h2) % nonceFilterBits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if f.current[word]&mask == 0 {
+			inCurrent = false
+			f.current[word] |= mask
+		}
+		if inPrevious && f.previous[word]&mask == 0 {
+			inPrevious = false
+		}
+	}
+
+	// Roll over before the filter fills up and false positives climb
+	if f.added++; f.added >= nonceGeneration {
+		f.previous, f.current, f.added = f.current, nil, 0
+	}
+	return inCurrent || inPrevious
+}
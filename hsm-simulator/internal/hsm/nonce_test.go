@@ -0,0 +1,140 @@
+package hsm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// stuckReader returns the same bytes forever, like a broken random source
+type stuckReader struct{}
+
+func (stuckReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0x42
+	}
+	return len(p), nil
+}
+
+func TestNonceReuseRejected(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("k", "AES-256-GCM")
+	h.nonces.source = stuckReader{}
+
+	if _, _, _, err := h.Encrypt("k", []byte("first"), nil); err != nil {
+		t.Fatalf("first Encrypt() error = %v", err)
+	}
+	if _, _, _, err := h.Encrypt("k", []byte("second"), nil); !errors.Is(err, ErrNonceReuse) {
+		t.Fatalf("second Encrypt() error = %v, want ErrNonceReuse", err)
+	}
+	if _, _, _, _, err := h.GenerateDataKey("k", nil); !errors.Is(err, ErrNonceReuse) {
+		t.Errorf("GenerateDataKey() error = %v, want ErrNonceReuse", err)
+	}
+	stats := h.NonceStats()
+	if want := uint64(2 * (nonceRedraws + 1)); stats.Rejected != want || stats.Flagged != 0 {
+		t.Errorf("NonceStats() = %+v, want %d rejected", stats, want)
+	}
+	if c := h.DumpState().Keys[0].Usage["NonceReuse"]; c.Failure != int(stats.Rejected) {
+		t.Errorf("NonceReuse usage = %+v, want one failure per rejected nonce", c)
+	}
+
+	// A new version has not used the nonce yet
+	h.RotateKey("k")
+	if _, _, v, err := h.Encrypt("k", []byte("third"), nil); err != nil || v != 2 {
+		t.Errorf("Encrypt() after rotation = version %d, %v", v, err)
+	}
+}
+
+func TestNonceReuseFlagged(t *testing.T) {
+	h := NewHSM(WithNonceCheck(NonceCheckConfig{Policy: NonceReuseFlag}))
+	h.GenerateKey("k", "AES-256-GCM")
+	h.nonces.source = stuckReader{}
+
+	_, n1, _, _ := h.Encrypt("k", []byte("first"), nil)
+	ciphertext, n2, _, err := h.Encrypt("k", []byte("second"), nil)
+	if err != nil || !bytes.Equal(n1, n2) {
+		t.Fatalf("Encrypt() = %x, %v; want the reused nonce kept", n2, err)
+	}
+	if plaintext, err := h.Decrypt("k", ciphertext, n2, nil, 1); err != nil || string(plaintext) != "second" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+	if stats := h.NonceStats(); stats.Checked != 2 || stats.Flagged != 1 || stats.Rejected != 0 {
+		t.Errorf("NonceStats() = %+v", stats)
+	}
+	if state := h.DumpState(); state.Policies.NonceReuse != NonceReuseFlag || state.Nonces.Flagged != 1 {
+		t.Errorf("state = %+v, %+v", state.Policies, state.Nonces)
+	}
+}
+
+func TestEncryptWithCallerNonce(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("k", "AES-256-GCM")
+	nonce := bytes.Repeat([]byte{7}, 12)
+
+	ciphertext, got, _, _, err := h.EncryptWithOptions("k", []byte("pan"), nil, EncryptOptions{Nonce: nonce})
+	if err != nil || !bytes.Equal(got, nonce) {
+		t.Fatalf("EncryptWithOptions() nonce = %x, %v; want the caller's", got, err)
+	}
+	if plaintext, err := h.Decrypt("k", ciphertext, nonce, nil, 1); err != nil || string(plaintext) != "pan" {
+		t.Errorf("Decrypt() = %q, %v", plaintext, err)
+	}
+	if _, _, _, _, err := h.EncryptWithOptions("k", []byte("pan"), nil, EncryptOptions{Nonce: nonce}); !errors.Is(err, ErrNonceReuse) {
+		t.Errorf("reused caller nonce error = %v, want ErrNonceReuse", err)
+	}
+	if _, _, _, _, err := h.EncryptWithOptions("k", []byte("pan"), nil, EncryptOptions{Nonce: nonce[:8]}); !errors.Is(err, ErrInvalidNonce) {
+		t.Errorf("short nonce error = %v, want ErrInvalidNonce", err)
+	}
+	if stats := h.NonceStats(); stats.Checked != 2 || stats.Rejected != 1 {
+		t.Errorf("NonceStats() = %+v", stats)
+	}
+}
+
+func TestEncryptDetachedTag(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("k", "AES-256-GCM")
+	aad := []byte("merchant-1")
+
+	ciphertext, nonce, tag, v, err := h.EncryptWithOptions("k", []byte("4111111111111111"), aad, EncryptOptions{DetachedTag: true})
+	if err != nil {
+		t.Fatalf("EncryptWithOptions() error = %v", err)
+	}
+	if len(ciphertext) != 16 || len(tag) != 16 {
+		t.Fatalf("ciphertext %d bytes, tag %d bytes; want 16 and 16", len(ciphertext), len(tag))
+	}
+	if plaintext, err := h.DecryptDetached("k", ciphertext, tag, nonce, aad, v); err != nil || string(plaintext) != "4111111111111111" {
+		t.Errorf("DecryptDetached() = %q, %v", plaintext, err)
+	}
+	tag[0] ^= 1
+	if _, err := h.DecryptDetached("k", ciphertext, tag, nonce, aad, v); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("DecryptDetached() with a bad tag error = %v", err)
+	}
+}
+
+func TestNonceFilterRemembersPreviousGeneration(t *testing.T) {
+	var f nonceFilter
+	nonce := func(i int) []byte { return []byte{byte(i), byte(i >> 8), byte(i >> 16), 0xA5} }
+	// False positives only become likely as a generation fills up
+	for i := 0; i < nonceGeneration; i++ {
+		if f.seen(nonce(i)) && i < 1000 {
+			t.Fatalf("fresh nonce %d reported as seen", i)
+		}
+	}
+	if !f.seen(nonce(0)) {
+		t.Error("nonce from the previous generation forgotten")
+	}
+	for i := nonceGeneration; i < 2*nonceGeneration+1; i++ {
+		f.seen(nonce(i))
+	}
+	if f.previous == nil || f.current == nil {
+		t.Error("filter did not roll over")
+	}
+}
+
+func TestParseNonceReusePolicy(t *testing.T) {
+	if p, err := ParseNonceReusePolicy(""); err != nil || p != NonceReuseReject {
+		t.Errorf(`ParseNonceReusePolicy("") = %q, %v`, p, err)
+	}
+	if _, err := ParseNonceReusePolicy("ignore"); err == nil {
+		t.Error("ParseNonceReusePolicy(ignore) accepted an unknown policy")
+	}
+}
@@ -0,0 +1,113 @@
+package hsm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/paymentgateway/go-common/dukpt"
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// The HSM is the root of the P2PE decryption zone. Terminals encrypt card
+// data under DUKPT keys that descend from a BDK the HSM holds; everything
+// between the terminal and the decryption service, the gateway included,
+// only ever handles the ciphertext and its KSN. The TDES BDK of a BDK key
+// is the first 16 bytes of its key material.
+
+// DeriveIPEK derives the initial key to inject into the terminal with a
+// KSN, as a key injection facility does, with its check value. Terminals
+// keep working across BDK rotations only if they are injected again.
+func (h *HSM) DeriveIPEK(bdkID string, ksn dukpt.KSN) (ipek []byte, kcv string, err error) {
+	h.simulate("DeriveIPEK")
+	bdk, version, err := h.baseDerivationKey("DeriveIPEK", bdkID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer securebytes.Zero(bdk)
+
+	ipek, err = dukpt.DeriveIPEK(bdk, ksn)
+	if err != nil {
+		h.logAudit("DeriveIPEK", bdkID, version, false, err.Error())
+		return nil, "", err
+	}
+	kcv, _ = dukpt.CheckValue(ipek)
+	h.logAudit("DeriveIPEK", bdkID, version, true, "")
+	return ipek, kcv, nil
+}
+
+// DecryptP2PE decrypts track data a terminal encrypted under the DUKPT key
+// of a KSN, and parses it like EncryptTrackData: the caller gets the fields
+// that may be stored, never the discretionary data.
+func (h *HSM) DecryptP2PE(bdkID string, ksn dukpt.KSN, ciphertext []byte) (TrackFields, ParsedTrack, error) {
+	h.simulate("DecryptP2PE")
+	bdk, version, err := h.baseDerivationKey("DecryptP2PE", bdkID)
+	if err != nil {
+		return TrackFields{}, ParsedTrack{}, err
+	}
+	defer securebytes.Zero(bdk)
+
+	track, err := decryptDUKPT(bdk, ksn, ciphertext)
+	if err != nil {
+		h.logAudit("DecryptP2PE", bdkID, version, false, err.Error())
+		return TrackFields{}, ParsedTrack{}, err
+	}
+	defer securebytes.Zero(track)
+	fields, parsed, err := parseTrack(track)
+	if err != nil {
+		// A wrong BDK or KSN decrypts to noise, which fails to parse
+		h.logAudit("DecryptP2PE", bdkID, version, false, err.Error())
+		return TrackFields{}, ParsedTrack{}, err
+	}
+
+	h.auditMu.Lock()
+	h.auditLog = append(h.auditLog, AuditEntry{
+		Timestamp: time.Now(),
+		Operation: "DecryptP2PE",
+		KeyID:     bdkID,
+		Version:   version,
+		Success:   true,
+		Detail:    fmt.Sprintf("KSN %s, track %d: %d bytes of discretionary data discarded", ksn, parsed.Track, parsed.DiscretionaryBytes),
+	})
+	h.auditMu.Unlock()
+	return fields, parsed, nil
+}
+
+// decryptDUKPT derives the data key of a KSN from the BDK and decrypts
+func decryptDUKPT(bdk []byte, ksn dukpt.KSN, ciphertext []byte) ([]byte, error) {
+	ipek, err := dukpt.DeriveIPEK(bdk, ksn)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(ipek)
+	key, err := dukpt.DeriveKey(ipek, ksn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrackData, err)
+	}
+	defer securebytes.Zero(key)
+	dataKey, err := dukpt.DataKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(dataKey)
+	plaintext, err := dukpt.DecryptData(dataKey, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrackData, err)
+	}
+	return plaintext, nil
+}
+
+// baseDerivationKey returns a copy of the TDES BDK of the current version
+// of a BDK key, and the version
+func (h *HSM) baseDerivationKey(operation, bdkID string) ([]byte, int, error) {
+	keyType, keyData, version, err := h.currentKey(bdkID)
+	if err != nil {
+		h.logAudit(operation, bdkID, 0, false, err.Error())
+		return nil, 0, err
+	}
+	defer securebytes.Zero(keyData)
+	if keyType != KeyTypeBDK {
+		h.logAudit(operation, bdkID, version, false, "key usage")
+		return nil, 0, fmt.Errorf("%w: %s is a %s, not a BDK", ErrKeyUsage, bdkID, keyTypeOrDEK(keyType))
+	}
+	return securebytes.Clone(keyData[:dukpt.KeySize]), version, nil
+}
@@ -0,0 +1,58 @@
+package hsm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/paymentgateway/go-common/dukpt"
+)
+
+func TestP2PEDecryption(t *testing.T) {
+	h := NewHSM()
+	if _, err := h.GenerateKeyOfType("bdk", "AES-256-GCM", KeyTypeBDK); err != nil {
+		t.Fatalf("GenerateKeyOfType() error = %v", err)
+	}
+	h.GenerateKey("vault", "AES-256-GCM")
+
+	ksn, _ := dukpt.ParseKSN("FFFF9876543210E00000")
+	ipek, kcv, err := h.DeriveIPEK("bdk", ksn)
+	if err != nil || len(kcv) != 6 {
+		t.Fatalf("DeriveIPEK() kcv = %q, %v", kcv, err)
+	}
+	terminal, _ := dukpt.NewTerminal(ipek, ksn)
+	txKSN, ciphertext, _ := terminal.Encrypt([]byte(";4111111111111111=30121011234567?"))
+
+	fields, parsed, err := h.DecryptP2PE("bdk", txKSN, ciphertext)
+	if err != nil {
+		t.Fatalf("DecryptP2PE() error = %v", err)
+	}
+	want := TrackFields{PAN: "4111111111111111", ExpiryYear: 2030, ExpiryMonth: 12, ServiceCode: "101"}
+	if fields != want || parsed.DiscretionaryBytes != 7 {
+		t.Errorf("DecryptP2PE() = %+v, %+v", fields, parsed)
+	}
+
+	// Under another transaction's KSN the data decrypts to noise
+	if _, _, err := h.DecryptP2PE("bdk", txKSN.WithCounter(2), ciphertext); !errors.Is(err, ErrInvalidTrackData) {
+		t.Errorf("DecryptP2PE() with the wrong KSN error = %v", err)
+	}
+	// A BDK only derives, and only a BDK decrypts P2PE data
+	if _, _, _, err := h.Encrypt("bdk", []byte("pan"), nil); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("Encrypt() under a BDK error = %v", err)
+	}
+	if _, _, err := h.DecryptP2PE("vault", txKSN, ciphertext); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("DecryptP2PE() under a DEK error = %v", err)
+	}
+	if _, _, err := h.ExportKey("bdk", "zmk"); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("ExportKey() of a BDK error = %v", err)
+	}
+}
+
+func TestP2PEDecryptionNeedsItsRole(t *testing.T) {
+	h := NewHSM()
+	if err := h.Authorize("gateway", []string{CryptoUserRole}, "DecryptP2PE"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Authorize() for a crypto user error = %v", err)
+	}
+	if err := h.Authorize("tokenization", []string{P2PEDecryptionRole}, "DecryptP2PE"); err != nil {
+		t.Errorf("Authorize() for the decryption zone error = %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package hsm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInvalidPermissions = errors.New("invalid permissions")
+)
+
+// Operator roles the built-in permission matrices grant commands to
+const (
+	AdminRole       = "hsm.admin"
+	ReplicationRole = "hsm.replication"
+	KeyManagerRole  = "hsm.key-manager"
+	CryptoUserRole  = "hsm.crypto-user"
+	// P2PEDecryptionRole belongs to the services of the P2PE decryption
+	// zone, the only callers that may see what terminals encrypted
+	P2PEDecryptionRole = "hsm.p2pe-decryption"
+)
+
+// Subjects of a permission matrix besides role names: AnyPrincipal grants a
+// command to every authenticated caller and PrincipalPrefix, followed by a
+// principal ID, to one caller alone
+const (
+	AnyPrincipal    = "*"
+	PrincipalPrefix = "principal:"
+)
+
+// AllCommands grants every command when listed in a matrix entry
+const AllCommands = "*"
+
+// Commands are the HSM commands a permission matrix controls, named after
+// their RPCs
+var Commands = []string{
+	"GenerateKey", "Encrypt", "GenerateDataKey", "Decrypt", "EncryptEnvelope", "DecryptEnvelope",
+	"EncryptTrackData", "DeriveIPEK", "DecryptP2PE", "EncryptPIN", "GeneratePINReference", "VerifyPIN",
+	"ChangePIN", "TranslatePIN", "GenerateCVV", "VerifyCVV", "RotateKey", "GetKeyInfo", "DestroyKeyVersion", "ImportKey", "BackupKeys", "RestoreKeys", "ListOperations",
+	"ApproveOperation", "RejectOperation", "DumpState", "Replicate", "PromoteStandby", "GenerateARQC",
+	"GenerateEMVResponse", "ExportKey", "ImportKeyBlock", "BeginZMKExchange", "EnterZMKComponent",
+	"ImportWrappedZMK", "CancelZMKExchange", "ListZMKExchanges", "WatchKeys", "GetServiceInfo", "GetPermissions",
+}
+
+// PermissionMatrix maps subjects (role names, AnyPrincipal or
+// PrincipalPrefix+ID) to the commands they may call. A caller may call the
+// union of the commands granted to its roles, its principal and everyone.
+type PermissionMatrix map[string][]string
+
+// DefaultPermissions matches the HSM's role checks before the matrix was
+// configurable: any caller may use, create and rotate keys, admins manage
+// them destructively and standbys replicate. Key exchange, and commands
+// added since, are not for any caller: they go to the roles that have them
+// in SeparatedPermissions, and to admins.
+func DefaultPermissions() PermissionMatrix {
+	return PermissionMatrix{
+		AnyPrincipal: {
+			"GenerateKey", "Encrypt", "GenerateDataKey", "Decrypt", "RotateKey", "GetKeyInfo",
+			"GenerateARQC", "GenerateEMVResponse", "WatchKeys", "GetServiceInfo",
+		},
+		AdminRole: {
+			"DestroyKeyVersion", "ImportKey", "BackupKeys", "RestoreKeys", "ListOperations", "ApproveOperation", "RejectOperation",
+			"DumpState", "PromoteStandby", "GetPermissions", "ExportKey", "ImportKeyBlock", "DeriveIPEK",
+			"BeginZMKExchange", "EnterZMKComponent", "ImportWrappedZMK", "CancelZMKExchange", "ListZMKExchanges",
+			"EncryptEnvelope", "DecryptEnvelope", "EncryptTrackData", "EncryptPIN", "GeneratePINReference",
+			"VerifyPIN", "ChangePIN", "TranslatePIN", "GenerateCVV", "VerifyCVV",
+		},
+		KeyManagerRole: {
+			"ExportKey", "ImportKeyBlock", "DeriveIPEK", "EnterZMKComponent", "ImportWrappedZMK", "ListZMKExchanges",
+		},
+		CryptoUserRole: {
+			"EncryptEnvelope", "DecryptEnvelope", "EncryptTrackData", "GeneratePINReference", "VerifyPIN",
+			"ChangePIN", "TranslatePIN", "GenerateCVV", "VerifyCVV",
+		},
+		ReplicationRole:    {"Replicate"},
+		P2PEDecryptionRole: {"DecryptP2PE"},
+	}
+}
+
+// SeparatedPermissions separates duties the way a production HSM is set up:
+// crypto users only use keys, key managers create and rotate them and
+// admins may do anything
+func SeparatedPermissions() PermissionMatrix {
+	return PermissionMatrix{
+		AdminRole: {AllCommands},
+		KeyManagerRole: {
+			"GenerateKey", "RotateKey", "GetKeyInfo", "ExportKey", "ImportKeyBlock", "DeriveIPEK", "EnterZMKComponent",
+			"ImportWrappedZMK", "ListZMKExchanges", "WatchKeys", "GetServiceInfo",
+		},
+		CryptoUserRole: {
+			"Encrypt", "GenerateDataKey", "Decrypt", "EncryptEnvelope", "DecryptEnvelope", "EncryptTrackData",
+			"GeneratePINReference", "VerifyPIN", "ChangePIN", "TranslatePIN", "GenerateCVV", "VerifyCVV", "GetKeyInfo", "GenerateARQC",
+			"GenerateEMVResponse", "WatchKeys", "GetServiceInfo",
+		},
+		ReplicationRole:    {"Replicate", "GetServiceInfo"},
+		P2PEDecryptionRole: {"DecryptP2PE", "GetServiceInfo"},
+	}
+}
+
+// ParsePermissions reads a permission matrix given as the name of a
+// built-in one ("default" or "separated") or as a JSON object of subjects
+// to command lists
+func ParsePermissions(spec string) (PermissionMatrix, error) {
+	switch spec := strings.TrimSpace(spec); spec {
+	case "default":
+		return DefaultPermissions(), nil
+	case "separated":
+		return SeparatedPermissions(), nil
+	}
+	var m PermissionMatrix
+	if err := json.Unmarshal([]byte(spec), &m); err != nil {
+		return nil, fmt.Errorf("%w: want default, separated or a JSON object: %v", ErrInvalidPermissions, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate rejects empty subjects and unknown commands, so a typo does not
+// silently withhold a command
+func (m PermissionMatrix) Validate() error {
+	known := make(map[string]bool, len(Commands))
+	for _, c := range Commands {
+		known[c] = true
+	}
+	for subject, commands := range m {
+		if subject == "" || subject == PrincipalPrefix {
+			return fmt.Errorf("%w: empty subject", ErrInvalidPermissions)
+		}
+		for _, c := range commands {
+			if c != AllCommands && !known[c] {
+				return fmt.Errorf("%w: unknown command %q for %s", ErrInvalidPermissions, c, subject)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows reports whether a caller with the principal ID and roles may call
+// command
+func (m PermissionMatrix) Allows(principal string, roles []string, command string) bool {
+	for _, subject := range subjects(principal, roles) {
+		for _, c := range m[subject] {
+			if c == AllCommands || c == command {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Effective lists the commands a caller with the principal ID and roles may
+// call, in the order of Commands
+func (m PermissionMatrix) Effective(principal string, roles []string) []string {
+	var allowed []string
+	for _, c := range Commands {
+		if m.Allows(principal, roles, c) {
+			allowed = append(allowed, c)
+		}
+	}
+	return allowed
+}
+
+func subjects(principal string, roles []string) []string {
+	out := append([]string{AnyPrincipal}, roles...)
+	if principal != "" {
+		out = append(out, PrincipalPrefix+principal)
+	}
+	return out
+}
+
+func (m PermissionMatrix) clone() PermissionMatrix {
+	out := make(PermissionMatrix, len(m))
+	for subject, commands := range m {
+		out[subject] = append([]string(nil), commands...)
+	}
+	return out
+}
+
+// WithPermissions replaces the default permission matrix. m must be valid.
+func WithPermissions(m PermissionMatrix) Option {
+	return func(h *HSM) {
+		m := m.clone()
+		h.permissions.Store(&m)
+	}
+}
+
+// Permissions returns a copy of the permission matrix in force
+func (h *HSM) Permissions() PermissionMatrix {
+	return h.permissions.Load().clone()
+}
+
+// SetPermissions swaps in a new permission matrix, e.g. on a config reload,
+// and describes the change by subject. m must be valid.
+func (h *HSM) SetPermissions(m PermissionMatrix) []string {
+	m = m.clone()
+	old := *h.permissions.Swap(&m)
+
+	describe := func(commands []string) string {
+		sorted := append([]string(nil), commands...)
+		sort.Strings(sorted)
+		return strings.Join(sorted, "|")
+	}
+	var changes []string
+	for subject, commands := range m {
+		prev, ok := old[subject]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("permissions added for %s", subject))
+		case describe(prev) != describe(commands):
+			changes = append(changes, fmt.Sprintf("permissions changed for %s", subject))
+		}
+	}
+	for subject := range old {
+		if _, ok := m[subject]; !ok {
+			changes = append(changes, fmt.Sprintf("permissions removed for %s", subject))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// Authorize checks a caller against the permission matrix before it runs
+// command. A denied call is audited with the caller and its roles, and
+// never reaches the command, so the command's own audit entry only records
+// permitted calls.
+func (h *HSM) Authorize(principal string, roles []string, command string) error {
+	if h.permissions.Load().Allows(principal, roles, command) {
+		return nil
+	}
+	h.auditMu.Lock()
+	h.auditLog = append(h.auditLog, AuditEntry{
+		Timestamp: time.Now(),
+		Operation: command,
+		Error:     ErrPermissionDenied.Error(),
+		Requester: principal,
+		Detail:    "roles: " + strings.Join(roles, "|"),
+	})
+	h.auditMu.Unlock()
+	return fmt.Errorf("%w: %s may not call %s", ErrPermissionDenied, principal, command)
+}
@@ -0,0 +1,109 @@
+package hsm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSeparatedPermissionsKeepCryptoUsersOffKeyManagement(t *testing.T) {
+	m := SeparatedPermissions()
+	for _, command := range []string{"GenerateKey", "RotateKey", "DestroyKeyVersion", "GetPermissions"} {
+		if m.Allows("tokenizer", []string{CryptoUserRole}, command) {
+			t.Errorf("crypto user may call %s", command)
+		}
+	}
+	if !m.Allows("tokenizer", []string{CryptoUserRole}, "Encrypt") {
+		t.Error("crypto user may not call Encrypt")
+	}
+	if !m.Allows("ops", []string{CryptoUserRole, KeyManagerRole}, "RotateKey") {
+		t.Error("roles do not add up")
+	}
+	if !m.Allows("root", []string{AdminRole}, "PromoteStandby") {
+		t.Error("admin wildcard does not grant every command")
+	}
+}
+
+func TestDefaultPermissionsReserveNewCommandsForRoles(t *testing.T) {
+	m := DefaultPermissions()
+	for _, command := range []string{"ExportKey", "ImportKeyBlock", "EncryptEnvelope", "EncryptPIN", "VerifyPIN", "GenerateCVV", "BackupKeys", "RestoreKeys"} {
+		if m.Allows("operator", nil, command) {
+			t.Errorf("a principal without roles may call %s", command)
+		}
+	}
+	for _, command := range []string{"Encrypt", "GenerateKey", "RotateKey"} {
+		if !m.Allows("operator", nil, command) {
+			t.Errorf("a principal without roles may not call %s", command)
+		}
+	}
+	if !m.Allows("ops", []string{KeyManagerRole}, "ExportKey") || !m.Allows("root", []string{AdminRole}, "ImportKeyBlock") {
+		t.Error("key managers and admins may not exchange keys")
+	}
+	if !m.Allows("issuer", []string{CryptoUserRole}, "VerifyPIN") || m.Allows("issuer", []string{CryptoUserRole}, "ExportKey") {
+		t.Error("crypto users do not get the commands of the separated matrix")
+	}
+}
+
+func TestPermissionsGrantToPrincipals(t *testing.T) {
+	m, err := ParsePermissions(`{"*": ["GetServiceInfo"], "principal:alice": ["DumpState"]}`)
+	if err != nil {
+		t.Fatalf("ParsePermissions() error = %v", err)
+	}
+	if got := m.Effective("alice", nil); !reflect.DeepEqual(got, []string{"DumpState", "GetServiceInfo"}) {
+		t.Errorf("Effective(alice) = %v", got)
+	}
+	if got := m.Effective("bob", nil); !reflect.DeepEqual(got, []string{"GetServiceInfo"}) {
+		t.Errorf("Effective(bob) = %v", got)
+	}
+}
+
+func TestParsePermissionsRejectsUnknownCommands(t *testing.T) {
+	for _, spec := range []string{`{"hsm.admin": ["Encrypt", "Shred"]}`, `{"": ["Encrypt"]}`, `not json`} {
+		if _, err := ParsePermissions(spec); !errors.Is(err, ErrInvalidPermissions) {
+			t.Errorf("ParsePermissions(%s) error = %v, want ErrInvalidPermissions", spec, err)
+		}
+	}
+	if err := DefaultPermissions().Validate(); err != nil {
+		t.Errorf("default matrix invalid: %v", err)
+	}
+}
+
+func TestAuthorizeAuditsDenials(t *testing.T) {
+	h := NewHSM(WithPermissions(SeparatedPermissions()))
+	if err := h.Authorize("tokenizer", []string{CryptoUserRole}, "Decrypt"); err != nil {
+		t.Fatalf("Authorize(Decrypt) error = %v", err)
+	}
+	if len(h.GetAuditLog()) != 0 {
+		t.Error("a permitted call was audited before it ran")
+	}
+
+	err := h.Authorize("tokenizer", []string{CryptoUserRole}, "GenerateKey")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("Authorize(GenerateKey) error = %v, want ErrPermissionDenied", err)
+	}
+	log := h.GetAuditLog()
+	if len(log) != 1 || log[0].Operation != "GenerateKey" || log[0].Success || log[0].Requester != "tokenizer" {
+		t.Errorf("audit log = %+v", log)
+	}
+}
+
+func TestSetPermissionsDescribesChanges(t *testing.T) {
+	h := NewHSM()
+	m := DefaultPermissions()
+	m[AdminRole] = append(m[AdminRole], "Replicate")
+	delete(m, ReplicationRole)
+	m[PrincipalPrefix+"ops"] = []string{"Encrypt"}
+
+	want := []string{
+		"permissions added for principal:ops",
+		"permissions changed for hsm.admin",
+		"permissions removed for hsm.replication",
+	}
+	if got := h.SetPermissions(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("SetPermissions() = %v, want %v", got, want)
+	}
+	m[PrincipalPrefix+"ops"][0] = "Decrypt"
+	if got := h.Permissions()[PrincipalPrefix+"ops"]; got[0] != "Encrypt" {
+		t.Errorf("matrix shares the caller's slices: %v", got)
+	}
+}
@@ -0,0 +1,479 @@
+package hsm
+
+import (
+	"crypto/aes"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrInvalidPIN          = errors.New("PINs must be 4-12 digits")
+	ErrInvalidPINBlock     = errors.New("invalid PIN block")
+	ErrInvalidPINMethod    = errors.New("invalid PIN verification method")
+	ErrInvalidPINReference = errors.New("invalid PIN offset or PVV")
+	ErrPINMismatch         = errors.New("PIN verification failed")
+)
+
+// PINs reach the HSM only as ISO 9564-1 format 4 PIN blocks, the AES
+// format, encrypted under a ZPK; the clear PIN never leaves it. Issuers
+// verify them against a value derived from the PIN and the PAN under a PIN
+// verification key (PVK), so they store neither PINs nor PIN blocks. Both
+// verification methods are DES algorithms: a PVK's first 16 bytes are the
+// double-length DES key they use.
+
+// PINBlockSize is the size of a format 4 PIN block
+const PINBlockSize = aes.BlockSize
+
+// PINMethod is a PIN verification method
+type PINMethod string
+
+const (
+	// PINMethodIBM3624 verifies PINs against an offset from the natural PIN
+	// the PVK derives from the PAN, so cardholders may choose their PIN
+	PINMethodIBM3624 PINMethod = "IBM3624"
+	// PINMethodVisaPVV verifies PINs against a 4-digit PIN verification
+	// value computed from the PAN, the PIN and a key index
+	PINMethodVisaPVV PINMethod = "VISA-PVV"
+)
+
+// ParsePINMethod parses a PIN verification method
+func ParsePINMethod(s string) (PINMethod, error) {
+	switch m := PINMethod(strings.ToUpper(s)); m {
+	case PINMethodIBM3624, PINMethodVisaPVV:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: %q, want IBM3624 or VISA-PVV", ErrInvalidPINMethod, s)
+}
+
+// PINReference is what an issuer keeps to verify a card's PIN: an IBM 3624
+// offset, as many digits as the PIN, or a Visa PVV and the index of the PVK
+// it was computed under
+type PINReference struct {
+	Method PINMethod
+	Value  string
+	PVKI   int // Visa PVV key index, 0-6
+}
+
+// decimalization maps the hex digits of an IBM 3624 cipher block to digits
+const decimalization = "0123456789012345"
+
+// EncryptPIN encrypts a clear PIN into a format 4 PIN block under a ZPK, as
+// a PIN pad does, for simulating the terminal side
+func (h *HSM) EncryptPIN(zpkID, pin, pan string) ([]byte, error) {
+	h.simulate("EncryptPIN")
+	if !validPIN(pin) {
+		h.logAudit("EncryptPIN", zpkID, 0, false, ErrInvalidPIN.Error())
+		return nil, ErrInvalidPIN
+	}
+	zpk, version, err := h.workingKey("EncryptPIN", zpkID, KeyTypeZPK, ModeEncrypt)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(zpk)
+
+	block, err := encryptPINBlock(zpk, []byte(pin), pan)
+	if err != nil {
+		h.logAudit("EncryptPIN", zpkID, version, false, err.Error())
+		return nil, err
+	}
+	h.logAudit("EncryptPIN", zpkID, version, true, "")
+	return block, nil
+}
+
+// GeneratePINReference derives the offset or PVV of the PIN in a PIN block,
+// when a card is issued or its PIN is set
+func (h *HSM) GeneratePINReference(pvkID, zpkID string, pinBlock []byte, pan string, method PINMethod, pvki int) (PINReference, error) {
+	h.simulate("GeneratePINReference")
+	pin, pvk, version, err := h.openPIN("GeneratePINReference", pvkID, zpkID, pinBlock, pan)
+	if err != nil {
+		return PINReference{}, err
+	}
+	defer securebytes.Zero(pin)
+	defer securebytes.Zero(pvk)
+
+	ref, err := pinReference(pvk, pin, pan, method, pvki)
+	if err != nil {
+		h.logAudit("GeneratePINReference", pvkID, version, false, err.Error())
+		return PINReference{}, err
+	}
+	h.logAudit("GeneratePINReference", pvkID, version, true, "")
+	return ref, nil
+}
+
+// VerifyPIN reports whether the PIN in a PIN block matches a card's offset
+// or PVV. A wrong PIN is a result, not an error; counting tries is up to
+// the issuer.
+func (h *HSM) VerifyPIN(pvkID, zpkID string, pinBlock []byte, pan string, ref PINReference) (bool, error) {
+	h.simulate("VerifyPIN")
+	pin, pvk, version, err := h.openPIN("VerifyPIN", pvkID, zpkID, pinBlock, pan)
+	if err != nil {
+		return false, err
+	}
+	defer securebytes.Zero(pin)
+	defer securebytes.Zero(pvk)
+
+	ok, err := verifyPIN(pvk, pin, pan, ref)
+	if err != nil {
+		h.logAudit("VerifyPIN", pvkID, version, false, err.Error())
+		return false, err
+	}
+	if !ok {
+		h.logAudit("VerifyPIN", pvkID, version, false, "PIN mismatch")
+		return false, nil
+	}
+	h.logAudit("VerifyPIN", pvkID, version, true, "")
+	return true, nil
+}
+
+// ChangePIN verifies the current PIN and returns the reference of the new
+// one, computed with the same method and key index. It fails with
+// ErrPINMismatch if the current PIN is wrong.
+func (h *HSM) ChangePIN(pvkID, zpkID string, oldPINBlock, newPINBlock []byte, pan string, ref PINReference) (PINReference, error) {
+	h.simulate("ChangePIN")
+	oldPIN, pvk, version, err := h.openPIN("ChangePIN", pvkID, zpkID, oldPINBlock, pan)
+	if err != nil {
+		return PINReference{}, err
+	}
+	defer securebytes.Zero(oldPIN)
+	defer securebytes.Zero(pvk)
+
+	if ok, err := verifyPIN(pvk, oldPIN, pan, ref); err != nil || !ok {
+		if err == nil {
+			err = ErrPINMismatch
+		}
+		h.logAudit("ChangePIN", pvkID, version, false, err.Error())
+		return PINReference{}, err
+	}
+	// The same ZPK protects both blocks
+	zpk, _, err := h.workingKey("ChangePIN", zpkID, KeyTypeZPK, ModeDecrypt)
+	if err != nil {
+		return PINReference{}, err
+	}
+	newPIN, err := decryptPINBlock(zpk, newPINBlock, pan)
+	securebytes.Zero(zpk)
+	if err != nil {
+		h.logAudit("ChangePIN", pvkID, version, false, err.Error())
+		return PINReference{}, err
+	}
+	defer securebytes.Zero(newPIN)
+
+	newRef, err := pinReference(pvk, newPIN, pan, ref.Method, ref.PVKI)
+	if err != nil {
+		h.logAudit("ChangePIN", pvkID, version, false, err.Error())
+		return PINReference{}, err
+	}
+	h.logAudit("ChangePIN", pvkID, version, true, "")
+	return newRef, nil
+}
+
+// TranslatePIN re-encrypts a PIN block from one ZPK to another, as an
+// acquirer does before passing a PIN on to the issuer: the source ZPK is
+// shared with the PIN pads, the destination one with the issuer. The PIN is
+// only in the clear inside the HSM.
+func (h *HSM) TranslatePIN(sourceZPKID, destZPKID string, pinBlock []byte, pan string) ([]byte, error) {
+	h.simulate("TranslatePIN")
+	source, _, err := h.workingKey("TranslatePIN", sourceZPKID, KeyTypeZPK, ModeDecrypt)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(source)
+	dest, version, err := h.workingKey("TranslatePIN", destZPKID, KeyTypeZPK, ModeEncrypt)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(dest)
+
+	pin, err := decryptPINBlock(source, pinBlock, pan)
+	if err != nil {
+		h.logAudit("TranslatePIN", sourceZPKID, 0, false, err.Error())
+		return nil, err
+	}
+	defer securebytes.Zero(pin)
+	block, err := encryptPINBlock(dest, pin, pan)
+	if err != nil {
+		h.logAudit("TranslatePIN", destZPKID, version, false, err.Error())
+		return nil, err
+	}
+	h.logAudit("TranslatePIN", destZPKID, version, true, "")
+	return block, nil
+}
+
+// openPIN decrypts a PIN block under a ZPK and returns the PIN with a copy
+// of the PVK's DES key and its version, all of which the caller zeroizes
+func (h *HSM) openPIN(operation, pvkID, zpkID string, pinBlock []byte, pan string) (pin, pvk []byte, version int, err error) {
+	zpk, _, err := h.workingKey(operation, zpkID, KeyTypeZPK, ModeDecrypt)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer securebytes.Zero(zpk)
+	if pvk, version, err = h.workingKey(operation, pvkID, KeyTypePVK, pvkMode(operation)); err != nil {
+		return nil, nil, 0, err
+	}
+	defer securebytes.Zero(pvk)
+
+	if pin, err = decryptPINBlock(zpk, pinBlock, pan); err != nil {
+		h.logAudit(operation, pvkID, version, false, err.Error())
+		return nil, nil, 0, err
+	}
+	return pin, securebytes.Clone(pvk[:16]), version, nil
+}
+
+// workingKey returns a copy of the current material of a working key of
+// the wanted type, such as a ZPK, PVK or CVK, whose mode of use permits op
+func (h *HSM) workingKey(operation, keyID string, want KeyType, op KeyMode) ([]byte, int, error) {
+	keyType, keyData, version, err := h.currentKey(keyID)
+	if err != nil {
+		h.logAudit(operation, keyID, 0, false, err.Error())
+		return nil, 0, err
+	}
+	if keyType != want {
+		securebytes.Zero(keyData)
+		h.logAudit(operation, keyID, version, false, "key usage")
+		return nil, 0, fmt.Errorf("%w: %s is a %s, not a %s", ErrKeyUsage, keyID, keyTypeOrDEK(keyType), want)
+	}
+	if err := h.checkKeyMode(operation, keyID, op); err != nil {
+		securebytes.Zero(keyData)
+		return nil, 0, err
+	}
+	return keyData, version, nil
+}
+
+// pvkMode is the mode of use a PVK needs for a PIN operation: generating a
+// reference, verifying against one, or both to change the PIN
+func pvkMode(operation string) KeyMode {
+	switch operation {
+	case "GeneratePINReference":
+		return ModeGenerate
+	case "VerifyPIN":
+		return ModeVerify
+	}
+	return ModeGenerateVerify
+}
+
+// encryptPINBlock builds a format 4 PIN block: the PIN field is enciphered,
+// XORed with the PAN field and enciphered again
+func encryptPINBlock(zpk, pin []byte, pan string) ([]byte, error) {
+	panField, err := pinPANField(pan)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(zpk)
+	if err != nil {
+		return nil, err
+	}
+	field := make([]byte, PINBlockSize)
+	field[0] = 0x40 | byte(len(pin))
+	for i := 2; i < 16; i++ {
+		nibble := byte(0xA)
+		if i-2 < len(pin) {
+			nibble = pin[i-2] - '0'
+		}
+		setNibble(field, i, nibble)
+	}
+	if _, err := rand.Read(field[8:]); err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(field)
+
+	out := make([]byte, PINBlockSize)
+	block.Encrypt(out, field)
+	subtle.XORBytes(out, out, panField)
+	block.Encrypt(out, out)
+	return out, nil
+}
+
+// decryptPINBlock reverses encryptPINBlock. A block under another ZPK or
+// for another PAN fails the format checks.
+func decryptPINBlock(zpk, pinBlock []byte, pan string) ([]byte, error) {
+	if len(pinBlock) != PINBlockSize {
+		return nil, fmt.Errorf("%w: must be %d bytes", ErrInvalidPINBlock, PINBlockSize)
+	}
+	panField, err := pinPANField(pan)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(zpk)
+	if err != nil {
+		return nil, err
+	}
+	field := make([]byte, PINBlockSize)
+	defer securebytes.Zero(field)
+	block.Decrypt(field, pinBlock)
+	subtle.XORBytes(field, field, panField)
+	block.Decrypt(field, field)
+
+	n := int(field[0] & 0x0F)
+	if field[0]>>4 != 4 || n < 4 || n > 12 {
+		return nil, ErrInvalidPINBlock
+	}
+	pin := make([]byte, n)
+	for i := 2; i < 16; i++ {
+		nibble := getNibble(field, i)
+		switch {
+		case i-2 < n && nibble <= 9:
+			pin[i-2] = '0' + nibble
+		case i-2 >= n && nibble == 0xA:
+		default:
+			securebytes.Zero(pin)
+			return nil, ErrInvalidPINBlock
+		}
+	}
+	return pin, nil
+}
+
+// pinPANField is the PAN field of a format 4 PIN block: the PAN's length
+// less 12, then its digits, zero padded
+func pinPANField(pan string) ([]byte, error) {
+	if len(pan) < 12 || len(pan) > 19 || !allDigits([]byte(pan)) {
+		return nil, ErrInvalidCardData
+	}
+	field := make([]byte, PINBlockSize)
+	setNibble(field, 0, byte(len(pan)-12))
+	for i := 0; i < len(pan); i++ {
+		setNibble(field, i+1, pan[i]-'0')
+	}
+	return field, nil
+}
+
+// pinReference computes the offset or PVV of a PIN
+func pinReference(pvk, pin []byte, pan string, method PINMethod, pvki int) (PINReference, error) {
+	switch method {
+	case PINMethodIBM3624:
+		natural, err := naturalPIN(pvk, pan, len(pin))
+		if err != nil {
+			return PINReference{}, err
+		}
+		defer securebytes.Zero(natural)
+		offset := make([]byte, len(pin))
+		for i := range pin {
+			offset[i] = '0' + (pin[i]-natural[i]+10)%10
+		}
+		return PINReference{Method: method, Value: string(offset)}, nil
+	case PINMethodVisaPVV:
+		pvv, err := visaPVV(pvk, pin, pan, pvki)
+		if err != nil {
+			return PINReference{}, err
+		}
+		return PINReference{Method: method, Value: pvv, PVKI: pvki}, nil
+	}
+	return PINReference{}, fmt.Errorf("%w: %q", ErrInvalidPINMethod, method)
+}
+
+// verifyPIN recomputes a PIN's reference and compares it in constant time
+func verifyPIN(pvk, pin []byte, pan string, ref PINReference) (bool, error) {
+	if ref.Value == "" || !allDigits([]byte(ref.Value)) {
+		return false, ErrInvalidPINReference
+	}
+	if ref.Method == PINMethodIBM3624 && len(ref.Value) != len(pin) {
+		// The offset fixes the PIN length
+		return false, nil
+	}
+	want, err := pinReference(pvk, pin, pan, ref.Method, ref.PVKI)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(want.Value), []byte(ref.Value)) == 1, nil
+}
+
+// naturalPIN is the IBM 3624 intermediate PIN: the validation data, the 12
+// PAN digits before the check digit padded with F, enciphered under the PVK
+// and decimalized
+func naturalPIN(pvk []byte, pan string, n int) ([]byte, error) {
+	if len(pan) < 13 {
+		return nil, ErrInvalidCardData
+	}
+	validation, err := hex.DecodeString(pan[len(pan)-13:len(pan)-1] + "FFFF")
+	if err != nil {
+		return nil, ErrInvalidCardData
+	}
+	enciphered, err := desEncrypt(pvk, validation)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(enciphered)
+	natural := make([]byte, n)
+	for i := range natural {
+		natural[i] = decimalization[getNibble(enciphered, i)]
+	}
+	return natural, nil
+}
+
+// visaPVV computes a Visa PIN verification value: the transformed security
+// parameter (11 PAN digits before the check digit, the key index and the
+// first four PIN digits) enciphered under the PVK, whose decimal digits are
+// taken first and its hex digits less ten after
+func visaPVV(pvk, pin []byte, pan string, pvki int) (string, error) {
+	if pvki < 0 || pvki > 6 {
+		return "", fmt.Errorf("%w: PVV key index %d is not 0-6", ErrInvalidPINReference, pvki)
+	}
+	if len(pan) < 12 {
+		return "", ErrInvalidCardData
+	}
+	tsp := make([]byte, 0, 16)
+	tsp = append(append(append(tsp, pan[len(pan)-12:len(pan)-1]...), byte('0'+pvki)), pin[:4]...)
+	defer securebytes.Zero(tsp)
+	data, err := hex.DecodeString(string(tsp))
+	if err != nil {
+		return "", ErrInvalidCardData
+	}
+	defer securebytes.Zero(data)
+	enciphered, err := desEncrypt(pvk, data)
+	if err != nil {
+		return "", err
+	}
+	defer securebytes.Zero(enciphered)
+
+	pvv := make([]byte, 0, 4)
+	for pass := 0; pass < 2 && len(pvv) < 4; pass++ {
+		for i := 0; i < 16 && len(pvv) < 4; i++ {
+			nibble := getNibble(enciphered, i)
+			switch {
+			case pass == 0 && nibble <= 9:
+				pvv = append(pvv, '0'+nibble)
+			case pass == 1 && nibble > 9:
+				pvv = append(pvv, '0'+nibble-10)
+			}
+		}
+	}
+	return string(pvv), nil
+}
+
+// desEncrypt enciphers one block under a double-length DES key
+func desEncrypt(key, data []byte) ([]byte, error) {
+	k := make([]byte, 0, 24)
+	k = append(append(k, key[:16]...), key[:8]...)
+	defer securebytes.Zero(k)
+	block, err := des.NewTripleDESCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, des.BlockSize)
+	block.Encrypt(out, data)
+	return out, nil
+}
+
+func validPIN(pin string) bool {
+	return len(pin) >= 4 && len(pin) <= 12 && allDigits([]byte(pin))
+}
+
+func getNibble(b []byte, i int) byte {
+	if i%2 == 0 {
+		return b[i/2] >> 4
+	}
+	return b[i/2] & 0x0F
+}
+
+func setNibble(b []byte, i int, v byte) {
+	if i%2 == 0 {
+		b[i/2] = b[i/2]&0x0F | v<<4
+	} else {
+		b[i/2] = b[i/2]&0xF0 | v&0x0F
+	}
+}
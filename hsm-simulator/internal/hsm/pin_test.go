@@ -0,0 +1,165 @@
+package hsm
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+const pinTestPAN = "4000001234567899"
+
+func newPINTestHSM(t *testing.T) *HSM {
+	t.Helper()
+	h := NewHSM()
+	if _, err := h.GenerateKeyOfType("zpk", "AES-256-GCM", KeyTypeZPK); err != nil {
+		t.Fatalf("GenerateKeyOfType(ZPK) error = %v", err)
+	}
+	if _, err := h.GenerateKeyOfType("pvk", "AES-256-GCM", KeyTypePVK); err != nil {
+		t.Fatalf("GenerateKeyOfType(PVK) error = %v", err)
+	}
+	return h
+}
+
+func TestPINBlockRoundTrip(t *testing.T) {
+	zpk := []byte("0123456789abcdef0123456789abcdef")
+	block, err := encryptPINBlock(zpk, []byte("123456"), pinTestPAN)
+	if err != nil || len(block) != PINBlockSize {
+		t.Fatalf("encryptPINBlock() = %x, %v", block, err)
+	}
+	again, _ := encryptPINBlock(zpk, []byte("123456"), pinTestPAN)
+	if string(again) == string(block) {
+		t.Error("format 4 PIN blocks of the same PIN should differ")
+	}
+	if pin, err := decryptPINBlock(zpk, block, pinTestPAN); err != nil || string(pin) != "123456" {
+		t.Errorf("decryptPINBlock() = %q, %v", pin, err)
+	}
+	// The PAN is bound into the block
+	if _, err := decryptPINBlock(zpk, block, "4000001234567881"); !errors.Is(err, ErrInvalidPINBlock) {
+		t.Errorf("decryptPINBlock() for another PAN error = %v", err)
+	}
+}
+
+func TestVerifyPIN(t *testing.T) {
+	h := newPINTestHSM(t)
+	// A PVK whose DES key is 0123456789ABCDEF FEDCBA9876543210, with the
+	// offset and PVV of 4321 for the test PAN under it worked out apart from
+	// this package
+	pvk, _ := hex.DecodeString("0123456789ABCDEFFEDCBA98765432100123456789ABCDEFFEDCBA9876543210")
+	if op, err := h.ImportKeyOfType("alice", "fixed-pvk", "AES-256-GCM", KeyTypePVK, pvk); err != nil || op.State != StateExecuted {
+		t.Fatalf("ImportKeyOfType(PVK) = %+v, %v", op, err)
+	}
+	right, _ := h.EncryptPIN("zpk", "4321", pinTestPAN)
+	wrong, _ := h.EncryptPIN("zpk", "4322", pinTestPAN)
+
+	for method, want := range map[PINMethod]string{PINMethodIBM3624: "4694", PINMethodVisaPVV: "2363"} {
+		ref, err := h.GeneratePINReference("fixed-pvk", "zpk", right, pinTestPAN, method, 1)
+		if err != nil {
+			t.Fatalf("GeneratePINReference(%s) error = %v", method, err)
+		}
+		if ref.Value != want {
+			t.Errorf("GeneratePINReference(%s) = %+v, want %s", method, ref, want)
+		}
+		if ok, err := h.VerifyPIN("fixed-pvk", "zpk", right, pinTestPAN, ref); !ok || err != nil {
+			t.Errorf("VerifyPIN(%s) of the right PIN = %v, %v", method, ok, err)
+		}
+		if ok, err := h.VerifyPIN("fixed-pvk", "zpk", wrong, pinTestPAN, ref); ok || err != nil {
+			t.Errorf("VerifyPIN(%s) of a wrong PIN = %v, %v", method, ok, err)
+		}
+	}
+
+	if _, err := h.GeneratePINReference("pvk", "zpk", right, pinTestPAN, PINMethodVisaPVV, 7); !errors.Is(err, ErrInvalidPINReference) {
+		t.Errorf("GeneratePINReference() with key index 7 error = %v", err)
+	}
+	if _, err := h.EncryptPIN("zpk", "123", pinTestPAN); !errors.Is(err, ErrInvalidPIN) {
+		t.Errorf("EncryptPIN() of 3 digits error = %v", err)
+	}
+	// PIN blocks are only opened under a ZPK, and verified under a PVK
+	if _, err := h.VerifyPIN("zpk", "zpk", right, pinTestPAN, PINReference{Method: PINMethodVisaPVV, Value: "1234"}); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("VerifyPIN() under a ZPK as PVK error = %v", err)
+	}
+}
+
+func TestIBM3624OffsetLetsCardholdersChoose(t *testing.T) {
+	h := newPINTestHSM(t)
+	for _, pin := range []string{"0000", "9999", "271828"} {
+		block, _ := h.EncryptPIN("zpk", pin, pinTestPAN)
+		ref, err := h.GeneratePINReference("pvk", "zpk", block, pinTestPAN, PINMethodIBM3624, 0)
+		if err != nil || len(ref.Value) != len(pin) {
+			t.Fatalf("GeneratePINReference(%s) = %+v, %v", pin, ref, err)
+		}
+		if ok, _ := h.VerifyPIN("pvk", "zpk", block, pinTestPAN, ref); !ok {
+			t.Errorf("VerifyPIN(%s) failed against its offset %s", pin, ref.Value)
+		}
+	}
+}
+
+func TestChangePIN(t *testing.T) {
+	h := newPINTestHSM(t)
+	oldBlock, _ := h.EncryptPIN("zpk", "1111", pinTestPAN)
+	newBlock, _ := h.EncryptPIN("zpk", "2468", pinTestPAN)
+	ref, _ := h.GeneratePINReference("pvk", "zpk", oldBlock, pinTestPAN, PINMethodVisaPVV, 2)
+
+	newRef, err := h.ChangePIN("pvk", "zpk", oldBlock, newBlock, pinTestPAN, ref)
+	if err != nil {
+		t.Fatalf("ChangePIN() error = %v", err)
+	}
+	if newRef.Method != PINMethodVisaPVV || newRef.PVKI != 2 {
+		t.Errorf("ChangePIN() = %+v, want the method and key index kept", newRef)
+	}
+	if ok, _ := h.VerifyPIN("pvk", "zpk", newBlock, pinTestPAN, newRef); !ok {
+		t.Error("the new PIN does not verify after ChangePIN()")
+	}
+	if ok, _ := h.VerifyPIN("pvk", "zpk", oldBlock, pinTestPAN, newRef); ok {
+		t.Error("the old PIN still verifies after ChangePIN()")
+	}
+
+	// Changing needs the current PIN
+	if _, err := h.ChangePIN("pvk", "zpk", newBlock, oldBlock, pinTestPAN, ref); !errors.Is(err, ErrPINMismatch) {
+		t.Errorf("ChangePIN() with a wrong current PIN error = %v", err)
+	}
+}
+
+func TestModeOfUseLimitsPINKeys(t *testing.T) {
+	h := newPINTestHSM(t)
+	h.GenerateKeyWithAttributes("verify-pvk", "AES-256-GCM", KeyTypePVK, KeyAttributes{Mode: ModeVerify})
+	block, _ := h.EncryptPIN("zpk", "1234", pinTestPAN)
+	ref, _ := h.GeneratePINReference("pvk", "zpk", block, pinTestPAN, PINMethodVisaPVV, 1)
+
+	if _, err := h.GeneratePINReference("verify-pvk", "zpk", block, pinTestPAN, PINMethodVisaPVV, 1); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("GeneratePINReference() under a verify-only PVK error = %v", err)
+	}
+	if _, err := h.VerifyPIN("verify-pvk", "zpk", block, pinTestPAN, ref); err != nil {
+		t.Errorf("VerifyPIN() under a verify-only PVK error = %v", err)
+	}
+}
+
+func TestTranslatePIN(t *testing.T) {
+	h := newPINTestHSM(t)
+	h.GenerateKeyOfType("issuer-zpk", "AES-256-GCM", KeyTypeZPK)
+	h.GenerateKeyWithAttributes("pinpad-zpk", "AES-256-GCM", KeyTypeZPK, KeyAttributes{Mode: ModeEncrypt})
+	block, _ := h.EncryptPIN("zpk", "4321", pinTestPAN)
+
+	translated, err := h.TranslatePIN("zpk", "issuer-zpk", block, pinTestPAN)
+	if err != nil {
+		t.Fatalf("TranslatePIN() error = %v", err)
+	}
+	ref, _ := h.GeneratePINReference("pvk", "zpk", block, pinTestPAN, PINMethodVisaPVV, 1)
+	if ok, err := h.VerifyPIN("pvk", "issuer-zpk", translated, pinTestPAN, ref); !ok || err != nil {
+		t.Errorf("VerifyPIN() of the translated block = %v, %v", ok, err)
+	}
+	if _, err := h.VerifyPIN("pvk", "zpk", translated, pinTestPAN, ref); !errors.Is(err, ErrInvalidPINBlock) {
+		t.Errorf("VerifyPIN() of the translated block under the source ZPK error = %v", err)
+	}
+
+	// The PAN is bound into both blocks
+	if _, err := h.TranslatePIN("zpk", "issuer-zpk", block, "4000001234567881"); !errors.Is(err, ErrInvalidPINBlock) {
+		t.Errorf("TranslatePIN() for another PAN error = %v", err)
+	}
+	// A PIN pad's encrypt-only ZPK cannot open blocks, and only ZPKs translate
+	if _, err := h.TranslatePIN("pinpad-zpk", "issuer-zpk", block, pinTestPAN); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("TranslatePIN() from an encrypt-only ZPK error = %v", err)
+	}
+	if _, err := h.TranslatePIN("zpk", "pvk", block, pinTestPAN); !errors.Is(err, ErrKeyUsage) {
+		t.Errorf("TranslatePIN() to a PVK error = %v", err)
+	}
+}
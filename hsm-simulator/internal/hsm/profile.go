@@ -0,0 +1,151 @@
+package hsm
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile models the performance of a real HSM or KMS: a latency per
+// operation and a ceiling on operations per second across all callers, so
+// capacity tests against the simulator produce comparable numbers
+type Profile struct {
+	Name string
+	// Latency is the mean service time per operation; operations not listed
+	// take no time
+	Latency map[string]time.Duration
+	// Jitter spreads each latency uniformly by this fraction, e.g. 0.2 for
+	// plus or minus 20%
+	Jitter float64
+	// MaxOpsPerSecond caps throughput; calls beyond it queue. Zero means
+	// unlimited.
+	MaxOpsPerSecond int
+}
+
+// Built-in profiles. The figures are approximations of published ratings and
+// typical observed latencies, not measurements of any particular device.
+var profiles = map[string]Profile{
+	"payShield-10k": {
+		Name: "payShield-10k",
+		Latency: map[string]time.Duration{
+			"Encrypt":         800 * time.Microsecond,
+			"Decrypt":         800 * time.Microsecond,
+			"GenerateDataKey": 1200 * time.Microsecond,
+			"GenerateKey":     5 * time.Millisecond,
+			"RotateKey":       5 * time.Millisecond,
+			"ExportKey":       1 * time.Millisecond,
+			"ImportKeyBlock":  1 * time.Millisecond,
+		},
+		Jitter:          0.1,
+		MaxOpsPerSecond: 2500,
+	},
+	"cloud-kms": {
+		Name: "cloud-kms",
+		Latency: map[string]time.Duration{
+			"Encrypt":         8 * time.Millisecond,
+			"Decrypt":         8 * time.Millisecond,
+			"GenerateDataKey": 10 * time.Millisecond,
+			"GenerateKey":     60 * time.Millisecond,
+			"RotateKey":       60 * time.Millisecond,
+			"ExportKey":       15 * time.Millisecond,
+			"ImportKeyBlock":  60 * time.Millisecond,
+		},
+		Jitter:          0.3,
+		MaxOpsPerSecond: 5500,
+	},
+}
+
+// ProfileNames lists the built-in profiles
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns a built-in profile by name, ignoring case
+func LookupProfile(name string) (Profile, error) {
+	for key, p := range profiles {
+		if strings.EqualFold(key, name) {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown HSM profile %q, want one of %s", name, strings.Join(ProfileNames(), ", "))
+}
+
+// WithProfile makes operations take the profile's latency and queue beyond
+// its throughput ceiling
+func WithProfile(p Profile) Option {
+	return func(h *HSM) {
+		h.SetProfile(&p)
+	}
+}
+
+// SetProfile replaces the performance profile while the HSM runs; nil
+// removes it. Operations already waiting finish on the old profile.
+func (h *HSM) SetProfile(p *Profile) {
+	if p == nil {
+		h.profile.Store(nil)
+		return
+	}
+	h.profile.Store(&throttle{profile: *p, now: h.now, sleep: time.Sleep})
+}
+
+// Profile returns the active performance profile, if any
+func (h *HSM) Profile() (Profile, bool) {
+	t := h.profile.Load()
+	if t == nil {
+		return Profile{}, false
+	}
+	return t.profile, true
+}
+
+// simulate delays an operation as the profile dictates. It must be called
+// without holding HSM locks.
+func (h *HSM) simulate(operation string) {
+	if t := h.profile.Load(); t != nil {
+		t.wait(operation)
+	}
+}
+
+// throttle spaces operations 1/MaxOpsPerSecond apart: each call reserves
+// the next free slot and sleeps until it, then for the operation's latency
+type throttle struct {
+	profile Profile
+	now     func() time.Time
+	sleep   func(time.Duration)
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (t *throttle) wait(operation string) {
+	var delay time.Duration
+	if t.profile.MaxOpsPerSecond > 0 {
+		interval := time.Second / time.Duration(t.profile.MaxOpsPerSecond)
+		t.mu.Lock()
+		now := t.now()
+		slot := t.next
+		if slot.Before(now) {
+			slot = now
+		}
+		t.next = slot.Add(interval)
+		t.mu.Unlock()
+		delay = slot.Sub(now)
+	}
+
+	if latency := t.profile.Latency[operation]; latency > 0 {
+		if t.profile.Jitter > 0 {
+			latency += time.Duration((rand.Float64()*2 - 1) * t.profile.Jitter * float64(latency))
+		}
+		delay += latency
+	}
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
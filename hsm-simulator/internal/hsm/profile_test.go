@@ -0,0 +1,86 @@
+package hsm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range []string{"payShield-10k", "PAYSHIELD-10K", "cloud-kms"} {
+		if _, err := LookupProfile(name); err != nil {
+			t.Errorf("LookupProfile(%q) error = %v", name, err)
+		}
+	}
+	if _, err := LookupProfile("thales-luna"); err == nil {
+		t.Error("LookupProfile() should reject unknown profiles")
+	}
+}
+
+func TestProfileThrottle(t *testing.T) {
+	h := NewHSM(WithProfile(Profile{
+		Name:            "test",
+		Latency:         map[string]time.Duration{"Encrypt": 2 * time.Millisecond},
+		MaxOpsPerSecond: 100,
+	}))
+	start := time.Now()
+	var slept []time.Duration
+	h.profile.Load().now = func() time.Time { return start }
+	h.profile.Load().sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	h.GenerateKey("key", "AES-256-GCM")
+	for i := 0; i < 3; i++ {
+		if _, _, _, err := h.Encrypt("key", []byte("data"), nil); err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+	}
+
+	// GenerateKey takes the first 10ms slot and has no latency; the encrypts
+	// queue behind it and each take 2ms
+	want := []time.Duration{12 * time.Millisecond, 22 * time.Millisecond, 32 * time.Millisecond}
+	if len(slept) != len(want) {
+		t.Fatalf("slept %v, want %v", slept, want)
+	}
+	for i := range want {
+		if slept[i] != want[i] {
+			t.Errorf("sleep %d = %v, want %v", i, slept[i], want[i])
+		}
+	}
+
+	if p, ok := h.Profile(); !ok || p.Name != "test" || h.DumpState().Policies.Profile != "test" {
+		t.Errorf("Profile() = %+v, %v", p, ok)
+	}
+}
+
+func TestProfileLatency(t *testing.T) {
+	p, _ := LookupProfile("cloud-kms")
+	h := NewHSM(WithProfile(p))
+	h.GenerateKey("key", "AES-256-GCM")
+
+	start := time.Now()
+	h.Encrypt("key", []byte("data"), nil)
+	if elapsed, min := time.Since(start), time.Duration(float64(p.Latency["Encrypt"])*(1-p.Jitter)); elapsed < min {
+		t.Errorf("Encrypt took %v, want at least %v", elapsed, min)
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	h := NewHSM()
+	p, _ := LookupProfile("cloud-kms")
+	h.SetProfile(&p)
+	if got, ok := h.Profile(); !ok || got.Name != "cloud-kms" {
+		t.Errorf("Profile() = %v, %v after SetProfile", got.Name, ok)
+	}
+	if state := h.DumpState(); state.Policies.Profile != "cloud-kms" {
+		t.Errorf("DumpState() profile = %q", state.Policies.Profile)
+	}
+	h.SetProfile(nil)
+	if _, ok := h.Profile(); ok {
+		t.Error("SetProfile(nil) left a profile active")
+	}
+
+	h.RecordConfigReload("sighup uid:0", "profile cloud-kms -> none", nil)
+	log := h.GetAuditLog()
+	if last := log[len(log)-1]; last.Operation != "ReloadConfig" || last.Requester != "sighup uid:0" || last.Detail == "" {
+		t.Errorf("audit entry = %+v", last)
+	}
+}
@@ -0,0 +1,494 @@
+package hsm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrInvalidTransportKey = errors.New("transport key must be 32 bytes")
+	ErrReplicationDisabled = errors.New("replication requires a transport key")
+	ErrReplicationConflict = errors.New("replicated key version conflicts with local key material")
+	ErrStandby             = errors.New("HSM is a standby replica; send key changes to the primary")
+)
+
+// ChangeKind says what a replicated change does
+type ChangeKind string
+
+const (
+	// ChangeVersion adds a key version, creating the key if needed
+	ChangeVersion ChangeKind = "version"
+	// ChangeDestroy removes a destroyed key version
+	ChangeDestroy ChangeKind = "destroy"
+)
+
+// Change is one entry of the replication log. Key material travels only as
+// WrappedKey, sealed under the pair's shared transport key with the key ID,
+// version and the rest of the key's metadata as AAD, so a change cannot be
+// replayed onto another version or install the key with other attributes.
+type Change struct {
+	Seq        uint64
+	Time       time.Time
+	Kind       ChangeKind
+	KeyID      string
+	Algorithm  string
+	KeyType    KeyType
+	Attributes KeyAttributes
+	Version    int
+	CreatedAt  time.Time
+	WrappedKey []byte
+	Nonce      []byte
+}
+
+// ChangeSource serves the replication log of a primary, e.g. an HSM in the
+// same process or a client of a remote one
+type ChangeSource interface {
+	// Changes returns every change after since and the newest sequence
+	Changes(since uint64) ([]Change, uint64, error)
+}
+
+// DefaultReplicationLogLimit is how many changes the replication log keeps.
+// A standby further behind is resynchronized from the keys themselves.
+const DefaultReplicationLogLimit = 10000
+
+// change is a replication log entry; key material is read and wrapped when
+// the change is served, not stored twice
+type change struct {
+	seq     uint64
+	time    time.Time
+	kind    ChangeKind
+	keyID   string
+	version int
+}
+
+// WithTransportKey enables replication. Both HSMs of a pair need the same
+// 256-bit transport key, which wraps key material on the wire.
+func WithTransportKey(key []byte) Option {
+	key = securebytes.Clone(key)
+	return func(h *HSM) { h.transportKey = key }
+}
+
+// Replicates reports whether the HSM can take part in an HA pair
+func (h *HSM) Replicates() bool {
+	return h.transportKey != nil
+}
+
+// recordChange appends to the replication log, dropping the oldest changes
+// beyond the log's limit. It may be called with HSM or key locks held.
+func (h *HSM) recordChange(kind ChangeKind, keyID string, version int) {
+	h.changeMu.Lock()
+	defer h.changeMu.Unlock()
+
+	h.changeSeq++
+	h.changes = append(h.changes, change{
+		seq:     h.changeSeq,
+		time:    h.now(),
+		kind:    kind,
+		keyID:   keyID,
+		version: version,
+	})
+	if limit := h.changeLimit; limit > 0 && len(h.changes) > limit {
+		drop := len(h.changes) - limit
+		h.trimmedSeq = h.changes[drop-1].seq
+		h.changes = append([]change(nil), h.changes[drop:]...)
+	}
+}
+
+// Changes returns the replication log after since with key material
+// wrapped under the transport key. Versions destroyed since they were
+// logged are left out; their destroy change follows. A standby asking for
+// changes the log no longer holds gets a resync instead: every version of
+// every key, with destroy changes for the versions destroyed.
+func (h *HSM) Changes(since uint64) ([]Change, uint64, error) {
+	gcm, err := h.transportGCM()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h.changeMu.Lock()
+	head := h.changeSeq
+	var pending []change
+	if since < h.trimmedSeq {
+		h.changeMu.Unlock()
+		pending = h.resync(head)
+	} else {
+		for _, c := range h.changes {
+			if c.seq > since {
+				pending = append(pending, c)
+			}
+		}
+		h.changeMu.Unlock()
+	}
+
+	out, err := h.wrapChanges(gcm, pending)
+	if err != nil {
+		return nil, 0, err
+	}
+	h.logAudit("Replicate", "", 0, true, "")
+	return out, head, nil
+}
+
+// wrapChanges reads the key material of version changes and wraps it under
+// the transport key. Versions destroyed since are left out.
+func (h *HSM) wrapChanges(gcm cipher.AEAD, pending []change) ([]Change, error) {
+	out := make([]Change, 0, len(pending))
+	for _, c := range pending {
+		ch := Change{Seq: c.seq, Time: c.time, Kind: c.kind, KeyID: c.keyID, Version: c.version}
+		if c.kind == ChangeVersion {
+			algorithm, keyType, attrs, createdAt, keyData, ok := h.version(c.keyID, c.version)
+			if !ok {
+				continue
+			}
+			ch.Algorithm = algorithm
+			ch.KeyType = keyType
+			ch.Attributes = attrs
+			ch.CreatedAt = createdAt
+			ch.Nonce = make([]byte, gcm.NonceSize())
+			if _, err := io.ReadFull(rand.Reader, ch.Nonce); err != nil {
+				securebytes.Zero(keyData)
+				return nil, fmt.Errorf("failed to generate nonce: %w", err)
+			}
+			ch.WrappedKey = gcm.Seal(nil, ch.Nonce, keyData, changeAAD(ch))
+			securebytes.Zero(keyData)
+		}
+		out = append(out, ch)
+	}
+	return out, nil
+}
+
+// resync lists the changes that bring a standby of any age to the keys as
+// they are: a version change for every version held and a destroy for every
+// version destroyed. Versions are numbered from 1 without gaps, so a missing
+// version below the current one was destroyed.
+func (h *HSM) resync(head uint64) []change {
+	h.mu.RLock()
+	keys := make([]*Key, 0, len(h.keys))
+	for _, key := range h.keys {
+		keys = append(keys, key)
+	}
+	h.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	now := h.now()
+	var out []change
+	for _, key := range keys {
+		key.mu.RLock()
+		for v := 1; v <= key.CurrentVersion; v++ {
+			kind := ChangeVersion
+			if _, ok := key.Versions[v]; !ok {
+				kind = ChangeDestroy
+			}
+			out = append(out, change{seq: head, time: now, kind: kind, keyID: key.ID, version: v})
+		}
+		key.mu.RUnlock()
+	}
+	return out
+}
+
+// ApplyChanges installs changes from the primary. Applying a change twice
+// is harmless; a version whose material differs from the local copy is a
+// split brain and fails with ErrReplicationConflict.
+func (h *HSM) ApplyChanges(changes []Change) error {
+	gcm, err := h.transportGCM()
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		switch ch.Kind {
+		case ChangeVersion:
+			keyData, err := gcm.Open(nil, ch.Nonce, ch.WrappedKey, changeAAD(ch))
+			if err != nil {
+				h.logAudit("ApplyChange", ch.KeyID, ch.Version, false, "unwrap failed")
+				return fmt.Errorf("change %d: %w", ch.Seq, ErrDecryptionFailed)
+			}
+			err = h.installVersion(ch, keyData)
+			securebytes.Zero(keyData)
+			if err != nil {
+				h.logAudit("ApplyChange", ch.KeyID, ch.Version, false, err.Error())
+				return fmt.Errorf("change %d: %w", ch.Seq, err)
+			}
+		case ChangeDestroy:
+			h.removeVersion(ch.KeyID, ch.Version)
+		default:
+			return fmt.Errorf("change %d: unknown kind %q", ch.Seq, ch.Kind)
+		}
+		h.logAudit("ApplyChange", ch.KeyID, ch.Version, true, "")
+	}
+	return nil
+}
+
+func (h *HSM) installVersion(ch Change, keyData []byte) error {
+	h.mu.Lock()
+	key, exists := h.keys[ch.KeyID]
+	if !exists {
+		key = &Key{
+			ID:         ch.KeyID,
+			Algorithm:  ch.Algorithm,
+			Type:       keyTypeOrDEK(ch.KeyType),
+			Attributes: ch.Attributes,
+			Versions:   make(map[int]*KeyVersion),
+			CreatedAt:  ch.CreatedAt,
+		}
+		h.keys[ch.KeyID] = key
+	}
+	h.mu.Unlock()
+
+	key.mu.Lock()
+	defer key.mu.Unlock()
+	if err := key.conflictLocked(ch, keyData); err != nil {
+		return err
+	}
+	if _, ok := key.Versions[ch.Version]; ok {
+		return nil
+	}
+	key.Versions[ch.Version] = &KeyVersion{
+		Version:   ch.Version,
+		KeyData:   securebytes.Clone(keyData),
+		CreatedAt: ch.CreatedAt,
+	}
+	if ch.Version > key.CurrentVersion {
+		if exists {
+			h.advise(Advice{Kind: AdviceRotated, KeyID: ch.KeyID, Version: ch.Version, CurrentVersion: ch.Version})
+		}
+		key.CurrentVersion = ch.Version
+		key.LastRotatedAt = ch.CreatedAt
+	}
+	h.recordChange(ChangeVersion, ch.KeyID, ch.Version)
+	return nil
+}
+
+// conflictLocked reports whether a version change disagrees with the key:
+// other metadata, or other material for a version both hold. key.mu must
+// be held.
+func (key *Key) conflictLocked(ch Change, keyData []byte) error {
+	if key.Algorithm != ch.Algorithm || key.Type != keyTypeOrDEK(ch.KeyType) || key.attributesLocked() != ch.Attributes {
+		return fmt.Errorf("%w: %s is a %s %s with mode of use %s and exportability %s, the change a %s %s with %s and %s",
+			ErrReplicationConflict, ch.KeyID, key.Algorithm, key.Type, key.attributesLocked().Mode, key.attributesLocked().Exportability,
+			ch.Algorithm, keyTypeOrDEK(ch.KeyType), ch.Attributes.Mode, ch.Attributes.Exportability)
+	}
+	if v, ok := key.Versions[ch.Version]; ok && !securebytes.Equal(v.KeyData, keyData) {
+		return ErrReplicationConflict
+	}
+	return nil
+}
+
+func (h *HSM) removeVersion(keyID string, version int) {
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	key.mu.Lock()
+	defer key.mu.Unlock()
+	if v, ok := key.Versions[version]; ok {
+		securebytes.Zero(v.KeyData)
+		delete(key.Versions, version)
+		h.recordChange(ChangeDestroy, keyID, version)
+		h.advise(Advice{Kind: AdviceRetired, KeyID: keyID, Version: version, CurrentVersion: key.CurrentVersion})
+	}
+}
+
+// version returns a copy of a key version's material for replication,
+// which the caller must zeroize
+func (h *HSM) version(keyID string, version int) (algorithm string, keyType KeyType, attrs KeyAttributes, createdAt time.Time, keyData []byte, ok bool) {
+	h.mu.RLock()
+	key, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if !exists {
+		return "", "", KeyAttributes{}, time.Time{}, nil, false
+	}
+
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+	v, ok := key.Versions[version]
+	if !ok {
+		return "", "", KeyAttributes{}, time.Time{}, nil, false
+	}
+	return key.Algorithm, key.Type, key.attributesLocked(), v.CreatedAt, securebytes.Clone(v.KeyData), true
+}
+
+func (h *HSM) transportGCM() (cipher.AEAD, error) {
+	if h.transportKey == nil {
+		return nil, ErrReplicationDisabled
+	}
+	if len(h.transportKey) != 32 {
+		return nil, ErrInvalidTransportKey
+	}
+	block, err := aes.NewCipher(h.transportKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// changeAAD binds a version change's metadata to its wrapped key, quoted so
+// no two changes share an AAD
+func changeAAD(ch Change) []byte {
+	return []byte(fmt.Sprintf("%q %d %q %q %q %q %d", ch.KeyID, ch.Version, ch.Algorithm, ch.KeyType,
+		ch.Attributes.Mode, ch.Attributes.Exportability, ch.CreatedAt.UnixNano()))
+}
+
+// Standby reports whether the HSM only accepts key changes by replication
+func (h *HSM) Standby() bool {
+	h.changeMu.Lock()
+	defer h.changeMu.Unlock()
+	return h.standby
+}
+
+func (h *HSM) setStandby(standby bool) {
+	h.changeMu.Lock()
+	defer h.changeMu.Unlock()
+	h.standby = standby
+}
+
+// checkWritable rejects key changes on a standby, which would diverge from
+// the primary
+func (h *HSM) checkWritable() error {
+	if h.Standby() {
+		return ErrStandby
+	}
+	return nil
+}
+
+// Replicator keeps a standby HSM in sync with a primary by pulling its
+// replication log, until Promote makes the standby the primary
+type Replicator struct {
+	local  *HSM
+	source ChangeSource
+	now    func() time.Time
+
+	syncMu     sync.Mutex // serializes syncs; mu is not held across a pull
+	mu         sync.Mutex
+	applied    uint64
+	caughtUpAt time.Time
+	promoted   bool
+
+	changesApplied *metrics.Counter
+	failures       *metrics.Counter
+}
+
+// NewReplicator makes local a standby of source. Both must share a
+// transport key. A nil registry keeps the metrics private.
+func NewReplicator(local *HSM, source ChangeSource, registry *metrics.Registry) (*Replicator, error) {
+	if _, err := local.transportGCM(); err != nil {
+		return nil, err
+	}
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	r := &Replicator{
+		local:          local,
+		source:         source,
+		now:            local.now,
+		changesApplied: registry.Counter("hsm_replication_changes_total", "Key changes applied from the primary.").With(),
+		failures:       registry.Counter("hsm_replication_failures_total", "Failed replication syncs.").With(),
+	}
+	r.caughtUpAt = r.now()
+	registry.GaugeFunc("hsm_replication_lag_seconds", "Time since the standby last matched the primary's replication log.", func() float64 {
+		return r.Lag().Seconds()
+	})
+	registry.GaugeFunc("hsm_replication_applied_seq", "Last primary replication sequence applied by the standby.", func() float64 {
+		return float64(r.Applied())
+	})
+	local.setStandby(true)
+	return r, nil
+}
+
+// SyncOnce pulls and applies the primary's changes since the last sync
+func (r *Replicator) SyncOnce() error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if r.Promoted() {
+		return nil
+	}
+
+	changes, head, err := r.source.Changes(r.Applied())
+	if err == nil {
+		err = r.local.ApplyChanges(changes)
+	}
+	if err != nil {
+		r.failures.Inc()
+		return fmt.Errorf("replicate: %w", err)
+	}
+	r.changesApplied.Add(float64(len(changes)))
+
+	r.mu.Lock()
+	r.applied = head
+	r.caughtUpAt = r.now()
+	r.mu.Unlock()
+	return nil
+}
+
+// Run syncs every interval until the context is cancelled or the standby
+// is promoted
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.SyncOnce(); err != nil {
+			log.Printf("HSM replication failed (lag %s): %v", r.Lag().Round(time.Millisecond), err)
+		}
+		if r.Promoted() {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Promote stops replication and lets the standby accept key changes, for
+// failover when the primary is lost. Changes the primary made after the
+// last sync are lost with it.
+func (r *Replicator) Promote() {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.promoted = true
+	r.local.setStandby(false)
+	r.local.logAudit("Promote", "", 0, true, "")
+}
+
+// Promoted reports whether the standby has taken over
+func (r *Replicator) Promoted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.promoted
+}
+
+// Applied returns the last primary sequence applied
+func (r *Replicator) Applied() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.applied
+}
+
+// Lag is how long ago the standby last matched the primary; it grows while
+// the primary is unreachable. A promoted standby has no lag.
+func (r *Replicator) Lag() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.promoted {
+		return 0
+	}
+	return r.now().Sub(r.caughtUpAt)
+}
@@ -0,0 +1,214 @@
+package hsm
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+var testTransportKey = bytes.Repeat([]byte{0x42}, 32)
+
+// flakySource fails while down, like an unreachable primary
+type flakySource struct {
+	*HSM
+	down bool
+}
+
+func (s *flakySource) Changes(since uint64) ([]Change, uint64, error) {
+	if s.down {
+		return nil, 0, errors.New("connection refused")
+	}
+	return s.HSM.Changes(since)
+}
+
+func TestReplication(t *testing.T) {
+	primary := NewHSM(WithTransportKey(testTransportKey))
+	standby := NewHSM(WithTransportKey(testTransportKey))
+	registry := metrics.NewRegistry()
+	replicator, err := NewReplicator(standby, primary, registry)
+	if err != nil {
+		t.Fatalf("NewReplicator() error = %v", err)
+	}
+
+	primary.GenerateKey("key", "AES-256-GCM")
+	oldCiphertext, oldNonce, _, _ := primary.Encrypt("key", []byte("before rotation"), []byte("aad"))
+	primary.RotateKey("key")
+	newCiphertext, newNonce, _, _ := primary.Encrypt("key", []byte("after rotation"), nil)
+	primary.GenerateKey("retired", "AES-256-GCM")
+	primary.RotateKey("retired")
+	primary.DestroyKeyVersion("ops", "retired", 1)
+
+	if err := replicator.SyncOnce(); err != nil {
+		t.Fatalf("SyncOnce() error = %v", err)
+	}
+	if got, err := standby.Decrypt("key", oldCiphertext, oldNonce, []byte("aad"), 1); err != nil || string(got) != "before rotation" {
+		t.Errorf("standby Decrypt(v1) = %q, %v", got, err)
+	}
+	if got, err := standby.Decrypt("key", newCiphertext, newNonce, nil, 2); err != nil || string(got) != "after rotation" {
+		t.Errorf("standby Decrypt(v2) = %q, %v", got, err)
+	}
+	if info, _ := standby.GetKeyInfo("key"); info.CurrentVersion != 2 {
+		t.Errorf("standby current version = %d, want 2", info.CurrentVersion)
+	}
+	if info, _ := standby.GetKeyInfo("retired"); len(info.AvailableVersions) != 1 {
+		t.Errorf("destroyed version replicated: %v", info.AvailableVersions)
+	}
+
+	// A second sync applies nothing new
+	if err := replicator.SyncOnce(); err != nil || replicator.Applied() != primary.changeSeq {
+		t.Errorf("SyncOnce() = %v, applied %d of %d", err, replicator.Applied(), primary.changeSeq)
+	}
+
+	if _, err := standby.GenerateKey("other", "AES-256-GCM"); !errors.Is(err, ErrStandby) {
+		t.Errorf("standby GenerateKey() error = %v, want %v", err, ErrStandby)
+	}
+	if _, _, err := standby.RotateKey("key"); !errors.Is(err, ErrStandby) {
+		t.Errorf("standby RotateKey() error = %v, want %v", err, ErrStandby)
+	}
+
+	// Five changes, of which the destroyed version is not shipped
+	var out strings.Builder
+	registry.WriteText(&out)
+	for _, want := range []string{"hsm_replication_lag_seconds", "hsm_replication_changes_total 4"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestReplicationTransportKey(t *testing.T) {
+	if _, err := NewReplicator(NewHSM(), NewHSM(), nil); !errors.Is(err, ErrReplicationDisabled) {
+		t.Errorf("NewReplicator() without a transport key: error = %v", err)
+	}
+
+	primary := NewHSM(WithTransportKey(testTransportKey))
+	primary.GenerateKey("key", "AES-256-GCM")
+	changes, _, _ := primary.Changes(0)
+	if bytes.Contains(changes[0].WrappedKey, primary.keys["key"].Versions[1].KeyData) {
+		t.Fatal("key material sent unwrapped")
+	}
+
+	standby := NewHSM(WithTransportKey(bytes.Repeat([]byte{0x43}, 32)))
+	replicator, _ := NewReplicator(standby, primary, nil)
+	if err := replicator.SyncOnce(); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("SyncOnce() with another transport key: error = %v, want %v", err, ErrDecryptionFailed)
+	}
+	if _, err := standby.GetKeyInfo("key"); !errors.Is(err, ErrKeyNotFound) {
+		t.Error("key installed despite the wrong transport key")
+	}
+
+	// A change replayed onto another version, or with other metadata, fails
+	// authentication
+	changes[0].Version = 2
+	if err := NewHSM(WithTransportKey(testTransportKey)).ApplyChanges(changes); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("ApplyChanges(replayed) error = %v", err)
+	}
+	changes[0].Version = 1
+	for name, tamper := range map[string]func(*Change){
+		"algorithm":     func(ch *Change) { ch.Algorithm = "AES-128-GCM" },
+		"key type":      func(ch *Change) { ch.KeyType = KeyTypeZMK },
+		"mode of use":   func(ch *Change) { ch.Attributes.Mode = ModeEncrypt },
+		"exportability": func(ch *Change) { ch.Attributes.Exportability = ExportableSensitive },
+	} {
+		tampered := changes[0]
+		tamper(&tampered)
+		if err := NewHSM(WithTransportKey(testTransportKey)).ApplyChanges([]Change{tampered}); !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("ApplyChanges() with another %s error = %v", name, err)
+		}
+	}
+
+	// A version of a key the standby holds with other metadata conflicts
+	standby = NewHSM(WithTransportKey(testTransportKey))
+	standby.GenerateKeyOfType("key", "AES-256-GCM", KeyTypeCVK)
+	if err := standby.ApplyChanges(changes[:1]); !errors.Is(err, ErrReplicationConflict) {
+		t.Errorf("ApplyChanges() onto a CVK error = %v, want %v", err, ErrReplicationConflict)
+	}
+}
+
+func TestReplicationLogLimit(t *testing.T) {
+	primary := NewHSM(WithTransportKey(testTransportKey))
+	primary.changeLimit = 3
+	standby := NewHSM(WithTransportKey(testTransportKey))
+	replicator, _ := NewReplicator(standby, primary, nil)
+
+	primary.GenerateKey("key", "AES-256-GCM")
+	primary.RotateKey("key")
+	replicator.SyncOnce()
+
+	// The standby falls behind by more than the log holds
+	primary.RotateKey("key")
+	primary.DestroyKeyVersion("ops", "key", 1)
+	primary.GenerateKey("other", "AES-256-GCM")
+	primary.RotateKey("other")
+	primary.DestroyKeyVersion("ops", "other", 1)
+	if len(primary.changes) != 3 {
+		t.Fatalf("replication log holds %d changes, want 3", len(primary.changes))
+	}
+
+	if err := replicator.SyncOnce(); err != nil || replicator.Applied() != primary.changeSeq {
+		t.Fatalf("SyncOnce() = %v, applied %d of %d", err, replicator.Applied(), primary.changeSeq)
+	}
+	for keyID, want := range map[string][]int{"key": {2, 3}, "other": {2}} {
+		info, err := standby.GetKeyInfo(keyID)
+		if err != nil {
+			t.Fatalf("standby GetKeyInfo(%s) error = %v", keyID, err)
+		}
+		got := info.AvailableVersions
+		sort.Ints(got)
+		if info.CurrentVersion != want[len(want)-1] || len(got) != len(want) || got[0] != want[0] {
+			t.Errorf("standby %s versions = %v (current %d), want %v", keyID, got, info.CurrentVersion, want)
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	primary := NewHSM(WithTransportKey(testTransportKey))
+	source := &flakySource{HSM: primary}
+	standby := NewHSM(WithTransportKey(testTransportKey))
+	now := time.Now()
+	standby.now = func() time.Time { return now }
+	replicator, _ := NewReplicator(standby, source, nil)
+
+	primary.GenerateKey("key", "AES-256-GCM")
+	ciphertext, nonce, version, _ := primary.Encrypt("key", []byte("card data"), nil)
+	replicator.SyncOnce()
+
+	// The primary goes down and the lag grows
+	source.down = true
+	now = now.Add(30 * time.Second)
+	if err := replicator.SyncOnce(); err == nil {
+		t.Fatal("SyncOnce() against a down primary should fail")
+	}
+	if lag := replicator.Lag(); lag != 30*time.Second {
+		t.Errorf("Lag() = %v, want 30s", lag)
+	}
+
+	// After promotion the standby serves old data and accepts key changes
+	replicator.Promote()
+	if replicator.Lag() != 0 || standby.Standby() {
+		t.Error("promoted standby still reports as a replica")
+	}
+	if got, err := standby.Decrypt("key", ciphertext, nonce, nil, version); err != nil || string(got) != "card data" {
+		t.Errorf("Decrypt() after failover = %q, %v", got, err)
+	}
+	if _, _, err := standby.RotateKey("key"); err != nil {
+		t.Fatalf("RotateKey() after failover error = %v", err)
+	}
+
+	// The old primary rejoins as a standby of the new one
+	rejoin, err := NewReplicator(primary, standby, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rejoin.SyncOnce(); err != nil {
+		t.Fatalf("rejoin SyncOnce() error = %v", err)
+	}
+	if info, _ := primary.GetKeyInfo("key"); info.CurrentVersion != 2 {
+		t.Errorf("rejoined primary current version = %d, want 2", info.CurrentVersion)
+	}
+}
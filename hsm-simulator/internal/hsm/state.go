@@ -0,0 +1,124 @@
+package hsm
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// State is a key-free snapshot of the HSM for debugging and test
+// assertions. It carries metadata only; no field ever holds key material.
+type State struct {
+	Keys        []KeyState          `json:"keys"`
+	Policies    Policies            `json:"policies"`
+	Counters    map[string]Counters `json:"counters"`
+	Nonces      NonceStats          `json:"nonces"`
+	Compression CompressionStats    `json:"compression"`
+	Operations  []Operation         `json:"operations"`
+	TakenAt     time.Time           `json:"taken_at"`
+}
+
+// KeyState describes a key and its versions
+type KeyState struct {
+	KeyID          string         `json:"key_id"`
+	Algorithm      string         `json:"algorithm"`
+	Type           KeyType        `json:"type"`
+	KCV            string         `json:"kcv"` // of the current version
+	Mode           KeyMode        `json:"mode_of_use"`
+	Exportability  Exportability  `json:"exportability"`
+	CurrentVersion int            `json:"current_version"`
+	Versions       []VersionState `json:"versions"`
+	CreatedAt      time.Time      `json:"created_at"`
+	LastRotatedAt  time.Time      `json:"last_rotated_at"`
+	// Usage counts audited operations on the key by name
+	Usage map[string]Counters `json:"usage"`
+}
+
+// VersionState describes one key version
+type VersionState struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Policies reports how the HSM is configured
+type Policies struct {
+	DualControl bool             `json:"dual_control"`
+	ApprovalTTL time.Duration    `json:"approval_ttl,omitempty"`
+	Profile     string           `json:"profile,omitempty"`
+	NonceReuse  NonceReusePolicy `json:"nonce_reuse"`
+}
+
+// Counters tallies audited outcomes
+type Counters struct {
+	Success int `json:"success"`
+	Failure int `json:"failure"`
+}
+
+// DumpState returns the HSM's keys, versions, policies, operation counters
+// and destructive operations
+func (h *HSM) DumpState() State {
+	state := State{
+		Policies:    Policies{DualControl: h.dualControl, ApprovalTTL: h.approvalTTL, NonceReuse: h.nonces.policy},
+		Counters:    make(map[string]Counters),
+		Nonces:      h.NonceStats(),
+		Compression: h.CompressionStats(),
+		TakenAt:     h.now(),
+	}
+	if p, ok := h.Profile(); ok {
+		state.Policies.Profile = p.Name
+	}
+
+	h.mu.RLock()
+	for _, key := range h.keys {
+		key.mu.RLock()
+		ks := KeyState{
+			KeyID:          key.ID,
+			Algorithm:      key.Algorithm,
+			Type:           key.Type,
+			KCV:            key.checkValueLocked(),
+			Mode:           key.attributesLocked().Mode,
+			Exportability:  key.attributesLocked().Exportability,
+			CurrentVersion: key.CurrentVersion,
+			CreatedAt:      key.CreatedAt,
+			LastRotatedAt:  key.LastRotatedAt,
+			Usage:          make(map[string]Counters),
+		}
+		for _, v := range key.Versions {
+			ks.Versions = append(ks.Versions, VersionState{Version: v.Version, CreatedAt: v.CreatedAt})
+		}
+		key.mu.RUnlock()
+		sort.Slice(ks.Versions, func(i, j int) bool { return ks.Versions[i].Version < ks.Versions[j].Version })
+		state.Keys = append(state.Keys, ks)
+	}
+	h.mu.RUnlock()
+	sort.Slice(state.Keys, func(i, j int) bool { return state.Keys[i].KeyID < state.Keys[j].KeyID })
+
+	usage := make(map[string]map[string]Counters, len(state.Keys))
+	for _, ks := range state.Keys {
+		usage[ks.KeyID] = ks.Usage
+	}
+	for _, entry := range h.GetAuditLog() {
+		count(state.Counters, entry)
+		if perKey, ok := usage[entry.KeyID]; ok {
+			count(perKey, entry)
+		}
+	}
+
+	state.Operations = h.Operations()
+	return state
+}
+
+// DumpStateJSON returns DumpState as indented JSON
+func (h *HSM) DumpStateJSON() ([]byte, error) {
+	return json.MarshalIndent(h.DumpState(), "", "  ")
+}
+
+func count(counters map[string]Counters, entry AuditEntry) {
+	c := counters[entry.Operation]
+	if entry.Success {
+		c.Success++
+	} else {
+		c.Failure++
+	}
+	counters[entry.Operation] = c
+}
@@ -0,0 +1,54 @@
+package hsm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDumpState(t *testing.T) {
+	h := NewHSM(WithDualControl(time.Hour))
+	h.GenerateKey("b-key", "AES-256-GCM")
+	h.GenerateKey("a-key", "AES-256-GCM")
+	h.RotateKey("a-key")
+	h.Encrypt("a-key", []byte("data"), nil)
+	h.Decrypt("a-key", []byte("not a ciphertext"), make([]byte, 12), nil, 1)
+	h.DestroyKeyVersion("alice", "a-key", 1)
+
+	state := h.DumpState()
+	if len(state.Keys) != 2 || state.Keys[0].KeyID != "a-key" || state.Keys[0].CurrentVersion != 2 || len(state.Keys[0].Versions) != 2 {
+		t.Fatalf("Keys = %+v", state.Keys)
+	}
+	if usage := state.Keys[0].Usage; usage["Encrypt"].Success != 1 || usage["Decrypt"].Failure != 1 {
+		t.Errorf("a-key usage = %+v", usage)
+	}
+	if state.Counters["GenerateKey"].Success != 2 {
+		t.Errorf("Counters = %+v", state.Counters)
+	}
+	if !state.Policies.DualControl || len(state.Operations) != 1 || state.Operations[0].State != StatePending {
+		t.Errorf("Policies = %+v, Operations = %+v", state.Policies, state.Operations)
+	}
+
+	out, err := h.DumpStateJSON()
+	if err != nil {
+		t.Fatalf("DumpStateJSON() error = %v", err)
+	}
+	var decoded State
+	if err := json.Unmarshal(out, &decoded); err != nil || len(decoded.Keys) != 2 {
+		t.Fatalf("DumpStateJSON() does not round-trip: %v", err)
+	}
+
+	// No key material in any encoding
+	for _, key := range h.keys {
+		for _, v := range key.Versions {
+			for _, enc := range []string{hex.EncodeToString(v.KeyData), base64.StdEncoding.EncodeToString(v.KeyData)} {
+				if bytes.Contains(out, []byte(enc)) {
+					t.Errorf("state dump contains key material of %s v%d", key.ID, v.Version)
+				}
+			}
+		}
+	}
+}
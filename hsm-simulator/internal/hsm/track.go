@@ -0,0 +1,218 @@
+package hsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var ErrInvalidTrackData = errors.New("invalid magnetic stripe track data")
+
+// Track lengths including sentinels and LRC, per ISO/IEC 7813
+const (
+	maxTrack1Bytes = 79
+	maxTrack2Bytes = 40
+)
+
+// MaxTrackBytes is the longest track EncryptTrackData accepts
+const MaxTrackBytes = maxTrack1Bytes
+
+// TrackFields are the fields of a magnetic stripe track that may be stored
+// after authorisation. The discretionary data, which carries the CVV and
+// PIN verification values, is deliberately not one of them.
+type TrackFields struct {
+	PAN            string `json:"pan"`
+	CardholderName string `json:"cardholder_name,omitempty"`
+	ExpiryYear     int    `json:"expiry_year"`
+	ExpiryMonth    int    `json:"expiry_month"`
+	ServiceCode    string `json:"service_code"`
+}
+
+// ParsedTrack describes a track without its sensitive data: the PAN is
+// reduced to its BIN and last four digits
+type ParsedTrack struct {
+	Track          int
+	BIN            string
+	LastFour       string
+	CardholderName string
+	ExpiryYear     int
+	ExpiryMonth    int
+	ServiceCode    string
+	// DiscretionaryBytes is the length of the discretionary data that was
+	// discarded
+	DiscretionaryBytes int
+}
+
+// TrackResult is a parsed track and its storable fields, encrypted
+type TrackResult struct {
+	Parsed ParsedTrack
+	// Envelope is a ciphertext envelope of the TrackFields as JSON
+	Envelope   []byte
+	KeyVersion int
+}
+
+// EncryptTrackData parses track 1 or track 2 data, as read from a magnetic
+// stripe, and encrypts the fields that may be stored into a ciphertext
+// envelope. The discretionary data never leaves the HSM in any form, so
+// nothing the caller stores after authorisation can contain it; it is also
+// wiped from track, which the caller should zeroize entirely once done.
+func (h *HSM) EncryptTrackData(keyID string, track, aad []byte) (TrackResult, error) {
+	fields, parsed, err := parseTrack(track)
+	if err != nil {
+		h.logAudit("EncryptTrackData", keyID, 0, false, err.Error())
+		return TrackResult{}, err
+	}
+
+	plaintext, err := json.Marshal(fields)
+	if err != nil {
+		return TrackResult{}, err
+	}
+	defer securebytes.Zero(plaintext)
+	envelope, version, err := h.EncryptEnvelope(keyID, plaintext, aad)
+	if err != nil {
+		h.logAudit("EncryptTrackData", keyID, 0, false, err.Error())
+		return TrackResult{}, err
+	}
+
+	h.auditMu.Lock()
+	h.auditLog = append(h.auditLog, AuditEntry{
+		Timestamp: time.Now(),
+		Operation: "EncryptTrackData",
+		KeyID:     keyID,
+		Version:   version,
+		Success:   true,
+		Detail:    fmt.Sprintf("track %d: %d bytes of discretionary data discarded", parsed.Track, parsed.DiscretionaryBytes),
+	})
+	h.auditMu.Unlock()
+	return TrackResult{Parsed: parsed, Envelope: envelope, KeyVersion: version}, nil
+}
+
+// DecryptTrackData opens an envelope from EncryptTrackData
+func (h *HSM) DecryptTrackData(envelope, aad []byte) (TrackFields, error) {
+	plaintext, _, _, err := h.DecryptEnvelope(envelope, aad)
+	if err != nil {
+		return TrackFields{}, err
+	}
+	defer securebytes.Zero(plaintext)
+	var fields TrackFields
+	if err := json.Unmarshal(plaintext, &fields); err != nil || fields.PAN == "" {
+		return TrackFields{}, fmt.Errorf("%w: envelope does not hold track fields", ErrInvalidTrackData)
+	}
+	return fields, nil
+}
+
+// parseTrack splits a track into its storable fields and description,
+// zeroizing the discretionary data in track. Track 1 is
+// %B<PAN>^<name>^<YYMM><service code><discretionary>?<LRC> and track 2
+// ;<PAN>=<YYMM><service code><discretionary>?<LRC>, with the sentinels and
+// LRC optional and D accepted for = as in EMV track 2 equivalent data.
+func parseTrack(track []byte) (TrackFields, ParsedTrack, error) {
+	data := track
+	if end := bytes.IndexByte(data, '?'); end >= 0 {
+		data = data[:end]
+	}
+
+	var (
+		fields TrackFields
+		parsed ParsedTrack
+		pan    []byte
+		rest   []byte
+	)
+	switch {
+	case len(data) > 0 && (data[0] == '%' || data[0] == 'B'):
+		if len(track) > maxTrack1Bytes {
+			return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: track 1 over %d bytes", ErrInvalidTrackData, maxTrack1Bytes)
+		}
+		parts := bytes.SplitN(bytes.TrimPrefix(bytes.TrimPrefix(data, []byte("%")), []byte("B")), []byte("^"), 3)
+		if len(parts) != 3 {
+			return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: track 1 needs PAN, name and data fields", ErrInvalidTrackData)
+		}
+		pan, rest = parts[0], parts[2]
+		name := bytes.TrimSpace(parts[1])
+		if len(name) > 26 || !printable(name) {
+			return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: cardholder name", ErrInvalidTrackData)
+		}
+		fields.CardholderName = string(name)
+		parsed.Track = 1
+	case len(data) > 0 && (data[0] == ';' || isDigit(data[0])):
+		if len(track) > maxTrack2Bytes {
+			return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: track 2 over %d bytes", ErrInvalidTrackData, maxTrack2Bytes)
+		}
+		data = bytes.TrimPrefix(data, []byte(";"))
+		sep := bytes.IndexAny(data, "=D")
+		if sep < 0 {
+			return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: track 2 needs a field separator", ErrInvalidTrackData)
+		}
+		pan, rest = data[:sep], data[sep+1:]
+		parsed.Track = 2
+	default:
+		return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: not track 1 or track 2", ErrInvalidTrackData)
+	}
+
+	if len(pan) < 12 || len(pan) > 19 || !allDigits(pan) || !luhn(pan) {
+		return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: PAN", ErrInvalidTrackData)
+	}
+	if len(rest) < 7 || !allDigits(rest[:7]) {
+		return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: expiry date and service code", ErrInvalidTrackData)
+	}
+	yy, _ := strconv.Atoi(string(rest[0:2]))
+	mm, _ := strconv.Atoi(string(rest[2:4]))
+	if mm < 1 || mm > 12 {
+		return TrackFields{}, ParsedTrack{}, fmt.Errorf("%w: expiry month %d", ErrInvalidTrackData, mm)
+	}
+
+	fields.PAN = string(pan)
+	fields.ExpiryYear, fields.ExpiryMonth = 2000+yy, mm
+	fields.ServiceCode = string(rest[4:7])
+	discretionary := rest[7:]
+	parsed.BIN, parsed.LastFour = string(pan[:6]), string(pan[len(pan)-4:])
+	parsed.CardholderName = fields.CardholderName
+	parsed.ExpiryYear, parsed.ExpiryMonth = fields.ExpiryYear, fields.ExpiryMonth
+	parsed.ServiceCode = fields.ServiceCode
+	parsed.DiscretionaryBytes = len(discretionary)
+	securebytes.Zero(discretionary)
+	return fields, parsed, nil
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func allDigits(b []byte) bool {
+	for _, c := range b {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// printable reports whether b holds only track 1 alphanumeric characters
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x5F || c == '^' || c == '%' || c == '?' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhn checks the mod 10 check digit of a PAN
+func luhn(pan []byte) bool {
+	sum := 0
+	for i := len(pan) - 1; i >= 0; i-- {
+		d := int(pan[i] - '0')
+		if (len(pan)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
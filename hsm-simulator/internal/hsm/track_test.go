@@ -0,0 +1,76 @@
+package hsm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptTrackData(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("track", "AES-256-GCM")
+
+	tests := []struct {
+		name   string
+		track  string
+		want   ParsedTrack
+		fields TrackFields
+	}{
+		{
+			"track 1",
+			"%B4111111111111111^DOE/JANE^3012101123456789000000?",
+			ParsedTrack{Track: 1, BIN: "411111", LastFour: "1111", CardholderName: "DOE/JANE", ExpiryYear: 2030, ExpiryMonth: 12, ServiceCode: "101", DiscretionaryBytes: 15},
+			TrackFields{PAN: "4111111111111111", CardholderName: "DOE/JANE", ExpiryYear: 2030, ExpiryMonth: 12, ServiceCode: "101"},
+		},
+		{
+			"track 2",
+			";5425233430109903=28062011234567?",
+			ParsedTrack{Track: 2, BIN: "542523", LastFour: "9903", ExpiryYear: 2028, ExpiryMonth: 6, ServiceCode: "201", DiscretionaryBytes: 7},
+			TrackFields{PAN: "5425233430109903", ExpiryYear: 2028, ExpiryMonth: 6, ServiceCode: "201"},
+		},
+		{
+			"track 2 equivalent data",
+			"5425233430109903D2806201123",
+			ParsedTrack{Track: 2, BIN: "542523", LastFour: "9903", ExpiryYear: 2028, ExpiryMonth: 6, ServiceCode: "201", DiscretionaryBytes: 3},
+			TrackFields{PAN: "5425233430109903", ExpiryYear: 2028, ExpiryMonth: 6, ServiceCode: "201"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := []byte(tt.track)
+			res, err := h.EncryptTrackData("track", track, []byte("terminal-1"))
+			if err != nil {
+				t.Fatalf("EncryptTrackData() error = %v", err)
+			}
+			if res.Parsed != tt.want {
+				t.Errorf("Parsed = %+v, want %+v", res.Parsed, tt.want)
+			}
+			if bytes.Contains(track, []byte("123")) {
+				t.Errorf("discretionary data left in the caller's buffer: %q", track)
+			}
+
+			fields, err := h.DecryptTrackData(res.Envelope, []byte("terminal-1"))
+			if err != nil || fields != tt.fields {
+				t.Errorf("DecryptTrackData() = %+v, %v; want %+v", fields, err, tt.fields)
+			}
+		})
+	}
+}
+
+func TestEncryptTrackDataRejectsMalformedTracks(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKey("track", "AES-256-GCM")
+	for _, track := range []string{
+		"",
+		"%B4111111111111112^DOE/JANE^3012101?", // Luhn
+		"%B4111111111111111^DOE/JANE?",         // missing data field
+		";4111111111111111=3013101?",           // month 13
+		";4111111111111111=30?",                // short
+		"4111111111111111",                     // no separator
+		";4111111111111111=301210112345678901234567?", // over 40 bytes
+	} {
+		if _, err := h.EncryptTrackData("track", []byte(track), nil); !errors.Is(err, ErrInvalidTrackData) {
+			t.Errorf("EncryptTrackData(%q) error = %v, want ErrInvalidTrackData", track, err)
+		}
+	}
+}
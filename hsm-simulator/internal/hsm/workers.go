@@ -0,0 +1,168 @@
+package hsm
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+// Lane is the priority class of a call waiting for an HSM worker. Live
+// payments must not queue behind bulk jobs, so when every worker is busy a
+// freed worker goes to the oldest call of the highest lane waiting.
+type Lane int
+
+const (
+	// LaneAuthorization is authorization-time traffic, e.g. detokenizing or
+	// verifying a PIN for a payment in flight
+	LaneAuthorization Lane = iota
+	// LaneStandard is traffic that is not tagged
+	LaneStandard
+	// LaneBatch is bulk traffic such as settlement files and re-encryption
+	LaneBatch
+)
+
+var laneNames = []string{"authorization", "standard", "batch"}
+
+func (l Lane) String() string {
+	if l < 0 || int(l) >= len(laneNames) {
+		return "unknown"
+	}
+	return laneNames[l]
+}
+
+// ParseLane reads a lane name; "" is LaneStandard
+func ParseLane(s string) (Lane, error) {
+	if s == "" {
+		return LaneStandard, nil
+	}
+	for i, name := range laneNames {
+		if s == name {
+			return Lane(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q: want authorization, standard or batch", s)
+}
+
+// WorkerPool bounds the operations the HSM runs at once, as a device's
+// command processors do. Calls beyond the bound wait in their lane.
+type WorkerPool struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	busy    int
+	waiting [3]*list.List // of *worker waiters, per lane
+
+	depth     *metrics.GaugeVec
+	wait      *metrics.HistogramVec
+	abandoned *metrics.CounterVec
+	busyGauge *metrics.Gauge
+}
+
+// waiter is a call waiting for a worker; granted is closed when it gets one
+type waiter struct {
+	granted chan struct{}
+	elem    *list.Element
+}
+
+// NewWorkerPool creates a pool of size workers. registry receives the queue
+// depth and wait time per lane and may be nil.
+func NewWorkerPool(size int, registry *metrics.Registry) *WorkerPool {
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	p := &WorkerPool{
+		size: size,
+		now:  time.Now,
+		depth: registry.Gauge("hsm_queue_depth",
+			"Calls waiting for an HSM worker", "lane"),
+		wait: registry.Histogram("hsm_queue_wait_seconds",
+			"Time calls waited for an HSM worker", nil, "lane"),
+		abandoned: registry.Counter("hsm_queue_abandoned_total",
+			"Calls that gave up waiting for an HSM worker", "lane"),
+		busyGauge: registry.Gauge("hsm_workers_busy",
+			"HSM workers running an operation").With(),
+	}
+	registry.GaugeFunc("hsm_workers", "HSM workers in the pool", func() float64 { return float64(size) })
+	for i := range p.waiting {
+		p.waiting[i] = list.New()
+		p.depth.With(Lane(i).String()).Set(0)
+	}
+	return p
+}
+
+// Size returns the number of workers
+func (p *WorkerPool) Size() int { return p.size }
+
+// Acquire waits for a worker for a call in lane until ctx is done. The
+// caller must call release once its operation has finished.
+func (p *WorkerPool) Acquire(ctx context.Context, lane Lane) (release func(), err error) {
+	if lane < LaneAuthorization || lane > LaneBatch {
+		lane = LaneStandard
+	}
+	start := p.now()
+	p.mu.Lock()
+	if p.busy < p.size {
+		p.busy++
+		p.busyGauge.Set(float64(p.busy))
+		p.mu.Unlock()
+		p.wait.With(lane.String()).Observe(0)
+		return p.release, nil
+	}
+	w := &waiter{granted: make(chan struct{})}
+	w.elem = p.waiting[lane].PushBack(w)
+	p.depth.With(lane.String()).Inc()
+	p.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		p.wait.With(lane.String()).Observe(p.now().Sub(start).Seconds())
+		return p.release, nil
+	case <-ctx.Done():
+	}
+	p.mu.Lock()
+	select {
+	case <-w.granted:
+		// The worker was handed over as the caller gave up; pass it on
+		p.mu.Unlock()
+		p.release()
+	default:
+		p.waiting[lane].Remove(w.elem)
+		p.depth.With(lane.String()).Dec()
+		p.mu.Unlock()
+	}
+	p.abandoned.With(lane.String()).Inc()
+	return nil, ctx.Err()
+}
+
+// release hands the worker to the first waiter of the highest lane, or
+// frees it
+func (p *WorkerPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for lane, queue := range p.waiting {
+		if front := queue.Front(); front != nil {
+			queue.Remove(front)
+			p.depth.With(Lane(lane).String()).Dec()
+			close(front.Value.(*waiter).granted)
+			return
+		}
+	}
+	p.busy--
+	p.busyGauge.Set(float64(p.busy))
+}
+
+// Waiting returns the number of calls waiting in each lane
+func (p *WorkerPool) Waiting() map[Lane]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiting := make(map[Lane]int, len(p.waiting))
+	for lane, queue := range p.waiting {
+		waiting[Lane(lane)] = queue.Len()
+	}
+	return waiting
+}
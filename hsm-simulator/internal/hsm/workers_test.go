@@ -0,0 +1,104 @@
+package hsm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+// waitQueued waits until the pool has n calls waiting in lane
+func waitQueued(t *testing.T, p *WorkerPool, lane Lane, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Waiting()[lane] != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d calls waiting in lane %s, want %d", p.Waiting()[lane], lane, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerPoolLanes(t *testing.T) {
+	registry := metrics.NewRegistry()
+	p := NewWorkerPool(1, registry)
+	release, err := p.Acquire(context.Background(), LaneBatch)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	// With the only worker busy, bulk calls queue first, then a payment
+	// arrives; the payment is served before them
+	served := make(chan Lane, 4)
+	enqueue := func(lane Lane) {
+		go func() {
+			release, err := p.Acquire(context.Background(), lane)
+			if err != nil {
+				t.Errorf("Acquire(%s) error = %v", lane, err)
+				return
+			}
+			served <- lane
+			release()
+		}()
+	}
+	for _, call := range []struct {
+		lane   Lane
+		queued int
+	}{{LaneBatch, 1}, {LaneBatch, 2}, {LaneStandard, 1}, {LaneAuthorization, 1}} {
+		enqueue(call.lane)
+		waitQueued(t, p, call.lane, call.queued)
+	}
+
+	var b strings.Builder
+	registry.WriteText(&b)
+	if !strings.Contains(b.String(), `hsm_queue_depth{lane="batch"} 2`) {
+		t.Errorf("metrics lack the batch queue depth:\n%s", b.String())
+	}
+
+	release()
+	want := []Lane{LaneAuthorization, LaneStandard, LaneBatch, LaneBatch}
+	for i, lane := range want {
+		if got := <-served; got != lane {
+			t.Errorf("call %d served from lane %s, want %s", i, got, lane)
+		}
+	}
+	if n := p.wait.With("authorization").Count(); n != 1 {
+		t.Errorf("authorization waits observed %d, want 1", n)
+	}
+}
+
+func TestWorkerPoolAbandon(t *testing.T) {
+	p := NewWorkerPool(1, nil)
+	release, _ := p.Acquire(context.Background(), LaneStandard)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(ctx, LaneBatch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() with all workers busy error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if p.Waiting()[LaneBatch] != 0 || p.abandoned.With("batch").Value() != 1 {
+		t.Errorf("abandoned call left %d waiting, counted %v", p.Waiting()[LaneBatch], p.abandoned.With("batch").Value())
+	}
+
+	// The worker is free again once released
+	release()
+	if release, err := p.Acquire(context.Background(), LaneBatch); err != nil {
+		t.Errorf("Acquire() after release error = %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestParseLane(t *testing.T) {
+	for s, want := range map[string]Lane{"": LaneStandard, "authorization": LaneAuthorization, "batch": LaneBatch} {
+		if got, err := ParseLane(s); err != nil || got != want {
+			t.Errorf("ParseLane(%q) = %s, %v, want %s", s, got, err, want)
+		}
+	}
+	if _, err := ParseLane("urgent"); err == nil {
+		t.Error("ParseLane() accepted an unknown priority")
+	}
+}
@@ -0,0 +1,375 @@
+package hsm
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrExchangeNotFound      = errors.New("ZMK exchange not found")
+	ErrExchangeClosed        = errors.New("ZMK exchange is closed")
+	ErrWrongExchangeMethod   = errors.New("step does not belong to the ZMK exchange's method")
+	ErrInvalidComponentCount = errors.New("a ZMK is formed from 2 to 9 components")
+	ErrDuplicateCustodian    = errors.New("each component must be entered by a different custodian")
+	ErrInvalidWrappedKey     = errors.New("wrapped key does not decrypt under the exchange key")
+	ErrInvalidKCV            = errors.New("key check value must be 6 hex digits")
+)
+
+// ZMKExchangeTTL is how long a ZMK exchange stays open for its components
+// or wrapped key
+const ZMKExchangeTTL = time.Hour
+
+// ZMKRSABits is the size of the RSA key a wrapped ZMK is sent under
+const ZMKRSABits = 2048
+
+// ZMKMethod is how the other party conveys a ZMK
+type ZMKMethod string
+
+const (
+	// ZMKComponents: the ZMK is the XOR of clear components, each carried
+	// by a different custodian and entered by them alone
+	ZMKComponents ZMKMethod = "components"
+	// ZMKWrappedRSA: the other party encrypts the ZMK with RSA-OAEP
+	// (SHA-256) under a public key the HSM generated for the exchange
+	ZMKWrappedRSA ZMKMethod = "rsa-oaep"
+)
+
+// ParseZMKMethod parses a ZMK exchange method
+func ParseZMKMethod(s string) (ZMKMethod, error) {
+	switch m := ZMKMethod(strings.ToLower(s)); m {
+	case ZMKComponents, ZMKWrappedRSA:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: unsupported ZMK exchange method %q, want components or rsa-oaep", ErrWrongExchangeMethod, s)
+}
+
+// ExchangeState is where a ZMK exchange is
+type ExchangeState string
+
+const (
+	ExchangePending     ExchangeState = "pending"
+	ExchangeEstablished ExchangeState = "established"
+	ExchangeFailed      ExchangeState = "failed"
+	ExchangeCancelled   ExchangeState = "cancelled"
+	ExchangeExpired     ExchangeState = "expired"
+)
+
+// ZMKExchange is the establishment of a ZMK shared with another party, such
+// as an acquirer. An admin begins it with the KCV the other party announced
+// for the ZMK; the ZMK is stored only if it arrives with that KCV, after
+// which working keys are exchanged under it with ExportKey and
+// ImportKeyBlock. Neither components nor the ZMK ever appear in it.
+type ZMKExchange struct {
+	ID        string    `json:"id"`
+	KeyID     string    `json:"key_id"`
+	Method    ZMKMethod `json:"method"`
+	Requester string    `json:"requester"`
+	// Components is how many components form the ZMK, and Custodians who
+	// entered theirs so far, in order
+	Components int      `json:"components,omitempty"`
+	Custodians []string `json:"custodians,omitempty"`
+	// PublicKey is the RSA key (DER, PKIX) to wrap the ZMK under
+	PublicKey []byte        `json:"public_key,omitempty"`
+	KCV       string        `json:"kcv"`
+	State     ExchangeState `json:"state"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	ClosedAt  time.Time     `json:"closed_at,omitempty"`
+
+	// sum is the XOR of the components entered so far, and rsaKey the
+	// private half of PublicKey; both are dropped when the exchange closes
+	sum    []byte
+	rsaKey *rsa.PrivateKey
+}
+
+// BeginZMKExchange opens the establishment of a ZMK under keyID, to be
+// formed from components clear custodians enter with EnterZMKComponent, or
+// wrapped under the returned exchange's public key and imported with
+// ImportWrappedZMK. kcv is the check value the other party announced for
+// the whole ZMK.
+func (h *HSM) BeginZMKExchange(requester, keyID string, method ZMKMethod, components int, kcv string) (*ZMKExchange, error) {
+	h.simulate("BeginZMKExchange")
+	ex, err := h.beginZMKExchange(requester, keyID, method, components, kcv)
+	if err != nil {
+		h.logAuditBy("BeginZMKExchange", keyID, 0, err, requester, "")
+		return nil, err
+	}
+	h.logAuditBy("BeginZMKExchange", keyID, 0, nil, requester, "")
+	return ex, nil
+}
+
+func (h *HSM) beginZMKExchange(requester, keyID string, method ZMKMethod, components int, kcv string) (*ZMKExchange, error) {
+	if requester == "" {
+		return nil, ErrMissingOperator
+	}
+	if err := h.checkWritable(); err != nil {
+		return nil, err
+	}
+	if keyID == "" {
+		return nil, ErrInvalidKeyID
+	}
+	if !validKCV(kcv) {
+		return nil, ErrInvalidKCV
+	}
+	ex := &ZMKExchange{
+		KeyID:     keyID,
+		Method:    method,
+		Requester: requester,
+		KCV:       strings.ToUpper(kcv),
+		State:     ExchangePending,
+	}
+	switch method {
+	case ZMKComponents:
+		if components < 2 || components > 9 {
+			return nil, ErrInvalidComponentCount
+		}
+		ex.Components = components
+		ex.sum = make([]byte, 32)
+	case ZMKWrappedRSA:
+		key, err := rsa.GenerateKey(rand.Reader, ZMKRSABits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate exchange key: %w", err)
+		}
+		if ex.PublicKey, err = x509.MarshalPKIXPublicKey(&key.PublicKey); err != nil {
+			return nil, fmt.Errorf("failed to encode exchange key: %w", err)
+		}
+		ex.rsaKey = key
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrWrongExchangeMethod, method)
+	}
+	id, err := operationID()
+	if err != nil {
+		return nil, err
+	}
+	ex.ID = "zmk-" + strings.TrimPrefix(id, "op-")
+	ex.CreatedAt = h.now()
+	ex.ExpiresAt = ex.CreatedAt.Add(ZMKExchangeTTL)
+
+	h.zmkMu.Lock()
+	defer h.zmkMu.Unlock()
+	h.mu.RLock()
+	_, exists := h.keys[keyID]
+	h.mu.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("%w: %s", ErrKeyExists, keyID)
+	}
+	for _, other := range h.zmkExchanges {
+		if other.KeyID == keyID && !h.expireExchangeLocked(other) && other.State == ExchangePending {
+			return nil, fmt.Errorf("%w: %s is being established by %s", ErrKeyExists, keyID, other.ID)
+		}
+	}
+	h.zmkExchanges[ex.ID] = ex
+	return ex.snapshot(), nil
+}
+
+// EnterZMKComponent enters one clear component of a ZMK (32 bytes) on
+// behalf of its custodian, with the component's own check value when
+// given. Each custodian enters one component; the last one forms the ZMK,
+// which is stored if it has the exchange's KCV. The caller should zeroize
+// component.
+func (h *HSM) EnterZMKComponent(exchangeID, custodian string, component []byte, kcv string) (*ZMKExchange, error) {
+	h.simulate("EnterZMKComponent")
+	h.zmkMu.Lock()
+	defer h.zmkMu.Unlock()
+
+	ex, err := h.pendingExchangeLocked(exchangeID, ZMKComponents)
+	if err == nil {
+		err = checkComponent(ex, custodian, component, kcv)
+	}
+	if err != nil {
+		h.logAuditBy("EnterZMKComponent", exchangeKeyID(ex), 0, err, custodian, "")
+		return nil, err
+	}
+	for i := range ex.sum {
+		ex.sum[i] ^= component[i]
+	}
+	ex.Custodians = append(ex.Custodians, custodian)
+	h.logAuditBy("EnterZMKComponent", ex.KeyID, 0, nil, custodian, "")
+	if len(ex.Custodians) < ex.Components {
+		return ex.snapshot(), nil
+	}
+	err = h.establishZMKLocked(ex, ex.sum, custodian)
+	return ex.snapshot(), err
+}
+
+func checkComponent(ex *ZMKExchange, custodian string, component []byte, kcv string) error {
+	if custodian == "" {
+		return ErrMissingOperator
+	}
+	for _, c := range ex.Custodians {
+		if c == custodian {
+			return ErrDuplicateCustodian
+		}
+	}
+	if len(component) != 32 {
+		return ErrInvalidKeyMaterial
+	}
+	if kcv != "" && !strings.EqualFold(kcv, KeyCheckValue(component)) {
+		return fmt.Errorf("%w: of the component", ErrKCVMismatch)
+	}
+	return nil
+}
+
+// ImportWrappedZMK decrypts a ZMK the other party wrapped under the
+// exchange's public key and stores it if it has the exchange's KCV. Under
+// dual control the operator importing it must not be the one who began
+// the exchange.
+func (h *HSM) ImportWrappedZMK(exchangeID, operator string, wrappedKey []byte) (*ZMKExchange, error) {
+	h.simulate("ImportWrappedZMK")
+	h.zmkMu.Lock()
+	defer h.zmkMu.Unlock()
+
+	ex, err := h.pendingExchangeLocked(exchangeID, ZMKWrappedRSA)
+	if err == nil {
+		switch {
+		case operator == "":
+			err = ErrMissingOperator
+		case h.dualControl && operator == ex.Requester:
+			err = ErrSelfApproval
+		}
+	}
+	var zmk []byte
+	if err == nil {
+		if zmk, err = rsa.DecryptOAEP(sha256.New(), nil, ex.rsaKey, wrappedKey, nil); err != nil {
+			err = ErrInvalidWrappedKey
+		} else if len(zmk) != 32 {
+			err = ErrInvalidKeyMaterial
+		}
+	}
+	defer securebytes.Zero(zmk)
+	if err != nil {
+		h.logAuditBy("ImportWrappedZMK", exchangeKeyID(ex), 0, err, operator, "")
+		return nil, err
+	}
+	h.logAuditBy("ImportWrappedZMK", ex.KeyID, 0, nil, operator, "")
+	err = h.establishZMKLocked(ex, zmk, operator)
+	return ex.snapshot(), err
+}
+
+// CancelZMKExchange abandons a pending exchange, discarding what was
+// entered
+func (h *HSM) CancelZMKExchange(exchangeID, operator string) (*ZMKExchange, error) {
+	h.zmkMu.Lock()
+	defer h.zmkMu.Unlock()
+
+	ex, err := h.pendingExchangeLocked(exchangeID, "")
+	if err == nil && operator == "" {
+		err = ErrMissingOperator
+	}
+	if err != nil {
+		h.logAuditBy("CancelZMKExchange", exchangeKeyID(ex), 0, err, operator, "")
+		return nil, err
+	}
+	h.closeExchangeLocked(ex, ExchangeCancelled, "")
+	h.logAuditBy("CancelZMKExchange", ex.KeyID, 0, nil, operator, "")
+	return ex.snapshot(), nil
+}
+
+// ZMKExchanges lists ZMK exchanges, oldest first
+func (h *HSM) ZMKExchanges() []ZMKExchange {
+	h.zmkMu.Lock()
+	defer h.zmkMu.Unlock()
+
+	out := make([]ZMKExchange, 0, len(h.zmkExchanges))
+	for _, ex := range h.zmkExchanges {
+		h.expireExchangeLocked(ex)
+		out = append(out, *ex.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// establishZMKLocked checks the ZMK against the announced KCV and stores
+// it. A mismatch fails the exchange, since the components or wrapped key
+// that were used cannot be told apart; it starts again from the beginning.
+func (h *HSM) establishZMKLocked(ex *ZMKExchange, zmk []byte, operator string) error {
+	var err error
+	if kcv := KeyCheckValue(zmk); !securebytes.EqualString(kcv, ex.KCV) {
+		err = fmt.Errorf("%w: the ZMK has %s, %s was announced", ErrKCVMismatch, kcv, ex.KCV)
+	} else {
+		err = h.importKey(ex.KeyID, "AES-256-GCM", KeyTypeZMK, zmk)
+	}
+	if err != nil {
+		h.closeExchangeLocked(ex, ExchangeFailed, err.Error())
+		h.logAuditBy("EstablishZMK", ex.KeyID, 0, err, ex.Requester, operator)
+		return err
+	}
+	h.closeExchangeLocked(ex, ExchangeEstablished, "")
+	h.logAuditBy("EstablishZMK", ex.KeyID, 1, nil, ex.Requester, operator)
+	return nil
+}
+
+// pendingExchangeLocked looks up an exchange that is still open, for a
+// step of the method unless method is empty
+func (h *HSM) pendingExchangeLocked(id string, method ZMKMethod) (*ZMKExchange, error) {
+	ex, ok := h.zmkExchanges[id]
+	if !ok {
+		return nil, ErrExchangeNotFound
+	}
+	if h.expireExchangeLocked(ex) || ex.State != ExchangePending {
+		return ex, fmt.Errorf("%w: %s", ErrExchangeClosed, ex.State)
+	}
+	if method != "" && ex.Method != method {
+		return ex, fmt.Errorf("%w: %s is a %s exchange", ErrWrongExchangeMethod, id, ex.Method)
+	}
+	if err := h.checkWritable(); err != nil {
+		return ex, err
+	}
+	return ex, nil
+}
+
+// expireExchangeLocked closes a pending exchange past its window
+func (h *HSM) expireExchangeLocked(ex *ZMKExchange) bool {
+	if ex.State == ExchangePending && h.now().After(ex.ExpiresAt) {
+		h.closeExchangeLocked(ex, ExchangeExpired, "")
+		ex.ClosedAt = ex.ExpiresAt
+	}
+	return ex.State == ExchangeExpired
+}
+
+func (h *HSM) closeExchangeLocked(ex *ZMKExchange, state ExchangeState, reason string) {
+	ex.State = state
+	ex.Error = reason
+	ex.ClosedAt = h.now()
+	securebytes.Zero(ex.sum)
+	ex.sum = nil
+	ex.rsaKey = nil
+}
+
+// snapshot copies an exchange without its secrets
+func (ex *ZMKExchange) snapshot() *ZMKExchange {
+	c := *ex
+	c.Custodians = append([]string(nil), ex.Custodians...)
+	c.sum = nil
+	c.rsaKey = nil
+	return &c
+}
+
+func exchangeKeyID(ex *ZMKExchange) string {
+	if ex == nil {
+		return ""
+	}
+	return ex.KeyID
+}
+
+func validKCV(kcv string) bool {
+	if len(kcv) != 6 {
+		return false
+	}
+	for _, c := range strings.ToUpper(kcv) {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package hsm
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquirerZMK is the ZMK a simulated acquirer generated, split into n
+// components
+func acquirerZMK(t *testing.T, n int) (zmk []byte, components [][]byte) {
+	t.Helper()
+	zmk = make([]byte, 32)
+	rand.Read(zmk)
+	last := append([]byte(nil), zmk...)
+	for i := 0; i < n-1; i++ {
+		c := make([]byte, 32)
+		rand.Read(c)
+		for j := range last {
+			last[j] ^= c[j]
+		}
+		components = append(components, c)
+	}
+	return zmk, append(components, last)
+}
+
+func TestZMKFromComponents(t *testing.T) {
+	h := NewHSM()
+	zmk, components := acquirerZMK(t, 3)
+
+	ex, err := h.BeginZMKExchange("admin", "acquirer-zmk", ZMKComponents, 3, KeyCheckValue(zmk))
+	if err != nil || ex.State != ExchangePending {
+		t.Fatalf("BeginZMKExchange() = %+v, %v", ex, err)
+	}
+	if _, err := h.EnterZMKComponent(ex.ID, "alice", components[0], "000000"); !errors.Is(err, ErrKCVMismatch) {
+		t.Errorf("EnterZMKComponent() with a wrong component KCV error = %v", err)
+	}
+	if _, err := h.EnterZMKComponent(ex.ID, "alice", components[0], KeyCheckValue(components[0])); err != nil {
+		t.Fatalf("EnterZMKComponent() error = %v", err)
+	}
+	if _, err := h.EnterZMKComponent(ex.ID, "alice", components[1], ""); !errors.Is(err, ErrDuplicateCustodian) {
+		t.Errorf("EnterZMKComponent() by the same custodian error = %v", err)
+	}
+	h.EnterZMKComponent(ex.ID, "bob", components[1], "")
+	if _, err := h.GetKeyInfo("acquirer-zmk"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("the ZMK exists before its last component, error = %v", err)
+	}
+	ex, err = h.EnterZMKComponent(ex.ID, "carol", components[2], "")
+	if err != nil || ex.State != ExchangeEstablished || len(ex.Custodians) != 3 {
+		t.Fatalf("EnterZMKComponent() of the last component = %+v, %v", ex, err)
+	}
+
+	meta, err := h.GetKeyInfo("acquirer-zmk")
+	if err != nil || meta.Type != KeyTypeZMK || meta.KCV != KeyCheckValue(zmk) {
+		t.Fatalf("GetKeyInfo() = %+v, %v; want a ZMK with the acquirer's KCV", meta, err)
+	}
+	// Working keys are now exchanged under it
+	h.GenerateKeyOfType("zpk", "AES-256-GCM", KeyTypeZPK)
+	if _, _, err := h.ExportKey("zpk", "acquirer-zmk"); err != nil {
+		t.Errorf("ExportKey() under the established ZMK error = %v", err)
+	}
+	if _, err := h.EnterZMKComponent(ex.ID, "dave", components[0], ""); !errors.Is(err, ErrExchangeClosed) {
+		t.Errorf("EnterZMKComponent() after establishment error = %v", err)
+	}
+}
+
+func TestZMKComponentsFailOnKCVMismatch(t *testing.T) {
+	h := NewHSM()
+	_, components := acquirerZMK(t, 2)
+	ex, _ := h.BeginZMKExchange("admin", "zmk", ZMKComponents, 2, "ABCDEF")
+	h.EnterZMKComponent(ex.ID, "alice", components[0], "")
+	ex, err := h.EnterZMKComponent(ex.ID, "bob", components[1], "")
+	if !errors.Is(err, ErrKCVMismatch) || ex.State != ExchangeFailed {
+		t.Fatalf("EnterZMKComponent() = %+v, %v; want a failed exchange", ex, err)
+	}
+	if _, err := h.GetKeyInfo("zmk"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("a ZMK with the wrong KCV was stored, error = %v", err)
+	}
+	// The key ID is free for a new attempt
+	if _, err := h.BeginZMKExchange("admin", "zmk", ZMKComponents, 2, "ABCDEF"); err != nil {
+		t.Errorf("BeginZMKExchange() after a failure error = %v", err)
+	}
+}
+
+func TestZMKWrappedUnderRSA(t *testing.T) {
+	h := NewHSM(WithDualControl(time.Hour))
+	zmk, _ := acquirerZMK(t, 1)
+
+	ex, err := h.BeginZMKExchange("admin", "acquirer-zmk", ZMKWrappedRSA, 0, KeyCheckValue(zmk))
+	if err != nil || len(ex.PublicKey) == 0 {
+		t.Fatalf("BeginZMKExchange() = %+v, %v", ex, err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(ex.PublicKey)
+	if err != nil {
+		t.Fatalf("ParsePKIXPublicKey() error = %v", err)
+	}
+	wrapped, _ := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub.(*rsa.PublicKey), zmk, nil)
+
+	if _, err := h.ImportWrappedZMK(ex.ID, "admin", wrapped); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("ImportWrappedZMK() by the requester under dual control error = %v", err)
+	}
+	if _, err := h.ImportWrappedZMK(ex.ID, "officer", wrapped[1:]); !errors.Is(err, ErrInvalidWrappedKey) {
+		t.Errorf("ImportWrappedZMK() of a damaged key error = %v", err)
+	}
+	if _, err := h.EnterZMKComponent(ex.ID, "officer", zmk, ""); !errors.Is(err, ErrWrongExchangeMethod) {
+		t.Errorf("EnterZMKComponent() in an RSA exchange error = %v", err)
+	}
+	ex, err = h.ImportWrappedZMK(ex.ID, "officer", wrapped)
+	if err != nil || ex.State != ExchangeEstablished {
+		t.Fatalf("ImportWrappedZMK() = %+v, %v", ex, err)
+	}
+	if meta, _ := h.GetKeyInfo("acquirer-zmk"); meta == nil || meta.KCV != KeyCheckValue(zmk) {
+		t.Errorf("GetKeyInfo() = %+v; want the acquirer's ZMK", meta)
+	}
+}
+
+func TestZMKExchangeLifecycle(t *testing.T) {
+	h := NewHSM()
+	h.GenerateKeyOfType("taken", "AES-256-GCM", KeyTypeZMK)
+
+	if _, err := h.BeginZMKExchange("admin", "taken", ZMKComponents, 2, "ABCDEF"); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("BeginZMKExchange() of an existing key error = %v", err)
+	}
+	if _, err := h.BeginZMKExchange("admin", "zmk", ZMKComponents, 1, "ABCDEF"); !errors.Is(err, ErrInvalidComponentCount) {
+		t.Errorf("BeginZMKExchange() of 1 component error = %v", err)
+	}
+	if _, err := h.BeginZMKExchange("admin", "zmk", ZMKComponents, 2, ""); !errors.Is(err, ErrInvalidKCV) {
+		t.Errorf("BeginZMKExchange() without a KCV error = %v", err)
+	}
+
+	ex, _ := h.BeginZMKExchange("admin", "zmk", ZMKComponents, 2, "ABCDEF")
+	if _, err := h.BeginZMKExchange("admin", "zmk", ZMKComponents, 2, "ABCDEF"); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("BeginZMKExchange() of a key being established error = %v", err)
+	}
+	if ex, err := h.CancelZMKExchange(ex.ID, "admin"); err != nil || ex.State != ExchangeCancelled {
+		t.Errorf("CancelZMKExchange() = %+v, %v", ex, err)
+	}
+
+	ex, _ = h.BeginZMKExchange("admin", "zmk", ZMKComponents, 2, "ABCDEF")
+	h.now = func() time.Time { return time.Now().Add(ZMKExchangeTTL + time.Minute) }
+	if _, err := h.EnterZMKComponent(ex.ID, "alice", make([]byte, 32), ""); !errors.Is(err, ErrExchangeClosed) {
+		t.Errorf("EnterZMKComponent() after expiry error = %v", err)
+	}
+	states := map[ExchangeState]int{}
+	for _, ex := range h.ZMKExchanges() {
+		states[ex.State]++
+	}
+	if states[ExchangeCancelled] != 1 || states[ExchangeExpired] != 1 {
+		t.Errorf("ZMKExchanges() states = %v", states)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+)
+
+// serviceMethodPrefix precedes the command name in the full method names
+// of HSMService
+const serviceMethodPrefix = "/hsm.v1.HSMService/"
+
+// Authorize implements interceptors.Authorizer: an authenticated call to
+// the HSM service runs only if the permission matrix grants its command to
+// the caller. With authentication off, or for public methods, there is no
+// principal to check.
+func (s *Server) Authorize(ctx context.Context, fullMethod string) error {
+	command, ok := strings.CutPrefix(fullMethod, serviceMethodPrefix)
+	if !ok {
+		return nil
+	}
+	p := interceptors.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil
+	}
+	if err := s.hsm.Authorize(p.ID, p.Roles, command); err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+// GetPermissions returns the permission matrix and the commands a principal
+// may call: the one and roles in the request, or else the caller
+func (s *Server) GetPermissions(ctx context.Context, req *GetPermissionsRequest) (*GetPermissionsResponse, error) {
+	matrix := s.hsm.Permissions()
+	resp := &GetPermissionsResponse{PrincipalId: req.PrincipalId, Roles: req.Roles}
+	switch p := interceptors.PrincipalFromContext(ctx); {
+	case req.PrincipalId != "" || len(req.Roles) > 0:
+		resp.Commands = matrix.Effective(req.PrincipalId, req.Roles)
+	case p != nil:
+		resp.PrincipalId, resp.Roles = p.ID, p.Roles
+		resp.Commands = matrix.Effective(p.ID, p.Roles)
+	default:
+		// Authentication is off, so nothing is checked
+		resp.PrincipalId = operator(ctx)
+		resp.Commands = append([]string(nil), hsm.Commands...)
+	}
+
+	subjects := make([]string, 0, len(matrix))
+	for subject := range matrix {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	for _, subject := range subjects {
+		resp.Matrix = append(resp.Matrix, &PermissionGrant{Subject: subject, Commands: matrix[subject]})
+	}
+	return resp, nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PriorityHeader is the metadata key callers name their lane with:
+// "authorization", "standard" (the default) or "batch"
+const PriorityHeader = "x-hsm-priority"
+
+// Prioritize runs each HSM command on a worker of the pool, so that when
+// all workers are busy, calls tagged for authorization go ahead of standard
+// and batch ones. It belongs after authentication in the chain, so callers
+// turned away never hold a place in a queue.
+func Prioritize(pool *hsm.WorkerPool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, serviceMethodPrefix) {
+			return handler(ctx, req)
+		}
+		lane := hsm.LaneStandard
+		if values := metadata.ValueFromIncomingContext(ctx, PriorityHeader); len(values) > 0 {
+			var err error
+			if lane, err = hsm.ParseLane(values[0]); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		release, err := pool.Acquire(ctx, lane)
+		if err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
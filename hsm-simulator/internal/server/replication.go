@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+	"google.golang.org/grpc/metadata"
+)
+
+// RemoteSource pulls the replication log from a primary HSM over gRPC
+type RemoteSource struct {
+	client  HSMServiceClient
+	apiKey  string
+	timeout time.Duration
+}
+
+// NewRemoteSource creates a change source for a standby. apiKey
+// authenticates to the primary and needs the hsm.replication role; it may be
+// empty when the primary has authentication off.
+func NewRemoteSource(client HSMServiceClient, apiKey string) *RemoteSource {
+	return &RemoteSource{client: client, apiKey: apiKey, timeout: 5 * time.Second}
+}
+
+// Changes implements hsm.ChangeSource
+func (r *RemoteSource) Changes(since uint64) ([]hsm.Change, uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if r.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Replicate(ctx, &ReplicateRequest{Since: since})
+	if err != nil {
+		return nil, 0, fmt.Errorf("pull replication log: %w", err)
+	}
+	return fromReplicatedChanges(resp.Changes), resp.Head, nil
+}
+
+func toReplicatedChanges(changes []hsm.Change) []*ReplicatedChange {
+	out := make([]*ReplicatedChange, len(changes))
+	for i, ch := range changes {
+		out[i] = &ReplicatedChange{
+			Seq:           ch.Seq,
+			Time:          ch.Time.UnixNano(),
+			Kind:          string(ch.Kind),
+			KeyId:         ch.KeyID,
+			Algorithm:     ch.Algorithm,
+			KeyType:       string(ch.KeyType),
+			ModeOfUse:     string(ch.Attributes.Mode),
+			Exportability: string(ch.Attributes.Exportability),
+			KeyVersion:    int32(ch.Version),
+			CreatedAt:     ch.CreatedAt.UnixNano(),
+			WrappedKey:    ch.WrappedKey,
+			Nonce:         ch.Nonce,
+		}
+	}
+	return out
+}
+
+func fromReplicatedChanges(changes []*ReplicatedChange) []hsm.Change {
+	out := make([]hsm.Change, len(changes))
+	for i, ch := range changes {
+		out[i] = hsm.Change{
+			Seq:        ch.Seq,
+			Time:       time.Unix(0, ch.Time),
+			Kind:       hsm.ChangeKind(ch.Kind),
+			KeyID:      ch.KeyId,
+			Algorithm:  ch.Algorithm,
+			KeyType:    hsm.KeyType(ch.KeyType),
+			Attributes: hsm.KeyAttributes{Mode: hsm.KeyMode(ch.ModeOfUse), Exportability: hsm.Exportability(ch.Exportability)},
+			Version:    int(ch.KeyVersion),
+			CreatedAt:  time.Unix(0, ch.CreatedAt),
+			WrappedKey: ch.WrappedKey,
+			Nonce:      ch.Nonce,
+		}
+	}
+	return out
+}
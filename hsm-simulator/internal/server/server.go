@@ -0,0 +1,756 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/paymentgateway/go-common/buildinfo"
+	"github.com/paymentgateway/go-common/dukpt"
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/securebytes"
+	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdminRole may request and approve destructive key operations and dump
+// the HSM state under the default permissions
+const AdminRole = hsm.AdminRole
+
+// ReplicationRole may pull the replication log, i.e. is the standby of an
+// HA pair
+const ReplicationRole = hsm.ReplicationRole
+
+// Server implements the HSMService gRPC server
+type Server struct {
+	UnimplementedHSMServiceServer
+	hsm        *hsm.HSM
+	replicator *hsm.Replicator
+}
+
+// NewServer creates a new gRPC server backed by the given HSM. The
+// replicator is set when the HSM is the standby of an HA pair.
+func NewServer(h *hsm.HSM, replicator *hsm.Replicator) *Server {
+	return &Server{
+		hsm:        h,
+		replicator: replicator,
+	}
+}
+
+// GenerateKey generates a new cryptographic key
+func (s *Server) GenerateKey(ctx context.Context, req *GenerateKeyRequest) (*GenerateKeyResponse, error) {
+	keyType, err := hsm.ParseKeyType(req.KeyType)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	attrs, err := hsm.ParseKeyAttributes(keyType, req.ModeOfUse, req.Exportability)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	meta, err := s.hsm.GenerateKeyWithAttributes(req.KeyId, req.Algorithm, keyType, attrs)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &GenerateKeyResponse{
+		KeyId:         meta.KeyID,
+		Version:       int32(meta.CurrentVersion),
+		Algorithm:     meta.Algorithm,
+		CreatedAt:     meta.CreatedAt.Unix(),
+		KeyType:       string(meta.Type),
+		Kcv:           meta.KCV,
+		ModeOfUse:     string(meta.Attributes.Mode),
+		Exportability: string(meta.Attributes.Exportability),
+	}, nil
+}
+
+// Encrypt encrypts data with the current version of a key, optionally under
+// the caller's nonce or with a detached tag. The plaintext in the request is
+// zeroized once sealed.
+func (s *Server) Encrypt(ctx context.Context, req *EncryptRequest) (*EncryptResponse, error) {
+	defer securebytes.Zero(req.Plaintext)
+	opts := hsm.EncryptOptions{DetachedTag: req.DetachedTag}
+	if len(req.Nonce) > 0 {
+		opts.Nonce = req.Nonce
+	}
+	ciphertext, nonce, tag, version, err := s.hsm.EncryptWithOptions(req.KeyId, req.Plaintext, req.Aad, opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &EncryptResponse{
+		Ciphertext: ciphertext,
+		Nonce:      nonce,
+		KeyVersion: int32(version),
+		Tag:        tag,
+	}, nil
+}
+
+// GenerateDataKey generates a data key for envelope encryption
+func (s *Server) GenerateDataKey(ctx context.Context, req *GenerateDataKeyRequest) (*GenerateDataKeyResponse, error) {
+	plaintext, wrapped, nonce, version, err := s.hsm.GenerateDataKey(req.KeyId, req.Aad)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &GenerateDataKeyResponse{
+		PlaintextKey: plaintext,
+		WrappedKey:   wrapped,
+		Nonce:        nonce,
+		KeyVersion:   int32(version),
+	}, nil
+}
+
+// Decrypt decrypts data with a specific key version
+func (s *Server) Decrypt(ctx context.Context, req *DecryptRequest) (*DecryptResponse, error) {
+	var plaintext []byte
+	var err error
+	if len(req.Tag) > 0 {
+		plaintext, err = s.hsm.DecryptDetached(req.KeyId, req.Ciphertext, req.Tag, req.Nonce, req.Aad, int(req.KeyVersion))
+	} else {
+		plaintext, err = s.hsm.Decrypt(req.KeyId, req.Ciphertext, req.Nonce, req.Aad, int(req.KeyVersion))
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &DecryptResponse{
+		Plaintext: plaintext,
+	}, nil
+}
+
+// EncryptEnvelope encrypts data with the current version of a key into a
+// self-describing envelope, compressing it first if asked. The plaintext in
+// the request is zeroized once sealed.
+func (s *Server) EncryptEnvelope(ctx context.Context, req *EncryptEnvelopeRequest) (*EncryptEnvelopeResponse, error) {
+	defer securebytes.Zero(req.Plaintext)
+	opts := hsm.EnvelopeOptions{Compress: req.Compress}
+	envelope, version, compressed, err := s.hsm.EncryptEnvelopeWithOptions(req.KeyId, req.Plaintext, req.Aad, opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &EncryptEnvelopeResponse{
+		Envelope:   envelope,
+		KeyVersion: int32(version),
+		Compressed: compressed,
+	}, nil
+}
+
+// DecryptEnvelope decrypts an envelope with the key version it names
+func (s *Server) DecryptEnvelope(ctx context.Context, req *DecryptEnvelopeRequest) (*DecryptEnvelopeResponse, error) {
+	plaintext, keyID, version, err := s.hsm.DecryptEnvelope(req.Envelope, req.Aad)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &DecryptEnvelopeResponse{
+		Plaintext:  plaintext,
+		KeyId:      keyID,
+		KeyVersion: int32(version),
+	}, nil
+}
+
+// EncryptTrackData parses magnetic stripe track data and encrypts the
+// fields that may be stored after authorisation. The track in the request
+// is zeroized once parsed.
+func (s *Server) EncryptTrackData(ctx context.Context, req *EncryptTrackDataRequest) (*EncryptTrackDataResponse, error) {
+	defer securebytes.Zero(req.TrackData)
+	res, err := s.hsm.EncryptTrackData(req.KeyId, req.TrackData, req.Aad)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &EncryptTrackDataResponse{
+		Parsed:     parsedTrack(res.Parsed),
+		Envelope:   res.Envelope,
+		KeyVersion: int32(res.KeyVersion),
+	}, nil
+}
+
+func parsedTrack(p hsm.ParsedTrack) *ParsedTrack {
+	return &ParsedTrack{
+		Track:              int32(p.Track),
+		Bin:                p.BIN,
+		LastFour:           p.LastFour,
+		CardholderName:     p.CardholderName,
+		ExpiryYear:         int32(p.ExpiryYear),
+		ExpiryMonth:        int32(p.ExpiryMonth),
+		ServiceCode:        p.ServiceCode,
+		DiscretionaryBytes: int32(p.DiscretionaryBytes),
+	}
+}
+
+// DeriveIPEK derives the initial key for a P2PE terminal
+func (s *Server) DeriveIPEK(ctx context.Context, req *DeriveIPEKRequest) (*DeriveIPEKResponse, error) {
+	ksn, err := dukpt.KSNFromBytes(req.Ksn)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	ipek, kcv, err := s.hsm.DeriveIPEK(req.BdkId, ksn)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &DeriveIPEKResponse{
+		Ipek: ipek,
+		Kcv:  kcv,
+	}, nil
+}
+
+// DecryptP2PE decrypts and parses track data from a P2PE terminal
+func (s *Server) DecryptP2PE(ctx context.Context, req *DecryptP2PERequest) (*DecryptP2PEResponse, error) {
+	ksn, err := dukpt.KSNFromBytes(req.Ksn)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	fields, parsed, err := s.hsm.DecryptP2PE(req.BdkId, ksn, req.EncryptedTrack)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &DecryptP2PEResponse{
+		Fields: &TrackFields{
+			Pan:            fields.PAN,
+			CardholderName: fields.CardholderName,
+			ExpiryYear:     int32(fields.ExpiryYear),
+			ExpiryMonth:    int32(fields.ExpiryMonth),
+			ServiceCode:    fields.ServiceCode,
+		},
+		Parsed: parsedTrack(parsed),
+	}, nil
+}
+
+// EncryptPIN encrypts a clear PIN into a PIN block
+func (s *Server) EncryptPIN(ctx context.Context, req *EncryptPINRequest) (*EncryptPINResponse, error) {
+	pinBlock, err := s.hsm.EncryptPIN(req.ZpkId, req.Pin, req.Pan)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &EncryptPINResponse{PinBlock: pinBlock}, nil
+}
+
+// GeneratePINReference derives the offset or PVV of a PIN
+func (s *Server) GeneratePINReference(ctx context.Context, req *GeneratePINReferenceRequest) (*GeneratePINReferenceResponse, error) {
+	method, err := hsm.ParsePINMethod(req.Method)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	ref, err := s.hsm.GeneratePINReference(req.PvkId, req.ZpkId, req.PinBlock, req.Pan, method, int(req.Pvki))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &GeneratePINReferenceResponse{Reference: pinReferenceMessage(ref)}, nil
+}
+
+// VerifyPIN verifies a PIN block against an offset or PVV
+func (s *Server) VerifyPIN(ctx context.Context, req *VerifyPINRequest) (*VerifyPINResponse, error) {
+	ref, err := hsmPINReference(req.Reference)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	verified, err := s.hsm.VerifyPIN(req.PvkId, req.ZpkId, req.PinBlock, req.Pan, ref)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &VerifyPINResponse{Verified: verified}, nil
+}
+
+// ChangePIN replaces a PIN's offset or PVV after verifying the current PIN
+func (s *Server) ChangePIN(ctx context.Context, req *ChangePINRequest) (*ChangePINResponse, error) {
+	ref, err := hsmPINReference(req.Reference)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	newRef, err := s.hsm.ChangePIN(req.PvkId, req.ZpkId, req.OldPinBlock, req.NewPinBlock, req.Pan, ref)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ChangePINResponse{Reference: pinReferenceMessage(newRef)}, nil
+}
+
+// TranslatePIN re-encrypts a PIN block from one ZPK to another
+func (s *Server) TranslatePIN(ctx context.Context, req *TranslatePINRequest) (*TranslatePINResponse, error) {
+	pinBlock, err := s.hsm.TranslatePIN(req.SourceZpkId, req.DestZpkId, req.PinBlock, req.Pan)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &TranslatePINResponse{PinBlock: pinBlock}, nil
+}
+
+// GenerateCVV computes a card's CVV under a CVK
+func (s *Server) GenerateCVV(ctx context.Context, req *GenerateCVVRequest) (*GenerateCVVResponse, error) {
+	cvv, err := s.hsm.GenerateCVV(req.CvkId, req.Pan, req.Expiry, req.ServiceCode)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GenerateCVVResponse{Cvv: cvv}, nil
+}
+
+// VerifyCVV verifies a card's CVV under a CVK
+func (s *Server) VerifyCVV(ctx context.Context, req *VerifyCVVRequest) (*VerifyCVVResponse, error) {
+	verified, err := s.hsm.VerifyCVV(req.CvkId, req.Pan, req.Expiry, req.ServiceCode, req.Cvv)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &VerifyCVVResponse{Verified: verified}, nil
+}
+
+func hsmPINReference(m *PINReference) (hsm.PINReference, error) {
+	method, err := hsm.ParsePINMethod(m.GetMethod())
+	if err != nil {
+		return hsm.PINReference{}, err
+	}
+	return hsm.PINReference{Method: method, Value: m.GetValue(), PVKI: int(m.GetPvki())}, nil
+}
+
+func pinReferenceMessage(ref hsm.PINReference) *PINReference {
+	return &PINReference{Method: string(ref.Method), Value: ref.Value, Pvki: int32(ref.PVKI)}
+}
+
+// RotateKey creates a new version of a key
+func (s *Server) RotateKey(ctx context.Context, req *RotateKeyRequest) (*RotateKeyResponse, error) {
+	newVersion, oldVersion, err := s.hsm.RotateKey(req.KeyId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &RotateKeyResponse{
+		KeyId:      req.KeyId,
+		NewVersion: int32(newVersion),
+		OldVersion: int32(oldVersion),
+	}, nil
+}
+
+// GetKeyInfo returns key metadata without the key material
+func (s *Server) GetKeyInfo(ctx context.Context, req *GetKeyInfoRequest) (*GetKeyInfoResponse, error) {
+	meta, err := s.hsm.GetKeyInfo(req.KeyId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	versions := make([]int32, len(meta.AvailableVersions))
+	for i, v := range meta.AvailableVersions {
+		versions[i] = int32(v)
+	}
+
+	return &GetKeyInfoResponse{
+		KeyId:             meta.KeyID,
+		CurrentVersion:    int32(meta.CurrentVersion),
+		AvailableVersions: versions,
+		Algorithm:         meta.Algorithm,
+		CreatedAt:         meta.CreatedAt.Unix(),
+		LastRotatedAt:     meta.LastRotatedAt.Unix(),
+		KeyType:           string(meta.Type),
+		Kcv:               meta.KCV,
+		ModeOfUse:         string(meta.Attributes.Mode),
+		Exportability:     string(meta.Attributes.Exportability),
+	}, nil
+}
+
+// DestroyKeyVersion destroys a retired key version, or queues it for
+// approval under dual control
+func (s *Server) DestroyKeyVersion(ctx context.Context, req *DestroyKeyVersionRequest) (*DestroyKeyVersionResponse, error) {
+	op, err := s.hsm.DestroyKeyVersion(operator(ctx), req.KeyId, int(req.KeyVersion))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &DestroyKeyVersionResponse{Operation: toOperation(op)}, nil
+}
+
+// ImportKey imports key material as a new key, or queues the import for
+// approval under dual control. The material in the request is zeroized.
+func (s *Server) ImportKey(ctx context.Context, req *ImportKeyRequest) (*ImportKeyResponse, error) {
+	defer securebytes.Zero(req.KeyMaterial)
+	keyType, err := hsm.ParseKeyType(req.KeyType)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	op, err := s.hsm.ImportKeyOfType(operator(ctx), req.KeyId, req.Algorithm, keyType, req.KeyMaterial)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ImportKeyResponse{Operation: toOperation(op)}, nil
+}
+
+// BackupKeys returns every key version wrapped under the transport key
+func (s *Server) BackupKeys(ctx context.Context, req *BackupKeysRequest) (*BackupKeysResponse, error) {
+	backup, err := s.hsm.BackupKeys()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &BackupKeysResponse{Versions: toReplicatedChanges(backup)}, nil
+}
+
+// RestoreKeys reinstalls the key versions of a backup, or queues the
+// restore for approval under dual control
+func (s *Server) RestoreKeys(ctx context.Context, req *RestoreKeysRequest) (*RestoreKeysResponse, error) {
+	op, err := s.hsm.RestoreKeys(operator(ctx), fromReplicatedChanges(req.Versions))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &RestoreKeysResponse{Operation: toOperation(op)}, nil
+}
+
+// ExportKey exports a working key under a zone master key, optionally with
+// narrower attributes than its own
+func (s *Server) ExportKey(ctx context.Context, req *ExportKeyRequest) (*ExportKeyResponse, error) {
+	attrs := hsm.KeyAttributes{
+		Mode:          hsm.KeyMode(strings.ToUpper(req.ModeOfUse)),
+		Exportability: hsm.Exportability(strings.ToUpper(req.Exportability)),
+	}
+	keyBlock, kcv, err := s.hsm.ExportKeyWithAttributes(req.KeyId, req.ZmkId, attrs)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ExportKeyResponse{KeyBlock: keyBlock, Kcv: kcv}, nil
+}
+
+// ImportKeyBlock imports a working key received under a zone master key, or
+// queues the import for approval under dual control
+func (s *Server) ImportKeyBlock(ctx context.Context, req *ImportKeyBlockRequest) (*ImportKeyBlockResponse, error) {
+	op, err := s.hsm.ImportKeyBlock(operator(ctx), req.KeyId, req.ZmkId, req.KeyBlock, req.Kcv, req.Rotate)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &ImportKeyBlockResponse{Operation: toOperation(op)}
+	if op.State != hsm.StateExecuted {
+		return resp, nil
+	}
+	meta, err := s.hsm.GetKeyInfo(op.KeyID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp.KeyId = meta.KeyID
+	resp.Version = int32(op.Version)
+	resp.KeyType = string(meta.Type)
+	resp.Kcv = meta.KCV
+	resp.ModeOfUse = string(meta.Attributes.Mode)
+	resp.Exportability = string(meta.Attributes.Exportability)
+	return resp, nil
+}
+
+// BeginZMKExchange opens the establishment of a ZMK shared with another
+// party
+func (s *Server) BeginZMKExchange(ctx context.Context, req *BeginZMKExchangeRequest) (*ZMKExchangeResponse, error) {
+	method, err := hsm.ParseZMKMethod(req.Method)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	ex, err := s.hsm.BeginZMKExchange(operator(ctx), req.KeyId, method, int(req.Components), req.Kcv)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ZMKExchangeResponse{Exchange: toZMKExchange(ex)}, nil
+}
+
+// EnterZMKComponent enters a ZMK component with the caller as its
+// custodian. The component in the request is zeroized.
+func (s *Server) EnterZMKComponent(ctx context.Context, req *EnterZMKComponentRequest) (*ZMKExchangeResponse, error) {
+	defer securebytes.Zero(req.Component)
+	ex, err := s.hsm.EnterZMKComponent(req.ExchangeId, operator(ctx), req.Component, req.ComponentKcv)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ZMKExchangeResponse{Exchange: toZMKExchange(ex)}, nil
+}
+
+// ImportWrappedZMK imports a ZMK wrapped under the exchange's RSA key
+func (s *Server) ImportWrappedZMK(ctx context.Context, req *ImportWrappedZMKRequest) (*ZMKExchangeResponse, error) {
+	ex, err := s.hsm.ImportWrappedZMK(req.ExchangeId, operator(ctx), req.WrappedKey)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ZMKExchangeResponse{Exchange: toZMKExchange(ex)}, nil
+}
+
+// CancelZMKExchange discards a pending ZMK exchange
+func (s *Server) CancelZMKExchange(ctx context.Context, req *CancelZMKExchangeRequest) (*ZMKExchangeResponse, error) {
+	ex, err := s.hsm.CancelZMKExchange(req.ExchangeId, operator(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ZMKExchangeResponse{Exchange: toZMKExchange(ex)}, nil
+}
+
+// ListZMKExchanges lists ZMK exchanges, pending ones included
+func (s *Server) ListZMKExchanges(ctx context.Context, req *ListZMKExchangesRequest) (*ListZMKExchangesResponse, error) {
+	exchanges := s.hsm.ZMKExchanges()
+	resp := &ListZMKExchangesResponse{Exchanges: make([]*ZMKExchange, len(exchanges))}
+	for i := range exchanges {
+		resp.Exchanges[i] = toZMKExchange(&exchanges[i])
+	}
+	return resp, nil
+}
+
+// ListOperations lists destructive operations, pending ones included
+func (s *Server) ListOperations(ctx context.Context, req *ListOperationsRequest) (*ListOperationsResponse, error) {
+	ops := s.hsm.Operations()
+	resp := &ListOperationsResponse{Operations: make([]*Operation, len(ops))}
+	for i := range ops {
+		resp.Operations[i] = toOperation(&ops[i])
+	}
+	return resp, nil
+}
+
+// ApproveOperation executes a pending operation as the second operator
+func (s *Server) ApproveOperation(ctx context.Context, req *ApproveOperationRequest) (*ApproveOperationResponse, error) {
+	op, err := s.hsm.ApproveOperation(req.OperationId, operator(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ApproveOperationResponse{Operation: toOperation(op)}, nil
+}
+
+// RejectOperation discards a pending operation
+func (s *Server) RejectOperation(ctx context.Context, req *RejectOperationRequest) (*RejectOperationResponse, error) {
+	op, err := s.hsm.RejectOperation(req.OperationId, operator(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &RejectOperationResponse{Operation: toOperation(op)}, nil
+}
+
+// DumpState returns the HSM's key-free state as JSON
+func (s *Server) DumpState(ctx context.Context, req *DumpStateRequest) (*DumpStateResponse, error) {
+	state, err := s.hsm.DumpStateJSON()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &DumpStateResponse{StateJson: string(state)}, nil
+}
+
+// Replicate serves the replication log to a standby
+func (s *Server) Replicate(ctx context.Context, req *ReplicateRequest) (*ReplicateResponse, error) {
+	changes, head, err := s.hsm.Changes(req.Since)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ReplicateResponse{Head: head, Changes: toReplicatedChanges(changes)}, nil
+}
+
+// WatchKeys streams key rollover advice for the requested keys until the
+// client disconnects or falls behind
+func (s *Server) WatchKeys(req *WatchKeysRequest, stream HSMService_WatchKeysServer) error {
+	ctx := stream.Context()
+	watched := make(map[string]bool, len(req.KeyIds))
+	for _, id := range req.KeyIds {
+		watched[id] = true
+	}
+
+	// Subscribe before describing the keys, so no change falls in between
+	sub := s.hsm.SubscribeAdvice()
+	defer sub.Close()
+	for _, a := range s.hsm.CurrentAdvice(req.KeyIds...) {
+		if err := stream.Send(toKeyAdvice(a)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case a, ok := <-sub.Advice():
+			if !ok {
+				return toStatus(sub.Err())
+			}
+			if len(watched) > 0 && !watched[a.KeyID] {
+				continue
+			}
+			if err := stream.Send(toKeyAdvice(a)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toKeyAdvice(a hsm.Advice) *KeyAdvice {
+	out := &KeyAdvice{
+		Seq:            a.Seq,
+		Time:           a.Time.Unix(),
+		Kind:           string(a.Kind),
+		KeyId:          a.KeyID,
+		KeyVersion:     int32(a.Version),
+		CurrentVersion: int32(a.CurrentVersion),
+		OperationId:    a.OperationID,
+	}
+	if !a.Deadline.IsZero() {
+		out.Deadline = a.Deadline.Unix()
+	}
+	return out
+}
+
+// PromoteStandby makes this standby the primary
+func (s *Server) PromoteStandby(ctx context.Context, req *PromoteStandbyRequest) (*PromoteStandbyResponse, error) {
+	if s.replicator == nil {
+		return nil, status.Error(codes.FailedPrecondition, "not a standby")
+	}
+	s.replicator.Promote()
+	return &PromoteStandbyResponse{AppliedSeq: s.replicator.Applied()}, nil
+}
+
+// GenerateARQC computes a card's ARQC for terminal simulations
+func (s *Server) GenerateARQC(ctx context.Context, req *GenerateARQCRequest) (*GenerateARQCResponse, error) {
+	card := hsm.CardID{PAN: req.Pan, PSN: int(req.PanSequenceNumber)}
+	arqc, err := s.hsm.GenerateARQC(req.KeyId, card, uint16(req.Atc), req.TransactionData)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GenerateARQCResponse{Arqc: arqc}, nil
+}
+
+// GenerateEMVResponse verifies the ARQC and builds field 55 response data
+func (s *Server) GenerateEMVResponse(ctx context.Context, req *GenerateEMVResponseRequest) (*GenerateEMVResponseResponse, error) {
+	card := hsm.CardID{PAN: req.Pan, PSN: int(req.PanSequenceNumber)}
+	arc := []byte(req.ResponseCode)
+	arpc, err := s.hsm.VerifyARQCAndGenerateARPC(req.KeyId, card, uint16(req.Atc), req.TransactionData, req.Arqc, arc)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	scripts := make([]hsm.IssuerScript, len(req.Scripts))
+	for i, sc := range req.Scripts {
+		scripts[i] = hsm.IssuerScript{BeforeGenerateAC: sc.BeforeGenerateAc, ID: sc.ScriptId, Commands: sc.Commands}
+	}
+	field55, err := hsm.ResponseData(arpc, arc, scripts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GenerateEMVResponseResponse{Arpc: arpc, Field_55: field55}, nil
+}
+
+// operator identifies the caller to the approval workflow. Unauthenticated
+// callers all share one identity, so dual control needs API keys.
+func operator(ctx context.Context) string {
+	if p := interceptors.PrincipalFromContext(ctx); p != nil {
+		return p.ID
+	}
+	return "anonymous"
+}
+
+func toZMKExchange(ex *hsm.ZMKExchange) *ZMKExchange {
+	out := &ZMKExchange{
+		Id:         ex.ID,
+		KeyId:      ex.KeyID,
+		Method:     string(ex.Method),
+		Requester:  ex.Requester,
+		Components: int32(ex.Components),
+		Custodians: ex.Custodians,
+		PublicKey:  ex.PublicKey,
+		Kcv:        ex.KCV,
+		State:      string(ex.State),
+		Error:      ex.Error,
+		CreatedAt:  ex.CreatedAt.Unix(),
+		ExpiresAt:  ex.ExpiresAt.Unix(),
+	}
+	if !ex.ClosedAt.IsZero() {
+		out.ClosedAt = ex.ClosedAt.Unix()
+	}
+	return out
+}
+
+func toOperation(op *hsm.Operation) *Operation {
+	out := &Operation{
+		Id:         op.ID,
+		Kind:       string(op.Kind),
+		KeyId:      op.KeyID,
+		KeyVersion: int32(op.Version),
+		Requester:  op.Requester,
+		Approver:   op.Approver,
+		State:      string(op.State),
+		Error:      op.Error,
+		CreatedAt:  op.CreatedAt.Unix(),
+		// Zero except for restores
+		BackupVersions: int32(op.BackupVersions),
+	}
+	if !op.ExpiresAt.IsZero() {
+		out.ExpiresAt = op.ExpiresAt.Unix()
+	}
+	if !op.DecidedAt.IsZero() {
+		out.DecidedAt = op.DecidedAt.Unix()
+	}
+	return out
+}
+
+// GetServiceInfo describes this build and its capabilities
+func (s *Server) GetServiceInfo(ctx context.Context, req *GetServiceInfoRequest) (*GetServiceInfoResponse, error) {
+	build := buildinfo.Get()
+	features := []string{"key-rotation", "versioned-decrypt", "aad", "audit-log", "data-keys", "key-import", "key-destruction", "state-dump", "emv-arqc", "emv-arpc", "key-hierarchy", "key-advice", "permissions", "nonce-reuse-detection", "external-nonce", "detached-tag", "ciphertext-envelope", "envelope-compression", "track-data", "p2pe-dukpt", "pin-verification", "pin-translation", "key-block-attributes", "zmk-exchange", "cvv-verification"}
+	if s.hsm.DualControl() {
+		features = append(features, "dual-control")
+	}
+	if s.hsm.Replicates() {
+		features = append(features, "replication")
+	}
+	if s.hsm.Standby() {
+		features = append(features, "standby")
+	}
+	limits := map[string]int64{
+		"key_bits":                       256,
+		"nonce_bytes":                    gcmNonceBytes,
+		"max_plaintext_bytes":            MaxPlaintextBytes,
+		"max_aad_bytes":                  MaxAADBytes,
+		"max_envelope_bytes":             MaxEnvelopeBytes,
+		"max_compressed_plaintext_bytes": int64(min(s.hsm.CompressionLimit(), MaxCompressiblePlaintextBytes)),
+	}
+	if profile, ok := s.hsm.Profile(); ok {
+		features = append(features, "profile:"+profile.Name)
+		if profile.MaxOpsPerSecond > 0 {
+			limits["max_ops_per_second"] = int64(profile.MaxOpsPerSecond)
+		}
+	}
+	return &GetServiceInfoResponse{
+		Service:    "hsm-simulator",
+		Version:    build.Version,
+		Commit:     build.Commit,
+		BuildDate:  build.Date,
+		Algorithms: []string{"AES-256-GCM"},
+		Features:   features,
+		Limits:     limits,
+	}, nil
+}
+
+// toStatus maps HSM errors to gRPC status codes. Unexpected errors are
+// reported as Internal without detail so nothing about key material leaks.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, hsm.ErrKeyNotFound), errors.Is(err, hsm.ErrInvalidKeyVersion):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, hsm.ErrInvalidKeyID), errors.Is(err, hsm.ErrInvalidAlgorithm), errors.Is(err, hsm.ErrInvalidKeyMaterial),
+		errors.Is(err, hsm.ErrInvalidCardData), errors.Is(err, hsm.ErrInvalidARC), errors.Is(err, hsm.ErrInvalidCryptogram),
+		errors.Is(err, hsm.ErrARQCMismatch), errors.Is(err, hsm.ErrScriptTooLong), errors.Is(err, hsm.ErrInvalidKeyType),
+		errors.Is(err, hsm.ErrInvalidKeyBlock), errors.Is(err, hsm.ErrKeyBlockMAC), errors.Is(err, hsm.ErrKCVMismatch),
+		errors.Is(err, hsm.ErrInvalidNonce), errors.Is(err, hsm.ErrInvalidEnvelope), errors.Is(err, hsm.ErrUnsupportedEnvelope),
+		errors.Is(err, hsm.ErrInvalidTrackData), errors.Is(err, hsm.ErrInvalidPIN), errors.Is(err, hsm.ErrInvalidPINBlock),
+		errors.Is(err, hsm.ErrInvalidPINMethod), errors.Is(err, hsm.ErrInvalidPINReference), errors.Is(err, hsm.ErrPINMismatch),
+		errors.Is(err, hsm.ErrInvalidKeyAttributes), errors.Is(err, hsm.ErrInvalidComponentCount), errors.Is(err, hsm.ErrInvalidWrappedKey),
+		errors.Is(err, hsm.ErrInvalidKCV), errors.Is(err, hsm.ErrWrongExchangeMethod), errors.Is(err, hsm.ErrInvalidCVV),
+		errors.Is(err, hsm.ErrInvalidExpiry), errors.Is(err, hsm.ErrInvalidServiceCode), errors.Is(err, hsm.ErrInvalidBackup):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, hsm.ErrOperationNotFound), errors.Is(err, hsm.ErrExchangeNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, hsm.ErrSelfApproval), errors.Is(err, hsm.ErrMissingOperator), errors.Is(err, hsm.ErrPermissionDenied),
+		errors.Is(err, hsm.ErrDuplicateCustodian):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, hsm.ErrKeyVersionInUse), errors.Is(err, hsm.ErrOperationDecided), errors.Is(err, hsm.ErrOperationExpired),
+		errors.Is(err, hsm.ErrReplicationDisabled), errors.Is(err, hsm.ErrKeyUsage), errors.Is(err, hsm.ErrNonceReuse),
+		errors.Is(err, hsm.ErrKeyNotExportable), errors.Is(err, hsm.ErrExchangeClosed), errors.Is(err, hsm.ErrReplicationConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, hsm.ErrAdviceSubscriberBehind), errors.Is(err, hsm.ErrPayloadTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, hsm.ErrStandby):
+		// Unavailable, so HA-aware clients retry against the primary
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, hsm.ErrKeyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, hsm.ErrDecryptionFailed):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, "internal HSM error")
+}
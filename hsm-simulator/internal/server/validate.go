@@ -0,0 +1,316 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/paymentgateway/go-common/validate"
+	"github.com/paymentgateway/hsm-simulator/internal/hsm"
+)
+
+// Request size limits, also reported by GetServiceInfo
+const (
+	MaxPlaintextBytes = 64 << 10
+	MaxAADBytes       = 1 << 10
+	// MaxCompressiblePlaintextBytes bounds the plaintext of an envelope
+	// the HSM is asked to compress
+	MaxCompressiblePlaintextBytes = hsm.DefaultCompressMaxBytes
+	// MaxEnvelopeBytes leaves room for the envelope header around the
+	// largest ciphertext
+	MaxEnvelopeBytes = MaxCompressiblePlaintextBytes + 1<<10
+	gcmNonceBytes    = 12
+	gcmTagBytes      = 16
+	ksnBytes         = 10
+)
+
+// Field rules for HSM requests, enforced by the validation interceptor
+
+func (r *GenerateKeyRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	if validate.Required(v, "algorithm", r.Algorithm) && r.Algorithm != "AES-256-GCM" {
+		v.Add("algorithm", "unsupported algorithm %q, want AES-256-GCM", r.Algorithm)
+	}
+	keyType(v, "key_type", r.KeyType)
+	keyAttributes(v, r.ModeOfUse, r.Exportability)
+}
+
+func (r *EncryptRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.Bytes(v, "plaintext", r.Plaintext, 1, MaxPlaintextBytes)
+	validate.Bytes(v, "aad", r.Aad, 0, MaxAADBytes)
+	if len(r.Nonce) > 0 {
+		validate.Bytes(v, "nonce", r.Nonce, gcmNonceBytes, gcmNonceBytes)
+	}
+}
+
+func (r *GenerateDataKeyRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.Bytes(v, "aad", r.Aad, 0, MaxAADBytes)
+}
+
+func (r *DecryptRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	if len(r.Tag) > 0 {
+		validate.Bytes(v, "ciphertext", r.Ciphertext, 1, MaxPlaintextBytes)
+		validate.Bytes(v, "tag", r.Tag, gcmTagBytes, gcmTagBytes)
+	} else {
+		validate.Bytes(v, "ciphertext", r.Ciphertext, gcmTagBytes, MaxPlaintextBytes+gcmTagBytes)
+	}
+	validate.Bytes(v, "nonce", r.Nonce, gcmNonceBytes, gcmNonceBytes)
+	validate.Bytes(v, "aad", r.Aad, 0, MaxAADBytes)
+	if r.KeyVersion < 1 {
+		v.Add("key_version", "must be at least 1")
+	}
+}
+
+func (r *EncryptEnvelopeRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	if r.Compress {
+		validate.Bytes(v, "plaintext", r.Plaintext, 1, MaxCompressiblePlaintextBytes)
+	} else {
+		validate.Bytes(v, "plaintext", r.Plaintext, 1, MaxPlaintextBytes)
+	}
+	validate.Bytes(v, "aad", r.Aad, 0, MaxAADBytes)
+}
+
+func (r *DecryptEnvelopeRequest) Validate(v *validate.Violations) {
+	validate.Bytes(v, "envelope", r.Envelope, 1, MaxEnvelopeBytes)
+	validate.Bytes(v, "aad", r.Aad, 0, MaxAADBytes)
+}
+
+func (r *EncryptTrackDataRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.Bytes(v, "track_data", r.TrackData, 1, hsm.MaxTrackBytes)
+	validate.Bytes(v, "aad", r.Aad, 0, MaxAADBytes)
+}
+
+func (r *DeriveIPEKRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "bdk_id", r.BdkId)
+	validate.Bytes(v, "ksn", r.Ksn, ksnBytes, ksnBytes)
+}
+
+func (r *DecryptP2PERequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "bdk_id", r.BdkId)
+	validate.Bytes(v, "ksn", r.Ksn, ksnBytes, ksnBytes)
+	validate.Bytes(v, "encrypted_track", r.EncryptedTrack, 8, hsm.MaxTrackBytes+8)
+}
+
+func (r *EncryptPINRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "zpk_id", r.ZpkId)
+	validate.PAN(v, "pan", r.Pan)
+	if len(r.Pin) < 4 || len(r.Pin) > 12 {
+		v.Add("pin", "must be 4-12 digits")
+	}
+}
+
+func (r *GeneratePINReferenceRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "pvk_id", r.PvkId)
+	validate.KeyID(v, "zpk_id", r.ZpkId)
+	validate.Bytes(v, "pin_block", r.PinBlock, hsm.PINBlockSize, hsm.PINBlockSize)
+	validate.PAN(v, "pan", r.Pan)
+	pinMethod(v, "method", r.Method)
+	validate.Range(v, "pvki", int64(r.Pvki), 0, 6)
+}
+
+func (r *VerifyPINRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "pvk_id", r.PvkId)
+	validate.KeyID(v, "zpk_id", r.ZpkId)
+	validate.Bytes(v, "pin_block", r.PinBlock, hsm.PINBlockSize, hsm.PINBlockSize)
+	validate.PAN(v, "pan", r.Pan)
+	pinReference(v, "reference", r.Reference)
+}
+
+func (r *TranslatePINRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "source_zpk_id", r.SourceZpkId)
+	validate.KeyID(v, "dest_zpk_id", r.DestZpkId)
+	validate.Bytes(v, "pin_block", r.PinBlock, hsm.PINBlockSize, hsm.PINBlockSize)
+	validate.PAN(v, "pan", r.Pan)
+}
+
+func (r *GenerateCVVRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "cvk_id", r.CvkId)
+	validate.PAN(v, "pan", r.Pan)
+	cvvInput(v, r.Expiry, r.ServiceCode)
+}
+
+func (r *VerifyCVVRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "cvk_id", r.CvkId)
+	validate.PAN(v, "pan", r.Pan)
+	cvvInput(v, r.Expiry, r.ServiceCode)
+	if len(r.Cvv) != 3 || strings.Trim(r.Cvv, "0123456789") != "" {
+		v.Add("cvv", "must be 3 digits")
+	}
+}
+
+func (r *ChangePINRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "pvk_id", r.PvkId)
+	validate.KeyID(v, "zpk_id", r.ZpkId)
+	validate.Bytes(v, "old_pin_block", r.OldPinBlock, hsm.PINBlockSize, hsm.PINBlockSize)
+	validate.Bytes(v, "new_pin_block", r.NewPinBlock, hsm.PINBlockSize, hsm.PINBlockSize)
+	validate.PAN(v, "pan", r.Pan)
+	pinReference(v, "reference", r.Reference)
+}
+
+func (r *RotateKeyRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+}
+
+func (r *GetKeyInfoRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+}
+
+func (r *DestroyKeyVersionRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	if r.KeyVersion < 1 {
+		v.Add("key_version", "must be at least 1")
+	}
+}
+
+func (r *ImportKeyRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	if validate.Required(v, "algorithm", r.Algorithm) && r.Algorithm != "AES-256-GCM" {
+		v.Add("algorithm", "unsupported algorithm %q, want AES-256-GCM", r.Algorithm)
+	}
+	validate.Bytes(v, "key_material", r.KeyMaterial, 32, 32)
+	keyType(v, "key_type", r.KeyType)
+}
+
+func (r *RestoreKeysRequest) Validate(v *validate.Violations) {
+	if len(r.Versions) == 0 {
+		v.Add("versions", "is required")
+	}
+	for _, ch := range r.Versions {
+		validate.KeyID(v, "versions.key_id", ch.KeyId)
+		if ch.KeyVersion < 1 {
+			v.Add("versions.key_version", "must be at least 1")
+		}
+	}
+}
+
+func (r *ExportKeyRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.KeyID(v, "zmk_id", r.ZmkId)
+	keyAttributes(v, r.ModeOfUse, r.Exportability)
+}
+
+func (r *ImportKeyBlockRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.KeyID(v, "zmk_id", r.ZmkId)
+	validate.Required(v, "key_block", r.KeyBlock)
+	if r.Kcv != "" && len(r.Kcv) != 6 {
+		v.Add("kcv", "must be 6 hex digits")
+	}
+}
+
+func (r *BeginZMKExchangeRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	method, err := hsm.ParseZMKMethod(r.Method)
+	if err != nil {
+		v.Add("method", "unsupported ZMK exchange method %q, want components or rsa-oaep", r.Method)
+	}
+	if method == hsm.ZMKComponents {
+		validate.Range(v, "components", int64(r.Components), 2, 9)
+	}
+	if len(r.Kcv) != 6 {
+		v.Add("kcv", "must be 6 hex digits")
+	}
+}
+
+func (r *EnterZMKComponentRequest) Validate(v *validate.Violations) {
+	validate.Required(v, "exchange_id", r.ExchangeId)
+	validate.Bytes(v, "component", r.Component, 32, 32)
+	if r.ComponentKcv != "" && len(r.ComponentKcv) != 6 {
+		v.Add("component_kcv", "must be 6 hex digits")
+	}
+}
+
+func (r *ImportWrappedZMKRequest) Validate(v *validate.Violations) {
+	validate.Required(v, "exchange_id", r.ExchangeId)
+	validate.Bytes(v, "wrapped_key", r.WrappedKey, hsm.ZMKRSABits/8, hsm.ZMKRSABits/8)
+}
+
+func (r *CancelZMKExchangeRequest) Validate(v *validate.Violations) {
+	validate.Required(v, "exchange_id", r.ExchangeId)
+}
+
+func (r *WatchKeysRequest) Validate(v *validate.Violations) {
+	for _, id := range r.KeyIds {
+		validate.KeyID(v, "key_ids", id)
+	}
+}
+
+func (r *ApproveOperationRequest) Validate(v *validate.Violations) {
+	validate.Required(v, "operation_id", r.OperationId)
+}
+
+func (r *RejectOperationRequest) Validate(v *validate.Violations) {
+	validate.Required(v, "operation_id", r.OperationId)
+}
+
+func (r *GenerateARQCRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.PAN(v, "pan", r.Pan)
+	validate.Range(v, "pan_sequence_number", int64(r.PanSequenceNumber), 0, 99)
+	validate.Range(v, "atc", int64(r.Atc), 0, 0xFFFF)
+	validate.Bytes(v, "transaction_data", r.TransactionData, 1, MaxAADBytes)
+}
+
+func (r *GenerateEMVResponseRequest) Validate(v *validate.Violations) {
+	validate.KeyID(v, "key_id", r.KeyId)
+	validate.PAN(v, "pan", r.Pan)
+	validate.Range(v, "pan_sequence_number", int64(r.PanSequenceNumber), 0, 99)
+	validate.Range(v, "atc", int64(r.Atc), 0, 0xFFFF)
+	validate.Bytes(v, "transaction_data", r.TransactionData, 1, MaxAADBytes)
+	validate.Bytes(v, "arqc", r.Arqc, 8, 8)
+	if len(r.ResponseCode) != 2 {
+		v.Add("response_code", "must be 2 characters")
+	}
+}
+
+// keyType checks an optional key type of the hierarchy
+func keyType(v *validate.Violations, field, value string) {
+	if _, err := hsm.ParseKeyType(value); err != nil {
+		v.Add(field, "unsupported key type %q, want ZMK, ZPK, CVK, DEK, BDK or PVK", value)
+	}
+}
+
+// keyAttributes checks a TR-31 mode of use and exportability, which may be
+// empty; whether they suit the key type is the HSM's to check
+func keyAttributes(v *validate.Violations, mode, exportability string) {
+	if len(mode) > 1 || !strings.Contains("BCDEGVX", strings.ToUpper(mode)) {
+		v.Add("mode_of_use", "unsupported mode of use %q, want one of B, C, D, E, G, V or X", mode)
+	}
+	if len(exportability) > 1 || !strings.Contains("ENS", strings.ToUpper(exportability)) {
+		v.Add("exportability", "unsupported exportability %q, want E, N or S", exportability)
+	}
+}
+
+// cvvInput checks the expiry date and optional service code a CVV is
+// computed from
+func cvvInput(v *validate.Violations, expiry, serviceCode string) {
+	if len(expiry) != 4 || strings.Trim(expiry, "0123456789") != "" {
+		v.Add("expiry", "must be YYMM")
+	}
+	if serviceCode != "" && (len(serviceCode) != 3 || strings.Trim(serviceCode, "0123456789") != "") {
+		v.Add("service_code", "must be 3 digits")
+	}
+}
+
+// pinMethod checks a PIN verification method
+func pinMethod(v *validate.Violations, field, value string) {
+	if _, err := hsm.ParsePINMethod(value); err != nil {
+		v.Add(field, "unsupported PIN verification method %q, want IBM3624 or VISA-PVV", value)
+	}
+}
+
+// pinReference checks the offset or PVV of a PIN
+func pinReference(v *validate.Violations, field string, ref *PINReference) {
+	if ref == nil {
+		v.Add(field, "is required")
+		return
+	}
+	pinMethod(v, field+".method", ref.Method)
+	if n := len(ref.Value); n < 4 || n > 12 {
+		v.Add(field+".value", "must be 4-12 digits")
+	}
+	validate.Range(v, field+".pvki", int64(ref.Pvki), 0, 6)
+}
@@ -0,0 +1,149 @@
+// Package embedded runs the HSM simulator inside another Go program. The
+// HSM's methods are the operations of the gRPC API, called directly, so
+// tests can use it without a server, a listener or generated code.
+package embedded
+
+import "github.com/paymentgateway/hsm-simulator/internal/hsm"
+
+type (
+	// HSM is an in-process HSM simulator
+	HSM = hsm.HSM
+	// KeyMetadata describes a key and its versions
+	KeyMetadata = hsm.KeyMetadata
+	// Option configures an HSM
+	Option = hsm.Option
+	// Profile models the latency and throughput of real hardware
+	Profile = hsm.Profile
+	// KeyType is a key's place in the LMK, ZMK, working key hierarchy
+	KeyType = hsm.KeyType
+	// EncryptOptions selects a caller's nonce or a detached tag
+	EncryptOptions = hsm.EncryptOptions
+	// EnvelopeOptions selects compression of an envelope's plaintext
+	EnvelopeOptions = hsm.EnvelopeOptions
+	// TrackFields are the storable fields of magnetic stripe track data
+	TrackFields = hsm.TrackFields
+	// ParsedTrack describes track data without its sensitive fields
+	ParsedTrack = hsm.ParsedTrack
+	// PINMethod is a PIN verification method
+	PINMethod = hsm.PINMethod
+	// PINReference is the offset or PVV an issuer keeps to verify a PIN
+	PINReference = hsm.PINReference
+	// KeyAttributes are a key's TR-31 mode of use and exportability
+	KeyAttributes = hsm.KeyAttributes
+	// KeyMode is a TR-31 mode of use
+	KeyMode = hsm.KeyMode
+	// Exportability is the TR-31 exportability of a key
+	Exportability = hsm.Exportability
+	// ZMKExchange is the establishment of a ZMK shared with another party
+	ZMKExchange = hsm.ZMKExchange
+	// ZMKMethod is how the other party conveys a ZMK
+	ZMKMethod = hsm.ZMKMethod
+	// ExchangeState is where a ZMK exchange is
+	ExchangeState = hsm.ExchangeState
+)
+
+// Key types
+const (
+	KeyTypeZMK = hsm.KeyTypeZMK
+	KeyTypeZPK = hsm.KeyTypeZPK
+	KeyTypeCVK = hsm.KeyTypeCVK
+	KeyTypeBDK = hsm.KeyTypeBDK
+	KeyTypeDEK = hsm.KeyTypeDEK
+	KeyTypePVK = hsm.KeyTypePVK
+)
+
+// TR-31 modes of use
+const (
+	ModeBoth           = hsm.ModeBoth
+	ModeGenerateVerify = hsm.ModeGenerateVerify
+	ModeDecrypt        = hsm.ModeDecrypt
+	ModeEncrypt        = hsm.ModeEncrypt
+	ModeGenerate       = hsm.ModeGenerate
+	ModeVerify         = hsm.ModeVerify
+	ModeDerive         = hsm.ModeDerive
+)
+
+// TR-31 exportability
+const (
+	ExportableTrusted   = hsm.ExportableTrusted
+	NonExportable       = hsm.NonExportable
+	ExportableSensitive = hsm.ExportableSensitive
+)
+
+// PIN verification methods
+const (
+	PINMethodIBM3624 = hsm.PINMethodIBM3624
+	PINMethodVisaPVV = hsm.PINMethodVisaPVV
+)
+
+// CVV service codes
+const (
+	ServiceCodeCVV2 = hsm.ServiceCodeCVV2
+	ServiceCodeICVV = hsm.ServiceCodeICVV
+)
+
+// ZMK exchange methods
+const (
+	ZMKComponents = hsm.ZMKComponents
+	ZMKWrappedRSA = hsm.ZMKWrappedRSA
+)
+
+// ZMK exchange states
+const (
+	ExchangePending     = hsm.ExchangePending
+	ExchangeEstablished = hsm.ExchangeEstablished
+	ExchangeFailed      = hsm.ExchangeFailed
+	ExchangeCancelled   = hsm.ExchangeCancelled
+	ExchangeExpired     = hsm.ExchangeExpired
+)
+
+// Errors returned by the HSM
+var (
+	ErrKeyNotFound           = hsm.ErrKeyNotFound
+	ErrKeyExists             = hsm.ErrKeyExists
+	ErrInvalidKeyID          = hsm.ErrInvalidKeyID
+	ErrInvalidAlgorithm      = hsm.ErrInvalidAlgorithm
+	ErrInvalidKeyVersion     = hsm.ErrInvalidKeyVersion
+	ErrDecryptionFailed      = hsm.ErrDecryptionFailed
+	ErrInvalidKeyType        = hsm.ErrInvalidKeyType
+	ErrKeyUsage              = hsm.ErrKeyUsage
+	ErrInvalidKeyBlock       = hsm.ErrInvalidKeyBlock
+	ErrKeyBlockMAC           = hsm.ErrKeyBlockMAC
+	ErrKCVMismatch           = hsm.ErrKCVMismatch
+	ErrInvalidKeyAttributes  = hsm.ErrInvalidKeyAttributes
+	ErrKeyNotExportable      = hsm.ErrKeyNotExportable
+	ErrInvalidNonce          = hsm.ErrInvalidNonce
+	ErrNonceReuse            = hsm.ErrNonceReuse
+	ErrInvalidEnvelope       = hsm.ErrInvalidEnvelope
+	ErrPayloadTooLarge       = hsm.ErrPayloadTooLarge
+	ErrInvalidTrackData      = hsm.ErrInvalidTrackData
+	ErrInvalidPIN            = hsm.ErrInvalidPIN
+	ErrInvalidPINBlock       = hsm.ErrInvalidPINBlock
+	ErrPINMismatch           = hsm.ErrPINMismatch
+	ErrInvalidCVV            = hsm.ErrInvalidCVV
+	ErrInvalidExpiry         = hsm.ErrInvalidExpiry
+	ErrInvalidServiceCode    = hsm.ErrInvalidServiceCode
+	ErrExchangeNotFound      = hsm.ErrExchangeNotFound
+	ErrExchangeClosed        = hsm.ErrExchangeClosed
+	ErrWrongExchangeMethod   = hsm.ErrWrongExchangeMethod
+	ErrInvalidComponentCount = hsm.ErrInvalidComponentCount
+	ErrDuplicateCustodian    = hsm.ErrDuplicateCustodian
+	ErrInvalidWrappedKey     = hsm.ErrInvalidWrappedKey
+	ErrInvalidKCV            = hsm.ErrInvalidKCV
+)
+
+// New creates an HSM with no keys
+func New(opts ...Option) *HSM {
+	return hsm.NewHSM(opts...)
+}
+
+// WithProfile makes operations take the profile's latency and queue beyond
+// its throughput ceiling
+func WithProfile(p Profile) Option {
+	return hsm.WithProfile(p)
+}
+
+// LookupProfile returns a built-in profile by name, ignoring case
+func LookupProfile(name string) (Profile, error) {
+	return hsm.LookupProfile(name)
+}
@@ -9,11 +9,20 @@ CREATE EXTENSION IF NOT EXISTS "btree_gist";
 -- Create custom types
 CREATE TYPE payment_status AS ENUM ('PENDING', 'AUTHORIZED', 'CAPTURED', 'SETTLED', 'FAILED', 'CANCELLED', 'REFUNDED');
 CREATE TYPE card_brand AS ENUM ('VISA', 'MASTERCARD', 'AMEX', 'DISCOVER', 'JCB', 'DINERS', 'UNIONPAY');
-CREATE TYPE transaction_type AS ENUM ('AUTHORIZATION', 'CAPTURE', 'REFUND', 'VOID');
+CREATE TYPE transaction_type AS ENUM ('AUTHORIZATION', 'CAPTURE', 'REFUND', 'VOID', 'CREDIT');
 CREATE TYPE fraud_status AS ENUM ('CLEAN', 'REVIEW', 'BLOCK');
 CREATE TYPE three_ds_status AS ENUM ('NOT_ENROLLED', 'ENROLLED', 'AUTHENTICATED', 'FAILED', 'BYPASSED');
 CREATE TYPE settlement_status AS ENUM ('PENDING', 'PROCESSING', 'SETTLED', 'FAILED');
 
+-- Sandboxes: isolated sets of merchants sharing one simulator
+CREATE TABLE sandboxes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sandbox_id VARCHAR(30) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_by VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
 -- Merchants table
 CREATE TABLE merchants (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -25,6 +34,21 @@ CREATE TABLE merchants (
     currency VARCHAR(3) NOT NULL DEFAULT 'USD',
     is_active BOOLEAN DEFAULT true,
     risk_level VARCHAR(20) DEFAULT 'LOW',
+    standalone_credits_enabled BOOLEAN NOT NULL DEFAULT false,
+    settlement_timezone VARCHAR(64), -- IANA zone; NULL uses the settlement service default
+    settlement_cutoff_time TIME,
+    avs_policy VARCHAR(20) NOT NULL DEFAULT 'NONE', -- AVS results declined, see AvsPolicy
+    statement_descriptor VARCHAR(18), -- NULL uses the merchant name
+    surcharge_percent DECIMAL(5,2), -- NULL surcharges nothing
+    convenience_fee DECIMAL(12,2), -- flat fee on MOTO payments
+    platform_merchant_id UUID REFERENCES merchants(id), -- marketplace platform of a sub-merchant
+    refund_window_days INTEGER, -- days after capture refunds are allowed; NULL uses the default
+    max_refunds_per_payment INTEGER, -- NULL uses the default
+    reserve_percentage DECIMAL(5,2), -- share of settled sales held; NULL uses the settlement default
+    reserve_hold_days INTEGER, -- days a reserve is held; NULL uses the settlement default
+    onboarding_status VARCHAR(30) NOT NULL DEFAULT 'LIVE', -- KYC stage, see OnboardingStatus
+    blocked_issuer_countries VARCHAR(255), -- comma-separated issuing countries declined
+    cross_border_policy VARCHAR(20) NOT NULL DEFAULT 'ALLOW', -- see CrossBorderPolicy
     
     -- PCI compliance fields
     pci_compliance_level VARCHAR(10) DEFAULT 'SAQ-A',
@@ -32,12 +56,15 @@ CREATE TABLE merchants (
     
     -- API credentials (encrypted)
     api_key_hash VARCHAR(255),
+    live_api_key_hash VARCHAR(255), -- issued once onboarding is approved
     webhook_url TEXT,
     webhook_secret_hash VARCHAR(255),
     
     -- Timestamps
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    deleted_at TIMESTAMP WITH TIME ZONE, -- soft-deleted; NULL while live
+    sandbox_id UUID REFERENCES sandboxes(id), -- NULL outside sandboxes
     
     -- Constraints
     CONSTRAINT valid_mcc CHECK (mcc ~ '^[0-9]{4}$'),
@@ -88,6 +115,7 @@ CREATE TABLE payments (
     currency VARCHAR(3) NOT NULL,
     description TEXT,
     reference_id VARCHAR(100),
+    statement_descriptor VARCHAR(25), -- static descriptor and any dynamic suffix
     
     -- Card information (tokenized)
     card_token_id UUID REFERENCES card_tokens(id),
@@ -101,6 +129,8 @@ CREATE TABLE payments (
     -- External references
     psp_transaction_id VARCHAR(100),
     psp_reference VARCHAR(100),
+    psp_name VARCHAR(50), -- acquirer chosen by routing
+    routing_path VARCHAR(255),
     acquirer_reference VARCHAR(100),
     
     -- Fraud detection
@@ -115,12 +145,48 @@ CREATE TABLE payments (
     three_ds_cavv TEXT,
     three_ds_xid TEXT,
     
+    -- Strong customer authentication
+    sca_exemption VARCHAR(30),
+    decline_code VARCHAR(20),
+    original_payment_id VARCHAR(100),
+    
+    -- Transaction initiation (MOTO, MIT/CIT, credential on file)
+    transaction_channel VARCHAR(20) DEFAULT 'ECOMMERCE',
+    initiator VARCHAR(20) DEFAULT 'CUSTOMER',
+    stored_credential VARCHAR(20),
+    network_transaction_id VARCHAR(15),
+    original_network_transaction_id VARCHAR(15),
+    
     -- Billing address
     billing_street TEXT,
+    billing_street2 TEXT,
     billing_city VARCHAR(100),
     billing_state VARCHAR(100),
     billing_zip VARCHAR(20),
     billing_country VARCHAR(2),
+    avs_result VARCHAR(1), -- Y, A, Z, N or U; NULL without a billing address
+    cvv_result VARCHAR(1), -- M, N or U as answered by the issuer
+    
+    -- Surcharge or convenience fee included in the amount
+    surcharge_amount DECIMAL(12,2),
+    convenience_fee DECIMAL(12,2),
+    
+    -- Tip added at capture, included in the amount
+    tip_amount DECIMAL(12,2),
+    
+    -- Installment plan, authorized and cleared as one payment
+    installment_count SMALLINT,
+    installment_plan_type VARCHAR(10), -- MERCHANT or ISSUER
+    
+    -- Issuing country of the card from the BIN table; cross-border when
+    -- it differs from the merchant's country
+    issuer_country VARCHAR(2),
+    cross_border BOOLEAN NOT NULL DEFAULT false,
+    
+    -- Level 2/3 purchasing data
+    tax_amount DECIMAL(12,2),
+    customer_code VARCHAR(17),
+    purchase_data_level SMALLINT NOT NULL DEFAULT 1, -- 1, 2 or 3
     
     -- Processing metadata
     processing_time_ms INTEGER,
@@ -141,6 +207,31 @@ CREATE TABLE payments (
     CONSTRAINT valid_payment_id CHECK (payment_id ~ '^pay_[A-Za-z0-9]{24}$')
 );
 
+-- Level 3 line items of payments
+CREATE TABLE payment_line_items (
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    line_number INTEGER NOT NULL,
+    product_code VARCHAR(12),
+    description VARCHAR(35) NOT NULL,
+    commodity_code VARCHAR(12),
+    quantity DECIMAL(12,4) NOT NULL,
+    unit_of_measure VARCHAR(12),
+    unit_price DECIMAL(12,4) NOT NULL,
+    discount_amount DECIMAL(12,2),
+    line_total DECIMAL(12,2) NOT NULL,
+    PRIMARY KEY (payment_id, line_number)
+);
+
+-- Marketplace portions of payments for the merchant's sub-merchants
+CREATE TABLE payment_splits (
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    split_number INTEGER NOT NULL,
+    sub_merchant_id UUID NOT NULL REFERENCES merchants(id),
+    amount DECIMAL(12,2) NOT NULL,
+    fee_amount DECIMAL(12,2) NOT NULL DEFAULT 0, -- platform's fee on the split
+    PRIMARY KEY (payment_id, split_number)
+);
+
 -- Payment events (audit trail)
 CREATE TABLE payment_events (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -166,12 +257,16 @@ CREATE TABLE payment_events (
     user_agent TEXT,
     ip_address INET,
     correlation_id UUID DEFAULT uuid_generate_v4(),
+    trace_id VARCHAR(32),
     
     -- Timestamp
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     
     -- Constraints
-    CONSTRAINT valid_event_type CHECK (event_type IN ('AUTHORIZATION', 'CAPTURE', 'REFUND', 'VOID', 'FRAUD_CHECK', '3DS_AUTH'))
+    CONSTRAINT valid_event_type CHECK (event_type IN (
+        'TOKENIZATION', 'CRYPTOGRAM_CHECK', 'FRAUD_CHECK', 'GEO_CHECK', 'SANCTIONS_SCREENING', '3DS_AUTH',
+        'SURCHARGE', 'AUTHORIZATION_REQUEST', 'AVS_CHECK', 'AUTHORIZATION', 'CAPTURE', 'VOID', 'REFUND',
+        'REFUND_CREATED', 'REFUND_COMPLETED', 'REFUND_FAILED', 'CREDIT'))
 );
 
 -- Refunds table
@@ -194,6 +289,7 @@ CREATE TABLE refunds (
     
     -- Processing
     processing_time_ms INTEGER,
+    policy_override VARCHAR(100), -- refund policy rules an administrator overrode
     
     -- Timestamps
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
@@ -245,11 +341,53 @@ CREATE TABLE settlement_transactions (
     fee_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
     net_amount DECIMAL(12,2) NOT NULL,
     currency VARCHAR(3) NOT NULL,
+    statement_descriptor VARCHAR(25),
+    
+    -- Purchasing data and the interchange program it qualified for
+    tax_amount DECIMAL(12,2),
+    customer_code VARCHAR(17),
+    purchase_data_level SMALLINT,
+    interchange_program VARCHAR(20), -- STANDARD, LEVEL_2 or LEVEL_3
+    
+    -- Installment plan as cleared
+    installment_count SMALLINT,
+    installment_plan_type VARCHAR(10),
+    
+    -- Cross-border markup, included in the fee
+    cross_border_fee DECIMAL(12,2),
     
     -- Timestamps
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Payouts of merchants' ledger balances
+CREATE TABLE payouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payout_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    currency VARCHAR(3) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    entry_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Merchants' ledgers: what settled payments, fees and splits owe each
+-- merchant; entries are paid out once
+CREATE TABLE ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    batch_id UUID NOT NULL REFERENCES settlement_batches(id),
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    entry_type VARCHAR(20) NOT NULL, -- see LedgerEntryType
+    amount DECIMAL(12,2) NOT NULL, -- negative for debits
+    currency VARCHAR(3) NOT NULL,
+    payout_id UUID REFERENCES payouts(id), -- NULL until paid out
+    reference VARCHAR(100), -- the refund or dispute a reversal entry posts
+    release_at TIMESTAMP WITH TIME ZONE, -- when a RESERVE_HOLD is due back
+    released_at TIMESTAMP WITH TIME ZONE, -- when its RESERVE_RELEASE was posted
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
 -- Fraud rules
 CREATE TABLE fraud_rules (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -342,6 +480,7 @@ CREATE TABLE api_keys (
 );
 
 -- Create indexes for performance
+CREATE INDEX idx_merchants_sandbox_id ON merchants(sandbox_id) WHERE sandbox_id IS NOT NULL;
 CREATE INDEX idx_payments_merchant_id ON payments(merchant_id);
 CREATE INDEX idx_payments_status ON payments(status);
 CREATE INDEX idx_payments_created_at ON payments(created_at);
@@ -355,12 +494,20 @@ CREATE INDEX idx_card_tokens_last_four ON card_tokens(last_four);
 CREATE INDEX idx_payment_events_payment_id ON payment_events(payment_id);
 CREATE INDEX idx_payment_events_created_at ON payment_events(created_at);
 CREATE INDEX idx_payment_events_event_type ON payment_events(event_type);
+CREATE INDEX idx_payment_events_correlation_id ON payment_events(correlation_id);
 
 CREATE INDEX idx_refunds_payment_id ON refunds(payment_id);
 CREATE INDEX idx_refunds_status ON refunds(status);
 
 CREATE INDEX idx_settlement_batches_merchant_id ON settlement_batches(merchant_id);
 CREATE INDEX idx_settlement_batches_settlement_date ON settlement_batches(settlement_date);
+CREATE INDEX idx_ledger_entries_merchant_id ON ledger_entries(merchant_id, created_at);
+CREATE INDEX idx_ledger_entries_unpaid ON ledger_entries(merchant_id, currency) WHERE payout_id IS NULL;
+CREATE INDEX idx_ledger_entries_reserve_due ON ledger_entries(release_at)
+    WHERE entry_type = 'RESERVE_HOLD' AND released_at IS NULL;
+CREATE INDEX idx_ledger_entries_payment_id ON ledger_entries(payment_id);
+CREATE INDEX idx_ledger_entries_reference ON ledger_entries(reference) WHERE reference IS NOT NULL;
+CREATE INDEX idx_payouts_merchant_id ON payouts(merchant_id, created_at);
 
 CREATE INDEX idx_fraud_alerts_payment_id ON fraud_alerts(payment_id);
 CREATE INDEX idx_fraud_alerts_status ON fraud_alerts(status);
@@ -433,12 +580,15 @@ CREATE TABLE disputes (
     
     -- Chargeback info
     chargeback_reference VARCHAR(100),
-    deadline TIMESTAMP WITH TIME ZONE,
+    deadline TIMESTAMP WITH TIME ZONE, -- merchant's respond-by time while OPEN
+    review_deadline TIMESTAMP WITH TIME ZONE, -- issuer's decision due once evidence is in
     
     -- Evidence and resolution
     evidence_submitted_at TIMESTAMP WITH TIME ZONE,
     resolved_at TIMESTAMP WITH TIME ZONE,
     resolution TEXT,
+    evidence_bundle TEXT, -- JSON representment evidence, see DisputeEvidenceBuilder
+    evidence_generated_at TIMESTAMP WITH TIME ZONE,
     
     -- Timestamps
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
@@ -454,9 +604,245 @@ CREATE INDEX idx_disputes_payment_id ON disputes(payment_id);
 CREATE INDEX idx_disputes_merchant_id ON disputes(merchant_id);
 CREATE INDEX idx_disputes_status ON disputes(status);
 CREATE INDEX idx_disputes_created_at ON disputes(created_at);
+CREATE INDEX idx_disputes_status_deadline ON disputes(status, deadline);
+CREATE INDEX idx_disputes_status_review_deadline ON disputes(status, review_deadline);
 
 CREATE TRIGGER update_disputes_updated_at BEFORE UPDATE ON disputes FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+-- Leader election for services running several instances. The leader
+-- renews its lease; fencing tokens grow with every change of leader.
+CREATE TABLE job_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    owner_id VARCHAR(255) NOT NULL,
+    fencing_token BIGINT NOT NULL,
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- One row per scheduled run of a singleton job, claimed by the leader
+CREATE TABLE job_runs (
+    job_name VARCHAR(100) NOT NULL,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    owner_id VARCHAR(255) NOT NULL,
+    fencing_token BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (job_name, scheduled_for),
+    CONSTRAINT valid_job_run_status CHECK (status IN ('RUNNING', 'SUCCEEDED', 'FAILED'))
+);
+
+-- Shared idempotency store (when not using Redis)
+CREATE TABLE idempotency_keys (
+    idem_key VARCHAR(300) PRIMARY KEY,
+    value TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- Transactional outbox of payment events awaiting publication
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(50) NOT NULL UNIQUE,
+    event_type VARCHAR(50) NOT NULL,
+    partition_key VARCHAR(100) NOT NULL,
+    payment_id UUID,
+    merchant_id UUID,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events(partition_key, id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_events_published_at ON outbox_events(published_at) WHERE published_at IS NOT NULL;
+
+-- Verified webhook endpoints with per-endpoint signing secrets
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    url TEXT NOT NULL,
+    status VARCHAR(30) NOT NULL DEFAULT 'PENDING_VERIFICATION',
+    signing_secret VARCHAR(255) NOT NULL,
+    previous_signing_secret VARCHAR(255),
+    previous_secret_expires_at TIMESTAMP WITH TIME ZONE,
+    secret_rotated_at TIMESTAMP WITH TIME ZONE,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_webhook_endpoints_merchant_status ON webhook_endpoints(merchant_id, status);
+
+-- Webhook deliveries; FAILED rows form the dead-letter queue
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL,
+    payment_id UUID, -- NULL for merchant events such as onboarding
+    endpoint_id UUID,
+    event_type VARCHAR(50) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    attempt_count INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 10,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    http_status_code INTEGER,
+    response_body TEXT,
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_retry_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    dead_lettered_at TIMESTAMP WITH TIME ZONE,
+    redelivery_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_webhook_deliveries_merchant_status ON webhook_deliveries(merchant_id, status);
+CREATE INDEX idx_webhook_deliveries_pending_retry ON webhook_deliveries(next_retry_at) WHERE status = 'PENDING';
+
+-- Batch transaction files uploaded by merchants
+CREATE TABLE batch_files (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    file_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    format VARCHAR(20) NOT NULL, -- CSV or FIXED_WIDTH
+    status VARCHAR(20) NOT NULL, -- RECEIVED, PROCESSING or COMPLETED
+    record_count INTEGER NOT NULL,
+    approved_count INTEGER NOT NULL DEFAULT 0,
+    declined_count INTEGER NOT NULL DEFAULT 0,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    source VARCHAR(10) NOT NULL DEFAULT 'API', -- API or SFTP
+    source_name VARCHAR(255), -- name dropped under, for SFTP files
+    response_delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_batch_files_unfinished ON batch_files(created_at) WHERE status <> 'COMPLETED';
+CREATE INDEX idx_batch_files_undelivered ON batch_files(source, status) WHERE response_delivered_at IS NULL;
+
+-- Records of batch files, by token, and their outcomes
+CREATE TABLE batch_file_records (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    batch_file_id UUID NOT NULL REFERENCES batch_files(id),
+    record_number INTEGER NOT NULL,
+    reference VARCHAR(20),
+    token VARCHAR(19) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    record_type VARCHAR(20) NOT NULL, -- SALE or AUTHORIZATION
+    outcome VARCHAR(20) NOT NULL, -- PENDING, APPROVED, DECLINED or ERROR
+    payment_id VARCHAR(50),
+    payment_status VARCHAR(20),
+    response_code VARCHAR(50),
+    message TEXT,
+    UNIQUE (batch_file_id, record_number)
+);
+
+-- Account-to-account credit transfers from ISO 20022 messages
+CREATE TABLE credit_transfers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transfer_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    message_type VARCHAR(10) NOT NULL, -- PAIN_001 or PACS_008
+    message_id VARCHAR(35) NOT NULL,
+    payment_info_id VARCHAR(35),
+    instruction_id VARCHAR(35),
+    end_to_end_id VARCHAR(35) NOT NULL,
+    transaction_id VARCHAR(36), -- TxId or UETR
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    execution_date DATE,
+    debtor_name VARCHAR(140),
+    debtor_iban VARCHAR(34),
+    debtor_agent_bic VARCHAR(11),
+    creditor_name VARCHAR(140),
+    creditor_iban VARCHAR(34),
+    creditor_agent_bic VARCHAR(11),
+    remittance_info VARCHAR(140),
+    status VARCHAR(20) NOT NULL, -- ACCEPTED, SETTLED or REJECTED
+    reason_code VARCHAR(4), -- ISO 20022 status reason of a rejection
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_credit_transfers_message ON credit_transfers(merchant_id, message_id);
+CREATE INDEX idx_credit_transfers_end_to_end ON credit_transfers(merchant_id, end_to_end_id);
+CREATE INDEX idx_credit_transfers_due ON credit_transfers(execution_date) WHERE status = 'ACCEPTED';
+
+-- EMVCo merchant-presented QR codes, each bound to one payment
+CREATE TABLE qr_payments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    qr_payment_id VARCHAR(30) UNIQUE NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    reference VARCHAR(25),
+    payload VARCHAR(512) NOT NULL,
+    status VARCHAR(20) NOT NULL, -- OPEN, PAID or EXPIRED
+    attempts INTEGER NOT NULL DEFAULT 0,
+    payment_id VARCHAR(50),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    paid_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_qr_payments_merchant ON qr_payments(merchant_id, created_at);
+
+-- KYC onboarding of applicant merchants
+CREATE TABLE merchant_onboarding (
+    merchant_id UUID PRIMARY KEY REFERENCES merchants(id) ON DELETE CASCADE,
+    rejection_reason TEXT,
+    reviewed_by VARCHAR(50),
+    submitted_at TIMESTAMP WITH TIME ZONE,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    live_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE merchant_onboarding_fields (
+    merchant_id UUID NOT NULL REFERENCES merchant_onboarding(merchant_id) ON DELETE CASCADE,
+    field_name VARCHAR(50) NOT NULL, -- see OnboardingRequirements
+    field_value TEXT NOT NULL,
+    PRIMARY KEY (merchant_id, field_name)
+);
+
+CREATE TABLE merchant_onboarding_documents (
+    merchant_id UUID NOT NULL REFERENCES merchant_onboarding(merchant_id) ON DELETE CASCADE,
+    document_type VARCHAR(50) NOT NULL,
+    reference VARCHAR(255) NOT NULL, -- where the uploaded document is stored
+    uploaded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (merchant_id, document_type)
+);
+
+CREATE INDEX idx_merchants_onboarding_status ON merchants(onboarding_status) WHERE onboarding_status <> 'LIVE';
+
+-- Sanctions and denylist screening hits, held until an administrator
+-- releases or confirms them
+CREATE TABLE screening_hits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    hit_id VARCHAR(30) UNIQUE NOT NULL,
+    subject_type VARCHAR(20) NOT NULL, -- MERCHANT or CARDHOLDER
+    merchant_id UUID NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+    payment_id VARCHAR(50), -- the held payment of a cardholder hit
+    screened_name VARCHAR(255),
+    screened_country VARCHAR(2),
+    provider VARCHAR(50) NOT NULL, -- the screening provider that matched
+    matched_entry VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL, -- HELD, RELEASED or CONFIRMED
+    decided_by VARCHAR(50),
+    decision_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_screening_hits_status ON screening_hits(status, created_at);
+CREATE INDEX idx_screening_hits_payment ON screening_hits(payment_id) WHERE payment_id IS NOT NULL;
+CREATE INDEX idx_screening_hits_merchant ON screening_hits(merchant_id);
+
 -- Grant permissions
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO payments_user;
 GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA public TO payments_user;
@@ -0,0 +1,44 @@
+package com.paymentgateway.settlement.calendar;
+
+import java.time.LocalDate;
+import java.time.OffsetDateTime;
+
+/**
+ * One day of a merchant's settlement calendar
+ */
+public class CalendarDay {
+    
+    private final LocalDate date;
+    private final boolean businessDay;
+    private final String holiday;
+    private final OffsetDateTime cutoffAt;
+    
+    public CalendarDay(LocalDate date, boolean businessDay, String holiday, OffsetDateTime cutoffAt) {
+        this.date = date;
+        this.businessDay = businessDay;
+        this.holiday = holiday;
+        this.cutoffAt = cutoffAt;
+    }
+    
+    public LocalDate getDate() {
+        return date;
+    }
+    
+    public boolean isBusinessDay() {
+        return businessDay;
+    }
+    
+    /**
+     * Name of the bank holiday on this day, or null
+     */
+    public String getHoliday() {
+        return holiday;
+    }
+    
+    /**
+     * When the day's batch closes, or null if nothing settles on this day
+     */
+    public OffsetDateTime getCutoffAt() {
+        return cutoffAt;
+    }
+}
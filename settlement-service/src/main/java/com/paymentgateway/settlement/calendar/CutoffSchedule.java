@@ -0,0 +1,26 @@
+package com.paymentgateway.settlement.calendar;
+
+import java.time.LocalTime;
+import java.time.ZoneId;
+
+/**
+ * A merchant's daily settlement cut-off: a local time in its timezone
+ */
+public final class CutoffSchedule {
+    
+    private final ZoneId zone;
+    private final LocalTime cutoff;
+    
+    public CutoffSchedule(ZoneId zone, LocalTime cutoff) {
+        this.zone = zone;
+        this.cutoff = cutoff;
+    }
+    
+    public ZoneId getZone() {
+        return zone;
+    }
+    
+    public LocalTime getCutoff() {
+        return cutoff;
+    }
+}
@@ -0,0 +1,118 @@
+package com.paymentgateway.settlement.calendar;
+
+import com.paymentgateway.settlement.domain.Merchant;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.stereotype.Component;
+
+import java.time.DateTimeException;
+import java.time.DayOfWeek;
+import java.time.LocalDate;
+import java.time.LocalTime;
+import java.time.OffsetDateTime;
+import java.time.ZoneId;
+import java.time.ZonedDateTime;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+import java.util.TreeMap;
+
+/**
+ * Settlement dates and cut-offs. Payments settle on business days only:
+ * not weekends and not the configured bank holidays. A capture belongs to
+ * the first business-day cut-off at or after it in the merchant's timezone,
+ * so a capture after Friday's cut-off settles on Monday.
+ */
+@Component
+public class SettlementCalendar {
+    
+    private static final Logger logger = LoggerFactory.getLogger(SettlementCalendar.class);
+    
+    private final CutoffSchedule defaultSchedule;
+    private final Map<LocalDate, String> holidays = new TreeMap<>();
+    
+    /**
+     * @param holidaySpec comma-separated {@code date:name} entries, e.g.
+     *                    {@code 2026-12-25:Christmas Day,2026-12-26:Boxing Day}
+     */
+    public SettlementCalendar(@Value("${settlement.calendar.default-timezone:UTC}") String defaultTimezone,
+                              @Value("${settlement.calendar.default-cutoff:22:00}") String defaultCutoff,
+                              @Value("${settlement.calendar.holidays:}") String holidaySpec) {
+        this.defaultSchedule = new CutoffSchedule(ZoneId.of(defaultTimezone), LocalTime.parse(defaultCutoff));
+        for (String entry : holidaySpec.split(",")) {
+            if (entry.isBlank()) {
+                continue;
+            }
+            String[] parts = entry.trim().split(":", 2);
+            holidays.put(LocalDate.parse(parts[0].trim()), parts.length > 1 ? parts[1].trim() : "Holiday");
+        }
+    }
+    
+    /**
+     * The merchant's schedule, with the defaults for anything it leaves unset
+     */
+    public CutoffSchedule scheduleFor(Merchant merchant) {
+        if (merchant == null) {
+            return defaultSchedule;
+        }
+        ZoneId zone = defaultSchedule.getZone();
+        if (merchant.getSettlementTimezone() != null) {
+            try {
+                zone = ZoneId.of(merchant.getSettlementTimezone());
+            } catch (DateTimeException e) {
+                logger.warn("Invalid settlement timezone {} for merchant {}, using {}",
+                           merchant.getSettlementTimezone(), merchant.getMerchantId(), zone);
+            }
+        }
+        LocalTime cutoff = merchant.getSettlementCutoffTime() != null
+            ? merchant.getSettlementCutoffTime() : defaultSchedule.getCutoff();
+        return new CutoffSchedule(zone, cutoff);
+    }
+    
+    public boolean isBusinessDay(LocalDate date) {
+        DayOfWeek day = date.getDayOfWeek();
+        return day != DayOfWeek.SATURDAY && day != DayOfWeek.SUNDAY && !holidays.containsKey(date);
+    }
+    
+    /**
+     * The cut-off that closes the batch a capture belongs to. Its local date
+     * is the capture's settlement date.
+     */
+    public ZonedDateTime cutoffFor(OffsetDateTime capturedAt, CutoffSchedule schedule) {
+        ZonedDateTime local = capturedAt.atZoneSameInstant(schedule.getZone());
+        LocalDate date = local.toLocalDate();
+        if (!local.toLocalTime().isBefore(schedule.getCutoff())) {
+            date = date.plusDays(1);
+        }
+        while (!isBusinessDay(date)) {
+            date = date.plusDays(1);
+        }
+        return ZonedDateTime.of(date, schedule.getCutoff(), schedule.getZone());
+    }
+    
+    /**
+     * The next cut-off after now
+     */
+    public ZonedDateTime nextCutoff(CutoffSchedule schedule, OffsetDateTime now) {
+        return cutoffFor(now, schedule);
+    }
+    
+    /**
+     * The merchant's calendar for the given number of days, starting with
+     * today in its timezone
+     */
+    public List<CalendarDay> upcoming(CutoffSchedule schedule, OffsetDateTime now, int days) {
+        LocalDate start = now.atZoneSameInstant(schedule.getZone()).toLocalDate();
+        List<CalendarDay> calendar = new ArrayList<>(days);
+        for (int i = 0; i < days; i++) {
+            LocalDate date = start.plusDays(i);
+            boolean businessDay = isBusinessDay(date);
+            OffsetDateTime cutoffAt = businessDay
+                ? ZonedDateTime.of(date, schedule.getCutoff(), schedule.getZone()).toOffsetDateTime()
+                : null;
+            calendar.add(new CalendarDay(date, businessDay, holidays.get(date), cutoffAt));
+        }
+        return calendar;
+    }
+}
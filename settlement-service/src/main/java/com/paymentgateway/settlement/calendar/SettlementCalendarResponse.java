@@ -0,0 +1,67 @@
+package com.paymentgateway.settlement.calendar;
+
+import java.time.LocalTime;
+import java.time.OffsetDateTime;
+import java.util.List;
+import java.util.UUID;
+
+public class SettlementCalendarResponse {
+    
+    private UUID merchantId;
+    private String timezone;
+    private LocalTime cutoffTime;
+    private OffsetDateTime nextCutoff;
+    private List<CalendarDay> days;
+    
+    public SettlementCalendarResponse() {}
+    
+    public SettlementCalendarResponse(UUID merchantId, CutoffSchedule schedule,
+                                      OffsetDateTime nextCutoff, List<CalendarDay> days) {
+        this.merchantId = merchantId;
+        this.timezone = schedule.getZone().getId();
+        this.cutoffTime = schedule.getCutoff();
+        this.nextCutoff = nextCutoff;
+        this.days = days;
+    }
+    
+    // Getters and Setters
+    public UUID getMerchantId() {
+        return merchantId;
+    }
+    
+    public void setMerchantId(UUID merchantId) {
+        this.merchantId = merchantId;
+    }
+    
+    public String getTimezone() {
+        return timezone;
+    }
+    
+    public void setTimezone(String timezone) {
+        this.timezone = timezone;
+    }
+    
+    public LocalTime getCutoffTime() {
+        return cutoffTime;
+    }
+    
+    public void setCutoffTime(LocalTime cutoffTime) {
+        this.cutoffTime = cutoffTime;
+    }
+    
+    public OffsetDateTime getNextCutoff() {
+        return nextCutoff;
+    }
+    
+    public void setNextCutoff(OffsetDateTime nextCutoff) {
+        this.nextCutoff = nextCutoff;
+    }
+    
+    public List<CalendarDay> getDays() {
+        return days;
+    }
+    
+    public void setDays(List<CalendarDay> days) {
+        this.days = days;
+    }
+}
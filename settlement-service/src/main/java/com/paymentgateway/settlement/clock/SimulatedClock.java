@@ -0,0 +1,75 @@
+package com.paymentgateway.settlement.clock;
+
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.stereotype.Component;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.time.ZoneId;
+import java.util.concurrent.atomic.AtomicReference;
+
+/**
+ * The clock dispute deadlines are measured by: the system clock moved
+ * forward by an offset, which tests advance to reach deadlines without
+ * waiting for them. The offset is per instance and lost on restart.
+ */
+@Component
+public class SimulatedClock extends Clock {
+    
+    private final Clock base;
+    private final AtomicReference<Duration> offset;
+    
+    @Autowired
+    public SimulatedClock() {
+        this(Clock.systemUTC());
+    }
+    
+    public SimulatedClock(Clock base) {
+        this(base, new AtomicReference<>(Duration.ZERO));
+    }
+    
+    private SimulatedClock(Clock base, AtomicReference<Duration> offset) {
+        this.base = base;
+        this.offset = offset;
+    }
+    
+    /**
+     * Move the clock forward
+     *
+     * @return The new offset from the system clock
+     */
+    public Duration advance(Duration duration) {
+        if (duration.isNegative()) {
+            throw new IllegalArgumentException("The clock only moves forward");
+        }
+        return offset.accumulateAndGet(duration, Duration::plus);
+    }
+    
+    /**
+     * Back to the system clock
+     */
+    public void reset() {
+        offset.set(Duration.ZERO);
+    }
+    
+    public Duration getOffset() {
+        return offset.get();
+    }
+    
+    @Override
+    public Instant instant() {
+        return base.instant().plus(offset.get());
+    }
+    
+    @Override
+    public ZoneId getZone() {
+        return base.getZone();
+    }
+    
+    @Override
+    public Clock withZone(ZoneId zone) {
+        // Shares the offset, so advancing one advances both
+        return new SimulatedClock(base.withZone(zone), offset);
+    }
+}
@@ -0,0 +1,55 @@
+package com.paymentgateway.settlement.controller;
+
+import java.time.OffsetDateTime;
+
+/**
+ * A chargeback notification from the acquirer
+ */
+public class ChargebackRequest {
+    
+    private String paymentId;
+    private String chargebackReference;
+    private String reasonCode;
+    private String reason;
+    private OffsetDateTime deadline;
+    
+    public String getPaymentId() {
+        return paymentId;
+    }
+    
+    public void setPaymentId(String paymentId) {
+        this.paymentId = paymentId;
+    }
+    
+    public String getChargebackReference() {
+        return chargebackReference;
+    }
+    
+    public void setChargebackReference(String chargebackReference) {
+        this.chargebackReference = chargebackReference;
+    }
+    
+    public String getReasonCode() {
+        return reasonCode;
+    }
+    
+    public void setReasonCode(String reasonCode) {
+        this.reasonCode = reasonCode;
+    }
+    
+    public String getReason() {
+        return reason;
+    }
+    
+    public void setReason(String reason) {
+        this.reason = reason;
+    }
+    
+    public OffsetDateTime getDeadline() {
+        return deadline;
+    }
+    
+    public void setDeadline(OffsetDateTime deadline) {
+        this.deadline = deadline;
+    }
+}
@@ -0,0 +1,72 @@
+package com.paymentgateway.settlement.controller;
+
+import com.paymentgateway.settlement.domain.Dispute;
+import com.paymentgateway.settlement.service.DisputeService;
+import org.springframework.http.HttpStatus;
+import org.springframework.http.MediaType;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+@RestController
+@RequestMapping("/api/v1/disputes")
+public class DisputeController {
+    
+    private final DisputeService disputeService;
+    
+    public DisputeController(DisputeService disputeService) {
+        this.disputeService = disputeService;
+    }
+    
+    /**
+     * Open a dispute from a chargeback notification, with its evidence
+     * bundle assembled
+     */
+    @PostMapping
+    public ResponseEntity<DisputeResponse> createDispute(@RequestBody ChargebackRequest request) {
+        if (request.getPaymentId() == null || request.getChargebackReference() == null) {
+            return ResponseEntity.badRequest().build();
+        }
+        try {
+            Dispute dispute = disputeService.createDisputeFromChargeback(request.getPaymentId(),
+                request.getChargebackReference(), request.getReasonCode(), request.getReason(),
+                request.getDeadline());
+            return ResponseEntity.status(HttpStatus.CREATED).body(new DisputeResponse(dispute));
+        } catch (IllegalArgumentException e) {
+            return ResponseEntity.notFound().build();
+        }
+    }
+    
+    @GetMapping("/{disputeId}")
+    public ResponseEntity<DisputeResponse> getDispute(@PathVariable("disputeId") String disputeId) {
+        try {
+            return ResponseEntity.ok(new DisputeResponse(disputeService.getDispute(disputeId)));
+        } catch (IllegalArgumentException e) {
+            return ResponseEntity.notFound().build();
+        }
+    }
+    
+    /**
+     * The evidence bundle alone, as submitted for representment
+     */
+    @GetMapping(value = "/{disputeId}/evidence", produces = MediaType.APPLICATION_JSON_VALUE)
+    public ResponseEntity<String> getEvidence(@PathVariable("disputeId") String disputeId) {
+        try {
+            String evidence = disputeService.getDispute(disputeId).getEvidenceBundle();
+            return evidence != null ? ResponseEntity.ok(evidence) : ResponseEntity.notFound().build();
+        } catch (IllegalArgumentException e) {
+            return ResponseEntity.notFound().build();
+        }
+    }
+    
+    /**
+     * Rebuild the evidence bundle with what happened since
+     */
+    @PostMapping("/{disputeId}/evidence")
+    public ResponseEntity<DisputeResponse> refreshEvidence(@PathVariable("disputeId") String disputeId) {
+        try {
+            return ResponseEntity.ok(new DisputeResponse(disputeService.refreshEvidence(disputeId)));
+        } catch (IllegalArgumentException e) {
+            return ResponseEntity.notFound().build();
+        }
+    }
+}
@@ -0,0 +1,87 @@
+package com.paymentgateway.settlement.controller;
+
+import com.fasterxml.jackson.annotation.JsonRawValue;
+import com.paymentgateway.settlement.domain.Dispute;
+
+import java.math.BigDecimal;
+import java.time.OffsetDateTime;
+import java.util.UUID;
+
+public class DisputeResponse {
+    
+    private final Dispute dispute;
+    
+    public DisputeResponse(Dispute dispute) {
+        this.dispute = dispute;
+    }
+    
+    public String getDisputeId() {
+        return dispute.getDisputeId();
+    }
+    
+    public UUID getPaymentId() {
+        return dispute.getPaymentId();
+    }
+    
+    public UUID getMerchantId() {
+        return dispute.getMerchantId();
+    }
+    
+    public BigDecimal getAmount() {
+        return dispute.getAmount();
+    }
+    
+    public String getCurrency() {
+        return dispute.getCurrency();
+    }
+    
+    public String getReasonCode() {
+        return dispute.getReasonCode();
+    }
+    
+    public String getReason() {
+        return dispute.getReason();
+    }
+    
+    public String getStatus() {
+        return dispute.getStatus();
+    }
+    
+    public String getChargebackReference() {
+        return dispute.getChargebackReference();
+    }
+    
+    public OffsetDateTime getDeadline() {
+        return dispute.getDeadline();
+    }
+    
+    public OffsetDateTime getReviewDeadline() {
+        return dispute.getReviewDeadline();
+    }
+    
+    public String getResolution() {
+        return dispute.getResolution();
+    }
+    
+    public OffsetDateTime getResolvedAt() {
+        return dispute.getResolvedAt();
+    }
+    
+    public OffsetDateTime getEvidenceSubmittedAt() {
+        return dispute.getEvidenceSubmittedAt();
+    }
+    
+    public OffsetDateTime getEvidenceGeneratedAt() {
+        return dispute.getEvidenceGeneratedAt();
+    }
+    
+    // The bundle is stored as JSON and returned as a nested document
+    @JsonRawValue
+    public String getEvidence() {
+        return dispute.getEvidenceBundle();
+    }
+    
+    public OffsetDateTime getCreatedAt() {
+        return dispute.getCreatedAt();
+    }
+}
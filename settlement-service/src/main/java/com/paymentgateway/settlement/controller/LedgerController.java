@@ -0,0 +1,65 @@
+package com.paymentgateway.settlement.controller;
+
+import com.paymentgateway.settlement.domain.Payout;
+import com.paymentgateway.settlement.repository.MerchantRepository;
+import com.paymentgateway.settlement.service.LedgerService;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.util.List;
+import java.util.UUID;
+
+@RestController
+@RequestMapping("/api/v1/settlement")
+public class LedgerController {
+    
+    private static final int MAX_LIMIT = 500;
+    
+    private final LedgerService ledgerService;
+    private final MerchantRepository merchantRepository;
+    
+    public LedgerController(LedgerService ledgerService, MerchantRepository merchantRepository) {
+        this.ledgerService = ledgerService;
+        this.merchantRepository = merchantRepository;
+    }
+    
+    /**
+     * A merchant's unpaid balances, latest ledger entries and reserve
+     */
+    @GetMapping("/ledger/{merchantId}")
+    public ResponseEntity<LedgerResponse> getLedger(
+            @PathVariable("merchantId") UUID merchantId,
+            @RequestParam(value = "limit", defaultValue = "50") int limit) {
+        
+        if (limit < 1 || limit > MAX_LIMIT) {
+            return ResponseEntity.badRequest().build();
+        }
+        if (!merchantRepository.existsById(merchantId)) {
+            return ResponseEntity.notFound().build();
+        }
+        return ResponseEntity.ok(new LedgerResponse(
+            merchantId,
+            ledgerService.balances(merchantId),
+            ledgerService.recentEntries(merchantId, limit),
+            ledgerService.reserves(merchantId),
+            ledgerService.reserveReleases(merchantId)
+        ));
+    }
+    
+    /**
+     * A merchant's latest payouts
+     */
+    @GetMapping("/payouts/{merchantId}")
+    public ResponseEntity<List<Payout>> getPayouts(
+            @PathVariable("merchantId") UUID merchantId,
+            @RequestParam(value = "limit", defaultValue = "50") int limit) {
+        
+        if (limit < 1 || limit > MAX_LIMIT) {
+            return ResponseEntity.badRequest().build();
+        }
+        if (!merchantRepository.existsById(merchantId)) {
+            return ResponseEntity.notFound().build();
+        }
+        return ResponseEntity.ok(ledgerService.recentPayouts(merchantId, limit));
+    }
+}
@@ -0,0 +1,72 @@
+package com.paymentgateway.settlement.controller;
+
+import com.paymentgateway.settlement.domain.LedgerEntry;
+import com.paymentgateway.settlement.service.ReserveRelease;
+
+import java.math.BigDecimal;
+import java.util.List;
+import java.util.Map;
+import java.util.UUID;
+
+public class LedgerResponse {
+    
+    private UUID merchantId;
+    // Unpaid balance by currency, paid out at the next cut-off if positive
+    private Map<String, BigDecimal> balances;
+    private List<LedgerEntry> entries;
+    // Rolling reserve still held by currency, and when it is due back
+    private Map<String, BigDecimal> reserves;
+    private List<ReserveRelease> reserveReleases;
+    
+    public LedgerResponse() {}
+    
+    public LedgerResponse(UUID merchantId, Map<String, BigDecimal> balances, List<LedgerEntry> entries,
+                          Map<String, BigDecimal> reserves, List<ReserveRelease> reserveReleases) {
+        this.merchantId = merchantId;
+        this.balances = balances;
+        this.entries = entries;
+        this.reserves = reserves;
+        this.reserveReleases = reserveReleases;
+    }
+    
+    // Getters and Setters
+    public UUID getMerchantId() {
+        return merchantId;
+    }
+    
+    public void setMerchantId(UUID merchantId) {
+        this.merchantId = merchantId;
+    }
+    
+    public Map<String, BigDecimal> getBalances() {
+        return balances;
+    }
+    
+    public void setBalances(Map<String, BigDecimal> balances) {
+        this.balances = balances;
+    }
+    
+    public List<LedgerEntry> getEntries() {
+        return entries;
+    }
+    
+    public void setEntries(List<LedgerEntry> entries) {
+        this.entries = entries;
+    }
+    
+    public Map<String, BigDecimal> getReserves() {
+        return reserves;
+    }
+    
+    public void setReserves(Map<String, BigDecimal> reserves) {
+        this.reserves = reserves;
+    }
+    
+    public List<ReserveRelease> getReserveReleases() {
+        return reserveReleases;
+    }
+    
+    public void setReserveReleases(List<ReserveRelease> reserveReleases) {
+        this.reserveReleases = reserveReleases;
+    }
+}
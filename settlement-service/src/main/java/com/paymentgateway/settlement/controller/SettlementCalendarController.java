@@ -0,0 +1,54 @@
+package com.paymentgateway.settlement.controller;
+
+import com.paymentgateway.settlement.calendar.CutoffSchedule;
+import com.paymentgateway.settlement.calendar.SettlementCalendar;
+import com.paymentgateway.settlement.calendar.SettlementCalendarResponse;
+import com.paymentgateway.settlement.domain.Merchant;
+import com.paymentgateway.settlement.repository.MerchantRepository;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.time.OffsetDateTime;
+import java.util.Optional;
+import java.util.UUID;
+
+@RestController
+@RequestMapping("/api/v1/settlement")
+public class SettlementCalendarController {
+    
+    private static final int MAX_DAYS = 90;
+    
+    private final SettlementCalendar calendar;
+    private final MerchantRepository merchantRepository;
+    
+    public SettlementCalendarController(SettlementCalendar calendar, MerchantRepository merchantRepository) {
+        this.calendar = calendar;
+        this.merchantRepository = merchantRepository;
+    }
+    
+    /**
+     * Upcoming cut-offs and bank holidays for a merchant
+     */
+    @GetMapping("/calendar/{merchantId}")
+    public ResponseEntity<SettlementCalendarResponse> getCalendar(
+            @PathVariable("merchantId") UUID merchantId,
+            @RequestParam(value = "days", defaultValue = "14") int days) {
+        
+        if (days < 1 || days > MAX_DAYS) {
+            return ResponseEntity.badRequest().build();
+        }
+        Optional<Merchant> merchant = merchantRepository.findById(merchantId);
+        if (merchant.isEmpty()) {
+            return ResponseEntity.notFound().build();
+        }
+        
+        CutoffSchedule schedule = calendar.scheduleFor(merchant.get());
+        OffsetDateTime now = OffsetDateTime.now();
+        return ResponseEntity.ok(new SettlementCalendarResponse(
+            merchantId,
+            schedule,
+            calendar.nextCutoff(schedule, now).toOffsetDateTime(),
+            calendar.upcoming(schedule, now, days)
+        ));
+    }
+}
@@ -0,0 +1,61 @@
+package com.paymentgateway.settlement.controller;
+
+import com.paymentgateway.settlement.clock.SimulatedClock;
+import com.paymentgateway.settlement.service.DisputeService;
+import org.springframework.http.ResponseEntity;
+import org.springframework.web.bind.annotation.*;
+
+import java.time.Duration;
+import java.time.format.DateTimeParseException;
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Moves the simulated clock so tests reach dispute deadlines without
+ * waiting. Disputes past their deadline are decided right after the clock
+ * moves, rather than at the next scheduled run.
+ */
+@RestController
+@RequestMapping("/api/v1/simulation/clock")
+public class SimulationClockController {
+    
+    private final SimulatedClock clock;
+    private final DisputeService disputeService;
+    
+    public SimulationClockController(SimulatedClock clock, DisputeService disputeService) {
+        this.clock = clock;
+        this.disputeService = disputeService;
+    }
+    
+    @GetMapping
+    public ResponseEntity<Map<String, Object>> getClock() {
+        return ResponseEntity.ok(state(0));
+    }
+    
+    /**
+     * Advance the clock by an ISO-8601 duration such as P21D or PT36H
+     */
+    @PostMapping("/advance")
+    public ResponseEntity<Map<String, Object>> advance(@RequestParam("by") String by) {
+        try {
+            clock.advance(Duration.parse(by));
+        } catch (DateTimeParseException | IllegalArgumentException e) {
+            return ResponseEntity.badRequest().build();
+        }
+        return ResponseEntity.ok(state(disputeService.expireOverdueDisputes()));
+    }
+    
+    @PostMapping("/reset")
+    public ResponseEntity<Map<String, Object>> reset() {
+        clock.reset();
+        return ResponseEntity.ok(state(0));
+    }
+    
+    private Map<String, Object> state(int disputesDecided) {
+        Map<String, Object> state = new LinkedHashMap<>();
+        state.put("now", clock.instant().toString());
+        state.put("offset", clock.getOffset().toString());
+        state.put("disputesDecided", disputesDecided);
+        return state;
+    }
+}
@@ -40,9 +40,14 @@ public class Dispute {
     @Column(name = "chargeback_reference")
     private String chargebackReference;
     
+    // Respond-by time of the merchant while OPEN
     @Column(name = "deadline")
     private OffsetDateTime deadline;
     
+    // Decision due from the issuer once evidence is submitted
+    @Column(name = "review_deadline")
+    private OffsetDateTime reviewDeadline;
+    
     @Column(name = "evidence_submitted_at")
     private OffsetDateTime evidenceSubmittedAt;
     
@@ -52,6 +57,13 @@ public class Dispute {
     @Column(name = "resolution")
     private String resolution;
     
+    // JSON representment evidence, see DisputeEvidenceBuilder
+    @Column(name = "evidence_bundle", columnDefinition = "TEXT")
+    private String evidenceBundle;
+    
+    @Column(name = "evidence_generated_at")
+    private OffsetDateTime evidenceGeneratedAt;
+    
     @Column(name = "created_at", nullable = false)
     private OffsetDateTime createdAt = OffsetDateTime.now();
     
@@ -161,6 +173,14 @@ public class Dispute {
         this.deadline = deadline;
     }
     
+    public OffsetDateTime getReviewDeadline() {
+        return reviewDeadline;
+    }
+    
+    public void setReviewDeadline(OffsetDateTime reviewDeadline) {
+        this.reviewDeadline = reviewDeadline;
+    }
+    
     public OffsetDateTime getEvidenceSubmittedAt() {
         return evidenceSubmittedAt;
     }
@@ -185,6 +205,22 @@ public class Dispute {
         this.resolution = resolution;
     }
     
+    public String getEvidenceBundle() {
+        return evidenceBundle;
+    }
+    
+    public void setEvidenceBundle(String evidenceBundle) {
+        this.evidenceBundle = evidenceBundle;
+    }
+    
+    public OffsetDateTime getEvidenceGeneratedAt() {
+        return evidenceGeneratedAt;
+    }
+    
+    public void setEvidenceGeneratedAt(OffsetDateTime evidenceGeneratedAt) {
+        this.evidenceGeneratedAt = evidenceGeneratedAt;
+    }
+    
     public OffsetDateTime getCreatedAt() {
         return createdAt;
     }
@@ -0,0 +1,163 @@
+package com.paymentgateway.settlement.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.time.OffsetDateTime;
+import java.util.UUID;
+
+/**
+ * A credit (positive) or debit (negative) to a merchant's balance from a
+ * settled payment, unpaid until a payout takes it
+ */
+@Entity
+@Table(name = "ledger_entries")
+public class LedgerEntry {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    @Column(name = "batch_id", nullable = false)
+    private UUID batchId;
+    
+    @Column(name = "payment_id", nullable = false)
+    private UUID paymentId;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "entry_type", nullable = false, length = 20)
+    private LedgerEntryType entryType;
+    
+    @Column(nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(nullable = false, length = 3)
+    private String currency;
+    
+    @Column(name = "payout_id")
+    private UUID payoutId;
+    
+    // The refund or dispute an entry reverses the payment for
+    @Column(name = "reference", length = 100)
+    private String reference;
+    
+    // When a RESERVE_HOLD is due back, and when it was released
+    @Column(name = "release_at")
+    private OffsetDateTime releaseAt;
+    
+    @Column(name = "released_at")
+    private OffsetDateTime releasedAt;
+    
+    @Column(name = "created_at", nullable = false)
+    private OffsetDateTime createdAt = OffsetDateTime.now();
+    
+    public LedgerEntry() {}
+    
+    public LedgerEntry(UUID merchantId, UUID batchId, UUID paymentId, LedgerEntryType entryType,
+                       BigDecimal amount, String currency) {
+        this.merchantId = merchantId;
+        this.batchId = batchId;
+        this.paymentId = paymentId;
+        this.entryType = entryType;
+        this.amount = amount;
+        this.currency = currency;
+    }
+    
+    public UUID getId() {
+        return id;
+    }
+    
+    public void setId(UUID id) {
+        this.id = id;
+    }
+    
+    public UUID getMerchantId() {
+        return merchantId;
+    }
+    
+    public void setMerchantId(UUID merchantId) {
+        this.merchantId = merchantId;
+    }
+    
+    public UUID getBatchId() {
+        return batchId;
+    }
+    
+    public void setBatchId(UUID batchId) {
+        this.batchId = batchId;
+    }
+    
+    public UUID getPaymentId() {
+        return paymentId;
+    }
+    
+    public void setPaymentId(UUID paymentId) {
+        this.paymentId = paymentId;
+    }
+    
+    public LedgerEntryType getEntryType() {
+        return entryType;
+    }
+    
+    public void setEntryType(LedgerEntryType entryType) {
+        this.entryType = entryType;
+    }
+    
+    public BigDecimal getAmount() {
+        return amount;
+    }
+    
+    public void setAmount(BigDecimal amount) {
+        this.amount = amount;
+    }
+    
+    public String getCurrency() {
+        return currency;
+    }
+    
+    public void setCurrency(String currency) {
+        this.currency = currency;
+    }
+    
+    public UUID getPayoutId() {
+        return payoutId;
+    }
+    
+    public void setPayoutId(UUID payoutId) {
+        this.payoutId = payoutId;
+    }
+    
+    public String getReference() {
+        return reference;
+    }
+    
+    public void setReference(String reference) {
+        this.reference = reference;
+    }
+    
+    public OffsetDateTime getCreatedAt() {
+        return createdAt;
+    }
+    
+    public void setCreatedAt(OffsetDateTime createdAt) {
+        this.createdAt = createdAt;
+    }
+    
+    public OffsetDateTime getReleaseAt() {
+        return releaseAt;
+    }
+    
+    public void setReleaseAt(OffsetDateTime releaseAt) {
+        this.releaseAt = releaseAt;
+    }
+    
+    public OffsetDateTime getReleasedAt() {
+        return releasedAt;
+    }
+    
+    public void setReleasedAt(OffsetDateTime releasedAt) {
+        this.releasedAt = releasedAt;
+    }
+}
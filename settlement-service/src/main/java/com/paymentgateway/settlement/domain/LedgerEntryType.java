@@ -0,0 +1,24 @@
+package com.paymentgateway.settlement.domain;
+
+/**
+ * What a ledger entry records. A settled payment credits its gross amount
+ * to the merchant of record and debits the processing fee; each split moves
+ * its amount from the platform to the sub-merchant and the platform's fee
+ * back, so a payment's entries add up to its net amount. A rolling reserve
+ * holds a share of a sale back and releases it after the hold period. A
+ * refund or lost chargeback debits the merchant of record, which takes each
+ * split's share of it back from the sub-merchant, and its fee on that share
+ * if fees are returned.
+ */
+public enum LedgerEntryType {
+    PAYMENT,
+    PROCESSING_FEE,
+    SPLIT,
+    SPLIT_FEE,
+    RESERVE_HOLD,
+    RESERVE_RELEASE,
+    REFUND,
+    CHARGEBACK,
+    SPLIT_REVERSAL,
+    SPLIT_FEE_REVERSAL
+}
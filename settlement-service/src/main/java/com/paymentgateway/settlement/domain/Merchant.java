@@ -0,0 +1,85 @@
+package com.paymentgateway.settlement.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.time.LocalTime;
+import java.util.UUID;
+
+/**
+ * The settlement view of a merchant: its settlement schedule and rolling
+ * reserve. Merchants are managed by the authorization service.
+ */
+@Entity
+@Table(name = "merchants")
+public class Merchant {
+    
+    @Id
+    private UUID id;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private String merchantId;
+    
+    // IANA zone ID, e.g. Europe/London; null uses the service default
+    @Column(name = "settlement_timezone", length = 64)
+    private String settlementTimezone;
+    
+    // Local time after which captures roll to the next settlement date
+    @Column(name = "settlement_cutoff_time")
+    private LocalTime settlementCutoffTime;
+    
+    // Rolling reserve; null uses the service default
+    @Column(name = "reserve_percentage", precision = 5, scale = 2)
+    private BigDecimal reservePercentage;
+    
+    @Column(name = "reserve_hold_days")
+    private Integer reserveHoldDays;
+    
+    // Getters and Setters
+    public UUID getId() {
+        return id;
+    }
+    
+    public void setId(UUID id) {
+        this.id = id;
+    }
+    
+    public String getMerchantId() {
+        return merchantId;
+    }
+    
+    public void setMerchantId(String merchantId) {
+        this.merchantId = merchantId;
+    }
+    
+    public String getSettlementTimezone() {
+        return settlementTimezone;
+    }
+    
+    public void setSettlementTimezone(String settlementTimezone) {
+        this.settlementTimezone = settlementTimezone;
+    }
+    
+    public LocalTime getSettlementCutoffTime() {
+        return settlementCutoffTime;
+    }
+    
+    public void setSettlementCutoffTime(LocalTime settlementCutoffTime) {
+        this.settlementCutoffTime = settlementCutoffTime;
+    }
+    
+    public BigDecimal getReservePercentage() {
+        return reservePercentage;
+    }
+    
+    public void setReservePercentage(BigDecimal reservePercentage) {
+        this.reservePercentage = reservePercentage;
+    }
+    
+    public Integer getReserveHoldDays() {
+        return reserveHoldDays;
+    }
+    
+    public void setReserveHoldDays(Integer reserveHoldDays) {
+        this.reserveHoldDays = reserveHoldDays;
+    }
+}
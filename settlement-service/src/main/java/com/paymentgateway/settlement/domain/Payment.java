@@ -3,6 +3,8 @@ package com.paymentgateway.settlement.domain;
 import jakarta.persistence.*;
 import java.math.BigDecimal;
 import java.time.OffsetDateTime;
+import java.util.ArrayList;
+import java.util.List;
 import java.util.UUID;
 
 @Entity
@@ -28,6 +30,9 @@ public class Payment {
     @Column(nullable = false)
     private String status;
     
+    @Column(name = "transaction_type")
+    private String transactionType = "AUTHORIZATION";
+    
     @Column(name = "created_at")
     private OffsetDateTime createdAt;
     
@@ -37,6 +42,41 @@ public class Payment {
     @Column(name = "settled_at")
     private OffsetDateTime settledAt;
     
+    @Column(name = "statement_descriptor", length = 25)
+    private String statementDescriptor;
+    
+    // Level 2/3 purchasing data, see InterchangeProgram
+    @Column(name = "tax_amount", precision = 12, scale = 2)
+    private BigDecimal taxAmount;
+    
+    @Column(name = "customer_code", length = 17)
+    private String customerCode;
+    
+    @Column(name = "purchase_data_level")
+    private Integer purchaseDataLevel;
+    
+    // Installment plan, cleared with the payment for the issuer to bill
+    @Column(name = "installment_count")
+    private Integer installmentCount;
+    
+    @Column(name = "installment_plan_type", length = 10)
+    private String installmentPlanType;
+    
+    // Card issued outside the merchant's country; pays the cross-border markup
+    @Column(name = "cross_border", nullable = false)
+    private boolean crossBorder;
+    
+    @ElementCollection
+    @CollectionTable(name = "payment_line_items", joinColumns = @JoinColumn(name = "payment_id"))
+    @OrderColumn(name = "line_number")
+    private List<PaymentLineItem> lineItems = new ArrayList<>();
+    
+    // Marketplace portions for the merchant's sub-merchants
+    @ElementCollection
+    @CollectionTable(name = "payment_splits", joinColumns = @JoinColumn(name = "payment_id"))
+    @OrderColumn(name = "split_number")
+    private List<PaymentSplit> splits = new ArrayList<>();
+    
     // Getters and Setters
     public UUID getId() {
         return id;
@@ -86,6 +126,22 @@ public class Payment {
         this.status = status;
     }
     
+    public String getTransactionType() {
+        return transactionType;
+    }
+    
+    public void setTransactionType(String transactionType) {
+        this.transactionType = transactionType;
+    }
+    
+    /**
+     * A standalone credit pays money out to the cardholder, so it settles
+     * against the merchant rather than in its favour
+     */
+    public boolean isCredit() {
+        return "CREDIT".equals(transactionType);
+    }
+    
     public OffsetDateTime getCreatedAt() {
         return createdAt;
     }
@@ -109,4 +165,76 @@ public class Payment {
     public void setSettledAt(OffsetDateTime settledAt) {
         this.settledAt = settledAt;
     }
+    
+    public String getStatementDescriptor() {
+        return statementDescriptor;
+    }
+    
+    public void setStatementDescriptor(String statementDescriptor) {
+        this.statementDescriptor = statementDescriptor;
+    }
+    
+    public BigDecimal getTaxAmount() {
+        return taxAmount;
+    }
+    
+    public void setTaxAmount(BigDecimal taxAmount) {
+        this.taxAmount = taxAmount;
+    }
+    
+    public String getCustomerCode() {
+        return customerCode;
+    }
+    
+    public void setCustomerCode(String customerCode) {
+        this.customerCode = customerCode;
+    }
+    
+    public Integer getPurchaseDataLevel() {
+        return purchaseDataLevel;
+    }
+    
+    public void setPurchaseDataLevel(Integer purchaseDataLevel) {
+        this.purchaseDataLevel = purchaseDataLevel;
+    }
+    
+    public Integer getInstallmentCount() {
+        return installmentCount;
+    }
+    
+    public void setInstallmentCount(Integer installmentCount) {
+        this.installmentCount = installmentCount;
+    }
+    
+    public String getInstallmentPlanType() {
+        return installmentPlanType;
+    }
+    
+    public void setInstallmentPlanType(String installmentPlanType) {
+        this.installmentPlanType = installmentPlanType;
+    }
+    
+    public boolean isCrossBorder() {
+        return crossBorder;
+    }
+    
+    public void setCrossBorder(boolean crossBorder) {
+        this.crossBorder = crossBorder;
+    }
+    
+    public List<PaymentLineItem> getLineItems() {
+        return lineItems;
+    }
+    
+    public void setLineItems(List<PaymentLineItem> lineItems) {
+        this.lineItems = lineItems;
+    }
+    
+    public List<PaymentSplit> getSplits() {
+        return splits;
+    }
+    
+    public void setSplits(List<PaymentSplit> splits) {
+        this.splits = splits;
+    }
 }
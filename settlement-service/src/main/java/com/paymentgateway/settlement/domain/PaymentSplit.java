@@ -0,0 +1,54 @@
+package com.paymentgateway.settlement.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.util.UUID;
+
+/**
+ * A marketplace portion of a payment for a sub-merchant of the merchant,
+ * posted to both ledgers in settlement with the platform's fee on it
+ */
+@Embeddable
+public class PaymentSplit {
+    
+    @Column(name = "sub_merchant_id", nullable = false)
+    private UUID subMerchantId;
+    
+    @Column(name = "amount", nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(name = "fee_amount", nullable = false, precision = 12, scale = 2)
+    private BigDecimal feeAmount = BigDecimal.ZERO;
+    
+    public PaymentSplit() {}
+    
+    public PaymentSplit(UUID subMerchantId, BigDecimal amount, BigDecimal feeAmount) {
+        this.subMerchantId = subMerchantId;
+        this.amount = amount;
+        this.feeAmount = feeAmount;
+    }
+    
+    public UUID getSubMerchantId() {
+        return subMerchantId;
+    }
+    
+    public void setSubMerchantId(UUID subMerchantId) {
+        this.subMerchantId = subMerchantId;
+    }
+    
+    public BigDecimal getAmount() {
+        return amount;
+    }
+    
+    public void setAmount(BigDecimal amount) {
+        this.amount = amount;
+    }
+    
+    public BigDecimal getFeeAmount() {
+        return feeAmount;
+    }
+    
+    public void setFeeAmount(BigDecimal feeAmount) {
+        this.feeAmount = feeAmount;
+    }
+}
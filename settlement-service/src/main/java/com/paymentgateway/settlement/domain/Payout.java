@@ -0,0 +1,103 @@
+package com.paymentgateway.settlement.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.time.OffsetDateTime;
+import java.util.UUID;
+
+/**
+ * A transfer of a merchant's unpaid ledger balance in one currency to the
+ * merchant's bank account (simulated)
+ */
+@Entity
+@Table(name = "payouts")
+public class Payout {
+    
+    @Id
+    @GeneratedValue(strategy = GenerationType.AUTO)
+    private UUID id;
+    
+    @Column(name = "payout_id", unique = true, nullable = false, length = 30)
+    private String payoutId;
+    
+    @Column(name = "merchant_id", nullable = false)
+    private UUID merchantId;
+    
+    @Column(nullable = false, length = 3)
+    private String currency;
+    
+    @Column(nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(name = "entry_count", nullable = false)
+    private Integer entryCount;
+    
+    @Column(name = "created_at", nullable = false)
+    private OffsetDateTime createdAt = OffsetDateTime.now();
+    
+    public Payout() {}
+    
+    public Payout(String payoutId, UUID merchantId, String currency, BigDecimal amount, Integer entryCount) {
+        this.payoutId = payoutId;
+        this.merchantId = merchantId;
+        this.currency = currency;
+        this.amount = amount;
+        this.entryCount = entryCount;
+    }
+    
+    public UUID getId() {
+        return id;
+    }
+    
+    public void setId(UUID id) {
+        this.id = id;
+    }
+    
+    public String getPayoutId() {
+        return payoutId;
+    }
+    
+    public void setPayoutId(String payoutId) {
+        this.payoutId = payoutId;
+    }
+    
+    public UUID getMerchantId() {
+        return merchantId;
+    }
+    
+    public void setMerchantId(UUID merchantId) {
+        this.merchantId = merchantId;
+    }
+    
+    public String getCurrency() {
+        return currency;
+    }
+    
+    public void setCurrency(String currency) {
+        this.currency = currency;
+    }
+    
+    public BigDecimal getAmount() {
+        return amount;
+    }
+    
+    public void setAmount(BigDecimal amount) {
+        this.amount = amount;
+    }
+    
+    public Integer getEntryCount() {
+        return entryCount;
+    }
+    
+    public void setEntryCount(Integer entryCount) {
+        this.entryCount = entryCount;
+    }
+    
+    public OffsetDateTime getCreatedAt() {
+        return createdAt;
+    }
+    
+    public void setCreatedAt(OffsetDateTime createdAt) {
+        this.createdAt = createdAt;
+    }
+}
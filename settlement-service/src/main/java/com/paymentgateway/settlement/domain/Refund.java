@@ -0,0 +1,104 @@
+package com.paymentgateway.settlement.domain;
+
+import jakarta.persistence.*;
+import java.math.BigDecimal;
+import java.time.OffsetDateTime;
+import java.util.UUID;
+
+/**
+ * A refund of a payment, as the authorization service processed it. Only
+ * read here: completed refunds are posted to the merchants' ledgers.
+ */
+@Entity
+@Table(name = "refunds")
+public class Refund {
+    
+    public static final String COMPLETED = "COMPLETED";
+    
+    @Id
+    private UUID id;
+    
+    @Column(name = "refund_id", unique = true, nullable = false)
+    private String refundId;
+    
+    @Column(name = "payment_id", nullable = false)
+    private UUID paymentId;
+    
+    @Column(nullable = false, precision = 12, scale = 2)
+    private BigDecimal amount;
+    
+    @Column(nullable = false, length = 3)
+    private String currency;
+    
+    @Column(nullable = false)
+    private String status;
+    
+    @Column(name = "processed_at")
+    private OffsetDateTime processedAt;
+    
+    public Refund() {}
+    
+    public Refund(String refundId, UUID paymentId, BigDecimal amount, String currency) {
+        this.refundId = refundId;
+        this.paymentId = paymentId;
+        this.amount = amount;
+        this.currency = currency;
+        this.status = COMPLETED;
+    }
+    
+    public UUID getId() {
+        return id;
+    }
+    
+    public void setId(UUID id) {
+        this.id = id;
+    }
+    
+    public String getRefundId() {
+        return refundId;
+    }
+    
+    public void setRefundId(String refundId) {
+        this.refundId = refundId;
+    }
+    
+    public UUID getPaymentId() {
+        return paymentId;
+    }
+    
+    public void setPaymentId(UUID paymentId) {
+        this.paymentId = paymentId;
+    }
+    
+    public BigDecimal getAmount() {
+        return amount;
+    }
+    
+    public void setAmount(BigDecimal amount) {
+        this.amount = amount;
+    }
+    
+    public String getCurrency() {
+        return currency;
+    }
+    
+    public void setCurrency(String currency) {
+        this.currency = currency;
+    }
+    
+    public String getStatus() {
+        return status;
+    }
+    
+    public void setStatus(String status) {
+        this.status = status;
+    }
+    
+    public OffsetDateTime getProcessedAt() {
+        return processedAt;
+    }
+    
+    public void setProcessedAt(OffsetDateTime processedAt) {
+        this.processedAt = processedAt;
+    }
+}
@@ -31,6 +31,36 @@ public class SettlementTransaction {
     @Column(nullable = false, length = 3)
     private String currency;
     
+    // The payment's descriptor, as it was cleared
+    @Column(name = "statement_descriptor", length = 25)
+    private String statementDescriptor;
+    
+    // Purchasing data as cleared, and the interchange program it qualified
+    // for; credits have no program
+    @Column(name = "tax_amount", precision = 12, scale = 2)
+    private BigDecimal taxAmount;
+    
+    @Column(name = "customer_code", length = 17)
+    private String customerCode;
+    
+    @Column(name = "purchase_data_level")
+    private Integer purchaseDataLevel;
+    
+    @Enumerated(EnumType.STRING)
+    @Column(name = "interchange_program", length = 20)
+    private InterchangeProgram interchangeProgram;
+    
+    // Installment plan as cleared: the count and MERCHANT or ISSUER
+    @Column(name = "installment_count")
+    private Integer installmentCount;
+    
+    @Column(name = "installment_plan_type", length = 10)
+    private String installmentPlanType;
+    
+    // Cross-border markup, included in the fee; null for domestic cards
+    @Column(name = "cross_border_fee", precision = 12, scale = 2)
+    private BigDecimal crossBorderFee;
+    
     @Column(name = "created_at", nullable = false)
     private OffsetDateTime createdAt = OffsetDateTime.now();
     
@@ -104,6 +134,70 @@ public class SettlementTransaction {
         this.currency = currency;
     }
     
+    public String getStatementDescriptor() {
+        return statementDescriptor;
+    }
+    
+    public void setStatementDescriptor(String statementDescriptor) {
+        this.statementDescriptor = statementDescriptor;
+    }
+    
+    public BigDecimal getTaxAmount() {
+        return taxAmount;
+    }
+    
+    public void setTaxAmount(BigDecimal taxAmount) {
+        this.taxAmount = taxAmount;
+    }
+    
+    public String getCustomerCode() {
+        return customerCode;
+    }
+    
+    public void setCustomerCode(String customerCode) {
+        this.customerCode = customerCode;
+    }
+    
+    public Integer getPurchaseDataLevel() {
+        return purchaseDataLevel;
+    }
+    
+    public void setPurchaseDataLevel(Integer purchaseDataLevel) {
+        this.purchaseDataLevel = purchaseDataLevel;
+    }
+    
+    public InterchangeProgram getInterchangeProgram() {
+        return interchangeProgram;
+    }
+    
+    public void setInterchangeProgram(InterchangeProgram interchangeProgram) {
+        this.interchangeProgram = interchangeProgram;
+    }
+    
+    public Integer getInstallmentCount() {
+        return installmentCount;
+    }
+    
+    public void setInstallmentCount(Integer installmentCount) {
+        this.installmentCount = installmentCount;
+    }
+    
+    public String getInstallmentPlanType() {
+        return installmentPlanType;
+    }
+    
+    public void setInstallmentPlanType(String installmentPlanType) {
+        this.installmentPlanType = installmentPlanType;
+    }
+    
+    public BigDecimal getCrossBorderFee() {
+        return crossBorderFee;
+    }
+    
+    public void setCrossBorderFee(BigDecimal crossBorderFee) {
+        this.crossBorderFee = crossBorderFee;
+    }
+    
     public OffsetDateTime getCreatedAt() {
         return createdAt;
     }
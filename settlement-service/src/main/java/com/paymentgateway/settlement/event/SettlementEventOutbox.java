@@ -0,0 +1,92 @@
+package com.paymentgateway.settlement.event;
+
+import com.fasterxml.jackson.core.JsonProcessingException;
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.paymentgateway.settlement.domain.Dispute;
+import com.paymentgateway.settlement.domain.Payment;
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.stereotype.Repository;
+
+import java.time.Instant;
+import java.util.LinkedHashMap;
+import java.util.Map;
+import java.util.UUID;
+
+/**
+ * Appends PAYMENT_SETTLED and dispute events to the outbox shared with the
+ * authorization service, whose dispatcher publishes them to Kafka and
+ * merchant webhooks like its own events. Appends join the settlement or
+ * dispute transaction, so an event exists exactly when the change was made.
+ */
+@Repository
+public class SettlementEventOutbox {
+    
+    public static final String PAYMENT_SETTLED = "PAYMENT_SETTLED";
+    public static final String DISPUTE_OPENED = "DISPUTE_OPENED";
+    public static final String DISPUTE_EVIDENCE_SUBMITTED = "DISPUTE_EVIDENCE_SUBMITTED";
+    public static final String DISPUTE_WON = "DISPUTE_WON";
+    public static final String DISPUTE_LOST = "DISPUTE_LOST";
+    
+    private static final String APPEND =
+        "INSERT INTO outbox_events (event_id, event_type, partition_key, payment_id, merchant_id, payload, " +
+        "created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())";
+    
+    private final JdbcTemplate jdbcTemplate;
+    private final ObjectMapper objectMapper;
+    
+    public SettlementEventOutbox(JdbcTemplate jdbcTemplate, ObjectMapper objectMapper) {
+        this.jdbcTemplate = jdbcTemplate;
+        this.objectMapper = objectMapper;
+    }
+    
+    /**
+     * Record that a payment was settled in a batch
+     */
+    public void paymentSettled(Payment payment, String batchId) {
+        // Same shape as the authorization service's PaymentEventMessage
+        Map<String, Object> payload = new LinkedHashMap<>();
+        payload.put("payment_id", payment.getPaymentId());
+        payload.put("merchant_id", payment.getMerchantId().toString());
+        payload.put("amount", payment.getAmount());
+        payload.put("currency", payment.getCurrency());
+        payload.put("status", payment.getStatus());
+        
+        // No tracing here; the batch ties the events of one run together
+        append(PAYMENT_SETTLED, payment, batchId, payload);
+    }
+    
+    /**
+     * Record that a dispute on a payment changed stage; the status is the
+     * dispute's
+     */
+    public void disputeChanged(String eventType, Dispute dispute, Payment payment) {
+        Map<String, Object> payload = new LinkedHashMap<>();
+        payload.put("payment_id", payment.getPaymentId());
+        payload.put("merchant_id", dispute.getMerchantId().toString());
+        payload.put("amount", dispute.getAmount());
+        payload.put("currency", dispute.getCurrency());
+        payload.put("status", dispute.getStatus());
+        payload.put("dispute_id", dispute.getDisputeId());
+        
+        append(eventType, payment, dispute.getDisputeId(), payload);
+    }
+    
+    private void append(String eventType, Payment payment, String traceId, Map<String, Object> payload) {
+        String eventId = "evt_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24);
+        
+        Map<String, Object> event = new LinkedHashMap<>();
+        event.put("event_id", eventId);
+        event.put("event_type", eventType);
+        event.put("timestamp", Instant.now().toString());
+        event.put("correlation_id", payment.getPaymentId());
+        event.put("trace_id", traceId);
+        event.put("payload", payload);
+        
+        try {
+            jdbcTemplate.update(APPEND, eventId, eventType, payment.getPaymentId(),
+                    payment.getId(), payment.getMerchantId(), objectMapper.writeValueAsString(event));
+        } catch (JsonProcessingException e) {
+            throw new IllegalStateException("Failed to serialize settlement event", e);
+        }
+    }
+}
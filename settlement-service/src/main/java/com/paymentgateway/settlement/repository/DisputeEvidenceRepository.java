@@ -0,0 +1,67 @@
+package com.paymentgateway.settlement.repository;
+
+import org.springframework.dao.EmptyResultDataAccessException;
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Reads what the authorization service recorded about a payment - its
+ * authorization and verification results, timeline, merchant webhook
+ * deliveries and refunds - for the evidence bundle of a dispute. Rows are
+ * returned as column maps keyed by column name.
+ */
+@Repository
+public class DisputeEvidenceRepository {
+    
+    private static final String AUTHORIZATION =
+        "SELECT payment_id, amount, currency, status::text AS status, transaction_type::text AS transaction_type, " +
+        "description, reference_id, statement_descriptor, card_brand::text AS card_brand, card_last_four, " +
+        "psp_name, acquirer_reference, network_transaction_id, transaction_channel, initiator, stored_credential, " +
+        "avs_result, cvv_result, billing_city, billing_zip, billing_country, " +
+        "three_ds_status::text AS three_ds_status, three_ds_eci, three_ds_transaction_id, " +
+        "three_ds_cavv IS NOT NULL AS three_ds_cavv_present, sca_exemption, " +
+        "created_at, authorized_at, captured_at, settled_at FROM payments WHERE id = ?";
+    
+    private static final String TIMELINE =
+        "SELECT event_type, event_status, amount, currency, description, error_code, created_at " +
+        "FROM payment_events WHERE payment_id = ? ORDER BY created_at";
+    
+    private static final String WEBHOOK_DELIVERIES =
+        "SELECT event_type, status, http_status_code, attempt_count, created_at, delivered_at " +
+        "FROM webhook_deliveries WHERE payment_id = ? ORDER BY created_at";
+    
+    private static final String REFUNDS =
+        "SELECT refund_id, amount, currency, status::text AS status, reason, created_at, processed_at " +
+        "FROM refunds WHERE payment_id = ? ORDER BY created_at";
+    
+    private final JdbcTemplate jdbcTemplate;
+    
+    public DisputeEvidenceRepository(JdbcTemplate jdbcTemplate) {
+        this.jdbcTemplate = jdbcTemplate;
+    }
+    
+    public Optional<Map<String, Object>> findAuthorization(UUID paymentId) {
+        try {
+            return Optional.of(jdbcTemplate.queryForMap(AUTHORIZATION, paymentId));
+        } catch (EmptyResultDataAccessException e) {
+            return Optional.empty();
+        }
+    }
+    
+    public List<Map<String, Object>> findTimeline(UUID paymentId) {
+        return jdbcTemplate.queryForList(TIMELINE, paymentId);
+    }
+    
+    public List<Map<String, Object>> findWebhookDeliveries(UUID paymentId) {
+        return jdbcTemplate.queryForList(WEBHOOK_DELIVERIES, paymentId);
+    }
+    
+    public List<Map<String, Object>> findRefunds(UUID paymentId) {
+        return jdbcTemplate.queryForList(REFUNDS, paymentId);
+    }
+}
@@ -4,6 +4,7 @@ import com.paymentgateway.settlement.domain.Dispute;
 import org.springframework.data.jpa.repository.JpaRepository;
 import org.springframework.stereotype.Repository;
 
+import java.time.OffsetDateTime;
 import java.util.List;
 import java.util.Optional;
 import java.util.UUID;
@@ -14,4 +15,6 @@ public interface DisputeRepository extends JpaRepository<Dispute, UUID> {
     List<Dispute> findByPaymentId(UUID paymentId);
     List<Dispute> findByMerchantId(UUID merchantId);
     List<Dispute> findByStatus(String status);
+    List<Dispute> findByStatusAndDeadlineBefore(String status, OffsetDateTime deadline);
+    List<Dispute> findByStatusAndReviewDeadlineBefore(String status, OffsetDateTime reviewDeadline);
 }
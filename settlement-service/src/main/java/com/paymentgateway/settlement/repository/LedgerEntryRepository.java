@@ -0,0 +1,23 @@
+package com.paymentgateway.settlement.repository;
+
+import com.paymentgateway.settlement.domain.LedgerEntry;
+import com.paymentgateway.settlement.domain.LedgerEntryType;
+import org.springframework.data.domain.Pageable;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.time.OffsetDateTime;
+import java.util.List;
+import java.util.UUID;
+
+@Repository
+public interface LedgerEntryRepository extends JpaRepository<LedgerEntry, UUID> {
+    List<LedgerEntry> findByPayoutIdIsNull();
+    List<LedgerEntry> findByPaymentId(UUID paymentId);
+    List<LedgerEntry> findByMerchantIdAndPayoutIdIsNull(UUID merchantId);
+    List<LedgerEntry> findByMerchantIdOrderByCreatedAtDesc(UUID merchantId, Pageable pageable);
+    List<LedgerEntry> findByEntryTypeAndReleasedAtIsNullAndReleaseAtLessThanEqual(LedgerEntryType entryType,
+                                                                               OffsetDateTime releaseAt);
+    List<LedgerEntry> findByMerchantIdAndEntryTypeAndReleasedAtIsNullOrderByReleaseAt(UUID merchantId,
+                                                                                    LedgerEntryType entryType);
+}
@@ -0,0 +1,11 @@
+package com.paymentgateway.settlement.repository;
+
+import com.paymentgateway.settlement.domain.Merchant;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.UUID;
+
+@Repository
+public interface MerchantRepository extends JpaRepository<Merchant, UUID> {
+}
@@ -0,0 +1,14 @@
+package com.paymentgateway.settlement.repository;
+
+import com.paymentgateway.settlement.domain.Payout;
+import org.springframework.data.domain.Pageable;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.UUID;
+
+@Repository
+public interface PayoutRepository extends JpaRepository<Payout, UUID> {
+    List<Payout> findByMerchantIdOrderByCreatedAtDesc(UUID merchantId, Pageable pageable);
+}
@@ -0,0 +1,23 @@
+package com.paymentgateway.settlement.repository;
+
+import com.paymentgateway.settlement.domain.Refund;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.data.jpa.repository.Query;
+import org.springframework.stereotype.Repository;
+
+import java.util.List;
+import java.util.UUID;
+
+@Repository
+public interface RefundRepository extends JpaRepository<Refund, UUID> {
+    
+    /**
+     * Completed refunds of payments already posted to the ledgers that
+     * have not been posted themselves, oldest first
+     */
+    @Query(value = "SELECT r.* FROM refunds r WHERE r.status::text = 'COMPLETED' " +
+        "AND EXISTS (SELECT 1 FROM ledger_entries e WHERE e.payment_id = r.payment_id AND e.entry_type = 'PAYMENT') " +
+        "AND NOT EXISTS (SELECT 1 FROM ledger_entries e WHERE e.reference = r.refund_id) " +
+        "ORDER BY r.processed_at", nativeQuery = true)
+    List<Refund> findUnpostedCompletedRefunds();
+}
@@ -0,0 +1,35 @@
+package com.paymentgateway.settlement.scheduling;
+
+import com.paymentgateway.settlement.service.DisputeService;
+import com.paymentgateway.shared.scheduling.SingletonJobRunner;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.time.Clock;
+import java.time.Instant;
+import java.time.temporal.ChronoUnit;
+
+/**
+ * Decides disputes past their stage deadline, on the leader only. Runs are
+ * named by the system clock; deadlines are measured by the simulated one.
+ */
+@Component
+public class DisputeDeadlineScheduler {
+    
+    static final String DISPUTE_DEADLINE_JOB = "dispute-deadlines";
+    
+    private final SingletonJobRunner runner;
+    private final DisputeService disputeService;
+    private final Clock clock = Clock.systemUTC();
+    
+    public DisputeDeadlineScheduler(SingletonJobRunner runner, DisputeService disputeService) {
+        this.runner = runner;
+        this.disputeService = disputeService;
+    }
+    
+    @Scheduled(cron = "${settlement.disputes.deadline-cron:0 * * * * *}")
+    public SingletonJobRunner.Outcome expireOverdueDisputes() {
+        Instant slot = clock.instant().truncatedTo(ChronoUnit.MINUTES);
+        return runner.run(DISPUTE_DEADLINE_JOB, slot, disputeService::expireOverdueDisputes);
+    }
+}
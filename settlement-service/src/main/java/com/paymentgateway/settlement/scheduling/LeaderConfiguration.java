@@ -0,0 +1,39 @@
+package com.paymentgateway.settlement.scheduling;
+
+import com.paymentgateway.shared.scheduling.JobLeaseRepository;
+import com.paymentgateway.shared.scheduling.LeaderElection;
+import com.paymentgateway.shared.scheduling.SingletonJobRunner;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.context.annotation.Bean;
+import org.springframework.context.annotation.Configuration;
+import org.springframework.jdbc.core.JdbcTemplate;
+
+import java.time.Duration;
+
+/**
+ * The settlement-service lease and the runner its singleton jobs go through
+ */
+@Configuration
+public class LeaderConfiguration {
+    
+    static final String LEASE_NAME = "settlement-service";
+    
+    @Bean
+    public JobLeaseRepository jobLeaseRepository(JdbcTemplate jdbcTemplate) {
+        return new JobLeaseRepository(jdbcTemplate);
+    }
+    
+    // Gives up the lease on shutdown so another instance takes over without
+    // waiting for it to expire
+    @Bean(destroyMethod = "resign")
+    public LeaderElection leaderElection(JobLeaseRepository repository,
+                                         @Value("${settlement.leader.instance-id:}") String instanceId,
+                                         @Value("${settlement.leader.lease-ttl-ms:30000}") long leaseTtlMillis) {
+        return new LeaderElection(repository, LEASE_NAME, instanceId, Duration.ofMillis(leaseTtlMillis));
+    }
+    
+    @Bean
+    public SingletonJobRunner singletonJobRunner(LeaderElection election, JobLeaseRepository repository) {
+        return new SingletonJobRunner(election, repository);
+    }
+}
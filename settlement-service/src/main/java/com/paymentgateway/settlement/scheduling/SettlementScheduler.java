@@ -0,0 +1,43 @@
+package com.paymentgateway.settlement.scheduling;
+
+import com.paymentgateway.settlement.service.SettlementService;
+import com.paymentgateway.shared.scheduling.SingletonJobRunner;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.stereotype.Component;
+
+import java.time.Clock;
+import java.time.Instant;
+import java.time.temporal.ChronoUnit;
+
+/**
+ * Triggers the settlement cut-off on every instance; only the leader runs
+ * it, once per slot
+ */
+@Component
+public class SettlementScheduler {
+    
+    static final String SETTLEMENT_CUTOFF_JOB = "settlement-cutoff";
+    
+    private final SingletonJobRunner runner;
+    private final SettlementService settlementService;
+    private final Clock clock;
+    
+    @Autowired
+    public SettlementScheduler(SingletonJobRunner runner, SettlementService settlementService) {
+        this(runner, settlementService, Clock.systemUTC());
+    }
+    
+    public SettlementScheduler(SingletonJobRunner runner, SettlementService settlementService, Clock clock) {
+        this.runner = runner;
+        this.settlementService = settlementService;
+        this.clock = clock;
+    }
+    
+    @Scheduled(cron = "${settlement.batch.cron:0 */15 * * * *}")
+    public SingletonJobRunner.Outcome runSettlementCutoff() {
+        // Instances fire a little apart; the minute names the run for all
+        Instant slot = clock.instant().truncatedTo(ChronoUnit.MINUTES);
+        return runner.run(SETTLEMENT_CUTOFF_JOB, slot, settlementService::processSettlementBatches);
+    }
+}
@@ -1,16 +1,23 @@
 package com.paymentgateway.settlement.service;
 
 import com.paymentgateway.settlement.domain.Dispute;
+import com.paymentgateway.settlement.domain.LedgerEntryType;
 import com.paymentgateway.settlement.domain.Payment;
+import com.paymentgateway.settlement.event.SettlementEventOutbox;
 import com.paymentgateway.settlement.repository.DisputeRepository;
 import com.paymentgateway.settlement.repository.PaymentRepository;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
 import org.springframework.stereotype.Service;
 import org.springframework.transaction.annotation.Transactional;
 
 import java.math.BigDecimal;
+import java.time.Clock;
+import java.time.Duration;
 import java.time.OffsetDateTime;
+import java.time.ZoneOffset;
 import java.util.List;
 import java.util.UUID;
 
@@ -21,10 +28,35 @@ public class DisputeService {
     
     private final DisputeRepository disputeRepository;
     private final PaymentRepository paymentRepository;
+    private final DisputeEvidenceBuilder evidenceBuilder;
+    private final SettlementEventOutbox eventOutbox;
+    private final Clock clock;
+    private final Duration responseWindow;
+    private final Duration reviewWindow;
+    private final LedgerService ledgerService;
     
-    public DisputeService(DisputeRepository disputeRepository, PaymentRepository paymentRepository) {
+    /**
+     * @param clock The simulated clock deadlines are measured by
+     * @param responseWindow Respond-by time after the chargeback when its
+     *                       notification names none
+     * @param reviewWindow Time the issuer has to decide once evidence is in
+     * @param ledgerService Where lost chargebacks are posted
+     */
+    @Autowired
+    public DisputeService(DisputeRepository disputeRepository, PaymentRepository paymentRepository,
+                          DisputeEvidenceBuilder evidenceBuilder, SettlementEventOutbox eventOutbox,
+                          Clock clock,
+                          @Value("${settlement.disputes.response-window:20d}") Duration responseWindow,
+                          @Value("${settlement.disputes.review-window:30d}") Duration reviewWindow,
+                          LedgerService ledgerService) {
         this.disputeRepository = disputeRepository;
         this.paymentRepository = paymentRepository;
+        this.evidenceBuilder = evidenceBuilder;
+        this.eventOutbox = eventOutbox;
+        this.clock = clock;
+        this.responseWindow = responseWindow;
+        this.reviewWindow = reviewWindow;
+        this.ledgerService = ledgerService;
     }
     
     /**
@@ -54,10 +86,13 @@ public class DisputeService {
         );
         
         dispute.setChargebackReference(chargebackReference);
-        dispute.setDeadline(deadline);
+        dispute.setDeadline(deadline != null ? deadline : now().plus(responseWindow));
         dispute.setStatus("OPEN");
+        dispute.setCreatedAt(now());
+        attachEvidence(dispute);
         
         dispute = disputeRepository.save(dispute);
+        eventOutbox.disputeChanged(SettlementEventOutbox.DISPUTE_OPENED, dispute, payment);
         
         logger.info("Created dispute {} for payment {} with chargeback reference {}", 
                    disputeId, paymentId, chargebackReference);
@@ -69,7 +104,26 @@ public class DisputeService {
     }
     
     /**
-     * Submit evidence for a dispute
+     * Rebuild the evidence bundle of a dispute, picking up webhook
+     * deliveries and refunds since the chargeback arrived
+     */
+    @Transactional
+    public Dispute refreshEvidence(String disputeId) {
+        Dispute dispute = getDispute(disputeId);
+        attachEvidence(dispute);
+        dispute.setUpdatedAt(now());
+        return disputeRepository.save(dispute);
+    }
+    
+    private void attachEvidence(Dispute dispute) {
+        OffsetDateTime now = now();
+        dispute.setEvidenceBundle(evidenceBuilder.build(dispute, now.toInstant()));
+        dispute.setEvidenceGeneratedAt(now);
+    }
+    
+    /**
+     * Submit evidence for a dispute; without evidence of its own the
+     * merchant submits the assembled evidence bundle
      */
     @Transactional
     public void submitEvidence(String disputeId, String evidence) {
@@ -79,14 +133,20 @@ public class DisputeService {
         if (!"OPEN".equals(dispute.getStatus())) {
             throw new IllegalStateException("Dispute is not in OPEN status");
         }
+        OffsetDateTime now = now();
+        if (dispute.getDeadline() != null && now.isAfter(dispute.getDeadline())) {
+            throw new IllegalStateException("Respond-by deadline of dispute has passed");
+        }
         
         dispute.setStatus("PENDING_EVIDENCE");
-        dispute.setEvidenceSubmittedAt(OffsetDateTime.now());
-        dispute.setUpdatedAt(OffsetDateTime.now());
+        dispute.setEvidenceSubmittedAt(now);
+        dispute.setReviewDeadline(now.plus(reviewWindow));
+        dispute.setUpdatedAt(now);
         disputeRepository.save(dispute);
+        publish(SettlementEventOutbox.DISPUTE_EVIDENCE_SUBMITTED, dispute);
         
         // Forward evidence to acquirer (simulated)
-        forwardEvidenceToAcquirer(dispute, evidence);
+        forwardEvidenceToAcquirer(dispute, evidence == null || evidence.isBlank() ? dispute.getEvidenceBundle() : evidence);
         
         logger.info("Submitted evidence for dispute {}", disputeId);
     }
@@ -99,11 +159,41 @@ public class DisputeService {
         Dispute dispute = disputeRepository.findByDisputeId(disputeId)
             .orElseThrow(() -> new IllegalArgumentException("Dispute not found: " + disputeId));
         
+        resolve(dispute, resolution, merchantWon);
+    }
+    
+    /**
+     * Decide the disputes whose stage deadline passed by the simulated
+     * clock: an OPEN dispute past its respond-by time is lost, and one
+     * whose issuer did not decide within the review window is won.
+     *
+     * @return The number of disputes decided
+     */
+    @Transactional
+    public int expireOverdueDisputes() {
+        OffsetDateTime now = now();
+        int decided = 0;
+        for (Dispute dispute : disputeRepository.findByStatusAndDeadlineBefore("OPEN", now)) {
+            resolve(dispute, "Merchant did not respond by " + dispute.getDeadline(), false);
+            decided++;
+        }
+        for (Dispute dispute : disputeRepository.findByStatusAndReviewDeadlineBefore("PENDING_EVIDENCE", now)) {
+            resolve(dispute, "Issuer did not decide by " + dispute.getReviewDeadline(), true);
+            decided++;
+        }
+        if (decided > 0) {
+            logger.info("Decided {} disputes past their deadline at {}", decided, now);
+        }
+        return decided;
+    }
+    
+    private void resolve(Dispute dispute, String resolution, boolean merchantWon) {
         dispute.setStatus(merchantWon ? "WON" : "LOST");
         dispute.setResolution(resolution);
-        dispute.setResolvedAt(OffsetDateTime.now());
-        dispute.setUpdatedAt(OffsetDateTime.now());
+        dispute.setResolvedAt(now());
+        dispute.setUpdatedAt(now());
         disputeRepository.save(dispute);
+        publish(merchantWon ? SettlementEventOutbox.DISPUTE_WON : SettlementEventOutbox.DISPUTE_LOST, dispute);
         
         // If merchant lost, adjust settlement records
         if (!merchantWon) {
@@ -113,18 +203,32 @@ public class DisputeService {
         // Notify merchant of resolution
         notifyMerchantOfResolution(dispute);
         
-        logger.info("Resolved dispute {} with status {}", disputeId, dispute.getStatus());
+        logger.info("Resolved dispute {} with status {}", dispute.getDisputeId(), dispute.getStatus());
+    }
+    
+    private void publish(String eventType, Dispute dispute) {
+        paymentRepository.findById(dispute.getPaymentId())
+            .ifPresent(payment -> eventOutbox.disputeChanged(eventType, dispute, payment));
+    }
+    
+    private OffsetDateTime now() {
+        return OffsetDateTime.now(clock.withZone(ZoneOffset.UTC));
     }
     
     /**
-     * Adjust settlement records for finalized chargeback
+     * Adjust settlement records for finalized chargeback: the merchant of
+     * record is debited the disputed amount on its ledger, and takes each
+     * split's share of it back from the sub-merchant
      */
     private void adjustSettlementForChargeback(Dispute dispute) {
-        // In production, this would create a debit entry in the settlement system
         logger.info("Adjusting settlement for chargeback on payment {}, amount {}", 
                    dispute.getPaymentId(), dispute.getAmount());
-        
-        // TODO: Create settlement adjustment record
+        Payment payment = paymentRepository.findById(dispute.getPaymentId()).orElse(null);
+        if (payment == null || ledgerService.reverse(payment, LedgerEntryType.CHARGEBACK, dispute.getAmount(),
+                dispute.getDisputeId()).isEmpty()) {
+            logger.warn("Chargeback of dispute {} was not posted: payment {} is not on the ledgers",
+                       dispute.getDisputeId(), dispute.getPaymentId());
+        }
     }
     
     /**
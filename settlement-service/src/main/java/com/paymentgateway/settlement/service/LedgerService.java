@@ -0,0 +1,328 @@
+package com.paymentgateway.settlement.service;
+
+import com.paymentgateway.settlement.domain.*;
+import com.paymentgateway.settlement.repository.LedgerEntryRepository;
+import com.paymentgateway.settlement.repository.MerchantRepository;
+import com.paymentgateway.settlement.repository.PayoutRepository;
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import org.springframework.beans.factory.annotation.Autowired;
+import org.springframework.beans.factory.annotation.Value;
+import org.springframework.data.domain.PageRequest;
+import org.springframework.stereotype.Service;
+import org.springframework.transaction.annotation.Transactional;
+
+import java.math.BigDecimal;
+import java.math.RoundingMode;
+import java.time.Clock;
+import java.time.LocalDate;
+import java.time.OffsetDateTime;
+import java.time.ZoneOffset;
+import java.util.*;
+
+/**
+ * Merchants' ledgers and payouts. Settled payments are posted to the
+ * ledgers of the merchant of record and of any sub-merchants they were
+ * split to; payouts then take each merchant's unpaid balance. A rolling
+ * reserve holds a share of each sale back from the merchant of record
+ * until its hold period ends. Refunds and lost chargebacks are taken back
+ * from the sub-merchants in proportion to their splits.
+ */
+@Service
+public class LedgerService {
+    
+    private static final Logger logger = LoggerFactory.getLogger(LedgerService.class);
+    
+    private static final BigDecimal ONE_HUNDRED = new BigDecimal("100");
+    
+    private static final Set<LedgerEntryType> REVERSALS = EnumSet.of(LedgerEntryType.REFUND, LedgerEntryType.CHARGEBACK);
+    
+    private final LedgerEntryRepository entryRepository;
+    private final PayoutRepository payoutRepository;
+    private final MerchantRepository merchantRepository;
+    private final Clock clock;
+    private final BigDecimal defaultReservePercentage;
+    private final int defaultReserveHoldDays;
+    private final boolean returnSplitFees;
+    
+    public LedgerService(LedgerEntryRepository entryRepository, PayoutRepository payoutRepository) {
+        this(entryRepository, payoutRepository, null, Clock.systemUTC(), BigDecimal.ZERO, 0);
+    }
+    
+    public LedgerService(LedgerEntryRepository entryRepository, PayoutRepository payoutRepository,
+                         MerchantRepository merchantRepository, Clock clock,
+                         BigDecimal defaultReservePercentage, int defaultReserveHoldDays) {
+        this(entryRepository, payoutRepository, merchantRepository, clock, defaultReservePercentage,
+             defaultReserveHoldDays, true);
+    }
+    
+    /**
+     * @param clock The simulated clock reserves are released by
+     * @param defaultReservePercentage Share of sales held for merchants
+     *                                 without their own, 0 for none
+     * @param returnSplitFees Whether the platform returns its split fee on
+     *                        the share of a split it takes back
+     */
+    @Autowired
+    public LedgerService(LedgerEntryRepository entryRepository, PayoutRepository payoutRepository,
+                         MerchantRepository merchantRepository, Clock clock,
+                         @Value("${settlement.reserve.percentage:0}") BigDecimal defaultReservePercentage,
+                         @Value("${settlement.reserve.hold-days:90}") int defaultReserveHoldDays,
+                         @Value("${settlement.splits.return-fees:true}") boolean returnSplitFees) {
+        this.entryRepository = entryRepository;
+        this.payoutRepository = payoutRepository;
+        this.merchantRepository = merchantRepository;
+        this.clock = clock;
+        this.defaultReservePercentage = defaultReservePercentage;
+        this.defaultReserveHoldDays = defaultReserveHoldDays;
+        this.returnSplitFees = returnSplitFees;
+    }
+    
+    /**
+     * Post a settled payment: its gross amount and processing fee to the
+     * merchant of record, and each split from that merchant, the platform,
+     * to the sub-merchant with the platform's fee back
+     */
+    @Transactional
+    public List<LedgerEntry> post(SettlementTransaction tx, Payment payment) {
+        List<LedgerEntry> entries = new ArrayList<>();
+        UUID platformId = payment.getMerchantId();
+        entries.add(entry(platformId, tx, LedgerEntryType.PAYMENT, tx.getGrossAmount()));
+        BigDecimal fee = tx.getFeeAmount().setScale(2, RoundingMode.HALF_UP);
+        if (fee.signum() != 0) {
+            entries.add(entry(platformId, tx, LedgerEntryType.PROCESSING_FEE, fee.negate()));
+        }
+        LedgerEntry hold = reserveHold(platformId, tx);
+        if (hold != null) {
+            entries.add(hold);
+        }
+        for (PaymentSplit split : payment.getSplits()) {
+            entries.add(entry(platformId, tx, LedgerEntryType.SPLIT, split.getAmount().negate()));
+            entries.add(entry(split.getSubMerchantId(), tx, LedgerEntryType.SPLIT, split.getAmount()));
+            if (split.getFeeAmount().signum() != 0) {
+                entries.add(entry(split.getSubMerchantId(), tx, LedgerEntryType.SPLIT_FEE, split.getFeeAmount().negate()));
+                entries.add(entry(platformId, tx, LedgerEntryType.SPLIT_FEE, split.getFeeAmount()));
+            }
+        }
+        return entryRepository.saveAll(entries);
+    }
+    
+    /**
+     * Post a refund or lost chargeback of a posted payment: the amount is
+     * debited from the merchant of record, and each split's share of it,
+     * in proportion to the split, moves from the sub-merchant back to the
+     * platform with the platform's fee on that share, if fees are
+     * returned. Shares are taken of the payment's running total of
+     * reversals, so partial reversals never take back more than a split.
+     *
+     * @param reference The refund or dispute reversing the payment; each is
+     *                  posted once
+     * @return The entries, none if the payment was never posted or the
+     *         reference already was
+     */
+    @Transactional
+    public List<LedgerEntry> reverse(Payment payment, LedgerEntryType type, BigDecimal amount, String reference) {
+        List<LedgerEntry> posted = entryRepository.findByPaymentId(payment.getId());
+        LedgerEntry sale = posted.stream()
+            .filter(entry -> entry.getEntryType() == LedgerEntryType.PAYMENT)
+            .findFirst()
+            .orElse(null);
+        if (sale == null || sale.getAmount().signum() <= 0
+                || posted.stream().anyMatch(entry -> reference.equals(entry.getReference()))) {
+            return List.of();
+        }
+        BigDecimal gross = sale.getAmount();
+        BigDecimal before = posted.stream()
+            .filter(entry -> REVERSALS.contains(entry.getEntryType()))
+            .map(LedgerEntry::getAmount)
+            .reduce(BigDecimal.ZERO, BigDecimal::add)
+            .negate();
+        BigDecimal after = before.add(amount);
+        
+        List<LedgerEntry> entries = new ArrayList<>();
+        UUID platformId = payment.getMerchantId();
+        entries.add(reversal(platformId, sale, type, amount.negate(), reference));
+        for (PaymentSplit split : payment.getSplits()) {
+            BigDecimal share = share(split.getAmount(), after, gross).subtract(share(split.getAmount(), before, gross));
+            if (share.signum() != 0) {
+                entries.add(reversal(split.getSubMerchantId(), sale, LedgerEntryType.SPLIT_REVERSAL, share.negate(), reference));
+                entries.add(reversal(platformId, sale, LedgerEntryType.SPLIT_REVERSAL, share, reference));
+            }
+            BigDecimal fee = share(split.getFeeAmount(), after, gross).subtract(share(split.getFeeAmount(), before, gross));
+            if (returnSplitFees && fee.signum() != 0) {
+                entries.add(reversal(split.getSubMerchantId(), sale, LedgerEntryType.SPLIT_FEE_REVERSAL, fee, reference));
+                entries.add(reversal(platformId, sale, LedgerEntryType.SPLIT_FEE_REVERSAL, fee.negate(), reference));
+            }
+        }
+        return entryRepository.saveAll(entries);
+    }
+    
+    /**
+     * Release the reserves whose hold period has ended by the simulated
+     * clock, crediting each back to its merchant's unpaid balance
+     *
+     * @return The release entries
+     */
+    @Transactional
+    public List<LedgerEntry> releaseReserves() {
+        OffsetDateTime now = now();
+        List<LedgerEntry> holds = entryRepository.findByEntryTypeAndReleasedAtIsNullAndReleaseAtLessThanEqual(
+            LedgerEntryType.RESERVE_HOLD, now);
+        List<LedgerEntry> releases = new ArrayList<>();
+        for (LedgerEntry hold : holds) {
+            hold.setReleasedAt(now);
+            releases.add(new LedgerEntry(hold.getMerchantId(), hold.getBatchId(), hold.getPaymentId(),
+                LedgerEntryType.RESERVE_RELEASE, hold.getAmount().negate(), hold.getCurrency()));
+        }
+        if (!holds.isEmpty()) {
+            entryRepository.saveAll(holds);
+            entryRepository.saveAll(releases);
+            logger.info("Released {} reserve holds", holds.size());
+        }
+        return releases;
+    }
+    
+    /**
+     * Pay out every merchant's unpaid balance in each currency. A balance
+     * that is not positive, such as a merchant's credits exceeding its
+     * sales, is carried forward.
+     */
+    @Transactional
+    public List<Payout> createPayouts() {
+        Map<String, List<LedgerEntry>> unpaid = new LinkedHashMap<>();
+        for (LedgerEntry entry : entryRepository.findByPayoutIdIsNull()) {
+            unpaid.computeIfAbsent(entry.getMerchantId() + "_" + entry.getCurrency(), k -> new ArrayList<>())
+                .add(entry);
+        }
+        
+        List<Payout> payouts = new ArrayList<>();
+        for (List<LedgerEntry> entries : unpaid.values()) {
+            BigDecimal balance = balance(entries);
+            if (balance.signum() <= 0) {
+                continue;
+            }
+            String payoutId = "po_" + UUID.randomUUID().toString().replace("-", "").substring(0, 24);
+            Payout payout = payoutRepository.save(new Payout(
+                payoutId, entries.get(0).getMerchantId(), entries.get(0).getCurrency(), balance, entries.size()));
+            for (LedgerEntry entry : entries) {
+                entry.setPayoutId(payout.getId());
+            }
+            entryRepository.saveAll(entries);
+            payouts.add(payout);
+        }
+        
+        logger.info("Created {} payouts", payouts.size());
+        return payouts;
+    }
+    
+    /**
+     * A merchant's unpaid balance in each currency
+     */
+    public Map<String, BigDecimal> balances(UUID merchantId) {
+        Map<String, BigDecimal> balances = new TreeMap<>();
+        for (LedgerEntry entry : entryRepository.findByMerchantIdAndPayoutIdIsNull(merchantId)) {
+            balances.merge(entry.getCurrency(), entry.getAmount(), BigDecimal::add);
+        }
+        return balances;
+    }
+    
+    /**
+     * A merchant's reserve still held, in each currency
+     */
+    public Map<String, BigDecimal> reserves(UUID merchantId) {
+        Map<String, BigDecimal> reserves = new TreeMap<>();
+        for (LedgerEntry hold : heldReserves(merchantId)) {
+            reserves.merge(hold.getCurrency(), hold.getAmount().negate(), BigDecimal::add);
+        }
+        return reserves;
+    }
+    
+    /**
+     * When a merchant's held reserve is due back: the total by UTC date and
+     * currency, earliest first
+     */
+    public List<ReserveRelease> reserveReleases(UUID merchantId) {
+        Map<LocalDate, Map<String, BigDecimal>> byDate = new TreeMap<>();
+        for (LedgerEntry hold : heldReserves(merchantId)) {
+            byDate.computeIfAbsent(hold.getReleaseAt().withOffsetSameInstant(ZoneOffset.UTC).toLocalDate(),
+                    k -> new TreeMap<>())
+                .merge(hold.getCurrency(), hold.getAmount().negate(), BigDecimal::add);
+        }
+        List<ReserveRelease> releases = new ArrayList<>();
+        byDate.forEach((date, amounts) -> amounts.forEach(
+            (currency, amount) -> releases.add(new ReserveRelease(date, currency, amount))));
+        return releases;
+    }
+    
+    /**
+     * A merchant's latest ledger entries, newest first
+     */
+    public List<LedgerEntry> recentEntries(UUID merchantId, int limit) {
+        return entryRepository.findByMerchantIdOrderByCreatedAtDesc(merchantId, PageRequest.of(0, limit));
+    }
+    
+    /**
+     * A merchant's latest payouts, newest first
+     */
+    public List<Payout> recentPayouts(UUID merchantId, int limit) {
+        return payoutRepository.findByMerchantIdOrderByCreatedAtDesc(merchantId, PageRequest.of(0, limit));
+    }
+    
+    private List<LedgerEntry> heldReserves(UUID merchantId) {
+        return entryRepository.findByMerchantIdAndEntryTypeAndReleasedAtIsNullOrderByReleaseAt(
+            merchantId, LedgerEntryType.RESERVE_HOLD);
+    }
+    
+    /**
+     * The reserve held from a sale, or null for none. Credits hold nothing.
+     */
+    private LedgerEntry reserveHold(UUID merchantId, SettlementTransaction tx) {
+        if (tx.getGrossAmount().signum() <= 0) {
+            return null;
+        }
+        Merchant merchant = merchantRepository != null ? merchantRepository.findById(merchantId).orElse(null) : null;
+        BigDecimal percentage = merchant != null && merchant.getReservePercentage() != null
+            ? merchant.getReservePercentage() : defaultReservePercentage;
+        int holdDays = merchant != null && merchant.getReserveHoldDays() != null
+            ? merchant.getReserveHoldDays() : defaultReserveHoldDays;
+        BigDecimal amount = tx.getGrossAmount().multiply(percentage)
+            .divide(ONE_HUNDRED, 2, RoundingMode.HALF_UP);
+        if (amount.signum() <= 0) {
+            return null;
+        }
+        LedgerEntry hold = entry(merchantId, tx, LedgerEntryType.RESERVE_HOLD, amount.negate());
+        hold.setReleaseAt(now().plusDays(holdDays));
+        return hold;
+    }
+    
+    private OffsetDateTime now() {
+        return OffsetDateTime.now(clock.withZone(ZoneOffset.UTC));
+    }
+    
+    private static BigDecimal balance(List<LedgerEntry> entries) {
+        return entries.stream()
+            .map(LedgerEntry::getAmount)
+            .reduce(BigDecimal.ZERO, BigDecimal::add);
+    }
+    
+    private static LedgerEntry entry(UUID merchantId, SettlementTransaction tx, LedgerEntryType type, BigDecimal amount) {
+        return new LedgerEntry(merchantId, tx.getBatchId(), tx.getPaymentId(), type, amount, tx.getCurrency());
+    }
+    
+    // Reversals are posted against the batch the payment settled in
+    private static LedgerEntry reversal(UUID merchantId, LedgerEntry sale, LedgerEntryType type, BigDecimal amount,
+                                        String reference) {
+        LedgerEntry entry = new LedgerEntry(merchantId, sale.getBatchId(), sale.getPaymentId(), type, amount,
+            sale.getCurrency());
+        entry.setReference(reference);
+        return entry;
+    }
+    
+    /**
+     * A part's share of the reversals of a payment so far, which is never
+     * more than the part
+     */
+    private static BigDecimal share(BigDecimal part, BigDecimal reversed, BigDecimal gross) {
+        return part.multiply(reversed.min(gross)).divide(gross, 2, RoundingMode.HALF_UP);
+    }
+}
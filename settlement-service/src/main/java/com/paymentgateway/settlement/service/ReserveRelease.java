@@ -0,0 +1,32 @@
+package com.paymentgateway.settlement.service;
+
+import java.math.BigDecimal;
+import java.time.LocalDate;
+
+/**
+ * Reserve due back to a merchant on a date, in one currency
+ */
+public final class ReserveRelease {
+    
+    private final LocalDate date;
+    private final String currency;
+    private final BigDecimal amount;
+    
+    public ReserveRelease(LocalDate date, String currency, BigDecimal amount) {
+        this.date = date;
+        this.currency = currency;
+        this.amount = amount;
+    }
+    
+    public LocalDate getDate() {
+        return date;
+    }
+    
+    public String getCurrency() {
+        return currency;
+    }
+    
+    public BigDecimal getAmount() {
+        return amount;
+    }
+}
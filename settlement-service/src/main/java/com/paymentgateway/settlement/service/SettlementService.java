@@ -1,18 +1,21 @@
 package com.paymentgateway.settlement.service;
 
+import com.paymentgateway.settlement.calendar.CutoffSchedule;
+import com.paymentgateway.settlement.calendar.SettlementCalendar;
 import com.paymentgateway.settlement.domain.*;
+import com.paymentgateway.settlement.event.SettlementEventOutbox;
 import com.paymentgateway.settlement.repository.*;
 import org.slf4j.Logger;
 import org.slf4j.LoggerFactory;
-import org.springframework.scheduling.annotation.Scheduled;
+import org.springframework.beans.factory.annotation.Autowired;
 import org.springframework.stereotype.Service;
 import org.springframework.transaction.annotation.Transactional;
 
 import java.math.BigDecimal;
 import java.time.LocalDate;
 import java.time.OffsetDateTime;
+import java.time.ZonedDateTime;
 import java.util.*;
-import java.util.stream.Collectors;
 
 @Service
 public class SettlementService {
@@ -22,26 +25,51 @@ public class SettlementService {
     private final SettlementBatchRepository batchRepository;
     private final SettlementTransactionRepository settlementTransactionRepository;
     private final PaymentRepository paymentRepository;
+    private final MerchantRepository merchantRepository;
+    private final SettlementCalendar calendar;
+    private final SettlementEventOutbox eventOutbox;
+    private final LedgerService ledgerService;
+    private final RefundRepository refundRepository;
     
+    @Autowired
     public SettlementService(SettlementBatchRepository batchRepository,
                            SettlementTransactionRepository settlementTransactionRepository,
-                           PaymentRepository paymentRepository) {
+                           PaymentRepository paymentRepository,
+                           MerchantRepository merchantRepository,
+                           SettlementCalendar calendar,
+                           SettlementEventOutbox eventOutbox,
+                           LedgerService ledgerService,
+                           RefundRepository refundRepository) {
         this.batchRepository = batchRepository;
         this.settlementTransactionRepository = settlementTransactionRepository;
         this.paymentRepository = paymentRepository;
+        this.merchantRepository = merchantRepository;
+        this.calendar = calendar;
+        this.eventOutbox = eventOutbox;
+        this.ledgerService = ledgerService;
+        this.refundRepository = refundRepository;
     }
     
     /**
-     * Scheduled job to process settlement batches daily at 2 AM
+     * Settlement cut-off job. Merchants have their own cut-offs, so
+     * {@link com.paymentgateway.settlement.scheduling.SettlementScheduler}
+     * runs it every 15 minutes, on the leader only, and it closes each batch
+     * soon after its cut-off passes, then posts the refunds completed
+     * since, releases the reserves that are due and pays out the merchants'
+     * ledger balances. Failures are rethrown so the run is recorded as
+     * failed.
      */
-    @Scheduled(cron = "0 0 2 * * *")
     public void processSettlementBatches() {
         logger.info("Starting scheduled settlement batch processing");
         try {
             createSettlementBatches();
+            postRefunds();
+            ledgerService.releaseReserves();
+            ledgerService.createPayouts();
             logger.info("Settlement batch processing completed successfully");
-        } catch (Exception e) {
+        } catch (RuntimeException e) {
             logger.error("Error processing settlement batches", e);
+            throw e;
         }
     }
     
@@ -50,30 +78,49 @@ public class SettlementService {
      */
     @Transactional
     public List<SettlementBatch> createSettlementBatches() {
-        OffsetDateTime cutoffTime = OffsetDateTime.now();
-        List<Payment> unsettledPayments = paymentRepository.findUnsettledCapturedPayments(cutoffTime);
+        return createSettlementBatches(OffsetDateTime.now());
+    }
+    
+    /**
+     * Create settlement batches for captured payments whose cut-off has
+     * passed at the given time. Each payment's settlement date is the date
+     * of its cut-off in the merchant's timezone.
+     */
+    @Transactional
+    public List<SettlementBatch> createSettlementBatches(OffsetDateTime now) {
+        List<Payment> unsettledPayments = paymentRepository.findUnsettledCapturedPayments(now);
         
         if (unsettledPayments.isEmpty()) {
             logger.info("No unsettled payments found");
             return Collections.emptyList();
         }
         
-        // Group payments by merchant and currency
-        Map<String, List<Payment>> groupedPayments = unsettledPayments.stream()
-            .collect(Collectors.groupingBy(p -> p.getMerchantId() + "_" + p.getCurrency()));
+        // Group payments by settlement date, then merchant and currency
+        Map<UUID, CutoffSchedule> schedules = new HashMap<>();
+        Map<LocalDate, Map<String, List<Payment>>> groupedPayments = new TreeMap<>();
+        for (Payment payment : unsettledPayments) {
+            CutoffSchedule schedule = schedules.computeIfAbsent(payment.getMerchantId(),
+                id -> calendar.scheduleFor(merchantRepository.findById(id).orElse(null)));
+            ZonedDateTime cutoff = calendar.cutoffFor(payment.getCapturedAt(), schedule);
+            if (cutoff.toInstant().isAfter(now.toInstant())) {
+                continue; // Its batch is still open
+            }
+            groupedPayments
+                .computeIfAbsent(cutoff.toLocalDate(), d -> new LinkedHashMap<>())
+                .computeIfAbsent(payment.getMerchantId() + "_" + payment.getCurrency(), k -> new ArrayList<>())
+                .add(payment);
+        }
         
         List<SettlementBatch> batches = new ArrayList<>();
-        LocalDate settlementDate = LocalDate.now();
         
-        for (Map.Entry<String, List<Payment>> entry : groupedPayments.entrySet()) {
-            List<Payment> payments = entry.getValue();
-            if (payments.isEmpty()) continue;
-            
-            UUID merchantId = payments.get(0).getMerchantId();
-            String currency = payments.get(0).getCurrency();
-            
-            SettlementBatch batch = createBatchForPayments(merchantId, currency, settlementDate, payments);
-            batches.add(batch);
+        for (Map.Entry<LocalDate, Map<String, List<Payment>>> day : groupedPayments.entrySet()) {
+            for (List<Payment> payments : day.getValue().values()) {
+                UUID merchantId = payments.get(0).getMerchantId();
+                String currency = payments.get(0).getCurrency();
+                
+                SettlementBatch batch = createBatchForPayments(merchantId, currency, day.getKey(), payments);
+                batches.add(batch);
+            }
         }
         
         logger.info("Created {} settlement batches", batches.size());
@@ -86,9 +133,9 @@ public class SettlementService {
     @Transactional
     public SettlementBatch createBatchForPayments(UUID merchantId, String currency, 
                                                   LocalDate settlementDate, List<Payment> payments) {
-        // Calculate totals
+        // Calculate totals, with credits netted off the sales
         BigDecimal totalAmount = payments.stream()
-            .map(Payment::getAmount)
+            .map(this::signedAmount)
             .reduce(BigDecimal.ZERO, BigDecimal::add);
         
         int transactionCount = payments.size();
@@ -105,19 +152,37 @@ public class SettlementService {
         
         // Create settlement transactions
         for (Payment payment : payments) {
-            BigDecimal grossAmount = payment.getAmount();
-            BigDecimal feeAmount = calculateFee(grossAmount);
+            BigDecimal grossAmount = signedAmount(payment);
+            // Credits carry no processing fee; sales pay the rate of the
+            // interchange program their purchasing data qualifies for, and
+            // the cross-border markup for cards issued abroad
+            InterchangeProgram program = payment.isCredit() ? null : InterchangeProgram.qualify(payment);
+            BigDecimal crossBorderFee = program == null ? null : InterchangeProgram.crossBorderFee(payment, grossAmount);
+            BigDecimal feeAmount = program == null ? BigDecimal.ZERO : program.fee(grossAmount);
+            if (crossBorderFee != null) {
+                feeAmount = feeAmount.add(crossBorderFee);
+            }
             BigDecimal netAmount = grossAmount.subtract(feeAmount);
             
             SettlementTransaction settlementTx = new SettlementTransaction(
                 batch.getId(), payment.getId(), grossAmount, feeAmount, netAmount, currency
             );
+            settlementTx.setStatementDescriptor(payment.getStatementDescriptor());
+            settlementTx.setTaxAmount(payment.getTaxAmount());
+            settlementTx.setCustomerCode(payment.getCustomerCode());
+            settlementTx.setPurchaseDataLevel(payment.getPurchaseDataLevel());
+            settlementTx.setInterchangeProgram(program);
+            settlementTx.setCrossBorderFee(crossBorderFee);
+            settlementTx.setInstallmentCount(payment.getInstallmentCount());
+            settlementTx.setInstallmentPlanType(payment.getInstallmentPlanType());
             settlementTransactionRepository.save(settlementTx);
+            ledgerService.post(settlementTx, payment);
             
             // Mark payment as settled
             payment.setStatus("SETTLED");
             payment.setSettledAt(OffsetDateTime.now());
             paymentRepository.save(payment);
+            eventOutbox.paymentSettled(payment, batchId);
         }
         
         logger.info("Created settlement batch {} with {} transactions totaling {}", 
@@ -127,12 +192,33 @@ public class SettlementService {
     }
     
     /**
-     * Calculate processing fee (simplified - 2.9% + $0.30)
+     * Post the completed refunds of payments already on the ledgers, taking
+     * each split's share back from its sub-merchant. A refund of a payment
+     * still to settle waits for the payment.
+     *
+     * @return The number of refunds posted
+     */
+    @Transactional
+    public int postRefunds() {
+        int posted = 0;
+        for (Refund refund : refundRepository.findUnpostedCompletedRefunds()) {
+            Payment payment = paymentRepository.findById(refund.getPaymentId()).orElse(null);
+            if (payment != null && !ledgerService.reverse(payment, LedgerEntryType.REFUND, refund.getAmount(),
+                    refund.getRefundId()).isEmpty()) {
+                posted++;
+            }
+        }
+        if (posted > 0) {
+            logger.info("Posted {} refunds to merchant ledgers", posted);
+        }
+        return posted;
+    }
+    
+    /**
+     * Amount a payment contributes to settlement: negative for credits
      */
-    private BigDecimal calculateFee(BigDecimal amount) {
-        BigDecimal percentageFee = amount.multiply(new BigDecimal("0.029"));
-        BigDecimal fixedFee = new BigDecimal("0.30");
-        return percentageFee.add(fixedFee);
+    private BigDecimal signedAmount(Payment payment) {
+        return payment.isCredit() ? payment.getAmount().negate() : payment.getAmount();
     }
     
     /**
@@ -187,23 +273,94 @@ public class SettlementService {
         ));
         
         file.append("\nTRANSACTIONS\n");
-        file.append("PAYMENT_ID,GROSS_AMOUNT,FEE_AMOUNT,NET_AMOUNT\n");
+        file.append("PAYMENT_ID,TYPE,GROSS_AMOUNT,FEE_AMOUNT,NET_AMOUNT,TAX_AMOUNT,CUSTOMER_CODE,INTERCHANGE_PROGRAM,"
+            + "INSTALLMENT_COUNT,INSTALLMENT_PLAN\n");
         
+        // Level 3 line items and marketplace splits follow as addenda of
+        // their transaction
+        StringBuilder lineItems = new StringBuilder();
+        StringBuilder splits = new StringBuilder();
         for (SettlementTransaction tx : transactions) {
             Payment payment = paymentRepository.findById(tx.getPaymentId()).orElse(null);
             if (payment != null) {
-                file.append(String.format("%s,%s,%s,%s\n",
+                file.append(String.format("%s,%s,%s,%s,%s,%s,%s,%s,%s,%s\n",
                     payment.getPaymentId(),
+                    payment.isCredit() ? "CREDIT" : "SALE",
                     tx.getGrossAmount(),
                     tx.getFeeAmount(),
-                    tx.getNetAmount()
+                    tx.getNetAmount(),
+                    tx.getTaxAmount() != null ? tx.getTaxAmount() : "",
+                    tx.getCustomerCode() != null ? tx.getCustomerCode() : "",
+                    tx.getInterchangeProgram() != null ? tx.getInterchangeProgram() : "",
+                    tx.getInstallmentCount() != null ? tx.getInstallmentCount() : "",
+                    tx.getInstallmentPlanType() != null ? tx.getInstallmentPlanType() : ""
                 ));
+                if (tx.getInterchangeProgram() == InterchangeProgram.LEVEL_3) {
+                    appendLineItems(lineItems, payment);
+                }
+                appendSplits(splits, payment);
             }
         }
         
+        if (!lineItems.isEmpty()) {
+            file.append("\nLINE_ITEMS\n");
+            file.append("PAYMENT_ID,LINE_NUMBER,PRODUCT_CODE,COMMODITY_CODE,DESCRIPTION,QUANTITY,UNIT_OF_MEASURE,UNIT_PRICE,DISCOUNT_AMOUNT,LINE_TOTAL\n");
+            file.append(lineItems);
+        }
+        
+        if (!splits.isEmpty()) {
+            file.append("\nSPLITS\n");
+            file.append("PAYMENT_ID,SPLIT_NUMBER,SUB_MERCHANT_ID,AMOUNT,FEE_AMOUNT\n");
+            file.append(splits);
+        }
+        
         return file.toString();
     }
     
+    private void appendLineItems(StringBuilder file, Payment payment) {
+        int lineNumber = 1;
+        for (PaymentLineItem item : payment.getLineItems()) {
+            file.append(String.format("%s,%d,%s,%s,%s,%s,%s,%s,%s,%s\n",
+                payment.getPaymentId(),
+                lineNumber++,
+                csvField(item.getProductCode()),
+                csvField(item.getCommodityCode()),
+                csvField(item.getDescription()),
+                item.getQuantity(),
+                csvField(item.getUnitOfMeasure()),
+                item.getUnitPrice(),
+                item.getDiscountAmount() != null ? item.getDiscountAmount() : "",
+                item.getLineTotal()
+            ));
+        }
+    }
+    
+    private void appendSplits(StringBuilder file, Payment payment) {
+        int splitNumber = 1;
+        for (PaymentSplit split : payment.getSplits()) {
+            file.append(String.format("%s,%d,%s,%s,%s\n",
+                payment.getPaymentId(),
+                splitNumber++,
+                split.getSubMerchantId(),
+                split.getAmount(),
+                split.getFeeAmount()
+            ));
+        }
+    }
+    
+    /**
+     * A free-text field, quoted if it has commas or quotes
+     */
+    private static String csvField(String value) {
+        if (value == null) {
+            return "";
+        }
+        if (value.contains(",") || value.contains("\"")) {
+            return "\"" + value.replace("\"", "\"\"") + "\"";
+        }
+        return value;
+    }
+    
     /**
      * Submit settlement file to acquirer (simulated)
      */
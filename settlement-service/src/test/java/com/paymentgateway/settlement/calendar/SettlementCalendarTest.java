@@ -0,0 +1,75 @@
+package com.paymentgateway.settlement.calendar;
+
+import com.paymentgateway.settlement.domain.Merchant;
+import org.junit.jupiter.api.Test;
+
+import java.time.LocalDate;
+import java.time.LocalTime;
+import java.time.OffsetDateTime;
+import java.time.ZoneId;
+import java.time.ZonedDateTime;
+import java.util.List;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class SettlementCalendarTest {
+    
+    private final SettlementCalendar calendar = new SettlementCalendar(
+        "UTC", "22:00", "2026-12-25:Christmas Day,2026-12-28:Boxing Day (substitute)"
+    );
+    
+    private final CutoffSchedule london = new CutoffSchedule(ZoneId.of("Europe/London"), LocalTime.of(18, 0));
+    
+    @Test
+    void shouldSettleCaptureBeforeCutoffOnSameDay() {
+        ZonedDateTime cutoff = calendar.cutoffFor(OffsetDateTime.parse("2026-06-10T16:59:00Z"), london);
+        
+        // 17:59 BST is before the 18:00 cut-off
+        assertThat(cutoff.toLocalDate()).isEqualTo(LocalDate.of(2026, 6, 10));
+        assertThat(cutoff.toOffsetDateTime()).isEqualTo(OffsetDateTime.parse("2026-06-10T18:00:00+01:00"));
+    }
+    
+    @Test
+    void shouldRollCaptureAtCutoffToNextBusinessDay() {
+        // Friday 18:00 local rolls over the weekend to Monday
+        ZonedDateTime cutoff = calendar.cutoffFor(OffsetDateTime.parse("2026-06-12T17:00:00Z"), london);
+        
+        assertThat(cutoff.toLocalDate()).isEqualTo(LocalDate.of(2026, 6, 15));
+    }
+    
+    @Test
+    void shouldSkipHolidays() {
+        // Thursday 24 December after cut-off: Christmas, the weekend and the substitute holiday are skipped
+        ZonedDateTime cutoff = calendar.cutoffFor(OffsetDateTime.parse("2026-12-24T19:00:00Z"), london);
+        
+        assertThat(cutoff.toLocalDate()).isEqualTo(LocalDate.of(2026, 12, 29));
+    }
+    
+    @Test
+    void shouldUseDefaultsForMerchantWithoutSchedule() {
+        Merchant merchant = new Merchant();
+        merchant.setSettlementTimezone("Asia/Tokyo");
+        
+        CutoffSchedule schedule = calendar.scheduleFor(merchant);
+        
+        assertThat(schedule.getZone()).isEqualTo(ZoneId.of("Asia/Tokyo"));
+        assertThat(schedule.getCutoff()).isEqualTo(LocalTime.of(22, 0));
+        
+        merchant.setSettlementTimezone("Not/AZone");
+        assertThat(calendar.scheduleFor(merchant).getZone()).isEqualTo(ZoneId.of("UTC"));
+    }
+    
+    @Test
+    void shouldListUpcomingCutoffsAndHolidays() {
+        List<CalendarDay> days = calendar.upcoming(london, OffsetDateTime.parse("2026-12-24T12:00:00Z"), 5);
+        
+        assertThat(days).extracting(CalendarDay::getDate).containsExactly(
+            LocalDate.of(2026, 12, 24), LocalDate.of(2026, 12, 25), LocalDate.of(2026, 12, 26),
+            LocalDate.of(2026, 12, 27), LocalDate.of(2026, 12, 28));
+        assertThat(days).extracting(CalendarDay::isBusinessDay).containsExactly(true, false, false, false, false);
+        assertThat(days.get(1).getHoliday()).isEqualTo("Christmas Day");
+        assertThat(days.get(2).getHoliday()).isNull();
+        assertThat(days.get(0).getCutoffAt()).isEqualTo(OffsetDateTime.parse("2026-12-24T18:00:00Z"));
+        assertThat(days.get(1).getCutoffAt()).isNull();
+    }
+}
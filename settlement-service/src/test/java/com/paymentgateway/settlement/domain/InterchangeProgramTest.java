@@ -0,0 +1,54 @@
+package com.paymentgateway.settlement.domain;
+
+import org.junit.jupiter.api.Test;
+
+import java.math.BigDecimal;
+
+import static org.assertj.core.api.Assertions.assertThat;
+
+class InterchangeProgramTest {
+    
+    @Test
+    void shouldQualifyByThePurchasingDataSent() {
+        assertThat(InterchangeProgram.qualify(sale(null, null, null))).isEqualTo(InterchangeProgram.STANDARD);
+        assertThat(InterchangeProgram.qualify(sale(2, "8.00", "PO-1001"))).isEqualTo(InterchangeProgram.LEVEL_2);
+        assertThat(InterchangeProgram.qualify(sale(3, "8.00", "PO-1001"))).isEqualTo(InterchangeProgram.LEVEL_3);
+    }
+    
+    @Test
+    void shouldDowngradeWhenTheTaxIsOutOfRange() {
+        // Below 0.1% and above 22% of the amount
+        assertThat(InterchangeProgram.qualify(sale(3, "0.05", "PO-1001"))).isEqualTo(InterchangeProgram.STANDARD);
+        assertThat(InterchangeProgram.qualify(sale(3, "23.00", "PO-1001"))).isEqualTo(InterchangeProgram.STANDARD);
+        assertThat(InterchangeProgram.qualify(sale(2, "0.10", "PO-1001"))).isEqualTo(InterchangeProgram.LEVEL_2);
+        assertThat(InterchangeProgram.qualify(sale(3, "8.00", null))).isEqualTo(InterchangeProgram.STANDARD);
+    }
+    
+    @Test
+    void shouldChargeLessForBetterData() {
+        BigDecimal amount = new BigDecimal("100.00");
+        
+        assertThat(InterchangeProgram.STANDARD.fee(amount)).isEqualByComparingTo("3.20");
+        assertThat(InterchangeProgram.LEVEL_2.fee(amount)).isEqualByComparingTo("2.80");
+        assertThat(InterchangeProgram.LEVEL_3.fee(amount)).isEqualByComparingTo("2.30");
+    }
+    
+    @Test
+    void shouldMarkUpOnlyCrossBorderSales() {
+        Payment domestic = sale(null, null, null);
+        Payment crossBorder = sale(null, null, null);
+        crossBorder.setCrossBorder(true);
+        
+        assertThat(InterchangeProgram.crossBorderFee(domestic, domestic.getAmount())).isNull();
+        assertThat(InterchangeProgram.crossBorderFee(crossBorder, crossBorder.getAmount())).isEqualByComparingTo("1.00");
+    }
+    
+    private static Payment sale(Integer level, String taxAmount, String customerCode) {
+        Payment payment = new Payment();
+        payment.setAmount(new BigDecimal("100.00"));
+        payment.setPurchaseDataLevel(level);
+        payment.setTaxAmount(taxAmount != null ? new BigDecimal(taxAmount) : null);
+        payment.setCustomerCode(customerCode);
+        return payment;
+    }
+}
@@ -1,14 +1,20 @@
 package com.paymentgateway.settlement.integration;
 
+import com.paymentgateway.settlement.calendar.SettlementCalendar;
+import com.paymentgateway.settlement.clock.SimulatedClock;
 import com.paymentgateway.settlement.domain.*;
+import com.paymentgateway.settlement.event.SettlementEventOutbox;
 import com.paymentgateway.settlement.repository.*;
+import com.paymentgateway.settlement.service.DisputeEvidenceBuilder;
 import com.paymentgateway.settlement.service.DisputeService;
+import com.paymentgateway.settlement.service.LedgerService;
 import com.paymentgateway.settlement.service.SettlementService;
 import org.junit.jupiter.api.*;
 import org.mockito.Mock;
 import org.mockito.MockitoAnnotations;
 
 import java.math.BigDecimal;
+import java.time.Duration;
 import java.time.LocalDate;
 import java.time.OffsetDateTime;
 import java.util.*;
@@ -28,7 +34,12 @@ class SettlementFlowIntegrationTest {
     @Mock private SettlementBatchRepository batchRepository;
     @Mock private SettlementTransactionRepository settlementTransactionRepository;
     @Mock private PaymentRepository paymentRepository;
+    @Mock private MerchantRepository merchantRepository;
     @Mock private DisputeRepository disputeRepository;
+    @Mock private DisputeEvidenceBuilder evidenceBuilder;
+    @Mock private SettlementEventOutbox eventOutbox;
+    @Mock private LedgerService ledgerService;
+    @Mock private RefundRepository refundRepository;
     
     private SettlementService settlementService;
     private DisputeService disputeService;
@@ -38,9 +49,12 @@ class SettlementFlowIntegrationTest {
     void setUp() {
         mocks = MockitoAnnotations.openMocks(this);
         settlementService = new SettlementService(
-            batchRepository, settlementTransactionRepository, paymentRepository
+            batchRepository, settlementTransactionRepository, paymentRepository,
+            merchantRepository, new SettlementCalendar("UTC", "22:00", ""), eventOutbox, ledgerService,
+            refundRepository
         );
-        disputeService = new DisputeService(disputeRepository, paymentRepository);
+        disputeService = new DisputeService(disputeRepository, paymentRepository, evidenceBuilder, eventOutbox,
+            new SimulatedClock(), Duration.ofDays(20), Duration.ofDays(30), ledgerService);
     }
     
     @AfterEach
@@ -0,0 +1,95 @@
+package com.paymentgateway.settlement.service;
+
+import com.fasterxml.jackson.databind.JsonNode;
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.paymentgateway.settlement.domain.Dispute;
+import com.paymentgateway.settlement.repository.DisputeEvidenceRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.extension.ExtendWith;
+import org.mockito.Mock;
+import org.mockito.junit.jupiter.MockitoExtension;
+
+import java.math.BigDecimal;
+import java.sql.Timestamp;
+import java.time.Instant;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.assertThatThrownBy;
+import static org.mockito.Mockito.when;
+
+@ExtendWith(MockitoExtension.class)
+class DisputeEvidenceBuilderTest {
+    
+    private static final Instant AUTHORIZED_AT = Instant.parse("2026-03-02T10:15:00Z");
+    
+    @Mock
+    private DisputeEvidenceRepository evidenceRepository;
+    
+    private final ObjectMapper objectMapper = new ObjectMapper();
+    
+    private DisputeEvidenceBuilder builder;
+    
+    private Dispute dispute;
+    
+    @BeforeEach
+    void setUp() {
+        builder = new DisputeEvidenceBuilder(evidenceRepository, objectMapper);
+        dispute = new Dispute("dis_test123", UUID.randomUUID(), UUID.randomUUID(),
+            new BigDecimal("100.00"), "USD", "10.4", "Fraud - card absent environment");
+        dispute.setChargebackReference("CB_123");
+    }
+    
+    @Test
+    void shouldAssembleVerificationResultsAndDeliveries() throws Exception {
+        Map<String, Object> payment = new HashMap<>();
+        payment.put("payment_id", "pay_test123");
+        payment.put("amount", new BigDecimal("100.00"));
+        payment.put("card_last_four", "4242");
+        payment.put("authorized_at", Timestamp.from(AUTHORIZED_AT));
+        payment.put("avs_result", "Y");
+        payment.put("cvv_result", "M");
+        payment.put("three_ds_status", "AUTHENTICATED");
+        payment.put("three_ds_eci", "05");
+        payment.put("three_ds_cavv_present", true);
+        when(evidenceRepository.findAuthorization(dispute.getPaymentId())).thenReturn(Optional.of(payment));
+        when(evidenceRepository.findTimeline(dispute.getPaymentId())).thenReturn(List.of(
+            Map.of("event_type", "AUTHORIZATION", "event_status", "SUCCESS")));
+        when(evidenceRepository.findWebhookDeliveries(dispute.getPaymentId())).thenReturn(List.of(
+            Map.of("event_type", "payment.captured", "status", "DELIVERED", "http_status_code", 200,
+                "delivered_at", Timestamp.from(AUTHORIZED_AT.plusSeconds(60)))));
+        when(evidenceRepository.findRefunds(dispute.getPaymentId())).thenReturn(List.of());
+        
+        JsonNode bundle = objectMapper.readTree(builder.build(dispute, AUTHORIZED_AT.plusSeconds(3600)));
+        
+        assertThat(bundle.path("dispute_id").asText()).isEqualTo("dis_test123");
+        assertThat(bundle.path("transaction").path("payment_id").asText()).isEqualTo("pay_test123");
+        assertThat(bundle.path("authorization").path("authorized_at").asText()).isEqualTo("2026-03-02T10:15:00Z");
+        assertThat(bundle.path("verification").path("cvv_result").asText()).isEqualTo("M");
+        assertThat(bundle.path("verification").path("three_ds").path("three_ds_cavv_present").asBoolean()).isTrue();
+        assertThat(bundle.path("timeline")).hasSize(1);
+        assertThat(bundle.path("webhook_deliveries").get(0).path("delivered_at").asText())
+            .isEqualTo("2026-03-02T10:16:00Z");
+        
+        JsonNode summary = bundle.path("summary");
+        assertThat(summary.path("avs_matched").asBoolean()).isTrue();
+        assertThat(summary.path("cvv_matched").asBoolean()).isTrue();
+        assertThat(summary.path("three_ds_authenticated").asBoolean()).isTrue();
+        assertThat(summary.path("merchant_notified").asBoolean()).isTrue();
+        assertThat(summary.path("refunded").asBoolean()).isFalse();
+    }
+    
+    @Test
+    void shouldRejectDisputeWithoutPayment() {
+        when(evidenceRepository.findAuthorization(dispute.getPaymentId())).thenReturn(Optional.empty());
+        
+        assertThatThrownBy(() -> builder.build(dispute, Instant.now()))
+            .isInstanceOf(IllegalArgumentException.class)
+            .hasMessageContaining("Payment not found");
+    }
+}
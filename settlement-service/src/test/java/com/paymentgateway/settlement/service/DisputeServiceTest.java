@@ -1,8 +1,12 @@
 package com.paymentgateway.settlement.service;
 
+import com.paymentgateway.settlement.clock.SimulatedClock;
 import com.paymentgateway.settlement.domain.Dispute;
+import com.paymentgateway.settlement.domain.LedgerEntry;
+import com.paymentgateway.settlement.domain.LedgerEntryType;
 import com.paymentgateway.settlement.domain.Payment;
 import com.paymentgateway.settlement.repository.DisputeRepository;
+import com.paymentgateway.settlement.event.SettlementEventOutbox;
 import com.paymentgateway.settlement.repository.PaymentRepository;
 import org.junit.jupiter.api.BeforeEach;
 import org.junit.jupiter.api.Test;
@@ -11,7 +15,12 @@ import org.mockito.Mock;
 import org.mockito.junit.jupiter.MockitoExtension;
 
 import java.math.BigDecimal;
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
 import java.time.OffsetDateTime;
+import java.time.ZoneOffset;
+import java.util.List;
 import java.util.Optional;
 import java.util.UUID;
 
@@ -23,17 +32,31 @@ import static org.mockito.Mockito.*;
 @ExtendWith(MockitoExtension.class)
 class DisputeServiceTest {
     
+    private static final Instant NOW = Instant.parse("2026-05-04T12:00:00Z");
+    
     @Mock
     private DisputeRepository disputeRepository;
     
     @Mock
     private PaymentRepository paymentRepository;
     
+    @Mock
+    private DisputeEvidenceBuilder evidenceBuilder;
+    
+    @Mock
+    private SettlementEventOutbox eventOutbox;
+    
+    @Mock
+    private LedgerService ledgerService;
+    
+    private final SimulatedClock clock = new SimulatedClock(Clock.fixed(NOW, ZoneOffset.UTC));
+    
     private DisputeService disputeService;
     
     @BeforeEach
     void setUp() {
-        disputeService = new DisputeService(disputeRepository, paymentRepository);
+        disputeService = new DisputeService(disputeRepository, paymentRepository, evidenceBuilder, eventOutbox,
+            clock, Duration.ofDays(20), Duration.ofDays(30), ledgerService);
     }
     
     @Test
@@ -61,6 +84,7 @@ class DisputeServiceTest {
         
         when(paymentRepository.findByPaymentId(paymentId)).thenReturn(Optional.of(payment));
         when(disputeRepository.save(any(Dispute.class))).thenReturn(savedDispute);
+        when(evidenceBuilder.build(any(Dispute.class), any(Instant.class))).thenReturn("{\"summary\":{}}");
         
         // When
         Dispute dispute = disputeService.createDisputeFromChargeback(
@@ -78,7 +102,11 @@ class DisputeServiceTest {
         assertThat(dispute.getAmount()).isEqualByComparingTo(new BigDecimal("100.00"));
         assertThat(dispute.getStatus()).isEqualTo("OPEN");
         
-        verify(disputeRepository).save(any(Dispute.class));
+        verify(disputeRepository).save(argThat(d ->
+            "{\"summary\":{}}".equals(d.getEvidenceBundle()) &&
+            d.getEvidenceGeneratedAt() != null
+        ));
+        verify(eventOutbox).disputeChanged(SettlementEventOutbox.DISPUTE_OPENED, savedDispute, payment);
     }
     
     @Test
@@ -116,6 +144,64 @@ class DisputeServiceTest {
         ));
     }
     
+    @Test
+    void shouldSetReviewDeadlineWhenEvidenceSubmitted() {
+        Dispute dispute = new Dispute();
+        dispute.setDisputeId("dis_test123");
+        dispute.setStatus("OPEN");
+        dispute.setDeadline(OffsetDateTime.ofInstant(NOW.plus(Duration.ofDays(5)), ZoneOffset.UTC));
+        
+        when(disputeRepository.findByDisputeId("dis_test123")).thenReturn(Optional.of(dispute));
+        
+        disputeService.submitEvidence("dis_test123", "Evidence document");
+        
+        assertThat(dispute.getReviewDeadline()).isEqualTo(OffsetDateTime.ofInstant(NOW.plus(Duration.ofDays(30)), ZoneOffset.UTC));
+    }
+    
+    @Test
+    void shouldRejectEvidenceAfterRespondByTime() {
+        Dispute dispute = new Dispute();
+        dispute.setDisputeId("dis_test123");
+        dispute.setStatus("OPEN");
+        dispute.setDeadline(OffsetDateTime.ofInstant(NOW.plus(Duration.ofDays(5)), ZoneOffset.UTC));
+        
+        when(disputeRepository.findByDisputeId("dis_test123")).thenReturn(Optional.of(dispute));
+        clock.advance(Duration.ofDays(6));
+        
+        assertThatThrownBy(() -> disputeService.submitEvidence("dis_test123", "Evidence"))
+            .isInstanceOf(IllegalStateException.class)
+            .hasMessageContaining("Respond-by deadline");
+    }
+    
+    @Test
+    void shouldLoseDisputesPastRespondByAndWinThosePastReview() {
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setPaymentId("pay_test123");
+        Dispute unanswered = new Dispute();
+        unanswered.setDisputeId("dis_unanswered");
+        unanswered.setPaymentId(payment.getId());
+        unanswered.setStatus("OPEN");
+        Dispute undecided = new Dispute();
+        undecided.setDisputeId("dis_undecided");
+        undecided.setPaymentId(payment.getId());
+        undecided.setStatus("PENDING_EVIDENCE");
+        
+        clock.advance(Duration.ofDays(21));
+        OffsetDateTime now = OffsetDateTime.ofInstant(NOW.plus(Duration.ofDays(21)), ZoneOffset.UTC);
+        when(disputeRepository.findByStatusAndDeadlineBefore("OPEN", now)).thenReturn(List.of(unanswered));
+        when(disputeRepository.findByStatusAndReviewDeadlineBefore("PENDING_EVIDENCE", now)).thenReturn(List.of(undecided));
+        when(paymentRepository.findById(payment.getId())).thenReturn(Optional.of(payment));
+        
+        assertThat(disputeService.expireOverdueDisputes()).isEqualTo(2);
+        
+        assertThat(unanswered.getStatus()).isEqualTo("LOST");
+        assertThat(unanswered.getResolvedAt()).isEqualTo(now);
+        assertThat(undecided.getStatus()).isEqualTo("WON");
+        verify(eventOutbox).disputeChanged(SettlementEventOutbox.DISPUTE_LOST, unanswered, payment);
+        verify(eventOutbox).disputeChanged(SettlementEventOutbox.DISPUTE_WON, undecided, payment);
+    }
+    
     @Test
     void shouldNotSubmitEvidenceForNonOpenDispute() {
         // Given
@@ -177,6 +263,26 @@ class DisputeServiceTest {
         ));
     }
     
+    @Test
+    void shouldPostLostChargebacksToTheLedgers() {
+        // Given
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        Dispute dispute = new Dispute("dis_test123", payment.getId(), UUID.randomUUID(),
+            new BigDecimal("40.00"), "USD", "10.4", "Fraud");
+        dispute.setStatus("PENDING_EVIDENCE");
+        when(disputeRepository.findByDisputeId("dis_test123")).thenReturn(Optional.of(dispute));
+        when(paymentRepository.findById(payment.getId())).thenReturn(Optional.of(payment));
+        when(ledgerService.reverse(payment, LedgerEntryType.CHARGEBACK, new BigDecimal("40.00"), "dis_test123"))
+            .thenReturn(List.of(new LedgerEntry()));
+        
+        // When
+        disputeService.resolveDispute("dis_test123", "Insufficient evidence", false);
+        
+        // Then the merchant of record is debited, splits and all
+        verify(ledgerService).reverse(payment, LedgerEntryType.CHARGEBACK, new BigDecimal("40.00"), "dis_test123");
+    }
+    
     @Test
     void shouldGetDispute() {
         // Given
@@ -0,0 +1,263 @@
+package com.paymentgateway.settlement.service;
+
+import com.paymentgateway.settlement.clock.SimulatedClock;
+import com.paymentgateway.settlement.domain.LedgerEntry;
+import com.paymentgateway.settlement.domain.LedgerEntryType;
+import com.paymentgateway.settlement.domain.Merchant;
+import com.paymentgateway.settlement.domain.Payment;
+import com.paymentgateway.settlement.domain.PaymentSplit;
+import com.paymentgateway.settlement.domain.Payout;
+import com.paymentgateway.settlement.domain.SettlementTransaction;
+import com.paymentgateway.settlement.repository.LedgerEntryRepository;
+import com.paymentgateway.settlement.repository.MerchantRepository;
+import com.paymentgateway.settlement.repository.PayoutRepository;
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.extension.ExtendWith;
+import org.mockito.Mock;
+import org.mockito.junit.jupiter.MockitoExtension;
+
+import java.math.BigDecimal;
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.time.LocalDate;
+import java.time.OffsetDateTime;
+import java.time.ZoneOffset;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.assertj.core.api.Assertions.tuple;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyList;
+import static org.mockito.Mockito.*;
+
+@ExtendWith(MockitoExtension.class)
+class LedgerServiceTest {
+    
+    @Mock
+    private LedgerEntryRepository entryRepository;
+    
+    @Mock
+    private PayoutRepository payoutRepository;
+    
+    @Mock
+    private MerchantRepository merchantRepository;
+    
+    private LedgerService ledgerService;
+    
+    @BeforeEach
+    void setUp() {
+        ledgerService = new LedgerService(entryRepository, payoutRepository);
+    }
+    
+    @Test
+    void shouldPostSplitsToSubMerchantLedgers() {
+        // Given a 100.00 payment with 60.00 split to a seller for a 5.00 fee
+        UUID platformId = UUID.randomUUID();
+        UUID sellerId = UUID.randomUUID();
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setMerchantId(platformId);
+        payment.getSplits().add(new PaymentSplit(sellerId, new BigDecimal("60.00"), new BigDecimal("5.00")));
+        SettlementTransaction tx = new SettlementTransaction(UUID.randomUUID(), payment.getId(),
+            new BigDecimal("100.00"), new BigDecimal("3.20"), new BigDecimal("96.80"), "USD");
+        when(entryRepository.saveAll(anyList())).thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        List<LedgerEntry> entries = ledgerService.post(tx, payment);
+        
+        // Then
+        assertThat(sum(entries, platformId)).isEqualByComparingTo("41.80");
+        assertThat(sum(entries, sellerId)).isEqualByComparingTo("55.00");
+        assertThat(entries.stream().map(LedgerEntry::getAmount).reduce(BigDecimal.ZERO, BigDecimal::add))
+            .isEqualByComparingTo(tx.getNetAmount());
+        assertThat(entries).filteredOn(e -> e.getMerchantId().equals(sellerId))
+            .extracting(LedgerEntry::getEntryType)
+            .containsExactly(LedgerEntryType.SPLIT, LedgerEntryType.SPLIT_FEE);
+    }
+    
+    @Test
+    void shouldTakeSplitsBackInProportionToRefunds() {
+        // Given a posted 100.00 payment with 33.33 split to a seller for a 3.00 fee
+        UUID platformId = UUID.randomUUID();
+        UUID sellerId = UUID.randomUUID();
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setMerchantId(platformId);
+        payment.getSplits().add(new PaymentSplit(sellerId, new BigDecimal("33.33"), new BigDecimal("3.00")));
+        LedgerEntry sale = new LedgerEntry(platformId, UUID.randomUUID(), payment.getId(), LedgerEntryType.PAYMENT,
+            new BigDecimal("100.00"), "USD");
+        List<LedgerEntry> posted = new ArrayList<>(List.of(sale));
+        when(entryRepository.findByPaymentId(payment.getId())).thenReturn(posted);
+        when(entryRepository.saveAll(anyList())).thenAnswer(invocation -> {
+            List<LedgerEntry> entries = invocation.getArgument(0);
+            posted.addAll(entries);
+            return entries;
+        });
+        
+        // When half is refunded twice
+        List<LedgerEntry> first = ledgerService.reverse(payment, LedgerEntryType.REFUND, new BigDecimal("50.00"), "ref_1");
+        List<LedgerEntry> second = ledgerService.reverse(payment, LedgerEntryType.REFUND, new BigDecimal("50.00"), "ref_2");
+        
+        // Then the seller's split and the platform's fee on it come back in full, and no more
+        assertThat(sum(first, sellerId)).isEqualByComparingTo("-15.17");
+        assertThat(sum(second, sellerId)).isEqualByComparingTo("-15.16");
+        assertThat(sum(first, platformId)).isEqualByComparingTo("-34.83");
+        assertThat(first).extracting(LedgerEntry::getBatchId).containsOnly(sale.getBatchId());
+        assertThat(first).extracting(LedgerEntry::getReference).containsOnly("ref_1");
+        assertThat(first).filteredOn(e -> e.getMerchantId().equals(sellerId))
+            .extracting(LedgerEntry::getEntryType)
+            .containsExactly(LedgerEntryType.SPLIT_REVERSAL, LedgerEntryType.SPLIT_FEE_REVERSAL);
+        assertThat(sum(posted, sellerId)).isEqualByComparingTo("-30.33");
+        assertThat(sum(posted, platformId)).isEqualByComparingTo("30.33");
+        
+        // And a refund is posted once
+        assertThat(ledgerService.reverse(payment, LedgerEntryType.REFUND, new BigDecimal("50.00"), "ref_2")).isEmpty();
+    }
+    
+    @Test
+    void shouldKeepSplitFeesOnChargebacksWhenFeesAreNotReturned() {
+        // Given a platform that keeps its fees and a posted 100.00 payment with 60.00 split for a 5.00 fee
+        LedgerService keeping = new LedgerService(entryRepository, payoutRepository, null, Clock.systemUTC(),
+            BigDecimal.ZERO, 0, false);
+        UUID platformId = UUID.randomUUID();
+        UUID sellerId = UUID.randomUUID();
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setMerchantId(platformId);
+        payment.getSplits().add(new PaymentSplit(sellerId, new BigDecimal("60.00"), new BigDecimal("5.00")));
+        when(entryRepository.findByPaymentId(payment.getId())).thenReturn(List.of(new LedgerEntry(platformId,
+            UUID.randomUUID(), payment.getId(), LedgerEntryType.PAYMENT, new BigDecimal("100.00"), "USD")));
+        when(entryRepository.saveAll(anyList())).thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When the whole payment is charged back
+        List<LedgerEntry> entries = keeping.reverse(payment, LedgerEntryType.CHARGEBACK, new BigDecimal("100.00"), "dis_1");
+        
+        // Then the seller loses its split and the platform the rest
+        assertThat(sum(entries, sellerId)).isEqualByComparingTo("-60.00");
+        assertThat(sum(entries, platformId)).isEqualByComparingTo("-40.00");
+        assertThat(entries).extracting(LedgerEntry::getEntryType)
+            .doesNotContain(LedgerEntryType.SPLIT_FEE_REVERSAL);
+    }
+    
+    @Test
+    void shouldNotReversePaymentsThatWereNeverPosted() {
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setMerchantId(UUID.randomUUID());
+        when(entryRepository.findByPaymentId(payment.getId())).thenReturn(List.of());
+        
+        assertThat(ledgerService.reverse(payment, LedgerEntryType.REFUND, new BigDecimal("10.00"), "ref_1")).isEmpty();
+        verify(entryRepository, never()).saveAll(anyList());
+    }
+    
+    @Test
+    void shouldPayOutPositiveBalancesAndCarryForwardTheRest() {
+        // Given
+        UUID sellerId = UUID.randomUUID();
+        UUID refundedId = UUID.randomUUID();
+        LedgerEntry sale = entry(sellerId, "55.00");
+        LedgerEntry fee = entry(sellerId, "-5.00");
+        LedgerEntry credit = entry(refundedId, "-40.00");
+        when(entryRepository.findByPayoutIdIsNull()).thenReturn(List.of(sale, fee, credit));
+        when(payoutRepository.save(any(Payout.class))).thenAnswer(invocation -> {
+            Payout payout = invocation.getArgument(0);
+            payout.setId(UUID.randomUUID());
+            return payout;
+        });
+        
+        // When
+        List<Payout> payouts = ledgerService.createPayouts();
+        
+        // Then
+        assertThat(payouts).hasSize(1);
+        assertThat(payouts.get(0).getMerchantId()).isEqualTo(sellerId);
+        assertThat(payouts.get(0).getAmount()).isEqualByComparingTo("50.00");
+        assertThat(payouts.get(0).getEntryCount()).isEqualTo(2);
+        assertThat(sale.getPayoutId()).isEqualTo(payouts.get(0).getId());
+        assertThat(credit.getPayoutId()).isNull();
+    }
+    
+    @Test
+    void shouldHoldReserveFromSalesAndReleaseItAfterTheHoldPeriod() {
+        // Given a merchant holding 10% for 90 days
+        UUID merchantId = UUID.randomUUID();
+        Merchant merchant = new Merchant();
+        merchant.setId(merchantId);
+        merchant.setReservePercentage(new BigDecimal("10.00"));
+        merchant.setReserveHoldDays(90);
+        SimulatedClock clock = new SimulatedClock(Clock.fixed(Instant.parse("2026-05-04T12:00:00Z"), ZoneOffset.UTC));
+        LedgerService reserving = new LedgerService(entryRepository, payoutRepository, merchantRepository,
+            clock, BigDecimal.ZERO, 30);
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setMerchantId(merchantId);
+        SettlementTransaction tx = new SettlementTransaction(UUID.randomUUID(), payment.getId(),
+            new BigDecimal("100.00"), new BigDecimal("3.20"), new BigDecimal("96.80"), "USD");
+        when(merchantRepository.findById(merchantId)).thenReturn(Optional.of(merchant));
+        when(entryRepository.saveAll(anyList())).thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        List<LedgerEntry> entries = reserving.post(tx, payment);
+        
+        // Then the payout is 86.80, and 10.00 is due back in 90 days
+        assertThat(sum(entries, merchantId)).isEqualByComparingTo("86.80");
+        LedgerEntry hold = entries.get(entries.size() - 1);
+        assertThat(hold.getEntryType()).isEqualTo(LedgerEntryType.RESERVE_HOLD);
+        assertThat(hold.getReleaseAt()).isEqualTo(OffsetDateTime.parse("2026-08-02T12:00:00Z"));
+        
+        // When the clock reaches the release
+        clock.advance(Duration.ofDays(90));
+        when(entryRepository.findByEntryTypeAndReleasedAtIsNullAndReleaseAtLessThanEqual(
+            LedgerEntryType.RESERVE_HOLD, OffsetDateTime.parse("2026-08-02T12:00:00Z"))).thenReturn(List.of(hold));
+        List<LedgerEntry> releases = reserving.releaseReserves();
+        
+        // Then the reserve is credited back
+        assertThat(releases).singleElement().satisfies(release -> {
+            assertThat(release.getEntryType()).isEqualTo(LedgerEntryType.RESERVE_RELEASE);
+            assertThat(release.getAmount()).isEqualByComparingTo("10.00");
+        });
+        assertThat(hold.getReleasedAt()).isNotNull();
+    }
+    
+    @Test
+    void shouldReportHeldReserveByReleaseDate() {
+        UUID merchantId = UUID.randomUUID();
+        LedgerEntry first = hold(merchantId, "-10.00", "2026-08-02T12:00:00Z");
+        LedgerEntry second = hold(merchantId, "-2.50", "2026-08-02T18:00:00Z");
+        LedgerEntry third = hold(merchantId, "-4.00", "2026-08-03T09:00:00Z");
+        when(entryRepository.findByMerchantIdAndEntryTypeAndReleasedAtIsNullOrderByReleaseAt(
+            merchantId, LedgerEntryType.RESERVE_HOLD)).thenReturn(List.of(first, second, third));
+        
+        assertThat(ledgerService.reserves(merchantId)).containsOnlyKeys("USD");
+        assertThat(ledgerService.reserves(merchantId).get("USD")).isEqualByComparingTo("16.50");
+        assertThat(ledgerService.reserveReleases(merchantId))
+            .extracting(ReserveRelease::getDate, release -> release.getAmount().toPlainString())
+            .containsExactly(
+                tuple(LocalDate.parse("2026-08-02"), "12.50"),
+                tuple(LocalDate.parse("2026-08-03"), "4.00"));
+    }
+    
+    private static LedgerEntry hold(UUID merchantId, String amount, String releaseAt) {
+        LedgerEntry hold = new LedgerEntry(merchantId, UUID.randomUUID(), UUID.randomUUID(),
+            LedgerEntryType.RESERVE_HOLD, new BigDecimal(amount), "USD");
+        hold.setReleaseAt(OffsetDateTime.parse(releaseAt));
+        return hold;
+    }
+    
+    private static LedgerEntry entry(UUID merchantId, String amount) {
+        return new LedgerEntry(merchantId, UUID.randomUUID(), UUID.randomUUID(), LedgerEntryType.SPLIT,
+            new BigDecimal(amount), "USD");
+    }
+    
+    private static BigDecimal sum(List<LedgerEntry> entries, UUID merchantId) {
+        return entries.stream()
+            .filter(e -> e.getMerchantId().equals(merchantId))
+            .map(LedgerEntry::getAmount)
+            .reduce(BigDecimal.ZERO, BigDecimal::add);
+    }
+}
@@ -1,20 +1,31 @@
 package com.paymentgateway.settlement.service;
 
+import com.paymentgateway.settlement.calendar.SettlementCalendar;
+import com.paymentgateway.settlement.domain.InterchangeProgram;
+import com.paymentgateway.settlement.domain.LedgerEntry;
+import com.paymentgateway.settlement.domain.LedgerEntryType;
+import com.paymentgateway.settlement.domain.Merchant;
 import com.paymentgateway.settlement.domain.Payment;
+import com.paymentgateway.settlement.domain.Refund;
 import com.paymentgateway.settlement.domain.SettlementBatch;
 import com.paymentgateway.settlement.domain.SettlementStatus;
 import com.paymentgateway.settlement.domain.SettlementTransaction;
+import com.paymentgateway.settlement.event.SettlementEventOutbox;
+import com.paymentgateway.settlement.repository.MerchantRepository;
 import com.paymentgateway.settlement.repository.PaymentRepository;
+import com.paymentgateway.settlement.repository.RefundRepository;
 import com.paymentgateway.settlement.repository.SettlementBatchRepository;
 import com.paymentgateway.settlement.repository.SettlementTransactionRepository;
 import org.junit.jupiter.api.BeforeEach;
 import org.junit.jupiter.api.Test;
 import org.junit.jupiter.api.extension.ExtendWith;
+import org.mockito.ArgumentCaptor;
 import org.mockito.Mock;
 import org.mockito.junit.jupiter.MockitoExtension;
 
 import java.math.BigDecimal;
 import java.time.LocalDate;
+import java.time.LocalTime;
 import java.time.OffsetDateTime;
 import java.util.ArrayList;
 import java.util.List;
@@ -24,6 +35,7 @@ import java.util.UUID;
 import static org.assertj.core.api.Assertions.assertThat;
 import static org.assertj.core.api.Assertions.assertThatThrownBy;
 import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.eq;
 import static org.mockito.Mockito.*;
 
 @ExtendWith(MockitoExtension.class)
@@ -38,12 +50,26 @@ class SettlementServiceTest {
     @Mock
     private PaymentRepository paymentRepository;
     
+    @Mock
+    private MerchantRepository merchantRepository;
+    
+    @Mock
+    private SettlementEventOutbox eventOutbox;
+    
+    @Mock
+    private LedgerService ledgerService;
+    
+    @Mock
+    private RefundRepository refundRepository;
+    
     private SettlementService settlementService;
     
     @BeforeEach
     void setUp() {
         settlementService = new SettlementService(
-            batchRepository, settlementTransactionRepository, paymentRepository
+            batchRepository, settlementTransactionRepository, paymentRepository,
+            merchantRepository, new SettlementCalendar("UTC", "22:00", ""), eventOutbox, ledgerService,
+            refundRepository
         );
     }
     
@@ -94,6 +120,194 @@ class SettlementServiceTest {
         verify(paymentRepository, times(3)).save(any(Payment.class));
     }
     
+    @Test
+    void shouldSettleStandaloneCreditsAsNegativeAmounts() {
+        // Given
+        UUID merchantId = UUID.randomUUID();
+        Payment sale = new Payment();
+        sale.setId(UUID.randomUUID());
+        sale.setPaymentId("pay_sale");
+        sale.setAmount(new BigDecimal("100.00"));
+        sale.setStatus("CAPTURED");
+        Payment credit = new Payment();
+        credit.setId(UUID.randomUUID());
+        credit.setPaymentId("crd_credit");
+        credit.setAmount(new BigDecimal("40.00"));
+        credit.setStatus("CAPTURED");
+        credit.setTransactionType("CREDIT");
+        
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> {
+            SettlementBatch batch = invocation.getArgument(0);
+            batch.setId(UUID.randomUUID());
+            return batch;
+        });
+        when(settlementTransactionRepository.save(any(SettlementTransaction.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        SettlementBatch batch = settlementService.createBatchForPayments(
+            merchantId, "USD", LocalDate.now(), List.of(sale, credit)
+        );
+        
+        // Then
+        assertThat(batch.getTotalAmount()).isEqualByComparingTo(new BigDecimal("60.00"));
+        ArgumentCaptor<SettlementTransaction> captor = ArgumentCaptor.forClass(SettlementTransaction.class);
+        verify(settlementTransactionRepository, times(2)).save(captor.capture());
+        SettlementTransaction creditTx = captor.getAllValues().get(1);
+        assertThat(creditTx.getGrossAmount()).isEqualByComparingTo(new BigDecimal("-40.00"));
+        assertThat(creditTx.getFeeAmount()).isEqualByComparingTo(BigDecimal.ZERO);
+        assertThat(creditTx.getNetAmount()).isEqualByComparingTo(new BigDecimal("-40.00"));
+        assertThat(credit.getStatus()).isEqualTo("SETTLED");
+    }
+    
+    @Test
+    void shouldClearPurchasingDataAtTheQualifiedInterchangeRate() {
+        // Given a Level 3 sale and a Level 2 sale whose tax is too low to qualify
+        Payment level3 = new Payment();
+        level3.setId(UUID.randomUUID());
+        level3.setPaymentId("pay_level3");
+        level3.setAmount(new BigDecimal("100.00"));
+        level3.setStatus("CAPTURED");
+        level3.setTaxAmount(new BigDecimal("8.00"));
+        level3.setCustomerCode("PO-1001");
+        level3.setPurchaseDataLevel(3);
+        Payment downgraded = new Payment();
+        downgraded.setId(UUID.randomUUID());
+        downgraded.setPaymentId("pay_level2");
+        downgraded.setAmount(new BigDecimal("100.00"));
+        downgraded.setStatus("CAPTURED");
+        downgraded.setTaxAmount(new BigDecimal("0.01"));
+        downgraded.setCustomerCode("PO-1002");
+        downgraded.setPurchaseDataLevel(2);
+        
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> {
+            SettlementBatch batch = invocation.getArgument(0);
+            batch.setId(UUID.randomUUID());
+            return batch;
+        });
+        when(settlementTransactionRepository.save(any(SettlementTransaction.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        settlementService.createBatchForPayments(UUID.randomUUID(), "USD", LocalDate.now(), List.of(level3, downgraded));
+        
+        // Then
+        ArgumentCaptor<SettlementTransaction> captor = ArgumentCaptor.forClass(SettlementTransaction.class);
+        verify(settlementTransactionRepository, times(2)).save(captor.capture());
+        SettlementTransaction level3Tx = captor.getAllValues().get(0);
+        assertThat(level3Tx.getInterchangeProgram()).isEqualTo(InterchangeProgram.LEVEL_3);
+        assertThat(level3Tx.getFeeAmount()).isEqualByComparingTo(new BigDecimal("2.30"));
+        assertThat(level3Tx.getTaxAmount()).isEqualByComparingTo(new BigDecimal("8.00"));
+        assertThat(level3Tx.getCustomerCode()).isEqualTo("PO-1001");
+        SettlementTransaction downgradedTx = captor.getAllValues().get(1);
+        assertThat(downgradedTx.getInterchangeProgram()).isEqualTo(InterchangeProgram.STANDARD);
+        assertThat(downgradedTx.getFeeAmount()).isEqualByComparingTo(new BigDecimal("3.20"));
+        assertThat(downgradedTx.getPurchaseDataLevel()).isEqualTo(2);
+    }
+    
+    @Test
+    void shouldCarryInstallmentPlansToTheClearingRecord() {
+        // Given a sale the cardholder repays in 6 issuer-financed installments
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setPaymentId("pay_installments");
+        payment.setAmount(new BigDecimal("600.00"));
+        payment.setStatus("CAPTURED");
+        payment.setInstallmentCount(6);
+        payment.setInstallmentPlanType("ISSUER");
+        
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> {
+            SettlementBatch batch = invocation.getArgument(0);
+            batch.setId(UUID.randomUUID());
+            return batch;
+        });
+        when(settlementTransactionRepository.save(any(SettlementTransaction.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        settlementService.createBatchForPayments(UUID.randomUUID(), "BRL", LocalDate.now(), List.of(payment));
+        
+        // Then
+        ArgumentCaptor<SettlementTransaction> captor = ArgumentCaptor.forClass(SettlementTransaction.class);
+        verify(settlementTransactionRepository).save(captor.capture());
+        assertThat(captor.getValue().getInstallmentCount()).isEqualTo(6);
+        assertThat(captor.getValue().getInstallmentPlanType()).isEqualTo("ISSUER");
+    }
+    
+    @Test
+    void shouldChargeTheCrossBorderMarkupOnCardsIssuedAbroad() {
+        // Given a standard sale on a card issued outside the merchant's country
+        Payment payment = createCapturedPayment(UUID.randomUUID(), "2026-03-02T10:00:00Z");
+        payment.setCrossBorder(true);
+        
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> {
+            SettlementBatch batch = invocation.getArgument(0);
+            batch.setId(UUID.randomUUID());
+            return batch;
+        });
+        when(settlementTransactionRepository.save(any(SettlementTransaction.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        settlementService.createBatchForPayments(UUID.randomUUID(), "USD", LocalDate.now(), List.of(payment));
+        
+        // Then the 1% markup is added to the standard fee
+        ArgumentCaptor<SettlementTransaction> captor = ArgumentCaptor.forClass(SettlementTransaction.class);
+        verify(settlementTransactionRepository).save(captor.capture());
+        assertThat(captor.getValue().getCrossBorderFee()).isEqualByComparingTo("1.00");
+        assertThat(captor.getValue().getFeeAmount()).isEqualByComparingTo("4.20");
+        assertThat(captor.getValue().getNetAmount()).isEqualByComparingTo("95.80");
+    }
+    
+    @Test
+    void shouldSliceBatchesBySettlementDateInMerchantTimezone() {
+        // Given a merchant in New York with a 17:00 cut-off, on Tuesday 2026-03-10 at 23:00 UTC
+        UUID merchantId = UUID.randomUUID();
+        Merchant merchant = new Merchant();
+        merchant.setId(merchantId);
+        merchant.setSettlementTimezone("America/New_York");
+        merchant.setSettlementCutoffTime(LocalTime.of(17, 0));
+        OffsetDateTime now = OffsetDateTime.parse("2026-03-10T23:00:00Z");
+        
+        // Monday 16:00 and Tuesday 10:00 local are before their cut-offs,
+        // Monday 18:00 local rolls to Tuesday and Tuesday 18:00 is still open
+        Payment monday = createCapturedPayment(merchantId, "2026-03-09T20:00:00Z");
+        Payment mondayLate = createCapturedPayment(merchantId, "2026-03-09T22:00:00Z");
+        Payment tuesday = createCapturedPayment(merchantId, "2026-03-10T14:00:00Z");
+        Payment tuesdayLate = createCapturedPayment(merchantId, "2026-03-10T22:00:00Z");
+        
+        when(paymentRepository.findUnsettledCapturedPayments(now))
+            .thenReturn(List.of(monday, mondayLate, tuesday, tuesdayLate));
+        when(merchantRepository.findById(merchantId)).thenReturn(Optional.of(merchant));
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> {
+            SettlementBatch batch = invocation.getArgument(0);
+            batch.setId(UUID.randomUUID());
+            return batch;
+        });
+        when(settlementTransactionRepository.save(any(SettlementTransaction.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        when(paymentRepository.save(any(Payment.class)))
+            .thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        List<SettlementBatch> batches = settlementService.createSettlementBatches(now);
+        
+        // Then
+        assertThat(batches).extracting(SettlementBatch::getSettlementDate)
+            .containsExactly(LocalDate.of(2026, 3, 9), LocalDate.of(2026, 3, 10));
+        assertThat(batches).extracting(SettlementBatch::getTransactionCount).containsExactly(1, 2);
+        assertThat(tuesdayLate.getStatus()).isEqualTo("CAPTURED");
+        verify(merchantRepository, times(1)).findById(merchantId);
+    }
+    
     @Test
     void shouldSubmitBatchToAcquirer() {
         // Given
@@ -197,4 +411,82 @@ class SettlementServiceTest {
             .isInstanceOf(IllegalArgumentException.class)
             .hasMessageContaining("Batch not found");
     }
+    
+    private Payment createCapturedPayment(UUID merchantId, String capturedAt) {
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setPaymentId("pay_" + capturedAt);
+        payment.setMerchantId(merchantId);
+        payment.setAmount(new BigDecimal("100.00"));
+        payment.setCurrency("USD");
+        payment.setStatus("CAPTURED");
+        payment.setCapturedAt(OffsetDateTime.parse(capturedAt));
+        return payment;
+    }
+    
+    @Test
+    void shouldQueueSettledEventForEachPayment() {
+        // Given
+        UUID merchantId = UUID.randomUUID();
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setPaymentId("pay_settled");
+        payment.setMerchantId(merchantId);
+        payment.setAmount(new BigDecimal("25.00"));
+        payment.setCurrency("USD");
+        payment.setStatus("CAPTURED");
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        SettlementBatch batch = settlementService.createBatchForPayments(merchantId, "USD", LocalDate.now(), List.of(payment));
+        
+        // Then
+        ArgumentCaptor<Payment> settled = ArgumentCaptor.forClass(Payment.class);
+        verify(eventOutbox).paymentSettled(settled.capture(), eq(batch.getBatchId()));
+        assertThat(settled.getValue().getStatus()).isEqualTo("SETTLED");
+    }
+    
+    @Test
+    void shouldPostEachSettledPaymentToTheLedger() {
+        // Given
+        UUID merchantId = UUID.randomUUID();
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        payment.setPaymentId("pay_split");
+        payment.setMerchantId(merchantId);
+        payment.setAmount(new BigDecimal("100.00"));
+        payment.setCurrency("USD");
+        payment.setStatus("CAPTURED");
+        when(batchRepository.save(any(SettlementBatch.class))).thenAnswer(invocation -> invocation.getArgument(0));
+        
+        // When
+        settlementService.createBatchForPayments(merchantId, "USD", LocalDate.now(), List.of(payment));
+        
+        // Then
+        ArgumentCaptor<SettlementTransaction> posted = ArgumentCaptor.forClass(SettlementTransaction.class);
+        verify(ledgerService).post(posted.capture(), eq(payment));
+        assertThat(posted.getValue().getGrossAmount()).isEqualByComparingTo("100.00");
+    }
+    
+    @Test
+    void shouldPostCompletedRefundsOfSettledPayments() {
+        // Given one refund of a settled payment and one of a payment since deleted
+        Payment payment = new Payment();
+        payment.setId(UUID.randomUUID());
+        Refund refund = new Refund("ref_split", payment.getId(), new BigDecimal("50.00"), "USD");
+        Refund orphan = new Refund("ref_orphan", UUID.randomUUID(), new BigDecimal("5.00"), "USD");
+        when(refundRepository.findUnpostedCompletedRefunds()).thenReturn(List.of(refund, orphan));
+        when(paymentRepository.findById(payment.getId())).thenReturn(Optional.of(payment));
+        when(paymentRepository.findById(orphan.getPaymentId())).thenReturn(Optional.empty());
+        when(ledgerService.reverse(payment, LedgerEntryType.REFUND, new BigDecimal("50.00"), "ref_split"))
+            .thenReturn(List.of(new LedgerEntry()));
+        
+        // When
+        int posted = settlementService.postRefunds();
+        
+        // Then
+        assertThat(posted).isEqualTo(1);
+        verify(ledgerService).reverse(payment, LedgerEntryType.REFUND, new BigDecimal("50.00"), "ref_split");
+        verifyNoMoreInteractions(ledgerService);
+    }
 }
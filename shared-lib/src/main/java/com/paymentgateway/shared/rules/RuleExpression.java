@@ -0,0 +1,322 @@
+package com.paymentgateway.shared.rules;
+
+import java.math.BigDecimal;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+
+/**
+ * A parsed rule condition. The language is deliberately small:
+ *
+ * <pre>
+ * amount &gt; 10000
+ * country = 'NG' AND hour &gt;= 22
+ * currency IN ('USD', 'EUR') OR NOT (metadata.channel = 'web')
+ * </pre>
+ *
+ * Operands are attribute names, numbers and single-quoted strings. Values are
+ * compared as numbers when both sides are numeric and as strings otherwise.
+ * A comparison involving a missing attribute is false.
+ */
+public final class RuleExpression {
+
+    private final String source;
+    private final Node root;
+
+    private RuleExpression(String source, Node root) {
+        this.source = source;
+        this.root = root;
+    }
+
+    /**
+     * Parses a condition, failing on any syntax error so a bad rule is
+     * rejected when loaded rather than silently never matching.
+     */
+    public static RuleExpression parse(String source) {
+        if (source == null || source.isBlank()) {
+            throw new RuleSyntaxException("empty condition");
+        }
+        Parser parser = new Parser(tokenize(source));
+        Node root = parser.parseOr();
+        if (!parser.atEnd()) {
+            throw new RuleSyntaxException("unexpected '" + parser.peek().text + "' in: " + source);
+        }
+        return new RuleExpression(source, root);
+    }
+
+    public boolean matches(Map<String, Object> attributes) {
+        return root.eval(attributes);
+    }
+
+    public String getSource() {
+        return source;
+    }
+
+    @Override
+    public String toString() {
+        return source;
+    }
+
+    // Syntax tree
+
+    private interface Node {
+        boolean eval(Map<String, Object> attributes);
+    }
+
+    private interface Operand {
+        Object value(Map<String, Object> attributes);
+    }
+
+    private static Operand attribute(String name) {
+        return attributes -> attributes.get(name);
+    }
+
+    private static Operand literal(Object value) {
+        return attributes -> value;
+    }
+
+    private static Node comparison(Operand left, String op, Operand right) {
+        return attributes -> {
+            Object l = left.value(attributes);
+            Object r = right.value(attributes);
+            if (l == null || r == null) {
+                return false;
+            }
+            int cmp = compare(l, r);
+            return switch (op) {
+                case "=", "==" -> cmp == 0;
+                case "!=" -> cmp != 0;
+                case ">" -> cmp > 0;
+                case ">=" -> cmp >= 0;
+                case "<" -> cmp < 0;
+                case "<=" -> cmp <= 0;
+                default -> throw new IllegalStateException("unknown operator " + op);
+            };
+        };
+    }
+
+    private static Node in(Operand left, List<Object> values) {
+        return attributes -> {
+            Object l = left.value(attributes);
+            if (l == null) {
+                return false;
+            }
+            for (Object v : values) {
+                if (compare(l, v) == 0) {
+                    return true;
+                }
+            }
+            return false;
+        };
+    }
+
+    private static int compare(Object l, Object r) {
+        BigDecimal ln = toNumber(l);
+        BigDecimal rn = toNumber(r);
+        if (ln != null && rn != null) {
+            return ln.compareTo(rn);
+        }
+        return l.toString().compareTo(r.toString());
+    }
+
+    private static BigDecimal toNumber(Object value) {
+        if (value instanceof BigDecimal d) {
+            return d;
+        }
+        if (value instanceof Number n) {
+            return new BigDecimal(n.toString());
+        }
+        return null;
+    }
+
+    // Tokenizer
+
+    private enum TokenType { IDENT, NUMBER, STRING, OPERATOR, LPAREN, RPAREN, COMMA }
+
+    private static final class Token {
+        final TokenType type;
+        final String text;
+
+        Token(TokenType type, String text) {
+            this.type = type;
+            this.text = text;
+        }
+
+        boolean isKeyword(String keyword) {
+            return type == TokenType.IDENT && text.equalsIgnoreCase(keyword);
+        }
+    }
+
+    private static List<Token> tokenize(String source) {
+        List<Token> tokens = new ArrayList<>();
+        int i = 0;
+        while (i < source.length()) {
+            char c = source.charAt(i);
+            if (Character.isWhitespace(c)) {
+                i++;
+            } else if (c == '(') {
+                tokens.add(new Token(TokenType.LPAREN, "("));
+                i++;
+            } else if (c == ')') {
+                tokens.add(new Token(TokenType.RPAREN, ")"));
+                i++;
+            } else if (c == ',') {
+                tokens.add(new Token(TokenType.COMMA, ","));
+                i++;
+            } else if (c == '\'') {
+                int end = source.indexOf('\'', i + 1);
+                if (end < 0) {
+                    throw new RuleSyntaxException("unterminated string in: " + source);
+                }
+                tokens.add(new Token(TokenType.STRING, source.substring(i + 1, end)));
+                i = end + 1;
+            } else if (Character.isDigit(c) || (c == '-' && i + 1 < source.length() && Character.isDigit(source.charAt(i + 1)))) {
+                int start = i++;
+                while (i < source.length() && (Character.isDigit(source.charAt(i)) || source.charAt(i) == '.')) {
+                    i++;
+                }
+                tokens.add(new Token(TokenType.NUMBER, source.substring(start, i)));
+            } else if (Character.isLetter(c) || c == '_') {
+                int start = i;
+                while (i < source.length() && (Character.isLetterOrDigit(source.charAt(i))
+                        || source.charAt(i) == '_' || source.charAt(i) == '.')) {
+                    i++;
+                }
+                tokens.add(new Token(TokenType.IDENT, source.substring(start, i)));
+            } else if ("=!<>".indexOf(c) >= 0) {
+                int start = i++;
+                if (i < source.length() && source.charAt(i) == '=') {
+                    i++;
+                }
+                String op = source.substring(start, i);
+                if (op.equals("!")) {
+                    throw new RuleSyntaxException("expected '!=' in: " + source);
+                }
+                tokens.add(new Token(TokenType.OPERATOR, op));
+            } else {
+                throw new RuleSyntaxException("unexpected character '" + c + "' in: " + source);
+            }
+        }
+        return tokens;
+    }
+
+    // Recursive-descent parser: OR binds loosest, then AND, then NOT
+
+    private static final class Parser {
+        private final List<Token> tokens;
+        private int pos;
+
+        Parser(List<Token> tokens) {
+            this.tokens = tokens;
+        }
+
+        boolean atEnd() {
+            return pos >= tokens.size();
+        }
+
+        Token peek() {
+            if (atEnd()) {
+                throw new RuleSyntaxException("unexpected end of condition");
+            }
+            return tokens.get(pos);
+        }
+
+        Token next() {
+            Token t = peek();
+            pos++;
+            return t;
+        }
+
+        boolean acceptKeyword(String keyword) {
+            if (!atEnd() && peek().isKeyword(keyword)) {
+                pos++;
+                return true;
+            }
+            return false;
+        }
+
+        Token expect(TokenType type) {
+            Token t = next();
+            if (t.type != type) {
+                throw new RuleSyntaxException("expected " + type.name().toLowerCase(Locale.ROOT) + " but found '" + t.text + "'");
+            }
+            return t;
+        }
+
+        Node parseOr() {
+            Node left = parseAnd();
+            while (acceptKeyword("OR")) {
+                Node l = left;
+                Node r = parseAnd();
+                left = attributes -> l.eval(attributes) || r.eval(attributes);
+            }
+            return left;
+        }
+
+        Node parseAnd() {
+            Node left = parseNot();
+            while (acceptKeyword("AND")) {
+                Node l = left;
+                Node r = parseNot();
+                left = attributes -> l.eval(attributes) && r.eval(attributes);
+            }
+            return left;
+        }
+
+        Node parseNot() {
+            if (acceptKeyword("NOT")) {
+                Node inner = parseNot();
+                return attributes -> !inner.eval(attributes);
+            }
+            if (peek().type == TokenType.LPAREN) {
+                next();
+                Node inner = parseOr();
+                expect(TokenType.RPAREN);
+                return inner;
+            }
+            return parseComparison();
+        }
+
+        Node parseComparison() {
+            Operand left = parseOperand();
+            if (acceptKeyword("IN")) {
+                expect(TokenType.LPAREN);
+                List<Object> values = new ArrayList<>();
+                values.add(parseLiteral());
+                while (!atEnd() && peek().type == TokenType.COMMA) {
+                    next();
+                    values.add(parseLiteral());
+                }
+                expect(TokenType.RPAREN);
+                return in(left, values);
+            }
+            Token op = expect(TokenType.OPERATOR);
+            return comparison(left, op.text, parseOperand());
+        }
+
+        Operand parseOperand() {
+            Token t = peek();
+            if (t.type == TokenType.IDENT) {
+                next();
+                return attribute(t.text);
+            }
+            return literal(parseLiteral());
+        }
+
+        Object parseLiteral() {
+            Token t = next();
+            return switch (t.type) {
+                case NUMBER -> {
+                    try {
+                        yield new BigDecimal(t.text);
+                    } catch (NumberFormatException e) {
+                        throw new RuleSyntaxException("invalid number '" + t.text + "'");
+                    }
+                }
+                case STRING -> t.text;
+                default -> throw new RuleSyntaxException("expected a value but found '" + t.text + "'");
+            };
+        }
+    }
+}
@@ -0,0 +1,11 @@
+package com.paymentgateway.shared.rules;
+
+/**
+ * Thrown when a rule condition or rules file cannot be parsed
+ */
+public class RuleSyntaxException extends RuntimeException {
+
+    public RuleSyntaxException(String message) {
+        super(message);
+    }
+}
@@ -0,0 +1,106 @@
+package com.paymentgateway.shared.scheduling;
+
+import org.springframework.jdbc.core.JdbcTemplate;
+import org.springframework.transaction.support.TransactionOperations;
+
+import java.sql.Timestamp;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.List;
+import java.util.Optional;
+
+/**
+ * Leases and run claims in the shared database. Expiry is judged by the
+ * database clock, so instances with skewed clocks still agree on who leads.
+ * The tables are {@code job_leases} and {@code job_runs}.
+ */
+public class JobLeaseRepository {
+    
+    // Renews the lease for its owner, or takes it over once it has expired.
+    // The fencing token grows only when the owner changes.
+    private static final String TRY_ACQUIRE =
+        "INSERT INTO job_leases (name, owner_id, fencing_token, acquired_at, expires_at) " +
+        "VALUES (?, ?, 1, NOW(), NOW() + ? * INTERVAL '1 millisecond') " +
+        "ON CONFLICT (name) DO UPDATE SET " +
+        "fencing_token = CASE WHEN job_leases.owner_id = EXCLUDED.owner_id " +
+        "THEN job_leases.fencing_token ELSE job_leases.fencing_token + 1 END, " +
+        "acquired_at = CASE WHEN job_leases.owner_id = EXCLUDED.owner_id " +
+        "THEN job_leases.acquired_at ELSE NOW() END, " +
+        "owner_id = EXCLUDED.owner_id, expires_at = EXCLUDED.expires_at " +
+        "WHERE job_leases.owner_id = EXCLUDED.owner_id OR job_leases.expires_at <= NOW() " +
+        "RETURNING fencing_token";
+    
+    // Expires the lease rather than deleting it, so the next leader's
+    // fencing token is still larger
+    private static final String RELEASE =
+        "UPDATE job_leases SET expires_at = NOW() " +
+        "WHERE name = ? AND owner_id = ? AND fencing_token = ?";
+    
+    // A run still RUNNING under an older token was left by a leader that
+    // lost its lease, and is taken over
+    private static final String CLAIM_RUN =
+        "INSERT INTO job_runs (job_name, scheduled_for, owner_id, fencing_token, status, started_at) " +
+        "VALUES (?, ?, ?, ?, 'RUNNING', NOW()) " +
+        "ON CONFLICT (job_name, scheduled_for) DO UPDATE SET " +
+        "owner_id = EXCLUDED.owner_id, fencing_token = EXCLUDED.fencing_token, started_at = NOW() " +
+        "WHERE job_runs.status = 'RUNNING' AND job_runs.fencing_token < EXCLUDED.fencing_token";
+    
+    private static final String FINISH_RUN =
+        "UPDATE job_runs SET status = ?, finished_at = NOW() " +
+        "WHERE job_name = ? AND scheduled_for = ? AND fencing_token = ? AND status = 'RUNNING'";
+    
+    private final JdbcTemplate jdbcTemplate;
+    private final TransactionOperations transactions;
+    
+    public JobLeaseRepository(JdbcTemplate jdbcTemplate) {
+        this(jdbcTemplate, TransactionOperations.withoutTransaction());
+    }
+    
+    /**
+     * @param transactions Commits each statement on its own, for a data
+     *                     source that does not auto-commit
+     */
+    public JobLeaseRepository(JdbcTemplate jdbcTemplate, TransactionOperations transactions) {
+        this.jdbcTemplate = jdbcTemplate;
+        this.transactions = transactions;
+    }
+    
+    /**
+     * Take or renew the lease for ttl
+     *
+     * @return The lease's fencing token, or empty if another owner holds it
+     */
+    public Optional<Long> tryAcquire(String name, String ownerId, Duration ttl) {
+        List<Long> tokens = transactions.execute(status ->
+            jdbcTemplate.queryForList(TRY_ACQUIRE, Long.class, name, ownerId, ttl.toMillis()));
+        return tokens.stream().findFirst();
+    }
+    
+    public void release(String name, String ownerId, long fencingToken) {
+        transactions.executeWithoutResult(status -> jdbcTemplate.update(RELEASE, name, ownerId, fencingToken));
+    }
+    
+    /**
+     * Claim one scheduled run of a job
+     *
+     * @return Whether the run is this owner's to execute
+     */
+    public boolean claimRun(String jobName, Instant scheduledFor, String ownerId, long fencingToken) {
+        return update(CLAIM_RUN, jobName, Timestamp.from(scheduledFor), ownerId, fencingToken) == 1;
+    }
+    
+    /**
+     * Record how a claimed run ended
+     *
+     * @return False if a newer leader has taken the run over
+     */
+    public boolean finishRun(String jobName, Instant scheduledFor, long fencingToken, boolean succeeded) {
+        return update(FINISH_RUN, succeeded ? "SUCCEEDED" : "FAILED",
+                      jobName, Timestamp.from(scheduledFor), fencingToken) == 1;
+    }
+    
+    private int update(String sql, Object... args) {
+        Integer rows = transactions.execute(status -> jdbcTemplate.update(sql, args));
+        return rows != null ? rows : 0;
+    }
+}
@@ -0,0 +1,137 @@
+package com.paymentgateway.shared.scheduling;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.Optional;
+import java.util.UUID;
+
+/**
+ * Elects one instance of the service to run its singleton jobs. Every
+ * instance tries to take or renew a lease in the shared database on each
+ * heartbeat; the holder leads until it fails to renew, and another instance
+ * takes over once the lease expires.
+ *
+ * <p>An instance counts itself leader only until the lease it last renewed
+ * would expire, measured from before the renewal was sent, so it stops
+ * before anyone else can start, even when it cannot reach the database.
+ *
+ * <p>Each service elects its own leader under its own lease name. The
+ * service calls {@link #heartbeat} on a schedule well within the lease TTL
+ * and {@link #resign} on shutdown.
+ */
+public class LeaderElection {
+    
+    private static final Logger logger = LoggerFactory.getLogger(LeaderElection.class);
+    
+    private final JobLeaseRepository repository;
+    private final String leaseName;
+    private final String instanceId;
+    private final Duration leaseTtl;
+    private final Clock clock;
+    private volatile Lease lease;
+    
+    /**
+     * @param instanceId Names this instance in the lease; blank for the
+     *                   hostname plus a random suffix
+     */
+    public LeaderElection(JobLeaseRepository repository, String leaseName, String instanceId, Duration leaseTtl) {
+        this(repository, leaseName, instanceId, leaseTtl, Clock.systemUTC());
+    }
+    
+    public LeaderElection(JobLeaseRepository repository, String leaseName, String instanceId, Duration leaseTtl,
+                          Clock clock) {
+        this.repository = repository;
+        this.leaseName = leaseName;
+        this.instanceId = instanceId == null || instanceId.isBlank() ? defaultInstanceId(leaseName) : instanceId;
+        this.leaseTtl = leaseTtl;
+        this.clock = clock;
+    }
+    
+    /**
+     * Take or renew the lease. Runs well within the lease TTL so a live
+     * leader never loses it.
+     */
+    public void heartbeat() {
+        Instant sent = clock.instant();
+        Optional<Long> token;
+        try {
+            token = repository.tryAcquire(leaseName, instanceId, leaseTtl);
+        } catch (RuntimeException e) {
+            // Keep leading until the lease we hold runs out locally
+            logger.warn("Could not renew leader lease for {}: {}", instanceId, e.getMessage());
+            return;
+        }
+        Lease previous = lease;
+        if (token.isPresent()) {
+            lease = new Lease(token.get(), sent.plus(leaseTtl));
+            if (previous == null || previous.fencingToken != token.get()) {
+                logger.info("Instance {} is now leader (fencing token {})", instanceId, token.get());
+            }
+        } else {
+            lease = null;
+            if (previous != null) {
+                logger.info("Instance {} lost leadership", instanceId);
+            }
+        }
+    }
+    
+    public boolean isLeader() {
+        Lease current = lease;
+        return current != null && clock.instant().isBefore(current.validUntil);
+    }
+    
+    /**
+     * The fencing token of the current lease, which orders this leader
+     * after every earlier one
+     */
+    public Optional<Long> fencingToken() {
+        Lease current = lease;
+        return current != null && clock.instant().isBefore(current.validUntil)
+            ? Optional.of(current.fencingToken) : Optional.empty();
+    }
+    
+    public String getLeaseName() {
+        return leaseName;
+    }
+    
+    public String getInstanceId() {
+        return instanceId;
+    }
+    
+    /**
+     * Give up the lease on shutdown so another instance takes over without
+     * waiting for it to expire
+     */
+    public void resign() {
+        Lease current = lease;
+        lease = null;
+        if (current == null) {
+            return;
+        }
+        try {
+            repository.release(leaseName, instanceId, current.fencingToken);
+            logger.info("Instance {} resigned leadership", instanceId);
+        } catch (RuntimeException e) {
+            logger.warn("Could not release leader lease for {}: {}", instanceId, e.getMessage());
+        }
+    }
+    
+    private static String defaultInstanceId(String leaseName) {
+        String host = System.getenv("HOSTNAME");
+        String suffix = UUID.randomUUID().toString().substring(0, 8);
+        return (host == null || host.isBlank() ? leaseName : host) + "-" + suffix;
+    }
+    
+    private static class Lease {
+        private final long fencingToken;
+        private final Instant validUntil;
+        
+        Lease(long fencingToken, Instant validUntil) {
+            this.fencingToken = fencingToken;
+            this.validUntil = validUntil;
+        }
+    }
+}
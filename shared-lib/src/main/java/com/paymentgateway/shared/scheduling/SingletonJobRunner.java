@@ -0,0 +1,76 @@
+package com.paymentgateway.shared.scheduling;
+
+import org.slf4j.Logger;
+import org.slf4j.LoggerFactory;
+
+import java.time.Instant;
+import java.util.Optional;
+
+/**
+ * Runs each scheduled run of a job exactly once across all instances. Only
+ * the leader runs jobs, and it first claims the run in the database, so a
+ * run already done, or being done by another leader, is skipped. A run left
+ * unfinished by a leader that lost its lease is taken over by the next one.
+ */
+public class SingletonJobRunner {
+    
+    private static final Logger logger = LoggerFactory.getLogger(SingletonJobRunner.class);
+    
+    public enum Outcome { NOT_LEADER, ALREADY_CLAIMED, SUCCEEDED, FAILED }
+    
+    private final LeaderElection election;
+    private final JobLeaseRepository repository;
+    
+    public SingletonJobRunner(LeaderElection election, JobLeaseRepository repository) {
+        this.election = election;
+        this.repository = repository;
+    }
+    
+    /**
+     * Run the job for the slot it was scheduled for. Every instance must use
+     * the same slot for the same run, e.g. the cron time truncated to the
+     * minute.
+     */
+    public Outcome run(String jobName, Instant scheduledFor, Runnable job) {
+        Optional<Long> token = election.fencingToken();
+        if (token.isEmpty()) {
+            logger.debug("Skipping {} at {}: instance {} is not leader", jobName, scheduledFor, election.getInstanceId());
+            return Outcome.NOT_LEADER;
+        }
+        if (!repository.claimRun(jobName, scheduledFor, election.getInstanceId(), token.get())) {
+            logger.info("Skipping {} at {}: run already claimed", jobName, scheduledFor);
+            return Outcome.ALREADY_CLAIMED;
+        }
+        
+        boolean succeeded = false;
+        try {
+            job.run();
+            succeeded = true;
+        } catch (RuntimeException e) {
+            logger.error("Job {} at {} failed", jobName, scheduledFor, e);
+        }
+        if (!repository.finishRun(jobName, scheduledFor, token.get(), succeeded)) {
+            logger.warn("Job {} at {} was taken over by a newer leader before it finished", jobName, scheduledFor);
+        }
+        return succeeded ? Outcome.SUCCEEDED : Outcome.FAILED;
+    }
+    
+    /**
+     * Run a polling job on the leader only, without claiming the run. For
+     * jobs that fire every few seconds to pick up whatever work is due,
+     * where a claim per firing would only fill the run table.
+     */
+    public Outcome runOnLeader(String jobName, Runnable job) {
+        if (election.fencingToken().isEmpty()) {
+            logger.debug("Skipping {}: instance {} is not leader", jobName, election.getInstanceId());
+            return Outcome.NOT_LEADER;
+        }
+        try {
+            job.run();
+            return Outcome.SUCCEEDED;
+        } catch (RuntimeException e) {
+            logger.error("Job {} failed", jobName, e);
+            return Outcome.FAILED;
+        }
+    }
+}
@@ -0,0 +1,119 @@
+package com.paymentgateway.shared.scheduling;
+
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.extension.ExtendWith;
+import org.mockito.Mock;
+import org.mockito.junit.jupiter.MockitoExtension;
+import org.springframework.dao.DataAccessResourceFailureException;
+
+import java.time.Clock;
+import java.time.Duration;
+import java.time.Instant;
+import java.time.ZoneOffset;
+import java.util.Optional;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.ArgumentMatchers.any;
+import static org.mockito.ArgumentMatchers.anyString;
+import static org.mockito.Mockito.*;
+
+@ExtendWith(MockitoExtension.class)
+class LeaderElectionTest {
+    
+    private static final String LEASE = "test-service";
+    private static final Duration TTL = Duration.ofSeconds(30);
+    
+    @Mock
+    private JobLeaseRepository repository;
+    
+    private MutableClock clock;
+    private LeaderElection election;
+    
+    @BeforeEach
+    void setUp() {
+        clock = new MutableClock();
+        election = new LeaderElection(repository, LEASE, "node-a", TTL, clock);
+    }
+    
+    @Test
+    void shouldLeadWhileLeaseIsHeld() {
+        when(repository.tryAcquire(LEASE, "node-a", TTL)).thenReturn(Optional.of(3L));
+        
+        assertThat(election.isLeader()).isFalse();
+        election.heartbeat();
+        
+        assertThat(election.isLeader()).isTrue();
+        assertThat(election.fencingToken()).contains(3L);
+    }
+    
+    @Test
+    void shouldNotLeadWhenAnotherInstanceHoldsLease() {
+        when(repository.tryAcquire(anyString(), anyString(), any())).thenReturn(Optional.of(1L), Optional.empty());
+        
+        election.heartbeat();
+        election.heartbeat();
+        
+        assertThat(election.isLeader()).isFalse();
+        assertThat(election.fencingToken()).isEmpty();
+    }
+    
+    @Test
+    void shouldStopLeadingWhenLeaseRunsOutWithoutRenewal() {
+        when(repository.tryAcquire(anyString(), anyString(), any()))
+            .thenReturn(Optional.of(1L))
+            .thenThrow(new DataAccessResourceFailureException("database unreachable"));
+        
+        election.heartbeat();
+        clock.advance(Duration.ofSeconds(10));
+        election.heartbeat();
+        assertThat(election.isLeader()).isTrue();
+        
+        // Another instance may take over once the lease expires, so stop first
+        clock.advance(Duration.ofSeconds(20));
+        assertThat(election.isLeader()).isFalse();
+    }
+    
+    @Test
+    void shouldReleaseLeaseOnResign() {
+        when(repository.tryAcquire(anyString(), anyString(), any())).thenReturn(Optional.of(7L));
+        election.heartbeat();
+        
+        election.resign();
+        
+        assertThat(election.isLeader()).isFalse();
+        verify(repository).release(LEASE, "node-a", 7L);
+    }
+    
+    @Test
+    void shouldGenerateInstanceIdWhenNoneConfigured() {
+        LeaderElection unnamed = new LeaderElection(repository, LEASE, "", TTL, clock);
+        
+        assertThat(unnamed.getInstanceId()).isNotBlank();
+        assertThat(unnamed.getInstanceId())
+            .isNotEqualTo(new LeaderElection(repository, LEASE, "", TTL, clock).getInstanceId());
+    }
+    
+    private static class MutableClock extends Clock {
+        private Instant now = Instant.parse("2026-01-01T00:00:00Z");
+        
+        void advance(Duration duration) {
+            now = now.plus(duration);
+        }
+        
+        @Override
+        public ZoneOffset getZone() {
+            return ZoneOffset.UTC;
+        }
+        
+        @Override
+        public Clock withZone(java.time.ZoneId zone) {
+            return this;
+        }
+        
+        @Override
+        public Instant instant() {
+            return now;
+        }
+    }
+}
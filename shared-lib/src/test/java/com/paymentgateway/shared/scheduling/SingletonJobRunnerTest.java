@@ -0,0 +1,89 @@
+package com.paymentgateway.shared.scheduling;
+
+import org.junit.jupiter.api.BeforeEach;
+import org.junit.jupiter.api.Test;
+import org.junit.jupiter.api.extension.ExtendWith;
+import org.mockito.Mock;
+import org.mockito.junit.jupiter.MockitoExtension;
+
+import java.time.Instant;
+import java.util.Optional;
+import java.util.concurrent.atomic.AtomicInteger;
+
+import static org.assertj.core.api.Assertions.assertThat;
+import static org.mockito.Mockito.*;
+
+@ExtendWith(MockitoExtension.class)
+class SingletonJobRunnerTest {
+    
+    private static final Instant SLOT = Instant.parse("2026-01-01T22:15:00Z");
+    
+    @Mock
+    private LeaderElection election;
+    
+    @Mock
+    private JobLeaseRepository repository;
+    
+    private SingletonJobRunner runner;
+    private AtomicInteger runs;
+    
+    @BeforeEach
+    void setUp() {
+        runner = new SingletonJobRunner(election, repository);
+        runs = new AtomicInteger();
+    }
+    
+    @Test
+    void shouldSkipJobOnFollower() {
+        when(election.fencingToken()).thenReturn(Optional.empty());
+        
+        assertThat(runner.run("job", SLOT, runs::incrementAndGet))
+            .isEqualTo(SingletonJobRunner.Outcome.NOT_LEADER);
+        
+        assertThat(runs).hasValue(0);
+        verifyNoInteractions(repository);
+    }
+    
+    @Test
+    void shouldRunClaimedJobOnceAndRecordSuccess() {
+        when(election.fencingToken()).thenReturn(Optional.of(2L));
+        when(election.getInstanceId()).thenReturn("node-a");
+        when(repository.claimRun("job", SLOT, "node-a", 2L)).thenReturn(true, false);
+        when(repository.finishRun("job", SLOT, 2L, true)).thenReturn(true);
+        
+        assertThat(runner.run("job", SLOT, runs::incrementAndGet))
+            .isEqualTo(SingletonJobRunner.Outcome.SUCCEEDED);
+        assertThat(runner.run("job", SLOT, runs::incrementAndGet))
+            .isEqualTo(SingletonJobRunner.Outcome.ALREADY_CLAIMED);
+        
+        assertThat(runs).hasValue(1);
+        verify(repository).finishRun("job", SLOT, 2L, true);
+    }
+    
+    @Test
+    void shouldRecordFailedRun() {
+        when(election.fencingToken()).thenReturn(Optional.of(2L));
+        when(election.getInstanceId()).thenReturn("node-a");
+        when(repository.claimRun("job", SLOT, "node-a", 2L)).thenReturn(true);
+        
+        SingletonJobRunner.Outcome outcome = runner.run("job", SLOT, () -> {
+            throw new IllegalStateException("settlement failed");
+        });
+        
+        assertThat(outcome).isEqualTo(SingletonJobRunner.Outcome.FAILED);
+        verify(repository).finishRun("job", SLOT, 2L, false);
+    }
+    
+    @Test
+    void shouldRunPollingJobOnLeaderOnlyWithoutClaim() {
+        when(election.fencingToken()).thenReturn(Optional.empty(), Optional.of(2L));
+        
+        assertThat(runner.runOnLeader("poll", runs::incrementAndGet))
+            .isEqualTo(SingletonJobRunner.Outcome.NOT_LEADER);
+        assertThat(runner.runOnLeader("poll", runs::incrementAndGet))
+            .isEqualTo(SingletonJobRunner.Outcome.SUCCEEDED);
+        
+        assertThat(runs).hasValue(1);
+        verifyNoInteractions(repository);
+    }
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/paymentgateway/go-common/grpcopts"
+	"github.com/paymentgateway/tokenization-service/internal/hsm"
+	"github.com/paymentgateway/tokenization-service/internal/loadgen"
+	"github.com/paymentgateway/tokenization-service/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	var (
+		profileSpec      = flag.String("profile", "constant:100:30s", "load profile: constant:<rps>:<dur>, ramp:<from>-<to>:<rampup>:<hold> or step:<start>+<step>x<n>:<steplen>")
+		targetList       = flag.String("targets", "tokenize,detokenize,validate", "comma-separated targets: tokenize, detokenize, validate, authorize")
+		tokenizationAddr = flag.String("tokenization-addr", "localhost:8445", "tokenization service gRPC address")
+		readAddr         = flag.String("read-addr", "", "read replica gRPC address for validate traffic (the tokenization service when empty)")
+		authURL          = flag.String("auth-url", "http://localhost:8446", "authorization service base URL")
+		apiKey           = flag.String("api-key", "pk_test_loadtest123456789012", "API key for the authorization service")
+		hsmAddr          = flag.String("hsm-addr", "", "HSM address to probe for saturation (disabled when empty)")
+		hsmKeyID         = flag.String("hsm-key", "tokenization-key-1", "HSM key used by the saturation probe")
+		maxInFlight      = flag.Int("max-inflight", 1000, "maximum concurrent requests before new ones are dropped")
+		sloP50           = flag.Duration("slo-p50", 0, "p50 latency objective (0 disables)")
+		sloP95           = flag.Duration("slo-p95", loadgen.DefaultSLO.P95, "p95 latency objective (0 disables)")
+		sloP99           = flag.Duration("slo-p99", 0, "p99 latency objective (0 disables)")
+		sloErrorRate     = flag.Float64("slo-error-rate", loadgen.DefaultSLO.MaxErrorRate, "maximum error rate, 0..1 (0 disables)")
+	)
+	flag.Parse()
+
+	profile, err := loadgen.ParseProfile(*profileSpec)
+	if err != nil {
+		log.Fatalf("Invalid profile: %v", err)
+	}
+
+	runner := &loadgen.Runner{
+		Profile:     profile,
+		MaxInFlight: *maxInFlight,
+	}
+
+	var (
+		tokenClient server.TokenizationServiceClient
+		pool        = &tokenPool{}
+	)
+	tokenization := func() server.TokenizationServiceClient {
+		if tokenClient == nil {
+			conn, err := grpc.Dial(*tokenizationAddr, append(grpcopts.Default().DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+			if err != nil {
+				log.Fatalf("Failed to connect to tokenization service: %v", err)
+			}
+			tokenClient = server.NewTokenizationServiceClient(conn)
+		}
+		return tokenClient
+	}
+
+	// Validation is read traffic and may be routed to a read replica
+	var readClient server.TokenizationServiceClient
+	reads := func() server.TokenizationServiceClient {
+		if *readAddr == "" {
+			return tokenization()
+		}
+		if readClient == nil {
+			conn, err := grpc.Dial(*readAddr, append(grpcopts.Default().DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+			if err != nil {
+				log.Fatalf("Failed to connect to read replica: %v", err)
+			}
+			readClient = server.NewTokenizationServiceClient(conn)
+		}
+		return readClient
+	}
+
+	for _, name := range strings.Split(*targetList, ",") {
+		switch strings.TrimSpace(name) {
+		case "tokenize":
+			runner.Targets = append(runner.Targets, &tokenizeTarget{client: tokenization(), pool: pool})
+		case "detokenize":
+			seed := &tokenizeTarget{client: tokenization(), pool: pool}
+			runner.Targets = append(runner.Targets, &detokenizeTarget{client: tokenization(), pool: pool, seed: seed})
+		case "validate":
+			seed := &tokenizeTarget{client: tokenization(), pool: pool}
+			runner.Targets = append(runner.Targets, &validateTarget{client: reads(), pool: pool, seed: seed})
+		case "authorize":
+			runner.Targets = append(runner.Targets, &authorizeTarget{
+				baseURL: strings.TrimRight(*authURL, "/"),
+				apiKey:  *apiKey,
+				client:  &http.Client{Timeout: 30 * time.Second},
+				runID:   newRunID(),
+			})
+		default:
+			log.Fatalf("Unknown target %q", name)
+		}
+	}
+
+	if *hsmAddr != "" {
+		hsmClient, err := hsm.NewClient(*hsmAddr)
+		if err != nil {
+			log.Fatalf("Failed to connect to HSM: %v", err)
+		}
+		defer hsmClient.Close()
+		runner.Probe = &hsmProbe{client: hsmClient, keyID: *hsmKeyID}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Running %s against %s", profile, *targetList)
+	result, err := runner.Run(ctx)
+	if err != nil {
+		log.Fatalf("Load run failed: %v", err)
+	}
+
+	slo := loadgen.SLO{P50: *sloP50, P95: *sloP95, P99: *sloP99, MaxErrorRate: *sloErrorRate}
+	violations := slo.Evaluate(result.Ops)
+	if err := loadgen.WriteReport(os.Stdout, result, violations); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/paymentgateway/tokenization-service/internal/hsm"
+	"github.com/paymentgateway/tokenization-service/internal/loadgen"
+	"github.com/paymentgateway/tokenization-service/internal/server"
+)
+
+// tokenPool remembers tokens issued during the run so detokenize and
+// validate targets exercise real vault entries
+type tokenPool struct {
+	tokens []string
+	mu     sync.RWMutex
+}
+
+func (p *tokenPool) add(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens = append(p.tokens, token)
+}
+
+func (p *tokenPool) pick(seq int64) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.tokens) == 0 {
+		return "", false
+	}
+	return p.tokens[int(seq%int64(len(p.tokens)))], true
+}
+
+type tokenizeTarget struct {
+	client server.TokenizationServiceClient
+	pool   *tokenPool
+}
+
+func (t *tokenizeTarget) Name() string { return "tokenize" }
+
+func (t *tokenizeTarget) Do(ctx context.Context, seq int64) error {
+	month, year := loadgen.TestExpiry()
+	resp, err := t.client.TokenizeCard(ctx, &server.TokenizeRequest{
+		Pan:         loadgen.TestPAN(seq),
+		ExpiryMonth: int32(month),
+		ExpiryYear:  int32(year),
+		Cvv:         "123",
+	})
+	if err != nil {
+		return err
+	}
+	t.pool.add(resp.Token)
+	return nil
+}
+
+type detokenizeTarget struct {
+	client server.TokenizationServiceClient
+	pool   *tokenPool
+	seed   *tokenizeTarget
+}
+
+func (t *detokenizeTarget) Name() string { return "detokenize" }
+
+func (t *detokenizeTarget) Do(ctx context.Context, seq int64) error {
+	token, ok := t.pool.pick(seq)
+	if !ok {
+		return t.seed.Do(ctx, seq)
+	}
+	_, err := t.client.DetokenizeCard(ctx, &server.DetokenizeRequest{Token: token})
+	return err
+}
+
+type validateTarget struct {
+	client server.TokenizationServiceClient
+	pool   *tokenPool
+	seed   *tokenizeTarget
+}
+
+func (t *validateTarget) Name() string { return "validate" }
+
+func (t *validateTarget) Do(ctx context.Context, seq int64) error {
+	token, ok := t.pool.pick(seq)
+	if !ok {
+		return t.seed.Do(ctx, seq)
+	}
+	resp, err := t.client.ValidateToken(ctx, &server.ValidateRequest{Token: token})
+	if err != nil {
+		return err
+	}
+	if !resp.Valid {
+		return fmt.Errorf("token rejected: %s", resp.ErrorMessage)
+	}
+	return nil
+}
+
+// authorizeTarget drives the authorization service's REST payment API
+type authorizeTarget struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	// runID keeps idempotency keys unique across runs, so a second run
+	// creates payments rather than replaying the first run's responses
+	runID string
+}
+
+// newRunID returns a random ID for one load run
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (t *authorizeTarget) Name() string { return "authorize" }
+
+type statusError int
+
+func (e statusError) Error() string { return fmt.Sprintf("HTTP %d", int(e)) }
+func (e statusError) Kind() string  { return e.Error() }
+
+func (t *authorizeTarget) Do(ctx context.Context, seq int64) error {
+	month, year := loadgen.TestExpiry()
+	body, err := json.Marshal(map[string]interface{}{
+		"cardNumber":  loadgen.TestPAN(seq),
+		"expiryMonth": month,
+		"expiryYear":  year,
+		"cvv":         "123",
+		"amount":      "10.00",
+		"currency":    "USD",
+		"referenceId": fmt.Sprintf("loadgen-%s-%d", t.runID, seq),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/api/v1/payments", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("loadgen-%s-%d", t.runID, seq))
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return statusError(resp.StatusCode)
+	}
+	return nil
+}
+
+// hsmProbe issues a small encrypt directly against the HSM to measure how
+// much its latency degrades while the services above it are under load
+type hsmProbe struct {
+	client *hsm.Client
+	keyID  string
+}
+
+func (p *hsmProbe) Name() string { return loadgen.ProbeOp }
+
+func (p *hsmProbe) Do(ctx context.Context, seq int64) error {
+	_, _, _, err := p.client.EncryptContext(ctx, p.keyID, []byte("loadgen-probe"), nil)
+	return err
+}
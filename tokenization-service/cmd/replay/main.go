@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/paymentgateway/go-common/grpcopts"
+	"github.com/paymentgateway/tokenization-service/internal/recorder"
+	_ "github.com/paymentgateway/tokenization-service/internal/server" // registers message types
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	var (
+		logPath = flag.String("log", "", "recorded session (JSON lines) to replay")
+		addr    = flag.String("addr", "localhost:8445", "gRPC address of the build under test")
+		ignore  = flag.String("ignore", strings.Join(recorder.DefaultIgnoreFields, ","), "comma-separated response fields to ignore")
+		tokens  = flag.String("token-fields", strings.Join(recorder.DefaultTokenFields, ","), "comma-separated response fields holding tokens")
+	)
+	flag.Parse()
+
+	if *logPath == "" {
+		log.Fatal("-log is required")
+	}
+	f, err := os.Open(*logPath)
+	if err != nil {
+		log.Fatalf("Failed to open log: %v", err)
+	}
+	entries, err := recorder.ReadLog(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed to read log: %v", err)
+	}
+
+	conn, err := grpc.Dial(*addr, append(grpcopts.Default().DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		log.Fatalf("Failed to connect to %s: %v", *addr, err)
+	}
+	defer conn.Close()
+
+	replayer := &recorder.Replayer{
+		Invoker:      &recorder.GRPCInvoker{Conn: conn},
+		IgnoreFields: splitList(*ignore),
+		TokenFields:  splitList(*tokens),
+	}
+	report, err := replayer.Replay(context.Background(), entries)
+	if err != nil {
+		log.Fatalf("Replay aborted: %v", err)
+	}
+
+	for _, d := range report.Diffs {
+		fmt.Println(d)
+	}
+	fmt.Printf("%d/%d responses matched\n", report.Matched, report.Total)
+	if report.Matched != report.Total {
+		conn.Close()
+		os.Exit(1)
+	}
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// Capacity alerts are queued for the webhook up to this many, and each post
+// is given this long
+const (
+	capacityAlertQueue   = 64
+	capacityAlertTimeout = 5 * time.Second
+)
+
+// vaultCapacityConfig reads the vault limits from the environment, and
+// reports false when neither limit is set
+func vaultCapacityConfig(registry *metrics.Registry) (tokenization.CapacityConfig, bool) {
+	cfg := tokenization.CapacityConfig{Metrics: registry}
+	if v := os.Getenv("TOKENIZATION_VAULT_MAX_TOKENS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid TOKENIZATION_VAULT_MAX_TOKENS: %q", v)
+		}
+		cfg.MaxTokens = n
+	}
+	if v := os.Getenv("TOKENIZATION_VAULT_MAX_MB"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid TOKENIZATION_VAULT_MAX_MB: %q", v)
+		}
+		cfg.MaxBytes = n << 20
+	}
+	if cfg.MaxTokens == 0 && cfg.MaxBytes == 0 {
+		return cfg, false
+	}
+	policy, err := tokenization.ParseCapacityPolicy(os.Getenv("TOKENIZATION_VAULT_FULL_POLICY"))
+	if err != nil {
+		log.Fatalf("Invalid TOKENIZATION_VAULT_FULL_POLICY: %v", err)
+	}
+	cfg.Policy = policy
+	if v := os.Getenv("TOKENIZATION_VAULT_ALERT_PERCENT"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p <= 0 || p > 100 {
+			log.Fatalf("Invalid TOKENIZATION_VAULT_ALERT_PERCENT: %q", v)
+		}
+		cfg.AlertThreshold = p / 100
+	}
+	cfg.OnAlert = capacityAlerts(os.Getenv("TOKENIZATION_VAULT_ALERT_WEBHOOK"))
+	return cfg, true
+}
+
+// capacityAlert is the JSON body posted to the alert webhook
+type capacityAlert struct {
+	Service   string    `json:"service"`
+	State     string    `json:"state"`
+	Previous  string    `json:"previous"`
+	Tokens    int       `json:"tokens"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+	Bytes     int64     `json:"estimated_bytes"`
+	MaxBytes  int64     `json:"max_bytes,omitempty"`
+	Usage     float64   `json:"usage"`
+	At        time.Time `json:"at"`
+}
+
+// capacityAlerts logs vault capacity alerts and, when webhook is set, posts
+// them to it in order from a goroutine of its own, since the vault raises
+// them with its lock held. Alerts beyond a full queue are only logged.
+func capacityAlerts(webhook string) func(tokenization.CapacityAlert) {
+	var queue chan tokenization.CapacityAlert
+	if webhook != "" {
+		queue = make(chan tokenization.CapacityAlert, capacityAlertQueue)
+		go func() {
+			client := &http.Client{Timeout: capacityAlertTimeout}
+			for a := range queue {
+				if err := postCapacityAlert(client, webhook, a); err != nil {
+					log.Printf("Vault capacity alert webhook failed: %v", err)
+				}
+			}
+		}()
+	}
+	return func(a tokenization.CapacityAlert) {
+		log.Printf("Vault capacity %s (was %s): %d tokens, about %d bytes, %.0f%% used",
+			a.State, a.Previous, a.Tokens, a.Bytes, a.Usage*100)
+		if queue == nil {
+			return
+		}
+		select {
+		case queue <- a:
+		default:
+			log.Printf("Vault capacity alert webhook is behind; dropped the %s alert", a.State)
+		}
+	}
+}
+
+func postCapacityAlert(client *http.Client, webhook string, a tokenization.CapacityAlert) error {
+	body, err := json.Marshal(capacityAlert{
+		Service:   "tokenization-service",
+		State:     string(a.State),
+		Previous:  string(a.Previous),
+		Tokens:    a.Tokens,
+		MaxTokens: a.MaxTokens,
+		Bytes:     a.Bytes,
+		MaxBytes:  a.MaxBytes,
+		Usage:     a.Usage,
+		At:        a.At,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
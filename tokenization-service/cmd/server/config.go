@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/reload"
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/retention"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// fileConfig is the reloadable configuration read from
+// TOKENIZATION_CONFIG_FILE. Absent fields leave the setting as it is.
+type fileConfig struct {
+	// APIKeys replaces the API keys, in the TOKENIZATION_API_KEYS format
+	APIKeys *string `json:"api_keys"`
+	// LegalHold replaces TOKENIZATION_LEGAL_HOLD: "*" or merchant IDs
+	LegalHold          *string `json:"legal_hold"`
+	TokenRetentionDays *int    `json:"token_retention_days"`
+	RestoreWindowDays  *int    `json:"restore_window_days"`
+	AuditRetentionDays *int    `json:"audit_retention_days"`
+	// VaultMigrationMode moves a vault migration on: "old", "dual" or "new"
+	VaultMigrationMode *string `json:"vault_migration_mode"`
+}
+
+// configReloader applies fileConfig to the running service
+type configReloader struct {
+	purger  *retention.Purger
+	auditor *audit.Auditor
+	keys    *interceptors.KeySet
+	// migration is set while the vault moves between write-ahead logs
+	migration *tokenization.MigratingStore
+	// started is set once the interceptor chain is built; after that a
+	// reload can change the keys but not turn authentication on or off
+	started bool
+}
+
+func (r *configReloader) apply(data []byte) ([]string, error) {
+	var cfg fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Validate everything before changing anything
+	var keys interceptors.StaticKeys
+	if cfg.APIKeys != nil {
+		var err error
+		if keys, err = interceptors.ParseStaticKeys(*cfg.APIKeys); err != nil {
+			return nil, fmt.Errorf("invalid api_keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil, errors.New("api_keys is empty; authentication cannot be turned off by a reload")
+		}
+		if r.keys == nil && r.started {
+			return nil, errors.New("api_keys needs authentication enabled at startup")
+		}
+	}
+	for name, days := range map[string]*int{"token_retention_days": cfg.TokenRetentionDays, "restore_window_days": cfg.RestoreWindowDays, "audit_retention_days": cfg.AuditRetentionDays} {
+		if days != nil && *days < 0 {
+			return nil, fmt.Errorf("invalid %s: %d", name, *days)
+		}
+	}
+	var mode tokenization.MigrationMode
+	if cfg.VaultMigrationMode != nil {
+		if r.migration == nil {
+			return nil, errors.New("vault_migration_mode needs TOKENIZATION_WAL_MIGRATE_FROM")
+		}
+		var err error
+		if mode, err = tokenization.ParseMigrationMode(*cfg.VaultMigrationMode); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []string
+	// The last check, as the switch itself refuses to lose records
+	if mode != "" {
+		old := r.migration.Mode()
+		if err := r.migration.SetMode(mode); err != nil {
+			return nil, err
+		}
+		if mode != old {
+			changes = append(changes, fmt.Sprintf("vault migration %s -> %s", old, mode))
+		}
+	}
+	old := r.purger.Policy()
+	policy := old
+	if cfg.LegalHold != nil {
+		policy.LegalHold = audit.ParseLegalHold(*cfg.LegalHold)
+		if describeHold(policy.LegalHold) != describeHold(old.LegalHold) {
+			changes = append(changes, fmt.Sprintf("legal hold %s -> %s", describeHold(old.LegalHold), describeHold(policy.LegalHold)))
+		}
+	}
+	if cfg.TokenRetentionDays != nil {
+		policy.TokenRetention = time.Duration(*cfg.TokenRetentionDays) * 24 * time.Hour
+		if policy.TokenRetention != old.TokenRetention {
+			changes = append(changes, fmt.Sprintf("token retention %s -> %s", old.TokenRetention, policy.TokenRetention))
+		}
+	}
+	if cfg.RestoreWindowDays != nil {
+		policy.RestoreWindow = time.Duration(*cfg.RestoreWindowDays) * 24 * time.Hour
+		if policy.RestoreWindow != old.RestoreWindow {
+			changes = append(changes, fmt.Sprintf("restore window %s -> %s", old.RestoreWindow, policy.RestoreWindow))
+		}
+	}
+	if cfg.AuditRetentionDays != nil {
+		policy.AuditRetention = time.Duration(*cfg.AuditRetentionDays) * 24 * time.Hour
+		if policy.AuditRetention != old.AuditRetention {
+			changes = append(changes, fmt.Sprintf("audit retention %s -> %s", old.AuditRetention, policy.AuditRetention))
+		}
+	}
+	r.purger.SetPolicy(policy)
+
+	if keys != nil {
+		if r.keys == nil {
+			r.keys = interceptors.NewKeySet(keys)
+			changes = append(changes, "authentication enabled")
+		} else {
+			changes = append(changes, r.keys.Replace(keys)...)
+		}
+	}
+	return changes, nil
+}
+
+// audit logs a reload and records it in the audit trail
+func (r *configReloader) audit(e reload.Event) {
+	log.Printf("Config %s", e)
+	requester := string(e.Trigger)
+	if e.Owner != "" {
+		requester += " " + e.Owner
+	}
+	detail := strings.Join(e.Changes, "; ")
+	if e.Digest != "" {
+		detail = strings.TrimPrefix(detail+"; sha256:"+e.Digest, "; ")
+	}
+	if err := r.auditor.RecordConfigReload(requester, detail, e.Err); err != nil {
+		log.Printf("Failed to audit config reload: %v", err)
+	}
+}
+
+// describeHold renders a legal hold as it is configured
+func describeHold(h audit.LegalHold) string {
+	if h.All {
+		return "*"
+	}
+	if len(h.Merchants) == 0 {
+		return "none"
+	}
+	merchants := make([]string, 0, len(h.Merchants))
+	for m := range h.Merchants {
+		merchants = append(merchants, m)
+	}
+	sort.Strings(merchants)
+	return strings.Join(merchants, ",")
+}
@@ -1,29 +1,116 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/paymentgateway/go-common/breaker"
+	"github.com/paymentgateway/go-common/diagnostics"
+	"github.com/paymentgateway/go-common/grpcopts"
+	"github.com/paymentgateway/go-common/hedge"
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/metrics"
+	"github.com/paymentgateway/go-common/reload"
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/cluster"
 	"github.com/paymentgateway/tokenization-service/internal/hsm"
+	"github.com/paymentgateway/tokenization-service/internal/recorder"
+	"github.com/paymentgateway/tokenization-service/internal/replica"
+	"github.com/paymentgateway/tokenization-service/internal/retention"
+	"github.com/paymentgateway/tokenization-service/internal/rotation"
 	"github.com/paymentgateway/tokenization-service/internal/server"
+	"github.com/paymentgateway/tokenization-service/internal/serverv2"
 	"github.com/paymentgateway/tokenization-service/internal/tokenization"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
-	port          = ":8445"
-	hsmAddress    = "localhost:8444"
-	keyID         = "tokenization-key-1"
-	tokenTTL      = 24 * time.Hour * 365 // 1 year
+	defaultPort        = "8445"
+	defaultMetricsPort = "9445"
+	defaultHSMAddress  = "localhost:8444"
+	keyID              = "tokenization-key-1"
+	tokenTTL           = 24 * time.Hour * 365 // 1 year
+	purgeInterval      = time.Hour
+	
+	// Expired data keys are zeroized by a sweep at this interval
+	cacheSweepInterval = 30 * time.Second
+	
+	// Write-ahead log defaults: buffered records are synced every second,
+	// so a crash loses at most a second of mutations, and the log is
+	// compacted once it has grown by this many records
+	defaultWALFlushInterval  = time.Second
+	defaultWALCompactRecords = 100000
+	
+	// While the vault migrates to a new log, the two logs are compared,
+	// and the new one backfilled, at this interval
+	defaultVaultVerifyInterval = 5 * time.Minute
+	
+	// The config file, when set, is checked for changes at this interval
+	defaultReloadInterval = 5 * time.Second
+	
+	// Retention defaults: revoked and expired tokens are kept 30 days,
+	// deleted tokens can be restored for 30 days, audit records are kept one
+	// year as PCI DSS requires
+	defaultTokenRetentionDays = 30
+	defaultRestoreWindowDays  = 30
+	defaultAuditRetentionDays = 365
 )
 
 func main() {
 	log.Println("Starting Tokenization Service...")
+	port := os.Getenv("TOKENIZATION_PORT")
+	if port == "" {
+		port = defaultPort
+	}
+	metricsPort := os.Getenv("TOKENIZATION_METRICS_PORT")
+	if metricsPort == "" {
+		metricsPort = defaultMetricsPort
+	}
+	hsmAddress := os.Getenv("TOKENIZATION_HSM_ADDR")
+	if hsmAddress == "" {
+		hsmAddress = defaultHSMAddress
+	}
+	
+	// Message limits, compression and keepalive of the gRPC server and of
+	// this service's connections to the HSM, its primary and its peers
+	transport, err := grpcopts.FromEnv("TOKENIZATION_", os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	
+	// TLS is opt-in: the server's certificate, which also identifies it to
+	// its primary and cluster peers, and the client certificate presented
+	// to the HSM are reloaded when rotated
+	serverTLS := loadTLS("TOKENIZATION_", true)
+	peerCreds := insecure.NewCredentials()
+	if serverTLS != nil {
+		peerCreds = serverTLS.ClientCredentials()
+	}
+	var hsmCreds credentials.TransportCredentials
+	if hsmTLS := loadTLS("TOKENIZATION_HSM_", false); hsmTLS != nil {
+		hsmCreds = hsmTLS.ClientCredentials()
+	}
 	
 	// Connect to HSM
-	log.Printf("Connecting to HSM at %s...", hsmAddress)
-	hsmClient, err := hsm.NewClient(hsmAddress)
+	// Standby HSMs of an HA pair take over when the primary is unreachable
+	var hsmStandbys []string
+	for _, addr := range strings.Split(os.Getenv("TOKENIZATION_HSM_STANDBYS"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			hsmStandbys = append(hsmStandbys, addr)
+		}
+	}
+	log.Printf("Connecting to HSM at %s (standbys %v)...", hsmAddress, hsmStandbys)
+	hsmClient, err := hsm.NewClientWithTransport(hsmAddress, transport, hsmCreds, hsmStandbys...)
 	if err != nil {
 		log.Fatalf("Failed to connect to HSM: %v", err)
 	}
@@ -35,21 +122,563 @@ func main() {
 		log.Printf("Key may already exist: %v", err)
 	}
 	
-	// Create tokenization service
-	tokenService := tokenization.NewService(hsmClient, keyID, tokenTTL)
+	registry := metrics.NewRegistry()
+	diagnostics.RegisterRuntimeMetrics(registry)
+	
+	// Profiles and goroutine dumps on an internal port, for load tests
+	serveDiagnostics("TOKENIZATION_", "tokenization")
+	
+	// Stop calling an HSM pair that keeps failing, so retries don't pile
+	// onto it while it recovers
+	hsmBreaker := breaker.New("hsm", hsmBreakerConfig(registry))
+	hsmClient.SetBreaker(hsmBreaker)
+	
+	// A node serving settlement batches can yield to authorization traffic
+	// at the HSM by tagging its calls
+	if err := hsmClient.SetPriority(os.Getenv("TOKENIZATION_HSM_PRIORITY")); err != nil {
+		log.Fatalf("Invalid TOKENIZATION_HSM_PRIORITY: %v", err)
+	}
+	
+	// Hedged requests send decryptions the active HSM is slow to answer to
+	// its standby too, trimming the tail latency of authorizations
+	if v := os.Getenv("TOKENIZATION_HSM_HEDGE_PERCENTILE"); v != "" {
+		if len(hsmStandbys) == 0 {
+			log.Fatal("TOKENIZATION_HSM_HEDGE_PERCENTILE requires TOKENIZATION_HSM_STANDBYS")
+		}
+		hsmClient.SetHedger(hedge.New("hsm", hsmHedgeConfig(v, registry)))
+	}
+	
+	// Create tokenization service. A data-key scope encrypts PANs under
+	// HSM-wrapped keys that can be destroyed to crypto-shred them.
+	keyScope, err := tokenization.ParseKeyScope(os.Getenv("TOKENIZATION_KEY_SCOPE"))
+	if err != nil {
+		log.Fatalf("Invalid TOKENIZATION_KEY_SCOPE: %v", err)
+	}
+	opts := []tokenization.Option{tokenization.WithKeyScope(keyScope)}
+	
+	// Envelope mode encrypts PANs locally under shared data keys from the
+	// HSM, trading per-token HSM calls for one per batch
+	if os.Getenv("TOKENIZATION_ENVELOPE") == "true" {
+		if keyScope != tokenization.KeyScopeNone {
+			log.Fatalf("TOKENIZATION_ENVELOPE cannot be combined with TOKENIZATION_KEY_SCOPE=%s", keyScope)
+		}
+		cfg := tokenization.EnvelopeConfig{}
+		if v := os.Getenv("TOKENIZATION_ENVELOPE_MAX_USES"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid TOKENIZATION_ENVELOPE_MAX_USES: %q", v)
+			}
+			cfg.MaxUses = n
+		}
+		opts = append(opts, tokenization.WithEnvelope(cfg))
+	}
+	
+	// Ciphertext envelopes name their key, version and nonce, so PANs the
+	// HSM encrypts directly survive rotations and migrations on their own
+	if os.Getenv("TOKENIZATION_CIPHERTEXT_ENVELOPE") == "true" {
+		opts = append(opts, tokenization.WithCiphertextEnvelopes())
+	}
+	
+	// With a BDK the service joins the P2PE decryption zone and tokenizes
+	// card reads that terminals encrypted under DUKPT
+	if bdkID := os.Getenv("TOKENIZATION_P2PE_BDK"); bdkID != "" {
+		opts = append(opts, tokenization.WithP2PE(bdkID))
+	}
+	
+	// With a cryptogram key the service issues and validates the
+	// cryptograms presented with network tokens
+	if keyID := os.Getenv("TOKENIZATION_CRYPTOGRAM_KEY"); keyID != "" {
+		opts = append(opts, tokenization.WithCryptograms(keyID))
+	}
+	
+	// A PAR secret keeps payment account references stable across
+	// restarts and nodes
+	if secret := os.Getenv("TOKENIZATION_PAR_SECRET"); secret != "" {
+		opts = append(opts, tokenization.WithPARSecret([]byte(secret)))
+	}
+	
+	// Cache unwrapped data keys unless every unwrap must reach the HSM
+	cacheDataKeys := os.Getenv("TOKENIZATION_DATAKEY_CACHE") != "off"
+	if cacheDataKeys {
+		cfg := tokenization.DataKeyCacheConfig{Metrics: registry}
+		if v := os.Getenv("TOKENIZATION_DATAKEY_CACHE_TTL"); v != "" {
+			ttl, err := time.ParseDuration(v)
+			if err != nil || ttl <= 0 {
+				log.Fatalf("Invalid TOKENIZATION_DATAKEY_CACHE_TTL: %q", v)
+			}
+			cfg.TTL = ttl
+		}
+		opts = append(opts, tokenization.WithDataKeyCache(cfg))
+	}
+	
+	// The vault lives in memory; a write-ahead log, when configured, lets
+	// it survive restarts and crashes
+	walPath := os.Getenv("TOKENIZATION_WAL_FILE")
+	walFlushInterval := defaultWALFlushInterval
+	if v := os.Getenv("TOKENIZATION_WAL_FLUSH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid TOKENIZATION_WAL_FLUSH_INTERVAL: %q", v)
+		}
+		walFlushInterval = d
+	}
+	walCompactRecords := defaultWALCompactRecords
+	if v := os.Getenv("TOKENIZATION_WAL_COMPACT_RECORDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid TOKENIZATION_WAL_COMPACT_RECORDS: %q", v)
+		}
+		walCompactRecords = n
+	}
+	
+	// The vault moves to the log at TOKENIZATION_WAL_FILE from the one at
+	// TOKENIZATION_WAL_MIGRATE_FROM by writing both until they match
+	migrateFrom := os.Getenv("TOKENIZATION_WAL_MIGRATE_FROM")
+	if migrateFrom != "" && walPath == "" {
+		log.Fatalf("TOKENIZATION_WAL_MIGRATE_FROM needs TOKENIZATION_WAL_FILE, the log to migrate to")
+	}
+	migrationMode, err := tokenization.ParseMigrationMode(os.Getenv("TOKENIZATION_VAULT_MIGRATION_MODE"))
+	if err != nil {
+		log.Fatalf("Invalid TOKENIZATION_VAULT_MIGRATION_MODE: %v", err)
+	}
+	verifyInterval := defaultVaultVerifyInterval
+	if v := os.Getenv("TOKENIZATION_VAULT_VERIFY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid TOKENIZATION_VAULT_VERIFY_INTERVAL: %q", v)
+		}
+		verifyInterval = d
+	}
+	
+	// A read replica follows a primary's change feed and serves reads only;
+	// the primary publishes the feed when TOKENIZATION_CHANGE_FEED is set
+	replicaOf := os.Getenv("TOKENIZATION_REPLICA_OF")
+	if replicaOf != "" && walPath != "" {
+		log.Fatalf("TOKENIZATION_REPLICA_OF cannot be combined with TOKENIZATION_WAL_FILE: a replica resyncs from its primary")
+	}
+	if os.Getenv("TOKENIZATION_CHANGE_FEED") == "true" {
+		if replicaOf != "" {
+			log.Fatalf("TOKENIZATION_CHANGE_FEED cannot be combined with TOKENIZATION_REPLICA_OF")
+		}
+		buffer := 0
+		if v := os.Getenv("TOKENIZATION_CHANGE_FEED_BUFFER"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid TOKENIZATION_CHANGE_FEED_BUFFER: %q", v)
+			}
+			buffer = n
+		}
+		opts = append(opts, tokenization.WithChangeFeed(tokenization.NewChangeFeed(buffer)))
+	}
+	
+	// In a cluster, nodes partition the token space by consistent hashing
+	// and each issues only tokens it owns
+	var (
+		ring        *cluster.Ring
+		clusterSelf string
+	)
+	if spec := os.Getenv("TOKENIZATION_CLUSTER_NODES"); spec != "" {
+		ring, err = cluster.NewRing(cluster.ParseNodes(spec), 0)
+		if err != nil {
+			log.Fatalf("Invalid TOKENIZATION_CLUSTER_NODES: %v", err)
+		}
+		clusterSelf = os.Getenv("TOKENIZATION_CLUSTER_SELF")
+		if !ring.Has(clusterSelf) {
+			log.Fatalf("TOKENIZATION_CLUSTER_SELF %q is not one of TOKENIZATION_CLUSTER_NODES", clusterSelf)
+		}
+		if replicaOf != "" {
+			log.Fatalf("TOKENIZATION_CLUSTER_NODES cannot be combined with TOKENIZATION_REPLICA_OF")
+		}
+		opts = append(opts, tokenization.WithTokenOwnership(serverv2.OwnedBy(ring, clusterSelf)))
+	}
+	
+	// Bound the in-memory vault so long soak tests fail loudly rather
+	// than run out of memory
+	if cfg, ok := vaultCapacityConfig(registry); ok {
+		log.Printf("Vault capacity: %d tokens, %d bytes (0 is unlimited), when full: %s", cfg.MaxTokens, cfg.MaxBytes, cfg.Policy)
+		opts = append(opts, tokenization.WithCapacity(cfg))
+	}
+	
+	var (
+		wal       *tokenization.WAL
+		migration *tokenization.MigratingStore
+	)
+	if walPath != "" {
+		// A zero flush interval syncs every record before the call returns
+		walCfg := tokenization.WALConfig{Sync: walFlushInterval == 0}
+		wal, err = tokenization.OpenWAL(walPath, walCfg)
+		if err != nil {
+			log.Fatalf("Failed to open write-ahead log: %v", err)
+		}
+		if migrateFrom != "" {
+			oldWAL, err := tokenization.OpenWAL(migrateFrom, walCfg)
+			if err != nil {
+				log.Fatalf("Failed to open write-ahead log to migrate from: %v", err)
+			}
+			migration = tokenization.NewMigratingStore(oldWAL, wal, migrationMode)
+			defer migration.Close()
+			opts = append(opts, tokenization.WithStore(migration))
+			walPath = migrateFrom + " and " + walPath + " (migration mode " + string(migrationMode) + ")"
+		} else {
+			defer wal.Close()
+			opts = append(opts, tokenization.WithWAL(wal))
+		}
+	}
+	tokenService := tokenization.NewService(hsmClient, keyID, tokenTTL, opts...)
+	if wal != nil {
+		n, err := tokenService.RecoverWAL()
+		if err != nil {
+			log.Fatalf("Failed to recover vault from %s: %v", walPath, err)
+		}
+		log.Printf("Recovered vault from %s (%d records, %d tokens)", walPath, n, tokenService.Stats().Tokens)
+		interval := walFlushInterval
+		if interval == 0 {
+			interval = defaultWALFlushInterval
+		}
+		go func() {
+			for range time.Tick(interval) {
+				if err := tokenService.FlushWAL(); err != nil {
+					log.Printf("Write-ahead log flush failed: %v", err)
+				}
+				if migration == nil && wal.Appended() >= walCompactRecords {
+					if err := tokenService.CompactWAL(); err != nil {
+						log.Printf("Write-ahead log compaction failed: %v", err)
+					}
+				}
+			}
+		}()
+	}
+	if migration != nil && verifyInterval > 0 {
+		go func() {
+			for range time.Tick(verifyInterval) {
+				if migration.Mode() != tokenization.MigrationDual {
+					continue
+				}
+				report, err := migration.Verify(true)
+				if err != nil {
+					log.Printf("Vault migration check failed: %v", err)
+					continue
+				}
+				log.Printf("Vault migration check: %d old entries, %d new, %d missing (%d backfilled), %d differing, %d extra",
+					report.OldEntries, report.NewEntries, report.Missing, report.Backfilled, report.Differing, report.Extra)
+			}
+		}()
+		lastCheck := func(count func(tokenization.MigrationReport) int) func() float64 {
+			return func() float64 {
+				if report := migration.LastReport(); report != nil {
+					return float64(count(*report))
+				}
+				return 0
+			}
+		}
+		registry.GaugeFunc("tokenization_vault_migration_missing_entries",
+			"Vault entries only the old log held at the last migration check, less those backfilled.",
+			lastCheck(func(r tokenization.MigrationReport) int { return r.Missing - r.Backfilled }))
+		registry.GaugeFunc("tokenization_vault_migration_differing_entries",
+			"Vault entries in different states in the two logs at the last migration check.",
+			lastCheck(func(r tokenization.MigrationReport) int { return r.Differing }))
+		registry.GaugeFunc("tokenization_vault_migration_extra_entries",
+			"Vault entries only the new log held at the last migration check.",
+			lastCheck(func(r tokenization.MigrationReport) int { return r.Extra }))
+	}
+	var follower *replica.Follower
+	if replicaOf != "" {
+		conn, err := grpc.Dial(replicaOf, append(transport.DialOptions(), grpc.WithTransportCredentials(peerCreds))...)
+		if err != nil {
+			log.Fatalf("Failed to connect to primary: %v", err)
+		}
+		defer conn.Close()
+		follower = replica.NewFollower(tokenService, replicaSource(conn, os.Getenv("TOKENIZATION_REPLICA_API_KEY")), replica.Config{})
+		go follower.Run(context.Background())
+		log.Printf("Serving as a read-only replica of %s", replicaOf)
+	}
+	log.Printf("Data key scope: %s, envelope encryption: %v, data key cache: %v", keyScope, tokenService.Envelope(), cacheDataKeys)
+	if cacheDataKeys {
+		go func() {
+			for range time.Tick(cacheSweepInterval) {
+				tokenService.SweepDataKeyCache()
+			}
+		}()
+	}
+	
+	// Follow the HSM's key rollover advice, so a rotation moves envelope
+	// encryption to the new version at once and a destroyed version's data
+	// keys leave the cache
+	if os.Getenv("TOKENIZATION_HSM_KEY_ADVICE") != "off" {
+		go hsmClient.WatchKeys(context.Background(), []string{keyID}, func(a hsm.KeyEvent) {
+			switch a.Kind {
+			case hsm.AdviceRotated:
+				retired := tokenService.KeyRotated(a.Version)
+				log.Printf("HSM key %s rotated to version %d (envelope key retired: %v)", a.KeyID, a.Version, retired)
+			case hsm.AdviceRetiring:
+				log.Printf("HSM key %s version %d pending destruction, approval due by %s", a.KeyID, a.Version, a.Deadline.Format(time.RFC3339))
+			case hsm.AdviceRetired:
+				n := tokenService.KeyVersionRetired(a.Version)
+				log.Printf("HSM key %s version %d destroyed; dropped %d cached data keys", a.KeyID, a.Version, n)
+			}
+		})
+	}
+	
+	// Audit trail of every token operation, persisted when a file is configured
+	var auditStore audit.Store = audit.NewMemoryStore()
+	if auditPath := os.Getenv("TOKENIZATION_AUDIT_FILE"); auditPath != "" {
+		fileStore, err := audit.OpenFileStore(auditPath)
+		if err != nil {
+			log.Fatalf("Failed to open audit file: %v", err)
+		}
+		defer fileStore.Close()
+		auditStore = fileStore
+		log.Printf("Writing audit trail to %s", auditPath)
+	}
+	
+	auditor := audit.NewAuditor(auditStore)
+	
+	// Purge old tokens and audit records per the retention policy
+	policy := retention.Policy{
+		TokenRetention: retentionDays("TOKENIZATION_TOKEN_RETENTION_DAYS", defaultTokenRetentionDays),
+		RestoreWindow:  retentionDays("TOKENIZATION_RESTORE_WINDOW_DAYS", defaultRestoreWindowDays),
+		AuditRetention: retentionDays("TOKENIZATION_AUDIT_RETENTION_DAYS", defaultAuditRetentionDays),
+		LegalHold:      audit.ParseLegalHold(os.Getenv("TOKENIZATION_LEGAL_HOLD")),
+	}
+	purger := retention.NewPurger(tokenService, auditor, policy)
+	
+	// Request IDs, recovery, logging, metrics and, when keys are configured,
+	// API-key authentication
+	cfg := interceptors.Config{
+		Service:       "tokenization-service",
+		Metrics:       registry,
+		Validation:    true,
+		PublicMethods: []string{
+			"/grpc.health.v1.Health/Check",
+			"/tokenization.v1.TokenizationService/GetServiceInfo",
+			"/tokenization.v2.TokenizationService/GetServiceInfo",
+			"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+		},
+	}
+	var keySet *interceptors.KeySet
+	if spec := os.Getenv("TOKENIZATION_API_KEYS"); spec != "" {
+		keys, err := interceptors.ParseStaticKeys(spec)
+		if err != nil {
+			log.Fatalf("Invalid TOKENIZATION_API_KEYS: %v", err)
+		}
+		keySet = interceptors.NewKeySet(keys)
+	}
+	
+	// A config file overrides the API keys, legal hold, retention and vault
+	// migration mode and is reloaded on SIGHUP or when it changes
+	if path := os.Getenv("TOKENIZATION_CONFIG_FILE"); path != "" {
+		interval := defaultReloadInterval
+		if v := os.Getenv("TOKENIZATION_CONFIG_RELOAD_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				log.Fatalf("Invalid TOKENIZATION_CONFIG_RELOAD_INTERVAL: %q", v)
+			}
+			interval = d
+		}
+		reloader := &configReloader{purger: purger, auditor: auditor, keys: keySet, migration: migration}
+		watcher := reload.NewWatcher(path, reloader.apply, reloader.audit)
+		if err := watcher.Load(reload.TriggerStartup); err != nil {
+			log.Fatalf("Invalid TOKENIZATION_CONFIG_FILE: %v", err)
+		}
+		keySet, reloader.started = reloader.keys, true
+		go watcher.Run(context.Background(), interval)
+	}
+	if keySet != nil {
+		cfg.Authenticator = keySet
+	}
+	
+	// A replica's tokens are purged by its primary
+	if follower == nil {
+		go purger.Run(context.Background(), purgeInterval)
+	}
+	policy = purger.Policy()
+	log.Printf("Retention: tokens %s, deleted tokens %s, audit records %s", policy.TokenRetention, policy.RestoreWindow, policy.AuditRetention)
+	serverOpts := append(interceptors.ServerOptions(cfg), transport.ServerOptions()...)
+	if serverTLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(serverTLS.ServerCredentials()))
+	}
+	
+	// Optionally record traffic for later replay against another build
+	if recordPath := os.Getenv("TOKENIZATION_RECORD_FILE"); recordPath != "" {
+		recordFile, err := os.OpenFile(recordPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Fatalf("Failed to open record file: %v", err)
+		}
+		defer recordFile.Close()
+		
+		rec, err := recorder.NewRecorder(recordFile)
+		if err != nil {
+			log.Fatalf("Failed to create recorder: %v", err)
+		}
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(rec.UnaryServerInterceptor()))
+		log.Printf("Recording gRPC traffic to %s", recordPath)
+	}
 	
 	// Create gRPC server
-	grpcServer := grpc.NewServer()
-	server.RegisterTokenizationServiceServer(grpcServer, server.NewServer(tokenService))
+	v2Server := serverv2.NewServer(tokenService, auditor)
+	v2Server.UseRetention(purger)
+	if ring != nil {
+		peers := make(map[string]serverv2.TokenizationServiceClient)
+		for _, node := range ring.Nodes() {
+			if node == clusterSelf {
+				continue
+			}
+			conn, err := grpc.Dial(node, append(transport.DialOptions(), grpc.WithTransportCredentials(peerCreds))...)
+			if err != nil {
+				log.Fatalf("Failed to connect to cluster node %s: %v", node, err)
+			}
+			defer conn.Close()
+			peers[node] = serverv2.NewTokenizationServiceClient(conn)
+		}
+		v2Server.UseCluster(ring, clusterSelf, peers)
+		log.Printf("Cluster node %s of %v", clusterSelf, ring.Nodes())
+	}
+	// Key rotation retires old key versions once this vault no longer needs
+	// them, which is only safe where this vault is the only one under the
+	// key: not on a replica, which follows its primary's re-encryption, nor
+	// in a cluster, whose other nodes hold ciphertexts of their own
+	if follower == nil && ring == nil {
+		rotationCfg := rotation.Config{}
+		if v := os.Getenv("TOKENIZATION_ROTATION_BATCH_SIZE"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid TOKENIZATION_ROTATION_BATCH_SIZE: %q", v)
+			}
+			rotationCfg.BatchSize = n
+		}
+		if v := os.Getenv("TOKENIZATION_ROTATION_PAUSE"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Invalid TOKENIZATION_ROTATION_PAUSE: %q", v)
+			}
+			rotationCfg.Pause = d
+		}
+		v2Server.UseKeyRotation(rotation.NewOrchestrator(tokenService, hsmClient, keyID, rotationCfg))
+	}
+	if follower != nil {
+		v2Server.UseReplica(follower.Ready)
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(v2Server.ReadOnly()))
+	}
+	// Sandboxes sharing the deployment each see only their own tokens
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(serverv2.Environments()))
+	grpcServer := grpc.NewServer(serverOpts...)
+	server.RegisterTokenizationServiceServer(grpcServer, server.NewServer(tokenService, v2Server))
+	serverv2.RegisterTokenizationServiceServer(grpcServer, v2Server)
+	reflection.Register(grpcServer)
 	
 	// Start listening
-	listener, err := net.Listen("tcp", port)
+	listener, err := net.Listen("tcp", ":"+port)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		log.Fatalf("Failed to listen on port %s: %v", port, err)
 	}
 	
-	log.Printf("Tokenization Service listening on %s", port)
+	// Serve Prometheus metrics on a separate port
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry.Handler())
+		mux.HandleFunc("/circuit-breakers", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode([]breaker.Snapshot{hsmBreaker.Snapshot()})
+		})
+		if migration != nil {
+			mux.HandleFunc("/vault-migration", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"mode":       migration.Mode(),
+					"last_check": migration.LastReport(),
+				})
+			})
+		}
+		if follower != nil {
+			mux.HandleFunc("/replica", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(follower.Status())
+			})
+		}
+		if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+	
+	log.Printf("Tokenization Service listening on port %s (metrics on %s)", port, metricsPort)
 	if err := grpcServer.Serve(listener); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
+
+// serveDiagnostics starts the diagnostics port when prefix+DIAGNOSTICS_ADDR
+// is set
+func serveDiagnostics(prefix, service string) {
+	cfg, err := diagnostics.FromEnv(prefix, os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	capturer, err := diagnostics.Serve(service, cfg)
+	if err != nil {
+		log.Fatalf("Failed to start diagnostics: %v", err)
+	}
+	if capturer != nil {
+		log.Printf("Diagnostics on %s, captures in %s", cfg.Addr, capturer.Dir())
+	}
+}
+
+// hsmBreakerConfig reads the HSM circuit breaker settings from the
+// environment
+func hsmBreakerConfig(registry *metrics.Registry) breaker.Config {
+	cfg := breaker.Config{IsFailure: hsm.IsUnavailable, Metrics: registry}
+	if v := os.Getenv("TOKENIZATION_HSM_BREAKER_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid TOKENIZATION_HSM_BREAKER_THRESHOLD: %q", v)
+		}
+		cfg.FailureThreshold = n
+	}
+	if v := os.Getenv("TOKENIZATION_HSM_BREAKER_OPEN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid TOKENIZATION_HSM_BREAKER_OPEN_TIMEOUT: %q", v)
+		}
+		cfg.OpenTimeout = d
+	}
+	fallback, err := breaker.ParseFallback(os.Getenv("TOKENIZATION_HSM_BREAKER_FALLBACK"))
+	if err != nil {
+		log.Fatalf("Invalid TOKENIZATION_HSM_BREAKER_FALLBACK: %v", err)
+	}
+	cfg.Fallback = fallback
+	return cfg
+}
+
+// hsmHedgeConfig reads the HSM hedging settings from the environment: the
+// latency percentile after which calls are hedged, as a percentage, and
+// the largest share of calls that may be hedged
+func hsmHedgeConfig(percentile string, registry *metrics.Registry) hedge.Config {
+	cfg := hedge.Config{IsRetryable: hsm.IsUnavailable, Metrics: registry}
+	p, err := strconv.ParseFloat(percentile, 64)
+	if err != nil || p < 50 || p >= 100 {
+		log.Fatalf("Invalid TOKENIZATION_HSM_HEDGE_PERCENTILE: want 50 to 99.9, got %q", percentile)
+	}
+	cfg.Percentile = p / 100
+	if v := os.Getenv("TOKENIZATION_HSM_HEDGE_MAX_RATIO"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r <= 0 || r > 1 {
+			log.Fatalf("Invalid TOKENIZATION_HSM_HEDGE_MAX_RATIO: %q", v)
+		}
+		cfg.MaxRatio = r
+	}
+	return cfg
+}
+
+// retentionDays reads a retention period in days from the environment. Zero
+// disables the purge.
+func retentionDays(name string, def int) time.Duration {
+	days := def
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid %s: %q", name, v)
+		}
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	"github.com/paymentgateway/tokenization-service/internal/replica"
+	"github.com/paymentgateway/tokenization-service/internal/serverv2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// replicaSource opens change streams from the primary behind conn,
+// authenticating with apiKey when one is set
+func replicaSource(conn *grpc.ClientConn, apiKey string) replica.Source {
+	client := serverv2.NewTokenizationServiceClient(conn)
+	return func(ctx context.Context) (replica.Stream, error) {
+		if apiKey != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+apiKey)
+		}
+		stream, err := client.StreamChanges(ctx, &serverv2.StreamChangesRequest{})
+		if err != nil {
+			return nil, err
+		}
+		return changeStream{stream}, nil
+	}
+}
+
+// changeStream adapts the StreamChanges client stream to replica.Stream
+type changeStream struct {
+	stream serverv2.TokenizationService_StreamChangesClient
+}
+
+func (s changeStream) Recv() (replica.Change, error) {
+	msg, err := s.stream.Recv()
+	if err != nil {
+		return replica.Change{}, err
+	}
+	return replica.Change{Record: msg.Record, SnapshotComplete: msg.SnapshotComplete}, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/paymentgateway/go-common/certs"
+	"github.com/paymentgateway/go-common/reload"
+)
+
+// loadTLS loads the certificates named by the prefix's TLS variables and
+// reloads them whenever they are rotated. It returns nil when none are
+// set. A server needs a certificate of its own.
+func loadTLS(prefix string, server bool) *certs.Reloader {
+	cfg, err := certs.FromEnv(prefix, os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+	if server && cfg.CertFile == "" {
+		log.Fatalf("%sTLS_CA_FILE requires %sTLS_CERT_FILE", prefix, prefix)
+	}
+	r, err := certs.NewReloader(cfg, func(e reload.Event) { log.Printf("TLS %s", e) })
+	if err != nil {
+		log.Fatalf("Invalid %sTLS configuration: %v", prefix, err)
+	}
+	go r.Run(context.Background(), certs.DefaultReloadInterval)
+	return r
+}
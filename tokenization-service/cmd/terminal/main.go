@@ -0,0 +1,146 @@
+// Command terminal simulates a P2PE card reader: it encrypts each swipe
+// under a fresh DUKPT key and sends only the ciphertext and KSN to the
+// tokenization service's TokenizeEncryptedCard, or with -gateway pays with
+// it through the authorization service, which passes it on opaquely. Inject
+// it with an IPEK from "gatewayctl key derive-ipek".
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/paymentgateway/go-common/dukpt"
+	"github.com/paymentgateway/go-common/grpcopts"
+	"github.com/paymentgateway/tokenization-service/internal/serverv2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	var (
+		ipekHex  = flag.String("ipek", "", "initial key injected into the terminal, 32 hex digits")
+		ksnHex   = flag.String("ksn", "", "initial key serial number, 20 hex digits")
+		track    = flag.String("track", ";4111111111111111=30121011234567?", "track 2 data to swipe")
+		count    = flag.Int("count", 1, "number of swipes")
+		addr     = flag.String("addr", "localhost:8445", "gRPC address of the tokenization service")
+		merchant = flag.String("merchant", "", "merchant ID the tokens are scoped to")
+		gateway  = flag.String("gateway", "", "base URL of the authorization service to pay through instead, e.g. http://localhost:8446")
+		apiKey   = flag.String("api-key", "", "merchant API key for -gateway")
+		amount   = flag.String("amount", "10.00", "amount of each payment with -gateway")
+		currency = flag.String("currency", "USD", "currency of each payment with -gateway")
+	)
+	flag.Parse()
+
+	ipek, err := hex.DecodeString(*ipekHex)
+	if err != nil {
+		log.Fatalf("Invalid -ipek: %v", err)
+	}
+	ksn, err := dukpt.ParseKSN(*ksnHex)
+	if err != nil {
+		log.Fatalf("Invalid -ksn: %v", err)
+	}
+	terminal, err := dukpt.NewTerminal(ipek, ksn)
+	if err != nil {
+		log.Fatalf("Failed to inject terminal: %v", err)
+	}
+	defer terminal.Destroy()
+
+	if *gateway != "" {
+		for i := 0; i < *count; i++ {
+			txKSN, encrypted, err := terminal.Encrypt([]byte(*track))
+			if err != nil {
+				log.Fatalf("Swipe %d: %v", i+1, err)
+			}
+			payment, err := pay(*gateway, *apiKey, *amount, *currency, txKSN, encrypted)
+			if err != nil {
+				log.Fatalf("Swipe %d (KSN %s): %v", i+1, txKSN, err)
+			}
+			fmt.Printf("KSN %s\t%s\t%s\t%s ****%s %s\n", txKSN, payment.PaymentID, payment.Status,
+				payment.CardBrand, payment.CardLastFour, payment.ErrorCode)
+		}
+		return
+	}
+
+	conn, err := grpc.Dial(*addr, append(grpcopts.Default().DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		log.Fatalf("Failed to connect to %s: %v", *addr, err)
+	}
+	defer conn.Close()
+	client := serverv2.NewTokenizationServiceClient(conn)
+
+	for i := 0; i < *count; i++ {
+		txKSN, encrypted, err := terminal.Encrypt([]byte(*track))
+		if err != nil {
+			log.Fatalf("Swipe %d: %v", i+1, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := client.TokenizeEncryptedCard(ctx, &serverv2.TokenizeEncryptedCardRequest{
+			Ksn:            txKSN[:],
+			EncryptedTrack: encrypted,
+			MerchantId:     *merchant,
+		})
+		cancel()
+		if err != nil {
+			log.Fatalf("Swipe %d (KSN %s): %v", i+1, txKSN, err)
+		}
+		fmt.Printf("KSN %s\t%s\t%s ****%s\n", txKSN, resp.Token, resp.CardBrand, resp.LastFour)
+	}
+}
+
+// gatewayPayment is the part of the authorization service's payment
+// response the terminal prints
+type gatewayPayment struct {
+	PaymentID    string `json:"paymentId"`
+	Status       string `json:"status"`
+	CardBrand    string `json:"cardBrand"`
+	CardLastFour string `json:"cardLastFour"`
+	ErrorCode    string `json:"errorCode"`
+}
+
+// pay sends a swipe to the authorization service as a payment's
+// encryptedCard
+func pay(gateway, apiKey, amount, currency string, ksn dukpt.KSN, encrypted []byte) (*gatewayPayment, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"encryptedCard": map[string]string{
+			"ksn":            hex.EncodeToString(ksn[:]),
+			"encryptedTrack": hex.EncodeToString(encrypted),
+		},
+		"amount":   json.Number(amount),
+		"currency": currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, gateway+"/api/v1/payments", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var payment gatewayPayment
+	if resp.StatusCode != http.StatusCreated {
+		var problem map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&problem)
+		return nil, fmt.Errorf("gateway answered %s: %v", resp.Status, problem)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
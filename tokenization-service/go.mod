@@ -8,3 +8,13 @@ require (
     google.golang.org/grpc v1.59.0
     google.golang.org/protobuf v1.31.0
 )
+
+require (
+    github.com/paymentgateway/go-common v0.0.0
+    github.com/paymentgateway/hsm-simulator v0.0.0
+)
+
+replace (
+    github.com/paymentgateway/go-common => ../go-common
+    github.com/paymentgateway/hsm-simulator => ../hsm-simulator
+)
@@ -0,0 +1,255 @@
+// Package audit records every token operation with the caller's identity,
+// merchant, token, outcome and latency. Records are append-only: stores hand
+// out copies and offer no way to change an entry, and the only deletion is a
+// retention purge of records older than a cutoff that are not under legal
+// hold.
+package audit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/redact"
+)
+
+var (
+	ErrStoreClosed = errors.New("audit store closed")
+)
+
+// Operation names an audited token operation
+type Operation string
+
+const (
+	OpTokenize   Operation = "tokenize"
+	OpDetokenize Operation = "detokenize"
+	OpValidate   Operation = "validate"
+	OpRevoke     Operation = "revoke"
+	OpDelete     Operation = "delete"
+	OpRestore    Operation = "restore"
+	OpUpdate     Operation = "update-metadata"
+	OpPurge      Operation = "purge"
+	OpForget     Operation = "forget"
+	OpShred      Operation = "shred"
+	// OpDetokenizeBatch is one token of a bulk detokenization; its detail
+	// holds the caller's business justification
+	OpDetokenizeBatch Operation = "detokenize-batch"
+	// OpProvision provisions a network token for a vault token
+	OpProvision Operation = "provision-network-token"
+	// OpExchange exchanges a vault token for its network token or back; the
+	// token recorded is the one presented
+	OpExchange Operation = "exchange"
+	// OpCardEvent is a card lifecycle event applied to a token; its detail
+	// holds the event
+	OpCardEvent Operation = "card-event"
+	// OpGenerateCryptogram issues a network token cryptogram and
+	// OpValidateCryptogram checks one; a rejected cryptogram is a failure
+	// whose detail says why
+	OpGenerateCryptogram Operation = "generate-cryptogram"
+	OpValidateCryptogram Operation = "validate-cryptogram"
+	// OpHandoff hands a node's tokens to their new owner in a cluster
+	// rebalance; its detail names the node and what was moved
+	OpHandoff Operation = "handoff"
+	// OpImport imports tokens handed off by another node
+	OpImport Operation = "import-tokens"
+	// OpReloadConfig is a configuration reload; its principal is what
+	// triggered the reload
+	OpReloadConfig Operation = "reload-config"
+	// OpUpdateDomain replaces a token's domain controls
+	OpUpdateDomain Operation = "update-domain"
+	// OpRotateKey starts a rotation of the vault's HSM key; its detail holds
+	// the rotation and the version ciphertexts move to
+	OpRotateKey Operation = "rotate-key"
+)
+
+// Outcome is the result of an audited operation
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Record is a single audit entry. It never holds a PAN: Token is the vault
+// token, and anything PAN-shaped is masked before the record is stored.
+type Record struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Operation  Operation `json:"operation"`
+	Principal  string    `json:"principal,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	MerchantID string    `json:"merchant_id,omitempty"`
+	Token      string    `json:"token,omitempty"`
+	Outcome    Outcome   `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	LatencyMs  float64   `json:"latency_ms"`
+	// Detail describes what an administrative operation changed
+	Detail string `json:"detail,omitempty"`
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Operation  Operation
+	Principal  string
+	MerchantID string
+	Token      string
+	Outcome    Outcome
+	Since      time.Time
+	Until      time.Time
+	// BeforeSeq skips records with this or a higher sequence number, so a
+	// query can continue after the last record of a previous page. Zero
+	// means no bound.
+	BeforeSeq int64
+	// Limit caps the result, newest records first. Zero means DefaultLimit.
+	Limit int
+}
+
+// DefaultLimit and MaxLimit bound query results
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Match reports whether the record passes the filter
+func (f Filter) Match(r Record) bool {
+	switch {
+	case f.Operation != "" && r.Operation != f.Operation,
+		f.Principal != "" && r.Principal != f.Principal,
+		f.MerchantID != "" && r.MerchantID != f.MerchantID,
+		f.Token != "" && r.Token != f.Token,
+		f.Outcome != "" && r.Outcome != f.Outcome,
+		!f.Since.IsZero() && r.Time.Before(f.Since),
+		!f.Until.IsZero() && !r.Time.Before(f.Until),
+		f.BeforeSeq > 0 && r.Seq >= f.BeforeSeq:
+		return false
+	}
+	return true
+}
+
+func (f Filter) limit() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultLimit
+	case f.Limit > MaxLimit:
+		return MaxLimit
+	}
+	return f.Limit
+}
+
+// Store persists audit records
+type Store interface {
+	// Append assigns the record its sequence number and stores it
+	Append(r Record) (Record, error)
+	// Query returns matching records, newest first
+	Query(f Filter) ([]Record, error)
+	// Purge deletes records older than before unless hold keeps them, and
+	// returns how many were deleted. Remaining records keep their sequence
+	// numbers.
+	Purge(before time.Time, hold func(Record) bool) (int, error)
+}
+
+// LegalHold exempts records from retention purges, either entirely or for
+// the listed merchants
+type LegalHold struct {
+	All       bool
+	Merchants map[string]bool
+}
+
+// ParseLegalHold parses "*" (hold everything) or a comma-separated list of
+// merchant IDs
+func ParseLegalHold(spec string) LegalHold {
+	var h LegalHold
+	for _, m := range strings.Split(spec, ",") {
+		switch m = strings.TrimSpace(m); m {
+		case "":
+		case "*":
+			h.All = true
+		default:
+			if h.Merchants == nil {
+				h.Merchants = make(map[string]bool)
+			}
+			h.Merchants[m] = true
+		}
+	}
+	return h
+}
+
+// Holds reports whether the record must be kept
+func (h LegalHold) Holds(r Record) bool {
+	return h.All || h.Merchants[r.MerchantID]
+}
+
+// Auditor builds records from request context and writes them to a store
+type Auditor struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewAuditor creates an auditor writing to the store
+func NewAuditor(store Store) *Auditor {
+	return &Auditor{store: store, now: time.Now}
+}
+
+// Record audits one operation that started at start and ended with err. The
+// caller identity and request ID come from the interceptor chain.
+func (a *Auditor) Record(ctx context.Context, op Operation, merchantID, token string, start time.Time, err error) error {
+	return a.RecordDetail(ctx, op, merchantID, token, "", start, err)
+}
+
+// RecordDetail is Record with a detail, such as the justification the caller
+// gave for the operation
+func (a *Auditor) RecordDetail(ctx context.Context, op Operation, merchantID, token, detail string, start time.Time, err error) error {
+	now := a.now()
+	r := Record{
+		Time:       now.UTC(),
+		Operation:  op,
+		RequestID:  interceptors.RequestIDFromContext(ctx),
+		MerchantID: merchantID,
+		Token:      redact.Text(token),
+		Outcome:    OutcomeSuccess,
+		LatencyMs:  float64(now.Sub(start).Microseconds()) / 1000,
+		Detail:     redact.Text(detail),
+	}
+	if p := interceptors.PrincipalFromContext(ctx); p != nil {
+		r.Principal = p.ID
+	}
+	if err != nil {
+		r.Outcome = OutcomeFailure
+		r.Error = redact.Text(err.Error())
+	}
+	_, storeErr := a.store.Append(r)
+	return storeErr
+}
+
+// RecordConfigReload audits a configuration reload triggered by requester
+// that changed what detail describes
+func (a *Auditor) RecordConfigReload(requester, detail string, err error) error {
+	r := Record{
+		Time:      a.now().UTC(),
+		Operation: OpReloadConfig,
+		Principal: requester,
+		Outcome:   OutcomeSuccess,
+		Detail:    redact.Text(detail),
+	}
+	if err != nil {
+		r.Outcome = OutcomeFailure
+		r.Error = redact.Text(err.Error())
+	}
+	_, storeErr := a.store.Append(r)
+	return storeErr
+}
+
+// Purge deletes records older than before that are not under legal hold
+func (a *Auditor) Purge(before time.Time, hold LegalHold) (int, error) {
+	if hold.All {
+		return 0, nil
+	}
+	return a.store.Purge(before, hold.Holds)
+}
+
+// Query returns matching records, newest first
+func (a *Auditor) Query(f Filter) ([]Record, error) {
+	return a.store.Query(f)
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+)
+
+/**
+ * Feature: payment-acquiring-gateway, Property 15: Audit Log Immutability
+ * For any audit record created, subsequent queries for that record should
+ * return identical data, even if a caller modifies a previously returned copy.
+ * Validates: Requirements 8.1
+ */
+func TestProperty_AuditRecordImmutability(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("queried records cannot be altered", prop.ForAll(
+		func(merchants []string) bool {
+			store := NewMemoryStore()
+			auditor := NewAuditor(store)
+			for _, m := range merchants {
+				auditor.Record(context.Background(), OpTokenize, m, "9123456789010366", time.Now(), nil)
+			}
+
+			first, _ := store.Query(Filter{Limit: MaxLimit})
+			snapshot := append([]Record(nil), first...)
+			for i := range first {
+				first[i].MerchantID = "tampered"
+				first[i].Outcome = OutcomeFailure
+			}
+
+			second, _ := store.Query(Filter{Limit: MaxLimit})
+			if !reflect.DeepEqual(second, snapshot) {
+				t.Logf("records changed between queries")
+				return false
+			}
+			return true
+		},
+		gen.SliceOf(gen.Identifier()),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+/**
+ * Feature: payment-acquiring-gateway, Property 16: PAN Redaction in Logs
+ * For any audit record, even when a PAN is passed as the token or appears in
+ * an error message, the stored record must not contain the raw PAN.
+ * Validates: Requirements 8.3
+ */
+func TestProperty_AuditPANRedaction(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("stored records never contain a PAN", prop.ForAll(
+		func(pan string) bool {
+			store := NewMemoryStore()
+			auditor := NewAuditor(store)
+			auditor.Record(context.Background(), OpDetokenize, "m1", pan, time.Now(), errors.New("lookup of "+pan+" failed"))
+
+			records, _ := store.Query(Filter{})
+			r := records[0]
+			if strings.Contains(r.Token, pan) || strings.Contains(r.Error, pan) {
+				t.Logf("PAN %s leaked into record %+v", pan, r)
+				return false
+			}
+			return true
+		},
+		genValidPAN(),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+// genValidPAN generates Luhn-valid 16-digit PANs that do not start with 9
+func genValidPAN() gopter.Gen {
+	return gen.SliceOfN(15, gen.IntRange(0, 9)).Map(func(digits []int) string {
+		if digits[0] == 9 || digits[0] == 0 {
+			digits[0] = 4
+		}
+		sum := 0
+		for i := len(digits) - 1; i >= 0; i-- {
+			d := digits[i]
+			if (len(digits)-1-i)%2 == 0 {
+				d *= 2
+				if d > 9 {
+					d -= 9
+				}
+			}
+			sum += d
+		}
+		var b strings.Builder
+		for _, d := range digits {
+			b.WriteByte(byte('0' + d))
+		}
+		b.WriteByte(byte('0' + (10-sum%10)%10))
+		return b.String()
+	})
+}
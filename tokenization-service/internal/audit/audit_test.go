@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/interceptors"
+)
+
+func TestAuditorRecordsCaller(t *testing.T) {
+	store := NewMemoryStore()
+	auditor := NewAuditor(store)
+
+	ctx := interceptors.WithPrincipal(context.Background(), &interceptors.Principal{ID: "authorization-service"})
+	ctx = interceptors.WithRequestID(ctx, "req-1")
+	start := time.Now().Add(-5 * time.Millisecond)
+
+	if err := auditor.Record(ctx, OpDetokenize, "m1", "9123456789010366", start, nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := auditor.Record(context.Background(), OpTokenize, "m1", "", start, errors.New("invalid PAN format")); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	records, _ := auditor.Query(Filter{})
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	failed, ok := records[0], records[1]
+	if ok.Principal != "authorization-service" || ok.RequestID != "req-1" || ok.Outcome != OutcomeSuccess || ok.LatencyMs < 5 {
+		t.Errorf("unexpected success record %+v", ok)
+	}
+	if failed.Outcome != OutcomeFailure || failed.Error != "invalid PAN format" || failed.Seq != 2 {
+		t.Errorf("unexpected failure record %+v", failed)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	store := NewMemoryStore()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, op := range []Operation{OpTokenize, OpDetokenize, OpValidate, OpRevoke, OpDetokenize} {
+		store.Append(Record{Time: base.Add(time.Duration(i) * time.Minute), Operation: op, MerchantID: "m1", Outcome: OutcomeSuccess})
+	}
+
+	tests := []struct {
+		name string
+		f    Filter
+		want []int64
+	}{
+		{"all newest first", Filter{}, []int64{5, 4, 3, 2, 1}},
+		{"by operation", Filter{Operation: OpDetokenize}, []int64{5, 2}},
+		{"time window", Filter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, []int64{3, 2}},
+		{"limit", Filter{Limit: 2}, []int64{5, 4}},
+		{"next page", Filter{BeforeSeq: 4, Limit: 2}, []int64{3, 2}},
+		{"other merchant", Filter{MerchantID: "m2"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := store.Query(tt.f)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d records, want %v", len(got), tt.want)
+			}
+			for i, seq := range tt.want {
+				if got[i].Seq != seq {
+					t.Errorf("record %d seq = %d, want %d", i, got[i].Seq, seq)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	store.Append(Record{Operation: OpTokenize, Token: "9123456789010366", Outcome: OutcomeSuccess})
+	store.Append(Record{Operation: OpRevoke, Token: "9123456789010366", Outcome: OutcomeSuccess})
+	store.Close()
+
+	if _, err := store.Append(Record{}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Append() after Close error = %v, want %v", err, ErrStoreClosed)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	defer reopened.Close()
+
+	rec, _ := reopened.Append(Record{Operation: OpValidate, Outcome: OutcomeFailure})
+	if rec.Seq != 3 {
+		t.Errorf("sequence not continued after reload: seq = %d, want 3", rec.Seq)
+	}
+	records, _ := reopened.Query(Filter{Token: "9123456789010366"})
+	if len(records) != 2 || records[0].Operation != OpRevoke {
+		t.Errorf("records not reloaded: %+v", records)
+	}
+}
+
+func TestPurgeLegalHold(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fileStore, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	defer fileStore.Close()
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": fileStore} {
+		t.Run(name, func(t *testing.T) {
+			auditor := NewAuditor(store)
+			for i, m := range []string{"m1", "held", "m1", "m2"} {
+				store.Append(Record{Time: base.Add(time.Duration(i) * 24 * time.Hour), Operation: OpTokenize, MerchantID: m})
+			}
+
+			if n, _ := auditor.Purge(base.Add(72*time.Hour), ParseLegalHold("*")); n != 0 {
+				t.Errorf("Purge() under full hold deleted %d records", n)
+			}
+			n, err := auditor.Purge(base.Add(72*time.Hour), ParseLegalHold("held, other"))
+			if err != nil || n != 2 {
+				t.Fatalf("Purge() = %d, %v; want 2", n, err)
+			}
+
+			records, _ := store.Query(Filter{})
+			if len(records) != 2 || records[0].Seq != 4 || records[1].MerchantID != "held" {
+				t.Errorf("remaining records = %+v, want seq 4 and the held record", records)
+			}
+			if rec, _ := store.Append(Record{Operation: OpValidate}); rec.Seq != 5 {
+				t.Errorf("sequence reused after purge: seq = %d, want 5", rec.Seq)
+			}
+		})
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	defer reopened.Close()
+	if records, _ := reopened.Query(Filter{}); len(records) != 3 {
+		t.Errorf("purged file has %d records, want 3", len(records))
+	}
+}
+
+func TestAuditorRecordsDetail(t *testing.T) {
+	auditor := NewAuditor(NewMemoryStore())
+
+	detail := `item 1/2; reference "SETTLE-1"; justification "settlement file for 4532015112830366"`
+	if err := auditor.RecordDetail(context.Background(), OpDetokenizeBatch, "m1", "9123456789010366", detail, time.Now(), nil); err != nil {
+		t.Fatalf("RecordDetail() error = %v", err)
+	}
+
+	records, _ := auditor.Query(Filter{Operation: OpDetokenizeBatch})
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if got := records[0].Detail; !strings.Contains(got, `reference "SETTLE-1"`) || strings.Contains(got, "4532015112830366") {
+		t.Errorf("detail = %q, want the reference kept and the PAN masked", got)
+	}
+}
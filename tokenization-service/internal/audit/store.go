@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in memory
+type MemoryStore struct {
+	records []Record
+	lastSeq int64
+	mu      sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append stores a record
+func (m *MemoryStore) Append(r Record) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSeq++
+	r.Seq = m.lastSeq
+	m.records = append(m.records, r)
+	return r, nil
+}
+
+// Query returns matching records, newest first
+func (m *MemoryStore) Query(f Filter) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return query(m.records, f), nil
+}
+
+// Purge deletes old records that are not held
+func (m *MemoryStore) Purge(before time.Time, hold func(Record) bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int
+	m.records, n = purge(m.records, before, hold)
+	return n, nil
+}
+
+func purge(records []Record, before time.Time, hold func(Record) bool) ([]Record, int) {
+	kept := make([]Record, 0, len(records))
+	for _, r := range records {
+		if r.Time.Before(before) && (hold == nil || !hold(r)) {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, len(records) - len(kept)
+}
+
+func query(records []Record, f Filter) []Record {
+	limit := f.limit()
+	var out []Record
+	for i := len(records) - 1; i >= 0 && len(out) < limit; i-- {
+		if f.Match(records[i]) {
+			out = append(out, records[i])
+		}
+	}
+	return out
+}
+
+// FileStore appends records to a JSON-lines file and keeps an in-memory copy
+// for queries. Existing records are loaded when the file is opened, so the
+// trail survives restarts.
+type FileStore struct {
+	MemoryStore
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+// OpenFileStore opens or creates the audit file at path
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit file: %w", err)
+	}
+
+	records, err := readRecords(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("load audit file %s: %w", path, err)
+	}
+
+	var lastSeq int64
+	if len(records) > 0 {
+		lastSeq = records[len(records)-1].Seq
+	}
+	return &FileStore{
+		MemoryStore: MemoryStore{records: records, lastSeq: lastSeq},
+		path:        path,
+		file:        file,
+		enc:         json.NewEncoder(file),
+	}, nil
+}
+
+// Append writes the record to the file before making it visible to queries
+func (s *FileStore) Append(r Record) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return Record{}, ErrStoreClosed
+	}
+	r.Seq = s.lastSeq + 1
+	if err := s.enc.Encode(r); err != nil {
+		return Record{}, fmt.Errorf("write audit record: %w", err)
+	}
+	s.lastSeq = r.Seq
+	s.records = append(s.records, r)
+	return r, nil
+}
+
+// Purge deletes old records that are not held by rewriting the file with
+// the remaining ones and atomically replacing it
+func (s *FileStore) Purge(before time.Time, hold func(Record) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return 0, ErrStoreClosed
+	}
+	kept, n := purge(s.records, before, hold)
+	if n == 0 {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".purge-*")
+	if err != nil {
+		return 0, fmt.Errorf("purge audit file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	for _, r := range kept {
+		if err := enc.Encode(r); err != nil {
+			tmp.Close()
+			return 0, fmt.Errorf("purge audit file: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("purge audit file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("purge audit file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, fmt.Errorf("purge audit file: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("reopen audit file: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+	s.enc = json.NewEncoder(file)
+	s.records = kept
+	return n, nil
+}
+
+// Close closes the underlying file
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func readRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,104 @@
+// Package cluster partitions the token space across tokenization-service
+// instances by consistent hashing. Each node owns the tokens, and the cards,
+// whose hashes fall on its arcs of the ring; adding or removing a node moves
+// only the keys on the arcs it gains or loses.
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// DefaultVirtualNodes is the number of points each node has on the ring.
+// More points spread keys more evenly at the cost of a larger ring.
+const DefaultVirtualNodes = 128
+
+// ErrNoNodes is returned for a ring without nodes
+var ErrNoNodes = errors.New("cluster has no nodes")
+
+// Ring maps keys to the nodes that own them
+type Ring struct {
+	nodes  []string
+	points []point
+}
+
+type point struct {
+	hash uint64
+	node string
+}
+
+// NewRing builds a ring of the given nodes with vnodes points each, or
+// DefaultVirtualNodes if vnodes is zero
+func NewRing(nodes []string, vnodes int) (*Ring, error) {
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	seen := make(map[string]bool, len(nodes))
+	r := &Ring{}
+	for _, node := range nodes {
+		if node == "" || seen[node] {
+			return nil, fmt.Errorf("invalid cluster node %q: empty or listed twice", node)
+		}
+		seen[node] = true
+		r.nodes = append(r.nodes, node)
+		for i := 0; i < vnodes; i++ {
+			r.points = append(r.points, point{hash: hash(fmt.Sprintf("%s#%d", node, i)), node: node})
+		}
+	}
+	if len(r.nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+	sort.Strings(r.nodes)
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r, nil
+}
+
+// ParseNodes reads a comma-separated node list
+func ParseNodes(spec string) []string {
+	var nodes []string
+	for _, node := range strings.Split(spec, ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Nodes returns the ring's nodes, sorted
+func (r *Ring) Nodes() []string {
+	return append([]string(nil), r.nodes...)
+}
+
+// Has reports whether node is on the ring
+func (r *Ring) Has(node string) bool {
+	i := sort.SearchStrings(r.nodes, node)
+	return i < len(r.nodes) && r.nodes[i] == node
+}
+
+// Owner returns the node owning key: the first point clockwise from the
+// key's hash
+func (r *Ring) Owner(key string) string {
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// FNV spreads short, similar keys poorly over the high bits the ring
+	// orders by, so mix the result
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
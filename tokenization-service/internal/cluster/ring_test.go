@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingSpreadsKeys(t *testing.T) {
+	ring, err := NewRing([]string{"a:8445", "b:8445", "c:8445"}, 0)
+	if err != nil {
+		t.Fatalf("NewRing() error = %v", err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 30000; i++ {
+		counts[ring.Owner(fmt.Sprintf("9%015d", i))]++
+	}
+	for _, node := range ring.Nodes() {
+		if n := counts[node]; n < 7000 || n > 13000 {
+			t.Errorf("node %s owns %d of 30000 keys, want about a third", node, n)
+		}
+	}
+}
+
+func TestRingMovesFewKeysOnGrowth(t *testing.T) {
+	before, _ := NewRing([]string{"a", "b", "c"}, 0)
+	after, _ := NewRing([]string{"a", "b", "c", "d"}, 0)
+
+	moved := 0
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if old, now := before.Owner(key), after.Owner(key); old != now {
+			if now != "d" {
+				t.Fatalf("key %s moved from %s to %s, want only moves to the new node", key, old, now)
+			}
+			moved++
+		}
+	}
+	if moved < 3000 || moved > 7000 {
+		t.Errorf("%d of 20000 keys moved, want about a quarter", moved)
+	}
+}
+
+func TestNewRingRejectsBadNodes(t *testing.T) {
+	if _, err := NewRing(nil, 0); err != ErrNoNodes {
+		t.Errorf("NewRing(nil) error = %v, want %v", err, ErrNoNodes)
+	}
+	if _, err := NewRing([]string{"a", "a"}, 0); err == nil {
+		t.Error("NewRing() accepted a node listed twice")
+	}
+	if nodes := ParseNodes(" a:1, ,b:2 "); len(nodes) != 2 || nodes[0] != "a:1" || nodes[1] != "b:2" {
+		t.Errorf("ParseNodes() = %q", nodes)
+	}
+}
@@ -0,0 +1,115 @@
+package hsm
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Kinds of key advice the HSM sends
+const (
+	AdviceCurrent             = "current"
+	AdviceRotated             = "rotated"
+	AdviceRetiring            = "retiring"
+	AdviceRetirementCancelled = "retirement-cancelled"
+	AdviceRetired             = "retired"
+)
+
+// Reconnect backoff of WatchKeys
+const (
+	watchMinBackoff = time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
+// KeyEvent is a key lifecycle event announced by the HSM, decoded from its
+// KeyAdvice message
+type KeyEvent struct {
+	Kind  string
+	KeyID string
+	// Version is the new version of a rotation, or the version retiring
+	Version        int
+	CurrentVersion int
+	// Deadline is when a pending retirement's approval window closes
+	Deadline time.Time
+}
+
+// WatchKeys follows the HSM's advice for keyIDs until ctx is done, calling
+// handle for each message. Every connection opens with the keys' current
+// versions, so advice missed while disconnected is made up for. When the
+// stream ends it reconnects with backoff, trying each HSM of the pair in
+// turn. While connected, CurrentVersion answers from the advice instead of
+// asking the HSM.
+func (c *Client) WatchKeys(ctx context.Context, keyIDs []string, handle func(KeyEvent)) {
+	backoff := watchMinBackoff
+	c.mu.Lock()
+	idx := c.active
+	c.mu.Unlock()
+	for ctx.Err() == nil {
+		connected, err := c.watch(ctx, c.clients[idx], keyIDs, handle)
+		c.forgetVersions()
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = watchMinBackoff
+		}
+		log.Printf("HSM key advice stream from %s ended: %v; reconnecting in %s", c.conns[idx].Target(), err, backoff)
+		if IsUnavailable(err) {
+			idx = (idx + 1) % len(c.clients)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, watchMaxBackoff)
+	}
+}
+
+// watch reads one advice stream until it fails, reporting whether it
+// delivered anything
+func (c *Client) watch(ctx context.Context, client HSMServiceClient, keyIDs []string, handle func(KeyEvent)) (bool, error) {
+	stream, err := client.WatchKeys(ctx, &WatchKeysRequest{KeyIds: keyIDs})
+	if err != nil {
+		return false, err
+	}
+	connected := false
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return connected, err
+		}
+		connected = true
+		advice := KeyEvent{
+			Kind:           msg.Kind,
+			KeyID:          msg.KeyId,
+			Version:        int(msg.KeyVersion),
+			CurrentVersion: int(msg.CurrentVersion),
+		}
+		if msg.Deadline != 0 {
+			advice.Deadline = time.Unix(msg.Deadline, 0)
+		}
+		c.mu.Lock()
+		if c.versions == nil {
+			c.versions = make(map[string]int)
+		}
+		c.versions[advice.KeyID] = advice.CurrentVersion
+		c.mu.Unlock()
+		handle(advice)
+	}
+}
+
+// cachedVersion returns a key's current version as last advised
+func (c *Client) cachedVersion(keyID string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.versions[keyID]
+	return v, ok && v > 0
+}
+
+// forgetVersions drops advised versions once no stream keeps them current
+func (c *Client) forgetVersions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.versions = nil
+}
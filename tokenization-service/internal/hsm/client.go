@@ -3,56 +3,254 @@ package hsm
 import (
 	"context"
 	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/paymentgateway/go-common/breaker"
+	"github.com/paymentgateway/go-common/grpcopts"
+	"github.com/paymentgateway/go-common/hedge"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-// Client wraps the HSM gRPC client
+// Client wraps the HSM gRPC client. Given the addresses of an HA pair it
+// fails over to the next HSM when the active one is unreachable.
 type Client struct {
-	conn   *grpc.ClientConn
-	client HSMServiceClient
+	conns   []*grpc.ClientConn
+	clients []HSMServiceClient
+	active  int
+	mu      sync.Mutex
+	breaker *breaker.Breaker
+	hedger  *hedge.Hedger
+	// priority tags calls for the HSM's worker pool
+	priority string
+	// versions holds current key versions advised over WatchKeys
+	versions map[string]int
 }
 
-// NewClient creates a new HSM client
-func NewClient(address string) (*Client, error) {
+// NewClient creates a new HSM client. The first address must be reachable;
+// standbys after it are connected to lazily.
+func NewClient(address string, standbys ...string) (*Client, error) {
+	return NewClientWithTransport(address, grpcopts.Default(), nil, standbys...)
+}
+
+// NewClientWithTransport creates a new HSM client whose connections use the
+// given message limits, compression and keepalive, secured by creds. Nil
+// creds connect in plaintext.
+func NewClientWithTransport(address string, transport grpcopts.Config, creds credentials.TransportCredentials, standbys ...string) (*Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
-	conn, err := grpc.DialContext(ctx, address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	dialOpts := append(transport.DialOptions(), grpc.WithTransportCredentials(creds))
+	conn, err := grpc.DialContext(ctx, address, append(dialOpts, grpc.WithBlock())...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to HSM: %w", err)
 	}
 	
-	client := NewHSMServiceClient(conn)
+	c := &Client{}
+	c.add(conn)
+	for _, standby := range standbys {
+		conn, err := grpc.Dial(standby, dialOpts...)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to set up standby HSM %s: %w", standby, err)
+		}
+		c.add(conn)
+	}
 	
-	return &Client{
-		conn:   conn,
-		client: client,
-	}, nil
+	return c, nil
 }
 
-// Close closes the HSM client connection
+func (c *Client) add(conn *grpc.ClientConn) {
+	c.conns = append(c.conns, conn)
+	c.clients = append(c.clients, NewHSMServiceClient(conn))
+}
+
+// Close closes the HSM client connections
 func (c *Client) Close() error {
-	return c.conn.Close()
+	var firstErr error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SetBreaker guards HSM calls with a circuit breaker, which opens once the
+// whole HA pair has been unreachable for a run of calls. Build it with
+// IsUnavailable so errors such as an unknown key don't count.
+func (c *Client) SetBreaker(b *breaker.Breaker) {
+	c.breaker = b
+}
+
+// IsUnavailable reports whether err means no HSM could be reached
+func IsUnavailable(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
+
+// call runs fn against the active HSM and, if it is unreachable, against
+// each other HSM in turn. The first one to answer becomes active.
+func (c *Client) call(fn func(ctx context.Context, client HSMServiceClient) error) error {
+	return c.callWithContext(context.Background(), fn)
+}
+
+// callWithContext is call bounded by parent as well as the per-call timeout
+func (c *Client) callWithContext(parent context.Context, fn func(ctx context.Context, client HSMServiceClient) error) error {
+	return c.guard(func() error {
+		return c.callHSMs(parent, fn)
+	})
+}
+
+// guard runs fn through the circuit breaker, if there is one
+func (c *Client) guard(fn func() error) error {
+	if c.breaker != nil {
+		return c.breaker.Do(context.Background(), fn)
+	}
+	return fn()
+}
+
+// Priorities of HSM calls, the lanes of its worker pool
+const (
+	PriorityAuthorization = "authorization"
+	PriorityStandard      = "standard"
+	PriorityBatch         = "batch"
+)
+
+// SetPriority tags the client's calls with a priority. When all of the
+// HSM's workers are busy, authorization calls are served before standard
+// ones, and those before batch calls. Untagged calls are standard.
+func (c *Client) SetPriority(priority string) error {
+	switch priority {
+	case "", PriorityAuthorization, PriorityStandard, PriorityBatch:
+		c.priority = priority
+		return nil
+	}
+	return fmt.Errorf("unknown HSM priority %q: want %s, %s or %s", priority, PriorityAuthorization, PriorityStandard, PriorityBatch)
+}
+
+// callContext returns the context of one HSM call, carrying the client's
+// priority
+func (c *Client) callContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	if c.priority != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-hsm-priority", c.priority)
+	}
+	return ctx, cancel
+}
+
+// SetHedger hedges the calls that are safe to repeat: when the active HSM
+// is slower than usual to answer, the call goes to the next HSM of the pair
+// too and the first answer wins. It takes effect with a standby configured.
+// Build the hedger with IsUnavailable as its IsRetryable so an unreachable
+// HSM still fails over at once.
+func (c *Client) SetHedger(h *hedge.Hedger) {
+	c.hedger = h
+}
+
+// callIdempotent runs fn like call, hedged when a hedger is set. Only HSM
+// commands without side effects, which give the same answer when repeated,
+// may go through it: decryption, cryptogram computation and key lookups.
+// Commands that create or rotate keys, or encrypt under a fresh nonce, must
+// use call.
+func callIdempotent[T any](c *Client, fn func(ctx context.Context, client HSMServiceClient) (T, error)) (T, error) {
+	var resp T
+	if c.hedger == nil || len(c.clients) < 2 {
+		err := c.call(func(ctx context.Context, client HSMServiceClient) (err error) {
+			resp, err = fn(ctx, client)
+			return err
+		})
+		return resp, err
+	}
+	
+	c.mu.Lock()
+	start := c.active
+	c.mu.Unlock()
+	var activeDown atomic.Bool
+	err := c.guard(func() error {
+		var target int
+		var err error
+		resp, target, err = hedge.Do(context.Background(), c.hedger, func(ctx context.Context, target int) (T, error) {
+			ctx, cancel := c.callContext(ctx)
+			defer cancel()
+			resp, err := fn(ctx, c.clients[(start+target)%len(c.clients)])
+			if target == 0 && IsUnavailable(err) {
+				activeDown.Store(true)
+			}
+			return resp, err
+		})
+		// A hedge answering first only means the active HSM was slow; it
+		// stays active unless it was unreachable
+		if target == 1 && err == nil && activeDown.Load() {
+			idx := (start + 1) % len(c.clients)
+			c.mu.Lock()
+			c.active = idx
+			c.mu.Unlock()
+			log.Printf("HSM failover: now using %s", c.conns[idx].Target())
+		}
+		return err
+	})
+	return resp, err
+}
+
+func (c *Client) callHSMs(parent context.Context, fn func(ctx context.Context, client HSMServiceClient) error) error {
+	c.mu.Lock()
+	start := c.active
+	c.mu.Unlock()
+	
+	var err error
+	for i := 0; i < len(c.clients); i++ {
+		idx := (start + i) % len(c.clients)
+		ctx, cancel := c.callContext(parent)
+		err = fn(ctx, c.clients[idx])
+		cancel()
+		// A caller giving up is not the HSM failing
+		if parent.Err() != nil {
+			return err
+		}
+		if IsUnavailable(err) {
+			continue
+		}
+		if idx != start {
+			c.mu.Lock()
+			c.active = idx
+			c.mu.Unlock()
+			log.Printf("HSM failover: now using %s", c.conns[idx].Target())
+		}
+		return err
+	}
+	return err
 }
 
 // Encrypt encrypts plaintext using the HSM
 func (c *Client) Encrypt(keyID string, plaintext, aad []byte) (ciphertext, nonce []byte, keyVersion int, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
+	return c.EncryptContext(context.Background(), keyID, plaintext, aad)
+}
+
+// EncryptContext is Encrypt, given up when ctx is done
+func (c *Client) EncryptContext(ctx context.Context, keyID string, plaintext, aad []byte) (ciphertext, nonce []byte, keyVersion int, err error) {
 	req := &EncryptRequest{
 		KeyId:     keyID,
 		Plaintext: plaintext,
 		Aad:       aad,
 	}
 	
-	resp, err := c.client.Encrypt(ctx, req)
+	var resp *EncryptResponse
+	err = c.callWithContext(ctx, func(ctx context.Context, client HSMServiceClient) (err error) {
+		resp, err = client.Encrypt(ctx, req)
+		return err
+	})
 	if err != nil {
 		return nil, nil, 0, fmt.Errorf("HSM encrypt failed: %w", err)
 	}
@@ -62,9 +260,6 @@ func (c *Client) Encrypt(keyID string, plaintext, aad []byte) (ciphertext, nonce
 
 // Decrypt decrypts ciphertext using the HSM
 func (c *Client) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
 	req := &DecryptRequest{
 		KeyId:      keyID,
 		Ciphertext: ciphertext,
@@ -73,7 +268,9 @@ func (c *Client) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion
 		KeyVersion: int32(keyVersion),
 	}
 	
-	resp, err := c.client.Decrypt(ctx, req)
+	resp, err := callIdempotent(c, func(ctx context.Context, client HSMServiceClient) (*DecryptResponse, error) {
+		return client.Decrypt(ctx, req)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("HSM decrypt failed: %w", err)
 	}
@@ -81,20 +278,201 @@ func (c *Client) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion
 	return resp.Plaintext, nil
 }
 
+// EncryptEnvelope encrypts plaintext into a self-describing ciphertext
+// envelope using the HSM
+func (c *Client) EncryptEnvelope(keyID string, plaintext, aad []byte) (envelope []byte, keyVersion int, err error) {
+	req := &EncryptEnvelopeRequest{
+		KeyId:     keyID,
+		Plaintext: plaintext,
+		Aad:       aad,
+	}
+	
+	var resp *EncryptEnvelopeResponse
+	err = c.call(func(ctx context.Context, client HSMServiceClient) (err error) {
+		resp, err = client.EncryptEnvelope(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("HSM encrypt envelope failed: %w", err)
+	}
+	
+	return resp.Envelope, int(resp.KeyVersion), nil
+}
+
+// DecryptEnvelope decrypts a ciphertext envelope using the HSM
+func (c *Client) DecryptEnvelope(envelope, aad []byte) ([]byte, error) {
+	req := &DecryptEnvelopeRequest{
+		Envelope: envelope,
+		Aad:      aad,
+	}
+	
+	resp, err := callIdempotent(c, func(ctx context.Context, client HSMServiceClient) (*DecryptEnvelopeResponse, error) {
+		return client.DecryptEnvelope(ctx, req)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("HSM decrypt envelope failed: %w", err)
+	}
+	
+	return resp.Plaintext, nil
+}
+
+// DecryptP2PE decrypts a P2PE terminal's encrypted track data using the
+// HSM, returning the card fields the vault needs. Data the HSM rejects is
+// reported as tokenization.ErrInvalidEncryptedCard.
+func (c *Client) DecryptP2PE(bdkID string, ksn, encryptedTrack []byte) (pan string, expiryMonth, expiryYear int, err error) {
+	req := &DecryptP2PERequest{
+		BdkId:          bdkID,
+		Ksn:            ksn,
+		EncryptedTrack: encryptedTrack,
+	}
+	
+	resp, err := callIdempotent(c, func(ctx context.Context, client HSMServiceClient) (*DecryptP2PEResponse, error) {
+		return client.DecryptP2PE(ctx, req)
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return "", 0, 0, fmt.Errorf("%w: %s", tokenization.ErrInvalidEncryptedCard, status.Convert(err).Message())
+	}
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("HSM P2PE decryption failed: %w", err)
+	}
+	
+	fields := resp.GetFields()
+	return fields.GetPan(), int(fields.GetExpiryMonth()), int(fields.GetExpiryYear()), nil
+}
+
+// GenerateARQC computes an application cryptogram for a card number, here
+// a network token, with the HSM's terminal-side command
+func (c *Client) GenerateARQC(keyID, pan string, atc uint16, txnData []byte) ([]byte, error) {
+	req := &GenerateARQCRequest{
+		KeyId:           keyID,
+		Pan:             pan,
+		Atc:             uint32(atc),
+		TransactionData: txnData,
+	}
+	
+	resp, err := callIdempotent(c, func(ctx context.Context, client HSMServiceClient) (*GenerateARQCResponse, error) {
+		return client.GenerateARQC(ctx, req)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("HSM cryptogram generation failed: %w", err)
+	}
+	return resp.Arqc, nil
+}
+
+// VerifyARQC verifies an application cryptogram with the HSM's issuer-side
+// command, discarding the response cryptogram it also builds. A cryptogram
+// the HSM rejects is reported as tokenization.ErrInvalidCryptogram.
+func (c *Client) VerifyARQC(keyID, pan string, atc uint16, txnData, arqc []byte) error {
+	req := &GenerateEMVResponseRequest{
+		KeyId:           keyID,
+		Pan:             pan,
+		Atc:             uint32(atc),
+		TransactionData: txnData,
+		Arqc:            arqc,
+		ResponseCode:    "00",
+	}
+	
+	_, err := callIdempotent(c, func(ctx context.Context, client HSMServiceClient) (*GenerateEMVResponseResponse, error) {
+		return client.GenerateEMVResponse(ctx, req)
+	})
+	if status.Code(err) == codes.InvalidArgument {
+		return fmt.Errorf("%w: %s", tokenization.ErrInvalidCryptogram, status.Convert(err).Message())
+	}
+	if err != nil {
+		return fmt.Errorf("HSM cryptogram verification failed: %w", err)
+	}
+	return nil
+}
+
+// GenerateDataKey asks the HSM for a data key, returned in plaintext and
+// wrapped by the master key
+func (c *Client) GenerateDataKey(keyID string, aad []byte) (plaintext, wrapped, nonce []byte, keyVersion int, err error) {
+	req := &GenerateDataKeyRequest{
+		KeyId: keyID,
+		Aad:   aad,
+	}
+	
+	var resp *GenerateDataKeyResponse
+	err = c.call(func(ctx context.Context, client HSMServiceClient) (err error) {
+		resp, err = client.GenerateDataKey(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, 0, fmt.Errorf("HSM generate data key failed: %w", err)
+	}
+	
+	return resp.PlaintextKey, resp.WrappedKey, resp.Nonce, int(resp.KeyVersion), nil
+}
+
 // GenerateKey generates a new key in the HSM
 func (c *Client) GenerateKey(keyID, algorithm string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
 	req := &GenerateKeyRequest{
 		KeyId:     keyID,
 		Algorithm: algorithm,
 	}
 	
-	_, err := c.client.GenerateKey(ctx, req)
+	err := c.call(func(ctx context.Context, client HSMServiceClient) error {
+		_, err := client.GenerateKey(ctx, req)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("HSM generate key failed: %w", err)
 	}
 	
 	return nil
 }
+
+// CurrentVersion returns the version the HSM encrypts new data under
+func (c *Client) CurrentVersion(keyID string) (int, error) {
+	if v, ok := c.cachedVersion(keyID); ok {
+		return v, nil
+	}
+	req := &GetKeyInfoRequest{KeyId: keyID}
+	
+	resp, err := callIdempotent(c, func(ctx context.Context, client HSMServiceClient) (*GetKeyInfoResponse, error) {
+		return client.GetKeyInfo(ctx, req)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("HSM get key info failed: %w", err)
+	}
+	
+	return int(resp.CurrentVersion), nil
+}
+
+// RotateKey creates a new version of a key, which the HSM encrypts under
+// from then on
+func (c *Client) RotateKey(keyID string) (newVersion, oldVersion int, err error) {
+	req := &RotateKeyRequest{KeyId: keyID}
+	
+	var resp *RotateKeyResponse
+	err = c.call(func(ctx context.Context, client HSMServiceClient) (err error) {
+		resp, err = client.RotateKey(ctx, req)
+		return err
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("HSM rotate key failed: %w", err)
+	}
+	
+	return int(resp.NewVersion), int(resp.OldVersion), nil
+}
+
+// DestroyKeyVersion destroys a retired key version and returns the state of
+// the operation, which is pending while it awaits a second operator under
+// dual control
+func (c *Client) DestroyKeyVersion(keyID string, version int) (string, error) {
+	req := &DestroyKeyVersionRequest{
+		KeyId:      keyID,
+		KeyVersion: int32(version),
+	}
+	
+	var resp *DestroyKeyVersionResponse
+	err := c.call(func(ctx context.Context, client HSMServiceClient) (err error) {
+		resp, err = client.DestroyKeyVersion(ctx, req)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("HSM destroy key version failed: %w", err)
+	}
+	
+	return resp.Operation.State, nil
+}
@@ -0,0 +1,21 @@
+package loadgen
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/paymentgateway/go-common/luhn"
+)
+
+// TestPAN returns a deterministic, Luhn-valid 16-digit Visa test PAN for a
+// request sequence number, so repeated runs exercise the same card set
+func TestPAN(seq int64) string {
+	body := fmt.Sprintf("411111%09d", seq%1_000_000_000)
+	return body + string(rune('0'+luhn.CheckDigit(body)))
+}
+
+// TestExpiry returns an expiry date comfortably inside the tokenization
+// service's accepted window
+func TestExpiry() (month, year int) {
+	return 12, time.Now().Year() + 2
+}
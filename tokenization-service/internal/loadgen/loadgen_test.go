@@ -0,0 +1,230 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantErr  bool
+		duration time.Duration
+		rateAt   map[time.Duration]float64
+	}{
+		{"constant:100:10s", false, 10 * time.Second, map[time.Duration]float64{0: 100, 9 * time.Second: 100}},
+		{"ramp:0-100:10s:5s", false, 15 * time.Second, map[time.Duration]float64{0: 0, 5 * time.Second: 50, 12 * time.Second: 100}},
+		{"step:10+20x3:1s", false, 3 * time.Second, map[time.Duration]float64{0: 10, 1500 * time.Millisecond: 30, 5 * time.Second: 50}},
+		{"constant:100", true, 0, nil},
+		{"constant:-1:10s", true, 0, nil},
+		{"ramp:100:10s:5s", true, 0, nil},
+		{"step:10+20x0:1s", true, 0, nil},
+		{"burst:1:1s", true, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := ParseProfile(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidProfile) {
+					t.Errorf("error = %v, want ErrInvalidProfile", err)
+				}
+				return
+			}
+			if p.Duration() != tt.duration {
+				t.Errorf("Duration() = %v, want %v", p.Duration(), tt.duration)
+			}
+			for at, want := range tt.rateAt {
+				if got := p.RateAt(at); got != want {
+					t.Errorf("RateAt(%v) = %v, want %v", at, got, want)
+				}
+			}
+			if p.String() != tt.spec {
+				t.Errorf("String() = %q, want %q", p.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestRecorderPercentiles(t *testing.T) {
+	r := NewRecorder()
+	for i := 1; i <= 100; i++ {
+		var err error
+		if i%10 == 0 {
+			err = errors.New("boom")
+		}
+		r.Record("tokenize", time.Duration(i)*time.Millisecond, err)
+	}
+	r.RecordDropped("tokenize")
+
+	stats := r.Op("tokenize", time.Second)
+	if stats.P50 != 50*time.Millisecond || stats.P95 != 95*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Errorf("percentiles = %v/%v/%v, want 50ms/95ms/99ms", stats.P50, stats.P95, stats.P99)
+	}
+	if stats.Max != 100*time.Millisecond {
+		t.Errorf("Max = %v, want 100ms", stats.Max)
+	}
+	if stats.Errors != 10 || stats.Dropped != 1 {
+		t.Errorf("Errors/Dropped = %d/%d, want 10/1", stats.Errors, stats.Dropped)
+	}
+	if want := 11.0 / 101.0; stats.ErrorRate != want {
+		t.Errorf("ErrorRate = %v, want %v", stats.ErrorRate, want)
+	}
+	if stats.ErrorKinds["boom"] != 10 {
+		t.Errorf("ErrorKinds = %v, want boom=10", stats.ErrorKinds)
+	}
+}
+
+func TestPercentileSmallSamples(t *testing.T) {
+	samples := func(n int) []time.Duration {
+		sorted := make([]time.Duration, n)
+		for i := range sorted {
+			sorted[i] = time.Duration(i+1) * time.Millisecond
+		}
+		return sorted
+	}
+	tests := []struct {
+		n    int
+		p    float64
+		want time.Duration
+	}{
+		{0, 99, 0},
+		{1, 50, 1 * time.Millisecond},
+		{1, 99, 1 * time.Millisecond},
+		{2, 50, 1 * time.Millisecond},
+		{2, 99, 2 * time.Millisecond},
+		{3, 50, 2 * time.Millisecond},
+		{3, 40, 2 * time.Millisecond},
+		{4, 60, 3 * time.Millisecond},
+		{10, 91, 10 * time.Millisecond},
+		{10, 95, 10 * time.Millisecond},
+		{50, 50, 25 * time.Millisecond},
+		{50, 95, 48 * time.Millisecond},
+		{50, 99, 50 * time.Millisecond},
+		{100, 7, 7 * time.Millisecond},
+		{10, 0, 1 * time.Millisecond},
+		{10, 100, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(samples(tt.n), tt.p); got != tt.want {
+			t.Errorf("percentile(%d samples, %v) = %v, want %v", tt.n, tt.p, got, tt.want)
+		}
+	}
+}
+
+type fakeTarget struct {
+	name    string
+	latency time.Duration
+	fail    bool
+}
+
+func (f *fakeTarget) Name() string { return f.name }
+
+func (f *fakeTarget) Do(ctx context.Context, seq int64) error {
+	time.Sleep(f.latency)
+	if f.fail {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestRunnerOffersProfileRate(t *testing.T) {
+	runner := &Runner{
+		Targets: []Target{&fakeTarget{name: "tokenize", latency: time.Millisecond}},
+		Profile: ConstantProfile{RPS: 200, Length: 500 * time.Millisecond},
+		Probe:   &fakeTarget{name: "probe", latency: time.Millisecond},
+	}
+
+	result, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var tokenize OpStats
+	for _, op := range result.Ops {
+		if op.Op == "tokenize" {
+			tokenize = op
+		}
+	}
+	// 200 rps for 0.5s is 100 requests; allow for ticker jitter on slow CI
+	if tokenize.Count < 70 || tokenize.Count > 110 {
+		t.Errorf("issued %d requests, want about 100", tokenize.Count)
+	}
+	if tokenize.Errors != 0 {
+		t.Errorf("Errors = %d, want 0", tokenize.Errors)
+	}
+	if result.Baseline <= 0 || result.HSMSaturation() <= 0 {
+		t.Errorf("expected HSM probe baseline and saturation, got %v / %v", result.Baseline, result.HSMSaturation())
+	}
+}
+
+func TestRunnerDropsWhenSaturated(t *testing.T) {
+	runner := &Runner{
+		Targets:     []Target{&fakeTarget{name: "slow", latency: 200 * time.Millisecond}},
+		Profile:     ConstantProfile{RPS: 500, Length: 100 * time.Millisecond},
+		MaxInFlight: 5,
+	}
+
+	result, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Ops[0].Dropped == 0 {
+		t.Error("expected requests to be dropped once the in-flight limit was reached")
+	}
+	if result.Ops[0].Count > 5 {
+		t.Errorf("Count = %d, want at most MaxInFlight", result.Ops[0].Count)
+	}
+}
+
+func TestRunnerRequiresTargets(t *testing.T) {
+	runner := &Runner{Profile: ConstantProfile{RPS: 1, Length: time.Millisecond}}
+	if _, err := runner.Run(context.Background()); err != ErrNoTargets {
+		t.Errorf("Run() error = %v, want ErrNoTargets", err)
+	}
+}
+
+func TestSLOEvaluate(t *testing.T) {
+	ops := []OpStats{
+		{Op: "tokenize", P95: 600 * time.Millisecond, ErrorRate: 0.01},
+		{Op: "validate", P95: 10 * time.Millisecond},
+		{Op: ProbeOp, P95: time.Second, ErrorRate: 1},
+	}
+
+	violations := DefaultSLO.Evaluate(ops)
+	if len(violations) != 2 {
+		t.Fatalf("got %d violations, want 2: %v", len(violations), violations)
+	}
+	for _, v := range violations {
+		if v.Op != "tokenize" {
+			t.Errorf("unexpected violation for %s", v.Op)
+		}
+	}
+
+	var sb strings.Builder
+	result := &Result{Profile: "constant:1:1s", Ops: ops}
+	if err := WriteReport(&sb, result, violations); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	if !strings.Contains(sb.String(), "SLO: FAIL") {
+		t.Errorf("report missing SLO verdict:\n%s", sb.String())
+	}
+}
+
+func TestTestPAN(t *testing.T) {
+	if got := TestPAN(111111111); got != "4111111111111111" {
+		t.Errorf("TestPAN() = %s, want 4111111111111111", got)
+	}
+	if TestPAN(1) == TestPAN(2) {
+		t.Error("TestPAN should differ per sequence number")
+	}
+	if len(TestPAN(999999999999)) != 16 {
+		t.Error("TestPAN should always produce 16 digits")
+	}
+}
@@ -0,0 +1,177 @@
+package loadgen
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidProfile = errors.New("invalid load profile")
+)
+
+// Profile describes how the offered request rate evolves over a run
+type Profile interface {
+	// RateAt returns the requests per second to offer at elapsed time t
+	RateAt(t time.Duration) float64
+	// Duration returns the total length of the run
+	Duration() time.Duration
+	// String returns the profile in the same notation ParseProfile accepts
+	String() string
+}
+
+// ConstantProfile offers a fixed rate for the whole run
+type ConstantProfile struct {
+	RPS    float64
+	Length time.Duration
+}
+
+func (p ConstantProfile) RateAt(t time.Duration) float64 { return p.RPS }
+func (p ConstantProfile) Duration() time.Duration        { return p.Length }
+func (p ConstantProfile) String() string {
+	return fmt.Sprintf("constant:%g:%s", p.RPS, p.Length)
+}
+
+// RampProfile linearly ramps from StartRPS to EndRPS over RampUp, then holds
+// EndRPS for Hold
+type RampProfile struct {
+	StartRPS float64
+	EndRPS   float64
+	RampUp   time.Duration
+	Hold     time.Duration
+}
+
+func (p RampProfile) RateAt(t time.Duration) float64 {
+	if t >= p.RampUp || p.RampUp <= 0 {
+		return p.EndRPS
+	}
+	frac := float64(t) / float64(p.RampUp)
+	return p.StartRPS + (p.EndRPS-p.StartRPS)*frac
+}
+
+func (p RampProfile) Duration() time.Duration { return p.RampUp + p.Hold }
+func (p RampProfile) String() string {
+	return fmt.Sprintf("ramp:%g-%g:%s:%s", p.StartRPS, p.EndRPS, p.RampUp, p.Hold)
+}
+
+// StepProfile starts at StartRPS and adds StepRPS every StepLength, for Steps
+// steps in total. Useful for finding the knee of the latency curve.
+type StepProfile struct {
+	StartRPS   float64
+	StepRPS    float64
+	Steps      int
+	StepLength time.Duration
+}
+
+func (p StepProfile) RateAt(t time.Duration) float64 {
+	step := int(t / p.StepLength)
+	if step >= p.Steps {
+		step = p.Steps - 1
+	}
+	return p.StartRPS + float64(step)*p.StepRPS
+}
+
+func (p StepProfile) Duration() time.Duration { return time.Duration(p.Steps) * p.StepLength }
+func (p StepProfile) String() string {
+	return fmt.Sprintf("step:%g+%gx%d:%s", p.StartRPS, p.StepRPS, p.Steps, p.StepLength)
+}
+
+// ParseProfile parses a profile specification:
+//
+//	constant:<rps>:<duration>            e.g. constant:500:1m
+//	ramp:<from>-<to>:<rampup>:<hold>     e.g. ramp:10-1000:30s:2m
+//	step:<start>+<step>x<n>:<steplen>    e.g. step:100+100x10:20s
+func ParseProfile(spec string) (Profile, error) {
+	parts := strings.Split(spec, ":")
+	switch parts[0] {
+	case "constant":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%w: want constant:<rps>:<duration>", ErrInvalidProfile)
+		}
+		rps, err := parseRate(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		length, err := parseLength(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		return ConstantProfile{RPS: rps, Length: length}, nil
+
+	case "ramp":
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("%w: want ramp:<from>-<to>:<rampup>:<hold>", ErrInvalidProfile)
+		}
+		from, to, ok := strings.Cut(parts[1], "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: ramp range %q", ErrInvalidProfile, parts[1])
+		}
+		start, err := parseRate(from)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseRate(to)
+		if err != nil {
+			return nil, err
+		}
+		rampUp, err := parseLength(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		hold, err := time.ParseDuration(parts[3])
+		if err != nil || hold < 0 {
+			return nil, fmt.Errorf("%w: hold %q", ErrInvalidProfile, parts[3])
+		}
+		return RampProfile{StartRPS: start, EndRPS: end, RampUp: rampUp, Hold: hold}, nil
+
+	case "step":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%w: want step:<start>+<step>x<n>:<steplen>", ErrInvalidProfile)
+		}
+		startStr, rest, ok := strings.Cut(parts[1], "+")
+		if !ok {
+			return nil, fmt.Errorf("%w: step %q", ErrInvalidProfile, parts[1])
+		}
+		stepStr, countStr, ok := strings.Cut(rest, "x")
+		if !ok {
+			return nil, fmt.Errorf("%w: step %q", ErrInvalidProfile, parts[1])
+		}
+		start, err := parseRate(startStr)
+		if err != nil {
+			return nil, err
+		}
+		step, err := parseRate(stepStr)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := strconv.Atoi(countStr)
+		if err != nil || steps < 1 {
+			return nil, fmt.Errorf("%w: step count %q", ErrInvalidProfile, countStr)
+		}
+		length, err := parseLength(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		return StepProfile{StartRPS: start, StepRPS: step, Steps: steps, StepLength: length}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidProfile, parts[0])
+}
+
+func parseRate(s string) (float64, error) {
+	rps, err := strconv.ParseFloat(s, 64)
+	if err != nil || rps < 0 {
+		return 0, fmt.Errorf("%w: rate %q", ErrInvalidProfile, s)
+	}
+	return rps, nil
+}
+
+func parseLength(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: duration %q", ErrInvalidProfile, s)
+	}
+	return d, nil
+}
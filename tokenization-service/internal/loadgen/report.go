@@ -0,0 +1,123 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// SLO is a latency and error-rate objective applied to every operation.
+// Zero fields are not checked.
+type SLO struct {
+	P50          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+	MaxErrorRate float64
+}
+
+// DefaultSLO mirrors the gateway SLA: p95 under 500ms and 99.99% success
+var DefaultSLO = SLO{
+	P95:          500 * time.Millisecond,
+	MaxErrorRate: 0.0001,
+}
+
+// Violation describes a single SLO breach
+type Violation struct {
+	Op     string
+	Metric string
+	Limit  string
+	Actual string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s: %s exceeds %s", v.Op, v.Metric, v.Actual, v.Limit)
+}
+
+// Evaluate checks every operation except the HSM probe against the SLO
+func (s SLO) Evaluate(ops []OpStats) []Violation {
+	var violations []Violation
+	check := func(op, metric string, limit, actual time.Duration) {
+		if limit > 0 && actual > limit {
+			violations = append(violations, Violation{
+				Op: op, Metric: metric, Limit: limit.String(), Actual: actual.String(),
+			})
+		}
+	}
+
+	for _, op := range ops {
+		if op.Op == ProbeOp {
+			continue
+		}
+		check(op.Op, "p50", s.P50, op.P50)
+		check(op.Op, "p95", s.P95, op.P95)
+		check(op.Op, "p99", s.P99, op.P99)
+		if s.MaxErrorRate > 0 && op.ErrorRate > s.MaxErrorRate {
+			violations = append(violations, Violation{
+				Op:     op.Op,
+				Metric: "error rate",
+				Limit:  formatRate(s.MaxErrorRate),
+				Actual: formatRate(op.ErrorRate),
+			})
+		}
+	}
+	return violations
+}
+
+// WriteReport writes a human-readable summary of a run and its SLO outcome
+func WriteReport(w io.Writer, result *Result, violations []Violation) error {
+	fmt.Fprintf(w, "profile: %s  elapsed: %s\n\n", result.Profile, result.Elapsed.Round(time.Millisecond))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\tok/s\terrors\tdropped\terr%\tp50\tp95\tp99\tmax\t")
+	for _, op := range result.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n",
+			op.Op, op.Count, op.Throughput, op.Errors, op.Dropped, formatRate(op.ErrorRate),
+			roundLatency(op.P50), roundLatency(op.P95), roundLatency(op.P99), roundLatency(op.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, op := range result.Ops {
+		if len(op.ErrorKinds) == 0 {
+			continue
+		}
+		kinds := make([]string, 0, len(op.ErrorKinds))
+		for kind := range op.ErrorKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		fmt.Fprintf(w, "\n%s errors:\n", op.Op)
+		for _, kind := range kinds {
+			fmt.Fprintf(w, "  %6d  %s\n", op.ErrorKinds[kind], kind)
+		}
+	}
+
+	if result.Baseline > 0 {
+		fmt.Fprintf(w, "\nHSM saturation: %.2fx (probe p95 idle %s, loaded %s)\n",
+			result.HSMSaturation(), roundLatency(result.Baseline), roundLatency(result.Loaded))
+	}
+
+	if len(violations) == 0 {
+		_, err := fmt.Fprintln(w, "\nSLO: PASS")
+		return err
+	}
+	fmt.Fprintln(w, "\nSLO: FAIL")
+	for _, v := range violations {
+		fmt.Fprintf(w, "  %s\n", v)
+	}
+	return nil
+}
+
+func formatRate(r float64) string {
+	return fmt.Sprintf("%.3f%%", r*100)
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
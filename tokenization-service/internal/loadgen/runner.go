@@ -0,0 +1,169 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoTargets = errors.New("no load targets configured")
+)
+
+const (
+	// ProbeOp is the operation name under which HSM probe latencies are recorded
+	ProbeOp = "hsm-probe"
+
+	tickInterval     = 10 * time.Millisecond
+	baselineProbes   = 20
+	defaultInFlight  = 1000
+	defaultProbeTick = 250 * time.Millisecond
+)
+
+// Target issues a single request against the system under test.
+// seq is a monotonically increasing request number, useful for
+// deterministic test data.
+type Target interface {
+	Name() string
+	Do(ctx context.Context, seq int64) error
+}
+
+// Runner drives one or more targets according to a Profile.
+// Requests are issued open-loop: the offered rate does not slow down when the
+// system under test does, so latency is not hidden by coordinated omission.
+type Runner struct {
+	Targets     []Target
+	Profile     Profile
+	MaxInFlight int
+
+	// Probe, if set, is issued periodically during the run to measure how
+	// much the HSM slows down under load compared to an idle baseline.
+	Probe         Target
+	ProbeInterval time.Duration
+
+	Recorder *Recorder
+}
+
+// Result is the outcome of a load run
+type Result struct {
+	Profile  string
+	Elapsed  time.Duration
+	Ops      []OpStats
+	Baseline time.Duration // idle HSM probe p95, zero without a probe
+	Loaded   time.Duration // HSM probe p95 under load, zero without a probe
+}
+
+// HSMSaturation is the ratio of loaded to idle HSM probe latency.
+// 1.0 means the HSM is unaffected; values well above 1 mean requests are
+// queueing inside the HSM. Zero when no probe was configured.
+func (r *Result) HSMSaturation() float64 {
+	if r.Baseline <= 0 || r.Loaded <= 0 {
+		return 0
+	}
+	return float64(r.Loaded) / float64(r.Baseline)
+}
+
+// Run executes the profile and returns aggregated statistics
+func (r *Runner) Run(ctx context.Context) (*Result, error) {
+	if len(r.Targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	if r.Recorder == nil {
+		r.Recorder = NewRecorder()
+	}
+	maxInFlight := r.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = defaultInFlight
+	}
+
+	result := &Result{Profile: r.Profile.String()}
+
+	if r.Probe != nil {
+		baseline := NewRecorder()
+		for i := 0; i < baselineProbes; i++ {
+			start := time.Now()
+			err := r.Probe.Do(ctx, int64(i))
+			baseline.Record(ProbeOp, time.Since(start), err)
+		}
+		result.Baseline = baseline.Op(ProbeOp, 0).P95
+	}
+
+	// runCtx bounds issuing; requests themselves use ctx so that those still
+	// in flight when the profile ends complete instead of failing.
+	runCtx, cancel := context.WithTimeout(ctx, r.Profile.Duration())
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		seq      int64
+		inFlight = make(chan struct{}, maxInFlight)
+	)
+
+	if r.Probe != nil {
+		interval := r.ProbeInterval
+		if interval <= 0 {
+			interval = defaultProbeTick
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for n := int64(0); ; n++ {
+				select {
+				case <-runCtx.Done():
+					return
+				case <-ticker.C:
+					start := time.Now()
+					err := r.Probe.Do(ctx, n)
+					r.Recorder.Record(ProbeOp, time.Since(start), err)
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	budget := 0.0
+	last := start
+loop:
+	for {
+		select {
+		case <-runCtx.Done():
+			break loop
+		case now := <-ticker.C:
+			budget += r.Profile.RateAt(now.Sub(start)) * now.Sub(last).Seconds()
+			last = now
+			for ; budget >= 1; budget-- {
+				seq++
+				n := seq
+				target := r.Targets[int(n)%len(r.Targets)]
+				select {
+				case inFlight <- struct{}{}:
+				default:
+					r.Recorder.RecordDropped(target.Name())
+					continue
+				}
+				wg.Add(1)
+				go func(t Target, n int64) {
+					defer wg.Done()
+					defer func() { <-inFlight }()
+					reqStart := time.Now()
+					err := t.Do(ctx, n)
+					r.Recorder.Record(t.Name(), time.Since(reqStart), err)
+				}(target, n)
+			}
+		}
+	}
+
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	result.Ops = r.Recorder.Stats(result.Elapsed)
+	if r.Probe != nil {
+		result.Loaded = r.Recorder.Op(ProbeOp, result.Elapsed).P95
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package loadgen
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OpStats summarizes the latency and outcome of one operation over a run
+type OpStats struct {
+	Op         string
+	Count      int
+	Errors     int
+	Dropped    int
+	ErrorRate  float64
+	Throughput float64
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	ErrorKinds map[string]int
+}
+
+// Recorder collects per-operation latency samples and errors.
+// It is safe for concurrent use.
+type Recorder struct {
+	samples    map[string][]time.Duration
+	errors     map[string]int
+	dropped    map[string]int
+	errorKinds map[string]map[string]int
+	mu         sync.Mutex
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		samples:    make(map[string][]time.Duration),
+		errors:     make(map[string]int),
+		dropped:    make(map[string]int),
+		errorKinds: make(map[string]map[string]int),
+	}
+}
+
+// Record stores the outcome of a single request
+func (r *Recorder) Record(op string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.samples[op] = append(r.samples[op], latency)
+	if err != nil {
+		r.errors[op]++
+		kinds, ok := r.errorKinds[op]
+		if !ok {
+			kinds = make(map[string]int)
+			r.errorKinds[op] = kinds
+		}
+		kinds[errorKind(err)]++
+	}
+}
+
+// RecordDropped counts a request that was due but could not be issued because
+// the in-flight limit was reached. Dropped requests count as errors so that
+// client-side saturation never hides server-side latency.
+func (r *Recorder) RecordDropped(op string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dropped[op]++
+}
+
+// Stats returns a summary for every recorded operation, sorted by name.
+// elapsed is used to compute throughput.
+func (r *Recorder) Stats(elapsed time.Duration) []OpStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ops := make(map[string]struct{})
+	for op := range r.samples {
+		ops[op] = struct{}{}
+	}
+	for op := range r.dropped {
+		ops[op] = struct{}{}
+	}
+
+	result := make([]OpStats, 0, len(ops))
+	for op := range ops {
+		result = append(result, r.opStats(op, elapsed))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Op < result[j].Op })
+	return result
+}
+
+// Op returns the summary for a single operation
+func (r *Recorder) Op(op string, elapsed time.Duration) OpStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.opStats(op, elapsed)
+}
+
+func (r *Recorder) opStats(op string, elapsed time.Duration) OpStats {
+	sorted := make([]time.Duration, len(r.samples[op]))
+	copy(sorted, r.samples[op])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	stats := OpStats{
+		Op:         op,
+		Count:      len(sorted),
+		Errors:     r.errors[op],
+		Dropped:    r.dropped[op],
+		P50:        percentile(sorted, 50),
+		P95:        percentile(sorted, 95),
+		P99:        percentile(sorted, 99),
+		ErrorKinds: make(map[string]int, len(r.errorKinds[op])),
+	}
+	for kind, n := range r.errorKinds[op] {
+		stats.ErrorKinds[kind] = n
+	}
+	if len(sorted) > 0 {
+		stats.Max = sorted[len(sorted)-1]
+	}
+	if attempted := stats.Count + stats.Dropped; attempted > 0 {
+		stats.ErrorRate = float64(stats.Errors+stats.Dropped) / float64(attempted)
+	}
+	if elapsed > 0 {
+		stats.Throughput = float64(stats.Count-stats.Errors) / elapsed.Seconds()
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice: the
+// smallest sample with at least p percent of the samples at or below it
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// p*n/100 rather than p/100*n, which can land just above a whole rank
+	rank := int(math.Ceil(p*float64(len(sorted))/100)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// errorKind reduces an error to a short, low-cardinality label
+func errorKind(err error) string {
+	if k, ok := err.(interface{ Kind() string }); ok {
+		return k.Kind()
+	}
+	msg := err.Error()
+	if len(msg) > 60 {
+		msg = msg[:60]
+	}
+	return msg
+}
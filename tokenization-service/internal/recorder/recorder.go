@@ -0,0 +1,125 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Entry is one recorded RPC. Request and Response hold the protojson encoding
+// of the messages after redaction.
+type Entry struct {
+	Seq          int64           `json:"seq"`
+	Time         time.Time       `json:"time"`
+	Method       string          `json:"method"`
+	RequestType  string          `json:"request_type"`
+	ResponseType string          `json:"response_type,omitempty"`
+	Request      json.RawMessage `json:"request"`
+	Response     json.RawMessage `json:"response,omitempty"`
+	Code         string          `json:"code"`
+	Error        string          `json:"error,omitempty"`
+	DurationMS   float64         `json:"duration_ms"`
+}
+
+// Recorder writes every unary RPC it observes to a JSON-lines log that the
+// replay tool can re-issue against another build
+type Recorder struct {
+	w        io.Writer
+	redactor *Redactor
+	seq      int64
+	mu       sync.Mutex
+}
+
+// NewRecorder creates a recorder writing to w
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	redactor, err := NewRedactor()
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, redactor: redactor}, nil
+}
+
+var marshaler = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// UnaryServerInterceptor records each call after the handler returns.
+// Recording failures never affect the call itself.
+func (r *Recorder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		r.record(info.FullMethod, start, req, resp, err)
+		return resp, err
+	}
+}
+
+func (r *Recorder) record(method string, start time.Time, req, resp interface{}, callErr error) {
+	entry := Entry{
+		Time:       start.UTC(),
+		Method:     method,
+		Code:       status.Code(callErr).String(),
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if callErr != nil {
+		entry.Error = status.Convert(callErr).Message()
+	}
+
+	reqMsg, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	entry.RequestType = string(reqMsg.ProtoReflect().Descriptor().FullName())
+	body, err := r.encode(reqMsg)
+	if err != nil {
+		return
+	}
+	entry.Request = body
+
+	if respMsg, ok := resp.(proto.Message); ok && callErr == nil {
+		entry.ResponseType = string(respMsg.ProtoReflect().Descriptor().FullName())
+		body, err := r.encode(respMsg)
+		if err != nil {
+			return
+		}
+		entry.Response = body
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	entry.Seq = r.seq
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	r.w.Write(append(line, '\n'))
+}
+
+func (r *Recorder) encode(msg proto.Message) (json.RawMessage, error) {
+	raw, err := marshaler.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return r.redactor.RedactJSON(raw)
+}
+
+// ReadLog parses a recorded session
+func ReadLog(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	dec := json.NewDecoder(r)
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
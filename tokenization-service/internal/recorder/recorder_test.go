@@ -0,0 +1,160 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/paymentgateway/go-common/luhn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSurrogatePAN(t *testing.T) {
+	r, err := NewRedactor()
+	if err != nil {
+		t.Fatalf("NewRedactor() error = %v", err)
+	}
+
+	pans := []string{"4532015112830366", "378282246310005", "6011000000000004", "4111111111111111110"}
+	for _, pan := range pans {
+		s := r.surrogatePAN(pan)
+		if s == pan {
+			t.Errorf("surrogate for %s equals the PAN", pan)
+		}
+		if len(s) != len(pan) || s[:6] != pan[:6] || s[len(s)-4:] != pan[len(pan)-4:] {
+			t.Errorf("surrogate %s does not preserve length, BIN and last four of %s", s, pan)
+		}
+		if !luhn.Valid(s) {
+			t.Errorf("surrogate %s is not Luhn-valid", s)
+		}
+		if r.surrogatePAN(pan) != s {
+			t.Errorf("surrogate for %s is not stable", pan)
+		}
+	}
+
+	invalid := "4532015112830367"
+	if s := r.surrogatePAN(invalid); luhn.Valid(s) {
+		t.Errorf("surrogate %s for Luhn-invalid PAN should stay invalid", s)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	r, _ := NewRedactor()
+	doc := []byte(`{"pan":"4532015112830366","cvv":"123","token":"9123456789010366","note":"card 5425233430109903","items":["5425233430109903"]}`)
+
+	out, err := r.RedactJSON(doc)
+	if err != nil {
+		t.Fatalf("RedactJSON() error = %v", err)
+	}
+	if bytes.Contains(out, []byte("4532015112830366")) || bytes.Contains(out, []byte(`"5425233430109903"`)) {
+		t.Errorf("PAN leaked into redacted output: %s", out)
+	}
+	if !bytes.Contains(out, []byte(`"cvv":"000"`)) {
+		t.Errorf("CVV not redacted: %s", out)
+	}
+	if !bytes.Contains(out, []byte(`"token":"9123456789010366"`)) {
+		t.Errorf("token should be left untouched: %s", out)
+	}
+}
+
+func TestInterceptorRecords(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	interceptor := rec.UnaryServerInterceptor()
+
+	req, _ := structpb.NewStruct(map[string]interface{}{"pan": "4532015112830366"})
+	info := &grpc.UnaryServerInfo{FullMethod: "/tokenization.TokenizationService/TokenizeCard"}
+
+	_, err = interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return structpb.NewStruct(map[string]interface{}{"token": "9123456789010366"})
+	})
+	if err != nil {
+		t.Fatalf("interceptor returned error = %v", err)
+	}
+	_, err = interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.InvalidArgument, "invalid PAN format")
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("interceptor should pass handler errors through, got %v", err)
+	}
+
+	if strings.Contains(buf.String(), "4532015112830366") {
+		t.Fatalf("log contains a clear PAN:\n%s", buf.String())
+	}
+
+	entries, err := ReadLog(&buf)
+	if err != nil {
+		t.Fatalf("ReadLog() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Seq != 1 || entries[0].Code != "OK" || entries[0].RequestType != "google.protobuf.Struct" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Code != "InvalidArgument" || entries[1].Error != "invalid PAN format" || len(entries[1].Response) != 0 {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+// scriptedInvoker answers replayed requests from a fixed script and records
+// what it was sent
+type scriptedInvoker struct {
+	responses []string
+	codes     []string
+	sent      []string
+}
+
+func (s *scriptedInvoker) Invoke(ctx context.Context, method, requestType string, request json.RawMessage) (json.RawMessage, string, error) {
+	i := len(s.sent)
+	s.sent = append(s.sent, string(request))
+	return json.RawMessage(s.responses[i]), s.codes[i], nil
+}
+
+func TestReplayMapsTokensAndDiffs(t *testing.T) {
+	entries := []Entry{
+		{Seq: 1, Method: "/t/Tokenize", Request: json.RawMessage(`{"pan":"4111"}`),
+			Response: json.RawMessage(`{"token":"9000000000000001","lastFour":"1111","expiresAt":"1"}`), Code: "OK"},
+		{Seq: 2, Method: "/t/Detokenize", Request: json.RawMessage(`{"token":"9000000000000001"}`),
+			Response: json.RawMessage(`{"pan":"4111","expiryMonth":12}`), Code: "OK"},
+		{Seq: 3, Method: "/t/Validate", Request: json.RawMessage(`{"token":"bad"}`), Code: "InvalidArgument"},
+	}
+	invoker := &scriptedInvoker{
+		responses: []string{
+			`{"token":"9555555555555555","lastFour":"1111","expiresAt":"2"}`,
+			`{"pan":"4111","expiryMonth":11}`,
+			``,
+		},
+		codes: []string{"OK", "OK", "OK"},
+	}
+
+	replayer := &Replayer{Invoker: invoker}
+	report, err := replayer.Replay(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if invoker.sent[1] != `{"token":"9555555555555555"}` {
+		t.Errorf("recorded token not rewritten in request: %s", invoker.sent[1])
+	}
+	if report.Total != 3 || report.Matched != 1 {
+		t.Errorf("Total/Matched = %d/%d, want 3/1", report.Total, report.Matched)
+	}
+	if len(report.Diffs) != 2 {
+		t.Fatalf("got %d diffs, want 2: %v", len(report.Diffs), report.Diffs)
+	}
+	if report.Diffs[0].Path != "expiryMonth" || report.Diffs[0].Recorded != "12" {
+		t.Errorf("unexpected diff: %v", report.Diffs[0])
+	}
+	if report.Diffs[1].Path != "(status)" || report.Diffs[1].Recorded != "InvalidArgument" {
+		t.Errorf("unexpected diff: %v", report.Diffs[1])
+	}
+}
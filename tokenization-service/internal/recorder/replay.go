@@ -0,0 +1,281 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Invoker re-issues a recorded request and returns the protojson-encoded
+// response and the gRPC status code name
+type Invoker interface {
+	Invoke(ctx context.Context, method, requestType string, request json.RawMessage) (response json.RawMessage, code string, err error)
+}
+
+// Diff is a single divergence between a recorded and a replayed response
+type Diff struct {
+	Seq      int64
+	Method   string
+	Path     string
+	Recorded string
+	Replayed string
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("#%d %s %s: recorded %s, replayed %s", d.Seq, d.Method, d.Path, d.Recorded, d.Replayed)
+}
+
+// Report summarizes a replay
+type Report struct {
+	Total   int
+	Matched int
+	Diffs   []Diff
+}
+
+// DefaultIgnoreFields are response fields that legitimately differ between runs
+var DefaultIgnoreFields = []string{"expiresAt"}
+
+// DefaultTokenFields are response fields holding tokens. Tokens are random, so
+// instead of being compared they are learned: the first time a recorded token
+// is seen its replayed counterpart is remembered, later requests that mention
+// the recorded token are rewritten to use the new one, and later responses
+// must agree with the mapping.
+var DefaultTokenFields = []string{"token"}
+
+// Replayer re-issues a recorded session and diffs the responses
+type Replayer struct {
+	Invoker      Invoker
+	IgnoreFields []string
+	TokenFields  []string
+
+	tokens map[string]string
+}
+
+// Replay re-issues entries in order and compares every response
+func (r *Replayer) Replay(ctx context.Context, entries []Entry) (*Report, error) {
+	if r.IgnoreFields == nil {
+		r.IgnoreFields = DefaultIgnoreFields
+	}
+	if r.TokenFields == nil {
+		r.TokenFields = DefaultTokenFields
+	}
+	r.tokens = make(map[string]string)
+
+	report := &Report{}
+	for _, entry := range entries {
+		req, err := r.rewriteRequest(entry.Request)
+		if err != nil {
+			return report, fmt.Errorf("entry %d: %w", entry.Seq, err)
+		}
+
+		resp, code, err := r.Invoker.Invoke(ctx, entry.Method, entry.RequestType, req)
+		if err != nil {
+			return report, fmt.Errorf("entry %d: %w", entry.Seq, err)
+		}
+
+		report.Total++
+		diffs := r.compare(entry, resp, code)
+		if len(diffs) == 0 {
+			report.Matched++
+		}
+		report.Diffs = append(report.Diffs, diffs...)
+	}
+	return report, nil
+}
+
+func (r *Replayer) rewriteRequest(req json.RawMessage) (json.RawMessage, error) {
+	if len(r.tokens) == 0 || len(req) == 0 {
+		return req, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(req, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.substitute(v))
+}
+
+func (r *Replayer) substitute(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = r.substitute(child)
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = r.substitute(child)
+		}
+	case string:
+		if mapped, ok := r.tokens[val]; ok {
+			return mapped
+		}
+	}
+	return v
+}
+
+func (r *Replayer) compare(entry Entry, resp json.RawMessage, code string) []Diff {
+	var diffs []Diff
+	if code != entry.Code {
+		diffs = append(diffs, Diff{
+			Seq: entry.Seq, Method: entry.Method, Path: "(status)",
+			Recorded: entry.Code, Replayed: code,
+		})
+		return diffs
+	}
+	if len(entry.Response) == 0 && len(resp) == 0 {
+		return nil
+	}
+
+	var recorded, replayed interface{}
+	if err := json.Unmarshal(entry.Response, &recorded); err != nil {
+		recorded = string(entry.Response)
+	}
+	if err := json.Unmarshal(resp, &replayed); err != nil {
+		replayed = string(resp)
+	}
+
+	r.diffValue(entry, "", "", recorded, replayed, &diffs)
+	return diffs
+}
+
+func (r *Replayer) diffValue(entry Entry, path, field string, recorded, replayed interface{}, diffs *[]Diff) {
+	for _, f := range r.IgnoreFields {
+		if f == field {
+			return
+		}
+	}
+
+	add := func() {
+		*diffs = append(*diffs, Diff{
+			Seq: entry.Seq, Method: entry.Method, Path: strings.TrimPrefix(path, "."),
+			Recorded: render(recorded), Replayed: render(replayed),
+		})
+	}
+
+	switch rec := recorded.(type) {
+	case map[string]interface{}:
+		rep, ok := replayed.(map[string]interface{})
+		if !ok {
+			add()
+			return
+		}
+		keys := make(map[string]struct{}, len(rec)+len(rep))
+		for k := range rec {
+			keys[k] = struct{}{}
+		}
+		for k := range rep {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			r.diffValue(entry, path+"."+k, k, rec[k], rep[k], diffs)
+		}
+
+	case []interface{}:
+		rep, ok := replayed.([]interface{})
+		if !ok || len(rep) != len(rec) {
+			add()
+			return
+		}
+		for i := range rec {
+			r.diffValue(entry, fmt.Sprintf("%s[%d]", path, i), field, rec[i], rep[i], diffs)
+		}
+
+	case string:
+		rep, ok := replayed.(string)
+		if ok && r.isTokenField(field) && rec != "" {
+			if mapped, known := r.tokens[rec]; known {
+				if mapped != rep {
+					add()
+				}
+				return
+			}
+			r.tokens[rec] = rep
+			return
+		}
+		if !ok || rec != rep {
+			add()
+		}
+
+	default:
+		if render(recorded) != render(replayed) {
+			add()
+		}
+	}
+}
+
+func (r *Replayer) isTokenField(field string) bool {
+	for _, f := range r.TokenFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func render(v interface{}) string {
+	if v == nil {
+		return "<absent>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// GRPCInvoker replays requests over a gRPC connection. Message types are
+// resolved from the protobuf registry, so the binary must link the generated
+// code of every service in the recording.
+type GRPCInvoker struct {
+	Conn *grpc.ClientConn
+}
+
+func (g *GRPCInvoker) Invoke(ctx context.Context, method, requestType string, request json.RawMessage) (json.RawMessage, string, error) {
+	reqType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(requestType))
+	if err != nil {
+		return nil, "", fmt.Errorf("unknown request type %s: %w", requestType, err)
+	}
+
+	// "/pkg.Service/Method" -> "pkg.Service.Method"
+	methodName := protoreflect.FullName(strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1))
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(methodName)
+	if err != nil {
+		return nil, "", fmt.Errorf("unknown method %s: %w", method, err)
+	}
+	methodDesc, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, "", fmt.Errorf("%s is not a method", method)
+	}
+	respType, err := protoregistry.GlobalTypes.FindMessageByName(methodDesc.Output().FullName())
+	if err != nil {
+		return nil, "", err
+	}
+
+	req := reqType.New().Interface()
+	if err := protojson.Unmarshal(request, req); err != nil {
+		return nil, "", fmt.Errorf("decode request: %w", err)
+	}
+	resp := respType.New().Interface()
+
+	if callErr := g.Conn.Invoke(ctx, method, req, resp); callErr != nil {
+		return nil, status.Code(callErr).String(), nil
+	}
+
+	body, err := marshaler.Marshal(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, "OK", nil
+}
@@ -0,0 +1,167 @@
+// Package replica keeps a read-only copy of a primary's vault in sync from
+// the primary's change feed, so token validation and lookups can be served
+// without contending with tokenization writes.
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default reconnect backoff after the stream from the primary fails
+const (
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// ErrSnapshotIncomplete is returned when a stream ends before its snapshot
+var ErrSnapshotIncomplete = errors.New("stream ended before the snapshot was complete")
+
+// Change is one message of the primary's change stream: an encoded change
+// record, or the marker that ends the initial snapshot
+type Change struct {
+	Record           []byte
+	SnapshotComplete bool
+}
+
+// Stream is an open change stream from the primary
+type Stream interface {
+	Recv() (Change, error)
+}
+
+// Source opens a change stream from the primary. The stream starts with a
+// snapshot of the vault.
+type Source func(ctx context.Context) (Stream, error)
+
+// Vault is the replica's copy of the vault
+type Vault interface {
+	ResetVault()
+	ApplyChange(record []byte) error
+}
+
+// Config configures a Follower
+type Config struct {
+	// MinBackoff and MaxBackoff bound the wait before reconnecting, which
+	// doubles after each failed attempt
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Status is a point-in-time view of a Follower
+type Status struct {
+	// Ready is set once a snapshot has been loaded, and cleared while
+	// resyncing after the stream is lost
+	Ready bool `json:"ready"`
+	// Syncs counts the snapshots loaded
+	Syncs int `json:"syncs"`
+	// Applied counts the change records applied since the last snapshot
+	Applied    int64     `json:"applied"`
+	LastChange time.Time `json:"last_change"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Follower applies a primary's change stream to a replica vault
+type Follower struct {
+	vault  Vault
+	source Source
+	config Config
+	mu     sync.Mutex
+	status Status
+}
+
+// NewFollower creates a follower that syncs vault from source
+func NewFollower(vault Vault, source Source, cfg Config) *Follower {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Follower{vault: vault, source: source, config: cfg}
+}
+
+// Status returns the follower's current status
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// Ready reports whether the replica holds a complete copy of the vault
+func (f *Follower) Ready() bool {
+	return f.Status().Ready
+}
+
+// Run follows the primary until ctx is done, resyncing from a fresh snapshot
+// whenever the stream is lost
+func (f *Follower) Run(ctx context.Context) {
+	backoff := f.config.MinBackoff
+	for ctx.Err() == nil {
+		synced, err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		f.mu.Lock()
+		f.status.Ready = false
+		if err != nil {
+			f.status.LastError = err.Error()
+		}
+		f.mu.Unlock()
+
+		if synced {
+			backoff = f.config.MinBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if !synced {
+			backoff = min(2*backoff, f.config.MaxBackoff)
+		}
+	}
+}
+
+// follow runs one stream: it loads the snapshot, then applies changes until
+// the stream fails. It reports whether the snapshot was loaded.
+func (f *Follower) follow(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := f.source(ctx)
+	if err != nil {
+		return false, fmt.Errorf("open change stream: %w", err)
+	}
+	f.vault.ResetVault()
+	synced := false
+	for {
+		change, err := stream.Recv()
+		if err != nil {
+			if !synced {
+				return false, fmt.Errorf("%w: %v", ErrSnapshotIncomplete, err)
+			}
+			return true, fmt.Errorf("change stream: %w", err)
+		}
+		if change.SnapshotComplete {
+			synced = true
+			f.mu.Lock()
+			f.status.Ready = true
+			f.status.Syncs++
+			f.status.Applied = 0
+			f.status.LastChange = time.Now()
+			f.mu.Unlock()
+			continue
+		}
+		if err := f.vault.ApplyChange(change.Record); err != nil {
+			return synced, err
+		}
+		if synced {
+			f.mu.Lock()
+			f.status.Applied++
+			f.status.LastChange = time.Now()
+			f.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// feedStream streams a primary's snapshot, then its changes, as the
+// StreamChanges RPC does
+type feedStream struct {
+	snapshot [][]byte
+	sub      *tokenization.Subscription
+	ctx      context.Context
+	marked   bool
+}
+
+func (s *feedStream) Recv() (Change, error) {
+	if len(s.snapshot) > 0 {
+		record := s.snapshot[0]
+		s.snapshot = s.snapshot[1:]
+		return Change{Record: record}, nil
+	}
+	if !s.marked {
+		s.marked = true
+		return Change{SnapshotComplete: true}, nil
+	}
+	select {
+	case <-s.ctx.Done():
+		return Change{}, s.ctx.Err()
+	case record, ok := <-s.sub.Changes():
+		if !ok {
+			return Change{}, io.EOF
+		}
+		return Change{Record: record}, nil
+	}
+}
+
+func feedSource(primary *tokenization.Service, opened *atomic.Int32) Source {
+	return func(ctx context.Context) (Stream, error) {
+		snapshot, sub, err := primary.SubscribeChanges()
+		if err != nil {
+			return nil, err
+		}
+		opened.Add(1)
+		go func() {
+			<-ctx.Done()
+			sub.Close()
+		}()
+		return &feedStream{snapshot: snapshot, sub: sub, ctx: ctx}, nil
+	}
+}
+
+// echoHSM stores a copy of the plaintext as ciphertext
+type echoHSM struct{}
+
+func (echoHSM) Encrypt(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+	return append([]byte(nil), plaintext...), []byte("nonce"), 1, nil
+}
+
+func (echoHSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
+	return append([]byte(nil), ciphertext...), nil
+}
+
+func newService(opts ...tokenization.Option) *tokenization.Service {
+	return tokenization.NewService(echoHSM{}, "test-key", 24*time.Hour, opts...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollowerSyncsFromPrimary(t *testing.T) {
+	primary := newService(tokenization.WithChangeFeed(tokenization.NewChangeFeed(0)))
+	year := time.Now().Year() + 2
+	before, _ := primary.TokenizeCardWithOptions("4532015112830366", 12, year, "123", tokenization.TokenizeOptions{MerchantID: "m1"})
+
+	replicaVault := newService()
+	var opened atomic.Int32
+	follower := NewFollower(replicaVault, feedSource(primary, &opened), Config{MinBackoff: time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go follower.Run(ctx)
+
+	waitFor(t, "the snapshot", follower.Ready)
+	if valid, _ := replicaVault.ValidateTokenForMerchant(before.Token, "m1"); !valid {
+		t.Error("token from the snapshot is not valid on the replica")
+	}
+
+	after, _ := primary.TokenizeCardWithOptions("5425233430109903", 12, year, "123", tokenization.TokenizeOptions{MerchantID: "m1"})
+	primary.RevokeTokenForMerchant(before.Token, "m1", tokenization.AnyRevision)
+	waitFor(t, "the changes", func() bool { return follower.Status().Applied == 2 })
+	if valid, _ := replicaVault.ValidateTokenForMerchant(after.Token, "m1"); !valid {
+		t.Error("token issued after the snapshot is not valid on the replica")
+	}
+	if valid, _ := replicaVault.ValidateTokenForMerchant(before.Token, "m1"); valid {
+		t.Error("token revoked on the primary is still valid on the replica")
+	}
+	if info, err := replicaVault.TokenInfo(before.Token, "m1"); err != nil || info.Revision != 2 {
+		t.Errorf("replica TokenInfo() = %+v, %v, want revision 2", info, err)
+	}
+}
+
+func TestFollowerResyncsAfterFallingBehind(t *testing.T) {
+	primary := newService(tokenization.WithChangeFeed(tokenization.NewChangeFeed(1)))
+	year := time.Now().Year() + 2
+
+	// The snapshot is only read once the stream is open, so changes made
+	// meanwhile overflow the one-record buffer
+	replicaVault := newService()
+	var opened atomic.Int32
+	source := feedSource(primary, &opened)
+	gate := make(chan struct{})
+	follower := NewFollower(replicaVault, func(ctx context.Context) (Stream, error) {
+		stream, err := source(ctx)
+		if opened.Load() == 1 {
+			<-gate
+		}
+		return stream, err
+	}, Config{MinBackoff: time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go follower.Run(ctx)
+
+	waitFor(t, "the first stream", func() bool { return opened.Load() == 1 })
+	var tokens []string
+	for _, pan := range []string{"4532015112830366", "5425233430109903", "371449635398431"} {
+		tokenData, _ := primary.TokenizeCardWithOptions(pan, 12, year, "123", tokenization.TokenizeOptions{MerchantID: "m1"})
+		tokens = append(tokens, tokenData.Token)
+	}
+	close(gate)
+
+	waitFor(t, "a resync", func() bool { return follower.Status().Syncs >= 2 && follower.Ready() })
+	for _, token := range tokens {
+		if valid, _ := replicaVault.ValidateTokenForMerchant(token, "m1"); !valid {
+			t.Errorf("token %s is not valid on the replica after resync", token)
+		}
+	}
+}
+
+func TestFollowerBacksOffWhilePrimaryIsDown(t *testing.T) {
+	var attempts atomic.Int32
+	down := errors.New("connection refused")
+	follower := NewFollower(newService(), func(ctx context.Context) (Stream, error) {
+		attempts.Add(1)
+		return nil, down
+	}, Config{MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	follower.Run(ctx)
+
+	// 10, 20, 40, 40 ms: five attempts fit, not fifteen
+	if n := attempts.Load(); n < 2 || n > 7 {
+		t.Errorf("attempts = %d, want backoff between them", n)
+	}
+	if status := follower.Status(); status.Ready || status.LastError == "" {
+		t.Errorf("Status() = %+v, want not ready with the last error", status)
+	}
+}
@@ -0,0 +1,138 @@
+// Package retention enforces the data-retention policy: revoked and expired
+// tokens are purged from the vault after a grace period, soft-deleted tokens
+// once they can no longer be restored, and audit records after their grace
+// period unless they are under legal hold.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// Principal is the identity purges are audited under
+const Principal = "retention-policy"
+
+// Policy configures how long data is kept. A zero retention disables that
+// purge.
+type Policy struct {
+	// TokenRetention is how long revoked or expired tokens are kept
+	TokenRetention time.Duration
+	// RestoreWindow is how long soft-deleted tokens can be restored before
+	// they are purged
+	RestoreWindow time.Duration
+	// AuditRetention is how long audit records are kept
+	AuditRetention time.Duration
+	// LegalHold keeps audit records regardless of their age
+	LegalHold audit.LegalHold
+}
+
+// Result reports what a purge deleted
+type Result struct {
+	Tokens       int
+	AuditRecords int
+}
+
+// Purger applies a policy to the token vault and audit trail
+type Purger struct {
+	service *tokenization.Service
+	auditor *audit.Auditor
+	now     func() time.Time
+
+	mu     sync.Mutex
+	policy Policy
+}
+
+// NewPurger creates a purger. A nil auditor skips audit purges and leaves
+// token purges unaudited.
+func NewPurger(service *tokenization.Service, auditor *audit.Auditor, policy Policy) *Purger {
+	return &Purger{
+		service: service,
+		auditor: auditor,
+		policy:  policy,
+		now:     time.Now,
+	}
+}
+
+// Policy returns the policy in force
+func (p *Purger) Policy() Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.policy
+}
+
+// SetPolicy replaces the policy from the next purge on
+func (p *Purger) SetPolicy(policy Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+}
+
+// PurgeOnce applies the policy once. Each purged token is audited before
+// old audit records are purged, so the newest evidence is never the first
+// to go.
+func (p *Purger) PurgeOnce(ctx context.Context) (Result, error) {
+	var res Result
+	now := p.now()
+	policy := p.Policy()
+	ctx = interceptors.WithPrincipal(ctx, &interceptors.Principal{ID: Principal})
+
+	if policy.TokenRetention > 0 {
+		for _, token := range p.service.PurgeTokens(now.Add(-policy.TokenRetention)) {
+			if p.auditor != nil {
+				if err := p.auditor.Record(ctx, audit.OpPurge, "", token, now, nil); err != nil {
+					return res, fmt.Errorf("audit token purge: %w", err)
+				}
+			}
+			res.Tokens++
+		}
+	}
+
+	if policy.RestoreWindow > 0 {
+		for _, token := range p.service.PurgeDeletedTokens(now.Add(-policy.RestoreWindow)) {
+			if p.auditor != nil {
+				if err := p.auditor.Record(ctx, audit.OpPurge, "", token, now, nil); err != nil {
+					return res, fmt.Errorf("audit deleted token purge: %w", err)
+				}
+			}
+			res.Tokens++
+		}
+	}
+
+	if policy.AuditRetention > 0 && p.auditor != nil {
+		n, err := p.auditor.Purge(now.Add(-policy.AuditRetention), policy.LegalHold)
+		if err != nil {
+			return res, fmt.Errorf("purge audit records: %w", err)
+		}
+		res.AuditRecords = n
+	}
+	return res, nil
+}
+
+// Run applies the policy every interval until the context is cancelled
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := p.PurgeOnce(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Retention purge failed: %v", err)
+		case res.Tokens > 0 || res.AuditRecords > 0:
+			log.Printf("Retention purge deleted %d tokens and %d audit records", res.Tokens, res.AuditRecords)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
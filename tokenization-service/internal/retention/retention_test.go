@@ -0,0 +1,98 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// echoHSM stores a copy of the plaintext as ciphertext
+type echoHSM struct{}
+
+func (echoHSM) Encrypt(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+	return append([]byte(nil), plaintext...), []byte("nonce"), 1, nil
+}
+
+func (echoHSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
+	return append([]byte(nil), ciphertext...), nil
+}
+
+func TestPurgeOnce(t *testing.T) {
+	service := tokenization.NewService(echoHSM{}, "test-key", 365*24*time.Hour)
+	store := audit.NewMemoryStore()
+	auditor := audit.NewAuditor(store)
+	year := time.Now().Year() + 2
+
+	kept, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+	revoked, _ := service.TokenizeCard("5425233430109903", 12, year, "123")
+	service.RevokeToken(revoked.Token)
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	store.Append(audit.Record{Time: old, Operation: audit.OpTokenize, MerchantID: "m1"})
+	store.Append(audit.Record{Time: old, Operation: audit.OpTokenize, MerchantID: "held"})
+
+	purger := NewPurger(service, auditor, Policy{
+		TokenRetention: 30 * 24 * time.Hour,
+		AuditRetention: 30 * 24 * time.Hour,
+		LegalHold:      audit.ParseLegalHold("held"),
+	})
+
+	// Within the retention period nothing is purged except old audit records
+	res, err := purger.PurgeOnce(context.Background())
+	if err != nil || res.Tokens != 0 || res.AuditRecords != 1 {
+		t.Fatalf("PurgeOnce() = %+v, %v; want 0 tokens, 1 audit record", res, err)
+	}
+
+	// A month later the revoked token goes; the audit record of its purge is
+	// written at the real time and stays under a longer audit retention
+	purger.now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	purger.policy.AuditRetention = 90 * 24 * time.Hour
+	res, err = purger.PurgeOnce(context.Background())
+	if err != nil || res.Tokens != 1 {
+		t.Fatalf("PurgeOnce() = %+v, %v; want 1 token", res, err)
+	}
+	if err := service.RevokeToken(revoked.Token); err != tokenization.ErrTokenNotFound {
+		t.Errorf("revoked token still stored: error = %v", err)
+	}
+	if err := service.RevokeToken(kept.Token); err != nil {
+		t.Errorf("active token purged: error = %v", err)
+	}
+
+	records, _ := auditor.Query(audit.Filter{Operation: audit.OpPurge})
+	if len(records) != 1 || records[0].Token != revoked.Token || records[0].Principal != Principal {
+		t.Errorf("purge audit records = %+v", records)
+	}
+	if held, _ := auditor.Query(audit.Filter{MerchantID: "held"}); len(held) != 1 {
+		t.Errorf("held records = %d, want 1", len(held))
+	}
+}
+
+func TestPurgeOnceDeletedTokens(t *testing.T) {
+	service := tokenization.NewService(echoHSM{}, "test-key", 365*24*time.Hour)
+	auditor := audit.NewAuditor(audit.NewMemoryStore())
+	year := time.Now().Year() + 2
+
+	deleted, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", tokenization.TokenizeOptions{MerchantID: "m1"})
+	if _, err := service.DeleteToken(deleted.Token, "m1", tokenization.AnyRevision); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+
+	purger := NewPurger(service, auditor, Policy{RestoreWindow: 7 * 24 * time.Hour})
+	if res, err := purger.PurgeOnce(context.Background()); err != nil || res.Tokens != 0 {
+		t.Fatalf("PurgeOnce() within restore window = %+v, %v; want 0 tokens", res, err)
+	}
+
+	purger.now = func() time.Time { return time.Now().Add(8 * 24 * time.Hour) }
+	if res, err := purger.PurgeOnce(context.Background()); err != nil || res.Tokens != 1 {
+		t.Fatalf("PurgeOnce() after restore window = %+v, %v; want 1 token", res, err)
+	}
+	if _, err := service.RestoreToken(deleted.Token, "m1", tokenization.AnyRevision); err != tokenization.ErrTokenNotFound {
+		t.Errorf("RestoreToken() after purge error = %v, want %v", err, tokenization.ErrTokenNotFound)
+	}
+	if records, _ := auditor.Query(audit.Filter{Operation: audit.OpPurge}); len(records) != 1 || records[0].Token != deleted.Token {
+		t.Errorf("purge audit records = %+v", records)
+	}
+}
@@ -0,0 +1,287 @@
+// Package rotation orchestrates rotating the HSM key the vault is encrypted
+// under: it rotates the key to a new version, re-encrypts every ciphertext
+// under an older version in batches, and retires the old versions only once
+// nothing in the vault needs them.
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+var (
+	ErrInProgress = errors.New("a key rotation is already in progress")
+	ErrNotFound   = errors.New("no key rotation has run")
+)
+
+// KeyManager rotates and retires versions of an HSM key
+type KeyManager interface {
+	// CurrentVersion returns the version new ciphertexts are encrypted under
+	CurrentVersion(keyID string) (int, error)
+	RotateKey(keyID string) (newVersion, oldVersion int, err error)
+	// DestroyKeyVersion destroys a version, or queues its destruction for
+	// approval under dual control, and returns the operation's state
+	DestroyKeyVersion(keyID string, version int) (state string, err error)
+}
+
+// State is the phase a rotation is in
+type State string
+
+const (
+	StateMigrating State = "migrating"
+	StateRetiring  State = "retiring"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+)
+
+// Defaults used when Config leaves fields zero
+const (
+	DefaultBatchSize = 500
+	DefaultPause     = 50 * time.Millisecond
+)
+
+// Config paces re-encryption. Zero fields take the defaults.
+type Config struct {
+	// BatchSize is the number of ciphertexts re-encrypted between pauses
+	BatchSize int
+	// Pause is the wait between batches, which leaves HSM capacity for
+	// tokenization traffic
+	Pause time.Duration
+}
+
+// RetiredVersion is an old key version and what became of it
+type RetiredVersion struct {
+	Version int
+	// State is the HSM's operation state: executed, or pending while it
+	// awaits a second operator's approval. Empty until retirement.
+	State string
+}
+
+// Status is the progress of a rotation
+type Status struct {
+	ID    string
+	KeyID string
+	State State
+	// NewVersion is the version ciphertexts are moved to
+	NewVersion int
+	// Retiring are the older versions that held ciphertexts when the
+	// rotation started, and the version it rotated away from
+	Retiring []RetiredVersion
+	// Total is the number of ciphertexts to re-encrypt, counted at the
+	// start; ciphertexts written under an old version while the rotation
+	// runs are migrated too, so Migrated may exceed it
+	Total     int
+	Migrated  int
+	Remaining int
+	Error     string
+	StartedAt time.Time
+	UpdatedAt time.Time
+	// FinishedAt is set once the rotation completed or failed
+	FinishedAt time.Time
+}
+
+// Done reports whether the rotation has finished, successfully or not
+func (st Status) Done() bool {
+	return st.State == StateCompleted || st.State == StateFailed
+}
+
+// clone copies a status the orchestrator may still update
+func (st *Status) clone() Status {
+	c := *st
+	c.Retiring = append([]RetiredVersion(nil), st.Retiring...)
+	return c
+}
+
+// Orchestrator runs one key rotation at a time and keeps the status of the
+// latest
+type Orchestrator struct {
+	service *tokenization.Service
+	keys    KeyManager
+	keyID   string
+	config  Config
+	now     func() time.Time
+
+	mu     sync.Mutex
+	status *Status
+	seq    int
+}
+
+// NewOrchestrator creates an orchestrator for the key the service encrypts
+// under
+func NewOrchestrator(service *tokenization.Service, keys KeyManager, keyID string, cfg Config) *Orchestrator {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Pause <= 0 {
+		cfg.Pause = DefaultPause
+	}
+	return &Orchestrator{
+		service: service,
+		keys:    keys,
+		keyID:   keyID,
+		config:  cfg,
+		now:     time.Now,
+	}
+}
+
+// Start rotates the key and re-encrypts the vault in the background. With
+// resume it does not rotate again but moves everything to the current
+// version and retires the older ones, to finish a rotation that failed or
+// was interrupted by a restart. The rotation outlives ctx; it stops early
+// only if the migration fails.
+func (o *Orchestrator) Start(ctx context.Context, resume bool) (Status, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.status != nil && !o.status.Done() {
+		return o.status.clone(), ErrInProgress
+	}
+
+	var newVersion, oldVersion int
+	var err error
+	if resume {
+		newVersion, err = o.keys.CurrentVersion(o.keyID)
+	} else {
+		newVersion, oldVersion, err = o.keys.RotateKey(o.keyID)
+	}
+	if err != nil {
+		return Status{}, fmt.Errorf("rotate key %s: %w", o.keyID, err)
+	}
+
+	retiring := map[int]bool{}
+	if oldVersion > 0 {
+		retiring[oldVersion] = true
+	}
+	total := 0
+	for v, n := range o.service.KeyVersionUsage() {
+		if v < newVersion {
+			retiring[v] = true
+			total += n
+		}
+	}
+	versions := make([]int, 0, len(retiring))
+	for v := range retiring {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	o.seq++
+	now := o.now()
+	st := &Status{
+		ID:         fmt.Sprintf("rotation-%d-%d", now.Unix(), o.seq),
+		KeyID:      o.keyID,
+		State:      StateMigrating,
+		NewVersion: newVersion,
+		Total:      total,
+		Remaining:  total,
+		StartedAt:  now,
+		UpdatedAt:  now,
+	}
+	for _, v := range versions {
+		st.Retiring = append(st.Retiring, RetiredVersion{Version: v})
+	}
+	o.status = st
+	log.Printf("Key rotation %s: moving %d ciphertexts of %s to version %d", st.ID, total, o.keyID, newVersion)
+
+	go o.run(context.WithoutCancel(ctx), st.ID)
+	return st.clone(), nil
+}
+
+// Status returns the status of the latest rotation
+func (o *Orchestrator) Status() (Status, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.status == nil {
+		return Status{}, ErrNotFound
+	}
+	return o.status.clone(), nil
+}
+
+// Wait blocks until the latest rotation finishes or ctx is done, polling
+// every interval, and returns its status
+func (o *Orchestrator) Wait(ctx context.Context, interval time.Duration) (Status, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		st, err := o.Status()
+		if err != nil || st.Done() {
+			return st, err
+		}
+		select {
+		case <-ctx.Done():
+			return st, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// run migrates the vault, then retires the old versions. A failure leaves
+// every old version in place, so nothing becomes undecryptable.
+func (o *Orchestrator) run(ctx context.Context, id string) {
+	newVersion := o.snapshot().NewVersion
+	for {
+		res, err := o.service.ReencryptBefore(newVersion, o.config.BatchSize)
+		o.update(func(st *Status) {
+			st.Migrated += res.Tokens + res.DataKeys
+			if err == nil {
+				st.Remaining = res.Remaining
+			}
+		})
+		if err != nil {
+			o.fail(id, fmt.Errorf("re-encrypt: %w", err))
+			return
+		}
+		if res.Remaining == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			o.fail(id, ctx.Err())
+			return
+		case <-time.After(o.config.Pause):
+		}
+	}
+
+	o.update(func(st *Status) { st.State = StateRetiring })
+	for i, rv := range o.snapshot().Retiring {
+		state, err := o.keys.DestroyKeyVersion(o.keyID, rv.Version)
+		if err != nil {
+			o.fail(id, fmt.Errorf("retire version %d: %w", rv.Version, err))
+			return
+		}
+		o.update(func(st *Status) { st.Retiring[i].State = state })
+	}
+
+	o.update(func(st *Status) {
+		st.State = StateCompleted
+		st.FinishedAt = st.UpdatedAt
+	})
+	log.Printf("Key rotation %s completed", id)
+}
+
+func (o *Orchestrator) snapshot() Status {
+	st, _ := o.Status()
+	return st
+}
+
+func (o *Orchestrator) update(fn func(*Status)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.status.UpdatedAt = o.now()
+	fn(o.status)
+}
+
+func (o *Orchestrator) fail(id string, err error) {
+	log.Printf("Key rotation %s failed: %v", id, err)
+	o.update(func(st *Status) {
+		st.State = StateFailed
+		st.Error = err.Error()
+		st.FinishedAt = st.UpdatedAt
+	})
+}
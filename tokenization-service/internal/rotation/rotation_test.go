@@ -0,0 +1,201 @@
+package rotation
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// versionedHSM keeps an AES-256-GCM key per version, like the HSM, so a
+// ciphertext left under a destroyed version cannot be decrypted
+type versionedHSM struct {
+	mu          sync.Mutex
+	versions    map[int][]byte
+	current     int
+	failDecrypt bool
+}
+
+func newVersionedHSM() *versionedHSM {
+	h := &versionedHSM{versions: map[int][]byte{}}
+	h.RotateKey("test-key")
+	return h
+}
+
+func (h *versionedHSM) gcm(version int) (cipher.AEAD, error) {
+	key, ok := h.versions[version]
+	if !ok {
+		return nil, errors.New("key version not found")
+	}
+	block, _ := aes.NewCipher(key)
+	return cipher.NewGCM(block)
+}
+
+func (h *versionedHSM) Encrypt(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	gcm, err := h.gcm(h.current)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return gcm.Seal(nil, nonce, plaintext, aad), nonce, h.current, nil
+}
+
+func (h *versionedHSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failDecrypt {
+		return nil, errors.New("HSM unavailable")
+	}
+	gcm, err := h.gcm(keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func (h *versionedHSM) CurrentVersion(keyID string) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.current, nil
+}
+
+func (h *versionedHSM) RotateKey(keyID string) (int, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := make([]byte, 32)
+	rand.Read(key)
+	old := h.current
+	h.current++
+	h.versions[h.current] = key
+	return h.current, old, nil
+}
+
+func (h *versionedHSM) DestroyKeyVersion(keyID string, version int) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if version == h.current {
+		return "", errors.New("cannot destroy the current version")
+	}
+	delete(h.versions, version)
+	return "executed", nil
+}
+
+func (h *versionedHSM) has(version int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.versions[version]
+	return ok
+}
+
+var pans = []string{"4532015112830366", "5425233430109903", "4111111111111111", "378282246310005"}
+
+func TestRotationMigratesAndRetires(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []tokenization.Option
+	}{
+		{"direct", nil},
+		{"token data keys", []tokenization.Option{tokenization.WithKeyScope(tokenization.KeyScopeToken)}},
+		{"envelope", []tokenization.Option{tokenization.WithEnvelope(tokenization.EnvelopeConfig{})}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hsm := newVersionedHSM()
+			service := tokenization.NewService(hsm, "test-key", 24*time.Hour, tt.opts...)
+			year := time.Now().Year() + 2
+			var tokens []string
+			for _, pan := range pans {
+				td, err := service.TokenizeCard(pan, 12, year, "123")
+				if err != nil {
+					t.Fatalf("TokenizeCard() error = %v", err)
+				}
+				tokens = append(tokens, td.Token)
+			}
+			revoked := tokens[0]
+			service.RevokeToken(revoked)
+
+			o := NewOrchestrator(service, hsm, "test-key", Config{BatchSize: 1, Pause: time.Millisecond})
+			st, err := o.Start(context.Background(), false)
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if st.NewVersion != 2 || len(st.Retiring) != 1 || st.Retiring[0].Version != 1 || st.Total == 0 {
+				t.Fatalf("Start() = %+v; want version 2 retiring 1", st)
+			}
+			if _, err := o.Start(context.Background(), false); !errors.Is(err, ErrInProgress) {
+				t.Errorf("second Start() error = %v, want %v", err, ErrInProgress)
+			}
+
+			st, err = o.Wait(context.Background(), time.Millisecond)
+			if err != nil || st.State != StateCompleted {
+				t.Fatalf("Wait() = %+v, %v; want completed", st, err)
+			}
+			if st.Migrated != st.Total || st.Remaining != 0 || st.Retiring[0].State != "executed" {
+				t.Errorf("status = %+v; want everything migrated and version 1 retired", st)
+			}
+			if hsm.has(1) {
+				t.Error("version 1 was not destroyed")
+			}
+			if usage := service.KeyVersionUsage(); usage[1] != 0 {
+				t.Errorf("KeyVersionUsage() = %v; want nothing under version 1", usage)
+			}
+			for i, token := range tokens[1:] {
+				if pan, _, _, err := service.DetokenizeCard(token); err != nil || pan != pans[i+1] {
+					t.Errorf("DetokenizeCard() after rotation = %q, %v; want %q", pan, err, pans[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestRotationFailureKeepsOldVersion(t *testing.T) {
+	hsm := newVersionedHSM()
+	service := tokenization.NewService(hsm, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	td, _ := service.TokenizeCard(pans[0], 12, year, "123")
+
+	hsm.failDecrypt = true
+	o := NewOrchestrator(service, hsm, "test-key", Config{})
+	if _, err := o.Start(context.Background(), false); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	st, _ := o.Wait(context.Background(), time.Millisecond)
+	if st.State != StateFailed || st.Error == "" || st.Retiring[0].State != "" {
+		t.Fatalf("status = %+v; want failed without retiring", st)
+	}
+	if !hsm.has(1) {
+		t.Fatal("version 1 was destroyed after a failed migration")
+	}
+
+	// Resuming moves to the current version without rotating again
+	hsm.mu.Lock()
+	hsm.failDecrypt = false
+	hsm.mu.Unlock()
+	st, err := o.Start(context.Background(), true)
+	if err != nil || st.NewVersion != 2 {
+		t.Fatalf("resume Start() = %+v, %v; want version 2", st, err)
+	}
+	st, _ = o.Wait(context.Background(), time.Millisecond)
+	if st.State != StateCompleted || hsm.has(1) {
+		t.Fatalf("resumed status = %+v; want completed with version 1 retired", st)
+	}
+	if pan, _, _, err := service.DetokenizeCard(td.Token); err != nil || pan != pans[0] {
+		t.Errorf("DetokenizeCard() = %q, %v", pan, err)
+	}
+}
+
+func TestStatusBeforeRotation(t *testing.T) {
+	hsm := newVersionedHSM()
+	o := NewOrchestrator(tokenization.NewService(hsm, "test-key", time.Hour), hsm, "test-key", Config{})
+	if _, err := o.Status(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Status() error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -2,80 +2,104 @@ package server
 
 import (
 	"context"
-	"fmt"
-	"log"
 
+	"github.com/paymentgateway/go-common/buildinfo"
+	"github.com/paymentgateway/tokenization-service/internal/serverv2"
 	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+	"google.golang.org/grpc/status"
 )
 
 // Server implements the TokenizationService gRPC server
 type Server struct {
 	UnimplementedTokenizationServiceServer
 	service *tokenization.Service
+	v2      *serverv2.Server
 }
 
-// NewServer creates a new gRPC server
-func NewServer(service *tokenization.Service) *Server {
+// NewServer creates a new gRPC server that forwards to the v2 server
+func NewServer(service *tokenization.Service, v2 *serverv2.Server) *Server {
 	return &Server{
 		service: service,
+		v2:      v2,
 	}
 }
 
+// The v1 API is a translation shim over v2: requests are forwarded with no
+// merchant scope, no metadata and the default TTL, and the v2-only response
+// fields are dropped.
+
 // TokenizeCard tokenizes a card PAN
 func (s *Server) TokenizeCard(ctx context.Context, req *TokenizeRequest) (*TokenizeResponse, error) {
-	log.Printf("TokenizeCard request: last4=%s, expiry=%d/%d", 
-		req.Pan[len(req.Pan)-4:], req.ExpiryMonth, req.ExpiryYear)
-	
-	tokenData, err := s.service.TokenizeCard(
-		req.Pan,
-		int(req.ExpiryMonth),
-		int(req.ExpiryYear),
-		req.Cvv,
-	)
+	resp, err := s.v2.TokenizeCard(ctx, &serverv2.TokenizeRequest{
+		Pan:         req.Pan,
+		ExpiryMonth: req.ExpiryMonth,
+		ExpiryYear:  req.ExpiryYear,
+		Cvv:         req.Cvv,
+	})
 	if err != nil {
-		log.Printf("TokenizeCard error: %v", err)
-		return nil, fmt.Errorf("tokenization failed: %w", err)
+		return nil, v1Error("tokenization failed", err)
 	}
 	
 	return &TokenizeResponse{
-		Token:     tokenData.Token,
-		LastFour:  tokenData.LastFour,
-		CardBrand: tokenData.CardBrand,
-		ExpiresAt: tokenData.ExpiresAt.Unix(),
+		Token:     resp.Token,
+		LastFour:  resp.LastFour,
+		CardBrand: resp.CardBrand,
+		ExpiresAt: resp.ExpiresAt,
 	}, nil
 }
 
 // DetokenizeCard retrieves the original PAN from a token
 func (s *Server) DetokenizeCard(ctx context.Context, req *DetokenizeRequest) (*DetokenizeResponse, error) {
-	log.Printf("DetokenizeCard request: token=%s", req.Token)
-	
-	pan, expiryMonth, expiryYear, err := s.service.DetokenizeCard(req.Token)
+	resp, err := s.v2.DetokenizeCard(ctx, &serverv2.DetokenizeRequest{
+		Token: req.Token,
+	})
 	if err != nil {
-		log.Printf("DetokenizeCard error: %v", err)
-		return nil, fmt.Errorf("detokenization failed: %w", err)
+		return nil, v1Error("detokenization failed", err)
 	}
 	
 	return &DetokenizeResponse{
-		Pan:         pan,
-		ExpiryMonth: int32(expiryMonth),
-		ExpiryYear:  int32(expiryYear),
+		Pan:         resp.Pan,
+		ExpiryMonth: resp.ExpiryMonth,
+		ExpiryYear:  resp.ExpiryYear,
 	}, nil
 }
 
 // ValidateToken validates a token
 func (s *Server) ValidateToken(ctx context.Context, req *ValidateRequest) (*ValidateResponse, error) {
-	log.Printf("ValidateToken request: token=%s", req.Token)
-	
-	valid, err := s.service.ValidateToken(req.Token)
+	resp, err := s.v2.ValidateToken(ctx, &serverv2.ValidateRequest{
+		Token: req.Token,
+	})
 	if err != nil {
-		return &ValidateResponse{
-			Valid:        false,
-			ErrorMessage: err.Error(),
-		}, nil
+		return nil, err
 	}
 	
 	return &ValidateResponse{
-		Valid:        valid,
-		ErrorMessage: "",
+		Valid:        resp.Valid,
+		ErrorMessage: resp.ErrorMessage,
+	}, nil
+}
+
+// v1Error keeps the v1 message prefix on the status returned by v2
+func v1Error(prefix string, err error) error {
+	st := status.Convert(err)
+	return status.Errorf(st.Code(), "%s: %s", prefix, st.Message())
+}
+
+// GetServiceInfo describes this build and its capabilities
+func (s *Server) GetServiceInfo(ctx context.Context, req *GetServiceInfoRequest) (*GetServiceInfoResponse, error) {
+	build := buildinfo.Get()
+	return &GetServiceInfoResponse{
+		Service:    "tokenization-service",
+		Version:    build.Version,
+		Commit:     build.Commit,
+		BuildDate:  build.Date,
+		Algorithms: []string{"AES-256-GCM"},
+		Features:   []string{"format-preserving-tokens", "luhn-validation", "pan-deduplication"},
+		Limits: map[string]int64{
+			"pan_min_length":         13,
+			"pan_max_length":         19,
+			"token_ttl_seconds":      int64(s.service.TokenTTL().Seconds()),
+			"expiry_max_years_ahead": 10,
+		},
 	}, nil
 }
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"time"
+
+	"github.com/paymentgateway/go-common/validate"
+)
+
+// Field rules for v1 requests, enforced by the validation interceptor.
+// ValidateRequest has none: reporting a malformed token is its job.
+
+func (r *TokenizeRequest) Validate(v *validate.Violations) {
+	validate.PAN(v, "pan", r.Pan)
+	validate.Expiry(v, "expiry_month", "expiry_year", int(r.ExpiryMonth), int(r.ExpiryYear), time.Now())
+	validate.CVV(v, "cvv", r.Cvv)
+}
+
+func (r *DetokenizeRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+}
@@ -0,0 +1,179 @@
+package serverv2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/cluster"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// forwardedHeader marks a call forwarded by another node. It is served where
+// it lands, so nodes that disagree on the membership cannot forward a call
+// in circles.
+const forwardedHeader = "x-tokenization-forwarded"
+
+// clusterRouting is a node's view of the cluster
+type clusterRouting struct {
+	ring  *cluster.Ring
+	self  string
+	peers map[string]TokenizationServiceClient
+}
+
+// UseCluster makes the server one node of a cluster partitioned by ring.
+// Calls for tokens, and tokenizations of cards, owned by another node are
+// forwarded to it through its client in peers. Issue only owned tokens by
+// creating the service with tokenization.WithTokenOwnership(OwnedBy(ring,
+// self)).
+func (s *Server) UseCluster(ring *cluster.Ring, self string, peers map[string]TokenizationServiceClient) {
+	s.cluster = &clusterRouting{ring: ring, self: self, peers: peers}
+}
+
+// OwnedBy reports whether node owns a token
+func OwnedBy(ring *cluster.Ring, node string) func(token string) bool {
+	return func(token string) bool { return ring.Owner(token) == node }
+}
+
+// peerFor returns the client of the node owning key, and the context to call
+// it with, which carries the caller's credentials. The client is nil when
+// this node serves the call.
+func (s *Server) peerFor(ctx context.Context, key string) (TokenizationServiceClient, context.Context) {
+	if s.cluster == nil || key == "" {
+		return nil, ctx
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get(forwardedHeader)) > 0 {
+		return nil, ctx
+	}
+	owner := s.cluster.ring.Owner(key)
+	peer, ok := s.cluster.peers[owner]
+	if owner == s.cluster.self || !ok {
+		return nil, ctx
+	}
+	return peer, forwardContext(ctx, md)
+}
+
+// forwardContext carries the incoming metadata, credentials included, to a
+// call to another node
+func forwardContext(ctx context.Context, md metadata.MD) context.Context {
+	md = md.Copy()
+	md.Set(forwardedHeader, "true")
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// cardKey is the ring key of a card, so every token of a card, whatever the
+// merchant, lives on one node and deduplication and card events stay local.
+// An invalid PAN has no key and is rejected by whichever node receives it.
+func cardKey(pan string) string {
+	fingerprint, err := tokenization.Fingerprint(pan)
+	if err != nil {
+		return ""
+	}
+	return fingerprint
+}
+
+// owner returns the node a token belongs to, or "" for this node
+func (c *clusterRouting) owner(token string) string {
+	if node := c.ring.Owner(token); node != c.self {
+		return node
+	}
+	return ""
+}
+
+// forwardBatch detokenizes the batch's tokens owned by other nodes on those
+// nodes, one sub-batch per node, and returns their items by position. A node
+// that cannot be reached fails its items.
+func (s *Server) forwardBatch(ctx context.Context, req *DetokenizeBatchRequest) map[int]*DetokenizeBatchItem {
+	byPeer := make(map[string][]int)
+	for i, token := range req.Tokens {
+		if peer, _ := s.peerFor(ctx, token); peer != nil {
+			node := s.cluster.ring.Owner(token)
+			byPeer[node] = append(byPeer[node], i)
+		}
+	}
+	items := make(map[int]*DetokenizeBatchItem)
+	for node, positions := range byPeer {
+		sub := &DetokenizeBatchRequest{
+			MerchantId:    req.MerchantId,
+			Reference:     req.Reference,
+			Justification: req.Justification,
+			Use:           req.Use,
+		}
+		for _, i := range positions {
+			sub.Tokens = append(sub.Tokens, req.Tokens[i])
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		resp, err := s.cluster.peers[node].DetokenizeBatch(forwardContext(ctx, md), sub)
+		for j, i := range positions {
+			if err != nil || j >= len(resp.Items) {
+				st := status.Convert(err)
+				if err == nil {
+					st = status.New(codes.Internal, "short reply from owning node")
+				}
+				items[i] = &DetokenizeBatchItem{Token: req.Tokens[i], ErrorCode: st.Code().String(), ErrorMessage: st.Message()}
+				continue
+			}
+			items[i] = resp.Items[j]
+		}
+	}
+	return items
+}
+
+// RebalanceTokens hands the vault and network tokens this node no longer
+// owns to their owners, then drops them. Run it on every node after the
+// membership changes. Changes to a moving token made during the handoff are
+// lost, so rebalance while traffic is quiet.
+func (s *Server) RebalanceTokens(ctx context.Context, req *RebalanceTokensRequest) (*RebalanceTokensResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+	if s.cluster == nil {
+		return nil, status.Error(codes.FailedPrecondition, "server is not part of a cluster")
+	}
+
+	groups, err := s.service.HandoffRecords(s.cluster.owner)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	resp := &RebalanceTokensResponse{TokensByNode: make(map[string]int32)}
+	for node, records := range groups {
+		start := time.Now()
+		peer, ok := s.cluster.peers[node]
+		if !ok {
+			return resp, status.Errorf(codes.FailedPrecondition, "no client for node %s", node)
+		}
+		if _, err := peer.ImportTokens(forwardContext(ctx, md), &ImportTokensRequest{Records: records}); err != nil {
+			s.auditDetail(ctx, audit.OpHandoff, "", "", "to node "+node, start, err)
+			return resp, status.Errorf(codes.Unavailable, "hand off to %s: %v", node, err)
+		}
+		tokens, networkTokens := s.service.DropHandedOff(s.cluster.owner, node)
+		s.auditDetail(ctx, audit.OpHandoff, "", "", fmt.Sprintf("to node %s: %d tokens, %d network tokens", node, tokens, networkTokens), start, nil)
+		resp.TokensMoved += int32(tokens)
+		resp.NetworkTokensMoved += int32(networkTokens)
+		resp.TokensByNode[node] = int32(tokens)
+	}
+	return resp, nil
+}
+
+// ImportTokens applies the records another node handed off in a rebalance
+func (s *Server) ImportTokens(ctx context.Context, req *ImportTokensRequest) (*ImportTokensResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	for i, record := range req.Records {
+		if err := s.service.ApplyChange(record); err != nil {
+			s.auditDetail(ctx, audit.OpImport, "", "", fmt.Sprintf("record %d of %d", i+1, len(req.Records)), start, err)
+			return nil, status.Errorf(codes.InvalidArgument, "record %d: %v", i+1, err)
+		}
+	}
+	s.auditDetail(ctx, audit.OpImport, "", "", fmt.Sprintf("%d records", len(req.Records)), start, nil)
+	return &ImportTokensResponse{RecordsApplied: int32(len(req.Records))}, nil
+}
@@ -0,0 +1,155 @@
+package serverv2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// environmentHeader names the sandbox a call is made in. Calls without it,
+// or with "live", are live.
+const environmentHeader = "x-environment"
+
+// sandboxMethods are the calls served in a sandbox: those scoped to one
+// merchant. Calls over the whole vault, such as card events, forgetting a
+// card or key rotation, would reach into other environments.
+var sandboxMethods = map[string]bool{
+	"/tokenization.v2.TokenizationService/TokenizeCard":            true,
+	"/tokenization.v2.TokenizationService/TokenizeEncryptedCard":   true,
+	"/tokenization.v2.TokenizationService/DetokenizeCard":          true,
+	"/tokenization.v2.TokenizationService/DetokenizeBatch":         true,
+	"/tokenization.v2.TokenizationService/ValidateToken":           true,
+	"/tokenization.v2.TokenizationService/RevokeToken":             true,
+	"/tokenization.v2.TokenizationService/DeleteToken":             true,
+	"/tokenization.v2.TokenizationService/RestoreToken":            true,
+	"/tokenization.v2.TokenizationService/UpdateTokenMetadata":     true,
+	"/tokenization.v2.TokenizationService/UpdateTokenDomain":       true,
+	"/tokenization.v2.TokenizationService/ListTokens":              true,
+	"/tokenization.v2.TokenizationService/ShredTokens":             true,
+	"/tokenization.v2.TokenizationService/ProvisionNetworkToken":   true,
+	"/tokenization.v2.TokenizationService/ExchangeToken":           true,
+	"/tokenization.v2.TokenizationService/GenerateTokenCryptogram": true,
+	"/tokenization.v2.TokenizationService/ValidateTokenCryptogram": true,
+	"/tokenization.v2.TokenizationService/GetServiceInfo":          true,
+}
+
+// Environments scopes the merchant IDs of every tokenization call to the
+// call's environment with tokenization.MerchantScope, and strips the scope
+// from the merchant IDs of the reply, so a sandbox's tokens are never found
+// from live or from another sandbox. A live call's merchant IDs may not name
+// a sandbox scope. A call forwarded by another node was scoped there, and
+// its merchant IDs are only checked to lie in its environment. Calls that
+// are not scoped to a merchant, v1 calls among them, are refused in a
+// sandbox with FailedPrecondition.
+func Environments() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, "/tokenization.") {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		environment := tokenization.LiveEnvironment
+		if values := md.Get(environmentHeader); len(values) > 0 && values[0] != "live" {
+			environment = values[0]
+		}
+		if environment != tokenization.LiveEnvironment {
+			if !sandboxMethods[info.FullMethod] {
+				return nil, status.Errorf(codes.FailedPrecondition, "%s is not served in a sandbox", info.FullMethod)
+			}
+			// Shredding by card would reach every environment's tokens
+			if shred, ok := req.(*ShredTokensRequest); ok && shred.MerchantId == "" {
+				return nil, status.Error(codes.FailedPrecondition, "only a merchant's tokens can be shredded in a sandbox")
+			}
+		}
+
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		forwarded := len(md.Get(forwardedHeader)) > 0
+		scope := func(merchantID string) (string, error) {
+			return tokenization.MerchantScope(environment, merchantID)
+		}
+		if forwarded {
+			scope = func(merchantID string) (string, error) {
+				if in, _ := tokenization.SplitMerchantScope(merchantID); in != environment {
+					return "", fmt.Errorf("%w: %q is outside environment %q", tokenization.ErrEnvironment, merchantID, environment)
+				}
+				return merchantID, nil
+			}
+		}
+		if err := rewriteMerchantIDs(msg.ProtoReflect(), true, scope); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		resp, err := handler(ctx, req)
+		if reply, ok := resp.(proto.Message); ok && err == nil && !forwarded && environment != tokenization.LiveEnvironment {
+			rewriteMerchantIDs(reply.ProtoReflect(), false, func(scope string) (string, error) {
+				_, merchantID := tokenization.SplitMerchantScope(scope)
+				return merchantID, nil
+			})
+		}
+		return resp, err
+	}
+}
+
+// rewriteMerchantIDs replaces the merchant IDs of m and its nested messages
+// with what rewrite returns for them. An empty merchant ID of the request
+// itself stands for the unscoped tokens and is rewritten when top is set; an
+// empty one of a nested message, such as a TokenUse, means the token's own
+// merchant and is left alone.
+func rewriteMerchantIDs(m protoreflect.Message, top bool, rewrite func(string) (string, error)) error {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.IsMap():
+			continue
+		case fd.Kind() == protoreflect.MessageKind:
+			if !m.Has(fd) {
+				continue
+			}
+			if fd.IsList() {
+				list := m.Get(fd).List()
+				for j := 0; j < list.Len(); j++ {
+					if err := rewriteMerchantIDs(list.Get(j).Message(), false, rewrite); err != nil {
+						return err
+					}
+				}
+			} else if err := rewriteMerchantIDs(m.Get(fd).Message(), false, rewrite); err != nil {
+				return err
+			}
+		case fd.Kind() != protoreflect.StringKind:
+			continue
+		case fd.Name() == "merchant_ids" && fd.IsList():
+			if !m.Has(fd) {
+				continue
+			}
+			list := m.Mutable(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				merchantID, err := rewrite(list.Get(j).String())
+				if err != nil {
+					return err
+				}
+				list.Set(j, protoreflect.ValueOfString(merchantID))
+			}
+		case fd.Name() == "merchant_id" && !fd.IsList():
+			if !top && !m.Has(fd) {
+				continue
+			}
+			merchantID, err := rewrite(m.Get(fd).String())
+			if err != nil {
+				return err
+			}
+			m.Set(fd, protoreflect.ValueOfString(merchantID))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package serverv2
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// readOnlyMethods are the tokenization calls a read replica serves. They
+// only read the vault, and none needs the HSM.
+var readOnlyMethods = map[string]bool{
+	"/tokenization.v1.TokenizationService/ValidateToken":  true,
+	"/tokenization.v1.TokenizationService/GetServiceInfo": true,
+	"/tokenization.v2.TokenizationService/ValidateToken":  true,
+	"/tokenization.v2.TokenizationService/ListTokens":     true,
+	"/tokenization.v2.TokenizationService/GetVaultStats":  true,
+	"/tokenization.v2.TokenizationService/GetServiceInfo": true,
+}
+
+// UseReplica marks the server as a read-only replica; ready reports whether
+// it holds a complete copy of the primary's vault. Install ReadOnly to
+// enforce it.
+func (s *Server) UseReplica(ready func() bool) {
+	s.replicaReady = ready
+}
+
+// ReadOnly refuses the tokenization calls a read replica does not serve with
+// FailedPrecondition, so writes go to the primary. Until the replica has
+// synced, the calls it serves are refused with Unavailable, which clients
+// may retry against the primary. Calls to other services pass through.
+func (s *Server) ReadOnly() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, "/tokenization.") || s.replicaReady == nil {
+			return handler(ctx, req)
+		}
+		if !readOnlyMethods[info.FullMethod] {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s is not served by a read-only replica", info.FullMethod)
+		}
+		if !strings.HasSuffix(info.FullMethod, "/GetServiceInfo") && !s.replicaReady() {
+			return nil, status.Error(codes.Unavailable, "replica is syncing from the primary")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,952 @@
+package serverv2
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/buildinfo"
+	"github.com/paymentgateway/go-common/interceptors"
+	"github.com/paymentgateway/go-common/pagination"
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/retention"
+	"github.com/paymentgateway/tokenization-service/internal/rotation"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the tokenization.v2 TokenizationService gRPC server. It is
+// also the implementation behind the v1 API, which translates its requests
+// into v2 ones.
+type Server struct {
+	UnimplementedTokenizationServiceServer
+	service *tokenization.Service
+	auditor *audit.Auditor
+	purger  *retention.Purger
+	// replicaReady is set on a read-only replica and reports whether it
+	// holds a complete copy of the primary's vault
+	replicaReady func() bool
+	// cluster is set on a node of a cluster
+	cluster *clusterRouting
+	// rotation is set when the server can rotate the vault's key
+	rotation *rotation.Orchestrator
+}
+
+// Roles checked by the v2 server when authentication is enabled
+const (
+	// AuditorRole may query the audit trail
+	AuditorRole = "auditor"
+	// AdminRole may erase and crypto-shred card data
+	AdminRole = "admin"
+	// BulkDetokenizeRole may detokenize tokens in batches
+	BulkDetokenizeRole = "bulk-detokenize"
+	// ReplicaRole may stream the vault's changes to a read replica
+	ReplicaRole = "replica"
+)
+
+// auditLimits bound the page size of ListAuditRecords
+var auditLimits = pagination.Limits{Default: audit.DefaultLimit, Max: audit.MaxLimit}
+
+// NewServer creates a new v2 gRPC server. Every token operation is recorded
+// by the auditor; a nil auditor disables the audit trail.
+func NewServer(service *tokenization.Service, auditor *audit.Auditor) *Server {
+	return &Server{
+		service: service,
+		auditor: auditor,
+	}
+}
+
+// UseRetention reports the purger's restore window for deleted tokens. Without
+// it, deleted tokens are reported as restorable indefinitely.
+func (s *Server) UseRetention(purger *retention.Purger) {
+	s.purger = purger
+}
+
+// UseKeyRotation enables the key rotation API. Without it, or on a
+// read-only replica, rotations are refused.
+func (s *Server) UseKeyRotation(o *rotation.Orchestrator) {
+	s.rotation = o
+}
+
+// restoreWindow is how long a deleted token can be restored; zero means
+// until further notice
+func (s *Server) restoreWindow() time.Duration {
+	if s.purger == nil {
+		return 0
+	}
+	return s.purger.Policy().RestoreWindow
+}
+
+// TokenizeCard tokenizes a card PAN within the request's merchant scope
+func (s *Server) TokenizeCard(ctx context.Context, req *TokenizeRequest) (resp *TokenizeResponse, err error) {
+	if peer, ctx := s.peerFor(ctx, cardKey(req.Pan)); peer != nil {
+		return peer.TokenizeCard(ctx, req)
+	}
+	start := time.Now()
+	defer func() {
+		var token string
+		if resp != nil {
+			token = resp.Token
+		}
+		s.audit(ctx, audit.OpTokenize, req.MerchantId, token, start, err)
+	}()
+
+	if req.TtlSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, tokenization.ErrInvalidTTL.Error())
+	}
+
+	tokenData, err := s.service.TokenizeCardWithOptions(
+		req.Pan,
+		int(req.ExpiryMonth),
+		int(req.ExpiryYear),
+		req.Cvv,
+		tokenization.TokenizeOptions{
+			MerchantID: req.MerchantId,
+			Metadata:   req.Metadata,
+			TTL:        time.Duration(req.TtlSeconds) * time.Second,
+			Domain:     domainFromMessage(req.Domain),
+		},
+	)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+
+	return &TokenizeResponse{
+		Token:      tokenData.Token,
+		LastFour:   tokenData.LastFour,
+		CardBrand:  tokenData.CardBrand,
+		ExpiresAt:  tokenData.ExpiresAt.Unix(),
+		MerchantId: tokenData.MerchantID,
+		Metadata:   tokenData.Metadata,
+		Revision:   tokenData.Revision,
+		Domain:     domainMessage(tokenData.Domain),
+		Par:        tokenData.PAR,
+	}, nil
+}
+
+// TokenizeEncryptedCard decrypts a P2PE terminal's read within the
+// decryption zone and tokenizes the card as TokenizeCard does, routing it to
+// the node that owns the card. The caller handles only ciphertext.
+func (s *Server) TokenizeEncryptedCard(ctx context.Context, req *TokenizeEncryptedCardRequest) (*TokenizeResponse, error) {
+	card, err := s.service.DecryptP2PE(req.Ksn, req.EncryptedTrack)
+	if err != nil {
+		s.audit(ctx, audit.OpTokenize, req.MerchantId, "", time.Now(), err)
+		return nil, ToStatus(err)
+	}
+	resp, err := s.TokenizeCard(ctx, &TokenizeRequest{
+		Pan:         card.PAN,
+		ExpiryMonth: int32(card.ExpiryMonth),
+		ExpiryYear:  int32(card.ExpiryYear),
+		MerchantId:  req.MerchantId,
+		Metadata:    req.Metadata,
+		TtlSeconds:  req.TtlSeconds,
+		Domain:      req.Domain,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The caller never sees the PAN, but routes and screens by its BIN
+	resp.Bin = cardBIN(card.PAN)
+	return resp, nil
+}
+
+// cardBIN is as much of the start of a PAN as may be shown beside its last
+// four digits: eight digits of a PAN of 16 or more, six of a shorter one
+func cardBIN(pan string) string {
+	if len(pan) >= 16 {
+		return pan[:8]
+	}
+	return pan[:6]
+}
+
+// DetokenizeCard retrieves the PAN of a token issued to the merchant, for a
+// use its domain controls allow
+func (s *Server) DetokenizeCard(ctx context.Context, req *DetokenizeRequest) (_ *DetokenizeResponse, err error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.DetokenizeCard(ctx, req)
+	}
+	start := time.Now()
+	defer func() { s.audit(ctx, audit.OpDetokenize, req.MerchantId, req.Token, start, err) }()
+
+	pan, expiryMonth, expiryYear, err := s.service.DetokenizeCardForUse(req.Token, req.MerchantId, tokenUse(req.Use))
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+
+	resp := &DetokenizeResponse{
+		Pan:         pan,
+		ExpiryMonth: int32(expiryMonth),
+		ExpiryYear:  int32(expiryYear),
+	}
+	if info, err := s.service.TokenInfo(req.Token, req.MerchantId); err == nil {
+		resp.Metadata = info.Metadata
+	}
+	return resp, nil
+}
+
+// DetokenizeBatch detokenizes a batch of the merchant's tokens. Unlike the
+// other role checks, this one also refuses unauthenticated callers. Auditing
+// is mandatory too: without an audit trail the call is refused, and a PAN
+// whose audit record cannot be written is withheld and reported as an item
+// error. Domain controls are checked only when the request says how the
+// tokens are being used, as settlement jobs do not use them to pay.
+func (s *Server) DetokenizeBatch(ctx context.Context, req *DetokenizeBatchRequest) (*DetokenizeBatchResponse, error) {
+	if p := interceptors.PrincipalFromContext(ctx); p == nil || !p.HasRole(BulkDetokenizeRole) {
+		return nil, status.Errorf(codes.PermissionDenied, "an authenticated caller with role %q is required", BulkDetokenizeRole)
+	}
+	if s.auditor == nil {
+		return nil, status.Error(codes.FailedPrecondition, "batch detokenization requires the audit trail")
+	}
+
+	resp := &DetokenizeBatchResponse{Items: make([]*DetokenizeBatchItem, 0, len(req.Tokens))}
+	var forwarded map[int]*DetokenizeBatchItem
+	if s.cluster != nil {
+		forwarded = s.forwardBatch(ctx, req)
+	}
+	for i, token := range req.Tokens {
+		if item, ok := forwarded[i]; ok {
+			if item.ErrorCode != "" {
+				resp.Failed++
+			} else {
+				resp.Succeeded++
+			}
+			resp.Items = append(resp.Items, item)
+			continue
+		}
+		start := time.Now()
+		item := &DetokenizeBatchItem{Token: token}
+		var pan string
+		var expiryMonth, expiryYear int
+		var err error
+		if req.Use != nil {
+			pan, expiryMonth, expiryYear, err = s.service.DetokenizeCardForUse(token, req.MerchantId, tokenUse(req.Use))
+		} else {
+			pan, expiryMonth, expiryYear, err = s.service.DetokenizeCardForMerchant(token, req.MerchantId)
+		}
+		detail := fmt.Sprintf("item %d/%d; reference %q; justification %q", i+1, len(req.Tokens), req.Reference, req.Justification)
+		var st *status.Status
+		if auditErr := s.auditor.RecordDetail(ctx, audit.OpDetokenizeBatch, req.MerchantId, token, detail, start, err); auditErr != nil {
+			log.Printf("Audit record for %s failed: %v", audit.OpDetokenizeBatch, auditErr)
+			st = status.New(codes.Unavailable, "audit record could not be written")
+		} else if err != nil {
+			st = status.Convert(ToStatus(err))
+		}
+		if st != nil {
+			item.ErrorCode = st.Code().String()
+			item.ErrorMessage = st.Message()
+			resp.Failed++
+		} else {
+			item.Pan = pan
+			item.ExpiryMonth = int32(expiryMonth)
+			item.ExpiryYear = int32(expiryYear)
+			resp.Succeeded++
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	return resp, nil
+}
+
+// ValidateToken reports whether a token issued to the merchant is usable.
+// Lookup failures are reported in the response rather than as an error.
+func (s *Server) ValidateToken(ctx context.Context, req *ValidateRequest) (*ValidateResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.ValidateToken(ctx, req)
+	}
+	start := time.Now()
+	valid, err := s.service.ValidateTokenForMerchant(req.Token, req.MerchantId)
+	s.audit(ctx, audit.OpValidate, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return &ValidateResponse{
+			Valid:        false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	resp := &ValidateResponse{Valid: valid}
+	if info, err := s.service.TokenInfo(req.Token, req.MerchantId); err == nil {
+		resp.ExpiresAt = info.ExpiresAt.Unix()
+		resp.Par = info.PAR
+	}
+	return resp, nil
+}
+
+// RevokeToken revokes a token issued to the merchant. Like every token
+// update, it can be made conditional on the token's revision.
+func (s *Server) RevokeToken(ctx context.Context, req *RevokeTokenRequest) (*RevokeTokenResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.RevokeToken(ctx, req)
+	}
+	start := time.Now()
+	revision, err := s.service.RevokeTokenForMerchant(req.Token, req.MerchantId, req.IfRevision)
+	s.audit(ctx, audit.OpRevoke, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &RevokeTokenResponse{Revoked: true, Revision: revision}, nil
+}
+
+// DeleteToken soft-deletes a token issued to the merchant
+func (s *Server) DeleteToken(ctx context.Context, req *DeleteTokenRequest) (*DeleteTokenResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.DeleteToken(ctx, req)
+	}
+	start := time.Now()
+	revision, err := s.service.DeleteToken(req.Token, req.MerchantId, req.IfRevision)
+	s.audit(ctx, audit.OpDelete, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	resp := &DeleteTokenResponse{Revision: revision}
+	if window := s.restoreWindow(); window > 0 {
+		resp.RestorableUntil = start.Add(window).Unix()
+	}
+	return resp, nil
+}
+
+// RestoreToken restores a soft-deleted token issued to the merchant
+func (s *Server) RestoreToken(ctx context.Context, req *RestoreTokenRequest) (*RestoreTokenResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.RestoreToken(ctx, req)
+	}
+	start := time.Now()
+	revision, err := s.service.RestoreToken(req.Token, req.MerchantId, req.IfRevision)
+	s.audit(ctx, audit.OpRestore, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &RestoreTokenResponse{Restored: true, Revision: revision}, nil
+}
+
+// UpdateTokenMetadata replaces the metadata of a token issued to the merchant
+func (s *Server) UpdateTokenMetadata(ctx context.Context, req *UpdateTokenMetadataRequest) (*UpdateTokenMetadataResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.UpdateTokenMetadata(ctx, req)
+	}
+	start := time.Now()
+	revision, err := s.service.UpdateMetadata(req.Token, req.MerchantId, req.Metadata, req.IfRevision)
+	s.audit(ctx, audit.OpUpdate, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &UpdateTokenMetadataResponse{Revision: revision}, nil
+}
+
+// UpdateTokenDomain replaces the domain controls of a token issued to the
+// merchant
+func (s *Server) UpdateTokenDomain(ctx context.Context, req *UpdateTokenDomainRequest) (*UpdateTokenDomainResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.UpdateTokenDomain(ctx, req)
+	}
+	start := time.Now()
+	revision, err := s.service.UpdateDomain(req.Token, req.MerchantId, domainFromMessage(req.Domain), req.IfRevision)
+	s.audit(ctx, audit.OpUpdateDomain, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &UpdateTokenDomainResponse{Revision: revision}, nil
+}
+
+// ListTokens lists the merchant's tokens a page at a time, oldest first
+func (s *Server) ListTokens(ctx context.Context, req *ListTokensRequest) (*ListTokensResponse, error) {
+	fingerprint := pagination.Fingerprint(req.MerchantId, strconv.FormatBool(req.ActiveOnly), strconv.FormatBool(req.Deleted), req.Par)
+	after, err := pagination.Decode(req.PageToken, fingerprint)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	filter := tokenization.TokenFilter{MerchantID: req.MerchantId, ActiveOnly: req.ActiveOnly, Deleted: req.Deleted, PAR: req.Par}
+	tokens, next := s.service.ListTokens(filter, after, tokenization.TokenListLimits.Size(int(req.PageSize)))
+	resp := &ListTokensResponse{}
+	for _, t := range tokens {
+		summary := &TokenSummary{
+			Token:       t.Token,
+			LastFour:    t.LastFour,
+			CardBrand:   t.CardBrand,
+			ExpiryMonth: int32(t.ExpiryMonth),
+			ExpiryYear:  int32(t.ExpiryYear),
+			Metadata:    t.Metadata,
+			Domain:      domainMessage(t.Domain),
+			Par:         t.PAR,
+			CreatedAt:   t.CreatedAt.Unix(),
+			ExpiresAt:   t.ExpiresAt.Unix(),
+			Active:      t.Active,
+			Revision:    t.Revision,
+		}
+		if !t.RevokedAt.IsZero() {
+			summary.RevokedAt = t.RevokedAt.Unix()
+		}
+		if !t.DeletedAt.IsZero() {
+			summary.DeletedAt = t.DeletedAt.Unix()
+		}
+		resp.Tokens = append(resp.Tokens, summary)
+	}
+	if next != nil {
+		resp.NextPageToken = pagination.Encode(fingerprint, next)
+	}
+	return resp, nil
+}
+
+func domainFromMessage(d *TokenDomain) *tokenization.Domain {
+	if d == nil {
+		return nil
+	}
+	return &tokenization.Domain{Channels: d.Channels, MerchantIDs: d.MerchantIds, RequestorIDs: d.RequestorIds}
+}
+
+func domainMessage(d *tokenization.Domain) *TokenDomain {
+	if d.IsZero() {
+		return nil
+	}
+	return &TokenDomain{Channels: d.Channels, MerchantIds: d.MerchantIDs, RequestorIds: d.RequestorIDs}
+}
+
+// tokenUse reads a use from a request; an unset one names nothing, so only
+// unrestricted tokens pass
+func tokenUse(u *TokenUse) tokenization.TokenUse {
+	if u == nil {
+		return tokenization.TokenUse{}
+	}
+	return tokenization.TokenUse{Channel: u.Channel, MerchantID: u.MerchantId, RequestorID: u.RequestorId}
+}
+
+// ListAuditRecords queries the audit trail, newest records first
+func (s *Server) ListAuditRecords(ctx context.Context, req *ListAuditRecordsRequest) (*ListAuditRecordsResponse, error) {
+	if s.auditor == nil {
+		return nil, status.Error(codes.Unimplemented, "audit trail is disabled")
+	}
+	if err := requireRole(ctx, AuditorRole); err != nil {
+		return nil, err
+	}
+
+	f := audit.Filter{
+		Operation:  audit.Operation(req.Operation),
+		Principal:  req.Principal,
+		MerchantID: req.MerchantId,
+		Token:      req.Token,
+		Outcome:    audit.Outcome(req.Outcome),
+	}
+	if req.Since > 0 {
+		f.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		f.Until = time.Unix(req.Until, 0)
+	}
+
+	fingerprint := pagination.Fingerprint(req.Operation, req.Principal, req.MerchantId, req.Token, req.Outcome,
+		strconv.FormatInt(req.Since, 10), strconv.FormatInt(req.Until, 10))
+	after, err := pagination.Decode(req.PageToken, fingerprint)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if after != nil {
+		seq, err := strconv.ParseInt(after[0], 10, 64)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, pagination.ErrInvalidToken.Error())
+		}
+		f.BeforeSeq = seq
+	}
+	size := req.PageSize
+	if size == 0 {
+		size = req.Limit
+	}
+	f.Limit = auditLimits.Size(int(size))
+
+	records, err := s.auditor.Query(f)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "audit query failed")
+	}
+
+	resp := &ListAuditRecordsResponse{}
+	// A full page may be followed by more records
+	if len(records) == f.Limit {
+		resp.NextPageToken = pagination.Encode(fingerprint, pagination.Key{strconv.FormatInt(records[len(records)-1].Seq, 10)})
+	}
+	for _, r := range records {
+		resp.Records = append(resp.Records, &AuditRecord{
+			Seq:        r.Seq,
+			Time:       r.Time.UnixMilli(),
+			Operation:  string(r.Operation),
+			Principal:  r.Principal,
+			RequestId:  r.RequestID,
+			MerchantId: r.MerchantID,
+			Token:      r.Token,
+			Outcome:    string(r.Outcome),
+			Error:      r.Error,
+			LatencyMs:  r.LatencyMs,
+			Detail:     r.Detail,
+		})
+	}
+	return resp, nil
+}
+
+// ForgetCard deletes every token derived from a card, for erasure requests.
+// Each deleted token is audited; the audit trail itself never held the PAN.
+func (s *Server) ForgetCard(ctx context.Context, req *ForgetCardRequest) (*ForgetCardResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+
+	fingerprint := req.Fingerprint
+	if req.Pan != "" {
+		var err error
+		if fingerprint, err = tokenization.Fingerprint(req.Pan); err != nil {
+			return nil, ToStatus(err)
+		}
+	}
+	if peer, ctx := s.peerFor(ctx, fingerprint); peer != nil {
+		return peer.ForgetCard(ctx, &ForgetCardRequest{Fingerprint: fingerprint})
+	}
+
+	start := time.Now()
+	tokens, err := s.service.ForgetCard(fingerprint)
+	if err != nil {
+		s.audit(ctx, audit.OpForget, "", "", start, err)
+		return nil, ToStatus(err)
+	}
+	for _, token := range tokens {
+		s.audit(ctx, audit.OpForget, "", token, start, nil)
+	}
+	return &ForgetCardResponse{TokensRemoved: int32(len(tokens))}, nil
+}
+
+// ShredTokens crypto-shreds the tokens of a merchant or card by destroying
+// their data keys. The tokens stay in the vault, deactivated, with
+// ciphertexts nothing can decrypt.
+func (s *Server) ShredTokens(ctx context.Context, req *ShredTokensRequest) (*ShredTokensResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+
+	if req.MerchantId == "" {
+		if peer, ctx := s.peerFor(ctx, req.Fingerprint); peer != nil {
+			return peer.ShredTokens(ctx, req)
+		}
+	}
+
+	start := time.Now()
+	var (
+		res *tokenization.ShredResult
+		err error
+	)
+	if req.MerchantId != "" {
+		res, err = s.service.ShredMerchant(req.MerchantId)
+	} else {
+		res, err = s.service.ShredCard(req.Fingerprint)
+	}
+	if err != nil {
+		s.audit(ctx, audit.OpShred, req.MerchantId, "", start, err)
+		return nil, ToStatus(err)
+	}
+	for _, token := range res.Tokens {
+		s.audit(ctx, audit.OpShred, req.MerchantId, token, start, nil)
+	}
+	return &ShredTokensResponse{
+		TokensShredded: int32(len(res.Tokens)),
+		KeysDestroyed:  int32(res.Keys),
+	}, nil
+}
+
+// ProvisionNetworkToken provisions a network token for the card behind a
+// vault token of the merchant
+func (s *Server) ProvisionNetworkToken(ctx context.Context, req *ProvisionNetworkTokenRequest) (*ProvisionNetworkTokenResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.ProvisionNetworkToken(ctx, req)
+	}
+	start := time.Now()
+	nt, err := s.service.ProvisionNetworkToken(req.Token, req.MerchantId)
+	s.audit(ctx, audit.OpProvision, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &ProvisionNetworkTokenResponse{NetworkToken: networkTokenMessage(nt)}, nil
+}
+
+// ExchangeToken exchanges a vault token for the linked network token, or a
+// network token for the linked vault token. The format of the token decides
+// the direction.
+func (s *Server) ExchangeToken(ctx context.Context, req *ExchangeTokenRequest) (*ExchangeTokenResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.Token); peer != nil {
+		return peer.ExchangeToken(ctx, req)
+	}
+	start := time.Now()
+	resp, err := s.exchange(req.Token, req.MerchantId)
+	s.audit(ctx, audit.OpExchange, req.MerchantId, req.Token, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return resp, nil
+}
+
+func (s *Server) exchange(token, merchantID string) (*ExchangeTokenResponse, error) {
+	if tokenization.IsVaultToken(token) {
+		nt, err := s.service.NetworkTokenFor(token, merchantID)
+		if err != nil {
+			return nil, err
+		}
+		return &ExchangeTokenResponse{Token: nt.Token, TokenType: "network", NetworkToken: networkTokenMessage(nt)}, nil
+	}
+	tokenData, err := s.service.VaultTokenFor(token, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	nt, err := s.service.NetworkTokenInfo(token, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	return &ExchangeTokenResponse{Token: tokenData.Token, TokenType: "vault", NetworkToken: networkTokenMessage(nt)}, nil
+}
+
+// ApplyCardEvent applies a card lifecycle event to every token of the card.
+// Each changed token is audited with the event.
+func (s *Server) ApplyCardEvent(ctx context.Context, req *ApplyCardEventRequest) (*ApplyCardEventResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+
+	if peer, ctx := s.peerFor(ctx, req.NetworkToken); peer != nil {
+		return peer.ApplyCardEvent(ctx, req)
+	}
+
+	start := time.Now()
+	event, err := tokenization.ParseCardEvent(req.Event)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	res, err := s.service.ApplyCardEvent(req.NetworkToken, event)
+	detail := "event " + string(event)
+	if err != nil {
+		s.auditDetail(ctx, audit.OpCardEvent, "", req.NetworkToken, detail, start, err)
+		return nil, ToStatus(err)
+	}
+	for _, token := range append(res.NetworkTokens, res.VaultTokens...) {
+		s.auditDetail(ctx, audit.OpCardEvent, "", token, detail, start, nil)
+	}
+	return &ApplyCardEventResponse{
+		NetworkTokensChanged: int32(len(res.NetworkTokens)),
+		VaultTokensChanged:   int32(len(res.VaultTokens)),
+	}, nil
+}
+
+// GenerateTokenCryptogram issues the cryptogram for the next use of a
+// network token, as the network token service would to its requestor
+func (s *Server) GenerateTokenCryptogram(ctx context.Context, req *GenerateTokenCryptogramRequest) (*GenerateTokenCryptogramResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.NetworkToken); peer != nil {
+		return peer.GenerateTokenCryptogram(ctx, req)
+	}
+	start := time.Now()
+	txn := tokenization.CryptogramTransaction{Amount: req.Amount, Currency: req.Currency}
+	cryptogram, err := s.service.GenerateCryptogram(req.NetworkToken, req.MerchantId, txn)
+	s.audit(ctx, audit.OpGenerateCryptogram, req.MerchantId, req.NetworkToken, start, err)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &GenerateTokenCryptogramResponse{
+		Cryptogram: cryptogram,
+		Atc:        uint32(binary.BigEndian.Uint16(cryptogram)),
+	}, nil
+}
+
+// ValidateTokenCryptogram checks the cryptogram presented with a network
+// token. A rejected cryptogram is an answer, not an error, so the caller
+// can decline the transaction with the reason.
+func (s *Server) ValidateTokenCryptogram(ctx context.Context, req *ValidateTokenCryptogramRequest) (*ValidateTokenCryptogramResponse, error) {
+	if peer, ctx := s.peerFor(ctx, req.NetworkToken); peer != nil {
+		return peer.ValidateTokenCryptogram(ctx, req)
+	}
+	start := time.Now()
+	txn := tokenization.CryptogramTransaction{Amount: req.Amount, Currency: req.Currency}
+	err := s.service.ValidateCryptogram(req.NetworkToken, req.MerchantId, txn, req.Cryptogram)
+	s.audit(ctx, audit.OpValidateCryptogram, req.MerchantId, req.NetworkToken, start, err)
+	if errors.Is(err, tokenization.ErrInvalidCryptogram) {
+		reason := strings.TrimPrefix(err.Error(), tokenization.ErrInvalidCryptogram.Error()+": ")
+		return &ValidateTokenCryptogramResponse{Reason: reason}, nil
+	}
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return &ValidateTokenCryptogramResponse{Valid: true}, nil
+}
+
+func networkTokenMessage(nt *tokenization.NetworkToken) *NetworkToken {
+	return &NetworkToken{
+		NetworkToken: nt.Token,
+		Network:      nt.Network,
+		LastFour:     nt.LastFour,
+		ExpiryMonth:  int32(nt.ExpiryMonth),
+		ExpiryYear:   int32(nt.ExpiryYear),
+		Status:       string(nt.Status),
+		CreatedAt:    nt.CreatedAt.Unix(),
+		Par:          nt.PAR,
+	}
+}
+
+// GetVaultStats reports the vault's size and composition, for planning key
+// rotation and purge jobs
+func (s *Server) GetVaultStats(ctx context.Context, req *GetVaultStatsRequest) (*GetVaultStatsResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+
+	stats := s.service.Stats()
+	resp := &GetVaultStatsResponse{
+		Tokens:          int64(stats.Tokens),
+		ByStatus:        make(map[string]int64, len(stats.ByStatus)),
+		ByBrand:         make(map[string]int64, len(stats.ByBrand)),
+		ByMerchant:      make(map[string]int64, len(stats.ByMerchant)),
+		ByKeyVersion:    make(map[int32]int64, len(stats.ByKeyVersion)),
+		Shredded:        int64(stats.Shredded),
+		DataKeys:        int64(stats.DataKeys),
+		NetworkTokens:   int64(stats.NetworkTokens),
+		CiphertextBytes: stats.CiphertextBytes,
+		EstimatedBytes:  stats.EstimatedBytes,
+		IssuedLastDay:   int64(stats.IssuedLastDay),
+		IssuedLastWeek:  int64(stats.IssuedLastWeek),
+		GrowthPerDay:    stats.GrowthPerDay,
+	}
+	for st, n := range stats.ByStatus {
+		resp.ByStatus[string(st)] = int64(n)
+	}
+	for brand, n := range stats.ByBrand {
+		resp.ByBrand[brand] = int64(n)
+	}
+	for merchantID, n := range stats.ByMerchant {
+		resp.ByMerchant[merchantID] = int64(n)
+	}
+	for version, n := range stats.ByKeyVersion {
+		resp.ByKeyVersion[int32(version)] = int64(n)
+	}
+	if stats.Tokens > 0 {
+		resp.OldestIssuedAt = stats.OldestIssued.Unix()
+		resp.NewestIssuedAt = stats.NewestIssued.Unix()
+	}
+	return resp, nil
+}
+
+// StreamChanges sends a read replica a snapshot of the vault, then every
+// change to it until the replica disconnects or falls behind. Records carry
+// what the vault holds: encrypted PANs and HSM-wrapped keys, never a PAN.
+func (s *Server) StreamChanges(req *StreamChangesRequest, stream TokenizationService_StreamChangesServer) error {
+	ctx := stream.Context()
+	if err := requireRole(ctx, ReplicaRole); err != nil {
+		return err
+	}
+
+	snapshot, sub, err := s.service.SubscribeChanges()
+	if err != nil {
+		return ToStatus(err)
+	}
+	defer sub.Close()
+	for _, record := range snapshot {
+		if err := stream.Send(&VaultChange{Record: record}); err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&VaultChange{SnapshotComplete: true}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case record, ok := <-sub.Changes():
+			if !ok {
+				return ToStatus(sub.Err())
+			}
+			if err := stream.Send(&VaultChange{Record: record}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StartKeyRotation rotates the vault's HSM key and re-encrypts the vault in
+// the background; poll GetKeyRotation for its progress
+func (s *Server) StartKeyRotation(ctx context.Context, req *StartKeyRotationRequest) (*StartKeyRotationResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+	if s.rotation == nil {
+		return nil, status.Error(codes.FailedPrecondition, "key rotation is not enabled on this server")
+	}
+
+	start := time.Now()
+	st, err := s.rotation.Start(ctx, req.Resume)
+	if errors.Is(err, rotation.ErrInProgress) {
+		return nil, status.Errorf(codes.FailedPrecondition, "%v: %s", err, st.ID)
+	}
+	if err != nil {
+		s.auditDetail(ctx, audit.OpRotateKey, "", "", "", start, err)
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	s.auditDetail(ctx, audit.OpRotateKey, "", "", fmt.Sprintf("%s to version %d", st.ID, st.NewVersion), start, nil)
+	return &StartKeyRotationResponse{Rotation: keyRotationMessage(st)}, nil
+}
+
+// GetKeyRotation reports the progress of the latest key rotation
+func (s *Server) GetKeyRotation(ctx context.Context, req *GetKeyRotationRequest) (*GetKeyRotationResponse, error) {
+	if err := requireRole(ctx, AdminRole); err != nil {
+		return nil, err
+	}
+	if s.rotation == nil {
+		return nil, status.Error(codes.FailedPrecondition, "key rotation is not enabled on this server")
+	}
+
+	st, err := s.rotation.Status()
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &GetKeyRotationResponse{Rotation: keyRotationMessage(st)}, nil
+}
+
+func keyRotationMessage(st rotation.Status) *KeyRotation {
+	msg := &KeyRotation{
+		Id:         st.ID,
+		KeyId:      st.KeyID,
+		State:      string(st.State),
+		NewVersion: int32(st.NewVersion),
+		Total:      int64(st.Total),
+		Migrated:   int64(st.Migrated),
+		Remaining:  int64(st.Remaining),
+		Error:      st.Error,
+		StartedAt:  st.StartedAt.Unix(),
+		UpdatedAt:  st.UpdatedAt.Unix(),
+	}
+	for _, rv := range st.Retiring {
+		msg.Retiring = append(msg.Retiring, &RetiredKeyVersion{Version: int32(rv.Version), State: rv.State})
+	}
+	if !st.FinishedAt.IsZero() {
+		msg.FinishedAt = st.FinishedAt.Unix()
+	}
+	return msg
+}
+
+// requireRole checks the caller's role. Without authentication there is no
+// caller to check, which is the accepted trade-off for local simulations.
+func requireRole(ctx context.Context, role string) error {
+	if p := interceptors.PrincipalFromContext(ctx); p != nil && !p.HasRole(role) {
+		return status.Errorf(codes.PermissionDenied, "role %q required", role)
+	}
+	return nil
+}
+
+// audit records an operation. A failing audit store is logged rather than
+// failing the call, so an audit outage degrades visibility, not payments.
+func (s *Server) audit(ctx context.Context, op audit.Operation, merchantID, token string, start time.Time, err error) {
+	if s.auditor == nil {
+		return
+	}
+	if auditErr := s.auditor.Record(ctx, op, merchantID, token, start, err); auditErr != nil {
+		log.Printf("Audit record for %s failed: %v", op, auditErr)
+	}
+}
+
+// auditDetail is audit for an operation that records a detail
+func (s *Server) auditDetail(ctx context.Context, op audit.Operation, merchantID, token, detail string, start time.Time, err error) {
+	if s.auditor == nil {
+		return
+	}
+	if auditErr := s.auditor.RecordDetail(ctx, op, merchantID, token, detail, start, err); auditErr != nil {
+		log.Printf("Audit record for %s failed: %v", op, auditErr)
+	}
+}
+
+// GetServiceInfo describes this build and its capabilities
+func (s *Server) GetServiceInfo(ctx context.Context, req *GetServiceInfoRequest) (*GetServiceInfoResponse, error) {
+	build := buildinfo.Get()
+	features := []string{
+		"format-preserving-tokens", "luhn-validation", "pan-deduplication",
+		"merchant-scoping", "token-metadata", "per-token-ttl", "audit-trail",
+		"data-retention", "forget-card", "token-listing", "soft-delete", "bulk-detokenize", "network-token-exchange", "vault-stats", "token-revisions", "domain-controls", "crypto-shredding:" + s.service.KeyScope().String(),
+	}
+	if s.service.Envelope() {
+		features = append(features, "envelope-encryption")
+	}
+	if s.service.PublishesChanges() {
+		features = append(features, "change-feed")
+	}
+	if s.replicaReady != nil {
+		features = append(features, "read-replica")
+	}
+	if s.cluster != nil {
+		features = append(features, "cluster")
+	}
+	if s.rotation != nil {
+		features = append(features, "key-rotation")
+	}
+	if s.service.DecryptsP2PE() {
+		features = append(features, "p2pe-decryption")
+	}
+	if s.service.IssuesCryptograms() {
+		features = append(features, "token-cryptograms")
+	}
+	return &GetServiceInfoResponse{
+		Service:    "tokenization-service",
+		Version:    build.Version,
+		Commit:     build.Commit,
+		BuildDate:  build.Date,
+		Algorithms: []string{"AES-256-GCM"},
+		Features:   features,
+		Limits: map[string]int64{
+			"pan_min_length":         13,
+			"pan_max_length":         19,
+			"token_ttl_seconds":      int64(s.service.TokenTTL().Seconds()),
+			"expiry_max_years_ahead": 10,
+			"metadata_max_entries":   tokenization.MaxMetadataEntries,
+			"metadata_max_key_len":   tokenization.MaxMetadataKeyLen,
+			"metadata_max_value_len": tokenization.MaxMetadataValueLen,
+			"domain_max_entries":     tokenization.MaxDomainEntries,
+			"page_size_max":          int64(tokenization.TokenListLimits.Max),
+			"detokenize_batch_max":   MaxDetokenizeBatch,
+			"restore_window_seconds": int64(s.restoreWindow().Seconds()),
+		},
+	}, nil
+}
+
+// ToStatus maps tokenization errors to gRPC status codes
+func ToStatus(err error) error {
+	switch {
+	case errors.Is(err, tokenization.ErrInvalidPAN),
+		errors.Is(err, tokenization.ErrInvalidExpiry),
+		errors.Is(err, tokenization.ErrInvalidToken),
+		errors.Is(err, tokenization.ErrInvalidTTL),
+		errors.Is(err, tokenization.ErrInvalidMetadata),
+		errors.Is(err, tokenization.ErrInvalidDomain),
+		errors.Is(err, tokenization.ErrInvalidFingerprint),
+		errors.Is(err, tokenization.ErrInvalidCardEvent),
+		errors.Is(err, tokenization.ErrInvalidEncryptedCard),
+		errors.Is(err, tokenization.ErrInvalidCryptogram):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, tokenization.ErrTokenNotFound),
+		errors.Is(err, tokenization.ErrNetworkTokenNotFound),
+		errors.Is(err, tokenization.ErrNoLinkedToken):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, tokenization.ErrTokenExpired),
+		errors.Is(err, tokenization.ErrTokenNotDeleted),
+		errors.Is(err, tokenization.ErrKeyShredded),
+		errors.Is(err, tokenization.ErrShredUnsupported),
+		errors.Is(err, tokenization.ErrTokenSuspended),
+		errors.Is(err, tokenization.ErrNetworkTokenSuspended),
+		errors.Is(err, tokenization.ErrNetworkNotSupported),
+		errors.Is(err, tokenization.ErrNoChangeFeed),
+		errors.Is(err, tokenization.ErrP2PEUnavailable),
+		errors.Is(err, tokenization.ErrCryptogramsUnavailable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, tokenization.ErrDomainRestricted):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, tokenization.ErrSubscriberBehind),
+		errors.Is(err, tokenization.ErrVaultFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, tokenization.ErrEncryptionFailed), errors.Is(err, tokenization.ErrDecryptionFailed):
+		return status.Error(codes.Unavailable, "HSM operation failed")
+	case errors.Is(err, tokenization.ErrDuplicateToken),
+		errors.Is(err, tokenization.ErrRevisionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return status.Error(codes.Internal, "internal error")
+}
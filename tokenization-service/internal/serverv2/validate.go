@@ -0,0 +1,277 @@
+package serverv2
+
+import (
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/paymentgateway/go-common/validate"
+	"github.com/paymentgateway/tokenization-service/internal/audit"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// MaxMerchantIDLen bounds merchant identifiers
+const MaxMerchantIDLen = 64
+
+// Bounds of a DetokenizeBatch request
+const (
+	MaxDetokenizeBatch  = 100
+	MinJustificationLen = 10
+	MaxJustificationLen = 500
+)
+
+// Bounds of a TokenizeEncryptedCard request: a KSN, and track data of at
+// most 79 characters padded to TDES blocks
+const (
+	KSNBytes               = 10
+	MaxEncryptedTrackBytes = 80
+)
+
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// cardNumberPattern matches a network token, or a vault token when either is
+// accepted
+var cardNumberPattern = regexp.MustCompile(`^\d{13,19}$`)
+
+// Field rules for v2 requests, enforced by the validation interceptor.
+// ValidateRequest only checks the scope: reporting a malformed token is its
+// job.
+
+func (r *TokenizeRequest) Validate(v *validate.Violations) {
+	validate.PAN(v, "pan", r.Pan)
+	validate.Expiry(v, "expiry_month", "expiry_year", int(r.ExpiryMonth), int(r.ExpiryYear), time.Now())
+	validate.CVV(v, "cvv", r.Cvv)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	if r.TtlSeconds < 0 {
+		v.Add("ttl_seconds", "must not be negative")
+	}
+	validateMetadata(v, r.Metadata)
+	validateDomain(v, r.Domain)
+}
+
+func (r *TokenizeEncryptedCardRequest) Validate(v *validate.Violations) {
+	if len(r.Ksn) != KSNBytes {
+		v.Add("ksn", "must be %d bytes", KSNBytes)
+	}
+	if n := len(r.EncryptedTrack); n == 0 || n > MaxEncryptedTrackBytes || n%8 != 0 {
+		v.Add("encrypted_track", "must be 8-%d bytes in 8-byte blocks", MaxEncryptedTrackBytes)
+	}
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	if r.TtlSeconds < 0 {
+		v.Add("ttl_seconds", "must not be negative")
+	}
+	validateMetadata(v, r.Metadata)
+	validateDomain(v, r.Domain)
+}
+
+func validateMetadata(v *validate.Violations, metadata map[string]string) {
+	if len(metadata) > tokenization.MaxMetadataEntries {
+		v.Add("metadata", "must have at most %d entries", tokenization.MaxMetadataEntries)
+	}
+	for k, val := range metadata {
+		if k == "" || len(k) > tokenization.MaxMetadataKeyLen {
+			v.Add("metadata", "key %q must be 1-%d characters", k, tokenization.MaxMetadataKeyLen)
+		}
+		validate.MaxLen(v, "metadata["+k+"]", val, tokenization.MaxMetadataValueLen)
+	}
+}
+
+func validateDomain(v *validate.Violations, d *TokenDomain) {
+	if d == nil {
+		return
+	}
+	for _, c := range d.Channels {
+		if _, err := tokenization.ParseChannel(c); err != nil {
+			v.Add("domain.channels", "must be ecommerce, in-store or moto")
+		}
+	}
+	validateDomainIDs(v, "domain.channels", d.Channels)
+	validateDomainIDs(v, "domain.merchant_ids", d.MerchantIds)
+	validateDomainIDs(v, "domain.requestor_ids", d.RequestorIds)
+}
+
+func validateDomainIDs(v *validate.Violations, field string, ids []string) {
+	if len(ids) > tokenization.MaxDomainEntries {
+		v.Add(field, "must have at most %d entries", tokenization.MaxDomainEntries)
+	}
+	for _, id := range ids {
+		if id == "" || len(id) > tokenization.MaxDomainIDLen {
+			v.Add(field, "entries must be 1-%d characters", tokenization.MaxDomainIDLen)
+		}
+	}
+}
+
+func validateUse(v *validate.Violations, u *TokenUse) {
+	if u == nil {
+		return
+	}
+	if u.Channel != "" {
+		if _, err := tokenization.ParseChannel(u.Channel); err != nil {
+			v.Add("use.channel", "must be ecommerce, in-store or moto")
+		}
+	}
+	validate.MaxLen(v, "use.merchant_id", u.MerchantId, MaxMerchantIDLen)
+	validate.MaxLen(v, "use.requestor_id", u.RequestorId, tokenization.MaxDomainIDLen)
+}
+
+func validateRevision(v *validate.Violations, ifRevision int64) {
+	if ifRevision < 0 {
+		v.Add("if_revision", "must not be negative")
+	}
+}
+
+func (r *DetokenizeRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validateUse(v, r.Use)
+}
+
+func (r *DetokenizeBatchRequest) Validate(v *validate.Violations) {
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	switch n := len(r.Tokens); {
+	case n == 0:
+		v.Add("tokens", "at least one token is required")
+	case n > MaxDetokenizeBatch:
+		v.Add("tokens", "must have at most %d tokens", MaxDetokenizeBatch)
+	}
+	// Malformed tokens fail individually rather than failing the batch
+	if validate.Required(v, "justification", r.Justification) {
+		if len(r.Justification) < MinJustificationLen {
+			v.Add("justification", "must be at least %d characters", MinJustificationLen)
+		}
+		validate.MaxLen(v, "justification", r.Justification, MaxJustificationLen)
+	}
+	validate.MaxLen(v, "reference", r.Reference, MaxJustificationLen)
+	validateUse(v, r.Use)
+}
+
+func (r *ProvisionNetworkTokenRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+}
+
+func (r *ExchangeTokenRequest) Validate(v *validate.Violations) {
+	if validate.Required(v, "token", r.Token) && !cardNumberPattern.MatchString(r.Token) {
+		v.Add("token", "must be a vault or network token of 13-19 digits")
+	}
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+}
+
+func (r *ApplyCardEventRequest) Validate(v *validate.Violations) {
+	if validate.Required(v, "network_token", r.NetworkToken) && !cardNumberPattern.MatchString(r.NetworkToken) {
+		v.Add("network_token", "must be 13-19 digits")
+	}
+	if _, err := tokenization.ParseCardEvent(r.Event); err != nil {
+		v.Add("event", "must be suspend, resume or close")
+	}
+}
+
+func (r *GenerateTokenCryptogramRequest) Validate(v *validate.Violations) {
+	validateCryptogramTransaction(v, r.NetworkToken, r.MerchantId, r.Amount, r.Currency)
+}
+
+func (r *ValidateTokenCryptogramRequest) Validate(v *validate.Violations) {
+	validateCryptogramTransaction(v, r.NetworkToken, r.MerchantId, r.Amount, r.Currency)
+	if len(r.Cryptogram) != tokenization.CryptogramLen {
+		v.Add("cryptogram", "must be %d bytes", tokenization.CryptogramLen)
+	}
+}
+
+func validateCryptogramTransaction(v *validate.Violations, networkToken, merchantID string, amount int64, currency string) {
+	if validate.Required(v, "network_token", networkToken) && !cardNumberPattern.MatchString(networkToken) {
+		v.Add("network_token", "must be 13-19 digits")
+	}
+	validate.MaxLen(v, "merchant_id", merchantID, MaxMerchantIDLen)
+	validate.Amount(v, "amount", amount, 1, math.MaxInt64)
+	validate.Currency(v, "currency", currency)
+}
+
+func (r *ValidateRequest) Validate(v *validate.Violations) {
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+}
+
+func (r *RevokeTokenRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validateRevision(v, r.IfRevision)
+}
+
+func (r *DeleteTokenRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validateRevision(v, r.IfRevision)
+}
+
+func (r *RestoreTokenRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validateRevision(v, r.IfRevision)
+}
+
+func (r *UpdateTokenMetadataRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validateMetadata(v, r.Metadata)
+	validateRevision(v, r.IfRevision)
+}
+
+func (r *UpdateTokenDomainRequest) Validate(v *validate.Violations) {
+	validate.Token(v, "token", r.Token)
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validateDomain(v, r.Domain)
+	validateRevision(v, r.IfRevision)
+}
+
+func (r *ListTokensRequest) Validate(v *validate.Violations) {
+	validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	validate.Range(v, "page_size", int64(r.PageSize), 0, int64(tokenization.TokenListLimits.Max))
+	if r.Par != "" && tokenization.ValidatePAR(r.Par) != nil {
+		v.Add("par", "must be %d uppercase letters and digits", tokenization.PARLen)
+	}
+}
+
+func (r *ListAuditRecordsRequest) Validate(v *validate.Violations) {
+	switch audit.Operation(r.Operation) {
+	case "", audit.OpTokenize, audit.OpDetokenize, audit.OpDetokenizeBatch, audit.OpValidate, audit.OpRevoke, audit.OpDelete, audit.OpRestore,
+		audit.OpUpdate, audit.OpUpdateDomain, audit.OpPurge, audit.OpForget, audit.OpShred, audit.OpProvision, audit.OpExchange, audit.OpCardEvent,
+		audit.OpGenerateCryptogram, audit.OpValidateCryptogram, audit.OpReloadConfig:
+	default:
+		v.Add("operation", "must be tokenize, detokenize, detokenize-batch, validate, revoke, delete, restore, update-metadata, update-domain, purge, forget, shred, provision-network-token, exchange, card-event, generate-cryptogram, validate-cryptogram or reload-config")
+	}
+	switch audit.Outcome(r.Outcome) {
+	case "", audit.OutcomeSuccess, audit.OutcomeFailure:
+	default:
+		v.Add("outcome", "must be success or failure")
+	}
+	validate.Range(v, "limit", int64(r.Limit), 0, audit.MaxLimit)
+	validate.Range(v, "page_size", int64(r.PageSize), 0, audit.MaxLimit)
+	if r.Since > 0 && r.Until > 0 && r.Until <= r.Since {
+		v.Add("until", "must be after since")
+	}
+}
+
+func (r *ForgetCardRequest) Validate(v *validate.Violations) {
+	switch {
+	case r.Fingerprint == "" && r.Pan == "":
+		v.Add("fingerprint", "fingerprint or pan is required")
+	case r.Fingerprint != "" && r.Pan != "":
+		v.Add("pan", "must not be set together with fingerprint")
+	case r.Pan != "":
+		validate.PAN(v, "pan", r.Pan)
+	case !fingerprintPattern.MatchString(r.Fingerprint):
+		v.Add("fingerprint", "must be 64 lowercase hex characters")
+	}
+}
+
+func (r *ShredTokensRequest) Validate(v *validate.Violations) {
+	switch {
+	case r.MerchantId == "" && r.Fingerprint == "":
+		v.Add("merchant_id", "merchant_id or fingerprint is required")
+	case r.MerchantId != "" && r.Fingerprint != "":
+		v.Add("fingerprint", "must not be set together with merchant_id")
+	case r.MerchantId != "":
+		validate.MaxLen(v, "merchant_id", r.MerchantId, MaxMerchantIDLen)
+	case !fingerprintPattern.MatchString(r.Fingerprint):
+		v.Add("fingerprint", "must be 64 lowercase hex characters")
+	}
+}
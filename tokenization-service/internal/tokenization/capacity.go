@@ -0,0 +1,298 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+// ErrVaultFull is returned when a new token, or a token grown by an update,
+// would take the vault past its capacity
+var ErrVaultFull = errors.New("token vault is full")
+
+// CapacityPolicy decides what tokenizing does when the vault is full
+type CapacityPolicy string
+
+const (
+	// CapacityReject refuses new tokens until retention purges make room
+	CapacityReject CapacityPolicy = "reject"
+	// CapacityEvictExpired makes room by removing expired and revoked
+	// tokens, the longest unusable first, and refuses new tokens only when
+	// none are left
+	CapacityEvictExpired CapacityPolicy = "evict-expired"
+)
+
+// ParseCapacityPolicy reads a policy name; "" is CapacityReject
+func ParseCapacityPolicy(s string) (CapacityPolicy, error) {
+	switch p := CapacityPolicy(s); p {
+	case "":
+		return CapacityReject, nil
+	case CapacityReject, CapacityEvictExpired:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown capacity policy %q: want reject or evict-expired", s)
+}
+
+// CapacityState is how close the vault is to its capacity
+type CapacityState string
+
+const (
+	CapacityOK      CapacityState = "ok"
+	CapacityWarning CapacityState = "warning"
+	CapacityFull    CapacityState = "full"
+)
+
+// DefaultCapacityAlertThreshold is the share of a limit at which the vault
+// warns when CapacityConfig leaves it zero
+const DefaultCapacityAlertThreshold = 0.8
+
+// capacityHysteresis is how far below the alert threshold usage must fall
+// for a warning to clear, so a vault hovering at the threshold doesn't flap
+const capacityHysteresis = 0.05
+
+// CapacityConfig bounds the in-memory vault. Zero limits are unlimited;
+// other zero fields take the defaults.
+type CapacityConfig struct {
+	// MaxTokens caps the tokens held, whatever their status
+	MaxTokens int
+	// MaxBytes caps the estimated memory of the tokens and their index
+	// entries, estimated as VaultStats.EstimatedBytes is. A token is
+	// admitted while the vault is under the cap, so the last one may
+	// overshoot it.
+	MaxBytes int64
+	// Policy decides what tokenizing does when the vault is full (default
+	// CapacityReject)
+	Policy CapacityPolicy
+	// AlertThreshold is the share of a limit in use at which the vault
+	// warns, between 0 and 1 (default 0.8)
+	AlertThreshold float64
+	// OnAlert is called when the vault's state changes. It is called with
+	// the vault locked, so it must neither block nor call the service.
+	OnAlert func(CapacityAlert)
+	// Metrics receives the usage gauges and the eviction and rejection
+	// counters. Nil keeps them private to the vault.
+	Metrics *metrics.Registry
+}
+
+// CapacityStatus is the vault's use of its capacity
+type CapacityStatus struct {
+	State     CapacityState
+	Tokens    int
+	MaxTokens int
+	Bytes     int64
+	MaxBytes  int64
+	// Usage is the larger share of the two limits in use
+	Usage float64
+}
+
+// CapacityAlert reports a change of the vault's state
+type CapacityAlert struct {
+	CapacityStatus
+	Previous CapacityState
+	At       time.Time
+}
+
+// vaultCapacity tracks the vault's estimated size against its limits. It is
+// guarded by the service's mu.
+type vaultCapacity struct {
+	cfg   CapacityConfig
+	bytes int64
+	state CapacityState
+	now   func() time.Time
+
+	evictions  *metrics.Counter
+	rejections *metrics.Counter
+}
+
+// WithCapacity bounds the vault, so a long-running simulation fails
+// tokenizations, or evicts dead tokens, instead of running out of memory.
+// Tokens replayed from the write-ahead log or a primary's change feed are
+// never refused; they only raise the alerts.
+func WithCapacity(cfg CapacityConfig) Option {
+	if cfg.Policy == "" {
+		cfg.Policy = CapacityReject
+	}
+	if cfg.AlertThreshold <= 0 || cfg.AlertThreshold > 1 {
+		cfg.AlertThreshold = DefaultCapacityAlertThreshold
+	}
+	return func(s *Service) {
+		registry := cfg.Metrics
+		if registry == nil {
+			registry = metrics.NewRegistry()
+		}
+		s.capacity = &vaultCapacity{
+			cfg:   cfg,
+			state: CapacityOK,
+			now:   time.Now,
+			evictions: registry.Counter("tokenization_vault_evictions_total",
+				"Expired and revoked tokens evicted to make room in the vault.").With(),
+			rejections: registry.Counter("tokenization_vault_rejections_total",
+				"Tokenizations and token updates refused because the vault was full.").With(),
+		}
+		registry.GaugeFunc("tokenization_vault_tokens", "Tokens held in the vault.", func() float64 {
+			return float64(s.Capacity().Tokens)
+		})
+		registry.GaugeFunc("tokenization_vault_estimated_bytes", "Estimated memory of the vault's tokens.", func() float64 {
+			return float64(s.Capacity().Bytes)
+		})
+		registry.GaugeFunc("tokenization_vault_capacity_usage", "Share of the vault's capacity in use.", func() float64 {
+			return s.Capacity().Usage
+		})
+	}
+}
+
+// Capacity returns the vault's use of its capacity. Without WithCapacity
+// the vault is unbounded and Bytes is not tracked.
+func (s *Service) Capacity() CapacityStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.capacity == nil {
+		return CapacityStatus{State: CapacityOK, Tokens: len(s.tokens)}
+	}
+	return s.capacityStatusLocked()
+}
+
+func (s *Service) capacityStatusLocked() CapacityStatus {
+	c := s.capacity
+	st := CapacityStatus{
+		State:     c.state,
+		Tokens:    len(s.tokens),
+		MaxTokens: c.cfg.MaxTokens,
+		Bytes:     c.bytes,
+		MaxBytes:  c.cfg.MaxBytes,
+	}
+	if st.MaxTokens > 0 {
+		st.Usage = float64(st.Tokens) / float64(st.MaxTokens)
+	}
+	if st.MaxBytes > 0 {
+		st.Usage = max(st.Usage, float64(st.Bytes)/float64(st.MaxBytes))
+	}
+	return st
+}
+
+// fullLocked reports whether another token would exceed a limit
+func (s *Service) fullLocked() bool {
+	c := s.capacity
+	return (c.cfg.MaxTokens > 0 && len(s.tokens) >= c.cfg.MaxTokens) ||
+		(c.cfg.MaxBytes > 0 && c.bytes >= c.cfg.MaxBytes)
+}
+
+// admit refuses a new token when the vault is full, after evicting dead
+// tokens if the policy allows
+func (s *Service) admit() error {
+	if s.capacity == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.admitLocked()
+}
+
+// admitLocked is admit with s.mu held
+func (s *Service) admitLocked() error {
+	if s.capacity == nil || !s.fullLocked() {
+		return nil
+	}
+	if s.capacity.cfg.Policy == CapacityEvictExpired {
+		s.evictLocked()
+		if !s.fullLocked() {
+			return nil
+		}
+	}
+	s.capacity.rejections.Inc()
+	st := s.capacityStatusLocked()
+	return fmt.Errorf("%w: %d tokens, about %d bytes", ErrVaultFull, st.Tokens, st.Bytes)
+}
+
+// admitGrowthLocked refuses to grow a token by growth bytes past the byte
+// limit. Nothing is evicted for it: eviction makes room for new tokens, and
+// the token being updated is locked. s.mu must be held.
+func (s *Service) admitGrowthLocked(growth int64) error {
+	c := s.capacity
+	if c == nil || c.cfg.MaxBytes <= 0 || growth <= 0 || c.bytes+growth <= c.cfg.MaxBytes {
+		return nil
+	}
+	c.rejections.Inc()
+	return fmt.Errorf("%w: about %d bytes, %d more needed", ErrVaultFull, c.bytes, growth)
+}
+
+// evictLocked removes expired and revoked tokens, those unusable longest
+// first, until usage is back under the alert threshold, so the next
+// tokenizations don't each scan the vault. Soft-deleted tokens are kept
+// for RestoreToken. s.mu must be held.
+func (s *Service) evictLocked() {
+	now := s.capacity.now()
+	type candidate struct {
+		tokenData *TokenData
+		deadSince time.Time
+	}
+	var dead []candidate
+	for _, tokenData := range s.tokens {
+		tokenData.mu.RLock()
+		switch tokenData.statusAt(now) {
+		case StatusExpired:
+			dead = append(dead, candidate{tokenData, tokenData.ExpiresAt})
+		case StatusRevoked:
+			dead = append(dead, candidate{tokenData, tokenData.RevokedAt})
+		}
+		tokenData.mu.RUnlock()
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].deadSince.Before(dead[j].deadSince) })
+
+	for _, c := range dead {
+		if !s.fullLocked() && s.capacityStatusLocked().Usage < s.capacity.cfg.AlertThreshold {
+			break
+		}
+		s.removeLocked(c.tokenData)
+		s.capacity.evictions.Inc()
+	}
+}
+
+// trackLocked accounts for a token entry replaced by another; either may be
+// nil for an entry added or removed. s.mu must be held.
+func (s *Service) trackLocked(old, added *TokenData) {
+	c := s.capacity
+	if c == nil {
+		return
+	}
+	if old != nil {
+		c.bytes -= old.accountedBytes
+	}
+	if added != nil {
+		added.accountedBytes = added.entryBytes()
+		c.bytes += added.accountedBytes
+	}
+	s.updateCapacityStateLocked()
+}
+
+// updateCapacityStateLocked moves the vault to the state its usage calls
+// for and raises an alert if that is a change. s.mu must be held.
+func (s *Service) updateCapacityStateLocked() {
+	c := s.capacity
+	st := s.capacityStatusLocked()
+	next := CapacityOK
+	switch {
+	case s.fullLocked():
+		next = CapacityFull
+	case st.Usage >= c.cfg.AlertThreshold,
+		c.state != CapacityOK && st.Usage >= c.cfg.AlertThreshold-capacityHysteresis:
+		next = CapacityWarning
+	}
+	if next == c.state {
+		return
+	}
+	alert := CapacityAlert{CapacityStatus: st, Previous: c.state, At: c.now()}
+	alert.State = next
+	c.state = next
+	if c.cfg.OnAlert != nil {
+		c.cfg.OnAlert(alert)
+	}
+}
+
+// entryBytes estimates the memory of a token and its dedup index entry
+func (t *TokenData) entryBytes() int64 {
+	return t.estimatedBytes() + indexOverheadBytes + int64(len(t.MerchantID)+1+len(t.PANHash))
+}
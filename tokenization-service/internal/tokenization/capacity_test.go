@@ -0,0 +1,187 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+var capacityPANs = []string{"4532015112830366", "5425233430109903", "378282246310005", "4012888888881881", "5105105105105100"}
+
+func TestCapacityReject(t *testing.T) {
+	var alerts []CapacityAlert
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCapacity(CapacityConfig{
+		MaxTokens: 5,
+		OnAlert:   func(a CapacityAlert) { alerts = append(alerts, a) },
+	}))
+	year := time.Now().Year() + 2
+
+	var tokens []string
+	for _, pan := range capacityPANs {
+		tokenData, err := service.TokenizeCard(pan, 12, year, "123")
+		if err != nil {
+			t.Fatalf("TokenizeCard(%s) error = %v", pan, err)
+		}
+		tokens = append(tokens, tokenData.Token)
+	}
+	if _, err := service.TokenizeCardWithOptions(capacityPANs[0], 12, year, "123", TokenizeOptions{MerchantID: "m1"}); !errors.Is(err, ErrVaultFull) {
+		t.Errorf("TokenizeCard() into a full vault error = %v, want %v", err, ErrVaultFull)
+	}
+	// A card already in the vault gets its token back
+	if tokenData, err := service.TokenizeCard(capacityPANs[0], 12, year, "123"); err != nil || tokenData.Token != tokens[0] {
+		t.Errorf("TokenizeCard() of a tokenized card = %v, %v", tokenData, err)
+	}
+
+	// Purges bring the vault back to a warning, then below it
+	service.RevokeToken(tokens[0])
+	service.PurgeTokens(time.Now().Add(time.Second))
+	service.RevokeToken(tokens[1])
+	service.RevokeToken(tokens[2])
+	service.PurgeTokens(time.Now().Add(time.Second))
+
+	var states []CapacityState
+	for _, a := range alerts {
+		states = append(states, a.State)
+	}
+	want := []CapacityState{CapacityWarning, CapacityFull, CapacityWarning, CapacityOK}
+	if fmt.Sprint(states) != fmt.Sprint(want) {
+		t.Errorf("alerts = %v, want %v", states, want)
+	}
+	if a := alerts[1]; a.Tokens != 5 || a.MaxTokens != 5 || a.Usage != 1 || a.Previous != CapacityWarning {
+		t.Errorf("full alert = %+v", a)
+	}
+	if st := service.Capacity(); st.Tokens != 2 || st.State != CapacityOK {
+		t.Errorf("Capacity() = %+v, want 2 tokens and ok", st)
+	}
+}
+
+func TestCapacityEvictExpired(t *testing.T) {
+	registry := metrics.NewRegistry()
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCapacity(CapacityConfig{
+		MaxTokens: 2,
+		Policy:    CapacityEvictExpired,
+		Metrics:   registry,
+	}))
+	year := time.Now().Year() + 2
+
+	revoked, _ := service.TokenizeCard(capacityPANs[0], 12, year, "123")
+	kept, _ := service.TokenizeCard(capacityPANs[1], 12, year, "123")
+	service.RevokeToken(revoked.Token)
+
+	if _, err := service.TokenizeCard(capacityPANs[2], 12, year, "123"); err != nil {
+		t.Fatalf("TokenizeCard() with a revoked token to evict error = %v", err)
+	}
+	if _, err := service.ValidateToken(revoked.Token); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("revoked token still in the vault: %v", err)
+	}
+	if _, err := service.ValidateToken(kept.Token); err != nil {
+		t.Errorf("live token evicted: %v", err)
+	}
+
+	// With only live tokens left, the vault refuses
+	if _, err := service.TokenizeCard(capacityPANs[3], 12, year, "123"); !errors.Is(err, ErrVaultFull) {
+		t.Errorf("TokenizeCard() error = %v, want %v", err, ErrVaultFull)
+	}
+	var b strings.Builder
+	registry.WriteText(&b)
+	for _, line := range []string{"tokenization_vault_evictions_total 1", "tokenization_vault_rejections_total 1", "tokenization_vault_capacity_usage 1"} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("metrics lack %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestCapacityBytes(t *testing.T) {
+	year := time.Now().Year() + 2
+	probe := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCapacity(CapacityConfig{}))
+	probe.TokenizeCard(capacityPANs[0], 12, year, "123")
+	entry := probe.Capacity().Bytes
+	if stats := probe.Stats(); entry <= 0 || entry > stats.EstimatedBytes {
+		t.Fatalf("Capacity().Bytes = %d, Stats().EstimatedBytes = %d", entry, stats.EstimatedBytes)
+	}
+
+	// Room for two tokens of about that size
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCapacity(CapacityConfig{MaxBytes: 2*entry + entry/2}))
+	for _, pan := range capacityPANs[:3] {
+		service.TokenizeCard(pan, 12, year, "123")
+	}
+	if st := service.Capacity(); st.Tokens != 3 || st.State != CapacityFull {
+		t.Errorf("Capacity() = %+v, want 3 tokens and full", st)
+	}
+	if _, err := service.TokenizeCard(capacityPANs[3], 12, year, "123"); !errors.Is(err, ErrVaultFull) {
+		t.Errorf("TokenizeCard() error = %v, want %v", err, ErrVaultFull)
+	}
+
+	fingerprint, _ := Fingerprint(capacityPANs[0])
+	service.ForgetCard(fingerprint)
+	if st := service.Capacity(); st.Tokens != 2 || st.Bytes >= service.capacity.cfg.MaxBytes {
+		t.Errorf("Capacity() after forgetting a card = %+v", st)
+	}
+	service.ResetVault()
+	if st := service.Capacity(); st.Bytes != 0 || st.State != CapacityOK {
+		t.Errorf("Capacity() after reset = %+v", st)
+	}
+}
+
+func TestCapacityBytesMetadataUpdate(t *testing.T) {
+	year := time.Now().Year() + 2
+	probe := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCapacity(CapacityConfig{}))
+	probe.TokenizeCardWithOptions(capacityPANs[0], 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	entry := probe.Capacity().Bytes
+
+	// Room for two tokens and a little metadata
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCapacity(CapacityConfig{MaxBytes: 2*entry + 100}))
+	var tokens []string
+	for _, pan := range capacityPANs[:2] {
+		tokenData, err := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+		if err != nil {
+			t.Fatalf("TokenizeCard(%s) error = %v", pan, err)
+		}
+		tokens = append(tokens, tokenData.Token)
+	}
+	unannotated := service.Capacity().Bytes
+
+	small := map[string]string{"order": "1"}
+	if _, err := service.UpdateMetadata(tokens[0], "m1", small, AnyRevision); err != nil {
+		t.Fatalf("UpdateMetadata() within the limit error = %v", err)
+	}
+	before := service.Capacity().Bytes
+	if before <= unannotated {
+		t.Errorf("Capacity().Bytes after a metadata update = %d, want more than %d", before, unannotated)
+	}
+
+	large := map[string]string{"note": strings.Repeat("x", 500)}
+	if _, err := service.UpdateMetadata(tokens[1], "m1", large, AnyRevision); !errors.Is(err, ErrVaultFull) {
+		t.Errorf("UpdateMetadata() past the limit error = %v, want %v", err, ErrVaultFull)
+	}
+	tokenData, _ := service.TokenInfo(tokens[1], "m1")
+	if len(tokenData.Metadata) != 0 || tokenData.Revision != 1 {
+		t.Errorf("refused update left metadata %v at revision %d", tokenData.Metadata, tokenData.Revision)
+	}
+	if st := service.Capacity(); st.Bytes != before || st.Bytes > st.MaxBytes {
+		t.Errorf("Capacity() after a refused update = %+v, want %d bytes", st, before)
+	}
+
+	// Shrinking is always allowed and gives the bytes back
+	if _, err := service.UpdateMetadata(tokens[0], "m1", nil, AnyRevision); err != nil {
+		t.Errorf("UpdateMetadata() clearing metadata error = %v", err)
+	}
+	if st := service.Capacity(); st.Bytes != unannotated {
+		t.Errorf("Capacity().Bytes after clearing metadata = %d, want %d", st.Bytes, unannotated)
+	}
+}
+
+func TestParseCapacityPolicy(t *testing.T) {
+	for s, want := range map[string]CapacityPolicy{"": CapacityReject, "reject": CapacityReject, "evict-expired": CapacityEvictExpired} {
+		if got, err := ParseCapacityPolicy(s); err != nil || got != want {
+			t.Errorf("ParseCapacityPolicy(%q) = %s, %v, want %s", s, got, err, want)
+		}
+	}
+	if _, err := ParseCapacityPolicy("evict-lru"); err == nil {
+		t.Error("ParseCapacityPolicy() accepted an unknown policy")
+	}
+}
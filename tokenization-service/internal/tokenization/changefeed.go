@@ -0,0 +1,165 @@
+package tokenization
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// The change feed streams vault mutations to read replicas. Its records are
+// the write-ahead log's: a replica loads a snapshot, then applies every
+// change after it, and ends up with the primary's vault.
+
+var (
+	// ErrNoChangeFeed is returned when the service publishes no changes
+	ErrNoChangeFeed = errors.New("change feed is not enabled")
+	// ErrSubscriberBehind is returned by a subscription dropped for not
+	// keeping up with the primary's changes
+	ErrSubscriberBehind = errors.New("change feed subscriber fell behind")
+	// ErrInvalidChange is returned for a change record that cannot be read
+	ErrInvalidChange = errors.New("invalid vault change record")
+)
+
+// DefaultChangeBuffer is the number of changes a subscriber may fall behind
+// before it is dropped
+const DefaultChangeBuffer = 10000
+
+// ChangeFeed publishes vault mutations to subscribers
+type ChangeFeed struct {
+	buffer int
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+}
+
+// NewChangeFeed creates a feed whose subscribers may fall buffer changes
+// behind, or DefaultChangeBuffer if buffer is zero
+func NewChangeFeed(buffer int) *ChangeFeed {
+	if buffer <= 0 {
+		buffer = DefaultChangeBuffer
+	}
+	return &ChangeFeed{buffer: buffer, subs: make(map[*Subscription]struct{})}
+}
+
+// WithChangeFeed publishes every vault mutation to f
+func WithChangeFeed(f *ChangeFeed) Option {
+	return func(s *Service) { s.feed = f }
+}
+
+// PublishesChanges reports whether the service has a change feed
+func (s *Service) PublishesChanges() bool {
+	return s.feed != nil
+}
+
+// Subscribers returns the number of open subscriptions
+func (f *ChangeFeed) Subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs)
+}
+
+// Subscription receives the changes published after it was opened
+type Subscription struct {
+	feed *ChangeFeed
+	ch   chan []byte
+	err  error
+}
+
+// Changes returns the channel of encoded change records. It is closed when
+// the subscription is closed or dropped.
+func (sub *Subscription) Changes() <-chan []byte {
+	return sub.ch
+}
+
+// Err returns ErrSubscriberBehind once the subscription has been dropped
+func (sub *Subscription) Err() error {
+	sub.feed.mu.Lock()
+	defer sub.feed.mu.Unlock()
+	return sub.err
+}
+
+// Close ends the subscription
+func (sub *Subscription) Close() {
+	sub.feed.mu.Lock()
+	defer sub.feed.mu.Unlock()
+	sub.feed.removeLocked(sub)
+}
+
+func (f *ChangeFeed) subscribe() *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub := &Subscription{feed: f, ch: make(chan []byte, f.buffer)}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+// publish hands a record to every subscriber. It never blocks, as the caller
+// holds the lock of the entry the record describes: a subscriber whose
+// buffer is full is dropped and must resubscribe.
+func (f *ChangeFeed) publish(line []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		select {
+		case sub.ch <- line:
+		default:
+			sub.err = ErrSubscriberBehind
+			f.removeLocked(sub)
+		}
+	}
+}
+
+func (f *ChangeFeed) removeLocked(sub *Subscription) {
+	if _, ok := f.subs[sub]; ok {
+		delete(f.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// SubscribeChanges returns a snapshot of the vault and a subscription to the
+// changes made after it. The subscription is opened before the snapshot is
+// taken, so a change may be in both; records are whole entry states, so
+// applying it twice changes nothing. Close the subscription when done.
+func (s *Service) SubscribeChanges() ([][]byte, *Subscription, error) {
+	if s.feed == nil {
+		return nil, nil, ErrNoChangeFeed
+	}
+	sub := s.feed.subscribe()
+	snapshot, err := s.snapshot()
+	if err != nil {
+		sub.Close()
+		return nil, nil, err
+	}
+	return snapshot, sub, nil
+}
+
+// ResetVault empties the vault. A replica calls it before loading a new
+// snapshot from its primary.
+func (s *Service) ResetVault() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]*TokenData)
+	s.panHashIndex = make(map[string]string)
+	s.dataKeys = make(map[string]*dataKey)
+	s.networkTokens = make(map[string]*NetworkToken)
+	s.tokenLinks = make(map[string]*TokenLink)
+	s.parIndex = make(map[string]string)
+	if s.capacity != nil {
+		s.capacity.bytes = 0
+		s.updateCapacityStateLocked()
+	}
+}
+
+// ApplyChange applies a change record from a primary's feed
+func (s *Service) ApplyChange(record []byte) error {
+	var rec walRecord
+	if err := json.Unmarshal(record, &rec); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChange, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyLocked(rec)
+	return nil
+}
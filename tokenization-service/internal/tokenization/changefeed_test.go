@@ -0,0 +1,80 @@
+package tokenization
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChangeFeedReplicatesVault(t *testing.T) {
+	primary := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithChangeFeed(NewChangeFeed(0)), WithKeyScope(KeyScopeMerchant))
+	year := time.Now().Year() + 2
+	kept, _ := primary.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	snapshot, sub, err := primary.SubscribeChanges()
+	if err != nil {
+		t.Fatalf("SubscribeChanges() error = %v", err)
+	}
+	defer sub.Close()
+	deleted, _ := primary.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	primary.DeleteToken(deleted.Token, "m2", AnyRevision)
+	nt, _ := primary.ProvisionNetworkToken(kept.Token, "m1")
+
+	replica := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	replica.TokenizeCardWithOptions("371449635398431", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	replica.ResetVault()
+	for _, record := range snapshot {
+		if err := replica.ApplyChange(record); err != nil {
+			t.Fatalf("ApplyChange() of snapshot error = %v", err)
+		}
+	}
+	for len(sub.Changes()) > 0 {
+		if err := replica.ApplyChange(<-sub.Changes()); err != nil {
+			t.Fatalf("ApplyChange() error = %v", err)
+		}
+	}
+
+	if got, want := replica.Stats(), primary.Stats(); got.Tokens != want.Tokens || got.DataKeys != want.DataKeys || got.NetworkTokens != want.NetworkTokens {
+		t.Errorf("replica Stats() = %d tokens, %d data keys, %d network tokens, want %d, %d, %d",
+			got.Tokens, got.DataKeys, got.NetworkTokens, want.Tokens, want.DataKeys, want.NetworkTokens)
+	}
+	if valid, _ := replica.ValidateTokenForMerchant(kept.Token, "m1"); !valid {
+		t.Error("replicated token is not valid")
+	}
+	if valid, _ := replica.ValidateTokenForMerchant(deleted.Token, "m2"); valid {
+		t.Error("token deleted on the primary is valid on the replica")
+	}
+	if got, err := replica.VaultTokenFor(nt.Token, "m1"); err != nil || got.Token != kept.Token {
+		t.Errorf("replica VaultTokenFor() = %v, %v, want %q", got, err, kept.Token)
+	}
+
+	if err := replica.ApplyChange([]byte("{")); !errors.Is(err, ErrInvalidChange) {
+		t.Errorf("ApplyChange() of a malformed record error = %v, want %v", err, ErrInvalidChange)
+	}
+}
+
+func TestChangeFeedDropsSlowSubscriber(t *testing.T) {
+	feed := NewChangeFeed(1)
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithChangeFeed(feed))
+	year := time.Now().Year() + 2
+
+	_, sub, _ := service.SubscribeChanges()
+	service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	if feed.Subscribers() != 0 {
+		t.Errorf("Subscribers() = %d, want the slow subscriber dropped", feed.Subscribers())
+	}
+	<-sub.Changes()
+	if _, open := <-sub.Changes(); open {
+		t.Error("dropped subscription's channel is still open")
+	}
+	if err := sub.Err(); err != ErrSubscriberBehind {
+		t.Errorf("Err() = %v, want %v", err, ErrSubscriberBehind)
+	}
+	sub.Close()
+
+	if _, _, err := NewService(&MockHSMClient{}, "test-key", 24*time.Hour).SubscribeChanges(); err != ErrNoChangeFeed {
+		t.Errorf("SubscribeChanges() without a feed error = %v, want %v", err, ErrNoChangeFeed)
+	}
+}
@@ -0,0 +1,65 @@
+package tokenization
+
+import (
+	"github.com/paymentgateway/go-common/ciphertext"
+)
+
+// EnvelopeCipher is implemented by HSM clients that encrypt into
+// self-describing ciphertext envelopes (see go-common/ciphertext). Clients
+// without it get envelopes assembled from Encrypt's output, which works for
+// any HSM that seals with AES-256-GCM.
+type EnvelopeCipher interface {
+	EncryptEnvelope(keyID string, plaintext, aad []byte) (envelope []byte, keyVersion int, err error)
+	DecryptEnvelope(envelope, aad []byte) ([]byte, error)
+}
+
+// WithCiphertextEnvelopes stores the PANs the HSM key encrypts directly as
+// ciphertext envelopes naming their key, version and nonce, so a record can
+// be decrypted after key rotations and migrations from EncryptedPAN alone.
+// Nonce is left empty and KeyVersion mirrors the envelope. Records written
+// without envelopes are still read, and PANs under data keys are unchanged.
+func WithCiphertextEnvelopes() Option {
+	return func(s *Service) { s.ciphertextEnvelopes = true }
+}
+
+// sealPAN encrypts a PAN under the HSM key, as an envelope if configured
+func (s *Service) sealPAN(plaintext, aad []byte) (sealed, nonce []byte, keyVersion int, err error) {
+	if !s.ciphertextEnvelopes {
+		return s.hsmClient.Encrypt(s.keyID, plaintext, aad)
+	}
+	if c, ok := s.hsmClient.(EnvelopeCipher); ok {
+		sealed, keyVersion, err = c.EncryptEnvelope(s.keyID, plaintext, aad)
+		return sealed, nil, keyVersion, err
+	}
+
+	sealed, nonce, keyVersion, err = s.hsmClient.Encrypt(s.keyID, plaintext, aad)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	e, err := ciphertext.Split(s.keyID, keyVersion, ciphertext.AlgorithmAES256GCM, nonce, sealed)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if sealed, err = e.Marshal(); err != nil {
+		return nil, nil, 0, err
+	}
+	return sealed, nil, keyVersion, nil
+}
+
+// openPAN decrypts a PAN the HSM key encrypted directly. An envelope is
+// opened with the key it names, which need not be the service's current
+// one; any other record with the service key and its stored nonce.
+func (s *Service) openPAN(tokenData *TokenData, aad []byte) ([]byte, error) {
+	if len(tokenData.Nonce) > 0 || !ciphertext.IsEnvelope(tokenData.EncryptedPAN) {
+		return s.hsmClient.Decrypt(s.keyID, tokenData.EncryptedPAN, tokenData.Nonce, aad, tokenData.KeyVersion)
+	}
+	if c, ok := s.hsmClient.(EnvelopeCipher); ok {
+		return c.DecryptEnvelope(tokenData.EncryptedPAN, aad)
+	}
+
+	e, err := ciphertext.Parse(tokenData.EncryptedPAN)
+	if err != nil {
+		return nil, err
+	}
+	return s.hsmClient.Decrypt(e.KeyID, e.Sealed(), e.Nonce, aad, e.KeyVersion)
+}
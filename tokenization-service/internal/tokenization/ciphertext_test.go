@@ -0,0 +1,43 @@
+package tokenization
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/ciphertext"
+)
+
+func TestCiphertextEnvelopes(t *testing.T) {
+	hsm := newGCMHSM(t)
+	legacy := NewService(hsm, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	old, err := legacy.TokenizeCard("5425233430109903", 12, year, "123")
+	if err != nil {
+		t.Fatalf("TokenizeCard() error = %v", err)
+	}
+
+	service := NewService(hsm, "test-key", 24*time.Hour, WithCiphertextEnvelopes())
+	tokenData, err := service.TokenizeCard("4532015112830366", 12, year, "123")
+	if err != nil {
+		t.Fatalf("TokenizeCard() error = %v", err)
+	}
+	if tokenData.Nonce != nil {
+		t.Errorf("Nonce = %x, want none beside an envelope", tokenData.Nonce)
+	}
+	e, err := ciphertext.Parse(tokenData.EncryptedPAN)
+	if err != nil {
+		t.Fatalf("EncryptedPAN is not an envelope: %v", err)
+	}
+	if e.KeyID != "test-key" || e.KeyVersion != tokenData.KeyVersion {
+		t.Errorf("envelope names %s v%d, want test-key v%d", e.KeyID, e.KeyVersion, tokenData.KeyVersion)
+	}
+	if pan, _, _, err := service.DetokenizeCard(tokenData.Token); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCard() = %q, %v", pan, err)
+	}
+
+	// A record stored with its nonce still decrypts
+	service.tokens[old.Token] = old
+	if pan, _, _, err := service.DetokenizeCard(old.Token); err != nil || pan != "5425233430109903" {
+		t.Errorf("DetokenizeCard() of a legacy record = %q, %v", pan, err)
+	}
+}
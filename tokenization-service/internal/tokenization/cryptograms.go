@@ -0,0 +1,170 @@
+package tokenization
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Token cryptograms stand in for the cryptograms (Visa TAVV, Mastercard
+// DSRP) a network token service issues with each use of a network token, so
+// the network can tell a token presented by its requestor from a token number
+// copied off a receipt. The simulator computes them like EMV application
+// cryptograms, with the HSM's ARQC commands: the network token is the card
+// number and each token keeps its own application transaction counter (ATC).
+
+var (
+	ErrCryptogramsUnavailable = errors.New("token cryptograms are not enabled")
+	ErrInvalidCryptogram      = errors.New("invalid token cryptogram")
+)
+
+// CryptogramLen is the length of a token cryptogram: the 2-byte ATC it was
+// generated at followed by the 8-byte application cryptogram
+const CryptogramLen = 10
+
+// CryptogramHSM is implemented by HSM clients that compute and verify EMV
+// application cryptograms. VerifyARQC returns an error wrapping
+// ErrInvalidCryptogram when the HSM rejects the cryptogram, so callers can
+// tell a bad cryptogram from an HSM outage.
+type CryptogramHSM interface {
+	GenerateARQC(keyID, pan string, atc uint16, txnData []byte) ([]byte, error)
+	VerifyARQC(keyID, pan string, atc uint16, txnData, arqc []byte) error
+}
+
+// CryptogramTransaction is what a cryptogram is bound to besides the token:
+// the amount in minor units and its currency
+type CryptogramTransaction struct {
+	Amount   int64
+	Currency string
+}
+
+// WithCryptograms makes the service issue and validate network token
+// cryptograms under the HSM key keyID, a CVK or DEK. The HSM client must
+// implement CryptogramHSM.
+func WithCryptograms(keyID string) Option {
+	return func(s *Service) { s.cryptogramKey = keyID }
+}
+
+// IssuesCryptograms reports whether token cryptograms are enabled
+func (s *Service) IssuesCryptograms() bool {
+	_, ok := s.hsmClient.(CryptogramHSM)
+	return ok && s.cryptogramKey != ""
+}
+
+// GenerateCryptogram issues the cryptogram for the next use of a network
+// token of the merchant in a transaction, as the token's requestor would
+// receive it
+func (s *Service) GenerateCryptogram(networkToken, merchantID string, txn CryptogramTransaction) ([]byte, error) {
+	h, ok := s.hsmClient.(CryptogramHSM)
+	if !ok || s.cryptogramKey == "" {
+		return nil, ErrCryptogramsUnavailable
+	}
+	txnData, err := cryptogramData(merchantID, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	nt, err := s.usableNetworkTokenLocked(networkToken, merchantID)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if nt.ATC == math.MaxUint16 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: transaction counter exhausted, provision a new network token", ErrInvalidCryptogram)
+	}
+	nt.ATC++
+	atc := nt.ATC
+	s.logNetworkToken(nt)
+	s.mu.Unlock()
+
+	arqc, err := h.GenerateARQC(s.cryptogramKey, networkToken, atc, txnData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncryptionFailed, err)
+	}
+	cryptogram := make([]byte, 2, CryptogramLen)
+	binary.BigEndian.PutUint16(cryptogram, atc)
+	return append(cryptogram, arqc...), nil
+}
+
+// ValidateCryptogram checks the cryptogram presented with a network token of
+// the merchant for a transaction. Each cryptogram is accepted once, and not
+// after a later one of the same token.
+func (s *Service) ValidateCryptogram(networkToken, merchantID string, txn CryptogramTransaction, cryptogram []byte) error {
+	h, ok := s.hsmClient.(CryptogramHSM)
+	if !ok || s.cryptogramKey == "" {
+		return ErrCryptogramsUnavailable
+	}
+	if len(cryptogram) != CryptogramLen {
+		return fmt.Errorf("%w: must be %d bytes", ErrInvalidCryptogram, CryptogramLen)
+	}
+	txnData, err := cryptogramData(merchantID, txn)
+	if err != nil {
+		return err
+	}
+	atc := binary.BigEndian.Uint16(cryptogram)
+
+	s.mu.RLock()
+	nt, err := s.usableNetworkTokenLocked(networkToken, merchantID)
+	var issued, validated uint16
+	if err == nil {
+		issued, validated = nt.ATC, nt.ValidatedATC
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if atc == 0 || atc > issued {
+		return fmt.Errorf("%w: not issued for this token", ErrInvalidCryptogram)
+	}
+	if atc <= validated {
+		return fmt.Errorf("%w: already used", ErrInvalidCryptogram)
+	}
+
+	if err := h.VerifyARQC(s.cryptogramKey, networkToken, atc, txnData, cryptogram[2:]); err != nil {
+		if errors.Is(err, ErrInvalidCryptogram) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+
+	// Another validation of the same cryptogram may have won the race
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if atc <= nt.ValidatedATC {
+		return fmt.Errorf("%w: already used", ErrInvalidCryptogram)
+	}
+	nt.ValidatedATC = atc
+	s.logNetworkToken(nt)
+	return nil
+}
+
+// usableNetworkTokenLocked finds a network token of the merchant that can be
+// used in a transaction. s.mu must be held.
+func (s *Service) usableNetworkTokenLocked(networkToken, merchantID string) (*NetworkToken, error) {
+	nt, ok := s.networkTokens[networkToken]
+	if !ok || nt.MerchantID != merchantID || nt.Status == NetworkTokenDeleted {
+		return nil, ErrNetworkTokenNotFound
+	}
+	if nt.Status == NetworkTokenSuspended {
+		return nil, ErrNetworkTokenSuspended
+	}
+	return nt, nil
+}
+
+// cryptogramData is the transaction data a cryptogram covers: the amount as
+// 8 bytes, the currency code and the merchant ID
+func cryptogramData(merchantID string, txn CryptogramTransaction) ([]byte, error) {
+	if txn.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidCryptogram)
+	}
+	if len(txn.Currency) != 3 || strings.ToUpper(txn.Currency) != txn.Currency {
+		return nil, fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidCryptogram)
+	}
+	data := binary.BigEndian.AppendUint64(nil, uint64(txn.Amount))
+	data = append(data, txn.Currency...)
+	return append(data, merchantID...), nil
+}
@@ -0,0 +1,142 @@
+package tokenization
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cryptogramHSM computes cryptograms with HMAC in place of the HSM's
+// session-key CMAC
+type cryptogramHSM struct {
+	MockHSMClient
+}
+
+func (h *cryptogramHSM) GenerateARQC(keyID, pan string, atc uint16, txnData []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, []byte(keyID))
+	mac.Write([]byte(pan))
+	mac.Write(binary.BigEndian.AppendUint16(nil, atc))
+	mac.Write(txnData)
+	return mac.Sum(nil)[:8], nil
+}
+
+func (h *cryptogramHSM) VerifyARQC(keyID, pan string, atc uint16, txnData, arqc []byte) error {
+	want, _ := h.GenerateARQC(keyID, pan, atc, txnData)
+	if !hmac.Equal(want, arqc) {
+		return ErrInvalidCryptogram
+	}
+	return nil
+}
+
+func provisionTestNetworkToken(t *testing.T, service *Service) *NetworkToken {
+	t.Helper()
+	vault, err := service.TokenizeCardWithOptions("4532015112830366", 12, time.Now().Year()+2, "123",
+		TokenizeOptions{MerchantID: "m1"})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	nt, err := service.ProvisionNetworkToken(vault.Token, "m1")
+	if err != nil {
+		t.Fatalf("ProvisionNetworkToken() error = %v", err)
+	}
+	return nt
+}
+
+func TestTokenCryptograms(t *testing.T) {
+	service := NewService(&cryptogramHSM{}, "test-key", 24*time.Hour, WithCryptograms("cvk"))
+	nt := provisionTestNetworkToken(t, service)
+	txn := CryptogramTransaction{Amount: 1999, Currency: "USD"}
+
+	first, err := service.GenerateCryptogram(nt.Token, "m1", txn)
+	if err != nil || len(first) != CryptogramLen {
+		t.Fatalf("GenerateCryptogram() = %x, %v", first, err)
+	}
+	second, _ := service.GenerateCryptogram(nt.Token, "m1", txn)
+
+	tampered := append([]byte(nil), second...)
+	tampered[CryptogramLen-1] ^= 1
+	unissued := append([]byte(nil), second...)
+	binary.BigEndian.PutUint16(unissued, 9)
+
+	tests := []struct {
+		name       string
+		merchantID string
+		txn        CryptogramTransaction
+		cryptogram []byte
+		err        error
+	}{
+		{"other amount", "m1", CryptogramTransaction{Amount: 2999, Currency: "USD"}, second, ErrInvalidCryptogram},
+		{"other merchant", "m2", txn, second, ErrNetworkTokenNotFound},
+		{"tampered", "m1", txn, tampered, ErrInvalidCryptogram},
+		{"not issued", "m1", txn, unissued, ErrInvalidCryptogram},
+		{"short", "m1", txn, second[:8], ErrInvalidCryptogram},
+		{"valid", "m1", txn, second, nil},
+		{"replayed", "m1", txn, second, ErrInvalidCryptogram},
+		{"older than the last accepted", "m1", txn, first, ErrInvalidCryptogram},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.ValidateCryptogram(nt.Token, tt.merchantID, tt.txn, tt.cryptogram); !errors.Is(err, tt.err) {
+				t.Errorf("ValidateCryptogram() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := service.ApplyCardEvent(nt.Token, CardEventSuspend); err != nil {
+		t.Fatalf("ApplyCardEvent() error = %v", err)
+	}
+	if _, err := service.GenerateCryptogram(nt.Token, "m1", txn); !errors.Is(err, ErrNetworkTokenSuspended) {
+		t.Errorf("GenerateCryptogram() for a suspended token error = %v, want %v", err, ErrNetworkTokenSuspended)
+	}
+}
+
+func TestTokenCryptogramsUnavailable(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithCryptograms("cvk"))
+	nt := provisionTestNetworkToken(t, service)
+	if service.IssuesCryptograms() {
+		t.Error("IssuesCryptograms() = true for an HSM client without cryptogram commands")
+	}
+	if _, err := service.GenerateCryptogram(nt.Token, "m1", CryptogramTransaction{Amount: 100, Currency: "EUR"}); !errors.Is(err, ErrCryptogramsUnavailable) {
+		t.Errorf("GenerateCryptogram() error = %v, want %v", err, ErrCryptogramsUnavailable)
+	}
+}
+
+// openCryptogramWAL opens a service with cryptograms over the log at path
+func openCryptogramWAL(t *testing.T, path string) *Service {
+	t.Helper()
+	wal, err := OpenWAL(path, WALConfig{Sync: true})
+	if err != nil {
+		t.Fatalf("OpenWAL() error = %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	service := NewService(&cryptogramHSM{}, "test-key", 24*time.Hour, WithWAL(wal), WithCryptograms("cvk"))
+	if _, err := service.RecoverWAL(); err != nil {
+		t.Fatalf("RecoverWAL() error = %v", err)
+	}
+	return service
+}
+
+func TestTokenCryptogramCounterRecovered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	service := openCryptogramWAL(t, path)
+	nt := provisionTestNetworkToken(t, service)
+	txn := CryptogramTransaction{Amount: 500, Currency: "GBP"}
+	cryptogram, _ := service.GenerateCryptogram(nt.Token, "m1", txn)
+	if err := service.ValidateCryptogram(nt.Token, "m1", txn, cryptogram); err != nil {
+		t.Fatalf("ValidateCryptogram() error = %v", err)
+	}
+
+	// A restart neither reissues nor re-accepts a counter
+	recovered := openCryptogramWAL(t, path)
+	if err := recovered.ValidateCryptogram(nt.Token, "m1", txn, cryptogram); !errors.Is(err, ErrInvalidCryptogram) {
+		t.Errorf("ValidateCryptogram() of a used cryptogram after recovery error = %v, want %v", err, ErrInvalidCryptogram)
+	}
+	next, _ := recovered.GenerateCryptogram(nt.Token, "m1", txn)
+	if atc := binary.BigEndian.Uint16(next); atc != 2 {
+		t.Errorf("ATC after recovery = %d, want 2", atc)
+	}
+}
@@ -0,0 +1,383 @@
+package tokenization
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+var (
+	ErrKeyShredded      = errors.New("data key shredded")
+	ErrShredUnsupported = errors.New("shredding not supported by key scope")
+)
+
+// KeyScope selects how PANs are encrypted. With a data-key scope each PAN is
+// encrypted locally under a data-encryption key (DEK) that is itself
+// encrypted ("wrapped") by the HSM master key. Destroying the wrapped DEK
+// crypto-shreds every PAN under it, including copies in backups.
+type KeyScope int
+
+const (
+	// KeyScopeNone sends every PAN to the HSM and uses no data keys
+	KeyScopeNone KeyScope = iota
+	// KeyScopeMerchant shares one DEK per merchant, so a merchant can be
+	// shredded but not a single card
+	KeyScopeMerchant
+	// KeyScopeToken gives every token its own DEK, so merchants and cards
+	// can both be shredded
+	KeyScopeToken
+)
+
+// ParseKeyScope parses "", "none", "merchant" or "token"
+func ParseKeyScope(s string) (KeyScope, error) {
+	switch s {
+	case "", "none":
+		return KeyScopeNone, nil
+	case "merchant":
+		return KeyScopeMerchant, nil
+	case "token":
+		return KeyScopeToken, nil
+	}
+	return KeyScopeNone, fmt.Errorf("unknown key scope %q", s)
+}
+
+func (k KeyScope) String() string {
+	switch k {
+	case KeyScopeMerchant:
+		return "merchant"
+	case KeyScopeToken:
+		return "token"
+	}
+	return "none"
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithKeyScope encrypts PANs under HSM-wrapped data keys of the given scope
+func WithKeyScope(scope KeyScope) Option {
+	return func(s *Service) { s.keyScope = scope }
+}
+
+// DataKeyGenerator is implemented by HSM clients that generate data keys,
+// returning the key in plaintext and wrapped in a single call. Clients
+// without it get a locally generated key wrapped with Encrypt.
+type DataKeyGenerator interface {
+	GenerateDataKey(keyID string, aad []byte) (plaintext, wrapped, nonce []byte, keyVersion int, err error)
+}
+
+// EnvelopeConfig bounds how long one envelope data key is used before a new
+// one is requested. Zero fields take the defaults.
+type EnvelopeConfig struct {
+	MaxUses int
+	MaxAge  time.Duration
+}
+
+// Envelope key limits used when EnvelopeConfig leaves them zero
+const (
+	DefaultEnvelopeMaxUses = 10000
+	DefaultEnvelopeMaxAge  = time.Hour
+)
+
+// WithEnvelope enables envelope encryption for services without a data-key
+// scope: PANs are encrypted locally under a data key from the HSM that is
+// reused for many tokens, so tokenizing costs one HSM round trip per
+// MaxUses tokens instead of one per token. Each token stores the ID of its
+// wrapped data key.
+func WithEnvelope(cfg EnvelopeConfig) Option {
+	if cfg.MaxUses <= 0 {
+		cfg.MaxUses = DefaultEnvelopeMaxUses
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultEnvelopeMaxAge
+	}
+	return func(s *Service) { s.envelope = &envelope{config: cfg} }
+}
+
+// envelope holds the data key currently used to encrypt new tokens
+type envelope struct {
+	config  EnvelopeConfig
+	mu      sync.Mutex
+	keyID   string
+	dek     []byte
+	uses    int
+	expires time.Time
+}
+
+// dataKey is a wrapped DEK. The plaintext key is never stored.
+type dataKey struct {
+	ID         string
+	MerchantID string
+	Wrapped    []byte
+	Nonce      []byte
+	KeyVersion int
+	CreatedAt  time.Time
+}
+
+// ShredResult reports what a shred destroyed
+type ShredResult struct {
+	// Tokens are the tokens whose PANs are no longer recoverable
+	Tokens []string
+	// Keys is the number of data keys destroyed
+	Keys int
+}
+
+// KeyScope returns how the service encrypts PANs
+func (s *Service) KeyScope() KeyScope {
+	return s.keyScope
+}
+
+// Envelope reports whether envelope encryption is enabled
+func (s *Service) Envelope() bool {
+	return s.envelope != nil && s.keyScope == KeyScopeNone
+}
+
+// usesDataKeys reports whether new tokens are encrypted under data keys
+// rather than by the HSM directly
+func (s *Service) usesDataKeys() bool {
+	return s.keyScope != KeyScopeNone || s.envelope != nil
+}
+
+// ShredMerchant destroys the data keys of every token issued to the
+// merchant. The tokens are deactivated and their ciphertexts kept, but no
+// longer decryptable.
+func (s *Service) ShredMerchant(merchantID string) (*ShredResult, error) {
+	if s.keyScope == KeyScopeNone {
+		return nil, ErrShredUnsupported
+	}
+	return s.shred(func(t *TokenData) bool { return t.MerchantID == merchantID }), nil
+}
+
+// ShredCard destroys the data keys of every token of the card with the
+// given fingerprint, across all merchants. Requires KeyScopeToken, since a
+// merchant key also protects other cards.
+func (s *Service) ShredCard(fingerprint string) (*ShredResult, error) {
+	if !fingerprintPattern.MatchString(fingerprint) {
+		return nil, ErrInvalidFingerprint
+	}
+	if s.keyScope != KeyScopeToken {
+		return nil, ErrShredUnsupported
+	}
+	return s.shred(func(t *TokenData) bool { return securebytes.EqualString(t.PANHash, fingerprint) }), nil
+}
+
+func (s *Service) shred(match func(*TokenData) bool) *ShredResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := &ShredResult{}
+	for token, tokenData := range s.tokens {
+		if tokenData.DataKeyID == "" || !match(tokenData) {
+			continue
+		}
+		if _, ok := s.dataKeys[tokenData.DataKeyID]; ok {
+			s.destroyDataKeyLocked(tokenData.DataKeyID)
+			res.Keys++
+		}
+		tokenData.mu.Lock()
+		if tokenData.IsActive {
+			tokenData.IsActive = false
+			tokenData.RevokedAt = time.Now()
+			s.updated(tokenData)
+		}
+		tokenData.mu.Unlock()
+		res.Tokens = append(res.Tokens, token)
+	}
+	return res
+}
+
+// encryptPAN encrypts a PAN under a data key of the service's scope and
+// returns the ciphertext, nonce and key ID. s.mu must not be held.
+func (s *Service) encryptPAN(pan, aad []byte, merchantID string) (ciphertext, nonce []byte, keyID string, err error) {
+	dek, keyID, err := s.dataKeyFor(merchantID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer securebytes.Zero(dek)
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nil, nonce, pan, aad), nonce, keyID, nil
+}
+
+// decryptPAN unwraps the token's data key and decrypts its PAN
+func (s *Service) decryptPAN(tokenData *TokenData, aad []byte) ([]byte, error) {
+	s.mu.RLock()
+	key, ok := s.dataKeys[tokenData.DataKeyID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrKeyShredded
+	}
+
+	dek, err := s.unwrap(key)
+	if err != nil {
+		return nil, err
+	}
+	defer securebytes.Zero(dek)
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, tokenData.Nonce, tokenData.EncryptedPAN, aad)
+}
+
+// dataKeyFor returns the plaintext DEK to encrypt a new token with, creating
+// and wrapping one when the scope has none yet. The caller owns the returned
+// slice and zeroizes it.
+func (s *Service) dataKeyFor(merchantID string) (dek []byte, keyID string, err error) {
+	if s.keyScope == KeyScopeNone {
+		return s.envelopeKey()
+	}
+	if s.keyScope == KeyScopeMerchant {
+		s.mu.RLock()
+		key, ok := s.dataKeys[merchantKeyID(merchantID)]
+		s.mu.RUnlock()
+		if ok {
+			dek, err := s.unwrap(key)
+			return dek, key.ID, err
+		}
+	}
+
+	keyID = merchantKeyID(merchantID)
+	if s.keyScope == KeyScopeToken {
+		if keyID, err = randomKeyID("token"); err != nil {
+			return nil, "", err
+		}
+	}
+	dek, err = s.newDataKey(keyID, merchantID)
+	return dek, keyID, err
+}
+
+// envelopeKey returns a copy of the current envelope data key, replacing it
+// with a new one from the HSM once it has been used MaxUses times or is
+// MaxAge old. The replaced key is zeroized.
+func (s *Service) envelopeKey() (dek []byte, keyID string, err error) {
+	e := s.envelope
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.dek != nil && e.uses < e.config.MaxUses && time.Now().Before(e.expires) {
+		e.uses++
+		return securebytes.Clone(e.dek), e.keyID, nil
+	}
+
+	keyID, err = randomKeyID("envelope")
+	if err != nil {
+		return nil, "", err
+	}
+	dek, err = s.newDataKey(keyID, "")
+	if err != nil {
+		return nil, "", err
+	}
+	securebytes.Zero(e.dek)
+	e.keyID, e.dek, e.uses, e.expires = keyID, dek, 1, time.Now().Add(e.config.MaxAge)
+	return securebytes.Clone(dek), keyID, nil
+}
+
+// newDataKey creates a DEK wrapped by the HSM, with the key ID bound as AAD,
+// and stores the wrapped key. If another request stored a key under the same
+// ID meanwhile, that key wins, so all of a merchant's tokens share one.
+func (s *Service) newDataKey(keyID, merchantID string) ([]byte, error) {
+	var (
+		dek, wrapped, nonce []byte
+		version             int
+		err                 error
+	)
+	if gen, ok := s.hsmClient.(DataKeyGenerator); ok {
+		dek, wrapped, nonce, version, err = gen.GenerateDataKey(s.keyID, []byte(keyID))
+	} else {
+		dek = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+		wrapped, nonce, version, err = s.hsmClient.Encrypt(s.keyID, dek, []byte(keyID))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	existing, ok := s.dataKeys[keyID]
+	if !ok {
+		s.dataKeys[keyID] = &dataKey{
+			ID:         keyID,
+			MerchantID: merchantID,
+			Wrapped:    wrapped,
+			Nonce:      nonce,
+			KeyVersion: version,
+			CreatedAt:  time.Now(),
+		}
+		s.logDataKey(s.dataKeys[keyID])
+	}
+	s.mu.Unlock()
+	if ok {
+		securebytes.Zero(dek)
+		return s.unwrap(existing)
+	}
+	if s.keyCache != nil {
+		s.keyCache.put(keyID, dek)
+	}
+	return dek, nil
+}
+
+// destroyDataKeyLocked deletes a wrapped key and any cached plaintext copy.
+// s.mu must be held.
+func (s *Service) destroyDataKeyLocked(keyID string) {
+	delete(s.dataKeys, keyID)
+	s.logRemoval(walDeleteDataKey, keyID)
+	if s.keyCache != nil {
+		s.keyCache.evict(keyID)
+	}
+}
+
+// unwrap returns the plaintext of a wrapped DEK, from the cache when enabled
+// or else from the HSM. The key ID is bound as AAD, so a wrapped key cannot
+// be swapped for another.
+func (s *Service) unwrap(key *dataKey) ([]byte, error) {
+	if s.keyCache != nil {
+		if dek, ok := s.keyCache.get(key.ID); ok {
+			return dek, nil
+		}
+	}
+	dek, err := s.hsmClient.Decrypt(s.keyID, key.Wrapped, key.Nonce, []byte(key.ID), key.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	if s.keyCache != nil {
+		s.keyCache.put(key.ID, dek)
+	}
+	return dek, nil
+}
+
+func merchantKeyID(merchantID string) string {
+	return "merchant:" + merchantID
+}
+
+func randomKeyID(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", fmt.Errorf("failed to generate data key ID: %w", err)
+	}
+	return prefix + ":" + hex.EncodeToString(b), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,182 @@
+package tokenization
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+// gcmHSM wraps with a real AES-256-GCM master key, so tampering and swapped
+// AAD are detected as they would be by the HSM
+type gcmHSM struct {
+	master   []byte
+	encrypts int
+	decrypts int
+}
+
+func newGCMHSM(t *testing.T) *gcmHSM {
+	master := make([]byte, 32)
+	if _, err := rand.Read(master); err != nil {
+		t.Fatal(err)
+	}
+	return &gcmHSM{master: master}
+}
+
+func (h *gcmHSM) Encrypt(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+	h.encrypts++
+	gcm, _ := newGCM(h.master)
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return gcm.Seal(nil, nonce, plaintext, aad), nonce, 1, nil
+}
+
+func (h *gcmHSM) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
+	h.decrypts++
+	gcm, _ := newGCM(h.master)
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func TestKeyScopeToken(t *testing.T) {
+	hsm := newGCMHSM(t)
+	service := NewService(hsm, "test-key", 24*time.Hour, WithKeyScope(KeyScopeToken))
+	year := time.Now().Year() + 2
+	pan := "4532015112830366"
+
+	a, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "merchant-a"})
+	b, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "merchant-b"})
+	other, err := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "merchant-a"})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	if a.DataKeyID == "" || a.DataKeyID == b.DataKeyID {
+		t.Errorf("tokens should have distinct data keys: %q, %q", a.DataKeyID, b.DataKeyID)
+	}
+	if bytes.Contains(a.EncryptedPAN, []byte(pan)) {
+		t.Error("stored ciphertext contains the PAN")
+	}
+	if got, _, _, err := service.DetokenizeCardForMerchant(a.Token, "merchant-a"); err != nil || got != pan {
+		t.Fatalf("DetokenizeCardForMerchant() = %v, %v; want %v", got, err, pan)
+	}
+
+	fingerprint, _ := Fingerprint(pan)
+	res, err := service.ShredCard(fingerprint)
+	if err != nil || len(res.Tokens) != 2 || res.Keys != 2 {
+		t.Fatalf("ShredCard() = %+v, %v; want 2 tokens and 2 keys", res, err)
+	}
+	if _, _, _, err := service.DetokenizeCardForMerchant(a.Token, "merchant-a"); err != ErrTokenNotFound {
+		t.Errorf("shredded token: error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if valid, _ := service.ValidateTokenForMerchant(b.Token, "merchant-b"); valid {
+		t.Error("shredded token still valid")
+	}
+
+	// The ciphertext survives, but nothing can decrypt it any more
+	if _, err := service.decryptPAN(a, []byte("12-2030")); !errors.Is(err, ErrKeyShredded) {
+		t.Errorf("decrypt after shred: error = %v, want %v", err, ErrKeyShredded)
+	}
+	if got, _, _, err := service.DetokenizeCardForMerchant(other.Token, "merchant-a"); err != nil || got != "5425233430109903" {
+		t.Errorf("other card: DetokenizeCardForMerchant() = %v, %v", got, err)
+	}
+}
+
+func TestKeyScopeMerchant(t *testing.T) {
+	hsm := newGCMHSM(t)
+	service := NewService(hsm, "test-key", 24*time.Hour, WithKeyScope(KeyScopeMerchant))
+	year := time.Now().Year() + 2
+
+	a, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	b, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	c, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	if a.DataKeyID != b.DataKeyID || a.DataKeyID == c.DataKeyID {
+		t.Errorf("merchant keys: m1 %q/%q, m2 %q", a.DataKeyID, b.DataKeyID, c.DataKeyID)
+	}
+	if hsm.encrypts != 2 {
+		t.Errorf("HSM wrapped %d keys, want one per merchant", hsm.encrypts)
+	}
+
+	fingerprint, _ := Fingerprint("4532015112830366")
+	if _, err := service.ShredCard(fingerprint); err != ErrShredUnsupported {
+		t.Errorf("ShredCard() error = %v, want %v", err, ErrShredUnsupported)
+	}
+	res, err := service.ShredMerchant("m1")
+	if err != nil || len(res.Tokens) != 2 || res.Keys != 1 {
+		t.Fatalf("ShredMerchant() = %+v, %v; want 2 tokens and 1 key", res, err)
+	}
+	if got, _, _, err := service.DetokenizeCardForMerchant(c.Token, "m2"); err != nil || got != "4532015112830366" {
+		t.Errorf("other merchant: DetokenizeCardForMerchant() = %v, %v", got, err)
+	}
+}
+
+func TestShredWithoutDataKeys(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	if _, err := service.ShredMerchant("m1"); err != ErrShredUnsupported {
+		t.Errorf("ShredMerchant() error = %v, want %v", err, ErrShredUnsupported)
+	}
+	if _, err := service.ShredCard("bad"); err != ErrInvalidFingerprint {
+		t.Errorf("ShredCard() error = %v, want %v", err, ErrInvalidFingerprint)
+	}
+	for in, want := range map[string]KeyScope{"": KeyScopeNone, "merchant": KeyScopeMerchant, "token": KeyScopeToken} {
+		if got, err := ParseKeyScope(in); err != nil || got != want {
+			t.Errorf("ParseKeyScope(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseKeyScope("card"); err == nil {
+		t.Error("ParseKeyScope(card) should fail")
+	}
+}
+
+// generatingHSM adds GenerateDataKey to gcmHSM
+type generatingHSM struct {
+	*gcmHSM
+	generated int
+}
+
+func (h *generatingHSM) GenerateDataKey(keyID string, aad []byte) ([]byte, []byte, []byte, int, error) {
+	h.generated++
+	dek := make([]byte, 32)
+	rand.Read(dek)
+	wrapped, nonce, version, err := h.gcmHSM.Encrypt(keyID, dek, aad)
+	return dek, wrapped, nonce, version, err
+}
+
+func TestEnvelope(t *testing.T) {
+	hsm := &generatingHSM{gcmHSM: newGCMHSM(t)}
+	service := NewService(hsm, "test-key", 24*time.Hour, WithEnvelope(EnvelopeConfig{MaxUses: 2}))
+	if !service.Envelope() {
+		t.Fatal("Envelope() = false")
+	}
+	year := time.Now().Year() + 2
+
+	pans := []string{"4532015112830366", "5425233430109903", "378282246310005", "6011000000000004", "4111111111111111"}
+	var tokens []*TokenData
+	var firstKey []byte
+	for _, pan := range pans {
+		tokenData, err := service.TokenizeCard(pan, 12, year, "123")
+		if err != nil {
+			t.Fatalf("TokenizeCard() error = %v", err)
+		}
+		tokens = append(tokens, tokenData)
+		if firstKey == nil {
+			firstKey = service.envelope.dek
+		}
+	}
+	if !bytes.Equal(firstKey, make([]byte, 32)) {
+		t.Error("rotated envelope key not zeroized")
+	}
+
+	// Five tokens at two per key take three data keys and no per-PAN HSM
+	// encryption
+	if hsm.generated != 3 || hsm.encrypts != 3 {
+		t.Errorf("HSM generated %d data keys with %d encryptions, want 3 and 3", hsm.generated, hsm.encrypts)
+	}
+	if tokens[0].DataKeyID != tokens[1].DataKeyID || tokens[1].DataKeyID == tokens[2].DataKeyID {
+		t.Errorf("data keys not rotated after MaxUses: %q %q %q", tokens[0].DataKeyID, tokens[1].DataKeyID, tokens[2].DataKeyID)
+	}
+	for i, tokenData := range tokens {
+		if got, _, _, err := service.DetokenizeCard(tokenData.Token); err != nil || got != pans[i] {
+			t.Errorf("DetokenizeCard(%s) = %v, %v; want %v", tokenData.Token, got, err, pans[i])
+		}
+	}
+}
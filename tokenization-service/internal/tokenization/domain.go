@@ -0,0 +1,194 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Domain controls restrict where a vault token may be used, as card networks
+// restrict network tokens to a domain: the channels it is presented in, the
+// merchants accepting it and the token requestors (wallets, card-on-file
+// services) presenting it. A token without controls may be used anywhere its
+// merchant scope allows.
+
+var (
+	ErrInvalidDomain    = errors.New("invalid token domain")
+	ErrDomainRestricted = errors.New("token used outside its domain")
+)
+
+// Channels a token can be restricted to
+const (
+	ChannelEcommerce = "ecommerce"
+	ChannelInStore   = "in-store"
+	ChannelMOTO      = "moto"
+)
+
+// Domain limits
+const (
+	MaxDomainEntries = 20
+	MaxDomainIDLen   = 64
+)
+
+// Domain lists what a token may be used with. An empty list does not
+// restrict; a non-empty one only admits uses naming one of its entries.
+type Domain struct {
+	Channels     []string `json:",omitempty"`
+	MerchantIDs  []string `json:",omitempty"`
+	RequestorIDs []string `json:",omitempty"`
+}
+
+// TokenUse describes where a token is being used. MerchantID is the
+// accepting merchant, which defaults to the token's merchant scope.
+type TokenUse struct {
+	Channel     string
+	MerchantID  string
+	RequestorID string
+}
+
+// IsZero reports whether the domain restricts nothing
+func (d *Domain) IsZero() bool {
+	return d == nil || len(d.Channels) == 0 && len(d.MerchantIDs) == 0 && len(d.RequestorIDs) == 0
+}
+
+// Check refuses a use outside the domain, saying which control it fails. A
+// use that leaves out a restricted field is refused.
+func (d *Domain) Check(use TokenUse) error {
+	if d.IsZero() {
+		return nil
+	}
+	if len(d.Channels) > 0 && !contains(d.Channels, use.Channel) {
+		return fmt.Errorf("%w: channel %q not allowed", ErrDomainRestricted, use.Channel)
+	}
+	if len(d.MerchantIDs) > 0 && !contains(d.MerchantIDs, use.MerchantID) {
+		return fmt.Errorf("%w: merchant %q not allowed", ErrDomainRestricted, use.MerchantID)
+	}
+	if len(d.RequestorIDs) > 0 && !contains(d.RequestorIDs, use.RequestorID) {
+		return fmt.Errorf("%w: token requestor %q not allowed", ErrDomainRestricted, use.RequestorID)
+	}
+	return nil
+}
+
+// Equal reports whether two domains admit the same uses
+func (d *Domain) Equal(o *Domain) bool {
+	if d.IsZero() || o.IsZero() {
+		return d.IsZero() == o.IsZero()
+	}
+	return equalSets(d.Channels, o.Channels) && equalSets(d.MerchantIDs, o.MerchantIDs) &&
+		equalSets(d.RequestorIDs, o.RequestorIDs)
+}
+
+// ParseChannel reports whether s names a channel
+func ParseChannel(s string) (string, error) {
+	switch s {
+	case ChannelEcommerce, ChannelInStore, ChannelMOTO:
+		return s, nil
+	}
+	return "", fmt.Errorf("%w: unknown channel %q", ErrInvalidDomain, s)
+}
+
+func validateDomain(d *Domain) error {
+	if d == nil {
+		return nil
+	}
+	for _, c := range d.Channels {
+		if _, err := ParseChannel(c); err != nil {
+			return err
+		}
+	}
+	lists := []struct {
+		name string
+		ids  []string
+	}{{"channels", d.Channels}, {"merchant IDs", d.MerchantIDs}, {"requestor IDs", d.RequestorIDs}}
+	for _, l := range lists {
+		if len(l.ids) > MaxDomainEntries {
+			return fmt.Errorf("%w: at most %d %s", ErrInvalidDomain, MaxDomainEntries, l.name)
+		}
+		for _, id := range l.ids {
+			if id == "" || len(id) > MaxDomainIDLen {
+				return fmt.Errorf("%w: %s must be 1-%d characters", ErrInvalidDomain, l.name, MaxDomainIDLen)
+			}
+		}
+	}
+	return nil
+}
+
+// copyDomain returns a sorted, deduplicated copy of d, or nil if it
+// restricts nothing
+func copyDomain(d *Domain) *Domain {
+	if d.IsZero() {
+		return nil
+	}
+	return &Domain{
+		Channels:     sortedSet(d.Channels),
+		MerchantIDs:  sortedSet(d.MerchantIDs),
+		RequestorIDs: sortedSet(d.RequestorIDs),
+	}
+}
+
+// UpdateDomain replaces the domain controls of a token issued to the
+// merchant, if it is still at ifRevision, and returns its new revision. A
+// nil or empty domain lifts the restrictions.
+func (s *Service) UpdateDomain(token, merchantID string, domain *Domain, ifRevision int64) (int64, error) {
+	if err := validateTokenFormat(token); err != nil {
+		return 0, err
+	}
+	if err := validateDomain(domain); err != nil {
+		return 0, err
+	}
+
+	tokenData, err := s.lookup(token, merchantID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenData.mu.Lock()
+	defer tokenData.mu.Unlock()
+
+	if err := checkRevision(tokenData, ifRevision); err != nil {
+		return 0, err
+	}
+	tokenData.Domain = copyDomain(domain)
+	s.updated(tokenData)
+	return tokenData.Revision, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedSet(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(list))
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equalSets(a, b []string) bool {
+	a, b = sortedSet(a), sortedSet(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package tokenization
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDomainControls(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	domain := &Domain{Channels: []string{ChannelEcommerce}, RequestorIDs: []string{"wallet-1"}}
+	tokenData, err := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123",
+		TokenizeOptions{MerchantID: "m1", Domain: domain})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		use  TokenUse
+		err  error
+	}{
+		{"in domain", TokenUse{Channel: ChannelEcommerce, RequestorID: "wallet-1"}, nil},
+		{"wrong channel", TokenUse{Channel: ChannelInStore, RequestorID: "wallet-1"}, ErrDomainRestricted},
+		{"wrong requestor", TokenUse{Channel: ChannelEcommerce, RequestorID: "wallet-2"}, ErrDomainRestricted},
+		{"unnamed use", TokenUse{}, ErrDomainRestricted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pan, _, _, err := service.DetokenizeCardForUse(tokenData.Token, "m1", tt.use)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DetokenizeCardForUse() error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && pan != "4532015112830366" {
+				t.Errorf("DetokenizeCardForUse() pan = %q", pan)
+			}
+		})
+	}
+
+	// Back-office detokenization does not check the domain
+	if _, _, _, err := service.DetokenizeCardForMerchant(tokenData.Token, "m1"); err != nil {
+		t.Errorf("DetokenizeCardForMerchant() error = %v", err)
+	}
+}
+
+func TestDomainMerchantDefaultsToScope(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	tokenData, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123",
+		TokenizeOptions{MerchantID: "platform", Domain: &Domain{MerchantIDs: []string{"platform", "sub-1"}}})
+
+	if _, _, _, err := service.DetokenizeCardForUse(tokenData.Token, "platform", TokenUse{}); err != nil {
+		t.Errorf("DetokenizeCardForUse() by the scope merchant error = %v", err)
+	}
+	if _, _, _, err := service.DetokenizeCardForUse(tokenData.Token, "platform", TokenUse{MerchantID: "sub-1"}); err != nil {
+		t.Errorf("DetokenizeCardForUse() by an allowed merchant error = %v", err)
+	}
+	if _, _, _, err := service.DetokenizeCardForUse(tokenData.Token, "platform", TokenUse{MerchantID: "sub-2"}); !errors.Is(err, ErrDomainRestricted) {
+		t.Errorf("DetokenizeCardForUse() by another merchant error = %v, want %v", err, ErrDomainRestricted)
+	}
+}
+
+func TestTokenizeDeduplicatesWithinDomain(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	ecommerce := TokenizeOptions{MerchantID: "m1", Domain: &Domain{Channels: []string{ChannelEcommerce}}}
+
+	first, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", ecommerce)
+	again, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", ecommerce)
+	if again.Token != first.Token {
+		t.Errorf("tokenizing again in the same domain = %q, want %q", again.Token, first.Token)
+	}
+	inStore, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123",
+		TokenizeOptions{MerchantID: "m1", Domain: &Domain{Channels: []string{ChannelInStore}}})
+	if inStore.Token == first.Token {
+		t.Error("tokenizing in another domain returned the existing token")
+	}
+
+	if _, err := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123",
+		TokenizeOptions{Domain: &Domain{Channels: []string{"kiosk"}}}); !errors.Is(err, ErrInvalidDomain) {
+		t.Errorf("TokenizeCardWithOptions() with an unknown channel error = %v, want %v", err, ErrInvalidDomain)
+	}
+}
+
+func TestUpdateDomain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	service := openTestWAL(t, path)
+	year := time.Now().Year() + 2
+	tokenData, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	revision, err := service.UpdateDomain(tokenData.Token, "m1", &Domain{Channels: []string{ChannelInStore}}, tokenData.Revision)
+	if err != nil || revision != 2 {
+		t.Fatalf("UpdateDomain() = %d, %v, want revision 2", revision, err)
+	}
+	if _, err := service.UpdateDomain(tokenData.Token, "m1", nil, 1); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("UpdateDomain() at a stale revision error = %v, want %v", err, ErrRevisionMismatch)
+	}
+
+	// The restriction survives a restart
+	recovered := openTestWAL(t, path)
+	if _, _, _, err := recovered.DetokenizeCardForUse(tokenData.Token, "m1", TokenUse{Channel: ChannelEcommerce}); !errors.Is(err, ErrDomainRestricted) {
+		t.Errorf("DetokenizeCardForUse() after recovery error = %v, want %v", err, ErrDomainRestricted)
+	}
+
+	// An empty domain lifts it
+	if _, err := recovered.UpdateDomain(tokenData.Token, "m1", &Domain{}, AnyRevision); err != nil {
+		t.Fatalf("UpdateDomain() error = %v", err)
+	}
+	if _, _, _, err := recovered.DetokenizeCardForUse(tokenData.Token, "m1", TokenUse{Channel: ChannelEcommerce}); err != nil {
+		t.Errorf("DetokenizeCardForUse() after lifting the domain error = %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A deployment shared by several teams serves each sandbox as an environment
+// of its own beside the live one. The environment qualifies every merchant
+// scope, so tokens, their deduplication and merchant data keys are
+// namespaced by it, and a token is never found from another environment.
+
+// LiveEnvironment is the environment outside every sandbox
+const LiveEnvironment = ""
+
+// ErrEnvironment is returned for an environment or merchant ID that would
+// reach into another environment's scope
+var ErrEnvironment = errors.New("invalid environment scope")
+
+const environmentSeparator = "/"
+
+// MerchantScope returns the vault scope of merchantID in environment. Live
+// scopes are the merchant IDs themselves; a sandbox's are prefixed by the
+// sandbox, the scope of its unscoped tokens included.
+func MerchantScope(environment, merchantID string) (string, error) {
+	if strings.Contains(merchantID, environmentSeparator) {
+		return "", fmt.Errorf("%w: merchant ID %q contains %q", ErrEnvironment, merchantID, environmentSeparator)
+	}
+	if environment == LiveEnvironment {
+		return merchantID, nil
+	}
+	if strings.Contains(environment, environmentSeparator) {
+		return "", fmt.Errorf("%w: environment %q contains %q", ErrEnvironment, environment, environmentSeparator)
+	}
+	return environment + environmentSeparator + merchantID, nil
+}
+
+// SplitMerchantScope returns the environment and merchant ID of a scope made
+// by MerchantScope
+func SplitMerchantScope(scope string) (environment, merchantID string) {
+	if i := strings.Index(scope, environmentSeparator); i >= 0 {
+		return scope[:i], scope[i+len(environmentSeparator):]
+	}
+	return LiveEnvironment, scope
+}
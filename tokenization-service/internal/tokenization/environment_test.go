@@ -0,0 +1,55 @@
+package tokenization
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSandboxTokenNotFoundInLiveEnvironment(t *testing.T) {
+	service := NewService(newGCMHSM(t), "test-key", 24*time.Hour, WithKeyScope(KeyScopeMerchant))
+	year := time.Now().Year() + 2
+	scope := func(environment, merchantID string) string {
+		t.Helper()
+		s, err := MerchantScope(environment, merchantID)
+		if err != nil {
+			t.Fatalf("MerchantScope(%q, %q) error = %v", environment, merchantID, err)
+		}
+		return s
+	}
+
+	sandboxed, err := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123",
+		TokenizeOptions{MerchantID: scope("sbx_team", "m1")})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	unscoped, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123",
+		TokenizeOptions{MerchantID: scope("sbx_team", "")})
+
+	// Neither the same merchant nor an unscoped call finds it outside the
+	// sandbox, and neither does another sandbox
+	for _, token := range []string{sandboxed.Token, unscoped.Token} {
+		for _, other := range []string{scope(LiveEnvironment, "m1"), scope(LiveEnvironment, ""), scope("sbx_other", "m1"), scope("sbx_other", "")} {
+			if _, _, _, err := service.DetokenizeCardForMerchant(token, other); !errors.Is(err, ErrTokenNotFound) {
+				t.Errorf("DetokenizeCardForMerchant(%s) in %q error = %v, want %v", token, other, err, ErrTokenNotFound)
+			}
+		}
+	}
+	if pan, _, _, err := service.DetokenizeCardForMerchant(sandboxed.Token, scope("sbx_team", "m1")); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCardForMerchant() in the sandbox = %q, %v", pan, err)
+	}
+
+	// The live merchant of the same ID gets its own token under its own key
+	live, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	if live.Token == sandboxed.Token || live.DataKeyID == sandboxed.DataKeyID {
+		t.Errorf("live token %s under %s shares the sandbox's %s under %s", live.Token, live.DataKeyID, sandboxed.Token, sandboxed.DataKeyID)
+	}
+
+	// A live merchant ID cannot name a sandbox scope
+	if _, err := MerchantScope(LiveEnvironment, "sbx_team/m1"); !errors.Is(err, ErrEnvironment) {
+		t.Errorf("MerchantScope() of a sandbox-like merchant ID error = %v, want %v", err, ErrEnvironment)
+	}
+	if environment, merchantID := SplitMerchantScope(scope("sbx_team", "m1")); environment != "sbx_team" || merchantID != "m1" {
+		t.Errorf("SplitMerchantScope() = %q, %q", environment, merchantID)
+	}
+}
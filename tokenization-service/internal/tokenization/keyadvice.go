@@ -0,0 +1,55 @@
+package tokenization
+
+import "github.com/paymentgateway/go-common/securebytes"
+
+// KeyRotated reacts to the HSM key moving to version: the envelope data key,
+// if wrapped under an older version, is retired so new tokens get a data key
+// wrapped under the new one. It reports whether the envelope key was
+// retired.
+func (s *Service) KeyRotated(version int) bool {
+	e := s.envelope
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dek == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	key, ok := s.dataKeys[e.keyID]
+	s.mu.RUnlock()
+	if ok && key.KeyVersion >= version {
+		return false
+	}
+	securebytes.Zero(e.dek)
+	e.dek = nil
+	return true
+}
+
+// KeyVersionRetired reacts to an HSM key version being destroyed: data keys
+// still wrapped under it can no longer be unwrapped, so their cached
+// plaintext copies are zeroized rather than left to outlive the version. It
+// returns the number of cached keys dropped.
+func (s *Service) KeyVersionRetired(version int) int {
+	if s.keyCache == nil {
+		return 0
+	}
+	s.mu.RLock()
+	var ids []string
+	for id, key := range s.dataKeys {
+		if key.KeyVersion == version {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	n := 0
+	for _, id := range ids {
+		if s.keyCache.evict(id) {
+			n++
+		}
+	}
+	return n
+}
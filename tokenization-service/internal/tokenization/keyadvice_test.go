@@ -0,0 +1,59 @@
+package tokenization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyRotatedRetiresEnvelopeKey(t *testing.T) {
+	version := 1
+	hsm := &MockHSMClient{
+		encryptFunc: func(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+			return append([]byte(nil), plaintext...), []byte("nonce123"), version, nil
+		},
+	}
+	service := NewService(hsm, "test-key", 24*time.Hour, WithEnvelope(EnvelopeConfig{}))
+	year := time.Now().Year() + 2
+	if service.KeyRotated(2) {
+		t.Error("KeyRotated() retired an envelope key before there was one")
+	}
+	a, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+
+	if service.KeyRotated(1) {
+		t.Error("KeyRotated(1) retired a key already wrapped under version 1")
+	}
+	version = 2
+	if !service.KeyRotated(2) {
+		t.Fatal("KeyRotated(2) kept the envelope key wrapped under version 1")
+	}
+	b, _ := service.TokenizeCard("5425233430109903", 12, year, "123")
+	if a.DataKeyID == b.DataKeyID {
+		t.Error("token after the rotation shares the old envelope key")
+	}
+	if usage := service.KeyVersionUsage(); usage[1] != 1 || usage[2] != 1 {
+		t.Errorf("KeyVersionUsage() = %v; want one data key per version", usage)
+	}
+	if pan, _, _, err := service.DetokenizeCard(a.Token); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCard() under the old envelope key = %q, %v", pan, err)
+	}
+}
+
+func TestKeyVersionRetiredEvictsCachedKeys(t *testing.T) {
+	hsm := newGCMHSM(t)
+	service := NewService(hsm, "test-key", 24*time.Hour,
+		WithKeyScope(KeyScopeToken),
+		WithDataKeyCache(DataKeyCacheConfig{}),
+	)
+	tokenData, _ := service.TokenizeCard("4532015112830366", 12, time.Now().Year()+2, "123")
+	service.DetokenizeCard(tokenData.Token)
+
+	if n := service.KeyVersionRetired(2); n != 0 {
+		t.Errorf("KeyVersionRetired(2) = %d; nothing is wrapped under version 2", n)
+	}
+	if n := service.KeyVersionRetired(1); n != 1 {
+		t.Errorf("KeyVersionRetired(1) = %d, want 1", n)
+	}
+	if st := service.DataKeyCacheStats(); st.Entries != 0 {
+		t.Errorf("stats = %+v; want the retired version's key dropped", st)
+	}
+}
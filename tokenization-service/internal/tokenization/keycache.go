@@ -0,0 +1,214 @@
+package tokenization
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// DataKeyCacheConfig bounds the cache of unwrapped data keys. Zero fields
+// take the defaults.
+type DataKeyCacheConfig struct {
+	MaxEntries int
+	TTL        time.Duration
+	// Metrics receives hit, miss and eviction counters. Nil keeps them
+	// private to the cache.
+	Metrics *metrics.Registry
+}
+
+// Data key cache limits used when DataKeyCacheConfig leaves them zero
+const (
+	DefaultDataKeyCacheEntries = 1000
+	DefaultDataKeyCacheTTL     = 5 * time.Minute
+)
+
+// WithDataKeyCache keeps unwrapped data keys in memory for up to TTL, so
+// detokenizing a recently used key skips the HSM. Expired and evicted keys
+// are zeroized. Without this option every unwrap goes to the HSM, as a
+// strict-HSM deployment requires.
+func WithDataKeyCache(cfg DataKeyCacheConfig) Option {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultDataKeyCacheEntries
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultDataKeyCacheTTL
+	}
+	return func(s *Service) { s.keyCache = newKeyCache(cfg) }
+}
+
+// DataKeyCacheStats is a snapshot of the data key cache counters
+type DataKeyCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// HitRate is the share of lookups served from the cache
+func (st DataKeyCacheStats) HitRate() float64 {
+	if st.Hits+st.Misses == 0 {
+		return 0
+	}
+	return float64(st.Hits) / float64(st.Hits+st.Misses)
+}
+
+// keyCache is an LRU of plaintext data keys with a TTL. It owns the bytes it
+// stores and hands out copies, so it can zeroize an entry without pulling a
+// key out from under a caller.
+type keyCache struct {
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	lru        *list.List // front is most recently used
+	mu         sync.Mutex
+	now        func() time.Time
+
+	hits      *metrics.Counter
+	misses    *metrics.Counter
+	evictions *metrics.CounterVec
+}
+
+type cachedKey struct {
+	id      string
+	dek     []byte
+	expires time.Time
+}
+
+func newKeyCache(cfg DataKeyCacheConfig) *keyCache {
+	registry := cfg.Metrics
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	lookups := registry.Counter("tokenization_datakey_cache_lookups_total", "Data key cache lookups, by result.", "result")
+	c := &keyCache{
+		maxEntries: cfg.MaxEntries,
+		ttl:        cfg.TTL,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+		hits:       lookups.With("hit"),
+		misses:     lookups.With("miss"),
+		evictions:  registry.Counter("tokenization_datakey_cache_evictions_total", "Data keys zeroized and dropped from the cache, by reason.", "reason"),
+	}
+	registry.GaugeFunc("tokenization_datakey_cache_entries", "Unwrapped data keys held in memory.", func() float64 {
+		return float64(c.stats().Entries)
+	})
+	registry.GaugeFunc("tokenization_datakey_cache_hit_ratio", "Share of data key lookups served from the cache.", func() float64 {
+		return c.stats().HitRate()
+	})
+	return c
+}
+
+// get returns a copy of a live cached key
+func (c *keyCache) get(id string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[id]
+	if ok && c.now().After(el.Value.(*cachedKey).expires) {
+		c.removeLocked(el, "expired")
+		ok = false
+	}
+	if !ok {
+		c.misses.Inc()
+		return nil, false
+	}
+	c.hits.Inc()
+	c.lru.MoveToFront(el)
+	return securebytes.Clone(el.Value.(*cachedKey).dek), true
+}
+
+// put stores a copy of a key, evicting the least recently used beyond the
+// bound
+func (c *keyCache) put(id string, dek []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[id]; ok {
+		c.removeLocked(el, "replaced")
+	}
+	c.entries[id] = c.lru.PushFront(&cachedKey{
+		id:      id,
+		dek:     securebytes.Clone(dek),
+		expires: c.now().Add(c.ttl),
+	})
+	for c.lru.Len() > c.maxEntries {
+		c.removeLocked(c.lru.Back(), "capacity")
+	}
+}
+
+// evict zeroizes and drops a key, e.g. when it is shredded, and reports
+// whether it was cached
+func (c *keyCache) evict(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[id]
+	if ok {
+		c.removeLocked(el, "destroyed")
+	}
+	return ok
+}
+
+// sweep zeroizes and drops every expired key
+func (c *keyCache) sweep() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	n := 0
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if now.After(el.Value.(*cachedKey).expires) {
+			c.removeLocked(el, "expired")
+			n++
+		}
+		el = prev
+	}
+	return n
+}
+
+func (c *keyCache) removeLocked(el *list.Element, reason string) {
+	entry := c.lru.Remove(el).(*cachedKey)
+	delete(c.entries, entry.id)
+	securebytes.Zero(entry.dek)
+	c.evictions.With(reason).Inc()
+}
+
+func (c *keyCache) stats() DataKeyCacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	var evictions float64
+	for _, reason := range []string{"expired", "capacity", "destroyed", "replaced"} {
+		evictions += c.evictions.With(reason).Value()
+	}
+	return DataKeyCacheStats{
+		Hits:      uint64(c.hits.Value()),
+		Misses:    uint64(c.misses.Value()),
+		Evictions: uint64(evictions),
+		Entries:   entries,
+	}
+}
+
+// DataKeyCacheStats returns the cache counters, or zeros when caching is off
+func (s *Service) DataKeyCacheStats() DataKeyCacheStats {
+	if s.keyCache == nil {
+		return DataKeyCacheStats{}
+	}
+	return s.keyCache.stats()
+}
+
+// SweepDataKeyCache zeroizes expired cached keys and returns how many were
+// dropped. Run it periodically so idle keys do not linger until their next
+// lookup.
+func (s *Service) SweepDataKeyCache() int {
+	if s.keyCache == nil {
+		return 0
+	}
+	return s.keyCache.sweep()
+}
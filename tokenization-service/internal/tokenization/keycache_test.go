@@ -0,0 +1,114 @@
+package tokenization
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/metrics"
+)
+
+func TestKeyCache(t *testing.T) {
+	now := time.Now()
+	c := newKeyCache(DataKeyCacheConfig{MaxEntries: 2, TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	dek := bytes.Repeat([]byte{7}, 32)
+	c.put("k1", dek)
+	got, ok := c.get("k1")
+	if !ok || !bytes.Equal(got, dek) {
+		t.Fatalf("get(k1) = %x, %v", got, ok)
+	}
+	got[0] = 0
+	if again, _ := c.get("k1"); again[0] != 7 {
+		t.Error("get() should return a copy")
+	}
+
+	// k1 is most recently used, so k2 is evicted for k3
+	c.put("k2", dek)
+	c.get("k1")
+	c.put("k3", dek)
+	if _, ok := c.get("k2"); ok {
+		t.Error("least recently used key not evicted")
+	}
+
+	// Expired keys are zeroized by the sweep
+	stored := c.entries["k1"].Value.(*cachedKey).dek
+	now = now.Add(2 * time.Minute)
+	if n := c.sweep(); n != 2 {
+		t.Errorf("sweep() = %d, want 2", n)
+	}
+	if !bytes.Equal(stored, make([]byte, 32)) {
+		t.Error("expired key not zeroized")
+	}
+	if _, ok := c.get("k1"); ok {
+		t.Error("expired key still served")
+	}
+
+	st := c.stats()
+	if st.Hits != 3 || st.Misses != 2 || st.Entries != 0 || st.Evictions != 3 {
+		t.Errorf("stats = %+v, want 3 hits, 2 misses, 3 evictions, 0 entries", st)
+	}
+	if rate := st.HitRate(); rate != 0.6 {
+		t.Errorf("HitRate() = %v, want 0.6", rate)
+	}
+}
+
+func TestDataKeyCacheSkipsHSM(t *testing.T) {
+	registry := metrics.NewRegistry()
+	hsm := newGCMHSM(t)
+	service := NewService(hsm, "test-key", 24*time.Hour,
+		WithKeyScope(KeyScopeToken),
+		WithDataKeyCache(DataKeyCacheConfig{Metrics: registry}),
+	)
+	year := time.Now().Year() + 2
+
+	tokenData, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+	for i := 0; i < 3; i++ {
+		if _, _, _, err := service.DetokenizeCard(tokenData.Token); err != nil {
+			t.Fatalf("DetokenizeCard() error = %v", err)
+		}
+	}
+	if hsm.decrypts != 0 {
+		t.Errorf("HSM unwrapped %d times, want 0 with a warm cache", hsm.decrypts)
+	}
+	if st := service.DataKeyCacheStats(); st.Hits != 3 || st.Entries != 1 {
+		t.Errorf("stats = %+v, want 3 hits and 1 entry", st)
+	}
+
+	// Shredding drops the cached plaintext too
+	fingerprint, _ := Fingerprint("4532015112830366")
+	service.ShredCard(fingerprint)
+	if st := service.DataKeyCacheStats(); st.Entries != 0 {
+		t.Errorf("shredded key still cached: %+v", st)
+	}
+
+	var out strings.Builder
+	registry.WriteText(&out)
+	for _, want := range []string{
+		`tokenization_datakey_cache_lookups_total{result="hit"} 3`,
+		`tokenization_datakey_cache_evictions_total{reason="destroyed"} 1`,
+		"tokenization_datakey_cache_hit_ratio 1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDataKeyCacheDisabled(t *testing.T) {
+	hsm := newGCMHSM(t)
+	service := NewService(hsm, "test-key", 24*time.Hour, WithKeyScope(KeyScopeToken))
+	year := time.Now().Year() + 2
+
+	tokenData, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+	service.DetokenizeCard(tokenData.Token)
+	service.DetokenizeCard(tokenData.Token)
+	if hsm.decrypts != 2 {
+		t.Errorf("HSM unwrapped %d times, want every detokenize to reach it", hsm.decrypts)
+	}
+	if st := service.DataKeyCacheStats(); st != (DataKeyCacheStats{}) {
+		t.Errorf("stats without a cache = %+v", st)
+	}
+}
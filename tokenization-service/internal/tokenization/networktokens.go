@@ -0,0 +1,401 @@
+package tokenization
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// Network tokens stand in for the tokens a card network's token service
+// (Visa VTS, Mastercard MDES) issues to a token requestor. The simulator
+// provisions them itself for the card behind a vault token. A link record
+// ties each network token to a vault token of the same card and merchant, so
+// either can be exchanged for the other, and lifecycle events the network
+// reports for a card reach every token of that card.
+
+var (
+	ErrNetworkTokenNotFound  = errors.New("network token not found")
+	ErrNoLinkedToken         = errors.New("no linked token for this card")
+	ErrNetworkTokenSuspended = errors.New("network token suspended")
+	ErrTokenSuspended        = errors.New("token suspended")
+	ErrNetworkNotSupported   = errors.New("card network does not issue network tokens")
+	ErrInvalidCardEvent      = errors.New("invalid card lifecycle event")
+)
+
+// NetworkTokenStatus is the state a network keeps for a network token
+type NetworkTokenStatus string
+
+const (
+	NetworkTokenActive    NetworkTokenStatus = "active"
+	NetworkTokenSuspended NetworkTokenStatus = "suspended"
+	NetworkTokenDeleted   NetworkTokenStatus = "deleted"
+)
+
+// networkTokenBINs are the simulated token BIN ranges of each network. Unlike
+// vault tokens, network tokens are Luhn-valid card numbers, as they are
+// routed through the network like PANs.
+var networkTokenBINs = map[string]string{
+	"VISA":       "489537",
+	"MASTERCARD": "520473",
+	"AMEX":       "374245",
+	"DISCOVER":   "601174",
+}
+
+// NetworkToken is a network-issued token for a card within a merchant scope
+type NetworkToken struct {
+	Token       string
+	Network     string
+	MerchantID  string
+	PANHash     string
+	LastFour    string
+	ExpiryMonth int
+	ExpiryYear  int
+	Status      NetworkTokenStatus
+	// PAR is the payment account reference of the card
+	PAR string `json:",omitempty"`
+	// ATC counts the cryptograms issued for the token and ValidatedATC is
+	// the counter of the last one accepted
+	ATC          uint16 `json:",omitempty"`
+	ValidatedATC uint16 `json:",omitempty"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TokenLink records that a vault token and a network token stand for the
+// same card within a merchant scope
+type TokenLink struct {
+	NetworkToken string
+	VaultToken   string
+	MerchantID   string
+	PANHash      string
+	LinkedAt     time.Time
+}
+
+// CardEvent is a lifecycle change a network reports for a card
+type CardEvent string
+
+const (
+	// CardEventSuspend suspends every token of the card, e.g. for a card
+	// reported lost
+	CardEventSuspend CardEvent = "suspend"
+	// CardEventResume lifts a suspension
+	CardEventResume CardEvent = "resume"
+	// CardEventClose revokes the vault tokens and deletes the network
+	// tokens of a closed card account
+	CardEventClose CardEvent = "close"
+)
+
+// ParseCardEvent parses a card event name
+func ParseCardEvent(s string) (CardEvent, error) {
+	switch e := CardEvent(strings.ToLower(s)); e {
+	case CardEventSuspend, CardEventResume, CardEventClose:
+		return e, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidCardEvent, s)
+}
+
+// IsVaultToken reports whether a token has the vault token format. Network
+// tokens are Luhn-valid card numbers in a network's BIN range and never start
+// with 9.
+func IsVaultToken(token string) bool {
+	return validateTokenFormat(token) == nil
+}
+
+// CardEventResult lists the tokens a card event changed
+type CardEventResult struct {
+	NetworkTokens []string
+	VaultTokens   []string
+}
+
+// ProvisionNetworkToken obtains a network token for the card behind a vault
+// token of the merchant and links the two. A card that already has a live
+// network token for the merchant keeps it.
+func (s *Service) ProvisionNetworkToken(vaultToken, merchantID string) (*NetworkToken, error) {
+	if err := validateTokenFormat(vaultToken); err != nil {
+		return nil, err
+	}
+	tokenData, err := s.lookup(vaultToken, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenData.mu.RLock()
+	usable := tokenData.IsActive && !tokenData.Suspended && time.Now().Before(tokenData.ExpiresAt)
+	card := NetworkToken{
+		Network:     tokenData.CardBrand,
+		MerchantID:  merchantID,
+		PANHash:     tokenData.PANHash,
+		PAR:         tokenData.PAR,
+		LastFour:    tokenData.LastFour,
+		ExpiryMonth: tokenData.ExpiryMonth,
+		ExpiryYear:  tokenData.ExpiryYear,
+	}
+	tokenData.mu.RUnlock()
+	if !usable {
+		return nil, ErrTokenNotFound
+	}
+	bin, ok := networkTokenBINs[card.Network]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkNotSupported, card.Network)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.networkTokenForCardLocked(merchantID, card.PANHash); existing != nil {
+		s.linkLocked(existing, vaultToken)
+		nt := *existing
+		return &nt, nil
+	}
+
+	length := 16
+	if card.Network == "AMEX" {
+		length = 15
+	}
+	var token string
+	for {
+		if token, err = s.ownedToken(func() (string, error) { return generateNetworkToken(bin, length) }); err != nil {
+			return nil, err
+		}
+		if _, taken := s.networkTokens[token]; !taken {
+			break
+		}
+	}
+
+	now := time.Now()
+	card.Token = token
+	card.Status = NetworkTokenActive
+	card.CreatedAt = now
+	card.UpdatedAt = now
+	s.networkTokens[token] = &card
+	s.logNetworkToken(&card)
+	s.linkLocked(&card, vaultToken)
+	nt := card
+	return &nt, nil
+}
+
+// NetworkTokenFor exchanges a vault token of the merchant for the linked
+// network token of the same card
+func (s *Service) NetworkTokenFor(vaultToken, merchantID string) (*NetworkToken, error) {
+	if err := validateTokenFormat(vaultToken); err != nil {
+		return nil, err
+	}
+	tokenData, err := s.lookup(vaultToken, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nt := s.networkTokenForCardLocked(merchantID, tokenData.PANHash)
+	if nt == nil {
+		return nil, ErrNoLinkedToken
+	}
+	// A vault token issued after the link was made, e.g. once the linked
+	// one expired, takes over the link
+	s.linkLocked(nt, vaultToken)
+	out := *nt
+	return &out, nil
+}
+
+// VaultTokenFor exchanges a network token of the merchant for the linked
+// vault token. If the linked vault token is gone, the merchant's current
+// vault token of the same card is linked instead.
+func (s *Service) VaultTokenFor(networkToken, merchantID string) (*TokenData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nt, ok := s.networkTokens[networkToken]
+	if !ok || nt.MerchantID != merchantID || nt.Status == NetworkTokenDeleted {
+		return nil, ErrNetworkTokenNotFound
+	}
+	if nt.Status == NetworkTokenSuspended {
+		return nil, ErrNetworkTokenSuspended
+	}
+
+	var candidates []string
+	if link, ok := s.tokenLinks[networkToken]; ok {
+		candidates = append(candidates, link.VaultToken)
+	}
+	candidates = append(candidates, s.panHashIndex[merchantID+":"+nt.PANHash])
+	for _, vaultToken := range candidates {
+		tokenData, exists := s.tokens[vaultToken]
+		if !exists || tokenData.MerchantID != merchantID {
+			continue
+		}
+		tokenData.mu.RLock()
+		live := tokenData.IsActive && tokenData.DeletedAt.IsZero() && time.Now().Before(tokenData.ExpiresAt)
+		tokenData.mu.RUnlock()
+		if live {
+			s.linkLocked(nt, vaultToken)
+			return tokenData, nil
+		}
+	}
+	return nil, ErrNoLinkedToken
+}
+
+// NetworkTokenInfo returns a network token issued to the merchant
+func (s *Service) NetworkTokenInfo(networkToken, merchantID string) (*NetworkToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nt, ok := s.networkTokens[networkToken]
+	if !ok || nt.MerchantID != merchantID {
+		return nil, ErrNetworkTokenNotFound
+	}
+	out := *nt
+	return &out, nil
+}
+
+// Links returns the link records of the card behind a network token
+func (s *Service) Links(networkToken string) ([]TokenLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nt, ok := s.networkTokens[networkToken]
+	if !ok {
+		return nil, ErrNetworkTokenNotFound
+	}
+	var links []TokenLink
+	for _, link := range s.tokenLinks {
+		if securebytes.EqualString(link.PANHash, nt.PANHash) {
+			links = append(links, *link)
+		}
+	}
+	return links, nil
+}
+
+// ApplyCardEvent applies a lifecycle event the network reported through one
+// of a card's network tokens to every token of that card: the network tokens
+// of all merchants and the vault tokens of the same card
+func (s *Service) ApplyCardEvent(networkToken string, event CardEvent) (*CardEventResult, error) {
+	if _, err := ParseCardEvent(string(event)); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reported, ok := s.networkTokens[networkToken]
+	if !ok {
+		return nil, ErrNetworkTokenNotFound
+	}
+	panHash := reported.PANHash
+	now := time.Now()
+
+	res := &CardEventResult{}
+	for token, nt := range s.networkTokens {
+		if !securebytes.EqualString(nt.PANHash, panHash) || nt.Status == NetworkTokenDeleted {
+			continue
+		}
+		status := nt.Status
+		switch event {
+		case CardEventSuspend:
+			status = NetworkTokenSuspended
+		case CardEventResume:
+			status = NetworkTokenActive
+		case CardEventClose:
+			status = NetworkTokenDeleted
+		}
+		if status != nt.Status {
+			nt.Status = status
+			nt.UpdatedAt = now
+			s.logNetworkToken(nt)
+			res.NetworkTokens = append(res.NetworkTokens, token)
+		}
+	}
+	for token, tokenData := range s.tokens {
+		if !securebytes.EqualString(tokenData.PANHash, panHash) {
+			continue
+		}
+		tokenData.mu.Lock()
+		changed := false
+		switch {
+		case event == CardEventSuspend && tokenData.IsActive && !tokenData.Suspended:
+			tokenData.Suspended, changed = true, true
+		case event == CardEventResume && tokenData.Suspended:
+			tokenData.Suspended, changed = false, true
+		case event == CardEventClose && tokenData.IsActive:
+			tokenData.IsActive, tokenData.Suspended, changed = false, false, true
+			tokenData.RevokedAt = now
+		}
+		if changed {
+			s.updated(tokenData)
+		}
+		tokenData.mu.Unlock()
+		if changed {
+			res.VaultTokens = append(res.VaultTokens, token)
+		}
+	}
+	return res, nil
+}
+
+// networkTokenForCardLocked finds the merchant's live network token for a
+// card. s.mu must be held.
+func (s *Service) networkTokenForCardLocked(merchantID, panHash string) *NetworkToken {
+	for _, nt := range s.networkTokens {
+		if nt.MerchantID == merchantID && securebytes.EqualString(nt.PANHash, panHash) && nt.Status != NetworkTokenDeleted {
+			return nt
+		}
+	}
+	return nil
+}
+
+// linkLocked links a network token to a vault token, replacing its previous
+// link. s.mu must be held.
+func (s *Service) linkLocked(nt *NetworkToken, vaultToken string) {
+	if link, ok := s.tokenLinks[nt.Token]; ok && link.VaultToken == vaultToken {
+		return
+	}
+	link := &TokenLink{
+		NetworkToken: nt.Token,
+		VaultToken:   vaultToken,
+		MerchantID:   nt.MerchantID,
+		PANHash:      nt.PANHash,
+		LinkedAt:     time.Now(),
+	}
+	s.tokenLinks[nt.Token] = link
+	s.logLink(link)
+}
+
+// forgetNetworkTokensLocked deletes the network tokens and links of a card
+// and returns the deleted tokens. s.mu must be held.
+func (s *Service) forgetNetworkTokensLocked(panHash string) []string {
+	var forgotten []string
+	for token, nt := range s.networkTokens {
+		if securebytes.EqualString(nt.PANHash, panHash) {
+			delete(s.networkTokens, token)
+			delete(s.tokenLinks, token)
+			s.logRemoval(walDeleteNetwork, token)
+			forgotten = append(forgotten, token)
+		}
+	}
+	return forgotten
+}
+
+// generateNetworkToken generates a Luhn-valid card number in a token BIN
+// range
+func generateNetworkToken(bin string, length int) (string, error) {
+	var b strings.Builder
+	b.WriteString(bin)
+	for b.Len() < length-1 {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random digit: %w", err)
+		}
+		b.WriteString(digit.String())
+	}
+	body := b.String()
+	for check := 0; check <= 9; check++ {
+		if candidate := body + fmt.Sprint(check); luhnCheck(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no Luhn check digit")
+}
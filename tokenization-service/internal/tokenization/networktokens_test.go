@@ -0,0 +1,121 @@
+package tokenization
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetworkTokenExchange(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+
+	vault, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	if _, err := service.NetworkTokenFor(vault.Token, "m1"); err != ErrNoLinkedToken {
+		t.Errorf("NetworkTokenFor() before provisioning error = %v, want %v", err, ErrNoLinkedToken)
+	}
+
+	nt, err := service.ProvisionNetworkToken(vault.Token, "m1")
+	if err != nil {
+		t.Fatalf("ProvisionNetworkToken() error = %v", err)
+	}
+	if !strings.HasPrefix(nt.Token, networkTokenBINs["VISA"]) || len(nt.Token) != 16 || !luhnCheck(nt.Token) {
+		t.Errorf("network token = %q, want a Luhn-valid VISA token number", nt.Token)
+	}
+	if IsVaultToken(nt.Token) || !IsVaultToken(vault.Token) {
+		t.Errorf("IsVaultToken() does not tell %q and %q apart", nt.Token, vault.Token)
+	}
+	if again, _ := service.ProvisionNetworkToken(vault.Token, "m1"); again.Token != nt.Token {
+		t.Errorf("second ProvisionNetworkToken() = %q, want %q", again.Token, nt.Token)
+	}
+
+	if got, err := service.NetworkTokenFor(vault.Token, "m1"); err != nil || got.Token != nt.Token {
+		t.Errorf("NetworkTokenFor() = %v, %v, want %q", got, err, nt.Token)
+	}
+	if got, err := service.VaultTokenFor(nt.Token, "m1"); err != nil || got.Token != vault.Token {
+		t.Errorf("VaultTokenFor() = %v, %v, want %q", got, err, vault.Token)
+	}
+	if _, err := service.VaultTokenFor(nt.Token, "m2"); err != ErrNetworkTokenNotFound {
+		t.Errorf("VaultTokenFor() by another merchant error = %v, want %v", err, ErrNetworkTokenNotFound)
+	}
+	links, _ := service.Links(nt.Token)
+	if len(links) != 1 || links[0].VaultToken != vault.Token || links[0].MerchantID != "m1" {
+		t.Errorf("Links() = %+v", links)
+	}
+
+	// A revoked vault token hands the link to the merchant's new token of
+	// the same card
+	service.RevokeTokenForMerchant(vault.Token, "m1", AnyRevision)
+	fresh, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	if got, err := service.VaultTokenFor(nt.Token, "m1"); err != nil || got.Token != fresh.Token {
+		t.Errorf("VaultTokenFor() after revocation = %v, %v, want %q", got, err, fresh.Token)
+	}
+}
+
+func TestCardEventsPropagate(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	pan := "5425233430109903"
+
+	m1, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	m2, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	nt1, _ := service.ProvisionNetworkToken(m1.Token, "m1")
+	nt2, _ := service.ProvisionNetworkToken(m2.Token, "m2")
+	other, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	res, err := service.ApplyCardEvent(nt1.Token, CardEventSuspend)
+	if err != nil {
+		t.Fatalf("ApplyCardEvent(suspend) error = %v", err)
+	}
+	if len(res.NetworkTokens) != 2 || len(res.VaultTokens) != 2 {
+		t.Errorf("suspend changed %+v, want both network and both vault tokens", res)
+	}
+	if _, _, _, err := service.DetokenizeCardForMerchant(m2.Token, "m2"); err != ErrTokenSuspended {
+		t.Errorf("DetokenizeCardForMerchant() of suspended card error = %v, want %v", err, ErrTokenSuspended)
+	}
+	if _, err := service.VaultTokenFor(nt2.Token, "m2"); err != ErrNetworkTokenSuspended {
+		t.Errorf("VaultTokenFor() of suspended card error = %v, want %v", err, ErrNetworkTokenSuspended)
+	}
+	if valid, _ := service.ValidateTokenForMerchant(other.Token, "m1"); !valid {
+		t.Error("a card event affected another card")
+	}
+
+	service.ApplyCardEvent(nt2.Token, CardEventResume)
+	if _, _, _, err := service.DetokenizeCardForMerchant(m1.Token, "m1"); err != nil {
+		t.Errorf("DetokenizeCardForMerchant() after resume error = %v", err)
+	}
+
+	res, _ = service.ApplyCardEvent(nt1.Token, CardEventClose)
+	if len(res.NetworkTokens) != 2 || len(res.VaultTokens) != 2 {
+		t.Errorf("close changed %+v, want both network and both vault tokens", res)
+	}
+	if info, _ := service.NetworkTokenInfo(nt1.Token, "m1"); info.Status != NetworkTokenDeleted {
+		t.Errorf("network token status after close = %q, want %q", info.Status, NetworkTokenDeleted)
+	}
+	if valid, _ := service.ValidateTokenForMerchant(m1.Token, "m1"); valid {
+		t.Error("vault token of a closed card is still valid")
+	}
+
+	if _, err := service.ApplyCardEvent(nt1.Token, "freeze"); !errors.Is(err, ErrInvalidCardEvent) {
+		t.Errorf("ApplyCardEvent(freeze) error = %v, want %v", err, ErrInvalidCardEvent)
+	}
+}
+
+func TestForgetCardRemovesNetworkTokens(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	pan := "4532015112830366"
+
+	vault, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	nt, _ := service.ProvisionNetworkToken(vault.Token, "m1")
+	fingerprint, _ := Fingerprint(pan)
+
+	forgotten, err := service.ForgetCard(fingerprint)
+	if err != nil || len(forgotten) != 2 {
+		t.Fatalf("ForgetCard() = %v, %v, want the vault and network token", forgotten, err)
+	}
+	if _, err := service.Links(nt.Token); err != ErrNetworkTokenNotFound {
+		t.Errorf("Links() after ForgetCard error = %v, want %v", err, ErrNetworkTokenNotFound)
+	}
+}
@@ -0,0 +1,122 @@
+package tokenization
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// In a cluster each node owns part of the token space. A node issues only
+// tokens it owns, so requests can be routed by the token alone, and hands
+// its entries to their new owners when the membership changes.
+
+// ErrNoOwnedToken is returned when no token this node owns could be drawn
+var ErrNoOwnedToken = errors.New("no token owned by this node could be generated")
+
+// maxOwnershipAttempts bounds the tokens drawn for one the node owns. With n
+// equal nodes, n are drawn on average.
+const maxOwnershipAttempts = 1000
+
+// WithTokenOwnership makes the service issue only vault and network tokens
+// that owns accepts
+func WithTokenOwnership(owns func(token string) bool) Option {
+	return func(s *Service) { s.owns = owns }
+}
+
+// ownedToken draws tokens from generate until one is owned by this node
+func (s *Service) ownedToken(generate func() (string, error)) (string, error) {
+	for i := 0; i < maxOwnershipAttempts; i++ {
+		token, err := generate()
+		if err != nil || s.owns == nil || s.owns(token) {
+			return token, err
+		}
+	}
+	return "", ErrNoOwnedToken
+}
+
+// HandoffRecords encodes the entries that belong to other nodes, grouped by
+// node, for a rebalance. owner returns a token's node, or "" for this node.
+// Each group holds the node's vault tokens with the data keys encrypting
+// them, then its network tokens with their links, ready for ApplyChange.
+func (s *Service) HandoffRecords(owner func(token string) string) (map[string][][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]*TokenData, 0, len(s.tokens))
+	for token, tokenData := range s.tokens {
+		if owner(token) != "" {
+			tokens = append(tokens, tokenData)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	byNode := make(map[string][]*TokenData)
+	for _, tokenData := range tokens {
+		node := owner(tokenData.Token)
+		byNode[node] = append(byNode[node], tokenData)
+	}
+
+	groups := make(map[string][]walRecord)
+	for node, tokens := range byNode {
+		sent := make(map[string]bool)
+		for _, tokenData := range tokens {
+			if key, ok := s.dataKeys[tokenData.DataKeyID]; ok && !sent[key.ID] {
+				sent[key.ID] = true
+				groups[node] = append(groups[node], walRecord{Op: walPutDataKey, DataKey: key})
+			}
+		}
+		for _, tokenData := range tokens {
+			groups[node] = append(groups[node], walRecord{Op: walPutToken, Token: tokenData})
+		}
+	}
+	for token, nt := range s.networkTokens {
+		if node := owner(token); node != "" {
+			groups[node] = append(groups[node], walRecord{Op: walPutNetworkToken, NetworkToken: nt})
+			if link, ok := s.tokenLinks[token]; ok {
+				groups[node] = append(groups[node], walRecord{Op: walPutLink, Link: link})
+			}
+		}
+	}
+
+	out := make(map[string][][]byte, len(groups))
+	for node, recs := range groups {
+		for _, rec := range recs {
+			if rec.Token != nil {
+				rec.Token.mu.RLock()
+			}
+			line, err := json.Marshal(rec)
+			if rec.Token != nil {
+				rec.Token.mu.RUnlock()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("encode handoff: %w", err)
+			}
+			out[node] = append(out[node], line)
+		}
+	}
+	return out, nil
+}
+
+// DropHandedOff removes the vault and network tokens owner assigns to node,
+// once node has imported them, and returns how many of each it removed.
+// Shared data keys stay, as other tokens may still use them.
+func (s *Service) DropHandedOff(owner func(token string) string, node string) (tokens, networkTokens int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, tokenData := range s.tokens {
+		if owner(token) == node {
+			s.removeLocked(tokenData)
+			tokens++
+		}
+	}
+	for token := range s.networkTokens {
+		if owner(token) == node {
+			delete(s.networkTokens, token)
+			delete(s.tokenLinks, token)
+			s.logRemoval(walDeleteNetwork, token)
+			networkTokens++
+		}
+	}
+	return tokens, networkTokens
+}
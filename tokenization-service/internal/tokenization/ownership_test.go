@@ -0,0 +1,82 @@
+package tokenization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenOwnership(t *testing.T) {
+	owns := func(token string) bool { return token[len(token)-6] < '5' }
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithTokenOwnership(owns))
+	year := time.Now().Year() + 2
+
+	for i, pan := range []string{"4532015112830366", "5425233430109903", "371449635398431", "6011111111111117"} {
+		tokenData, err := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+		if err != nil {
+			t.Fatalf("TokenizeCardWithOptions(%d) error = %v", i, err)
+		}
+		if !owns(tokenData.Token) {
+			t.Errorf("token %s is not owned by the node", tokenData.Token)
+		}
+		if i == 0 {
+			nt, err := service.ProvisionNetworkToken(tokenData.Token, "m1")
+			if err != nil || !owns(nt.Token) {
+				t.Errorf("ProvisionNetworkToken() = %v, %v, want a network token the node owns", nt, err)
+			}
+		}
+	}
+
+	never := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithTokenOwnership(func(string) bool { return false }))
+	if _, err := never.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{}); err != ErrNoOwnedToken {
+		t.Errorf("TokenizeCardWithOptions() owning nothing error = %v, want %v", err, ErrNoOwnedToken)
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	from := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithKeyScope(KeyScopeMerchant))
+	year := time.Now().Year() + 2
+	var tokens []string
+	for _, pan := range []string{"4532015112830366", "5425233430109903", "371449635398431", "6011111111111117"} {
+		tokenData, _ := from.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+		tokens = append(tokens, tokenData.Token)
+	}
+	nt, _ := from.ProvisionNetworkToken(tokens[0], "m1")
+
+	// The first token and the network token move; the rest stay
+	owner := func(token string) string {
+		if token == tokens[0] || token == nt.Token {
+			return "b"
+		}
+		return ""
+	}
+	groups, err := from.HandoffRecords(owner)
+	if err != nil {
+		t.Fatalf("HandoffRecords() error = %v", err)
+	}
+	if len(groups) != 1 || len(groups["b"]) != 4 {
+		t.Fatalf("HandoffRecords() = %d groups, %d records for b, want the data key, token, network token and link", len(groups), len(groups["b"]))
+	}
+
+	to := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithKeyScope(KeyScopeMerchant))
+	for _, record := range groups["b"] {
+		if err := to.ApplyChange(record); err != nil {
+			t.Fatalf("ApplyChange() error = %v", err)
+		}
+	}
+	if moved, movedNetwork := from.DropHandedOff(owner, "b"); moved != 1 || movedNetwork != 1 {
+		t.Errorf("DropHandedOff() = %d, %d, want 1 and 1", moved, movedNetwork)
+	}
+
+	if pan, _, _, err := to.DetokenizeCardForMerchant(tokens[0], "m1"); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCardForMerchant() on the new owner = %q, %v", pan, err)
+	}
+	if got, err := to.VaultTokenFor(nt.Token, "m1"); err != nil || got.Token != tokens[0] {
+		t.Errorf("VaultTokenFor() on the new owner = %v, %v, want %q", got, err, tokens[0])
+	}
+	if _, err := from.TokenInfo(tokens[0], "m1"); err != ErrTokenNotFound {
+		t.Errorf("TokenInfo() of a handed-off token error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, _, _, err := from.DetokenizeCardForMerchant(tokens[1], "m1"); err != nil {
+		t.Errorf("DetokenizeCardForMerchant() of a kept token error = %v", err)
+	}
+}
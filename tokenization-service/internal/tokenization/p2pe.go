@@ -0,0 +1,59 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrP2PEUnavailable      = errors.New("P2PE decryption is not enabled")
+	ErrInvalidEncryptedCard = errors.New("encrypted card data does not decrypt to a card")
+)
+
+// P2PEDecrypter is implemented by HSM clients that decrypt track data a
+// P2PE terminal encrypted under DUKPT (see go-common/dukpt). Implementations
+// return an error wrapping ErrInvalidEncryptedCard when the HSM rejects the
+// data, so callers can tell a bad read from an HSM outage.
+type P2PEDecrypter interface {
+	DecryptP2PE(bdkID string, ksn, encryptedTrack []byte) (pan string, expiryMonth, expiryYear int, err error)
+}
+
+// P2PECard is the card data recovered from an encrypted read. It holds the
+// clear PAN, so it must not leave the decryption zone: tokenize it and drop
+// it.
+type P2PECard struct {
+	PAN         string
+	ExpiryMonth int
+	ExpiryYear  int
+}
+
+// WithP2PE makes the service part of the P2PE decryption zone: it decrypts
+// card data encrypted by terminals injected with keys derived from the HSM
+// BDK bdkID. The HSM client must implement P2PEDecrypter and be granted the
+// HSM's P2PE decryption role.
+func WithP2PE(bdkID string) Option {
+	return func(s *Service) { s.p2peBDK = bdkID }
+}
+
+// DecryptsP2PE reports whether P2PE decryption is enabled
+func (s *Service) DecryptsP2PE() bool {
+	_, ok := s.hsmClient.(P2PEDecrypter)
+	return ok && s.p2peBDK != ""
+}
+
+// DecryptP2PE decrypts a terminal's encrypted read with the KSN it was
+// sent with
+func (s *Service) DecryptP2PE(ksn, encryptedTrack []byte) (P2PECard, error) {
+	d, ok := s.hsmClient.(P2PEDecrypter)
+	if !ok || s.p2peBDK == "" {
+		return P2PECard{}, ErrP2PEUnavailable
+	}
+	pan, expiryMonth, expiryYear, err := d.DecryptP2PE(s.p2peBDK, ksn, encryptedTrack)
+	if err != nil {
+		if errors.Is(err, ErrInvalidEncryptedCard) {
+			return P2PECard{}, err
+		}
+		return P2PECard{}, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return P2PECard{PAN: pan, ExpiryMonth: expiryMonth, ExpiryYear: expiryYear}, nil
+}
@@ -0,0 +1,68 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paymentgateway/go-common/dukpt"
+)
+
+// p2peHSM decrypts track 2 data like the HSM's DecryptP2PE
+type p2peHSM struct {
+	*gcmHSM
+	bdk []byte
+}
+
+func (h *p2peHSM) DecryptP2PE(bdkID string, ksnBytes, encryptedTrack []byte) (string, int, int, error) {
+	ksn, err := dukpt.KSNFromBytes(ksnBytes)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrInvalidEncryptedCard, err)
+	}
+	ipek, _ := dukpt.DeriveIPEK(h.bdk, ksn)
+	key, _ := dukpt.DeriveKey(ipek, ksn)
+	dataKey, _ := dukpt.DataKey(key)
+	track, err := dukpt.DecryptData(dataKey, encryptedTrack)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrInvalidEncryptedCard, err)
+	}
+	pan, rest, ok := strings.Cut(strings.TrimPrefix(string(track), ";"), "=")
+	if !ok || len(rest) < 4 {
+		return "", 0, 0, ErrInvalidEncryptedCard
+	}
+	year, _ := strconv.Atoi(rest[:2])
+	month, _ := strconv.Atoi(rest[2:4])
+	return pan, month, 2000 + year, nil
+}
+
+func TestDecryptP2PE(t *testing.T) {
+	hsm := &p2peHSM{gcmHSM: newGCMHSM(t), bdk: []byte("0123456789ABCDEF")}
+	ksn, _ := dukpt.ParseKSN("FFFF9876543210E00000")
+	ipek, _ := dukpt.DeriveIPEK(hsm.bdk, ksn)
+	terminal, _ := dukpt.NewTerminal(ipek, ksn)
+	year := time.Now().Year()%100 + 2
+	txKSN, encrypted, _ := terminal.Encrypt([]byte(fmt.Sprintf(";4532015112830366=%02d121011234567?", year)))
+
+	if _, err := NewService(hsm, "test-key", 24*time.Hour).DecryptP2PE(txKSN[:], encrypted); !errors.Is(err, ErrP2PEUnavailable) {
+		t.Errorf("DecryptP2PE() without a BDK error = %v", err)
+	}
+
+	service := NewService(hsm, "test-key", 24*time.Hour, WithP2PE("bdk"))
+	card, err := service.DecryptP2PE(txKSN[:], encrypted)
+	if err != nil {
+		t.Fatalf("DecryptP2PE() error = %v", err)
+	}
+	if card.PAN != "4532015112830366" || card.ExpiryMonth != 12 || card.ExpiryYear != 2000+year {
+		t.Errorf("DecryptP2PE() = %+v", card)
+	}
+	if _, err := service.TokenizeCard(card.PAN, card.ExpiryMonth, card.ExpiryYear, ""); err != nil {
+		t.Errorf("TokenizeCard() of the decrypted card error = %v", err)
+	}
+
+	if _, err := service.DecryptP2PE(txKSN[:], encrypted[:5]); !errors.Is(err, ErrInvalidEncryptedCard) {
+		t.Errorf("DecryptP2PE() of a truncated read error = %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package tokenization
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPARSharedByTokensOfCard(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	m1, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	m2, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	other, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	if err := ValidatePAR(m1.PAR); err != nil || !strings.HasPrefix(m1.PAR, "V001") {
+		t.Fatalf("PAR = %q, want a Visa PAR", m1.PAR)
+	}
+	if m2.PAR != m1.PAR {
+		t.Errorf("PAR for another merchant = %q, want %q", m2.PAR, m1.PAR)
+	}
+	if other.PAR == m1.PAR || !strings.HasPrefix(other.PAR, "M001") {
+		t.Errorf("PAR of another card = %q", other.PAR)
+	}
+	nt, err := service.ProvisionNetworkToken(m2.Token, "m2")
+	if err != nil {
+		t.Fatalf("ProvisionNetworkToken() error = %v", err)
+	}
+	if nt.PAR != m1.PAR {
+		t.Errorf("network token PAR = %q, want %q", nt.PAR, m1.PAR)
+	}
+
+	tokens, _ := service.ListTokens(TokenFilter{MerchantID: "m1", PAR: m1.PAR}, nil, 10)
+	if len(tokens) != 1 || tokens[0].Token != m1.Token || tokens[0].PAR != m1.PAR {
+		t.Errorf("ListTokens() by PAR = %+v, want only %s", tokens, m1.Token)
+	}
+}
+
+func TestPARSecret(t *testing.T) {
+	year := time.Now().Year() + 2
+	par := func(opts ...Option) string {
+		service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, opts...)
+		tokenData, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+		return tokenData.PAR
+	}
+	if a, b := par(WithPARSecret([]byte("shared"))), par(WithPARSecret([]byte("shared"))); a != b {
+		t.Errorf("PARs under the same secret = %q, %q", a, b)
+	}
+	if a, b := par(), par(); a == b {
+		t.Error("PARs under random secrets are equal")
+	}
+}
+
+func TestPARRecovered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	service := openTestWAL(t, path)
+	year := time.Now().Year() + 2
+	first, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	legacy, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	legacy.mu.Lock()
+	legacy.PAR = ""
+	service.logToken(legacy)
+	legacy.mu.Unlock()
+
+	// The card keeps its PAR although the restarted service has another
+	// secret, and a token logged without one is given one
+	recovered := openTestWAL(t, path)
+	again, _ := recovered.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	if again.PAR != first.PAR {
+		t.Errorf("PAR after recovery = %q, want %q", again.PAR, first.PAR)
+	}
+	info, _ := recovered.TokenInfo(legacy.Token, "m1")
+	if err := ValidatePAR(info.PAR); err != nil {
+		t.Errorf("PAR of a token logged without one = %q", info.PAR)
+	}
+}
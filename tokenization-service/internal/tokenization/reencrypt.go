@@ -0,0 +1,187 @@
+package tokenization
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/paymentgateway/go-common/securebytes"
+)
+
+// ErrStaleKeyVersion is returned when the HSM encrypts under a key version
+// older than the one ciphertexts are being moved to
+var ErrStaleKeyVersion = errors.New("HSM encrypted under a stale key version")
+
+// ReencryptResult reports a re-encryption pass
+type ReencryptResult struct {
+	// Tokens is the number of PANs re-encrypted by the HSM
+	Tokens int
+	// DataKeys is the number of data keys re-wrapped. The PANs under them
+	// are untouched, since the data key itself does not change.
+	DataKeys int
+	// Remaining is the number of ciphertexts still under an older version
+	Remaining int
+}
+
+// KeyVersionUsage counts the ciphertexts the HSM key encrypted directly, by
+// key version: PANs of tokens without a data key, and wrapped data keys.
+// Unlike VaultStats.ByKeyVersion it counts a shared data key once, which is
+// what re-encryption has to touch.
+func (s *Service) KeyVersionUsage() map[int]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usage := make(map[int]int)
+	for _, tokenData := range s.tokens {
+		tokenData.mu.RLock()
+		if tokenData.DataKeyID == "" {
+			usage[tokenData.KeyVersion]++
+		}
+		tokenData.mu.RUnlock()
+	}
+	for _, key := range s.dataKeys {
+		usage[key.KeyVersion]++
+	}
+	return usage
+}
+
+// ReencryptBefore moves up to limit ciphertexts encrypted under an HSM key
+// version older than version to the key's current version, data keys
+// first. Revoked and deleted tokens are re-encrypted too, as they can still
+// be restored or read by the purge. Changes are logged without a new token
+// revision, since nothing a client sees changes.
+func (s *Service) ReencryptBefore(version, limit int) (ReencryptResult, error) {
+	var res ReencryptResult
+	keyIDs, tokens := s.staleCiphertexts(version)
+
+	for _, keyID := range keyIDs {
+		if res.Tokens+res.DataKeys == limit {
+			break
+		}
+		done, err := s.rewrapDataKey(keyID, version)
+		if err != nil {
+			return res, fmt.Errorf("data key %s: %w", keyID, err)
+		}
+		if done {
+			res.DataKeys++
+		}
+	}
+	for _, tokenData := range tokens {
+		if res.Tokens+res.DataKeys == limit {
+			break
+		}
+		done, err := s.reencryptToken(tokenData, version)
+		if err != nil {
+			return res, fmt.Errorf("token ending %s: %w", tokenData.LastFour, err)
+		}
+		if done {
+			res.Tokens++
+		}
+	}
+
+	for v, n := range s.KeyVersionUsage() {
+		if v < version {
+			res.Remaining += n
+		}
+	}
+	return res, nil
+}
+
+// staleCiphertexts lists the data keys and directly encrypted tokens under a
+// key version older than version, data keys in ID order
+func (s *Service) staleCiphertexts(version int) ([]string, []*TokenData) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keyIDs []string
+	for id, key := range s.dataKeys {
+		if key.KeyVersion < version {
+			keyIDs = append(keyIDs, id)
+		}
+	}
+	sort.Strings(keyIDs)
+
+	var tokens []*TokenData
+	for _, tokenData := range s.tokens {
+		tokenData.mu.RLock()
+		if tokenData.DataKeyID == "" && tokenData.KeyVersion < version {
+			tokens = append(tokens, tokenData)
+		}
+		tokenData.mu.RUnlock()
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Token < tokens[j].Token })
+	return keyIDs, tokens
+}
+
+// rewrapDataKey unwraps a data key and wraps it again under the current key
+// version. The stored key is replaced, not modified, since readers use it
+// without holding s.mu. It reports false if the key was destroyed or
+// re-wrapped meanwhile.
+func (s *Service) rewrapDataKey(keyID string, version int) (bool, error) {
+	s.mu.RLock()
+	key, ok := s.dataKeys[keyID]
+	s.mu.RUnlock()
+	if !ok || key.KeyVersion >= version {
+		return false, nil
+	}
+
+	dek, err := s.hsmClient.Decrypt(s.keyID, key.Wrapped, key.Nonce, []byte(key.ID), key.KeyVersion)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	defer securebytes.Zero(dek)
+	wrapped, nonce, newVersion, err := s.hsmClient.Encrypt(s.keyID, dek, []byte(key.ID))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrEncryptionFailed, err)
+	}
+	if newVersion < version {
+		return false, fmt.Errorf("%w: got version %d, want at least %d", ErrStaleKeyVersion, newVersion, version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dataKeys[keyID] != key {
+		return false, nil
+	}
+	rewrapped := *key
+	rewrapped.Wrapped, rewrapped.Nonce, rewrapped.KeyVersion = wrapped, nonce, newVersion
+	s.dataKeys[keyID] = &rewrapped
+	s.logDataKey(&rewrapped)
+	return true, nil
+}
+
+// reencryptToken decrypts a token's PAN and encrypts it again under the
+// current key version. It reports false if the token was removed or
+// re-encrypted meanwhile.
+func (s *Service) reencryptToken(tokenData *TokenData, version int) (bool, error) {
+	s.mu.RLock()
+	current := s.tokens[tokenData.Token]
+	s.mu.RUnlock()
+	if current != tokenData {
+		return false, nil
+	}
+
+	tokenData.mu.Lock()
+	defer tokenData.mu.Unlock()
+	if tokenData.DataKeyID != "" || tokenData.KeyVersion >= version {
+		return false, nil
+	}
+
+	aad := []byte(fmt.Sprintf("%d-%d", tokenData.ExpiryMonth, tokenData.ExpiryYear))
+	plaintext, err := s.openPAN(tokenData, aad)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	defer securebytes.Zero(plaintext)
+	ciphertext, nonce, newVersion, err := s.sealPAN(plaintext, aad)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrEncryptionFailed, err)
+	}
+	if newVersion < version {
+		return false, fmt.Errorf("%w: got version %d, want at least %d", ErrStaleKeyVersion, newVersion, version)
+	}
+
+	tokenData.EncryptedPAN, tokenData.Nonce, tokenData.KeyVersion = ciphertext, nonce, newVersion
+	s.logToken(tokenData)
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package tokenization
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReencryptBefore(t *testing.T) {
+	version := 1
+	hsm := &MockHSMClient{
+		encryptFunc: func(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+			return append([]byte(nil), plaintext...), []byte("nonce123"), version, nil
+		},
+	}
+	service := NewService(hsm, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	a, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+	b, _ := service.TokenizeCard("5425233430109903", 12, year, "123")
+	service.DeleteToken(b.Token, "", AnyRevision)
+
+	version = 2
+	res, err := service.ReencryptBefore(2, 1)
+	if err != nil || res.Tokens != 1 || res.Remaining != 1 {
+		t.Fatalf("ReencryptBefore() = %+v, %v; want 1 token done, 1 remaining", res, err)
+	}
+	res, err = service.ReencryptBefore(2, 10)
+	if err != nil || res.Tokens != 1 || res.Remaining != 0 {
+		t.Fatalf("ReencryptBefore() = %+v, %v; want the deleted token done too", res, err)
+	}
+	if usage := service.KeyVersionUsage(); usage[2] != 2 || usage[1] != 0 {
+		t.Errorf("KeyVersionUsage() = %v; want both tokens under version 2", usage)
+	}
+	if a.Revision != 1 {
+		t.Errorf("revision = %d; re-encryption must not change it", a.Revision)
+	}
+	if pan, _, _, err := service.DetokenizeCard(a.Token); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCard() = %q, %v", pan, err)
+	}
+}
+
+func TestReencryptBeforeStaleVersion(t *testing.T) {
+	hsm := newGCMHSM(t)
+	service := NewService(hsm, "test-key", 24*time.Hour, WithKeyScope(KeyScopeMerchant))
+	service.TokenizeCard("4532015112830366", 12, time.Now().Year()+2, "123")
+
+	// The HSM still encrypts under version 1, so nothing can move to 2
+	if _, err := service.ReencryptBefore(2, 10); !errors.Is(err, ErrStaleKeyVersion) {
+		t.Fatalf("ReencryptBefore() error = %v, want %v", err, ErrStaleKeyVersion)
+	}
+	if usage := service.KeyVersionUsage(); usage[1] != 1 {
+		t.Errorf("KeyVersionUsage() = %v; want the data key left under version 1", usage)
+	}
+}
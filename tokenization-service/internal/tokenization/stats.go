@@ -0,0 +1,133 @@
+package tokenization
+
+import "time"
+
+// TokenStatus is the state a token is counted under in VaultStats
+type TokenStatus string
+
+const (
+	StatusActive    TokenStatus = "active"
+	StatusExpired   TokenStatus = "expired"
+	StatusRevoked   TokenStatus = "revoked"
+	StatusSuspended TokenStatus = "suspended"
+	StatusDeleted   TokenStatus = "deleted"
+)
+
+// Rough per-entry overheads of the in-memory vault: the TokenData struct with
+// its times, mutex and map entry, a PAN hash index entry and a data key
+const (
+	tokenOverheadBytes   = 320
+	indexOverheadBytes   = 96
+	dataKeyOverheadBytes = 160
+)
+
+// VaultStats is a point-in-time summary of the vault for capacity planning
+type VaultStats struct {
+	Tokens     int
+	ByStatus   map[TokenStatus]int
+	ByBrand    map[string]int
+	ByMerchant map[string]int
+	// ByKeyVersion counts tokens by the HSM key version their PAN, or the
+	// data key encrypting it, is encrypted under
+	ByKeyVersion map[int]int
+	// Shredded counts tokens whose data key was destroyed
+	Shredded      int
+	DataKeys      int
+	NetworkTokens int
+	// CiphertextBytes is the size of the encrypted PANs and their nonces
+	CiphertextBytes int64
+	// EstimatedBytes estimates the memory the vault holds, including
+	// indexes and data keys
+	EstimatedBytes int64
+	IssuedLastDay  int
+	IssuedLastWeek int
+	// GrowthPerDay is the average number of tokens issued per day over the
+	// last week
+	GrowthPerDay float64
+	OldestIssued time.Time
+	NewestIssued time.Time
+}
+
+// statusAt returns the status a token is counted under. A token that is
+// several things at once counts as the most final one.
+func (t *TokenData) statusAt(now time.Time) TokenStatus {
+	switch {
+	case !t.DeletedAt.IsZero():
+		return StatusDeleted
+	case !t.IsActive:
+		return StatusRevoked
+	case t.Suspended:
+		return StatusSuspended
+	case !now.Before(t.ExpiresAt):
+		return StatusExpired
+	}
+	return StatusActive
+}
+
+// estimatedBytes estimates the memory a token's data holds
+func (t *TokenData) estimatedBytes() int64 {
+	n := int64(tokenOverheadBytes + len(t.EncryptedPAN) + len(t.Nonce) + len(t.Token) + len(t.PANHash) +
+		len(t.LastFour) + len(t.CardBrand) + len(t.MerchantID) + len(t.DataKeyID))
+	for k, v := range t.Metadata {
+		n += int64(len(k) + len(v))
+	}
+	return n
+}
+
+// Stats summarizes the vault's contents
+func (s *Service) Stats() VaultStats {
+	now := time.Now()
+	stats := VaultStats{
+		ByStatus:     make(map[TokenStatus]int),
+		ByBrand:      make(map[string]int),
+		ByMerchant:   make(map[string]int),
+		ByKeyVersion: make(map[int]int),
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, tokenData := range s.tokens {
+		tokenData.mu.RLock()
+		stats.Tokens++
+		stats.ByStatus[tokenData.statusAt(now)]++
+		stats.ByBrand[tokenData.CardBrand]++
+		stats.ByMerchant[tokenData.MerchantID]++
+
+		if tokenData.DataKeyID == "" {
+			stats.ByKeyVersion[tokenData.KeyVersion]++
+		} else if key, ok := s.dataKeys[tokenData.DataKeyID]; ok {
+			stats.ByKeyVersion[key.KeyVersion]++
+		} else {
+			stats.Shredded++
+		}
+
+		stats.CiphertextBytes += int64(len(tokenData.EncryptedPAN) + len(tokenData.Nonce))
+		stats.EstimatedBytes += tokenData.estimatedBytes()
+
+		issued := tokenData.CreatedAt
+		if now.Sub(issued) < 24*time.Hour {
+			stats.IssuedLastDay++
+		}
+		if now.Sub(issued) < 7*24*time.Hour {
+			stats.IssuedLastWeek++
+		}
+		if stats.OldestIssued.IsZero() || issued.Before(stats.OldestIssued) {
+			stats.OldestIssued = issued
+		}
+		if issued.After(stats.NewestIssued) {
+			stats.NewestIssued = issued
+		}
+		tokenData.mu.RUnlock()
+	}
+	for indexKey := range s.panHashIndex {
+		stats.EstimatedBytes += indexOverheadBytes + int64(len(indexKey))
+	}
+	for _, key := range s.dataKeys {
+		stats.EstimatedBytes += dataKeyOverheadBytes + int64(len(key.ID)+len(key.MerchantID)+len(key.Wrapped)+len(key.Nonce))
+	}
+	stats.DataKeys = len(s.dataKeys)
+	stats.NetworkTokens = len(s.networkTokens)
+	stats.GrowthPerDay = float64(stats.IssuedLastWeek) / 7
+	return stats
+}
@@ -0,0 +1,53 @@
+package tokenization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+
+	if stats := service.Stats(); stats.Tokens != 0 || stats.EstimatedBytes != 0 || !stats.OldestIssued.IsZero() {
+		t.Errorf("Stats() of empty vault = %+v", stats)
+	}
+
+	active, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	revoked, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	deleted, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	service.RevokeTokenForMerchant(revoked.Token, "m1", AnyRevision)
+	service.DeleteToken(deleted.Token, "m2", AnyRevision)
+	service.ProvisionNetworkToken(active.Token, "m1")
+
+	stats := service.Stats()
+	if stats.Tokens != 3 || stats.IssuedLastDay != 3 || stats.IssuedLastWeek != 3 {
+		t.Errorf("Stats() counts = %+v", stats)
+	}
+	for status, want := range map[TokenStatus]int{StatusActive: 1, StatusRevoked: 1, StatusDeleted: 1, StatusExpired: 0} {
+		if got := stats.ByStatus[status]; got != want {
+			t.Errorf("ByStatus[%s] = %d, want %d", status, got, want)
+		}
+	}
+	if stats.ByBrand["VISA"] != 2 || stats.ByBrand["MASTERCARD"] != 1 {
+		t.Errorf("ByBrand = %v", stats.ByBrand)
+	}
+	if stats.ByMerchant["m1"] != 2 || stats.ByMerchant["m2"] != 1 {
+		t.Errorf("ByMerchant = %v", stats.ByMerchant)
+	}
+	if stats.ByKeyVersion[1] != 3 {
+		t.Errorf("ByKeyVersion = %v, want all tokens under version 1", stats.ByKeyVersion)
+	}
+	if stats.NetworkTokens != 1 {
+		t.Errorf("NetworkTokens = %d, want 1", stats.NetworkTokens)
+	}
+	if stats.CiphertextBytes == 0 || stats.EstimatedBytes <= stats.CiphertextBytes {
+		t.Errorf("CiphertextBytes = %d, EstimatedBytes = %d", stats.CiphertextBytes, stats.EstimatedBytes)
+	}
+	if stats.GrowthPerDay != 3.0/7 {
+		t.Errorf("GrowthPerDay = %v, want %v", stats.GrowthPerDay, 3.0/7)
+	}
+	if stats.NewestIssued.Before(stats.OldestIssued) {
+		t.Errorf("NewestIssued %v before OldestIssued %v", stats.NewestIssued, stats.OldestIssued)
+	}
+}
@@ -0,0 +1,406 @@
+package tokenization
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// VaultStore holds the vault's mutation records, the lines of the
+// write-ahead log. The WAL is one; a MigratingStore moves the vault from one
+// store to another without downtime.
+type VaultStore interface {
+	// Append stores an encoded record. A failed write is returned by the
+	// next Flush.
+	Append(line []byte)
+	// Replay hands every stored record to apply, oldest first
+	Replay(apply func(line []byte) error) (int, error)
+	// Flush makes the appended records durable
+	Flush() error
+	Close() error
+}
+
+// WithStore keeps the vault's mutation records in store. Use WithWAL for a
+// single write-ahead log, which can also be compacted.
+func WithStore(store VaultStore) Option {
+	return func(s *Service) { s.store = store }
+}
+
+// ErrMigrationMode is returned for an unknown migration mode or a switch
+// that would lose records
+var ErrMigrationMode = errors.New("invalid vault migration mode")
+
+// MigrationMode is the phase of a migration between two stores
+type MigrationMode string
+
+const (
+	// MigrationOld reads and writes the old store only
+	MigrationOld MigrationMode = "old"
+	// MigrationDual writes both stores and recovers from both, the new
+	// store's records taking precedence over the old's
+	MigrationDual MigrationMode = "dual"
+	// MigrationNew reads and writes the new store only; there is no going
+	// back, as the old store no longer sees writes
+	MigrationNew MigrationMode = "new"
+)
+
+// ParseMigrationMode reads a mode name; "" is MigrationDual
+func ParseMigrationMode(s string) (MigrationMode, error) {
+	switch m := MigrationMode(s); m {
+	case "":
+		return MigrationDual, nil
+	case MigrationOld, MigrationDual, MigrationNew:
+		return m, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrMigrationMode, s)
+	}
+}
+
+// MigratingStore moves the vault from an old store to a new one. In dual
+// mode every record is written to both, and recovery reads both, copying
+// entries only the old store holds forward to the new one. Verify compares
+// the two and backfills the new store, after which the mode can move to new
+// and the old store be retired.
+type MigratingStore struct {
+	old, new VaultStore
+	// mu orders appends against replays and verification, so a backfill
+	// never lands after a newer record of the same entry
+	mu   sync.Mutex
+	mode MigrationMode
+	last *MigrationReport
+}
+
+// NewMigratingStore starts a migration from old to new in mode
+func NewMigratingStore(old, new VaultStore, mode MigrationMode) *MigratingStore {
+	return &MigratingStore{old: old, new: new, mode: mode}
+}
+
+// Mode returns the migration's current mode
+func (m *MigratingStore) Mode() MigrationMode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mode
+}
+
+// SetMode moves the migration to mode. Old and dual may switch either way,
+// and dual may move on to new; anything else would leave a store short of
+// records the vault relies on.
+func (m *MigratingStore) SetMode(mode MigrationMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mode == m.mode {
+		return nil
+	}
+	switch {
+	case mode != MigrationOld && mode != MigrationDual && mode != MigrationNew:
+		return fmt.Errorf("%w: %q", ErrMigrationMode, mode)
+	case m.mode == MigrationNew:
+		return fmt.Errorf("%w: cannot leave %s for %s", ErrMigrationMode, m.mode, mode)
+	case mode == MigrationNew && m.mode != MigrationDual:
+		return fmt.Errorf("%w: %s must go through %s", ErrMigrationMode, mode, MigrationDual)
+	}
+	m.mode = mode
+	return nil
+}
+
+// Append writes the record to the new store first, then the old
+func (m *MigratingStore) Append(line []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mode != MigrationOld {
+		m.new.Append(line)
+	}
+	if m.mode != MigrationNew {
+		m.old.Append(line)
+	}
+}
+
+// Flush flushes the stores being written and returns their errors
+func (m *MigratingStore) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	if m.mode != MigrationOld {
+		errs = append(errs, m.new.Flush())
+	}
+	if m.mode != MigrationNew {
+		errs = append(errs, m.old.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes both stores
+func (m *MigratingStore) Close() error {
+	return errors.Join(m.new.Close(), m.old.Close())
+}
+
+// Replay replays the store being read. In dual mode it replays the old
+// store's entries the new store has no record of, copies their latest state
+// forward to the new store, and then replays the new store.
+func (m *MigratingStore) Replay(apply func(line []byte) error) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch m.mode {
+	case MigrationOld:
+		return m.old.Replay(apply)
+	case MigrationNew:
+		return m.new.Replay(apply)
+	}
+
+	var (
+		newer   [][]byte
+		touched = make(map[string]bool)
+	)
+	if _, err := m.new.Replay(func(line []byte) error {
+		rec, err := decodeRecord(line)
+		if err != nil {
+			return err
+		}
+		for _, entry := range rec.entries() {
+			touched[entry] = true
+		}
+		newer = append(newer, line)
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("new store: %w", err)
+	}
+
+	var (
+		n       int
+		carried = newVaultEntries()
+	)
+	if _, err := m.old.Replay(func(line []byte) error {
+		rec, err := decodeRecord(line)
+		if err != nil {
+			return err
+		}
+		if entries := rec.entries(); len(entries) == 0 || touched[entries[0]] {
+			return nil
+		}
+		if err := apply(line); err != nil {
+			return err
+		}
+		carried.add(rec, line)
+		n++
+		return nil
+	}); err != nil {
+		return n, fmt.Errorf("old store: %w", err)
+	}
+	for _, line := range carried.lines() {
+		m.new.Append(line)
+	}
+	if err := m.new.Flush(); err != nil {
+		return n, fmt.Errorf("new store: %w", err)
+	}
+
+	for _, line := range newer {
+		if err := apply(line); err != nil {
+			return n, fmt.Errorf("new store: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// MigrationReport is the outcome of comparing the latest state of every
+// vault entry in the old and new stores. Entries are named by kind and ID,
+// e.g. token:tok_..., never by content.
+type MigrationReport struct {
+	Mode       MigrationMode `json:"mode"`
+	CheckedAt  time.Time     `json:"checked_at"`
+	OldEntries int           `json:"old_entries"`
+	NewEntries int           `json:"new_entries"`
+	// Missing entries are in the old store only
+	Missing int `json:"missing"`
+	// Differing entries are in both stores in different states
+	Differing int `json:"differing"`
+	// Extra entries are in the new store only, e.g. after a failed write to
+	// the old one
+	Extra int `json:"extra"`
+	// Backfilled entries were copied from the old store to the new one
+	Backfilled int `json:"backfilled"`
+	// Examples names up to MaxMigrationExamples of the entries that do not
+	// match
+	Examples []string `json:"examples,omitempty"`
+}
+
+// MaxMigrationExamples bounds the mismatched entries a report names
+const MaxMigrationExamples = 20
+
+// Consistent reports whether the new store holds every entry as the old one
+// does
+func (r MigrationReport) Consistent() bool {
+	return r.Missing == 0 && r.Differing == 0 && r.Extra == 0
+}
+
+// Verify compares the two stores. With backfill, in dual mode, entries
+// missing from the new store are copied to it from the old one; entries in
+// different states are left for an operator, as either may be stale.
+// Appends wait while the stores are read.
+func (m *MigratingStore) Verify(backfill bool) (MigrationReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := MigrationReport{Mode: m.mode, CheckedAt: time.Now()}
+	source, err := readVaultEntries(m.old)
+	if err != nil {
+		return report, fmt.Errorf("old store: %w", err)
+	}
+	target, err := readVaultEntries(m.new)
+	if err != nil {
+		return report, fmt.Errorf("new store: %w", err)
+	}
+	report.OldEntries, report.NewEntries = len(source.states), len(target.states)
+
+	example := func(entry, problem string) {
+		if len(report.Examples) < MaxMigrationExamples {
+			report.Examples = append(report.Examples, entry+": "+problem)
+		}
+	}
+	var missing [][]byte
+	for _, entry := range source.order() {
+		line := source.states[entry]
+		current, ok := target.states[entry]
+		switch {
+		case !ok:
+			report.Missing++
+			missing = append(missing, line)
+			example(entry, "missing")
+		case string(current) != string(line):
+			report.Differing++
+			example(entry, "differs")
+		}
+	}
+	for _, entry := range target.order() {
+		if _, ok := source.states[entry]; !ok {
+			report.Extra++
+			example(entry, "extra")
+		}
+	}
+
+	if backfill && m.mode == MigrationDual && len(missing) > 0 {
+		for _, line := range missing {
+			m.new.Append(line)
+		}
+		if err := m.new.Flush(); err != nil {
+			return report, fmt.Errorf("backfill new store: %w", err)
+		}
+		report.Backfilled = len(missing)
+	}
+	m.last = &report
+	return report, nil
+}
+
+// LastReport returns the most recent verification, or nil if none has run
+func (m *MigratingStore) LastReport() *MigrationReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+func decodeRecord(line []byte) (walRecord, error) {
+	var rec walRecord
+	err := json.Unmarshal(line, &rec)
+	return rec, err
+}
+
+// entries names the vault entries a record changes, the first being the one
+// it describes. Removing a network token also removes its link.
+func (rec walRecord) entries() []string {
+	switch rec.Op {
+	case walPutToken:
+		if rec.Token != nil {
+			return []string{"token:" + rec.Token.Token}
+		}
+	case walDeleteToken:
+		return []string{"token:" + rec.ID}
+	case walPutDataKey:
+		if rec.DataKey != nil {
+			return []string{"data-key:" + rec.DataKey.ID}
+		}
+	case walDeleteDataKey:
+		return []string{"data-key:" + rec.ID}
+	case walPutNetworkToken:
+		if rec.NetworkToken != nil {
+			return []string{"network-token:" + rec.NetworkToken.Token}
+		}
+	case walDeleteNetwork:
+		return []string{"network-token:" + rec.ID, "link:" + rec.ID}
+	case walPutLink:
+		if rec.Link != nil {
+			return []string{"link:" + rec.Link.NetworkToken}
+		}
+	}
+	return nil
+}
+
+// vaultEntries is the latest record of every entry in a store, in the order
+// entries first appeared, so data keys come before the tokens they encrypt
+type vaultEntries struct {
+	states map[string][]byte
+	seen   map[string]bool
+	first  []string
+}
+
+func newVaultEntries() *vaultEntries {
+	return &vaultEntries{states: make(map[string][]byte), seen: make(map[string]bool)}
+}
+
+func readVaultEntries(store VaultStore) (*vaultEntries, error) {
+	entries := newVaultEntries()
+	_, err := store.Replay(func(line []byte) error {
+		rec, err := decodeRecord(line)
+		if err != nil {
+			return err
+		}
+		entries.add(rec, line)
+		return nil
+	})
+	return entries, err
+}
+
+func (e *vaultEntries) add(rec walRecord, line []byte) {
+	names := rec.entries()
+	if len(names) == 0 {
+		return
+	}
+	switch rec.Op {
+	case walDeleteToken, walDeleteDataKey, walDeleteNetwork:
+		for _, name := range names {
+			delete(e.states, name)
+		}
+		return
+	}
+	if !e.seen[names[0]] {
+		e.seen[names[0]] = true
+		e.first = append(e.first, names[0])
+	}
+	e.states[names[0]] = line
+}
+
+// order returns the entries still present, in order of first appearance
+func (e *vaultEntries) order() []string {
+	order := make([]string, 0, len(e.states))
+	for _, name := range e.first {
+		if _, ok := e.states[name]; ok {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// lines returns the latest record of every entry still present
+func (e *vaultEntries) lines() [][]byte {
+	order := e.order()
+	lines := make([][]byte, len(order))
+	for i, name := range order {
+		lines[i] = e.states[name]
+	}
+	return lines
+}
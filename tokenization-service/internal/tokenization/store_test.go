@@ -0,0 +1,119 @@
+package tokenization
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestMigration(t *testing.T, oldPath, newPath string, mode MigrationMode) (*Service, *MigratingStore) {
+	t.Helper()
+	var wals [2]*WAL
+	for i, path := range []string{oldPath, newPath} {
+		wal, err := OpenWAL(path, WALConfig{Sync: true})
+		if err != nil {
+			t.Fatalf("OpenWAL() error = %v", err)
+		}
+		wals[i] = wal
+	}
+	store := NewMigratingStore(wals[0], wals[1], mode)
+	t.Cleanup(func() { store.Close() })
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithStore(store))
+	if _, err := service.RecoverWAL(); err != nil {
+		t.Fatalf("RecoverWAL() error = %v", err)
+	}
+	return service, store
+}
+
+func TestMigratingStoreDualReadCopiesForward(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.wal"), filepath.Join(dir, "new.wal")
+	year := time.Now().Year() + 2
+
+	// Tokens from before the migration are only in the old log
+	service := openTestWAL(t, oldPath)
+	before, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	revoked, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	service.wal.Close()
+
+	service, store := openTestMigration(t, oldPath, newPath, MigrationDual)
+	if _, _, _, err := service.DetokenizeCardForMerchant(before.Token, "m1"); err != nil {
+		t.Errorf("DetokenizeCardForMerchant() of an old-only token error = %v", err)
+	}
+	// Newer records of an entry win over the old store's
+	service.RevokeTokenForMerchant(revoked.Token, "m1", AnyRevision)
+	after, _ := service.TokenizeCardWithOptions("371449635398431", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	store.Close()
+
+	service, store = openTestMigration(t, oldPath, newPath, MigrationDual)
+	if valid, _ := service.ValidateTokenForMerchant(revoked.Token, "m1"); valid {
+		t.Error("token revoked during the migration is valid after recovery")
+	}
+	if report, err := store.Verify(false); err != nil || !report.Consistent() {
+		t.Errorf("Verify() = %+v, %v, want consistent stores", report, err)
+	}
+	store.Close()
+
+	// Recovery copied the old entries forward, so the new log stands alone
+	recovered := openTestWAL(t, newPath)
+	for _, token := range []string{before.Token, after.Token} {
+		if _, _, _, err := recovered.DetokenizeCardForMerchant(token, "m1"); err != nil {
+			t.Errorf("DetokenizeCardForMerchant(%s) from the new log error = %v", token, err)
+		}
+	}
+	if valid, _ := recovered.ValidateTokenForMerchant(revoked.Token, "m1"); valid {
+		t.Error("revoked token is valid in the new log")
+	}
+}
+
+func TestMigratingStoreVerifyBackfillsAndSwitchesModes(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.wal"), filepath.Join(dir, "new.wal")
+	year := time.Now().Year() + 2
+	service, store := openTestMigration(t, oldPath, newPath, MigrationOld)
+
+	first, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	if err := store.SetMode(MigrationNew); !errors.Is(err, ErrMigrationMode) {
+		t.Errorf("SetMode(new) from old error = %v, want %v", err, ErrMigrationMode)
+	}
+	if err := store.SetMode(MigrationDual); err != nil {
+		t.Fatalf("SetMode(dual) error = %v", err)
+	}
+	second, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	// The token written before dual mode is missing
+	report, err := store.Verify(true)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if report.Missing != 1 || report.Differing != 0 || report.Extra != 0 || report.Backfilled != 1 {
+		t.Errorf("Verify() = %+v, want the missing token backfilled", report)
+	}
+	if report, _ = store.Verify(true); !report.Consistent() || report.Backfilled != 0 {
+		t.Errorf("Verify() after backfill = %+v, want consistent stores", report)
+	}
+	if last := store.LastReport(); last == nil || last.NewEntries != 2 {
+		t.Errorf("LastReport() = %+v, want both tokens in the new store", last)
+	}
+
+	if err := store.SetMode(MigrationNew); err != nil {
+		t.Fatalf("SetMode(new) error = %v", err)
+	}
+	service.RevokeTokenForMerchant(first.Token, "m1", AnyRevision)
+	if err := store.SetMode(MigrationDual); !errors.Is(err, ErrMigrationMode) {
+		t.Errorf("SetMode(dual) from new error = %v, want %v", err, ErrMigrationMode)
+	}
+	store.Close()
+
+	recovered := openTestWAL(t, newPath)
+	if valid, _ := recovered.ValidateTokenForMerchant(first.Token, "m1"); valid {
+		t.Error("token revoked in new mode is valid in the new log")
+	}
+	if _, _, _, err := recovered.DetokenizeCardForMerchant(second.Token, "m1"); err != nil {
+		t.Errorf("DetokenizeCardForMerchant() from the new log error = %v", err)
+	}
+	if _, err := ParseMigrationMode("both"); !errors.Is(err, ErrMigrationMode) {
+		t.Errorf("ParseMigrationMode(both) error = %v, want %v", err, ErrMigrationMode)
+	}
+}
@@ -8,10 +8,15 @@ import (
 	"fmt"
 	"math/big"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/paymentgateway/go-common/pagination"
+	"github.com/paymentgateway/go-common/redact"
+	"github.com/paymentgateway/go-common/securebytes"
 )
 
 var (
@@ -23,9 +28,23 @@ var (
 	ErrDuplicateToken    = errors.New("duplicate token generated")
 	ErrEncryptionFailed  = errors.New("encryption failed")
 	ErrDecryptionFailed  = errors.New("decryption failed")
+	ErrInvalidTTL        = errors.New("invalid token TTL")
+	ErrInvalidMetadata   = errors.New("invalid token metadata")
+	ErrInvalidFingerprint = errors.New("invalid PAN fingerprint")
+	ErrTokenNotDeleted    = errors.New("token is not deleted")
+	ErrRevisionMismatch   = errors.New("token was modified since the given revision")
+)
+
+// Metadata limits for TokenizeOptions
+const (
+	MaxMetadataEntries  = 16
+	MaxMetadataKeyLen   = 40
+	MaxMetadataValueLen = 500
 )
 
-// HSMClient interface for HSM operations
+// HSMClient interface for HSM operations. Encrypt must not retain the
+// plaintext, and Decrypt must return a slice the caller owns, since the
+// service zeroizes both after use.
 type HSMClient interface {
 	Encrypt(keyID string, plaintext, aad []byte) (ciphertext, nonce []byte, keyVersion int, err error)
 	Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error)
@@ -42,10 +61,30 @@ type TokenData struct {
 	CardBrand     string
 	ExpiryMonth   int
 	ExpiryYear    int
+	MerchantID    string
+	Metadata      map[string]string
+	DataKeyID     string
 	CreatedAt     time.Time
 	ExpiresAt     time.Time
+	RevokedAt     time.Time
+	// DeletedAt is set while the token is soft-deleted: it is hidden from
+	// every operation but RestoreToken until it is purged
+	DeletedAt     time.Time
 	IsActive      bool
+	// Suspended is set while the card network has the card suspended
+	Suspended     bool
+	// Domain restricts where the token may be used; nil restricts nothing
+	Domain        *Domain
+	// PAR is the payment account reference of the card, shared by all
+	// of its tokens
+	PAR           string `json:",omitempty"`
+	// Revision starts at 1 and is incremented by every change, so an
+	// update can be made conditional on the token not having changed
+	Revision      int64
 	mu            sync.RWMutex
+	// accountedBytes is the size the vault's capacity counts the token
+	// at; it is guarded by the service's mu
+	accountedBytes int64
 }
 
 // Service provides tokenization operations
@@ -53,24 +92,87 @@ type Service struct {
 	hsmClient     HSMClient
 	keyID         string
 	tokens        map[string]*TokenData  // token -> TokenData
-	panHashIndex  map[string]string      // PANHash -> token
+	panHashIndex  map[string]string      // merchant + PANHash -> token
+	dataKeys      map[string]*dataKey    // data key ID -> wrapped DEK
+	networkTokens map[string]*NetworkToken // network token -> token
+	tokenLinks    map[string]*TokenLink    // network token -> linked vault token
+	wal           *WAL
+	store         VaultStore // the WAL, or a migration between two
+	feed          *ChangeFeed
+	owns          func(token string) bool
 	mu            sync.RWMutex
 	tokenTTL      time.Duration
+	keyScope      KeyScope
+	envelope      *envelope
+	keyCache      *keyCache
+	// ciphertextEnvelopes stores directly encrypted PANs as self-describing
+	// envelopes; see WithCiphertextEnvelopes
+	ciphertextEnvelopes bool
+	// p2peBDK is the HSM BDK of P2PE terminals; see WithP2PE
+	p2peBDK string
+	// cryptogramKey is the HSM key of network token cryptograms; see
+	// WithCryptograms
+	cryptogramKey string
+	// parSecret derives payment account references, and parIndex holds
+	// the PAR of each card seen, by PAN hash; see WithPARSecret
+	parSecret []byte
+	parIndex  map[string]string
+	// capacity bounds the vault and tracks its estimated size; see
+	// WithCapacity
+	capacity *vaultCapacity
 }
 
+// fingerprintPattern matches a PAN fingerprint, a hex SHA-256
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
 // NewService creates a new tokenization service
-func NewService(hsmClient HSMClient, keyID string, tokenTTL time.Duration) *Service {
-	return &Service{
+func NewService(hsmClient HSMClient, keyID string, tokenTTL time.Duration, opts ...Option) *Service {
+	s := &Service{
 		hsmClient:    hsmClient,
 		keyID:        keyID,
 		tokens:       make(map[string]*TokenData),
 		panHashIndex: make(map[string]string),
+		dataKeys:     make(map[string]*dataKey),
+		networkTokens: make(map[string]*NetworkToken),
+		tokenLinks:    make(map[string]*TokenLink),
+		parIndex:      make(map[string]string),
 		tokenTTL:     tokenTTL,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.parSecret == nil {
+		s.parSecret = make([]byte, 32)
+		rand.Read(s.parSecret)
+	}
+	return s
+}
+
+// TokenTTL returns how long issued tokens stay valid
+func (s *Service) TokenTTL() time.Duration {
+	return s.tokenTTL
+}
+
+// TokenizeOptions carries the merchant scope, metadata and TTL a caller may
+// attach to a token. The zero value gives an unscoped token with the service
+// default TTL, which is what v1 callers get.
+type TokenizeOptions struct {
+	MerchantID string
+	Metadata   map[string]string
+	TTL        time.Duration
+	// Domain restricts where the token may be used
+	Domain *Domain
 }
 
 // TokenizeCard tokenizes a PAN using format-preserving encryption
 func (s *Service) TokenizeCard(pan string, expiryMonth, expiryYear int, cvv string) (*TokenData, error) {
+	return s.TokenizeCardWithOptions(pan, expiryMonth, expiryYear, cvv, TokenizeOptions{})
+}
+
+// TokenizeCardWithOptions tokenizes a PAN within a merchant scope. Tokens are
+// deduplicated per merchant, so the same PAN yields different tokens for
+// different merchants, and a new token for a different domain.
+func (s *Service) TokenizeCardWithOptions(pan string, expiryMonth, expiryYear int, cvv string, opts TokenizeOptions) (*TokenData, error) {
 	// Validate PAN
 	if err := validatePAN(pan); err != nil {
 		return nil, err
@@ -81,10 +183,26 @@ func (s *Service) TokenizeCard(pan string, expiryMonth, expiryYear int, cvv stri
 		return nil, err
 	}
 	
-	// Check if PAN already tokenized
+	ttl := s.tokenTTL
+	if opts.TTL != 0 {
+		if opts.TTL < 0 || opts.TTL > s.tokenTTL {
+			return nil, fmt.Errorf("%w: must be positive and at most %s", ErrInvalidTTL, s.tokenTTL)
+		}
+		ttl = opts.TTL
+	}
+	
+	if err := validateMetadata(opts.Metadata); err != nil {
+		return nil, err
+	}
+	if err := validateDomain(opts.Domain); err != nil {
+		return nil, err
+	}
+	
+	// Check if PAN already tokenized for this merchant
 	panHash := hashPAN(pan)
+	indexKey := opts.MerchantID + ":" + panHash
 	s.mu.RLock()
-	existingToken, exists := s.panHashIndex[panHash]
+	existingToken, exists := s.panHashIndex[indexKey]
 	s.mu.RUnlock()
 	
 	if exists {
@@ -92,23 +210,43 @@ func (s *Service) TokenizeCard(pan string, expiryMonth, expiryYear int, cvv stri
 		tokenData := s.tokens[existingToken]
 		s.mu.RUnlock()
 		
-		// Return existing token if still valid
-		if tokenData.IsActive && time.Now().Before(tokenData.ExpiresAt) {
+		// Return existing token if still valid and in the same domain
+		tokenData.mu.RLock()
+		reusable := tokenData.IsActive && tokenData.DeletedAt.IsZero() && time.Now().Before(tokenData.ExpiresAt) &&
+			tokenData.Domain.Equal(opts.Domain)
+		tokenData.mu.RUnlock()
+		if reusable {
 			return tokenData, nil
 		}
 	}
 	
-	// Encrypt PAN using HSM
+	// Refuse a full vault before asking the HSM to encrypt
+	if err := s.admit(); err != nil {
+		return nil, err
+	}
+	
+	// Encrypt PAN using HSM, directly or through a wrapped data key
 	plaintext := []byte(pan)
+	defer securebytes.Zero(plaintext)
 	aad := []byte(fmt.Sprintf("%d-%d", expiryMonth, expiryYear))
 	
-	ciphertext, nonce, keyVersion, err := s.hsmClient.Encrypt(s.keyID, plaintext, aad)
+	var (
+		ciphertext, nonce []byte
+		keyVersion        int
+		dataKeyID         string
+		err               error
+	)
+	if !s.usesDataKeys() {
+		ciphertext, nonce, keyVersion, err = s.sealPAN(plaintext, aad)
+	} else {
+		ciphertext, nonce, dataKeyID, err = s.encryptPAN(plaintext, aad, opts.MerchantID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrEncryptionFailed, err)
 	}
 	
 	// Generate format-preserving token
-	token, err := s.generateFormatPreservingToken(pan)
+	token, err := s.ownedToken(func() (string, error) { return s.generateFormatPreservingToken(pan) })
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +258,13 @@ func (s *Service) TokenizeCard(pan string, expiryMonth, expiryYear int, cvv stri
 	if _, exists := s.tokens[token]; exists {
 		return nil, ErrDuplicateToken
 	}
+	// Concurrent tokenizations may have filled the vault since
+	if err := s.admitLocked(); err != nil {
+		if s.keyScope == KeyScopeToken && dataKeyID != "" {
+			s.destroyDataKeyLocked(dataKeyID)
+		}
+		return nil, err
+	}
 	
 	// Create token data
 	now := time.Now()
@@ -133,32 +278,60 @@ func (s *Service) TokenizeCard(pan string, expiryMonth, expiryYear int, cvv stri
 		CardBrand:    detectCardBrand(pan),
 		ExpiryMonth:  expiryMonth,
 		ExpiryYear:   expiryYear,
+		MerchantID:   opts.MerchantID,
+		Metadata:     copyMetadata(opts.Metadata),
+		Domain:       copyDomain(opts.Domain),
+		PAR:          s.parForLocked(panHash, detectCardBrand(pan)),
+		DataKeyID:    dataKeyID,
 		CreatedAt:    now,
-		ExpiresAt:    now.Add(s.tokenTTL),
+		ExpiresAt:    now.Add(ttl),
 		IsActive:     true,
+		Revision:     1,
 	}
 	
 	// Store token
 	s.tokens[token] = tokenData
-	s.panHashIndex[panHash] = token
+	s.panHashIndex[indexKey] = token
+	s.trackLocked(nil, tokenData)
+	s.logToken(tokenData)
 	
 	return tokenData, nil
 }
 
-// DetokenizeCard retrieves the original PAN from a token
+// DetokenizeCard retrieves the original PAN from an unscoped token
 func (s *Service) DetokenizeCard(token string) (pan string, expiryMonth, expiryYear int, err error) {
+	return s.DetokenizeCardForMerchant(token, "")
+}
+
+// DetokenizeCardForMerchant retrieves the original PAN from a token issued to
+// the given merchant. Tokens of other merchants are reported as not found so
+// their existence is not revealed. The token's domain controls are not
+// checked; DetokenizeCardForUse checks them.
+func (s *Service) DetokenizeCardForMerchant(token, merchantID string) (pan string, expiryMonth, expiryYear int, err error) {
+	return s.detokenize(token, merchantID, nil)
+}
+
+// DetokenizeCardForUse retrieves the original PAN from a token issued to the
+// given merchant for a use its domain controls allow
+func (s *Service) DetokenizeCardForUse(token, merchantID string, use TokenUse) (pan string, expiryMonth, expiryYear int, err error) {
+	if use.MerchantID == "" {
+		use.MerchantID = merchantID
+	}
+	return s.detokenize(token, merchantID, &use)
+}
+
+// detokenize decrypts a token's PAN, checking use against its domain unless
+// use is nil
+func (s *Service) detokenize(token, merchantID string, use *TokenUse) (pan string, expiryMonth, expiryYear int, err error) {
 	// Validate token format
 	if err := validateTokenFormat(token); err != nil {
 		return "", 0, 0, err
 	}
 	
 	// Retrieve token data
-	s.mu.RLock()
-	tokenData, exists := s.tokens[token]
-	s.mu.RUnlock()
-	
-	if !exists {
-		return "", 0, 0, ErrTokenNotFound
+	tokenData, err := s.lookup(token, merchantID)
+	if err != nil {
+		return "", 0, 0, err
 	}
 	
 	tokenData.mu.RLock()
@@ -168,46 +341,62 @@ func (s *Service) DetokenizeCard(token string) (pan string, expiryMonth, expiryY
 	if !tokenData.IsActive {
 		return "", 0, 0, ErrTokenNotFound
 	}
+	if tokenData.Suspended {
+		return "", 0, 0, ErrTokenSuspended
+	}
 	
 	// Check if token is expired
 	if time.Now().After(tokenData.ExpiresAt) {
 		return "", 0, 0, ErrTokenExpired
 	}
 	
-	// Decrypt PAN using HSM
+	if use != nil {
+		if err := tokenData.Domain.Check(*use); err != nil {
+			return "", 0, 0, err
+		}
+	}
+	
+	// Decrypt PAN using HSM, directly or through its wrapped data key
 	aad := []byte(fmt.Sprintf("%d-%d", tokenData.ExpiryMonth, tokenData.ExpiryYear))
-	plaintext, err := s.hsmClient.Decrypt(
-		s.keyID,
-		tokenData.EncryptedPAN,
-		tokenData.Nonce,
-		aad,
-		tokenData.KeyVersion,
-	)
+	var plaintext []byte
+	if tokenData.DataKeyID == "" {
+		plaintext, err = s.openPAN(tokenData, aad)
+	} else {
+		plaintext, err = s.decryptPAN(tokenData, aad)
+	}
+	if errors.Is(err, ErrKeyShredded) {
+		return "", 0, 0, err
+	}
 	if err != nil {
 		return "", 0, 0, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
 	
-	return string(plaintext), tokenData.ExpiryMonth, tokenData.ExpiryYear, nil
+	// The string handed to the caller is the only copy left in memory
+	pan = string(plaintext)
+	securebytes.Zero(plaintext)
+	return pan, tokenData.ExpiryMonth, tokenData.ExpiryYear, nil
 }
 
-// ValidateToken checks if a token is valid
+// ValidateToken checks if an unscoped token is valid
 func (s *Service) ValidateToken(token string) (bool, error) {
+	return s.ValidateTokenForMerchant(token, "")
+}
+
+// ValidateTokenForMerchant checks if a token issued to the merchant is valid
+func (s *Service) ValidateTokenForMerchant(token, merchantID string) (bool, error) {
 	if err := validateTokenFormat(token); err != nil {
 		return false, err
 	}
 	
-	s.mu.RLock()
-	tokenData, exists := s.tokens[token]
-	s.mu.RUnlock()
-	
-	if !exists {
-		return false, ErrTokenNotFound
+	tokenData, err := s.lookup(token, merchantID)
+	if err != nil {
+		return false, err
 	}
 	
 	tokenData.mu.RLock()
 	defer tokenData.mu.RUnlock()
 	
-	if !tokenData.IsActive {
+	if !tokenData.IsActive || tokenData.Suspended {
 		return false, nil
 	}
 	
@@ -218,12 +407,129 @@ func (s *Service) ValidateToken(token string) (bool, error) {
 	return true, nil
 }
 
-// RevokeToken revokes a token
-func (s *Service) RevokeToken(token string) error {
+// TokenInfo returns the stored data of a token issued to the merchant, for
+// reading its metadata and expiry
+func (s *Service) TokenInfo(token, merchantID string) (*TokenData, error) {
+	if err := validateTokenFormat(token); err != nil {
+		return nil, err
+	}
+	return s.lookup(token, merchantID)
+}
+
+// TokenListLimits bound the page size of ListTokens
+var TokenListLimits = pagination.Limits{Default: 100, Max: 1000}
+
+// TokenFilter selects the tokens returned by ListTokens
+type TokenFilter struct {
+	MerchantID string
+	// ActiveOnly skips revoked and expired tokens
+	ActiveOnly bool
+	// Deleted lists the soft-deleted tokens, which are otherwise skipped
+	Deleted bool
+	// PAR lists only the tokens of the card with this payment account
+	// reference
+	PAR string
+}
+
+// TokenSummary describes a listed token without its card data
+type TokenSummary struct {
+	Token       string
+	LastFour    string
+	CardBrand   string
+	ExpiryMonth int
+	ExpiryYear  int
+	Metadata    map[string]string
+	Domain      *Domain
+	PAR         string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	RevokedAt   time.Time
+	DeletedAt   time.Time
+	Active      bool
+	Revision    int64
+}
+
+// TokenSortKey orders tokens by issue time, with the token breaking ties
+func TokenSortKey(t TokenSummary) pagination.Key {
+	return pagination.Key{pagination.Time(t.CreatedAt), t.Token}
+}
+
+// ListTokens returns up to limit of the merchant's tokens issued after the
+// after key, oldest first, and the key to continue from, which is nil once
+// the list is exhausted
+func (s *Service) ListTokens(f TokenFilter, after pagination.Key, limit int) ([]TokenSummary, pagination.Key) {
+	now := time.Now()
+	s.mu.RLock()
+	var matched []TokenSummary
+	for _, tokenData := range s.tokens {
+		if tokenData.MerchantID != f.MerchantID {
+			continue
+		}
+		tokenData.mu.RLock()
+		summary := TokenSummary{
+			Token:       tokenData.Token,
+			LastFour:    tokenData.LastFour,
+			CardBrand:   tokenData.CardBrand,
+			ExpiryMonth: tokenData.ExpiryMonth,
+			ExpiryYear:  tokenData.ExpiryYear,
+			Metadata:    copyMetadata(tokenData.Metadata),
+			Domain:      copyDomain(tokenData.Domain),
+			PAR:         tokenData.PAR,
+			CreatedAt:   tokenData.CreatedAt,
+			ExpiresAt:   tokenData.ExpiresAt,
+			RevokedAt:   tokenData.RevokedAt,
+			DeletedAt:   tokenData.DeletedAt,
+			Active:      tokenData.IsActive && !tokenData.Suspended && now.Before(tokenData.ExpiresAt),
+			Revision:    tokenData.Revision,
+		}
+		tokenData.mu.RUnlock()
+		if summary.DeletedAt.IsZero() == f.Deleted || f.ActiveOnly && !summary.Active || f.PAR != "" && summary.PAR != f.PAR {
+			continue
+		}
+		matched = append(matched, summary)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return TokenSortKey(matched[i]).Compare(TokenSortKey(matched[j])) < 0
+	})
+	return pagination.Page(matched, after, limit, pagination.Ascending, TokenSortKey)
+}
+
+// lookup finds a token within a merchant scope. Soft-deleted tokens are not
+// found.
+func (s *Service) lookup(token, merchantID string) (*TokenData, error) {
+	tokenData, err := s.lookupAny(token, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	tokenData.mu.RLock()
+	deleted := !tokenData.DeletedAt.IsZero()
+	tokenData.mu.RUnlock()
+	if deleted {
+		return nil, ErrTokenNotFound
+	}
+	return tokenData, nil
+}
+
+// lookupAny finds a token within a merchant scope, deleted or not
+func (s *Service) lookupAny(token, merchantID string) (*TokenData, error) {
 	s.mu.RLock()
 	tokenData, exists := s.tokens[token]
 	s.mu.RUnlock()
 	
+	if !exists || tokenData.MerchantID != merchantID {
+		return nil, ErrTokenNotFound
+	}
+	return tokenData, nil
+}
+
+// RevokeToken revokes a token regardless of its merchant scope
+func (s *Service) RevokeToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	tokenData, exists := s.tokens[token]
 	if !exists {
 		return ErrTokenNotFound
 	}
@@ -231,10 +537,251 @@ func (s *Service) RevokeToken(token string) error {
 	tokenData.mu.Lock()
 	defer tokenData.mu.Unlock()
 	
-	tokenData.IsActive = false
+	if !tokenData.DeletedAt.IsZero() {
+		return ErrTokenNotFound
+	}
+	if tokenData.IsActive {
+		tokenData.IsActive = false
+		tokenData.RevokedAt = time.Now()
+		s.updated(tokenData)
+	}
+	return nil
+}
+
+// RevokeTokenForMerchant revokes a token issued to the merchant, if it is
+// still at ifRevision (AnyRevision skips the check), and returns its new
+// revision
+func (s *Service) RevokeTokenForMerchant(token, merchantID string, ifRevision int64) (int64, error) {
+	if err := validateTokenFormat(token); err != nil {
+		return 0, err
+	}
+	
+	tokenData, err := s.lookup(token, merchantID)
+	if err != nil {
+		return 0, err
+	}
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenData.mu.Lock()
+	defer tokenData.mu.Unlock()
+	
+	if err := checkRevision(tokenData, ifRevision); err != nil {
+		return 0, err
+	}
+	if tokenData.IsActive {
+		tokenData.IsActive = false
+		tokenData.RevokedAt = time.Now()
+		s.updated(tokenData)
+	}
+	return tokenData.Revision, nil
+}
+
+// DeleteToken soft-deletes a token issued to the merchant, if it is still at
+// ifRevision, and returns its new revision. The token and its encrypted PAN
+// are kept, but every operation treats it as not found until it is restored
+// or purged.
+func (s *Service) DeleteToken(token, merchantID string, ifRevision int64) (int64, error) {
+	if err := validateTokenFormat(token); err != nil {
+		return 0, err
+	}
+
+	tokenData, err := s.lookup(token, merchantID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenData.mu.Lock()
+	defer tokenData.mu.Unlock()
+
+	if !tokenData.DeletedAt.IsZero() {
+		return 0, ErrTokenNotFound
+	}
+	if err := checkRevision(tokenData, ifRevision); err != nil {
+		return 0, err
+	}
+	tokenData.DeletedAt = time.Now()
+	s.updated(tokenData)
+	return tokenData.Revision, nil
+}
+
+// RestoreToken undoes DeleteToken for a token that has not been purged yet,
+// if it is still at ifRevision, and returns its new revision. The token
+// comes back as it was, revoked or expired if it was so before.
+func (s *Service) RestoreToken(token, merchantID string, ifRevision int64) (int64, error) {
+	if err := validateTokenFormat(token); err != nil {
+		return 0, err
+	}
+
+	tokenData, err := s.lookupAny(token, merchantID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenData.mu.Lock()
+	defer tokenData.mu.Unlock()
+
+	if tokenData.DeletedAt.IsZero() {
+		return 0, ErrTokenNotDeleted
+	}
+	if err := checkRevision(tokenData, ifRevision); err != nil {
+		return 0, err
+	}
+	tokenData.DeletedAt = time.Time{}
+	s.updated(tokenData)
+	return tokenData.Revision, nil
+}
+
+// UpdateMetadata replaces the metadata of a token issued to the merchant, if
+// it is still at ifRevision, and returns its new revision. Metadata that
+// would take the vault past its byte limit is refused with ErrVaultFull.
+func (s *Service) UpdateMetadata(token, merchantID string, metadata map[string]string, ifRevision int64) (int64, error) {
+	if err := validateTokenFormat(token); err != nil {
+		return 0, err
+	}
+	if err := validateMetadata(metadata); err != nil {
+		return 0, err
+	}
+
+	tokenData, err := s.lookup(token, merchantID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenData.mu.Lock()
+	defer tokenData.mu.Unlock()
+
+	if err := checkRevision(tokenData, ifRevision); err != nil {
+		return 0, err
+	}
+	previous := tokenData.Metadata
+	tokenData.Metadata = copyMetadata(metadata)
+	if err := s.admitGrowthLocked(tokenData.entryBytes() - tokenData.accountedBytes); err != nil {
+		tokenData.Metadata = previous
+		return 0, err
+	}
+	s.updated(tokenData)
+	return tokenData.Revision, nil
+}
+
+// AnyRevision makes a token update unconditional
+const AnyRevision int64 = 0
+
+// checkRevision refuses an update made against a revision the token has
+// moved on from. tokenData.mu must be held.
+func checkRevision(tokenData *TokenData, ifRevision int64) error {
+	if ifRevision != AnyRevision && ifRevision != tokenData.Revision {
+		return fmt.Errorf("%w: token is at revision %d, not %d", ErrRevisionMismatch, tokenData.Revision, ifRevision)
+	}
 	return nil
 }
 
+// updated records a change to a token: it moves the token to its next
+// revision, accounts for its new size and logs it. s.mu and tokenData.mu
+// must be held.
+func (s *Service) updated(tokenData *TokenData) {
+	tokenData.Revision++
+	// A token purged since it was looked up is no longer accounted for
+	if s.tokens[tokenData.Token] == tokenData {
+		s.trackLocked(tokenData, tokenData)
+	}
+	s.logToken(tokenData)
+}
+
+// PurgeDeletedTokens permanently deletes tokens soft-deleted before the
+// cutoff, along with their encrypted PANs, and returns the deleted tokens
+func (s *Service) PurgeDeletedTokens(cutoff time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []string
+	for token, tokenData := range s.tokens {
+		tokenData.mu.RLock()
+		deletedAt := tokenData.DeletedAt
+		tokenData.mu.RUnlock()
+
+		if !deletedAt.IsZero() && deletedAt.Before(cutoff) {
+			s.removeLocked(tokenData)
+			purged = append(purged, token)
+		}
+	}
+	return purged
+}
+
+// PurgeTokens deletes tokens that were revoked or expired before the cutoff,
+// along with their encrypted PANs, and returns the deleted tokens
+func (s *Service) PurgeTokens(cutoff time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	var purged []string
+	for token, tokenData := range s.tokens {
+		tokenData.mu.RLock()
+		expired := tokenData.ExpiresAt.Before(cutoff)
+		revoked := !tokenData.IsActive && tokenData.RevokedAt.Before(cutoff)
+		tokenData.mu.RUnlock()
+		
+		if expired || revoked {
+			s.removeLocked(tokenData)
+			purged = append(purged, token)
+		}
+	}
+	return purged
+}
+
+// ForgetCard deletes every token of the card with the given fingerprint,
+// vault and network tokens across all merchants, and returns the deleted
+// tokens
+func (s *Service) ForgetCard(fingerprint string) ([]string, error) {
+	if !fingerprintPattern.MatchString(fingerprint) {
+		return nil, ErrInvalidFingerprint
+	}
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	var forgotten []string
+	for token, tokenData := range s.tokens {
+		if securebytes.EqualString(tokenData.PANHash, fingerprint) {
+			s.removeLocked(tokenData)
+			forgotten = append(forgotten, token)
+		}
+	}
+	forgotten = append(forgotten, s.forgetNetworkTokensLocked(fingerprint)...)
+	delete(s.parIndex, fingerprint)
+	return forgotten, nil
+}
+
+// removeLocked deletes a token, its dedup index entry and, for per-token
+// data keys, its key. s.mu must be held.
+func (s *Service) removeLocked(tokenData *TokenData) {
+	delete(s.tokens, tokenData.Token)
+	s.trackLocked(tokenData, nil)
+	s.logRemoval(walDeleteToken, tokenData.Token)
+	if s.keyScope == KeyScopeToken {
+		s.destroyDataKeyLocked(tokenData.DataKeyID)
+	}
+	indexKey := tokenData.MerchantID + ":" + tokenData.PANHash
+	if s.panHashIndex[indexKey] == tokenData.Token {
+		delete(s.panHashIndex, indexKey)
+	}
+}
+
+// Fingerprint returns the stable identifier of a card used to find all data
+// derived from its PAN, without storing the PAN itself
+func Fingerprint(pan string) (string, error) {
+	if err := validatePAN(pan); err != nil {
+		return "", err
+	}
+	return hashPAN(pan), nil
+}
+
 // generateFormatPreservingToken generates a token that looks like a PAN
 func (s *Service) generateFormatPreservingToken(pan string) (string, error) {
 	// Keep first 6 digits (BIN) and last 4 digits for format preservation
@@ -265,52 +812,46 @@ func (s *Service) generateFormatPreservingToken(pan string) (string, error) {
 	return token.String(), nil
 }
 
-// validatePAN validates PAN format and Luhn checksum
+// validatePAN validates PAN format and Luhn checksum. Spaces and dashes
+// between the digits are ignored. It runs on every tokenization, so it
+// checks digit by digit without allocating.
 func validatePAN(pan string) error {
-	// Remove spaces and dashes
-	pan = strings.ReplaceAll(pan, " ", "")
-	pan = strings.ReplaceAll(pan, "-", "")
-	
-	// Check if numeric
-	if !regexp.MustCompile(`^\d+$`).MatchString(pan) {
-		return ErrInvalidPAN
-	}
-	
-	// Check length (13-19 digits)
-	if len(pan) < 13 || len(pan) > 19 {
-		return ErrInvalidPAN
-	}
-	
-	// Validate Luhn checksum
-	if !luhnCheck(pan) {
+	digits, sum, ok := luhn(pan, true)
+	if !ok || digits < 13 || digits > 19 || sum%10 != 0 {
 		return ErrInvalidPAN
 	}
-	
 	return nil
 }
 
 // luhnCheck validates a number using the Luhn algorithm
 func luhnCheck(number string) bool {
-	var sum int
-	parity := len(number) % 2
-	
-	for i, digit := range number {
-		d, err := strconv.Atoi(string(digit))
-		if err != nil {
-			return false
+	_, sum, ok := luhn(number, false)
+	return ok && sum%10 == 0
+}
+
+// luhn returns the number of digits in s and their Luhn sum, doubling every
+// second digit from the right. With separators, spaces and dashes are
+// skipped; any other character that is not a digit makes ok false.
+func luhn(s string, separators bool) (digits, sum int, ok bool) {
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if separators && (c == ' ' || c == '-') {
+			continue
 		}
-		
-		if i%2 == parity {
+		if c < '0' || c > '9' {
+			return 0, 0, false
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
 			d *= 2
 			if d > 9 {
 				d -= 9
 			}
 		}
-		
 		sum += d
+		digits++
 	}
-	
-	return sum%10 == 0
+	return digits, sum, true
 }
 
 // validateExpiry validates expiry date
@@ -335,28 +876,61 @@ func validateExpiry(month, year int) error {
 	return nil
 }
 
-// validateTokenFormat validates token format
+// validateTokenFormat checks that a token is 13-19 digits starting with 9,
+// our convention. Every token operation runs it, so it allocates nothing.
 func validateTokenFormat(token string) error {
-	if token == "" {
+	if len(token) < 13 || len(token) > 19 || token[0] != '9' || !isDigits(token) {
 		return ErrInvalidToken
 	}
-	
-	// Token should be numeric and 13-19 digits
-	if !regexp.MustCompile(`^\d{13,19}$`).MatchString(token) {
-		return ErrInvalidToken
+	return nil
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
 	}
-	
-	// Token should start with 9 (our convention)
-	if !strings.HasPrefix(token, "9") {
-		return ErrInvalidToken
+	return true
+}
+
+// validateMetadata bounds token metadata and rejects values that look like a
+// PAN, since metadata is stored and returned in the clear
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("%w: at most %d entries", ErrInvalidMetadata, MaxMetadataEntries)
+	}
+	for k, v := range metadata {
+		if k == "" || len(k) > MaxMetadataKeyLen {
+			return fmt.Errorf("%w: key %q must be 1-%d characters", ErrInvalidMetadata, k, MaxMetadataKeyLen)
+		}
+		if len(v) > MaxMetadataValueLen {
+			return fmt.Errorf("%w: value of %q exceeds %d characters", ErrInvalidMetadata, k, MaxMetadataValueLen)
+		}
+		if redact.LooksLikePAN(strings.ReplaceAll(v, " ", "")) {
+			return fmt.Errorf("%w: value of %q looks like a card number", ErrInvalidMetadata, k)
+		}
 	}
-	
 	return nil
 }
 
+func copyMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	return out
+}
+
 // hashPAN creates a SHA-256 hash of the PAN for indexing
 func hashPAN(pan string) string {
-	hash := sha256.Sum256([]byte(pan))
+	b := []byte(pan)
+	defer securebytes.Zero(b)
+	hash := sha256.Sum256(b)
 	return hex.EncodeToString(hash[:])
 }
 
@@ -162,14 +162,14 @@ func TestProperty_EncryptionAlgorithm(t *testing.T) {
 				encryptFunc: func(keyID string, plaintext, aad []byte) (ciphertext, nonce []byte, keyVersion int, err error) {
 					// Simulate encryption by storing plaintext
 					key := string(plaintext) + string(aad)
-					encryptedData[key] = plaintext
+					encryptedData[key] = append([]byte(nil), plaintext...)
 					return []byte("encrypted_" + string(plaintext)), []byte("nonce"), 1, nil
 				},
 				decryptFunc: func(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
 					// Simulate decryption by retrieving stored plaintext
 					// In real AES-256-GCM, we'd verify the ciphertext
 					// For this test, we verify the round-trip works
-					return encryptedData[string(ciphertext[10:])+string(aad)], nil
+					return append([]byte(nil), encryptedData[string(ciphertext[10:])+string(aad)]...), nil
 				},
 			}
 			
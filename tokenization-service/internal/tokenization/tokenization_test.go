@@ -1,8 +1,13 @@
 package tokenization
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/paymentgateway/go-common/pagination"
+	"github.com/paymentgateway/go-common/securebytes/securetest"
 )
 
 // MockHSMClient for testing
@@ -15,16 +20,17 @@ func (m *MockHSMClient) Encrypt(keyID string, plaintext, aad []byte) (ciphertext
 	if m.encryptFunc != nil {
 		return m.encryptFunc(keyID, plaintext, aad)
 	}
-	// Default mock: return plaintext as ciphertext with dummy nonce
-	return plaintext, []byte("nonce123"), 1, nil
+	// Default mock: return a copy of plaintext as ciphertext with dummy
+	// nonce, since the service zeroizes its plaintext after encryption
+	return append([]byte(nil), plaintext...), []byte("nonce123"), 1, nil
 }
 
 func (m *MockHSMClient) Decrypt(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
 	if m.decryptFunc != nil {
 		return m.decryptFunc(keyID, ciphertext, nonce, aad, keyVersion)
 	}
-	// Default mock: return ciphertext as plaintext
-	return ciphertext, nil
+	// Default mock: return a copy of ciphertext as plaintext
+	return append([]byte(nil), ciphertext...), nil
 }
 
 func TestValidatePAN(t *testing.T) {
@@ -40,6 +46,9 @@ func TestValidatePAN(t *testing.T) {
 		{"Too Long", "12345678901234567890", true},
 		{"Non-numeric", "453201511283abcd", true},
 		{"Empty", "", true},
+		{"Spaces and dashes", "4532 0151-1283 0366", false},
+		{"Separators only", "- - - - - - - - -", true},
+		{"Non-ASCII digit", "453201511283036٦", true},
 	}
 	
 	for _, tt := range tests {
@@ -62,6 +71,8 @@ func TestLuhnCheck(t *testing.T) {
 		{"Valid Mastercard", "5425233430109903", true},
 		{"Invalid", "4532015112830367", false},
 		{"Valid Amex", "378282246310005", true},
+		{"Odd length", "79927398713", true},
+		{"Separator", "4532-0151-1283-0366", false},
 	}
 	
 	for _, tt := range tests {
@@ -73,6 +84,68 @@ func TestLuhnCheck(t *testing.T) {
 	}
 }
 
+func TestValidateTokenFormat(t *testing.T) {
+	valid := []string{"9123456789010366", "9123456789012", "9123456789012345678"}
+	invalid := []string{"", "912345678901", "91234567890123456789", "4123456789010366", "912345678901036a", "9123 4567 8901 0366"}
+	for _, token := range valid {
+		if err := validateTokenFormat(token); err != nil {
+			t.Errorf("validateTokenFormat(%q) error = %v", token, err)
+		}
+	}
+	for _, token := range invalid {
+		if err := validateTokenFormat(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("validateTokenFormat(%q) error = %v, want %v", token, err, ErrInvalidToken)
+		}
+	}
+}
+
+// Validation runs on every request, so it must not allocate
+func TestValidationAllocations(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	tokenData, err := service.TokenizeCard("4532015112830366", 12, time.Now().Year()+2, "123")
+	if err != nil {
+		t.Fatalf("TokenizeCard() error = %v", err)
+	}
+	checks := map[string]func(){
+		"validatePAN":         func() { validatePAN("4532 0151 1283 0366") },
+		"luhnCheck":           func() { luhnCheck("4532015112830366") },
+		"validateTokenFormat": func() { validateTokenFormat(tokenData.Token) },
+		"ValidateToken":       func() { service.ValidateToken(tokenData.Token) },
+	}
+	for name, check := range checks {
+		if allocs := testing.AllocsPerRun(100, check); allocs != 0 {
+			t.Errorf("%s allocates %v times per call", name, allocs)
+		}
+	}
+}
+
+func BenchmarkValidatePAN(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validatePAN("4532015112830366")
+	}
+}
+
+func BenchmarkLuhnCheck(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		luhnCheck("4532015112830366")
+	}
+}
+
+func BenchmarkValidateToken(b *testing.B) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	tokenData, err := service.TokenizeCard("4532015112830366", 12, time.Now().Year()+2, "123")
+	if err != nil {
+		b.Fatalf("TokenizeCard() error = %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		service.ValidateToken(tokenData.Token)
+	}
+}
+
 func TestValidateExpiry(t *testing.T) {
 	now := time.Now()
 	currentYear := now.Year()
@@ -284,3 +357,320 @@ func TestRevokeToken(t *testing.T) {
 		t.Errorf("DetokenizeCard() error = %v, want %v", err, ErrTokenNotFound)
 	}
 }
+
+func TestMerchantScoping(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	pan := "4532015112830366"
+	year := time.Now().Year() + 2
+	
+	a, err := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "merchant-a"})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	b, err := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "merchant-b"})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	if a.Token == b.Token {
+		t.Error("same PAN should get distinct tokens for different merchants")
+	}
+	
+	if got, _, _, err := service.DetokenizeCardForMerchant(a.Token, "merchant-a"); err != nil || got != pan {
+		t.Errorf("DetokenizeCardForMerchant() = %v, %v; want %v", got, err, pan)
+	}
+	if _, _, _, err := service.DetokenizeCardForMerchant(a.Token, "merchant-b"); err != ErrTokenNotFound {
+		t.Errorf("other merchant: error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, _, _, err := service.DetokenizeCard(a.Token); err != ErrTokenNotFound {
+		t.Errorf("unscoped caller: error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if valid, err := service.ValidateTokenForMerchant(b.Token, "merchant-a"); valid || err != ErrTokenNotFound {
+		t.Errorf("ValidateTokenForMerchant() = %v, %v; want false, %v", valid, err, ErrTokenNotFound)
+	}
+}
+
+func TestTokenizeOptions(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	
+	tokenData, err := service.TokenizeCardWithOptions("5425233430109903", 6, year, "123", TokenizeOptions{
+		MerchantID: "m1",
+		Metadata:   map[string]string{"customer": "c-42"},
+		TTL:        time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	if ttl := tokenData.ExpiresAt.Sub(tokenData.CreatedAt); ttl != time.Hour {
+		t.Errorf("TTL = %v, want 1h", ttl)
+	}
+	info, err := service.TokenInfo(tokenData.Token, "m1")
+	if err != nil || info.Metadata["customer"] != "c-42" {
+		t.Errorf("TokenInfo() = %+v, %v; want metadata customer=c-42", info, err)
+	}
+	
+	tests := []struct {
+		name    string
+		opts    TokenizeOptions
+		wantErr error
+	}{
+		{"TTL above default", TokenizeOptions{TTL: 48 * time.Hour}, ErrInvalidTTL},
+		{"negative TTL", TokenizeOptions{TTL: -time.Minute}, ErrInvalidTTL},
+		{"PAN in metadata", TokenizeOptions{Metadata: map[string]string{"note": "4111 1111 1111 1111"}}, ErrInvalidMetadata},
+		{"empty metadata key", TokenizeOptions{Metadata: map[string]string{"": "x"}}, ErrInvalidMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPurgeTokens(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	
+	active, _ := service.TokenizeCard("4532015112830366", 12, year, "123")
+	revoked, _ := service.TokenizeCard("5425233430109903", 12, year, "123")
+	expired, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1", TTL: time.Minute})
+	if err := service.RevokeToken(revoked.Token); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	
+	if purged := service.PurgeTokens(time.Now().Add(-time.Hour)); len(purged) != 0 {
+		t.Errorf("PurgeTokens() before retention = %v, want none", purged)
+	}
+	
+	purged := service.PurgeTokens(time.Now().Add(time.Hour))
+	if len(purged) != 2 {
+		t.Fatalf("PurgeTokens() = %v, want the revoked and expired tokens", purged)
+	}
+	for _, token := range []string{revoked.Token, expired.Token} {
+		if err := service.RevokeToken(token); err != ErrTokenNotFound {
+			t.Errorf("token %s still stored after purge: error = %v", token, err)
+		}
+	}
+	if valid, err := service.ValidateToken(active.Token); !valid || err != nil {
+		t.Errorf("active token: ValidateToken() = %v, %v; want true", valid, err)
+	}
+	
+	// The purged merchant token no longer blocks re-tokenization
+	again, err := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	if err != nil || again.Token == expired.Token {
+		t.Errorf("re-tokenize after purge = %v, %v; want a new token", again, err)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	opts := TokenizeOptions{MerchantID: "m1"}
+
+	tokenData, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", opts)
+	if _, err := service.DeleteToken(tokenData.Token, "m2", AnyRevision); err != ErrTokenNotFound {
+		t.Errorf("DeleteToken() by another merchant error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, err := service.DeleteToken(tokenData.Token, "m1", AnyRevision); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+
+	// A deleted token is hidden from every normal operation
+	if _, _, _, err := service.DetokenizeCardForMerchant(tokenData.Token, "m1"); err != ErrTokenNotFound {
+		t.Errorf("DetokenizeCardForMerchant() of deleted token error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, err := service.DeleteToken(tokenData.Token, "m1", AnyRevision); err != ErrTokenNotFound {
+		t.Errorf("second DeleteToken() error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if listed, _ := service.ListTokens(TokenFilter{MerchantID: "m1"}, nil, 10); len(listed) != 0 {
+		t.Errorf("ListTokens() = %+v, want no tokens", listed)
+	}
+	deleted, _ := service.ListTokens(TokenFilter{MerchantID: "m1", Deleted: true}, nil, 10)
+	if len(deleted) != 1 || deleted[0].DeletedAt.IsZero() {
+		t.Errorf("ListTokens(Deleted) = %+v, want the deleted token", deleted)
+	}
+
+	if _, err := service.RestoreToken(tokenData.Token, "m1", AnyRevision); err != nil {
+		t.Fatalf("RestoreToken() error = %v", err)
+	}
+	if pan, _, _, err := service.DetokenizeCardForMerchant(tokenData.Token, "m1"); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCardForMerchant() after restore = %q, %v", pan, err)
+	}
+	if _, err := service.RestoreToken(tokenData.Token, "m1", AnyRevision); err != ErrTokenNotDeleted {
+		t.Errorf("RestoreToken() of live token error = %v, want %v", err, ErrTokenNotDeleted)
+	}
+
+	// Once past the restore window the purge removes it for good
+	service.DeleteToken(tokenData.Token, "m1", AnyRevision)
+	if purged := service.PurgeDeletedTokens(time.Now().Add(-time.Hour)); len(purged) != 0 {
+		t.Errorf("PurgeDeletedTokens() within window = %v, want none", purged)
+	}
+	if purged := service.PurgeDeletedTokens(time.Now().Add(time.Hour)); len(purged) != 1 || purged[0] != tokenData.Token {
+		t.Errorf("PurgeDeletedTokens() = %v, want the deleted token", purged)
+	}
+	if _, err := service.RestoreToken(tokenData.Token, "m1", AnyRevision); err != ErrTokenNotFound {
+		t.Errorf("RestoreToken() after purge error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
+
+func TestTokenRevisions(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	tokenData, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	if tokenData.Revision != 1 {
+		t.Fatalf("new token Revision = %d, want 1", tokenData.Revision)
+	}
+
+	// Two writers read revision 1; the second one's update is refused
+	rev, err := service.UpdateMetadata(tokenData.Token, "m1", map[string]string{"order": "42"}, 1)
+	if err != nil || rev != 2 {
+		t.Fatalf("UpdateMetadata() = %d, %v, want revision 2", rev, err)
+	}
+	if _, err := service.RevokeTokenForMerchant(tokenData.Token, "m1", 1); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("RevokeTokenForMerchant() at a stale revision error = %v, want %v", err, ErrRevisionMismatch)
+	}
+	if valid, _ := service.ValidateTokenForMerchant(tokenData.Token, "m1"); !valid {
+		t.Error("a refused revocation revoked the token")
+	}
+	if rev, err = service.RevokeTokenForMerchant(tokenData.Token, "m1", 2); err != nil || rev != 3 {
+		t.Errorf("RevokeTokenForMerchant() at the current revision = %d, %v, want revision 3", rev, err)
+	}
+
+	// Deletes, restores and card events move the revision on too
+	if _, err := service.DeleteToken(tokenData.Token, "m1", 2); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("DeleteToken() at a stale revision error = %v, want %v", err, ErrRevisionMismatch)
+	}
+	rev, _ = service.DeleteToken(tokenData.Token, "m1", AnyRevision)
+	if rev, err = service.RestoreToken(tokenData.Token, "m1", rev); err != nil || rev != 5 {
+		t.Errorf("RestoreToken() = %d, %v, want revision 5", rev, err)
+	}
+	listed, _ := service.ListTokens(TokenFilter{MerchantID: "m1"}, nil, 10)
+	if len(listed) != 1 || listed[0].Revision != 5 || listed[0].Metadata["order"] != "42" {
+		t.Errorf("ListTokens() = %+v, want revision 5 with the updated metadata", listed)
+	}
+	if _, err := service.UpdateMetadata(tokenData.Token, "m1", map[string]string{"card": "4532015112830366"}, AnyRevision); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("UpdateMetadata() with a PAN error = %v, want %v", err, ErrInvalidMetadata)
+	}
+}
+
+func TestListTokens(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+
+	issued := map[string]bool{}
+	for _, pan := range []string{"4532015112830366", "5425233430109903", "4111111111111111", "378282246310005", "6011111111111117"} {
+		tokenData, err := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+		if err != nil {
+			t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+		}
+		issued[tokenData.Token] = true
+	}
+	service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+
+	var listed []TokenSummary
+	var after pagination.Key
+	for pages := 0; pages < 10; pages++ {
+		var page []TokenSummary
+		page, after = service.ListTokens(TokenFilter{MerchantID: "m1"}, after, 2)
+		if len(page) > 2 {
+			t.Fatalf("page of %d tokens, want at most 2", len(page))
+		}
+		listed = append(listed, page...)
+		if after == nil {
+			break
+		}
+	}
+	if len(listed) != len(issued) {
+		t.Fatalf("listed %d tokens, want %d", len(listed), len(issued))
+	}
+	for i, summary := range listed {
+		if !issued[summary.Token] || !summary.Active {
+			t.Errorf("unexpected token %+v", summary)
+		}
+		if i > 0 && TokenSortKey(listed[i-1]).Compare(TokenSortKey(summary)) >= 0 {
+			t.Errorf("tokens %d and %d out of order", i-1, i)
+		}
+	}
+
+	if err := service.RevokeToken(listed[0].Token); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	active, next := service.ListTokens(TokenFilter{MerchantID: "m1", ActiveOnly: true}, nil, 10)
+	if len(active) != 4 || next != nil || active[0].Token == listed[0].Token {
+		t.Errorf("active tokens = %+v, next %v; want the 4 unrevoked tokens", active, next)
+	}
+}
+
+func TestForgetCard(t *testing.T) {
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	pan := "4532015112830366"
+	
+	a, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "merchant-a"})
+	b, _ := service.TokenizeCardWithOptions(pan, 12, year, "123", TokenizeOptions{MerchantID: "merchant-b"})
+	other, _ := service.TokenizeCard("5425233430109903", 12, year, "123")
+	
+	fingerprint, err := Fingerprint(pan)
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	forgotten, err := service.ForgetCard(fingerprint)
+	if err != nil || len(forgotten) != 2 {
+		t.Fatalf("ForgetCard() = %v, %v; want 2 tokens", forgotten, err)
+	}
+	if _, _, _, err := service.DetokenizeCardForMerchant(a.Token, "merchant-a"); err != ErrTokenNotFound {
+		t.Errorf("merchant-a token: error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, _, _, err := service.DetokenizeCardForMerchant(b.Token, "merchant-b"); err != ErrTokenNotFound {
+		t.Errorf("merchant-b token: error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if _, _, _, err := service.DetokenizeCard(other.Token); err != nil {
+		t.Errorf("other card: error = %v, want nil", err)
+	}
+	
+	if _, err := service.ForgetCard("not-a-fingerprint"); err != ErrInvalidFingerprint {
+		t.Errorf("ForgetCard(invalid) error = %v, want %v", err, ErrInvalidFingerprint)
+	}
+	if _, err := Fingerprint("1234"); err != ErrInvalidPAN {
+		t.Errorf("Fingerprint(invalid) error = %v, want %v", err, ErrInvalidPAN)
+	}
+}
+
+func TestPlaintextZeroized(t *testing.T) {
+	var encrypted, decrypted []byte
+	mockHSM := &MockHSMClient{
+		encryptFunc: func(keyID string, plaintext, aad []byte) ([]byte, []byte, int, error) {
+			encrypted = plaintext
+			return append([]byte(nil), plaintext...), []byte("nonce123"), 1, nil
+		},
+		decryptFunc: func(keyID string, ciphertext, nonce, aad []byte, keyVersion int) ([]byte, error) {
+			decrypted = append([]byte(nil), ciphertext...)
+			return decrypted, nil
+		},
+	}
+	service := NewService(mockHSM, "test-key", 24*time.Hour)
+	year := time.Now().Year() + 2
+	
+	tokenData, err := service.TokenizeCard("4532015112830366", 12, year, "123")
+	if err != nil {
+		t.Fatalf("TokenizeCard() error = %v", err)
+	}
+	if !bytes.Equal(encrypted, make([]byte, 16)) {
+		t.Errorf("PAN buffer not zeroized after encryption: %q", encrypted)
+	}
+	
+	pan, _, _, err := service.DetokenizeCard(tokenData.Token)
+	if err != nil || pan != "4532015112830366" {
+		t.Fatalf("DetokenizeCard() = %v, %v", pan, err)
+	}
+	if !bytes.Equal(decrypted, make([]byte, 16)) {
+		t.Errorf("decrypted PAN buffer not zeroized: %q", decrypted)
+	}
+}
+
+// Secrets such as PAN hashes and MACs must only be compared in constant time
+func TestConstantTimeComparisons(t *testing.T) {
+	securetest.AssertConstantTime(t, "../..")
+}
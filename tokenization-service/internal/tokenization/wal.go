@@ -0,0 +1,482 @@
+package tokenization
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// The write-ahead log lets the in-memory vault survive a restart or crash.
+// Every mutation appends the new state of the entry it changed, or its
+// removal, and replaying the log rebuilds the vault. Records hold what the
+// vault holds: encrypted PANs, HSM-wrapped data keys, PAN hashes and last four
+// digits, never a PAN or a plaintext key.
+
+// ErrWALCorrupt is returned when a record other than the last cannot be read
+var ErrWALCorrupt = errors.New("write-ahead log is corrupt")
+
+// WALConfig configures a write-ahead log
+type WALConfig struct {
+	// Sync writes and syncs every record before the mutation returns.
+	// Otherwise records are buffered until FlushWAL, and a crash loses the
+	// mutations since the last flush.
+	Sync bool
+}
+
+type walOp string
+
+const (
+	walPutToken        walOp = "put-token"
+	walDeleteToken     walOp = "delete-token"
+	walPutDataKey      walOp = "put-data-key"
+	walDeleteDataKey   walOp = "delete-data-key"
+	walPutNetworkToken walOp = "put-network-token"
+	walDeleteNetwork   walOp = "delete-network-token"
+	walPutLink         walOp = "put-link"
+)
+
+// walRecord is one line of the log: an entry's new state, or the ID of a
+// removed entry
+type walRecord struct {
+	Op           walOp         `json:"op"`
+	ID           string        `json:"id,omitempty"`
+	Token        *TokenData    `json:"token,omitempty"`
+	DataKey      *dataKey      `json:"data_key,omitempty"`
+	NetworkToken *NetworkToken `json:"network_token,omitempty"`
+	Link         *TokenLink    `json:"link,omitempty"`
+}
+
+// WAL is an append-only log of vault mutations in a JSON-lines file
+type WAL struct {
+	path     string
+	config   WALConfig
+	mu       sync.Mutex
+	file     *os.File
+	buf      *bufio.Writer
+	records  int   // records in the file
+	appended int   // records appended since opening or the last compaction
+	err      error // first write error; later records are dropped
+}
+
+// OpenWAL opens or creates the log at path. Recover the service from it
+// before serving.
+func OpenWAL(path string, cfg WALConfig) (*WAL, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	return &WAL{path: path, config: cfg, file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+// WithWAL logs every vault mutation to w
+func WithWAL(w *WAL) Option {
+	return func(s *Service) { s.wal, s.store = w, w }
+}
+
+// Records returns the number of records in the log
+func (w *WAL) Records() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.records
+}
+
+// Appended returns the number of records appended since the log was opened
+// or last compacted
+func (w *WAL) Appended() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.appended
+}
+
+// Close flushes and closes the log
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.flushLocked()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
+
+// Append writes an encoded record to the buffer, or through to disk in sync
+// mode. The caller holds the lock of the entry the record describes, so
+// records of one entry are logged in the order its changes were made. A
+// failed write would leave a partial record, so it stops the log; the error
+// is returned by the next flush.
+func (w *WAL) Append(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil || w.file == nil {
+		return
+	}
+	_, err := w.buf.Write(append(line, '\n'))
+	if err == nil && w.config.Sync {
+		err = w.flushLocked()
+	}
+	if err != nil {
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return
+	}
+	w.records++
+	w.appended++
+}
+
+// Flush writes and syncs buffered records
+func (w *WAL) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flushLocked()
+}
+
+func (w *WAL) flushLocked() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return w.err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.err = fmt.Errorf("write-ahead log: %w", err)
+		return w.err
+	}
+	return nil
+}
+
+// Replay reads the log from the start and applies every record. A final
+// record cut short by a crash is dropped and truncated away; any other
+// unreadable record, or one apply refuses, fails the replay. Buffered
+// records are flushed first, so the log can be read while in use.
+func (w *WAL) Replay(apply func(line []byte) error) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.flushLocked(); err != nil {
+		return 0, err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("read write-ahead log: %w", err)
+	}
+	reader := bufio.NewReader(w.file)
+	var (
+		offset int64
+		n      int
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				if err := w.file.Truncate(offset); err != nil {
+					return n, fmt.Errorf("truncate write-ahead log: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("read write-ahead log: %w", err)
+		}
+		if err := apply(line[:len(line)-1]); err != nil {
+			return n, fmt.Errorf("%w: record %d: %v", ErrWALCorrupt, n+1, err)
+		}
+		offset += int64(len(line))
+		n++
+	}
+	w.records = n
+	return n, nil
+}
+
+// mark flushes the log and returns its size, where a compaction's snapshot
+// starts
+func (w *WAL) mark() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.flushLocked(); err != nil {
+		return 0, err
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("compact write-ahead log: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// compact replaces the log with a snapshot followed by the records appended
+// after the mark the snapshot was taken from. Records are whole entry
+// states, so replaying one the snapshot already reflects changes nothing.
+func (w *WAL) compact(snapshot [][]byte, mark int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".compact-*")
+	if err != nil {
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	out := bufio.NewWriter(tmp)
+	for _, line := range snapshot {
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	tail := io.NewSectionReader(w.file, mark, info.Size()-mark)
+	counter := &lineCounter{w: out}
+	if _, err := io.Copy(counter, tail); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+	if err := out.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return fmt.Errorf("compact write-ahead log: %w", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		w.err = fmt.Errorf("reopen write-ahead log: %w", err)
+		return w.err
+	}
+	w.file.Close()
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.records = len(snapshot) + counter.lines
+	w.appended = 0
+	return nil
+}
+
+// lineCounter counts the records it copies
+type lineCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			c.lines++
+		}
+	}
+	return c.w.Write(p)
+}
+
+// RecoverWAL rebuilds the vault from the write-ahead log, or the store
+// holding it, and returns the number of records replayed. Call it once, on
+// an empty vault, before serving.
+func (s *Service) RecoverWAL() (int, error) {
+	if s.store == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.store.Replay(func(line []byte) error {
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		s.applyLocked(rec)
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	s.assignPARsLocked()
+	return n, nil
+}
+
+// FlushWAL writes and syncs buffered log records. Run it periodically when
+// the log does not sync every record: the interval bounds what a crash loses.
+func (s *Service) FlushWAL() error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.Flush()
+}
+
+// CompactWAL rewrites the log as a snapshot of the vault, dropping the
+// records of entries that have since changed or been removed. Mutations may
+// continue meanwhile. A vault being migrated between stores is not
+// compacted.
+func (s *Service) CompactWAL() error {
+	if s.wal == nil {
+		return nil
+	}
+	mark, err := s.wal.mark()
+	if err != nil {
+		return err
+	}
+	snapshot, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	return s.wal.compact(snapshot, mark)
+}
+
+// snapshot encodes every vault entry as a log record. Data keys come before
+// the tokens they encrypt, and tokens in issue order, so replay points the
+// PAN hash index at each card's newest token.
+func (s *Service) snapshot() ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lines [][]byte
+	add := func(rec walRecord) error {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("compact write-ahead log: %w", err)
+		}
+		lines = append(lines, line)
+		return nil
+	}
+	for _, key := range s.dataKeys {
+		if err := add(walRecord{Op: walPutDataKey, DataKey: key}); err != nil {
+			return nil, err
+		}
+	}
+	tokens := make([]*TokenData, 0, len(s.tokens))
+	for _, tokenData := range s.tokens {
+		tokens = append(tokens, tokenData)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	for _, tokenData := range tokens {
+		tokenData.mu.RLock()
+		err := add(walRecord{Op: walPutToken, Token: tokenData})
+		tokenData.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, nt := range s.networkTokens {
+		if err := add(walRecord{Op: walPutNetworkToken, NetworkToken: nt}); err != nil {
+			return nil, err
+		}
+	}
+	for _, link := range s.tokenLinks {
+		if err := add(walRecord{Op: walPutLink, Link: link}); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// applyLocked applies a replayed record. A token seen for the first time is
+// the newest of its card and merchant, as tokens are logged in issue order.
+// s.mu must be held.
+func (s *Service) applyLocked(rec walRecord) {
+	switch rec.Op {
+	case walPutToken:
+		if rec.Token == nil {
+			return
+		}
+		old, exists := s.tokens[rec.Token.Token]
+		if !exists {
+			s.panHashIndex[rec.Token.MerchantID+":"+rec.Token.PANHash] = rec.Token.Token
+		}
+		s.tokens[rec.Token.Token] = rec.Token
+		s.trackLocked(old, rec.Token)
+		if rec.Token.PAR != "" {
+			s.parIndex[rec.Token.PANHash] = rec.Token.PAR
+		}
+	case walDeleteToken:
+		if tokenData, ok := s.tokens[rec.ID]; ok {
+			delete(s.tokens, rec.ID)
+			s.trackLocked(tokenData, nil)
+			indexKey := tokenData.MerchantID + ":" + tokenData.PANHash
+			if s.panHashIndex[indexKey] == rec.ID {
+				delete(s.panHashIndex, indexKey)
+			}
+		}
+	case walPutDataKey:
+		if rec.DataKey != nil {
+			s.dataKeys[rec.DataKey.ID] = rec.DataKey
+		}
+	case walDeleteDataKey:
+		delete(s.dataKeys, rec.ID)
+	case walPutNetworkToken:
+		if rec.NetworkToken != nil {
+			s.networkTokens[rec.NetworkToken.Token] = rec.NetworkToken
+			if rec.NetworkToken.PAR != "" {
+				s.parIndex[rec.NetworkToken.PANHash] = rec.NetworkToken.PAR
+			}
+		}
+	case walDeleteNetwork:
+		delete(s.networkTokens, rec.ID)
+		delete(s.tokenLinks, rec.ID)
+	case walPutLink:
+		if rec.Link != nil {
+			s.tokenLinks[rec.Link.NetworkToken] = rec.Link
+		}
+	}
+}
+
+// record encodes a mutation once and hands it to the write-ahead log, or the
+// store holding the vault, and the change feed, whichever are configured
+func (s *Service) record(rec walRecord) {
+	if s.store == nil && s.feed == nil {
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if s.store != nil {
+		s.store.Append(line)
+	}
+	if s.feed != nil {
+		s.feed.publish(line)
+	}
+}
+
+// logToken logs a token's state. The caller holds tokenData.mu, or s.mu for
+// a token not yet shared.
+func (s *Service) logToken(tokenData *TokenData) {
+	s.record(walRecord{Op: walPutToken, Token: tokenData})
+}
+
+// logRemoval logs the removal of a token, data key or network token
+func (s *Service) logRemoval(op walOp, id string) {
+	s.record(walRecord{Op: op, ID: id})
+}
+
+// logDataKey logs a new data key. s.mu must be held.
+func (s *Service) logDataKey(key *dataKey) {
+	s.record(walRecord{Op: walPutDataKey, DataKey: key})
+}
+
+// logNetworkToken logs a network token's state. s.mu must be held.
+func (s *Service) logNetworkToken(nt *NetworkToken) {
+	s.record(walRecord{Op: walPutNetworkToken, NetworkToken: nt})
+}
+
+// logLink logs a link record. s.mu must be held.
+func (s *Service) logLink(link *TokenLink) {
+	s.record(walRecord{Op: walPutLink, Link: link})
+}
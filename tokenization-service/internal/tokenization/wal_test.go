@@ -0,0 +1,149 @@
+package tokenization
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestWAL(t *testing.T, path string, opts ...Option) *Service {
+	t.Helper()
+	wal, err := OpenWAL(path, WALConfig{Sync: true})
+	if err != nil {
+		t.Fatalf("OpenWAL() error = %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, append(opts, WithWAL(wal))...)
+	if _, err := service.RecoverWAL(); err != nil {
+		t.Fatalf("RecoverWAL() error = %v", err)
+	}
+	return service
+}
+
+func TestWALRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	year := time.Now().Year() + 2
+	service := openTestWAL(t, path)
+
+	kept, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1", Metadata: map[string]string{"order": "42"}})
+	revoked, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	deleted, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	forgotten, _ := service.TokenizeCardWithOptions("371449635398431", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	service.RevokeTokenForMerchant(revoked.Token, "m1", AnyRevision)
+	service.DeleteToken(deleted.Token, "m2", AnyRevision)
+	nt, _ := service.ProvisionNetworkToken(kept.Token, "m1")
+	fingerprint, _ := Fingerprint("371449635398431")
+	service.ForgetCard(fingerprint)
+
+	// A restarted service sees the vault as it was
+	recovered := openTestWAL(t, path)
+	if pan, _, _, err := recovered.DetokenizeCardForMerchant(kept.Token, "m1"); err != nil || pan != "4532015112830366" {
+		t.Errorf("DetokenizeCardForMerchant() after recovery = %q, %v", pan, err)
+	}
+	if again, _ := recovered.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"}); again.Token != kept.Token {
+		t.Errorf("tokenizing again after recovery = %q, want the existing %q", again.Token, kept.Token)
+	}
+	if info, err := recovered.TokenInfo(kept.Token, "m1"); err != nil || info.Metadata["order"] != "42" {
+		t.Errorf("TokenInfo() after recovery = %+v, %v", info, err)
+	}
+	if valid, _ := recovered.ValidateTokenForMerchant(revoked.Token, "m1"); valid {
+		t.Error("revoked token is valid after recovery")
+	}
+	if _, err := recovered.RestoreToken(deleted.Token, "m2", AnyRevision); err != nil {
+		t.Errorf("RestoreToken() after recovery error = %v", err)
+	}
+	if _, err := recovered.TokenInfo(forgotten.Token, "m1"); err != ErrTokenNotFound {
+		t.Errorf("forgotten token after recovery error = %v, want %v", err, ErrTokenNotFound)
+	}
+	if got, err := recovered.VaultTokenFor(nt.Token, "m1"); err != nil || got.Token != kept.Token {
+		t.Errorf("VaultTokenFor() after recovery = %v, %v, want %q", got, err, kept.Token)
+	}
+}
+
+func TestWALRecoversShreddedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	year := time.Now().Year() + 2
+	service := openTestWAL(t, path, WithKeyScope(KeyScopeMerchant))
+
+	shredded, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	kept, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m2"})
+	service.ShredMerchant("m1")
+
+	recovered := openTestWAL(t, path, WithKeyScope(KeyScopeMerchant))
+	if _, _, _, err := recovered.DetokenizeCardForMerchant(kept.Token, "m2"); err != nil {
+		t.Errorf("DetokenizeCardForMerchant() under a kept key error = %v", err)
+	}
+	if valid, _ := recovered.ValidateTokenForMerchant(shredded.Token, "m1"); valid {
+		t.Error("shredded token is valid after recovery")
+	}
+	if stats := recovered.Stats(); stats.DataKeys != 1 || stats.Shredded != 1 {
+		t.Errorf("Stats() after recovery = %d data keys, %d shredded, want 1 and 1", stats.DataKeys, stats.Shredded)
+	}
+}
+
+func TestWALTornAndCorruptRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	year := time.Now().Year() + 2
+	service := openTestWAL(t, path)
+	tokenData, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+
+	// A crash mid-write leaves a partial last record, which is dropped
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"op":"put-token","token":{"Tok`)
+	f.Close()
+	recovered := openTestWAL(t, path)
+	if _, err := recovered.TokenInfo(tokenData.Token, "m1"); err != nil {
+		t.Errorf("TokenInfo() after torn write error = %v", err)
+	}
+	recovered.RevokeTokenForMerchant(tokenData.Token, "m1", AnyRevision)
+	if recovered = openTestWAL(t, path); recovered.wal.Records() != 2 {
+		t.Errorf("Records() = %d, want the two whole records", recovered.wal.Records())
+	}
+
+	// Damage before the last record is not a crash and is refused
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append([]byte("garbage\n"), data...), 0600)
+	wal, _ := OpenWAL(path, WALConfig{})
+	defer wal.Close()
+	if _, err := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithWAL(wal)).RecoverWAL(); !errors.Is(err, ErrWALCorrupt) {
+		t.Errorf("RecoverWAL() of corrupt log error = %v, want %v", err, ErrWALCorrupt)
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.wal")
+	year := time.Now().Year() + 2
+	wal, _ := OpenWAL(path, WALConfig{})
+	service := NewService(&MockHSMClient{}, "test-key", 24*time.Hour, WithWAL(wal))
+
+	tokenData, _ := service.TokenizeCardWithOptions("4532015112830366", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	for i := 0; i < 5; i++ {
+		service.DeleteToken(tokenData.Token, "m1", AnyRevision)
+		service.RestoreToken(tokenData.Token, "m1", AnyRevision)
+	}
+	purged, _ := service.TokenizeCardWithOptions("5425233430109903", 12, year, "123", TokenizeOptions{MerchantID: "m1"})
+	service.DeleteToken(purged.Token, "m1", AnyRevision)
+	service.PurgeDeletedTokens(time.Now().Add(time.Second))
+	if wal.Appended() != 14 {
+		t.Fatalf("Appended() = %d, want 14", wal.Appended())
+	}
+
+	if err := service.CompactWAL(); err != nil {
+		t.Fatalf("CompactWAL() error = %v", err)
+	}
+	if wal.Records() != 1 || wal.Appended() != 0 {
+		t.Errorf("after compaction Records() = %d, Appended() = %d, want 1 and 0", wal.Records(), wal.Appended())
+	}
+	service.RevokeTokenForMerchant(tokenData.Token, "m1", AnyRevision)
+	wal.Close()
+
+	recovered := openTestWAL(t, path)
+	if info, err := recovered.TokenInfo(tokenData.Token, "m1"); err != nil || info.IsActive {
+		t.Errorf("TokenInfo() after compaction = %+v, %v, want the revoked token", info, err)
+	}
+	if _, err := recovered.TokenInfo(purged.Token, "m1"); err != ErrTokenNotFound {
+		t.Errorf("purged token after compaction error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
@@ -0,0 +1,109 @@
+// Package simulator embeds the HSM simulator and the tokenization service in
+// the calling program, for tests that want real card vaulting and
+// encryption without starting servers. The tokenization service calls the
+// HSM directly instead of over gRPC, so there are no listeners and no
+// generated code, and a Simulator is cheap enough to create per test.
+//
+// The authorization service is a Spring Boot application and cannot be
+// embedded; tests that need it run it as a process (see e2e/).
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/paymentgateway/hsm-simulator/pkg/embedded"
+	"github.com/paymentgateway/tokenization-service/internal/tokenization"
+)
+
+// KeyID is the HSM key the tokenization service encrypts PANs under, as in
+// the standalone service
+const KeyID = "tokenization-key-1"
+
+// DefaultTokenTTL is how long issued tokens stay valid unless WithTokenTTL
+// says otherwise
+const DefaultTokenTTL = 24 * time.Hour * 365
+
+type (
+	// Tokenization is the tokenization service's vault
+	Tokenization = tokenization.Service
+	// TokenData is a vaulted card and its token
+	TokenData = tokenization.TokenData
+	// TokenizeOptions scopes a token to a merchant and sets its metadata
+	// and TTL
+	TokenizeOptions = tokenization.TokenizeOptions
+	// TokenFilter selects tokens to list
+	TokenFilter = tokenization.TokenFilter
+	// TokenSummary is a listed token, without card data
+	TokenSummary = tokenization.TokenSummary
+)
+
+// Errors returned by the tokenization service
+var (
+	ErrInvalidPAN       = tokenization.ErrInvalidPAN
+	ErrInvalidExpiry    = tokenization.ErrInvalidExpiry
+	ErrInvalidToken     = tokenization.ErrInvalidToken
+	ErrTokenNotFound    = tokenization.ErrTokenNotFound
+	ErrTokenExpired     = tokenization.ErrTokenExpired
+	ErrRevisionMismatch = tokenization.ErrRevisionMismatch
+)
+
+// Simulator holds the embedded services
+type Simulator struct {
+	// HSM is the HSM simulator the vault encrypts with. Tests may rotate
+	// or destroy its keys to exercise the vault's error paths.
+	HSM *embedded.HSM
+	// Tokenization is the card vault
+	Tokenization *Tokenization
+}
+
+type config struct {
+	hsm        *embedded.HSM
+	hsmOptions []embedded.Option
+	tokenTTL   time.Duration
+}
+
+// Option configures New
+type Option func(*config)
+
+// WithTokenTTL sets how long issued tokens stay valid
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(c *config) { c.tokenTTL = ttl }
+}
+
+// WithHSMProfile makes the HSM take the latency of a real device, for
+// tests of timeouts and throughput
+func WithHSMProfile(p embedded.Profile) Option {
+	return func(c *config) { c.hsmOptions = append(c.hsmOptions, embedded.WithProfile(p)) }
+}
+
+// WithHSM uses an existing HSM instead of a new one, so several simulators
+// can share keys. The HSM options of the other Options are then ignored.
+func WithHSM(h *embedded.HSM) Option {
+	return func(c *config) { c.hsm = h }
+}
+
+// New creates an HSM, generates the tokenization key in it and starts a
+// tokenization service on top. Each Simulator has its own vault.
+func New(opts ...Option) (*Simulator, error) {
+	cfg := config{tokenTTL: DefaultTokenTTL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.tokenTTL <= 0 {
+		return nil, fmt.Errorf("simulator: token TTL must be positive, got %s", cfg.tokenTTL)
+	}
+
+	h := cfg.hsm
+	if h == nil {
+		h = embedded.New(cfg.hsmOptions...)
+	}
+	if _, err := h.GenerateKey(KeyID, "AES-256-GCM"); err != nil && !errors.Is(err, embedded.ErrKeyExists) {
+		return nil, fmt.Errorf("simulator: generate %s: %w", KeyID, err)
+	}
+	return &Simulator{
+		HSM:          h,
+		Tokenization: tokenization.NewService(h, KeyID, cfg.tokenTTL),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package simulator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testPAN = "4111111111111111"
+
+func TestTokenizeAndDetokenize(t *testing.T) {
+	sim, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	expiryYear := time.Now().Year() + 2
+	data, err := sim.Tokenization.TokenizeCardWithOptions(testPAN, 12, expiryYear, "123", TokenizeOptions{MerchantID: "m1"})
+	if err != nil {
+		t.Fatalf("TokenizeCardWithOptions() error = %v", err)
+	}
+	if data.LastFour != "1111" {
+		t.Errorf("LastFour = %q, want 1111", data.LastFour)
+	}
+
+	pan, month, year, err := sim.Tokenization.DetokenizeCardForMerchant(data.Token, "m1")
+	if err != nil {
+		t.Fatalf("DetokenizeCardForMerchant() error = %v", err)
+	}
+	if pan != testPAN || month != 12 || year != expiryYear {
+		t.Errorf("DetokenizeCardForMerchant() = %d/%d, want the tokenized card", month, year)
+	}
+	if _, _, _, err := sim.Tokenization.DetokenizeCardForMerchant(data.Token, "m2"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("DetokenizeCardForMerchant() by another merchant error = %v, want %v", err, ErrTokenNotFound)
+	}
+
+	key, err := sim.HSM.GetKeyInfo(KeyID)
+	if err != nil {
+		t.Fatalf("GetKeyInfo() error = %v", err)
+	}
+	if key.KeyID != KeyID {
+		t.Errorf("KeyID = %q, want %q", key.KeyID, KeyID)
+	}
+}
+
+func TestSharedHSM(t *testing.T) {
+	first, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	second, err := New(WithHSM(first.HSM))
+	if err != nil {
+		t.Fatalf("New(WithHSM) error = %v", err)
+	}
+	if second.HSM != first.HSM {
+		t.Fatal("second simulator does not use the shared HSM")
+	}
+
+	// Vaults stay separate
+	data, err := first.Tokenization.TokenizeCard(testPAN, 12, time.Now().Year()+2, "123")
+	if err != nil {
+		t.Fatalf("TokenizeCard() error = %v", err)
+	}
+	if _, _, _, err := second.Tokenization.DetokenizeCard(data.Token); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("DetokenizeCard() in another vault error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
+
+func TestInvalidTokenTTL(t *testing.T) {
+	if _, err := New(WithTokenTTL(0)); err == nil {
+		t.Error("New(WithTokenTTL(0)) error = nil, want an error")
+	}
+}